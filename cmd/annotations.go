@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/ghactions"
+)
+
+// annotationOutputLines is how much of the output of a failed check an
+// annotation holds when the failures in it cannot be told apart
+const annotationOutputLines = 20
+
+// annotating reports whether failed checks are printed as GitHub Actions
+// annotations, which they are in --ci runs on GitHub Actions
+func annotating() bool {
+	return ciMode && ghactions.Running()
+}
+
+// annotateReview prints an annotation for each test failure and lint finding
+// of a review of targetDir, so they show inline on the pull request
+func annotateReview(targetDir string, result *ReviewResult) {
+	if !annotating() || result == nil {
+		return
+	}
+	var annotations []ghactions.Annotation
+	if !result.TestsPassed {
+		annotations = append(annotations, testAnnotations(targetDir, result.TestOutput)...)
+	}
+	if !result.LintPassed {
+		annotations = append(annotations, lintAnnotations(targetDir, result.LintFindings, result.LintOutput)...)
+	}
+	for _, annotation := range annotations {
+		fmt.Println(annotation)
+	}
+}
+
+// testAnnotations returns the annotations of the failures in the output of
+// a test command run in targetDir: one per failing go test or build error,
+// or one holding the end of the output for other test runners
+func testAnnotations(targetDir, output string) []ghactions.Annotation {
+	failures := ghactions.ParseGoTest(output)
+	if len(failures) == 0 {
+		return []ghactions.Annotation{{Level: ghactions.Error, Title: "Tests failed", Message: ghactions.Summary(output, annotationOutputLines)}}
+	}
+	var annotations []ghactions.Annotation
+	for _, failure := range failures {
+		annotation := ghactions.Annotation{Level: ghactions.Error, Line: failure.Line, Column: failure.Column, Message: failure.Message}
+		var file string
+		if failure.Test == "" {
+			annotation.Title = "Build failed: " + failure.Package
+			if failure.File != "" {
+				file = filepath.Join(targetDir, failure.File)
+			}
+		} else {
+			annotation.Title = "Test failed: " + failure.Test
+			if dir := goPackageDir(targetDir, failure.Package); dir != "" && failure.File != "" {
+				file = filepath.Join(dir, failure.File)
+			}
+		}
+		annotation.File = repositoryPath(file)
+		annotations = append(annotations, annotation)
+	}
+	return annotations
+}
+
+// lintAnnotations returns an annotation per lint finding, or one holding the
+// end of the output when the linter's findings could not be parsed
+func lintAnnotations(targetDir string, findings []agent.LintIssue, output string) []ghactions.Annotation {
+	if len(findings) == 0 {
+		return []ghactions.Annotation{{Level: ghactions.Error, Title: "Lint failed", Message: ghactions.Summary(output, annotationOutputLines)}}
+	}
+	var annotations []ghactions.Annotation
+	for _, finding := range findings {
+		file := finding.File
+		if file != "" && !filepath.IsAbs(file) {
+			file = filepath.Join(targetDir, file)
+		}
+		title := finding.Linter
+		if finding.Rule != "" {
+			title += " (" + finding.Rule + ")"
+		}
+		annotations = append(annotations, ghactions.Annotation{
+			Level:   ghactions.LintLevel(finding.Severity),
+			File:    repositoryPath(file),
+			Line:    finding.Line,
+			Column:  finding.Column,
+			Title:   strings.TrimSpace(title),
+			Message: finding.Message,
+		})
+	}
+	return annotations
+}
+
+// goPackageDir returns the directory of the package with importPath in the
+// Go module holding dir, or "" when it is not in that module
+func goPackageDir(dir, importPath string) string {
+	for {
+		if module := goModulePath(filepath.Join(dir, "go.mod")); module != "" {
+			if importPath == module {
+				return dir
+			}
+			if rest, ok := strings.CutPrefix(importPath, module+"/"); ok {
+				return filepath.Join(dir, filepath.FromSlash(rest))
+			}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// goModulePath returns the module path declared by the go.mod at path, or ""
+func goModulePath(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`)
+		}
+	}
+	return ""
+}
+
+// repositoryPath returns path relative to the root of the repository the
+// workflow checked out, as annotations name files, or "" when path is not
+// an existing file in it
+func repositoryPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	root := os.Getenv("GITHUB_WORKSPACE")
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return ""
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	if info, err := os.Stat(abs); err != nil || info.IsDir() {
+		return ""
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/orchestrator"
+	"github.com/castrovroberto/CGE/internal/policy"
+	"github.com/spf13/cobra"
+)
+
+// stdinIsTerminal reports whether the user can be prompted on stdin; --ci
+// runs never prompt
+func stdinIsTerminal() bool {
+	if ciMode {
+		return false
+	}
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalApprover asks for checkpoint decisions on the terminal
+type terminalApprover struct {
+	in *bufio.Reader
+}
+
+// Approve implements orchestrator.Approver
+func (a *terminalApprover) Approve(ctx context.Context, req orchestrator.ApprovalRequest) (orchestrator.Decision, string, string, error) {
+	fmt.Printf("\n⏸️  Checkpoint %s: %s\n", req.Checkpoint, req.Subject)
+	if req.Summary != "" {
+		fmt.Println(req.Summary)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", "", "", err
+		}
+		fmt.Print("Approve? [a]pprove, [r]eject, [e]dit: ")
+		answer, err := a.in.ReadString('\n')
+		if err != nil && answer == "" {
+			return "", "", "", err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "a", "approve", "y", "yes":
+			return orchestrator.DecisionApprove, "", "", nil
+		case "r", "reject", "n", "no":
+			fmt.Print("Reason (optional): ")
+			reason, _ := a.in.ReadString('\n')
+			return orchestrator.DecisionReject, "", strings.TrimSpace(reason), nil
+		case "e", "edit":
+			edited, err := editInEditor(req.Content)
+			if err != nil {
+				fmt.Printf("❌ Failed to edit: %v\n", err)
+				continue
+			}
+			if edited == req.Content {
+				return orchestrator.DecisionApprove, "", "approved without changes in the editor", nil
+			}
+			return orchestrator.DecisionEdit, edited, "", nil
+		}
+	}
+}
+
+// editInEditor opens content in $VISUAL or $EDITOR and returns it as saved
+func editInEditor(content string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	file, err := os.CreateTemp("", "cge-edit-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	// The editor may come with arguments, e.g. "code --wait"
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], file.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", parts[0], err)
+	}
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(edited), nil
+}
+
+// reviewApprovalGate builds the approval gate of a review run. Interactive
+// runs ask at the configured checkpoints (or --checkpoints); other runs apply
+// the [commands.review.approval] policies. autoApprove and dryRun decide
+// every checkpoint without asking: approving everything, or changing nothing.
+func reviewApprovalGate(cmd *cobra.Command, cfg *config.AppConfig, autoApprove, dryRun bool) (*orchestrator.ApprovalGate, error) {
+	names := cfg.Commands.Review.Checkpoints
+	if cmd.Flags().Changed("checkpoints") {
+		names, _ = cmd.Flags().GetStringSlice("checkpoints")
+	}
+	checkpoints, err := orchestrator.ParseCheckpoints(names)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := orchestrator.ParsePolicies(cfg.Commands.Review.Approval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid [commands.review.approval] configuration: %w", err)
+	}
+
+	switch {
+	case dryRun:
+		return orchestrator.NewApprovalGate(orchestrator.Checkpoints, map[orchestrator.Checkpoint]orchestrator.Decision{
+			orchestrator.CheckpointPlan:       orchestrator.DecisionApprove,
+			orchestrator.CheckpointFileChange: orchestrator.DecisionReject,
+			orchestrator.CheckpointCommit:     orchestrator.DecisionReject,
+		}, nil), nil
+	case autoApprove:
+		return orchestrator.NewApprovalGate(orchestrator.Checkpoints, nil, nil), nil
+	case stdinIsTerminal():
+		return orchestrator.NewApprovalGate(checkpoints, policies, &terminalApprover{in: bufio.NewReader(os.Stdin)}), nil
+	default:
+		return orchestrator.NewApprovalGate(checkpoints, policies, nil), nil
+	}
+}
+
+// reviewSession records the approval decisions of a review run in a
+// session, so they can be audited with 'CGE session info'
+type reviewSession struct {
+	manager *orchestrator.SessionManager
+	state   *orchestrator.SessionState
+	gate    *orchestrator.ApprovalGate
+}
+
+// newReviewSession creates and locks a review session in workspaceRoot
+func newReviewSession(workspaceRoot, model string, gate *orchestrator.ApprovalGate) (*reviewSession, error) {
+	manager, err := orchestrator.NewSessionManager(workspaceRoot, nil)
+	if err != nil {
+		return nil, err
+	}
+	state := manager.CreateSession("", model, "review", nil)
+	if err := manager.LockSession(state.SessionID); err != nil {
+		return nil, err
+	}
+	return &reviewSession{manager: manager, state: state, gate: gate}, nil
+}
+
+// check decides req at the gate, recording the decision in the session
+func (s *reviewSession) check(ctx context.Context, req orchestrator.ApprovalRequest) (*orchestrator.ApprovalDecision, error) {
+	decision, err := s.gate.Check(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	s.save()
+	return decision, nil
+}
+
+// save writes the decisions so far to the session
+func (s *reviewSession) save() {
+	s.state.Approvals = s.gate.Decisions()
+	if err := s.manager.SaveSession(s.state); err != nil {
+		fmt.Printf("⚠️  Failed to record approval decisions: %v\n", err)
+	}
+}
+
+// finish records the outcome of the review and releases the session
+func (s *reviewSession) finish(state string) {
+	s.manager.UpdateSessionState(s.state, state)
+	s.save()
+	s.manager.UnlockSession(s.state.SessionID)
+	if len(s.state.Approvals) > 0 {
+		fmt.Printf("📝 Approval decisions recorded in session %s\n", s.state.SessionID)
+	}
+}
+
+// errNothingToCommit is returned by commitReviewChanges without changes
+var errNothingToCommit = errors.New("no changes to commit")
+
+// commitReviewChanges commits files, given as absolute paths, in the git
+// repository of dir with message, once the commit checkpoint approves it. The
+// message follows the repository's commit conventions. It reports whether a
+// commit was made.
+func commitReviewChanges(ctx context.Context, dir string, files []string, message string, conventions agent.CommitConventions, check func(context.Context, orchestrator.ApprovalRequest) (*orchestrator.ApprovalDecision, error)) (bool, error) {
+	if len(files) == 0 {
+		return false, errNothingToCommit
+	}
+	conventions = agent.LoadCommitConventions(dir, conventions)
+	message, _, err := conventions.Apply(ctx, dir, message, files)
+	if err != nil {
+		return false, err
+	}
+	shown := make([]string, len(files))
+	for i, file := range files {
+		shown[i] = file
+		if rel, err := filepath.Rel(dir, file); err == nil {
+			shown[i] = rel
+		}
+	}
+	decision, err := check(ctx, orchestrator.ApprovalRequest{
+		Checkpoint: orchestrator.CheckpointCommit,
+		Subject:    fmt.Sprintf("%d file(s)", len(files)),
+		Summary:    fmt.Sprintf("Files:\n  %s\n\nMessage:\n%s", strings.Join(shown, "\n  "), message),
+		Content:    message,
+	})
+	if err != nil {
+		return false, err
+	}
+	if !decision.Approved() {
+		return false, nil
+	}
+	if decision.Decision == orchestrator.DecisionEdit {
+		message = strings.TrimSpace(decision.Content)
+	}
+	if problems := conventions.Validate(message); len(problems) > 0 {
+		return false, fmt.Errorf("commit message breaks the repository conventions: %s", strings.Join(problems, "; "))
+	}
+	if err := checkCommitPolicy(ctx, "", files, message); err != nil {
+		return false, err
+	}
+
+	add := exec.CommandContext(ctx, "git", append([]string{"add", "--"}, files...)...)
+	add.Dir = dir
+	if output, err := add.CombinedOutput(); err != nil {
+		return false, fmt.Errorf("git add failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	commit := exec.CommandContext(ctx, "git", append([]string{"commit", "-m", message, "--"}, files...)...)
+	commit.Dir = dir
+	if output, err := commit.CombinedOutput(); err != nil {
+		return false, fmt.Errorf("git commit failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return true, nil
+}
+
+// checkCommitPolicy evaluates committing files with message against the
+// [policy] of the configuration in ctx. Relative files are relative to root,
+// the workspace when empty.
+func checkCommitPolicy(ctx context.Context, root string, files []string, message string) error {
+	cfg := contextkeys.ConfigPtrFromContext(ctx)
+	if cfg == nil {
+		return nil
+	}
+	if root == "" {
+		root = stateWorkspaceRoot(cfg)
+	}
+	engine, err := policy.New(root, cfg.GetPolicy())
+	if err != nil {
+		return fmt.Errorf("invalid [policy]: %w", err)
+	}
+	if denial := engine.Check(ctx, policy.Input{Action: policy.ActionCommit, Files: files, CommitMessage: message}); denial != nil {
+		return fmt.Errorf("commit %w", denial)
+	}
+	return nil
+}
+
+// changedFiles returns the absolute paths of the files git reports as changed
+// or untracked under dir
+func changedFiles(ctx context.Context, dir string) (map[string]bool, error) {
+	toplevel := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel")
+	toplevel.Dir = dir
+	root, err := toplevel.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository: %w", dir, err)
+	}
+	status := exec.CommandContext(ctx, "git", "status", "--porcelain", "--untracked-files=all", "--", ".")
+	status.Dir = dir
+	output, err := status.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status failed: %w", err)
+	}
+	files := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		path := line[3:]
+		if _, to, renamed := strings.Cut(path, " -> "); renamed {
+			path = to
+		}
+		// Paths are relative to the top of the repository
+		files[filepath.Join(strings.TrimSpace(string(root)), filepath.FromSlash(path))] = true
+	}
+	return files, nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/castrovroberto/CGE/internal/bench"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchRun       string
+	benchShort     bool
+	benchList      bool
+	benchOutput    string
+	benchCompare   string
+	benchThreshold float64
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure chunking, vector search and prompt assembly performance",
+	Long: `Bench runs the benchmark suite of CGE on synthetic workloads, without a
+model or a workspace:
+
+  Chunk/<strategy>           chunking a 2000-line Go file by lines, tokens and
+                             semantic boundaries
+  Search/<backend>/<size>    top-10 search of the flat and HNSW vector store
+                             backends holding 1k, 10k and 50k vectors
+  Prompt/<strategy>/<turns>  assembling the prompt of a step from a history
+                             of 100 and 1000 turns under each history strategy
+
+Results are printed in the format of 'go test -bench', so the output of two
+releases can be compared with benchstat. --output saves them as JSON, and
+--compare compares the run with such a saved baseline, failing when a case
+got slower by more than --threshold percent.
+
+Building the 50k vector indexes takes a while; --short leaves out the largest
+cases.`,
+	Example: `  CGE bench --short
+  CGE bench --run 'Search/hnsw' > new.txt && benchstat old.txt new.txt
+  CGE bench --output bench-v1.2.0.json
+  CGE bench --compare bench-v1.2.0.json --threshold 15`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cases, err := bench.Select(bench.Cases(), benchRun, benchShort)
+		if err != nil {
+			return err
+		}
+		if len(cases) == 0 {
+			return fmt.Errorf("no benchmark matches %q", benchRun)
+		}
+		out := cmd.OutOrStdout()
+		if benchList {
+			for _, c := range cases {
+				fmt.Fprintln(out, c.Name)
+			}
+			return nil
+		}
+
+		var baseline *bench.Report
+		if benchCompare != "" {
+			if baseline, err = bench.LoadReport(benchCompare); err != nil {
+				return err
+			}
+		}
+
+		report := bench.NewReport()
+		report.WriteHeader(out)
+		for _, c := range cases {
+			if err := cmd.Context().Err(); err != nil {
+				return err
+			}
+			result := bench.Run(c)
+			report.Results = append(report.Results, result)
+			report.WriteResult(out, result)
+		}
+
+		if benchOutput != "" {
+			if err := report.Save(benchOutput); err != nil {
+				return fmt.Errorf("failed to save benchmark results: %w", err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "📊 Saved the results to %s\n", benchOutput)
+		}
+		if baseline != nil {
+			fmt.Fprintln(out)
+			if regressions := report.WriteComparison(out, baseline, benchThreshold/100); regressions > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d benchmark(s) slower than %s by more than %.0f%%", regressions, benchCompare, benchThreshold)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	benchCmd.Flags().StringVar(&benchRun, "run", "", "Run only the benchmarks whose name matches this regular expression")
+	benchCmd.Flags().BoolVar(&benchShort, "short", false, "Leave out the largest cases")
+	benchCmd.Flags().BoolVar(&benchList, "list", false, "List the benchmarks instead of running them")
+	benchCmd.Flags().StringVarP(&benchOutput, "output", "o", "", "Save the results as JSON to this file")
+	benchCmd.Flags().StringVar(&benchCompare, "compare", "", "Compare the results with those saved by --output of an earlier run")
+	benchCmd.Flags().Float64Var(&benchThreshold, "threshold", 10, "Percentage by which a benchmark may be slower than the --compare baseline")
+}
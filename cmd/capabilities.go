@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// fitToWorkflow checks that model can serve a workflow needing req, warning
+// about each capability it lacks and wrapping client to fall back where it
+// can
+func fitToWorkflow(ctx context.Context, cfg *config.AppConfig, client llm.Client, model string, req llm.Requirements) llm.Client {
+	client, warnings := llm.FitToWorkflow(client, cfg.LLM.Provider, model, cfg.LLM.ModelCapabilities, req)
+	logger := contextkeys.LoggerFromContext(ctx)
+	for _, warning := range warnings {
+		logger.Warn(warning)
+	}
+	return client
+}
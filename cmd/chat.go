@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/castrovroberto/CGE/internal/a11y"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
 	"github.com/castrovroberto/CGE/internal/di"
+	"github.com/castrovroberto/CGE/internal/llm"
 	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/statedir"
 	"github.com/castrovroberto/CGE/internal/tui/chat"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -37,8 +40,8 @@ Examples:
 		log := contextkeys.LoggerFromContext(ctx)
 
 		// Initialize TUI-safe logger to prevent logs from interfering with display
-		logFile := ".cge/chat.log"
-		if err := logger.InitLoggerForTUI(appCfg.Logging.Level, logFile); err != nil {
+		logFile := statedir.Path(stateWorkspaceRoot(appCfg), statedir.Logs, "chat.log")
+		if err := logger.InitLoggerForTUI(appCfg.Logging.Level, logFile, appCfg.GetLogRotation()); err != nil {
 			log.Warn("Failed to initialize TUI logger, logs may interfere with display", "error", err)
 		}
 
@@ -86,6 +89,13 @@ Examples:
 			log.Info("Loaded chat session", "session", sessionID)
 		}
 
+		if _, err := applyScope(cmd, appCfg); err != nil {
+			return err
+		}
+		if err := applyAllowProtected(cmd, appCfg); err != nil {
+			return err
+		}
+
 		log.Info("Starting chat session", "model", chatModelName)
 
 		// Create a context containing the global config and logger for downstream components
@@ -93,19 +103,37 @@ Examples:
 		ctx = context.WithValue(ctx, contextkeys.LoggerKey, log)
 
 		// Create dependency injection container
-		container := di.NewContainer(appCfg)
+		container, err := di.NewContainer(appCfg)
+		if err != nil {
+			return err
+		}
+		container.WithModelPullConfirm(confirmChatModelPull)
+		for _, warning := range container.FitLLMClient(chatModelName, llm.AgentRequirements) {
+			log.Warn(warning)
+		}
 
 		// Get system prompt and create chat presenter using DI container
 		systemPrompt := appCfg.GetLoadedChatSystemPrompt()
 		chatPresenter := container.GetChatPresenter(ctx, chatModelName, systemPrompt)
 
 		// Initialize chat model with dependency injection
-		chatAppModel := chat.NewChatModel(
+		commands := chat.NewCommandRegistry()
+		if err := commands.Register(modelCommand(ctx, container.GetLLMClient())); err != nil {
+			return err
+		}
+		chatOptions := []chat.ChatModelOption{
 			chat.WithParentContext(ctx),
+			chat.WithCommands(commands),
+			chat.WithModelName(chatModelName),
 			chat.WithInitialConfig(appCfg),
 			chat.WithMessageProvider(chatPresenter),
 			chat.WithDelayProvider(&chat.RealDelayProvider{}),
-		)
+			chat.WithModelLoader(container.GetModelLoader()),
+		}
+		if a11y.Enabled() {
+			chatOptions = append(chatOptions, chat.WithTheme(chat.NewAccessibleTheme()))
+		}
+		chatAppModel := chat.NewChatModel(chatOptions...)
 
 		// Load history if available
 		if history != nil {
@@ -145,6 +173,12 @@ Examples:
 			tea.WithAltScreen(),
 			tea.WithMouseAllMotion(),
 		}
+		if a11y.Enabled() {
+			// Screen readers follow the normal screen better than the
+			// alternate one, and the TUI draws to the terminal itself rather
+			// than through the plain-text output filter
+			programOptions = []tea.ProgramOption{tea.WithOutput(a11y.Stdout())}
+		}
 
 		// Add input sanitization for better terminal control sequence handling
 		programOptions = append(programOptions, tea.WithInputTTY())
@@ -164,5 +198,9 @@ func init() {
 	chatCmd.Flags().StringP("model", "m", "", "Model to use for the chat session (overrides default model in config)")
 	chatCmd.Flags().StringP("session", "s", "", "Session ID to continue a previous chat")
 	chatCmd.Flags().Bool("list-sessions", false, "List available chat sessions")
+	addProfileFlag(chatCmd)
+	addPprofFlag(chatCmd)
+	addScopeFlag(chatCmd)
+	addAllowProtectedFlag(chatCmd)
 	rootCmd.AddCommand(chatCmd)
 }
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/tui/chat"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// modelListTimeout bounds the request listing the models offered by /model
+const modelListTimeout = 10 * time.Second
+
+// modelCommand returns the /model slash command, which shows the active model
+// or switches to another. The models of the provider are fetched in the
+// background and offered as completions once they arrive.
+func modelCommand(ctx context.Context, client llm.Client) chat.SlashCommand {
+	var mu sync.Mutex
+	var models []string
+	go func() {
+		listCtx, cancel := context.WithTimeout(ctx, modelListTimeout)
+		defer cancel()
+		listed, err := client.ListAvailableModels(listCtx)
+		if err != nil {
+			contextkeys.LoggerFromContext(ctx).Debug("Could not list models for /model completion", "error", err)
+			return
+		}
+		mu.Lock()
+		models = listed
+		mu.Unlock()
+	}()
+
+	return chat.SlashCommand{
+		Name:  "/model",
+		Usage: "[name]",
+		Help:  "Show the active model, or switch to another",
+		Complete: func(args string) []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return models
+		},
+		Run: func(c *chat.CommandContext, args string) tea.Cmd {
+			if args == "" {
+				c.Notice(fmt.Sprintf("🤖 The active model is %s; /model <name> switches to another", c.ModelName()))
+				return nil
+			}
+			switcher, ok := c.Provider().(chat.ModelSwitcher)
+			if !ok {
+				c.Notice("🤖 This chat backend cannot switch models")
+				return nil
+			}
+			if err := switcher.SetModel(args); err != nil {
+				c.Notice(fmt.Sprintf("🤖 Could not switch to %s: %v", args, err))
+				return nil
+			}
+			c.SetModelName(args)
+			c.Notice(fmt.Sprintf("🤖 Switched to %s for the next messages", args))
+			return nil
+		},
+	}
+}
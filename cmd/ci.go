@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/policy"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of --ci runs, so pipelines can tell why a run failed
+const (
+	exitFailure        = 1 // Any other error, or the time limit was reached
+	exitTestsFailing   = 2
+	exitBudgetExceeded = 3
+	exitPolicyDenied   = 4
+)
+
+// timeLimitGrace is how long a run past its --time-limit has to wind down
+// once its context is cancelled, before the process exits regardless
+const timeLimitGrace = 30 * time.Second
+
+var (
+	ciMode         bool          // --ci
+	ciSummaryFile  string        // --ci-summary
+	runTimeLimit   time.Duration // --time-limit
+	runLimitCtx    context.Context
+	runLimitCancel context.CancelFunc
+
+	// deniedBeforeRun is the number of policy denials when the command started
+	deniedBeforeRun int
+
+	// runTestsFailing records that the tests of the workspace still fail when
+	// the command ends
+	runTestsFailing bool
+
+	// runBudgets are the clients enforcing the run budget
+	runBudgets []*llm.BudgetClient
+
+	finishOnce sync.Once
+)
+
+// addCIFlags adds --ci, --ci-summary and --time-limit to an agent command
+func addCIFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&ciMode, "ci", false, "Run non-interactively for CI: plain timestamped output, no prompts, exit code 2 when tests fail, 3 when the budget is exceeded, 4 on a policy denial")
+	c.Flags().StringVar(&ciSummaryFile, "ci-summary", "", "Write a JSON summary of the run to this file when it ends")
+	c.Flags().DurationVar(&runTimeLimit, "time-limit", 0, "Stop the run after this long, e.g. 30m; the process exits if it has not stopped 30s later")
+}
+
+// startRunLimits records the state the outcome of the run is measured
+// against and applies --time-limit to ctx
+func startRunLimits(ctx context.Context, cmd *cobra.Command) context.Context {
+	deniedBeforeRun = policy.Denials()
+	if ciMode {
+		fmt.Printf("CGE %s started\n", cmd.Name())
+	}
+	if runTimeLimit <= 0 {
+		return ctx
+	}
+	runLimitCtx, runLimitCancel = context.WithTimeout(ctx, runTimeLimit)
+	time.AfterFunc(runTimeLimit+timeLimitGrace, func() {
+		err := fmt.Errorf("time limit of %s reached: %w", runTimeLimit, context.DeadlineExceeded)
+		fmt.Fprintf(os.Stderr, "Error: %v; the run did not stop within %s\n", err, timeLimitGrace)
+		finishRun(context.Background(), cmd, err)
+		if restoreOutput != nil {
+			restoreOutput()
+		}
+		os.Exit(exitFailure)
+	})
+	return runLimitCtx
+}
+
+// timeLimitReached reports whether the run ended for --time-limit
+func timeLimitReached(err error) bool {
+	if runLimitCtx != nil && errors.Is(runLimitCtx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// budgetExceeded reports whether a request of the run was refused for the
+// run budget
+func budgetExceeded() bool {
+	for _, budget := range runBudgets {
+		if budget.Exceeded() {
+			return true
+		}
+	}
+	return false
+}
+
+// ExitCode returns the exit status of the command that returned err: 1 for
+// any error, or with --ci the code telling why the run failed
+func ExitCode(err error) int {
+	code, _ := runOutcome(err)
+	return code
+}
+
+// runOutcome returns the exit code of the run that ended with err and its
+// reason, as written to the summary
+func runOutcome(err error) (int, string) {
+	if !ciMode {
+		if err != nil {
+			return exitFailure, "error"
+		}
+		return 0, "success"
+	}
+	var denial *policy.Denial
+	switch {
+	case errors.As(err, &denial):
+		return exitPolicyDenied, "policy_denied"
+	case budgetExceeded() || errors.Is(err, llm.ErrBudgetExceeded):
+		return exitBudgetExceeded, "budget_exceeded"
+	case timeLimitReached(err):
+		return exitFailure, "time_limit"
+	case policy.Denials() > deniedBeforeRun:
+		return exitPolicyDenied, "policy_denied"
+	case runTestsFailing:
+		return exitTestsFailing, "tests_failing"
+	case err != nil:
+		return exitFailure, "error"
+	}
+	return 0, "success"
+}
+
+// ciSummary is the machine-readable summary --ci-summary writes
+type ciSummary struct {
+	Command           string   `json:"command"`
+	Task              string   `json:"task,omitempty"`
+	Workspace         string   `json:"workspace"`
+	Outcome           string   `json:"outcome"` // success, tests_failing, budget_exceeded, policy_denied, time_limit or error
+	ExitCode          int      `json:"exit_code"`
+	Error             string   `json:"error,omitempty"`
+	StartedAt         string   `json:"started_at"`
+	ElapsedSeconds    float64  `json:"elapsed_seconds"`
+	FilesChanged      []string `json:"files_changed"`
+	InputTokens       int      `json:"input_tokens"`
+	CachedInputTokens int      `json:"cached_input_tokens"`
+	OutputTokens      int      `json:"output_tokens"`
+	CostUSD           *float64 `json:"cost_usd"` // Null when the pricing of the model is unknown
+	BudgetUSD         float64  `json:"budget_usd,omitempty"`
+	PolicyDenials     int      `json:"policy_denials"`
+}
+
+// finishRun reports how a command that started ended, once: a closing line
+// in --ci mode and the --ci-summary file
+func finishRun(ctx context.Context, cmd *cobra.Command, runErr error) {
+	if cmd == nil || runStartTime.IsZero() {
+		return
+	}
+	finishOnce.Do(func() {
+		if runLimitCancel != nil {
+			defer runLimitCancel()
+		}
+		code, outcome := runOutcome(runErr)
+		if ciMode {
+			fmt.Printf("CGE %s finished after %s: %s, exit code %d\n", cmd.Name(), time.Since(runStartTime).Round(time.Second), outcome, code)
+		}
+		if ciSummaryFile == "" {
+			return
+		}
+		if err := writeCISummary(ctx, ciSummaryFile, cmd, runErr, code, outcome); err != nil {
+			logger.Get().Warn("Failed to write the run summary", "file", ciSummaryFile, "error", err)
+		}
+	})
+}
+
+// writeCISummary writes the summary of the run to path
+func writeCISummary(ctx context.Context, path string, cmd *cobra.Command, runErr error, code int, outcome string) error {
+	summary := runSummary(context.WithoutCancel(ctx), cmd, &config.Cfg, runErr)
+	report := ciSummary{
+		Command:           summary.Command,
+		Task:              summary.Task,
+		Workspace:         summary.Workspace,
+		Outcome:           outcome,
+		ExitCode:          code,
+		Error:             summary.Error,
+		StartedAt:         runStartTime.UTC().Format(time.RFC3339),
+		ElapsedSeconds:    summary.Elapsed.Seconds(),
+		FilesChanged:      summary.FilesChanged,
+		InputTokens:       summary.InputTokens,
+		CachedInputTokens: summary.CachedInputTokens,
+		OutputTokens:      summary.OutputTokens,
+		BudgetUSD:         config.Cfg.Budget.RunBudgetUSD,
+		PolicyDenials:     policy.Denials() - deniedBeforeRun,
+	}
+	if report.FilesChanged == nil {
+		report.FilesChanged = []string{}
+	}
+	if summary.CostKnown {
+		report.CostUSD = &summary.CostUSD
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// timestampWriter prefixes each line written with the current UTC time, for
+// the progress logs of --ci runs
+type timestampWriter struct {
+	w       io.Writer
+	midLine bool
+}
+
+// newTimestampWriter returns a timestampWriter writing to w
+func newTimestampWriter(w io.Writer) io.Writer {
+	return &timestampWriter{w: w}
+}
+
+// Write implements io.Writer
+func (tw *timestampWriter) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p)+32)
+	for _, b := range p {
+		if !tw.midLine {
+			out = append(out, time.Now().UTC().Format(time.RFC3339)+" "...)
+			tw.midLine = true
+		}
+		out = append(out, b)
+		if b == '\n' {
+			tw.midLine = false
+		}
+	}
+	if _, err := tw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/depgraph"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diagramFormat    string
+	diagramOutput    string
+	diagramEmbed     string
+	diagramLanguages []string
+	diagramDepth     int
+	diagramExternal  bool
+	diagramTests     bool
+	diagramDescribe  bool
+	diagramDirection string
+)
+
+var diagramCmd = &cobra.Command{
+	Use:   "diagram",
+	Short: "Draw the package dependency graph of the codebase",
+	Long: `Diagram builds the dependency graph of the packages of the workspace and
+prints it as a Mermaid flowchart or a Graphviz digraph. Go packages are linked
+by their import declarations, and JavaScript and TypeScript modules, grouped
+by directory, by their import, export and require statements.
+
+Nodes are labelled with the first sentence of their package comment. With
+--describe, the model writes a one-line description of the role of each
+package instead. --depth merges nested packages into their ancestors to keep
+large codebases readable, and --external adds third-party dependencies.
+
+--embed keeps a diagram up to date in a Markdown document: the diagram is
+written as a fenced code block between <!-- cge:diagram --> and
+<!-- /cge:diagram --> markers, replacing the one embedded before, or appended
+to the document the first time.`,
+	Example: `  CGE diagram
+  CGE diagram --format dot --output deps.dot && dot -Tsvg deps.dot -o deps.svg
+  CGE diagram --lang go --depth 2 --describe
+  CGE diagram --embed docs/architecture.md`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		cfg := contextkeys.ConfigFromContext(ctx)
+		format, err := depgraph.ParseFormat(diagramFormat)
+		if err != nil {
+			return err
+		}
+		options := depgraph.Options{Depth: diagramDepth, External: diagramExternal, Tests: diagramTests}
+		for _, name := range diagramLanguages {
+			language, err := depgraph.ParseLanguage(name)
+			if err != nil {
+				return err
+			}
+			options.Languages = append(options.Languages, language)
+		}
+
+		workspaceRoot := stateWorkspaceRoot(&cfg)
+		graph, err := depgraph.Build(workspaceRoot, options)
+		if err != nil {
+			return err
+		}
+		if len(graph.Nodes) == 0 {
+			return fmt.Errorf("no Go, JavaScript or TypeScript packages found in %s", workspaceRoot)
+		}
+		if diagramDescribe {
+			client, err := diagramLLMClient(&cfg)
+			if err != nil {
+				return err
+			}
+			contextkeys.LoggerFromContext(ctx).Info("Describing packages", "packages", len(graph.Nodes), "model", cfg.LLM.Model)
+			if err := depgraph.Describe(ctx, graph, client, cfg.LLM.Model, workspaceRoot); err != nil {
+				return err
+			}
+		}
+		diagram := depgraph.Render(graph, format, diagramDirection)
+
+		switch {
+		case diagramEmbed != "":
+			doc, err := os.ReadFile(diagramEmbed) // #nosec G304 - path given by the user
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to read %s: %w", diagramEmbed, err)
+			}
+			if err := os.WriteFile(diagramEmbed, []byte(depgraph.Embed(string(doc), diagram, format)), 0644); err != nil { // #nosec G306 - documentation
+				return fmt.Errorf("failed to write %s: %w", diagramEmbed, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "🗺️  Embedded the diagram of %d packages and %d dependencies in %s\n", len(graph.Nodes), len(graph.Edges), diagramEmbed)
+		case diagramOutput != "":
+			if err := os.WriteFile(diagramOutput, []byte(diagram), 0644); err != nil { // #nosec G306 - documentation
+				return fmt.Errorf("failed to write %s: %w", diagramOutput, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "🗺️  Wrote the diagram of %d packages and %d dependencies to %s\n", len(graph.Nodes), len(graph.Edges), diagramOutput)
+		default:
+			fmt.Fprint(cmd.OutOrStdout(), diagram)
+		}
+		return nil
+	},
+}
+
+// diagramLLMClient returns the client describing packages
+func diagramLLMClient(cfg *config.AppConfig) (llm.Client, error) {
+	var client llm.Client
+	switch cfg.LLM.Provider {
+	case "ollama":
+		client = withModelRecovery(llm.NewOllamaClient(cfg.GetOllamaConfig()))
+	case "openai":
+		client = llm.NewOpenAIClient(cfg.GetOpenAIConfig())
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
+	}
+	return meterRun(llm.WithConcurrencyLimits(client, cfg.GetConcurrencyConfig())), nil
+}
+
+func init() {
+	rootCmd.AddCommand(diagramCmd)
+	diagramCmd.Flags().StringVarP(&diagramFormat, "format", "f", "mermaid", "Output format: mermaid or dot (Graphviz)")
+	diagramCmd.Flags().StringVarP(&diagramOutput, "output", "o", "", "Write the diagram to a file instead of standard output")
+	diagramCmd.Flags().StringVar(&diagramEmbed, "embed", "", "Embed the diagram in a Markdown document, between <!-- cge:diagram --> markers")
+	diagramCmd.Flags().StringSliceVar(&diagramLanguages, "lang", nil, "Languages to include: "+strings.Join([]string{string(depgraph.LanguageGo), string(depgraph.LanguageJS)}, ", ")+" (default all)")
+	diagramCmd.Flags().IntVar(&diagramDepth, "depth", 0, "Merge packages nested deeper than this many directories into their ancestor")
+	diagramCmd.Flags().BoolVar(&diagramExternal, "external", false, "Include third-party dependencies")
+	diagramCmd.Flags().BoolVar(&diagramTests, "tests", false, "Include test files and their imports")
+	diagramCmd.Flags().BoolVar(&diagramDescribe, "describe", false, "Have the model describe the role of each package")
+	diagramCmd.Flags().StringVar(&diagramDirection, "direction", "LR", "Direction of the graph: LR (left to right) or TB (top to bottom)")
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cgecontext "github.com/castrovroberto/CGE/internal/context"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/doctor"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+// minFreeDiskSpace is the space the index and session directories should
+// have available
+const minFreeDiskSpace = 512 << 20
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the LLM provider, tools and disk space are usable",
+	Long: `Doctor probes the configured LLM provider and the local environment and
+prints a report, with a remediation tip for every problem found:
+
+- the provider answers, how long it takes, and the configured model exists
+- embeddings work, as needed by semantic search and the index command
+- git is installed and the workspace is a repository
+- the review test and lint commands resolve
+- there is enough disk space for the index and session directories
+
+It exits with a non-zero status when any check fails.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		cfg := contextkeys.ConfigFromContext(ctx)
+
+		var llmClient llm.Client
+		switch cfg.LLM.Provider {
+		case "ollama":
+			llmClient = llm.NewOllamaClient(cfg.GetOllamaConfig())
+		case "openai":
+			llmClient = llm.NewOpenAIClient(cfg.GetOpenAIConfig())
+		case "gemini":
+			llmClient = llm.NewGeminiClient(cfg.GetGeminiConfig())
+		default:
+			return fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
+		}
+
+		workspaceRoot := cfg.Project.WorkspaceRoot
+		if workspaceRoot == "" || workspaceRoot == "." {
+			var err error
+			workspaceRoot, err = os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current directory: %w", err)
+			}
+		}
+
+		checks := []doctor.Check{
+			doctor.ProviderCheck(llmClient, cfg.LLM.Provider, cfg.LLM.Model),
+			doctor.EmbeddingCheck(llmClient),
+			doctor.GitCheck(workspaceRoot),
+			doctor.CommandCheck("Test command", cfg.Commands.Review.TestCommand, "commands.review.test_command", workspaceRoot),
+			doctor.CommandCheck("Lint command", cfg.Commands.Review.LintCommand, "commands.review.lint_command", workspaceRoot),
+			doctor.DiskSpaceCheck("Index directory", filepath.Dir(cgecontext.IndexFilePath(workspaceRoot)), minFreeDiskSpace),
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			checks = append(checks, doctor.DiskSpaceCheck("Session directory", filepath.Join(home, ".cge"), minFreeDiskSpace))
+		}
+
+		results := doctor.Run(ctx, checks...)
+		doctor.WriteReport(cmd.OutOrStdout(), results)
+		if doctor.Failed(results) {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("some checks failed")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -8,9 +8,11 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/castrovroberto/CGE/internal/apispec"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
 	"github.com/castrovroberto/CGE/internal/llm"
 	"github.com/castrovroberto/CGE/internal/security"
+	"github.com/castrovroberto/CGE/internal/teamsync"
 	"github.com/castrovroberto/CGE/internal/templates"
 	"github.com/spf13/cobra"
 )
@@ -35,10 +37,17 @@ You can run in different modes:
 - Apply: Directly apply changes to the codebase
 - Output: Save generated diffs to a specified directory
 
+With --sandbox, changes are made in a git worktree on a new branch, which is
+merged, squashed or kept when the run succeeds and discarded when it fails.
+With --snapshot, the workspace is captured first, so 'CGE snapshot restore'
+can return to it and the run can be repeated from the same files.
+
 Example:
   CGE generate --plan plan.json --dry-run
   CGE generate --plan plan.json --apply
-  CGE generate --plan plan.json --output-dir ./generated_changes`,
+  CGE generate --plan plan.json --output-dir ./generated_changes
+  CGE generate --plan plan.json --apply --sandbox`,
+	Annotations: map[string]string{notifyAnnotation: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 		logger := contextkeys.LoggerFromContext(ctx)
@@ -60,7 +69,7 @@ Example:
 		switch cfg.LLM.Provider {
 		case "ollama":
 			ollamaConfig := cfg.GetOllamaConfig()
-			llmClient = llm.NewOllamaClient(ollamaConfig)
+			llmClient = withModelRecovery(llm.NewOllamaClient(ollamaConfig))
 			logger.Info("Using Ollama client", "host", ollamaConfig.HostURL)
 		case "openai":
 			openaiConfig := cfg.GetOpenAIConfig()
@@ -69,6 +78,10 @@ Example:
 		default:
 			return fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
 		}
+		llmClient = llm.WithConcurrencyLimits(llmClient, cfg.GetConcurrencyConfig())
+		llmClient = fitToWorkflow(ctx, &cfg, llmClient, cfg.LLM.Model, llm.AgentRequirements)
+		llmClient = meterRun(llmClient)
+		setRunTask(plan.OverallGoal)
 
 		// 3. Get workspace root
 		workspaceRoot := cfg.Project.WorkspaceRoot
@@ -87,7 +100,36 @@ Example:
 
 		// 4. Initialize template engine
 		promptsDir := filepath.Join(absWorkspaceRoot, "prompts")
-		templateEngine := templates.NewEngine(promptsDir)
+		templateEngine := templates.NewEngine(promptsDir).WithFallbackDir(teamsync.PromptsPath(absWorkspaceRoot))
+
+		// Changes are restricted to the scope of the run
+		scopeRoot, err := applyScope(cmd, &cfg)
+		if err != nil {
+			return err
+		}
+		if err := applyAllowProtected(cmd, &cfg); err != nil {
+			return err
+		}
+
+		if err := snapshotBeforeRun(cmd, absWorkspaceRoot); err != nil {
+			return err
+		}
+
+		// Changes go to a sandbox worktree when asked to
+		sandbox, err := startSandbox(ctx, cmd, &cfg, absWorkspaceRoot, "generate")
+		if err != nil {
+			return err
+		}
+		workDir := scopeRoot
+		if sandbox != nil {
+			workDir = sandbox.Dir(scopeRoot)
+		}
+		succeeded := false
+		defer func() {
+			if err := finishSandbox(ctx, sandbox, &cfg, succeeded, "Apply changes generated by CGE for: "+plan.OverallGoal); err != nil {
+				logger.Warn("Failed to finish the sandbox", "error", err)
+			}
+		}()
 
 		// 5. Process each task
 		var processedTasks []string
@@ -106,7 +148,7 @@ Example:
 			}
 
 			// Generate code for this task
-			err := processTask(ctx, task, plan, llmClient, templateEngine, absWorkspaceRoot, cfg, logger)
+			err := processTask(ctx, task, plan, llmClient, templateEngine, workDir, cfg, logger)
 			if err != nil {
 				logger.Error("Failed to process task", "id", task.ID, "error", err)
 				if !dryRun {
@@ -121,6 +163,7 @@ Example:
 		}
 
 		logger.Info("Code generation completed", "processed_tasks", len(processedTasks))
+		succeeded = true
 		return nil
 	},
 }
@@ -196,6 +239,10 @@ func processTask(ctx context.Context, task PlanTask, plan *Plan, llmClient llm.C
 
 	// 2. Gather project context
 	projectContext := fmt.Sprintf("Workspace: %s\nOverall Goal: %s", workspaceRoot, plan.OverallGoal)
+	taskFiles := append(append([]string{}, task.FilesToModify...), task.FilesToCreate...)
+	if apiContext := apispec.PromptContext(workspaceRoot, task.Description+"\n"+task.Rationale, taskFiles); apiContext != "" {
+		projectContext += "\n\n" + apiContext
+	}
 
 	// 3. Prepare template data
 	templateData := templates.GenerateTemplateData{
@@ -260,8 +307,7 @@ func processTask(ctx context.Context, task PlanTask, plan *Plan, llmClient llm.C
 
 	if err := json.Unmarshal([]byte(llmResponse), &response); err != nil {
 		// Save raw response for debugging
-		rawPath := fmt.Sprintf("failed_generate_task_%s_raw.txt", task.ID)
-		_ = os.WriteFile(rawPath, []byte(llmResponse), 0600)
+		rawPath, _ := saveReport(workspaceRoot, fmt.Sprintf("failed_generate_task_%s_raw.txt", task.ID), []byte(llmResponse))
 		return fmt.Errorf("failed to parse LLM JSON response: %w. Raw response saved to %s", err, rawPath)
 	}
 
@@ -442,6 +488,12 @@ func init() {
 	generateCmd.Flags().BoolVar(&applyChanges, "apply", false, "Apply changes directly to the codebase")
 	generateCmd.Flags().StringVarP(&outputDir, "output-dir", "o", "", "Directory to save generated changes (if not applying directly)")
 	generateCmd.Flags().StringVar(&taskFilter, "task", "", "Filter to process only tasks containing this string")
+	generateCmd.Flags().Bool("sandbox", false, "Make changes in a git worktree on a new branch (overrides config)")
+	generateCmd.Flags().Bool("snapshot", false, "Capture the workspace before the run, to restore it with 'CGE snapshot restore'")
+	addScopeFlag(generateCmd)
+	addCIFlags(generateCmd)
+	addProfileFlag(generateCmd)
+	addAllowProtectedFlag(generateCmd)
 
 	// Make the flags mutually exclusive
 	generateCmd.MarkFlagsMutuallyExclusive("dry-run", "apply")
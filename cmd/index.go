@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/a11y"
+	"github.com/castrovroberto/CGE/internal/config"
+	cgecontext "github.com/castrovroberto/CGE/internal/context"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	forceReindex     bool
+	indexConcurrency int
+)
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Build or update the semantic search index for the workspace",
+	Long: `The index command chunks the workspace's source files and stores their
+embeddings in .cge/index/vectors.json for context retrieval.
+
+Chunks are identified by a content hash, so re-running the command only
+embeds files that changed since the last run. Use --force to discard the
+existing index and re-embed everything. With --scope, only a subdirectory is
+indexed, into an index of its own under .cge/index/scopes/.
+
+Example:
+  CGE index
+  CGE index --force
+  CGE index --scope services/billing`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{notifyAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		cfg := contextkeys.ConfigFromContext(ctx)
+		if _, err := applyScope(cmd, &cfg); err != nil {
+			return err
+		}
+		return indexWorkspace(ctx, &cfg, forceReindex, indexConcurrency)
+	},
+}
+
+// indexWorkspace builds or updates the semantic search index of the workspace
+// of cfg, or of its scope when one is set, printing progress and a summary;
+// concurrency overrides the configured number of parallel embedding requests
+// when positive
+func indexWorkspace(ctx context.Context, cfg *config.AppConfig, force bool, concurrency int) error {
+	logger := contextkeys.LoggerFromContext(ctx)
+
+	var llmClient llm.Client
+	switch cfg.LLM.Provider {
+	case "ollama":
+		ollamaConfig := cfg.GetOllamaConfig()
+		llmClient = withModelRecovery(llm.NewOllamaClient(ollamaConfig))
+		logger.Info("Using Ollama client", "host", ollamaConfig.HostURL)
+	case "openai":
+		openaiConfig := cfg.GetOpenAIConfig()
+		llmClient = llm.NewOpenAIClient(openaiConfig)
+		logger.Info("Using OpenAI client", "base_url", openaiConfig.BaseURL)
+	default:
+		return fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
+	}
+	llmClient = llm.WithConcurrencyLimits(llmClient, cfg.GetConcurrencyConfig())
+	llmClient = fitToWorkflow(ctx, cfg, llmClient, cfg.LLM.Model, llm.IndexRequirements)
+
+	workspaceRoot := cfg.Project.WorkspaceRoot
+	if workspaceRoot == "" || workspaceRoot == "." {
+		var err error
+		workspaceRoot, err = os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current directory: %w", err)
+		}
+	}
+
+	options := cgecontext.DefaultContextOptions()
+	options.IndexPath = cgecontext.IndexFilePath(workspaceRoot)
+	indexRoot := workspaceRoot
+	if cfg.Project.Scope != "" {
+		scopeRoot, err := cfg.ScopeRoot()
+		if err != nil {
+			return err
+		}
+		absRoot, err := filepath.Abs(workspaceRoot)
+		if err != nil {
+			return err
+		}
+		scope, err := filepath.Rel(absRoot, scopeRoot)
+		if err != nil {
+			return err
+		}
+		indexRoot = scopeRoot
+		options.IndexPath = cgecontext.ScopedIndexFilePath(workspaceRoot, scope)
+	}
+	if cfg.Indexing.EmbedBatchSize > 0 {
+		options.EmbedBatchSize = cfg.Indexing.EmbedBatchSize
+	}
+	if cfg.Indexing.EmbedConcurrency > 0 {
+		options.EmbedConcurrency = cfg.Indexing.EmbedConcurrency
+	}
+	options.VectorBackend = cfg.GetVectorBackendConfig()
+	if concurrency > 0 {
+		options.EmbedConcurrency = concurrency
+	}
+	manager := cgecontext.NewContextManager(indexRoot, llmClient, cfg.LLM.Model, options)
+
+	fmt.Printf("📚 Indexing %s...\n", indexRoot)
+	stats, err := manager.IndexWorkspaceWithOptions(ctx, cgecontext.IndexOptions{
+		Force:    force,
+		Progress: printIndexProgress,
+	})
+	if stats != nil && stats.ChunksEmbedded+stats.ChunksFailed > 0 && !a11y.Enabled() {
+		fmt.Println() // Finish the progress bar line
+	}
+	if err != nil {
+		return fmt.Errorf("indexing failed: %w", err)
+	}
+
+	fmt.Printf("✅ Indexed %d file(s) in %s\n", stats.FilesScanned, stats.Duration.Round(time.Millisecond))
+	if stats.Rebuilt {
+		fmt.Printf("   Rebuilt: the saved index came from another embedding model (now %s)\n", llm.EmbeddingModelOf(llmClient))
+	}
+	fmt.Printf("   Chunks embedded: %d\n", stats.ChunksEmbedded)
+	fmt.Printf("   Chunks skipped (unchanged): %d\n", stats.ChunksSkipped)
+	if stats.ChunksFailed > 0 {
+		fmt.Printf("   Chunks failed: %d\n", stats.ChunksFailed)
+	}
+	if stats.FilesSkipped > 0 {
+		fmt.Printf("   Files skipped (binary or too large): %d\n", stats.FilesSkipped)
+	}
+	if stats.FilesRemoved > 0 {
+		fmt.Printf("   Deleted files pruned: %d\n", stats.FilesRemoved)
+	}
+	logger.Info("Workspace indexed", "files", stats.FilesScanned, "embedded", stats.ChunksEmbedded, "skipped", stats.ChunksSkipped)
+
+	return nil
+}
+
+// indexMilestones thins out embedding progress in the accessible mode
+var indexMilestones a11y.Milestones
+
+// printIndexProgress renders an in-place progress bar for embedding, or a
+// line per quarter of the chunks in the accessible mode
+func printIndexProgress(done, total int) {
+	if a11y.Enabled() {
+		if total > 0 && indexMilestones.Reached("embed", float64(done)/float64(total)) {
+			fmt.Printf("   Embedded %d of %d chunks\n", done, total)
+		}
+		return
+	}
+	const width = 30
+	filled := 0
+	if total > 0 {
+		filled = done * width / total
+	}
+	fmt.Printf("\r   Embedding [%s%s] %d/%d chunks", strings.Repeat("█", filled), strings.Repeat("░", width-filled), done, total)
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.Flags().BoolVar(&forceReindex, "force", false, "Re-embed every chunk, ignoring content hashes from previous runs")
+	indexCmd.Flags().IntVar(&indexConcurrency, "concurrency", 0, "Parallel embedding requests (overrides config)")
+	addScopeFlag(indexCmd)
+	addCIFlags(indexCmd)
+	addProfileFlag(indexCmd)
+}
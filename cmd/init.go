@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/analyzer"
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/detect"
+	"github.com/castrovroberto/CGE/internal/statedir"
+	"github.com/spf13/cobra"
+)
+
+var (
+	initForce bool
+	initIndex bool
+)
+
+// configFileName is the configuration written by init, found in the working
+// directory by LoadConfig
+const configFileName = "codex.toml"
+
+// maxRepoMapEntries bounds the directories and files listed in the repo map
+const maxRepoMapEntries = 40
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Set up CGE for the current project",
+	Long: `Init bootstraps CGE for a project in one command:
+
+- detects the languages of the project and its test and lint commands
+- writes codex.toml with those defaults
+- creates the .cge/ state directory
+- writes .cge/rules.md, project rules added to every agent prompt, starting
+  with a summary of the project and a map of the repository
+- with --index, builds the semantic search index
+
+Existing files are kept unless --force is given.
+
+Example:
+  CGE init
+  CGE init --index`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		cfg := contextkeys.ConfigFromContext(ctx)
+		workspaceRoot := stateWorkspaceRoot(&cfg)
+
+		project := detect.Detect(workspaceRoot)
+		fmt.Printf("🔎 Detected %s\n", project.Summary())
+
+		configPath := filepath.Join(workspaceRoot, configFileName)
+		written, err := writeIfMissing(configPath, renderInitConfig(&cfg, project), initForce)
+		if err != nil {
+			return err
+		}
+		reportInitFile(configPath, written)
+
+		stateRoot := statedir.Root(workspaceRoot)
+		for _, subdir := range statedir.Subdirs {
+			if err := os.MkdirAll(filepath.Join(stateRoot, subdir), 0755); err != nil {
+				return fmt.Errorf("failed to create the state directory: %w", err)
+			}
+		}
+		fmt.Printf("✅ Created %s\n", stateRoot)
+
+		rulesPath := filepath.Join(stateRoot, statedir.RulesFile)
+		written, err = writeIfMissing(rulesPath, renderStarterRules(workspaceRoot, project), initForce)
+		if err != nil {
+			return err
+		}
+		reportInitFile(rulesPath, written)
+
+		if initIndex {
+			// The configuration just written applies to the index
+			if err := config.LoadConfig(configPath); err != nil {
+				return fmt.Errorf("failed to load %s: %w", configPath, err)
+			}
+			if err := indexWorkspace(ctx, &config.Cfg, false, 0); err != nil {
+				return err
+			}
+		} else {
+			fmt.Println("💡 Run `CGE index` to build the semantic search index")
+		}
+		fmt.Printf("💡 Edit %s to tell the agent about your conventions\n", rulesPath)
+		return nil
+	},
+}
+
+// writeIfMissing writes content to path unless the file exists and force is
+// false, reporting whether it wrote
+func writeIfMissing(path, content string, force bool) (bool, error) {
+	if _, err := os.Stat(path); err == nil && !force {
+		return false, nil
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// reportInitFile tells whether init wrote path or kept the existing file
+func reportInitFile(path string, written bool) {
+	if written {
+		fmt.Printf("✅ Wrote %s\n", path)
+	} else {
+		fmt.Printf("⏭️  Kept the existing %s (--force overwrites it)\n", path)
+	}
+}
+
+// renderInitConfig returns the codex.toml written by init: the provider and
+// model in use, and the detected project defaults
+func renderInitConfig(cfg *config.AppConfig, p detect.Project) string {
+	var b strings.Builder
+	b.WriteString("# CGE configuration written by `CGE init`. Settings left out keep their\n")
+	b.WriteString("# defaults; see the README for the full list.\n\n")
+	b.WriteString("[llm]\n")
+	fmt.Fprintf(&b, "  provider = %q\n", cfg.LLM.Provider)
+	fmt.Fprintf(&b, "  model = %q\n\n", cfg.LLM.Model)
+	b.WriteString("[project]\n")
+	b.WriteString("  workspace_root = \".\"\n")
+	quoted := []string{}
+	for _, ext := range append(p.Extensions, ".md") {
+		quoted = append(quoted, fmt.Sprintf("%q", ext))
+	}
+	fmt.Fprintf(&b, "  default_source_extensions = [%s]\n\n", strings.Join(quoted, ", "))
+	b.WriteString("[commands.review]\n")
+	writeTOMLCommand(&b, "test_command", p.TestCommand)
+	writeTOMLCommand(&b, "lint_command", p.LintCommand)
+	return b.String()
+}
+
+// writeTOMLCommand writes a command setting, commented out when none was
+// detected
+func writeTOMLCommand(b *strings.Builder, key, command string) {
+	if command == "" {
+		fmt.Fprintf(b, "  # %s = \"\"\n", key)
+		return
+	}
+	fmt.Fprintf(b, "  %s = %q\n", key, command)
+}
+
+// renderStarterRules returns the rules.md written by init: a map of the
+// repository and headings for the conventions. What detection finds is added
+// to prompts on its own, so it is only mentioned.
+func renderStarterRules(root string, p detect.Project) string {
+	var b strings.Builder
+	b.WriteString("# Project Rules\n\n")
+	b.WriteString("<!-- Added to every agent prompt. Keep it short and specific to this project. -->\n")
+	if !p.Empty() {
+		b.WriteString("<!-- The agent is already told what was detected: " + p.Summary() + ". -->\n")
+	}
+	b.WriteString("\n## Repository Map\n\n")
+	b.WriteString(repoMap(root))
+	b.WriteString("\n## Conventions\n\n")
+	b.WriteString("- Follow the style of the surrounding code.\n")
+	b.WriteString("- Add or update tests with every behavior change.\n")
+	b.WriteString("<!-- Add naming, error handling, dependency and review rules here. -->\n")
+	return b.String()
+}
+
+// repoMap lists the top-level directories of root with their number of
+// source files, then its top-level files
+func repoMap(root string) string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return "- (could not read the workspace)\n"
+	}
+	var dirs, files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || analyzer.IsSkippableDir(name) {
+			continue
+		}
+		if entry.IsDir() {
+			dirs = append(dirs, fmt.Sprintf("- `%s/` (%d source files)\n", name, countSourceFiles(filepath.Join(root, name))))
+		} else {
+			files = append(files, fmt.Sprintf("- `%s`\n", name))
+		}
+	}
+	sort.Strings(dirs)
+	sort.Strings(files)
+	lines := append(dirs, files...)
+	if len(lines) > maxRepoMapEntries {
+		lines = append(lines[:maxRepoMapEntries], fmt.Sprintf("- … and %d more\n", len(lines)-maxRepoMapEntries))
+	}
+	if len(lines) == 0 {
+		return "- (empty)\n"
+	}
+	return strings.Join(lines, "")
+}
+
+// countSourceFiles counts the source files under dir, skipping dependency and
+// build directories
+func countSourceFiles(dir string) int {
+	count := 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(d.Name(), ".") || analyzer.IsSkippableDir(d.Name())) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if analyzer.IsSourceFile(strings.ToLower(filepath.Ext(path))) {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Overwrite an existing codex.toml and rules.md")
+	initCmd.Flags().BoolVar(&initIndex, "index", false, "Build the semantic search index once set up")
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/i18n"
+	"github.com/spf13/cobra"
+)
+
+var localeCmd = &cobra.Command{
+	Use:   "locale",
+	Short: "Show the locale of TUI messages and prompts",
+	Long: `CGE shows TUI messages in the locale set by locale in the [ui] section of
+codex.toml, or detected from CGE_LOCALE, LC_ALL, LC_MESSAGES or LANG.
+
+To translate CGE, export the English catalog, translate its messages, and save
+it as <locale>.json (e.g. de.json or pt-BR.json) in the locales directory.
+Messages left out stay in English. Prompt templates are localized by copying
+them to a subdirectory of prompts/ named after the locale, e.g. prompts/de/.`,
+	Example: `  CGE locale                      # Show the locale and where catalogs are read
+  CGE locale export > de.json     # Start a translation from the English catalog`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		dir := cfg.GetLocalesDir()
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "🌐 Locale: %s\n", i18n.Locale())
+		fmt.Fprintf(out, "   Catalogs: %s\n", dir)
+		for _, locale := range i18n.Fallbacks(i18n.Locale()) {
+			fmt.Fprintf(out, "   Looking for: %s\n", filepath.Join(dir, locale+".json"))
+		}
+		return nil
+	},
+}
+
+var localeExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print the English message catalog as JSON, to start a translation",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := json.MarshalIndent(i18n.English(), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(data))
+		return nil
+	},
+}
+
+func init() {
+	localeCmd.AddCommand(localeExportCmd)
+	rootCmd.AddCommand(localeCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/a11y"
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// autoPullModels pulls missing Ollama models without asking
+var autoPullModels bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&autoPullModels, "pull", false, "Pull missing Ollama models without asking")
+}
+
+// withModelRecovery wraps client so a request for a model missing on the
+// server pulls the model, after confirmation, and is retried. Clients that
+// cannot pull models are returned unchanged.
+func withModelRecovery(client llm.Client) llm.Client {
+	puller, ok := client.(llm.ModelPuller)
+	if !ok {
+		return client
+	}
+	return llm.NewModelRecoveryClient(client, puller, confirmModelPull, printPullProgress)
+}
+
+// confirmModelPull asks on the terminal whether a missing model should be
+// pulled. Without --pull, non-interactive runs never pull.
+func confirmModelPull(ctx context.Context, modelName string) (bool, error) {
+	if autoPullModels {
+		return true, nil
+	}
+	if !stdinIsTerminal() {
+		fmt.Fprintf(os.Stderr, "Model %q is not available on the Ollama server; rerun with --pull to download it\n", modelName)
+		return false, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Model %q is not available on the Ollama server. Pull it now? [y/N] ", modelName)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// confirmChatModelPull decides pulls during a chat session, where the TUI owns
+// the terminal and cannot be prompted from
+func confirmChatModelPull(ctx context.Context, modelName string) (bool, error) {
+	if autoPullModels {
+		return true, nil
+	}
+	return false, fmt.Errorf("start the chat with --pull to download %q automatically", modelName)
+}
+
+// pullMilestones thins out pull progress in the accessible mode
+var pullMilestones a11y.Milestones
+
+// printPullProgress shows pull progress on a single stderr line, or a line
+// per status and quarter of the download in the accessible mode
+func printPullProgress(progress llm.PullProgress) {
+	fraction := progress.Fraction()
+	if a11y.Enabled() {
+		switch {
+		case progress.Status == "success":
+			fmt.Fprintf(os.Stderr, "Pulled %s\n", progress.Model)
+		case !pullMilestones.Reached(progress.Model+" "+progress.Status, fraction):
+		case fraction >= 0:
+			fmt.Fprintf(os.Stderr, "Pulling %s: %s %.0f%%\n", progress.Model, progress.Status, fraction*100)
+		default:
+			fmt.Fprintf(os.Stderr, "Pulling %s: %s\n", progress.Model, progress.Status)
+		}
+		return
+	}
+	if progress.Status == "success" {
+		fmt.Fprintf(os.Stderr, "\rPulled %s%s\n", progress.Model, strings.Repeat(" ", 40))
+		return
+	}
+	if fraction >= 0 {
+		fmt.Fprintf(os.Stderr, "\rPulling %s: %s %3.0f%%   ", progress.Model, progress.Status, fraction*100)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\rPulling %s: %s   ", progress.Model, progress.Status)
+}
@@ -14,30 +14,17 @@ import (
 	"github.com/castrovroberto/CGE/internal/contextkeys"
 	"github.com/castrovroberto/CGE/internal/llm"
 	"github.com/castrovroberto/CGE/internal/orchestrator"
+	"github.com/castrovroberto/CGE/internal/teamsync"
 	"github.com/castrovroberto/CGE/internal/templates"
 	"github.com/spf13/cobra"
 )
 
 // PlanTask represents a single task in the generated plan.
-type PlanTask struct {
-	ID              string   `json:"id"`
-	Description     string   `json:"description"`
-	FilesToModify   []string `json:"files_to_modify,omitempty"`
-	FilesToCreate   []string `json:"files_to_create,omitempty"`
-	FilesToDelete   []string `json:"files_to_delete,omitempty"`
-	EstimatedEffort string   `json:"estimated_effort,omitempty"` // e.g., "small", "medium", "large"
-	Dependencies    []string `json:"dependencies,omitempty"`
-	Rationale       string   `json:"rationale,omitempty"`
-}
+type PlanTask = agent.PlanTask
 
-// Plan represents the structure of the plan.json file.
-type Plan struct {
-	OverallGoal            string     `json:"overall_goal"`
-	Tasks                  []PlanTask `json:"tasks"`
-	Summary                string     `json:"summary,omitempty"`
-	EstimatedTotalEffort   string     `json:"estimated_total_effort,omitempty"`
-	RisksAndConsiderations []string   `json:"risks_and_considerations,omitempty"`
-}
+// Plan represents the structure of the plan.json file. It is shared with the
+// plan mode of the chat, which saves plans in the same format.
+type Plan = agent.Plan
 
 var (
 	userPromptPlan  string
@@ -57,7 +44,8 @@ This plan can then be used by the 'generate' command.
 
 Example:
   CGE plan "Refactor the user authentication module to use JWT" --output plan_auth_refactor.json`,
-	Args: cobra.ExactArgs(1), // Expects the main goal as an argument
+	Args:        cobra.ExactArgs(1), // Expects the main goal as an argument
+	Annotations: map[string]string{notifyAnnotation: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 		logger := contextkeys.LoggerFromContext(ctx)
@@ -68,6 +56,11 @@ Example:
 			return fmt.Errorf("the goal description cannot be empty")
 		}
 
+		scopeRoot, err := applyScope(cmd, &cfg)
+		if err != nil {
+			return err
+		}
+
 		logger.Info("Starting plan generation...", "goal", userGoal, "use_orchestrator", useOrchestrator)
 
 		// 1. Instantiate LLM Client
@@ -75,7 +68,7 @@ Example:
 		switch cfg.LLM.Provider {
 		case "ollama":
 			ollamaConfig := cfg.GetOllamaConfig()
-			llmClient = llm.NewOllamaClient(ollamaConfig)
+			llmClient = withModelRecovery(llm.NewOllamaClient(ollamaConfig))
 			logger.Info("Using Ollama client", "host", ollamaConfig.HostURL)
 		case "openai":
 			openaiConfig := cfg.GetOpenAIConfig()
@@ -84,6 +77,9 @@ Example:
 		default:
 			return fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
 		}
+		llmClient = llm.WithConcurrencyLimits(llmClient, cfg.GetConcurrencyConfig())
+		llmClient = meterRun(llmClient)
+		setRunTask(userGoal)
 
 		// 2. Repository Walker & Context Gathering
 		logger.Info("Gathering codebase context...")
@@ -98,8 +94,8 @@ Example:
 			}
 		}
 
-		// Gather real codebase context
-		gatherer := cgecontext.NewGatherer(workspaceRoot)
+		// Gather real codebase context, from the scope of the run only
+		gatherer := cgecontext.NewGatherer(scopeRoot)
 		contextInfo, err := gatherer.GatherContext()
 		if err != nil {
 			logger.Error("Failed to gather codebase context", "error", err)
@@ -110,7 +106,7 @@ Example:
 		// 3. Plan Generation Logic - choose between orchestrator and template
 		if useOrchestrator {
 			logger.Info("Generating plan with orchestrator...")
-			return generatePlanWithOrchestrator(ctx, userGoal, contextInfo, llmClient, workspaceRoot, cfg, logger)
+			return generatePlanWithOrchestrator(ctx, userGoal, contextInfo, llmClient, workspaceRoot, scopeRoot, cfg, logger)
 		}
 
 		// 3. Plan Generation Logic using template
@@ -118,7 +114,7 @@ Example:
 
 		// Get prompts directory (relative to workspace root)
 		promptsDir := filepath.Join(workspaceRoot, "prompts")
-		templateEngine := templates.NewEngine(promptsDir)
+		templateEngine := templates.NewEngine(promptsDir).WithFallbackDir(teamsync.PromptsPath(workspaceRoot))
 
 		// Prepare template data
 		templateData := templates.PlanTemplateData{
@@ -151,8 +147,7 @@ Example:
 		if err := json.Unmarshal([]byte(llmResponse), &generatedPlan); err != nil {
 			logger.Error("Failed to parse LLM response into Plan struct", "error", err, "response", llmResponse)
 			// Attempt to save the raw response for debugging if JSON parsing fails
-			rawPlanPath := "failed_plan_raw_output.txt"
-			_ = os.WriteFile(rawPlanPath, []byte(llmResponse), 0600)
+			rawPlanPath, _ := saveReport(workspaceRoot, "failed_plan_raw_output.txt", []byte(llmResponse))
 			logger.Info("Raw LLM response saved for debugging.", "path", rawPlanPath)
 			return fmt.Errorf("failed to parse LLM JSON response: %w. Raw response saved to %s", err, rawPlanPath)
 		}
@@ -233,8 +228,9 @@ func validatePlan(plan *Plan) error {
 	return nil
 }
 
-// generatePlanWithOrchestrator uses the agent orchestrator to generate a plan
-func generatePlanWithOrchestrator(ctx context.Context, userGoal string, contextInfo interface{}, llmClient llm.Client, workspaceRoot string, cfg interface{}, logger interface{}) error {
+// generatePlanWithOrchestrator uses the agent orchestrator to generate a plan,
+// with tools restricted to toolRoot
+func generatePlanWithOrchestrator(ctx context.Context, userGoal string, contextInfo interface{}, llmClient llm.Client, workspaceRoot, toolRoot string, cfg interface{}, logger interface{}) error {
 	// Initialize audit logger for session tracking
 	auditLogger, err := audit.NewAuditLogger(workspaceRoot, "plan")
 	if err != nil {
@@ -246,11 +242,14 @@ func generatePlanWithOrchestrator(ctx context.Context, userGoal string, contextI
 			auditLogger.Close()
 		}
 	}()
+	if auditLogger != nil {
+		ctx = agent.WithProgressReporter(ctx, agent.NewAuditProgressReporter(auditLogger))
+	}
 
 	// TODO: Integrate session manager with planning command in future iteration
 
 	// Initialize tool registry with planning tools
-	toolFactory := agent.NewToolFactory(workspaceRoot)
+	toolFactory := agent.NewToolFactory(toolRoot)
 	toolRegistry := toolFactory.CreatePlanningRegistry()
 
 	// Create command integrator and execute plan
@@ -286,8 +285,7 @@ func generatePlanWithOrchestrator(ctx context.Context, userGoal string, contextI
 	var generatedPlan Plan
 	if err := json.Unmarshal(planJSON, &generatedPlan); err != nil {
 		// Save raw response for debugging
-		rawPlanPath := "failed_orchestrated_plan_raw_output.txt"
-		_ = os.WriteFile(rawPlanPath, planJSON, 0600)
+		rawPlanPath, _ := saveReport(workspaceRoot, "failed_orchestrated_plan_raw_output.txt", planJSON)
 		return fmt.Errorf("generated plan has invalid structure: %w. Raw response saved to %s", err, rawPlanPath)
 	}
 
@@ -327,6 +325,8 @@ func init() {
 	rootCmd.AddCommand(planCmd)
 	planCmd.Flags().StringVarP(&outputFilePlan, "output", "o", "plan.json", "Output file for the generated plan")
 	planCmd.Flags().BoolVar(&useOrchestrator, "use-orchestrator", false, "Use the agent orchestrator with function calling")
+	addScopeFlag(planCmd)
+	addCIFlags(planCmd)
 	// We are taking the prompt as a positional arg now.
 	// planCmd.Flags().StringVarP(&userPromptPlan, "prompt", "p", "", "Your goal or task description (required)")
 	// planCmd.MarkFlagRequired("prompt")
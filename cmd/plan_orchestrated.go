@@ -34,7 +34,8 @@ This version uses the agent orchestrator with function calling capabilities, all
 
 Example:
   CGE plan-orchestrated "Refactor the user authentication module to use JWT" --output plan_auth_refactor.json`,
-	Args: cobra.ExactArgs(1), // Expects the main goal as an argument
+	Args:        cobra.ExactArgs(1), // Expects the main goal as an argument
+	Annotations: map[string]string{notifyAnnotation: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 		logger := contextkeys.LoggerFromContext(ctx)
@@ -52,7 +53,7 @@ Example:
 		switch cfg.LLM.Provider {
 		case "ollama":
 			ollamaConfig := cfg.GetOllamaConfig()
-			llmClient = llm.NewOllamaClient(ollamaConfig)
+			llmClient = withModelRecovery(llm.NewOllamaClient(ollamaConfig))
 			logger.Info("Using Ollama client", "host", ollamaConfig.HostURL)
 		case "openai":
 			openaiConfig := cfg.GetOpenAIConfig()
@@ -61,6 +62,10 @@ Example:
 		default:
 			return fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
 		}
+		llmClient = llm.WithConcurrencyLimits(llmClient, cfg.GetConcurrencyConfig())
+		llmClient = fitToWorkflow(ctx, &cfg, llmClient, cfg.LLM.Model, llm.AgentRequirements)
+		llmClient = meterRun(llmClient)
+		setRunTask(userGoal)
 
 		// 2. Get workspace root
 		workspaceRoot := cfg.Project.WorkspaceRoot
@@ -72,19 +77,20 @@ Example:
 			}
 		}
 
-		// Convert workspace root to absolute path to fix tool access issues
-		absWorkspaceRoot, err := filepath.Abs(workspaceRoot)
+		// Tools are restricted to the scope of the run, an absolute path
+		scopeRoot, err := applyScope(cmd, &cfg)
 		if err != nil {
-			return fmt.Errorf("failed to convert workspace root to absolute path: %w", err)
+			return err
 		}
 
 		// 3. Initialize tool registry with planning tools
-		toolFactory := agent.NewToolFactory(absWorkspaceRoot)
+		toolFactory := agent.NewToolFactory(scopeRoot)
+		toolFactory.SetDatabaseConfig(cfg.GetDatabaseToolConfig())
 		toolRegistry := toolFactory.CreatePlanningRegistry()
 
 		// 4. Gather initial codebase context (lightweight)
 		logger.Info("Gathering initial codebase context...")
-		gatherer := context.NewGatherer(workspaceRoot)
+		gatherer := context.NewGatherer(scopeRoot)
 		contextInfo, err := gatherer.GatherContext()
 		if err != nil {
 			logger.Error("Failed to gather codebase context", "error", err)
@@ -126,8 +132,7 @@ Example:
 		if err := json.Unmarshal(planJSON, &generatedPlan); err != nil {
 			logger.Error("Generated plan has invalid structure", "error", err)
 			// Save raw response for debugging
-			rawPlanPath := "failed_orchestrated_plan_raw_output.txt"
-			_ = os.WriteFile(rawPlanPath, planJSON, 0600)
+			rawPlanPath, _ := saveReport(workspaceRoot, "failed_orchestrated_plan_raw_output.txt", planJSON)
 			logger.Info("Raw plan response saved for debugging.", "path", rawPlanPath)
 			return fmt.Errorf("generated plan has invalid structure: %w. Raw response saved to %s", err, rawPlanPath)
 		}
@@ -174,4 +179,6 @@ func init() {
 
 	planOrchestratedCmd.Flags().StringVarP(&outputFilePlanOrchestrated, "output", "o", "plan.json", "Output file for the generated plan")
 	planOrchestratedCmd.Flags().BoolVar(&useOrchestratorPlan, "use-orchestrator", true, "Use the agent orchestrator (always true for this command)")
+	addScopeFlag(planOrchestratedCmd)
+	addCIFlags(planOrchestratedCmd)
 }
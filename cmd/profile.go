@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/statedir"
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileRun bool   // --profile
+	pprofAddr  string // --pprof
+
+	// cpuProfile is the file the CPU profile of the run is written to, nil
+	// when the run is not profiled
+	cpuProfile *os.File
+)
+
+// addProfileFlag adds --profile to a command worth profiling
+func addProfileFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&profileRun, "profile", false, "Write CPU and heap profiles of the run to .cge/profiles/, for 'go tool pprof'")
+}
+
+// addPprofFlag adds --pprof to a long-running command
+func addPprofFlag(c *cobra.Command) {
+	c.Flags().StringVar(&pprofAddr, "pprof", "", "Serve the pprof endpoints at this address while the command runs, e.g. localhost:6060")
+}
+
+// startProfiling starts the CPU profile of the run for --profile, and the
+// pprof endpoints for --pprof
+func startProfiling(cmd *cobra.Command, cfg *config.AppConfig) {
+	if pprofAddr != "" {
+		if err := servePprof(pprofAddr); err != nil {
+			logger.Get().Warn("Failed to serve pprof", "address", pprofAddr, "error", err)
+		}
+	}
+	if !profileRun {
+		return
+	}
+	dir := statedir.Path(stateWorkspaceRoot(cfg), statedir.Profiles)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		logger.Get().Warn("Failed to create the profiles directory", "error", err)
+		return
+	}
+	path := filepath.Join(dir, profileName(cmd, "cpu"))
+	file, err := os.Create(path)
+	if err != nil {
+		logger.Get().Warn("Failed to create CPU profile", "error", err)
+		return
+	}
+	if err := runtimepprof.StartCPUProfile(file); err != nil {
+		file.Close()
+		logger.Get().Warn("Failed to start CPU profile", "error", err)
+		return
+	}
+	cpuProfile = file
+}
+
+// stopProfiling ends the CPU profile of the run and writes its heap profile
+// next to it
+func stopProfiling(cmd *cobra.Command) {
+	if cpuProfile == nil {
+		return
+	}
+	runtimepprof.StopCPUProfile()
+	cpuProfile.Close()
+	fmt.Fprintf(os.Stderr, "CPU profile written to %s\n", cpuProfile.Name())
+
+	path := filepath.Join(filepath.Dir(cpuProfile.Name()), profileName(cmd, "heap"))
+	cpuProfile = nil
+	file, err := os.Create(path)
+	if err != nil {
+		logger.Get().Warn("Failed to create heap profile", "error", err)
+		return
+	}
+	defer file.Close()
+	// Up-to-date statistics of the objects still in use
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(file); err != nil {
+		logger.Get().Warn("Failed to write heap profile", "error", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Heap profile written to %s\nInspect them with: go tool pprof -http=localhost:8080 <profile>\n", path)
+}
+
+// profileName names a profile of a run by its command and start time, e.g.
+// index-20261016-150405-cpu.pprof
+func profileName(cmd *cobra.Command, kind string) string {
+	started := runStartTime
+	if started.IsZero() {
+		started = time.Now()
+	}
+	return fmt.Sprintf("%s-%s-%s.pprof", cmd.Name(), started.Format("20060102-150405"), kind)
+}
+
+// servePprof serves the pprof endpoints at addr under /debug/pprof/ until the
+// process exits. An address without a host listens on localhost only.
+func servePprof(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		addr = net.JoinHostPort("localhost", port)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Get().Warn("pprof server stopped", "error", err)
+		}
+	}()
+	logger.Get().Info("Serving pprof", "url", fmt.Sprintf("http://%s/debug/pprof/", listener.Addr()))
+	return nil
+}
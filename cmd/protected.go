@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/spf13/cobra"
+)
+
+// addAllowProtectedFlag adds --allow-protected to a command whose tools can
+// change files
+func addAllowProtectedFlag(c *cobra.Command) {
+	c.Flags().Bool("allow-protected", false, "Let tools change files matching [tools] protected_paths, after confirmation")
+}
+
+// applyAllowProtected lifts the protected paths of a run when --allow-protected
+// is given and the user confirms it on the terminal. Without a terminal to
+// confirm on, the run is refused rather than left unprotected.
+func applyAllowProtected(cmd *cobra.Command, cfg *config.AppConfig) error {
+	allow, _ := cmd.Flags().GetBool("allow-protected")
+	if !allow || len(cfg.Tools.ProtectedPaths) == 0 {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("--allow-protected must be confirmed on a terminal")
+	}
+	fmt.Printf("⚠️  Tools will be able to change protected files: %s\n", strings.Join(cfg.Tools.ProtectedPaths, ", "))
+	fmt.Print("Type \"yes\" to continue: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(strings.ToLower(answer)) != "yes" {
+		return fmt.Errorf("protected paths were not unlocked")
+	}
+	cfg.Tools.ProtectedPaths = nil
+	if runCfg := contextkeys.ConfigPtrFromContext(cmd.Context()); runCfg != nil {
+		runCfg.Tools.ProtectedPaths = nil
+	}
+	fmt.Println("🔓 Protected paths unlocked for this run")
+	return nil
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,10 +11,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/config"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
 	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/orchestrator"
 	"github.com/castrovroberto/CGE/internal/security"
+	"github.com/castrovroberto/CGE/internal/statedir"
+	"github.com/castrovroberto/CGE/internal/teamsync"
 	"github.com/castrovroberto/CGE/internal/templates"
+	"github.com/castrovroberto/CGE/internal/worktree"
 	"github.com/spf13/cobra"
 )
 
@@ -25,6 +32,7 @@ var (
 	autoFix         bool
 	previewFixes    bool
 	applyFixes      bool
+	commitFixes     bool
 )
 
 // ReviewResult represents the result of a review cycle
@@ -36,6 +44,9 @@ type ReviewResult struct {
 	Issues       []string `json:"issues"`
 	Suggestions  []string `json:"suggestions"`
 	FixesApplied []string `json:"fixes_applied"`
+	ChangedFiles []string `json:"changed_files,omitempty"` // Absolute paths of the files fixed
+	// LintFindings holds structured findings when the lint command has an adapter
+	LintFindings []agent.LintIssue `json:"lint_findings,omitempty"`
 }
 
 // reviewCmd represents the review command
@@ -51,31 +62,48 @@ It can:
 - Automatically apply fixes using LLM assistance
 - Iterate until all issues are resolved or max cycles reached
 
+With --auto-fix, interactive runs stop at checkpoints to approve, reject or
+edit: the planned fixes, each file change, and the commit made by --commit.
+Runs without a terminal decide checkpoints by the [commands.review.approval]
+policies. --apply approves everything and --preview changes nothing. The
+decisions are recorded in a review session.
+
+With --sandbox, fixes are made in a git worktree on a new branch, which is
+merged, squashed or kept when all checks pass and discarded otherwise.
+
 Example:
   CGE review ./src --test-cmd "go test ./..." --lint-cmd "golangci-lint run"
-  CGE review --auto-fix --max-cycles 3`,
-	Args: cobra.MaximumNArgs(1),
+  CGE review --auto-fix --max-cycles 3
+  CGE review --auto-fix --checkpoints file_change --commit`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{notifyAnnotation: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 		logger := contextkeys.LoggerFromContext(ctx)
 		cfg := contextkeys.ConfigFromContext(ctx)
 
-		// Determine target directory
-		targetDir := "."
+		// Determine target directory, the scope of the run by default
+		scopeRoot, err := applyScope(cmd, &cfg)
+		if err != nil {
+			return err
+		}
+		if err := applyAllowProtected(cmd, &cfg); err != nil {
+			return err
+		}
+		targetDir := ""
 		if len(args) > 0 {
 			targetDir = args[0]
 		}
 		if reviewTargetDir != "" {
 			targetDir = reviewTargetDir
 		}
-
-		// Get absolute path
-		absTargetDir, err := filepath.Abs(targetDir)
+		absTargetDir, err := scopedTarget(targetDir, scopeRoot, cfg.Project.Scope != "")
 		if err != nil {
-			return fmt.Errorf("failed to get absolute path: %w", err)
+			return err
 		}
 
 		logger.Info("Starting code review", "target_dir", absTargetDir)
+		setRunTask("review of " + absTargetDir)
 
 		// Use commands from config if not specified
 		if testCommand == "" {
@@ -88,13 +116,14 @@ Example:
 			maxCycles = cfg.Commands.Review.MaxCycles
 		}
 
-		// Initialize LLM client if auto-fix is enabled
+		// Initialize LLM client if fixes are wanted
+		fixing := autoFix || previewFixes || applyFixes
 		var llmClient llm.Client
-		if autoFix {
+		if fixing {
 			switch cfg.LLM.Provider {
 			case "ollama":
 				ollamaConfig := cfg.GetOllamaConfig()
-				llmClient = llm.NewOllamaClient(ollamaConfig)
+				llmClient = withModelRecovery(llm.NewOllamaClient(ollamaConfig))
 				logger.Info("Using Ollama client for auto-fix", "host", ollamaConfig.HostURL)
 			case "openai":
 				openaiConfig := cfg.GetOpenAIConfig()
@@ -103,6 +132,9 @@ Example:
 			default:
 				return fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
 			}
+			llmClient = llm.WithConcurrencyLimits(llmClient, cfg.GetConcurrencyConfig())
+			llmClient = fitToWorkflow(ctx, &cfg, llmClient, cfg.LLM.Model, llm.AgentRequirements)
+			llmClient = meterRun(llmClient)
 		}
 
 		// Get workspace root for templates
@@ -116,13 +148,57 @@ Example:
 
 		// Initialize template engine
 		promptsDir := filepath.Join(workspaceRoot, "prompts")
-		templateEngine := templates.NewEngine(promptsDir)
+		templateEngine := templates.NewEngine(promptsDir).WithFallbackDir(teamsync.PromptsPath(workspaceRoot))
+
+		// Fixes wait for approval at checkpoints, recorded in a session
+		var session *reviewSession
+		if fixing {
+			gate, err := reviewApprovalGate(cmd, &cfg, applyFixes, previewFixes)
+			if err != nil {
+				return err
+			}
+			if session, err = newReviewSession(workspaceRoot, cfg.LLM.Model, gate); err != nil {
+				return fmt.Errorf("failed to create review session: %w", err)
+			}
+		}
+		// Fixes go to a sandbox worktree when asked to
+		var sandbox *worktree.Sandbox
+		if fixing {
+			if sandbox, err = startSandbox(ctx, cmd, &cfg, absTargetDir, "review"); err != nil {
+				session.finish("failed")
+				return err
+			}
+			if sandbox != nil {
+				absTargetDir = sandbox.Dir(absTargetDir)
+			}
+		}
+		var changed []string
+		passed := false
+		defer func() {
+			if err := finishSandbox(ctx, sandbox, &cfg, passed, "Fix test and lint issues found by CGE review"); err != nil {
+				logger.Warn("Failed to finish the sandbox", "error", err)
+			}
+		}()
+		defer func() {
+			if session == nil {
+				return
+			}
+			if passed {
+				session.finish("completed")
+			} else {
+				session.finish("failed")
+			}
+		}()
 
 		// Track previous issues to detect infinite loops
 		var previousIssues []string
 		noProgressCount := 0
 
+		// Track lint findings across cycles so unresolved ones are not retried forever
+		findingTracker := agent.NewLintFindingTracker(cfg.Commands.Review.MaxFixAttempts)
+
 		// Run review cycles
+		var lastResult *ReviewResult
 		for cycle := 1; cycle <= maxCycles; cycle++ {
 			logger.Info("Starting review cycle", "cycle", cycle, "max_cycles", maxCycles)
 
@@ -134,16 +210,19 @@ Example:
 
 			// Print results
 			printReviewResults(result, cycle)
+			runTestsFailing = !result.TestsPassed
+			lastResult = result
 
 			// Check if we're done
 			if result.TestsPassed && result.LintPassed {
 				logger.Info("All checks passed!", "cycle", cycle)
 				fmt.Printf("✅ All checks passed after %d cycle(s)!\n", cycle)
-				return nil
+				passed = true
+				break
 			}
 
-			// If auto-fix is disabled or this is the last cycle, stop here
-			if !autoFix || cycle == maxCycles {
+			// If fixing is disabled or this is the last cycle, stop here
+			if !fixing || cycle == maxCycles {
 				if cycle == maxCycles {
 					logger.Warn("Maximum cycles reached", "max_cycles", maxCycles)
 					fmt.Printf("⚠️  Maximum cycles (%d) reached. Some issues remain unresolved.\n", maxCycles)
@@ -165,22 +244,71 @@ Example:
 			}
 			previousIssues = append(previousIssues, currentIssues)
 
+			// Only hand the fixer lint findings it has not already failed to resolve
+			if len(result.LintFindings) > 0 {
+				actionable, suppressed := findingTracker.Select(result.LintFindings)
+				if len(suppressed) > 0 {
+					logger.Info("Skipping lint findings that persisted after previous fix attempts", "count", len(suppressed))
+				}
+				if len(actionable) == 0 && result.TestsPassed {
+					fmt.Printf("⚠️  %d lint finding(s) persisted after repeated fix attempts. Stopping review cycles.\n", len(suppressed))
+					break
+				}
+				result.Issues = lintIssuesForPrompt(result.Issues, actionable)
+				findingTracker.RecordAttempt(actionable)
+			}
+
 			// Apply fixes using LLM
 			logger.Info("Attempting to fix issues with LLM", "cycle", cycle)
-			err = applyLLMFixes(ctx, result, llmClient, templateEngine, absTargetDir, cfg, logger)
+			err = applyLLMFixes(ctx, result, llmClient, templateEngine, absTargetDir, cfg, session)
 			if err != nil {
 				logger.Error("Failed to apply LLM fixes", "cycle", cycle, "error", err)
 				// Continue to next cycle even if fixes fail
 			}
+			changed = appendMissing(changed, result.ChangedFiles...)
 
 			// Wait a bit before next cycle
 			time.Sleep(2 * time.Second)
 		}
 
+		annotateReview(absTargetDir, lastResult)
+
+		if commitFixes && session != nil {
+			message := "Fix test and lint issues found by CGE review"
+			committed, err := commitReviewChanges(ctx, absTargetDir, changed, message, cfg.GetCommitConventions(), session.check)
+			switch {
+			case errors.Is(err, errNothingToCommit):
+				fmt.Printf("ℹ️  No fixes to commit\n")
+			case err != nil:
+				return err
+			case committed:
+				fmt.Printf("✅ Committed %d fixed file(s)\n", len(changed))
+			default:
+				fmt.Printf("⏭️  Commit rejected; the fixes are left uncommitted\n")
+			}
+		}
+
 		return nil
 	},
 }
 
+// appendMissing appends the values not in list yet
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
 // runReviewCycle executes tests and linters and returns the results
 func runReviewCycle(ctx context.Context, targetDir, testCmd, lintCmd string, logger interface{}) (*ReviewResult, error) {
 	result := &ReviewResult{}
@@ -201,13 +329,27 @@ func runReviewCycle(ctx context.Context, targetDir, testCmd, lintCmd string, log
 
 	// Run linter if command is specified
 	if lintCmd != "" {
+		adapter := agent.LintAdapterForCommand(lintCmd)
+		if adapter != nil {
+			// Ask the linter for machine-readable output so findings map to files and lines
+			lintCmd = strings.Join(adapter.StructuredArgs(strings.Fields(lintCmd)), " ")
+		}
+
 		fmt.Printf("🔍 Running linter: %s\n", lintCmd)
-		lintOutput, lintErr := runCommand(ctx, lintCmd, targetDir)
-		result.LintOutput = lintOutput
-		result.LintPassed = lintErr == nil
+		var lintErr error
+		if adapter != nil {
+			result.LintOutput, lintErr = runStructuredLinter(ctx, adapter, lintCmd, targetDir, result)
+		} else {
+			result.LintOutput, lintErr = runCommand(ctx, lintCmd, targetDir)
+			result.LintPassed = lintErr == nil
+		}
 
-		if lintErr != nil {
-			result.Issues = append(result.Issues, fmt.Sprintf("Linting failed: %v", lintErr))
+		if !result.LintPassed {
+			if len(result.LintFindings) > 0 {
+				result.Issues = append(result.Issues, fmt.Sprintf("Linting failed: %d finding(s)", len(result.LintFindings)))
+			} else {
+				result.Issues = append(result.Issues, fmt.Sprintf("Linting failed: %v", lintErr))
+			}
 		}
 	} else {
 		result.LintPassed = true // No linting to run
@@ -216,6 +358,63 @@ func runReviewCycle(ctx context.Context, targetDir, testCmd, lintCmd string, log
 	return result, nil
 }
 
+// lintIssuesForPrompt replaces the generic lint failure entry with one line per finding
+func lintIssuesForPrompt(issues []string, findings []agent.LintIssue) []string {
+	var out []string
+	for _, issue := range issues {
+		if !strings.HasPrefix(issue, "Linting failed") {
+			out = append(out, issue)
+		}
+	}
+	for _, finding := range findings {
+		out = append(out, agent.FormatLintIssue(finding))
+	}
+	return out
+}
+
+// runStructuredLinter runs a linter whose adapter parses its structured
+// output, and records the findings in result. Only stdout is parsed, since
+// linters log progress to stderr. The lint passes when the linter ran and
+// reported no findings; a run that exited with anything but "no issues" or
+// "issues found", or whose output could not be parsed, fails it with the
+// error returned.
+func runStructuredLinter(ctx context.Context, adapter agent.LintAdapter, command, workingDir string, result *ReviewResult) (string, error) {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	cmd.Dir = workingDir
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+	output := string(stdout)
+
+	var exitErr *exec.ExitError
+	ran := err == nil || (errors.As(err, &exitErr) && exitErr.ExitCode() == agent.LintIssuesExitCode)
+	findings, parseErr := adapter.Parse(output)
+	if ran && parseErr == nil {
+		result.LintFindings = findings
+		result.LintPassed = len(findings) == 0 && err == nil
+		if err != nil && len(findings) == 0 {
+			return output, err
+		}
+		return output, nil
+	}
+
+	result.LintPassed = false
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		output += "\n" + msg
+		if err != nil {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("cannot parse the %s output: %w", adapter.Name(), parseErr)
+	}
+	return output, err
+}
+
 // runCommand executes a shell command and returns its output
 func runCommand(ctx context.Context, command, workingDir string) (string, error) {
 	// Split command into parts
@@ -277,12 +476,19 @@ func printReviewResults(result *ReviewResult, cycle int) {
 }
 
 // applyLLMFixes uses the LLM to suggest and apply fixes for the identified issues
-func applyLLMFixes(ctx context.Context, result *ReviewResult, llmClient llm.Client, templateEngine *templates.Engine, targetDir string, cfg interface{}, logger interface{}) error {
+func applyLLMFixes(ctx context.Context, result *ReviewResult, llmClient llm.Client, templateEngine *templates.Engine, targetDir string, cfg interface{}, session *reviewSession) error {
 	fmt.Printf("🤖 Analyzing issues with LLM...\n")
 
 	// Create safe file operations with target directory as allowed root
 	safeOps := security.NewSafeFileOps(targetDir)
 
+	// Reports and backups go to the state directory of the workspace
+	workspaceRoot := targetDir
+	if appCfg, ok := cfg.(config.AppConfig); ok && appCfg.Project.WorkspaceRoot != "" {
+		workspaceRoot = appCfg.Project.WorkspaceRoot
+	}
+	backupDir := statedir.Path(workspaceRoot, statedir.Backups, "review-"+time.Now().Format("20060102-150405"))
+
 	// 1. Gather relevant file contents for files that might need fixing
 	fileContents := make(map[string]string)
 
@@ -307,6 +513,22 @@ func applyLLMFixes(ctx context.Context, result *ReviewResult, llmClient llm.Clie
 		return fmt.Errorf("failed to gather file contents: %w", err)
 	}
 
+	// Include files named by lint findings, which may not be Go sources
+	for _, finding := range result.LintFindings {
+		relPath := finding.File
+		if filepath.IsAbs(relPath) {
+			if rel, relErr := filepath.Rel(targetDir, relPath); relErr == nil {
+				relPath = rel
+			}
+		}
+		if _, exists := fileContents[relPath]; exists {
+			continue
+		}
+		if content, readErr := safeOps.SafeReadFile(filepath.Join(targetDir, relPath)); readErr == nil {
+			fileContents[relPath] = string(content)
+		}
+	}
+
 	// 2. Prepare template data for the review prompt
 	templateData := templates.ReviewTemplateData{
 		TestOutput:     result.TestOutput,
@@ -364,8 +586,7 @@ func applyLLMFixes(ctx context.Context, result *ReviewResult, llmClient llm.Clie
 
 	if err := json.Unmarshal([]byte(llmResponse), &response); err != nil {
 		// Save raw response for debugging
-		rawPath := "failed_review_fixes_raw.txt"
-		_ = os.WriteFile(rawPath, []byte(llmResponse), 0600)
+		rawPath, _ := saveReport(workspaceRoot, "failed_review_fixes_raw.txt", []byte(llmResponse))
 		fmt.Printf("⚠️  Failed to parse LLM response. Raw response saved to %s\n", rawPath)
 		return fmt.Errorf("failed to parse LLM JSON response: %w", err)
 	}
@@ -376,16 +597,65 @@ func applyLLMFixes(ctx context.Context, result *ReviewResult, llmClient llm.Clie
 		return nil
 	}
 
+	// The plan lists one fix per line; an edit drops the lines removed
+	var plan strings.Builder
+	for _, fix := range response.Fixes {
+		fmt.Fprintf(&plan, "%s: %s\n", fix.FilePath, fix.Reason)
+	}
+	decision, err := session.check(ctx, orchestrator.ApprovalRequest{
+		Checkpoint: orchestrator.CheckpointPlan,
+		Subject:    fmt.Sprintf("%d planned fix(es)", len(response.Fixes)),
+		Summary:    strings.TrimSpace(response.Summary + "\n\n" + plan.String()),
+		Content:    plan.String(),
+	})
+	if err != nil {
+		return err
+	}
+	if !decision.Approved() {
+		fmt.Printf("⏭️  Fix plan rejected; no fixes applied\n")
+		return nil
+	}
+	if decision.Decision == orchestrator.DecisionEdit {
+		kept := response.Fixes[:0]
+		for _, fix := range response.Fixes {
+			if planKeeps(decision.Content, fix.FilePath) {
+				kept = append(kept, fix)
+			}
+		}
+		response.Fixes = kept
+	}
+
 	fmt.Printf("🔧 Applying %d fixes suggested by LLM...\n", len(response.Fixes))
 
 	for _, fix := range response.Fixes {
 		if fix.Action == "modify" {
 			fullPath := filepath.Join(targetDir, fix.FilePath)
 
+			// Each change waits for approval; an edit replaces the content
+			original, _ := safeOps.SafeReadFile(fullPath)
+			decision, err := session.check(ctx, orchestrator.ApprovalRequest{
+				Checkpoint: orchestrator.CheckpointFileChange,
+				Subject:    fix.FilePath,
+				Summary:    fmt.Sprintf("%s\n%d line(s) now, %d after the fix; edit to see the new content", fix.Reason, strings.Count(string(original), "\n"), strings.Count(fix.Content, "\n")),
+				Content:    fix.Content,
+			})
+			if err != nil {
+				return err
+			}
+			if !decision.Approved() {
+				fmt.Printf("⏭️  Skipped fix to %s: %s\n", fix.FilePath, fix.Reason)
+				continue
+			}
+			if decision.Decision == orchestrator.DecisionEdit {
+				fix.Content = decision.Content
+			}
+
 			// Create backup
-			backupPath := fullPath + ".backup"
+			backupPath := filepath.Join(backupDir, fix.FilePath)
 			if originalContent, err := safeOps.SafeReadFile(fullPath); err == nil {
-				_ = safeOps.SafeWriteFile(backupPath, originalContent, 0600)
+				if err := os.MkdirAll(filepath.Dir(backupPath), 0750); err == nil {
+					_ = os.WriteFile(backupPath, originalContent, 0600)
+				}
 			}
 
 			// Apply fix
@@ -396,6 +666,7 @@ func applyLLMFixes(ctx context.Context, result *ReviewResult, llmClient llm.Clie
 
 			fmt.Printf("✅ Applied fix to %s: %s\n", fix.FilePath, fix.Reason)
 			result.FixesApplied = append(result.FixesApplied, fmt.Sprintf("%s: %s", fix.FilePath, fix.Reason))
+			result.ChangedFiles = append(result.ChangedFiles, fullPath)
 		}
 	}
 
@@ -414,6 +685,16 @@ func applyLLMFixes(ctx context.Context, result *ReviewResult, llmClient llm.Clie
 	return nil
 }
 
+// planKeeps reports whether an edited fix plan still has a line for path
+func planKeeps(plan, path string) bool {
+	for _, line := range strings.Split(plan, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), path+":") {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	rootCmd.AddCommand(reviewCmd)
 
@@ -424,6 +705,13 @@ func init() {
 	reviewCmd.Flags().BoolVar(&autoFix, "auto-fix", false, "Automatically attempt to fix issues using LLM")
 	reviewCmd.Flags().BoolVar(&previewFixes, "preview", false, "Show fixes only without applying them")
 	reviewCmd.Flags().BoolVar(&applyFixes, "apply", false, "Auto-apply fixes without review")
+	reviewCmd.Flags().BoolVar(&commitFixes, "commit", false, "Commit the fixed files when the review ends")
+	reviewCmd.Flags().Bool("sandbox", false, "Fix in a git worktree on a new branch (overrides config)")
+	addScopeFlag(reviewCmd)
+	addCIFlags(reviewCmd)
+	addProfileFlag(reviewCmd)
+	addAllowProtectedFlag(reviewCmd)
+	reviewCmd.Flags().StringSlice("checkpoints", nil, "Checkpoints where fixes wait for approval: plan, file_change, command, commit, or none (overrides config)")
 
 	// Make the flags mutually exclusive
 	reviewCmd.MarkFlagsMutuallyExclusive("auto-fix", "preview", "apply")
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/castrovroberto/CGE/internal/agent"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
@@ -19,6 +22,7 @@ var (
 	orchestratedMaxCycles       int
 	orchestratedAutoFix         bool
 	orchestratedDryRun          bool
+	orchestratedCommit          bool
 )
 
 // reviewOrchestratedCmd represents the orchestrated review command
@@ -37,28 +41,44 @@ It can:
 - Apply targeted fixes using patch tools
 - Iterate until all issues are resolved or max cycles reached
 
+Interactive runs stop at checkpoints to approve, reject or edit: the review
+instructions, each file change, and commits (including the one made by
+--commit). Runs without a terminal decide checkpoints by the
+[commands.review.approval] policies. The decisions are recorded in the
+review session.
+
+With --sandbox, fixes are made in a git worktree on a new branch, which is
+merged, squashed or kept when the review succeeds and discarded otherwise.
+
 Example:
   CGE review-orchestrated ./src --auto-fix --max-cycles 5
-  CGE review-orchestrated --test-cmd "go test ./..." --lint-cmd "golangci-lint run"`,
-	Args: cobra.MaximumNArgs(1),
+  CGE review-orchestrated --test-cmd "go test ./..." --lint-cmd "golangci-lint run"
+  CGE review-orchestrated --auto-fix --checkpoints plan,commit --commit`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{notifyAnnotation: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 		logger := contextkeys.LoggerFromContext(ctx)
 		cfg := contextkeys.ConfigFromContext(ctx)
 
-		// Determine target directory
-		targetDir := "."
+		// Determine target directory, the scope of the run by default
+		scopeRoot, err := applyScope(cmd, &cfg)
+		if err != nil {
+			return err
+		}
+		if err := applyAllowProtected(cmd, &cfg); err != nil {
+			return err
+		}
+		targetDir := ""
 		if len(args) > 0 {
 			targetDir = args[0]
 		}
 		if orchestratedReviewTargetDir != "" {
 			targetDir = orchestratedReviewTargetDir
 		}
-
-		// Get absolute path
-		absTargetDir, err := filepath.Abs(targetDir)
+		absTargetDir, err := scopedTarget(targetDir, scopeRoot, cfg.Project.Scope != "")
 		if err != nil {
-			return fmt.Errorf("failed to get absolute path: %w", err)
+			return err
 		}
 
 		logger.Info("Starting orchestrated code review", "target_dir", absTargetDir)
@@ -79,7 +99,7 @@ Example:
 		switch cfg.LLM.Provider {
 		case "ollama":
 			ollamaConfig := cfg.GetOllamaConfig()
-			llmClient = llm.NewOllamaClient(ollamaConfig)
+			llmClient = withModelRecovery(llm.NewOllamaClient(ollamaConfig))
 			logger.Info("Using Ollama client for orchestrated review", "host", ollamaConfig.HostURL)
 		case "openai":
 			openaiConfig := cfg.GetOpenAIConfig()
@@ -88,6 +108,10 @@ Example:
 		default:
 			return fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
 		}
+		llmClient = llm.WithConcurrencyLimits(llmClient, cfg.GetConcurrencyConfig())
+		llmClient = fitToWorkflow(ctx, &cfg, llmClient, cfg.LLM.Model, llm.AgentRequirements)
+		llmClient = meterRun(llmClient)
+		setRunTask("review of " + absTargetDir)
 
 		// Get workspace root
 		workspaceRoot := cfg.Project.WorkspaceRoot
@@ -104,23 +128,57 @@ Example:
 			return fmt.Errorf("failed to convert workspace root to absolute path: %w", err)
 		}
 
+		// Tools are restricted to the scope of the run. Fixes go to a sandbox
+		// worktree when asked to; sessions stay in the workspace
+		toolRoot := scopeRoot
+		succeeded := false
+		if orchestratedAutoFix && !orchestratedDryRun {
+			sandbox, err := startSandbox(ctx, cmd, &cfg, absWorkspaceRoot, "review")
+			if err != nil {
+				return err
+			}
+			if sandbox != nil {
+				toolRoot, absTargetDir = sandbox.Dir(scopeRoot), sandbox.Dir(absTargetDir)
+				defer func() {
+					if err := finishSandbox(ctx, sandbox, &cfg, succeeded, "Fix test and lint issues found by CGE review"); err != nil {
+						logger.Warn("Failed to finish the sandbox", "error", err)
+					}
+				}()
+			}
+		}
+
 		// Initialize tool registry with review tools
-		toolFactory := agent.NewToolFactory(absWorkspaceRoot)
+		toolFactory := agent.NewToolFactoryWithConfig(toolRoot, cfg.GetToolFactoryConfig())
 		toolRegistry := toolFactory.CreateReviewRegistry()
 
 		// Create command integrator and execute review
 		integratorConfig := cfg.GetIntegratorConfig()
 		integrator := orchestrator.NewCommandIntegrator(llmClient, toolRegistry, integratorConfig)
 
+		// Changes wait for approval at checkpoints, recorded in the session
+		gate, err := reviewApprovalGate(cmd, &cfg, false, orchestratedDryRun)
+		if err != nil {
+			return err
+		}
+		sessionManager, err := orchestrator.NewSessionManager(absWorkspaceRoot, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create session manager: %w", err)
+		}
+		integrator.SetApprovalGate(gate)
+		integrator.SetSessionManager(sessionManager)
+
 		// Run initial tests and linting to get baseline
 		logger.Info("Running initial tests and linting...")
 		initialTestOutput := ""
 		initialLintOutput := ""
+		initial := &ReviewResult{TestsPassed: true, LintPassed: true}
 
 		if orchestratedTestCommand != "" {
 			fmt.Printf("🧪 Running tests: %s\n", orchestratedTestCommand)
 			testOutput, testErr := runCommand(ctx, orchestratedTestCommand, absTargetDir)
 			initialTestOutput = testOutput
+			runTestsFailing = testErr != nil
+			initial.TestsPassed, initial.TestOutput = testErr == nil, testOutput
 			if testErr != nil {
 				fmt.Printf("❌ Tests failed\n")
 			} else {
@@ -132,6 +190,7 @@ Example:
 			fmt.Printf("🔍 Running linter: %s\n", orchestratedLintCommand)
 			lintOutput, lintErr := runCommand(ctx, orchestratedLintCommand, absTargetDir)
 			initialLintOutput = lintOutput
+			initial.LintPassed, initial.LintOutput = lintErr == nil, lintOutput
 			if lintErr != nil {
 				fmt.Printf("❌ Linting failed\n")
 			} else {
@@ -155,6 +214,7 @@ Example:
 				fmt.Printf("Lint Output:\n%s\n\n", initialLintOutput)
 			}
 			fmt.Printf("Use --auto-fix to automatically attempt fixes.\n")
+			annotateReview(absTargetDir, initial)
 			return nil
 		}
 
@@ -167,12 +227,27 @@ Example:
 			MaxCycles:  orchestratedMaxCycles,
 		}
 
+		// Files changed before the review are not committed by --commit
+		var changedBefore map[string]bool
+		if orchestratedCommit {
+			if changedBefore, err = changedFiles(ctx, absTargetDir); err != nil {
+				return err
+			}
+		}
+
 		logger.Info("Executing orchestrated review with function calling...", "model", cfg.LLM.Model)
 		reviewResponse, err := integrator.ExecuteReview(ctx, reviewRequest)
 		if err != nil {
 			logger.Error("Orchestrated review failed", "error", err)
 			return fmt.Errorf("orchestrated review failed: %w", err)
 		}
+		if reviewResponse.Rejected {
+			fmt.Printf("⏭️  Review plan rejected; nothing was changed\n")
+			if reviewResponse.SessionID != "" {
+				fmt.Printf("📝 Approval decisions recorded in session %s\n", reviewResponse.SessionID)
+			}
+			return nil
+		}
 
 		// Print results
 		fmt.Printf("\n🎯 Orchestrated Review Complete!\n")
@@ -201,14 +276,72 @@ Example:
 		}
 		fmt.Printf("  - Tool Calls: %d\n", toolCalls)
 
+		succeeded = reviewResponse.Success
+		if runTestsFailing && !orchestratedDryRun {
+			fmt.Printf("\n🧪 Running tests again: %s\n", orchestratedTestCommand)
+			if testOutput, testErr := runCommand(ctx, orchestratedTestCommand, absTargetDir); testErr != nil {
+				fmt.Printf("❌ Tests still fail\n")
+				annotateReview(absTargetDir, &ReviewResult{TestOutput: testOutput, LintPassed: true})
+			} else {
+				runTestsFailing = false
+				fmt.Printf("✅ Tests pass\n")
+			}
+		}
 		if orchestratedDryRun {
+			annotateReview(absTargetDir, initial)
 			fmt.Printf("\n🔍 Dry Run Mode: No actual changes were made\n")
+		} else if orchestratedCommit {
+			if err := commitOrchestratedReview(ctx, absTargetDir, changedBefore, gate, cfg.GetCommitConventions()); err != nil {
+				return err
+			}
+		}
+
+		if reviewResponse.SessionID != "" {
+			// The commit decision is made after the run, so record it too
+			if session, err := sessionManager.LoadSession(reviewResponse.SessionID); err == nil {
+				session.Approvals = gate.Decisions()
+				if err := sessionManager.SaveSession(session); err != nil {
+					logger.Warn("Failed to record approval decisions", "error", err)
+				}
+			}
+			if len(gate.Decisions()) > 0 {
+				fmt.Printf("📝 Approval decisions recorded in session %s\n", reviewResponse.SessionID)
+			}
 		}
 
 		return nil
 	},
 }
 
+// commitOrchestratedReview commits the files the review changed, leaving out
+// those already changed before it
+func commitOrchestratedReview(ctx context.Context, dir string, changedBefore map[string]bool, gate *orchestrator.ApprovalGate, conventions agent.CommitConventions) error {
+	changedAfter, err := changedFiles(ctx, dir)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for file := range changedAfter {
+		if !changedBefore[file] {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	committed, err := commitReviewChanges(ctx, dir, files, "Fix test and lint issues found by CGE review", conventions, gate.Check)
+	switch {
+	case errors.Is(err, errNothingToCommit):
+		fmt.Printf("ℹ️  No fixes to commit\n")
+	case err != nil:
+		return err
+	case committed:
+		fmt.Printf("✅ Committed %d fixed file(s)\n", len(files))
+	default:
+		fmt.Printf("⏭️  Commit rejected; the fixes are left uncommitted\n")
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(reviewOrchestratedCmd)
 
@@ -218,4 +351,10 @@ func init() {
 	reviewOrchestratedCmd.Flags().IntVar(&orchestratedMaxCycles, "max-cycles", 0, "Maximum number of review cycles (overrides config)")
 	reviewOrchestratedCmd.Flags().BoolVar(&orchestratedAutoFix, "auto-fix", false, "Automatically attempt to fix issues using function-calling agent")
 	reviewOrchestratedCmd.Flags().BoolVar(&orchestratedDryRun, "dry-run", false, "Show what would be done without making actual changes")
+	reviewOrchestratedCmd.Flags().BoolVar(&orchestratedCommit, "commit", false, "Commit the files changed by the review when it ends")
+	reviewOrchestratedCmd.Flags().Bool("sandbox", false, "Fix in a git worktree on a new branch (overrides config)")
+	addScopeFlag(reviewOrchestratedCmd)
+	addCIFlags(reviewOrchestratedCmd)
+	addAllowProtectedFlag(reviewOrchestratedCmd)
+	reviewOrchestratedCmd.Flags().StringSlice("checkpoints", nil, "Checkpoints where changes wait for approval: plan, file_change, command, commit, or none (overrides config)")
 }
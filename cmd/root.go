@@ -6,16 +6,38 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
+	"github.com/castrovroberto/CGE/internal/a11y"
 	"github.com/castrovroberto/CGE/internal/config" // Assuming this path is correct
 	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/ghactions"
+	"github.com/castrovroberto/CGE/internal/httpclient"
+	"github.com/castrovroberto/CGE/internal/i18n"
 	"github.com/castrovroberto/CGE/internal/logger" // New import
+	"github.com/castrovroberto/CGE/internal/notify"
+	"github.com/castrovroberto/CGE/internal/orchestrator"
+	"github.com/castrovroberto/CGE/internal/statecrypt"
+	"github.com/castrovroberto/CGE/internal/statedir"
 	"github.com/spf13/cobra"
 )
 
 var cfgFile string
 
+// accessible is the --accessible flag; see config UI.Accessible
+var accessible bool
+
+// restoreOutput undoes the plain-text output filter of the accessible mode
+var restoreOutput func()
+
+// notifyAnnotation marks long-running commands that notify the user when they finish
+const notifyAnnotation = "cge/notify-on-finish"
+
+// runStartTime is when the current command started, after configuration was loaded
+var runStartTime time.Time
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "CGE",
@@ -39,10 +61,47 @@ to quickly create a Cobra application.`,
 			return fmt.Errorf("failed to load configuration: %w", err)
 		}
 		logger.InitLogger(config.Cfg.Logging.Level) // Initialize logger after config is loaded
+		if err := logger.SetLevels(config.Cfg.Logging.Components); err != nil {
+			logger.Get().Warn("Invalid [logging.components] configuration", "error", err)
+		}
+		if err := httpclient.Configure(config.Cfg.GetHTTPOptions()); err != nil {
+			return fmt.Errorf("invalid [http] configuration: %w", err)
+		}
+		if err := statecrypt.Configure(config.Cfg.GetEncryptionOptions()); err != nil {
+			return fmt.Errorf("invalid [encryption] configuration: %w", err)
+		}
+		if err := i18n.Configure(config.Cfg.UI.Locale, config.Cfg.GetLocalesDir()); err != nil {
+			// A locale detected from the environment often has no catalog
+			if config.Cfg.UI.Locale != "" {
+				logger.Get().Warn("Failed to load message catalog", "error", err)
+			} else {
+				logger.Get().Debug("No message catalog for the detected locale", "error", err)
+			}
+		}
+		if err := configureAccessibility(cmd); err != nil {
+			logger.Get().Warn("Failed to filter output for the accessible mode", "error", err)
+		}
+		migrateStateDir(&config.Cfg)
+		debugLogFile := config.Cfg.HTTP.DebugLogFile
+		if debugLogFile == httpclient.DefaultDebugLogFile {
+			// Follows the state directory when it falls back outside the workspace
+			debugLogFile = statedir.Path(stateWorkspaceRoot(&config.Cfg), statedir.Logs, "llm-http.log")
+		}
+		httpclient.ConfigureDebugLog(debugLogFile, config.Cfg.HTTP.DebugLogMaxBody)
+		if config.Cfg.HTTP.DebugLog {
+			if err := httpclient.SetDebugLogging(true); err != nil {
+				logger.Get().Warn("Failed to enable HTTP debug log", "error", err)
+			}
+		}
+		warnOrphanedSessions(&config.Cfg)
+		enforceRetention(&config.Cfg)
+		recordChangesBeforeRun(cmd.Context(), cmd, &config.Cfg)
+		runStartTime = time.Now()
+		startProfiling(cmd, &config.Cfg)
 
 		// The context is now set by ExecuteContext before this PersistentPreRunE is called.
 		// We retrieve it and add our values.
-		ctx := cmd.Context()
+		ctx := startRunLimits(cmd.Context(), cmd)
 		ctx = context.WithValue(ctx, contextkeys.ConfigKey, &config.Cfg)
 		ctx = context.WithValue(ctx, contextkeys.LoggerKey, logger.Get())
 		cmd.SetContext(ctx) // Set the enriched context back to the command
@@ -55,12 +114,23 @@ to quickly create a Cobra application.`,
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 // It uses the provided context for the command execution.
 func ExecuteContext(ctx context.Context) error {
+	// Agent runs stop gracefully on RequestShutdown
+	ctx = orchestrator.WithShutdown(ctx, shutdown)
+
 	// Set the initial context for the root command.
 	// This context will be available in PersistentPreRunE and RunE functions.
 	rootCmd.SetContext(ctx)
 
 	// Execute the root command with the provided context.
-	if err := rootCmd.ExecuteContext(ctx); err != nil {
+	executedCmd, err := rootCmd.ExecuteContextC(ctx)
+	stopProfiling(executedCmd)
+	reportPausedSessions()
+	notifyRunFinished(ctx, executedCmd, err)
+	finishRun(ctx, executedCmd, err)
+	if restoreOutput != nil {
+		restoreOutput()
+	}
+	if err != nil {
 		// Cobra already prints the error to stderr when ExecuteContext fails.
 		// We also os.Exit(1) in the original Execute() or let main handle it.
 		// Here, we just return the error for main.go to decide.
@@ -69,6 +139,57 @@ func ExecuteContext(ctx context.Context) error {
 	return nil
 }
 
+// configureAccessibility turns the accessible mode on when --accessible, the
+// accessible setting in [ui] or CGE_ACCESSIBLE asks for it, the flag taking
+// precedence, and always for --ci runs. Everything commands print then passes
+// through a11y.Plain, with each line timestamped for --ci.
+func configureAccessibility(cmd *cobra.Command) error {
+	on := config.Cfg.UI.Accessible || a11y.FromEnv()
+	if cmd.Flags().Changed("accessible") {
+		on = accessible
+	}
+	on = on || ciMode
+	a11y.SetEnabled(on)
+	if !on || restoreOutput != nil {
+		return nil
+	}
+	// The GitHub Actions runner timestamps lines itself, and only reads
+	// annotations at the start of a line
+	var wrap func(io.Writer) io.Writer
+	if ciMode && !ghactions.Running() {
+		wrap = newTimestampWriter
+	}
+	restore, err := a11y.FilterOutputThrough(wrap)
+	if err != nil {
+		return err
+	}
+	restoreOutput = restore
+	return nil
+}
+
+// notifyRunFinished rings the bell, optionally sends a desktop notification
+// and posts a run summary to the configured chat webhooks when a long-running
+// command annotated with notifyAnnotation completes or fails
+func notifyRunFinished(ctx context.Context, cmd *cobra.Command, runErr error) {
+	if cmd == nil || cmd.Annotations[notifyAnnotation] != "true" || runStartTime.IsZero() {
+		return
+	}
+
+	opts := config.Cfg.GetNotifyOptions()
+	n := notify.New(opts)
+	if err := n.RunFinished(cmd.Name(), time.Since(runStartTime), runErr); err != nil {
+		logger.Get().Warn("Failed to send completion notification", "error", err)
+	}
+	if len(opts.Chat) == 0 {
+		return
+	}
+	// Interrupted runs are reported too
+	ctx = context.WithoutCancel(ctx)
+	if err := n.PostSummary(ctx, runSummary(ctx, cmd, &config.Cfg, runErr)); err != nil {
+		logger.Get().Warn("Failed to post run summary", "error", err)
+	}
+}
+
 // Execute is the original execute function, retained for compatibility if needed
 // but new calls should ideally use ExecuteContext.
 // Deprecated: Use ExecuteContext instead to support graceful shutdown.
@@ -82,6 +203,7 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cge/codex.toml, $HOME/.codex.toml or ./codex.toml)")
+	rootCmd.PersistentFlags().BoolVar(&accessible, "accessible", false, "Plain linear output for screen readers: no spinners, progress bars, emoji or box drawing")
 
 	// Bind flags for global config settings that can be overridden via root command
 	// Example: rootCmd.PersistentFlags().String("llm-provider", "", "LLM provider (e.g., ollama, openai)")
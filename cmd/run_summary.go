@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+// runTask describes what the current command works on, for its summary
+var runTask string
+
+// runUsage meters the LLM requests of the current command
+var runUsage = &llm.UsageMeter{}
+
+// changedBeforeRun are the files of the workspace that had uncommitted changes
+// when the command started; nil when they were not recorded
+var changedBeforeRun map[string]bool
+
+// setRunTask records what the current command works on
+func setRunTask(task string) {
+	runTask = task
+}
+
+// meterRun wraps the LLM client of a command, so the cost of its requests is
+// in the run summary and, with [budget] run_budget_usd, limited
+func meterRun(client llm.Client) llm.Client {
+	client = llm.WithUsageMeter(client, runUsage)
+	limit := config.Cfg.Budget.RunBudgetUSD
+	if limit <= 0 {
+		return client
+	}
+	pricing, ok := llm.LookupPricing(config.Cfg.LLM.Provider, config.Cfg.LLM.Model)
+	if !ok {
+		logger.Get().Warn("The run budget is not enforced: the pricing of the model is unknown", "provider", config.Cfg.LLM.Provider, "model", config.Cfg.LLM.Model)
+		return client
+	}
+	budget := llm.WithBudget(client, runUsage, pricing, limit)
+	runBudgets = append(runBudgets, budget)
+	return budget
+}
+
+// recordChangesBeforeRun remembers the files already changed when a command
+// that posts or writes run summaries starts, so its summary only lists the
+// files it changed
+func recordChangesBeforeRun(ctx context.Context, cmd *cobra.Command, cfg *config.AppConfig) {
+	if cmd.Annotations[notifyAnnotation] != "true" || (len(cfg.GetNotifyOptions().Chat) == 0 && ciSummaryFile == "") {
+		return
+	}
+	if changed, err := changedFiles(ctx, stateWorkspaceRoot(cfg)); err == nil {
+		changedBeforeRun = changed
+	}
+}
+
+// runSummary describes the command that finished with runErr
+func runSummary(ctx context.Context, cmd *cobra.Command, cfg *config.AppConfig, runErr error) notify.Summary {
+	workspaceRoot := stateWorkspaceRoot(cfg)
+	summary := notify.Summary{
+		Command:   cmd.Name(),
+		Task:      runTask,
+		Workspace: filepath.Base(workspaceRoot),
+		Success:   runErr == nil,
+		Elapsed:   time.Since(runStartTime),
+	}
+	if runErr != nil {
+		summary.Error = runErr.Error()
+	}
+
+	usage := runUsage.Snapshot()
+	summary.InputTokens, summary.CachedInputTokens, summary.OutputTokens = usage.InputTokens, usage.CachedInputTokens, usage.OutputTokens
+	if pricing, ok := llm.LookupPricing(cfg.LLM.Provider, cfg.LLM.Model); ok {
+		summary.CostUSD, summary.CostKnown = usage.Cost(pricing), true
+	} else if usage.Requests == 0 {
+		summary.CostKnown = true // Nothing was spent
+	}
+
+	if changedBeforeRun != nil {
+		if changed, err := changedFiles(ctx, workspaceRoot); err == nil {
+			for path := range changed {
+				if changedBeforeRun[path] {
+					continue
+				}
+				if rel, err := filepath.Rel(workspaceRoot, path); err == nil {
+					path = filepath.ToSlash(rel)
+				}
+				summary.FilesChanged = append(summary.FilesChanged, path)
+			}
+			sort.Strings(summary.FilesChanged)
+		}
+	}
+	return summary
+}
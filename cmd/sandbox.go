@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+// startSandbox moves a run into a git worktree on a new branch when
+// --sandbox or [sandbox] asks for it. It returns nil when the run is not
+// sandboxed.
+func startSandbox(ctx context.Context, cmd *cobra.Command, cfg *config.AppConfig, dir, name string) (*worktree.Sandbox, error) {
+	enabled := cfg.Sandbox.Enabled
+	if cmd.Flags().Changed("sandbox") {
+		enabled, _ = cmd.Flags().GetBool("sandbox")
+	}
+	if !enabled {
+		return nil, nil
+	}
+	switch cfg.Sandbox.OnSuccess {
+	case "prompt", "merge", "squash", "keep":
+	default:
+		return nil, fmt.Errorf("invalid [sandbox] on_success %q (want prompt, merge, squash or keep)", cfg.Sandbox.OnSuccess)
+	}
+
+	sandbox, err := worktree.Create(ctx, dir, cfg.Sandbox.BranchPrefix, name)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("🧪 Sandboxed on branch %s in %s\n", sandbox.Branch, sandbox.Path)
+	if sandbox.Dirty(ctx) {
+		fmt.Printf("⚠️  Uncommitted changes in your working tree are not in the sandbox\n")
+	}
+	return sandbox, nil
+}
+
+// finishSandbox ends a sandboxed run. A failed run is discarded. The changes
+// of a successful one are committed with message on the sandbox branch, which
+// is merged, squashed or kept as [sandbox] on_success says, asking the user
+// when it says "prompt".
+func finishSandbox(ctx context.Context, sandbox *worktree.Sandbox, cfg *config.AppConfig, succeeded bool, message string) error {
+	if sandbox == nil {
+		return nil
+	}
+	if !succeeded {
+		fmt.Printf("🗑️  Discarding sandbox branch %s of the failed run\n", sandbox.Branch)
+		return sandbox.Remove(ctx, false)
+	}
+
+	if _, err := sandbox.Commit(ctx, message); err != nil {
+		sandbox.Remove(ctx, true)
+		return fmt.Errorf("failed to commit the sandbox changes, kept branch %s: %w", sandbox.Branch, err)
+	}
+	files, err := sandbox.ChangedFiles(ctx)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Printf("ℹ️  The run changed nothing; removing sandbox branch %s\n", sandbox.Branch)
+		return sandbox.Remove(ctx, false)
+	}
+
+	fmt.Printf("\n🧪 Sandbox branch %s changed %d file(s):\n", sandbox.Branch, len(files))
+	for _, file := range files {
+		fmt.Printf("   %s\n", file)
+	}
+
+	action := cfg.Sandbox.OnSuccess
+	if action == "prompt" {
+		action = "keep"
+		if stdinIsTerminal() {
+			action = promptSandboxAction(bufio.NewReader(os.Stdin))
+		}
+	}
+
+	if action == "merge" || action == "squash" {
+		if err := checkCommitPolicy(ctx, sandbox.RepoRoot, files, message); err != nil {
+			fmt.Printf("🚫 Not merging: %v\n", err)
+			action = "keep"
+		}
+	}
+
+	switch action {
+	case "merge", "squash":
+		if err := sandbox.Merge(ctx, action == "squash", message); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			fmt.Printf("📌 Kept branch %s\n", sandbox.Branch)
+			return sandbox.Remove(ctx, true)
+		}
+		fmt.Printf("✅ Merged %s into %s\n", sandbox.Branch, sandbox.BaseBranch)
+		return sandbox.Remove(ctx, false)
+	case "discard":
+		fmt.Printf("🗑️  Discarded sandbox branch %s\n", sandbox.Branch)
+		return sandbox.Remove(ctx, false)
+	default:
+		fmt.Printf("📌 Kept branch %s; merge it with: git merge %s\n", sandbox.Branch, sandbox.Branch)
+		return sandbox.Remove(ctx, true)
+	}
+}
+
+// promptSandboxAction asks what to do with a successful sandbox branch
+func promptSandboxAction(in *bufio.Reader) string {
+	for {
+		fmt.Print("[m]erge, [s]quash, [k]eep branch, [d]iscard: ")
+		answer, err := in.ReadString('\n')
+		if err != nil && answer == "" {
+			return "keep"
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "m", "merge":
+			return "merge"
+		case "s", "squash":
+			return "squash"
+		case "k", "keep":
+			return "keep"
+		case "d", "discard":
+			return "discard"
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// addScopeFlag adds --scope to a command that can be restricted to a
+// subdirectory of the workspace
+func addScopeFlag(c *cobra.Command) {
+	c.Flags().String("scope", "", "Restrict tools, retrieval and indexing to this subdirectory of the workspace (overrides config)")
+}
+
+// applyScope restricts a run to the subdirectory given by --scope or
+// [project] scope, recording it in cfg and the configuration of the run
+// context. It returns the absolute directory
+// tools, retrieval and indexing are rooted at, which is the workspace root
+// when the run is not scoped. State stays in the workspace either way.
+func applyScope(cmd *cobra.Command, cfg *config.AppConfig) (string, error) {
+	if cmd.Flags().Changed("scope") {
+		cfg.Project.Scope, _ = cmd.Flags().GetString("scope")
+		if runCfg := contextkeys.ConfigPtrFromContext(cmd.Context()); runCfg != nil {
+			runCfg.Project.Scope = cfg.Project.Scope
+		}
+	}
+	scopeRoot, err := cfg.ScopeRoot()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Project.Scope != "" {
+		fmt.Printf("🎯 Scoped to %s\n", scopeRoot)
+	}
+	return scopeRoot, nil
+}
+
+// scopedTarget resolves the target directory of a review: the scope root when
+// none is given, or target, which must then be inside the scope
+func scopedTarget(target, scopeRoot string, scoped bool) (string, error) {
+	if target == "" {
+		if scoped {
+			return scopeRoot, nil
+		}
+		target = "."
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if scoped && !security.IsWithin(scopeRoot, absTarget) {
+		return "", fmt.Errorf("%s is outside the scope %s", target, scopeRoot)
+	}
+	return absTarget, nil
+}
@@ -4,13 +4,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/castrovroberto/CGE/internal/agent"
 	"github.com/castrovroberto/CGE/internal/audit"
+	"github.com/castrovroberto/CGE/internal/config"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
 	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
 	"github.com/castrovroberto/CGE/internal/orchestrator"
+	"github.com/castrovroberto/CGE/internal/redact"
+	"github.com/castrovroberto/CGE/internal/statedir"
 	"github.com/spf13/cobra"
 )
 
@@ -18,7 +23,12 @@ var (
 	sessionListAll     bool
 	sessionCleanupDays int
 	sessionExportPath  string
+	sessionExportFmt   string
+	sessionRedact      string
 	sessionCommand     string
+	sessionTimelineOut string
+	sessionTimelineGap time.Duration
+	sessionTimelineCol int
 )
 
 var sessionCmd = &cobra.Command{
@@ -36,8 +46,10 @@ Examples:
   CGE session list                    # List recent sessions
   CGE session list --all              # List all sessions
   CGE session resume <session-id>     # Resume a specific session
+  CGE session recover <session-id>    # Recover a session interrupted by a crash
   CGE session info <session-id>       # Show session information
-  CGE session export <session-id>     # Export session to JSONL
+  CGE session export <session-id>     # Export session to JSONL, without code
+  CGE session export <session-id> --format json --redact metadata  # Share a session's structure
   CGE session cleanup --days 30       # Clean up sessions older than 30 days`,
 }
 
@@ -130,120 +142,155 @@ var sessionResumeCmd = &cobra.Command{
 	Long:  `Resume a paused agent session and continue execution.`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := cmd.Context()
-		cfg := contextkeys.ConfigFromContext(ctx)
-		logger := contextkeys.LoggerFromContext(ctx)
+		return continueSession(cmd, args[0], false)
+	},
+}
 
-		sessionID := args[0]
+var sessionRecoverCmd = &cobra.Command{
+	Use:   "recover <session-id>",
+	Short: "Recover a session interrupted by a crash",
+	Long: `Continue a session whose process stopped while it was running, for example
+after a crash or SIGKILL, from its last checkpoint.
 
-		// Get workspace root
-		workspaceRoot := cfg.Project.WorkspaceRoot
-		if workspaceRoot == "" {
-			var err error
-			workspaceRoot, err = os.Getwd()
-			if err != nil {
-				return fmt.Errorf("failed to get current directory: %w", err)
-			}
-		}
+Running sessions are checkpointed after every step. Sessions left running by a
+process that died are flagged as interrupted when CGE next starts.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return continueSession(cmd, args[0], true)
+	},
+}
 
-		// Convert workspace root to absolute path to fix tool access issues
-		absWorkspaceRoot, err := filepath.Abs(workspaceRoot)
+// continueSession runs the agent on a saved session. A recovered session
+// continues from its last checkpoint after a crash; a resumed one after a pause.
+func continueSession(cmd *cobra.Command, sessionID string, recovering bool) error {
+	ctx := cmd.Context()
+	cfg := contextkeys.ConfigFromContext(ctx)
+	logger := contextkeys.LoggerFromContext(ctx)
+
+	// Get workspace root
+	workspaceRoot := cfg.Project.WorkspaceRoot
+	if workspaceRoot == "" {
+		var err error
+		workspaceRoot, err = os.Getwd()
 		if err != nil {
-			return fmt.Errorf("failed to convert workspace root to absolute path: %w", err)
+			return fmt.Errorf("failed to get current directory: %w", err)
 		}
+	}
 
-		// Initialize audit logger
-		auditLogger, err := audit.NewAuditLogger(absWorkspaceRoot, "session-resume")
-		if err != nil {
-			logger.Warn("Failed to initialize audit logger", "error", err)
-		}
-		defer func() {
-			if auditLogger != nil {
-				auditLogger.Close()
-			}
-		}()
+	// Convert workspace root to absolute path to fix tool access issues
+	absWorkspaceRoot, err := filepath.Abs(workspaceRoot)
+	if err != nil {
+		return fmt.Errorf("failed to convert workspace root to absolute path: %w", err)
+	}
 
-		// Initialize session manager
-		sessionManager, err := orchestrator.NewSessionManager(absWorkspaceRoot, auditLogger)
-		if err != nil {
-			return fmt.Errorf("failed to initialize session manager: %w", err)
+	// Initialize audit logger
+	auditLogger, err := audit.NewAuditLogger(absWorkspaceRoot, "session-resume")
+	if err != nil {
+		logger.Warn("Failed to initialize audit logger", "error", err)
+	}
+	defer func() {
+		if auditLogger != nil {
+			auditLogger.Close()
 		}
+	}()
 
-		// Load session
-		session, err := sessionManager.LoadSession(sessionID)
-		if err != nil {
-			return fmt.Errorf("failed to load session: %w", err)
-		}
+	// Initialize session manager
+	sessionManager, err := orchestrator.NewSessionManager(absWorkspaceRoot, auditLogger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize session manager: %w", err)
+	}
 
+	// Load session
+	session, err := sessionManager.LoadSession(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+
+	if recovering {
+		fmt.Printf("Recovering session: %s\n", sessionID)
+	} else {
 		fmt.Printf("Resuming session: %s\n", sessionID)
-		fmt.Printf("Command: %s | Model: %s | State: %s\n",
-			session.Command, session.Model, session.CurrentState)
-		fmt.Printf("Messages: %d | Tool Calls: %d\n",
-			len(session.Messages), len(session.ToolCalls))
-
-		// Initialize LLM client
-		var llmClient llm.Client
-		switch cfg.LLM.Provider {
-		case "ollama":
-			ollamaConfig := cfg.GetOllamaConfig()
-			llmClient = llm.NewOllamaClient(ollamaConfig)
-		case "openai":
-			openaiConfig := cfg.GetOpenAIConfig()
-			llmClient = llm.NewOpenAIClient(openaiConfig)
-		default:
-			return fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
-		}
-
-		// Initialize tool registry based on session command
-		toolFactory := agent.NewToolFactory(absWorkspaceRoot)
-		var toolRegistry *agent.Registry
-		switch session.Command {
-		case "plan":
-			toolRegistry = toolFactory.CreatePlanningRegistry()
-		case "generate":
-			toolRegistry = toolFactory.CreateGenerationRegistry()
-		case "review":
-			toolRegistry = toolFactory.CreateReviewRegistry()
-		default:
-			toolRegistry = toolFactory.CreateGenerationRegistry() // Default
-		}
-
-		// Create agent runner with session
-		runner := orchestrator.NewAgentRunnerWithSession(
-			llmClient, toolRegistry, session.SystemPrompt, session.Model, sessionManager)
-
-		// Resume the session
-		if err := runner.ResumeSession(sessionID); err != nil {
-			return fmt.Errorf("failed to resume session: %w", err)
-		}
-
-		// Continue execution with a continuation prompt
-		continuationPrompt := "Please continue from where we left off."
-		if sessionCommand != "" {
-			continuationPrompt = sessionCommand
-		}
-
-		fmt.Printf("\nContinuing session with prompt: %s\n\n", continuationPrompt)
-
-		result, err := runner.RunWithCommand(ctx, continuationPrompt, session.Command)
-		if err != nil {
-			return fmt.Errorf("session execution failed: %w", err)
-		}
+	}
+	fmt.Printf("Command: %s | Model: %s | State: %s\n",
+		session.Command, session.Model, session.CurrentState)
+	if !session.UpdatedAt.IsZero() {
+		fmt.Printf("Last checkpoint: %s\n", session.UpdatedAt.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("Messages: %d | Tool Calls: %d\n",
+		len(session.Messages), len(session.ToolCalls))
+
+	// Initialize LLM client
+	var llmClient llm.Client
+	switch cfg.LLM.Provider {
+	case "ollama":
+		ollamaConfig := cfg.GetOllamaConfig()
+		llmClient = withModelRecovery(llm.NewOllamaClient(ollamaConfig))
+	case "openai":
+		openaiConfig := cfg.GetOpenAIConfig()
+		llmClient = llm.NewOpenAIClient(openaiConfig)
+	default:
+		return fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
+	}
+	llmClient = llm.WithConcurrencyLimits(llmClient, cfg.GetConcurrencyConfig())
+	llmClient = fitToWorkflow(ctx, &cfg, llmClient, session.Model, llm.AgentRequirements)
+
+	// Initialize tool registry based on session command
+	toolFactory := agent.NewToolFactory(absWorkspaceRoot)
+	toolFactory.SetDatabaseConfig(cfg.GetDatabaseToolConfig())
+	toolFactory.SetDockerConfig(cfg.GetDockerToolConfig())
+	toolFactory.SetSnippetConfig(cfg.GetSnippetToolConfig())
+	var toolRegistry *agent.Registry
+	switch session.Command {
+	case "plan":
+		toolRegistry = toolFactory.CreatePlanningRegistry()
+	case "generate":
+		toolRegistry = toolFactory.CreateGenerationRegistry()
+	case "review":
+		toolRegistry = toolFactory.CreateReviewRegistry()
+	default:
+		toolRegistry = toolFactory.CreateGenerationRegistry() // Default
+	}
 
-		// Display results
-		fmt.Printf("\n📊 Session Resume Results:\n")
-		fmt.Printf("Success: %t\n", result.Success)
-		fmt.Printf("Total Iterations: %d\n", result.Iterations)
-		fmt.Printf("Total Tool Calls: %d\n", result.ToolCalls)
+	// Create agent runner with session
+	runner := orchestrator.NewAgentRunnerWithSession(
+		llmClient, toolRegistry, session.SystemPrompt, session.Model, sessionManager)
 
-		if result.Error != "" {
-			fmt.Printf("Error: %s\n", result.Error)
+	// Resume the session, or recover it from its last checkpoint
+	continuationPrompt := "Please continue from where we left off."
+	if recovering {
+		if err := runner.RecoverSession(sessionID); err != nil {
+			return fmt.Errorf("failed to recover session: %w", err)
 		}
+		continuationPrompt = orchestrator.RecoveryPrompt
+	} else if err := runner.ResumeSession(sessionID); err != nil {
+		return fmt.Errorf("failed to resume session: %w", err)
+	}
+
+	// Continue execution with a continuation prompt
+	if sessionCommand != "" {
+		continuationPrompt = sessionCommand
+	}
 
-		fmt.Printf("\n💬 Final Response:\n%s\n", result.FinalResponse)
+	fmt.Printf("\nContinuing session with prompt: %s\n\n", continuationPrompt)
 
-		return nil
-	},
+	result, err := runner.RunWithCommand(ctx, continuationPrompt, session.Command)
+	if err != nil {
+		return fmt.Errorf("session execution failed: %w", err)
+	}
+
+	// Display results
+	fmt.Printf("\n📊 Session Resume Results:\n")
+	fmt.Printf("Success: %t\n", result.Success)
+	fmt.Printf("Total Iterations: %d\n", result.Iterations)
+	fmt.Printf("Total Tool Calls: %d\n", result.ToolCalls)
+
+	if result.Error != "" {
+		fmt.Printf("Error: %s\n", result.Error)
+	}
+
+	fmt.Printf("\n💬 Final Response:\n%s\n", result.FinalResponse)
+
+	return nil
 }
 
 var sessionInfoCmd = &cobra.Command{
@@ -308,6 +355,11 @@ var sessionInfoCmd = &cobra.Command{
 		fmt.Printf("  Workspace: %s\n", session.WorkspaceRoot)
 		fmt.Printf("\n")
 
+		if shutdown, ok := orchestrator.SessionShutdown(session); ok && session.CurrentState == "paused" {
+			fmt.Printf("⏸️  Paused by %s at iteration %d, %s\n", shutdown.Signal, shutdown.Iteration, shutdown.Time.Format("2006-01-02 15:04:05"))
+			fmt.Printf("  Resume with: %s\n\n", shutdown.ResumeHint)
+		}
+
 		fmt.Printf("⏰ Timing:\n")
 		fmt.Printf("  Started: %s\n", session.StartTime.Format("2006-01-02 15:04:05"))
 		if session.EndTime != nil {
@@ -341,6 +393,36 @@ var sessionInfoCmd = &cobra.Command{
 		}
 		fmt.Printf("\n")
 
+		if history, ok := orchestrator.SessionHistory(session); ok {
+			fmt.Printf("🧵 History:\n")
+			fmt.Printf("  Strategy: %s\n", history.Strategy)
+			if history.Strategy != orchestrator.HistoryFull {
+				fmt.Printf("  Window: %d turns\n", history.WindowTurns)
+			}
+			fmt.Printf("  Last step: %d of %d turns sent", history.SentTurns, history.Turns)
+			if history.SummarizedTurns > 0 {
+				fmt.Printf(", %d summarized", history.SummarizedTurns)
+			}
+			if history.RetrievedTurns > 0 {
+				fmt.Printf(", %d retrieved by relevance", history.RetrievedTurns)
+			}
+			fmt.Printf("\n\n")
+		}
+
+		if len(session.Tasks) > 0 {
+			done := 0
+			for _, task := range session.Tasks {
+				if task.Status == agent.TaskDone {
+					done++
+				}
+			}
+			fmt.Printf("✅ Tasks: %d of %d done\n", done, len(session.Tasks))
+			for _, line := range strings.Split(agent.FormatChecklist(session.Tasks), "\n") {
+				fmt.Printf("  %s\n", line)
+			}
+			fmt.Printf("\n")
+		}
+
 		if len(toolStats) > 0 {
 			fmt.Printf("🔧 Tool Usage:\n")
 			for toolName, count := range toolStats {
@@ -370,6 +452,19 @@ var sessionInfoCmd = &cobra.Command{
 			}
 		}
 
+		if len(session.Approvals) > 0 {
+			fmt.Printf("\n🛂 Approval Decisions:\n")
+			for _, approval := range session.Approvals {
+				fmt.Printf("  %s %s: %s (%s, %s)\n",
+					approval.Timestamp.Format("15:04:05"),
+					approval.Checkpoint, approval.Subject,
+					approval.Decision, approval.DecidedBy)
+				if approval.Reason != "" {
+					fmt.Printf("    Reason: %s\n", approval.Reason)
+				}
+			}
+		}
+
 		return nil
 	},
 }
@@ -377,8 +472,18 @@ var sessionInfoCmd = &cobra.Command{
 var sessionExportCmd = &cobra.Command{
 	Use:   "export <session-id>",
 	Short: "Export session to JSONL format",
-	Long:  `Export a session's tool call history to JSONL format for analysis.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Export a session's tool call history to JSONL format for analysis or, with
+--format json, the whole session, for instance to attach to a bug report.
+
+Exports are redacted at the export_redaction level of the [retention] section
+of codex.toml, or --redact:
+
+  code      code blocks and file contents are replaced by placeholders giving
+            their size, and secrets are masked (default)
+  metadata  only the structure is kept: roles, tool names, file paths,
+            timings and outcomes
+  none      the session is exported as it is`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 		cfg := contextkeys.ConfigFromContext(ctx)
@@ -386,6 +491,17 @@ var sessionExportCmd = &cobra.Command{
 
 		sessionID := args[0]
 
+		level := sessionRedact
+		if level == "" {
+			level = cfg.Retention.ExportRedaction
+		}
+		if err := redact.Validate(level); err != nil {
+			return err
+		}
+		if sessionExportFmt != "jsonl" && sessionExportFmt != "json" {
+			return fmt.Errorf("unknown export format %q (want jsonl or json)", sessionExportFmt)
+		}
+
 		// Get workspace root
 		workspaceRoot := cfg.Project.WorkspaceRoot
 		if workspaceRoot == "" {
@@ -422,7 +538,7 @@ var sessionExportCmd = &cobra.Command{
 		// Determine output path
 		outputPath := sessionExportPath
 		if outputPath == "" {
-			outputPath = fmt.Sprintf("session_%s_export.jsonl", sessionID[:8])
+			outputPath = fmt.Sprintf("session_%s_export.%s", sessionID[:min(8, len(sessionID))], sessionExportFmt)
 		}
 
 		// Make path absolute
@@ -431,11 +547,16 @@ var sessionExportCmd = &cobra.Command{
 		}
 
 		// Export session
-		if err := sessionManager.ExportSessionToJSONL(sessionID, outputPath); err != nil {
+		if sessionExportFmt == "json" {
+			err = sessionManager.ExportSessionToJSON(sessionID, outputPath, level)
+		} else {
+			err = sessionManager.ExportSessionToJSONL(sessionID, outputPath, level)
+		}
+		if err != nil {
 			return fmt.Errorf("failed to export session: %w", err)
 		}
 
-		fmt.Printf("Session exported to: %s\n", outputPath)
+		fmt.Printf("Session exported to: %s (redaction: %s)\n", outputPath, level)
 		return nil
 	},
 }
@@ -527,8 +648,39 @@ var sessionAnalyticsCmd = &cobra.Command{
 				if i >= 5 { // Show top 5 tools
 					break
 				}
-				fmt.Printf("  %s: %d calls (%.1f%% success, avg %.2fs)\n",
-					tool.ToolName, tool.TotalCalls, tool.SuccessRate, tool.AverageDuration.Seconds())
+				fmt.Printf("  %s: %d calls (%.1f%% success, avg %.2fs, max %.2fs, %.1f%% of tool time)\n",
+					tool.ToolName, tool.TotalCalls, tool.SuccessRate, tool.AverageDuration.Seconds(), tool.MaxDuration.Seconds(), tool.RuntimeShare)
+				if code := tool.TopErrorCode(); code != "" {
+					fmt.Printf("    most frequent error: %s (%d of %d failures)\n", code, tool.ErrorCodes[code], tool.FailedCalls)
+				}
+			}
+			fmt.Printf("\n")
+		}
+
+		if len(report.FileHotspots) > 0 {
+			fmt.Printf("🔥 Files Touched (heatmap):\n")
+			printFileHeatmap(report.FileHotspots, 10)
+			fmt.Printf("\n")
+		}
+
+		if len(report.DirectoryChanges) > 0 {
+			fmt.Printf("📁 Change Frequency by Directory:\n")
+			for i, dir := range report.DirectoryChanges {
+				if i >= 10 {
+					break
+				}
+				fmt.Printf("  %s: %d edits to %d file(s) in %d session(s)\n", dir.Path, dir.Edits, dir.Files, dir.Sessions)
+			}
+			fmt.Printf("\n")
+		}
+
+		if routing := report.RoutingStats; routing.Tried > 0 {
+			fmt.Printf("🪶 Small-Model Routing:\n")
+			fmt.Printf("  Steps tried: %d\n", routing.Tried)
+			fmt.Printf("  Answered by the small model: %d\n", routing.AnsweredBySmall)
+			fmt.Printf("  Escalated: %d\n", routing.Escalated)
+			for reason, count := range routing.EscalationReasons {
+				fmt.Printf("    %s: %d\n", reason, count)
 			}
 			fmt.Printf("\n")
 		}
@@ -555,6 +707,47 @@ var sessionAnalyticsCmd = &cobra.Command{
 	},
 }
 
+var sessionTimelineCmd = &cobra.Command{
+	Use:   "timeline <session-id>",
+	Short: "Show where the time of a run went",
+	Long: `Show a timeline of a run: its LLM calls and tool executions with their
+durations, retried tool calls and the idle gaps between them, followed by
+the share of the run each took and the slowest tools. --html writes the
+timeline as a standalone page instead.
+
+LLM calls are recorded for sessions started with this version or later.`,
+	Example: `  CGE session timeline 1f2e3d4c
+  CGE session timeline 1f2e3d4c --html timeline.html`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		sessionManager, err := orchestrator.NewSessionManager(stateWorkspaceRoot(&cfg), nil)
+		if err != nil {
+			return fmt.Errorf("failed to initialize session manager: %w", err)
+		}
+		session, err := sessionManager.LoadSession(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to load session: %w", err)
+		}
+
+		timeline := orchestrator.BuildTimeline(session, sessionTimelineGap)
+		if sessionTimelineOut == "" {
+			timeline.WriteText(cmd.OutOrStdout(), sessionTimelineCol)
+			return nil
+		}
+		file, err := os.Create(sessionTimelineOut)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", sessionTimelineOut, err)
+		}
+		defer file.Close()
+		if err := timeline.WriteHTML(file); err != nil {
+			return fmt.Errorf("failed to write timeline: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Timeline written to %s\n", sessionTimelineOut)
+		return nil
+	},
+}
+
 var sessionCleanupCmd = &cobra.Command{
 	Use:   "cleanup",
 	Short: "Clean up old sessions",
@@ -609,6 +802,60 @@ var sessionCleanupCmd = &cobra.Command{
 	},
 }
 
+// warnOrphanedSessions flags sessions left running by a process that died
+// and tells the user how to recover them. It runs as every command starts and
+// does nothing in a workspace without sessions.
+func warnOrphanedSessions(cfg *config.AppConfig) {
+	workspaceRoot := cfg.Project.WorkspaceRoot
+	if workspaceRoot == "" {
+		workspaceRoot = "."
+	}
+	absWorkspaceRoot, err := filepath.Abs(workspaceRoot)
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(statedir.Path(absWorkspaceRoot, statedir.Sessions)); err != nil {
+		return
+	}
+
+	sessionManager, err := orchestrator.NewSessionManager(absWorkspaceRoot, nil)
+	if err != nil {
+		return
+	}
+	flagged, err := sessionManager.FlagOrphanedSessions()
+	if err != nil {
+		logger.Get().Warn("Failed to check for interrupted sessions", "error", err)
+	}
+	for _, info := range flagged {
+		fmt.Fprintf(os.Stderr, "💥 Session %s (%s) was interrupted; its last checkpoint is from %s.\n   Run 'CGE session recover %s' to continue it.\n",
+			info.SessionID, info.Command, info.UpdatedAt.Format("2006-01-02 15:04:05"), info.SessionID)
+	}
+}
+
+// printFileHeatmap prints the first limit hotspots with a bar as long as
+// their share of the edits of the most edited one
+func printFileHeatmap(hotspots []orchestrator.FileHotspot, limit int) {
+	const barWidth = 20
+	if len(hotspots) > limit {
+		hotspots = hotspots[:limit]
+	}
+	maxEdits, pathWidth := 0, 0
+	for _, hotspot := range hotspots {
+		maxEdits = max(maxEdits, hotspot.Edits)
+		pathWidth = max(pathWidth, len(hotspot.Path))
+	}
+	pathWidth = min(pathWidth, 50)
+	for _, hotspot := range hotspots {
+		filled := max(1, hotspot.Edits*barWidth/maxEdits)
+		note := ""
+		if hotspot.Untested {
+			note = ", no tests"
+		}
+		fmt.Printf("  %-*s %s%s %d edit(s) in %d session(s)%s\n", pathWidth, hotspot.Path,
+			strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled), hotspot.Edits, hotspot.Sessions, note)
+	}
+}
+
 func getStatusIcon(state string) string {
 	switch state {
 	case "running":
@@ -619,6 +866,8 @@ func getStatusIcon(state string) string {
 		return "❌"
 	case "paused":
 		return "⏸️"
+	case orchestrator.SessionStateInterrupted:
+		return "💥"
 	default:
 		return "❓"
 	}
@@ -628,9 +877,11 @@ func init() {
 	// Add subcommands
 	sessionCmd.AddCommand(sessionListCmd)
 	sessionCmd.AddCommand(sessionResumeCmd)
+	sessionCmd.AddCommand(sessionRecoverCmd)
 	sessionCmd.AddCommand(sessionInfoCmd)
 	sessionCmd.AddCommand(sessionExportCmd)
 	sessionCmd.AddCommand(sessionAnalyticsCmd)
+	sessionCmd.AddCommand(sessionTimelineCmd)
 	sessionCmd.AddCommand(sessionCleanupCmd)
 
 	// Flags for list command
@@ -638,9 +889,17 @@ func init() {
 
 	// Flags for resume command
 	sessionResumeCmd.Flags().StringVar(&sessionCommand, "command", "", "Custom command to continue with")
+	sessionRecoverCmd.Flags().StringVar(&sessionCommand, "command", "", "Custom command to continue with")
 
 	// Flags for export command
-	sessionExportCmd.Flags().StringVar(&sessionExportPath, "output", "", "Output file path (default: session_<id>_export.jsonl)")
+	sessionExportCmd.Flags().StringVar(&sessionExportPath, "output", "", "Output file path (default: session_<id>_export.<format>)")
+	sessionExportCmd.Flags().StringVar(&sessionExportFmt, "format", "jsonl", "Export format: jsonl (tool calls) or json (whole session)")
+	sessionExportCmd.Flags().StringVar(&sessionRedact, "redact", "", "Redaction level: code, metadata or none (default: export_redaction in [retention])")
+
+	// Flags for timeline command
+	sessionTimelineCmd.Flags().StringVar(&sessionTimelineOut, "html", "", "Write the timeline as an HTML page to this file")
+	sessionTimelineCmd.Flags().DurationVar(&sessionTimelineGap, "gap", orchestrator.DefaultTimelineGap, "Shortest stretch with nothing running shown as idle")
+	sessionTimelineCmd.Flags().IntVar(&sessionTimelineCol, "width", 60, "Columns of the timeline bars")
 
 	// Flags for cleanup command
 	sessionCleanupCmd.Flags().IntVar(&sessionCleanupDays, "days", 30, "Remove sessions older than this many days")
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/orchestrator"
+)
+
+// defaultShutdownGrace bounds a graceful shutdown when [shutdown] grace is unset
+const defaultShutdownGrace = 30 * time.Second
+
+// shutdown stops the agent runs of the command gracefully on SIGINT and
+// SIGTERM
+var shutdown = orchestrator.NewShutdown()
+
+// RequestShutdown asks the agent runs of the command to stop on sig: they
+// finish their tool call in flight and pause their sessions. It returns a
+// channel closed once they have, nil when no run is in progress, and how long
+// to wait for them before cancelling the command.
+func RequestShutdown(sig os.Signal) (<-chan struct{}, time.Duration) {
+	grace := config.Cfg.Shutdown.Grace
+	if grace <= 0 {
+		grace = defaultShutdownGrace
+	}
+	return shutdown.Request(signalName(sig)), grace
+}
+
+// signalName names sig the way users know it, e.g. SIGINT
+func signalName(sig os.Signal) string {
+	switch sig {
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	}
+	return sig.String()
+}
+
+// reportPausedSessions prints the sessions a shutdown paused, with how to
+// resume them
+func reportPausedSessions() {
+	for _, session := range shutdown.Paused() {
+		fmt.Fprintf(os.Stderr, "⏸️  Session %s (%s) was paused.\n   Run 'CGE session resume %s' to continue it.\n", session.SessionID, session.Command, session.SessionID)
+	}
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotName   string
+	snapshotDryRun bool
+	snapshotForce  bool
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Capture and restore the files of the workspace",
+	Long: `Snapshot captures the files of the workspace, tracked and untracked, and
+restores them exactly afterwards, so evaluation and generate runs can be
+repeated from the same starting point to compare models, prompts or settings.
+
+In a git repository, a snapshot holds the tracked files and the untracked ones
+that are not ignored; elsewhere, every file outside dependency and build
+directories. Restoring rewrites modified and deleted files and removes files
+added since; ignored files are left alone. A snapshot taken in a repository
+is only restored over the commit it was taken at, unless --force is given.
+Snapshots are kept in the state directory, with each distinct content stored
+once.`,
+	Example: `  CGE snapshot create --name baseline
+  CGE generate --plan plan.json --apply
+  CGE snapshot diff baseline
+  CGE snapshot restore baseline`,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Capture the files of the workspace",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		snap, err := takeSnapshot(cmd.Context(), stateWorkspaceRoot(&cfg), snapshotName)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "📸 Snapshot %s: %d files (%s)\n", snapshotLabel(snap), len(snap.Files), formatBytes(snap.Size()))
+		return nil
+	},
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the snapshots of the workspace",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		snapshots, err := snapshot.List(stateWorkspaceRoot(&cfg))
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		if len(snapshots) == 0 {
+			fmt.Fprintln(out, "No snapshots; take one with 'CGE snapshot create'")
+			return nil
+		}
+		for _, snap := range snapshots {
+			head := ""
+			if snap.Head != "" {
+				head = "  at " + snapshot.ShortCommit(snap.Head)
+			}
+			fmt.Fprintf(out, "  %-40s %s  %5d files  %s%s\n", snapshotLabel(snap), snap.CreatedAt.Format("2006-01-02 15:04"), len(snap.Files), formatBytes(snap.Size()), head)
+		}
+		return nil
+	},
+}
+
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff <id-or-name>",
+	Short: "Show how the workspace differs from a snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		workspaceRoot := stateWorkspaceRoot(&cfg)
+		snap, err := snapshot.Load(workspaceRoot, args[0])
+		if err != nil {
+			return err
+		}
+		changes, err := snap.Diff(cmd.Context(), workspaceRoot)
+		if err != nil {
+			return err
+		}
+		printSnapshotChanges(cmd, snap, changes, "differ")
+		return nil
+	},
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <id-or-name>",
+	Short: "Return the files of the workspace to a snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		workspaceRoot := stateWorkspaceRoot(&cfg)
+		snap, err := snapshot.Load(workspaceRoot, args[0])
+		if err != nil {
+			return err
+		}
+		if snapshotDryRun {
+			if head, moved := snap.HeadMoved(cmd.Context(), workspaceRoot); moved {
+				fmt.Fprintf(cmd.OutOrStdout(), "⚠️  %s is checked out, but the snapshot was taken at %s\n", snapshot.ShortCommit(head), snapshot.ShortCommit(snap.Head))
+			}
+			changes, err := snap.Diff(cmd.Context(), workspaceRoot)
+			if err != nil {
+				return err
+			}
+			printSnapshotChanges(cmd, snap, changes, "would be restored")
+			return nil
+		}
+		changes, err := snap.Restore(cmd.Context(), workspaceRoot, snapshotForce)
+		if errors.Is(err, snapshot.ErrHeadMoved) {
+			return fmt.Errorf("%w; check out that commit first, or pass --force to restore the files anyway", err)
+		}
+		if err != nil {
+			return err
+		}
+		printSnapshotChanges(cmd, snap, changes, "restored")
+		return nil
+	},
+}
+
+var snapshotDeleteCmd = &cobra.Command{
+	Use:   "delete <id-or-name>",
+	Short: "Delete a snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		if err := snapshot.Delete(stateWorkspaceRoot(&cfg), args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "🗑️  Deleted snapshot %s\n", args[0])
+		return nil
+	},
+}
+
+// takeSnapshot captures the workspace, logging the snapshot taken
+func takeSnapshot(ctx context.Context, workspaceRoot, name string) (*snapshot.Snapshot, error) {
+	snap, err := snapshot.Capture(ctx, workspaceRoot, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot the workspace: %w", err)
+	}
+	contextkeys.LoggerFromContext(ctx).Info("Captured workspace snapshot", "id", snap.ID, "name", name, "files", len(snap.Files))
+	return snap, nil
+}
+
+// snapshotBeforeRun captures the workspace before a run when --snapshot is
+// set, so the run can be undone or repeated from the same files
+func snapshotBeforeRun(cmd *cobra.Command, workspaceRoot string) error {
+	if enabled, _ := cmd.Flags().GetBool("snapshot"); !enabled {
+		return nil
+	}
+	snap, err := takeSnapshot(cmd.Context(), workspaceRoot, "")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📸 Snapshot %s taken; return to it with 'CGE snapshot restore %s'\n", snap.ID, snap.ID)
+	return nil
+}
+
+// snapshotLabel names a snapshot by its ID and, when it has one, its name
+func snapshotLabel(snap *snapshot.Snapshot) string {
+	if snap.Name == "" {
+		return snap.ID
+	}
+	return fmt.Sprintf("%s (%s)", snap.Name, snap.ID)
+}
+
+// printSnapshotChanges lists the files of the workspace that differ from a
+// snapshot
+func printSnapshotChanges(cmd *cobra.Command, snap *snapshot.Snapshot, changes []snapshot.Change, verb string) {
+	out := cmd.OutOrStdout()
+	if len(changes) == 0 {
+		fmt.Fprintf(out, "✅ The workspace matches snapshot %s\n", snapshotLabel(snap))
+		return
+	}
+	symbols := map[string]string{snapshot.ChangeModified: "M", snapshot.ChangeAdded: "A", snapshot.ChangeDeleted: "D"}
+	for _, change := range changes {
+		fmt.Fprintf(out, "  %s %s\n", symbols[change.Kind], change.Path)
+	}
+	fmt.Fprintf(out, "%d file(s) %s from snapshot %s\n", len(changes), verb, snapshotLabel(snap))
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotDiffCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+	snapshotCmd.AddCommand(snapshotDeleteCmd)
+	rootCmd.AddCommand(snapshotCmd)
+
+	snapshotCreateCmd.Flags().StringVar(&snapshotName, "name", "", "Name to restore the snapshot by, e.g. baseline")
+	snapshotRestoreCmd.Flags().BoolVar(&snapshotDryRun, "dry-run", false, "Show what would be restored without changing anything")
+	snapshotRestoreCmd.Flags().BoolVar(&snapshotForce, "force", false, "Restore even though another commit is checked out than when the snapshot was taken")
+}
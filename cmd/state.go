@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/orchestrator"
+	"github.com/castrovroberto/CGE/internal/statecrypt"
+	"github.com/castrovroberto/CGE/internal/statedir"
+	"github.com/castrovroberto/CGE/internal/tui/chat"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stateGCDryRun bool
+	stateDecrypt  bool
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect and prune the workspace state directory",
+	Long: `CGE keeps the state of a workspace in .cge/, or in ~/.cge/workspaces/ when
+the workspace is not writable:
+
+  manifest.json    layout version of the directory
+  encryption.json  how sessions are encrypted, with [encryption] enabled
+  sessions/        agent sessions
+  index/           semantic search index
+  audit/           audit logs of tool executions
+  cache/           derived data that can be rebuilt at any time
+  reports/         raw LLM responses kept for debugging
+  backups/         copies of files taken before auto-fixes
+  logs/            chat and HTTP debug logs
+  shared/          team configuration, rules and prompts pulled by 'CGE sync'
+  snapshots/       workspace snapshots taken by 'CGE snapshot'
+
+Directories from older CGE versions are migrated automatically.`,
+	Example: `  CGE state info          # Show where state is kept and how much space it uses
+  CGE state gc --dry-run  # Show what gc would remove
+  CGE state gc            # Prune caches, reports and backups
+  CGE state encrypt       # Encrypt existing sessions and chat histories`,
+}
+
+var stateInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the state directory and its disk usage",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		root := statedir.Root(stateWorkspaceRoot(&cfg))
+
+		manifest, err := statedir.ReadManifest(root)
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "📦 State directory: %s (layout v%d)\n", root, manifest.Version)
+		for _, subdir := range []string{statedir.Sessions, statedir.Index, statedir.Audit, statedir.Cache, statedir.Reports, statedir.Backups, statedir.Logs, statedir.Worktrees, statedir.Shared, statedir.Snapshots, statedir.Profiles} {
+			usage, err := statedir.DirUsage(filepath.Join(root, subdir))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "  %-10s %5d files  %s\n", subdir+"/", usage.Files, formatBytes(usage.Bytes))
+		}
+		return nil
+	},
+}
+
+var stateGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Prune caches, reports and backups by age and size",
+	Long: `Gc removes files from the cache, reports and backups directories that are
+older than, or do not fit in, the limits of the [state] section of codex.toml.
+It then applies the [retention] section, deleting old sessions and chat
+histories and reducing sessions to their metadata; CGE also applies it once a
+day as commands start. 'CGE session cleanup' deletes sessions by age on demand.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		root := statedir.Root(stateWorkspaceRoot(&cfg))
+
+		results, err := statedir.GC(root, cfg.GetStatePolicies(), stateGCDryRun)
+		if err != nil {
+			return fmt.Errorf("failed to prune state directory: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+		verb := "Removed"
+		if stateGCDryRun {
+			verb = "Would remove"
+		}
+		var freed int64
+		for _, result := range results {
+			fmt.Fprintf(out, "  %-10s %s %d files (%s), kept %d files (%s)\n",
+				result.Subdir+"/", verb, result.Removed, formatBytes(result.Freed), result.Kept, formatBytes(result.Size))
+			freed += result.Freed
+		}
+		fmt.Fprintf(out, "🧹 %s %s from %s\n", verb, formatBytes(freed), root)
+
+		if !cfg.GetRetention().Enabled() {
+			return nil
+		}
+		sessions, chats, err := applyRetention(&cfg, stateGCDryRun)
+		if err != nil {
+			return err
+		}
+		scrubVerb := "scrubbed"
+		if stateGCDryRun {
+			scrubVerb = "would scrub"
+		}
+		fmt.Fprintf(out, "🗑️  %s %d session(s) and %d chat histories past retention; %s %d session(s) to metadata; skipped %d in use\n",
+			verb, sessions.Deleted, chats, scrubVerb, sessions.Scrubbed, sessions.Skipped)
+		return nil
+	},
+}
+
+var stateEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt existing sessions and chat histories",
+	Long: `Encrypt seals the sessions of the workspace and the chat histories in
+~/.cge/chat_history written before [encryption] was enabled in codex.toml.
+New files are encrypted as they are written; files already encrypted are left
+alone. With --decrypt, encrypted files are turned back into plaintext, for
+instance before disabling encryption.
+
+Sessions in use by a running CGE process are skipped.`,
+	Example: `  CGE_STATE_PASSPHRASE=... CGE state encrypt
+  CGE state encrypt --decrypt`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !statecrypt.Enabled() {
+			return fmt.Errorf("encryption is not enabled; set enabled = true in the [encryption] section of codex.toml")
+		}
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		workspaceRoot := stateWorkspaceRoot(&cfg)
+		out := cmd.OutOrStdout()
+
+		manager, err := orchestrator.NewSessionManager(workspaceRoot, nil)
+		if err != nil {
+			return err
+		}
+		sessions, err := manager.ListSessions()
+		if err != nil {
+			return err
+		}
+		cipher, err := statecrypt.ForDir(statedir.Root(workspaceRoot))
+		if err != nil {
+			return err
+		}
+		converted := 0
+		for _, sessionID := range sessions {
+			// Sessions are locked while they are converted, so no process
+			// writes them meanwhile
+			if err := manager.LockSession(sessionID); err != nil {
+				fmt.Fprintf(out, "⏭️  Skipping session %s: %v\n", sessionID, err)
+				continue
+			}
+			path := statedir.Path(workspaceRoot, statedir.Sessions, fmt.Sprintf("session_%s.json", sessionID))
+			changed, err := statecrypt.ConvertFile(path, cipher, stateDecrypt)
+			manager.UnlockSession(sessionID)
+			if err != nil {
+				return fmt.Errorf("failed to convert session %s: %w", sessionID, err)
+			}
+			if changed {
+				converted++
+			}
+		}
+
+		historyDir := filepath.Join(os.Getenv("HOME"), ".cge", "chat_history")
+		histories, _ := filepath.Glob(filepath.Join(historyDir, "chat_*.json"))
+		convertedHistories := 0
+		if len(histories) > 0 {
+			if cipher, err = statecrypt.ForDir(filepath.Dir(historyDir)); err != nil {
+				return err
+			}
+		}
+		for _, path := range histories {
+			changed, err := statecrypt.ConvertFile(path, cipher, stateDecrypt)
+			if err != nil {
+				return fmt.Errorf("failed to convert %s: %w", path, err)
+			}
+			if changed {
+				convertedHistories++
+			}
+		}
+
+		verb := "Encrypted"
+		if stateDecrypt {
+			verb = "Decrypted"
+		}
+		fmt.Fprintf(out, "🔐 %s %d of %d session(s) and %d of %d chat histories\n", verb, converted, len(sessions), convertedHistories, len(histories))
+		return nil
+	},
+}
+
+// stateWorkspaceRoot returns the absolute workspace root of cfg
+func stateWorkspaceRoot(cfg *config.AppConfig) string {
+	workspaceRoot := cfg.Project.WorkspaceRoot
+	if workspaceRoot == "" {
+		workspaceRoot = "."
+	}
+	if absWorkspaceRoot, err := filepath.Abs(workspaceRoot); err == nil {
+		return absWorkspaceRoot
+	}
+	return workspaceRoot
+}
+
+// retentionStampFile, in the cache, records when retention was last applied
+// as a command started
+const retentionStampFile = "retention.stamp"
+
+// retentionInterval is how often retention is applied as commands start
+const retentionInterval = 24 * time.Hour
+
+// applyRetention enforces the [retention] limits of cfg on the sessions of the
+// workspace and the chat histories, returning what it did to sessions and how
+// many chat histories it deleted. With dryRun, nothing is changed.
+func applyRetention(cfg *config.AppConfig, dryRun bool) (orchestrator.RetentionResult, int, error) {
+	retention := cfg.GetRetention()
+	workspaceRoot := stateWorkspaceRoot(cfg)
+
+	var sessions orchestrator.RetentionResult
+	if _, err := os.Stat(statedir.Path(workspaceRoot, statedir.Sessions)); err == nil {
+		manager, err := orchestrator.NewSessionManager(workspaceRoot, nil)
+		if err != nil {
+			return sessions, 0, err
+		}
+		if sessions, err = manager.ApplyRetention(retention, dryRun); err != nil {
+			return sessions, 0, err
+		}
+	}
+
+	chats := 0
+	if retention.ChatHistoryMaxAge > 0 {
+		var err error
+		if chats, err = chat.PruneHistory(retention.ChatHistoryMaxAge, dryRun); err != nil {
+			return sessions, chats, err
+		}
+	}
+	return sessions, chats, nil
+}
+
+// enforceRetention applies the [retention] limits as commands start, at most
+// once a day, telling the user what was removed
+func enforceRetention(cfg *config.AppConfig) {
+	if !cfg.GetRetention().Enabled() {
+		return
+	}
+	stamp := statedir.Path(stateWorkspaceRoot(cfg), statedir.Cache, retentionStampFile)
+	if info, err := os.Stat(stamp); err == nil && time.Since(info.ModTime()) < retentionInterval {
+		return
+	}
+
+	sessions, chats, err := applyRetention(cfg, false)
+	if err != nil {
+		logger.Get().Warn("Failed to apply the retention policy", "error", err)
+		return
+	}
+	if sessions.Deleted > 0 || sessions.Scrubbed > 0 || chats > 0 {
+		fmt.Fprintf(os.Stderr, "🗑️  Retention: deleted %d session(s) and %d chat histories, scrubbed %d session(s) to metadata\n",
+			sessions.Deleted, chats, sessions.Scrubbed)
+	}
+	// The stamp is only kept in an existing state directory, so commands
+	// run outside a workspace do not create one
+	if _, err := os.Stat(statedir.Root(stateWorkspaceRoot(cfg))); err == nil {
+		if err := os.MkdirAll(filepath.Dir(stamp), 0750); err == nil {
+			_ = os.WriteFile(stamp, nil, 0600)
+			now := time.Now()
+			_ = os.Chtimes(stamp, now, now)
+		}
+	}
+}
+
+// migrateStateDir upgrades the state directory of the workspace to the
+// current layout as every command starts, telling the user what moved
+func migrateStateDir(cfg *config.AppConfig) {
+	applied, err := statedir.Migrate(stateWorkspaceRoot(cfg))
+	if err != nil {
+		logger.Get().Warn("Failed to migrate state directory", "error", err)
+	}
+	for _, description := range applied {
+		fmt.Fprintf(os.Stderr, "📦 Upgraded the state directory: %s\n", description)
+	}
+}
+
+// saveReport writes a file to the reports directory of the workspace and
+// returns its path
+func saveReport(workspaceRoot, name string, data []byte) (string, error) {
+	path := statedir.Path(workspaceRoot, statedir.Reports, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return path, err
+	}
+	return path, os.WriteFile(path, data, 0600)
+}
+
+// formatBytes renders a size with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	stateCmd.AddCommand(stateInfoCmd)
+	stateCmd.AddCommand(stateGCCmd)
+	stateCmd.AddCommand(stateEncryptCmd)
+	rootCmd.AddCommand(stateCmd)
+
+	stateGCCmd.Flags().BoolVar(&stateGCDryRun, "dry-run", false, "Show what would be removed without removing it")
+	stateEncryptCmd.Flags().BoolVar(&stateDecrypt, "decrypt", false, "Decrypt encrypted files back to plaintext instead")
+}
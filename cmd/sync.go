@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/teamsync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncFrom            string
+	syncRef             string
+	syncChecksum        string
+	syncVerifySignature bool
+	syncAllowUnverified bool
+	syncStatus          bool
+	syncRemove          bool
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Pull the team's shared configuration, rules and prompts",
+	Long: `Sync pulls a shared CGE setup from a git repository, a .tar.gz URL or a
+directory and installs it in the shared/ directory of the workspace state.
+A bundle may contain:
+
+  codex.toml   configuration, e.g. tool policies; the local codex.toml is
+               merged over it
+  rules.md     project rules; local .cge/rules.md follows them
+  prompts/     prompt templates; local prompts/ templates replace them
+  SHA256SUMS   optional sha256sum checksums of the files above
+
+A bundle must be verified before it is installed: pin its digest with
+--checksum, or require a signed commit with --verify-signature (checked with
+'git verify-commit' and your git signing configuration). The digest of every
+bundle is printed, so it can be published alongside the bundle. Without
+--from, the [sync] section of codex.toml says what to pull.`,
+	Example: `  CGE sync --from git@github.com:acme/cge-config.git --verify-signature
+  CGE sync --from https://example.com/cge-config.tar.gz --checksum 3b1f...
+  CGE sync            # Pull again from [sync] in codex.toml
+  CGE sync --status   # Show what is installed`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := contextkeys.ConfigFromContext(cmd.Context())
+		workspaceRoot := stateWorkspaceRoot(&cfg)
+		out := cmd.OutOrStdout()
+
+		if syncStatus {
+			source, err := teamsync.Current(workspaceRoot)
+			if errors.Is(err, fs.ErrNotExist) {
+				fmt.Fprintln(out, "No shared bundle installed; run 'CGE sync --from <source>'")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			printSyncSource(cmd, source, workspaceRoot)
+			return nil
+		}
+		if syncRemove {
+			if err := os.RemoveAll(teamsync.Dir(workspaceRoot)); err != nil {
+				return fmt.Errorf("failed to remove the shared bundle: %w", err)
+			}
+			fmt.Fprintln(out, "🗑️  Removed the shared bundle; local configuration applies on its own")
+			return nil
+		}
+
+		opts := teamsync.Options{
+			From:            cfg.Sync.From,
+			Ref:             cfg.Sync.Ref,
+			Checksum:        cfg.Sync.Checksum,
+			VerifySignature: cfg.Sync.VerifySignature || syncVerifySignature,
+			AllowUnverified: syncAllowUnverified,
+		}
+		if syncFrom != "" {
+			// A source on the command line brings its own ref and pin
+			opts.From, opts.Ref, opts.Checksum = syncFrom, syncRef, syncChecksum
+		} else {
+			if cmd.Flags().Changed("ref") {
+				opts.Ref = syncRef
+			}
+			if cmd.Flags().Changed("checksum") {
+				opts.Checksum = syncChecksum
+			}
+		}
+
+		fmt.Fprintf(out, "🔄 Syncing from %s\n", opts.From)
+		source, err := teamsync.Sync(cmd.Context(), workspaceRoot, opts)
+		if err != nil {
+			return err
+		}
+		printSyncSource(cmd, source, workspaceRoot)
+		fmt.Fprintln(out, "✅ Shared bundle installed; local codex.toml, rules.md and prompts/ still take precedence")
+		return nil
+	},
+}
+
+// printSyncSource describes an installed bundle
+func printSyncSource(cmd *cobra.Command, source *teamsync.Source, workspaceRoot string) {
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "📦 Shared bundle in %s\n", teamsync.Dir(workspaceRoot))
+	fmt.Fprintf(out, "  Source:   %s", source.From)
+	if source.Ref != "" {
+		fmt.Fprintf(out, " (%s)", source.Ref)
+	}
+	fmt.Fprintln(out)
+	if source.Revision != "" {
+		fmt.Fprintf(out, "  Revision: %s\n", source.Revision)
+	}
+	fmt.Fprintf(out, "  Digest:   %s\n", source.Digest)
+	verified := "not verified"
+	if len(source.Verified) > 0 {
+		verified = fmt.Sprint(source.Verified)
+	}
+	fmt.Fprintf(out, "  Verified: %s\n", verified)
+	fmt.Fprintf(out, "  Synced:   %s\n", source.SyncedAt.Local().Format("2006-01-02 15:04"))
+	for _, file := range source.Files {
+		fmt.Fprintf(out, "    %s\n", file)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().StringVar(&syncFrom, "from", "", "Git URL or path, .tar.gz URL or directory to pull from (default [sync] from)")
+	syncCmd.Flags().StringVar(&syncRef, "ref", "", "Branch, tag or commit of a git source")
+	syncCmd.Flags().StringVar(&syncChecksum, "checksum", "", "Digest the bundle must have, as printed by a previous sync")
+	syncCmd.Flags().BoolVar(&syncVerifySignature, "verify-signature", false, "Require a valid signature on the commit of a git source")
+	syncCmd.Flags().BoolVar(&syncAllowUnverified, "allow-unverified", false, "Install a bundle that is neither pinned nor signed")
+	syncCmd.Flags().BoolVar(&syncStatus, "status", false, "Show the installed bundle instead of syncing")
+	syncCmd.Flags().BoolVar(&syncRemove, "remove", false, "Remove the installed bundle")
+}
@@ -0,0 +1,194 @@
+// Package a11y supports the accessible output mode, for screen readers and
+// other assistive technology. In this mode CGE writes linear plain text:
+// spinners and progress bars are replaced by occasional status lines, emoji
+// by words or nothing, and box drawing by ASCII.
+package a11y
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+)
+
+// EnvVar enables the accessible mode when set to a true value, e.g. 1
+const EnvVar = "CGE_ACCESSIBLE"
+
+var enabled atomic.Bool
+
+// SetEnabled turns the accessible mode on or off
+func SetEnabled(on bool) {
+	enabled.Store(on)
+}
+
+// Enabled reports whether the accessible mode is on
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// FromEnv reports whether EnvVar asks for the accessible mode
+func FromEnv() bool {
+	on, err := strconv.ParseBool(os.Getenv(EnvVar))
+	return err == nil && on
+}
+
+// symbolWords are the symbols that carry meaning, spoken as a word; other
+// emoji and pictographs are decoration and dropped
+var symbolWords = map[rune]string{
+	'✅': "OK",
+	'✔': "OK",
+	'✓': "OK",
+	'🟢': "OK",
+	'❌': "Error",
+	'✗': "Error",
+	'✘': "Error",
+	'⛔': "Error",
+	'🔴': "Error",
+	'⚠': "Warning",
+	'ℹ': "Info",
+	'💡': "Tip",
+	'⎇': "branch",
+}
+
+// Plain rewrites s for the accessible mode. Meaningful symbols become words
+// ("✅ Saved" reads "OK: Saved"; "⚠️ Warning: x" just "Warning: x"), other
+// emoji, spinner frames and block elements are dropped with the space after
+// them, and box drawing becomes -, | and +. ANSI escape sequences and
+// everything else pass through.
+func Plain(s string) string {
+	if isPlain(s) {
+		return s
+	}
+	runes := []rune(s)
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if word, ok := symbolWords[r]; ok {
+			rest := strings.TrimLeft(strings.TrimLeftFunc(string(runes[i+1:]), isDecoration), " ")
+			if !hasPrefixFold(rest, word) {
+				b.WriteString(word)
+				if rest != "" && !strings.HasPrefix(rest, "\n") {
+					b.WriteString(": ")
+				}
+			}
+			i = skipDecoration(runes, i+1)
+			for i < len(runes) && runes[i] == ' ' {
+				i++
+			}
+			i--
+			continue
+		}
+		if box, ok := boxDrawing(r); ok {
+			b.WriteRune(box)
+			continue
+		}
+		if isDecoration(r) {
+			i = skipSpace(runes, skipDecoration(runes, i+1)) - 1
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isPlain reports whether s has nothing for Plain to rewrite, which is true
+// of most output
+func isPlain(s string) bool {
+	for _, r := range s {
+		if r >= 0x2100 {
+			return false
+		}
+	}
+	return true
+}
+
+// isDecoration reports whether r is an emoji, pictograph, spinner frame,
+// block element or emoji modifier
+func isDecoration(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // Emoji, pictographs, flags
+		return true
+	case r >= 0x2580 && r <= 0x25FF: // Block elements and geometric shapes
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // Miscellaneous symbols and dingbats
+		return true
+	case r >= 0x2800 && r <= 0x28FF: // Braille patterns, used by spinners
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // Stars, arrows and shapes
+		return true
+	case r == 0xFE0E || r == 0xFE0F || r == 0x200D || r == 0x20E3: // Variation selectors, joiners
+		return true
+	}
+	return false
+}
+
+// skipDecoration returns the index of the first rune from i on that is not
+// part of an emoji sequence
+func skipDecoration(runes []rune, i int) int {
+	for i < len(runes) && (runes[i] == 0xFE0E || runes[i] == 0xFE0F || runes[i] == 0x200D || (runes[i] >= 0x1F3FB && runes[i] <= 0x1F3FF)) {
+		i++
+	}
+	return i
+}
+
+// skipSpace skips one space after a dropped symbol, so "🔧 Tool" reads "Tool"
+func skipSpace(runes []rune, i int) int {
+	if i < len(runes) && runes[i] == ' ' {
+		return i + 1
+	}
+	return i
+}
+
+// boxDrawing maps box-drawing characters to ASCII
+func boxDrawing(r rune) (rune, bool) {
+	if r < 0x2500 || r > 0x257F {
+		return 0, false
+	}
+	switch r {
+	case '─', '━', '═', '┄', '┅', '┈', '┉', '╌', '╍', '╴', '╶', '╸', '╺':
+		return '-', true
+	case '│', '┃', '║', '┆', '┇', '┊', '┋', '╎', '╏', '╵', '╷', '╹', '╻':
+		return '|', true
+	}
+	return '+', true
+}
+
+// hasPrefixFold reports whether s starts with the word prefix, ignoring case
+func hasPrefixFold(s, prefix string) bool {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return false
+	}
+	rest := []rune(s[len(prefix):])
+	return len(rest) == 0 || !unicode.IsLetter(rest[0])
+}
+
+// Milestones thins out progress updates to one line per quarter of the work,
+// per task, so progress is announced without flooding a screen reader
+type Milestones struct {
+	mu   sync.Mutex
+	last map[string]int
+}
+
+// Reached reports whether fraction (0 to 1) of task crossed a new quarter
+// since the last call that returned true; the first call for a task always
+// reports true
+func (m *Milestones) Reached(task string, fraction float64) bool {
+	quarter := int(fraction * 4)
+	if quarter > 4 {
+		quarter = 4
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		m.last = make(map[string]int)
+	}
+	last, seen := m.last[task]
+	if seen && quarter <= last {
+		return false
+	}
+	m.last[task] = quarter
+	return true
+}
@@ -0,0 +1,65 @@
+package a11y
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlain(t *testing.T) {
+	tests := map[string]string{
+		"plain ascii":                "plain ascii",
+		"✅ Plan saved":               "OK: Plan saved",
+		"❌ Failed to write":          "Error: Failed to write",
+		"⚠️ Warning: index is stale": "Warning: index is stale",
+		"⚠️  disk almost full":       "Warning: disk almost full",
+		"🔧 Tool Call: read_file":     "Tool Call: read_file",
+		"   📁 cmd/":                  "   cmd/",
+		"👍🏽 Thanks":                  "Thanks",
+		"⠋ Thinking...":              "Thinking...",
+		"╭──╮\n│hi│\n╰──╯":           "+--+\n|hi|\n+--+",
+		"[███░░░] 50%":               "[] 50%",
+		"⎇ main*":                    "branch: main*",
+		"done ✓":                     "done OK",
+		"\x1b[1m✅ bold\x1b[0m":       "\x1b[1mOK: bold\x1b[0m",
+		"café → naïve":               "café → naïve",
+	}
+	for input, want := range tests {
+		assert.Equal(t, want, Plain(input), input)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(EnvVar, "1")
+	assert.True(t, FromEnv())
+	t.Setenv(EnvVar, "false")
+	assert.False(t, FromEnv())
+	t.Setenv(EnvVar, "")
+	assert.False(t, FromEnv())
+}
+
+func TestWriterSplitRune(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	data := []byte("✅ saved\n")
+	for _, chunk := range [][]byte{data[:1], data[1:2], data[2:]} {
+		n, err := w.Write(chunk)
+		require.NoError(t, err)
+		assert.Equal(t, len(chunk), n)
+	}
+	assert.Equal(t, "OK: saved\n", out.String())
+}
+
+func TestMilestones(t *testing.T) {
+	var m Milestones
+	var reported []float64
+	for _, fraction := range []float64{0, 0.1, 0.2, 0.3, 0.45, 0.5, 0.9, 1, 1} {
+		if m.Reached("embed", fraction) {
+			reported = append(reported, fraction)
+		}
+	}
+	assert.Equal(t, []float64{0, 0.3, 0.5, 0.9, 1}, reported)
+	assert.True(t, m.Reached("other", 0.5), "tasks are tracked separately")
+}
@@ -0,0 +1,108 @@
+package a11y
+
+import (
+	"io"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+// Writer passes everything written through Plain
+type Writer struct {
+	w       io.Writer
+	partial []byte // Start of a rune cut off at the end of the last write
+}
+
+// NewWriter returns a Writer writing plain text to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write implements io.Writer. Bytes of a rune split across writes are held
+// back until the rune is complete.
+func (pw *Writer) Write(p []byte) (int, error) {
+	data := append(pw.partial, p...)
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	pw.partial = append([]byte(nil), data[cut:]...)
+	if _, err := io.WriteString(pw.w, Plain(string(data[:cut]))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+var (
+	terminalOut = os.Stdout
+	terminalErr = os.Stderr
+)
+
+// Stdout returns the standard output as it was before FilterOutput, for
+// programs such as the chat TUI that need the terminal itself
+func Stdout() *os.File {
+	return terminalOut
+}
+
+// FilterOutput replaces os.Stdout and os.Stderr with pipes whose output is
+// passed through Plain, so everything a command prints reads linearly. The
+// returned function restores them, after writing out what is still buffered;
+// call it before the process exits.
+func FilterOutput() (restore func(), err error) {
+	return FilterOutputThrough(nil)
+}
+
+// FilterOutputThrough is FilterOutput with the plain text written to the
+// writer wrap returns for the original file, e.g. to prefix lines; a nil wrap
+// writes it as it is
+func FilterOutputThrough(wrap func(io.Writer) io.Writer) (restore func(), err error) {
+	stdout, err := filterFile(&os.Stdout, wrap)
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := filterFile(&os.Stderr, wrap)
+	if err != nil {
+		stdout()
+		return nil, err
+	}
+	return func() {
+		stdout()
+		stderr()
+	}, nil
+}
+
+// filterFile points *file at a pipe copied to the original file through a
+// Writer, and wrap when not nil, returning a function that undoes it
+func filterFile(file **os.File, wrap func(io.Writer) io.Writer) (func(), error) {
+	original := *file
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	var out io.Writer = original
+	if wrap != nil {
+		out = wrap(original)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(NewWriter(out), r)
+		r.Close()
+	}()
+	*file = w
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			*file = original
+			w.Close()
+			wg.Wait()
+		})
+	}, nil
+}
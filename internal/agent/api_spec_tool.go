@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/apispec"
+)
+
+// GetAPISpecParams are the parameters of get_api_spec
+type GetAPISpecParams struct {
+	Query string `json:"query" description:"Words to match against paths, operation IDs, summaries, service and type names; omit to list everything"`
+	Name  string `json:"name" description:"Exact operation ID, path, service or type name to return in full"`
+	Limit int    `json:"limit" description:"Most endpoints, services and types of each kind to return" jsonschema:"default=20,minimum=1,maximum=100"`
+}
+
+// NewAPISpecTool creates the get_api_spec tool, which returns the endpoints,
+// gRPC services and types defined by the OpenAPI specifications and .proto
+// files of the workspace
+func NewAPISpecTool(workspaceRoot string) Tool {
+	return NewTypedTool("get_api_spec", `Returns the API contracts defined by the OpenAPI/Swagger specifications and .proto files in the workspace: HTTP endpoints with their parameters, request bodies and responses, gRPC services and their RPCs, and the schemas and messages they exchange. Use it before writing handlers, clients or tests for an API so the code matches the specification.
+
+USAGE EXAMPLES:
+- get_api_spec({"query": "pets"})
+- get_api_spec({"name": "CreateOrder"})
+- get_api_spec({})`,
+		func(ctx context.Context, params GetAPISpecParams) (interface{}, error) {
+			if params.Limit == 0 {
+				params.Limit = 20
+			}
+			api, err := apispec.Load(workspaceRoot)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load API specifications: %w", err)
+			}
+			if len(api.Specs) == 0 {
+				return nil, NewStandardizedError(ErrorCodeFileNotFound, "no OpenAPI specifications or .proto files found in the workspace", "The workspace does not define its API in a specification; read the handler code instead")
+			}
+
+			var selected *apispec.API
+			if params.Name != "" {
+				selected = named(api, params.Name)
+				if selected.Empty() {
+					return nil, NewStandardizedError(ErrorCodeFileNotFound, fmt.Sprintf("nothing named %s in the API specifications", params.Name), "Use get_api_spec with a query to find the right name")
+				}
+			} else {
+				selected = api.Select(params.Query, params.Limit)
+			}
+			return map[string]interface{}{
+				"specs":     selected.Specs,
+				"endpoints": selected.Endpoints,
+				"services":  selected.Services,
+				"types":     selected.Types,
+				"errors":    selected.Errors,
+				"total": map[string]int{
+					"endpoints": len(api.Endpoints),
+					"services":  len(api.Services),
+					"types":     len(api.Types),
+				},
+			}, nil
+		})
+}
+
+// named returns the endpoints, services and types of api called name. Proto
+// names match with or without their package.
+func named(api *apispec.API, name string) *apispec.API {
+	matches := func(candidates ...string) bool {
+		for _, candidate := range candidates {
+			if candidate != "" && (strings.EqualFold(candidate, name) || strings.EqualFold(candidate[strings.LastIndex(candidate, ".")+1:], name)) {
+				return true
+			}
+		}
+		return false
+	}
+	found := &apispec.API{Specs: api.Specs}
+	for _, e := range api.Endpoints {
+		if matches(e.OperationID) || e.Path == name {
+			found.Endpoints = append(found.Endpoints, e)
+		}
+	}
+	for _, s := range api.Services {
+		if matches(s.Name) {
+			found.Services = append(found.Services, s)
+		}
+	}
+	for _, t := range api.Types {
+		if matches(t.Name) {
+			found.Types = append(found.Types, t)
+		}
+	}
+	return found
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/apispec"
+)
+
+func TestAPISpecTool(t *testing.T) {
+	root := t.TempDir()
+	proto := `syntax = "proto3";
+package shop.v1;
+service OrderService { rpc GetOrder(GetOrderRequest) returns (Order); }
+message GetOrderRequest { string id = 1; }
+message Order { string id = 1; int64 total = 2; }
+message Refund { string order_id = 1; }
+`
+	if err := os.WriteFile(filepath.Join(root, "shop.proto"), []byte(proto), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tool := NewAPISpecTool(root)
+
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{"name": "Order"}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	types := result.Data.(map[string]interface{})["types"].([]apispec.Type)
+	if len(types) != 1 || types[0].Name != "shop.v1.Order" || len(types[0].Fields) != 2 {
+		t.Errorf("expected the Order message, got %+v", types)
+	}
+
+	result, err = tool.Execute(context.Background(), json.RawMessage(`{"query": "GetOrder"}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	data := result.Data.(map[string]interface{})
+	if services := data["services"].([]apispec.Service); len(services) != 1 {
+		t.Errorf("expected the order service, got %+v", services)
+	}
+	for _, typ := range data["types"].([]apispec.Type) {
+		if typ.Name == "shop.v1.Refund" {
+			t.Errorf("expected only the types of the service, got %+v", data["types"])
+		}
+	}
+
+	result, err = tool.Execute(context.Background(), json.RawMessage(`{"name": "Invoice"}`))
+	if err != nil || result.Success || result.StandardizedError.Code != ErrorCodeFileNotFound {
+		t.Errorf("expected an unknown name to fail, got %v %+v", err, result)
+	}
+
+	result, err = NewAPISpecTool(t.TempDir()).Execute(context.Background(), json.RawMessage(`{}`))
+	if err != nil || result.Success || result.StandardizedError.Code != ErrorCodeFileNotFound {
+		t.Errorf("expected a workspace without specifications to fail, got %v %+v", err, result)
+	}
+}
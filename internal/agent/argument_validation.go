@@ -0,0 +1,258 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ArgumentError is an argument of a tool call that does not match the tool's
+// parameter schema
+type ArgumentError struct {
+	Field   string `json:"field"`   // Path of the argument, e.g. "edits[1].line"
+	Problem string `json:"problem"` // What is wrong, e.g. "must be an integer, got string"
+}
+
+// String describes the problem, e.g. "start_line must be an integer"
+func (e ArgumentError) String() string {
+	return e.Field + " " + e.Problem
+}
+
+// ValidateArguments checks the arguments of a tool call against the tool's
+// JSON schema: types, required fields, enums, patterns, ranges and lengths,
+// in nested objects and arrays too. Every offending field is listed in one
+// error, so a retry can fix them all. Arguments are not checked when the tool
+// has no usable schema.
+func ValidateArguments(arguments, schema json.RawMessage) *StandardizedToolError {
+	var schemaMap map[string]interface{}
+	if len(schema) == 0 || json.Unmarshal(schema, &schemaMap) != nil {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(arguments, &value); err != nil {
+		return NewStandardizedError(
+			ErrorCodeInvalidParameters,
+			fmt.Sprintf("Arguments are not valid JSON: %v", err),
+			"Send the arguments as a JSON object matching the tool's parameters",
+		)
+	}
+
+	var problems []ArgumentError
+	validateValue("", value, schemaMap, &problems)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	listed := make([]string, len(problems))
+	fields := make([]string, len(problems))
+	for i, problem := range problems {
+		listed[i] = problem.String()
+		fields[i] = problem.Field
+	}
+	return NewStandardizedError(
+		ErrorCodeInvalidParameters,
+		fmt.Sprintf("%d invalid argument(s): %s", len(problems), strings.Join(listed, "; ")),
+		"Fix the listed arguments to match the tool's parameters and call it again; the other arguments can stay as they are",
+	).WithDetail("fields", fields).WithDetail("problems", problems)
+}
+
+// validateValue appends the problems of value, at path, to problems
+func validateValue(path string, value interface{}, schema map[string]interface{}, problems *[]ArgumentError) {
+	report := func(format string, args ...interface{}) {
+		field := path
+		if field == "" {
+			field = "arguments"
+		}
+		*problems = append(*problems, ArgumentError{Field: field, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesAnyType(value, types) {
+		report("must be %s, got %s", strings.Join(types, " or "), jsonTypeOf(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(value, enum) {
+		allowed := make([]string, len(enum))
+		for i, v := range enum {
+			data, _ := json.Marshal(v)
+			allowed[i] = string(data)
+		}
+		report("must be one of %s", strings.Join(allowed, ", "))
+	}
+
+	switch v := value.(type) {
+	case string:
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				report("must match the pattern %s", pattern)
+			}
+		}
+		if minLength, ok := schema["minLength"].(float64); ok && len(v) < int(minLength) {
+			report("must be at least %d characters", int(minLength))
+		}
+		if maxLength, ok := schema["maxLength"].(float64); ok && len(v) > int(maxLength) {
+			report("must be at most %d characters", int(maxLength))
+		}
+	case float64:
+		if minimum, ok := schema["minimum"].(float64); ok && v < minimum {
+			report("must be >= %g, got %g", minimum, v)
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && v > maximum {
+			report("must be <= %g, got %g", maximum, v)
+		}
+	case []interface{}:
+		if minItems, ok := schema["minItems"].(float64); ok && len(v) < int(minItems) {
+			report("must have at least %d items", int(minItems))
+		}
+		if maxItems, ok := schema["maxItems"].(float64); ok && len(v) > int(maxItems) {
+			report("must have at most %d items", int(maxItems))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(fmt.Sprintf("%s[%d]", path, i), item, items, problems)
+			}
+		}
+	case map[string]interface{}:
+		validateObject(path, v, schema, problems)
+	}
+}
+
+// validateObject appends the problems of the fields of object, at path, to
+// problems. Optional fields set to null count as left out.
+func validateObject(path string, object map[string]interface{}, schema map[string]interface{}, problems *[]ArgumentError) {
+	fieldPath := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			key, ok := name.(string)
+			if !ok {
+				continue
+			}
+			if value, exists := object[key]; !exists || value == nil {
+				*problems = append(*problems, ArgumentError{Field: fieldPath(key), Problem: "is required"})
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := object[key]
+		propSchema, known := properties[key].(map[string]interface{})
+		if !known {
+			if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				*problems = append(*problems, ArgumentError{Field: fieldPath(key), Problem: "is not a parameter of this tool"})
+			}
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		validateValue(fieldPath(key), value, propSchema, problems)
+	}
+}
+
+// schemaTypes returns the types allowed by a schema's type keyword, which is
+// a type name or a list of them
+func schemaTypes(typeValue interface{}) []string {
+	switch t := typeValue.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if name, ok := v.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesAnyType(value interface{}, types []string) bool {
+	for _, t := range types {
+		if matchesType(value, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(value interface{}, expected string) bool {
+	switch expected {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == float64(int64(n))
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	// Unknown types are not checked
+	return true
+}
+
+// jsonTypeOf names the JSON type of a decoded value
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", truncateForError(v))
+	case float64:
+		return fmt.Sprintf("number %g", v)
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// inEnum compares values by their JSON, as arrays and objects cannot be
+// compared directly
+func inEnum(value interface{}, enum []interface{}) bool {
+	data, _ := json.Marshal(value)
+	for _, allowed := range enum {
+		if allowedData, _ := json.Marshal(allowed); string(allowedData) == string(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateForError shortens values quoted in validation errors
+func truncateForError(s string) string {
+	const maxQuoted = 40
+	if len(s) > maxQuoted {
+		return s[:maxQuoted] + "…"
+	}
+	return s
+}
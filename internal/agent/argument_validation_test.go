@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var validationSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"file_path": {"type": "string", "minLength": 1},
+		"mode": {"type": "string", "enum": ["overwrite", "append"]},
+		"start_line": {"type": "integer", "minimum": 1},
+		"dry_run": {"type": "boolean"},
+		"edits": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"line": {"type": "integer"},
+					"text": {"type": "string"}
+				},
+				"required": ["line"],
+				"additionalProperties": false
+			}
+		}
+	},
+	"required": ["file_path"]
+}`)
+
+func TestValidateArguments(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string
+		fields    []string // Offending fields, or nil when the arguments are valid
+	}{
+		{"valid", `{"file_path": "main.go", "mode": "append", "start_line": 3}`, nil},
+		{"optional null", `{"file_path": "main.go", "mode": null}`, nil},
+		{"unknown top-level field", `{"file_path": "main.go", "extra": 1}`, nil},
+		{"missing required", `{"mode": "append"}`, []string{"file_path"}},
+		{"required null", `{"file_path": null}`, []string{"file_path"}},
+		{"wrong types", `{"file_path": 42, "dry_run": "yes"}`, []string{"dry_run", "file_path"}},
+		{"integer expected", `{"file_path": "a", "start_line": 1.5}`, []string{"start_line"}},
+		{"enum", `{"file_path": "a", "mode": "prepend"}`, []string{"mode"}},
+		{"range and length", `{"file_path": "", "start_line": 0}`, []string{"file_path", "start_line"}},
+		{"nested", `{"file_path": "a", "edits": [{"line": 1}, {"text": "x", "col": 2}]}`, []string{"edits[1].line", "edits[1].col"}},
+		{"not an object", `"main.go"`, []string{"arguments"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateArguments(json.RawMessage(tt.arguments), validationSchema)
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatalf("expected valid arguments, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected %v to be reported", tt.fields)
+			}
+			if err.Code != ErrorCodeInvalidParameters {
+				t.Errorf("expected code %s, got %s", ErrorCodeInvalidParameters, err.Code)
+			}
+			if fields := err.Details["fields"]; !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("expected fields %v, got %v", tt.fields, fields)
+			}
+			for _, field := range tt.fields {
+				if !strings.Contains(err.Message, field) {
+					t.Errorf("expected the message to name %s: %s", field, err.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateArgumentsMessage(t *testing.T) {
+	err := ValidateArguments(json.RawMessage(`{"mode": "prepend", "start_line": "3"}`), validationSchema)
+	if err == nil {
+		t.Fatal("expected invalid arguments")
+	}
+
+	want := `3 invalid argument(s): file_path is required; mode must be one of "overwrite", "append"; start_line must be integer, got string "3"`
+	if err.Message != want {
+		t.Errorf("unexpected message:\n got: %s\nwant: %s", err.Message, want)
+	}
+	if err.Permanent() {
+		t.Error("expected invalid arguments to be worth a corrected retry")
+	}
+}
+
+func TestValidateArgumentsWithoutSchema(t *testing.T) {
+	for _, schema := range []string{"", "not json"} {
+		if err := ValidateArguments(json.RawMessage(`{"anything": 1}`), json.RawMessage(schema)); err != nil {
+			t.Errorf("expected no validation with schema %q, got %v", schema, err)
+		}
+	}
+
+	if err := ValidateArguments(json.RawMessage(`{broken`), validationSchema); err == nil {
+		t.Error("expected malformed arguments to be reported")
+	}
+}
@@ -0,0 +1,148 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Checkpoint records the original content of the files tools modify, so the
+// changes can be reverted later. A checkpoint is passed to tools through their
+// Execute context, see WithCheckpoint; only the first snapshot of each file is
+// kept, so restoring returns files to their state when the checkpoint began.
+//
+// Changes made by shell commands are not recorded.
+type Checkpoint struct {
+	ID string
+
+	mu         sync.Mutex
+	files      map[string]fileSnapshot // Absolute path -> original state
+	onSnapshot func(path string)
+}
+
+// fileSnapshot is the state of a file before it was first modified
+type fileSnapshot struct {
+	existed bool
+	content []byte
+	mode    os.FileMode
+}
+
+// NewCheckpoint creates an empty checkpoint
+func NewCheckpoint(id string) *Checkpoint {
+	return &Checkpoint{ID: id, files: make(map[string]fileSnapshot)}
+}
+
+// OnSnapshot sets fn to be called with the absolute path of each file
+// recorded, as a tool is about to change it
+func (c *Checkpoint) OnSnapshot(fn func(path string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onSnapshot = fn
+}
+
+// Snapshot records the current state of path unless it was already recorded
+func (c *Checkpoint) Snapshot(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if _, ok := c.files[path]; ok {
+		c.mu.Unlock()
+		return nil
+	}
+	err = c.snapshot(path)
+	onSnapshot := c.onSnapshot
+	c.mu.Unlock()
+	if err == nil && onSnapshot != nil {
+		onSnapshot(path)
+	}
+	return err
+}
+
+// snapshot records the current state of path; callers hold mu
+func (c *Checkpoint) snapshot(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		c.files[path] = fileSnapshot{existed: false}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path) // #nosec G304 - path validated by the calling tool
+	if err != nil {
+		return err
+	}
+	c.files[path] = fileSnapshot{existed: true, content: content, mode: info.Mode().Perm()}
+	return nil
+}
+
+// Files returns the recorded files in sorted order
+func (c *Checkpoint) Files() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files := make([]string, 0, len(c.files))
+	for path := range c.files {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Restore returns every recorded file to its original state: modified files
+// get their original content back and created files are removed. It returns
+// the files restored; files that failed are reported in the error and kept
+// in the checkpoint so Restore can be retried.
+func (c *Checkpoint) Restore() ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var restored []string
+	var errs []error
+	for path, snapshot := range c.files {
+		var err error
+		if snapshot.existed {
+			err = os.WriteFile(path, snapshot.content, snapshot.mode)
+		} else if err = os.Remove(path); errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		restored = append(restored, path)
+		delete(c.files, path)
+	}
+	sort.Strings(restored)
+	return restored, errors.Join(errs...)
+}
+
+type checkpointKey struct{}
+
+// WithCheckpoint returns a context that records file changes made by the
+// tools executed with it in checkpoint
+func WithCheckpoint(ctx context.Context, checkpoint *Checkpoint) context.Context {
+	return context.WithValue(ctx, checkpointKey{}, checkpoint)
+}
+
+// CheckpointFromContext returns the checkpoint carried by ctx, if any
+func CheckpointFromContext(ctx context.Context) (*Checkpoint, bool) {
+	checkpoint, ok := ctx.Value(checkpointKey{}).(*Checkpoint)
+	return checkpoint, ok && checkpoint != nil
+}
+
+// SnapshotFile records the state of path in the checkpoint carried by ctx
+// before a tool modifies it. It does nothing when ctx carries no checkpoint,
+// so tools can call it unconditionally.
+func SnapshotFile(ctx context.Context, path string) error {
+	if checkpoint, ok := CheckpointFromContext(ctx); ok {
+		return checkpoint.Snapshot(path)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "main.go")
+	require.NoError(t, os.WriteFile(existing, []byte("package main\n"), 0600))
+
+	checkpoint := NewCheckpoint("turn-1")
+	var snapshots []string
+	checkpoint.OnSnapshot(func(path string) { snapshots = append(snapshots, path) })
+	ctx := WithCheckpoint(context.Background(), checkpoint)
+	tool := NewFileWriteTool(root)
+
+	write := func(path, content string) {
+		params, _ := json.Marshal(map[string]interface{}{"file_path": path, "content": content, "if_exists": "overwrite"})
+		result, err := tool.Execute(ctx, params)
+		require.NoError(t, err)
+		require.True(t, result.Success, result.Error)
+	}
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("main.go", "package main // second edit\n")
+	write("pkg/new.go", "package pkg\n")
+
+	assert.Equal(t, []string{existing, filepath.Join(root, "pkg", "new.go")}, checkpoint.Files())
+	assert.Equal(t, checkpoint.Files(), snapshots, "each file is reported once, when first recorded")
+
+	restored, err := checkpoint.Restore()
+	require.NoError(t, err)
+	assert.Len(t, restored, 2)
+
+	content, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(content), "the state before the first edit is restored")
+	assert.NoFileExists(t, filepath.Join(root, "pkg", "new.go"), "created files are removed")
+	assert.Empty(t, checkpoint.Files())
+
+	assert.NoError(t, SnapshotFile(context.Background(), existing), "no checkpoint in the context")
+}
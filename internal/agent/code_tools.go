@@ -2,6 +2,7 @@ package agent
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/castrovroberto/CGE/internal/analyzer"
 	"github.com/castrovroberto/CGE/internal/security"
+	"github.com/castrovroberto/CGE/internal/textutils"
 )
 
 // CodeSearchTool implements semantic code search
@@ -261,7 +263,7 @@ func (t *FileReadTool) Name() string {
 }
 
 func (t *FileReadTool) Description() string {
-	return "Read the contents of a file"
+	return fmt.Sprintf("Read the contents of a file, optionally limited to a line range or a named symbol. Output is capped at %d lines. Binary files are refused unless hex_preview is set", maxReadLines)
 }
 
 func (t *FileReadTool) Parameters() json.RawMessage {
@@ -279,6 +281,22 @@ func (t *FileReadTool) Parameters() json.RawMessage {
 			"end_line_one_indexed_inclusive": {
 				"type": "integer",
 				"description": "The line number to end reading at (1-based, inclusive)"
+			},
+			"lines": {
+				"type": "string",
+				"description": "Line range to read, e.g. \"100-180\" or \"42\". A few lines of surrounding context are included"
+			},
+			"symbol": {
+				"type": "string",
+				"description": "Function, type or method to read instead of the whole file, e.g. \"AgentRunner.Run\" or \"NewConfig\""
+			},
+			"context_lines": {
+				"type": "integer",
+				"description": "Lines of context around lines/symbol (default 3)"
+			},
+			"hex_preview": {
+				"type": "boolean",
+				"description": "For binary files, return a hex dump of the first bytes instead of failing"
 			}
 		},
 		"required": ["target_file"]
@@ -289,6 +307,14 @@ type FileReadParams struct {
 	TargetFile string `json:"target_file"`
 	StartLine  int    `json:"start_line_one_indexed,omitempty"`
 	EndLine    int    `json:"end_line_one_indexed_inclusive,omitempty"`
+	// Lines is a range such as "100-180"; padded with context lines
+	Lines string `json:"lines,omitempty"`
+	// Symbol names a declaration to read, e.g. "AgentRunner.Run"; padded with context lines
+	Symbol string `json:"symbol,omitempty"`
+	// ContextLines overrides the padding added around Lines and Symbol
+	ContextLines *int `json:"context_lines,omitempty"`
+	// HexPreview returns a hex dump of the start of a binary file
+	HexPreview bool `json:"hex_preview,omitempty"`
 }
 
 func (t *FileReadTool) Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error) {
@@ -321,11 +347,68 @@ func (t *FileReadTool) Execute(ctx context.Context, params json.RawMessage) (*To
 		}, nil
 	}
 
-	// Handle line range if specified
+	RecordFileVersion(ctx, filePath, content)
+
+	// Binary content would flood the context with noise
+	if textutils.IsBinary(content) {
+		if !p.HexPreview {
+			return NewErrorResult(NewStandardizedError(
+				ErrorCodeInvalidFileFormat,
+				fmt.Sprintf("%s is a binary file (%d bytes)", p.TargetFile, len(content)),
+				"Binary files cannot be read as text; set hex_preview=true to see its first bytes",
+			).WithDetail("file_path", p.TargetFile).WithDetail("file_size", len(content))), nil
+		}
+		preview := content
+		if len(preview) > hexPreviewBytes {
+			preview = preview[:hexPreviewBytes]
+		}
+		return NewSuccessResult(map[string]interface{}{
+			"binary":      true,
+			"file_size":   len(content),
+			"hex_preview": hex.Dump(preview),
+			"truncated":   len(content) > hexPreviewBytes,
+		}), nil
+	}
+
 	contentStr := string(content)
-	if p.StartLine > 0 || p.EndLine > 0 {
-		lines := strings.Split(contentStr, "\n")
+	lines := strings.Split(contentStr, "\n")
+	totalLines := len(lines)
+
+	// Resolve the requested range; symbol and lines get context padding
+	selected := lineRange{Start: 1, End: totalLines}
+	ranged := false
+	switch {
+	case p.Symbol != "":
+		r, err := findSymbol(filePath, contentStr, p.Symbol)
+		if err != nil {
+			return NewErrorResult(NewStandardizedError(
+				ErrorCodeInvalidParameters,
+				fmt.Sprintf("symbol %q not found in %s", p.Symbol, p.TargetFile),
+				"Check the symbol name; use Type.Method for methods, or read the file with a line range instead",
+			).WithDetail("symbol", p.Symbol)), nil
+		}
+		selected = r.pad(t.padding(p.ContextLines), totalLines)
+		ranged = true
 
+	case p.Lines != "":
+		r, err := parseLineSpec(p.Lines)
+		if err != nil {
+			return NewErrorResult(NewStandardizedError(
+				ErrorCodeInvalidLineRange,
+				fmt.Sprintf("invalid lines parameter: %v", err),
+				"Use a range such as \"100-180\" or a single line number such as \"42\"",
+			)), nil
+		}
+		if r.Start > totalLines {
+			return &ToolResult{
+				Success: false,
+				Error:   fmt.Sprintf("start line %d exceeds file length %d", r.Start, totalLines),
+			}, nil
+		}
+		selected = r.pad(t.padding(p.ContextLines), totalLines)
+		ranged = true
+
+	case p.StartLine > 0 || p.EndLine > 0:
 		// Validate line range
 		if p.StartLine > 0 && p.EndLine > 0 && p.StartLine > p.EndLine {
 			return &ToolResult{
@@ -334,38 +417,57 @@ func (t *FileReadTool) Execute(ctx context.Context, params json.RawMessage) (*To
 			}, nil
 		}
 
-		// Convert to 0-based indexing and apply range
-		startIdx := 0
 		if p.StartLine > 0 {
-			startIdx = p.StartLine - 1
+			selected.Start = p.StartLine
 		}
-
-		endIdx := len(lines)
-		if p.EndLine > 0 {
-			endIdx = p.EndLine
+		if p.EndLine > 0 && p.EndLine < totalLines {
+			selected.End = p.EndLine
 		}
 
 		// Validate bounds
-		if startIdx >= len(lines) {
+		if selected.Start > totalLines {
 			return &ToolResult{
 				Success: false,
-				Error:   fmt.Sprintf("start_line %d exceeds file length %d", p.StartLine, len(lines)),
+				Error:   fmt.Sprintf("start_line %d exceeds file length %d", p.StartLine, totalLines),
 			}, nil
 		}
+		ranged = true
+	}
 
-		if endIdx > len(lines) {
-			endIdx = len(lines)
-		}
+	// Enforce the hard size cap so huge files don't flood the context window
+	truncated := false
+	if selected.End-selected.Start+1 > maxReadLines {
+		selected.End = selected.Start + maxReadLines - 1
+		truncated = true
+	}
+	if ranged || truncated {
+		contentStr = strings.Join(lines[selected.Start-1:selected.End], "\n")
+	}
 
-		// Extract the specified range
-		selectedLines := lines[startIdx:endIdx]
-		contentStr = strings.Join(selectedLines, "\n")
+	data := map[string]interface{}{
+		"content":     contentStr,
+		"start_line":  selected.Start,
+		"end_line":    selected.End,
+		"total_lines": totalLines,
+	}
+	if p.Symbol != "" {
+		data["symbol"] = p.Symbol
+	}
+	if truncated {
+		data["truncated"] = true
+		data["note"] = fmt.Sprintf("Output capped at %d lines; request a later range with lines (e.g. \"%d-%d\") to continue", maxReadLines, selected.End+1, min(selected.End+maxReadLines, totalLines))
 	}
 
 	return &ToolResult{
 		Success: true,
-		Data: map[string]interface{}{
-			"content": contentStr,
-		},
+		Data:    data,
 	}, nil
 }
+
+// padding returns the number of context lines to add around a range or symbol
+func (t *FileReadTool) padding(requested *int) int {
+	if requested != nil && *requested >= 0 {
+		return *requested
+	}
+	return defaultReadPadding
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -166,19 +167,23 @@ func TestFileReadTool(t *testing.T) {
 			t.Fatalf("Expected no error, got: %v", err)
 		}
 
-		if !result.Success {
-			t.Errorf("Expected success reading binary file, got failure: %s", result.Error)
+		// Binary content is refused rather than dumped into the context
+		if result.Success || result.StandardizedError == nil || result.StandardizedError.Code != ErrorCodeInvalidFileFormat {
+			t.Errorf("Expected INVALID_FILE_FORMAT reading binary file, got %+v", result)
+		}
+
+		params = json.RawMessage(`{"target_file": "binary.bin", "hex_preview": true}`)
+		result, err = tool.Execute(context.Background(), params)
+		if err != nil || !result.Success {
+			t.Fatalf("Expected a hex preview, got %+v (%v)", result, err)
 		}
 
-		// The content should be readable as string (even if it contains binary data)
 		data, ok := result.Data.(map[string]interface{})
 		if !ok {
 			t.Fatalf("Expected data to be map[string]interface{}")
 		}
-
-		_, ok = data["content"].(string)
-		if !ok {
-			t.Fatalf("Expected content to be string")
+		if !strings.HasPrefix(data["hex_preview"].(string), "00000000  00 01 02 03 ff fe") || data["truncated"] != false {
+			t.Errorf("Unexpected preview: %+v", data)
 		}
 	})
 }
@@ -0,0 +1,261 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// DefaultTicketPattern matches issue tracker IDs such as PROJ-123
+const DefaultTicketPattern = `\b[A-Z][A-Z0-9]+-[0-9]+\b`
+
+// DefaultCommitTemplate keeps the message and adds the tickets of the branch
+// that it does not mention yet
+const DefaultCommitTemplate = `{{.Message}}{{if .MissingTickets}}
+
+Refs: {{join .MissingTickets ", "}}{{end}}`
+
+// CommitConventions are the commit message rules of a repository, checked in
+// the style of commitlint before committing
+type CommitConventions struct {
+	Types               []string // Allowed conventional commit types; empty allows any
+	RequireConventional bool     // The header must read "type(scope): subject"
+	RequireTicket       bool     // The message must mention a ticket ID
+	MaxHeaderLength     int      // 0 means no limit
+	TicketPattern       string   // Regexp matching ticket IDs; DefaultTicketPattern when empty
+	Template            string   // text/template rendering the final message from CommitTemplateData
+}
+
+// DefaultCommitConventions returns the rules used when none are configured
+func DefaultCommitConventions() CommitConventions {
+	return CommitConventions{
+		Types:           []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"},
+		MaxHeaderLength: 100,
+	}
+}
+
+// CommitTemplateData is what commit message templates can use
+type CommitTemplateData struct {
+	Message        string   // The message as written or generated
+	Header         string   // First line of Message
+	Body           string   // Message after the header
+	Type           string   // Conventional commit type, if the header has one
+	Scope          string   // Conventional commit scope, if the header has one
+	Branch         string   // Current branch
+	Tickets        []string // Ticket IDs from the branch name and its commits
+	MissingTickets []string // Tickets that Message does not mention
+	Files          []string // Files being committed
+}
+
+var conventionalHeader = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?: (.+)$`)
+
+// LoadCommitConventions completes conventions with the repository's commit
+// template: a .gitmessage file in workspaceRoot, or the file set by git's
+// commit.template, is used when it contains template actions. Lines starting
+// with # are comments, as in git.
+func LoadCommitConventions(workspaceRoot string, conventions CommitConventions) CommitConventions {
+	if conventions.Template != "" {
+		return conventions
+	}
+	conventions.Template = DefaultCommitTemplate
+
+	path := filepath.Join(workspaceRoot, ".gitmessage")
+	if _, err := os.Stat(path); err != nil {
+		cmd := exec.Command("git", "config", "--path", "commit.template")
+		cmd.Dir = workspaceRoot
+		output, err := cmd.Output()
+		if err != nil {
+			return conventions
+		}
+		path = strings.TrimSpace(string(output))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workspaceRoot, path)
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return conventions
+	}
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if tmpl := strings.TrimSpace(strings.Join(lines, "\n")); strings.Contains(tmpl, "{{") {
+		conventions.Template = tmpl
+	}
+	return conventions
+}
+
+// ticketPattern compiles the configured ticket pattern
+func (c CommitConventions) ticketPattern() (*regexp.Regexp, error) {
+	pattern := c.TicketPattern
+	if pattern == "" {
+		pattern = DefaultTicketPattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ticket pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// Apply renders message through the template, with the tickets found in the
+// branch name and in the commits of the branch
+func (c CommitConventions) Apply(ctx context.Context, workspaceRoot, message string, files []string) (string, CommitTemplateData, error) {
+	data := CommitTemplateData{Message: message, Files: files}
+	data.Header, data.Body, _ = strings.Cut(message, "\n")
+	data.Body = strings.TrimSpace(data.Body)
+	if m := conventionalHeader.FindStringSubmatch(data.Header); m != nil {
+		data.Type, data.Scope = m[1], m[2]
+	}
+
+	pattern, err := c.ticketPattern()
+	if err != nil {
+		return "", data, err
+	}
+	data.Branch = gitOutput(ctx, workspaceRoot, "rev-parse", "--abbrev-ref", "HEAD")
+	data.Tickets = ExtractTickets(pattern, data.Branch, branchCommitMessages(ctx, workspaceRoot))
+	mentioned := make(map[string]bool)
+	for _, ticket := range ExtractTickets(pattern, message) {
+		mentioned[ticket] = true
+	}
+	for _, ticket := range data.Tickets {
+		if !mentioned[ticket] {
+			data.MissingTickets = append(data.MissingTickets, ticket)
+		}
+	}
+
+	text := c.Template
+	if text == "" {
+		text = DefaultCommitTemplate
+	}
+	tmpl, err := template.New("commit").Funcs(template.FuncMap{
+		"join":  strings.Join,
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}).Parse(text)
+	if err != nil {
+		return "", data, fmt.Errorf("invalid commit template: %w", err)
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", data, fmt.Errorf("failed to render commit template: %w", err)
+	}
+	return strings.TrimSpace(rendered.String()), data, nil
+}
+
+// Validate returns the rules message breaks, empty when it is fine
+func (c CommitConventions) Validate(message string) []string {
+	var problems []string
+	header, body, hasBody := strings.Cut(strings.TrimSpace(message), "\n")
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return []string{"the header must not be empty"}
+	}
+	if c.MaxHeaderLength > 0 && len(header) > c.MaxHeaderLength {
+		problems = append(problems, fmt.Sprintf("the header must be at most %d characters, it has %d", c.MaxHeaderLength, len(header)))
+	}
+	if strings.HasSuffix(header, ".") {
+		problems = append(problems, "the header must not end with a period")
+	}
+	if hasBody && strings.TrimSpace(strings.SplitN(body, "\n", 2)[0]) != "" {
+		problems = append(problems, "the header must be followed by a blank line")
+	}
+
+	if m := conventionalHeader.FindStringSubmatch(header); m != nil {
+		if len(c.Types) > 0 && !containsString(c.Types, m[1]) {
+			problems = append(problems, fmt.Sprintf("type %q must be one of %s", m[1], strings.Join(c.Types, ", ")))
+		}
+	} else if c.RequireConventional {
+		problems = append(problems, `the header must read "type(scope): subject"`)
+	}
+
+	if c.RequireTicket {
+		pattern, err := c.ticketPattern()
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else if !pattern.MatchString(message) {
+			problems = append(problems, "the message must mention a ticket, e.g. PROJ-123")
+		}
+	}
+	return problems
+}
+
+// ExtractTickets returns the ticket IDs in texts, once each, in order of
+// appearance
+func ExtractTickets(pattern *regexp.Regexp, texts ...string) []string {
+	var tickets []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, ticket := range pattern.FindAllString(text, -1) {
+			if !seen[ticket] {
+				seen[ticket] = true
+				tickets = append(tickets, ticket)
+			}
+		}
+	}
+	return tickets
+}
+
+// branchCommitMessages returns the messages of the commits on the current
+// branch that are not on the default branch
+func branchCommitMessages(ctx context.Context, workspaceRoot string) string {
+	for _, base := range []string{"origin/HEAD", "main", "master"} {
+		if gitOutput(ctx, workspaceRoot, "rev-parse", "--verify", "--quiet", base) == "" {
+			continue
+		}
+		return gitOutput(ctx, workspaceRoot, "log", "-n", "20", "--format=%B", base+"..HEAD")
+	}
+	return ""
+}
+
+// gitOutput runs git in dir, returning its trimmed output or "" on failure
+func gitOutput(ctx context.Context, dir string, args ...string) string {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// applyCommitConventions renders message through conventions and checks it.
+// It returns the final message and the branch tickets, or the result to
+// report when the message breaks a rule.
+func applyCommitConventions(ctx context.Context, conventions CommitConventions, workspaceRoot, message string, files []string) (string, []string, *ToolResult) {
+	rendered, data, err := conventions.Apply(ctx, workspaceRoot, message, files)
+	if err != nil {
+		return "", nil, &ToolResult{
+			Success:           false,
+			Error:             err.Error(),
+			StandardizedError: NewStandardizedError(ErrorCodeInvalidCommitMessage, err.Error(), "Fix the commit conventions in the configuration."),
+		}
+	}
+	if problems := conventions.Validate(rendered); len(problems) > 0 {
+		message := "commit message breaks the repository conventions: " + strings.Join(problems, "; ")
+		return "", nil, &ToolResult{
+			Success:           false,
+			Error:             message,
+			StandardizedError: NewStandardizedError(ErrorCodeInvalidCommitMessage, message, "Rewrite the commit message to follow these rules and commit again."),
+		}
+	}
+	return rendered, data.Tickets, nil
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// initRepo creates a git repository with one commit on branch
+func initRepo(t *testing.T, branch string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+		{"checkout", "-q", "-b", branch},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "wip\n\nSee PROJ-7"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	return dir
+}
+
+func TestExtractTickets(t *testing.T) {
+	pattern := regexp.MustCompile(DefaultTicketPattern)
+	tickets := ExtractTickets(pattern, "feature/PROJ-123-login", "Fix PROJ-123 and OPS-9", "lowercase abc-1")
+	assert.Equal(t, []string{"PROJ-123", "OPS-9"}, tickets)
+}
+
+func TestCommitConventionsValidate(t *testing.T) {
+	conventions := DefaultCommitConventions()
+	assert.Empty(t, conventions.Validate("fix(agent): handle empty diffs\n\nDetails."))
+	assert.Empty(t, conventions.Validate("Fix the build"), "conventional headers are optional by default")
+
+	assert.Len(t, conventions.Validate("wip: stuff"), 1, "unknown type")
+	assert.Len(t, conventions.Validate("Fix the build."), 1, "trailing period")
+	assert.Len(t, conventions.Validate("Fix the build\nno blank line"), 1)
+	assert.Equal(t, []string{"the header must not be empty"}, conventions.Validate("  \n"))
+
+	conventions.RequireConventional = true
+	conventions.RequireTicket = true
+	conventions.MaxHeaderLength = 12
+	problems := conventions.Validate("Fix the whole build")
+	assert.Len(t, problems, 3)
+	assert.Empty(t, conventions.Validate("fix: PROJ-1"))
+}
+
+func TestCommitConventionsApply(t *testing.T) {
+	dir := initRepo(t, "feature/PROJ-123-login")
+	conventions := LoadCommitConventions(dir, DefaultCommitConventions())
+
+	message, data, err := conventions.Apply(context.Background(), dir, "feat: add login", []string{"login.go"})
+	require.NoError(t, err)
+	assert.Equal(t, "feature/PROJ-123-login", data.Branch)
+	assert.Equal(t, []string{"PROJ-123", "PROJ-7"}, data.Tickets)
+	assert.Equal(t, "feat: add login\n\nRefs: PROJ-123, PROJ-7", message)
+
+	message, _, err = conventions.Apply(context.Background(), dir, "feat: add login for PROJ-123", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "feat: add login for PROJ-123\n\nRefs: PROJ-7", message, "mentioned tickets are not repeated")
+}
+
+func TestLoadCommitConventionsGitmessage(t *testing.T) {
+	dir := initRepo(t, "PROJ-5")
+	gitmessage := "# Subject first\n[{{index .Tickets 0}}] {{.Message}}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmessage"), []byte(gitmessage), 0644))
+
+	conventions := LoadCommitConventions(dir, CommitConventions{})
+	message, _, err := conventions.Apply(context.Background(), dir, "Add login", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[PROJ-5] Add login", message)
+
+	// A .gitmessage without template actions is only a hint for editors
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmessage"), []byte("Subject\n\nBody\n"), 0644))
+	assert.Equal(t, DefaultCommitTemplate, LoadCommitConventions(dir, CommitConventions{}).Template)
+}
@@ -0,0 +1,591 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Coverage profile formats understood by CoverageTool
+const (
+	CoverageFormatGo   = "go"   // go test -coverprofile
+	CoverageFormatLCOV = "lcov" // jest --coverage, pytest-cov --cov-report=lcov, cargo llvm-cov --lcov
+)
+
+// CoverageToolConfig configures how CoverageTool runs the project's tests
+type CoverageToolConfig struct {
+	// TestCommand overrides the default `go test` invocation. Coverage flags are
+	// added for go test, jest, vitest, pytest and cargo llvm-cov, and the
+	// target_path a call passes is appended.
+	TestCommand string
+	// Format is the coverage profile format ("go" or "lcov"); inferred when empty
+	Format string
+	// ProfilePath is where a non-Go test command writes its coverage report
+	ProfilePath string
+}
+
+// CoverageTool runs tests with coverage and reports gaps in recently modified files
+type CoverageTool struct {
+	workspaceRoot string
+	config        CoverageToolConfig
+}
+
+// NewCoverageTool creates a coverage tool that runs `go test` by default
+func NewCoverageTool(workspaceRoot string) *CoverageTool {
+	return &CoverageTool{
+		workspaceRoot: workspaceRoot,
+	}
+}
+
+// NewCoverageToolWithConfig creates a coverage tool using the configured test command
+func NewCoverageToolWithConfig(workspaceRoot string, config CoverageToolConfig) *CoverageTool {
+	return &CoverageTool{
+		workspaceRoot: workspaceRoot,
+		config:        config,
+	}
+}
+
+func (t *CoverageTool) Name() string {
+	return "run_tests_with_coverage"
+}
+
+func (t *CoverageTool) Description() string {
+	return "Runs the test suite with coverage enabled and reports uncovered lines in the files you modified, so you can add tests that close the gaps"
+}
+
+func (t *CoverageTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"target_path": {
+				"type": "string",
+				"description": "Package or directory to test (default: ./... or everything the configured test command runs)"
+			},
+			"modified_files": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "Files to report coverage for (default: files changed in the Git working tree)"
+			},
+			"all_files": {
+				"type": "boolean",
+				"description": "Report coverage for every file in the profile instead of only modified files"
+			},
+			"timeout_seconds": {
+				"type": "integer",
+				"description": "Test execution timeout in seconds (default: 300)"
+			}
+		}
+	}`)
+}
+
+type CoverageParams struct {
+	TargetPath     string   `json:"target_path,omitempty"`
+	ModifiedFiles  []string `json:"modified_files,omitempty"`
+	AllFiles       bool     `json:"all_files,omitempty"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+}
+
+// FileCoverage describes coverage for a single source file
+type FileCoverage struct {
+	File              string   `json:"file"`
+	CoveredLines      int      `json:"covered_lines"`
+	TotalLines        int      `json:"total_lines"`
+	CoveragePercent   float64  `json:"coverage_percent"`
+	UncoveredRanges   []string `json:"uncovered_ranges,omitempty"` // e.g. "12-18", "40"
+	uncoveredLineList []int
+}
+
+// CoverageReport is the structured result returned by CoverageTool
+type CoverageReport struct {
+	TestsPassed     bool           `json:"tests_passed"`
+	TotalCoverage   float64        `json:"total_coverage_percent"`
+	Files           []FileCoverage `json:"files"`
+	FilesNotCovered []string       `json:"files_not_in_profile,omitempty"`
+	Summary         string         `json:"summary"`
+	TestOutput      string         `json:"test_output,omitempty"`
+}
+
+func (t *CoverageTool) Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error) {
+	var p CoverageParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if p.TimeoutSeconds == 0 {
+		p.TimeoutSeconds = 300
+	}
+
+	format := t.coverageFormat()
+
+	profilePath := t.config.ProfilePath
+	if format == CoverageFormatGo {
+		tmp, err := os.CreateTemp("", "cge-coverage-*.out")
+		if err != nil {
+			return NewErrorResult(NewStandardizedError(
+				ErrorCodeInternalError,
+				fmt.Sprintf("failed to create coverage profile: %v", err),
+				"Retry the operation; check that the system temp directory is writable",
+			)), nil
+		}
+		tmp.Close()
+		profilePath = tmp.Name()
+		defer os.Remove(profilePath)
+	} else if profilePath == "" {
+		profilePath = filepath.Join("coverage", "lcov.info")
+	}
+	if !filepath.IsAbs(profilePath) {
+		profilePath = filepath.Join(t.workspaceRoot, profilePath)
+	}
+
+	if format == CoverageFormatLCOV {
+		// A report left by an earlier run would pass for this run's coverage
+		if err := os.Remove(profilePath); err != nil && !os.IsNotExist(err) {
+			return NewErrorResult(NewStandardizedError(
+				ErrorCodePermissionDenied,
+				fmt.Sprintf("failed to remove the previous coverage report %s: %v", profilePath, err),
+				"Ask the user to delete the report or fix its permissions",
+			)), nil
+		}
+	}
+
+	command, args := t.buildCommand(format, profilePath, p.TargetPath)
+
+	testCtx, cancel := context.WithTimeout(ctx, time.Duration(p.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(testCtx, command, args...)
+	cmd.Dir = t.workspaceRoot
+	stopGracefully(cmd)
+	output, runErr := cmd.CombinedOutput()
+
+	if testCtx.Err() == context.DeadlineExceeded {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeTimeout,
+			fmt.Sprintf("tests did not finish within %d seconds", p.TimeoutSeconds),
+			"Narrow target_path to the packages you changed or increase timeout_seconds",
+		).WithDetail("timeout_seconds", p.TimeoutSeconds)), nil
+	}
+
+	if _, err := os.Stat(profilePath); os.IsNotExist(err) {
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("the test command did not write a coverage report to %s", profilePath),
+			Data: map[string]interface{}{
+				"test_output": truncateOutput(string(output), 4000),
+			},
+		}, nil
+	}
+
+	var coverage map[string]*FileCoverage
+	var err error
+	switch format {
+	case CoverageFormatLCOV:
+		coverage, err = parseLCOVProfile(profilePath, t.workspaceRoot)
+	default:
+		coverage, err = parseGoCoverProfile(profilePath, goModulePath(t.workspaceRoot))
+	}
+	if err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("failed to read coverage profile: %v", err),
+			Data: map[string]interface{}{
+				"test_output": truncateOutput(string(output), 4000),
+			},
+		}, nil
+	}
+
+	modified := p.ModifiedFiles
+	if len(modified) == 0 && !p.AllFiles {
+		modified = t.changedFiles(ctx)
+	}
+
+	report := buildCoverageReport(coverage, modified, p.AllFiles)
+	report.TestsPassed = runErr == nil
+	if !report.TestsPassed {
+		report.TestOutput = truncateOutput(string(output), 4000)
+	}
+
+	return &ToolResult{
+		Success: true,
+		Data:    report,
+	}, nil
+}
+
+// coverageFormat resolves the profile format from configuration
+func (t *CoverageTool) coverageFormat() string {
+	if t.config.Format != "" {
+		return t.config.Format
+	}
+	if t.config.TestCommand == "" || strings.HasPrefix(strings.TrimSpace(t.config.TestCommand), "go test") {
+		return CoverageFormatGo
+	}
+	return CoverageFormatLCOV
+}
+
+// buildCommand returns the test command with coverage output enabled,
+// narrowed to targetPath when one is given
+func (t *CoverageTool) buildCommand(format, profilePath, targetPath string) (string, []string) {
+	if t.config.TestCommand == "" {
+		if targetPath == "" {
+			targetPath = "./..."
+		}
+		return "go", []string{"test", "-coverprofile=" + profilePath, targetPath}
+	}
+
+	parts := strings.Fields(t.config.TestCommand)
+	if format == CoverageFormatGo && len(parts) >= 2 && parts[0] == "go" && parts[1] == "test" {
+		args := []string{"test", "-coverprofile=" + profilePath}
+		for _, arg := range parts[2:] {
+			// The target replaces the packages the configured command tests
+			if targetPath != "" && isGoPackagePattern(arg) {
+				continue
+			}
+			args = append(args, arg)
+		}
+		if targetPath != "" {
+			args = append(args, targetPath)
+		}
+		return "go", args
+	}
+
+	args := append([]string{}, parts[1:]...)
+	if format == CoverageFormatLCOV && !hasCoverageFlag(args) {
+		args = append(args, lcovFlags(parts, profilePath)...)
+	}
+	if targetPath != "" {
+		args = append(args, targetPath)
+	}
+	return parts[0], args
+}
+
+// isGoPackagePattern reports whether a go test argument names packages
+func isGoPackagePattern(arg string) bool {
+	return arg == "." || strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../")
+}
+
+// hasCoverageFlag reports whether a test command already asks for coverage,
+// as with pytest --cov, jest --coverage or cargo llvm-cov --lcov
+func hasCoverageFlag(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--cov") || strings.HasPrefix(arg, "--lcov") {
+			return true
+		}
+	}
+	return false
+}
+
+// lcovFlags returns the flags that make a known test runner write an LCOV
+// report to profilePath; unknown commands are expected to write it themselves.
+// Jest and Vitest name the report lcov.info in the profile's directory.
+func lcovFlags(parts []string, profilePath string) []string {
+	for i, part := range parts {
+		switch strings.TrimSuffix(filepath.Base(part), ".exe") {
+		case "jest":
+			return []string{"--coverage", "--coverageReporters=lcov", "--coverageDirectory=" + filepath.Dir(profilePath)}
+		case "vitest":
+			return []string{"--coverage.enabled", "--coverage.reporter=lcov", "--coverage.reportsDirectory=" + filepath.Dir(profilePath)}
+		case "pytest":
+			return []string{"--cov", "--cov-report=lcov:" + profilePath}
+		case "cargo":
+			if i+1 < len(parts) && parts[i+1] == "llvm-cov" {
+				return []string{"--lcov", "--output-path", profilePath}
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// changedFiles lists files modified in the Git working tree, relative to the
+// workspace. Git reports them relative to the root of the repository, which
+// the workspace may be a subdirectory of; files outside the workspace are
+// left out.
+func (t *CoverageTool) changedFiles(ctx context.Context) []string {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel")
+	cmd.Dir = t.workspaceRoot
+	toplevel, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	repoRoot := strings.TrimSpace(string(toplevel))
+	workspaceRoot, err := filepath.Abs(t.workspaceRoot)
+	if err != nil {
+		return nil
+	}
+	if resolved, err := filepath.EvalSymlinks(workspaceRoot); err == nil {
+		workspaceRoot = resolved
+	}
+
+	cmd = exec.CommandContext(ctx, "git", "status", "--porcelain", "--untracked-files=all")
+	cmd.Dir = t.workspaceRoot
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		path := strings.TrimSpace(line[3:])
+		if idx := strings.Index(path, " -> "); idx >= 0 {
+			path = path[idx+4:]
+		}
+		path = filepath.Join(repoRoot, filepath.FromSlash(strings.Trim(path, `"`)))
+		rel, err := filepath.Rel(workspaceRoot, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		files = append(files, filepath.ToSlash(rel))
+	}
+	return files
+}
+
+// buildCoverageReport narrows coverage data to the requested files
+func buildCoverageReport(coverage map[string]*FileCoverage, modified []string, allFiles bool) CoverageReport {
+	report := CoverageReport{Files: []FileCoverage{}}
+
+	var covered, total int
+	for _, fc := range coverage {
+		covered += fc.CoveredLines
+		total += fc.TotalLines
+	}
+	if total > 0 {
+		report.TotalCoverage = roundPercent(covered, total)
+	}
+
+	selected := make(map[string]bool)
+	if allFiles {
+		for file := range coverage {
+			selected[file] = true
+		}
+	} else {
+		for _, file := range modified {
+			file = filepath.ToSlash(filepath.Clean(file))
+			if _, ok := coverage[file]; ok {
+				selected[file] = true
+			} else if isCoverageCandidate(file) {
+				report.FilesNotCovered = append(report.FilesNotCovered, file)
+			}
+		}
+	}
+
+	for file := range selected {
+		fc := coverage[file]
+		fc.CoveragePercent = roundPercent(fc.CoveredLines, fc.TotalLines)
+		fc.UncoveredRanges = compressLineRanges(fc.uncoveredLineList)
+		report.Files = append(report.Files, *fc)
+	}
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].CoveragePercent < report.Files[j].CoveragePercent
+	})
+	sort.Strings(report.FilesNotCovered)
+
+	gaps := 0
+	for _, fc := range report.Files {
+		if len(fc.UncoveredRanges) > 0 {
+			gaps++
+		}
+	}
+	report.Summary = fmt.Sprintf("Total coverage %.1f%%; %d of %d reported file(s) have uncovered lines", report.TotalCoverage, gaps, len(report.Files))
+	if len(report.FilesNotCovered) > 0 {
+		report.Summary += fmt.Sprintf("; %d modified file(s) are not exercised by any test", len(report.FilesNotCovered))
+	}
+
+	return report
+}
+
+// parseGoCoverProfile parses a `go test -coverprofile` file. Paths in the profile
+// are import paths; they are made workspace-relative using the module path.
+func parseGoCoverProfile(path, modulePath string) (map[string]*FileCoverage, error) {
+	file, err := os.Open(path) // #nosec G304 - profile path is generated by this tool
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Blocks may be listed more than once (one per test binary); a line is
+	// covered if any block spanning it was executed
+	lineHits := make(map[string]map[int]bool)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+
+		// Format: name.go:line.column,line.column numberOfStatements count
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 {
+			continue
+		}
+		name := line[:colon]
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) != 3 {
+			continue
+		}
+
+		span := strings.SplitN(fields[0], ",", 2)
+		if len(span) != 2 {
+			continue
+		}
+		startLine, err1 := strconv.Atoi(strings.SplitN(span[0], ".", 2)[0])
+		endLine, err2 := strconv.Atoi(strings.SplitN(span[1], ".", 2)[0])
+		count, err3 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+
+		if modulePath != "" {
+			name = strings.TrimPrefix(strings.TrimPrefix(name, modulePath), "/")
+		}
+
+		hits, ok := lineHits[name]
+		if !ok {
+			hits = make(map[int]bool)
+			lineHits[name] = hits
+		}
+		for l := startLine; l <= endLine; l++ {
+			hits[l] = hits[l] || count > 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return coverageFromLineHits(lineHits), nil
+}
+
+// parseLCOVProfile parses an LCOV tracefile (SF/DA/end_of_record records)
+func parseLCOVProfile(path, workspaceRoot string) (map[string]*FileCoverage, error) {
+	file, err := os.Open(path) // #nosec G304 - profile path comes from tool configuration
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lineHits := make(map[string]map[int]bool)
+	var current string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			current = strings.TrimPrefix(line, "SF:")
+			if rel, err := filepath.Rel(workspaceRoot, current); err == nil && filepath.IsAbs(current) {
+				current = rel
+			}
+			current = filepath.ToSlash(current)
+			if _, ok := lineHits[current]; !ok {
+				lineHits[current] = make(map[int]bool)
+			}
+		case strings.HasPrefix(line, "DA:") && current != "":
+			parts := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(parts) < 2 {
+				continue
+			}
+			lineNum, err1 := strconv.Atoi(parts[0])
+			count, err2 := strconv.Atoi(parts[1])
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			lineHits[current][lineNum] = lineHits[current][lineNum] || count > 0
+		case line == "end_of_record":
+			current = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return coverageFromLineHits(lineHits), nil
+}
+
+func coverageFromLineHits(lineHits map[string]map[int]bool) map[string]*FileCoverage {
+	coverage := make(map[string]*FileCoverage, len(lineHits))
+	for name, hits := range lineHits {
+		fc := &FileCoverage{File: name, TotalLines: len(hits)}
+		for line, hit := range hits {
+			if hit {
+				fc.CoveredLines++
+			} else {
+				fc.uncoveredLineList = append(fc.uncoveredLineList, line)
+			}
+		}
+		sort.Ints(fc.uncoveredLineList)
+		coverage[name] = fc
+	}
+	return coverage
+}
+
+// compressLineRanges turns sorted line numbers into "a-b" ranges
+func compressLineRanges(lines []int) []string {
+	var ranges []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(lines[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return ranges
+}
+
+// goModulePath reads the module path from the workspace's go.mod, if any
+func goModulePath(workspaceRoot string) string {
+	data, err := os.ReadFile(filepath.Join(workspaceRoot, "go.mod")) // #nosec G304 - fixed file name inside workspace
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`)
+		}
+	}
+	return ""
+}
+
+// isCoverageCandidate reports whether a modified file is source code that
+// should appear in a coverage profile (tests and docs are excluded)
+func isCoverageCandidate(file string) bool {
+	base := filepath.Base(file)
+	if strings.HasSuffix(base, "_test.go") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") || strings.HasPrefix(base, "test_") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".go", ".py", ".js", ".jsx", ".ts", ".tsx", ".rs":
+		return true
+	}
+	return false
+}
+
+func roundPercent(covered, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(float64(covered)/float64(total)*1000+0.5)) / 10
+}
+
+func truncateOutput(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+	return output[len(output)-limit:]
+}
@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGoCoverProfile(t *testing.T) {
+	dir := t.TempDir()
+	profile := filepath.Join(dir, "cover.out")
+	content := `mode: set
+example.com/calc/calc.go:3.24,5.2 1 1
+example.com/calc/calc.go:7.24,9.2 1 0
+example.com/calc/calc.go:11.30,12.16 1 0
+example.com/calc/calc.go:12.16,14.3 1 0
+example.com/calc/util.go:3.20,4.2 1 1
+`
+	if err := os.WriteFile(profile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	coverage, err := parseGoCoverProfile(profile, "example.com/calc")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	calc, ok := coverage["calc.go"]
+	if !ok {
+		t.Fatalf("Expected calc.go in coverage, got %v", coverage)
+	}
+	if calc.TotalLines != 10 || calc.CoveredLines != 3 {
+		t.Errorf("Unexpected calc.go counts: covered=%d total=%d", calc.CoveredLines, calc.TotalLines)
+	}
+
+	report := buildCoverageReport(coverage, []string{"calc.go", "new.go", "calc_test.go", "README.md"}, false)
+	if len(report.Files) != 1 || report.Files[0].File != "calc.go" {
+		t.Fatalf("Expected only calc.go in report, got %+v", report.Files)
+	}
+	if want := []string{"7-9", "11-14"}; !reflect.DeepEqual(report.Files[0].UncoveredRanges, want) {
+		t.Errorf("Expected uncovered ranges %v, got %v", want, report.Files[0].UncoveredRanges)
+	}
+	if !reflect.DeepEqual(report.FilesNotCovered, []string{"new.go"}) {
+		t.Errorf("Expected new.go to be reported as not covered, got %v", report.FilesNotCovered)
+	}
+}
+
+func TestParseLCOVProfile(t *testing.T) {
+	dir := t.TempDir()
+	profile := filepath.Join(dir, "lcov.info")
+	content := "TN:\nSF:" + filepath.Join(dir, "src", "sum.js") + "\nDA:1,4\nDA:2,4\nDA:5,0\nDA:6,0\nDA:9,0\nend_of_record\n"
+	if err := os.WriteFile(profile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	coverage, err := parseLCOVProfile(profile, dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	report := buildCoverageReport(coverage, nil, true)
+	if len(report.Files) != 1 || report.Files[0].File != "src/sum.js" {
+		t.Fatalf("Expected src/sum.js in report, got %+v", report.Files)
+	}
+	if report.Files[0].CoveragePercent != 40 {
+		t.Errorf("Expected 40%% coverage, got %.1f", report.Files[0].CoveragePercent)
+	}
+	if want := []string{"5-6", "9"}; !reflect.DeepEqual(report.Files[0].UncoveredRanges, want) {
+		t.Errorf("Expected uncovered ranges %v, got %v", want, report.Files[0].UncoveredRanges)
+	}
+}
+
+func TestCoverageToolBuildCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		testCommand string
+		format      string
+		targetPath  string
+		want        []string
+	}{
+		{"default", "", CoverageFormatGo, "", []string{"go", "test", "-coverprofile=/p/cover.out", "./..."}},
+		{"default with target", "", CoverageFormatGo, "./internal/calc", []string{"go", "test", "-coverprofile=/p/cover.out", "./internal/calc"}},
+		{"configured go test", "go test -race ./...", CoverageFormatGo, "", []string{"go", "test", "-coverprofile=/p/cover.out", "-race", "./..."}},
+		{"configured go test with target", "go test -race ./...", CoverageFormatGo, "./internal/calc", []string{"go", "test", "-coverprofile=/p/cover.out", "-race", "./internal/calc"}},
+		{"jest", "npx jest", CoverageFormatLCOV, "src/sum", []string{"npx", "jest", "--coverage", "--coverageReporters=lcov", "--coverageDirectory={dir}", "src/sum"}},
+		{"vitest", "vitest run", CoverageFormatLCOV, "", []string{"vitest", "run", "--coverage.enabled", "--coverage.reporter=lcov", "--coverage.reportsDirectory={dir}"}},
+		{"pytest", "python -m pytest -q", CoverageFormatLCOV, "tests/unit", []string{"python", "-m", "pytest", "-q", "--cov", "--cov-report=lcov:/p/lcov.info", "tests/unit"}},
+		{"cargo llvm-cov", "cargo llvm-cov", CoverageFormatLCOV, "", []string{"cargo", "llvm-cov", "--lcov", "--output-path", "/p/lcov.info"}},
+		{"coverage already configured", "pytest --cov=src --cov-report=lcov", CoverageFormatLCOV, "", []string{"pytest", "--cov=src", "--cov-report=lcov"}},
+		{"unknown runner", "make test", CoverageFormatLCOV, "pkg", []string{"make", "test", "pkg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profilePath := "/p/lcov.info"
+			if tt.format == CoverageFormatGo {
+				profilePath = "/p/cover.out"
+			}
+			tool := NewCoverageToolWithConfig(t.TempDir(), CoverageToolConfig{TestCommand: tt.testCommand})
+			command, args := tool.buildCommand(tt.format, profilePath, tt.targetPath)
+			got := append([]string{command}, args...)
+			for i := range tt.want {
+				tt.want[i] = strings.ReplaceAll(tt.want[i], "{dir}", filepath.Dir(profilePath))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoverageToolIgnoresStaleReports(t *testing.T) {
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("true not available")
+	}
+	workspace := t.TempDir()
+	stale := filepath.Join(workspace, "coverage", "lcov.info")
+	if err := os.MkdirAll(filepath.Dir(stale), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("SF:src/sum.js\nDA:1,1\nend_of_record\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The command passes without writing a report
+	tool := NewCoverageToolWithConfig(workspace, CoverageToolConfig{TestCommand: "true", Format: CoverageFormatLCOV})
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{"all_files": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || !strings.Contains(result.Error, "did not write a coverage report") {
+		t.Errorf("expected the missing report to be an error, got %+v", result)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected the stale report to be removed, got %v", err)
+	}
+}
+
+func TestCoverageToolChangedFilesInSubdirectory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	repo := t.TempDir()
+	workspace := filepath.Join(repo, "services", "api")
+	for _, file := range []string{filepath.Join(workspace, "handler.go"), filepath.Join(repo, "web", "app.js")} {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if output, err := exec.Command("git", "-C", repo, "init").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, output)
+	}
+
+	files := NewCoverageTool(workspace).changedFiles(context.Background())
+	if !reflect.DeepEqual(files, []string{"handler.go"}) {
+		t.Errorf("expected paths relative to the workspace, got %v", files)
+	}
+}
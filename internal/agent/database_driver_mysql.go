@@ -0,0 +1,7 @@
+//go:build !nomysql
+
+package agent
+
+// The MySQL driver of the database tools; build with -tags nomysql to leave
+// it out
+import _ "github.com/go-sql-driver/mysql"
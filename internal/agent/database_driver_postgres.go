@@ -0,0 +1,7 @@
+//go:build !nopostgres
+
+package agent
+
+// The Postgres driver of the database tools; build with -tags nopostgres to
+// leave it out
+import _ "github.com/lib/pq"
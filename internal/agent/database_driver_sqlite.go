@@ -0,0 +1,7 @@
+//go:build !nosqlite
+
+package agent
+
+// The SQLite driver of the database tools; build with -tags nosqlite to leave
+// it out
+import _ "modernc.org/sqlite"
@@ -0,0 +1,672 @@
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Database drivers the database tools connect with
+const (
+	DatabasePostgres = "postgres"
+	DatabaseMySQL    = "mysql"
+	DatabaseSQLite   = "sqlite"
+)
+
+// Defaults of DatabaseToolConfig
+const (
+	DefaultDatabaseMaxRows        = 100
+	DefaultDatabaseMaxResultBytes = 64 * 1024
+	DefaultDatabaseQueryTimeout   = 30 * time.Second
+)
+
+// maxSchemaTables is how many tables db_schema describes in one call
+const maxSchemaTables = 200
+
+// maskedValue replaces the values of sensitive columns
+const maskedValue = "***"
+
+// DefaultMaskedColumns are the column name patterns whose values db_query
+// masks; patterns are matched case-insensitively with path.Match
+var DefaultMaskedColumns = []string{
+	"*password*", "*passwd*", "*secret*", "*token*", "*api_key*", "*apikey*",
+	"*private_key*", "*ssn*", "*credit_card*", "*card_number*", "*cvv*",
+}
+
+// readOnlyStatements are the statements db_query runs unless writes are
+// allowed
+var readOnlyStatements = map[string]bool{
+	"SELECT": true, "WITH": true, "SHOW": true, "EXPLAIN": true,
+	"DESCRIBE": true, "DESC": true, "VALUES": true, "TABLE": true,
+}
+
+// writeKeywords make a statement that starts read-only change data, e.g. a
+// data-modifying WITH or SELECT ... INTO
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true,
+	"INTO": true, "CREATE": true, "ALTER": true, "DROP": true,
+	"TRUNCATE": true, "RENAME": true, "GRANT": true, "REVOKE": true, "COPY": true,
+	"CALL": true, "EXEC": true, "EXECUTE": true, "DO": true, "LOCK": true,
+	"VACUUM": true, "ATTACH": true, "DETACH": true, "PRAGMA": true, "SET": true,
+}
+
+// DatabaseToolConfig configures the database tools
+type DatabaseToolConfig struct {
+	Driver         string        // DatabasePostgres, DatabaseMySQL or DatabaseSQLite
+	DSN            string        // Connection string; ${VAR} references are expanded from the environment
+	MaxRows        int           // Rows returned per query; 0 uses DefaultDatabaseMaxRows
+	MaxResultBytes int           // Size of the rows returned per query; 0 uses DefaultDatabaseMaxResultBytes
+	AllowWrites    bool          // Run statements other than queries, and commit them
+	MaskedColumns  []string      // Column patterns whose values are masked; DefaultMaskedColumns when nil
+	QueryTimeout   time.Duration // 0 uses DefaultDatabaseQueryTimeout
+}
+
+// Enabled reports whether a database is configured
+func (c DatabaseToolConfig) Enabled() bool {
+	return c.Driver != "" && c.DSN != ""
+}
+
+// Database is a lazily opened connection shared by the database tools
+type Database struct {
+	config DatabaseToolConfig
+	once   sync.Once
+	db     *sql.DB
+	err    error
+}
+
+// NewDatabase creates a database from config; it is connected on first use
+func NewDatabase(config DatabaseToolConfig) *Database {
+	if config.MaxRows <= 0 {
+		config.MaxRows = DefaultDatabaseMaxRows
+	}
+	if config.MaxResultBytes <= 0 {
+		config.MaxResultBytes = DefaultDatabaseMaxResultBytes
+	}
+	if config.QueryTimeout <= 0 {
+		config.QueryTimeout = DefaultDatabaseQueryTimeout
+	}
+	if config.MaskedColumns == nil {
+		config.MaskedColumns = DefaultMaskedColumns
+	}
+	return &Database{config: config}
+}
+
+// NewDatabaseTools creates db_query and db_schema, sharing one connection
+func NewDatabaseTools(config DatabaseToolConfig) []Tool {
+	database := NewDatabase(config)
+	return []Tool{NewDatabaseQueryTool(database), NewDatabaseSchemaTool(database)}
+}
+
+// open connects to the database the first time it is called
+func (d *Database) open() (*sql.DB, error) {
+	d.once.Do(func() {
+		switch d.config.Driver {
+		case DatabasePostgres, DatabaseMySQL, DatabaseSQLite:
+		default:
+			d.err = fmt.Errorf("unsupported database driver %q (use %s, %s or %s)", d.config.Driver, DatabasePostgres, DatabaseMySQL, DatabaseSQLite)
+			return
+		}
+		// Each driver is left out by a build tag of its own
+		if !slices.Contains(sql.Drivers(), d.config.Driver) {
+			d.err = fmt.Errorf("this build of CGE has no %s driver; it was built with -tags no%s", d.config.Driver, d.config.Driver)
+			return
+		}
+		d.db, d.err = sql.Open(d.config.Driver, os.ExpandEnv(d.config.DSN))
+		if d.err == nil {
+			d.db.SetMaxOpenConns(2)
+		}
+	})
+	return d.db, d.err
+}
+
+// Close closes the connection, if it was opened
+func (d *Database) Close() error {
+	if d.db != nil {
+		return d.db.Close()
+	}
+	return nil
+}
+
+// inTransaction runs fn in a transaction that is only committed when writes
+// are allowed. Otherwise the transaction is read-only and rolled back, so a
+// statement that got past ValidateReadOnlyQuery still cannot change data.
+func (d *Database) inTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	db, err := d.open()
+	if err != nil {
+		return NewStandardizedError(ErrorCodeUnsupportedOperation, err.Error(), "Check tools.database in codex.toml")
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.config.QueryTimeout)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: !d.config.AllowWrites})
+	if err != nil {
+		return NewStandardizedError(ErrorCodeCommandFailed, fmt.Sprintf("cannot connect to the database: %v", err), "Check tools.database in codex.toml")
+	}
+	defer func() { _ = tx.Rollback() }()
+	if d.config.Driver == DatabaseSQLite && !d.config.AllowWrites {
+		// SQLite has no read-only transactions; query_only stays set on the
+		// connection, which only ever runs read-only statements
+		if _, err := tx.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+			return err
+		}
+	}
+	if err := fn(tx); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return NewStandardizedError(ErrorCodeTimeout, fmt.Sprintf("query timed out after %s", d.config.QueryTimeout), "Narrow the query, e.g. with WHERE or LIMIT")
+		}
+		return err
+	}
+	if d.config.AllowWrites {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// masked reports whether the values of column are masked
+func (d *Database) masked(column string) bool {
+	column = strings.ToLower(column)
+	for _, pattern := range d.config.MaskedColumns {
+		if ok, _ := path.Match(strings.ToLower(pattern), column); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selectListEnd are the words ending the select list of a query
+var selectListEnd = map[string]bool{
+	"FROM": true, "INTO": true, "WHERE": true, "GROUP": true, "HAVING": true, "WINDOW": true,
+	"ORDER": true, "LIMIT": true, "OFFSET": true, "FETCH": true, "FOR": true,
+	"UNION": true, "INTERSECT": true, "EXCEPT": true,
+}
+
+// nonAliasWords are keywords that a parenthesized list follows, so they are
+// not taken for an alias with a column list
+var nonAliasWords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IN": true, "IS": true, "ANY": true, "ALL": true,
+	"SOME": true, "EXISTS": true, "VALUES": true, "OVER": true, "USING": true, "ON": true,
+	"BY": true, "LIKE": true, "ILIKE": true, "BETWEEN": true, "WHEN": true, "THEN": true,
+	"ELSE": true, "AS": true, "SELECT": true, "FROM": true, "WHERE": true, "JOIN": true,
+	"LATERAL": true, "FILTER": true, "WITHIN": true, "UNION": true, "INTERSECT": true,
+	"EXCEPT": true, "ROW": true, "ARRAY": true, "INTO": true, "DISTINCT": true,
+}
+
+// checkMaskedColumns refuses queries that could return the values of masked
+// columns under other names. Values are masked by the names of the result
+// columns, so a masked column may only be selected as it is, by the select
+// list of the query itself; in an alias, expression, condition or subquery
+// it could come out unmasked. Column lists renaming the columns of a table
+// or subquery are refused for the same reason.
+func (d *Database) checkMaskedColumns(query string) error {
+	if len(d.config.MaskedColumns) == 0 {
+		return nil
+	}
+	tokens := sqlTokens(query, d.config.Driver == DatabaseMySQL)
+
+	// The select list of the query spans tokens[1:listEnd]
+	listEnd := 0
+	if len(tokens) > 0 && isSQLWord(tokens[0], "SELECT") {
+		listEnd = len(tokens)
+		depth := 0
+		for i := 1; i < len(tokens) && listEnd == len(tokens); i++ {
+			switch t := tokens[i]; {
+			case isSQLSymbol(t, "("):
+				depth++
+			case isSQLSymbol(t, ")"):
+				depth--
+			case depth == 0 && (isSQLSymbol(t, ";") || t.kind == sqlWord && selectListEnd[t.text]):
+				listEnd = i
+			}
+		}
+	}
+
+	depth := 0
+	for i, t := range tokens {
+		switch {
+		case isSQLSymbol(t, "("):
+			depth++
+		case isSQLSymbol(t, ")"):
+			depth--
+		case isSQLName(t) && d.masked(t.text):
+			if depth == 0 && i < listEnd && bareSelectItem(tokens, i, listEnd) {
+				continue
+			}
+			return fmt.Errorf("query uses the masked column %s other than by selecting it as it is, which would return its values unmasked", strings.ToLower(t.text))
+		}
+	}
+
+	if name, ok := columnAliasList(tokens); ok {
+		return fmt.Errorf("query renames the columns of %s with a column list, which would return masked values unmasked", strings.ToLower(name))
+	}
+	return nil
+}
+
+// bareSelectItem reports whether the name at tokens[i], possibly qualified,
+// is a whole item of the select list ending at tokens[end]
+func bareSelectItem(tokens []sqlToken, i, end int) bool {
+	start := i
+	for start >= 2 && isSQLSymbol(tokens[start-1], ".") && isSQLName(tokens[start-2]) {
+		start -= 2
+	}
+	if start < 1 {
+		return false
+	}
+	prev := tokens[start-1]
+	if !isSQLSymbol(prev, ",") && !isSQLWord(prev, "SELECT") && !isSQLWord(prev, "DISTINCT") && !isSQLWord(prev, "ALL") {
+		return false
+	}
+	return i+1 == end || isSQLSymbol(tokens[i+1], ",")
+}
+
+// columnAliasList finds an alias with a column list, as in
+// "FROM users u(a, b)", "(SELECT ...) AS t(a, b)" or "WITH t(a, b) AS (...)",
+// and returns the alias
+func columnAliasList(tokens []sqlToken) (string, bool) {
+	for i := 1; i+1 < len(tokens); i++ {
+		name := tokens[i]
+		if !isSQLName(name) || name.kind == sqlWord && nonAliasWords[name.text] || !isSQLSymbol(tokens[i+1], "(") {
+			continue
+		}
+		// The list holds names only, unlike the arguments of a function
+		end := i + 2
+		for end < len(tokens) && (isSQLName(tokens[end]) || isSQLSymbol(tokens[end], ",")) {
+			end++
+		}
+		if end == i+2 || end == len(tokens) || !isSQLSymbol(tokens[end], ")") {
+			continue
+		}
+
+		prev := tokens[i-1]
+		switch {
+		case isSQLWord(prev, "AS"), isSQLSymbol(prev, ")"):
+			return name.text, true
+		case isSQLName(prev) && i >= 2 && (isSQLWord(tokens[i-2], "FROM") || isSQLWord(tokens[i-2], "JOIN") ||
+			isSQLSymbol(tokens[i-2], ",") || isSQLSymbol(tokens[i-2], ".")):
+			return name.text, true
+		case end+2 < len(tokens) && isSQLWord(tokens[end+1], "AS") && isSQLSymbol(tokens[end+2], "("):
+			return name.text, true
+		}
+	}
+	return "", false
+}
+
+// isSQLName reports whether t is a word or quoted identifier
+func isSQLName(t sqlToken) bool {
+	return t.kind == sqlWord || t.kind == sqlIdentifier
+}
+
+// isSQLWord reports whether t is the keyword word
+func isSQLWord(t sqlToken, word string) bool {
+	return t.kind == sqlWord && t.text == word
+}
+
+// isSQLSymbol reports whether t is the character symbol
+func isSQLSymbol(t sqlToken, symbol string) bool {
+	return t.kind == sqlSymbol && t.text == symbol
+}
+
+// DatabaseQueryParams are the parameters of db_query
+type DatabaseQueryParams struct {
+	Query string        `json:"query" description:"SQL to run; a single SELECT, WITH, SHOW or EXPLAIN statement unless writes are allowed" jsonschema:"required,minLength=1"`
+	Args  []interface{} `json:"args" description:"Values of the query's placeholders ($1 for Postgres, ? for MySQL and SQLite)"`
+}
+
+// NewDatabaseQueryTool creates the db_query tool
+func NewDatabaseQueryTool(database *Database) Tool {
+	statement := "a read-only SQL query"
+	if database.config.AllowWrites {
+		statement = "a SQL statement"
+	}
+	return NewTypedTool("db_query", fmt.Sprintf(`Runs %s against the project's %s database and returns the columns and rows. Results are limited to %d rows, and values of sensitive columns such as passwords and tokens are masked: such columns may be selected as they are, but not renamed or used in expressions or conditions. Use db_schema first to learn the tables.
+
+USAGE EXAMPLES:
+- db_query({"query": "SELECT id, email, created_at FROM users ORDER BY id DESC LIMIT 10"})
+- db_query({"query": "SELECT count(*) FROM orders WHERE status = $1", "args": ["pending"]})`, statement, database.config.Driver, database.config.MaxRows),
+		func(ctx context.Context, params DatabaseQueryParams) (interface{}, error) {
+			return database.query(ctx, params)
+		})
+}
+
+// query runs a db_query call
+func (d *Database) query(ctx context.Context, params DatabaseQueryParams) (interface{}, error) {
+	if !d.config.AllowWrites {
+		if err := ValidateReadOnlyQuery(d.config.Driver, params.Query); err != nil {
+			return nil, NewStandardizedError(ErrorCodeInvalidParameters, err.Error(),
+				"Only single read-only statements are allowed; write a migration for schema or data changes")
+		}
+	}
+	if err := d.checkMaskedColumns(params.Query); err != nil {
+		return nil, NewStandardizedError(ErrorCodeInvalidParameters, err.Error(),
+			"Select masked columns as they are, without aliases, expressions or conditions, or leave them out; their values are masked either way")
+	}
+
+	data := map[string]interface{}{}
+	err := d.inTransaction(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, params.Query, params.Args...)
+		if err != nil {
+			return NewStandardizedError(ErrorCodeCommandFailed, fmt.Sprintf("query failed: %v", err), "Check the query against db_schema")
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		var masked []string
+		for _, column := range columns {
+			if d.masked(column) {
+				masked = append(masked, column)
+			}
+		}
+
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		result := [][]interface{}{}
+		size, truncated := 0, false
+		for rows.Next() {
+			if len(result) == d.config.MaxRows {
+				truncated = true
+				break
+			}
+			if err := rows.Scan(pointers...); err != nil {
+				return err
+			}
+			row := make([]interface{}, len(columns))
+			for i, value := range values {
+				if value != nil && d.masked(columns[i]) {
+					row[i] = maskedValue
+				} else {
+					row[i] = databaseValue(value)
+				}
+			}
+			encoded, _ := json.Marshal(row)
+			if size+len(encoded) > d.config.MaxResultBytes {
+				truncated = true
+				break
+			}
+			size += len(encoded)
+			result = append(result, row)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		data["columns"] = columns
+		data["rows"] = result
+		data["row_count"] = len(result)
+		data["truncated"] = truncated
+		if len(masked) > 0 {
+			data["masked_columns"] = masked
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// databaseValue converts a scanned value to one that reads well as JSON
+func databaseValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return fmt.Sprintf("<%d bytes>", len(v))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return value
+}
+
+// ValidateReadOnlyQuery checks that query is a single statement that reads
+// data from a database of driver. Comments, string literals and quoted
+// identifiers are ignored, so a column named "update" is fine while a
+// data-modifying WITH is not.
+func ValidateReadOnlyQuery(driver, query string) error {
+	words, statements := sqlWords(query, driver == DatabaseMySQL)
+	if len(words) == 0 {
+		return fmt.Errorf("query is empty")
+	}
+	if statements > 1 {
+		return fmt.Errorf("query has %d statements; run one at a time", statements)
+	}
+	if !readOnlyStatements[words[0]] {
+		return fmt.Errorf("%s statements are not allowed; only queries are", words[0])
+	}
+	for _, word := range words[1:] {
+		if writeKeywords[word] {
+			return fmt.Errorf("query contains %s, which may change data", word)
+		}
+	}
+	return nil
+}
+
+// sqlTokenKind classifies the tokens of a SQL statement
+type sqlTokenKind int
+
+const (
+	sqlWord       sqlTokenKind = iota // Keyword or unquoted identifier
+	sqlIdentifier                     // Quoted identifier
+	sqlLiteral                        // String literal
+	sqlSymbol                         // Any other character
+)
+
+// sqlToken is a token of a SQL statement; words are upper-cased and quoted
+// identifiers unquoted
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// sqlTokens splits query into tokens, leaving out comments and white space.
+// MySQL also has # comments and backslash escapes in strings; elsewhere #
+// and backslashes are ordinary characters.
+func sqlTokens(query string, mysql bool) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case strings.HasPrefix(query[i:], "--") || mysql && c == '#':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 1
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '\'' || c == '"' || c == '`':
+			var text strings.Builder
+			i++
+			for i < len(query) {
+				if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c {
+						text.WriteByte(c) // Doubled quote
+						i += 2
+						continue
+					}
+					break
+				}
+				if query[i] == '\\' && mysql && c != '`' {
+					i++
+				}
+				if i < len(query) {
+					text.WriteByte(query[i])
+				}
+				i++
+			}
+			i++
+			kind := sqlIdentifier
+			if c == '\'' {
+				kind = sqlLiteral
+			}
+			tokens = append(tokens, sqlToken{kind: kind, text: text.String()})
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			start := i
+			for i < len(query) && (query[i] == '_' || query[i] == '$' || query[i] >= '0' && query[i] <= '9' ||
+				query[i] >= 'A' && query[i] <= 'Z' || query[i] >= 'a' && query[i] <= 'z') {
+				i++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlWord, text: strings.ToUpper(query[start:i])})
+		default:
+			if c > ' ' {
+				tokens = append(tokens, sqlToken{kind: sqlSymbol, text: string(c)})
+			}
+			i++
+		}
+	}
+	return tokens
+}
+
+// sqlWords returns the upper-cased keywords and identifiers of query, outside
+// comments, literals and quoted identifiers, and the number of statements
+func sqlWords(query string, mysql bool) ([]string, int) {
+	var words []string
+	statements, pending := 0, false
+	for _, token := range sqlTokens(query, mysql) {
+		switch {
+		case token.kind == sqlSymbol && token.text == ";":
+			statements += boolToInt(pending)
+			pending = false
+		case token.kind == sqlWord:
+			words = append(words, token.text)
+			pending = true
+		default:
+			pending = true
+		}
+	}
+	return words, statements + boolToInt(pending)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// DatabaseSchemaParams are the parameters of db_schema
+type DatabaseSchemaParams struct {
+	Table string `json:"table" description:"Only describe this table; all tables when empty"`
+}
+
+// DatabaseColumn describes a column of a table
+type DatabaseColumn struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Nullable   bool    `json:"nullable"`
+	Default    *string `json:"default,omitempty"`
+	PrimaryKey bool    `json:"primary_key,omitempty"`
+}
+
+// DatabaseTable describes a table or view
+type DatabaseTable struct {
+	Name    string           `json:"name"` // Qualified with its schema when not the default one
+	Columns []DatabaseColumn `json:"columns"`
+}
+
+// NewDatabaseSchemaTool creates the db_schema tool
+func NewDatabaseSchemaTool(database *Database) Tool {
+	return NewTypedTool("db_schema", fmt.Sprintf(`Describes the tables of the project's %s database: their columns, types, nullability, defaults and primary keys. Use it before writing queries or migrations.
+
+USAGE EXAMPLES:
+- db_schema({})
+- db_schema({"table": "users"})`, database.config.Driver),
+		func(ctx context.Context, params DatabaseSchemaParams) (interface{}, error) {
+			return database.schema(ctx, params)
+		})
+}
+
+// schemaQueries list the columns of every table, as table, column, type,
+// nullable, default and primary key
+var schemaQueries = map[string]string{
+	DatabasePostgres: `SELECT CASE WHEN c.table_schema = current_schema() THEN c.table_name ELSE c.table_schema || '.' || c.table_name END,
+		c.column_name, c.data_type, c.is_nullable = 'YES', c.column_default,
+		EXISTS (SELECT 1 FROM information_schema.table_constraints t
+			JOIN information_schema.key_column_usage k ON k.constraint_name = t.constraint_name AND k.table_schema = t.table_schema
+			WHERE t.constraint_type = 'PRIMARY KEY' AND t.table_schema = c.table_schema AND t.table_name = c.table_name AND k.column_name = c.column_name)
+		FROM information_schema.columns c
+		WHERE c.table_schema NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY c.table_schema, c.table_name, c.ordinal_position`,
+	DatabaseMySQL: `SELECT table_name, column_name, column_type, is_nullable = 'YES', column_default, column_key = 'PRI'
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE()
+		ORDER BY table_name, ordinal_position`,
+	DatabaseSQLite: `SELECT m.name, p.name, p.type, p."notnull" = 0, p.dflt_value, p.pk > 0
+		FROM sqlite_master m JOIN pragma_table_info(m.name) p
+		WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite_%'
+		ORDER BY m.name, p.cid`,
+}
+
+// schema runs a db_schema call
+func (d *Database) schema(ctx context.Context, params DatabaseSchemaParams) (interface{}, error) {
+	tables := map[string]*DatabaseTable{}
+	err := d.inTransaction(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, schemaQueries[d.config.Driver])
+		if err != nil {
+			return NewStandardizedError(ErrorCodeCommandFailed, fmt.Sprintf("cannot read the schema: %v", err), "")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var table string
+			var column DatabaseColumn
+			var defaultValue sql.NullString
+			if err := rows.Scan(&table, &column.Name, &column.Type, &column.Nullable, &defaultValue, &column.PrimaryKey); err != nil {
+				return err
+			}
+			if params.Table != "" && !strings.EqualFold(table, params.Table) {
+				continue
+			}
+			if defaultValue.Valid {
+				column.Default = &defaultValue.String
+			}
+			if tables[table] == nil {
+				tables[table] = &DatabaseTable{Name: table}
+			}
+			tables[table].Columns = append(tables[table].Columns, column)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if params.Table != "" && len(tables) == 0 {
+		return nil, NewStandardizedError(ErrorCodeFileNotFound, fmt.Sprintf("table %s not found", params.Table), "Call db_schema without a table to list them")
+	}
+
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := map[string]interface{}{
+		"driver":      d.config.Driver,
+		"table_count": len(names),
+	}
+	if len(names) > maxSchemaTables {
+		names = names[:maxSchemaTables]
+		result["truncated"] = true
+	}
+	described := make([]DatabaseTable, 0, len(names))
+	for _, name := range names {
+		described = append(described, *tables[name])
+	}
+	result["tables"] = described
+	return result, nil
+}
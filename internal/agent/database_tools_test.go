@@ -0,0 +1,188 @@
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func setupDatabase(t *testing.T, config DatabaseToolConfig) (*Database, *sql.DB) {
+	t.Helper()
+	if !slices.Contains(sql.Drivers(), DatabaseSQLite) {
+		t.Skip("built without the SQLite driver")
+	}
+	path := filepath.Join(t.TempDir(), "app.db")
+	db, err := sql.Open(DatabaseSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL, password_hash TEXT, status TEXT DEFAULT 'active');
+		INSERT INTO users (email, password_hash) VALUES ('a@example.com', 'x1'), ('b@example.com', 'x2'), ('c@example.com', NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Driver, config.DSN = DatabaseSQLite, path
+	database := NewDatabase(config)
+	t.Cleanup(func() { database.Close() })
+	return database, db
+}
+
+func TestDatabaseQuery(t *testing.T) {
+	database, _ := setupDatabase(t, DatabaseToolConfig{MaxRows: 2})
+	query := NewDatabaseQueryTool(database)
+
+	result, err := query.Execute(context.Background(), json.RawMessage(`{"query": "SELECT id, email, password_hash FROM users WHERE id > ? ORDER BY id", "args": [0]}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	data := result.Data.(map[string]interface{})
+	want := [][]interface{}{{int64(1), "a@example.com", maskedValue}, {int64(2), "b@example.com", maskedValue}}
+	if !reflect.DeepEqual(data["rows"], want) {
+		t.Errorf("unexpected rows: %v", data["rows"])
+	}
+	if data["truncated"] != true || !reflect.DeepEqual(data["masked_columns"], []string{"password_hash"}) {
+		t.Errorf("expected truncated rows with a masked column, got %v", data)
+	}
+}
+
+func TestDatabaseQueryKeepsMaskedColumnsMasked(t *testing.T) {
+	database, _ := setupDatabase(t, DatabaseToolConfig{})
+	query := NewDatabaseQueryTool(database)
+
+	for _, statement := range []string{
+		"SELECT password_hash AS p FROM users",
+		"SELECT id, password_hash p FROM users",
+		"SELECT upper(password_hash) FROM users",
+		`SELECT "password_hash" || '' FROM users`,
+		"SELECT id FROM users WHERE password_hash = 'x1'",
+		"SELECT p FROM (SELECT password_hash AS p FROM users)",
+		"SELECT 1, 'x' UNION SELECT id, password_hash FROM users",
+		"WITH t(a, b) AS (SELECT id, password_hash FROM users) SELECT b FROM t",
+		"SELECT d FROM (SELECT * FROM users) AS t(a, b, c, d)",
+		"SELECT d FROM users u(a, b, c, d)",
+	} {
+		args, _ := json.Marshal(map[string]string{"query": statement})
+		result, err := query.Execute(context.Background(), args)
+		if err != nil || result.Success || result.StandardizedError.Code != ErrorCodeInvalidParameters {
+			t.Errorf("expected %q to be rejected, got %v %+v", statement, err, result)
+		}
+	}
+
+	for _, statement := range []string{
+		"SELECT users.password_hash, email FROM users WHERE id IN (1, 2)",
+		"SELECT * FROM users",
+		"SELECT count(*), coalesce(status, email) FROM users GROUP BY status, email",
+		"WITH active AS (SELECT id FROM users) SELECT * FROM active",
+	} {
+		args, _ := json.Marshal(map[string]string{"query": statement})
+		result, err := query.Execute(context.Background(), args)
+		if err != nil || !result.Success {
+			t.Errorf("expected %q to run, got %v %+v", statement, err, result)
+		}
+	}
+}
+
+func TestDatabaseQueryIsReadOnly(t *testing.T) {
+	database, db := setupDatabase(t, DatabaseToolConfig{})
+	query := NewDatabaseQueryTool(database)
+
+	for _, statement := range []string{
+		"DELETE FROM users",
+		"SELECT 1; DELETE FROM users",
+		"WITH gone AS (DELETE FROM users RETURNING id) SELECT * FROM gone",
+		"SELECT * INTO backup FROM users",
+	} {
+		args, _ := json.Marshal(map[string]string{"query": statement})
+		result, err := query.Execute(context.Background(), args)
+		if err != nil || result.Success || result.StandardizedError.Code != ErrorCodeInvalidParameters {
+			t.Errorf("expected %q to be rejected, got %v %+v", statement, err, result)
+		}
+	}
+
+	// Statements that get past the validation still cannot write
+	err := database.inTransaction(context.Background(), func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM users")
+		return err
+	})
+	if err == nil {
+		t.Error("expected the transaction to be read-only")
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM users").Scan(&count); err != nil || count != 3 {
+		t.Errorf("expected the users to be left alone, got %d %v", count, err)
+	}
+}
+
+func TestValidateReadOnlyQuery(t *testing.T) {
+	allowed := []string{
+		`SELECT "update", 'DELETE FROM users' FROM events -- DROP TABLE events`,
+		"/* INSERT */ select count(*) from orders;",
+		"EXPLAIN SELECT * FROM users",
+	}
+	for _, query := range allowed {
+		if err := ValidateReadOnlyQuery(DatabasePostgres, query); err != nil {
+			t.Errorf("expected %q to be allowed, got %v", query, err)
+		}
+	}
+
+	rejected := map[string]string{
+		"":                                   "empty",
+		"UPDATE users SET email = ''":        "UPDATE statements",
+		"SELECT 1; SELECT 2":                 "2 statements",
+		`SELECT '\'; DELETE FROM users; --'`: "2 statements",
+		"SELECT * FROM users FOR UPDATE":     "UPDATE",
+		"SELECT 1 # ; DROP TABLE users":      "2 statements",
+		"select pg_sleep(1); vacuum":         "2 statements",
+	}
+	for query, message := range rejected {
+		if err := ValidateReadOnlyQuery(DatabasePostgres, query); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("expected %q to be rejected with %q, got %v", query, message, err)
+		}
+	}
+
+	// MySQL escapes quotes with backslashes and has # comments
+	if err := ValidateReadOnlyQuery(DatabaseMySQL, `SELECT 'it\'s; DROP' # ; DELETE`); err != nil {
+		t.Errorf("expected the MySQL query to be allowed, got %v", err)
+	}
+}
+
+func TestDatabaseSchema(t *testing.T) {
+	database, _ := setupDatabase(t, DatabaseToolConfig{})
+	schema := NewDatabaseSchemaTool(database)
+
+	result, err := schema.Execute(context.Background(), json.RawMessage(`{"table": "USERS"}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	tables := result.Data.(map[string]interface{})["tables"].([]DatabaseTable)
+	if len(tables) != 1 || len(tables[0].Columns) != 4 {
+		t.Fatalf("unexpected tables: %+v", tables)
+	}
+	id, email, status := tables[0].Columns[0], tables[0].Columns[1], tables[0].Columns[3]
+	if !id.PrimaryKey || email.Nullable || email.Type != "TEXT" || status.Default == nil || *status.Default != "'active'" {
+		t.Errorf("unexpected columns: %+v", tables[0].Columns)
+	}
+
+	result, err = schema.Execute(context.Background(), json.RawMessage(`{"table": "orders"}`))
+	if err != nil || result.Success {
+		t.Errorf("expected a missing table to fail, got %v %+v", err, result)
+	}
+}
+
+func TestDatabaseToolsReportConfigurationErrors(t *testing.T) {
+	tools := NewDatabaseTools(DatabaseToolConfig{Driver: "oracle", DSN: "x"})
+	for i, args := range []string{`{"query": "SELECT 1"}`, `{}`} {
+		result, err := tools[i].Execute(context.Background(), json.RawMessage(args))
+		if err != nil || result.Success || !strings.Contains(result.StandardizedError.Message, "oracle") {
+			t.Errorf("%s: expected the driver to be reported, got %v %+v", tools[i].Name(), err, result)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Tool sets a delegated subtask can be given
+const (
+	DelegateToolSetReadOnly = "read_only" // Read and search the workspace
+	DelegateToolSetEdit     = "edit"      // Read, search and modify files
+	DelegateToolSetFull     = "full"      // All tools, including shell and tests
+)
+
+// DelegateRequest describes a subtask handed to a child agent
+type DelegateRequest struct {
+	Task          string // What the child agent must do
+	Context       string // Facts from the parent conversation the child needs
+	ToolSet       string // One of the DelegateToolSet constants
+	MaxIterations int    // Requested iteration cap; 0 uses the budget default
+}
+
+// DelegateResult is the outcome of a delegated subtask. Only the summary is
+// returned to the parent agent, not the child's conversation.
+type DelegateResult struct {
+	Summary    string
+	Success    bool
+	Iterations int
+	ToolCalls  int
+	Error      string
+}
+
+// ErrDelegationBudgetExhausted is returned by a SubAgentSpawner once the
+// iterations it may hand to child agents are used up
+var ErrDelegationBudgetExhausted = errors.New("no iterations are left to delegate")
+
+// SubAgentSpawner runs a delegated subtask in a bounded child agent. It is
+// implemented by the orchestrator, which owns the agent loop.
+type SubAgentSpawner interface {
+	Spawn(ctx context.Context, req DelegateRequest) (*DelegateResult, error)
+}
+
+// DelegateTaskTool lets the agent hand a well-scoped subtask to a child agent
+// and receive only its final summary, keeping the parent's context small
+type DelegateTaskTool struct {
+	spawner SubAgentSpawner
+}
+
+// NewDelegateTaskTool creates a new delegate task tool
+func NewDelegateTaskTool(spawner SubAgentSpawner) *DelegateTaskTool {
+	return &DelegateTaskTool{spawner: spawner}
+}
+
+// Name returns the tool name
+func (t *DelegateTaskTool) Name() string {
+	return "delegate_task"
+}
+
+// Description returns the tool description
+func (t *DelegateTaskTool) Description() string {
+	return `Delegate a well-scoped subtask to a child agent and receive only its final summary.
+
+Use this tool for self-contained work that would otherwise fill the conversation with intermediate output, such as surveying how a function is used across the codebase or fixing an isolated test. The child agent does not see this conversation: put everything it needs in "task" and "context".
+
+The child agent runs with its own tool set and a capped number of iterations taken from this run's budget, and cannot delegate further.`
+}
+
+// Parameters returns the tool parameters schema
+func (t *DelegateTaskTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"task": {
+				"type": "string",
+				"description": "The subtask to perform, including what the summary should contain"
+			},
+			"context": {
+				"type": "string",
+				"description": "Facts from this conversation the child agent needs, such as file paths or decisions already made"
+			},
+			"tool_set": {
+				"type": "string",
+				"enum": ["read_only", "edit", "full"],
+				"description": "Tools available to the child agent (default: read_only)"
+			},
+			"max_iterations": {
+				"type": "integer",
+				"minimum": 1,
+				"description": "Iteration cap for the child agent; limited by this run's budget"
+			}
+		},
+		"required": ["task"]
+	}`)
+}
+
+// delegateTaskTimeout bounds a delegated subtask; the spawner gives the child
+// only a slice of the time left in the parent run
+const delegateTaskTimeout = 10 * time.Minute
+
+// ToolTimeout implements LongRunningTool, since a child agent makes several
+// LLM and tool calls
+func (t *DelegateTaskTool) ToolTimeout() time.Duration {
+	return delegateTaskTimeout
+}
+
+// Execute runs the subtask in a child agent
+func (t *DelegateTaskTool) Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error) {
+	var args struct {
+		Task          string `json:"task"`
+		Context       string `json:"context"`
+		ToolSet       string `json:"tool_set"`
+		MaxIterations int    `json:"max_iterations"`
+	}
+	if err := json.Unmarshal(params, &args); err != nil {
+		return NewErrorResult(NewParameterError("parameters", fmt.Sprintf("invalid JSON: %v", err))), nil
+	}
+
+	if strings.TrimSpace(args.Task) == "" {
+		return NewErrorResult(NewMissingParameterError("task")), nil
+	}
+	switch args.ToolSet {
+	case "":
+		args.ToolSet = DelegateToolSetReadOnly
+	case DelegateToolSetReadOnly, DelegateToolSetEdit, DelegateToolSetFull:
+	default:
+		return NewErrorResult(NewParameterError("tool_set", "must be one of read_only, edit or full")), nil
+	}
+	if args.MaxIterations < 0 {
+		return NewErrorResult(NewParameterError("max_iterations", "must be at least 1")), nil
+	}
+
+	result, err := t.spawner.Spawn(ctx, DelegateRequest{
+		Task:          args.Task,
+		Context:       args.Context,
+		ToolSet:       args.ToolSet,
+		MaxIterations: args.MaxIterations,
+	})
+	if errors.Is(err, ErrDelegationBudgetExhausted) {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeResourceLimit,
+			err.Error(),
+			"Do the rest of the work yourself instead of delegating it",
+		)), nil
+	}
+	if err != nil {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeInternalError,
+			fmt.Sprintf("child agent failed: %v", err),
+			"Do the subtask yourself or delegate a smaller part of it",
+		)), nil
+	}
+
+	data := map[string]interface{}{
+		"summary":    result.Summary,
+		"iterations": result.Iterations,
+		"tool_calls": result.ToolCalls,
+	}
+	if !result.Success {
+		return &ToolResult{
+			Success: false,
+			Data:    data,
+			Error:   fmt.Sprintf("child agent did not complete the subtask: %s", result.Error),
+		}, nil
+	}
+	return NewSuccessResult(data), nil
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSpawner records the request it receives and returns a canned result
+type fakeSpawner struct {
+	req    DelegateRequest
+	result *DelegateResult
+	err    error
+}
+
+func (s *fakeSpawner) Spawn(ctx context.Context, req DelegateRequest) (*DelegateResult, error) {
+	s.req = req
+	return s.result, s.err
+}
+
+func TestDelegateTaskTool(t *testing.T) {
+	execute := func(t *testing.T, spawner *fakeSpawner, args map[string]interface{}) *ToolResult {
+		params, _ := json.Marshal(args)
+		result, err := NewDelegateTaskTool(spawner).Execute(context.Background(), params)
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("returns only the summary", func(t *testing.T) {
+		spawner := &fakeSpawner{result: &DelegateResult{Summary: "found 3 callers", Success: true, Iterations: 2, ToolCalls: 4}}
+		result := execute(t, spawner, map[string]interface{}{"task": "find callers", "max_iterations": 3})
+		require.True(t, result.Success)
+		assert.Equal(t, DelegateRequest{Task: "find callers", ToolSet: DelegateToolSetReadOnly, MaxIterations: 3}, spawner.req)
+		assert.Equal(t, map[string]interface{}{"summary": "found 3 callers", "iterations": 2, "tool_calls": 4}, result.Data)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		spawner := &fakeSpawner{}
+		assert.Equal(t, ErrorCodeMissingParameter, execute(t, spawner, map[string]interface{}{"task": " "}).StandardizedError.Code)
+		assert.Equal(t, ErrorCodeInvalidParameters, execute(t, spawner, map[string]interface{}{"task": "x", "tool_set": "root"}).StandardizedError.Code)
+		assert.Empty(t, spawner.req.Task, "the child is not spawned")
+	})
+
+	t.Run("child failures", func(t *testing.T) {
+		result := execute(t, &fakeSpawner{err: errors.New("boom")}, map[string]interface{}{"task": "x"})
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Error, "boom")
+
+		result = execute(t, &fakeSpawner{result: &DelegateResult{Summary: "partial", Error: "max iterations reached"}}, map[string]interface{}{"task": "x"})
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Error, "max iterations reached")
+		assert.Equal(t, "partial", result.Data.(map[string]interface{})["summary"])
+	})
+
+	t.Run("budget used up", func(t *testing.T) {
+		result := execute(t, &fakeSpawner{err: fmt.Errorf("%w: children used 5 of 10", ErrDelegationBudgetExhausted)}, map[string]interface{}{"task": "x"})
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Error, "no iterations are left to delegate")
+		assert.NotContains(t, result.Error, "child agent failed")
+	})
+}
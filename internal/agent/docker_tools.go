@@ -0,0 +1,449 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Defaults of DockerToolConfig
+const (
+	DefaultDockerCommand = "docker"
+	DefaultDockerTimeout = 30 * time.Second
+)
+
+// maxDockerLogBytes is how much of the end of the logs docker_logs returns
+const maxDockerLogBytes = 64 * 1024
+
+// DockerToolConfig configures docker_ps, docker_logs and compose_config
+type DockerToolConfig struct {
+	Enabled      bool          // The tools are only registered when enabled
+	Command      string        // Docker CLI, e.g. "podman"; DefaultDockerCommand when empty
+	ComposeFiles []string      // Compose files relative to the workspace; compose looks for its defaults when empty
+	ProjectName  string        // Compose project name; compose derives it from the directory when empty
+	Timeout      time.Duration // Per command; 0 uses DefaultDockerTimeout
+}
+
+// Docker runs the Docker CLI for the docker tools, in the workspace so
+// compose finds the project's files
+type Docker struct {
+	workspaceRoot string
+	config        DockerToolConfig
+	// run runs the CLI with args and returns its standard output and error;
+	// replaced in tests
+	run func(ctx context.Context, args ...string) (stdout, stderr []byte, err error)
+}
+
+// NewDocker creates the Docker CLI runner of a workspace
+func NewDocker(workspaceRoot string, config DockerToolConfig) *Docker {
+	if config.Command == "" {
+		config.Command = DefaultDockerCommand
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultDockerTimeout
+	}
+	d := &Docker{workspaceRoot: workspaceRoot, config: config}
+	d.run = d.exec
+	return d
+}
+
+// NewDockerTools creates docker_ps, docker_logs and compose_config
+func NewDockerTools(workspaceRoot string, config DockerToolConfig) []Tool {
+	docker := NewDocker(workspaceRoot, config)
+	return []Tool{NewDockerPsTool(docker), NewDockerLogsTool(docker), NewComposeConfigTool(docker)}
+}
+
+// exec runs the CLI with the environment of shell commands plus the
+// variables compose reads
+func (d *Docker) exec(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	cmd := exec.CommandContext(ctx, d.config.Command, args...) // #nosec G204 - configured CLI, arguments passed directly
+	cmd.Dir = d.workspaceRoot
+	allowlist := append(append([]string{}, DefaultShellEnvAllowlist...), "COMPOSE_*")
+	cmd.Env = commandEnv(os.Environ(), allowlist, nil)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = subprocessStopGrace
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// command runs the CLI with the configured timeout and turns failures into
+// errors the model can act on
+func (d *Docker) command(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	runCtx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	defer cancel()
+	stdout, stderr, err := d.run(runCtx, args...)
+	switch {
+	case err == nil:
+		return stdout, stderr, nil
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, nil, NewStandardizedError(ErrorCodeCommandNotFound,
+			fmt.Sprintf("%s is not installed", d.config.Command),
+			"Check the containers with run_shell_command, or set tools.docker.command")
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		return nil, nil, NewStandardizedError(ErrorCodeCommandTimeout,
+			fmt.Sprintf("%s %s timed out after %s", d.config.Command, args[0], d.config.Timeout), "The Docker daemon may be unresponsive")
+	case ctx.Err() != nil:
+		return nil, nil, ctx.Err()
+	}
+	message := strings.TrimSpace(string(stderr))
+	if message == "" {
+		message = err.Error()
+	}
+	return nil, nil, NewStandardizedError(ErrorCodeCommandFailed,
+		fmt.Sprintf("%s %s failed: %s", d.config.Command, args[0], message),
+		"Check that the Docker daemon is running and the names are right (docker_ps lists them)")
+}
+
+// composeArgs returns the arguments of a compose subcommand for the
+// workspace's project
+func (d *Docker) composeArgs(subcommand ...string) []string {
+	args := []string{"compose"}
+	for _, file := range d.config.ComposeFiles {
+		args = append(args, "-f", file)
+	}
+	if d.config.ProjectName != "" {
+		args = append(args, "-p", d.config.ProjectName)
+	}
+	return append(args, subcommand...)
+}
+
+// DockerPsParams are the parameters of docker_ps
+type DockerPsParams struct {
+	Scope          string `json:"scope" description:"compose lists the containers of the workspace's compose project, host every container of the daemon" jsonschema:"enum=compose|host,default=compose"`
+	IncludeStopped bool   `json:"include_stopped" description:"Also list stopped and exited containers"`
+}
+
+// DockerContainer is a container listed by docker_ps
+type DockerContainer struct {
+	Name     string `json:"name"`
+	Service  string `json:"service,omitempty"` // Compose service
+	Image    string `json:"image"`
+	State    string `json:"state"`            // e.g. running, exited, restarting
+	Status   string `json:"status"`           // e.g. "Up 5 minutes (healthy)"
+	Health   string `json:"health,omitempty"` // healthy, unhealthy or starting
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Ports    string `json:"ports,omitempty"`
+}
+
+// NewDockerPsTool creates the docker_ps tool
+func NewDockerPsTool(docker *Docker) Tool {
+	return NewTypedTool("docker_ps", `Lists containers with their state, health and ports, by default those of the workspace's Docker Compose project. Use it when tests or commands fail to reach a database, queue or other service, to check whether the service is running and healthy before retrying.
+
+USAGE EXAMPLES:
+- docker_ps({})
+- docker_ps({"include_stopped": true})
+- docker_ps({"scope": "host"})`,
+		func(ctx context.Context, params DockerPsParams) (interface{}, error) {
+			return docker.ps(ctx, params)
+		})
+}
+
+// ps runs a docker_ps call
+func (d *Docker) ps(ctx context.Context, params DockerPsParams) (interface{}, error) {
+	var args []string
+	if params.Scope == "host" {
+		args = []string{"ps", "--format", "{{json .}}", "--no-trunc"}
+	} else {
+		args = d.composeArgs("ps", "--format", "json")
+	}
+	if params.IncludeStopped {
+		args = append(args, "--all")
+	}
+	output, _, err := d.command(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := jsonRecords(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the container list: %w", err)
+	}
+	containers := make([]DockerContainer, 0, len(records))
+	notReady := 0
+	for _, record := range records {
+		c := DockerContainer{
+			Name:    firstString(record, "Name", "Names"),
+			Service: firstString(record, "Service"),
+			Image:   firstString(record, "Image"),
+			State:   strings.ToLower(firstString(record, "State")),
+			Status:  firstString(record, "Status"),
+			Health:  firstString(record, "Health"),
+			Ports:   firstString(record, "Ports"),
+		}
+		if c.Health == "" { // docker ps only reports health in the status
+			for _, health := range []string{"unhealthy", "healthy", "health: starting"} {
+				if strings.Contains(c.Status, "("+health+")") {
+					c.Health = strings.TrimPrefix(health, "health: ")
+					break
+				}
+			}
+		}
+		if publishers, ok := record["Publishers"].([]interface{}); ok && c.Ports == "" { // Older compose
+			var ports []string
+			for _, raw := range publishers {
+				if p, ok := raw.(map[string]interface{}); ok && firstString(p, "PublishedPort") != "" && firstString(p, "PublishedPort") != "0" {
+					ports = append(ports, fmt.Sprintf("%s->%s/%s", firstString(p, "PublishedPort"), firstString(p, "TargetPort"), firstString(p, "Protocol")))
+				}
+			}
+			c.Ports = strings.Join(ports, ", ")
+		}
+		if code, ok := record["ExitCode"].(float64); ok && c.State == "exited" {
+			exitCode := int(code)
+			c.ExitCode = &exitCode
+		}
+		if c.State != "running" || c.Health == "unhealthy" {
+			notReady++
+		}
+		containers = append(containers, c)
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+
+	return map[string]interface{}{
+		"containers": containers,
+		"count":      len(containers),
+		"not_ready":  notReady, // Not running, or running but unhealthy
+	}, nil
+}
+
+// DockerLogsParams are the parameters of docker_logs
+type DockerLogsParams struct {
+	Target string `json:"target" description:"Compose service, or container name or ID when scope is host" pattern:"^[A-Za-z0-9][A-Za-z0-9_.-]*$" jsonschema:"required"`
+	Scope  string `json:"scope" description:"compose reads the logs of a service of the workspace's compose project, host of any container" jsonschema:"enum=compose|host,default=compose"`
+	Tail   int    `json:"tail" description:"Number of lines from the end of the logs" jsonschema:"default=200,minimum=1,maximum=2000"`
+	Since  string `json:"since" description:"Only logs since a duration ago, e.g. 10m, or a timestamp" pattern:"^[0-9][0-9A-Za-z:.+-]*$"`
+}
+
+// NewDockerLogsTool creates the docker_logs tool
+func NewDockerLogsTool(docker *Docker) Tool {
+	return NewTypedTool("docker_logs", `Returns the recent logs of a container or Docker Compose service, with timestamps. Use it after docker_ps shows a service exited, restarting or unhealthy, or when tests fail against a containerized dependency, to find out why instead of retrying.
+
+USAGE EXAMPLES:
+- docker_logs({"target": "postgres"})
+- docker_logs({"target": "api", "tail": 50, "since": "5m"})
+- docker_logs({"target": "3f2a9c1b7e4d", "scope": "host"})`,
+		func(ctx context.Context, params DockerLogsParams) (interface{}, error) {
+			return docker.logs(ctx, params)
+		})
+}
+
+// logs runs a docker_logs call
+func (d *Docker) logs(ctx context.Context, params DockerLogsParams) (interface{}, error) {
+	if params.Tail == 0 {
+		params.Tail = 200
+	}
+	options := []string{"--tail", fmt.Sprint(params.Tail), "--timestamps"}
+	if params.Since != "" {
+		options = append(options, "--since", params.Since)
+	}
+
+	var args []string
+	if params.Scope == "host" {
+		args = append(append([]string{"logs"}, options...), "--", params.Target)
+	} else {
+		args = d.composeArgs(append(append([]string{"logs", "--no-color"}, options...), "--", params.Target)...)
+	}
+	// Containers log to both streams, which the CLI passes through
+	stdout, stderr, err := d.command(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := string(stdout) + string(stderr)
+	truncated := false
+	if len(logs) > maxDockerLogBytes {
+		logs = logs[len(logs)-maxDockerLogBytes:]
+		if newline := strings.IndexByte(logs, '\n'); newline >= 0 {
+			logs = logs[newline+1:]
+		}
+		truncated = true
+	}
+	return map[string]interface{}{
+		"target":    params.Target,
+		"logs":      logs,
+		"lines":     strings.Count(logs, "\n"),
+		"truncated": truncated,
+	}, nil
+}
+
+// ComposeConfigParams are the parameters of compose_config
+type ComposeConfigParams struct {
+	Service string `json:"service" description:"Only describe this service"`
+}
+
+// ComposeService is a service of the compose project, as compose_config
+// describes it
+type ComposeService struct {
+	Name        string      `json:"name"`
+	Image       string      `json:"image,omitempty"`
+	Build       string      `json:"build,omitempty"` // Build context
+	Command     interface{} `json:"command,omitempty"`
+	Ports       []string    `json:"ports,omitempty"` // published:target/protocol
+	DependsOn   []string    `json:"depends_on,omitempty"`
+	Healthcheck []string    `json:"healthcheck,omitempty"` // Test command
+	Environment []string    `json:"environment,omitempty"` // Names only; values may be secrets
+	Profiles    []string    `json:"profiles,omitempty"`
+}
+
+// NewComposeConfigTool creates the compose_config tool
+func NewComposeConfigTool(docker *Docker) Tool {
+	return NewTypedTool("compose_config", `Describes the services of the workspace's Docker Compose project as compose resolves them: images, build contexts, published ports, dependencies, health checks and the names of environment variables (their values are not returned). Use it to learn which host and port a test should reach a service on.
+
+USAGE EXAMPLES:
+- compose_config({})
+- compose_config({"service": "db"})`,
+		func(ctx context.Context, params ComposeConfigParams) (interface{}, error) {
+			return docker.composeConfig(ctx, params)
+		})
+}
+
+// composeConfig runs a compose_config call
+func (d *Docker) composeConfig(ctx context.Context, params ComposeConfigParams) (interface{}, error) {
+	output, _, err := d.command(ctx, d.composeArgs("config", "--format", "json")...)
+	if err != nil {
+		return nil, err
+	}
+	var project struct {
+		Name     string                            `json:"name"`
+		Services map[string]map[string]interface{} `json:"services"`
+	}
+	if err := json.Unmarshal(output, &project); err != nil {
+		return nil, fmt.Errorf("failed to parse the compose configuration: %w", err)
+	}
+
+	names := make([]string, 0, len(project.Services))
+	for name := range project.Services {
+		if params.Service == "" || name == params.Service {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && params.Service != "" {
+		return nil, NewStandardizedError(ErrorCodeInvalidParameters,
+			fmt.Sprintf("the compose project has no service %s", params.Service), "Call compose_config without a service to list them")
+	}
+	sort.Strings(names)
+
+	services := make([]ComposeService, len(names))
+	for i, name := range names {
+		services[i] = composeService(name, project.Services[name])
+	}
+	return map[string]interface{}{
+		"project":  project.Name,
+		"services": services,
+	}, nil
+}
+
+// composeService summarizes a service of the normalized compose configuration
+func composeService(name string, raw map[string]interface{}) ComposeService {
+	s := ComposeService{Name: name, Image: firstString(raw, "image"), Command: raw["command"]}
+	switch build := raw["build"].(type) {
+	case string:
+		s.Build = build
+	case map[string]interface{}:
+		s.Build = firstString(build, "context")
+	}
+	if ports, ok := raw["ports"].([]interface{}); ok {
+		for _, port := range ports {
+			switch p := port.(type) {
+			case string:
+				s.Ports = append(s.Ports, p)
+			case map[string]interface{}:
+				mapping := fmt.Sprint(p["target"])
+				if published := firstString(p, "published"); published != "" {
+					mapping = published + ":" + mapping
+				}
+				if protocol := firstString(p, "protocol"); protocol != "" {
+					mapping += "/" + protocol
+				}
+				s.Ports = append(s.Ports, mapping)
+			}
+		}
+	}
+	switch deps := raw["depends_on"].(type) {
+	case map[string]interface{}:
+		for dep := range deps {
+			s.DependsOn = append(s.DependsOn, dep)
+		}
+		sort.Strings(s.DependsOn)
+	case []interface{}:
+		for _, dep := range deps {
+			s.DependsOn = append(s.DependsOn, fmt.Sprint(dep))
+		}
+	}
+	if healthcheck, ok := raw["healthcheck"].(map[string]interface{}); ok {
+		switch test := healthcheck["test"].(type) {
+		case string:
+			s.Healthcheck = []string{test}
+		case []interface{}:
+			for _, part := range test {
+				s.Healthcheck = append(s.Healthcheck, fmt.Sprint(part))
+			}
+		}
+	}
+	switch env := raw["environment"].(type) {
+	case map[string]interface{}:
+		for variable := range env {
+			s.Environment = append(s.Environment, variable)
+		}
+		sort.Strings(s.Environment)
+	case []interface{}:
+		for _, entry := range env {
+			variable, _, _ := strings.Cut(fmt.Sprint(entry), "=")
+			s.Environment = append(s.Environment, variable)
+		}
+	}
+	if profiles, ok := raw["profiles"].([]interface{}); ok {
+		for _, profile := range profiles {
+			s.Profiles = append(s.Profiles, fmt.Sprint(profile))
+		}
+	}
+	return s
+}
+
+// jsonRecords parses the JSON output of a list command, either one array or
+// one object per line depending on the CLI version
+func jsonRecords(output []byte) ([]map[string]interface{}, error) {
+	output = bytes.TrimSpace(output)
+	var records []map[string]interface{}
+	if len(output) == 0 {
+		return records, nil
+	}
+	if output[0] == '[' {
+		err := json.Unmarshal(output, &records)
+		return records, err
+	}
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// firstString returns the first of keys that is a string in record
+func firstString(record map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := record[key].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case float64:
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeDocker returns a Docker whose CLI answers with output and records the
+// arguments of each call
+func fakeDocker(config DockerToolConfig, output string, err error) (*Docker, *[][]string) {
+	docker := NewDocker("/workspace", config)
+	var calls [][]string
+	docker.run = func(ctx context.Context, args ...string) ([]byte, []byte, error) {
+		calls = append(calls, args)
+		if err != nil {
+			return nil, []byte("no such service: web"), err
+		}
+		return []byte(output), []byte("stderr line\n"), nil
+	}
+	return docker, &calls
+}
+
+func TestDockerPs(t *testing.T) {
+	output := `{"Name":"app-db-1","Service":"db","Image":"postgres:16","State":"running","Status":"Up 2 minutes (healthy)","Health":"healthy","Ports":"0.0.0.0:5432->5432/tcp"}
+{"Name":"app-api-1","Service":"api","Image":"app-api","State":"exited","Status":"Exited (1) 5 seconds ago","ExitCode":1}
+`
+	docker, calls := fakeDocker(DockerToolConfig{ComposeFiles: []string{"compose.test.yml"}, ProjectName: "app"}, output, nil)
+
+	result, err := NewDockerPsTool(docker).Execute(context.Background(), json.RawMessage(`{"include_stopped": true}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	want := []string{"compose", "-f", "compose.test.yml", "-p", "app", "ps", "--format", "json", "--all"}
+	if !reflect.DeepEqual((*calls)[0], want) {
+		t.Errorf("expected %v, got %v", want, (*calls)[0])
+	}
+	data := result.Data.(map[string]interface{})
+	containers := data["containers"].([]DockerContainer)
+	if len(containers) != 2 || containers[0].Name != "app-api-1" || *containers[0].ExitCode != 1 || containers[1].Health != "healthy" {
+		t.Errorf("unexpected containers: %+v", containers)
+	}
+	if data["not_ready"] != 1 {
+		t.Errorf("expected the exited container to be counted, got %v", data["not_ready"])
+	}
+
+	// docker ps reports health only in the status, and older compose
+	// versions print an array
+	docker, _ = fakeDocker(DockerToolConfig{}, `[{"Names":"cache","Image":"redis","State":"running","Status":"Up 1 second (health: starting)"}]`, nil)
+	result, err = NewDockerPsTool(docker).Execute(context.Background(), json.RawMessage(`{"scope": "host"}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	containers = result.Data.(map[string]interface{})["containers"].([]DockerContainer)
+	if len(containers) != 1 || containers[0].Name != "cache" || containers[0].Health != "starting" {
+		t.Errorf("unexpected containers: %+v", containers)
+	}
+}
+
+func TestDockerLogs(t *testing.T) {
+	docker, calls := fakeDocker(DockerToolConfig{}, "2024-05-01T10:00:00Z ready\n", nil)
+	logs := NewDockerLogsTool(docker)
+
+	result, err := logs.Execute(context.Background(), json.RawMessage(`{"target": "db", "tail": 20, "since": "10m"}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	want := []string{"compose", "logs", "--no-color", "--tail", "20", "--timestamps", "--since", "10m", "--", "db"}
+	if !reflect.DeepEqual((*calls)[0], want) {
+		t.Errorf("expected %v, got %v", want, (*calls)[0])
+	}
+	if text := result.Data.(map[string]interface{})["logs"].(string); !strings.Contains(text, "ready") || !strings.Contains(text, "stderr line") {
+		t.Errorf("expected both streams, got %q", text)
+	}
+
+	// Targets cannot pass options to the CLI
+	result, err = logs.Execute(context.Background(), json.RawMessage(`{"target": "--help"}`))
+	if err == nil && result.Success {
+		t.Errorf("expected an option as target to be rejected, got %+v", result)
+	}
+
+	docker, _ = fakeDocker(DockerToolConfig{}, "", errors.New("exit status 1"))
+	result, err = NewDockerLogsTool(docker).Execute(context.Background(), json.RawMessage(`{"target": "web"}`))
+	if err != nil || result.Success || result.StandardizedError.Code != ErrorCodeCommandFailed || !strings.Contains(result.StandardizedError.Message, "no such service") {
+		t.Errorf("expected the CLI error to be reported, got %v %+v", err, result)
+	}
+
+	docker, _ = fakeDocker(DockerToolConfig{Command: "podman"}, "", exec.ErrNotFound)
+	result, err = NewDockerLogsTool(docker).Execute(context.Background(), json.RawMessage(`{"target": "web"}`))
+	if err != nil || result.Success || result.StandardizedError.Code != ErrorCodeCommandNotFound {
+		t.Errorf("expected a missing CLI to be reported, got %v %+v", err, result)
+	}
+}
+
+func TestComposeConfig(t *testing.T) {
+	output := `{"name": "app", "services": {
+		"db": {"image": "postgres:16", "environment": {"POSTGRES_PASSWORD": "hunter2", "POSTGRES_DB": "app"},
+			"ports": [{"mode": "ingress", "target": 5432, "published": "55432", "protocol": "tcp"}],
+			"healthcheck": {"test": ["CMD", "pg_isready"]}},
+		"api": {"build": {"context": "/workspace", "dockerfile": "Dockerfile"}, "depends_on": {"db": {"condition": "service_healthy"}}}
+	}}`
+	docker, _ := fakeDocker(DockerToolConfig{}, output, nil)
+	tool := NewComposeConfigTool(docker)
+
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	services := result.Data.(map[string]interface{})["services"].([]ComposeService)
+	if len(services) != 2 {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+	api, db := services[0], services[1]
+	if api.Build != "/workspace" || !reflect.DeepEqual(api.DependsOn, []string{"db"}) {
+		t.Errorf("unexpected api service: %+v", api)
+	}
+	if !reflect.DeepEqual(db.Ports, []string{"55432:5432/tcp"}) || !reflect.DeepEqual(db.Healthcheck, []string{"CMD", "pg_isready"}) {
+		t.Errorf("unexpected db service: %+v", db)
+	}
+	if !reflect.DeepEqual(db.Environment, []string{"POSTGRES_DB", "POSTGRES_PASSWORD"}) {
+		t.Errorf("expected only the variable names, got %v", db.Environment)
+	}
+	if encoded, _ := json.Marshal(result.Data); strings.Contains(string(encoded), "hunter2") {
+		t.Error("expected environment values to be left out")
+	}
+
+	result, err = tool.Execute(context.Background(), json.RawMessage(`{"service": "queue"}`))
+	if err != nil || result.Success {
+		t.Errorf("expected an unknown service to fail, got %v %+v", err, result)
+	}
+}
+
+func TestDockerToolsAreOptIn(t *testing.T) {
+	factory := NewToolFactory(t.TempDir())
+	if _, ok := factory.CreateReviewRegistry().Get("docker_ps"); ok {
+		t.Error("expected the docker tools to be off by default")
+	}
+	factory.SetDockerConfig(DockerToolConfig{Enabled: true})
+	registry := factory.CreateReviewRegistry()
+	for _, name := range []string{"docker_ps", "docker_logs", "compose_config"} {
+		if _, ok := registry.Get(name); !ok {
+			t.Errorf("expected %s to be registered", name)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -14,6 +15,8 @@ const (
 	ErrorCodeMissingParameter     ToolErrorCode = "MISSING_PARAMETER"
 	ErrorCodeInvalidPathFormat    ToolErrorCode = "INVALID_PATH_FORMAT"
 	ErrorCodePathOutsideWorkspace ToolErrorCode = "PATH_OUTSIDE_WORKSPACE"
+	ErrorCodeProtectedPath        ToolErrorCode = "PROTECTED_PATH"
+	ErrorCodePolicyDenied         ToolErrorCode = "POLICY_DENIED" // Refused by an organizational policy
 
 	// File system errors
 	ErrorCodeFileNotFound      ToolErrorCode = "FILE_NOT_FOUND"
@@ -21,6 +24,7 @@ const (
 	ErrorCodeFileAlreadyExists ToolErrorCode = "FILE_ALREADY_EXISTS"
 	ErrorCodePermissionDenied  ToolErrorCode = "PERMISSION_DENIED"
 	ErrorCodeInsufficientSpace ToolErrorCode = "INSUFFICIENT_SPACE"
+	ErrorCodeFileConflict      ToolErrorCode = "FILE_CONFLICT"
 
 	// Content validation errors
 	ErrorCodeInvalidFileFormat ToolErrorCode = "INVALID_FILE_FORMAT"
@@ -51,6 +55,7 @@ const (
 	ErrorCodeTimeout              ToolErrorCode = "TIMEOUT"
 	ErrorCodeResourceLimit        ToolErrorCode = "RESOURCE_LIMIT"
 	ErrorCodeUnsupportedOperation ToolErrorCode = "UNSUPPORTED_OPERATION"
+	ErrorCodeUnclassified         ToolErrorCode = "UNCLASSIFIED" // Failures reported without a code
 )
 
 // StandardizedToolError represents a rich error structure for tools
@@ -66,6 +71,18 @@ func (e *StandardizedToolError) Error() string {
 	return fmt.Sprintf("[%s] %s", e.Code, e.Message)
 }
 
+// permanentErrorCodes are errors a retry cannot fix: the same call fails the
+// same way every time
+var permanentErrorCodes = map[ToolErrorCode]bool{
+	ErrorCodeProtectedPath: true,
+	ErrorCodePolicyDenied:  true,
+}
+
+// Permanent reports whether retrying the failed call cannot succeed
+func (e *StandardizedToolError) Permanent() bool {
+	return permanentErrorCodes[e.Code]
+}
+
 // NewStandardizedError creates a new standardized tool error
 func NewStandardizedError(code ToolErrorCode, message string, suggestion string) *StandardizedToolError {
 	return &StandardizedToolError{
@@ -76,6 +93,16 @@ func NewStandardizedError(code ToolErrorCode, message string, suggestion string)
 	}
 }
 
+// AsStandardizedError returns err as a StandardizedToolError. Other errors are
+// wrapped with code, keeping their message.
+func AsStandardizedError(err error, code ToolErrorCode) *StandardizedToolError {
+	var standardized *StandardizedToolError
+	if errors.As(err, &standardized) {
+		return standardized
+	}
+	return NewStandardizedError(code, err.Error(), "")
+}
+
 // WithDetail adds a detail to the error
 func (e *StandardizedToolError) WithDetail(key string, value interface{}) *StandardizedToolError {
 	e.Details[key] = value
@@ -196,6 +223,7 @@ func GetErrorCodeSuggestions() map[ToolErrorCode]string {
 		ErrorCodePathOutsideWorkspace: "Use relative paths within the workspace and avoid '..' or absolute paths",
 		ErrorCodeInvalidLineRange:     "Ensure start_line <= end_line and both are within the file's line count",
 		ErrorCodeContentTooLarge:      "Break large content into smaller chunks or use streaming operations",
+		ErrorCodeFileConflict:         "Read the file again before changing it; someone else edited it",
 		ErrorCodeGitNotRepository:     "Ensure you're working within a git repository or initialize one if needed",
 		ErrorCodeTestFailure:          "Review test failures and fix underlying issues before proceeding",
 		ErrorCodeCommandFailed:        "Check command syntax, arguments, and ensure required dependencies are available",
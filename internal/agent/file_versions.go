@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// Values accepted by the on_conflict parameter of the editing tools
+const (
+	OnConflictFail  = "fail"
+	OnConflictMerge = "merge"
+)
+
+// FileVersions remembers the content of each file as the agent last read or
+// wrote it, so an edit can tell when someone else, such as the user in their
+// editor, changed the file in between and would lose that change. Like a
+// Checkpoint, it is passed to tools through their Execute context, see
+// WithFileVersions.
+type FileVersions struct {
+	mu    sync.Mutex
+	files map[string]fileVersion // Absolute path -> version last seen
+}
+
+// fileVersion is a file's content as the agent knew it
+type fileVersion struct {
+	hash    string
+	content []byte
+}
+
+// NewFileVersions creates an empty set of versions
+func NewFileVersions() *FileVersions {
+	return &FileVersions{files: make(map[string]fileVersion)}
+}
+
+// Record remembers content as the version of path the agent knows
+func (v *FileVersions) Record(path string, content []byte) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.files[path] = fileVersion{hash: contentHash(content), content: content}
+}
+
+// Forget drops the version of path, for a file that no longer exists
+func (v *FileVersions) Forget(path string) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.files, path)
+}
+
+// Changed reports whether current differs from the version of path the agent
+// knows, returning that version as the base for a merge. Files the agent has
+// not seen are never changed.
+func (v *FileVersions) Changed(path string, current []byte) ([]byte, bool) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	known, ok := v.files[path]
+	if !ok || known.hash == contentHash(current) {
+		return nil, false
+	}
+	return known.content, true
+}
+
+type fileVersionsKey struct{}
+
+// WithFileVersions returns a context in which the tools executed with it
+// record and check file versions in versions
+func WithFileVersions(ctx context.Context, versions *FileVersions) context.Context {
+	return context.WithValue(ctx, fileVersionsKey{}, versions)
+}
+
+// FileVersionsFromContext returns the file versions carried by ctx, if any
+func FileVersionsFromContext(ctx context.Context) (*FileVersions, bool) {
+	versions, ok := ctx.Value(fileVersionsKey{}).(*FileVersions)
+	return versions, ok && versions != nil
+}
+
+// RecordFileVersion records content as the version of path the agent knows,
+// after reading or writing it. It does nothing when ctx carries no versions.
+func RecordFileVersion(ctx context.Context, path string, content []byte) {
+	if versions, ok := FileVersionsFromContext(ctx); ok {
+		versions.Record(path, content)
+	}
+}
+
+// checkFileVersion compares the current content of path with the version the
+// agent last saw. It returns that version, to merge with, and the conflict
+// error to report unless the caller merges; both are nil when the file is
+// unchanged or was never seen. mergeable says whether the tool offers
+// on_conflict="merge".
+func checkFileVersion(ctx context.Context, path, relPath string, current []byte, mergeable bool) ([]byte, *StandardizedToolError) {
+	versions, ok := FileVersionsFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	base, changed := versions.Changed(path, current)
+	if !changed {
+		return nil, nil
+	}
+	suggestion := "Read the file again and redo the change on its current content"
+	if mergeable {
+		suggestion += `, or retry with on_conflict="merge" to merge your change with the new content`
+	}
+	return base, NewStandardizedError(
+		ErrorCodeFileConflict,
+		fmt.Sprintf("%s changed since it was last read; writing would discard those changes", relPath),
+		suggestion,
+	).WithDetail("file_path", relPath).
+		WithDetail("read_hash", contentHash(base)).
+		WithDetail("current_hash", contentHash(current))
+}
+
+// contentHash returns the SHA-256 of content in hex
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
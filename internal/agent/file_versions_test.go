@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const versionedSource = "package main\n\nfunc a() {}\n\nfunc b() {}\n\nfunc c() {}\n"
+
+// execute runs tool with params, failing the test on an execution error
+func execute(t *testing.T, ctx context.Context, tool Tool, params map[string]interface{}) *ToolResult {
+	t.Helper()
+	raw, _ := json.Marshal(params)
+	result, err := tool.Execute(ctx, raw)
+	require.NoError(t, err)
+	return result
+}
+
+func TestFileWriteDetectsConcurrentEdits(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	require.NoError(t, os.WriteFile(path, []byte(versionedSource), 0600))
+	ctx := WithFileVersions(context.Background(), NewFileVersions())
+	reader, writer := NewFileReadTool(root), NewFileWriteTool(root)
+
+	require.True(t, execute(t, ctx, reader, map[string]interface{}{"target_file": "main.go"}).Success)
+
+	// The user edits c while the agent rewrites a
+	require.NoError(t, os.WriteFile(path, []byte("package main\n\nfunc a() {}\n\nfunc b() {}\n\nfunc c() { user() }\n"), 0600))
+	agentVersion := "package main\n\nfunc a() { agent() }\n\nfunc b() {}\n\nfunc c() {}\n"
+
+	result := execute(t, ctx, writer, map[string]interface{}{"file_path": "main.go", "content": agentVersion})
+	require.False(t, result.Success)
+	assert.Equal(t, ErrorCodeFileConflict, result.StandardizedError.Code)
+
+	result = execute(t, ctx, writer, map[string]interface{}{"file_path": "main.go", "content": agentVersion, "on_conflict": "merge"})
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, true, result.Data.(map[string]interface{})["merged"])
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc a() { agent() }\n\nfunc b() {}\n\nfunc c() { user() }\n", string(content))
+
+	// The agent's own write is the version it knows now
+	result = execute(t, ctx, writer, map[string]interface{}{"file_path": "main.go", "content": versionedSource})
+	assert.True(t, result.Success, result.Error)
+
+	// Without versions in the context nothing is checked
+	require.NoError(t, os.WriteFile(path, []byte("changed\n"), 0600))
+	result = execute(t, context.Background(), writer, map[string]interface{}{"file_path": "main.go", "content": "x\n"})
+	assert.True(t, result.Success, result.Error)
+}
+
+func TestPatchApplyDetectsConcurrentEdits(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	require.NoError(t, os.WriteFile(path, []byte(versionedSource), 0600))
+	ctx := WithFileVersions(context.Background(), NewFileVersions())
+	require.True(t, execute(t, ctx, NewFileReadTool(root), map[string]interface{}{"target_file": "main.go"}).Success)
+
+	// The user edits b, where the patch to a has context lines
+	require.NoError(t, os.WriteFile(path, []byte("package main\n\nfunc a() {}\n\nfunc b() { user() }\n\nfunc c() {}\n"), 0600))
+	patch := "@@ -1,5 +1,5 @@\n package main\n \n-func a() {}\n+func a() { agent() }\n \n func b() {}\n"
+	tool := NewPatchApplyTool(root)
+
+	result := execute(t, ctx, tool, map[string]interface{}{"file_path": "main.go", "patch_content": patch, "backup_original": false})
+	require.False(t, result.Success)
+	assert.Equal(t, ErrorCodeFileConflict, result.StandardizedError.Code)
+
+	result = execute(t, ctx, tool, map[string]interface{}{"file_path": "main.go", "patch_content": patch, "on_conflict": "merge"})
+	require.True(t, result.Success, result.Error)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc a() { agent() }\n\nfunc b() { user() }\n\nfunc c() {}\n", string(content))
+}
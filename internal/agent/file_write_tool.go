@@ -6,47 +6,67 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/castrovroberto/CGE/internal/patchutils"
+	"github.com/castrovroberto/CGE/internal/textutils"
 )
 
-// FileWriteTool implements file writing capabilities with enhanced validation and error handling
-type FileWriteTool struct {
-	workspaceRoot string
-	validator     *ToolValidator
-}
+// Values accepted by write_file's if_exists parameter
+const (
+	WriteIfExistsOverwrite = "overwrite"
+	WriteIfExistsAppend    = "append"
+	WriteIfExistsFail      = "fail"
+)
 
-// NewFileWriteTool creates a new file write tool
-func NewFileWriteTool(workspaceRoot string) *FileWriteTool {
-	return &FileWriteTool{
-		workspaceRoot: workspaceRoot,
-		validator:     NewToolValidator(workspaceRoot),
-	}
+// Actions reported in a write_file summary
+const (
+	WriteActionCreated  = "created"
+	WriteActionModified = "modified"
+	WriteActionAppended = "appended"
+)
+
+// DefaultMaxOverwriteBytes is the largest existing file write_file replaces
+// without force
+const DefaultMaxOverwriteBytes = 512 * 1024
+
+// FileToolConfig holds the safety limits of the file tools
+type FileToolConfig struct {
+	// MaxOverwriteBytes is the largest existing file write_file overwrites or
+	// appends to without force; 0 uses DefaultMaxOverwriteBytes
+	MaxOverwriteBytes int64
 }
 
-func (t *FileWriteTool) Name() string {
-	return "write_file"
+// maxOverwriteBytes returns the configured limit or the default
+func (c FileToolConfig) maxOverwriteBytes() int64 {
+	if c.MaxOverwriteBytes > 0 {
+		return c.MaxOverwriteBytes
+	}
+	return DefaultMaxOverwriteBytes
 }
 
-func (t *FileWriteTool) Description() string {
-	return `Writes content to a specified file, creating the file and parent directories if needed.
+const fileWriteDescription = `Writes content to a specified file, creating the file and parent directories if needed.
 
 USAGE EXAMPLES:
 - write_file({"file_path": "src/main.go", "content": "package main\n..."})
 - write_file({"file_path": "docs/README.md", "content": "# Project", "create_dirs_if_needed": false})
+- write_file({"file_path": "CHANGELOG.md", "content": "- Fixed bug\n", "if_exists": "append"})
+- write_file({"file_path": "scripts/build.sh", "content": "#!/bin/sh\n...", "if_exists": "fail", "executable": true})
 
 IMPORTANT NOTES:
 - File path must be relative to workspace root
-- Will overwrite existing files by default
+- Will overwrite existing files by default; use if_exists="append" to add to the end or if_exists="fail" to refuse to touch existing files
 - Creates parent directories unless create_dirs_if_needed=false
-- Use with caution on existing files - always check file existence first if unsure
+- Existing file permissions (including executable bits) are preserved unless preserve_mode=false
+- The result reports whether the file was created, modified or appended to, and how many bytes were written
+- Binary files and large existing files are not replaced unless force=true; edit large files with apply_patch_to_file or search_replace instead
+- Overwriting a file that changed since you read it fails with FILE_CONFLICT; on_conflict="merge" merges your content with the changes instead
 
 PRE-CONDITIONS:
 - Workspace must be writable
 - Parent directory must exist (unless create_dirs_if_needed=true)
 - File path must be within workspace boundary`
-}
 
-func (t *FileWriteTool) Parameters() json.RawMessage {
-	return json.RawMessage(`{
+const fileWriteParameters = `{
 		"type": "object",
 		"properties": {
 			"file_path": {
@@ -65,23 +85,157 @@ func (t *FileWriteTool) Parameters() json.RawMessage {
 				"type": "boolean",
 				"description": "Whether to create parent directories if they don't exist",
 				"default": true
+			},
+			"if_exists": {
+				"type": "string",
+				"description": "What to do when the file already exists: overwrite it, append to it, or fail",
+				"enum": ["overwrite", "append", "fail"],
+				"default": "overwrite"
+			},
+			"executable": {
+				"type": "boolean",
+				"description": "Mark the file as executable (e.g. for scripts)",
+				"default": false
+			},
+			"preserve_mode": {
+				"type": "boolean",
+				"description": "Keep the existing file's permissions, including executable bits",
+				"default": true
+			},
+			"force": {
+				"type": "boolean",
+				"description": "Overwrite or append to an existing binary file, or one above the size limit",
+				"default": false
+			},
+			"on_conflict": {
+				"type": "string",
+				"description": "What to do when the file changed since it was last read: fail, or merge both changes three-way",
+				"enum": ["fail", "merge"],
+				"default": "fail"
 			}
 		},
 		"required": ["file_path", "content"],
 		"additionalProperties": false
-	}`)
+	}`
+
+// FileWriteTool implements file writing capabilities with enhanced validation and error handling
+type FileWriteTool struct {
+	workspaceRoot string
+	validator     *ToolValidator
+	config        FileToolConfig
+}
+
+// NewFileWriteTool creates a new file write tool
+func NewFileWriteTool(workspaceRoot string) *FileWriteTool {
+	return NewFileWriteToolWithConfig(workspaceRoot, FileToolConfig{})
+}
+
+// NewFileWriteToolWithConfig creates a file write tool with custom limits
+func NewFileWriteToolWithConfig(workspaceRoot string, config FileToolConfig) *FileWriteTool {
+	return &FileWriteTool{
+		workspaceRoot: workspaceRoot,
+		validator:     NewToolValidator(workspaceRoot),
+		config:        config,
+	}
+}
+
+func (t *FileWriteTool) Name() string {
+	return "write_file"
+}
+
+func (t *FileWriteTool) Description() string {
+	return fileWriteDescription
+}
+
+func (t *FileWriteTool) Parameters() json.RawMessage {
+	return json.RawMessage(fileWriteParameters)
 }
 
 type FileWriteParams struct {
 	FilePath           string `json:"file_path"`
 	Content            string `json:"content"`
 	CreateDirsIfNeeded *bool  `json:"create_dirs_if_needed,omitempty"`
+	IfExists           string `json:"if_exists,omitempty"`
+	Executable         bool   `json:"executable,omitempty"`
+	PreserveMode       *bool  `json:"preserve_mode,omitempty"`
+	Force              bool   `json:"force,omitempty"`
+	OnConflict         string `json:"on_conflict,omitempty"`
 }
 
 func (t *FileWriteTool) Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error) {
+	return executeFileWrite(ctx, params, t.Parameters(), t.validator, osFileSystem{}, t.config), nil
+}
+
+// osFileSystem is the FileSystemService used by FileWriteTool when no file system is injected
+type osFileSystem struct{}
+
+func (osFileSystem) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(path, data, perm)
+}
+
+func (osFileSystem) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path) // #nosec G304 - path validated by ToolValidator
+}
+
+func (osFileSystem) ListDir(path string) ([]os.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
+func (osFileSystem) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (osFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osFileSystem) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (osFileSystem) Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (osFileSystem) IsDir(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.IsDir()
+}
+
+func (osFileSystem) Chmod(path string, mode os.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
+func (osFileSystem) WriteFileAtomically(path string, data []byte, perm os.FileMode) error {
+	return writeFileAtomically(path, data, perm)
+}
+
+// atomicWriter is implemented by file systems that can replace a file without
+// exposing a partly written one
+type atomicWriter interface {
+	WriteFileAtomically(path string, data []byte, perm os.FileMode) error
+}
+
+// writeWholeFile replaces path with data, atomically when fs supports it
+func writeWholeFile(fs FileSystemService, path string, data []byte, perm os.FileMode) error {
+	if w, ok := fs.(atomicWriter); ok {
+		return w.WriteFileAtomically(path, data, perm)
+	}
+	return fs.WriteFile(path, data, perm)
+}
+
+// modeChanger is implemented by file systems that can change permissions of existing files
+type modeChanger interface {
+	Chmod(path string, mode os.FileMode) error
+}
+
+// executeFileWrite implements write_file on top of fs. Validation failures and
+// I/O errors are reported as standardized error results.
+func executeFileWrite(ctx context.Context, params, schema json.RawMessage, validator *ToolValidator, fs FileSystemService, config FileToolConfig) *ToolResult {
 	// Enhanced parameter validation
-	if err := t.validator.ValidateJSONSchema(params, t.Parameters()); err != nil {
-		return NewErrorResult(err.(*StandardizedToolError)), nil
+	if err := validator.ValidateJSONSchema(params, schema); err != nil {
+		return NewErrorResult(err.(*StandardizedToolError))
 	}
 
 	var p FileWriteParams
@@ -90,105 +244,246 @@ func (t *FileWriteTool) Execute(ctx context.Context, params json.RawMessage) (*T
 			ErrorCodeInvalidParameters,
 			"Failed to parse parameters",
 			"Ensure all parameters are properly formatted JSON",
-		).WithDetail("parse_error", err.Error())), nil
+		).WithDetail("parse_error", err.Error()))
 	}
 
-	// Set default value for CreateDirsIfNeeded
+	// Set default values for optional flags
 	createDirs := true
 	if p.CreateDirsIfNeeded != nil {
 		createDirs = *p.CreateDirsIfNeeded
 	}
+	preserveMode := true
+	if p.PreserveMode != nil {
+		preserveMode = *p.PreserveMode
+	}
+	ifExists := p.IfExists
+	if ifExists == "" {
+		ifExists = WriteIfExistsOverwrite
+	}
 
 	// Enhanced path validation
-	if err := t.validator.ValidateFilePath(p.FilePath); err != nil {
-		return NewErrorResult(err.(*StandardizedToolError)), nil
+	if err := validator.ValidateFilePath(p.FilePath); err != nil {
+		return NewErrorResult(err.(*StandardizedToolError))
 	}
 
 	// Content size validation (1MB limit)
-	if err := t.validator.ValidateContentSize(p.Content, 1048576); err != nil {
-		return NewErrorResult(err.(*StandardizedToolError)), nil
+	if err := validator.ValidateContentSize(p.Content, 1048576); err != nil {
+		return NewErrorResult(err.(*StandardizedToolError))
 	}
 
 	// Get safe path
-	fullPath, err := t.validator.GetSafePath(p.FilePath)
+	fullPath, err := validator.GetSafePath(p.FilePath)
 	if err != nil {
-		return NewErrorResult(err.(*StandardizedToolError)), nil
+		return NewErrorResult(err.(*StandardizedToolError))
+	}
+	if protected := CheckProtected(ctx, fullPath, p.FilePath); protected != nil {
+		return NewErrorResult(protected)
 	}
 
 	// Check if parent directory exists or needs creation
 	parentDir := filepath.Dir(fullPath)
-	if stat, err := os.Stat(parentDir); os.IsNotExist(err) {
+	var dirsCreated []string
+	if !fs.Exists(parentDir) {
 		if !createDirs {
 			return NewErrorResult(NewDirectoryNotFoundError(filepath.Dir(p.FilePath)).
-				WithDetail("suggestion", "Set create_dirs_if_needed=true or create parent directories first")), nil
+				WithDetail("suggestion", "Set create_dirs_if_needed=true or create parent directories first"))
 		}
 
-		// Create parent directories
-		if err := os.MkdirAll(parentDir, 0750); err != nil {
+		dirsCreated = missingDirs(fs, parentDir, validator.workspaceRoot)
+		if err := fs.MkdirAll(parentDir, 0750); err != nil {
 			return NewErrorResult(NewStandardizedError(
 				ErrorCodePermissionDenied,
 				fmt.Sprintf("Failed to create parent directories for %s", p.FilePath),
 				"Check write permissions for the workspace directory",
-			).WithDetail("file_path", p.FilePath).WithDetail("parent_dir", parentDir).WithDetail("os_error", err.Error())), nil
+			).WithDetail("file_path", p.FilePath).WithDetail("parent_dir", parentDir).WithDetail("os_error", err.Error()))
 		}
-	} else if err != nil {
-		return NewErrorResult(NewStandardizedError(
-			ErrorCodePermissionDenied,
-			fmt.Sprintf("Cannot access parent directory for %s", p.FilePath),
-			"Check directory permissions and ensure the path is accessible",
-		).WithDetail("file_path", p.FilePath).WithDetail("parent_dir", parentDir)), nil
-	} else if !stat.IsDir() {
+	} else if !fs.IsDir(parentDir) {
 		return NewErrorResult(NewStandardizedError(
 			ErrorCodeInvalidPathFormat,
 			fmt.Sprintf("Parent path is not a directory: %s", filepath.Dir(p.FilePath)),
 			"Ensure the parent path points to a directory, not a file",
-		).WithDetail("file_path", p.FilePath).WithDetail("parent_path", filepath.Dir(p.FilePath))), nil
+		).WithDetail("file_path", p.FilePath).WithDetail("parent_path", filepath.Dir(p.FilePath)))
 	}
 
-	// Check if file already exists (for informational purposes)
+	// Inspect the existing file to decide the action and permissions
 	var fileExisted bool
 	var originalSize int64
-	if stat, err := os.Stat(fullPath); err == nil {
+	perm := os.FileMode(0644)
+	if stat, err := fs.Stat(fullPath); err == nil {
+		if stat.IsDir() {
+			return NewErrorResult(NewStandardizedError(
+				ErrorCodeInvalidPathFormat,
+				fmt.Sprintf("Path is a directory: %s", p.FilePath),
+				"Provide a file path, not a directory",
+			).WithDetail("file_path", p.FilePath))
+		}
 		fileExisted = true
 		originalSize = stat.Size()
+		if preserveMode {
+			perm = stat.Mode().Perm()
+		}
+	}
+	if p.Executable {
+		perm |= 0111
+	}
+
+	if fileExisted && ifExists == WriteIfExistsFail {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeFileAlreadyExists,
+			fmt.Sprintf("File already exists: %s", p.FilePath),
+			"Read the file and use apply_patch_to_file to modify it, or set if_exists to \"overwrite\" or \"append\"",
+		).WithDetail("file_path", p.FilePath).WithDetail("file_size", originalSize))
+	}
+
+	// Large and binary files are assets or generated; replacing them is
+	// rarely what the model meant
+	if fileExisted && !p.Force {
+		if limit := config.maxOverwriteBytes(); originalSize > limit {
+			return NewErrorResult(NewStandardizedError(
+				ErrorCodeContentTooLarge,
+				fmt.Sprintf("Refusing to overwrite %s: it has %d bytes, more than the %d byte limit", p.FilePath, originalSize, limit),
+				"Edit the file with apply_patch_to_file or search_replace, or set force=true to replace it",
+			).WithDetail("file_path", p.FilePath).WithDetail("file_size", originalSize).WithDetail("limit", limit))
+		}
+	}
+
+	data := []byte(p.Content)
+	action := WriteActionCreated
+	merged := false
+	if fileExisted {
+		action = WriteActionModified
+		existing, err := fs.ReadFile(fullPath)
+		if err != nil {
+			return NewErrorResult(NewStandardizedError(
+				ErrorCodePermissionDenied,
+				fmt.Sprintf("Failed to read existing file: %s", p.FilePath),
+				"Check read permissions for the file",
+			).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error()))
+		}
+		if !p.Force && textutils.IsBinary(existing) {
+			return NewErrorResult(NewStandardizedError(
+				ErrorCodeInvalidFileFormat,
+				fmt.Sprintf("Refusing to overwrite binary file: %s", p.FilePath),
+				"Binary files cannot be edited as text; set force=true only if replacing it is intended",
+			).WithDetail("file_path", p.FilePath).WithDetail("file_size", originalSize))
+		}
+		if ifExists == WriteIfExistsAppend {
+			data = append(existing, data...)
+			action = WriteActionAppended
+		} else if base, conflict := checkFileVersion(ctx, fullPath, p.FilePath, existing, true); conflict != nil {
+			// The file changed since it was read; overwriting would lose that change
+			if p.OnConflict != OnConflictMerge {
+				return NewErrorResult(conflict)
+			}
+			merge := patchutils.Merge3(string(base), p.Content, string(existing))
+			if merge.Conflicts > 0 {
+				return NewErrorResult(NewStandardizedError(
+					ErrorCodeFileConflict,
+					fmt.Sprintf("%s changed since it was last read and %d region(s) conflict with your change", p.FilePath, merge.Conflicts),
+					"Read the file again and redo the change on its current content",
+				).WithDetail("file_path", p.FilePath).WithDetail("conflicts", merge.Conflicts))
+			}
+			data = []byte(merge.Content)
+			merged = true
+		}
 	}
 
 	// Write the file
-	if err := os.WriteFile(fullPath, []byte(p.Content), 0644); err != nil {
+	if err := SnapshotFile(ctx, fullPath); err != nil {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeInternalError,
+			fmt.Sprintf("Failed to checkpoint file before writing: %s", p.FilePath),
+			"Check read permissions for the file",
+		).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error()))
+	}
+	if err := fs.WriteFile(fullPath, data, perm); err != nil {
 		return NewErrorResult(NewStandardizedError(
 			ErrorCodePermissionDenied,
 			fmt.Sprintf("Failed to write file: %s", p.FilePath),
 			"Check write permissions for the file and its parent directory",
-		).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error())), nil
+		).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error()))
 	}
 
+	// WriteFile only applies perm to new files, so explicitly update existing ones
+	if fileExisted {
+		if mc, ok := fs.(modeChanger); ok {
+			if info, err := fs.Stat(fullPath); err == nil && info.Mode().Perm() != perm {
+				if err := mc.Chmod(fullPath, perm); err != nil {
+					return NewErrorResult(NewStandardizedError(
+						ErrorCodePermissionDenied,
+						fmt.Sprintf("File written but failed to set permissions: %s", p.FilePath),
+						"Check that the file is owned by the current user",
+					).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error()))
+				}
+			}
+		}
+	}
+
+	RecordFileVersion(ctx, fullPath, data)
+
 	// Get file info for response
-	info, err := os.Stat(fullPath)
+	info, err := fs.Stat(fullPath)
 	if err != nil {
 		return NewErrorResult(NewStandardizedError(
 			ErrorCodeInternalError,
 			fmt.Sprintf("File written successfully but cannot read file info: %s", p.FilePath),
 			"This is unusual - file was written but cannot be accessed immediately",
-		).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error())), nil
+		).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error()))
 	}
 
 	// Prepare response data
 	responseData := map[string]interface{}{
 		"file_path":     p.FilePath,
+		"action":        action,
 		"bytes_written": len(p.Content),
 		"file_size":     info.Size(),
-		"created_dirs":  createDirs && !fileExisted,
-		"overwritten":   fileExisted,
+		"mode":          fmt.Sprintf("%#o", info.Mode().Perm()),
+		"created_dirs":  len(dirsCreated) > 0,
+		"overwritten":   action == WriteActionModified,
+	}
+	if len(dirsCreated) > 0 {
+		responseData["directories_created"] = dirsCreated
+	}
+	if merged {
+		responseData["merged"] = true
 	}
 
-	if fileExisted {
+	switch action {
+	case WriteActionModified:
 		responseData["original_size"] = originalSize
 		responseData["message"] = fmt.Sprintf("Successfully overwrote %s (%d bytes written, was %d bytes)",
 			p.FilePath, len(p.Content), originalSize)
-	} else {
+		if merged {
+			responseData["message"] = fmt.Sprintf("Merged your content into %s, which had changed since it was read (now %d bytes)",
+				p.FilePath, info.Size())
+		}
+	case WriteActionAppended:
+		responseData["original_size"] = originalSize
+		responseData["message"] = fmt.Sprintf("Successfully appended to %s (%d bytes written, now %d bytes)",
+			p.FilePath, len(p.Content), info.Size())
+	default:
 		responseData["message"] = fmt.Sprintf("Successfully created %s (%d bytes written)",
 			p.FilePath, len(p.Content))
 	}
 
-	return NewSuccessResult(responseData), nil
+	return NewSuccessResult(responseData)
+}
+
+// missingDirs lists the ancestors of dir that don't exist yet, outermost first,
+// relative to the workspace root
+func missingDirs(fs FileSystemService, dir, workspaceRoot string) []string {
+	var missing []string
+	for current := dir; !fs.Exists(current); {
+		rel, err := filepath.Rel(workspaceRoot, current)
+		if err != nil {
+			rel = current
+		}
+		missing = append([]string{rel}, missing...)
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+	return missing
 }
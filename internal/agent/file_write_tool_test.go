@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileWriteToolOptions(t *testing.T) {
+	workspace := setupTestWorkspace(t)
+	tool := NewFileWriteTool(workspace)
+
+	write := func(params string) *ToolResult {
+		t.Helper()
+		result, err := tool.Execute(context.Background(), json.RawMessage(params))
+		if err != nil {
+			t.Fatalf("Execute(%s) returned error: %v", params, err)
+		}
+		return result
+	}
+	summary := func(result *ToolResult) map[string]interface{} {
+		t.Helper()
+		if !result.Success {
+			t.Fatalf("Expected success, got: %s", result.Error)
+		}
+		return result.Data.(map[string]interface{})
+	}
+
+	t.Run("create_with_dirs", func(t *testing.T) {
+		data := summary(write(`{"file_path": "a/b/new.txt", "content": "hello"}`))
+		if data["action"] != WriteActionCreated || data["bytes_written"] != 5 {
+			t.Errorf("Unexpected summary: %+v", data)
+		}
+		dirs, _ := data["directories_created"].([]string)
+		if len(dirs) != 2 || dirs[0] != "a" || dirs[1] != filepath.Join("a", "b") {
+			t.Errorf("Expected directories a and a/b to be reported, got %v", data["directories_created"])
+		}
+	})
+
+	t.Run("append", func(t *testing.T) {
+		data := summary(write(`{"file_path": "a/b/new.txt", "content": " world", "if_exists": "append"}`))
+		if data["action"] != WriteActionAppended || data["file_size"] != int64(11) {
+			t.Errorf("Unexpected summary: %+v", data)
+		}
+		content, _ := os.ReadFile(filepath.Join(workspace, "a/b/new.txt"))
+		if string(content) != "hello world" {
+			t.Errorf("Expected appended content, got %q", content)
+		}
+	})
+
+	t.Run("fail_if_exists", func(t *testing.T) {
+		result := write(`{"file_path": "main.go", "content": "x", "if_exists": "fail"}`)
+		if result.Success || result.StandardizedError == nil || result.StandardizedError.Code != ErrorCodeFileAlreadyExists {
+			t.Errorf("Expected FILE_ALREADY_EXISTS, got %+v", result)
+		}
+	})
+
+	t.Run("preserves_executable_bit", func(t *testing.T) {
+		summary(write(`{"file_path": "run.sh", "content": "#!/bin/sh\n", "executable": true}`))
+		data := summary(write(`{"file_path": "run.sh", "content": "#!/bin/sh\necho hi\n"}`))
+		if data["action"] != WriteActionModified {
+			t.Errorf("Expected modified action, got %v", data["action"])
+		}
+		info, err := os.Stat(filepath.Join(workspace, "run.sh"))
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if info.Mode().Perm()&0100 == 0 {
+			t.Errorf("Expected executable bit to be preserved, got %v", info.Mode())
+		}
+
+		summary(write(`{"file_path": "run.sh", "content": "plain", "preserve_mode": false}`))
+		info, _ = os.Stat(filepath.Join(workspace, "run.sh"))
+		if info.Mode().Perm()&0100 != 0 {
+			t.Errorf("Expected executable bit to be dropped with preserve_mode=false, got %v", info.Mode())
+		}
+	})
+}
+
+func TestFileWriteToolProtectsLargeAndBinaryFiles(t *testing.T) {
+	workspace := setupTestWorkspace(t)
+	tool := NewFileWriteToolWithConfig(workspace, FileToolConfig{MaxOverwriteBytes: 16})
+	if err := os.WriteFile(filepath.Join(workspace, "large.txt"), []byte("more than sixteen bytes"), 0600); err != nil {
+		t.Fatalf("Failed to write large file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "logo.png"), []byte("\x89PNG\x00\x01"), 0600); err != nil {
+		t.Fatalf("Failed to write binary file: %v", err)
+	}
+
+	write := func(params string) *ToolResult {
+		t.Helper()
+		result, err := tool.Execute(context.Background(), json.RawMessage(params))
+		if err != nil {
+			t.Fatalf("Execute(%s) returned error: %v", params, err)
+		}
+		return result
+	}
+
+	result := write(`{"file_path": "large.txt", "content": "x"}`)
+	if result.Success || result.StandardizedError.Code != ErrorCodeContentTooLarge {
+		t.Errorf("Expected CONTENT_TOO_LARGE, got %+v", result)
+	}
+	result = write(`{"file_path": "logo.png", "content": "x", "if_exists": "append"}`)
+	if result.Success || result.StandardizedError.Code != ErrorCodeInvalidFileFormat {
+		t.Errorf("Expected INVALID_FILE_FORMAT, got %+v", result)
+	}
+	if content, _ := os.ReadFile(filepath.Join(workspace, "logo.png")); string(content) != "\x89PNG\x00\x01" {
+		t.Errorf("Binary file was modified: %q", content)
+	}
+
+	if result := write(`{"file_path": "large.txt", "content": "x", "force": true}`); !result.Success {
+		t.Errorf("Expected force to overwrite, got: %s", result.Error)
+	}
+	if result := write(`{"file_path": "new.png", "content": "x"}`); !result.Success {
+		t.Errorf("Expected new files to be created, got: %s", result.Error)
+	}
+}
@@ -15,21 +15,28 @@ import (
 type EnhancedGitCommitTool struct {
 	workspaceRoot string
 	auditLogger   *audit.AuditLogger
+	conventions   CommitConventions
 }
 
 func NewEnhancedGitCommitTool(workspaceRoot string, auditLogger *audit.AuditLogger) *EnhancedGitCommitTool {
 	return &EnhancedGitCommitTool{
 		workspaceRoot: workspaceRoot,
 		auditLogger:   auditLogger,
+		conventions:   LoadCommitConventions(workspaceRoot, DefaultCommitConventions()),
 	}
 }
 
+// SetConventions replaces the commit conventions, e.g. with configured ones
+func (t *EnhancedGitCommitTool) SetConventions(conventions CommitConventions) {
+	t.conventions = LoadCommitConventions(t.workspaceRoot, conventions)
+}
+
 func (t *EnhancedGitCommitTool) Name() string {
 	return "git_commit_enhanced"
 }
 
 func (t *EnhancedGitCommitTool) Description() string {
-	return "Enhanced Git commit tool with automated workflows, smart staging, and audit logging. Supports auto-commit patterns and commit message templates."
+	return "Enhanced Git commit tool with automated workflows, smart staging, and audit logging. Supports auto-commit patterns and commit message templates. Ticket IDs from the branch name are added to the message, which must follow the repository's commit conventions."
 }
 
 func (t *EnhancedGitCommitTool) Parameters() json.RawMessage {
@@ -126,6 +133,22 @@ func (t *EnhancedGitCommitTool) Execute(ctx context.Context, params json.RawMess
 		p.CommitMessage = t.formatConventionalCommit(p.CommitType, p.Scope, p.CommitMessage, p.BreakingChange)
 	}
 
+	// Render the repository's template, with the branch tickets, and check
+	// the message against its conventions
+	var tickets []string
+	if strings.TrimSpace(p.CommitMessage) != "" {
+		message, found, failure := applyCommitConventions(ctx, t.conventions, t.workspaceRoot, p.CommitMessage, p.FilesToStage)
+		if failure != nil {
+			if t.auditLogger != nil {
+				t.auditLogger.LogError(audit.OpCommit, "git_commit_enhanced", fmt.Errorf("%s", failure.Error), map[string]interface{}{
+					"commit_message": p.CommitMessage,
+				})
+			}
+			return failure, nil
+		}
+		p.CommitMessage, tickets = message, found
+	}
+
 	// Add co-authors to commit message
 	if len(p.CoAuthors) > 0 {
 		p.CommitMessage = t.addCoAuthors(p.CommitMessage, p.CoAuthors)
@@ -212,6 +235,9 @@ func (t *EnhancedGitCommitTool) Execute(ctx context.Context, params json.RawMess
 	if len(p.CoAuthors) > 0 {
 		responseData["co_authors"] = p.CoAuthors
 	}
+	if len(tickets) > 0 {
+		responseData["tickets"] = tickets
+	}
 
 	return &ToolResult{
 		Success: true,
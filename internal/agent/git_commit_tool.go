@@ -11,6 +11,7 @@ import (
 // GitCommitTool implements Git commit operations
 type GitCommitTool struct {
 	workspaceRoot string
+	conventions   *CommitConventions
 }
 
 func NewGitCommitTool(workspaceRoot string) *GitCommitTool {
@@ -19,6 +20,16 @@ func NewGitCommitTool(workspaceRoot string) *GitCommitTool {
 	}
 }
 
+// NewGitCommitToolWithConventions creates a commit tool that renders and
+// checks messages with the repository's commit conventions
+func NewGitCommitToolWithConventions(workspaceRoot string, conventions CommitConventions) *GitCommitTool {
+	conventions = LoadCommitConventions(workspaceRoot, conventions)
+	return &GitCommitTool{
+		workspaceRoot: workspaceRoot,
+		conventions:   &conventions,
+	}
+}
+
 func (t *GitCommitTool) Name() string {
 	return "git_commit"
 }
@@ -77,6 +88,16 @@ func (t *GitCommitTool) Execute(ctx context.Context, params json.RawMessage) (*T
 		}, nil
 	}
 
+	// Add branch tickets and check the repository's conventions
+	var tickets []string
+	if t.conventions != nil {
+		message, found, failure := applyCommitConventions(ctx, *t.conventions, t.workspaceRoot, p.CommitMessage, p.FilesToStage)
+		if failure != nil {
+			return failure, nil
+		}
+		p.CommitMessage, tickets = message, found
+	}
+
 	// Stage files
 	if err := t.stageFiles(p.FilesToStage); err != nil {
 		return &ToolResult{
@@ -111,13 +132,17 @@ func (t *GitCommitTool) Execute(ctx context.Context, params json.RawMessage) (*T
 		}, nil
 	}
 
+	data := map[string]interface{}{
+		"commit_hash":    commitHash,
+		"commit_message": p.CommitMessage,
+		"files_staged":   len(p.FilesToStage),
+	}
+	if len(tickets) > 0 {
+		data["tickets"] = tickets
+	}
 	return &ToolResult{
 		Success: true,
-		Data: map[string]interface{}{
-			"commit_hash":    commitHash,
-			"commit_message": p.CommitMessage,
-			"files_staged":   len(p.FilesToStage),
-		},
+		Data:    data,
 	}, nil
 }
 
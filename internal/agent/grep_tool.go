@@ -0,0 +1,451 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultGrepMaxResults   = 50
+	maxGrepResults          = 500
+	defaultGrepContextLines = 2
+	maxGrepContextLines     = 10
+	// maxGrepLineLength truncates very long matched lines (e.g. minified code)
+	maxGrepLineLength = 300
+)
+
+// Search engines accepted by grep_codebase
+const (
+	GrepEngineAuto    = "auto"
+	GrepEngineGo      = "go"
+	GrepEngineRipgrep = "ripgrep"
+)
+
+// GrepTool performs exact text or regex search across the workspace
+type GrepTool struct {
+	workspaceRoot string
+	validator     *ToolValidator
+	rgPath        string // Path to the rg binary, empty when unavailable
+}
+
+// NewGrepTool creates a new grep tool, using ripgrep when it is installed
+func NewGrepTool(workspaceRoot string) *GrepTool {
+	rgPath, _ := exec.LookPath("rg")
+	return &GrepTool{
+		workspaceRoot: workspaceRoot,
+		validator:     NewToolValidator(workspaceRoot),
+		rgPath:        rgPath,
+	}
+}
+
+func (t *GrepTool) Name() string {
+	return "grep_codebase"
+}
+
+func (t *GrepTool) Description() string {
+	return `Searches the workspace for exact text or a regular expression and returns each matching line with its file, line number and surrounding context.
+
+USAGE EXAMPLES:
+- grep_codebase({"pattern": "NewToolFactory("})
+- grep_codebase({"pattern": "func \\w+Handler", "regex": true, "include": ["*.go"], "path": "internal"})
+- grep_codebase({"pattern": "todo", "case_sensitive": false, "exclude": ["*_test.go"], "context_lines": 0})
+
+IMPORTANT NOTES:
+- Use this for exact identifiers and strings; use codebase_search for fuzzy, relevance-ranked lookups
+- Matching is line-based; patterns cannot span lines
+- Results are paginated by max_results (default 50, max 500); when truncated is true, pass next_page_token as page_token with the same parameters for the next page, or narrow path/include`
+}
+
+func (t *GrepTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"pattern": {
+				"type": "string",
+				"description": "Text or regular expression to search for",
+				"minLength": 1
+			},
+			"regex": {
+				"type": "boolean",
+				"description": "Treat pattern as a regular expression (Go RE2 syntax)",
+				"default": false
+			},
+			"case_sensitive": {
+				"type": "boolean",
+				"description": "Match case exactly",
+				"default": true
+			},
+			"path": {
+				"type": "string",
+				"description": "Directory to search, relative to workspace root",
+				"default": "."
+			},
+			"include": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "Only search files matching these globs, e.g. [\"*.go\", \"internal/**/*.ts\"]"
+			},
+			"exclude": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "Skip files matching these globs, e.g. [\"*_test.go\"]"
+			},
+			"context_lines": {
+				"type": "integer",
+				"description": "Lines of context before and after each match",
+				"minimum": 0,
+				"maximum": 10,
+				"default": 2
+			},
+			"max_results": {
+				"type": "integer",
+				"description": "Maximum number of matches to return",
+				"minimum": 1,
+				"maximum": 500,
+				"default": 50
+			},
+			"engine": {
+				"type": "string",
+				"description": "Search engine: auto uses ripgrep when installed, otherwise the built-in engine",
+				"enum": ["auto", "go", "ripgrep"],
+				"default": "auto"
+			},
+			"page_token": {
+				"type": "string",
+				"description": "next_page_token of the previous result, to fetch the next page of matches"
+			}
+		},
+		"required": ["pattern"],
+		"additionalProperties": false
+	}`)
+}
+
+// GrepParams are the parameters accepted by grep_codebase
+type GrepParams struct {
+	Pattern       string   `json:"pattern"`
+	Regex         bool     `json:"regex,omitempty"`
+	CaseSensitive *bool    `json:"case_sensitive,omitempty"`
+	Path          string   `json:"path,omitempty"`
+	Include       []string `json:"include,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	ContextLines  *int     `json:"context_lines,omitempty"`
+	MaxResults    int      `json:"max_results,omitempty"`
+	Engine        string   `json:"engine,omitempty"`
+	PageToken     string   `json:"page_token,omitempty"`
+}
+
+// GrepMatch is a single matching line with its surrounding context
+type GrepMatch struct {
+	File   string   `json:"file"`
+	Line   int      `json:"line"`
+	Column int      `json:"column"`
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// grepSearch holds the resolved options for one search
+type grepSearch struct {
+	re           *regexp.Regexp
+	dir          string
+	include      []string
+	exclude      []string
+	contextLines int
+	maxResults   int
+	skip         int // Matches of the previous pages
+}
+
+func (t *GrepTool) Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error) {
+	if err := t.validator.ValidateJSONSchema(params, t.Parameters()); err != nil {
+		return NewErrorResult(err.(*StandardizedToolError)), nil
+	}
+
+	var p GrepParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeInvalidParameters,
+			"Failed to parse parameters",
+			"Ensure all parameters are properly formatted JSON",
+		).WithDetail("parse_error", err.Error())), nil
+	}
+
+	search := grepSearch{
+		dir:          p.Path,
+		include:      p.Include,
+		exclude:      p.Exclude,
+		contextLines: defaultGrepContextLines,
+		maxResults:   p.MaxResults,
+	}
+	if search.dir == "" {
+		search.dir = "."
+	}
+	if err := t.validator.ValidateDirectoryExists(search.dir); err != nil {
+		return NewErrorResult(err.(*StandardizedToolError)), nil
+	}
+	if p.ContextLines != nil {
+		search.contextLines = min(max(*p.ContextLines, 0), maxGrepContextLines)
+	}
+	if search.maxResults <= 0 {
+		search.maxResults = defaultGrepMaxResults
+	}
+	search.maxResults = min(search.maxResults, maxGrepResults)
+
+	caseSensitive := p.CaseSensitive == nil || *p.CaseSensitive
+	re, err := compileSearchPattern(p.Pattern, p.Regex, caseSensitive)
+	if err != nil {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeInvalidParameters,
+			fmt.Sprintf("Invalid regular expression: %v", err),
+			"Check the pattern syntax (Go RE2) or set regex=false for a literal search",
+		).WithDetail("pattern", p.Pattern)), nil
+	}
+	search.re = re
+
+	query := p
+	query.MaxResults, query.PageToken = 0, ""
+	queryID := pageQuery(t.Name(), query)
+	if search.skip, err = decodePageToken(p.PageToken, queryID); err != nil {
+		return NewErrorResult(err.(*StandardizedToolError)), nil
+	}
+
+	engine := p.Engine
+	if engine == "" || engine == GrepEngineAuto {
+		engine = GrepEngineGo
+		if t.rgPath != "" {
+			engine = GrepEngineRipgrep
+		}
+	}
+	if engine == GrepEngineRipgrep && t.rgPath == "" {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeCommandNotFound,
+			"ripgrep (rg) is not installed",
+			"Use engine \"go\" or \"auto\" to search with the built-in engine",
+		)), nil
+	}
+
+	var matches []GrepMatch
+	var filesSearched int
+	var truncated bool
+	if engine == GrepEngineRipgrep {
+		matches, truncated, err = t.searchRipgrep(ctx, search, p.Pattern, p.Regex, caseSensitive)
+		filesSearched = -1 // ripgrep does not report files without matches
+	} else {
+		matches, filesSearched, truncated, err = t.searchGo(ctx, search)
+	}
+	if err != nil {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeCommandFailed,
+			fmt.Sprintf("Search failed: %v", err),
+			"Retry with a narrower path or simpler pattern",
+		).WithDetail("engine", engine)), nil
+	}
+
+	files := make(map[string]bool)
+	for _, m := range matches {
+		files[m.File] = true
+	}
+
+	data := map[string]interface{}{
+		"matches":       matches,
+		"total_matches": len(matches),
+		"files_matched": len(files),
+		"truncated":     truncated,
+		"engine":        engine,
+	}
+	if truncated {
+		data["next_page_token"] = encodePageToken(queryID, search.skip+len(matches))
+	}
+	if filesSearched >= 0 {
+		data["files_searched"] = filesSearched
+	}
+	return NewSuccessResult(data), nil
+}
+
+// searchGo searches with the built-in regexp engine
+func (t *GrepTool) searchGo(ctx context.Context, s grepSearch) ([]GrepMatch, int, bool, error) {
+	var matches []GrepMatch
+	filesSearched := 0
+	skipped := 0
+	truncated := false
+
+	err := walkTextFiles(osFileSystem{}, t.workspaceRoot, s.dir, s.include, func(relPath string, content []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(s.exclude) > 0 && matchesAnyGlob(s.exclude, relPath) {
+			return nil
+		}
+		filesSearched++
+
+		lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+		for i, line := range lines {
+			loc := s.re.FindStringIndex(line)
+			if loc == nil {
+				continue
+			}
+			if skipped < s.skip {
+				skipped++
+				continue
+			}
+			if len(matches) >= s.maxResults {
+				truncated = true
+				return errStopWalk
+			}
+
+			match := GrepMatch{
+				File:   relPath,
+				Line:   i + 1,
+				Column: loc[0] + 1,
+				Text:   truncateGrepLine(line),
+			}
+			if s.contextLines > 0 {
+				start := max(0, i-s.contextLines)
+				end := min(len(lines), i+1+s.contextLines)
+				match.Before = truncateGrepLines(lines[start:i])
+				match.After = truncateGrepLines(lines[i+1 : end])
+			}
+			matches = append(matches, match)
+		}
+		return nil
+	})
+	return matches, filesSearched, truncated, err
+}
+
+// rgMessage is one line of `rg --json` output
+type rgMessage struct {
+	Type string `json:"type"`
+	Data struct {
+		Path struct {
+			Text string `json:"text"`
+		} `json:"path"`
+		Lines struct {
+			Text string `json:"text"`
+		} `json:"lines"`
+		LineNumber int `json:"line_number"`
+		Submatches []struct {
+			Start int `json:"start"`
+		} `json:"submatches"`
+	} `json:"data"`
+}
+
+// searchRipgrep searches with the rg binary and converts its JSON output to matches
+func (t *GrepTool) searchRipgrep(ctx context.Context, s grepSearch, pattern string, isRegex, caseSensitive bool) ([]GrepMatch, bool, error) {
+	args := []string{"--json", "--sort", "path", "--max-filesize", strconv.Itoa(maxScannedFileSize),
+		"-C", strconv.Itoa(s.contextLines)}
+	if !isRegex {
+		args = append(args, "--fixed-strings")
+	}
+	if !caseSensitive {
+		args = append(args, "--ignore-case")
+	}
+	for _, glob := range s.include {
+		args = append(args, "--glob", glob)
+	}
+	for _, glob := range s.exclude {
+		args = append(args, "--glob", "!"+glob)
+	}
+	args = append(args, "--", pattern, s.dir)
+
+	cmd := exec.CommandContext(ctx, t.rgPath, args...) // #nosec G204 - arguments are passed directly, not through a shell
+	cmd.Dir = t.workspaceRoot
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		// Exit status 1 means no matches
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+			return nil, false, fmt.Errorf("rg: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	}
+
+	type fileLine struct {
+		file string
+		line int
+	}
+	lineText := make(map[fileLine]string)
+	var matches []GrepMatch
+	skipped := 0
+	truncated := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var msg rgMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.Type != "match" && msg.Type != "context" {
+			continue
+		}
+
+		file := filepath.ToSlash(filepath.Clean(msg.Data.Path.Text))
+		text := strings.TrimRight(msg.Data.Lines.Text, "\r\n")
+		lineText[fileLine{file, msg.Data.LineNumber}] = text
+
+		if msg.Type == "match" {
+			if skipped < s.skip {
+				skipped++
+				continue
+			}
+			if len(matches) >= s.maxResults {
+				truncated = true
+				continue
+			}
+			column := 1
+			if len(msg.Data.Submatches) > 0 {
+				column = msg.Data.Submatches[0].Start + 1
+			}
+			matches = append(matches, GrepMatch{
+				File:   file,
+				Line:   msg.Data.LineNumber,
+				Column: column,
+				Text:   truncateGrepLine(text),
+			})
+		}
+	}
+
+	// Attach context now that all surrounding lines have been seen
+	for i := range matches {
+		m := &matches[i]
+		for l := m.Line - s.contextLines; l < m.Line; l++ {
+			if text, ok := lineText[fileLine{m.File, l}]; ok {
+				m.Before = append(m.Before, truncateGrepLine(text))
+			}
+		}
+		for l := m.Line + 1; l <= m.Line+s.contextLines; l++ {
+			if text, ok := lineText[fileLine{m.File, l}]; ok {
+				m.After = append(m.After, truncateGrepLine(text))
+			}
+		}
+	}
+
+	return matches, truncated, nil
+}
+
+func truncateGrepLine(line string) string {
+	if len(line) > maxGrepLineLength {
+		cut := maxGrepLineLength
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut-- // Don't split a multi-byte character
+		}
+		return line[:cut] + "..."
+	}
+	return line
+}
+
+func truncateGrepLines(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = truncateGrepLine(line)
+	}
+	return out
+}
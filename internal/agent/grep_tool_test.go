@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGrepTool(t *testing.T) {
+	workspace := setupTestWorkspace(t)
+	if err := os.WriteFile(filepath.Join(workspace, "src/utils_test.go"), []byte("package src\n\n// Helper is tested here\n"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	engines := []string{GrepEngineGo}
+	if NewGrepTool(workspace).rgPath != "" {
+		engines = append(engines, GrepEngineRipgrep)
+	}
+
+	for _, engine := range engines {
+		tool := NewGrepTool(workspace)
+		run := func(params map[string]interface{}) map[string]interface{} {
+			t.Helper()
+			params["engine"] = engine
+			raw, _ := json.Marshal(params)
+			result, err := tool.Execute(context.Background(), raw)
+			if err != nil {
+				t.Fatalf("[%s] Execute returned error: %v", engine, err)
+			}
+			if !result.Success {
+				t.Fatalf("[%s] Expected success, got: %s", engine, result.Error)
+			}
+			return result.Data.(map[string]interface{})
+		}
+
+		data := run(map[string]interface{}{"pattern": "func Helper()", "context_lines": 1})
+		matches := data["matches"].([]GrepMatch)
+		if len(matches) != 1 {
+			t.Fatalf("[%s] Expected 1 match, got %+v", engine, matches)
+		}
+		m := matches[0]
+		if m.File != "src/utils.go" || m.Line != 3 || m.Column != 1 {
+			t.Errorf("[%s] Unexpected match location: %+v", engine, m)
+		}
+		if len(m.Before) != 1 || len(m.After) != 1 || m.After[0] != "\treturn \"helper function\"" {
+			t.Errorf("[%s] Unexpected context: before=%q after=%q", engine, m.Before, m.After)
+		}
+
+		// Case-insensitive regex with an exclude filter
+		data = run(map[string]interface{}{"pattern": "helper\\b", "regex": true, "case_sensitive": false, "exclude": []string{"*_test.go"}, "context_lines": 0})
+		for _, m := range data["matches"].([]GrepMatch) {
+			if m.File == "src/utils_test.go" {
+				t.Errorf("[%s] Excluded file returned: %+v", engine, m)
+			}
+		}
+		if data["total_matches"] != 2 {
+			t.Errorf("[%s] Expected 2 case-insensitive matches, got %v", engine, data["total_matches"])
+		}
+
+		// Result cap
+		data = run(map[string]interface{}{"pattern": "e", "max_results": 2})
+		if data["total_matches"] != 2 || data["truncated"] != true {
+			t.Errorf("[%s] Expected truncated results capped at 2, got %v (truncated=%v)", engine, data["total_matches"], data["truncated"])
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/depgraph"
+)
+
+// ImpactOfChangeParams are the parameters of impact_of_change
+type ImpactOfChangeParams struct {
+	File   string `json:"file" description:"File to change, relative to the workspace root; required for JavaScript and TypeScript symbols"`
+	Symbol string `json:"symbol" description:"Top-level function, type, constant or variable to change, or a method as Type.Method; omit to assess the whole file"`
+}
+
+// NewImpactTool creates the impact_of_change tool, which lists what depends
+// on a file or symbol of the workspace: the references to it, the packages
+// importing its package, the tests to run and how public it is
+func NewImpactTool(workspaceRoot string) Tool {
+	return NewTypedTool("impact_of_change", `Assesses what may break when a file or symbol changes, from the import graph of the workspace and the references to the symbol: the packages depending on it directly and transitively, the places using it, the test files and test packages to run, and its exposure (public API, exported within the module, or private to its package). Supports Go, JavaScript and TypeScript. Use it to scope a change before planning it, and to check that a change is covered by tests before approving it.
+
+USAGE EXAMPLES:
+- impact_of_change({"file": "internal/config/config.go", "symbol": "Load"})
+- impact_of_change({"symbol": "Registry.Register"})
+- impact_of_change({"file": "src/api/client.ts"})`,
+		func(ctx context.Context, params ImpactOfChangeParams) (interface{}, error) {
+			if params.File == "" && params.Symbol == "" {
+				return nil, NewStandardizedError(ErrorCodeMissingParameter, "a file or a symbol is required", "Give the file to change, the symbol to change, or both")
+			}
+			file := ""
+			if params.File != "" {
+				if err := NewToolValidator(workspaceRoot).ValidateFileExists(params.File); err != nil {
+					return nil, err
+				}
+				file = filepath.ToSlash(filepath.Clean(params.File))
+			}
+
+			impact, err := depgraph.AnalyzeImpact(workspaceRoot, file, params.Symbol)
+			var ambiguous *depgraph.AmbiguousSymbolError
+			switch {
+			case errors.As(err, &ambiguous):
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, err.Error(), "Give the file declaring the symbol you mean").WithDetail("files", ambiguous.Files)
+			case errors.Is(err, depgraph.ErrSymbolNotFound):
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, err.Error(), "Check the spelling of the symbol, write methods as Type.Method, or use grep_codebase to find where it is declared")
+			case err != nil:
+				return nil, fmt.Errorf("failed to analyze the impact of the change: %w", err)
+			}
+			return map[string]interface{}{
+				"summary": impactSummary(impact),
+				"impact":  impact,
+			}, nil
+		})
+}
+
+// impactSummary describes an impact in a sentence
+func impactSummary(impact *depgraph.Impact) string {
+	target := impact.File
+	if impact.Symbol != "" {
+		target = fmt.Sprintf("%s %s (%s:%d)", impact.Kind, impact.Symbol, impact.File, impact.Line)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s has %s exposure", target, impact.Exposure)
+	if impact.Symbol != "" {
+		fmt.Fprintf(&b, " and %d references", impact.ReferenceCount)
+		if impact.ByName {
+			b.WriteString(" matched by name")
+		}
+	}
+	fmt.Fprintf(&b, "; %d packages depend on it directly and %d transitively; %d test files are affected", len(impact.DirectDependents), len(impact.TransitiveDependents), len(impact.AffectedTests))
+	if len(impact.TestPackages) > 0 {
+		fmt.Fprintf(&b, "; run the tests of %s", strings.Join(impact.TestPackages, " "))
+	}
+	return b.String() + "."
+}
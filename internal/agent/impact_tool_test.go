@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/depgraph"
+)
+
+func TestImpactTool(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":            "module example.com/shop\n\ngo 1.22\n",
+		"cart/cart.go":      "package cart\n\n// Total sums the cart\nfunc Total() int { return 0 }\n",
+		"cart/cart_test.go": "package cart\n\nimport \"testing\"\n\nfunc TestTotal(t *testing.T) { Total() }\n",
+		"api/checkout.go":   "package api\n\nimport \"example.com/shop/cart\"\n\nfunc Checkout() int { return cart.Total() }\n",
+		"legacy/cart.go":    "package legacy\n\nfunc Total() int { return 1 }\n",
+	}
+	for name, content := range files {
+		full := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tool := NewImpactTool(root)
+
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{"file": "cart/cart.go", "symbol": "Total"}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	data := result.Data.(map[string]interface{})
+	impact := data["impact"].(*depgraph.Impact)
+	if impact.Exposure != depgraph.ExposurePublic || impact.ReferenceCount != 2 || len(impact.DirectDependents) != 1 {
+		t.Errorf("expected a public function used by api, got %+v", impact)
+	}
+	if summary := data["summary"].(string); !strings.Contains(summary, "func Total (cart/cart.go:4) has public exposure and 2 references") || !strings.Contains(summary, "run the tests of ./cart") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+
+	for name, params := range map[string]string{
+		"missing parameters": `{}`,
+		"unknown symbol":     `{"file": "cart/cart.go", "symbol": "Discount"}`,
+		"ambiguous symbol":   `{"symbol": "Total"}`,
+		"missing file":       `{"file": "cart/missing.go"}`,
+		"outside workspace":  `{"file": "../cart.go"}`,
+	} {
+		result, err := tool.Execute(context.Background(), json.RawMessage(params))
+		if err != nil || result.Success || result.StandardizedError == nil {
+			t.Errorf("%s: expected a standardized error, got %v %+v", name, err, result)
+		}
+	}
+}
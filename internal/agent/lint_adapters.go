@@ -0,0 +1,342 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LintAdapter knows how to run a specific linter with machine-readable output
+// and how to turn that output into LintIssues
+type LintAdapter interface {
+	// Name returns the linter name used in LintIssue.Linter
+	Name() string
+	// Command returns the executable and arguments that produce structured output
+	Command(targetPath string, fix bool) (string, []string)
+	// StructuredArgs rewrites a user-configured command so that it emits structured output
+	StructuredArgs(args []string) []string
+	// Parse converts structured linter output into issues
+	Parse(output string) ([]LintIssue, error)
+}
+
+// LintAdapterForCommand returns the adapter matching a configured lint command,
+// or nil when the linter is not one with a structured adapter
+func LintAdapterForCommand(command string) LintAdapter {
+	fields := strings.Fields(command)
+	for _, field := range fields {
+		switch strings.TrimSuffix(filepath.Base(field), ".exe") {
+		case "golangci-lint":
+			return &GolangciLintAdapter{}
+		case "eslint":
+			return &ESLintAdapter{}
+		case "ruff":
+			return &RuffAdapter{}
+		}
+	}
+	return nil
+}
+
+// LintAdapterByName returns the adapter for a linter name used by run_linter
+func LintAdapterByName(name string) LintAdapter {
+	switch name {
+	case "golangci-lint":
+		return &GolangciLintAdapter{}
+	case "eslint":
+		return &ESLintAdapter{}
+	case "ruff":
+		return &RuffAdapter{}
+	}
+	return nil
+}
+
+// GolangciLintAdapter handles `golangci-lint run --out-format=json`
+type GolangciLintAdapter struct{}
+
+func (a *GolangciLintAdapter) Name() string { return "golangci-lint" }
+
+func (a *GolangciLintAdapter) Command(targetPath string, fix bool) (string, []string) {
+	args := []string{"run"}
+	if fix {
+		args = append(args, "--fix")
+	}
+	return "golangci-lint", append(args, "--out-format=json", targetPath)
+}
+
+func (a *GolangciLintAdapter) StructuredArgs(args []string) []string {
+	return withFlag(args, "--out-format", "--out-format=json")
+}
+
+type golangciReport struct {
+	Issues []struct {
+		FromLinter string `json:"FromLinter"`
+		Text       string `json:"Text"`
+		Severity   string `json:"Severity"`
+		Pos        struct {
+			Filename string `json:"Filename"`
+			Line     int    `json:"Line"`
+			Column   int    `json:"Column"`
+		} `json:"Pos"`
+	} `json:"Issues"`
+}
+
+func (a *GolangciLintAdapter) Parse(output string) ([]LintIssue, error) {
+	var report golangciReport
+	if err := json.Unmarshal([]byte(extractJSON(output, '{', '}')), &report); err != nil {
+		return nil, fmt.Errorf("failed to parse golangci-lint JSON output: %w", err)
+	}
+
+	issues := make([]LintIssue, 0, len(report.Issues))
+	for _, i := range report.Issues {
+		severity := strings.ToLower(i.Severity)
+		if severity == "" {
+			severity = "warning"
+			if i.FromLinter == "typecheck" || i.FromLinter == "govet" {
+				severity = "error"
+			}
+		}
+		issues = append(issues, LintIssue{
+			File:     filepath.ToSlash(i.Pos.Filename),
+			Line:     i.Pos.Line,
+			Column:   i.Pos.Column,
+			Severity: severity,
+			Rule:     i.FromLinter,
+			Message:  i.Text,
+			Linter:   a.Name(),
+		})
+	}
+	return issues, nil
+}
+
+// ESLintAdapter handles `eslint -f json`
+type ESLintAdapter struct{}
+
+func (a *ESLintAdapter) Name() string { return "eslint" }
+
+func (a *ESLintAdapter) Command(targetPath string, fix bool) (string, []string) {
+	args := []string{"eslint", "-f", "json"}
+	if fix {
+		args = append(args, "--fix")
+	}
+	return "npx", append(args, targetPath)
+}
+
+func (a *ESLintAdapter) StructuredArgs(args []string) []string {
+	for i, arg := range args {
+		if (arg == "-f" || arg == "--format") && i+1 < len(args) {
+			out := append([]string{}, args...)
+			out[i+1] = "json"
+			return out
+		}
+	}
+	return withFlag(args, "--format=", "--format=json")
+}
+
+type eslintFileResult struct {
+	FilePath string `json:"filePath"`
+	Messages []struct {
+		RuleID   string `json:"ruleId"`
+		Severity int    `json:"severity"`
+		Message  string `json:"message"`
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+	} `json:"messages"`
+}
+
+func (a *ESLintAdapter) Parse(output string) ([]LintIssue, error) {
+	var results []eslintFileResult
+	if err := json.Unmarshal([]byte(extractJSON(output, '[', ']')), &results); err != nil {
+		return nil, fmt.Errorf("failed to parse eslint JSON output: %w", err)
+	}
+
+	var issues []LintIssue
+	for _, file := range results {
+		for _, m := range file.Messages {
+			severity := "warning"
+			if m.Severity >= 2 {
+				severity = "error"
+			}
+			issues = append(issues, LintIssue{
+				File:     filepath.ToSlash(file.FilePath),
+				Line:     m.Line,
+				Column:   m.Column,
+				Severity: severity,
+				Rule:     m.RuleID,
+				Message:  m.Message,
+				Linter:   a.Name(),
+			})
+		}
+	}
+	return issues, nil
+}
+
+// RuffAdapter handles `ruff check --output-format=json`
+type RuffAdapter struct{}
+
+func (a *RuffAdapter) Name() string { return "ruff" }
+
+func (a *RuffAdapter) Command(targetPath string, fix bool) (string, []string) {
+	args := []string{"check", "--output-format=json"}
+	if fix {
+		args = append(args, "--fix")
+	}
+	return "ruff", append(args, targetPath)
+}
+
+func (a *RuffAdapter) StructuredArgs(args []string) []string {
+	return withFlag(args, "--output-format", "--output-format=json")
+}
+
+type ruffDiagnostic struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Filename string `json:"filename"`
+	Location struct {
+		Row    int `json:"row"`
+		Column int `json:"column"`
+	} `json:"location"`
+}
+
+func (a *RuffAdapter) Parse(output string) ([]LintIssue, error) {
+	var diagnostics []ruffDiagnostic
+	if err := json.Unmarshal([]byte(extractJSON(output, '[', ']')), &diagnostics); err != nil {
+		return nil, fmt.Errorf("failed to parse ruff JSON output: %w", err)
+	}
+
+	issues := make([]LintIssue, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		// Ruff reports syntax errors without a rule code
+		severity := "warning"
+		if d.Code == "" || strings.HasPrefix(d.Code, "E9") || strings.HasPrefix(d.Code, "F8") {
+			severity = "error"
+		}
+		issues = append(issues, LintIssue{
+			File:     filepath.ToSlash(d.Filename),
+			Line:     d.Location.Row,
+			Column:   d.Location.Column,
+			Severity: severity,
+			Rule:     d.Code,
+			Message:  d.Message,
+			Linter:   a.Name(),
+		})
+	}
+	return issues, nil
+}
+
+// withFlag replaces any argument starting with prefix by flag, or appends flag
+// when none is present
+func withFlag(args []string, prefix, flag string) []string {
+	out := make([]string, 0, len(args)+1)
+	replaced := false
+	for _, arg := range args {
+		if strings.HasPrefix(arg, prefix) {
+			if !replaced {
+				out = append(out, flag)
+				replaced = true
+			}
+			continue
+		}
+		out = append(out, arg)
+	}
+	if !replaced {
+		out = append(out, flag)
+	}
+	return out
+}
+
+// extractJSON trims log noise printed around a JSON document
+func extractJSON(output string, open, close byte) string {
+	start := strings.IndexByte(output, open)
+	end := strings.LastIndexByte(output, close)
+	if start < 0 || end < start {
+		return output
+	}
+	return output[start : end+1]
+}
+
+// LintFindingTracker de-duplicates findings across iterations of an auto-fix
+// loop. Findings that have already been handed to a fixer maxAttempts times
+// are suppressed so the loop does not keep retrying the same issue.
+type LintFindingTracker struct {
+	maxAttempts int
+	attempts    map[string]int
+}
+
+// NewLintFindingTracker creates a tracker; maxAttempts <= 0 defaults to 2
+func NewLintFindingTracker(maxAttempts int) *LintFindingTracker {
+	if maxAttempts <= 0 {
+		maxAttempts = 2
+	}
+	return &LintFindingTracker{
+		maxAttempts: maxAttempts,
+		attempts:    make(map[string]int),
+	}
+}
+
+// lintFindingKey identifies a finding independently of which linter pass
+// reported it. The line is left out: fixes elsewhere in the file move a
+// finding that is still there, and it must not count as a new one.
+func lintFindingKey(issue LintIssue) string {
+	return fmt.Sprintf("%s:%s:%s", filepath.ToSlash(filepath.Clean(issue.File)), issue.Rule, issue.Message)
+}
+
+// lintFindingLocationKey identifies a finding within one linter pass, where
+// the same finding on two lines is two findings
+func lintFindingLocationKey(issue LintIssue) string {
+	return fmt.Sprintf("%d:%s", issue.Line, lintFindingKey(issue))
+}
+
+// Select returns unique findings that are still worth attempting to fix, and
+// those suppressed because earlier attempts did not resolve them
+func (t *LintFindingTracker) Select(issues []LintIssue) (actionable, suppressed []LintIssue) {
+	seen := make(map[string]bool, len(issues))
+	for _, issue := range issues {
+		if seen[lintFindingLocationKey(issue)] {
+			continue
+		}
+		seen[lintFindingLocationKey(issue)] = true
+
+		if t.attempts[lintFindingKey(issue)] >= t.maxAttempts {
+			suppressed = append(suppressed, issue)
+		} else {
+			actionable = append(actionable, issue)
+		}
+	}
+
+	sort.SliceStable(actionable, func(i, j int) bool {
+		if actionable[i].File != actionable[j].File {
+			return actionable[i].File < actionable[j].File
+		}
+		return actionable[i].Line < actionable[j].Line
+	})
+	return actionable, suppressed
+}
+
+// RecordAttempt notes that a fix was attempted for the given findings
+func (t *LintFindingTracker) RecordAttempt(issues []LintIssue) {
+	// A finding on several lines is attempted once per fix
+	recorded := make(map[string]bool, len(issues))
+	for _, issue := range issues {
+		key := lintFindingKey(issue)
+		if !recorded[key] {
+			recorded[key] = true
+			t.attempts[key]++
+		}
+	}
+}
+
+// FormatLintIssue renders an issue in the conventional file:line:col form
+func FormatLintIssue(issue LintIssue) string {
+	location := issue.File
+	if issue.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, issue.Line)
+		if issue.Column > 0 {
+			location = fmt.Sprintf("%s:%d", location, issue.Column)
+		}
+	}
+	if issue.Rule != "" {
+		return fmt.Sprintf("%s: %s (%s/%s)", location, issue.Message, issue.Linter, issue.Rule)
+	}
+	return fmt.Sprintf("%s: %s (%s)", location, issue.Message, issue.Linter)
+}
@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintAdaptersParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		output  string
+		want    LintIssue
+	}{
+		{
+			name:    "golangci-lint",
+			command: "golangci-lint run ./...",
+			output: `level=warning msg="[runner] some linters are disabled"
+{"Issues":[{"FromLinter":"errcheck","Text":"Error return value of ` + "`f.Close`" + ` is not checked","Severity":"","Pos":{"Filename":"internal/io.go","Line":42,"Column":10}}],"Report":{}}`,
+			want: LintIssue{File: "internal/io.go", Line: 42, Column: 10, Severity: "warning", Rule: "errcheck", Message: "Error return value of `f.Close` is not checked", Linter: "golangci-lint"},
+		},
+		{
+			name:    "eslint",
+			command: "npx eslint src",
+			output:  `[{"filePath":"/app/src/index.js","messages":[{"ruleId":"no-unused-vars","severity":2,"message":"'x' is defined but never used.","line":3,"column":7}]}]`,
+			want:    LintIssue{File: "/app/src/index.js", Line: 3, Column: 7, Severity: "error", Rule: "no-unused-vars", Message: "'x' is defined but never used.", Linter: "eslint"},
+		},
+		{
+			name:    "ruff",
+			command: "ruff check .",
+			output:  `[{"code":"F401","message":"` + "`os`" + ` imported but unused","filename":"app/main.py","location":{"row":1,"column":8},"end_location":{"row":1,"column":10}}]`,
+			want:    LintIssue{File: "app/main.py", Line: 1, Column: 8, Severity: "warning", Rule: "F401", Message: "`os` imported but unused", Linter: "ruff"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := LintAdapterForCommand(tt.command)
+			if adapter == nil || adapter.Name() != tt.name {
+				t.Fatalf("Expected %s adapter for %q, got %v", tt.name, tt.command, adapter)
+			}
+
+			issues, err := adapter.Parse(tt.output)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(issues) != 1 || !reflect.DeepEqual(issues[0], tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, issues)
+			}
+		})
+	}
+
+	if adapter := LintAdapterForCommand("go vet ./..."); adapter != nil {
+		t.Errorf("Expected no adapter for go vet, got %s", adapter.Name())
+	}
+}
+
+func TestLintAdapterStructuredArgs(t *testing.T) {
+	golangci := (&GolangciLintAdapter{}).StructuredArgs([]string{"golangci-lint", "run", "--out-format=line-number", "./..."})
+	if want := []string{"golangci-lint", "run", "--out-format=json", "./..."}; !reflect.DeepEqual(golangci, want) {
+		t.Errorf("Expected %v, got %v", want, golangci)
+	}
+
+	eslint := (&ESLintAdapter{}).StructuredArgs([]string{"eslint", "-f", "stylish", "src"})
+	if want := []string{"eslint", "-f", "json", "src"}; !reflect.DeepEqual(eslint, want) {
+		t.Errorf("Expected %v, got %v", want, eslint)
+	}
+}
+
+func TestLintFindingTracker(t *testing.T) {
+	unused := LintIssue{File: "a.go", Line: 3, Rule: "unused", Message: "x is unused", Linter: "golangci-lint"}
+	errcheck := LintIssue{File: "a.go", Line: 1, Rule: "errcheck", Message: "unchecked error", Linter: "golangci-lint"}
+
+	tracker := NewLintFindingTracker(1)
+
+	actionable, suppressed := tracker.Select([]LintIssue{unused, unused, errcheck})
+	if len(actionable) != 2 || len(suppressed) != 0 {
+		t.Fatalf("Expected 2 unique actionable findings, got %d actionable, %d suppressed", len(actionable), len(suppressed))
+	}
+	if actionable[0].Line != 1 {
+		t.Errorf("Expected findings sorted by location, got %+v", actionable)
+	}
+	tracker.RecordAttempt([]LintIssue{unused})
+
+	actionable, suppressed = tracker.Select([]LintIssue{unused, errcheck})
+	if len(actionable) != 1 || actionable[0].Rule != "errcheck" {
+		t.Errorf("Expected only errcheck to remain actionable, got %+v", actionable)
+	}
+	if len(suppressed) != 1 || suppressed[0].Rule != "unused" {
+		t.Errorf("Expected unused to be suppressed, got %+v", suppressed)
+	}
+
+	// A fix elsewhere in the file moves the finding, which is still the same one
+	moved := unused
+	moved.Line = 7
+	actionable, suppressed = tracker.Select([]LintIssue{moved, errcheck})
+	if len(actionable) != 1 || len(suppressed) != 1 || suppressed[0].Line != 7 {
+		t.Errorf("Expected the moved unused finding to stay suppressed, got %+v actionable, %+v suppressed", actionable, suppressed)
+	}
+}
+
+func TestLintFindingTrackerKeepsFindingsOnSeveralLines(t *testing.T) {
+	first := LintIssue{File: "a.go", Line: 3, Rule: "errcheck", Message: "unchecked error", Linter: "golangci-lint"}
+	second := first
+	second.Line = 9
+
+	tracker := NewLintFindingTracker(2)
+	actionable, _ := tracker.Select([]LintIssue{first, second})
+	if len(actionable) != 2 {
+		t.Fatalf("Expected the finding on both lines to be actionable, got %+v", actionable)
+	}
+
+	tracker.RecordAttempt(actionable)
+	actionable, suppressed := tracker.Select([]LintIssue{first, second})
+	if len(actionable) != 2 || len(suppressed) != 0 {
+		t.Errorf("Expected one attempt to be recorded for both lines, got %d actionable, %d suppressed", len(actionable), len(suppressed))
+	}
+}
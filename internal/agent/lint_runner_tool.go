@@ -1,8 +1,10 @@
 package agent
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
 	"regexp"
@@ -26,7 +28,7 @@ func (t *LintRunnerTool) Name() string {
 }
 
 func (t *LintRunnerTool) Description() string {
-	return "Runs linting tools (golangci-lint, go vet, go fmt) with structured output parsing"
+	return "Runs linting tools (golangci-lint, go vet, go fmt, eslint, ruff) with structured output parsing"
 }
 
 func (t *LintRunnerTool) Parameters() json.RawMessage {
@@ -39,8 +41,8 @@ func (t *LintRunnerTool) Parameters() json.RawMessage {
 			},
 			"linter": {
 				"type": "string",
-				"enum": ["golangci-lint", "go-vet", "go-fmt", "all"],
-				"description": "Which linter to run (default: all)"
+				"enum": ["golangci-lint", "go-vet", "go-fmt", "eslint", "ruff", "all"],
+				"description": "Which linter to run (default: all, which runs the Go linters)"
 			},
 			"fix": {
 				"type": "boolean",
@@ -118,15 +120,15 @@ func (t *LintRunnerTool) Execute(ctx context.Context, params json.RawMessage) (*
 	if p.Linter == "all" {
 		t.runGoFmt(lintCtx, p, &summary)
 		t.runGoVet(lintCtx, p, &summary)
-		t.runGolangciLint(lintCtx, p, &summary)
+		t.runAdapterLinter(lintCtx, &GolangciLintAdapter{}, p, &summary)
 	} else {
 		switch p.Linter {
 		case "go-fmt":
 			t.runGoFmt(lintCtx, p, &summary)
 		case "go-vet":
 			t.runGoVet(lintCtx, p, &summary)
-		case "golangci-lint":
-			t.runGolangciLint(lintCtx, p, &summary)
+		case "golangci-lint", "eslint", "ruff":
+			t.runAdapterLinter(lintCtx, LintAdapterByName(p.Linter), p, &summary)
 		default:
 			return &ToolResult{
 				Success: false,
@@ -148,17 +150,24 @@ func (t *LintRunnerTool) Execute(ctx context.Context, params json.RawMessage) (*
 		}
 	}
 
-	// Determine overall success
-	success := summary.ErrorCount == 0
+	// Determine overall success: a linter that failed to run reports
+	// nothing, which must not pass for a clean result
+	var failed []string
+	for _, name := range []string{"golangci-lint", "eslint", "ruff"} {
+		if result, ok := summary.LinterResults[name]; ok && !result.Success {
+			failed = append(failed, fmt.Sprintf("%s failed: %s", name, result.Error))
+		}
+	}
+	success := summary.ErrorCount == 0 && len(failed) == 0
 
 	return &ToolResult{
 		Success: success,
 		Data:    summary,
 		Error: func() string {
-			if !success {
-				return fmt.Sprintf("linting found %d error(s)", summary.ErrorCount)
+			if summary.ErrorCount > 0 {
+				failed = append([]string{fmt.Sprintf("linting found %d error(s)", summary.ErrorCount)}, failed...)
 			}
-			return ""
+			return strings.Join(failed, "; ")
 		}(),
 	}, nil
 }
@@ -172,6 +181,7 @@ func (t *LintRunnerTool) runGoFmt(ctx context.Context, p LintRunnerParams, summa
 
 	cmd := exec.CommandContext(ctx, "go", args...)
 	cmd.Dir = t.workspaceRoot
+	stopGracefully(cmd)
 	output, err := cmd.CombinedOutput()
 	outputStr := string(output)
 
@@ -211,6 +221,7 @@ func (t *LintRunnerTool) runGoVet(ctx context.Context, p LintRunnerParams, summa
 
 	cmd := exec.CommandContext(ctx, "go", args...)
 	cmd.Dir = t.workspaceRoot
+	stopGracefully(cmd)
 	output, err := cmd.CombinedOutput()
 	outputStr := string(output)
 
@@ -234,16 +245,21 @@ func (t *LintRunnerTool) runGoVet(ctx context.Context, p LintRunnerParams, summa
 	summary.RawOutput += fmt.Sprintf("=== go vet ===\n%s\n\n", outputStr)
 }
 
-func (t *LintRunnerTool) runGolangciLint(ctx context.Context, p LintRunnerParams, summary *LintSummary) {
-	args := []string{"run"}
-	if p.Fix {
-		args = append(args, "--fix")
-	}
-	args = append(args, "--out-format=line-number", p.TargetPath)
+// LintIssuesExitCode is the exit code golangci-lint, eslint and ruff use for
+// "ran fine and found issues"; any other non-zero code means the run failed
+const LintIssuesExitCode = 1
 
-	cmd := exec.CommandContext(ctx, "golangci-lint", args...)
+// runAdapterLinter runs a linter through its adapter so findings come from
+// structured (JSON) output rather than scraped text
+func (t *LintRunnerTool) runAdapterLinter(ctx context.Context, adapter LintAdapter, p LintRunnerParams, summary *LintSummary) {
+	command, args := adapter.Command(p.TargetPath, p.Fix)
+
+	cmd := exec.CommandContext(ctx, command, args...)
 	cmd.Dir = t.workspaceRoot
-	output, err := cmd.CombinedOutput()
+	stopGracefully(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr // Kept apart; linters log progress there
+	output, err := cmd.Output()
 	outputStr := string(output)
 
 	result := LintResult{
@@ -251,25 +267,41 @@ func (t *LintRunnerTool) runGolangciLint(ctx context.Context, p LintRunnerParams
 		Output:  outputStr,
 	}
 
+	var exitErr *exec.ExitError
+	issuesFound := errors.As(err, &exitErr) && exitErr.ExitCode() == LintIssuesExitCode
+
+	issues, parseErr := adapter.Parse(outputStr)
+	if parseErr != nil && adapter.Name() == "golangci-lint" {
+		// Older golangci-lint builds may ignore the JSON flag; fall back to
+		// text parsing, trusted only when it finds issues or the exit code
+		// says there are some, so a failed run is not taken for a clean one
+		if textIssues := t.parseGolangciLintOutput(outputStr); len(textIssues) > 0 || issuesFound {
+			issues, parseErr = textIssues, nil
+		}
+	}
+
 	if err != nil {
-		// golangci-lint returns non-zero exit code when issues are found
-		// Only treat it as an error if it's not just linting issues
-		if !strings.Contains(outputStr, ":") {
-			result.Error = err.Error()
-		} else {
+		// Linters return a non-zero exit code when issues are found; any
+		// other failure, or output that could not be parsed, is an error
+		if issuesFound && parseErr == nil {
 			result.Success = true // Issues found but tool ran successfully
+		} else {
+			result.Error = err.Error()
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				result.Error = fmt.Sprintf("%v: %s", err, msg)
+			}
+			issues = nil
 		}
 	}
 
-	// Parse golangci-lint output
-	if outputStr != "" {
-		issues := t.parseGolangciLintOutput(outputStr)
-		summary.Issues = append(summary.Issues, issues...)
-		result.IssueCount = len(issues)
-	}
+	summary.Issues = append(summary.Issues, issues...)
+	result.IssueCount = len(issues)
 
-	summary.LinterResults["golangci-lint"] = result
-	summary.RawOutput += fmt.Sprintf("=== golangci-lint ===\n%s\n\n", outputStr)
+	summary.LinterResults[adapter.Name()] = result
+	summary.RawOutput += fmt.Sprintf("=== %s ===\n%s\n\n", adapter.Name(), outputStr)
+	if stderr.Len() > 0 {
+		summary.RawOutput += fmt.Sprintf("=== %s (stderr) ===\n%s\n\n", adapter.Name(), stderr.String())
+	}
 }
 
 func (t *LintRunnerTool) parseGoVetOutput(output string) []LintIssue {
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedGolangciLint stands in for golangci-lint with a shell script
+type scriptedGolangciLint struct {
+	GolangciLintAdapter
+	script string
+}
+
+func (a *scriptedGolangciLint) Command(targetPath string, fix bool) (string, []string) {
+	return "sh", []string{"-c", a.script}
+}
+
+func TestLintRunnerTool_RunAdapterLinter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	tests := []struct {
+		name        string
+		script      string
+		wantSuccess bool
+		wantIssues  int
+		wantError   string
+	}{
+		{
+			name:        "clean run",
+			script:      `echo '{"Issues":[]}'`,
+			wantSuccess: true,
+		},
+		{
+			name:        "issues found",
+			script:      `echo '{"Issues":[{"FromLinter":"errcheck","Text":"unchecked","Pos":{"Filename":"a.go","Line":3,"Column":1}}]}'; exit 1`,
+			wantSuccess: true,
+			wantIssues:  1,
+		},
+		{
+			name:        "text output with issues",
+			script:      `echo 'a.go:3:1: unchecked error (errcheck)'; exit 1`,
+			wantSuccess: true,
+			wantIssues:  1,
+		},
+		{
+			name:        "failure with empty stdout",
+			script:      `echo 'Error: unknown flag: --out-format' >&2; exit 3`,
+			wantSuccess: false,
+			wantError:   "unknown flag: --out-format",
+		},
+		{
+			name:        "crash with log noise on stdout",
+			script:      `echo 'panic: runtime error'; exit 2`,
+			wantSuccess: false,
+			wantError:   "exit status 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := NewLintRunnerTool(t.TempDir())
+			summary := LintSummary{LinterResults: make(map[string]LintResult)}
+			tool.runAdapterLinter(context.Background(), &scriptedGolangciLint{script: tt.script}, LintRunnerParams{TargetPath: "."}, &summary)
+
+			result := summary.LinterResults["golangci-lint"]
+			assert.Equal(t, tt.wantSuccess, result.Success)
+			assert.Len(t, summary.Issues, tt.wantIssues)
+			assert.Contains(t, result.Error, tt.wantError)
+		})
+	}
+}
+
+func TestLintRunnerTool_MissingLinterFails(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	tool := NewLintRunnerTool(t.TempDir())
+
+	params, _ := json.Marshal(LintRunnerParams{Linter: "golangci-lint"})
+	result, err := tool.Execute(context.Background(), params)
+	require.NoError(t, err)
+	assert.False(t, result.Success, "a linter that did not run is not a clean result")
+	assert.Contains(t, result.Error, "golangci-lint failed")
+}
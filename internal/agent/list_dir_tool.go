@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/castrovroberto/CGE/internal/analyzer"
+	"github.com/castrovroberto/CGE/internal/security"
 )
 
 // ListDirToolConfig contains configuration for the list directory tool
@@ -69,7 +70,7 @@ func (t *ListDirTool) Name() string {
 }
 
 func (t *ListDirTool) Description() string {
-	return "Lists files and subdirectories within a specified directory with enhanced path resolution and security controls."
+	return "Lists files and subdirectories within a specified directory with enhanced path resolution and security controls. Large listings are paginated: pass next_page_token as page_token, with the same parameters, to list the next page."
 }
 
 func (t *ListDirTool) Parameters() json.RawMessage {
@@ -108,6 +109,15 @@ func (t *ListDirTool) Parameters() json.RawMessage {
 				"type": "boolean",
 				"description": "Enable smart path resolution to handle common path variations",
 				"default": true
+			},
+			"page_size": {
+				"type": "integer",
+				"description": "Maximum number of entries per page",
+				"default": 200
+			},
+			"page_token": {
+				"type": "string",
+				"description": "next_page_token of the previous result, to list the next page with the same parameters"
 			}
 		},
 		"required": ["directory_path"]
@@ -122,8 +132,13 @@ type ListDirParams struct {
 	Pattern       string `json:"pattern"`
 	SortBy        string `json:"sort_by"`
 	SmartResolve  bool   `json:"smart_resolve"`
+	PageSize      int    `json:"page_size,omitempty"`
+	PageToken     string `json:"page_token,omitempty"`
 }
 
+// defaultListDirPageSize is the number of entries listed per page by default
+const defaultListDirPageSize = 200
+
 type FileInfo struct {
 	Name         string    `json:"name"`
 	Path         string    `json:"path"`
@@ -162,12 +177,26 @@ func (t *ListDirTool) Execute(ctx context.Context, params json.RawMessage) (*Too
 	if p.SortBy == "" {
 		p.SortBy = "type_name"
 	}
+	if p.PageSize <= 0 {
+		p.PageSize = defaultListDirPageSize
+	}
+	if t.config.MaxFilesLimit > 0 {
+		p.PageSize = min(p.PageSize, t.config.MaxFilesLimit)
+	}
 
 	// Apply configuration limits
 	if p.MaxDepth > t.config.MaxDepthLimit {
 		p.MaxDepth = t.config.MaxDepthLimit
 	}
 
+	query := p
+	query.PageSize, query.PageToken = 0, ""
+	queryID := pageQuery(t.Name(), query)
+	offset, err := decodePageToken(p.PageToken, queryID)
+	if err != nil {
+		return NewErrorResult(err.(*StandardizedToolError)), nil
+	}
+
 	// Resolve and validate the directory path
 	pathResult, err := t.resolvePath(p.DirectoryPath, p.SmartResolve && t.config.SmartPathResolution)
 	if err != nil {
@@ -229,29 +258,30 @@ func (t *ListDirTool) Execute(ctx context.Context, params json.RawMessage) (*Too
 		}, nil
 	}
 
-	// Apply file limit
-	if len(files) > t.config.MaxFilesLimit {
-		files = files[:t.config.MaxFilesLimit]
-	}
-
-	// Sort files based on the specified criterion
+	// Sort before paging, so the pages of a listing follow one order
 	t.sortFiles(files, p.SortBy)
+	page, nextPageToken := paginate(files, queryID, offset, p.PageSize)
+	message := fmt.Sprintf("Listed %d files and %d directories in %s", totalFiles, totalDirs, pathResult.ResolvedPath)
+	if nextPageToken != "" || offset > 0 {
+		message += fmt.Sprintf(" (entries %d-%d of %d)", min(offset+1, len(files)), offset+len(page), len(files))
+	}
 
-	return &ToolResult{
-		Success: true,
-		Data: map[string]interface{}{
-			"directory_path":  p.DirectoryPath,
-			"resolved_path":   pathResult.ResolvedPath,
-			"path_resolution": pathResult,
-			"files":           files,
-			"total_files":     totalFiles,
-			"total_dirs":      totalDirs,
-			"recursive":       p.Recursive,
-			"pattern":         p.Pattern,
-			"truncated":       len(files) >= t.config.MaxFilesLimit,
-			"message":         fmt.Sprintf("Listed %d files and %d directories in %s", totalFiles, totalDirs, pathResult.ResolvedPath),
-		},
-	}, nil
+	data := map[string]interface{}{
+		"directory_path":  p.DirectoryPath,
+		"resolved_path":   pathResult.ResolvedPath,
+		"path_resolution": pathResult,
+		"files":           page,
+		"total_files":     totalFiles,
+		"total_dirs":      totalDirs,
+		"recursive":       p.Recursive,
+		"pattern":         p.Pattern,
+		"truncated":       nextPageToken != "",
+		"message":         message,
+	}
+	if nextPageToken != "" {
+		data["next_page_token"] = nextPageToken
+	}
+	return &ToolResult{Success: true, Data: data}, nil
 }
 
 // resolvePath resolves the directory path using various strategies
@@ -269,16 +299,12 @@ func (t *ListDirTool) resolvePath(dirPath string, smartResolve bool) (*PathResol
 	}
 
 	// Handle home directory expansion
-	if strings.HasPrefix(dirPath, "~") {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("cannot resolve home directory: %v", err)
-		}
-		if dirPath == "~" {
-			dirPath = homeDir
-		} else {
-			dirPath = filepath.Join(homeDir, dirPath[2:])
-		}
+	dirPath, err := security.ExpandHome(security.NormalizePath(dirPath))
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve home directory: %v", err)
+	}
+	if security.IsDriveRelative(dirPath) {
+		return nil, fmt.Errorf("drive-relative path %q is ambiguous; use an absolute path", dirPath)
 	}
 
 	// Determine if path is absolute
@@ -294,18 +320,8 @@ func (t *ListDirTool) resolvePath(dirPath string, smartResolve bool) (*PathResol
 	// Clean the path
 	result.ResolvedPath = filepath.Clean(result.ResolvedPath)
 
-	// Check if within workspace - fixed to prevent false positives
-	cleanWorkspace := filepath.Clean(t.workspaceRoot)
-	cleanResolved := result.ResolvedPath
-
-	// A path is within the workspace if:
-	// 1. It's exactly the workspace root, OR
-	// 2. It starts with the workspace root followed by a path separator
-	if cleanResolved == cleanWorkspace {
-		result.IsInWorkspace = true
-	} else {
-		result.IsInWorkspace = strings.HasPrefix(cleanResolved, cleanWorkspace+string(filepath.Separator))
-	}
+	// Check if within workspace, ignoring case on Windows
+	result.IsInWorkspace = security.IsWithin(t.workspaceRoot, result.ResolvedPath)
 
 	return result, nil
 }
@@ -325,8 +341,7 @@ func (t *ListDirTool) validateAccess(pathResult *PathResolutionResult) error {
 
 	// Check against allowed roots
 	for _, allowedRoot := range t.config.AllowedRoots {
-		cleanAllowedRoot := filepath.Clean(allowedRoot)
-		if strings.HasPrefix(pathResult.ResolvedPath, cleanAllowedRoot) {
+		if security.IsWithin(allowedRoot, pathResult.ResolvedPath) {
 			pathResult.AllowedByRule = fmt.Sprintf("allowed_root: %s", allowedRoot)
 			return nil
 		}
@@ -0,0 +1,292 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operation types of modify_file
+const (
+	ModifyReplace      = "replace"
+	ModifyInsertBefore = "insert_before"
+	ModifyInsertAfter  = "insert_after"
+)
+
+// ModifyFileParams are the parameters of modify_file
+type ModifyFileParams struct {
+	FilePath     string             `json:"file_path" description:"File to modify, relative to the workspace root" jsonschema:"required,minLength=1"`
+	Operations   []FileModification `json:"operations" description:"Transformations applied in order to the file's content; if any fails, the file is left unchanged" jsonschema:"required,minItems=1,maxItems=20"`
+	ExpectedHash string             `json:"expected_hash" description:"SHA-256 (hex) of the content the operations were planned against, e.g. the content_hash of a previous modify_file; the file is left unchanged if it differs"`
+	Backup       *bool              `json:"backup" description:"Keep a copy of the original file next to it" jsonschema:"default=true"`
+	DryRun       bool               `json:"dry_run" description:"Report what would change without writing the file"`
+}
+
+// FileModification is one transformation of modify_file
+type FileModification struct {
+	Type            string `json:"type" description:"replace substitutes every match of pattern; insert_before and insert_after add text as new lines before or after each line matching pattern" jsonschema:"required,enum=replace|insert_before|insert_after"`
+	Pattern         string `json:"pattern" description:"Regular expression (Go RE2 syntax) to match; for inserts it is matched against each line to find the anchor" jsonschema:"required,minLength=1"`
+	Literal         bool   `json:"literal" description:"Match pattern as plain text instead of a regular expression"`
+	Replacement     string `json:"replacement" description:"For replace: the replacement text; $1 and ${name} expand capture groups unless literal is set"`
+	Text            string `json:"text" description:"For inserts: the lines to insert"`
+	ExpectedMatches int    `json:"expected_matches" description:"Number of matches the operation must find, or nothing is written. Defaults to at least one for replace, and exactly one anchor line for inserts" jsonschema:"minimum=1"`
+}
+
+// ModificationResult describes what one operation of modify_file changed.
+// Line numbers are those of the content the operation was applied to.
+type ModificationResult struct {
+	Type     string           `json:"type"`
+	Pattern  string           `json:"pattern"`
+	Matches  int              `json:"matches"`
+	Previews []ReplacePreview `json:"previews"`
+}
+
+// NewModifyFileTool creates the modify_file tool, which reads a file,
+// transforms it and writes it back in one call, so nothing can change the file
+// between the read and the write unnoticed
+func NewModifyFileTool(workspaceRoot string) Tool {
+	validator := NewToolValidator(workspaceRoot)
+	return NewTypedTool("modify_file", `Reads a file, applies textual transformations and writes it back in one atomic step. Use it for targeted edits instead of reading a file and rewriting it with write_file.
+
+USAGE EXAMPLES:
+- modify_file({"file_path": "main.go", "operations": [{"type": "replace", "pattern": "oldName\\(", "replacement": "newName("}]})
+- modify_file({"file_path": "go.mod", "operations": [{"type": "insert_after", "pattern": "^require \\(", "text": "\tgithub.com/pkg/errors v0.9.1"}]})
+
+IMPORTANT NOTES:
+- Operations apply in order; if one finds no match, or not expected_matches, the file is left unchanged
+- Insert anchors must match exactly one line unless expected_matches says otherwise
+- Fails with FILE_CONFLICT if the file changed since it was last read, or does not match expected_hash
+- A backup of the original is kept unless backup=false; run with dry_run=true to preview`,
+		func(ctx context.Context, p ModifyFileParams) (interface{}, error) {
+			return modifyFile(ctx, validator, p)
+		})
+}
+
+func modifyFile(ctx context.Context, validator *ToolValidator, p ModifyFileParams) (interface{}, error) {
+	fullPath, err := validator.GetSafePath(p.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if protected := CheckProtected(ctx, fullPath, p.FilePath); protected != nil {
+		return nil, protected
+	}
+	if err := validator.ValidateFileExists(p.FilePath); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, NewFileNotFoundError(p.FilePath)
+	}
+	original, err := os.ReadFile(fullPath) // #nosec G304 - path validated above
+	if err != nil {
+		return nil, NewStandardizedError(
+			ErrorCodePermissionDenied,
+			fmt.Sprintf("Failed to read file: %s", p.FilePath),
+			"Check read permissions",
+		).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error())
+	}
+
+	// The operations were planned against the content the agent knows
+	if _, conflict := checkFileVersion(ctx, fullPath, p.FilePath, original, false); conflict != nil {
+		return nil, conflict
+	}
+	if p.ExpectedHash != "" && !strings.EqualFold(p.ExpectedHash, contentHash(original)) {
+		return nil, NewStandardizedError(
+			ErrorCodeFileConflict,
+			fmt.Sprintf("%s does not match expected_hash; it changed since the operations were planned", p.FilePath),
+			"Read the file again and plan the operations on its current content",
+		).WithDetail("file_path", p.FilePath).
+			WithDetail("expected_hash", p.ExpectedHash).
+			WithDetail("current_hash", contentHash(original))
+	}
+
+	content := string(original)
+	results := make([]ModificationResult, 0, len(p.Operations))
+	for i, op := range p.Operations {
+		updated, result, opErr := applyModification(content, op)
+		if opErr != nil {
+			return nil, opErr.
+				WithDetail("operation", i).
+				WithDetail("file_path", p.FilePath).
+				WithDetail("suggestion", "Nothing was written; fix this operation and send all of them again")
+		}
+		content = updated
+		results = append(results, result)
+	}
+
+	data := map[string]interface{}{
+		"file_path":     p.FilePath,
+		"dry_run":       p.DryRun,
+		"operations":    results,
+		"original_hash": contentHash(original),
+		"content_hash":  contentHash([]byte(content)),
+		"changed":       content != string(original),
+	}
+	if p.DryRun || content == string(original) {
+		if p.DryRun {
+			data["message"] = fmt.Sprintf("Dry run: %d operation(s) would change %s", len(results), p.FilePath)
+		} else {
+			data["message"] = fmt.Sprintf("%s already had the requested content; nothing was written", p.FilePath)
+		}
+		return data, nil
+	}
+
+	// Nothing else may have written the file while it was transformed
+	current, err := os.ReadFile(fullPath) // #nosec G304 - path validated above
+	if err != nil || !bytes.Equal(current, original) {
+		return nil, NewStandardizedError(
+			ErrorCodeFileConflict,
+			fmt.Sprintf("%s changed while it was being modified; nothing was written", p.FilePath),
+			"Call modify_file again",
+		).WithDetail("file_path", p.FilePath)
+	}
+
+	if p.Backup == nil || *p.Backup {
+		backupPath := fullPath + ".bak." + strconv.FormatInt(time.Now().Unix(), 10)
+		if err := os.WriteFile(backupPath, original, 0600); err != nil {
+			return nil, NewStandardizedError(
+				ErrorCodePermissionDenied,
+				fmt.Sprintf("Failed to back up %s; nothing was written", p.FilePath),
+				"Check write permissions, or retry with backup=false",
+			).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error())
+		}
+		rel, _ := filepath.Rel(validator.workspaceRoot, backupPath)
+		data["backup_path"] = filepath.ToSlash(rel)
+	}
+
+	if err := SnapshotFile(ctx, fullPath); err != nil {
+		return nil, NewStandardizedError(
+			ErrorCodeInternalError,
+			fmt.Sprintf("Failed to checkpoint %s before writing; nothing was written", p.FilePath),
+			"Check read permissions",
+		).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error())
+	}
+	if err := writeFileAtomically(fullPath, []byte(content), info.Mode().Perm()); err != nil {
+		return nil, NewStandardizedError(
+			ErrorCodePermissionDenied,
+			fmt.Sprintf("Failed to write %s; the original is unchanged", p.FilePath),
+			"Check write permissions",
+		).WithDetail("file_path", p.FilePath).WithDetail("os_error", err.Error())
+	}
+	RecordFileVersion(ctx, fullPath, []byte(content))
+
+	data["message"] = fmt.Sprintf("Applied %d operation(s) to %s", len(results), p.FilePath)
+	return data, nil
+}
+
+// applyModification applies one operation of modify_file to content
+func applyModification(content string, op FileModification) (string, ModificationResult, *StandardizedToolError) {
+	result := ModificationResult{Type: op.Type, Pattern: op.Pattern}
+	pattern := op.Pattern
+	if op.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", result, NewStandardizedError(
+			ErrorCodeInvalidParameters,
+			fmt.Sprintf("Invalid regular expression %q: %v", op.Pattern, err),
+			"Check the pattern syntax (Go RE2), or set literal=true to match plain text",
+		)
+	}
+
+	var updated string
+	switch op.Type {
+	case ModifyReplace:
+		var change FileReplacement
+		change, updated = replaceInContent(content, re, op.Replacement, op.Literal)
+		result.Matches, result.Previews = change.Replacements, change.Previews
+	case ModifyInsertBefore, ModifyInsertAfter:
+		updated, result.Matches, result.Previews = insertAtAnchors(content, re, op.Text, op.Type == ModifyInsertAfter)
+	default:
+		return "", result, NewStandardizedError(
+			ErrorCodeInvalidParameters,
+			fmt.Sprintf("Unknown operation type %q", op.Type),
+			"Use replace, insert_before or insert_after",
+		)
+	}
+
+	switch {
+	case op.ExpectedMatches > 0 && result.Matches != op.ExpectedMatches:
+		return "", result, matchCountError(op, result.Matches, fmt.Sprintf("exactly %d", op.ExpectedMatches))
+	case op.ExpectedMatches == 0 && result.Matches == 0:
+		return "", result, matchCountError(op, 0, "at least one")
+	case op.ExpectedMatches == 0 && op.Type != ModifyReplace && result.Matches > 1:
+		return "", result, matchCountError(op, result.Matches, "exactly one anchor line")
+	}
+	return updated, result, nil
+}
+
+func matchCountError(op FileModification, found int, expected string) *StandardizedToolError {
+	suggestion := "Read the file and adjust the pattern"
+	if found > 1 {
+		suggestion = "Make the pattern more specific, or set expected_matches to the number of places to change"
+	}
+	return NewStandardizedError(
+		ErrorCodeInvalidParameters,
+		fmt.Sprintf("%s pattern %q matched %d time(s), expected %s", op.Type, op.Pattern, found, expected),
+		suggestion,
+	).WithDetail("matches", found)
+}
+
+// insertAtAnchors inserts text as new lines before or after each line of
+// content matching re, returning the updated content, the number of anchor
+// lines and previews of the changes
+func insertAtAnchors(content string, re *regexp.Regexp, text string, after bool) (string, int, []ReplacePreview) {
+	lines := strings.Split(content, "\n")
+	count := len(lines)
+	if strings.HasSuffix(content, "\n") {
+		count-- // The last element is the empty string after the final newline
+	}
+	inserted := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	var previews []ReplacePreview
+	out := make([]string, 0, len(lines)+len(inserted))
+	matches := 0
+	for i, line := range lines {
+		if i >= count || !re.MatchString(line) {
+			out = append(out, line)
+			continue
+		}
+		matches++
+		block := append([]string{line}, inserted...)
+		if !after {
+			block = append(append([]string{}, inserted...), line)
+		}
+		out = append(out, block...)
+		if len(previews) < maxPreviewsPerFile {
+			previews = append(previews, ReplacePreview{Line: i + 1, Before: line, After: strings.Join(block, "\n")})
+		}
+	}
+	return strings.Join(out, "\n"), matches, previews
+}
+
+// writeFileAtomically replaces path with content through a temporary file
+// renamed over it, so the file is never left half written
+func writeFileAtomically(path string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // Fails harmlessly once renamed
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
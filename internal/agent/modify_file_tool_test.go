@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const modifyFileContent = "package main\n\nimport (\n\t\"fmt\"\n)\n\nfunc main() {\n\tfmt.Println(oldName())\n}\n"
+
+func setupModifyFile(t *testing.T) (string, Tool) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte(modifyFileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root, NewModifyFileTool(root)
+}
+
+func TestModifyFile(t *testing.T) {
+	root, tool := setupModifyFile(t)
+
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{
+		"file_path": "main.go",
+		"operations": [
+			{"type": "replace", "pattern": "oldName\\((\\w*)\\)", "replacement": "newName($1)"},
+			{"type": "insert_after", "pattern": "^\\t\"fmt\"$", "text": "\t\"os\""},
+			{"type": "insert_before", "pattern": "func main", "text": "// main prints a name\n"}
+		]
+	}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(root, "main.go"))
+	want := "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\n// main prints a name\nfunc main() {\n\tfmt.Println(newName())\n}\n"
+	if string(got) != want {
+		t.Errorf("unexpected content:\n%s", got)
+	}
+
+	data := result.Data.(map[string]interface{})
+	if data["content_hash"] != contentHash(got) || data["original_hash"] != contentHash([]byte(modifyFileContent)) {
+		t.Errorf("unexpected hashes: %v", data)
+	}
+	backup, ok := data["backup_path"].(string)
+	if !ok {
+		t.Fatal("expected a backup")
+	}
+	if original, _ := os.ReadFile(filepath.Join(root, backup)); string(original) != modifyFileContent {
+		t.Errorf("the backup should hold the original content, got %q", original)
+	}
+	results := data["operations"].([]ModificationResult)
+	if len(results) != 3 || results[0].Matches != 1 || results[1].Previews[0].Line != 4 {
+		t.Errorf("unexpected operation results: %+v", results)
+	}
+}
+
+func TestModifyFileIsAllOrNothing(t *testing.T) {
+	root, tool := setupModifyFile(t)
+	path := filepath.Join(root, "main.go")
+	run := func(args string) *StandardizedToolError {
+		t.Helper()
+		result, err := tool.Execute(context.Background(), json.RawMessage(args))
+		if err != nil {
+			var invalid *StandardizedToolError
+			if errors.As(err, &invalid) {
+				return invalid
+			}
+			t.Fatalf("unexpected execution error: %v", err)
+		}
+		if result.Success {
+			t.Fatalf("expected a failure for %s", args)
+		}
+		return result.StandardizedError
+	}
+
+	tests := []struct {
+		name, args, message string
+	}{
+		{"no match", `{"file_path": "main.go", "operations": [
+			{"type": "replace", "pattern": "oldName", "replacement": "newName"},
+			{"type": "replace", "pattern": "missing", "replacement": "x"}]}`, "matched 0 time(s), expected at least one"},
+		{"ambiguous anchor", `{"file_path": "main.go", "operations": [
+			{"type": "insert_after", "pattern": "\\(", "text": "x"}]}`, "expected exactly one anchor line"},
+		{"expected matches", `{"file_path": "main.go", "operations": [
+			{"type": "replace", "pattern": "(", "literal": true, "replacement": "[", "expected_matches": 2}]}`, "expected exactly 2"},
+		{"invalid pattern", `{"file_path": "main.go", "operations": [
+			{"type": "replace", "pattern": "(", "replacement": "x"}]}`, "Invalid regular expression"},
+		{"expected hash", `{"file_path": "main.go", "expected_hash": "abc", "operations": [
+			{"type": "replace", "pattern": "oldName", "replacement": "newName"}]}`, "does not match expected_hash"},
+		{"invalid type", `{"file_path": "main.go", "operations": [{"type": "delete", "pattern": "x"}]}`, "operations[0].type must be one of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure := run(tt.args)
+			if failure == nil || !strings.Contains(failure.Message, tt.message) {
+				t.Errorf("expected %q, got %+v", tt.message, failure)
+			}
+			if got, _ := os.ReadFile(path); string(got) != modifyFileContent {
+				t.Errorf("the file must be left unchanged, got %q", got)
+			}
+		})
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(root, "main.go.*"))
+	temps, _ := filepath.Glob(filepath.Join(root, ".main.go.tmp-*"))
+	if len(backups)+len(temps) != 0 {
+		t.Errorf("failed calls must not leave backups or temporary files: %v %v", backups, temps)
+	}
+}
+
+func TestModifyFileConflictsAndDryRun(t *testing.T) {
+	root, tool := setupModifyFile(t)
+	path := filepath.Join(root, "main.go")
+	versions := NewFileVersions()
+	versions.Record(path, []byte("package main\n"))
+	ctx := WithFileVersions(context.Background(), versions)
+
+	args := json.RawMessage(`{"file_path": "main.go", "backup": false, "operations": [{"type": "replace", "pattern": "oldName", "replacement": "newName"}]}`)
+	result, err := tool.Execute(ctx, args)
+	if err != nil || result.Success || result.StandardizedError.Code != ErrorCodeFileConflict {
+		t.Fatalf("expected a conflict with the version the agent read, got %v %+v", err, result)
+	}
+
+	versions.Record(path, []byte(modifyFileContent))
+	dryRun := json.RawMessage(`{"file_path": "main.go", "dry_run": true, "operations": [{"type": "replace", "pattern": "oldName", "replacement": "newName"}]}`)
+	result, err = tool.Execute(ctx, dryRun)
+	if err != nil || !result.Success {
+		t.Fatalf("expected the dry run to succeed, got %v %+v", err, result)
+	}
+	if got, _ := os.ReadFile(path); string(got) != modifyFileContent {
+		t.Error("a dry run must not write the file")
+	}
+
+	result, err = tool.Execute(ctx, args)
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	if _, ok := result.Data.(map[string]interface{})["backup_path"]; ok {
+		t.Error("no backup was asked for")
+	}
+	got, _ := os.ReadFile(path)
+	if _, changed := versions.Changed(path, got); changed {
+		t.Error("the written content should be recorded as the version the agent knows")
+	}
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// pageToken is the state a continuation token carries from one page of a
+// tool result to the next. Tools are stateless between calls, so the next
+// page is found again from the request and the offset.
+type pageToken struct {
+	Query  string `json:"q"` // Fingerprint of the request the token continues
+	Offset int    `json:"o"` // Position of the first item of the next page
+}
+
+// pageQuery fingerprints the request of a paginated tool, so a token is only
+// accepted by the request it continues. params should have its page token
+// and page size cleared.
+func pageQuery(toolName string, params interface{}) string {
+	data, _ := json.Marshal(params)
+	sum := sha256.Sum256(append([]byte(toolName+"\x00"), data...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// encodePageToken returns the continuation token of the page of query
+// starting at offset
+func encodePageToken(query string, offset int) string {
+	data, _ := json.Marshal(pageToken{Query: query, Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageToken returns the offset a continuation token resumes query at;
+// an empty token starts at the first page
+func decodePageToken(token, query string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	var page pageToken
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(data, &page)
+	}
+	if err != nil || page.Offset < 0 {
+		return 0, NewStandardizedError(ErrorCodeInvalidParameters, "invalid page_token",
+			"Pass the next_page_token of the previous result unchanged")
+	}
+	if page.Query != query {
+		return 0, NewStandardizedError(ErrorCodeInvalidParameters, "page_token belongs to a different request",
+			"Repeat the parameters of the request that returned the token, or omit page_token to start over")
+	}
+	return page.Offset, nil
+}
+
+// paginate returns the page of items starting at offset, at most size long,
+// and the continuation token of the next page, empty on the last one
+func paginate[T any](items []T, query string, offset, size int) ([]T, string) {
+	start := min(offset, len(items))
+	end := min(start+size, len(items))
+	if end == len(items) {
+		return items[start:end], ""
+	}
+	return items[start:end], encodePageToken(query, end)
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// executeTool runs tool with params and returns the data of its result
+func executeTool(t *testing.T, tool Tool, params map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	raw, _ := json.Marshal(params)
+	result, err := tool.Execute(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("%s(%s) returned error: %v", tool.Name(), raw, err)
+	}
+	if !result.Success {
+		t.Fatalf("%s(%s) failed: %s", tool.Name(), raw, result.Error)
+	}
+	return result.Data.(map[string]interface{})
+}
+
+func TestListDirPagination(t *testing.T) {
+	workspace := t.TempDir()
+	for i := 0; i < 25; i++ {
+		dir := filepath.Join(workspace, fmt.Sprintf("pkg%d", i%5))
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%02d.go", i)), []byte("package pkg\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tool := NewListDirTool(workspace)
+
+	params := map[string]interface{}{"directory_path": ".", "recursive": true, "page_size": 12}
+	seen := make(map[string]bool)
+	pages := 0
+	for {
+		data := executeTool(t, tool, params)
+		pages++
+		for _, file := range data["files"].([]FileInfo) {
+			if seen[file.Path] {
+				t.Errorf("%s listed on two pages", file.Path)
+			}
+			seen[file.Path] = true
+		}
+		token, ok := data["next_page_token"].(string)
+		if !ok {
+			if data["truncated"] != false {
+				t.Error("the last page should not be truncated")
+			}
+			break
+		}
+		params["page_token"] = token
+	}
+	if pages != 3 || len(seen) != 30 {
+		t.Errorf("listed %d entries on %d pages, want 30 on 3", len(seen), pages)
+	}
+
+	params["pattern"] = "*.go"
+	result, err := tool.Execute(context.Background(), mustJSON(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || !strings.Contains(result.Error, "different request") {
+		t.Errorf("a token reused with other parameters should be rejected, got %+v", result)
+	}
+}
+
+func TestGrepPagination(t *testing.T) {
+	workspace := t.TempDir()
+	var b strings.Builder
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&b, "// TODO item %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "todo.go"), []byte(b.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	engines := []string{GrepEngineGo}
+	if NewGrepTool(workspace).rgPath != "" {
+		engines = append(engines, GrepEngineRipgrep)
+	}
+	for _, engine := range engines {
+		tool := NewGrepTool(workspace)
+		params := map[string]interface{}{"pattern": "TODO", "max_results": 20, "context_lines": 0, "engine": engine}
+		first := executeTool(t, tool, params)
+		token, _ := first["next_page_token"].(string)
+		if first["truncated"] != true || token == "" {
+			t.Fatalf("[%s] the first page should continue, got %v", engine, first)
+		}
+
+		params["page_token"] = token
+		second := executeTool(t, tool, params)
+		matches := second["matches"].([]GrepMatch)
+		if len(matches) != 10 || matches[0].Line != 21 || matches[9].Line != 30 {
+			t.Errorf("[%s] second page = %+v, want lines 21-30", engine, matches)
+		}
+		if second["truncated"] != false || second["next_page_token"] != nil {
+			t.Errorf("[%s] the second page should be the last", engine)
+		}
+	}
+}
+
+func TestReadToolOutputPagination(t *testing.T) {
+	workspace := t.TempDir()
+	content := strings.Repeat("ok\n", 10000) + strings.Repeat("FAIL: TestLogin\n", 300)
+	id, err := SaveToolOutput(ToolOutputDir(workspace), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := NewReadToolOutputTool(workspace)
+
+	data := executeTool(t, tool, map[string]interface{}{"output_id": id, "limit": 20000})
+	data = executeTool(t, tool, map[string]interface{}{"output_id": id, "page_token": data["next_page_token"]})
+	if data["offset"] != 20000 || data["next_offset"] != 28000 {
+		t.Errorf("the page token should continue at 20000, got offset %v next %v", data["offset"], data["next_offset"])
+	}
+
+	data = executeTool(t, tool, map[string]interface{}{"output_id": id, "pattern": "FAIL"})
+	if n := len(data["matches"].([]string)); n != maxToolOutputMatches || data["truncated"] != true {
+		t.Fatalf("the first search page has %d matches, want %d", n, maxToolOutputMatches)
+	}
+	data = executeTool(t, tool, map[string]interface{}{"output_id": id, "pattern": "FAIL", "page_token": data["next_page_token"]})
+	matches := data["matches"].([]string)
+	if len(matches) != 100 || matches[0] != "10201: FAIL: TestLogin" || data["truncated"] != false {
+		t.Errorf("the second search page has %d matches from %q", len(matches), matches[0])
+	}
+}
+
+func TestTestRunnerPaginatesRawOutput(t *testing.T) {
+	workspace := t.TempDir()
+	tool := NewTestRunnerTool(workspace)
+	log := strings.Repeat("=== RUN   TestCase\n--- PASS: TestCase (0.00s)\n", 1000)
+	summary := tool.parseTestOutput(log)
+	tool.paginateRawOutput(context.Background(), &summary)
+	if len(summary.RawOutput) > testLogPageSize || summary.RawOutputID == "" || summary.NextPageToken == "" {
+		t.Fatalf("raw output not paginated: %d characters, id %q", len(summary.RawOutput), summary.RawOutputID)
+	}
+	if summary.PassedTests != 1000 {
+		t.Errorf("the summary should count the whole log, got %d", summary.PassedTests)
+	}
+
+	data := executeTool(t, NewReadToolOutputTool(workspace), map[string]interface{}{"output_id": summary.RawOutputID, "page_token": summary.NextPageToken})
+	if data["offset"] != len(summary.RawOutput) || !strings.HasPrefix(log, summary.RawOutput+data["content"].(string)) || !strings.HasSuffix(summary.RawOutput, "\n") {
+		t.Errorf("the next page should start where the raw output stops, got offset %v", data["offset"])
+	}
+}
+
+func mustJSON(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
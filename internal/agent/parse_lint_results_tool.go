@@ -37,7 +37,7 @@ func (t *ParseLintResultsTool) Parameters() json.RawMessage {
 			},
 			"linter_type": {
 				"type": "string",
-				"enum": ["golangci-lint", "go-vet", "go-fmt", "eslint", "auto"],
+				"enum": ["golangci-lint", "go-vet", "go-fmt", "eslint", "ruff", "auto"],
 				"description": "Linter type (default: auto-detect)"
 			}
 		},
@@ -95,14 +95,35 @@ func (t *ParseLintResultsTool) Execute(ctx context.Context, params json.RawMessa
 		LinterResults: make(map[string]int),
 	}
 
-	switch p.LinterType {
-	case "golangci-lint":
+	structured := false
+	if adapter := LintAdapterByName(p.LinterType); adapter != nil {
+		// JSON reports from golangci-lint, eslint and ruff are parsed exactly;
+		// text output falls through to the pattern-based parsers below
+		if issues, err := adapter.Parse(p.LintOutput); err == nil {
+			for _, issue := range issues {
+				summary.Issues = append(summary.Issues, ParsedLintIssue{
+					File:     issue.File,
+					Line:     issue.Line,
+					Column:   issue.Column,
+					Severity: issue.Severity,
+					Rule:     issue.Rule,
+					Message:  issue.Message,
+					Linter:   issue.Linter,
+				})
+			}
+			structured = true
+		}
+	}
+
+	switch {
+	case structured:
+	case p.LinterType == "golangci-lint":
 		t.parseGolangciLintOutput(p.LintOutput, &summary)
-	case "go-vet":
+	case p.LinterType == "go-vet":
 		t.parseGoVetOutput(p.LintOutput, &summary)
-	case "go-fmt":
+	case p.LinterType == "go-fmt":
 		t.parseGoFmtOutput(p.LintOutput, &summary)
-	case "eslint":
+	case p.LinterType == "eslint":
 		t.parseESLintOutput(p.LintOutput, &summary)
 	default:
 		// Try to parse as generic format
@@ -138,6 +159,20 @@ func (t *ParseLintResultsTool) Execute(ctx context.Context, params json.RawMessa
 }
 
 func (t *ParseLintResultsTool) detectLinterType(output string) string {
+	// Structured JSON reports
+	trimmed := strings.TrimSpace(output)
+	if strings.HasPrefix(trimmed, "{") && strings.Contains(trimmed, `"Issues"`) {
+		return "golangci-lint"
+	}
+	if strings.HasPrefix(trimmed, "[") {
+		if strings.Contains(trimmed, `"filePath"`) {
+			return "eslint"
+		}
+		if strings.Contains(trimmed, `"location"`) && strings.Contains(trimmed, `"code"`) {
+			return "ruff"
+		}
+	}
+
 	output = strings.ToLower(output)
 
 	if strings.Contains(output, "golangci-lint") {
@@ -0,0 +1,589 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Structured test report formats understood by ParseTestResultsTool in addition
+// to the plain-text Go, Jest and Pytest output.
+const (
+	TestFormatGoJSON   = "go-json"   // go test -json
+	TestFormatJUnitXML = "junit-xml" // pytest --junitxml (and any JUnit-style report)
+	TestFormatJestJSON = "jest-json" // jest --json
+	TestFormatCargo    = "cargo"     // cargo test
+)
+
+var (
+	goFileLinePattern     = regexp.MustCompile(`^\s*([\w./\-]+\.go):(\d+):\s*(.*)$`)
+	goStackFramePattern   = regexp.MustCompile(`^\s*(\S+\.go):(\d+)(?:\s+\+0x[0-9a-f]+)?$`)
+	goCoveragePattern     = regexp.MustCompile(`coverage:\s+([0-9.]+%)\s+of\s+statements`)
+	cargoTestLinePattern  = regexp.MustCompile(`^test\s+(\S+)\s+\.\.\.\s+(ok|FAILED|ignored)`)
+	cargoStdoutPattern    = regexp.MustCompile(`^----\s+(\S+)\s+stdout\s+----$`)
+	cargoPanicNewPattern  = regexp.MustCompile(`panicked at ([^:\s]+):(\d+):\d+:?$`)
+	cargoPanicOldPattern  = regexp.MustCompile(`panicked at '(.*)',\s*([^:\s]+):(\d+):\d+`)
+	cargoResultPattern    = regexp.MustCompile(`^test result:.*finished in ([0-9.]+s)`)
+	junitFileLinePattern  = regexp.MustCompile(`([\w./\-]+\.py):(\d+):`)
+	jestStackFramePattern = regexp.MustCompile(`\(?([^\s()]+\.(?:js|jsx|ts|tsx|mjs|cjs)):(\d+):\d+\)?`)
+)
+
+// detectStructuredTestFormat recognizes machine-readable test reports. It
+// returns an empty string when the output does not look structured.
+func detectStructuredTestFormat(output string) string {
+	trimmed := strings.TrimSpace(output)
+
+	switch {
+	case strings.HasPrefix(trimmed, "<?xml") || strings.HasPrefix(trimmed, "<testsuite"):
+		return TestFormatJUnitXML
+	case strings.HasPrefix(trimmed, "{") && strings.Contains(trimmed, `"numTotalTests"`):
+		return TestFormatJestJSON
+	case strings.HasPrefix(trimmed, "{") && strings.Contains(trimmed, `"Action"`):
+		return TestFormatGoJSON
+	case strings.Contains(trimmed, "test result:") && strings.Contains(trimmed, "running "):
+		return TestFormatCargo
+	}
+
+	return ""
+}
+
+// goTestEvent mirrors the events emitted by `go test -json` (see `go doc test2json`)
+type goTestEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Output  string  `json:"Output"`
+	Elapsed float64 `json:"Elapsed"`
+}
+
+// parseGoJSONOutput parses the event stream produced by `go test -json`
+func (t *ParseTestResultsTool) parseGoJSONOutput(output string) ParsedTestSummary {
+	summary := ParsedTestSummary{
+		Framework: TestFormatGoJSON,
+		Failures:  []ParsedTestFailure{},
+	}
+
+	testOutput := make(map[string][]string)
+	packageOutput := make(map[string][]string)
+	failedPackages := make(map[string]bool)
+	var totalElapsed float64
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var event goTestEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			continue
+		}
+
+		key := event.Package + "/" + event.Test
+
+		switch event.Action {
+		case "build-output":
+			packageOutput[event.Package] = append(packageOutput[event.Package], event.Output)
+		case "output":
+			if event.Test != "" {
+				testOutput[key] = append(testOutput[key], event.Output)
+			} else {
+				packageOutput[event.Package] = append(packageOutput[event.Package], event.Output)
+				if match := goCoveragePattern.FindStringSubmatch(event.Output); match != nil {
+					summary.Coverage = match[1]
+				}
+			}
+		case "pass":
+			if event.Test == "" {
+				totalElapsed += event.Elapsed
+				continue
+			}
+			summary.PassedTests++
+			summary.TotalTests++
+		case "skip":
+			if event.Test == "" {
+				continue
+			}
+			summary.SkippedTests++
+			summary.TotalTests++
+		case "fail":
+			if event.Test == "" {
+				totalElapsed += event.Elapsed
+				// A failing package without failing tests is a build failure
+				if !failedPackages[event.Package] {
+					summary.CompileErrors = append(summary.CompileErrors, goCompileErrors(packageOutput[event.Package])...)
+				}
+				continue
+			}
+			failedPackages[event.Package] = true
+			summary.Failures = append(summary.Failures, newGoJSONFailure(event, testOutput[key]))
+			summary.FailedTests++
+			summary.TotalTests++
+		}
+	}
+
+	if totalElapsed > 0 {
+		summary.Duration = fmt.Sprintf("%.3fs", totalElapsed)
+	}
+
+	finalizeTestSummary(&summary)
+	return summary
+}
+
+// newGoJSONFailure builds a failure record from the captured output of one test
+func newGoJSONFailure(event goTestEvent, lines []string) ParsedTestFailure {
+	failure := ParsedTestFailure{
+		TestName:    event.Test,
+		FailureType: "assertion",
+	}
+
+	var messages []string
+	var stack []string
+	inPanic := false
+
+	for _, raw := range lines {
+		line := strings.TrimRight(raw, "\n")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "panic:") {
+			inPanic = true
+			failure.FailureType = "panic"
+			messages = append(messages, strings.TrimSpace(strings.TrimPrefix(trimmed, "panic:")))
+			continue
+		}
+		if inPanic {
+			stack = append(stack, line)
+			continue
+		}
+
+		if match := goFileLinePattern.FindStringSubmatch(line); match != nil {
+			if failure.TestFile == "" {
+				failure.TestFile = match[1]
+				failure.Line, _ = strconv.Atoi(match[2])
+			}
+			messages = append(messages, match[3])
+			continue
+		}
+		messages = append(messages, trimmed)
+	}
+
+	if strings.Contains(strings.Join(messages, " "), "test timed out") {
+		failure.FailureType = "timeout"
+	}
+
+	failure.ErrorMessage = strings.Join(messages, "\n")
+	failure.StackTrace = strings.Join(stack, "\n")
+
+	// Panics report their origin in the stack; use the first frame in a _test file
+	if failure.TestFile == "" {
+		for _, frame := range stack {
+			if match := goStackFramePattern.FindStringSubmatch(frame); match != nil && strings.HasSuffix(match[1], "_test.go") {
+				failure.TestFile = match[1]
+				failure.Line, _ = strconv.Atoi(match[2])
+				break
+			}
+		}
+	}
+
+	return failure
+}
+
+// goCompileErrors extracts compiler diagnostics from a package's output
+func goCompileErrors(lines []string) []string {
+	var errors []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if goFileLinePattern.MatchString(trimmed) {
+			errors = append(errors, trimmed)
+		}
+	}
+	return errors
+}
+
+// junitTestSuites covers both <testsuites> and bare <testsuite> documents
+type junitTestSuites struct {
+	XMLName xml.Name
+	Suites  []junitTestSuite `xml:"testsuite"`
+	Cases   []junitTestCase  `xml:"testcase"`
+	Time    string           `xml:"time,attr"`
+}
+
+type junitTestSuite struct {
+	Name   string           `xml:"name,attr"`
+	Time   string           `xml:"time,attr"`
+	Cases  []junitTestCase  `xml:"testcase"`
+	Suites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *junitProblem `xml:"skipped"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// parseJUnitXMLOutput parses JUnit-style XML reports such as pytest --junitxml
+func (t *ParseTestResultsTool) parseJUnitXMLOutput(output string) ParsedTestSummary {
+	summary := ParsedTestSummary{
+		Framework: TestFormatJUnitXML,
+		Failures:  []ParsedTestFailure{},
+	}
+
+	var doc junitTestSuites
+	if err := xml.Unmarshal([]byte(strings.TrimSpace(output)), &doc); err != nil {
+		summary.Summary = fmt.Sprintf("failed to parse JUnit XML: %v", err)
+		return summary
+	}
+
+	var cases []junitTestCase
+	if doc.XMLName.Local == "testsuite" {
+		cases = append(cases, doc.Cases...)
+		summary.Duration = formatSeconds(doc.Time)
+	}
+	for _, suite := range doc.Suites {
+		cases = append(cases, collectJUnitCases(suite)...)
+	}
+	if summary.Duration == "" {
+		summary.Duration = formatSeconds(doc.Time)
+	}
+
+	for _, tc := range cases {
+		summary.TotalTests++
+
+		problem := tc.Failure
+		failureType := "assertion"
+		if problem == nil && tc.Error != nil {
+			problem = tc.Error
+			failureType = "error"
+		}
+
+		switch {
+		case problem != nil:
+			summary.FailedTests++
+			summary.Failures = append(summary.Failures, newJUnitFailure(tc, problem, failureType))
+		case tc.Skipped != nil:
+			summary.SkippedTests++
+		default:
+			summary.PassedTests++
+		}
+	}
+
+	finalizeTestSummary(&summary)
+	return summary
+}
+
+func collectJUnitCases(suite junitTestSuite) []junitTestCase {
+	cases := append([]junitTestCase{}, suite.Cases...)
+	for _, nested := range suite.Suites {
+		cases = append(cases, collectJUnitCases(nested)...)
+	}
+	return cases
+}
+
+func newJUnitFailure(tc junitTestCase, problem *junitProblem, failureType string) ParsedTestFailure {
+	name := tc.Name
+	if tc.ClassName != "" {
+		name = tc.ClassName + "::" + tc.Name
+	}
+
+	failure := ParsedTestFailure{
+		TestName:     name,
+		TestFile:     tc.File,
+		Line:         tc.Line,
+		ErrorMessage: strings.TrimSpace(problem.Message),
+		StackTrace:   strings.TrimSpace(problem.Body),
+		FailureType:  failureType,
+	}
+
+	if failure.ErrorMessage == "" {
+		failure.ErrorMessage = firstNonEmptyLine(problem.Body)
+	}
+	if strings.Contains(strings.ToLower(problem.Type+problem.Message), "timeout") {
+		failure.FailureType = "timeout"
+	}
+
+	// The traceback points at the failing assertion, which is more precise
+	// than the line of the test function recorded on the testcase element
+	if matches := junitFileLinePattern.FindAllStringSubmatch(problem.Body, -1); len(matches) > 0 {
+		last := matches[len(matches)-1]
+		failure.TestFile = last[1]
+		failure.Line, _ = strconv.Atoi(last[2])
+	}
+
+	return failure
+}
+
+// jestReport is the subset of `jest --json` output used for parsing
+type jestReport struct {
+	NumTotalTests   int `json:"numTotalTests"`
+	NumPassedTests  int `json:"numPassedTests"`
+	NumFailedTests  int `json:"numFailedTests"`
+	NumPendingTests int `json:"numPendingTests"`
+	TestResults     []struct {
+		Name             string `json:"name"`
+		Status           string `json:"status"`
+		Message          string `json:"message"`
+		StartTime        int64  `json:"startTime"`
+		EndTime          int64  `json:"endTime"`
+		AssertionResults []struct {
+			FullName        string   `json:"fullName"`
+			Title           string   `json:"title"`
+			Status          string   `json:"status"`
+			FailureMessages []string `json:"failureMessages"`
+			Location        *struct {
+				Line   int `json:"line"`
+				Column int `json:"column"`
+			} `json:"location"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+// parseJestJSONOutput parses the report written by `jest --json`
+func (t *ParseTestResultsTool) parseJestJSONOutput(output string) ParsedTestSummary {
+	summary := ParsedTestSummary{
+		Framework: TestFormatJestJSON,
+		Failures:  []ParsedTestFailure{},
+	}
+
+	// Jest may print log lines before the JSON document
+	trimmed := strings.TrimSpace(output)
+	if idx := strings.Index(trimmed, "{"); idx > 0 {
+		trimmed = trimmed[idx:]
+	}
+
+	var report jestReport
+	if err := json.Unmarshal([]byte(trimmed), &report); err != nil {
+		summary.Summary = fmt.Sprintf("failed to parse Jest JSON: %v", err)
+		return summary
+	}
+
+	summary.TotalTests = report.NumTotalTests
+	summary.PassedTests = report.NumPassedTests
+	summary.FailedTests = report.NumFailedTests
+	summary.SkippedTests = report.NumPendingTests
+
+	var elapsedMs int64
+	for _, suite := range report.TestResults {
+		if suite.EndTime > suite.StartTime {
+			elapsedMs += suite.EndTime - suite.StartTime
+		}
+
+		suiteHasAssertionFailure := false
+		for _, assertion := range suite.AssertionResults {
+			if assertion.Status != "failed" {
+				continue
+			}
+			suiteHasAssertionFailure = true
+
+			message := strings.Join(assertion.FailureMessages, "\n")
+			failure := ParsedTestFailure{
+				TestName:     assertion.FullName,
+				TestFile:     suite.Name,
+				ErrorMessage: firstNonEmptyLine(stripANSI(message)),
+				StackTrace:   stripANSI(message),
+				FailureType:  "assertion",
+			}
+			if failure.TestName == "" {
+				failure.TestName = assertion.Title
+			}
+			if assertion.Location != nil {
+				failure.Line = assertion.Location.Line
+			}
+			if failure.Line == 0 {
+				failure.Line = jestFailureLine(message, suite.Name)
+			}
+			if strings.Contains(message, "Exceeded timeout") {
+				failure.FailureType = "timeout"
+			}
+			summary.Failures = append(summary.Failures, failure)
+		}
+
+		// A suite that failed to run (syntax error, missing module) has no assertions
+		if suite.Status == "failed" && !suiteHasAssertionFailure && suite.Message != "" {
+			summary.CompileErrors = append(summary.CompileErrors, fmt.Sprintf("%s: %s", suite.Name, firstNonEmptyLine(stripANSI(suite.Message))))
+		}
+	}
+
+	if elapsedMs > 0 {
+		summary.Duration = fmt.Sprintf("%.3fs", float64(elapsedMs)/1000)
+	}
+
+	finalizeTestSummary(&summary)
+	return summary
+}
+
+// jestFailureLine finds the line in the test file referenced by a failure stack
+func jestFailureLine(message, testFile string) int {
+	for _, match := range jestStackFramePattern.FindAllStringSubmatch(message, -1) {
+		if testFile == "" || strings.HasSuffix(testFile, match[1]) || strings.HasSuffix(match[1], testFile) {
+			line, _ := strconv.Atoi(match[2])
+			return line
+		}
+	}
+	return 0
+}
+
+// parseCargoOutput parses the libtest output printed by `cargo test`
+func (t *ParseTestResultsTool) parseCargoOutput(output string) ParsedTestSummary {
+	summary := ParsedTestSummary{
+		Framework: TestFormatCargo,
+		Failures:  []ParsedTestFailure{},
+	}
+
+	failureIndex := make(map[string]int)
+	lines := strings.Split(output, "\n")
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if match := cargoTestLinePattern.FindStringSubmatch(trimmed); match != nil {
+			summary.TotalTests++
+			switch match[2] {
+			case "ok":
+				summary.PassedTests++
+			case "ignored":
+				summary.SkippedTests++
+			case "FAILED":
+				summary.FailedTests++
+				failureIndex[match[1]] = len(summary.Failures)
+				summary.Failures = append(summary.Failures, ParsedTestFailure{
+					TestName:    match[1],
+					FailureType: "assertion",
+				})
+			}
+			continue
+		}
+		if match := cargoResultPattern.FindStringSubmatch(trimmed); match != nil {
+			summary.Duration = match[1]
+		}
+		if strings.HasPrefix(trimmed, "error[E") || strings.HasPrefix(trimmed, "error: could not compile") {
+			summary.CompileErrors = append(summary.CompileErrors, trimmed)
+		}
+	}
+
+	// Attach captured stdout ("---- name stdout ----" sections) to failures
+	var current *ParsedTestFailure
+	var captured []string
+	flush := func() {
+		if current != nil {
+			applyCargoCapture(current, captured)
+		}
+		current, captured = nil, nil
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if match := cargoStdoutPattern.FindStringSubmatch(trimmed); match != nil {
+			flush()
+			if idx, ok := failureIndex[match[1]]; ok {
+				current = &summary.Failures[idx]
+			}
+			continue
+		}
+		if trimmed == "failures:" || strings.HasPrefix(trimmed, "test result:") {
+			flush()
+			continue
+		}
+		if current != nil {
+			captured = append(captured, line)
+		}
+	}
+	flush()
+
+	finalizeTestSummary(&summary)
+	return summary
+}
+
+// applyCargoCapture fills in message and location from a test's captured output
+func applyCargoCapture(failure *ParsedTestFailure, captured []string) {
+	var message []string
+	for i, line := range captured {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		if match := cargoPanicOldPattern.FindStringSubmatch(trimmed); match != nil {
+			failure.FailureType = "panic"
+			failure.TestFile = match[2]
+			failure.Line, _ = strconv.Atoi(match[3])
+			message = append(message, match[1])
+			continue
+		}
+		if match := cargoPanicNewPattern.FindStringSubmatch(trimmed); match != nil {
+			failure.FailureType = "panic"
+			failure.TestFile = match[1]
+			failure.Line, _ = strconv.Atoi(match[2])
+			// Since Rust 1.73 the panic message follows on the next lines
+			for _, next := range captured[i+1:] {
+				next = strings.TrimSpace(next)
+				if next == "" || strings.HasPrefix(next, "note:") || strings.HasPrefix(next, "stack backtrace:") {
+					break
+				}
+				message = append(message, next)
+			}
+			break
+		}
+	}
+
+	if len(message) > 0 {
+		failure.ErrorMessage = strings.Join(message, "\n")
+	} else {
+		failure.ErrorMessage = firstNonEmptyLine(strings.Join(captured, "\n"))
+	}
+	failure.StackTrace = strings.TrimSpace(strings.Join(captured, "\n"))
+}
+
+// finalizeTestSummary fills the human-readable summary from the counters
+func finalizeTestSummary(summary *ParsedTestSummary) {
+	switch {
+	case len(summary.CompileErrors) > 0 && summary.TotalTests == 0:
+		summary.Summary = fmt.Sprintf("Build failed with %d error(s); no tests ran", len(summary.CompileErrors))
+	case summary.FailedTests > 0:
+		summary.Summary = fmt.Sprintf("%d test(s) failed out of %d total tests", summary.FailedTests, summary.TotalTests)
+	default:
+		summary.Summary = fmt.Sprintf("All %d tests passed", summary.TotalTests)
+	}
+}
+
+func formatSeconds(value string) string {
+	if value == "" {
+		return ""
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return fmt.Sprintf("%.3fs", seconds)
+	}
+	return value
+}
+
+func firstNonEmptyLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			return trimmed
+		}
+	}
+	return ""
+}
+
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func stripANSI(text string) string {
+	return ansiEscapePattern.ReplaceAllString(text, "")
+}
@@ -24,7 +24,7 @@ func (t *ParseTestResultsTool) Name() string {
 }
 
 func (t *ParseTestResultsTool) Description() string {
-	return "Parses raw test output to extract structured information about failed tests, error messages, and file locations"
+	return "Parses raw test output (go test, go test -json, jest, jest --json, pytest, JUnit XML, cargo test) to extract structured information about failed tests, error messages, and file locations"
 }
 
 func (t *ParseTestResultsTool) Parameters() json.RawMessage {
@@ -37,8 +37,8 @@ func (t *ParseTestResultsTool) Parameters() json.RawMessage {
 			},
 			"test_framework": {
 				"type": "string",
-				"enum": ["go", "jest", "pytest", "auto"],
-				"description": "Test framework type (default: auto-detect)"
+				"enum": ["go", "go-json", "jest", "jest-json", "pytest", "junit-xml", "cargo", "auto"],
+				"description": "Test framework or report format (default: auto-detect). Use junit-xml for pytest --junitxml reports"
 			}
 		},
 		"required": ["test_output"]
@@ -61,6 +61,7 @@ type ParsedTestFailure struct {
 }
 
 type ParsedTestSummary struct {
+	Framework     string              `json:"framework,omitempty"`
 	TotalTests    int                 `json:"total_tests"`
 	PassedTests   int                 `json:"passed_tests"`
 	FailedTests   int                 `json:"failed_tests"`
@@ -90,15 +91,29 @@ func (t *ParseTestResultsTool) Execute(ctx context.Context, params json.RawMessa
 		p.TestFramework = t.detectTestFramework(p.TestOutput)
 	}
 
+	// Structured reports take precedence over the framework's text output,
+	// e.g. "pytest" with a --junitxml report or "go" with -json events
+	if format := detectStructuredTestFormat(p.TestOutput); format != "" {
+		p.TestFramework = format
+	}
+
 	var summary ParsedTestSummary
 
 	switch p.TestFramework {
 	case "go":
 		summary = t.parseGoTestOutput(p.TestOutput)
+	case TestFormatGoJSON:
+		summary = t.parseGoJSONOutput(p.TestOutput)
 	case "jest":
 		summary = t.parseJestOutput(p.TestOutput)
+	case TestFormatJestJSON:
+		summary = t.parseJestJSONOutput(p.TestOutput)
 	case "pytest":
 		summary = t.parsePytestOutput(p.TestOutput)
+	case TestFormatJUnitXML:
+		summary = t.parseJUnitXMLOutput(p.TestOutput)
+	case TestFormatCargo:
+		summary = t.parseCargoOutput(p.TestOutput)
 	default:
 		// Fallback to Go parsing as it's most common in this codebase
 		summary = t.parseGoTestOutput(p.TestOutput)
@@ -111,6 +126,10 @@ func (t *ParseTestResultsTool) Execute(ctx context.Context, params json.RawMessa
 }
 
 func (t *ParseTestResultsTool) detectTestFramework(output string) string {
+	if format := detectStructuredTestFormat(output); format != "" {
+		return format
+	}
+
 	output = strings.ToLower(output)
 
 	if strings.Contains(output, "=== run") || strings.Contains(output, "--- fail") || strings.Contains(output, "--- pass") {
@@ -128,7 +147,8 @@ func (t *ParseTestResultsTool) detectTestFramework(output string) string {
 
 func (t *ParseTestResultsTool) parseGoTestOutput(output string) ParsedTestSummary {
 	summary := ParsedTestSummary{
-		Failures: []ParsedTestFailure{},
+		Framework: "go",
+		Failures:  []ParsedTestFailure{},
 	}
 
 	lines := strings.Split(output, "\n")
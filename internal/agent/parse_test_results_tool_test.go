@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func runParseTestResults(t *testing.T, output, framework string) ParsedTestSummary {
+	t.Helper()
+
+	tool := NewParseTestResultsTool(t.TempDir())
+	params, _ := json.Marshal(ParseTestResultsParams{TestOutput: output, TestFramework: framework})
+
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Error)
+	}
+
+	summary, ok := result.Data.(ParsedTestSummary)
+	if !ok {
+		t.Fatalf("Expected ParsedTestSummary, got %T", result.Data)
+	}
+	return summary
+}
+
+func TestParseTestResultsGoJSON(t *testing.T) {
+	output := `{"Action":"run","Package":"example.com/calc","Test":"TestAdd"}
+{"Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"pass","Package":"example.com/calc","Test":"TestAdd","Elapsed":0}
+{"Action":"run","Package":"example.com/calc","Test":"TestSub"}
+{"Action":"output","Package":"example.com/calc","Test":"TestSub","Output":"    calc_test.go:14: expected 1, got 2\n"}
+{"Action":"output","Package":"example.com/calc","Test":"TestSub","Output":"--- FAIL: TestSub (0.00s)\n"}
+{"Action":"fail","Package":"example.com/calc","Test":"TestSub","Elapsed":0}
+{"Action":"output","Package":"example.com/calc","Output":"coverage: 50.0% of statements\n"}
+{"Action":"fail","Package":"example.com/calc","Elapsed":0.012}`
+
+	summary := runParseTestResults(t, output, "auto")
+
+	if summary.Framework != TestFormatGoJSON {
+		t.Errorf("Expected framework %q, got %q", TestFormatGoJSON, summary.Framework)
+	}
+	if summary.TotalTests != 2 || summary.PassedTests != 1 || summary.FailedTests != 1 {
+		t.Errorf("Unexpected counts: %+v", summary)
+	}
+	if summary.Coverage != "50.0%" {
+		t.Errorf("Expected coverage 50.0%%, got %q", summary.Coverage)
+	}
+	if len(summary.CompileErrors) != 0 {
+		t.Errorf("Expected no compile errors, got %v", summary.CompileErrors)
+	}
+
+	failure := summary.Failures[0]
+	if failure.TestName != "TestSub" || failure.TestFile != "calc_test.go" || failure.Line != 14 {
+		t.Errorf("Unexpected failure location: %+v", failure)
+	}
+	if failure.ErrorMessage != "expected 1, got 2" {
+		t.Errorf("Unexpected failure message: %q", failure.ErrorMessage)
+	}
+}
+
+func TestParseTestResultsJUnitXML(t *testing.T) {
+	output := `<?xml version="1.0" encoding="utf-8"?>
+<testsuites><testsuite name="pytest" tests="3" time="0.041">
+<testcase classname="tests.test_math" name="test_ok" file="tests/test_math.py" line="3" time="0.001"/>
+<testcase classname="tests.test_math" name="test_div" file="tests/test_math.py" line="7" time="0.002">
+<failure message="AssertionError: assert 2 == 3">def test_div():
+&gt;       assert divide(6, 3) == 3
+E       AssertionError: assert 2 == 3
+
+tests/test_math.py:9: AssertionError</failure>
+</testcase>
+<testcase classname="tests.test_math" name="test_skip" time="0.000"><skipped message="not ready"/></testcase>
+</testsuite></testsuites>`
+
+	summary := runParseTestResults(t, output, "pytest")
+
+	if summary.Framework != TestFormatJUnitXML {
+		t.Errorf("Expected framework %q, got %q", TestFormatJUnitXML, summary.Framework)
+	}
+	if summary.TotalTests != 3 || summary.PassedTests != 1 || summary.FailedTests != 1 || summary.SkippedTests != 1 {
+		t.Errorf("Unexpected counts: %+v", summary)
+	}
+
+	failure := summary.Failures[0]
+	if failure.TestName != "tests.test_math::test_div" {
+		t.Errorf("Unexpected test name: %q", failure.TestName)
+	}
+	if failure.TestFile != "tests/test_math.py" || failure.Line != 9 {
+		t.Errorf("Expected failure at tests/test_math.py:9, got %s:%d", failure.TestFile, failure.Line)
+	}
+	if failure.ErrorMessage != "AssertionError: assert 2 == 3" {
+		t.Errorf("Unexpected failure message: %q", failure.ErrorMessage)
+	}
+}
+
+func TestParseTestResultsJestJSON(t *testing.T) {
+	output := `{"numTotalTests":2,"numPassedTests":1,"numFailedTests":1,"numPendingTests":0,
+"testResults":[{"name":"/app/src/sum.test.js","status":"failed","startTime":1000,"endTime":1250,
+"assertionResults":[
+{"fullName":"sum adds numbers","title":"adds numbers","status":"passed","failureMessages":[]},
+{"fullName":"sum handles negatives","title":"handles negatives","status":"failed",
+"failureMessages":["Error: expect(received).toBe(expected)\n\nExpected: -1\nReceived: 1\n    at Object.<anonymous> (/app/src/sum.test.js:12:20)"],
+"location":null}]}]}`
+
+	summary := runParseTestResults(t, output, "auto")
+
+	if summary.Framework != TestFormatJestJSON {
+		t.Errorf("Expected framework %q, got %q", TestFormatJestJSON, summary.Framework)
+	}
+	if summary.TotalTests != 2 || summary.FailedTests != 1 {
+		t.Errorf("Unexpected counts: %+v", summary)
+	}
+	if summary.Duration != "0.250s" {
+		t.Errorf("Expected duration 0.250s, got %q", summary.Duration)
+	}
+
+	failure := summary.Failures[0]
+	if failure.TestName != "sum handles negatives" || failure.TestFile != "/app/src/sum.test.js" || failure.Line != 12 {
+		t.Errorf("Unexpected failure: %+v", failure)
+	}
+	if failure.ErrorMessage != "Error: expect(received).toBe(expected)" {
+		t.Errorf("Unexpected failure message: %q", failure.ErrorMessage)
+	}
+}
+
+func TestParseTestResultsCargo(t *testing.T) {
+	output := `   Compiling calc v0.1.0 (/work/calc)
+    Finished test [unoptimized + debuginfo] target(s) in 0.52s
+     Running unittests src/lib.rs (target/debug/deps/calc-1234)
+
+running 3 tests
+test tests::adds ... ok
+test tests::ignored_case ... ignored
+test tests::divides ... FAILED
+
+failures:
+
+---- tests::divides stdout ----
+thread 'tests::divides' panicked at src/lib.rs:21:9:
+assertion ` + "`left == right`" + ` failed
+  left: 2
+ right: 3
+note: run with ` + "`RUST_BACKTRACE=1`" + ` environment variable to display a backtrace
+
+
+failures:
+    tests::divides
+
+test result: FAILED. 1 passed; 1 failed; 1 ignored; 0 measured; 0 filtered out; finished in 0.01s
+`
+
+	summary := runParseTestResults(t, output, "auto")
+
+	if summary.Framework != TestFormatCargo {
+		t.Errorf("Expected framework %q, got %q", TestFormatCargo, summary.Framework)
+	}
+	if summary.TotalTests != 3 || summary.PassedTests != 1 || summary.FailedTests != 1 || summary.SkippedTests != 1 {
+		t.Errorf("Unexpected counts: %+v", summary)
+	}
+	if summary.Duration != "0.01s" {
+		t.Errorf("Expected duration 0.01s, got %q", summary.Duration)
+	}
+
+	failure := summary.Failures[0]
+	if failure.TestName != "tests::divides" || failure.FailureType != "panic" {
+		t.Errorf("Unexpected failure: %+v", failure)
+	}
+	if failure.TestFile != "src/lib.rs" || failure.Line != 21 {
+		t.Errorf("Expected failure at src/lib.rs:21, got %s:%d", failure.TestFile, failure.Line)
+	}
+	if failure.ErrorMessage != "assertion `left == right` failed\nleft: 2\nright: 3" {
+		t.Errorf("Unexpected failure message: %q", failure.ErrorMessage)
+	}
+}
@@ -3,12 +3,16 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/castrovroberto/CGE/internal/patchutils"
+	"github.com/castrovroberto/CGE/internal/security"
 )
 
 // PatchApplyTool implements patch/diff application capabilities
@@ -28,7 +32,7 @@ func (t *PatchApplyTool) Name() string {
 }
 
 func (t *PatchApplyTool) Description() string {
-	return "Applies a diff/patch (in unified diff format) to a specified file. Creates backup before applying."
+	return "Applies a diff/patch (in unified diff format) to a specified file. Creates backup before applying. Fails with FILE_CONFLICT if the file changed since it was read, unless on_conflict is \"merge\"."
 }
 
 func (t *PatchApplyTool) Parameters() json.RawMessage {
@@ -47,6 +51,12 @@ func (t *PatchApplyTool) Parameters() json.RawMessage {
 				"type": "boolean",
 				"description": "Whether to create a backup of the original file",
 				"default": true
+			},
+			"on_conflict": {
+				"type": "string",
+				"description": "What to do when the file changed since it was last read: fail, or apply the patch to the version read and merge three-way",
+				"enum": ["fail", "merge"],
+				"default": "fail"
 			}
 		},
 		"required": ["file_path", "patch_content"]
@@ -57,6 +67,7 @@ type PatchApplyParams struct {
 	FilePath       string `json:"file_path"`
 	PatchContent   string `json:"patch_content"`
 	BackupOriginal bool   `json:"backup_original"`
+	OnConflict     string `json:"on_conflict,omitempty"`
 }
 
 type PatchHunk struct {
@@ -97,12 +108,18 @@ func (t *PatchApplyTool) Execute(ctx context.Context, params json.RawMessage) (*
 	// Security check: ensure path is within workspace
 	fullPath := filepath.Join(t.workspaceRoot, p.FilePath)
 	cleanPath := filepath.Clean(fullPath)
-	if !strings.HasPrefix(cleanPath, filepath.Clean(t.workspaceRoot)) {
+	if !security.IsWithin(t.workspaceRoot, cleanPath) {
 		return &ToolResult{
 			Success: false,
 			Error:   "file path is outside workspace root",
 		}, nil
 	}
+	if protected := CheckProtected(ctx, cleanPath, p.FilePath); protected != nil {
+		return NewErrorResult(protected), nil
+	}
+	if protected := CheckProtectedPatch(ctx, t.workspaceRoot, p.PatchContent); protected != nil {
+		return NewErrorResult(protected), nil
+	}
 
 	// Check if file exists
 	if _, err := os.Stat(cleanPath); os.IsNotExist(err) {
@@ -121,6 +138,13 @@ func (t *PatchApplyTool) Execute(ctx context.Context, params json.RawMessage) (*
 		}, nil
 	}
 
+	// The patch was written against the version the agent read; applying it
+	// to a file changed since could lose those changes
+	base, conflict := checkFileVersion(ctx, cleanPath, p.FilePath, originalContent, true)
+	if conflict != nil && p.OnConflict != OnConflictMerge {
+		return NewErrorResult(conflict), nil
+	}
+
 	// Create backup if requested
 	var backupPath string
 	if p.BackupOriginal {
@@ -142,13 +166,23 @@ func (t *PatchApplyTool) Execute(ctx context.Context, params json.RawMessage) (*
 		}, nil
 	}
 
-	// Apply patch
-	patchedContent, err := t.applyPatch(string(originalContent), hunks)
+	// Apply patch; after a conflict, to the version it was written against,
+	// merging the result with the current content
+	var patchedContent string
+	if conflict != nil {
+		patchedContent, err = t.applyPatchAndMerge(p.FilePath, string(base), string(originalContent), hunks)
+	} else {
+		patchedContent, err = t.applyPatch(string(originalContent), hunks)
+	}
 	if err != nil {
 		// Clean up backup on failure
 		if p.BackupOriginal && backupPath != "" {
 			os.Remove(backupPath)
 		}
+		var conflictErr *StandardizedToolError
+		if errors.As(err, &conflictErr) {
+			return NewErrorResult(conflictErr), nil
+		}
 		return &ToolResult{
 			Success: false,
 			Error:   fmt.Sprintf("failed to apply patch: %v", err),
@@ -156,6 +190,12 @@ func (t *PatchApplyTool) Execute(ctx context.Context, params json.RawMessage) (*
 	}
 
 	// Write patched content
+	if err := SnapshotFile(ctx, cleanPath); err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("failed to checkpoint file: %v", err),
+		}, nil
+	}
 	if err := os.WriteFile(cleanPath, []byte(patchedContent), 0600); err != nil {
 		// Try to restore from backup on write failure
 		if p.BackupOriginal && backupPath != "" {
@@ -168,6 +208,8 @@ func (t *PatchApplyTool) Execute(ctx context.Context, params json.RawMessage) (*
 		}, nil
 	}
 
+	RecordFileVersion(ctx, cleanPath, []byte(patchedContent))
+
 	result := map[string]interface{}{
 		"file_path":     p.FilePath,
 		"hunks_applied": len(hunks),
@@ -175,6 +217,10 @@ func (t *PatchApplyTool) Execute(ctx context.Context, params json.RawMessage) (*
 		"patched_size":  len(patchedContent),
 		"message":       fmt.Sprintf("Successfully applied patch to %s", p.FilePath),
 	}
+	if conflict != nil {
+		result["merged"] = true
+		result["message"] = fmt.Sprintf("Applied patch to %s and merged it with the changes made since it was read", p.FilePath)
+	}
 
 	if p.BackupOriginal {
 		result["backup_path"] = strings.TrimPrefix(backupPath, t.workspaceRoot+string(filepath.Separator))
@@ -186,6 +232,25 @@ func (t *PatchApplyTool) Execute(ctx context.Context, params json.RawMessage) (*
 	}, nil
 }
 
+// applyPatchAndMerge applies hunks to base, the version they were written
+// against, and merges the result with current three-way. Overlapping changes
+// fail with a FILE_CONFLICT error.
+func (t *PatchApplyTool) applyPatchAndMerge(relPath, base, current string, hunks []PatchHunk) (string, error) {
+	patched, err := t.applyPatch(base, hunks)
+	if err != nil {
+		return "", err
+	}
+	merge := patchutils.Merge3(base, patched, current)
+	if merge.Conflicts > 0 {
+		return "", NewStandardizedError(
+			ErrorCodeFileConflict,
+			fmt.Sprintf("%s changed since it was last read and %d region(s) conflict with the patch", relPath, merge.Conflicts),
+			"Read the file again and write the patch against its current content",
+		).WithDetail("file_path", relPath).WithDetail("conflicts", merge.Conflicts)
+	}
+	return merge.Content, nil
+}
+
 // parsePatch parses a unified diff format patch
 func (t *PatchApplyTool) parsePatch(patchContent string) ([]PatchHunk, error) {
 	lines := strings.Split(patchContent, "\n")
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/castrovroberto/CGE/internal/patchutils"
 )
@@ -108,6 +110,32 @@ func (t *EnhancedPatchApplyTool) Execute(ctx context.Context, params json.RawMes
 	// Create a new applier with the specific options
 	applier := patchutils.NewPatchApplier(t.workspaceRoot, options)
 
+	target := p.FilePath
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(t.workspaceRoot, target)
+	}
+	if protected := CheckProtected(ctx, filepath.Clean(target), p.FilePath); protected != nil {
+		return NewErrorResult(protected), nil
+	}
+	if protected := CheckProtectedPatch(ctx, t.workspaceRoot, p.PatchContent); protected != nil {
+		return NewErrorResult(protected), nil
+	}
+	if !p.DryRun {
+		// A patch written against an older version could lose the changes
+		// made since the agent read the file
+		if current, err := os.ReadFile(target); err == nil { // #nosec G304 - the applier validates the path
+			if _, conflict := checkFileVersion(ctx, target, p.FilePath, current, false); conflict != nil {
+				return NewErrorResult(conflict), nil
+			}
+		}
+		if err := SnapshotFile(ctx, target); err != nil {
+			return &ToolResult{
+				Success: false,
+				Error:   fmt.Sprintf("failed to checkpoint file: %v", err),
+			}, nil
+		}
+	}
+
 	// Apply the patch
 	result, err := applier.ApplyPatch(p.FilePath, p.PatchContent)
 	if err != nil {
@@ -130,6 +158,11 @@ func (t *EnhancedPatchApplyTool) Execute(ctx context.Context, params json.RawMes
 	if result.BackupPath != "" {
 		responseData["backup_path"] = result.BackupPath
 	}
+	if !p.DryRun {
+		if patched, err := os.ReadFile(target); err == nil { // #nosec G304 - the applier validated the path
+			RecordFileVersion(ctx, target, patched)
+		}
+	}
 
 	if p.DryRun {
 		responseData["message"] = fmt.Sprintf("Dry run: Would apply patch to %s (%d hunks, %d lines changed)",
@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SubmitPlanToolName is the name of the tool the agent records its plan with
+// in plan mode
+const SubmitPlanToolName = "submit_plan"
+
+// PlanTask is a step of a Plan
+type PlanTask struct {
+	ID              string   `json:"id" description:"Short unique ID, e.g. task-1" jsonschema:"required,minLength=1"`
+	Description     string   `json:"description" description:"What the step changes and how" jsonschema:"required,minLength=5"`
+	FilesToModify   []string `json:"files_to_modify,omitempty" description:"Existing files the step changes"`
+	FilesToCreate   []string `json:"files_to_create,omitempty" description:"Files the step adds"`
+	FilesToDelete   []string `json:"files_to_delete,omitempty" description:"Files the step removes"`
+	EstimatedEffort string   `json:"estimated_effort,omitempty" description:"Rough size of the step" jsonschema:"enum=small|medium|large"` // e.g., "small", "medium", "large"
+	Dependencies    []string `json:"dependencies,omitempty" description:"IDs of the steps that must be done first"`
+	Rationale       string   `json:"rationale,omitempty" description:"Why the step is needed"`
+}
+
+// Plan is a development plan: the plan.json written by 'CGE plan' and run by
+// 'CGE generate', and the artifact of plan mode in the chat
+type Plan struct {
+	OverallGoal            string     `json:"overall_goal" description:"The goal the plan reaches" jsonschema:"required,minLength=5"`
+	Tasks                  []PlanTask `json:"tasks" description:"The steps, in the order they should be done" jsonschema:"required,minItems=1"`
+	Summary                string     `json:"summary,omitempty" description:"A short overview of the approach"`
+	EstimatedTotalEffort   string     `json:"estimated_total_effort,omitempty" description:"Rough size of the whole plan"`
+	RisksAndConsiderations []string   `json:"risks_and_considerations,omitempty" description:"Risks, open questions and trade-offs the user should weigh"`
+}
+
+// Validate checks that the tasks of the plan have unique IDs and depend only
+// on tasks of the plan
+func (p *Plan) Validate() error {
+	ids := make(map[string]bool, len(p.Tasks))
+	for _, task := range p.Tasks {
+		if ids[task.ID] {
+			return fmt.Errorf("task ID %q is used twice", task.ID)
+		}
+		ids[task.ID] = true
+	}
+	for _, task := range p.Tasks {
+		for _, dependency := range task.Dependencies {
+			if !ids[dependency] {
+				return fmt.Errorf("task %s depends on %q, which is not a task of the plan", task.ID, dependency)
+			}
+		}
+	}
+	return nil
+}
+
+// Markdown renders the plan for the user to read
+func (p *Plan) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Plan: %s\n", p.OverallGoal)
+	if p.Summary != "" {
+		fmt.Fprintf(&b, "\n%s\n", p.Summary)
+	}
+	b.WriteString("\n")
+	for i, task := range p.Tasks {
+		fmt.Fprintf(&b, "%d. **%s** %s", i+1, task.ID, task.Description)
+		if task.EstimatedEffort != "" {
+			fmt.Fprintf(&b, " _(%s)_", task.EstimatedEffort)
+		}
+		b.WriteString("\n")
+		for _, files := range []struct {
+			label string
+			paths []string
+		}{{"modify", task.FilesToModify}, {"create", task.FilesToCreate}, {"delete", task.FilesToDelete}} {
+			if len(files.paths) > 0 {
+				fmt.Fprintf(&b, "   - %s: %s\n", files.label, strings.Join(files.paths, ", "))
+			}
+		}
+		if len(task.Dependencies) > 0 {
+			fmt.Fprintf(&b, "   - after: %s\n", strings.Join(task.Dependencies, ", "))
+		}
+	}
+	if len(p.RisksAndConsiderations) > 0 {
+		b.WriteString("\n**Risks and considerations**\n")
+		for _, risk := range p.RisksAndConsiderations {
+			fmt.Fprintf(&b, "- %s\n", risk)
+		}
+	}
+	return b.String()
+}
+
+// PlanDraft holds the plan being worked out in plan mode, revised each time
+// the agent submits it. It is passed to submit_plan through the Execute
+// context, see WithPlanDraft.
+type PlanDraft struct {
+	mu       sync.Mutex
+	plan     *Plan
+	revision int
+}
+
+// Set replaces the plan of the draft
+func (d *PlanDraft) Set(plan *Plan) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan = plan
+	d.revision++
+}
+
+// Plan returns the plan of the draft, nil before one was submitted, and how
+// many times it was revised
+func (d *PlanDraft) Plan() (*Plan, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.plan, d.revision
+}
+
+// Clear drops the plan of the draft
+func (d *PlanDraft) Clear() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan = nil
+	d.revision = 0
+}
+
+type planDraftKey struct{}
+
+// WithPlanDraft returns a context in which submit_plan records plans in draft
+func WithPlanDraft(ctx context.Context, draft *PlanDraft) context.Context {
+	return context.WithValue(ctx, planDraftKey{}, draft)
+}
+
+// PlanDraftFromContext returns the plan draft carried by ctx, if any
+func PlanDraftFromContext(ctx context.Context) (*PlanDraft, bool) {
+	draft, ok := ctx.Value(planDraftKey{}).(*PlanDraft)
+	return draft, ok && draft != nil
+}
+
+// NewSubmitPlanTool creates the submit_plan tool, with which the agent
+// records a structured plan in plan mode
+func NewSubmitPlanTool() Tool {
+	return NewTypedTool(SubmitPlanToolName, `Records your development plan for the user to review. Call it once you understand the request and the code it touches, with the complete plan: every call replaces the previous plan, so include the steps that stay unchanged when revising it. The user reviews the plan, asks for changes, and runs it with /execute.
+
+IMPORTANT NOTES:
+- Name the files each step changes, creates or deletes, using paths relative to the workspace root
+- Order the steps so each one comes after the steps it depends on
+- List open questions and risks under risks_and_considerations rather than guessing`,
+		func(ctx context.Context, plan Plan) (interface{}, error) {
+			draft, ok := PlanDraftFromContext(ctx)
+			if !ok {
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, "plans can only be submitted in plan mode", "Describe the plan in your answer instead")
+			}
+			if err := plan.Validate(); err != nil {
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, fmt.Sprintf("invalid plan: %v", err), "Fix the task IDs and dependencies and submit the plan again")
+			}
+			draft.Set(&plan)
+			_, revision := draft.Plan()
+			return map[string]interface{}{
+				"revision": revision,
+				"tasks":    len(plan.Tasks),
+				"message":  "Plan recorded. Summarize it briefly for the user and ask whether to change anything; they run it with /execute.",
+			}, nil
+		})
+}
+
+// readOnlyTools are the tools that never change the workspace, which the
+// agent keeps in plan mode. db_query is left out as it may be allowed to
+// write.
+var readOnlyTools = map[string]bool{
+	"read_file":                   true,
+	"codebase_search":             true,
+	"grep_codebase":               true,
+	"list_directory":              true,
+	"git_info":                    true,
+	"get_api_spec":                true,
+	"impact_of_change":            true,
+	"retrieve_context":            true,
+	"analyze_codebase":            true,
+	"analyze_advanced":            true,
+	"parse_test_results":          true,
+	"parse_lint_results":          true,
+	"request_human_clarification": true,
+	"db_schema":                   true,
+	"docker_ps":                   true,
+	"docker_logs":                 true,
+	"compose_config":              true,
+	ReadToolOutputName:            true,
+	SubmitPlanToolName:            true,
+	ScratchpadWriteToolName:       true,
+	ScratchpadReadToolName:        true,
+	UpdateTasksToolName:           true,
+	ListTasksToolName:             true,
+}
+
+// ReadOnlyTool reports whether the tool called name never changes the
+// workspace
+func ReadOnlyTool(name string) bool {
+	return readOnlyTools[name]
+}
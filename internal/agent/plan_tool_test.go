@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSubmitPlanTool(t *testing.T) {
+	tool := NewSubmitPlanTool()
+	draft := &PlanDraft{}
+	ctx := WithPlanDraft(context.Background(), draft)
+	plan := `{
+		"overall_goal": "Add rate limiting to the API",
+		"tasks": [
+			{"id": "task-1", "description": "Add a limiter middleware", "files_to_create": ["api/limiter.go"], "estimated_effort": "small"},
+			{"id": "task-2", "description": "Register the middleware", "files_to_modify": ["api/server.go"], "dependencies": ["task-1"]}
+		],
+		"risks_and_considerations": ["Limits per user or per IP?"]
+	}`
+
+	result, err := tool.Execute(ctx, json.RawMessage(plan))
+	if err != nil || !result.Success {
+		t.Fatalf("expected the plan to be recorded, got %v %+v", err, result)
+	}
+	recorded, revision := draft.Plan()
+	if recorded == nil || revision != 1 || len(recorded.Tasks) != 2 || recorded.Tasks[1].Dependencies[0] != "task-1" {
+		t.Fatalf("unexpected draft: revision %d, %+v", revision, recorded)
+	}
+	markdown := recorded.Markdown()
+	for _, want := range []string{"## Plan: Add rate limiting to the API", "1. **task-1** Add a limiter middleware _(small)_", "   - create: api/limiter.go", "   - after: task-1", "- Limits per user or per IP?"} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("expected the rendered plan to contain %q, got:\n%s", want, markdown)
+		}
+	}
+
+	if _, err := tool.Execute(ctx, json.RawMessage(plan)); err != nil {
+		t.Fatal(err)
+	}
+	if _, revision := draft.Plan(); revision != 2 {
+		t.Errorf("expected a second submission to revise the plan, got revision %d", revision)
+	}
+}
+
+func TestSubmitPlanToolRejects(t *testing.T) {
+	tool := NewSubmitPlanTool()
+	ctx := WithPlanDraft(context.Background(), &PlanDraft{})
+	tests := map[string]struct {
+		ctx    context.Context
+		params string
+	}{
+		"outside plan mode":  {context.Background(), `{"overall_goal": "Add a cache", "tasks": [{"id": "a", "description": "Add the cache"}]}`},
+		"no tasks":           {ctx, `{"overall_goal": "Add a cache", "tasks": []}`},
+		"duplicate ID":       {ctx, `{"overall_goal": "Add a cache", "tasks": [{"id": "a", "description": "Add the cache"}, {"id": "a", "description": "Test the cache"}]}`},
+		"unknown dependency": {ctx, `{"overall_goal": "Add a cache", "tasks": [{"id": "a", "description": "Add the cache", "dependencies": ["b"]}]}`},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := tool.Execute(tt.ctx, json.RawMessage(tt.params))
+			if err == nil && result.Success {
+				t.Error("expected the plan to be rejected")
+			}
+		})
+	}
+}
+
+func TestPlanningToolsAreReadOnly(t *testing.T) {
+	for _, tool := range NewToolFactory(t.TempDir()).CreatePlanningRegistry().List() {
+		if !ReadOnlyTool(tool.Name()) {
+			t.Errorf("planning tool %s is not known to be read-only", tool.Name())
+		}
+	}
+	for _, name := range []string{"write_file", "run_shell_command", "git_commit", "run_tests", "delegate_task", "db_query"} {
+		if ReadOnlyTool(name) {
+			t.Errorf("expected %s not to be read-only", name)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package agent
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// platformShellCommands are allowed in the shell tool in addition to the
+// defaults
+var platformShellCommands []string
+
+// interruptProcess asks a cancelled subprocess to stop, so it can clean up
+func interruptProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Signal(os.Interrupt)
+}
+
+// commandName returns the name a command is allowed by
+func commandName(command string) string {
+	return filepath.Base(command)
+}
+
+// shellCommand builds the command run by the shell tool. It runs directly,
+// without a shell, so its arguments are never reinterpreted.
+func shellCommand(ctx context.Context, name string, args []string) (*exec.Cmd, error) {
+	return exec.CommandContext(ctx, name, args...), nil
+}
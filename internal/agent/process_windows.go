@@ -0,0 +1,62 @@
+//go:build windows
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// platformShellCommands are allowed in the shell tool in addition to the
+// defaults: the Windows counterparts of ls, cat, which and grep
+var platformShellCommands = []string{"dir", "type", "where", "findstr", "ver"}
+
+// cmdBuiltins are commands built into cmd.exe, which have no executable of
+// their own
+var cmdBuiltins = map[string]bool{"dir": true, "type": true, "echo": true, "ver": true, "cd": true}
+
+// cmdMetacharacters would let an argument run further commands through
+// cmd.exe
+const cmdMetacharacters = "&|<>^%!\"()"
+
+// interruptProcess stops a cancelled subprocess and the processes it started.
+// Windows has no interrupt signal for child processes, nor process groups to
+// signal at once, so the whole tree is ended with taskkill, falling back to
+// killing the process itself.
+func interruptProcess(cmd *exec.Cmd) error {
+	kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)) // #nosec G204 - fixed arguments
+	if err := kill.Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
+
+// commandName returns the name a command is allowed by. Windows resolves
+// commands case-insensitively and without their executable extension.
+func commandName(command string) string {
+	name := strings.ToLower(filepath.Base(command))
+	switch filepath.Ext(name) {
+	case ".exe", ".com", ".bat", ".cmd":
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+// shellCommand builds the command run by the shell tool. Executables run
+// directly; cmd.exe builtins such as dir run through cmd /C, provided their
+// arguments cannot chain other commands.
+func shellCommand(ctx context.Context, name string, args []string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath(name); err == nil || !cmdBuiltins[commandName(name)] {
+		return exec.CommandContext(ctx, name, args...), nil
+	}
+	for _, arg := range args {
+		if strings.ContainsAny(arg, cmdMetacharacters) {
+			return nil, fmt.Errorf("argument %q contains characters cmd.exe would interpret", arg)
+		}
+	}
+	return exec.CommandContext(ctx, "cmd.exe", append([]string{"/D", "/C", commandName(name)}, args...)...), nil // #nosec G204 - builtin from a fixed list, arguments checked above
+}
@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/castrovroberto/CGE/internal/audit"
+)
+
+// ProgressUpdate is a progress report from a running tool call
+type ProgressUpdate struct {
+	ToolName   string
+	CallID     string
+	Progress   float64 // 0.0 to 1.0; negative when the total amount of work is unknown
+	Status     string  // Current status description
+	Step       int     // Current step number
+	TotalSteps int     // Total number of steps; 0 when unknown
+	Done       bool    // The tool call has finished
+
+	// Error explains why a finished tool call failed; nil otherwise
+	Error *StandardizedToolError
+}
+
+// ProgressReporter receives progress updates from running tools. A reporter is
+// passed to tools through their Execute context, see WithProgressReporter.
+type ProgressReporter interface {
+	Report(update ProgressUpdate)
+}
+
+// ProgressReporterFunc adapts a function to the ProgressReporter interface
+type ProgressReporterFunc func(update ProgressUpdate)
+
+// Report calls f(update)
+func (f ProgressReporterFunc) Report(update ProgressUpdate) {
+	f(update)
+}
+
+type progressReporterKey struct{}
+
+// WithProgressReporter returns a context that carries reporter to the tools
+// executed with it
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, reporter)
+}
+
+// ProgressReporterFromContext returns the reporter carried by ctx, if any
+func ProgressReporterFromContext(ctx context.Context) (ProgressReporter, bool) {
+	reporter, ok := ctx.Value(progressReporterKey{}).(ProgressReporter)
+	return reporter, ok && reporter != nil
+}
+
+// WithToolProgress scopes the reporter carried by ctx to one tool call, so the
+// updates a tool reports are stamped with its name and call ID. ctx is
+// returned unchanged when it carries no reporter.
+func WithToolProgress(ctx context.Context, toolName, callID string) context.Context {
+	parent, ok := ProgressReporterFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return WithProgressReporter(ctx, ProgressReporterFunc(func(update ProgressUpdate) {
+		update.ToolName = toolName
+		update.CallID = callID
+		parent.Report(update)
+	}))
+}
+
+// ReportProgress reports the progress of the tool running with ctx. It does
+// nothing when ctx carries no reporter, so tools can call it unconditionally.
+func ReportProgress(ctx context.Context, progress float64, status string, step, totalSteps int) {
+	if reporter, ok := ProgressReporterFromContext(ctx); ok {
+		reporter.Report(ProgressUpdate{Progress: progress, Status: status, Step: step, TotalSteps: totalSteps})
+	}
+}
+
+// FinishProgress reports that the tool call running with ctx has finished
+func FinishProgress(ctx context.Context, status string) {
+	if reporter, ok := ProgressReporterFromContext(ctx); ok {
+		reporter.Report(ProgressUpdate{Progress: 1.0, Status: status, Done: true})
+	}
+}
+
+// FailProgress reports that the tool call running with ctx has failed with err
+func FailProgress(ctx context.Context, err *StandardizedToolError) {
+	if reporter, ok := ProgressReporterFromContext(ctx); ok {
+		reporter.Report(ProgressUpdate{Progress: 1.0, Status: "Failed", Done: true, Error: err})
+	}
+}
+
+// ProgressCallbackFromContext adapts the reporter carried by ctx to a
+// ProgressCallback for ProgressAwareTool implementations. It returns nil when
+// ctx carries no reporter.
+func ProgressCallbackFromContext(ctx context.Context) ProgressCallback {
+	if _, ok := ProgressReporterFromContext(ctx); !ok {
+		return nil
+	}
+	return func(progress float64, status string, step, totalSteps int) {
+		ReportProgress(ctx, progress, status, step, totalSteps)
+	}
+}
+
+// MultiProgressReporter sends every update to each of reporters
+func MultiProgressReporter(reporters ...ProgressReporter) ProgressReporter {
+	return ProgressReporterFunc(func(update ProgressUpdate) {
+		for _, reporter := range reporters {
+			reporter.Report(update)
+		}
+	})
+}
+
+// NewAuditProgressReporter records progress updates in the audit log
+func NewAuditProgressReporter(logger *audit.AuditLogger) ProgressReporter {
+	return ProgressReporterFunc(func(update ProgressUpdate) {
+		logger.LogToolProgress(update.ToolName, update.CallID, update.Progress, update.Status, update.Step, update.TotalSteps, update.Done)
+	})
+}
+
+// progressLineInterval limits how often command output is reported as progress
+const progressLineInterval = 200 * time.Millisecond
+
+// maxProgressStatusLength truncates output lines used as progress status
+const maxProgressStatusLength = 120
+
+// progressLineWriter collects the output of a command and reports the latest
+// output line as indeterminate progress, at most once per progressLineInterval
+type progressLineWriter struct {
+	ctx        context.Context
+	prefix     string // Put before reported lines, e.g. to tell stderr from stdout
+	mu         sync.Mutex
+	buf        bytes.Buffer
+	lines      int
+	lastReport time.Time
+}
+
+// newProgressLineWriter creates a writer reporting to the reporter carried by ctx
+func newProgressLineWriter(ctx context.Context) *progressLineWriter {
+	return &progressLineWriter{ctx: ctx}
+}
+
+// Write implements io.Writer
+func (w *progressLineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	newLines := bytes.Count(p, []byte{'\n'})
+	if newLines == 0 {
+		return len(p), nil
+	}
+	w.lines += newLines
+
+	if time.Since(w.lastReport) < progressLineInterval {
+		return len(p), nil
+	}
+	w.lastReport = time.Now()
+
+	// The last complete line ends at the last newline
+	data := w.buf.Bytes()
+	data = data[:bytes.LastIndexByte(data, '\n')]
+	line := data[bytes.LastIndexByte(data, '\n')+1:]
+	status := w.prefix + strings.TrimSpace(string(line))
+	if len(status) > maxProgressStatusLength {
+		cut := maxProgressStatusLength
+		for cut > 0 && !utf8.RuneStart(status[cut]) {
+			cut-- // Don't split a multi-byte character
+		}
+		status = status[:cut] + "..."
+	}
+	ReportProgress(w.ctx, -1, status, w.lines, 0)
+	return len(p), nil
+}
+
+// Bytes returns all output written so far
+func (w *progressLineWriter) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Bytes()
+}
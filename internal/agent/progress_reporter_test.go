@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingReporter collects the updates it receives
+type recordingReporter struct {
+	mu      sync.Mutex
+	updates []ProgressUpdate
+}
+
+func (r *recordingReporter) Report(update ProgressUpdate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates = append(r.updates, update)
+}
+
+func (r *recordingReporter) Updates() []ProgressUpdate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ProgressUpdate(nil), r.updates...)
+}
+
+func TestProgressReporterContext(t *testing.T) {
+	t.Run("no reporter", func(t *testing.T) {
+		ctx := context.Background()
+		assert.NotPanics(t, func() { ReportProgress(ctx, 0.5, "working", 1, 2) })
+		assert.Nil(t, ProgressCallbackFromContext(ctx))
+		assert.Equal(t, ctx, WithToolProgress(ctx, "tool", "call-1"))
+	})
+
+	t.Run("tool scope stamps updates", func(t *testing.T) {
+		reporter := &recordingReporter{}
+		ctx := WithToolProgress(WithProgressReporter(context.Background(), reporter), "run_tests", "call-1")
+
+		ReportProgress(ctx, 0.5, "halfway", 1, 2)
+		ProgressCallbackFromContext(ctx)(0.75, "almost", 2, 2)
+		FinishProgress(ctx, "Completed")
+
+		updates := reporter.Updates()
+		require.Len(t, updates, 3)
+		assert.Equal(t, ProgressUpdate{ToolName: "run_tests", CallID: "call-1", Progress: 0.5, Status: "halfway", Step: 1, TotalSteps: 2}, updates[0])
+		assert.Equal(t, "almost", updates[1].Status)
+		assert.True(t, updates[2].Done)
+		assert.Equal(t, "call-1", updates[2].CallID)
+	})
+
+	t.Run("failure carries the error", func(t *testing.T) {
+		reporter := &recordingReporter{}
+		ctx := WithToolProgress(WithProgressReporter(context.Background(), reporter), "read_file", "call-2")
+
+		FailProgress(ctx, NewFileNotFoundError("missing.go"))
+
+		updates := reporter.Updates()
+		require.Len(t, updates, 1)
+		assert.True(t, updates[0].Done)
+		require.NotNil(t, updates[0].Error)
+		assert.Equal(t, ErrorCodeFileNotFound, updates[0].Error.Code)
+	})
+}
+
+func TestProgressLineWriter(t *testing.T) {
+	reporter := &recordingReporter{}
+	w := newProgressLineWriter(WithProgressReporter(context.Background(), reporter))
+
+	w.Write([]byte("first line\nsecond"))
+	w.Write([]byte(" line\nthird")) // Within the interval: not reported
+
+	updates := reporter.Updates()
+	require.Len(t, updates, 1)
+	assert.Equal(t, "first line", updates[0].Status, "partial lines are not reported")
+	assert.Equal(t, -1.0, updates[0].Progress)
+	assert.Equal(t, 1, updates[0].Step)
+
+	w.lastReport = time.Time{}
+	w.Write([]byte(" partial\n"))
+	updates = reporter.Updates()
+	require.Len(t, updates, 2)
+	assert.Equal(t, "third partial", updates[1].Status)
+	assert.Equal(t, 3, updates[1].Step)
+
+	assert.Equal(t, "first line\nsecond line\nthird partial\n", string(w.Bytes()))
+}
+
+func TestShellRunToolReportsProgress(t *testing.T) {
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip("echo not available")
+	}
+
+	reporter := &recordingReporter{}
+	ctx := WithProgressReporter(context.Background(), reporter)
+	tool := NewShellRunTool(t.TempDir())
+
+	params, _ := json.Marshal(map[string]interface{}{"command": "echo hello"})
+	result, err := tool.Execute(ctx, params)
+	require.NoError(t, err)
+	require.True(t, result.Success)
+	assert.Equal(t, "hello\n", result.Data.(map[string]interface{})["stdout"])
+
+	updates := reporter.Updates()
+	require.GreaterOrEqual(t, len(updates), 2)
+	assert.Equal(t, "Running echo hello", updates[0].Status)
+	assert.Equal(t, "hello", updates[len(updates)-1].Status)
+}
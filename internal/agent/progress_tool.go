@@ -77,6 +77,11 @@ func (ptw *ProgressToolWrapper) Execute(ctx context.Context, params json.RawMess
 		}
 	}
 
+	// Tools that report through their context feed the same callback
+	ctx = WithProgressReporter(ctx, ProgressReporterFunc(func(update ProgressUpdate) {
+		progressCallback(update.Progress, update.Status, update.Step, update.TotalSteps)
+	}))
+
 	// Execute the tool
 	var result *ToolResult
 	var err error
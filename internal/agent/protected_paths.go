@@ -0,0 +1,323 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/security"
+)
+
+// PathGuard refuses changes to protected files: paths matching never-edit
+// globs such as "vendor/**", "*.lock" or "migrations/**". Globs are relative
+// to the workspace root, even when tools are restricted to a scope inside it.
+type PathGuard struct {
+	workspaceRoot string
+	globs         []string
+}
+
+// NewPathGuard creates a guard protecting the paths of workspaceRoot matching
+// globs; it is nil, and protects nothing, when there are no globs
+func NewPathGuard(workspaceRoot string, globs []string) *PathGuard {
+	if len(globs) == 0 {
+		return nil
+	}
+	if abs, err := filepath.Abs(workspaceRoot); err == nil {
+		workspaceRoot = abs
+	}
+	return &PathGuard{workspaceRoot: workspaceRoot, globs: globs}
+}
+
+type pathGuardKey struct{}
+
+// WithPathGuard returns a context whose tool calls refuse to change the files
+// guard protects
+func WithPathGuard(ctx context.Context, guard *PathGuard) context.Context {
+	if guard == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, pathGuardKey{}, guard)
+}
+
+// PathGuardFromContext returns the guard of a tool call, or nil when nothing
+// is protected
+func PathGuardFromContext(ctx context.Context) *PathGuard {
+	guard, _ := ctx.Value(pathGuardKey{}).(*PathGuard)
+	return guard
+}
+
+// Globs returns the never-edit globs of the guard
+func (g *PathGuard) Globs() []string {
+	if g == nil {
+		return nil
+	}
+	return g.globs
+}
+
+// Match returns the glob protecting absPath, reporting false when the path is
+// not protected or is outside the workspace
+func (g *PathGuard) Match(absPath string) (string, bool) {
+	if g == nil || !security.IsWithin(g.workspaceRoot, absPath) {
+		return "", false
+	}
+	rel, err := filepath.Rel(g.workspaceRoot, absPath)
+	if err != nil || rel == "." {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, glob := range g.globs {
+		// "vendor/**" protects the directory itself as well as its contents:
+		// a directory is protected when the files in it would be
+		if matchGlob(glob, rel) || (strings.HasSuffix(glob, "/**") && matchGlob(glob, rel+"/file")) {
+			return glob, true
+		}
+	}
+	return "", false
+}
+
+// PathPolicy refuses changes organizational policies forbid. Tools check it
+// along with protected paths, so files a call changes without naming them in
+// its arguments, such as those search_replace matches, are covered too.
+type PathPolicy interface {
+	// CheckChange returns the error to report when the tool call of ctx may
+	// not change absPath; nil when it may
+	CheckChange(ctx context.Context, absPath, displayPath string) *StandardizedToolError
+}
+
+type pathPolicyKey struct{}
+
+// WithPathPolicy returns a context whose tool calls refuse the changes policy
+// forbids
+func WithPathPolicy(ctx context.Context, policy PathPolicy) context.Context {
+	if policy == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, pathPolicyKey{}, policy)
+}
+
+// PathPolicyFromContext returns the path policy of a tool call, or nil when
+// there is none
+func PathPolicyFromContext(ctx context.Context) PathPolicy {
+	policy, _ := ctx.Value(pathPolicyKey{}).(PathPolicy)
+	return policy
+}
+
+// CheckProtected returns the error to report when the tool call of ctx would
+// change absPath, a protected file or one a policy denies; nil when the
+// change is allowed
+func CheckProtected(ctx context.Context, absPath, displayPath string) *StandardizedToolError {
+	if glob, ok := PathGuardFromContext(ctx).Match(absPath); ok {
+		return NewProtectedPathError(displayPath, glob)
+	}
+	if policy := PathPolicyFromContext(ctx); policy != nil {
+		return policy.CheckChange(ctx, absPath, displayPath)
+	}
+	return nil
+}
+
+// CheckProtectedPatch returns the error to report when a unified diff names a
+// file, in its ---/+++ headers, that the tool call of ctx may not change; nil
+// when it names none. Patch tools change only the file they are given, but a
+// patch aimed at a forbidden file is refused rather than applied elsewhere.
+func CheckProtectedPatch(ctx context.Context, workspaceRoot, patch string) *StandardizedToolError {
+	if PathGuardFromContext(ctx) == nil && PathPolicyFromContext(ctx) == nil {
+		return nil
+	}
+	for _, line := range strings.Split(patch, "\n") {
+		if !strings.HasPrefix(line, "--- ") && !strings.HasPrefix(line, "+++ ") {
+			continue
+		}
+		// The name ends at a tab, before an optional timestamp
+		name, _, _ := strings.Cut(strings.TrimSpace(line[4:]), "\t")
+		if name == "" || name == "/dev/null" {
+			continue
+		}
+		if strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/") {
+			name = name[2:]
+		}
+		path := security.NormalizePath(name)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workspaceRoot, path)
+		}
+		if err := CheckProtected(ctx, filepath.Clean(path), name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readOnlyCommands never change the files they are given
+var readOnlyCommands = map[string]bool{
+	"ls": true, "cat": true, "grep": true, "wc": true, "head": true, "tail": true,
+	"test": true, "echo": true, "pwd": true, "which": true, "whoami": true, "type": true, "dir": true,
+}
+
+// rewrittenFiles lists files that dependency commands rewrite without naming
+// them, by command and subcommand
+var rewrittenFiles = map[string][]string{
+	"go mod":        {"go.mod", "go.sum"},
+	"go get":        {"go.mod", "go.sum"},
+	"npm install":   {"package.json", "package-lock.json"},
+	"npm i":         {"package.json", "package-lock.json"},
+	"npm update":    {"package.json", "package-lock.json"},
+	"npm uninstall": {"package.json", "package-lock.json"},
+	"yarn add":      {"package.json", "yarn.lock"},
+	"yarn install":  {"yarn.lock"},
+	"yarn upgrade":  {"package.json", "yarn.lock"},
+	"yarn remove":   {"package.json", "yarn.lock"},
+	"cargo add":     {"Cargo.toml", "Cargo.lock"},
+	"cargo update":  {"Cargo.lock"},
+	"cargo remove":  {"Cargo.toml", "Cargo.lock"},
+}
+
+// CheckProtectedCommand returns the error to report when the shell command of
+// the tool call of ctx, run in workDir, would change a protected file or one
+// a policy denies; nil when it is allowed. Commands are checked on a
+// best-effort basis: by the paths among their arguments, and by the files
+// known dependency commands rewrite. Read-only commands are always allowed.
+func CheckProtectedCommand(ctx context.Context, workDir string, fields []string) *StandardizedToolError {
+	if PathGuardFromContext(ctx) == nil && PathPolicyFromContext(ctx) == nil {
+		return nil
+	}
+	if len(fields) == 0 || readOnlyCommands[filepath.Base(fields[0])] {
+		return nil
+	}
+	if filepath.Base(fields[0]) == "find" {
+		return checkProtectedFind(ctx, workDir, fields)
+	}
+
+	check := func(arg string) *StandardizedToolError {
+		path := security.NormalizePath(arg)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workDir, path)
+		}
+		if err := CheckProtected(ctx, path, arg); err != nil {
+			return err.WithDetail("command", strings.Join(fields, " "))
+		}
+		return nil
+	}
+
+	if len(fields) > 1 {
+		for _, file := range rewrittenFiles[filepath.Base(fields[0])+" "+fields[1]] {
+			if err := check(file); err != nil {
+				return err
+			}
+		}
+	}
+	for _, arg := range fields[1:] {
+		// Values of flags such as --output=path are paths as well
+		if strings.HasPrefix(arg, "-") {
+			_, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				continue
+			}
+			arg = value
+		}
+		if arg == "" || strings.ContainsAny(arg, "*?") {
+			continue
+		}
+		if err := check(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findFileActions are the find actions that change the files found, or run
+// commands that may
+var findFileActions = map[string]bool{
+	"-delete": true, "-exec": true, "-execdir": true, "-ok": true, "-okdir": true,
+}
+
+// findOutputActions are the find actions that write their output to the file
+// following them
+var findOutputActions = map[string]bool{
+	"-fprint": true, "-fprint0": true, "-fprintf": true, "-fls": true,
+}
+
+// checkProtectedFind checks a find command. Without actions that write it is
+// read-only; with -delete or -exec and the like it may change any file under
+// its starting points, so it is refused when one of them is protected.
+func checkProtectedFind(ctx context.Context, workDir string, fields []string) *StandardizedToolError {
+	abs := func(arg string) string {
+		path := security.NormalizePath(arg)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workDir, path)
+		}
+		return filepath.Clean(path)
+	}
+	withCommand := func(err *StandardizedToolError) *StandardizedToolError {
+		return err.WithDetail("command", strings.Join(fields, " "))
+	}
+
+	// Starting points follow the -H, -L, -P, -D and -O options and end at
+	// the first expression
+	var starts []string
+	i := 1
+	for ; i < len(fields); i++ {
+		arg := fields[i]
+		if arg == "-H" || arg == "-L" || arg == "-P" || strings.HasPrefix(arg, "-O") {
+			continue
+		}
+		if arg == "-D" {
+			i++
+			continue
+		}
+		break
+	}
+	for ; i < len(fields) && !strings.HasPrefix(fields[i], "-") && fields[i] != "(" && fields[i] != "!"; i++ {
+		starts = append(starts, fields[i])
+	}
+	if len(starts) == 0 {
+		starts = []string{"."}
+	}
+
+	changesFiles := false
+	for ; i < len(fields); i++ {
+		if findFileActions[fields[i]] {
+			changesFiles = true
+		}
+		if findOutputActions[fields[i]] && i+1 < len(fields) {
+			if err := CheckProtected(ctx, abs(fields[i+1]), fields[i+1]); err != nil {
+				return withCommand(err)
+			}
+		}
+	}
+	if !changesFiles {
+		return nil
+	}
+
+	for _, start := range starts {
+		var refusal *StandardizedToolError
+		_ = filepath.WalkDir(abs(start), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			display := path
+			if rel, err := filepath.Rel(workDir, path); err == nil {
+				display = filepath.ToSlash(rel)
+			}
+			if refusal = CheckProtected(ctx, path, display); refusal != nil {
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if refusal != nil {
+			return withCommand(refusal)
+		}
+	}
+	return nil
+}
+
+// NewProtectedPathError creates the error for a change to a protected file.
+// It is permanent: retrying cannot succeed, so the agent must leave the file
+// alone.
+func NewProtectedPathError(path, glob string) *StandardizedToolError {
+	return NewStandardizedError(
+		ErrorCodeProtectedPath,
+		fmt.Sprintf("%s is protected by the never-edit rule %q", path, glob),
+		fmt.Sprintf("Do not retry this call or try another way to change %s: protected files may only be changed by the user. Make the change elsewhere, or tell the user what %s needs and why", path, path),
+	).WithDetail("file_path", path).WithDetail("protected_by", glob)
+}
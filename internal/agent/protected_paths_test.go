@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathGuardMatch(t *testing.T) {
+	root := t.TempDir()
+	guard := NewPathGuard(root, []string{"vendor/**", "*.lock", "migrations/**"})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"vendor/github.com/x/y.go", "vendor/**"},
+		{"vendor", "vendor/**"},
+		{"Cargo.lock", "*.lock"},
+		{"web/yarn.lock", "*.lock"},
+		{"db/migrations/001.sql", ""},
+		{"migrations/001.sql", "migrations/**"},
+		{"pkg/vendor/x.go", ""},
+		{"main.go", ""},
+	}
+	for _, tt := range tests {
+		got, ok := guard.Match(filepath.Join(root, filepath.FromSlash(tt.path)))
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Match(%q) = %q, %v, want %q", tt.path, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := guard.Match(filepath.Join(filepath.Dir(root), "vendor", "x.go")); ok {
+		t.Error("Paths outside the workspace are not protected")
+	}
+	if NewPathGuard(root, nil) != nil {
+		t.Error("A guard without globs should be nil")
+	}
+	if _, ok := (*PathGuard)(nil).Match(filepath.Join(root, "Cargo.lock")); ok {
+		t.Error("A nil guard protects nothing")
+	}
+}
+
+func TestProtectedPathsRefused(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "go.lock"), []byte("v1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("v1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithPathGuard(context.Background(), NewPathGuard(root, []string{"*.lock"}))
+
+	write, _ := json.Marshal(map[string]interface{}{"file_path": "go.lock", "content": "v2\n"})
+	result, err := NewFileWriteTool(root).Execute(ctx, write)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || result.StandardizedError == nil || result.StandardizedError.Code != ErrorCodeProtectedPath {
+		t.Fatalf("Expected a protected path error, got %+v", result)
+	}
+	if !result.StandardizedError.Permanent() {
+		t.Error("Protected path errors should not be retried")
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "go.lock")); string(content) != "v1\n" {
+		t.Errorf("Protected file was changed: %q", content)
+	}
+
+	// Without a guard in the context, nothing is protected
+	result, err = NewFileWriteTool(root).Execute(context.Background(), write)
+	if err != nil || !result.Success {
+		t.Fatalf("Expected the write to succeed without a guard, got %+v, %v", result, err)
+	}
+
+	// Search and replace refuses the whole edit
+	replace, _ := json.Marshal(map[string]interface{}{"pattern": "v", "replacement": "w"})
+	result, err = NewSearchReplaceTool(root).Execute(ctx, replace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || result.StandardizedError == nil || result.StandardizedError.Code != ErrorCodeProtectedPath {
+		t.Fatalf("Expected a protected path error, got %+v", result)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "main.go")); string(content) != "v1\n" {
+		t.Errorf("Unprotected file was changed by a refused edit: %q", content)
+	}
+}
+
+func TestCheckProtectedCommand(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{"vendor/modules.txt", "src/main.go"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := WithPathGuard(context.Background(), NewPathGuard(root, []string{"vendor/**", "go.sum", "*.lock"}))
+
+	tests := []struct {
+		command string
+		refused bool
+	}{
+		{"cat vendor/modules.txt", false},
+		{"find . -name *.go", false},
+		{"find . -name *.tmp -delete", true},
+		{"find -L vendor -type f -exec rm {} ;", true},
+		{"find src -name *.go -exec gofmt -w {} ;", false},
+		{"find src -fprint go.sum", true},
+		{"rm -rf vendor", true},
+		{"sed -i s/a/b/ app.lock", true},
+		{"gofmt -w --out=vendor/x.go main.go", true},
+		{"go mod tidy", true},
+		{"go test ./...", false},
+		{"npm install", false},
+		{"cargo update", true},
+	}
+	for _, tt := range tests {
+		err := CheckProtectedCommand(ctx, root, strings.Fields(tt.command))
+		if (err != nil) != tt.refused {
+			t.Errorf("CheckProtectedCommand(%q) = %v, want refused %v", tt.command, err, tt.refused)
+		}
+	}
+	if err := CheckProtectedCommand(context.Background(), root, []string{"rm", "go.sum"}); err != nil {
+		t.Errorf("Commands are allowed without a guard, got %v", err)
+	}
+}
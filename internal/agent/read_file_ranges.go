@@ -0,0 +1,261 @@
+package agent
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultReadPadding is the number of context lines added around a requested range or symbol
+	defaultReadPadding = 3
+	// maxReadLines caps how many lines a single read_file call returns
+	maxReadLines = 1000
+	// hexPreviewBytes is how much of a binary file read_file dumps as hex
+	hexPreviewBytes = 512
+)
+
+// lineRange is a 1-based, inclusive range of lines
+type lineRange struct {
+	Start int
+	End   int
+}
+
+// parseLineSpec parses "100-180", "100:180" or a single line number "42"
+func parseLineSpec(spec string) (lineRange, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return lineRange{}, fmt.Errorf("empty line range")
+	}
+
+	sep := strings.IndexAny(spec, "-:")
+	if sep < 0 {
+		n, err := strconv.Atoi(spec)
+		if err != nil || n < 1 {
+			return lineRange{}, fmt.Errorf("invalid line number %q", spec)
+		}
+		return lineRange{Start: n, End: n}, nil
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(spec[:sep]))
+	if err != nil || start < 1 {
+		return lineRange{}, fmt.Errorf("invalid start line in %q", spec)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(spec[sep+1:]))
+	if err != nil || end < 1 {
+		return lineRange{}, fmt.Errorf("invalid end line in %q", spec)
+	}
+	if start > end {
+		return lineRange{}, fmt.Errorf("start line %d is after end line %d", start, end)
+	}
+	return lineRange{Start: start, End: end}, nil
+}
+
+// pad widens r by padding lines on each side, clamped to the file
+func (r lineRange) pad(padding, totalLines int) lineRange {
+	r.Start -= padding
+	if r.Start < 1 {
+		r.Start = 1
+	}
+	r.End += padding
+	if r.End > totalLines {
+		r.End = totalLines
+	}
+	return r
+}
+
+// findSymbol locates a symbol such as "Run", "AgentRunner" or "AgentRunner.Run"
+// in content. Go files are parsed precisely; other languages use a
+// definition-line heuristic.
+func findSymbol(path, content, symbol string) (lineRange, error) {
+	if strings.EqualFold(filepath.Ext(path), ".go") {
+		if r, err := findGoSymbol(content, symbol); err == nil {
+			return r, nil
+		}
+		// Unparseable Go falls through to the heuristic
+	}
+	return findSymbolHeuristic(content, symbol)
+}
+
+// findGoSymbol finds functions, methods (Receiver.Method), types, consts and vars in Go source
+func findGoSymbol(content, symbol string) (lineRange, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return lineRange{}, err
+	}
+
+	receiver, name := "", symbol
+	if idx := strings.LastIndex(symbol, "."); idx >= 0 {
+		receiver, name = symbol[:idx], symbol[idx+1:]
+	}
+
+	span := func(node ast.Node, doc *ast.CommentGroup) lineRange {
+		start := node.Pos()
+		if doc != nil {
+			start = doc.Pos() // Include the doc comment with the declaration
+		}
+		return lineRange{Start: fset.Position(start).Line, End: fset.Position(node.End()).Line}
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Name.Name != name {
+				continue
+			}
+			if receiverTypeName(d) == receiver {
+				return span(d, d.Doc), nil
+			}
+		case *ast.GenDecl:
+			if receiver != "" {
+				continue
+			}
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.Name == name {
+						if len(d.Specs) == 1 {
+							return span(d, d.Doc), nil
+						}
+						return span(s, s.Doc), nil
+					}
+				case *ast.ValueSpec:
+					for _, ident := range s.Names {
+						if ident.Name == name {
+							if len(d.Specs) == 1 {
+								return span(d, d.Doc), nil
+							}
+							return span(s, s.Doc), nil
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return lineRange{}, fmt.Errorf("symbol %q not found", symbol)
+}
+
+// receiverTypeName returns the receiver's type name without pointer or type parameters
+func receiverTypeName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	expr := fn.Recv.List[0].Type
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// definitionPattern matches common definition keywords followed by a name
+const definitionPattern = `^\s*(?:export\s+)?(?:default\s+)?(?:pub(?:\(crate\))?\s+)?(?:async\s+)?(?:static\s+)?(?:func|function|def|class|interface|struct|enum|trait|type|impl|fn|const|let|var)\s+%s\b`
+
+// methodPattern matches a method definition inside a class body
+const methodPattern = `^\s*(?:(?:public|private|protected|static|async|override|def|fn|func|pub)\s+)*%s\s*\(`
+
+// findSymbolHeuristic locates a definition by keyword and extends it to the end of its block
+func findSymbolHeuristic(content, symbol string) (lineRange, error) {
+	lines := strings.Split(content, "\n")
+
+	parts := strings.Split(symbol, ".")
+	searchFrom, searchTo := 0, len(lines)
+	var r lineRange
+	for i, part := range parts {
+		pattern := definitionPattern
+		if i > 0 {
+			pattern = methodPattern
+		}
+		re, err := regexp.Compile(fmt.Sprintf(pattern, regexp.QuoteMeta(part)))
+		if err != nil {
+			return lineRange{}, err
+		}
+
+		found := false
+		for idx := searchFrom; idx < searchTo; idx++ {
+			if re.MatchString(lines[idx]) {
+				r = lineRange{Start: idx + 1, End: blockEnd(lines, idx) + 1}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return lineRange{}, fmt.Errorf("symbol %q not found", symbol)
+		}
+		// Nested parts are searched within the enclosing definition
+		searchFrom, searchTo = r.Start, r.End
+	}
+	return r, nil
+}
+
+// blockEnd returns the 0-based index of the last line of the block starting at
+// start, using brace matching when the block opens a brace and indentation otherwise
+func blockEnd(lines []string, start int) int {
+	depth := 0
+	opened := false
+	for idx := start; idx < len(lines); idx++ {
+		for _, ch := range lines[idx] {
+			switch ch {
+			case '{':
+				depth++
+				opened = true
+			case '}':
+				depth--
+			}
+		}
+		if opened && depth <= 0 {
+			return idx
+		}
+		if !opened {
+			trimmed := strings.TrimSpace(lines[idx])
+			switch {
+			case idx == start && strings.HasSuffix(trimmed, ":"):
+				// Python-style blocks are delimited by indentation
+				return indentBlockEnd(lines, start)
+			case strings.HasSuffix(trimmed, ";"):
+				// Single statement declaration
+				return idx
+			case idx-start >= 10:
+				// No block opened near the definition; fall back to indentation
+				return indentBlockEnd(lines, start)
+			}
+		}
+	}
+	return len(lines) - 1
+}
+
+// indentBlockEnd returns the last line indented deeper than the line at start
+func indentBlockEnd(lines []string, start int) int {
+	baseIndent := indentWidth(lines[start])
+	end := start
+	for idx := start + 1; idx < len(lines); idx++ {
+		if strings.TrimSpace(lines[idx]) == "" {
+			continue
+		}
+		if indentWidth(lines[idx]) <= baseIndent {
+			break
+		}
+		end = idx
+	}
+	return end
+}
+
+func indentWidth(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const rangeTestGoSource = `package sample
+
+// Runner runs things
+type Runner struct {
+	name string
+}
+
+// Run executes the runner
+func (r *Runner) Run() error {
+	return nil
+}
+
+func Helper() string {
+	return "helper"
+}
+`
+
+const rangeTestPySource = `import os
+
+class Runner:
+    def __init__(self):
+        self.name = "x"
+
+    def run(self):
+        return os.getcwd()
+
+def helper():
+    return 1
+`
+
+func TestFindSymbol(t *testing.T) {
+	tests := []struct {
+		path, content, symbol string
+		want                  lineRange
+	}{
+		{"a.go", rangeTestGoSource, "Runner", lineRange{3, 6}},
+		{"a.go", rangeTestGoSource, "Runner.Run", lineRange{8, 11}},
+		{"a.go", rangeTestGoSource, "Helper", lineRange{13, 15}},
+		{"a.py", rangeTestPySource, "Runner.run", lineRange{7, 8}},
+		{"a.py", rangeTestPySource, "helper", lineRange{10, 11}},
+		{"a.js", "function main() {\n  if (x) {\n    y()\n  }\n}\n", "main", lineRange{1, 5}},
+	}
+
+	for _, tt := range tests {
+		got, err := findSymbol(tt.path, tt.content, tt.symbol)
+		if err != nil {
+			t.Errorf("findSymbol(%s, %q) failed: %v", tt.path, tt.symbol, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("findSymbol(%s, %q) = %+v, want %+v", tt.path, tt.symbol, got, tt.want)
+		}
+	}
+
+	if _, err := findSymbol("a.go", rangeTestGoSource, "Runner.Missing"); err == nil {
+		t.Errorf("Expected error for missing method")
+	}
+}
+
+func TestFileReadToolRangesAndSymbols(t *testing.T) {
+	workspace := setupTestWorkspace(t)
+	if err := os.WriteFile(filepath.Join(workspace, "sample.go"), []byte(rangeTestGoSource), 0600); err != nil {
+		t.Fatalf("Failed to write sample: %v", err)
+	}
+
+	var big strings.Builder
+	for i := 1; i <= maxReadLines+500; i++ {
+		fmt.Fprintf(&big, "line %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "big.txt"), []byte(big.String()), 0600); err != nil {
+		t.Fatalf("Failed to write big file: %v", err)
+	}
+
+	tool := NewFileReadTool(workspace)
+	run := func(params string) *ToolResult {
+		t.Helper()
+		result, err := tool.Execute(context.Background(), json.RawMessage(params))
+		if err != nil {
+			t.Fatalf("Execute(%s) returned error: %v", params, err)
+		}
+		return result
+	}
+
+	t.Run("symbol_with_padding", func(t *testing.T) {
+		result := run(`{"target_file": "sample.go", "symbol": "Runner.Run", "context_lines": 1}`)
+		if !result.Success {
+			t.Fatalf("Expected success, got: %s", result.Error)
+		}
+		data := result.Data.(map[string]interface{})
+		if data["start_line"] != 7 || data["end_line"] != 12 {
+			t.Errorf("Expected padded range 7-12, got %v-%v", data["start_line"], data["end_line"])
+		}
+		if !strings.Contains(data["content"].(string), "func (r *Runner) Run() error") {
+			t.Errorf("Expected method body in content, got %q", data["content"])
+		}
+	})
+
+	t.Run("lines_range", func(t *testing.T) {
+		result := run(`{"target_file": "big.txt", "lines": "100-110", "context_lines": 0}`)
+		data := result.Data.(map[string]interface{})
+		content := data["content"].(string)
+		if !strings.HasPrefix(content, "line 100\n") || !strings.HasSuffix(content, "line 110") {
+			t.Errorf("Unexpected range content: %q", content)
+		}
+	})
+
+	t.Run("size_cap", func(t *testing.T) {
+		result := run(`{"target_file": "big.txt"}`)
+		data := result.Data.(map[string]interface{})
+		if data["truncated"] != true {
+			t.Fatalf("Expected truncated output for large file")
+		}
+		if lines := strings.Count(data["content"].(string), "\n") + 1; lines != maxReadLines {
+			t.Errorf("Expected %d lines, got %d", maxReadLines, lines)
+		}
+	})
+
+	t.Run("unknown_symbol", func(t *testing.T) {
+		result := run(`{"target_file": "sample.go", "symbol": "Nope"}`)
+		if result.Success || result.StandardizedError == nil {
+			t.Errorf("Expected standardized failure for unknown symbol")
+		}
+	})
+
+	t.Run("invalid_lines", func(t *testing.T) {
+		result := run(`{"target_file": "sample.go", "lines": "20-10"}`)
+		if result.Success {
+			t.Errorf("Expected failure for reversed range")
+		}
+	})
+}
@@ -17,6 +17,7 @@ import (
 type RetrieveContextTool struct {
 	workspaceRoot string
 	vectorStore   *vectorstore.VectorStore
+	backendErr    error
 	llmClient     LLMClient
 	modelName     string
 	chunker       *textutils.Chunker
@@ -30,10 +31,32 @@ type LLMClient interface {
 	SupportsEmbeddings() bool
 }
 
+// RetrieveContextToolConfig configures the context retrieval tool
+type RetrieveContextToolConfig struct {
+	// EmbeddingModel names the model query embeddings come from; the index
+	// saved by `CGE index` is only used when it was built with the same one
+	EmbeddingModel string
+	// VectorBackend selects exact (flat) or approximate (HNSW) search
+	VectorBackend vectorstore.BackendConfig
+}
+
 // NewRetrieveContextTool creates a new context retrieval tool
 func NewRetrieveContextTool(workspaceRoot string, llmClient LLMClient, modelName string) *RetrieveContextTool {
-	// Initialize vector store with a reasonable dimension (will be set dynamically)
-	vectorStore := vectorstore.NewVectorStore(0)
+	return NewRetrieveContextToolWithConfig(workspaceRoot, llmClient, modelName, RetrieveContextToolConfig{})
+}
+
+// NewRetrieveContextToolWithConfig creates a context retrieval tool searching
+// the index saved for the workspace with the configured backend
+func NewRetrieveContextToolWithConfig(workspaceRoot string, llmClient LLMClient, modelName string, config RetrieveContextToolConfig) *RetrieveContextTool {
+	backend, backendErr := vectorstore.NewBackend(config.VectorBackend)
+	if backendErr != nil {
+		backend = vectorstore.NewFlatBackend() // Never used; Execute reports backendErr
+	}
+	vectorStore := vectorstore.NewVectorStoreWithBackend(0, backend)
+	// Without a usable index, retrieval falls back to LLM-assisted search
+	if backendErr == nil {
+		_, _ = vectorStore.LoadIndex(vectorstore.IndexFilePath(workspaceRoot), config.EmbeddingModel, 0)
+	}
 
 	// Configure chunker for context retrieval
 	chunkOptions := textutils.ChunkOptions{
@@ -51,6 +74,7 @@ func NewRetrieveContextTool(workspaceRoot string, llmClient LLMClient, modelName
 	return &RetrieveContextTool{
 		workspaceRoot: workspaceRoot,
 		vectorStore:   vectorStore,
+		backendErr:    backendErr,
 		llmClient:     llmClient,
 		modelName:     modelName,
 		chunker:       chunker,
@@ -106,6 +130,13 @@ func (t *RetrieveContextTool) Execute(ctx context.Context, params json.RawMessag
 		return nil, fmt.Errorf("invalid parameters: %w", err)
 	}
 
+	if t.backendErr != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("failed to retrieve context: %v", t.backendErr),
+		}, nil
+	}
+
 	// Set defaults
 	if p.MaxResults == 0 {
 		p.MaxResults = 5
@@ -165,6 +196,9 @@ func (t *RetrieveContextTool) vectorSearch(ctx context.Context, params RetrieveC
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
+	if dimension := t.vectorStore.Dimension(); dimension > 0 && len(queryEmbedding) != dimension {
+		return nil, fmt.Errorf("index has %d-dimensional embeddings but the query has %d", dimension, len(queryEmbedding))
+	}
 
 	// Prepare metadata filter if file filter is specified
 	var metadataFilter map[string]interface{}
@@ -198,10 +232,10 @@ func (t *RetrieveContextTool) vectorSearch(ctx context.Context, params RetrieveC
 		if filePath, ok := result.Document.Metadata["file_path"].(string); ok {
 			contextResult.FilePath = filePath
 		}
-		if startLine, ok := result.Document.Metadata["start_line"].(int); ok {
+		if startLine, ok := vectorstore.MetadataInt(result.Document, "start_line"); ok {
 			contextResult.StartLine = startLine
 		}
-		if endLine, ok := result.Document.Metadata["end_line"].(int); ok {
+		if endLine, ok := vectorstore.MetadataInt(result.Document, "end_line"); ok {
 			contextResult.EndLine = endLine
 		}
 
@@ -464,15 +498,16 @@ func (t *RetrieveContextTool) IndexWorkspace(ctx context.Context) error {
 		return fmt.Errorf("failed to get file list: %w", err)
 	}
 
-	for _, filePath := range files {
+	for i, filePath := range files {
+		ReportProgress(ctx, float64(i)/float64(len(files)), "Indexing "+filePath, i+1, len(files))
 		fullPath := filepath.Join(t.workspaceRoot, filePath)
 		content, err := readFileContent(fullPath)
 		if err != nil {
 			continue // Skip files that can't be read
 		}
 
-		// Skip very large files
-		if len(content) > 100000 { // 100KB limit
+		// Skip very large and binary files
+		if len(content) > 100000 || textutils.IsBinary([]byte(content)) { // 100KB limit
 			continue
 		}
 
@@ -482,8 +517,12 @@ func (t *RetrieveContextTool) IndexWorkspace(ctx context.Context) error {
 			continue
 		}
 
-		// Generate embeddings and store chunks
+		// Generate embeddings and store chunks, skipping chunks whose content is unchanged
 		for _, chunk := range chunks {
+			if t.vectorStore.HasChunk(chunk) {
+				continue
+			}
+
 			embedding, err := t.llmClient.Embed(ctx, chunk.Content)
 			if err != nil {
 				continue // Skip chunks that can't be embedded
@@ -502,7 +541,7 @@ func (t *RetrieveContextTool) IndexWorkspace(ctx context.Context) error {
 // Helper functions (these would typically be in a shared utility package)
 
 func shouldSkipDir(name string) bool {
-	skipDirs := []string{".git", "node_modules", "vendor", ".vscode", ".idea", "target", "build", "dist"}
+	skipDirs := []string{".git", ".cge", "node_modules", "vendor", ".vscode", ".idea", "target", "build", "dist"}
 	for _, skip := range skipDirs {
 		if name == skip {
 			return true
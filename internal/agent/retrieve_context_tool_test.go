@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/textutils"
+	"github.com/castrovroberto/CGE/internal/vectorstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// embedOnlyClient returns a fixed embedding for every text
+type embedOnlyClient struct{}
+
+func (embedOnlyClient) Generate(ctx context.Context, modelName, prompt, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	return "", nil
+}
+
+func (embedOnlyClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	return []float32{1, 0, 0}, nil
+}
+
+func (embedOnlyClient) SupportsEmbeddings() bool { return true }
+
+func TestRetrieveContextToolLoadsSavedIndex(t *testing.T) {
+	workspace := t.TempDir()
+	saved := vectorstore.NewVectorStore(0)
+	saved.SetEmbeddingModel("nomic-embed-text")
+	require.NoError(t, saved.AddChunk(textutils.TextChunk{Content: "func A() {}", StartLine: 1, EndLine: 1}, []float32{1, 0, 0}))
+	require.NoError(t, saved.SaveToFile(vectorstore.IndexFilePath(workspace)))
+
+	hnsw := vectorstore.BackendConfig{Type: vectorstore.BackendHNSW}
+	tool := NewRetrieveContextToolWithConfig(workspace, embedOnlyClient{}, "test", RetrieveContextToolConfig{EmbeddingModel: "nomic-embed-text", VectorBackend: hnsw})
+	assert.Equal(t, 1, tool.vectorStore.Count(), "the saved index is loaded into the configured backend")
+
+	tool = NewRetrieveContextToolWithConfig(workspace, embedOnlyClient{}, "test", RetrieveContextToolConfig{EmbeddingModel: "text-embedding-004", VectorBackend: hnsw})
+	assert.Equal(t, 0, tool.vectorStore.Count(), "an index from another embedding model is ignored")
+}
+
+func TestRetrieveContextToolRejectsUnknownBackend(t *testing.T) {
+	tool := NewRetrieveContextToolWithConfig(t.TempDir(), embedOnlyClient{}, "test", RetrieveContextToolConfig{VectorBackend: vectorstore.BackendConfig{Type: "hsnw"}})
+	params, _ := json.Marshal(map[string]interface{}{"query": "where is A defined"})
+	result, err := tool.Execute(context.Background(), params)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "hsnw")
+}
@@ -0,0 +1,295 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the scratchpad tools
+const (
+	ScratchpadWriteToolName = "scratchpad_write"
+	ScratchpadReadToolName  = "scratchpad_read"
+)
+
+// Bounds of a scratchpad, so it cannot grow into what it spares the
+// conversation
+const (
+	maxScratchpadNotes     = 50
+	maxScratchpadNoteChars = 20000
+)
+
+// Bounds of the scratchpad digest added to the prompt: notes up to
+// maxInlineNoteChars are shown whole while the digest has room, others by
+// their first line
+const (
+	maxInlineNoteChars  = 600
+	maxNotePreviewChars = 120
+)
+
+// ScratchpadNote is a note of the scratchpad
+type ScratchpadNote struct {
+	Content string    `json:"content"`
+	Updated time.Time `json:"updated"`
+}
+
+// Scratchpad holds the notes an agent keeps outside the conversation, such
+// as its reasoning so far or the files it still has to edit. Notes are
+// written and read with the scratchpad tools, which find it in the Execute
+// context, see WithScratchpad; the prompt only carries a digest of them.
+type Scratchpad struct {
+	mu    sync.Mutex
+	notes map[string]ScratchpadNote
+}
+
+// NewScratchpad creates a scratchpad holding notes, e.g. those saved with a
+// session
+func NewScratchpad(notes map[string]ScratchpadNote) *Scratchpad {
+	pad := &Scratchpad{notes: make(map[string]ScratchpadNote, len(notes))}
+	for name, note := range notes {
+		pad.notes[name] = note
+	}
+	return pad
+}
+
+// Notes returns a copy of the notes of the scratchpad, nil when it is empty
+func (s *Scratchpad) Notes() map[string]ScratchpadNote {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.notes) == 0 {
+		return nil
+	}
+	notes := make(map[string]ScratchpadNote, len(s.notes))
+	for name, note := range s.notes {
+		notes[name] = note
+	}
+	return notes
+}
+
+// Note returns the note called name
+func (s *Scratchpad) Note(name string) (ScratchpadNote, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	note, ok := s.notes[name]
+	return note, ok
+}
+
+// Write replaces the note called name with content, or adds it
+func (s *Scratchpad) Write(name, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set(name, content)
+}
+
+// Append adds content to the end of the note called name, on a new line
+func (s *Scratchpad) Append(name, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if note, ok := s.notes[name]; ok && note.Content != "" {
+		content = strings.TrimRight(note.Content, "\n") + "\n" + content
+	}
+	return s.set(name, content)
+}
+
+// RemoveLines drops the lines of the note called name matching any line of
+// lines, ignoring surrounding spaces, as when ticking items off a list. It
+// returns how many lines were removed.
+func (s *Scratchpad) RemoveLines(name, lines string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	note, ok := s.notes[name]
+	if !ok {
+		return 0, fmt.Errorf("no note called %q", name)
+	}
+	drop := make(map[string]bool)
+	for _, line := range strings.Split(lines, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			drop[line] = true
+		}
+	}
+	var kept []string
+	removed := 0
+	for _, line := range strings.Split(note.Content, "\n") {
+		if drop[strings.TrimSpace(line)] {
+			removed++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return removed, s.set(name, strings.Join(kept, "\n"))
+}
+
+// Delete drops the note called name
+func (s *Scratchpad) Delete(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.notes[name]
+	delete(s.notes, name)
+	return ok
+}
+
+// set stores a note within the bounds of the scratchpad; callers hold mu
+func (s *Scratchpad) set(name, content string) error {
+	if len(content) > maxScratchpadNoteChars {
+		return fmt.Errorf("note %q would be %d characters, more than the %d a note may hold", name, len(content), maxScratchpadNoteChars)
+	}
+	if _, ok := s.notes[name]; !ok && len(s.notes) >= maxScratchpadNotes {
+		return fmt.Errorf("the scratchpad already holds %d notes, the most it may", maxScratchpadNotes)
+	}
+	if s.notes == nil {
+		s.notes = make(map[string]ScratchpadNote)
+	}
+	s.notes[name] = ScratchpadNote{Content: content, Updated: time.Now()}
+	return nil
+}
+
+// Digest describes the notes in at most budget characters, for the prompt:
+// the most recently updated notes first, short ones whole and the others by
+// their first line and size. It is empty when there are no notes.
+func (s *Scratchpad) Digest(budget int) string {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.notes))
+	for name := range s.notes {
+		names = append(names, name)
+	}
+	notes := s.notes
+	sort.Slice(names, func(i, j int) bool {
+		if !notes[names[i]].Updated.Equal(notes[names[j]].Updated) {
+			return notes[names[i]].Updated.After(notes[names[j]].Updated)
+		}
+		return names[i] < names[j]
+	})
+	entries := make([]string, 0, len(names))
+	left := budget
+	for _, name := range names {
+		content := strings.TrimSpace(notes[name].Content)
+		entry := fmt.Sprintf("### %s\n%s", name, content)
+		if len(content) > maxInlineNoteChars || len(entry) > left {
+			preview, _, _ := strings.Cut(content, "\n")
+			if runes := []rune(preview); len(runes) > maxNotePreviewChars {
+				preview = string(runes[:maxNotePreviewChars]) + "..."
+			}
+			entry = fmt.Sprintf("### %s (%d characters, %d lines; read it with %s)\n%s", name, len(content), strings.Count(content, "\n")+1, ScratchpadReadToolName, preview)
+		}
+		if len(entry) > left {
+			entries = append(entries, fmt.Sprintf("(%d more notes: read them with %s)", len(names)-len(entries), ScratchpadReadToolName))
+			break
+		}
+		entries = append(entries, entry)
+		left -= len(entry)
+	}
+	s.mu.Unlock()
+	if len(entries) == 0 {
+		return ""
+	}
+	return strings.Join(entries, "\n\n")
+}
+
+type scratchpadKey struct{}
+
+// WithScratchpad returns a context in which the scratchpad tools use pad
+func WithScratchpad(ctx context.Context, pad *Scratchpad) context.Context {
+	return context.WithValue(ctx, scratchpadKey{}, pad)
+}
+
+// ScratchpadFromContext returns the scratchpad carried by ctx, if any
+func ScratchpadFromContext(ctx context.Context) (*Scratchpad, bool) {
+	pad, ok := ctx.Value(scratchpadKey{}).(*Scratchpad)
+	return pad, ok && pad != nil
+}
+
+// ScratchpadWriteParams are the parameters of scratchpad_write
+type ScratchpadWriteParams struct {
+	Name    string `json:"name" description:"Name of the note, e.g. remaining_files or findings" jsonschema:"required,minLength=1"`
+	Content string `json:"content,omitempty" description:"Text to write, append, or the lines to remove; not needed to delete"`
+	Mode    string `json:"mode,omitempty" description:"replace (default) replaces the note, append adds a line, remove_lines drops the lines matching those of content, delete drops the note" jsonschema:"enum=replace|append|remove_lines|delete,default=replace"`
+}
+
+// ScratchpadReadParams are the parameters of scratchpad_read
+type ScratchpadReadParams struct {
+	Name string `json:"name,omitempty" description:"Note to read; omit to read every note"`
+}
+
+// ScratchpadTools returns the scratchpad tools, which work on the scratchpad
+// of the Execute context
+func ScratchpadTools() []Tool {
+	return []Tool{NewScratchpadWriteTool(), NewScratchpadReadTool()}
+}
+
+// NewScratchpadWriteTool creates the scratchpad_write tool, which writes the
+// notes of the scratchpad
+func NewScratchpadWriteTool() Tool {
+	return NewTypedTool(ScratchpadWriteToolName, `Writes a note to your scratchpad, which persists across the steps of the task outside the conversation. Only a digest of the notes is shown with each step, so keep long lists and intermediate conclusions there rather than repeating them in your replies: the files left to edit, findings to report, hypotheses ruled out.
+
+USAGE EXAMPLES:
+- scratchpad_write({"name": "remaining_files", "content": "cmd/root.go\ninternal/config/config.go"})
+- scratchpad_write({"name": "remaining_files", "content": "cmd/root.go", "mode": "remove_lines"})
+- scratchpad_write({"name": "findings", "content": "The cache is never invalidated on writes", "mode": "append"})`,
+		func(ctx context.Context, params ScratchpadWriteParams) (interface{}, error) {
+			pad, ok := ScratchpadFromContext(ctx)
+			if !ok {
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, "no scratchpad is available in this run", "Keep the notes in your replies instead")
+			}
+			name := strings.TrimSpace(params.Name)
+			if name == "" {
+				return nil, NewStandardizedError(ErrorCodeMissingParameter, "a note name is required", "Name the note, e.g. remaining_files")
+			}
+			var err error
+			result := map[string]interface{}{"name": name}
+			switch params.Mode {
+			case "", "replace":
+				err = pad.Write(name, params.Content)
+			case "append":
+				err = pad.Append(name, params.Content)
+			case "remove_lines":
+				var removed int
+				removed, err = pad.RemoveLines(name, params.Content)
+				result["removed_lines"] = removed
+			case "delete":
+				result["deleted"] = pad.Delete(name)
+				result["notes"] = len(pad.Notes())
+				return result, nil
+			default:
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, fmt.Sprintf("unknown mode %q", params.Mode), "Use replace, append, remove_lines or delete")
+			}
+			if err != nil {
+				return nil, NewStandardizedError(ErrorCodeResourceLimit, err.Error(), "Shorten or delete notes you no longer need")
+			}
+			note, _ := pad.Note(name)
+			result["chars"] = len(note.Content)
+			result["notes"] = len(pad.Notes())
+			return result, nil
+		})
+}
+
+// NewScratchpadReadTool creates the scratchpad_read tool, which reads the
+// notes of the scratchpad
+func NewScratchpadReadTool() Tool {
+	return NewTypedTool(ScratchpadReadToolName, `Reads a note of your scratchpad whole, or every note when no name is given. The digest shown with each step abbreviates long notes; read them here when you need their full content.`,
+		func(ctx context.Context, params ScratchpadReadParams) (interface{}, error) {
+			pad, ok := ScratchpadFromContext(ctx)
+			if !ok {
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, "no scratchpad is available in this run", "Keep the notes in your replies instead")
+			}
+			if params.Name == "" {
+				notes := make(map[string]string)
+				for name, note := range pad.Notes() {
+					notes[name] = note.Content
+				}
+				return map[string]interface{}{"notes": notes}, nil
+			}
+			note, ok := pad.Note(strings.TrimSpace(params.Name))
+			if !ok {
+				var names []string
+				for name := range pad.Notes() {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, fmt.Sprintf("no note called %q", params.Name), "Read one of the existing notes, or all of them by omitting the name").WithDetail("notes", names)
+			}
+			return map[string]interface{}{"name": params.Name, "content": note.Content}, nil
+		})
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestScratchpadTools(t *testing.T) {
+	pad := NewScratchpad(nil)
+	ctx := WithScratchpad(context.Background(), pad)
+	write := NewScratchpadWriteTool()
+	read := NewScratchpadReadTool()
+
+	for _, args := range []string{
+		`{"name": "remaining_files", "content": "cmd/root.go\ninternal/config/config.go"}`,
+		`{"name": "remaining_files", "content": "main.go", "mode": "append"}`,
+		`{"name": "remaining_files", "content": "  cmd/root.go ", "mode": "remove_lines"}`,
+	} {
+		if result, err := write.Execute(ctx, json.RawMessage(args)); err != nil || !result.Success {
+			t.Fatalf("expected %s to succeed, got %v %+v", args, err, result)
+		}
+	}
+	note, ok := pad.Note("remaining_files")
+	if !ok || note.Content != "internal/config/config.go\nmain.go" {
+		t.Fatalf("expected the list without the removed file, got %q", note.Content)
+	}
+
+	result, err := read.Execute(ctx, json.RawMessage(`{"name": "remaining_files"}`))
+	if err != nil || !result.Success || result.Data.(map[string]interface{})["content"] != note.Content {
+		t.Fatalf("expected the note, got %v %+v", err, result)
+	}
+	result, err = read.Execute(ctx, json.RawMessage(`{"name": "missing"}`))
+	if err != nil || result.Success || result.StandardizedError == nil {
+		t.Fatalf("expected a standardized error for a missing note, got %v %+v", err, result)
+	}
+
+	if result, err := write.Execute(ctx, json.RawMessage(`{"name": "remaining_files", "mode": "delete"}`)); err != nil || !result.Success {
+		t.Fatalf("expected the note to be deleted, got %v %+v", err, result)
+	}
+	if pad.Notes() != nil {
+		t.Fatalf("expected an empty scratchpad, got %v", pad.Notes())
+	}
+
+	result, err = write.Execute(context.Background(), json.RawMessage(`{"name": "x", "content": "y"}`))
+	if err != nil || result.Success {
+		t.Fatalf("expected an error without a scratchpad, got %v %+v", err, result)
+	}
+}
+
+func TestScratchpadLimits(t *testing.T) {
+	pad := NewScratchpad(nil)
+	if err := pad.Write("big", strings.Repeat("x", maxScratchpadNoteChars+1)); err == nil {
+		t.Fatal("expected notes over the size limit to be refused")
+	}
+	for i := 0; i < maxScratchpadNotes; i++ {
+		if err := pad.Write(strings.Repeat("n", i+1), "note"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pad.Write("one too many", "note"); err == nil {
+		t.Fatal("expected notes over the count limit to be refused")
+	}
+	if err := pad.Write("n", "rewritten"); err != nil {
+		t.Fatalf("expected existing notes to stay writable, got %v", err)
+	}
+}
+
+func TestScratchpadDigest(t *testing.T) {
+	pad := NewScratchpad(nil)
+	if digest := pad.Digest(1000); digest != "" {
+		t.Fatalf("expected no digest without notes, got %q", digest)
+	}
+	pad.Write("plan", "1. Rename the handler\n2. Update the tests")
+	pad.Write("log", "first line of a long log\n"+strings.Repeat("detail\n", 200))
+
+	digest := pad.Digest(1000)
+	if !strings.Contains(digest, "### plan\n1. Rename the handler\n2. Update the tests") {
+		t.Fatalf("expected short notes whole, got %q", digest)
+	}
+	if !strings.Contains(digest, "### log (") || !strings.Contains(digest, "first line of a long log") || strings.Contains(digest, "detail") {
+		t.Fatalf("expected long notes by their first line, got %q", digest)
+	}
+	if strings.Index(digest, "### log") > strings.Index(digest, "### plan") {
+		t.Fatalf("expected the most recently updated note first, got %q", digest)
+	}
+	if digest := pad.Digest(10); !strings.Contains(digest, "2 more notes") {
+		t.Fatalf("expected the notes beyond the budget to be counted, got %q", digest)
+	}
+}
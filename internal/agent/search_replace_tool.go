@@ -0,0 +1,399 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultSearchReplaceMaxFiles = 20
+	maxSearchReplaceFiles        = 100
+	// maxPreviewsPerFile limits how many changed-line previews are returned for each file
+	maxPreviewsPerFile = 5
+)
+
+// SearchReplaceTool performs workspace-scoped find/replace with literal or regex patterns
+type SearchReplaceTool struct {
+	workspaceRoot string
+	validator     *ToolValidator
+	fileSystem    FileSystemService
+}
+
+// NewSearchReplaceTool creates a new search and replace tool
+func NewSearchReplaceTool(workspaceRoot string) *SearchReplaceTool {
+	return NewSearchReplaceToolWithFS(workspaceRoot, osFileSystem{})
+}
+
+// NewSearchReplaceToolWithFS creates a search and replace tool that reads and
+// writes the workspace through fs
+func NewSearchReplaceToolWithFS(workspaceRoot string, fs FileSystemService) *SearchReplaceTool {
+	return &SearchReplaceTool{
+		workspaceRoot: workspaceRoot,
+		validator:     NewToolValidator(workspaceRoot),
+		fileSystem:    fs,
+	}
+}
+
+func (t *SearchReplaceTool) Name() string {
+	return "search_replace"
+}
+
+func (t *SearchReplaceTool) Description() string {
+	return `Finds and replaces text across files in the workspace. Use this for mechanical edits (renames, API migrations) instead of rewriting whole files.
+
+USAGE EXAMPLES:
+- search_replace({"pattern": "oldName", "replacement": "newName", "include": ["*.go"], "dry_run": true})
+- search_replace({"pattern": "log\\.Printf\\((.*)\\)", "replacement": "logger.Info($1)", "regex": true, "path": "internal"})
+
+IMPORTANT NOTES:
+- Patterns are literal unless regex=true; regex replacements may use $1 or ${name} for capture groups
+- Run with dry_run=true first to review the proposed hunks
+- Refuses to modify more than max_files files (default 20, max 100); narrow path/include if the limit is hit
+- Binary files, dependency directories and build output are skipped`
+}
+
+func (t *SearchReplaceTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"pattern": {
+				"type": "string",
+				"description": "Text or regular expression to search for",
+				"minLength": 1
+			},
+			"replacement": {
+				"type": "string",
+				"description": "Replacement text; with regex=true, $1 and ${name} expand capture groups"
+			},
+			"regex": {
+				"type": "boolean",
+				"description": "Treat pattern as a regular expression (Go RE2 syntax)",
+				"default": false
+			},
+			"case_sensitive": {
+				"type": "boolean",
+				"description": "Match case exactly",
+				"default": true
+			},
+			"path": {
+				"type": "string",
+				"description": "Directory to search, relative to workspace root",
+				"default": "."
+			},
+			"include": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "Glob patterns for files to include, e.g. [\"*.go\", \"internal/**/*.ts\"]"
+			},
+			"dry_run": {
+				"type": "boolean",
+				"description": "Return proposed hunks without modifying files",
+				"default": false
+			},
+			"max_files": {
+				"type": "integer",
+				"description": "Maximum number of files that may be changed in one call",
+				"minimum": 1,
+				"maximum": 100,
+				"default": 20
+			}
+		},
+		"required": ["pattern", "replacement"],
+		"additionalProperties": false
+	}`)
+}
+
+// SearchReplaceParams are the parameters accepted by search_replace
+type SearchReplaceParams struct {
+	Pattern       string   `json:"pattern"`
+	Replacement   string   `json:"replacement"`
+	Regex         bool     `json:"regex,omitempty"`
+	CaseSensitive *bool    `json:"case_sensitive,omitempty"`
+	Path          string   `json:"path,omitempty"`
+	Include       []string `json:"include,omitempty"`
+	DryRun        bool     `json:"dry_run,omitempty"`
+	MaxFiles      int      `json:"max_files,omitempty"`
+}
+
+// ReplacePreview shows the lines touched by one or more matches before and after replacement
+type ReplacePreview struct {
+	Line   int    `json:"line"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// FileReplacement describes the changes made (or proposed) to one file
+type FileReplacement struct {
+	FilePath     string           `json:"file_path"`
+	Replacements int              `json:"replacements"`
+	Previews     []ReplacePreview `json:"previews"`
+	Hunks        string           `json:"hunks"`
+}
+
+func (t *SearchReplaceTool) Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error) {
+	if err := t.validator.ValidateJSONSchema(params, t.Parameters()); err != nil {
+		return NewErrorResult(err.(*StandardizedToolError)), nil
+	}
+
+	var p SearchReplaceParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeInvalidParameters,
+			"Failed to parse parameters",
+			"Ensure all parameters are properly formatted JSON",
+		).WithDetail("parse_error", err.Error())), nil
+	}
+
+	searchDir := p.Path
+	if searchDir == "" {
+		searchDir = "."
+	}
+	if err := t.validator.ValidateDirectoryExists(searchDir); err != nil {
+		return NewErrorResult(err.(*StandardizedToolError)), nil
+	}
+
+	maxFiles := p.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultSearchReplaceMaxFiles
+	}
+	if maxFiles > maxSearchReplaceFiles {
+		maxFiles = maxSearchReplaceFiles
+	}
+
+	re, err := compileSearchPattern(p.Pattern, p.Regex, p.CaseSensitive == nil || *p.CaseSensitive)
+	if err != nil {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeInvalidParameters,
+			fmt.Sprintf("Invalid regular expression: %v", err),
+			"Check the pattern syntax (Go RE2) or set regex=false for a literal search",
+		).WithDetail("pattern", p.Pattern)), nil
+	}
+
+	var changes []FileReplacement
+	oldContents := make(map[string][]byte)
+	newContents := make(map[string][]byte)
+	filesMatched := 0
+	err = walkTextFiles(t.fileSystem, t.workspaceRoot, searchDir, p.Include, func(relPath string, content []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		change, updated := replaceInContent(string(content), re, p.Replacement, !p.Regex)
+		if change.Replacements == 0 {
+			return nil
+		}
+		filesMatched++
+		if len(changes) < maxFiles {
+			change.FilePath = relPath
+			changes = append(changes, change)
+			oldContents[relPath] = content
+			newContents[relPath] = []byte(updated)
+		}
+		return nil
+	})
+	if err != nil {
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeInternalError,
+			fmt.Sprintf("Failed to search workspace: %v", err),
+			"Retry with a narrower path",
+		)), nil
+	}
+
+	totalReplacements := 0
+	var protected []string
+	var refusal *StandardizedToolError
+	for _, change := range changes {
+		totalReplacements += change.Replacements
+		if err := CheckProtected(ctx, filepath.Join(t.workspaceRoot, filepath.FromSlash(change.FilePath)), change.FilePath); err != nil {
+			protected = append(protected, change.FilePath)
+			refusal = err
+		}
+	}
+	if refusal != nil {
+		// Refuse the whole edit, like one over the file limit, rather than
+		// leave protected files out of it
+		return NewErrorResult(refusal.
+			WithDetail("protected_files", protected).
+			WithDetail("suggestion", "Narrow path or include so that protected files are not matched")), nil
+	}
+
+	if filesMatched > maxFiles && !p.DryRun {
+		// Applying a partial edit would leave the workspace inconsistent, so change nothing
+		return NewErrorResult(NewStandardizedError(
+			ErrorCodeResourceLimit,
+			fmt.Sprintf("Pattern matches %d files, more than the limit of %d", filesMatched, maxFiles),
+			"Narrow the search with path or include, raise max_files (up to 100), or run with dry_run=true to review matches",
+		).WithDetail("files_matched", filesMatched).WithDetail("max_files", maxFiles)), nil
+	}
+
+	if !p.DryRun {
+		if refusal := t.applyChanges(ctx, changes, oldContents, newContents); refusal != nil {
+			return NewErrorResult(refusal), nil
+		}
+	}
+
+	data := map[string]interface{}{
+		"dry_run":            p.DryRun,
+		"files_changed":      len(changes),
+		"files_matched":      filesMatched,
+		"total_replacements": totalReplacements,
+		"changes":            changes,
+	}
+	if filesMatched > len(changes) {
+		data["truncated"] = true
+	}
+	if p.DryRun {
+		data["message"] = fmt.Sprintf("Dry run: %d replacement(s) proposed in %d file(s)", totalReplacements, len(changes))
+	} else {
+		data["message"] = fmt.Sprintf("Made %d replacement(s) in %d file(s)", totalReplacements, len(changes))
+	}
+
+	return NewSuccessResult(data), nil
+}
+
+// applyChanges writes the new contents of every changed file, or none of them:
+// files are checkpointed before the first write, each one is replaced as a
+// whole, and a failed write restores the files already written
+func (t *SearchReplaceTool) applyChanges(ctx context.Context, changes []FileReplacement, oldContents, newContents map[string][]byte) *StandardizedToolError {
+	perms := make(map[string]os.FileMode, len(changes))
+	for _, change := range changes {
+		fullPath := filepath.Join(t.workspaceRoot, filepath.FromSlash(change.FilePath))
+		info, err := t.fileSystem.Stat(fullPath)
+		if err != nil {
+			return NewFileNotFoundError(change.FilePath)
+		}
+		perms[change.FilePath] = info.Mode().Perm()
+		if err := SnapshotFile(ctx, fullPath); err != nil {
+			return NewStandardizedError(
+				ErrorCodeInternalError,
+				fmt.Sprintf("Failed to checkpoint file before writing: %s", change.FilePath),
+				"Check read permissions; no files were changed",
+			).WithDetail("file_path", change.FilePath).WithDetail("os_error", err.Error())
+		}
+	}
+
+	for i, change := range changes {
+		fullPath := filepath.Join(t.workspaceRoot, filepath.FromSlash(change.FilePath))
+		if err := writeWholeFile(t.fileSystem, fullPath, newContents[change.FilePath], perms[change.FilePath]); err != nil {
+			refusal := NewStandardizedError(
+				ErrorCodePermissionDenied,
+				fmt.Sprintf("Failed to write file: %s", change.FilePath),
+				"Check write permissions; no files were changed",
+			).WithDetail("file_path", change.FilePath).WithDetail("os_error", err.Error())
+
+			var unrestored []string
+			for _, written := range changes[:i] {
+				writtenPath := filepath.Join(t.workspaceRoot, filepath.FromSlash(written.FilePath))
+				if err := writeWholeFile(t.fileSystem, writtenPath, oldContents[written.FilePath], perms[written.FilePath]); err != nil {
+					unrestored = append(unrestored, written.FilePath)
+				}
+			}
+			if len(unrestored) > 0 {
+				refusal.SuggestionForLLM = "Check write permissions; the listed files could not be restored and still hold the replacement"
+				refusal = refusal.WithDetail("unrestored_files", unrestored)
+			}
+			return refusal
+		}
+	}
+
+	for _, change := range changes {
+		RecordFileVersion(ctx, filepath.Join(t.workspaceRoot, filepath.FromSlash(change.FilePath)), newContents[change.FilePath])
+	}
+	return nil
+}
+
+// compileSearchPattern builds the matcher for a literal or regex pattern
+func compileSearchPattern(pattern string, isRegex, caseSensitive bool) (*regexp.Regexp, error) {
+	if !isRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// replaceInContent replaces every match of re in content. It returns a change
+// record with previews and unified-diff hunks (without context lines) for each
+// group of touched lines, and the updated content.
+func replaceInContent(content string, re *regexp.Regexp, replacement string, literal bool) (FileReplacement, string) {
+	var change FileReplacement
+	matches := re.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return change, content
+	}
+
+	var out, hunks strings.Builder
+	last := 0      // End of the content already copied to out
+	lineDelta := 0 // New line number minus old line number at the current position
+	for i := 0; i < len(matches); {
+		// Expand to whole lines and merge matches that share lines
+		spanStart := lineStartOffset(content, matches[i][0])
+		spanEnd := lineEndOffset(content, matches[i][1])
+		j := i + 1
+		for j < len(matches) && matches[j][0] <= spanEnd {
+			spanEnd = lineEndOffset(content, matches[j][1])
+			j++
+		}
+
+		var replaced strings.Builder
+		pos := spanStart
+		for _, m := range matches[i:j] {
+			replaced.WriteString(content[pos:m[0]])
+			var repl string
+			if literal {
+				repl = replacement
+			} else {
+				repl = string(re.ExpandString(nil, replacement, content, m))
+			}
+			replaced.WriteString(repl)
+			pos = m[1]
+			change.Replacements++
+		}
+		replaced.WriteString(content[pos:spanEnd])
+
+		oldText := content[spanStart:spanEnd]
+		newText := replaced.String()
+		oldStart := strings.Count(content[:spanStart], "\n") + 1
+		if len(change.Previews) < maxPreviewsPerFile {
+			change.Previews = append(change.Previews, ReplacePreview{Line: oldStart, Before: oldText, After: newText})
+		}
+		oldLines := strings.Split(oldText, "\n")
+		newLines := strings.Split(newText, "\n")
+		fmt.Fprintf(&hunks, "@@ -%d,%d +%d,%d @@\n", oldStart, len(oldLines), oldStart+lineDelta, len(newLines))
+		for _, l := range oldLines {
+			hunks.WriteString("-" + l + "\n")
+		}
+		for _, l := range newLines {
+			hunks.WriteString("+" + l + "\n")
+		}
+		lineDelta += len(newLines) - len(oldLines)
+
+		out.WriteString(content[last:spanStart])
+		out.WriteString(newText)
+		last = spanEnd
+		i = j
+	}
+	out.WriteString(content[last:])
+
+	change.Hunks = hunks.String()
+	return change, out.String()
+}
+
+// lineStartOffset returns the offset of the start of the line containing pos
+func lineStartOffset(content string, pos int) int {
+	return strings.LastIndex(content[:pos], "\n") + 1
+}
+
+// lineEndOffset returns the offset of the end of the line containing pos, excluding the newline
+func lineEndOffset(content string, pos int) int {
+	if idx := strings.IndexByte(content[pos:], '\n'); idx >= 0 {
+		return pos + idx
+	}
+	return len(content)
+}
@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestReplaceInContent(t *testing.T) {
+	content := "a := oldName()\nb := 1\nc := oldName(oldName)\n"
+	re := regexp.MustCompile(regexp.QuoteMeta("oldName"))
+
+	change, updated := replaceInContent(content, re, "newName", true)
+	if change.Replacements != 3 {
+		t.Errorf("Expected 3 replacements, got %d", change.Replacements)
+	}
+	if updated != "a := newName()\nb := 1\nc := newName(newName)\n" {
+		t.Errorf("Unexpected updated content: %q", updated)
+	}
+
+	wantHunks := "@@ -1,1 +1,1 @@\n-a := oldName()\n+a := newName()\n" +
+		"@@ -3,1 +3,1 @@\n-c := oldName(oldName)\n+c := newName(newName)\n"
+	if change.Hunks != wantHunks {
+		t.Errorf("Unexpected hunks:\n%s", change.Hunks)
+	}
+	if len(change.Previews) != 2 || change.Previews[1].Line != 3 || change.Previews[1].After != "c := newName(newName)" {
+		t.Errorf("Unexpected previews: %+v", change.Previews)
+	}
+
+	// Regex replacements expand capture groups and can change the line count
+	re = regexp.MustCompile(`b := (\d+)`)
+	change, updated = replaceInContent(content, re, "b := $1\nb2 := $1", false)
+	if !strings.Contains(updated, "b := 1\nb2 := 1\n") || change.Hunks != "@@ -2,1 +2,2 @@\n-b := 1\n+b := 1\n+b2 := 1\n" {
+		t.Errorf("Unexpected regex replacement: %q\n%s", updated, change.Hunks)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.go", "internal/agent/tools.go", true},
+		{"*.go", "README.md", false},
+		{"internal/**/*.go", "internal/agent/tools.go", true},
+		{"internal/**/*.go", "internal/tools.go", true},
+		{"internal/**/*.go", "cmd/root.go", false},
+		{"cmd/*.go", "cmd/root.go", true},
+		{"**/testdata/**", "a/b/testdata/x.txt", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSearchReplaceTool(t *testing.T) {
+	workspace := setupTestWorkspace(t)
+	tool := NewSearchReplaceTool(workspace)
+
+	run := func(params string) *ToolResult {
+		t.Helper()
+		result, err := tool.Execute(context.Background(), json.RawMessage(params))
+		if err != nil {
+			t.Fatalf("Execute(%s) returned error: %v", params, err)
+		}
+		return result
+	}
+
+	t.Run("dry_run_leaves_files", func(t *testing.T) {
+		result := run(`{"pattern": "Helper", "replacement": "Assist", "dry_run": true}`)
+		if !result.Success {
+			t.Fatalf("Expected success, got: %s", result.Error)
+		}
+		data := result.Data.(map[string]interface{})
+		if data["files_changed"] != 1 || data["total_replacements"] != 1 {
+			t.Errorf("Unexpected dry run summary: %+v", data)
+		}
+		content, _ := os.ReadFile(filepath.Join(workspace, "src/utils.go"))
+		if !strings.Contains(string(content), "func Helper()") {
+			t.Errorf("Dry run modified the file")
+		}
+	})
+
+	t.Run("apply_with_include", func(t *testing.T) {
+		result := run(`{"pattern": "hello, world", "replacement": "Hi", "case_sensitive": false, "include": ["*.go"]}`)
+		if !result.Success {
+			t.Fatalf("Expected success, got: %s", result.Error)
+		}
+		content, _ := os.ReadFile(filepath.Join(workspace, "main.go"))
+		if !strings.Contains(string(content), `fmt.Println("Hi!")`) {
+			t.Errorf("Expected replacement in main.go, got %q", content)
+		}
+	})
+
+	t.Run("file_cap", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			path := filepath.Join(workspace, "many", fmt.Sprintf("f%d.txt", i))
+			os.MkdirAll(filepath.Dir(path), 0750)
+			os.WriteFile(path, []byte("token\n"), 0600)
+		}
+		result := run(`{"pattern": "token", "replacement": "x", "path": "many", "max_files": 2}`)
+		if result.Success || result.StandardizedError.Code != ErrorCodeResourceLimit {
+			t.Fatalf("Expected resource limit error, got %+v", result)
+		}
+		content, _ := os.ReadFile(filepath.Join(workspace, "many", "f0.txt"))
+		if string(content) != "token\n" {
+			t.Errorf("No file should change when the cap is exceeded")
+		}
+	})
+
+	t.Run("invalid_regex", func(t *testing.T) {
+		result := run(`{"pattern": "(", "replacement": "x", "regex": true}`)
+		if result.Success {
+			t.Errorf("Expected failure for invalid regex")
+		}
+	})
+
+	t.Run("path_outside_workspace", func(t *testing.T) {
+		result := run(`{"pattern": "x", "replacement": "y", "path": "../"}`)
+		if result.Success {
+			t.Errorf("Expected failure for path outside workspace")
+		}
+	})
+}
+
+// failingWriteFS fails every write of one file
+type failingWriteFS struct {
+	osFileSystem
+	failPath string
+}
+
+func (f failingWriteFS) WriteFileAtomically(path string, data []byte, perm os.FileMode) error {
+	if path == f.failPath {
+		return fmt.Errorf("disk full")
+	}
+	return writeFileAtomically(path, data, perm)
+}
+
+func TestSearchReplaceToolWritesAllOrNothing(t *testing.T) {
+	workspace := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(workspace, name), []byte("oldName\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tool := NewSearchReplaceToolWithFS(workspace, failingWriteFS{failPath: filepath.Join(workspace, "b.txt")})
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{"pattern": "oldName", "replacement": "newName"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success {
+		t.Fatalf("Expected the failed write to fail the call, got %+v", result.Data)
+	}
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		content, err := os.ReadFile(filepath.Join(workspace, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "oldName\n" {
+			t.Errorf("Expected %s to be left unchanged, got %q", name, content)
+		}
+	}
+}
@@ -3,17 +3,63 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/castrovroberto/CGE/internal/security"
 )
 
+// subprocessStopGrace is how long a cancelled subprocess gets to exit after
+// being interrupted before it is killed
+const subprocessStopGrace = 5 * time.Second
+
+// stopGracefully makes cancelling cmd's context interrupt the process rather
+// than kill it, so test runs and builds can clean up after themselves.
+// Processes still running after subprocessStopGrace are killed, and output
+// pipes held open by their children are closed, so a timeout always returns.
+func stopGracefully(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return interruptProcess(cmd)
+	}
+	cmd.WaitDelay = subprocessStopGrace
+}
+
+// DefaultShellEnvAllowlist lists the environment variables commands may see;
+// a trailing * matches any suffix. Everything else CGE was started with, such
+// as API keys, is withheld from commands.
+var DefaultShellEnvAllowlist = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "LANG", "LC_*", "TZ",
+	"TMPDIR", "TMP", "TEMP", "XDG_*", "CI",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy", "SSL_CERT_*",
+	// Go variables are listed one by one, since GO* would match GOOGLE_API_KEY
+	"GOPATH", "GOROOT", "GOFLAGS", "GOCACHE", "GOMODCACHE", "GOPROXY", "GOPRIVATE", "GONOPROXY",
+	"GONOSUMDB", "GOSUMDB", "GOINSECURE", "GOOS", "GOARCH", "GOBIN", "GOTOOLCHAIN", "GOWORK", "GOEXPERIMENT",
+	"CGO_*", "NODE_*", "NPM_CONFIG_*", "PYTHON*", "VIRTUAL_ENV", "PIP_*",
+	"JAVA_HOME", "MAVEN_*", "CARGO_*", "RUSTUP_*", "DOCKER_*", "KUBECONFIG",
+	// Windows
+	"SYSTEMROOT", "SYSTEMDRIVE", "WINDIR", "COMSPEC", "PATHEXT", "USERPROFILE",
+	"APPDATA", "LOCALAPPDATA", "PROGRAMDATA", "PROGRAMFILES*", "PROCESSOR_*", "NUMBER_OF_PROCESSORS",
+}
+
+// ShellRunToolConfig configures the shell tool
+type ShellRunToolConfig struct {
+	// EnvAllowlist lists the environment variables commands inherit and that
+	// calls may set; DefaultShellEnvAllowlist when empty
+	EnvAllowlist []string
+}
+
 // ShellRunTool implements shell command execution capabilities
 type ShellRunTool struct {
 	workspaceRoot   string
 	allowedCommands []string
+	envAllowlist    []string
 }
 
 // NewShellRunTool creates a new shell run tool with security restrictions
@@ -26,11 +72,22 @@ func NewShellRunTool(workspaceRoot string) *ShellRunTool {
 		"docker", "kubectl", "helm", "terraform",
 		"test", "echo", "pwd", "which", "whoami",
 	}
+	allowedCommands = append(allowedCommands, platformShellCommands...)
 
 	return &ShellRunTool{
 		workspaceRoot:   workspaceRoot,
 		allowedCommands: allowedCommands,
+		envAllowlist:    DefaultShellEnvAllowlist,
+	}
+}
+
+// NewShellRunToolWithConfig creates a shell run tool with custom configuration
+func NewShellRunToolWithConfig(workspaceRoot string, config ShellRunToolConfig) *ShellRunTool {
+	tool := NewShellRunTool(workspaceRoot)
+	if len(config.EnvAllowlist) > 0 {
+		tool.envAllowlist = config.EnvAllowlist
 	}
+	return tool
 }
 
 func (t *ShellRunTool) Name() string {
@@ -38,7 +95,7 @@ func (t *ShellRunTool) Name() string {
 }
 
 func (t *ShellRunTool) Description() string {
-	return "Executes a shell command and returns its standard output and standard error. Use with caution - only allowed commands can be executed."
+	return "Executes a shell command and returns its standard output and standard error separately, with the exit code and duration. Output is streamed as progress while it runs. Commands only see allow-listed environment variables. Use with caution - only allowed commands can be executed."
 }
 
 func (t *ShellRunTool) Parameters() json.RawMessage {
@@ -57,6 +114,11 @@ func (t *ShellRunTool) Parameters() json.RawMessage {
 				"type": "integer",
 				"description": "Timeout for command execution in seconds",
 				"default": 30
+			},
+			"env": {
+				"type": "object",
+				"additionalProperties": {"type": "string"},
+				"description": "Environment variables to set for the command; only allow-listed names such as GOFLAGS or NODE_ENV"
 			}
 		},
 		"required": ["command"]
@@ -64,9 +126,10 @@ func (t *ShellRunTool) Parameters() json.RawMessage {
 }
 
 type ShellRunParams struct {
-	Command          string `json:"command"`
-	WorkingDirectory string `json:"working_directory"`
-	TimeoutSeconds   int    `json:"timeout_seconds"`
+	Command          string            `json:"command"`
+	WorkingDirectory string            `json:"working_directory"`
+	TimeoutSeconds   int               `json:"timeout_seconds"`
+	Env              map[string]string `json:"env,omitempty"`
 }
 
 func (t *ShellRunTool) Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error) {
@@ -103,15 +166,40 @@ func (t *ShellRunTool) Execute(ctx context.Context, params json.RawMessage) (*To
 	// Set working directory
 	workDir := t.workspaceRoot
 	if p.WorkingDirectory != "" {
-		workDir = filepath.Join(t.workspaceRoot, p.WorkingDirectory)
+		workDir = filepath.Join(t.workspaceRoot, security.NormalizePath(p.WorkingDirectory))
 		// Security check: ensure working directory is within workspace
-		cleanWorkDir := filepath.Clean(workDir)
-		if !strings.HasPrefix(cleanWorkDir, filepath.Clean(t.workspaceRoot)) {
+		if security.IsDriveRelative(p.WorkingDirectory) || !security.IsWithin(t.workspaceRoot, workDir) {
 			return &ToolResult{
 				Success: false,
 				Error:   "working directory is outside workspace root",
 			}, nil
 		}
+		if info, err := os.Stat(workDir); err != nil || !info.IsDir() {
+			return &ToolResult{
+				Success: false,
+				Error:   fmt.Sprintf("working directory %s does not exist", p.WorkingDirectory),
+			}, nil
+		}
+	}
+
+	if protected := CheckProtectedCommand(ctx, workDir, strings.Fields(p.Command)); protected != nil {
+		return NewErrorResult(protected), nil
+	}
+
+	// Only allow-listed variables may be set
+	var denied []string
+	for name := range p.Env {
+		if !envAllowed(t.envAllowlist, name) {
+			denied = append(denied, name)
+		}
+	}
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		return &ToolResult{
+			Success: false,
+			Error: fmt.Sprintf("environment variables not allowed: %s. Allowed: %s",
+				strings.Join(denied, ", "), strings.Join(t.envAllowlist, ", ")),
+		}, nil
 	}
 
 	// Create context with timeout
@@ -128,20 +216,40 @@ func (t *ShellRunTool) Execute(ctx context.Context, params json.RawMessage) (*To
 	}
 
 	// Create and configure command
-	cmd := exec.CommandContext(cmdCtx, parts[0], parts[1:]...)
+	cmd, err := shellCommand(cmdCtx, parts[0], parts[1:])
+	if err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
 	cmd.Dir = workDir
+	cmd.Env = commandEnv(os.Environ(), t.envAllowlist, p.Env)
+	stopGracefully(cmd)
 
-	// Execute command and capture output
-	output, err := cmd.CombinedOutput()
+	// Execute command and capture output, streaming output lines as progress
+	ReportProgress(ctx, -1, "Running "+p.Command, 0, 0)
+	stdoutWriter := newProgressLineWriter(ctx)
+	stderrWriter := newProgressLineWriter(ctx)
+	stderrWriter.prefix = "stderr: "
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+	start := time.Now()
+	err = cmd.Run()
+	duration := time.Since(start)
 
 	// Determine if command was successful
 	success := err == nil
 	var errorMsg string
 	var exitCode int
+	timedOut := false
 
 	if err != nil {
 		if cmdCtx.Err() == context.DeadlineExceeded {
+			timedOut = true
 			errorMsg = fmt.Sprintf("command timed out after %d seconds", p.TimeoutSeconds)
+		} else if errors.Is(ctx.Err(), context.Canceled) {
+			errorMsg = "command cancelled"
 		} else if exitError, ok := err.(*exec.ExitError); ok {
 			exitCode = exitError.ExitCode()
 			errorMsg = fmt.Sprintf("command exited with code %d", exitCode)
@@ -153,10 +261,12 @@ func (t *ShellRunTool) Execute(ctx context.Context, params json.RawMessage) (*To
 	result := map[string]interface{}{
 		"command":           p.Command,
 		"working_directory": p.WorkingDirectory,
-		"stdout":            string(output),
-		"stderr":            "", // We use CombinedOutput, so stderr is included in stdout
+		"stdout":            string(stdoutWriter.Bytes()),
+		"stderr":            string(stderrWriter.Bytes()),
 		"success":           success,
 		"exit_code":         exitCode,
+		"duration_ms":       duration.Milliseconds(),
+		"timed_out":         timedOut,
 	}
 
 	if errorMsg != "" {
@@ -172,6 +282,48 @@ func (t *ShellRunTool) Execute(ctx context.Context, params json.RawMessage) (*To
 	}, nil
 }
 
+// envAllowed reports whether allowlist has a pattern matching name
+func envAllowed(allowlist []string, name string) bool {
+	for _, pattern := range allowlist {
+		if runtime.GOOS == "windows" {
+			// Variable names are case-insensitive on Windows
+			pattern, name = strings.ToUpper(pattern), strings.ToUpper(name)
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// commandEnv builds the environment of a command: the allow-listed variables
+// of environ, overridden by set
+func commandEnv(environ, allowlist []string, set map[string]string) []string {
+	var env []string
+	for _, entry := range environ {
+		name, _, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			continue
+		}
+		if _, overridden := set[name]; !overridden && envAllowed(allowlist, name) {
+			env = append(env, entry)
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+set[name])
+	}
+	return env
+}
+
 // isCommandAllowed checks if a command is in the allowed list
 func (t *ShellRunTool) isCommandAllowed(command string) bool {
 	parts := strings.Fields(command)
@@ -179,10 +331,8 @@ func (t *ShellRunTool) isCommandAllowed(command string) bool {
 		return false
 	}
 
-	baseCommand := parts[0]
-
 	// Remove path if present (e.g., "/usr/bin/git" -> "git")
-	baseCommand = filepath.Base(baseCommand)
+	baseCommand := commandName(parts[0])
 
 	// Check against allowed commands
 	for _, allowed := range t.allowedCommands {
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellRunToolWorkingDirectory(t *testing.T) {
+	parent := t.TempDir()
+	workspace := filepath.Join(parent, "app")
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "sub"), 0750))
+	require.NoError(t, os.MkdirAll(filepath.Join(parent, "app-secrets"), 0750))
+	tool := NewShellRunTool(workspace)
+
+	for _, dir := range []string{"..", "../app-secrets", "sub/../../app-secrets"} {
+		params, _ := json.Marshal(map[string]interface{}{"command": "pwd", "working_directory": dir})
+		result, err := tool.Execute(context.Background(), params)
+		require.NoError(t, err)
+		assert.False(t, result.Success, dir)
+		assert.Contains(t, result.Error, "outside workspace root", dir)
+	}
+}
+
+func TestShellRunToolCommandName(t *testing.T) {
+	tool := NewShellRunTool(t.TempDir())
+	assert.True(t, tool.isCommandAllowed("git status"))
+	assert.True(t, tool.isCommandAllowed(filepath.Join("usr", "bin", "git")+" status"))
+	assert.False(t, tool.isCommandAllowed("rm -rf ."))
+
+	if runtime.GOOS == "windows" {
+		assert.True(t, tool.isCommandAllowed(`C:\Git\cmd\GIT.EXE status`))
+		assert.True(t, tool.isCommandAllowed("dir /b"))
+	}
+}
+
+func TestShellRunToolSeparateOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses ls")
+	}
+	tool := NewShellRunTool(t.TempDir())
+	params, _ := json.Marshal(map[string]interface{}{"command": "ls missing-file"})
+	result, err := tool.Execute(context.Background(), params)
+	require.NoError(t, err)
+
+	data := result.Data.(map[string]interface{})
+	assert.Equal(t, false, data["success"])
+	assert.NotZero(t, data["exit_code"])
+	assert.Empty(t, data["stdout"])
+	assert.Contains(t, data["stderr"], "missing-file")
+	assert.Contains(t, data, "duration_ms")
+	assert.Equal(t, false, data["timed_out"])
+}
+
+func TestShellRunToolEnvironment(t *testing.T) {
+	if _, err := exec.LookPath("env"); err != nil || runtime.GOOS == "windows" {
+		t.Skip("env not available")
+	}
+	t.Setenv("CGE_TEST_API_KEY", "secret")
+	t.Setenv("LANG", "C")
+	tool := NewShellRunToolWithConfig(t.TempDir(), ShellRunToolConfig{EnvAllowlist: []string{"PATH", "LANG", "GO*"}})
+	tool.AddAllowedCommand("env")
+
+	params, _ := json.Marshal(map[string]interface{}{"command": "env", "env": map[string]string{"GOFLAGS": "-count=1"}})
+	result, err := tool.Execute(context.Background(), params)
+	require.NoError(t, err)
+	stdout := result.Data.(map[string]interface{})["stdout"].(string)
+	assert.Contains(t, stdout, "LANG=C\n")
+	assert.Contains(t, stdout, "GOFLAGS=-count=1\n")
+	assert.NotContains(t, stdout, "CGE_TEST_API_KEY", "variables outside the allow-list are withheld")
+
+	params, _ = json.Marshal(map[string]interface{}{"command": "env", "env": map[string]string{"AWS_SECRET_ACCESS_KEY": "x"}})
+	result, err = tool.Execute(context.Background(), params)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "AWS_SECRET_ACCESS_KEY")
+}
+
+func TestShellRunToolMissingWorkingDirectory(t *testing.T) {
+	tool := NewShellRunTool(t.TempDir())
+	params, _ := json.Marshal(map[string]interface{}{"command": "pwd", "working_directory": "missing"})
+	result, err := tool.Execute(context.Background(), params)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "does not exist")
+}
+
+func TestEnvAllowed(t *testing.T) {
+	allowlist := []string{"PATH", "GO*"}
+	assert.True(t, envAllowed(allowlist, "PATH"))
+	assert.True(t, envAllowed(allowlist, "GOFLAGS"))
+	assert.False(t, envAllowed(allowlist, "PATHEXT"))
+	assert.False(t, envAllowed(allowlist, "OPENAI_API_KEY"))
+}
+
+func TestDefaultShellEnvAllowlistWithholdsGoogleCredentials(t *testing.T) {
+	env := commandEnv([]string{
+		"GOPATH=/go",
+		"GOFLAGS=-mod=mod",
+		"GOOGLE_API_KEY=secret",
+		"GOOGLE_APPLICATION_CREDENTIALS=/keys/sa.json",
+	}, DefaultShellEnvAllowlist, nil)
+	assert.Contains(t, env, "GOPATH=/go")
+	assert.Contains(t, env, "GOFLAGS=-mod=mod")
+	assert.NotContains(t, env, "GOOGLE_API_KEY=secret")
+	assert.NotContains(t, env, "GOOGLE_APPLICATION_CREDENTIALS=/keys/sa.json")
+}
@@ -0,0 +1,257 @@
+//go:build linux
+
+package agent
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// snippetSandboxEnv marks the process that sets up the sandbox of a snippet:
+// CGE itself, started again in new namespaces with the writable directories,
+// the workspace and the interpreter's command line as arguments
+const snippetSandboxEnv = "CGE_SNIPPET_SANDBOX"
+
+// snippetSandboxErrFD is the descriptor on which the sandbox reports why it
+// could not be set up; it is closed when the interpreter starts
+const snippetSandboxErrFD = 3
+
+func init() {
+	if os.Getenv(snippetSandboxEnv) == "1" {
+		enterSnippetSandbox(os.Args[1:])
+	}
+}
+
+// sandboxSnippet returns the command running argv inside new user, mount,
+// network and PID namespaces. The snippet has no network but loopback, the
+// whole file system is read-only except the writable directories, and the
+// workspace is hidden behind an empty directory; killing it on timeout ends
+// every process it started. Call setupErr after the command ran: it reports
+// a sandbox that could not be set up, in which case the snippet did not run.
+func sandboxSnippet(ctx context.Context, workspaceRoot string, writable []string, argv []string) (cmd *exec.Cmd, setupErr func() error, err error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot find the CGE executable: %w", err)
+	}
+	resolved := make([]string, len(writable))
+	for i, dir := range writable {
+		if resolved[i], err = filepath.EvalSymlinks(dir); err != nil {
+			return nil, nil, err
+		}
+	}
+	if workspaceRoot, err = filepath.Abs(workspaceRoot); err != nil {
+		return nil, nil, err
+	}
+	if real, err := filepath.EvalSymlinks(workspaceRoot); err == nil {
+		workspaceRoot = real
+	}
+
+	errRead, errWrite, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	dirs := strings.Join(resolved, string(os.PathListSeparator))
+	cmd = exec.CommandContext(ctx, self, append([]string{dirs, workspaceRoot}, argv...)...) // #nosec G204 - CGE itself
+	cmd.Env = []string{snippetSandboxEnv + "=1"}
+	cmd.ExtraFiles = []*os.File{errWrite}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET | syscall.CLONE_NEWPID,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	setupErr = func() error {
+		defer errRead.Close()
+		errWrite.Close()
+		message, _ := io.ReadAll(errRead)
+		if len(message) > 0 {
+			return errors.New(strings.TrimSpace(string(message)))
+		}
+		return nil
+	}
+	return cmd, setupErr, nil
+}
+
+// enterSnippetSandbox isolates the file system of the sandbox process and
+// replaces it with the interpreter. It does not return.
+func enterSnippetSandbox(args []string) {
+	errPipe := os.NewFile(snippetSandboxErrFD, "sandbox-errors")
+	fail := func(err error) {
+		fmt.Fprintf(errPipe, "cannot isolate the snippet: %v\n", err)
+		os.Exit(125)
+	}
+	if len(args) < 3 {
+		fail(errors.New("missing arguments"))
+	}
+	writable, workspaceRoot, argv := filepath.SplitList(args[0]), args[1], args[2:]
+
+	// Capabilities are per thread, so they are dropped on the thread that
+	// starts the interpreter
+	runtime.LockOSThread()
+	if err := isolateFileSystem(writable, workspaceRoot); err != nil {
+		fail(err)
+	}
+	if err := dropCapabilities(); err != nil {
+		fail(err)
+	}
+	if _, err := unix.FcntlInt(snippetSandboxErrFD, unix.F_SETFD, unix.FD_CLOEXEC); err != nil {
+		fail(err)
+	}
+
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, snippetSandboxEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	fail(syscall.Exec(argv[0], argv, env)) // #nosec G204 - interpreter from a fixed list
+}
+
+// isolateFileSystem makes every mount read-only except the writable
+// directories, the first of which becomes the working directory, and hides
+// the workspace behind an empty read-only file system
+func isolateFileSystem(writable []string, workspaceRoot string) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %w", err)
+	}
+	// A bind mount of its own keeps a directory writable when the mount
+	// holding it becomes read-only
+	for _, dir := range writable {
+		if err := unix.Mount(dir, dir, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("mounting %s: %w", dir, err)
+		}
+	}
+	mounts, err := readMounts()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if withinAny(m.point, writable) {
+			continue
+		}
+		flags := uintptr(unix.MS_REMOUNT | unix.MS_BIND | unix.MS_RDONLY | m.flags)
+		if err := unix.Mount("", m.point, "", flags, ""); err != nil {
+			// Mounts the user cannot reach are out of the snippet's reach too
+			if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.EACCES) {
+				continue
+			}
+			return fmt.Errorf("making %s read-only: %w", m.point, err)
+		}
+	}
+	if workspaceRoot != "/" && !withinAny(workspaceRoot, writable) {
+		for _, dir := range writable {
+			if withinAny(dir, []string{workspaceRoot}) {
+				return fmt.Errorf("%s is inside the workspace", dir)
+			}
+		}
+		if err := unix.Mount("tmpfs", workspaceRoot, "tmpfs", unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "size=4k"); err != nil {
+			return fmt.Errorf("hiding the workspace: %w", err)
+		}
+	}
+	// The working directory still refers to the mount it was on
+	return unix.Chdir(writable[0])
+}
+
+// withinAny reports whether path is one of dirs or inside one of them
+func withinAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mount is a mount point and the flags it must keep when remounted
+type mount struct {
+	point string
+	flags int
+}
+
+// mountFlags are the per-mount options that a remount in a user namespace
+// has to keep
+var mountFlags = map[string]int{
+	"nosuid":     unix.MS_NOSUID,
+	"nodev":      unix.MS_NODEV,
+	"noexec":     unix.MS_NOEXEC,
+	"noatime":    unix.MS_NOATIME,
+	"nodiratime": unix.MS_NODIRATIME,
+	"relatime":   unix.MS_RELATIME,
+}
+
+// readMounts lists the mounts of the process from /proc/self/mountinfo
+func readMounts() ([]mount, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []mount
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// ID, parent ID, major:minor, root, mount point, options, ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		m := mount{point: unescapeMountPath(fields[4])}
+		for _, option := range strings.Split(fields[5], ",") {
+			m.flags |= mountFlags[option]
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes of spaces, tabs, newlines and
+// backslashes in mountinfo paths
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if n, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// dropCapabilities drops every capability of the calling thread, including
+// the bounding set, so the interpreter cannot undo the read-only mounts even
+// though it runs as root of its user namespace
+func dropCapabilities() error {
+	for c := 0; c <= unix.CAP_LAST_CAP; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil && !errors.Is(err, unix.EINVAL) {
+			return fmt.Errorf("dropping capability %d: %w", c, err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("setting no_new_privs: %w", err)
+	}
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&header, &data[0]); err != nil {
+		return fmt.Errorf("dropping capabilities: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// sandboxSnippet refuses to run snippets: outside Linux they cannot be kept
+// from the network and the file system without privileges
+func sandboxSnippet(ctx context.Context, workspaceRoot string, writable []string, argv []string) (*exec.Cmd, func() error, error) {
+	return nil, nil, errors.New("snippets can only be sandboxed on Linux")
+}
@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxSnippetOutput is how much of each output stream of a snippet is kept
+const maxSnippetOutput = 16 * 1024
+
+// RunSnippetParams are the parameters of run_snippet
+type RunSnippetParams struct {
+	Language       string `json:"language" description:"Language of the snippet" jsonschema:"required,enum=go|python|javascript"`
+	Code           string `json:"code" description:"Source of the snippet; Go snippets must be a complete main package using only the standard library" jsonschema:"required,minLength=1,maxLength=65536"`
+	Stdin          string `json:"stdin" description:"Standard input passed to the snippet"`
+	TimeoutSeconds int    `json:"timeout_seconds" description:"Time the snippet may run, including compilation" jsonschema:"default=10,minimum=1,maximum=60"`
+}
+
+// snippetRuntime describes how snippets of a language are run
+type snippetRuntime struct {
+	file     string   // Name the snippet is saved as
+	commands []string // Interpreters to try, in order
+	args     []string // Arguments before the file name
+}
+
+var snippetRuntimes = map[string]snippetRuntime{
+	"go":         {file: "main.go", commands: []string{"go"}, args: []string{"run"}},
+	"python":     {file: "snippet.py", commands: []string{"python3", "python"}},
+	"javascript": {file: "snippet.js", commands: []string{"node"}},
+}
+
+// SnippetToolConfig configures run_snippet
+type SnippetToolConfig struct {
+	Enabled bool // The tool is only registered when enabled
+}
+
+// snippetCacheDir returns the build cache of Go snippets, kept apart from the
+// user's so snippets, which may write to it, cannot tamper with other builds
+func snippetCacheDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cache, "cge", "snippet-go-build")
+	return dir, os.MkdirAll(dir, 0700)
+}
+
+// snippetEnv keeps the toolchains of snippets from fetching anything, in
+// addition to the sandbox, and points them at the directories the sandbox
+// lets them write to
+func snippetEnv(dir, goCache string) map[string]string {
+	return map[string]string{
+		"TMPDIR":      dir,
+		"GOCACHE":     goCache,
+		"GOPROXY":     "off",
+		"GOTOOLCHAIN": "local",
+		"GOFLAGS":     "-mod=mod",
+		"HTTP_PROXY":  "http://127.0.0.1:9",
+		"HTTPS_PROXY": "http://127.0.0.1:9",
+		"http_proxy":  "http://127.0.0.1:9",
+		"https_proxy": "http://127.0.0.1:9",
+	}
+}
+
+// NewRunSnippetTool creates the run_snippet tool, which runs short programs in
+// a sandboxed scratch directory so the model can check logic before writing
+// it into the workspace. Snippets are refused where they cannot be sandboxed.
+func NewRunSnippetTool(workspaceRoot string) Tool {
+	return NewTypedTool("run_snippet", `Runs a short Go, Python or JavaScript snippet in an empty temporary directory and returns its standard output and standard error. Use it to check a regular expression, an algorithm or a library call before writing it into the codebase. Snippets run in a sandbox with a timeout: they cannot see the workspace, reach the network or write anywhere but their own directory, which is deleted afterwards.
+
+USAGE EXAMPLES:
+- run_snippet({"language": "python", "code": "import re\nprint(re.findall(r'v(\\d+)', 'v1 v22'))"})
+- run_snippet({"language": "go", "code": "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(7 / 2) }"})`,
+		func(ctx context.Context, params RunSnippetParams) (interface{}, error) {
+			return runSnippet(ctx, workspaceRoot, params)
+		})
+}
+
+// runSnippet saves the snippet to a scratch directory and runs it there, in
+// the sandbox
+func runSnippet(ctx context.Context, workspaceRoot string, params RunSnippetParams) (interface{}, error) {
+	if params.TimeoutSeconds == 0 {
+		params.TimeoutSeconds = 10
+	}
+	rt := snippetRuntimes[params.Language]
+	interpreter := ""
+	for _, command := range rt.commands {
+		if path, err := exec.LookPath(command); err == nil {
+			interpreter = path
+			break
+		}
+	}
+	if interpreter == "" {
+		return nil, NewStandardizedError(ErrorCodeCommandNotFound,
+			fmt.Sprintf("no %s interpreter found (looked for %s)", params.Language, strings.Join(rt.commands, ", ")),
+			"Use a language whose toolchain is installed")
+	}
+
+	dir, err := os.MkdirTemp("", "cge-snippet-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, rt.file), []byte(params.Code), 0600); err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(params.TimeoutSeconds)*time.Second)
+	defer cancel()
+	// Without a cache of their own, Go snippets build the packages they use
+	// from scratch each time
+	writable, goCache := []string{dir}, filepath.Join(dir, ".gocache")
+	if cache, err := snippetCacheDir(); err == nil {
+		writable, goCache = append(writable, cache), cache
+	}
+	argv := append(append([]string{interpreter}, rt.args...), rt.file)
+	cmd, sandboxErr, err := sandboxSnippet(runCtx, workspaceRoot, writable, argv)
+	if err != nil {
+		return nil, snippetSandboxUnavailable(err)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Env, commandEnv(os.Environ(), DefaultShellEnvAllowlist, snippetEnv(dir, goCache))...)
+	cmd.Stdin = strings.NewReader(params.Stdin)
+	stdout := &cappedBuffer{limit: maxSnippetOutput}
+	stderr := &cappedBuffer{limit: maxSnippetOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = subprocessStopGrace
+
+	ReportProgress(ctx, -1, "Running "+params.Language+" snippet", 0, 0)
+	start := time.Now()
+	err = cmd.Run()
+	duration := time.Since(start)
+	if setupErr := sandboxErr(); setupErr != nil {
+		return nil, snippetSandboxUnavailable(setupErr)
+	}
+
+	data := map[string]interface{}{
+		"language":    params.Language,
+		"stdout":      stdout.String(),
+		"stderr":      stderr.String(),
+		"success":     err == nil,
+		"exit_code":   0,
+		"duration_ms": duration.Milliseconds(),
+		"timed_out":   false,
+	}
+	if stdout.truncated || stderr.truncated {
+		data["truncated"] = true
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		data["timed_out"] = true
+		data["error_message"] = fmt.Sprintf("snippet timed out after %d seconds", params.TimeoutSeconds)
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case errors.As(err, &exitErr):
+		data["exit_code"] = exitErr.ExitCode()
+		data["error_message"] = fmt.Sprintf("snippet exited with code %d", exitErr.ExitCode())
+	case cmd.ProcessState == nil:
+		return nil, snippetSandboxUnavailable(err)
+	default:
+		return nil, err
+	}
+	// As with shell commands, a snippet that fails is still a successful call
+	return data, nil
+}
+
+// snippetSandboxUnavailable is the error of a snippet that was not run
+// because it could not be sandboxed
+func snippetSandboxUnavailable(err error) error {
+	return NewStandardizedError(ErrorCodeUnsupportedOperation,
+		fmt.Sprintf("refusing to run the snippet outside its sandbox: %v", err),
+		"Snippets need Linux with unprivileged user namespaces; check the code by running the tests instead")
+}
+
+// cappedBuffer keeps the first limit bytes written to it and drops the rest.
+// The buffer is not embedded, so copies cannot bypass Write with ReadFrom.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write implements io.Writer
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns the output kept
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
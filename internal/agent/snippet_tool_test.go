@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func runSnippetTool(t *testing.T, args string) map[string]interface{} {
+	t.Helper()
+	result, err := NewRunSnippetTool(t.TempDir()).Execute(context.Background(), json.RawMessage(args))
+	if err != nil || !result.Success {
+		t.Fatalf("expected the call to succeed, got %v %+v", err, result)
+	}
+	return result.Data.(map[string]interface{})
+}
+
+func TestRunSnippet(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+
+	data := runSnippetTool(t, `{"language": "python", "code": "import re, sys\nprint(re.findall(r'v(\\d+)', sys.stdin.read()))", "stdin": "v1 v22"}`)
+	if data["stdout"] != "['1', '22']\n" || data["success"] != true {
+		t.Errorf("unexpected result: %v", data)
+	}
+
+	data = runSnippetTool(t, `{"language": "python", "code": "import sys\nsys.stderr.write('boom')\nsys.exit(3)"}`)
+	if data["success"] != false || data["exit_code"] != 3 || data["stderr"] != "boom" {
+		t.Errorf("expected the failure to be reported, got %v", data)
+	}
+
+	data = runSnippetTool(t, `{"language": "python", "code": "print('x' * 100000)"}`)
+	if len(data["stdout"].(string)) != maxSnippetOutput || data["truncated"] != true {
+		t.Errorf("expected the output to be capped, got %d bytes", len(data["stdout"].(string)))
+	}
+
+	data = runSnippetTool(t, `{"language": "python", "code": "import time\ntime.sleep(5)", "timeout_seconds": 1}`)
+	if data["timed_out"] != true {
+		t.Errorf("expected a timeout, got %v", data)
+	}
+}
+
+func TestRunSnippetHasNoNetwork(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil || runtime.GOOS != "linux" {
+		t.Skip("network isolation needs Linux and python3")
+	}
+
+	result, err := NewRunSnippetTool(t.TempDir()).Execute(context.Background(), json.RawMessage(`{"language": "python",
+		"code": "import socket\ns = socket.socket()\ns.settimeout(2)\nprint(s.connect_ex(('1.1.1.1', 80)))"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success && result.StandardizedError.Code == ErrorCodeUnsupportedOperation {
+		t.Skip("user namespaces are not available")
+	}
+	data := result.Data.(map[string]interface{})
+	if strings.TrimSpace(data["stdout"].(string)) == "0" {
+		t.Errorf("expected the connection to fail, got %v", data)
+	}
+}
+
+func TestRunSnippetFileSystemIsReadOnly(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil || runtime.GOOS != "linux" {
+		t.Skip("the sandbox needs Linux and python3")
+	}
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "secret.txt"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+
+	code := fmt.Sprintf(`import os
+print(os.path.exists(%q))
+for path in (%q, %q, "scratch.txt"):
+    try:
+        open(path, "w").write("x")
+        print("wrote", path)
+    except OSError as e:
+        print("denied", path)
+`, filepath.Join(workspace, "secret.txt"), filepath.Join(workspace, "new.txt"), filepath.Join(outside, "new.txt"))
+	args, _ := json.Marshal(map[string]string{"language": "python", "code": code})
+	result, err := NewRunSnippetTool(workspace).Execute(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success && result.StandardizedError.Code == ErrorCodeUnsupportedOperation {
+		t.Skip("user namespaces are not available")
+	}
+	stdout := result.Data.(map[string]interface{})["stdout"].(string)
+	want := fmt.Sprintf("False\ndenied %s\ndenied %s\nwrote scratch.txt\n", filepath.Join(workspace, "new.txt"), filepath.Join(outside, "new.txt"))
+	if stdout != want {
+		t.Errorf("expected the workspace to be hidden and only the scratch directory writable, got %q", stdout)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Error("the snippet wrote outside its directory")
+	}
+}
+
+func TestRunSnippetRejectsUnknownLanguages(t *testing.T) {
+	_, err := NewRunSnippetTool(t.TempDir()).Execute(context.Background(), json.RawMessage(`{"language": "ruby", "code": "puts 1"}`))
+	if err == nil || !strings.Contains(err.Error(), "language") {
+		t.Errorf("expected the language to be rejected, got %v", err)
+	}
+}
+
+func TestRunSnippetIsOptIn(t *testing.T) {
+	factory := NewToolFactory(t.TempDir())
+	if _, ok := factory.CreateReviewRegistry().Get("run_snippet"); ok {
+		t.Error("expected run_snippet to be off by default")
+	}
+	factory.SetSnippetConfig(SnippetToolConfig{Enabled: true})
+	if _, ok := factory.CreateReviewRegistry().Get("run_snippet"); !ok {
+		t.Error("expected run_snippet in the review registry once enabled")
+	}
+	for name, registry := range map[string]*Registry{"planning": factory.CreatePlanningRegistry(), "generation": factory.CreateGenerationRegistry()} {
+		if _, ok := registry.Get("run_snippet"); ok {
+			t.Errorf("expected run_snippet to stay out of the %s registry", name)
+		}
+	}
+}
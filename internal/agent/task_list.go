@@ -0,0 +1,305 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Names of the task list tools
+const (
+	UpdateTasksToolName = "update_tasks"
+	ListTasksToolName   = "list_tasks"
+)
+
+// Bounds of a task list
+const (
+	maxTasks          = 50
+	maxTaskTitleChars = 200
+)
+
+// TaskStatus is the status of a task of a TaskList
+type TaskStatus string
+
+// Statuses of a task
+const (
+	TaskPending    TaskStatus = "pending"
+	TaskInProgress TaskStatus = "in_progress"
+	TaskDone       TaskStatus = "done"
+)
+
+// TaskItem is a task of a TaskList
+type TaskItem struct {
+	ID     string     `json:"id"`
+	Title  string     `json:"title"`
+	Status TaskStatus `json:"status"`
+}
+
+// TaskList is the checklist an agent keeps of the steps of a long run, so
+// neither it nor the user loses track of what is done and what is left.
+// Tasks are updated with the task list tools, which find it in the Execute
+// context, see WithTaskList.
+type TaskList struct {
+	mu       sync.Mutex
+	tasks    []TaskItem
+	revision int
+	onChange func([]TaskItem)
+}
+
+// NewTaskList creates a task list holding tasks, e.g. those saved with a
+// session
+func NewTaskList(tasks []TaskItem) *TaskList {
+	return &TaskList{tasks: append([]TaskItem(nil), tasks...)}
+}
+
+// OnChange sets fn to be called with the tasks whenever they change
+func (l *TaskList) OnChange(fn func([]TaskItem)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onChange = fn
+}
+
+// Tasks returns a copy of the tasks in their order, nil when there are none
+func (l *TaskList) Tasks() []TaskItem {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.copyTasks()
+}
+
+// Revision counts the changes made to the list
+func (l *TaskList) Revision() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.revision
+}
+
+// Progress returns how many tasks are done, out of how many
+func (l *TaskList) Progress() (done, total int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, task := range l.tasks {
+		if task.Status == TaskDone {
+			done++
+		}
+	}
+	return done, len(l.tasks)
+}
+
+// Open returns the tasks not done yet
+func (l *TaskList) Open() []TaskItem {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var open []TaskItem
+	for _, task := range l.tasks {
+		if task.Status != TaskDone {
+			open = append(open, task)
+		}
+	}
+	return open
+}
+
+// Replace sets the tasks of the list, e.g. to restore those of a session or
+// clear them with nil
+func (l *TaskList) Replace(tasks []TaskItem) {
+	l.mu.Lock()
+	l.tasks = append([]TaskItem(nil), tasks...)
+	l.changed()
+}
+
+// Update merges tasks into the list by ID: the status and title given
+// replace those of the task with that ID, and tasks with a new ID are added
+// at the end, pending unless another status is given. The tasks with an ID in
+// remove are then dropped. Nothing changes when an update is invalid.
+func (l *TaskList) Update(tasks []TaskItem, remove []string) error {
+	l.mu.Lock()
+	updated := append([]TaskItem(nil), l.tasks...)
+	index := make(map[string]int, len(updated))
+	for i, task := range updated {
+		index[task.ID] = i
+	}
+	for _, task := range tasks {
+		task.ID = strings.TrimSpace(task.ID)
+		task.Title = strings.TrimSpace(task.Title)
+		if task.ID == "" {
+			l.mu.Unlock()
+			return fmt.Errorf("a task ID is required")
+		}
+		if len(task.Title) > maxTaskTitleChars {
+			l.mu.Unlock()
+			return fmt.Errorf("the title of task %q is %d characters, more than the %d a title may hold", task.ID, len(task.Title), maxTaskTitleChars)
+		}
+		switch task.Status {
+		case "", TaskPending, TaskInProgress, TaskDone:
+		default:
+			l.mu.Unlock()
+			return fmt.Errorf("task %q has unknown status %q", task.ID, task.Status)
+		}
+		i, exists := index[task.ID]
+		if !exists {
+			if task.Title == "" {
+				l.mu.Unlock()
+				return fmt.Errorf("new task %q needs a title", task.ID)
+			}
+			if task.Status == "" {
+				task.Status = TaskPending
+			}
+			index[task.ID] = len(updated)
+			updated = append(updated, task)
+			continue
+		}
+		if task.Title != "" {
+			updated[i].Title = task.Title
+		}
+		if task.Status != "" {
+			updated[i].Status = task.Status
+		}
+	}
+	if len(remove) > 0 {
+		drop := make(map[string]bool, len(remove))
+		for _, id := range remove {
+			drop[strings.TrimSpace(id)] = true
+		}
+		kept := updated[:0]
+		for _, task := range updated {
+			if !drop[task.ID] {
+				kept = append(kept, task)
+			}
+		}
+		updated = kept
+	}
+	if len(updated) > maxTasks {
+		l.mu.Unlock()
+		return fmt.Errorf("the list would hold %d tasks, more than the %d it may", len(updated), maxTasks)
+	}
+	l.tasks = updated
+	l.changed()
+	return nil
+}
+
+// Checklist renders the tasks one per line, "[x]" when done, "[~]" in
+// progress and "[ ]" pending. It is empty when there are no tasks.
+func (l *TaskList) Checklist() string {
+	return FormatChecklist(l.Tasks())
+}
+
+// FormatChecklist renders tasks as TaskList.Checklist does
+func FormatChecklist(tasks []TaskItem) string {
+	lines := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		switch task.Status {
+		case TaskDone:
+			lines = append(lines, fmt.Sprintf("[x] %s: %s", task.ID, task.Title))
+		case TaskInProgress:
+			lines = append(lines, fmt.Sprintf("[~] %s: %s (in progress)", task.ID, task.Title))
+		default:
+			lines = append(lines, fmt.Sprintf("[ ] %s: %s", task.ID, task.Title))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// changed records a change and notifies the OnChange function; callers hold
+// mu, which it releases
+func (l *TaskList) changed() {
+	l.revision++
+	tasks, onChange := l.copyTasks(), l.onChange
+	l.mu.Unlock()
+	if onChange != nil {
+		onChange(tasks)
+	}
+}
+
+// copyTasks returns a copy of the tasks; callers hold mu
+func (l *TaskList) copyTasks() []TaskItem {
+	if len(l.tasks) == 0 {
+		return nil
+	}
+	return append([]TaskItem(nil), l.tasks...)
+}
+
+type taskListKey struct{}
+
+// WithTaskList returns a context in which the task list tools use list
+func WithTaskList(ctx context.Context, list *TaskList) context.Context {
+	return context.WithValue(ctx, taskListKey{}, list)
+}
+
+// TaskListFromContext returns the task list carried by ctx, if any
+func TaskListFromContext(ctx context.Context) (*TaskList, bool) {
+	list, ok := ctx.Value(taskListKey{}).(*TaskList)
+	return list, ok && list != nil
+}
+
+// TaskUpdate is a task added or changed by update_tasks
+type TaskUpdate struct {
+	ID     string `json:"id" description:"Short unique ID of the task, e.g. 1 or tests" jsonschema:"required,minLength=1"`
+	Title  string `json:"title,omitempty" description:"What the task is; required for new tasks, omit to keep the current title"`
+	Status string `json:"status,omitempty" description:"pending for new tasks by default; in_progress when you start it, done once finished" jsonschema:"enum=pending|in_progress|done"`
+}
+
+// UpdateTasksParams are the parameters of update_tasks
+type UpdateTasksParams struct {
+	Tasks  []TaskUpdate `json:"tasks,omitempty" description:"Tasks to add, or whose status or title changes; the others are left as they are"`
+	Remove []string     `json:"remove,omitempty" description:"IDs of the tasks no longer needed"`
+}
+
+// ListTasksParams are the parameters of list_tasks
+type ListTasksParams struct{}
+
+// TaskListTools returns the task list tools, which work on the task list of
+// the Execute context
+func TaskListTools() []Tool {
+	return []Tool{NewUpdateTasksTool(), NewListTasksTool()}
+}
+
+// taskListResult describes the list after a call to a task list tool
+func taskListResult(list *TaskList) map[string]interface{} {
+	done, total := list.Progress()
+	tasks := list.Tasks()
+	if tasks == nil {
+		tasks = []TaskItem{}
+	}
+	return map[string]interface{}{"tasks": tasks, "done": done, "total": total}
+}
+
+// NewUpdateTasksTool creates the update_tasks tool, which maintains the task
+// list of the run
+func NewUpdateTasksTool() Tool {
+	return NewTypedTool(UpdateTasksToolName, `Maintains your task list for this run: the steps of the task, each pending, in_progress or done. For work that takes several steps, add the tasks first, mark each in_progress when you start it and done once finished. The user follows the list as a checklist, and it is shown to you with each step.
+
+USAGE EXAMPLES:
+- update_tasks({"tasks": [{"id": "1", "title": "Add the config option"}, {"id": "2", "title": "Update the tests"}]})
+- update_tasks({"tasks": [{"id": "1", "status": "done"}, {"id": "2", "status": "in_progress"}]})
+- update_tasks({"remove": ["2"]})`,
+		func(ctx context.Context, params UpdateTasksParams) (interface{}, error) {
+			list, ok := TaskListFromContext(ctx)
+			if !ok {
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, "no task list is available in this run", "Keep track of the steps in your replies instead")
+			}
+			if len(params.Tasks) == 0 && len(params.Remove) == 0 {
+				return nil, NewStandardizedError(ErrorCodeMissingParameter, "no tasks to update or remove", "Give the tasks to add or change, or the IDs to remove")
+			}
+			updates := make([]TaskItem, 0, len(params.Tasks))
+			for _, task := range params.Tasks {
+				updates = append(updates, TaskItem{ID: task.ID, Title: task.Title, Status: TaskStatus(task.Status)})
+			}
+			if err := list.Update(updates, params.Remove); err != nil {
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, err.Error(), "Give new tasks an ID and a title, and a status of pending, in_progress or done").WithDetail("tasks", list.Tasks())
+			}
+			return taskListResult(list), nil
+		})
+}
+
+// NewListTasksTool creates the list_tasks tool, which reads the task list of
+// the run
+func NewListTasksTool() Tool {
+	return NewTypedTool(ListTasksToolName, `Lists the tasks of your task list for this run with their status, and how many are done.`,
+		func(ctx context.Context, params ListTasksParams) (interface{}, error) {
+			list, ok := TaskListFromContext(ctx)
+			if !ok {
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, "no task list is available in this run", "Keep track of the steps in your replies instead")
+			}
+			return taskListResult(list), nil
+		})
+}
@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTaskListUpdate(t *testing.T) {
+	list := NewTaskList(nil)
+	var seen [][]TaskItem
+	list.OnChange(func(tasks []TaskItem) { seen = append(seen, tasks) })
+
+	if err := list.Update([]TaskItem{{ID: "1", Title: "Add the option"}, {ID: "2", Title: "Update the tests"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := list.Update([]TaskItem{{ID: "1", Status: TaskDone}, {ID: "2", Status: TaskInProgress}, {ID: "3", Title: "Document it"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []TaskItem{
+		{ID: "1", Title: "Add the option", Status: TaskDone},
+		{ID: "2", Title: "Update the tests", Status: TaskInProgress},
+		{ID: "3", Title: "Document it", Status: TaskPending},
+	}
+	if !reflect.DeepEqual(list.Tasks(), want) {
+		t.Fatalf("expected the updates merged by ID, got %+v", list.Tasks())
+	}
+	if done, total := list.Progress(); done != 1 || total != 3 {
+		t.Fatalf("expected 1 of 3 done, got %d of %d", done, total)
+	}
+	if len(seen) != 2 || !reflect.DeepEqual(seen[1], want) {
+		t.Fatalf("expected each change to be reported, got %+v", seen)
+	}
+
+	for _, update := range [][]TaskItem{
+		{{ID: "4"}},
+		{{ID: "1", Status: "blocked"}},
+		{{ID: " ", Title: "No ID"}},
+		{{ID: "4", Title: strings.Repeat("x", maxTaskTitleChars+1)}},
+	} {
+		if err := list.Update(update, nil); err == nil {
+			t.Fatalf("expected %+v to be refused", update)
+		}
+	}
+	if list.Revision() != 2 {
+		t.Fatalf("expected refused updates to change nothing, got revision %d", list.Revision())
+	}
+
+	if err := list.Update(nil, []string{"3"}); err != nil {
+		t.Fatal(err)
+	}
+	if open := list.Open(); len(open) != 1 || open[0].ID != "2" {
+		t.Fatalf("expected task 2 alone open, got %+v", open)
+	}
+	if checklist := list.Checklist(); checklist != "[x] 1: Add the option\n[~] 2: Update the tests (in progress)" {
+		t.Fatalf("unexpected checklist %q", checklist)
+	}
+}
+
+func TestTaskListTools(t *testing.T) {
+	list := NewTaskList(nil)
+	ctx := WithTaskList(context.Background(), list)
+	update := NewUpdateTasksTool()
+
+	result, err := update.Execute(ctx, json.RawMessage(`{"tasks": [{"id": "1", "title": "Rename the handler"}, {"id": "2", "title": "Fix the callers", "status": "in_progress"}]}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected the tasks to be added, got %v %+v", err, result)
+	}
+	if data := result.Data.(map[string]interface{}); data["done"] != 0 || data["total"] != 2 {
+		t.Fatalf("expected the progress in the result, got %+v", data)
+	}
+
+	result, err = update.Execute(ctx, json.RawMessage(`{"tasks": [{"id": "9", "status": "done"}]}`))
+	if err != nil || result.Success || result.StandardizedError == nil {
+		t.Fatalf("expected a standardized error for a new task without a title, got %v %+v", err, result)
+	}
+	result, err = update.Execute(ctx, json.RawMessage(`{}`))
+	if err != nil || result.Success {
+		t.Fatalf("expected an error for an empty update, got %v %+v", err, result)
+	}
+
+	result, err = NewListTasksTool().Execute(ctx, json.RawMessage(`{}`))
+	if err != nil || !result.Success || len(result.Data.(map[string]interface{})["tasks"].([]TaskItem)) != 2 {
+		t.Fatalf("expected the two tasks, got %v %+v", err, result)
+	}
+
+	result, err = update.Execute(context.Background(), json.RawMessage(`{"tasks": [{"id": "1", "title": "x"}]}`))
+	if err != nil || result.Success {
+		t.Fatalf("expected an error without a task list, got %v %+v", err, result)
+	}
+}
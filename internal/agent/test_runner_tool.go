@@ -27,7 +27,7 @@ func (t *TestRunnerTool) Name() string {
 }
 
 func (t *TestRunnerTool) Description() string {
-	return "Runs tests in the specified directory or package with structured output parsing"
+	return "Runs tests in the specified directory or package with structured output parsing. A long raw_output holds the first page of the log; pass raw_output_id and next_page_token to read_tool_output as output_id and page_token to read the next page."
 }
 
 func (t *TestRunnerTool) Parameters() json.RawMessage {
@@ -83,8 +83,15 @@ type TestSummary struct {
 	Coverage     string       `json:"coverage,omitempty"`
 	Results      []TestResult `json:"results"`
 	RawOutput    string       `json:"raw_output"`
+	// RawOutputID and NextPageToken continue a raw output longer than a page
+	// with read_tool_output
+	RawOutputID   string `json:"raw_output_id,omitempty"`
+	NextPageToken string `json:"next_page_token,omitempty"`
 }
 
+// testLogPageSize is the longest raw output run_tests returns in one page
+const testLogPageSize = 8000
+
 func (t *TestRunnerTool) Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error) {
 	var p TestRunnerParams
 	if err := json.Unmarshal(params, &p); err != nil {
@@ -127,9 +134,14 @@ func (t *TestRunnerTool) Execute(ctx context.Context, params json.RawMessage) (*
 	// Execute test command
 	cmd := exec.CommandContext(testCtx, "go", args...)
 	cmd.Dir = t.workspaceRoot
+	stopGracefully(cmd)
 
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
+	ReportProgress(ctx, -1, "Running go "+strings.Join(args, " "), 0, 0)
+	outputWriter := newProgressLineWriter(ctx)
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
+	err := cmd.Run()
+	outputStr := string(outputWriter.Bytes())
 
 	// Parse test results
 	summary := t.parseTestOutput(outputStr)
@@ -143,6 +155,7 @@ func (t *TestRunnerTool) Execute(ctx context.Context, params json.RawMessage) (*
 			summary.RawOutput += "\n\nTest execution timed out"
 		}
 	}
+	t.paginateRawOutput(ctx, &summary)
 
 	return &ToolResult{
 		Success: success,
@@ -159,6 +172,25 @@ func (t *TestRunnerTool) Execute(ctx context.Context, params json.RawMessage) (*
 	}, nil
 }
 
+// paginateRawOutput cuts a raw output longer than a page to its first page,
+// saving the whole log for read_tool_output to page through
+func (t *TestRunnerTool) paginateRawOutput(ctx context.Context, summary *TestSummary) {
+	if len(summary.RawOutput) <= testLogPageSize {
+		return
+	}
+	id, err := SaveToolOutput(toolOutputDir(ctx, t.workspaceRoot), summary.RawOutput)
+	if err != nil {
+		return // The result guard of the runner truncates it instead
+	}
+	end := testLogPageSize
+	if i := strings.LastIndexByte(summary.RawOutput[:end], '\n'); i > end/2 {
+		end = i + 1
+	}
+	summary.RawOutput = summary.RawOutput[:end]
+	summary.RawOutputID = id
+	summary.NextPageToken = encodePageToken(ReadToolOutputParams{OutputID: id}.pageQuery(), end)
+}
+
 func (t *TestRunnerTool) parseTestOutput(output string) TestSummary {
 	summary := TestSummary{
 		RawOutput: output,
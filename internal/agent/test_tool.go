@@ -59,9 +59,9 @@ func (t *TestTool) Parameters() json.RawMessage {
 	return json.RawMessage(schemaBytes)
 }
 
-// Execute runs the tests without progress reporting (fallback)
+// Execute runs the tests, reporting progress to the reporter carried by ctx if any
 func (t *TestTool) Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error) {
-	return t.ExecuteWithProgress(ctx, params, nil)
+	return t.ExecuteWithProgress(ctx, params, ProgressCallbackFromContext(ctx))
 }
 
 // ExecuteWithProgress runs tests with progress reporting
@@ -127,6 +127,7 @@ func (t *TestTool) ExecuteWithProgress(ctx context.Context, params json.RawMessa
 	// Execute the test command
 	cmd := exec.CommandContext(ctx, "go", args...)
 	cmd.Dir = t.workspaceRoot
+	stopGracefully(cmd)
 
 	output, err := cmd.CombinedOutput()
 	outputStr := string(output)
@@ -7,8 +7,14 @@ import (
 // ToolFactoryConfig holds configuration for all tools that need it
 type ToolFactoryConfig struct {
 	ListDirectory *ListDirToolConfig
+	Coverage      *CoverageToolConfig
+	Commit        *CommitConventions
+	ShellRun      *ShellRunToolConfig
+	Files         *FileToolConfig
+	Database      *DatabaseToolConfig // db_query and db_schema are only registered when a database is configured
+	Docker        *DockerToolConfig   // docker_ps, docker_logs and compose_config are only registered when enabled
+	Snippet       *SnippetToolConfig  // run_snippet is only registered in review and full registries, when enabled
 	// Future tool configs can be added here
-	// ShellRun      *ShellRunToolConfig
 	// Git           *GitToolConfig
 }
 
@@ -42,6 +48,30 @@ func (tf *ToolFactory) SetListDirectoryConfig(config ListDirToolConfig) {
 	tf.config.ListDirectory = &config
 }
 
+// SetDatabaseConfig configures the database the database tools inspect
+func (tf *ToolFactory) SetDatabaseConfig(config DatabaseToolConfig) {
+	if tf.config == nil {
+		tf.config = &ToolFactoryConfig{}
+	}
+	tf.config.Database = &config
+}
+
+// SetDockerConfig configures the docker tools
+func (tf *ToolFactory) SetDockerConfig(config DockerToolConfig) {
+	if tf.config == nil {
+		tf.config = &ToolFactoryConfig{}
+	}
+	tf.config.Docker = &config
+}
+
+// SetSnippetConfig configures run_snippet
+func (tf *ToolFactory) SetSnippetConfig(config SnippetToolConfig) {
+	if tf.config == nil {
+		tf.config = &ToolFactoryConfig{}
+	}
+	tf.config.Snippet = &config
+}
+
 // CreateRegistry creates a new registry with all available tools
 func (tf *ToolFactory) CreateRegistry() *Registry {
 	registry := NewRegistry()
@@ -59,10 +89,15 @@ func (tf *ToolFactory) CreatePlanningRegistry() *Registry {
 	// Planning tools - read-only operations
 	registry.Register(NewFileReadTool(tf.workspaceRoot))
 	registry.Register(NewCodeSearchTool(tf.workspaceRoot))
+	registry.Register(NewGrepTool(tf.workspaceRoot))
 	registry.Register(tf.createListDirTool())
 	registry.Register(NewGitTool(tf.workspaceRoot))
+	registry.Register(NewAPISpecTool(tf.workspaceRoot))
+	registry.Register(NewImpactTool(tf.workspaceRoot))
+	registry.Register(NewReadToolOutputTool(tf.workspaceRoot))
 	// Add clarification tool for planning when uncertainty arises
 	registry.Register(NewClarificationTool(tf.workspaceRoot))
+	registerOptionalTools(registry, tf.workspaceRoot, tf.config)
 
 	return registry
 }
@@ -73,13 +108,21 @@ func (tf *ToolFactory) CreateGenerationRegistry() *Registry {
 
 	// Generation tools - read and write operations
 	registry.Register(NewFileReadTool(tf.workspaceRoot))
-	registry.Register(NewFileWriteTool(tf.workspaceRoot))
+	registry.Register(tf.createFileWriteTool())
 	registry.Register(NewCodeSearchTool(tf.workspaceRoot))
+	registry.Register(NewGrepTool(tf.workspaceRoot))
 	registry.Register(tf.createListDirTool())
 	registry.Register(NewPatchApplyTool(tf.workspaceRoot))
+	registry.Register(NewSearchReplaceTool(tf.workspaceRoot))
+	registry.Register(NewModifyFileTool(tf.workspaceRoot))
+	registry.Register(NewAPISpecTool(tf.workspaceRoot))
+	registry.Register(NewImpactTool(tf.workspaceRoot))
+	registry.Register(NewReadToolOutputTool(tf.workspaceRoot))
 	registry.Register(NewGitTool(tf.workspaceRoot))
+	registry.Register(tf.createCoverageTool())
 	// Add clarification tool for generation when requirements are unclear
 	registry.Register(NewClarificationTool(tf.workspaceRoot))
+	registerOptionalTools(registry, tf.workspaceRoot, tf.config)
 
 	return registry
 }
@@ -90,19 +133,28 @@ func (tf *ToolFactory) CreateReviewRegistry() *Registry {
 
 	// Review tools - read, write, and test operations
 	registry.Register(NewFileReadTool(tf.workspaceRoot))
-	registry.Register(NewFileWriteTool(tf.workspaceRoot))
+	registry.Register(tf.createFileWriteTool())
 	registry.Register(NewCodeSearchTool(tf.workspaceRoot))
+	registry.Register(NewGrepTool(tf.workspaceRoot))
 	registry.Register(tf.createListDirTool())
 	registry.Register(NewPatchApplyTool(tf.workspaceRoot))
-	registry.Register(NewShellRunTool(tf.workspaceRoot))
+	registry.Register(NewSearchReplaceTool(tf.workspaceRoot))
+	registry.Register(NewModifyFileTool(tf.workspaceRoot))
+	registry.Register(NewAPISpecTool(tf.workspaceRoot))
+	registry.Register(NewImpactTool(tf.workspaceRoot))
+	registry.Register(NewReadToolOutputTool(tf.workspaceRoot))
+	registry.Register(tf.createShellRunTool())
 	registry.Register(NewGitTool(tf.workspaceRoot))
-	registry.Register(NewGitCommitTool(tf.workspaceRoot))
+	registry.Register(tf.createGitCommitTool())
 	registry.Register(NewTestRunnerTool(tf.workspaceRoot))
 	registry.Register(NewLintRunnerTool(tf.workspaceRoot))
 	registry.Register(NewParseTestResultsTool(tf.workspaceRoot))
 	registry.Register(NewParseLintResultsTool(tf.workspaceRoot))
+	registry.Register(tf.createCoverageTool())
 	// Add clarification tool for review when fixes are ambiguous
 	registry.Register(NewClarificationTool(tf.workspaceRoot))
+	registerOptionalTools(registry, tf.workspaceRoot, tf.config)
+	registerCommandTools(registry, tf.workspaceRoot, tf.config)
 
 	return registry
 }
@@ -118,19 +170,28 @@ func (tf *ToolFactory) CreateFullRegistry() *Registry {
 func (tf *ToolFactory) registerCoreTool(registry *Registry) {
 	tools := []Tool{
 		NewFileReadTool(tf.workspaceRoot),
-		NewFileWriteTool(tf.workspaceRoot),
+		tf.createFileWriteTool(),
 		NewCodeSearchTool(tf.workspaceRoot),
+		NewGrepTool(tf.workspaceRoot),
 		tf.createListDirTool(),
 		NewPatchApplyTool(tf.workspaceRoot),
-		NewShellRunTool(tf.workspaceRoot),
+		NewSearchReplaceTool(tf.workspaceRoot),
+		NewModifyFileTool(tf.workspaceRoot),
+		NewAPISpecTool(tf.workspaceRoot),
+		NewImpactTool(tf.workspaceRoot),
+		NewReadToolOutputTool(tf.workspaceRoot),
+		tf.createShellRunTool(),
 		NewGitTool(tf.workspaceRoot),
-		NewGitCommitTool(tf.workspaceRoot),
+		tf.createGitCommitTool(),
 		NewTestRunnerTool(tf.workspaceRoot),
 		NewLintRunnerTool(tf.workspaceRoot),
 		NewParseTestResultsTool(tf.workspaceRoot),
 		NewParseLintResultsTool(tf.workspaceRoot),
+		tf.createCoverageTool(),
 		NewClarificationTool(tf.workspaceRoot),
 	}
+	tools = append(tools, optionalTools(tf.workspaceRoot, tf.config)...)
+	tools = append(tools, commandTools(tf.workspaceRoot, tf.config)...)
 
 	for _, tool := range tools {
 		if err := registry.Register(tool); err != nil {
@@ -140,6 +201,45 @@ func (tf *ToolFactory) registerCoreTool(registry *Registry) {
 	}
 }
 
+// optionalTools returns the tools that are only available when configured:
+// the database tools when a database is set and the docker tools when enabled
+func optionalTools(workspaceRoot string, config *ToolFactoryConfig) []Tool {
+	if config == nil {
+		return nil
+	}
+	var tools []Tool
+	if config.Database != nil && config.Database.Enabled() {
+		tools = append(tools, NewDatabaseTools(*config.Database)...)
+	}
+	if config.Docker != nil && config.Docker.Enabled {
+		tools = append(tools, NewDockerTools(workspaceRoot, *config.Docker)...)
+	}
+	return tools
+}
+
+// registerOptionalTools registers the optional tools that are configured
+func registerOptionalTools(registry *Registry, workspaceRoot string, config *ToolFactoryConfig) {
+	for _, tool := range optionalTools(workspaceRoot, config) {
+		registry.Register(tool)
+	}
+}
+
+// commandTools returns the opt-in tools that run code, which are only given
+// to registries that already run commands: run_snippet when enabled
+func commandTools(workspaceRoot string, config *ToolFactoryConfig) []Tool {
+	if config == nil || config.Snippet == nil || !config.Snippet.Enabled {
+		return nil
+	}
+	return []Tool{NewRunSnippetTool(workspaceRoot)}
+}
+
+// registerCommandTools registers the opt-in tools that run code
+func registerCommandTools(registry *Registry, workspaceRoot string, config *ToolFactoryConfig) {
+	for _, tool := range commandTools(workspaceRoot, config) {
+		registry.Register(tool)
+	}
+}
+
 // createListDirTool creates the appropriate list directory tool based on configuration
 func (tf *ToolFactory) createListDirTool() Tool {
 	if tf.config != nil && tf.config.ListDirectory != nil {
@@ -148,14 +248,54 @@ func (tf *ToolFactory) createListDirTool() Tool {
 	return NewListDirTool(tf.workspaceRoot)
 }
 
+// createCoverageTool creates the coverage tool using the configured test command
+func (tf *ToolFactory) createCoverageTool() Tool {
+	if tf.config != nil && tf.config.Coverage != nil {
+		return NewCoverageToolWithConfig(tf.workspaceRoot, *tf.config.Coverage)
+	}
+	return NewCoverageTool(tf.workspaceRoot)
+}
+
+// createFileWriteTool creates the write tool with the configured limits
+func (tf *ToolFactory) createFileWriteTool() Tool {
+	if tf.config != nil && tf.config.Files != nil {
+		return NewFileWriteToolWithConfig(tf.workspaceRoot, *tf.config.Files)
+	}
+	return NewFileWriteTool(tf.workspaceRoot)
+}
+
+// createShellRunTool creates the shell tool with the configured environment
+// allow-list
+func (tf *ToolFactory) createShellRunTool() Tool {
+	if tf.config != nil && tf.config.ShellRun != nil {
+		return NewShellRunToolWithConfig(tf.workspaceRoot, *tf.config.ShellRun)
+	}
+	return NewShellRunTool(tf.workspaceRoot)
+}
+
+// createGitCommitTool creates the commit tool, following the configured
+// commit conventions when there are any
+func (tf *ToolFactory) createGitCommitTool() Tool {
+	if tf.config != nil && tf.config.Commit != nil {
+		return NewGitCommitToolWithConventions(tf.workspaceRoot, *tf.config.Commit)
+	}
+	return NewGitCommitTool(tf.workspaceRoot)
+}
+
 // GetAvailableToolNames returns the names of all available tools
 func (tf *ToolFactory) GetAvailableToolNames() []string {
 	return []string{
 		"read_file",
 		"write_file",
 		"codebase_search",
+		"grep_codebase",
 		"list_directory",
 		"apply_patch_to_file",
+		"search_replace",
+		"modify_file",
+		"get_api_spec",
+		"impact_of_change",
+		"read_tool_output",
 		"run_shell_command",
 		"git_info",
 		"git_commit",
@@ -163,6 +303,7 @@ func (tf *ToolFactory) GetAvailableToolNames() []string {
 		"run_linter",
 		"parse_test_results",
 		"parse_lint_results",
+		"run_tests_with_coverage",
 		"request_human_clarification",
 	}
 }
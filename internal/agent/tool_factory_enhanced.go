@@ -53,6 +53,30 @@ func NewEnhancedToolFactoryWithConfig(workspaceRoot string, config ToolFactoryCo
 	}
 }
 
+// SetDatabaseConfig configures the database the database tools inspect
+func (etf *EnhancedToolFactory) SetDatabaseConfig(config DatabaseToolConfig) {
+	if etf.config == nil {
+		etf.config = &ToolFactoryConfig{}
+	}
+	etf.config.Database = &config
+}
+
+// SetDockerConfig configures the docker tools
+func (etf *EnhancedToolFactory) SetDockerConfig(config DockerToolConfig) {
+	if etf.config == nil {
+		etf.config = &ToolFactoryConfig{}
+	}
+	etf.config.Docker = &config
+}
+
+// SetSnippetConfig configures run_snippet
+func (etf *EnhancedToolFactory) SetSnippetConfig(config SnippetToolConfig) {
+	if etf.config == nil {
+		etf.config = &ToolFactoryConfig{}
+	}
+	etf.config.Snippet = &config
+}
+
 // CreateGenerationRegistry creates a registry with tools suitable for code generation
 func (etf *EnhancedToolFactory) CreateGenerationRegistry() *Registry {
 	registry := NewRegistry()
@@ -61,10 +85,16 @@ func (etf *EnhancedToolFactory) CreateGenerationRegistry() *Registry {
 	registry.Register(NewFileReadToolWithFS(etf.workspaceRoot, etf.fileSystem))
 	registry.Register(NewFileWriteToolWithFS(etf.workspaceRoot, etf.fileSystem))
 	registry.Register(NewCodeSearchToolWithFS(etf.workspaceRoot, etf.fileSystem))
+	registry.Register(NewGrepTool(etf.workspaceRoot))
 	registry.Register(etf.createListDirTool())
 	registry.Register(NewPatchApplyToolWithFS(etf.workspaceRoot, etf.fileSystem))
+	registry.Register(NewSearchReplaceToolWithFS(etf.workspaceRoot, etf.fileSystem))
+	registry.Register(NewModifyFileTool(etf.workspaceRoot))
+	registry.Register(NewAPISpecTool(etf.workspaceRoot))
+	registry.Register(NewReadToolOutputTool(etf.workspaceRoot))
 	registry.Register(NewGitToolWithExecutor(etf.workspaceRoot, etf.cmdExecutor))
 	registry.Register(NewClarificationTool(etf.workspaceRoot))
+	registerOptionalTools(registry, etf.workspaceRoot, etf.config)
 
 	return registry
 }
@@ -77,8 +107,13 @@ func (etf *EnhancedToolFactory) CreateReviewRegistry() *Registry {
 	registry.Register(NewFileReadToolWithFS(etf.workspaceRoot, etf.fileSystem))
 	registry.Register(NewFileWriteToolWithFS(etf.workspaceRoot, etf.fileSystem))
 	registry.Register(NewCodeSearchToolWithFS(etf.workspaceRoot, etf.fileSystem))
+	registry.Register(NewGrepTool(etf.workspaceRoot))
 	registry.Register(etf.createListDirTool())
 	registry.Register(NewPatchApplyToolWithFS(etf.workspaceRoot, etf.fileSystem))
+	registry.Register(NewSearchReplaceToolWithFS(etf.workspaceRoot, etf.fileSystem))
+	registry.Register(NewModifyFileTool(etf.workspaceRoot))
+	registry.Register(NewAPISpecTool(etf.workspaceRoot))
+	registry.Register(NewReadToolOutputTool(etf.workspaceRoot))
 	registry.Register(NewShellRunToolWithExecutor(etf.workspaceRoot, etf.cmdExecutor))
 	registry.Register(NewGitToolWithExecutor(etf.workspaceRoot, etf.cmdExecutor))
 	registry.Register(NewGitCommitToolWithExecutor(etf.workspaceRoot, etf.cmdExecutor))
@@ -86,7 +121,10 @@ func (etf *EnhancedToolFactory) CreateReviewRegistry() *Registry {
 	registry.Register(NewLintRunnerToolWithExecutor(etf.workspaceRoot, etf.cmdExecutor))
 	registry.Register(NewParseTestResultsToolWithFS(etf.workspaceRoot, etf.fileSystem))
 	registry.Register(NewParseLintResultsToolWithFS(etf.workspaceRoot, etf.fileSystem))
+	registry.Register(etf.createCoverageTool())
 	registry.Register(NewClarificationTool(etf.workspaceRoot))
+	registerOptionalTools(registry, etf.workspaceRoot, etf.config)
+	registerCommandTools(registry, etf.workspaceRoot, etf.config)
 
 	return registry
 }
@@ -98,9 +136,13 @@ func (etf *EnhancedToolFactory) CreatePlanningRegistry() *Registry {
 	// Planning tools - read-only operations
 	registry.Register(NewFileReadToolWithFS(etf.workspaceRoot, etf.fileSystem))
 	registry.Register(NewCodeSearchToolWithFS(etf.workspaceRoot, etf.fileSystem))
+	registry.Register(NewGrepTool(etf.workspaceRoot))
 	registry.Register(etf.createListDirTool())
 	registry.Register(NewGitToolWithExecutor(etf.workspaceRoot, etf.cmdExecutor))
+	registry.Register(NewAPISpecTool(etf.workspaceRoot))
+	registry.Register(NewReadToolOutputTool(etf.workspaceRoot))
 	registry.Register(NewClarificationTool(etf.workspaceRoot))
+	registerOptionalTools(registry, etf.workspaceRoot, etf.config)
 
 	return registry
 }
@@ -113,6 +155,14 @@ func (etf *EnhancedToolFactory) createListDirTool() Tool {
 	return NewListDirToolWithFS(etf.workspaceRoot, etf.fileSystem)
 }
 
+// createCoverageTool creates the coverage tool using the configured test command
+func (etf *EnhancedToolFactory) createCoverageTool() Tool {
+	if etf.config != nil && etf.config.Coverage != nil {
+		return NewCoverageToolWithConfig(etf.workspaceRoot, *etf.config.Coverage)
+	}
+	return NewCoverageTool(etf.workspaceRoot)
+}
+
 // Placeholder constructors for enhanced tools (these would need to be implemented)
 // For now, we'll fallback to regular constructors and gradually enhance each tool
 
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"sort"
+	"time"
+)
+
+// ToolStats counts the calls made to a tool through a registry
+type ToolStats struct {
+	Calls       int                   // Calls executed
+	Errors      int                   // Calls that failed or returned an error result
+	Duration    time.Duration         // Time spent in the calls
+	MaxDuration time.Duration         // Longest call
+	ErrorCodes  map[ToolErrorCode]int // Failed calls by error code
+}
+
+// ErrorRate returns the share of calls that failed, from 0 to 1
+func (s ToolStats) ErrorRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Calls)
+}
+
+// AverageDuration returns the mean time spent in a call
+func (s ToolStats) AverageDuration() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Duration / time.Duration(s.Calls)
+}
+
+// TopErrorCodes returns the error codes of the failed calls, most frequent
+// first
+func (s ToolStats) TopErrorCodes() []ToolErrorCode {
+	codes := make([]ToolErrorCode, 0, len(s.ErrorCodes))
+	for code := range s.ErrorCodes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if s.ErrorCodes[codes[i]] != s.ErrorCodes[codes[j]] {
+			return s.ErrorCodes[codes[i]] > s.ErrorCodes[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	return codes
+}
+
+// ErrorCodeOf returns the error code of a tool call that returned result and
+// err, or an empty code when the call succeeded. Failures without a code are
+// ErrorCodeUnclassified.
+func ErrorCodeOf(result *ToolResult, err error) ToolErrorCode {
+	switch {
+	case err != nil:
+		return AsStandardizedError(err, ErrorCodeInternalError).Code
+	case result == nil:
+		return ErrorCodeInternalError
+	case result.Success:
+		return ""
+	case result.StandardizedError != nil && result.StandardizedError.Code != "":
+		return result.StandardizedError.Code
+	}
+	return ErrorCodeUnclassified
+}
+
+// RecordCall adds a finished call of the tool called name to the statistics
+// of the registry
+func (r *Registry) RecordCall(name string, duration time.Duration, result *ToolResult, err error) {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	if r.stats == nil {
+		r.stats = make(map[string]*ToolStats)
+	}
+	stats, ok := r.stats[name]
+	if !ok {
+		stats = &ToolStats{ErrorCodes: make(map[ToolErrorCode]int)}
+		r.stats[name] = stats
+	}
+	stats.Calls++
+	stats.Duration += duration
+	if duration > stats.MaxDuration {
+		stats.MaxDuration = duration
+	}
+	if code := ErrorCodeOf(result, err); code != "" {
+		stats.Errors++
+		stats.ErrorCodes[code]++
+	}
+}
+
+// Stats returns the call statistics of each tool called so far, by name
+func (r *Registry) Stats() map[string]ToolStats {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	stats := make(map[string]ToolStats, len(r.stats))
+	for name, s := range r.stats {
+		copied := *s
+		copied.ErrorCodes = make(map[ToolErrorCode]int, len(s.ErrorCodes))
+		for code, count := range s.ErrorCodes {
+			copied.ErrorCodes[code] = count
+		}
+		stats[name] = copied
+	}
+	return stats
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryStats(t *testing.T) {
+	registry := NewRegistry()
+	registry.RecordCall("read_file", 100*time.Millisecond, NewSuccessResult("ok"), nil)
+	registry.RecordCall("read_file", 300*time.Millisecond, NewErrorResult(NewFileNotFoundError("a.go")), nil)
+	registry.RecordCall("read_file", 200*time.Millisecond, NewErrorResult(NewFileNotFoundError("b.go")), nil)
+	registry.RecordCall("read_file", 0, NewSimpleErrorResult("boom"), nil)
+	registry.RecordCall("run_tests", time.Second, nil, errors.New("tool execution error"))
+
+	stats := registry.Stats()
+	read := stats["read_file"]
+	if read.Calls != 4 || read.Errors != 3 {
+		t.Errorf("expected 4 calls and 3 errors, got %+v", read)
+	}
+	if read.Duration != 600*time.Millisecond || read.MaxDuration != 300*time.Millisecond || read.AverageDuration() != 150*time.Millisecond {
+		t.Errorf("unexpected durations: %+v", read)
+	}
+	if read.ErrorRate() != 0.75 {
+		t.Errorf("expected an error rate of 0.75, got %v", read.ErrorRate())
+	}
+	if codes := read.TopErrorCodes(); !reflect.DeepEqual(codes, []ToolErrorCode{ErrorCodeFileNotFound, ErrorCodeUnclassified}) {
+		t.Errorf("unexpected error codes: %v", codes)
+	}
+	if stats["run_tests"].ErrorCodes[ErrorCodeInternalError] != 1 {
+		t.Errorf("expected execution errors to be internal errors, got %v", stats["run_tests"].ErrorCodes)
+	}
+
+	stats["read_file"].ErrorCodes[ErrorCodeTimeout] = 5
+	if _, ok := registry.Stats()["read_file"].ErrorCodes[ErrorCodeTimeout]; ok {
+		t.Error("Stats must return a copy")
+	}
+	if (ToolStats{}).ErrorRate() != 0 || (ToolStats{}).AverageDuration() != 0 {
+		t.Error("expected zero rates without calls")
+	}
+}
+
+func TestErrorCodeOf(t *testing.T) {
+	wrapped := errors.Join(errors.New("tool execution error"), NewStandardizedError(ErrorCodeInvalidParameters, "bad", ""))
+	tests := []struct {
+		name   string
+		result *ToolResult
+		err    error
+		want   ToolErrorCode
+	}{
+		{"success", NewSuccessResult(nil), nil, ""},
+		{"coded result", NewErrorResult(NewFileNotFoundError("a.go")), nil, ErrorCodeFileNotFound},
+		{"legacy result", NewSimpleErrorResult("boom"), nil, ErrorCodeUnclassified},
+		{"no result", nil, nil, ErrorCodeInternalError},
+		{"wrapped coded error", nil, wrapped, ErrorCodeInvalidParameters},
+	}
+	for _, tt := range tests {
+		if got := ErrorCodeOf(tt.result, tt.err); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/statedir"
+)
+
+// ReadToolOutputName is the name of the tool reading the full output of a
+// tool whose result was truncated
+const ReadToolOutputName = "read_tool_output"
+
+// toolOutputsDir is the directory of the cache holding the full outputs of
+// truncated tool results
+const toolOutputsDir = "tool_outputs"
+
+// maxToolOutputRead is the most read_tool_output returns per call
+const maxToolOutputRead = 20000
+
+// maxToolOutputMatches is the most lines a pattern search returns
+const maxToolOutputMatches = 200
+
+// OutputLimits bound the size of the tool results added to the conversation.
+// A longer result keeps its first HeadChars and last TailChars; the middle is
+// summarized and the full result saved for read_tool_output.
+type OutputLimits struct {
+	MaxChars  int  // Longest result kept whole; 0 disables the limit
+	HeadChars int  // Start of a truncated result that is kept
+	TailChars int  // End of a truncated result that is kept
+	Summarize bool // Summarize the omitted middle with the model
+}
+
+// DefaultOutputLimits returns the limits used when none are configured
+func DefaultOutputLimits() OutputLimits {
+	return OutputLimits{MaxChars: 40000, HeadChars: 12000, TailChars: 12000, Summarize: true}
+}
+
+// ToolOutputDir returns the directory the full outputs of truncated tool
+// results of a workspace are saved in. It is in the cache, so 'CGE state gc'
+// prunes it.
+func ToolOutputDir(workspaceRoot string) string {
+	return statedir.Path(workspaceRoot, statedir.Cache, toolOutputsDir)
+}
+
+type toolOutputDirKey struct{}
+
+// WithToolOutputDir returns a context whose tool calls save and read the full
+// outputs of truncated results in dir. The runner sets the directory it saves
+// in itself, so read_tool_output finds them however its tools are rooted.
+func WithToolOutputDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, toolOutputDirKey{}, dir)
+}
+
+// toolOutputDir returns the output directory carried by ctx, or the one of
+// workspaceRoot when there is none
+func toolOutputDir(ctx context.Context, workspaceRoot string) string {
+	if dir, ok := ctx.Value(toolOutputDirKey{}).(string); ok && dir != "" {
+		return dir
+	}
+	return ToolOutputDir(workspaceRoot)
+}
+
+// SaveToolOutput saves the full output of a tool result to dir and returns
+// the ID read_tool_output reads it by. Identical outputs share an ID.
+func SaveToolOutput(dir, content string) (string, error) {
+	sum := sha256.Sum256([]byte(content))
+	id := "out_" + hex.EncodeToString(sum[:8])
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create tool output directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, id+".txt"), []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("failed to save tool output: %w", err)
+	}
+	return id, nil
+}
+
+// SplitOutput splits content that is over limits into the head and tail that
+// are kept and the middle that is omitted, cutting at line breaks when one is
+// near
+func SplitOutput(content string, limits OutputLimits) (head, middle, tail string) {
+	if limits.MaxChars <= 0 || len(content) <= limits.MaxChars || limits.HeadChars+limits.TailChars >= len(content) {
+		return content, "", ""
+	}
+	headEnd := limits.HeadChars
+	if i := strings.LastIndexByte(content[:headEnd], '\n'); i > headEnd/2 {
+		headEnd = i + 1
+	}
+	tailStart := len(content) - limits.TailChars
+	if i := strings.IndexByte(content[tailStart:], '\n'); i >= 0 && i < limits.TailChars/2 {
+		tailStart += i + 1
+	}
+	return content[:headEnd], content[headEnd:tailStart], content[tailStart:]
+}
+
+// ReadToolOutputParams are the parameters of read_tool_output
+type ReadToolOutputParams struct {
+	OutputID  string `json:"output_id" description:"ID of the saved output, given in the truncated tool result" pattern:"^out_[0-9a-f]+$" jsonschema:"required"`
+	Offset    int    `json:"offset" description:"Character offset to read from" jsonschema:"minimum=0"`
+	Limit     int    `json:"limit" description:"Characters to read" jsonschema:"default=8000,minimum=1,maximum=20000"`
+	Pattern   string `json:"pattern" description:"Regular expression; when set, returns the matching lines with their line numbers instead of a range"`
+	PageToken string `json:"page_token" description:"next_page_token of the previous result, to read on from where it stopped"`
+}
+
+// pageQuery returns the fingerprint continuation tokens of a read of the
+// saved output are checked against: the output and the pattern searched
+func (p ReadToolOutputParams) pageQuery() string {
+	return pageQuery(ReadToolOutputName, ReadToolOutputParams{OutputID: p.OutputID, Pattern: p.Pattern})
+}
+
+// NewReadToolOutputTool creates the read_tool_output tool, which reads the
+// full output of a tool result that was truncated to fit the conversation
+func NewReadToolOutputTool(workspaceRoot string) Tool {
+	return NewTypedTool(ReadToolOutputName, `Reads more of a tool result that was too large for the conversation and was truncated. The truncated result gives the ID of the saved full output and its size; read a range of it by character offset, or search it with a regular expression to find the relevant lines, e.g. the failing tests of a long test log. Pass next_page_token as page_token to read on.
+
+USAGE EXAMPLES:
+- read_tool_output({"output_id": "out_3f2a9c1e5b7d4a60", "offset": 12000, "limit": 8000})
+- read_tool_output({"output_id": "out_3f2a9c1e5b7d4a60", "pattern": "FAIL|panic"})
+- read_tool_output({"output_id": "out_3f2a9c1e5b7d4a60", "page_token": "<next_page_token>"})`,
+		func(ctx context.Context, params ReadToolOutputParams) (interface{}, error) {
+			dir := toolOutputDir(ctx, workspaceRoot)
+			data, err := os.ReadFile(filepath.Join(dir, filepath.Base(params.OutputID)+".txt")) // #nosec G304 - the ID is a base name in the output directory
+			if os.IsNotExist(err) {
+				return nil, NewStandardizedError(ErrorCodeFileNotFound, fmt.Sprintf("no saved output %s", params.OutputID), "Saved outputs are pruned with the cache; run the tool again")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read saved output: %w", err)
+			}
+			content := string(data)
+			start, err := decodePageToken(params.PageToken, params.pageQuery())
+			if err != nil {
+				return nil, err
+			}
+			if params.Pattern != "" {
+				return searchToolOutput(content, params, start)
+			}
+			if params.PageToken != "" {
+				params.Offset = start
+			}
+
+			if params.Limit == 0 {
+				params.Limit = 8000
+			}
+			params.Limit = min(params.Limit, maxToolOutputRead)
+			if params.Offset > len(content) {
+				return nil, NewStandardizedError(ErrorCodeInvalidParameters, fmt.Sprintf("offset %d is past the end of the output (%d characters)", params.Offset, len(content)), "Use an offset below the total size")
+			}
+			end := min(params.Offset+params.Limit, len(content))
+			result := map[string]interface{}{
+				"output_id": params.OutputID,
+				"offset":    params.Offset,
+				"content":   content[params.Offset:end],
+				"total":     len(content),
+			}
+			if end < len(content) {
+				result["next_offset"] = end
+				result["next_page_token"] = encodePageToken(params.pageQuery(), end)
+			}
+			return result, nil
+		})
+}
+
+// searchToolOutput returns the lines of content matching the pattern of
+// params, from the line with index start on
+func searchToolOutput(content string, params ReadToolOutputParams, start int) (interface{}, error) {
+	pattern, err := regexp.Compile(params.Pattern)
+	if err != nil {
+		return nil, NewStandardizedError(ErrorCodeInvalidParameters, fmt.Sprintf("invalid pattern: %v", err), "Use a valid Go regular expression")
+	}
+	var matches []string
+	next := -1
+	size := 0
+	lines := strings.Split(content, "\n")
+	for i := min(start, len(lines)); i < len(lines); i++ {
+		line := lines[i]
+		if !pattern.MatchString(line) {
+			continue
+		}
+		if len(matches) == maxToolOutputMatches || len(matches) > 0 && size+len(line) > maxToolOutputRead {
+			next = i
+			break
+		}
+		matches = append(matches, fmt.Sprintf("%d: %s", i+1, line))
+		size += len(line)
+	}
+	result := map[string]interface{}{
+		"output_id": params.OutputID,
+		"matches":   matches,
+		"truncated": next >= 0,
+		"total":     len(content),
+	}
+	if next >= 0 {
+		result["next_page_token"] = encodePageToken(params.pageQuery(), next)
+	}
+	return result, nil
+}
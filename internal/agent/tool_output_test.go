@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitOutput(t *testing.T) {
+	var b strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&b, "line %03d\n", i)
+	}
+	content := b.String() // 900 characters, 9 per line
+	limits := OutputLimits{MaxChars: 500, HeadChars: 100, TailChars: 100}
+
+	head, middle, tail := SplitOutput(content, limits)
+	if head+middle+tail != content {
+		t.Fatal("the parts do not make up the content")
+	}
+	if head != content[:99] || !strings.HasSuffix(head, "line 011\n") {
+		t.Errorf("head = %q, want the first 11 whole lines", head)
+	}
+	if !strings.HasPrefix(tail, "line 090\n") || !strings.HasSuffix(tail, "line 100\n") {
+		t.Errorf("tail = %q, want the last lines from a line start", tail)
+	}
+
+	if head, middle, _ := SplitOutput(content, OutputLimits{MaxChars: 1000, HeadChars: 100, TailChars: 100}); head != content || middle != "" {
+		t.Error("content within MaxChars should be kept whole")
+	}
+	if _, middle, _ := SplitOutput(content, OutputLimits{}); middle != "" {
+		t.Error("a zero MaxChars should disable the limit")
+	}
+}
+
+func TestReadToolOutputTool(t *testing.T) {
+	workspace := t.TempDir()
+	content := strings.Repeat("ok\n", 5000) + "FAIL: TestLogin\n" + strings.Repeat("ok\n", 5000)
+	id, err := SaveToolOutput(ToolOutputDir(workspace), content)
+	if err != nil {
+		t.Fatalf("SaveToolOutput failed: %v", err)
+	}
+	if again, _ := SaveToolOutput(ToolOutputDir(workspace), content); again != id {
+		t.Errorf("identical outputs got IDs %s and %s", id, again)
+	}
+
+	tool := NewReadToolOutputTool(workspace)
+	read := func(params string) *ToolResult {
+		t.Helper()
+		result, err := tool.Execute(context.Background(), json.RawMessage(params))
+		if err != nil {
+			t.Fatalf("Execute(%s) failed: %v", params, err)
+		}
+		return result
+	}
+
+	result := read(fmt.Sprintf(`{"output_id": %q, "offset": 15000, "limit": 30}`, id))
+	if !result.Success {
+		t.Fatalf("range read failed: %s", result.Error)
+	}
+	data := result.Data.(map[string]interface{})
+	if data["content"] != "FAIL: TestLogin\nok\nok\nok\nok\nok" || data["next_offset"] != 15030 || data["total"] != len(content) {
+		t.Errorf("range read = %v", data)
+	}
+
+	result = read(fmt.Sprintf(`{"output_id": %q, "pattern": "FAIL"}`, id))
+	if matches := result.Data.(map[string]interface{})["matches"].([]string); len(matches) != 1 || matches[0] != "5001: FAIL: TestLogin" {
+		t.Errorf("pattern matches = %v", matches)
+	}
+
+	if result = read(`{"output_id": "out_0123456789abcdef"}`); result.Success {
+		t.Error("reading an unknown output should fail")
+	}
+	if _, err := tool.Execute(context.Background(), json.RawMessage(`{"output_id": "../../secrets"}`)); err == nil {
+		t.Error("an ID that is not an output ID should be rejected")
+	}
+}
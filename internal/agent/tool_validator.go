@@ -8,6 +8,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/castrovroberto/CGE/internal/security"
 )
 
 // ToolValidator provides common validation functions for tools
@@ -33,17 +35,13 @@ func (v *ToolValidator) ValidateFilePath(filePath string) error {
 		return NewPathOutsideWorkspaceError(filePath)
 	}
 
-	// Check for absolute paths
-	if filepath.IsAbs(filePath) {
+	// Check for absolute and drive-relative paths
+	if filepath.IsAbs(filePath) || security.IsDriveRelative(filePath) {
 		return NewPathOutsideWorkspaceError(filePath)
 	}
 
-	// Resolve full path
-	fullPath := filepath.Join(v.workspaceRoot, filePath)
-	cleanPath := filepath.Clean(fullPath)
-
 	// Ensure path is within workspace
-	if !strings.HasPrefix(cleanPath, filepath.Clean(v.workspaceRoot)) {
+	if !security.IsWithin(v.workspaceRoot, filepath.Join(v.workspaceRoot, filePath)) {
 		return NewPathOutsideWorkspaceError(filePath)
 	}
 
@@ -81,17 +79,13 @@ func (v *ToolValidator) ValidateDirectoryPath(dirPath string) error {
 		return NewPathOutsideWorkspaceError(dirPath)
 	}
 
-	// Check for absolute paths
-	if filepath.IsAbs(dirPath) {
+	// Check for absolute and drive-relative paths
+	if filepath.IsAbs(dirPath) || security.IsDriveRelative(dirPath) {
 		return NewPathOutsideWorkspaceError(dirPath)
 	}
 
-	// Resolve full path
-	fullPath := filepath.Join(v.workspaceRoot, dirPath)
-	cleanPath := filepath.Clean(fullPath)
-
 	// Ensure path is within workspace
-	if !strings.HasPrefix(cleanPath, filepath.Clean(v.workspaceRoot)) {
+	if !security.IsWithin(v.workspaceRoot, filepath.Join(v.workspaceRoot, dirPath)) {
 		return NewPathOutsideWorkspaceError(dirPath)
 	}
 
@@ -498,10 +492,7 @@ func (v *ToolValidator) SanitizeFilePath(filePath string) string {
 
 // IsWithinWorkspace checks if a path is within the workspace
 func (v *ToolValidator) IsWithinWorkspace(path string) bool {
-	fullPath := filepath.Join(v.workspaceRoot, path)
-	cleanPath := filepath.Clean(fullPath)
-	cleanWorkspace := filepath.Clean(v.workspaceRoot)
-	return strings.HasPrefix(cleanPath, cleanWorkspace)
+	return security.IsWithin(v.workspaceRoot, filepath.Join(v.workspaceRoot, path))
 }
 
 // GetSafePath returns a safe absolute path within the workspace
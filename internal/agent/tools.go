@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 )
 
 // ToolResult represents the result of executing a tool
@@ -81,15 +84,27 @@ type Tool interface {
 	Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error)
 }
 
-// Registry maintains the set of available tools
+// LongRunningTool is implemented by tools whose calls may outlast the default
+// per-call timeout of the agent runner. ToolTimeout returns the timeout to use
+// instead; the call is still bounded by the timeout of the run itself.
+type LongRunningTool interface {
+	ToolTimeout() time.Duration
+}
+
+// Registry maintains the set of available tools and the statistics of the
+// calls made to them
 type Registry struct {
 	tools map[string]Tool
+
+	statsMu sync.Mutex
+	stats   map[string]*ToolStats
 }
 
 // NewRegistry creates a new tool registry
 func NewRegistry() *Registry {
 	return &Registry{
 		tools: make(map[string]Tool),
+		stats: make(map[string]*ToolStats),
 	}
 }
 
@@ -109,21 +124,25 @@ func (r *Registry) Get(name string) (Tool, bool) {
 	return tool, ok
 }
 
-// List returns all registered tools
+// List returns all registered tools, sorted by name. The definitions sent
+// to the model are in this order, so it must not change between requests for
+// providers to serve the prompt prefix from their cache.
 func (r *Registry) List() []Tool {
 	tools := make([]Tool, 0, len(r.tools))
 	for _, tool := range r.tools {
 		tools = append(tools, tool)
 	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name() < tools[j].Name() })
 	return tools
 }
 
-// GetToolNames returns the names of all registered tools
+// GetToolNames returns the names of all registered tools, sorted
 func (r *Registry) GetToolNames() []string {
 	names := make([]string, 0, len(r.tools))
 	for name := range r.tools {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
@@ -0,0 +1,22 @@
+package agent
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestRegistryListIsSorted(t *testing.T) {
+	registry := NewToolFactory(t.TempDir()).CreateFullRegistry()
+	for i := 0; i < 5; i++ {
+		var names []string
+		for _, tool := range registry.List() {
+			names = append(names, tool.Name())
+		}
+		if !sort.StringsAreSorted(names) {
+			t.Fatalf("expected the tools sorted by name, so their definitions are the same in every request, got %v", names)
+		}
+		if !sort.StringsAreSorted(registry.GetToolNames()) {
+			t.Fatalf("expected sorted tool names, got %v", registry.GetToolNames())
+		}
+	}
+}
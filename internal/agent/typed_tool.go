@@ -0,0 +1,312 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ToolFunc runs a typed tool with its decoded and validated parameters,
+// returning the data of a successful result. Returning a
+// *StandardizedToolError reports the call as failed to the agent; any other
+// error is an execution error. A *ToolResult is returned to the agent as is.
+type ToolFunc[P any] func(ctx context.Context, params P) (interface{}, error)
+
+// TypedTool is a Tool whose parameters are the Go struct P. Its JSON schema is
+// derived from the struct, so the two cannot drift apart, and arguments are
+// validated against it and decoded before the tool runs.
+//
+// Parameter names come from json tags. Schema details come from these tags:
+//
+//	description:"What the parameter is for"
+//	pattern:"^[a-z]+$"
+//	jsonschema:"required,enum=low|medium|high,default=medium"
+//
+// The jsonschema options are required, enum (values separated by |),
+// default, minimum, maximum, minLength, maxLength, minItems and maxItems.
+// Options prefixed with "items." apply to the items of an array, e.g.
+// "items.maxLength=200".
+type TypedTool[P any] struct {
+	name        string
+	description string
+	schema      json.RawMessage
+	run         ToolFunc[P]
+}
+
+// NewTypedTool creates a tool running run with parameters of type P. It panics
+// when P cannot be described by a JSON schema, as a tool's parameters are
+// fixed when it is written; SchemaOf reports the problem as an error instead.
+func NewTypedTool[P any](name, description string, run ToolFunc[P]) *TypedTool[P] {
+	schema, err := SchemaOf[P]()
+	if err != nil {
+		panic(fmt.Sprintf("tool %s: %v", name, err))
+	}
+	return &TypedTool[P]{
+		name:        name,
+		description: description,
+		schema:      schema,
+		run:         run,
+	}
+}
+
+// Name returns the tool name
+func (t *TypedTool[P]) Name() string {
+	return t.name
+}
+
+// Description returns the tool description
+func (t *TypedTool[P]) Description() string {
+	return t.description
+}
+
+// Parameters returns the schema derived from P
+func (t *TypedTool[P]) Parameters() json.RawMessage {
+	return t.schema
+}
+
+// Execute validates and decodes the arguments, then runs the tool. Invalid
+// arguments are returned as a *StandardizedToolError listing every offending
+// field.
+func (t *TypedTool[P]) Execute(ctx context.Context, params json.RawMessage) (*ToolResult, error) {
+	if invalid := ValidateArguments(params, t.schema); invalid != nil {
+		return nil, invalid
+	}
+	var decoded P
+	if err := json.Unmarshal(params, &decoded); err != nil {
+		return nil, NewStandardizedError(ErrorCodeInvalidParameters, fmt.Sprintf("Invalid arguments: %v", err), "Send arguments matching the tool's parameters")
+	}
+
+	data, err := t.run(ctx, decoded)
+	if err != nil {
+		var toolErr *StandardizedToolError
+		if errors.As(err, &toolErr) {
+			return NewErrorResult(toolErr), nil
+		}
+		return nil, err
+	}
+	if result, ok := data.(*ToolResult); ok {
+		return result, nil
+	}
+	return NewSuccessResult(data), nil
+}
+
+// SchemaOf derives the JSON schema of the parameters of a typed tool from the
+// struct P; see TypedTool for the tags it reads
+func SchemaOf[P any]() (json.RawMessage, error) {
+	paramsType := reflect.TypeOf((*P)(nil)).Elem()
+	for paramsType.Kind() == reflect.Pointer {
+		paramsType = paramsType.Elem()
+	}
+	if paramsType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("parameters must be a struct, got %s", paramsType)
+	}
+	schema, err := schemaForType(paramsType, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(schema)
+}
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// schemaForType describes values of type t. visiting holds the structs being
+// described, as recursive types have no finite schema.
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case rawMessageType:
+		return map[string]interface{}{}, nil
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json sends bytes as base64 strings
+			return map[string]interface{}{"type": "string"}, nil
+		}
+		items, err := schemaForType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map keys must be strings, got %s", t.Key())
+		}
+		values, err := schemaForType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("recursive type %s", t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           map[string]interface{}{},
+			"additionalProperties": false,
+		}
+		if err := addStructFields(schema, t, visiting); err != nil {
+			return nil, err
+		}
+		return schema, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// addStructFields adds the properties of the fields of struct t to schema.
+// Embedded structs without a json name add their fields, as encoding/json
+// decodes them.
+func addStructFields(schema map[string]interface{}, t reflect.Type, visiting map[reflect.Type]bool) error {
+	properties := schema["properties"].(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := addStructFields(schema, embedded, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := schemaForType(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if description := field.Tag.Get("description"); description != "" {
+			property["description"] = description
+		}
+		if pattern := field.Tag.Get("pattern"); pattern != "" {
+			property["pattern"] = pattern
+		}
+		required, err := applySchemaOptions(property, field.Tag.Get("jsonschema"))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		properties[name] = property
+		if required {
+			list, _ := schema["required"].([]string)
+			schema["required"] = append(list, name)
+		}
+	}
+	return nil
+}
+
+// applySchemaOptions adds the options of a jsonschema tag to the schema of a
+// property, reporting whether the property is required
+func applySchemaOptions(property map[string]interface{}, tag string) (required bool, err error) {
+	if tag == "" {
+		return false, nil
+	}
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		target := property
+		forItems := false
+		if rest, ok := strings.CutPrefix(key, "items."); ok {
+			items, isArray := property["items"].(map[string]interface{})
+			if !isArray {
+				return false, fmt.Errorf("option %s on a parameter that is not an array", key)
+			}
+			target, key, forItems = items, rest, true
+		}
+
+		switch key {
+		case "":
+		case "required":
+			if forItems {
+				return false, fmt.Errorf("items cannot be required")
+			}
+			required = true
+		case "enum":
+			var enum []interface{}
+			for _, v := range strings.Split(value, "|") {
+				parsed, err := parseSchemaValue(target, v)
+				if err != nil {
+					return false, fmt.Errorf("enum: %w", err)
+				}
+				enum = append(enum, parsed)
+			}
+			target["enum"] = enum
+		case "default":
+			parsed, err := parseSchemaValue(target, value)
+			if err != nil {
+				return false, fmt.Errorf("default: %w", err)
+			}
+			target["default"] = parsed
+		case "minimum", "maximum":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", key, err)
+			}
+			target[key] = n
+		case "minLength", "maxLength", "minItems", "maxItems":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", key, err)
+			}
+			target[key] = n
+		default:
+			return false, fmt.Errorf("unknown jsonschema option %q", key)
+		}
+	}
+	return required, nil
+}
+
+// parseSchemaValue parses a value of a tag as the type of the schema it
+// belongs to
+func parseSchemaValue(schema map[string]interface{}, value string) (interface{}, error) {
+	switch schema["type"] {
+	case "string":
+		return value, nil
+	case "integer":
+		return strconv.ParseInt(value, 10, 64)
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	case "boolean":
+		return strconv.ParseBool(value)
+	}
+	return nil, fmt.Errorf("values of type %v cannot be given in tags", schema["type"])
+}
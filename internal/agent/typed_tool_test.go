@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type typedToolOptions struct {
+	Force bool `json:"force" description:"Overwrite existing files"`
+}
+
+type typedToolParams struct {
+	typedToolOptions
+
+	Path    string            `json:"path" description:"File to change" pattern:"^[^/]" jsonschema:"required,minLength=1"`
+	Mode    string            `json:"mode,omitempty" jsonschema:"enum=overwrite|append,default=overwrite"`
+	Lines   []int             `json:"lines" jsonschema:"maxItems=3,items.minimum=1"`
+	Labels  map[string]string `json:"labels"`
+	Limit   *float64          `json:"limit" jsonschema:"minimum=0,maximum=1"`
+	Ignored string            `json:"-"`
+	hidden  string
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := SchemaOf[typedToolParams]()
+	if err != nil {
+		t.Fatalf("SchemaOf failed: %v", err)
+	}
+
+	want := `{
+		"type": "object",
+		"additionalProperties": false,
+		"required": ["path"],
+		"properties": {
+			"force": {"type": "boolean", "description": "Overwrite existing files"},
+			"path": {"type": "string", "description": "File to change", "pattern": "^[^/]", "minLength": 1},
+			"mode": {"type": "string", "enum": ["overwrite", "append"], "default": "overwrite"},
+			"lines": {"type": "array", "maxItems": 3, "items": {"type": "integer", "minimum": 1}},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"limit": {"type": "number", "minimum": 0, "maximum": 1}
+		}
+	}`
+	var got, expected interface{}
+	if err := json.Unmarshal(schema, &got); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected schema:\n%s", schema)
+	}
+}
+
+func TestSchemaOfRejectsUndescribableParams(t *testing.T) {
+	type recursive struct {
+		Children []recursive `json:"children"`
+	}
+	type badOption struct {
+		Name string `json:"name" jsonschema:"requird"`
+	}
+	type badEnum struct {
+		Count int `json:"count" jsonschema:"enum=one|two"`
+	}
+
+	if _, err := SchemaOf[string](); err == nil {
+		t.Error("expected parameters that are not a struct to be rejected")
+	}
+	if _, err := SchemaOf[recursive](); err == nil {
+		t.Error("expected recursive parameters to be rejected")
+	}
+	if _, err := SchemaOf[badOption](); err == nil || !strings.Contains(err.Error(), "requird") {
+		t.Errorf("expected the unknown option to be reported, got %v", err)
+	}
+	if _, err := SchemaOf[badEnum](); err == nil || !strings.Contains(err.Error(), "count") {
+		t.Errorf("expected the enum that is not integers to be reported, got %v", err)
+	}
+}
+
+func TestTypedToolExecute(t *testing.T) {
+	var received typedToolParams
+	tool := NewTypedTool("change_file", "Changes a file", func(ctx context.Context, params typedToolParams) (interface{}, error) {
+		received = params
+		switch params.Path {
+		case "missing.go":
+			return nil, NewFileNotFoundError(params.Path)
+		case "broken.go":
+			return nil, errors.New("disk failure")
+		}
+		return map[string]interface{}{"changed": params.Path}, nil
+	})
+
+	ctx := context.Background()
+	result, err := tool.Execute(ctx, json.RawMessage(`{"path": "main.go", "force": true, "lines": [1, 2]}`))
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %v %+v", err, result)
+	}
+	if received.Path != "main.go" || !received.Force || len(received.Lines) != 2 {
+		t.Errorf("parameters were not decoded: %+v", received)
+	}
+	if data := result.Data.(map[string]interface{}); data["changed"] != "main.go" {
+		t.Errorf("unexpected data: %v", data)
+	}
+
+	received = typedToolParams{}
+	_, err = tool.Execute(ctx, json.RawMessage(`{"mode": "prepend", "lines": [0]}`))
+	var invalid *StandardizedToolError
+	if !errors.As(err, &invalid) || invalid.Code != ErrorCodeInvalidParameters {
+		t.Fatalf("expected invalid parameters, got %v", err)
+	}
+	if fields := invalid.Details["fields"]; !reflect.DeepEqual(fields, []string{"path", "lines[0]", "mode"}) {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if received.Path != "" {
+		t.Error("the tool must not run with invalid parameters")
+	}
+
+	result, err = tool.Execute(ctx, json.RawMessage(`{"path": "missing.go"}`))
+	if err != nil || result.Success || result.StandardizedError.Code != ErrorCodeFileNotFound {
+		t.Errorf("expected a failed result, got %v %+v", err, result)
+	}
+
+	if _, err := tool.Execute(ctx, json.RawMessage(`{"path": "broken.go"}`)); err == nil || err.Error() != "disk failure" {
+		t.Errorf("expected an execution error, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/analyzer"
+	"github.com/castrovroberto/CGE/internal/textutils"
+)
+
+// maxScannedFileSize is the largest file the workspace search tools will read
+const maxScannedFileSize = 2 * 1024 * 1024
+
+// errStopWalk is returned by a walkTextFiles callback to end the walk early
+var errStopWalk = errors.New("stop walk")
+
+// walkTextFiles calls fn for every text file under dir (relative to root) whose
+// workspace-relative path matches one of include (all files when empty),
+// reading the workspace through fsys. Dependency and build directories, binary
+// files and very large files are skipped. Returning errStopWalk from fn ends
+// the walk without error.
+func walkTextFiles(fsys FileSystemService, root, dir string, include []string, fn func(relPath string, content []byte) error) error {
+	err := walkTextDir(fsys, root, filepath.Join(root, dir), include, fn)
+	if errors.Is(err, errStopWalk) {
+		return nil
+	}
+	return err
+}
+
+// walkTextDir visits the entries of dir in lexical order
+func walkTextDir(fsys FileSystemService, root, dir string, include []string, fn func(relPath string, content []byte) error) error {
+	entries, err := fsys.ListDir(dir)
+	if err != nil {
+		return nil // Unreadable directories are skipped
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, d := range entries {
+		path := filepath.Join(dir, d.Name())
+		if d.IsDir() {
+			if analyzer.IsSkippableDir(d.Name()) || d.Name() == ".cge" {
+				continue
+			}
+			if err := walkTextDir(fsys, root, path, include, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if !d.Type().IsRegular() {
+			continue
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		relPath = filepath.ToSlash(relPath)
+		if !matchesAnyGlob(include, relPath) {
+			continue
+		}
+
+		info, err := d.Info()
+		if err != nil || info.Size() > maxScannedFileSize {
+			continue
+		}
+
+		content, err := fsys.ReadFile(path)
+		if err != nil || textutils.IsBinary(content) {
+			continue
+		}
+
+		if err := fn(relPath, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchesAnyGlob reports whether relPath matches any of patterns; an empty list matches everything
+func matchesAnyGlob(patterns []string, relPath string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated relative path against a glob. Patterns
+// without a slash match the file name ("*.go"); others match the whole path
+// and may use "**" to span directories ("internal/**/*_test.go").
+func matchGlob(pattern, relPath string) bool {
+	pattern = filepath.ToSlash(strings.TrimPrefix(pattern, "./"))
+	if !strings.Contains(pattern, "/") {
+		matched, _ := filepath.Match(pattern, filepath.Base(relPath))
+		return matched
+	}
+	if !strings.Contains(pattern, "**") {
+		matched, _ := filepath.Match(pattern, relPath)
+		return matched
+	}
+
+	re, err := regexp.Compile(globToRegexp(pattern))
+	if err != nil {
+		return false
+	}
+	return re.MatchString(relPath)
+}
+
+// globToRegexp converts a glob with "**" support into an anchored regular expression
+func globToRegexp(pattern string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case ch == '*':
+			sb.WriteString("[^/]*")
+		case ch == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
@@ -0,0 +1,268 @@
+// Package apispec parses the OpenAPI specifications and protocol buffer
+// definitions of a workspace into one model of its API: endpoints, the types
+// they exchange and gRPC services. The model backs the get_api_spec tool and
+// is added to generation prompts when a task touches API code, so generated
+// handlers and clients follow the contracts instead of guessing them.
+package apispec
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Format is the kind of file a specification was read from
+type Format string
+
+const (
+	FormatOpenAPI Format = "openapi" // OpenAPI 3 or Swagger 2, YAML or JSON
+	FormatProto   Format = "proto"   // Protocol buffers
+)
+
+// maxSpecSize is the largest file parsed as a specification
+const maxSpecSize = 4 << 20
+
+// skippedDirs are never searched for specifications
+var skippedDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "dist": true, "build": true,
+	"target": true, ".cge": true, "testdata": true, "third_party": true,
+}
+
+// openAPIMarker finds the version key of an OpenAPI document near its start
+var openAPIMarker = regexp.MustCompile(`(?m)^\s*"?(openapi|swagger)"?\s*:`)
+
+// API is the API model of a workspace
+type API struct {
+	Specs     []Spec     `json:"specs"`
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+	Types     []Type     `json:"types,omitempty"`
+	Services  []Service  `json:"services,omitempty"`
+	Errors    []string   `json:"errors,omitempty"` // Specifications that could not be parsed
+}
+
+// Spec is a specification file
+type Spec struct {
+	File    string `json:"file"` // Slash-separated, relative to the workspace root
+	Format  Format `json:"format"`
+	Title   string `json:"title,omitempty"`
+	Version string `json:"version,omitempty"`
+	Package string `json:"package,omitempty"` // Proto package
+}
+
+// Endpoint is an HTTP operation of an OpenAPI specification
+type Endpoint struct {
+	File        string            `json:"file"`
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	OperationID string            `json:"operation_id,omitempty"`
+	Summary     string            `json:"summary,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Parameters  []Field           `json:"parameters,omitempty"`
+	RequestBody string            `json:"request_body,omitempty"` // Type of the body
+	Responses   map[string]string `json:"responses,omitempty"`    // Type or description by status code
+}
+
+// Field is a property, message field or parameter
+type Field struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	In          string `json:"in,omitempty"`     // Where a parameter goes: path, query, header or cookie
+	Number      int    `json:"number,omitempty"` // Proto field number
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Type is an OpenAPI schema or a proto message or enum
+type Type struct {
+	File        string   `json:"file"`
+	Name        string   `json:"name"` // Qualified with the proto package
+	Kind        string   `json:"kind"` // "object", "message" or "enum"
+	Description string   `json:"description,omitempty"`
+	Fields      []Field  `json:"fields,omitempty"`
+	Values      []string `json:"values,omitempty"` // Enum values
+}
+
+// Service is a gRPC service
+type Service struct {
+	File    string `json:"file"`
+	Name    string `json:"name"`
+	Methods []RPC  `json:"methods"`
+}
+
+// RPC is a method of a gRPC service
+type RPC struct {
+	Name            string `json:"name"`
+	Request         string `json:"request"`
+	Response        string `json:"response"`
+	ClientStreaming bool   `json:"client_streaming,omitempty"`
+	ServerStreaming bool   `json:"server_streaming,omitempty"`
+}
+
+// Empty reports whether the API has nothing in it
+func (a *API) Empty() bool {
+	return len(a.Endpoints) == 0 && len(a.Types) == 0 && len(a.Services) == 0
+}
+
+// Load finds the OpenAPI specifications and .proto files under root and
+// parses them. Files that fail to parse are listed in the API's errors
+// rather than failing the load.
+func Load(root string) (*API, error) {
+	api := &API{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable entries are skipped
+		}
+		if entry.IsDir() {
+			if path != root && (skippedDirs[entry.Name()] || strings.HasPrefix(entry.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		format, ok := specFormat(entry.Name())
+		if !ok {
+			return nil
+		}
+		if info, err := entry.Info(); err != nil || info.Size() > maxSpecSize {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+
+		var parsed *API
+		switch format {
+		case FormatProto:
+			parsed, err = ParseProto(rel, data)
+		case FormatOpenAPI:
+			if !openAPIMarker.Match(head(data)) {
+				return nil // Some other YAML or JSON file
+			}
+			parsed, err = ParseOpenAPI(rel, data)
+		}
+		if err != nil {
+			api.Errors = append(api.Errors, fmt.Sprintf("%s: %v", rel, err))
+			return nil
+		}
+		api.merge(parsed)
+		return nil
+	})
+	return api, err
+}
+
+// specFormat returns the format a file with name could be written in
+func specFormat(name string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".proto":
+		return FormatProto, true
+	case ".yaml", ".yml", ".json":
+		return FormatOpenAPI, true
+	}
+	return "", false
+}
+
+// head returns the start of a file, where an OpenAPI version key would be
+func head(data []byte) []byte {
+	if len(data) > 4096 {
+		data = data[:4096]
+	}
+	return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+}
+
+// merge adds the contents of other to a
+func (a *API) merge(other *API) {
+	a.Specs = append(a.Specs, other.Specs...)
+	a.Endpoints = append(a.Endpoints, other.Endpoints...)
+	a.Types = append(a.Types, other.Types...)
+	a.Services = append(a.Services, other.Services...)
+	a.Errors = append(a.Errors, other.Errors...)
+}
+
+// Format renders the API compactly for a prompt: one line per endpoint,
+// type and RPC
+func (a *API) Format() string {
+	var b strings.Builder
+	if len(a.Endpoints) > 0 {
+		b.WriteString("Endpoints:\n")
+		for _, e := range a.Endpoints {
+			fmt.Fprintf(&b, "- %s %s", e.Method, e.Path)
+			if e.OperationID != "" {
+				fmt.Fprintf(&b, " (%s)", e.OperationID)
+			}
+			if e.Summary != "" {
+				fmt.Fprintf(&b, ": %s", e.Summary)
+			}
+			fmt.Fprintf(&b, " [%s]\n", e.File)
+			if len(e.Parameters) > 0 {
+				params := make([]string, len(e.Parameters))
+				for i, p := range e.Parameters {
+					params[i] = fmt.Sprintf("%s %s %s", p.In, p.Name, p.Type)
+					if p.Required {
+						params[i] += " required"
+					}
+				}
+				fmt.Fprintf(&b, "  params: %s\n", strings.Join(params, ", "))
+			}
+			if e.RequestBody != "" {
+				fmt.Fprintf(&b, "  body: %s\n", e.RequestBody)
+			}
+			if len(e.Responses) > 0 {
+				codes := make([]string, 0, len(e.Responses))
+				for code := range e.Responses {
+					codes = append(codes, code)
+				}
+				sort.Strings(codes)
+				for i, code := range codes {
+					codes[i] = code + " " + e.Responses[code]
+				}
+				fmt.Fprintf(&b, "  responses: %s\n", strings.Join(codes, "; "))
+			}
+		}
+	}
+	if len(a.Services) > 0 {
+		b.WriteString("Services:\n")
+		for _, s := range a.Services {
+			fmt.Fprintf(&b, "- service %s [%s]\n", s.Name, s.File)
+			for _, m := range s.Methods {
+				fmt.Fprintf(&b, "  rpc %s(%s%s) returns (%s%s)\n", m.Name,
+					streamPrefix(m.ClientStreaming), m.Request, streamPrefix(m.ServerStreaming), m.Response)
+			}
+		}
+	}
+	if len(a.Types) > 0 {
+		b.WriteString("Types:\n")
+		for _, t := range a.Types {
+			fmt.Fprintf(&b, "- %s %s [%s]", t.Kind, t.Name, t.File)
+			if t.Kind == "enum" {
+				fmt.Fprintf(&b, ": %s\n", strings.Join(t.Values, ", "))
+				continue
+			}
+			fields := make([]string, len(t.Fields))
+			for i, f := range t.Fields {
+				fields[i] = f.Name + " " + f.Type
+				if f.Number > 0 {
+					fields[i] += fmt.Sprintf(" = %d", f.Number)
+				}
+				if f.Required {
+					fields[i] += " required"
+				}
+			}
+			fmt.Fprintf(&b, " { %s }\n", strings.Join(fields, "; "))
+		}
+	}
+	return b.String()
+}
+
+func streamPrefix(streaming bool) string {
+	if streaming {
+		return "stream "
+	}
+	return ""
+}
@@ -0,0 +1,306 @@
+package apispec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const petstore = `openapi: 3.0.3
+info:
+  title: Petstore
+  version: 1.2.0
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List all pets
+      tags: [pets]
+      parameters:
+        - name: limit
+          in: query
+          schema: {type: integer, format: int32}
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Pet'}
+        default:
+          $ref: '#/components/responses/Error'
+    post:
+      operationId: createPet
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/NewPet'}
+      responses:
+        '201': {description: Created}
+  /pets/{petId}:
+    parameters:
+      - {name: petId, in: path, required: true, schema: {type: string}}
+    get:
+      operationId: showPetById
+      responses:
+        '200':
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+components:
+  responses:
+    Error:
+      description: Unexpected error
+      content:
+        application/json:
+          schema: {$ref: '#/components/schemas/Error'}
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      properties:
+        id: {type: integer, format: int64}
+        name: {type: string}
+        status: {$ref: '#/components/schemas/Status'}
+    NewPet:
+      allOf:
+        - {$ref: '#/components/schemas/Pet'}
+    Status:
+      type: string
+      enum: [available, sold]
+    Error:
+      type: object
+      properties:
+        message: {type: string}
+`
+
+const orders = `syntax = "proto3";
+
+// Orders of the shop
+package shop.v1;
+
+import "google/protobuf/timestamp.proto";
+
+option go_package = "example.com/shop/v1;shopv1";
+
+service OrderService {
+  option (google.api.default_host) = "shop.example.com";
+  rpc CreateOrder(CreateOrderRequest) returns (Order);
+  rpc WatchOrders(stream WatchRequest) returns (stream Order) {
+    option (google.api.http) = { get: "/v1/orders:watch" };
+  }
+}
+
+message Order {
+  /* Identifier; "quoted" */
+  string id = 1;
+  repeated Item items = 2 [deprecated = true];
+  map<string, string> labels = 3;
+  oneof payment {
+    string card = 4;
+    string voucher = 5;
+  }
+  reserved 6, 7;
+  Status status = 8;
+
+  message Item {
+    string sku = 1;
+    int32 quantity = 2;
+  }
+
+  enum Status {
+    STATUS_UNSPECIFIED = 0;
+    STATUS_PAID = 1 [(custom) = true];
+  }
+}
+
+message CreateOrderRequest { repeated Order.Item items = 1; }
+message WatchRequest {}
+`
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+}
+
+func TestParseOpenAPI(t *testing.T) {
+	api, err := ParseOpenAPI("api/openapi.yaml", []byte(petstore))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Spec{{File: "api/openapi.yaml", Format: FormatOpenAPI, Title: "Petstore", Version: "1.2.0"}}, api.Specs)
+	require.Len(t, api.Endpoints, 3)
+
+	list := api.Endpoints[0]
+	assert.Equal(t, "GET", list.Method)
+	assert.Equal(t, "/pets", list.Path)
+	assert.Equal(t, "listPets", list.OperationID)
+	assert.Equal(t, []string{"pets"}, list.Tags)
+	assert.Equal(t, []Field{{Name: "limit", Type: "integer(int32)", In: "query"}}, list.Parameters)
+	assert.Equal(t, map[string]string{"200": "[]Pet", "default": "Error"}, list.Responses)
+
+	create := api.Endpoints[1]
+	assert.Equal(t, "POST", create.Method)
+	assert.Equal(t, "NewPet", create.RequestBody)
+	assert.Equal(t, "Created", create.Responses["201"])
+
+	show := api.Endpoints[2]
+	assert.Equal(t, []Field{{Name: "petId", Type: "string", In: "path", Required: true}}, show.Parameters)
+
+	require.Len(t, api.Types, 4)
+	assert.Equal(t, "Error", api.Types[0].Name)
+	assert.Equal(t, Type{File: "api/openapi.yaml", Name: "NewPet", Kind: "object", Fields: []Field{{Name: "value", Type: "Pet"}}}, api.Types[1])
+	assert.Equal(t, []Field{
+		{Name: "id", Type: "integer(int64)", Required: true},
+		{Name: "name", Type: "string", Required: true},
+		{Name: "status", Type: "Status"},
+	}, api.Types[2].Fields)
+	assert.Equal(t, []string{"available", "sold"}, api.Types[3].Values)
+}
+
+func TestParseSwagger(t *testing.T) {
+	api, err := ParseOpenAPI("swagger.json", []byte(`{
+		"swagger": "2.0",
+		"info": {"title": "Users", "version": "1"},
+		"paths": {"/users": {"post": {
+			"operationId": "addUser",
+			"parameters": [
+				{"name": "body", "in": "body", "schema": {"$ref": "#/definitions/User"}},
+				{"name": "dry_run", "in": "query", "type": "boolean"}
+			],
+			"responses": {"200": {"description": "OK", "schema": {"$ref": "#/definitions/User"}}}
+		}}},
+		"definitions": {"User": {"type": "object", "properties": {"tags": {"type": "array", "items": {"type": "string"}}}}}
+	}`))
+	require.NoError(t, err)
+
+	require.Len(t, api.Endpoints, 1)
+	add := api.Endpoints[0]
+	assert.Equal(t, "User", add.RequestBody)
+	assert.Equal(t, []Field{{Name: "dry_run", Type: "boolean", In: "query"}}, add.Parameters)
+	assert.Equal(t, map[string]string{"200": "User"}, add.Responses)
+	require.Len(t, api.Types, 1)
+	assert.Equal(t, []Field{{Name: "tags", Type: "[]string"}}, api.Types[0].Fields)
+
+	_, err = ParseOpenAPI("config.yaml", []byte("name: app\n"))
+	assert.Error(t, err)
+}
+
+func TestParseProto(t *testing.T) {
+	api, err := ParseProto("proto/orders.proto", []byte(orders))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Spec{{File: "proto/orders.proto", Format: FormatProto, Package: "shop.v1"}}, api.Specs)
+	require.Len(t, api.Services, 1)
+	assert.Equal(t, "shop.v1.OrderService", api.Services[0].Name)
+	assert.Equal(t, []RPC{
+		{Name: "CreateOrder", Request: "CreateOrderRequest", Response: "Order"},
+		{Name: "WatchOrders", Request: "WatchRequest", Response: "Order", ClientStreaming: true, ServerStreaming: true},
+	}, api.Services[0].Methods)
+
+	names := make([]string, len(api.Types))
+	for i, t := range api.Types {
+		names[i] = t.Name
+	}
+	assert.Equal(t, []string{"shop.v1.Order", "shop.v1.Order.Item", "shop.v1.Order.Status", "shop.v1.CreateOrderRequest", "shop.v1.WatchRequest"}, names)
+	assert.Equal(t, []Field{
+		{Name: "id", Type: "string", Number: 1},
+		{Name: "items", Type: "repeated Item", Number: 2},
+		{Name: "labels", Type: "map<string, string>", Number: 3},
+		{Name: "card", Type: "string", Number: 4},
+		{Name: "voucher", Type: "string", Number: 5},
+		{Name: "status", Type: "Status", Number: 8},
+	}, api.Types[0].Fields)
+	assert.Equal(t, []string{"STATUS_UNSPECIFIED", "STATUS_PAID"}, api.Types[2].Values)
+	assert.Equal(t, "repeated Order.Item", api.Types[3].Fields[0].Type)
+
+	_, err = ParseProto("broken.proto", []byte("message Order { string id = ; }"))
+	assert.Error(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"api/openapi.yaml":         petstore,
+		"proto/orders.proto":       orders,
+		"proto/broken.proto":       "service {",
+		"config/settings.yaml":     "server:\n  port: 8080\n",
+		"node_modules/x/spec.yaml": petstore,
+		".github/openapi.yaml":     petstore,
+	})
+
+	api, err := Load(root)
+	require.NoError(t, err)
+
+	files := make([]string, len(api.Specs))
+	for i, spec := range api.Specs {
+		files[i] = spec.File
+	}
+	assert.Equal(t, []string{"api/openapi.yaml", "proto/orders.proto"}, files)
+	assert.Len(t, api.Endpoints, 3)
+	assert.Len(t, api.Services, 1)
+	require.Len(t, api.Errors, 1)
+	assert.Contains(t, api.Errors[0], "proto/broken.proto")
+}
+
+func TestSelect(t *testing.T) {
+	api, err := ParseOpenAPI("openapi.yaml", []byte(petstore))
+	require.NoError(t, err)
+	protoAPI, err := ParseProto("orders.proto", []byte(orders))
+	require.NoError(t, err)
+	api.merge(protoAPI)
+
+	selected := api.Select("showPetById", 10)
+	require.Len(t, selected.Endpoints, 1)
+	assert.Equal(t, "showPetById", selected.Endpoints[0].OperationID)
+	assert.Empty(t, selected.Services)
+	// The types of the endpoint come along
+	var types []string
+	for _, t := range selected.Types {
+		types = append(types, t.Name)
+	}
+	assert.Contains(t, types, "Pet")
+
+	selected = api.Select("watch orders", 10)
+	assert.Empty(t, selected.Endpoints)
+	require.Len(t, selected.Services, 1)
+	types = nil
+	for _, t := range selected.Types {
+		types = append(types, t.Name)
+	}
+	assert.Contains(t, types, "shop.v1.WatchRequest")
+
+	all := api.Select("", 2)
+	assert.Len(t, all.Endpoints, 2)
+	assert.Len(t, all.Services, 1)
+}
+
+func TestTouchesAPI(t *testing.T) {
+	assert.True(t, TouchesAPI("Add a pagination parameter to the list endpoint", nil))
+	assert.True(t, TouchesAPI("Fix the bug", []string{"internal/handlers/pets.go"}))
+	assert.True(t, TouchesAPI("Regenerate", []string{"proto/orders.proto"}))
+	assert.False(t, TouchesAPI("Refactor the config loader", []string{"internal/config/load.go"}))
+	// Words are matched whole
+	assert.False(t, TouchesAPI("Capitalize the title of the rapid reports", nil))
+}
+
+func TestPromptContext(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"api/openapi.yaml": petstore})
+
+	context := PromptContext(root, "Implement the createPet handler", []string{"internal/handlers/pets.go"})
+	assert.Contains(t, context, "POST /pets (createPet)")
+	assert.Contains(t, context, "object NewPet")
+	assert.NotContains(t, context, "showPetById")
+
+	assert.Empty(t, PromptContext(root, "Refactor the config loader", []string{"internal/config/load.go"}))
+	assert.Empty(t, PromptContext(t.TempDir(), "Implement the createPet handler", nil))
+	assert.True(t, strings.HasPrefix(context, "API definitions"))
+}
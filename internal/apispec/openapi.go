@@ -0,0 +1,261 @@
+package apispec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// httpMethods are the operations of an OpenAPI path item, in display order
+var httpMethods = []string{"get", "post", "put", "patch", "delete", "head", "options", "trace"}
+
+// ParseOpenAPI parses an OpenAPI 3 or Swagger 2 document, in YAML or JSON.
+// Only local references ("#/components/...") are resolved.
+func ParseOpenAPI(file string, data []byte) (*API, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	// Unquoted versions such as swagger: 2.0 are numbers
+	if doc["openapi"] == nil && doc["swagger"] == nil {
+		return nil, fmt.Errorf("not an OpenAPI document: no openapi or swagger version")
+	}
+
+	p := openAPIParser{file: file, doc: doc}
+	info := mapOf(doc["info"])
+	api := &API{Specs: []Spec{{File: file, Format: FormatOpenAPI, Title: stringOf(info["title"]), Version: stringOf(info["version"])}}}
+
+	paths := mapOf(doc["paths"])
+	for _, path := range sortedKeys(paths) {
+		item := p.resolve(paths[path])
+		shared := listOf(item["parameters"])
+		for _, method := range httpMethods {
+			if op, ok := item[method]; ok {
+				api.Endpoints = append(api.Endpoints, p.endpoint(strings.ToUpper(method), path, p.resolve(op), shared))
+			}
+		}
+	}
+
+	schemas := mapOf(mapOf(doc["components"])["schemas"])
+	if len(schemas) == 0 {
+		schemas = mapOf(doc["definitions"]) // Swagger 2
+	}
+	for _, name := range sortedKeys(schemas) {
+		api.Types = append(api.Types, p.schemaType(name, p.resolve(schemas[name])))
+	}
+	return api, nil
+}
+
+// openAPIParser resolves references within one document
+type openAPIParser struct {
+	file string
+	doc  map[string]interface{}
+}
+
+// resolve follows a local $ref, if value is one
+func (p *openAPIParser) resolve(value interface{}) map[string]interface{} {
+	node := mapOf(value)
+	for depth := 0; depth < 10; depth++ {
+		ref, ok := node["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return node
+		}
+		var target interface{} = p.doc
+		for _, part := range strings.Split(ref[2:], "/") {
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+			target = mapOf(target)[part]
+		}
+		node = mapOf(target)
+	}
+	return node
+}
+
+// endpoint describes an operation
+func (p *openAPIParser) endpoint(method, path string, op map[string]interface{}, shared []interface{}) Endpoint {
+	endpoint := Endpoint{
+		File:        p.file,
+		Method:      method,
+		Path:        path,
+		OperationID: stringOf(op["operationId"]),
+		Summary:     firstLine(stringOf(op["summary"]), stringOf(op["description"])),
+	}
+	for _, tag := range listOf(op["tags"]) {
+		endpoint.Tags = append(endpoint.Tags, stringOf(tag))
+	}
+
+	for _, raw := range append(shared, listOf(op["parameters"])...) {
+		param := p.resolve(raw)
+		if stringOf(param["in"]) == "body" { // Swagger 2
+			endpoint.RequestBody = p.typeName(param["schema"])
+			continue
+		}
+		field := Field{
+			Name:        stringOf(param["name"]),
+			In:          stringOf(param["in"]),
+			Required:    param["required"] == true,
+			Description: firstLine(stringOf(param["description"])),
+		}
+		if schema, ok := param["schema"]; ok {
+			field.Type = p.typeName(schema)
+		} else {
+			field.Type = p.typeName(param) // Swagger 2 keeps the type on the parameter
+		}
+		// Operation parameters override shared ones of the same name
+		replaced := false
+		for i, existing := range endpoint.Parameters {
+			if existing.Name == field.Name && existing.In == field.In {
+				endpoint.Parameters[i], replaced = field, true
+			}
+		}
+		if !replaced {
+			endpoint.Parameters = append(endpoint.Parameters, field)
+		}
+	}
+
+	if body := p.resolve(op["requestBody"]); len(body) > 0 {
+		endpoint.RequestBody = p.contentType(body)
+	}
+	responses := mapOf(op["responses"])
+	if len(responses) > 0 {
+		endpoint.Responses = make(map[string]string, len(responses))
+		for code, raw := range responses {
+			response := p.resolve(raw)
+			described := p.contentType(response)
+			if schema, ok := response["schema"]; ok { // Swagger 2
+				described = p.typeName(schema)
+			}
+			if described == "" {
+				described = firstLine(stringOf(response["description"]))
+			}
+			endpoint.Responses[code] = described
+		}
+	}
+	return endpoint
+}
+
+// contentType returns the type of the first media type of a request body or
+// response, preferring JSON
+func (p *openAPIParser) contentType(node map[string]interface{}) string {
+	content := mapOf(node["content"])
+	if media, ok := content["application/json"]; ok {
+		return p.typeName(mapOf(media)["schema"])
+	}
+	for _, name := range sortedKeys(content) {
+		return p.typeName(mapOf(content[name])["schema"])
+	}
+	return ""
+}
+
+// typeName names the type of a schema: the referenced schema, []T for
+// arrays, or the JSON type and format
+func (p *openAPIParser) typeName(value interface{}) string {
+	schema := mapOf(value)
+	if ref, ok := schema["$ref"].(string); ok {
+		return ref[strings.LastIndex(ref, "/")+1:]
+	}
+	for _, combinator := range []string{"oneOf", "anyOf", "allOf"} {
+		if options := listOf(schema[combinator]); len(options) > 0 {
+			names := make([]string, len(options))
+			for i, option := range options {
+				names[i] = p.typeName(option)
+			}
+			separator := " | "
+			if combinator == "allOf" {
+				separator = " & "
+			}
+			return strings.Join(names, separator)
+		}
+	}
+	kind := stringOf(schema["type"])
+	switch kind {
+	case "array":
+		return "[]" + p.typeName(schema["items"])
+	case "object":
+		if additional := mapOf(schema["additionalProperties"]); len(additional) > 0 {
+			return "map[string]" + p.typeName(additional)
+		}
+	case "":
+		return "any"
+	}
+	if format := stringOf(schema["format"]); format != "" {
+		return kind + "(" + format + ")"
+	}
+	return kind
+}
+
+// schemaType describes a named schema
+func (p *openAPIParser) schemaType(name string, schema map[string]interface{}) Type {
+	t := Type{File: p.file, Name: name, Kind: "object", Description: firstLine(stringOf(schema["description"]))}
+	if values := listOf(schema["enum"]); len(values) > 0 {
+		t.Kind = "enum"
+		for _, value := range values {
+			t.Values = append(t.Values, fmt.Sprint(value))
+		}
+		return t
+	}
+	required := map[string]bool{}
+	for _, field := range listOf(schema["required"]) {
+		required[stringOf(field)] = true
+	}
+	properties := mapOf(schema["properties"])
+	for _, field := range sortedKeys(properties) {
+		t.Fields = append(t.Fields, Field{
+			Name:        field,
+			Type:        p.typeName(properties[field]),
+			Required:    required[field],
+			Description: firstLine(stringOf(mapOf(properties[field])["description"])),
+		})
+	}
+	if len(t.Fields) == 0 && stringOf(schema["type"]) != "object" {
+		t.Fields = []Field{{Name: "value", Type: p.typeName(schema)}}
+	}
+	return t
+}
+
+// mapOf returns value as a mapping. YAML mappings with keys that are not
+// all strings, such as unquoted status codes, have their keys formatted.
+func mapOf(value interface{}) map[string]interface{} {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(m))
+		for key, v := range m {
+			converted[fmt.Sprint(key)] = v
+		}
+		return converted
+	}
+	return nil
+}
+
+func listOf(value interface{}) []interface{} {
+	l, _ := value.([]interface{})
+	return l
+}
+
+func stringOf(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+// firstLine returns the first line of the first non-empty text
+func firstLine(texts ...string) string {
+	for _, text := range texts {
+		if text = strings.TrimSpace(text); text != "" {
+			line, _, _ := strings.Cut(text, "\n")
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,328 @@
+package apispec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseProto parses the messages, enums and services of a .proto file.
+// Options, reserved ranges and extensions are skipped.
+func ParseProto(file string, data []byte) (*API, error) {
+	tokens, err := tokenizeProto(string(data))
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{file: file, tokens: tokens, api: &API{}}
+	for p.err == nil && !p.done() {
+		switch token := p.next(); token {
+		case "syntax", "edition", "import", "option":
+			p.skipStatement()
+		case "package":
+			p.pkg = p.next()
+			p.expect(";")
+		case "message":
+			p.message("")
+		case "enum":
+			p.enum("")
+		case "service":
+			p.service()
+		case "extend":
+			p.next()
+			p.skipBlock()
+		case ";":
+		default:
+			p.fail("unexpected %q", token)
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.api.Specs = []Spec{{File: file, Format: FormatProto, Package: p.pkg}}
+	return p.api, nil
+}
+
+// protoParser reads the declarations of a .proto file from its tokens
+type protoParser struct {
+	file   string
+	tokens []string
+	pos    int
+	pkg    string
+	api    *API
+	err    error
+}
+
+func (p *protoParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *protoParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) next() string {
+	token := p.peek()
+	if token == "" {
+		p.fail("unexpected end of file")
+	}
+	p.pos++
+	return token
+}
+
+func (p *protoParser) expect(want string) {
+	if got := p.next(); got != want && p.err == nil {
+		p.fail("expected %q, got %q", want, got)
+	}
+}
+
+// fail records the first error, stopping the parse
+func (p *protoParser) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf(format, args...)
+		p.pos = len(p.tokens)
+	}
+}
+
+// qualify prefixes a name with the package
+func (p *protoParser) qualify(name string) string {
+	if p.pkg == "" {
+		return name
+	}
+	return p.pkg + "." + name
+}
+
+// skipStatement skips to the end of a statement, including option values
+// written as blocks
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for !p.done() {
+		switch p.next() {
+		case "{", "[":
+			depth++
+		case "}", "]":
+			depth--
+		case ";":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// skipBlock skips a block and everything in it
+func (p *protoParser) skipBlock() {
+	p.expect("{")
+	for depth := 1; depth > 0 && !p.done(); {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+	}
+}
+
+// message parses a message, after its keyword; nested types are named
+// after the messages they are in
+func (p *protoParser) message(parent string) {
+	name := p.next()
+	if parent != "" {
+		name = parent + "." + name
+	}
+	p.expect("{")
+	index := len(p.api.Types)
+	p.api.Types = append(p.api.Types, Type{File: p.file, Name: p.qualify(name), Kind: "message"})
+	var fields []Field
+	for p.err == nil && p.peek() != "}" {
+		switch p.peek() {
+		case "message":
+			p.next()
+			p.message(name)
+		case "enum":
+			p.next()
+			p.enum(name)
+		case "option", "reserved", "extensions":
+			p.skipStatement()
+		case "extend":
+			p.next()
+			p.next()
+			p.skipBlock()
+		case "oneof":
+			p.next()
+			p.next()
+			p.expect("{")
+			for p.err == nil && p.peek() != "}" {
+				if p.peek() == "option" {
+					p.skipStatement()
+					continue
+				}
+				fields = append(fields, p.field())
+			}
+			p.expect("}")
+		case ";":
+			p.next()
+		default:
+			fields = append(fields, p.field())
+		}
+	}
+	p.expect("}")
+	p.api.Types[index].Fields = fields
+}
+
+// field parses a message field
+func (p *protoParser) field() Field {
+	var field Field
+	label := ""
+	switch p.peek() {
+	case "repeated", "optional", "required":
+		label = p.next()
+	}
+	field.Type = p.next()
+	if field.Type == "map" {
+		p.expect("<")
+		key := p.next()
+		p.expect(",")
+		value := p.next()
+		p.expect(">")
+		field.Type = fmt.Sprintf("map<%s, %s>", key, value)
+	}
+	if label == "repeated" {
+		field.Type = "repeated " + field.Type
+	}
+	field.Required = label == "required"
+	field.Name = p.next()
+	p.expect("=")
+	number, err := strconv.Atoi(p.next())
+	if err != nil {
+		p.fail("field %s has no number", field.Name)
+	}
+	field.Number = number
+	if p.peek() == "[" {
+		for !p.done() && p.next() != "]" {
+		}
+	}
+	p.expect(";")
+	return field
+}
+
+// enum parses an enum, after its keyword
+func (p *protoParser) enum(parent string) {
+	name := p.next()
+	if parent != "" {
+		name = parent + "." + name
+	}
+	p.expect("{")
+	enum := Type{File: p.file, Name: p.qualify(name), Kind: "enum"}
+	for p.err == nil && p.peek() != "}" {
+		switch p.peek() {
+		case "option", "reserved":
+			p.skipStatement()
+		case ";":
+			p.next()
+		default:
+			enum.Values = append(enum.Values, p.next())
+			p.skipStatement()
+		}
+	}
+	p.expect("}")
+	p.api.Types = append(p.api.Types, enum)
+}
+
+// service parses a service, after its keyword
+func (p *protoParser) service() {
+	service := Service{File: p.file, Name: p.qualify(p.next())}
+	p.expect("{")
+	for p.err == nil && p.peek() != "}" {
+		switch p.next() {
+		case "rpc":
+			var rpc RPC
+			rpc.Name = p.next()
+			rpc.Request, rpc.ClientStreaming = p.rpcType()
+			p.expect("returns")
+			rpc.Response, rpc.ServerStreaming = p.rpcType()
+			if p.peek() == "{" {
+				p.skipBlock()
+			} else {
+				p.expect(";")
+			}
+			service.Methods = append(service.Methods, rpc)
+		case "option":
+			p.skipStatement()
+		case ";":
+		default:
+			p.fail("unexpected %q in service %s", p.tokens[p.pos-1], service.Name)
+		}
+	}
+	p.expect("}")
+	p.api.Services = append(p.api.Services, service)
+}
+
+// rpcType parses the parenthesized request or response type of an RPC
+func (p *protoParser) rpcType() (string, bool) {
+	p.expect("(")
+	streaming := false
+	if p.peek() == "stream" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] != ")" {
+		p.next()
+		streaming = true
+	}
+	name := p.next()
+	p.expect(")")
+	return name, streaming
+}
+
+// tokenizeProto splits a .proto file into identifiers, numbers, strings and
+// punctuation, dropping comments
+func tokenizeProto(source string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(source[i:], "//"):
+			end := strings.IndexByte(source[i:], '\n')
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end + 1
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			start := i
+			for i++; i < len(source) && source[i] != c; i++ {
+				if source[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(source) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			i++
+			tokens = append(tokens, source[start:i])
+		case isProtoWordByte(c):
+			start := i
+			for i < len(source) && isProtoWordByte(source[i]) {
+				i++
+			}
+			tokens = append(tokens, source[start:i])
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// isProtoWordByte reports whether c can be part of an identifier, a fully
+// qualified name or a number
+func isProtoWordByte(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c == '+' ||
+		c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,248 @@
+package apispec
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxPromptContext caps the API definitions added to a prompt
+const maxPromptContext = 6000
+
+// apiWords are words in a task that suggest it touches API code
+var apiWords = map[string]bool{
+	"api": true, "apis": true, "endpoint": true, "endpoints": true, "route": true, "routes": true,
+	"handler": true, "handlers": true, "controller": true, "grpc": true, "rpc": true, "rest": true,
+	"openapi": true, "swagger": true, "proto": true, "protobuf": true, "request": true, "response": true,
+	"client": true, "server": true, "http": true,
+}
+
+// apiDirs are directory names that hold API code
+var apiDirs = map[string]bool{
+	"api": true, "apis": true, "handler": true, "handlers": true, "routes": true, "router": true,
+	"controller": true, "controllers": true, "grpc": true, "proto": true, "rpc": true, "openapi": true,
+}
+
+var (
+	wordPattern     = regexp.MustCompile(`[A-Za-z][A-Za-z0-9]*`)
+	typeNamePattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_.]*`)
+)
+
+// TouchesAPI reports whether a task, described by its text and the files it
+// changes, is about API code
+func TouchesAPI(text string, files []string) bool {
+	for _, file := range files {
+		file = strings.ToLower(path.Clean(strings.ReplaceAll(file, "\\", "/")))
+		base := path.Base(file)
+		if path.Ext(base) == ".proto" || strings.Contains(base, "openapi") || strings.Contains(base, "swagger") {
+			return true
+		}
+		for _, dir := range strings.Split(path.Dir(file), "/") {
+			if apiDirs[dir] {
+				return true
+			}
+		}
+	}
+	for _, word := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		if apiWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the endpoints, services and types most relevant to query,
+// at most limit of each, along with the types they use. An empty query
+// selects everything up to the limit, and a limit of 0 is no limit.
+func (a *API) Select(query string, limit int) *API {
+	terms := queryTerms(query)
+	selected := &API{Specs: a.Specs, Errors: a.Errors}
+
+	endpoints := rank(len(a.Endpoints), terms, func(i int) string {
+		e := a.Endpoints[i]
+		return strings.Join(append([]string{e.Method, e.Path, e.OperationID, e.Summary, e.RequestBody}, e.Tags...), " ")
+	})
+	for _, i := range capped(endpoints, limit) {
+		selected.Endpoints = append(selected.Endpoints, a.Endpoints[i])
+	}
+	services := rank(len(a.Services), terms, func(i int) string {
+		s := a.Services[i]
+		text := s.Name
+		for _, m := range s.Methods {
+			text += " " + m.Name + " " + m.Request + " " + m.Response
+		}
+		return text
+	})
+	for _, i := range capped(services, limit) {
+		selected.Services = append(selected.Services, a.Services[i])
+	}
+
+	// Types named by the query, then the ones the selection uses
+	wanted := map[string]bool{}
+	types := rank(len(a.Types), terms, func(i int) string {
+		t := a.Types[i]
+		text := t.Name + " " + t.Description
+		for _, f := range t.Fields {
+			text += " " + f.Name
+		}
+		return text
+	})
+	if len(terms) > 0 {
+		for _, i := range capped(types, limit) {
+			wanted[a.Types[i].Name] = true
+		}
+	}
+	for _, e := range selected.Endpoints {
+		markTypes(wanted, e.RequestBody)
+		for _, response := range e.Responses {
+			markTypes(wanted, response)
+		}
+		for _, p := range e.Parameters {
+			markTypes(wanted, p.Type)
+		}
+	}
+	for _, s := range selected.Services {
+		for _, m := range s.Methods {
+			markTypes(wanted, m.Request)
+			markTypes(wanted, m.Response)
+		}
+	}
+	for i, t := range a.Types {
+		everything := len(terms) == 0 && (limit <= 0 || len(selected.Types) < limit)
+		if everything || wanted[t.Name] || wanted[shortName(t.Name)] {
+			selected.Types = append(selected.Types, a.Types[i])
+		}
+	}
+	if limit > 0 && len(selected.Types) > 2*limit {
+		selected.Types = selected.Types[:2*limit]
+	}
+	return selected
+}
+
+// PromptContext loads the API of the workspace at root and returns the
+// definitions relevant to a task, ready to add to a prompt. It returns ""
+// when the task does not touch API code or nothing relevant is found.
+func PromptContext(root, task string, files []string) string {
+	if !TouchesAPI(task, files) {
+		return ""
+	}
+	api, err := Load(root)
+	if err != nil || api.Empty() {
+		return ""
+	}
+	selected := api.Select(task+" "+strings.Join(files, " "), 20)
+	if selected.Empty() {
+		return ""
+	}
+	text := selected.Format()
+	if len(text) > maxPromptContext {
+		text = text[:strings.LastIndex(text[:maxPromptContext], "\n")+1] + "...\n"
+	}
+	return "API definitions from the workspace specifications (follow these contracts):\n" + text
+}
+
+// queryTerms splits a query into lowercase terms, splitting camel case and
+// path segments and dropping short words
+func queryTerms(query string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, word := range wordPattern.FindAllString(splitCamel(query), -1) {
+		word = strings.ToLower(word)
+		if len(word) < 3 || seen[word] || apiWords[word] || stopWords[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// stopWords are common words that say nothing about which definitions matter
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "add": true, "new": true, "use": true,
+	"from": true, "into": true, "that": true, "this": true, "should": true, "when": true,
+	"implement": true, "file": true, "files": true, "code": true,
+	"json": true, "yaml": true, "internal": true, "pkg": true,
+}
+
+// splitCamel separates the words of camelCase identifiers
+func splitCamel(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && r >= 'A' && r <= 'Z' && s[i-1] >= 'a' && s[i-1] <= 'z' {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// rank returns the indexes of the n items that match terms, best first. With
+// no terms every item matches, in order. Terms that match every item, such as
+// the resource of a spec that has only one, are ignored while others match.
+func rank(n int, terms []string, text func(int) string) []int {
+	haystacks := make([]string, n)
+	counts := make([]int, len(terms))
+	for i := range haystacks {
+		haystacks[i] = strings.ToLower(splitCamel(text(i)))
+		for j, term := range terms {
+			if strings.Contains(haystacks[i], term) {
+				counts[j]++
+			}
+		}
+	}
+	var informative []string
+	for j, term := range terms {
+		if counts[j] > 0 && (counts[j] < n || n == 1) {
+			informative = append(informative, term)
+		}
+	}
+	if len(informative) > 0 {
+		terms = informative
+	}
+
+	type scored struct{ index, score int }
+	var matches []scored
+	for i, haystack := range haystacks {
+		score := 0
+		if len(terms) == 0 {
+			score = 1
+		}
+		for _, term := range terms {
+			if strings.Contains(haystack, term) {
+				score++
+			}
+		}
+		if score > 0 {
+			matches = append(matches, scored{i, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	indexes := make([]int, len(matches))
+	for i, m := range matches {
+		indexes[i] = m.index
+	}
+	return indexes
+}
+
+func capped(indexes []int, limit int) []int {
+	if limit > 0 && len(indexes) > limit {
+		return indexes[:limit]
+	}
+	return indexes
+}
+
+// markTypes marks the type names in a type expression such as
+// "[]Pet | Error" or "repeated pkg.Pet"
+func markTypes(wanted map[string]bool, expression string) {
+	for _, name := range typeNamePattern.FindAllString(expression, -1) {
+		wanted[name] = true
+		wanted[shortName(name)] = true
+	}
+}
+
+// shortName drops the package from a qualified proto name
+func shortName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/castrovroberto/CGE/internal/statedir"
 	"github.com/google/uuid"
 )
 
@@ -16,10 +17,12 @@ type EventType string
 
 const (
 	EventToolExecution EventType = "tool_execution"
+	EventToolProgress  EventType = "tool_progress"
 	EventFileOperation EventType = "file_operation"
 	EventPatchApply    EventType = "patch_apply"
 	EventGitCommit     EventType = "git_commit"
 	EventRollback      EventType = "rollback"
+	EventPolicyDenial  EventType = "policy_denial"
 	EventError         EventType = "error"
 )
 
@@ -34,6 +37,7 @@ const (
 	OpPatch    OperationType = "patch"
 	OpCommit   OperationType = "commit"
 	OpRollback OperationType = "rollback"
+	OpDeny     OperationType = "deny"
 )
 
 // AuditEvent represents a single audit event
@@ -73,17 +77,17 @@ func NewAuditLogger(workspaceRoot string, sessionID string) (*AuditLogger, error
 	}
 	cleanWorkspaceRoot := filepath.Clean(absWorkspaceRoot)
 
-	logDir := filepath.Join(cleanWorkspaceRoot, ".cge", "audit")
-
-	if !strings.HasPrefix(filepath.Clean(logDir), cleanWorkspaceRoot) {
-		return nil, fmt.Errorf("audit log directory path is outside the intended workspace root")
-	}
+	logDir := statedir.Path(cleanWorkspaceRoot, statedir.Audit)
 
 	if err := os.MkdirAll(logDir, 0750); err != nil {
 		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
 	}
+	sessionPrefix := sessionID
+	if len(sessionPrefix) > 8 {
+		sessionPrefix = sessionPrefix[:8]
+	}
 	logFileName := fmt.Sprintf("audit_%s_%s.jsonl",
-		time.Now().Format("2006-01-02"), sessionID[:8])
+		time.Now().Format("2006-01-02"), sessionPrefix)
 
 	rawLogFilePath := filepath.Join(logDir, logFileName)
 	cleanLogFilePath := filepath.Clean(rawLogFilePath)
@@ -140,6 +144,31 @@ func (al *AuditLogger) LogToolExecution(toolName string, success bool, duration
 	al.writeEvent(event)
 }
 
+// LogToolProgress logs a progress update reported by a running tool
+func (al *AuditLogger) LogToolProgress(toolName, callID string, progress float64, status string, step, totalSteps int, done bool) {
+	if !al.enabled {
+		return
+	}
+
+	al.writeEvent(AuditEvent{
+		ID:        uuid.New().String(),
+		Timestamp: time.Now(),
+		SessionID: al.sessionID,
+		EventType: EventToolProgress,
+		Operation: OpRead,
+		ToolName:  toolName,
+		Success:   true,
+		Metadata: map[string]interface{}{
+			"tool_call_id": callID,
+			"progress":     progress,
+			"status":       status,
+			"step":         step,
+			"total_steps":  totalSteps,
+			"done":         done,
+		},
+	})
+}
+
 // LogFileOperation logs a file operation event
 func (al *AuditLogger) LogFileOperation(operation OperationType, filePath string, success bool, err error, metadata map[string]interface{}) {
 	if !al.enabled {
@@ -262,6 +291,32 @@ func (al *AuditLogger) LogRollback(filePaths []string, success bool, err error)
 	al.writeEvent(event)
 }
 
+// LogPolicyDenial logs a tool call or commit refused by a policy rule
+func (al *AuditLogger) LogPolicyDenial(rule, action, toolName, filePath, reason string, metadata map[string]interface{}) {
+	if !al.enabled {
+		return
+	}
+
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata["rule"] = rule
+	metadata["action"] = action
+
+	al.writeEvent(AuditEvent{
+		ID:        uuid.New().String(),
+		Timestamp: time.Now(),
+		SessionID: al.sessionID,
+		EventType: EventPolicyDenial,
+		Operation: OpDeny,
+		ToolName:  toolName,
+		FilePath:  filePath,
+		Success:   false,
+		Error:     reason,
+		Metadata:  metadata,
+	})
+}
+
 // LogError logs an error event
 func (al *AuditLogger) LogError(operation OperationType, context string, err error, metadata map[string]interface{}) {
 	if !al.enabled {
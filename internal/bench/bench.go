@@ -0,0 +1,387 @@
+// Package bench measures the hot paths of CGE on synthetic workloads:
+// chunking source files, searching the vector store at several index sizes,
+// and assembling the prompt of a step from a long history. Results are
+// reported in the format of `go test -bench`, so runs of different releases
+// can be compared with benchstat, and as JSON for `CGE bench --compare`.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/orchestrator"
+	"github.com/castrovroberto/CGE/internal/textutils"
+	"github.com/castrovroberto/CGE/internal/vectorstore"
+)
+
+// Dimension of the vectors of the search benchmarks, that of common local
+// embedding models
+const vectorDim = 384
+
+// Case is a benchmark of the suite
+type Case struct {
+	Name string // e.g. "Search/hnsw/10k"
+	// Short reports whether the case runs with --short
+	Short bool
+	Run   func(b *testing.B)
+}
+
+// Cases returns the benchmarks of the suite, in the order they are run
+func Cases() []Case {
+	var cases []Case
+	source := syntheticSource(2000)
+	for _, strategy := range []struct {
+		name     string
+		strategy textutils.ChunkStrategy
+		maxSize  int
+	}{
+		{"lines", textutils.ChunkByLines, 100},
+		{"tokens", textutils.ChunkByTokens, 512},
+		{"semantic", textutils.ChunkBySemanticBoundaries, 100},
+	} {
+		chunker := textutils.NewChunker(textutils.ChunkOptions{Strategy: strategy.strategy, MaxSize: strategy.maxSize, OverlapSize: strategy.maxSize / 10})
+		cases = append(cases, Case{Name: "Chunk/" + strategy.name, Short: true, Run: func(b *testing.B) {
+			b.SetBytes(int64(len(source)))
+			for i := 0; i < b.N; i++ {
+				if _, err := chunker.ChunkText(source); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}})
+	}
+
+	for _, size := range []int{1000, 10000, 50000} {
+		for _, backend := range []string{vectorstore.BackendFlat, vectorstore.BackendHNSW} {
+			index := &searchIndex{backend: backend, size: size}
+			cases = append(cases, Case{Name: fmt.Sprintf("Search/%s/%dk", backend, size/1000), Short: size <= 10000, Run: index.benchmark})
+		}
+	}
+
+	for _, turns := range []int{100, 1000} {
+		messages := syntheticHistory(turns)
+		for _, strategy := range []string{orchestrator.HistoryFull, orchestrator.HistoryWindow, orchestrator.HistorySummaryWindow, orchestrator.HistoryRetrieval} {
+			// Without a model, summary_window summarizes with a digest of the
+			// turns, so the case measures CGE rather than the model
+			runner := orchestrator.NewAgentRunner(nil, nil, "You are a coding agent.", "")
+			runner.SetHistory(config.HistoryConfig{Strategy: strategy})
+			cases = append(cases, Case{Name: fmt.Sprintf("Prompt/%s/%dturns", strategy, turns), Short: turns <= 100, Run: func(b *testing.B) {
+				ctx := context.Background()
+				_, prompt := runner.AssemblePrompt(ctx, messages)
+				b.SetBytes(int64(len(prompt)))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					runner.AssemblePrompt(ctx, messages)
+				}
+			}})
+		}
+	}
+	return cases
+}
+
+// searchIndex is the index of a search case, built on its first run and
+// reused across the runs testing.Benchmark makes to find the iteration count
+type searchIndex struct {
+	backend string
+	size    int
+	index   vectorstore.VectorStoreBackend
+	queries [][]float32
+}
+
+func (s *searchIndex) benchmark(b *testing.B) {
+	if s.index == nil {
+		index, err := vectorstore.NewBackend(vectorstore.BackendConfig{Type: s.backend})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i, vector := range randomVectors(s.size, vectorDim, 1) {
+			index.Add(fmt.Sprintf("chunk_%d", i), vector)
+		}
+		s.index = index
+		s.queries = randomVectors(100, vectorDim, 2)
+		b.ResetTimer()
+	}
+	for i := 0; i < b.N; i++ {
+		s.index.Search(s.queries[i%len(s.queries)], 10, nil)
+	}
+}
+
+// Select returns the cases whose name matches pattern, those of the short
+// suite only when short is set
+func Select(cases []Case, pattern string, short bool) ([]Case, error) {
+	var re *regexp.Regexp
+	if pattern != "" {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid benchmark pattern: %w", err)
+		}
+	}
+	var selected []Case
+	for _, c := range cases {
+		if (re == nil || re.MatchString(c.Name)) && (!short || c.Short) {
+			selected = append(selected, c)
+		}
+	}
+	return selected, nil
+}
+
+// Result is the measurement of a case
+type Result struct {
+	Name        string  `json:"name"`
+	Iterations  int     `json:"iterations"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	MBPerSec    float64 `json:"mb_per_sec,omitempty"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+}
+
+// Run measures a case
+func Run(c Case) Result {
+	r := testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		c.Run(b)
+	})
+	result := Result{
+		Name:        c.Name,
+		Iterations:  r.N,
+		BytesPerOp:  r.AllocedBytesPerOp(),
+		AllocsPerOp: r.AllocsPerOp(),
+	}
+	if r.N > 0 {
+		result.NsPerOp = float64(r.T.Nanoseconds()) / float64(r.N)
+		if r.Bytes > 0 && r.T > 0 {
+			result.MBPerSec = float64(r.Bytes) * float64(r.N) / 1e6 / r.T.Seconds()
+		}
+	}
+	return result
+}
+
+// Report is a run of the suite
+type Report struct {
+	Version   string    `json:"version"`
+	GoVersion string    `json:"go_version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	CPUs      int       `json:"cpus"`
+	Time      time.Time `json:"time"`
+	Results   []Result  `json:"results"`
+}
+
+// NewReport returns an empty report of a run on this machine
+func NewReport() *Report {
+	return &Report{
+		Version:   buildVersion(),
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		CPUs:      runtime.GOMAXPROCS(0),
+		Time:      time.Now(),
+	}
+}
+
+// buildVersion returns the module version of the binary, or its VCS
+// revision for development builds
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if version := info.Main.Version; version != "" && version != "(devel)" {
+		return version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return "devel-" + setting.Value[:12]
+		}
+	}
+	return "devel"
+}
+
+// WriteHeader writes the configuration lines benchstat groups results by
+func (r *Report) WriteHeader(w io.Writer) {
+	fmt.Fprintf(w, "goos: %s\ngoarch: %s\npkg: cge/bench\ncge: %s\n", r.OS, r.Arch, r.Version)
+}
+
+// WriteResult writes a result as a line of `go test -bench` output
+func (r *Report) WriteResult(w io.Writer, result Result) {
+	name := "Benchmark" + result.Name
+	if r.CPUs > 1 {
+		name = fmt.Sprintf("%s-%d", name, r.CPUs)
+	}
+	line := fmt.Sprintf("%s\t%d\t%.1f ns/op", name, result.Iterations, result.NsPerOp)
+	if result.MBPerSec > 0 {
+		line += fmt.Sprintf("\t%.2f MB/s", result.MBPerSec)
+	}
+	fmt.Fprintf(w, "%s\t%d B/op\t%d allocs/op\n", line, result.BytesPerOp, result.AllocsPerOp)
+}
+
+// Save writes the report as JSON to path
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644) // #nosec G306 - benchmark results
+}
+
+// LoadReport reads a report saved by Save
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - path given by the user
+	if err != nil {
+		return nil, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse benchmark report %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// Delta compares a result of a run with that of the same case in a baseline
+type Delta struct {
+	Name     string
+	Base     float64 // ns/op of the baseline
+	Current  float64 // ns/op of the run
+	Change   float64 // Relative change of the time per operation, e.g. -0.25 for 25% faster
+	Baseline bool    // Whether the baseline has the case
+}
+
+// Compare compares the time per operation of the results of the report with
+// those of the baseline, in the order of the report
+func (r *Report) Compare(baseline *Report) []Delta {
+	base := make(map[string]float64, len(baseline.Results))
+	for _, result := range baseline.Results {
+		base[result.Name] = result.NsPerOp
+	}
+	deltas := make([]Delta, 0, len(r.Results))
+	for _, result := range r.Results {
+		delta := Delta{Name: result.Name, Current: result.NsPerOp}
+		if ns, ok := base[result.Name]; ok && ns > 0 {
+			delta.Base, delta.Baseline = ns, true
+			delta.Change = result.NsPerOp/ns - 1
+		}
+		deltas = append(deltas, delta)
+	}
+	return deltas
+}
+
+// WriteComparison writes the comparison of the report with the baseline as a
+// table, flagging the cases slower by more than threshold, e.g. 0.1 for 10%.
+// It returns the number of such regressions.
+func (r *Report) WriteComparison(w io.Writer, baseline *Report, threshold float64) int {
+	fmt.Fprintf(w, "Compared with %s (%s)\n", baseline.Version, baseline.Time.Format("2006-01-02 15:04"))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "case\tbaseline\tcurrent\tchange")
+	regressions := 0
+	for _, delta := range r.Compare(baseline) {
+		if !delta.Baseline {
+			fmt.Fprintf(tw, "%s\t-\t%s\tnew\n", delta.Name, formatNs(delta.Current))
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%+.1f%%", delta.Name, formatNs(delta.Base), formatNs(delta.Current), delta.Change*100)
+		if delta.Change > threshold {
+			fmt.Fprint(tw, "\tregression")
+			regressions++
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	return regressions
+}
+
+// formatNs formats a time per operation with a unit suiting its magnitude
+func formatNs(ns float64) string {
+	d := time.Duration(math.Round(ns))
+	switch {
+	case d < time.Microsecond:
+		return fmt.Sprintf("%.0fns", ns)
+	case d < time.Millisecond:
+		return fmt.Sprintf("%.1fµs", ns/1e3)
+	case d < time.Second:
+		return fmt.Sprintf("%.1fms", ns/1e6)
+	}
+	return fmt.Sprintf("%.2fs", ns/1e9)
+}
+
+// randomVectors returns n normalized random vectors, the same for a seed
+func randomVectors(n, dim int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed)) // #nosec G404 - synthetic data
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vector := make([]float32, dim)
+		var magnitude float64
+		for j := range vector {
+			vector[j] = float32(rng.NormFloat64())
+			magnitude += float64(vector[j] * vector[j])
+		}
+		magnitude = math.Sqrt(magnitude)
+		for j := range vector {
+			vector[j] /= float32(magnitude)
+		}
+		vectors[i] = vector
+	}
+	return vectors
+}
+
+// syntheticSource returns a Go source file of about the given number of
+// lines, made of documented functions of 15 lines
+func syntheticSource(lines int) string {
+	var sb strings.Builder
+	sb.WriteString("package synthetic\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n")
+	for i := 0; i*15 < lines; i++ {
+		fmt.Fprintf(&sb, `
+// Handler%[1]d joins the names of the items of batch %[1]d, skipping empty ones
+func Handler%[1]d(items []string) (string, error) {
+	var names []string
+	for _, item := range items {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		names = append(names, fmt.Sprintf("%%d:%%s", %[1]d, item))
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("batch %[1]d has no items")
+	}
+	return strings.Join(names, ","), nil
+}
+`, i)
+	}
+	return sb.String()
+}
+
+// syntheticHistory returns the messages of a run with the given number of
+// turns, each reading a file and answering about it
+func syntheticHistory(turns int) []orchestrator.Message {
+	messages := []orchestrator.Message{
+		{Role: "system", Content: "You are a coding agent. Use the tools to read and change the workspace."},
+		{Role: "user", Content: "Find why the retry handler drops requests and fix it."},
+	}
+	files := []string{"internal/retry/handler.go", "internal/retry/backoff.go", "cmd/serve.go", "internal/queue/queue.go"}
+	content := strings.Repeat("func retry(ctx context.Context, attempt int) error { return nil }\n", 20)
+	for i := 0; i < turns; i++ {
+		file := files[i%len(files)]
+		id := fmt.Sprintf("call_%d", i)
+		switch i % 3 {
+		case 0, 1:
+			messages = append(messages,
+				orchestrator.Message{Role: "assistant", ToolCall: &llm.FunctionCall{ID: id, Name: "read_file", Arguments: json.RawMessage(fmt.Sprintf(`{"file_path":%q}`, file))}},
+				orchestrator.Message{Role: "tool", ToolCallID: id, Name: "read_file", Content: content})
+		default:
+			messages = append(messages, orchestrator.Message{Role: "assistant", Content: fmt.Sprintf("The backoff in %s resets the attempt counter on every call, so requests past the third retry are dropped.", file)})
+		}
+	}
+	return messages
+}
@@ -0,0 +1,97 @@
+package bench
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/orchestrator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// BenchmarkSuite runs the suite with go test, e.g.
+// go test ./internal/bench -bench 'Suite/Search' -short
+func BenchmarkSuite(b *testing.B) {
+	cases, err := Select(Cases(), "", testing.Short())
+	require.NoError(b, err)
+	for _, c := range cases {
+		b.Run(c.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			c.Run(b)
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	cases := Cases()
+	short, err := Select(cases, "", true)
+	require.NoError(t, err)
+	assert.Less(t, len(short), len(cases))
+	for _, c := range short {
+		assert.NotContains(t, c.Name, "50k")
+	}
+
+	search, err := Select(cases, "^Search/hnsw/", false)
+	require.NoError(t, err)
+	var names []string
+	for _, c := range search {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"Search/hnsw/1k", "Search/hnsw/10k", "Search/hnsw/50k"}, names)
+
+	_, err = Select(cases, "(", false)
+	assert.ErrorContains(t, err, "invalid benchmark pattern")
+}
+
+func TestAssemblePromptOfSyntheticHistory(t *testing.T) {
+	messages := syntheticHistory(300)
+	prompts := map[string]string{}
+	for _, strategy := range []string{orchestrator.HistoryFull, orchestrator.HistoryWindow} {
+		runner := orchestrator.NewAgentRunner(nil, nil, "", "")
+		runner.SetHistory(config.HistoryConfig{Strategy: strategy})
+		systemPrompt, prompt := runner.AssemblePrompt(context.Background(), messages)
+		assert.Equal(t, messages[0].Content, systemPrompt)
+		assert.True(t, strings.HasPrefix(prompt, "User: "+messages[1].Content), "the task is always sent")
+		prompts[strategy] = prompt
+	}
+	assert.Less(t, len(prompts[orchestrator.HistoryWindow]), len(prompts[orchestrator.HistoryFull])/10)
+}
+
+func TestReportCompare(t *testing.T) {
+	baseline := &Report{Version: "v0.9.0", Time: time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC), Results: []Result{
+		{Name: "Chunk/lines", NsPerOp: 200000},
+		{Name: "Search/flat/10k", NsPerOp: 1500000},
+	}}
+	report := &Report{Version: "v1.0.0", CPUs: 8, Results: []Result{
+		{Name: "Chunk/lines", Iterations: 6000, NsPerOp: 150000, MBPerSec: 312.5, BytesPerOp: 90112, AllocsPerOp: 41},
+		{Name: "Search/flat/10k", Iterations: 700, NsPerOp: 1800000},
+		{Name: "Prompt/full/100turns", Iterations: 9000, NsPerOp: 900},
+	}}
+
+	deltas := report.Compare(baseline)
+	require.Len(t, deltas, 3)
+	assert.InDelta(t, -0.25, deltas[0].Change, 1e-9)
+	assert.InDelta(t, 0.2, deltas[1].Change, 1e-9)
+	assert.False(t, deltas[2].Baseline)
+
+	var table strings.Builder
+	assert.Equal(t, 1, report.WriteComparison(&table, baseline, 0.1))
+	assert.Contains(t, table.String(), "Compared with v0.9.0 (2026-09-01 12:00)")
+	assert.Regexp(t, `Chunk/lines\s+200.0µs\s+150.0µs\s+-25.0%`, table.String())
+	assert.Regexp(t, `Search/flat/10k\s+1.5ms\s+1.8ms\s+\+20.0%\s+regression`, table.String())
+	assert.Regexp(t, `Prompt/full/100turns\s+-\s+900ns\s+new`, table.String())
+
+	var line strings.Builder
+	report.WriteResult(&line, report.Results[0])
+	assert.Equal(t, "BenchmarkChunk/lines-8\t6000\t150000.0 ns/op\t312.50 MB/s\t90112 B/op\t41 allocs/op\n", line.String())
+
+	path := filepath.Join(t.TempDir(), "bench.json")
+	require.NoError(t, report.Save(path))
+	loaded, err := LoadReport(path)
+	require.NoError(t, err)
+	assert.Equal(t, report.Results, loaded.Results)
+}
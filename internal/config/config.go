@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,7 +12,18 @@ import (
 	"time"
 
 	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/detect"
+	"github.com/castrovroberto/CGE/internal/hooks"
+	"github.com/castrovroberto/CGE/internal/httpclient"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/notify"
+	"github.com/castrovroberto/CGE/internal/policy"
+	"github.com/castrovroberto/CGE/internal/redact"
 	"github.com/castrovroberto/CGE/internal/security"
+	"github.com/castrovroberto/CGE/internal/statecrypt"
+	"github.com/castrovroberto/CGE/internal/statedir"
+	"github.com/castrovroberto/CGE/internal/teamsync"
+	"github.com/castrovroberto/CGE/internal/vectorstore"
 	"github.com/spf13/viper"
 )
 
@@ -25,13 +37,38 @@ type AppConfig struct {
 		RequestTimeoutSeconds time.Duration `mapstructure:"request_timeout_seconds"`
 		OllamaHostURL         string        `mapstructure:"ollama_host_url"`        // Specific to Ollama, might be refactored
 		OllamaKeepAlive       string        `mapstructure:"ollama_keep_alive"`      // Specific to Ollama
+		OllamaContextReuse    bool          `mapstructure:"ollama_context_reuse"`   // Send the context of a conversation back instead of its whole prompt
 		OpenAIAPIKey          string        `mapstructure:"openai_api_key"`         // Loaded from env typically
 		GeminiAPIKey          string        `mapstructure:"gemini_api_key"`         // Loaded from env typically
 		GeminiTemperature     float64       `mapstructure:"gemini_temperature"`     // Gemini-specific temperature
 		MaxTokensPerRequest   int           `mapstructure:"max_tokens_per_request"` // New
 		RequestsPerMinute     int           `mapstructure:"requests_per_minute"`    // New
+
+		// Caps on LLM requests in flight; 0 means no limit
+		MaxConcurrentRequests int            `mapstructure:"max_concurrent_requests"` // Per session
+		ProviderConcurrency   map[string]int `mapstructure:"provider_concurrency"`    // Per provider across the process, e.g. ollama = 2
+
+		// ModelCapabilities declares models the built-in registry does not
+		// know, or corrects it
+		ModelCapabilities []ModelCapabilities `mapstructure:"model_capabilities"`
+
+		Routing RoutingConfig `mapstructure:"routing"`
 	} `mapstructure:"llm"`
 
+	// HTTP configures the connection pool shared by the LLM clients
+	HTTP struct {
+		Proxy               string        `mapstructure:"proxy"`                   // Proxy URL; empty uses HTTPS_PROXY/HTTP_PROXY/NO_PROXY
+		CACertFile          string        `mapstructure:"ca_cert_file"`            // Extra trusted CAs, e.g. for a TLS-inspecting proxy
+		InsecureSkipVerify  bool          `mapstructure:"insecure_skip_verify"`    // Disable certificate verification; testing only
+		MaxIdleConns        int           `mapstructure:"max_idle_conns"`          // Idle connections kept across all hosts
+		MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"` // Idle connections kept per host
+		MaxConnsPerHost     int           `mapstructure:"max_conns_per_host"`      // Concurrent connections per host; 0 is unlimited
+		IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout"`
+		DebugLog            bool          `mapstructure:"debug_log"`          // Log LLM HTTP traffic, with secrets redacted; toggle in chat with /debug llm
+		DebugLogFile        string        `mapstructure:"debug_log_file"`     // Where the HTTP debug log is written
+		DebugLogMaxBody     int           `mapstructure:"debug_log_max_body"` // Bytes of each body logged; 0 logs headers only
+	} `mapstructure:"http"`
+
 	KGM struct {
 		Enabled        bool   `mapstructure:"enabled"`
 		Address        string `mapstructure:"address"`
@@ -42,11 +79,16 @@ type AppConfig struct {
 		DefaultIgnoreDirs       []string `mapstructure:"default_ignore_dirs"`
 		DefaultSourceExtensions []string `mapstructure:"default_source_extensions"`
 		WorkspaceRoot           string   `mapstructure:"workspace_root"`
+		AutoDetect              bool     `mapstructure:"auto_detect"` // Infer the languages and commands of the workspace
+		Scope                   string   `mapstructure:"scope"`       // Subdirectory of the workspace runs are restricted to
 	} `mapstructure:"project"`
 
 	Logging struct {
-		Level   string `mapstructure:"level"`
-		LogFile string `mapstructure:"log_file"`
+		Level      string            `mapstructure:"level"`
+		LogFile    string            `mapstructure:"log_file"`
+		Components map[string]string `mapstructure:"components"`  // Level of each subsystem: llm, orchestrator, tools, tui, context
+		MaxSizeMB  int               `mapstructure:"max_size_mb"` // Size the log file is rotated at, 0 never rotates it
+		MaxFiles   int               `mapstructure:"max_files"`   // Rotated log files kept
 	} `mapstructure:"logging"`
 
 	Budget struct {
@@ -55,14 +97,36 @@ type AppConfig struct {
 
 	Commands struct {
 		Review struct {
-			TestCommand string `mapstructure:"test_command"`
-			LintCommand string `mapstructure:"lint_command"`
-			MaxCycles   int    `mapstructure:"max_cycles"`
+			TestCommand    string `mapstructure:"test_command"`
+			LintCommand    string `mapstructure:"lint_command"`
+			MaxCycles      int    `mapstructure:"max_cycles"`
+			MaxFixAttempts int    `mapstructure:"max_fix_attempts"` // Per lint finding, across review cycles
+
+			// Checkpoints where interactive runs stop for approval: "plan",
+			// "file_change", "command" and "commit", or "none"
+			Checkpoints []string `mapstructure:"checkpoints"`
+			// Approval decides checkpoints of non-interactive runs: checkpoint
+			// name to "approve" or "reject"
+			Approval map[string]string `mapstructure:"approval"`
 		} `mapstructure:"review"`
 	} `mapstructure:"commands"`
 
+	// History chooses how much of the conversation agent runs send the model
+	// at each step; [history.commands.<command>] overrides it for the runs of
+	// one command, e.g. chat, generate or review
+	History struct {
+		Strategy       string                   `mapstructure:"strategy"`
+		WindowTurns    int                      `mapstructure:"window_turns"`
+		RetrievalTurns int                      `mapstructure:"retrieval_turns"`
+		Commands       map[string]HistoryConfig `mapstructure:"commands"`
+	} `mapstructure:"history"`
+
 	// Tools configuration for enhanced tool behavior
 	Tools struct {
+		// Never-edit globs relative to the workspace root, e.g. "vendor/**";
+		// write, patch and shell tools refuse to change matching files
+		ProtectedPaths []string `mapstructure:"protected_paths"`
+
 		ListDirectory struct {
 			AllowOutsideWorkspace bool     `mapstructure:"allow_outside_workspace"`
 			AllowedRoots          []string `mapstructure:"allowed_roots"`
@@ -71,8 +135,71 @@ type AppConfig struct {
 			AutoResolveSymlinks   bool     `mapstructure:"auto_resolve_symlinks"`
 			SmartPathResolution   bool     `mapstructure:"smart_path_resolution"`
 		} `mapstructure:"list_directory"`
+		// Commit message conventions, checked before every commit
+		GitCommit struct {
+			Types               []string `mapstructure:"types"`                // Allowed conventional commit types
+			RequireConventional bool     `mapstructure:"require_conventional"` // Headers must read "type(scope): subject"
+			RequireTicket       bool     `mapstructure:"require_ticket"`       // Messages must mention a ticket ID
+			MaxHeaderLength     int      `mapstructure:"max_header_length"`    // 0 means no limit
+			TicketPattern       string   `mapstructure:"ticket_pattern"`       // Regexp for ticket IDs, e.g. PROJ-123
+			Template            string   `mapstructure:"template"`             // Message template; .gitmessage when empty
+		} `mapstructure:"git_commit"`
+		ShellCommands struct {
+			// Environment variables commands inherit and may set; a trailing *
+			// matches a prefix. Others, such as API keys, are withheld.
+			EnvAllowlist []string `mapstructure:"env_allowlist"`
+		} `mapstructure:"shell_commands"`
+		FileOperations struct {
+			// Largest existing file write_file replaces without force=true
+			MaxOverwriteBytes int64 `mapstructure:"max_overwrite_bytes"`
+		} `mapstructure:"file_operations"`
+		// Database the db_query and db_schema tools inspect; the tools are
+		// only available when driver and dsn are set
+		Database struct {
+			Driver              string   `mapstructure:"driver"`                // postgres, mysql or sqlite
+			DSN                 string   `mapstructure:"dsn"`                   // ${VAR} references are expanded, e.g. for passwords
+			MaxRows             int      `mapstructure:"max_rows"`              // Rows returned per query
+			MaxResultBytes      int      `mapstructure:"max_result_bytes"`      // Size of the rows returned per query
+			QueryTimeoutSeconds int      `mapstructure:"query_timeout_seconds"` // Per query
+			AllowWrites         bool     `mapstructure:"allow_writes"`          // Run and commit statements other than queries
+			MaskedColumns       []string `mapstructure:"masked_columns"`        // Column patterns whose values are masked
+		} `mapstructure:"database"`
+		// Size guard of the tool results added to the conversation: longer
+		// results keep their head and tail, the middle is summarized, and
+		// the full result is saved for read_tool_output
+		Output struct {
+			MaxChars  int  `mapstructure:"max_chars"`  // 0 disables the guard
+			HeadChars int  `mapstructure:"head_chars"` // Start of a truncated result that is kept
+			TailChars int  `mapstructure:"tail_chars"` // End of a truncated result that is kept
+			Summarize bool `mapstructure:"summarize"`  // Summarize the omitted middle with the model
+		} `mapstructure:"output"`
+		// Container tools docker_ps, docker_logs and compose_config; off
+		// unless enabled
+		Docker struct {
+			Enabled        bool     `mapstructure:"enabled"`
+			Command        string   `mapstructure:"command"`         // Docker CLI, e.g. podman
+			ComposeFiles   []string `mapstructure:"compose_files"`   // Relative to the workspace; compose's defaults when empty
+			ProjectName    string   `mapstructure:"project_name"`    // Compose project; derived from the directory when empty
+			TimeoutSeconds int      `mapstructure:"timeout_seconds"` // Per command
+		} `mapstructure:"docker"`
+		// run_snippet, which runs code in a sandbox during reviews; off unless
+		// enabled
+		Snippets struct {
+			Enabled bool `mapstructure:"enabled"`
+		} `mapstructure:"snippets"`
 	} `mapstructure:"tools"`
 
+	// Indexing configuration for the semantic search index
+	Indexing struct {
+		EmbedBatchSize     int    `mapstructure:"embed_batch_size"`     // Texts per embedding request (OpenAI)
+		EmbedConcurrency   int    `mapstructure:"embed_concurrency"`    // Parallel embedding requests
+		Backend            string `mapstructure:"backend"`              // "flat" (exact) or "hnsw" (approximate)
+		Quantization       string `mapstructure:"quantization"`         // "none" or "float16" (hnsw only)
+		HNSWM              int    `mapstructure:"hnsw_m"`               // Graph degree; higher = better recall, more memory
+		HNSWEfConstruction int    `mapstructure:"hnsw_ef_construction"` // Build-time beam width
+		HNSWEfSearch       int    `mapstructure:"hnsw_ef_search"`       // Query-time beam width; higher = better recall, slower
+	} `mapstructure:"indexing"`
+
 	// Deliberation configuration for advanced reasoning
 	Deliberation struct {
 		Enabled             bool    `mapstructure:"enabled"`
@@ -94,6 +221,129 @@ type AppConfig struct {
 		} `mapstructure:"safety_checks"`
 	} `mapstructure:"deliberation"`
 
+	Notifications struct {
+		Enabled     bool          `mapstructure:"enabled"`      // Notify when long runs finish
+		MinDuration time.Duration `mapstructure:"min_duration"` // Only runs at least this long trigger a notification
+		Bell        bool          `mapstructure:"bell"`         // Ring the terminal bell
+		Desktop     bool          `mapstructure:"desktop"`      // Also send an OS notification (osascript / notify-send)
+
+		// Run summaries posted to chat; off without a webhook URL
+		Slack   ChatNotificationConfig `mapstructure:"slack"`
+		Discord ChatNotificationConfig `mapstructure:"discord"`
+	} `mapstructure:"notifications"`
+
+	// Hooks are the [[hooks]] run on the lifecycle events of agent runs
+	Hooks []HookConfig `mapstructure:"hooks"`
+
+	// Policy are the organizational guardrails tool calls and commits are
+	// checked against
+	Policy struct {
+		Rules     []PolicyRuleConfig `mapstructure:"rules"`
+		Rego      []string           `mapstructure:"rego"`       // Rego files or directories, evaluated with opa
+		RegoQuery string             `mapstructure:"rego_query"` // Query yielding the reasons to deny
+		OPA       string             `mapstructure:"opa"`        // OPA executable
+	} `mapstructure:"policy"`
+
+	// State configures how `CGE state gc` prunes the .cge state directory;
+	// 0 disables a limit
+	State struct {
+		CacheMaxAgeDays   int `mapstructure:"cache_max_age_days"`
+		CacheMaxSizeMB    int `mapstructure:"cache_max_size_mb"`
+		ReportsMaxAgeDays int `mapstructure:"reports_max_age_days"`
+		BackupsMaxAgeDays int `mapstructure:"backups_max_age_days"`
+	} `mapstructure:"state"`
+
+	// Shutdown is how SIGINT and SIGTERM stop an agent run: the tool call in
+	// flight may finish for up to Grace, then the session is paused; a
+	// second signal stops at once
+	Shutdown struct {
+		Grace time.Duration `mapstructure:"grace"`
+	} `mapstructure:"shutdown"`
+
+	// Retention bounds how long sessions and chat histories are kept and
+	// what 'CGE session export' writes; 0 disables a limit
+	Retention struct {
+		SessionsMaxAgeDays    int    `mapstructure:"sessions_max_age_days"`     // Delete sessions inactive for longer
+		ChatHistoryMaxAgeDays int    `mapstructure:"chat_history_max_age_days"` // Delete chat histories older than this
+		ScrubAfterDays        int    `mapstructure:"scrub_after_days"`          // Keep only the metadata of sessions inactive for longer
+		ExportRedaction       string `mapstructure:"export_redaction"`          // "code", "metadata" or "none"
+	} `mapstructure:"retention"`
+
+	// Sandbox runs generate and review in a git worktree on a new branch, so
+	// the working tree is only changed by merging the result
+	Sandbox struct {
+		Enabled      bool   `mapstructure:"enabled"`       // Sandbox every run (or --sandbox)
+		BranchPrefix string `mapstructure:"branch_prefix"` // Prefix of sandbox branches
+		OnSuccess    string `mapstructure:"on_success"`    // "prompt", "merge", "squash" or "keep"
+	} `mapstructure:"sandbox"`
+
+	// Encryption encrypts sessions and chat histories at rest
+	Encryption struct {
+		Enabled       bool   `mapstructure:"enabled"`
+		KeySource     string `mapstructure:"key_source"`     // "auto", "keyring" or "passphrase"
+		PassphraseEnv string `mapstructure:"passphrase_env"` // Variable holding the passphrase
+	} `mapstructure:"encryption"`
+
+	// Sync is where 'CGE sync' pulls the team's shared configuration, rules
+	// and prompt templates from when no --from is given
+	Sync struct {
+		From            string `mapstructure:"from"`             // Git URL or path, .tar.gz URL or directory
+		Ref             string `mapstructure:"ref"`              // Branch, tag or commit of a git source
+		Checksum        string `mapstructure:"checksum"`         // Digest the bundle must have
+		VerifySignature bool   `mapstructure:"verify_signature"` // Require a signed commit
+	} `mapstructure:"sync"`
+
+	Security struct {
+		// PromptInjection guards the model against instructions hidden in
+		// tool results
+		PromptInjection struct {
+			Enabled        bool     `mapstructure:"enabled"`         // Wrap untrusted results and add the system prompt rule
+			UntrustedTools []string `mapstructure:"untrusted_tools"` // Results are wrapped and cleaned of injection patterns
+			VerbatimTools  []string `mapstructure:"verbatim_tools"`  // Results are wrapped, suspected injections only flagged
+			Classifier     bool     `mapstructure:"classifier"`      // Also ask the model whether a result is an injection
+		} `mapstructure:"prompt_injection"`
+	} `mapstructure:"security"`
+
+	UI struct {
+		// Locale of TUI messages and prompt templates, e.g. "de" or "pt-BR";
+		// empty detects it from CGE_LOCALE, LC_ALL, LC_MESSAGES or LANG
+		Locale     string `mapstructure:"locale"`
+		LocalesDir string `mapstructure:"locales_dir"` // Message catalogs (<locale>.json); default ~/.cge/locales
+
+		// Accessible writes linear plain text for screen readers: no spinners,
+		// progress bars, emoji or box drawing; also set by --accessible or
+		// CGE_ACCESSIBLE=1
+		Accessible bool `mapstructure:"accessible"`
+
+		Chat struct {
+			// QueueMode decides what happens to messages sent while the agent
+			// is busy: "after_run" sends them when the run finishes, "steer"
+			// injects them into the running conversation
+			QueueMode string `mapstructure:"queue_mode"`
+
+			// Snippets are prompt templates typed as #name in the input, with
+			// {{placeholder}} values given after the name; they replace the
+			// built-in #explain, #refactor and #tests of the same name
+			Snippets map[string]string `mapstructure:"snippets"`
+
+			// Sidebar shows the sidebar of tasks, changed files and running
+			// tools when the chat starts; it is toggled with ctrl+b
+			Sidebar bool `mapstructure:"sidebar"`
+
+			// IdleTimeout is how long the chat waits without input before it
+			// saves the history, dims the status bar and, with UnloadOnIdle,
+			// unloads the model; 0 disables it
+			IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+
+			// UnloadOnIdle unloads the Ollama model when the chat goes idle,
+			// freeing its memory, and loads it again on the next input
+			UnloadOnIdle bool `mapstructure:"unload_on_idle"`
+		} `mapstructure:"chat"`
+	} `mapstructure:"ui"`
+
+	// Keybindings remaps chat TUI actions, e.g. quit = ["ctrl+q"]; see `/keys` in chat
+	Keybindings map[string][]string `mapstructure:"keybindings"`
+
 	// Old fields - to be reviewed/migrated or removed
 	ChatSystemPromptFile          string        `mapstructure:"chat_system_prompt_file"`
 	MaxAgentConcurrency           int           `mapstructure:"max_agent_concurrency"`
@@ -113,9 +363,48 @@ func (ac *AppConfig) GetLoadedChatSystemPrompt() string {
 type OllamaConfig struct {
 	HostURL           string        `json:"host_url"`
 	KeepAlive         string        `json:"keep_alive"`
+	ContextReuse      bool          `json:"context_reuse"` // Send the context returned for a prompt back with the next one continuing it
 	RequestTimeout    time.Duration `json:"request_timeout"`
 	MaxTokens         int           `json:"max_tokens"`
 	RequestsPerMinute int           `json:"requests_per_minute"`
+	HTTPClient        *http.Client  `json:"-"` // Shared client; nil uses httpclient.Default()
+}
+
+// ConcurrencyConfig caps the requests a client has in flight; 0 means no limit
+type ConcurrencyConfig struct {
+	Provider    string
+	ProviderMax int // Across all clients of the provider in the process
+	SessionMax  int // Across the requests of one client
+}
+
+// RoutingConfig tries simple agent steps, such as short questions before any
+// tool has run, on a cheaper model first. Steps it fails, answers with a tool
+// call or sounds unsure about are escalated to the configured model.
+type RoutingConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	SmallModel     string `mapstructure:"small_model"`      // Cheap or fast model of the same provider
+	MaxPromptChars int    `mapstructure:"max_prompt_chars"` // Longest user message considered simple
+}
+
+// HistoryConfig is the history strategy of agent runs: "full" sends the whole
+// conversation, "window" the task and the latest turns, "summary_window" also
+// a summary of the turns left out, and "retrieval" also the earlier turns most
+// relevant to the task. A turn is a user message or a model step with its
+// tool results.
+type HistoryConfig struct {
+	Strategy       string `mapstructure:"strategy"`
+	WindowTurns    int    `mapstructure:"window_turns"`    // Latest turns kept by the windowed strategies
+	RetrievalTurns int    `mapstructure:"retrieval_turns"` // Earlier turns retrieval brings back
+}
+
+// ModelCapabilities declares what the models matching Pattern can do.
+// Pattern is "provider/model" with shell wildcards, e.g. "ollama/my-coder*".
+type ModelCapabilities struct {
+	Pattern         string `mapstructure:"pattern"`
+	ContextWindow   int    `mapstructure:"context_window"`   // Tokens
+	FunctionCalling bool   `mapstructure:"function_calling"` // Native tool calls
+	Vision          bool   `mapstructure:"vision"`           // Image input
+	Embeddings      bool   `mapstructure:"embeddings"`       // The provider can embed text
 }
 
 // OpenAIConfig holds configuration specific to OpenAI LLM client
@@ -125,6 +414,7 @@ type OpenAIConfig struct {
 	RequestTimeout    time.Duration `json:"request_timeout"`
 	MaxTokens         int           `json:"max_tokens"`
 	RequestsPerMinute int           `json:"requests_per_minute"`
+	HTTPClient        *http.Client  `json:"-"` // Shared client; nil uses httpclient.Default()
 }
 
 // GeminiConfig holds configuration specific to Google Gemini LLM client
@@ -144,11 +434,113 @@ type IntegratorConfig struct {
 
 // Convenience methods to extract sub-configs from AppConfig
 
+// GetEncryptionOptions extracts the options of encryption at rest
+func (ac *AppConfig) GetEncryptionOptions() statecrypt.Options {
+	return statecrypt.Options{
+		Enabled:       ac.Encryption.Enabled,
+		KeySource:     ac.Encryption.KeySource,
+		PassphraseEnv: ac.Encryption.PassphraseEnv,
+	}
+}
+
+// GetHTTPOptions returns the options of the shared HTTP client
+func (ac *AppConfig) GetHTTPOptions() httpclient.Options {
+	opts := httpclient.DefaultOptions()
+	opts.Proxy = ac.HTTP.Proxy
+	opts.CACertFile = ac.HTTP.CACertFile
+	opts.InsecureSkipVerify = ac.HTTP.InsecureSkipVerify
+	if ac.HTTP.MaxIdleConns > 0 {
+		opts.MaxIdleConns = ac.HTTP.MaxIdleConns
+	}
+	if ac.HTTP.MaxIdleConnsPerHost > 0 {
+		opts.MaxIdleConnsPerHost = ac.HTTP.MaxIdleConnsPerHost
+	}
+	opts.MaxConnsPerHost = ac.HTTP.MaxConnsPerHost
+	if ac.HTTP.IdleConnTimeout > 0 {
+		opts.IdleConnTimeout = ac.HTTP.IdleConnTimeout
+	}
+	return opts
+}
+
+// GetLogRotation returns when the log file is rotated
+func (ac *AppConfig) GetLogRotation() logger.Rotation {
+	return logger.Rotation{MaxSizeMB: ac.Logging.MaxSizeMB, MaxFiles: ac.Logging.MaxFiles}
+}
+
+// GetStatePolicies returns the pruning policy of each state subdirectory
+// `CGE state gc` prunes
+func (ac *AppConfig) GetStatePolicies() map[string]statedir.Policy {
+	const day = 24 * time.Hour
+	return map[string]statedir.Policy{
+		statedir.Cache: {
+			MaxAge:  time.Duration(ac.State.CacheMaxAgeDays) * day,
+			MaxSize: int64(ac.State.CacheMaxSizeMB) << 20,
+		},
+		statedir.Reports: {MaxAge: time.Duration(ac.State.ReportsMaxAgeDays) * day},
+		statedir.Backups: {MaxAge: time.Duration(ac.State.BackupsMaxAgeDays) * day},
+	}
+}
+
+// GetRetention returns how long sessions and chat histories are kept
+func (ac *AppConfig) GetRetention() statedir.Retention {
+	const day = 24 * time.Hour
+	return statedir.Retention{
+		SessionsMaxAge:    time.Duration(ac.Retention.SessionsMaxAgeDays) * day,
+		ChatHistoryMaxAge: time.Duration(ac.Retention.ChatHistoryMaxAgeDays) * day,
+		ScrubAfter:        time.Duration(ac.Retention.ScrubAfterDays) * day,
+	}
+}
+
+// ScopeRoot returns the absolute directory runs are restricted to: the
+// project scope, a subdirectory of the workspace root, or the workspace root
+// itself when no scope is set
+func (ac *AppConfig) ScopeRoot() (string, error) {
+	workspaceRoot := ac.Project.WorkspaceRoot
+	if workspaceRoot == "" {
+		workspaceRoot = "."
+	}
+	absRoot, err := filepath.Abs(workspaceRoot)
+	if err != nil {
+		return "", err
+	}
+	if ac.Project.Scope == "" {
+		return absRoot, nil
+	}
+
+	scope := security.NormalizePath(ac.Project.Scope)
+	if !filepath.IsAbs(scope) {
+		scope = filepath.Join(absRoot, scope)
+	}
+	if !security.IsWithin(absRoot, scope) {
+		return "", fmt.Errorf("scope %s is outside the workspace %s", ac.Project.Scope, absRoot)
+	}
+	if info, err := os.Stat(scope); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("scope %s is not a directory of the workspace", ac.Project.Scope)
+	}
+	return scope, nil
+}
+
+// GetLocalesDir returns the directory of the message catalogs
+func (ac *AppConfig) GetLocalesDir() string {
+	if ac.UI.LocalesDir != "" {
+		if dir, err := security.ExpandHome(ac.UI.LocalesDir); err == nil {
+			return dir
+		}
+		return ac.UI.LocalesDir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "locales"
+	}
+	return filepath.Join(home, ".cge", "locales")
+}
+
 // GetOllamaConfig extracts Ollama-specific configuration
 func (ac *AppConfig) GetOllamaConfig() OllamaConfig {
 	return OllamaConfig{
 		HostURL:           ac.LLM.OllamaHostURL,
 		KeepAlive:         ac.LLM.OllamaKeepAlive,
+		ContextReuse:      ac.LLM.OllamaContextReuse,
 		RequestTimeout:    ac.LLM.RequestTimeoutSeconds,
 		MaxTokens:         ac.LLM.MaxTokensPerRequest,
 		RequestsPerMinute: ac.LLM.RequestsPerMinute,
@@ -177,6 +569,16 @@ func (ac *AppConfig) GetGeminiConfig() GeminiConfig {
 	}
 }
 
+// GetConcurrencyConfig extracts the request concurrency limits of the
+// configured provider
+func (ac *AppConfig) GetConcurrencyConfig() ConcurrencyConfig {
+	return ConcurrencyConfig{
+		Provider:    ac.LLM.Provider,
+		ProviderMax: ac.LLM.ProviderConcurrency[strings.ToLower(ac.LLM.Provider)],
+		SessionMax:  ac.LLM.MaxConcurrentRequests,
+	}
+}
+
 // GetIntegratorConfig extracts command integrator configuration
 func (ac *AppConfig) GetIntegratorConfig() IntegratorConfig {
 	return IntegratorConfig{
@@ -200,12 +602,233 @@ func (ac *AppConfig) GetListDirectoryConfig() agent.ListDirToolConfig {
 // GetToolFactoryConfig extracts complete tool factory configuration
 func (ac *AppConfig) GetToolFactoryConfig() agent.ToolFactoryConfig {
 	listDirConfig := ac.GetListDirectoryConfig()
+	coverageConfig := agent.CoverageToolConfig{
+		TestCommand: ac.Commands.Review.TestCommand,
+	}
+	commitConventions := ac.GetCommitConventions()
+	shellRunConfig := agent.ShellRunToolConfig{
+		EnvAllowlist: ac.Tools.ShellCommands.EnvAllowlist,
+	}
+	fileConfig := agent.FileToolConfig{
+		MaxOverwriteBytes: ac.Tools.FileOperations.MaxOverwriteBytes,
+	}
+	databaseConfig := ac.GetDatabaseToolConfig()
+	dockerConfig := ac.GetDockerToolConfig()
+	snippetConfig := ac.GetSnippetToolConfig()
 	return agent.ToolFactoryConfig{
 		ListDirectory: &listDirConfig,
+		Coverage:      &coverageConfig,
+		Commit:        &commitConventions,
+		ShellRun:      &shellRunConfig,
+		Files:         &fileConfig,
+		Database:      &databaseConfig,
+		Docker:        &dockerConfig,
+		Snippet:       &snippetConfig,
 		// Future tool configs will be added here
 	}
 }
 
+// GetDatabaseToolConfig extracts the configuration of the database tools
+func (ac *AppConfig) GetDatabaseToolConfig() agent.DatabaseToolConfig {
+	return agent.DatabaseToolConfig{
+		Driver:         ac.Tools.Database.Driver,
+		DSN:            ac.Tools.Database.DSN,
+		MaxRows:        ac.Tools.Database.MaxRows,
+		MaxResultBytes: ac.Tools.Database.MaxResultBytes,
+		QueryTimeout:   time.Duration(ac.Tools.Database.QueryTimeoutSeconds) * time.Second,
+		AllowWrites:    ac.Tools.Database.AllowWrites,
+		MaskedColumns:  ac.Tools.Database.MaskedColumns,
+	}
+}
+
+// GetDockerToolConfig extracts the configuration of the docker tools
+func (ac *AppConfig) GetDockerToolConfig() agent.DockerToolConfig {
+	return agent.DockerToolConfig{
+		Enabled:      ac.Tools.Docker.Enabled,
+		Command:      ac.Tools.Docker.Command,
+		ComposeFiles: ac.Tools.Docker.ComposeFiles,
+		ProjectName:  ac.Tools.Docker.ProjectName,
+		Timeout:      time.Duration(ac.Tools.Docker.TimeoutSeconds) * time.Second,
+	}
+}
+
+// GetSnippetToolConfig extracts the configuration of run_snippet
+func (ac *AppConfig) GetSnippetToolConfig() agent.SnippetToolConfig {
+	return agent.SnippetToolConfig{Enabled: ac.Tools.Snippets.Enabled}
+}
+
+// GetOutputLimits extracts the size guard of tool results
+func (ac *AppConfig) GetOutputLimits() agent.OutputLimits {
+	return agent.OutputLimits{
+		MaxChars:  ac.Tools.Output.MaxChars,
+		HeadChars: ac.Tools.Output.HeadChars,
+		TailChars: ac.Tools.Output.TailChars,
+		Summarize: ac.Tools.Output.Summarize,
+	}
+}
+
+// GetHistoryConfig returns the history strategy of the runs of command: the
+// [history] settings, overridden by those of [history.commands.<command>]
+func (ac *AppConfig) GetHistoryConfig(command string) HistoryConfig {
+	history := HistoryConfig{
+		Strategy:       ac.History.Strategy,
+		WindowTurns:    ac.History.WindowTurns,
+		RetrievalTurns: ac.History.RetrievalTurns,
+	}
+	if override, ok := ac.History.Commands[command]; ok {
+		if override.Strategy != "" {
+			history.Strategy = override.Strategy
+		}
+		if override.WindowTurns > 0 {
+			history.WindowTurns = override.WindowTurns
+		}
+		if override.RetrievalTurns > 0 {
+			history.RetrievalTurns = override.RetrievalTurns
+		}
+	}
+	return history
+}
+
+// ChatNotificationConfig configures the run summaries posted to a Slack or
+// Discord incoming webhook
+type ChatNotificationConfig struct {
+	WebhookURL   string `mapstructure:"webhook_url"`   // $VARIABLES are expanded, so the URL can stay out of the file
+	Template     string `mapstructure:"template"`      // text/template over the run summary; a default when empty
+	OnlyFailures bool   `mapstructure:"only_failures"` // Post only failed runs
+}
+
+// GetNotifyOptions extracts the options of completion notifications
+func (ac *AppConfig) GetNotifyOptions() notify.Options {
+	opts := notify.Options{
+		Enabled:     ac.Notifications.Enabled,
+		MinDuration: ac.Notifications.MinDuration,
+		Bell:        ac.Notifications.Bell,
+		Desktop:     ac.Notifications.Desktop,
+	}
+	kinds := []string{notify.ChatSlack, notify.ChatDiscord}
+	for i, chat := range []ChatNotificationConfig{ac.Notifications.Slack, ac.Notifications.Discord} {
+		if chat.WebhookURL == "" {
+			continue
+		}
+		opts.Chat = append(opts.Chat, notify.ChatWebhook{
+			Kind:         kinds[i],
+			URL:          chat.WebhookURL,
+			Template:     chat.Template,
+			OnlyFailures: chat.OnlyFailures,
+		})
+	}
+	return opts
+}
+
+// HookConfig is a [[hooks]] entry: a shell command or webhook run on events
+type HookConfig struct {
+	Name     string            `mapstructure:"name"`
+	Events   []string          `mapstructure:"events"`   // run_started, run_completed, pre_tool, post_tool, file_written, tests_failed
+	Command  string            `mapstructure:"command"`  // Receives the event as JSON on stdin
+	URL      string            `mapstructure:"url"`      // Receives the event as JSON in a POST request
+	Headers  map[string]string `mapstructure:"headers"`  // Webhook headers; $VARIABLES are expanded
+	Tools    []string          `mapstructure:"tools"`    // Limit tool events to these tools
+	Blocking bool              `mapstructure:"blocking"` // A failing run_started or pre_tool hook refuses the run or tool call
+	Timeout  time.Duration     `mapstructure:"timeout"`
+}
+
+// GetHooks extracts the lifecycle hooks
+func (ac *AppConfig) GetHooks() []hooks.Hook {
+	result := make([]hooks.Hook, 0, len(ac.Hooks))
+	for _, h := range ac.Hooks {
+		result = append(result, hooks.Hook{
+			Name:     h.Name,
+			Events:   h.Events,
+			Command:  h.Command,
+			URL:      h.URL,
+			Headers:  h.Headers,
+			Tools:    h.Tools,
+			Blocking: h.Blocking,
+			Timeout:  h.Timeout,
+		})
+	}
+	return result
+}
+
+// PolicyRuleConfig is a [[policy.rules]] entry
+type PolicyRuleConfig struct {
+	Name          string   `mapstructure:"name"`
+	Reason        string   `mapstructure:"reason"`
+	DenyPaths     []string `mapstructure:"deny_paths"`
+	DenyCommands  []string `mapstructure:"deny_commands"`
+	DenyTools     []string `mapstructure:"deny_tools"`
+	CommitMessage string   `mapstructure:"commit_message"`
+}
+
+// GetPolicy extracts the policy configuration
+func (ac *AppConfig) GetPolicy() policy.Config {
+	cfg := policy.Config{
+		Rego:      ac.Policy.Rego,
+		RegoQuery: ac.Policy.RegoQuery,
+		OPA:       ac.Policy.OPA,
+	}
+	for _, r := range ac.Policy.Rules {
+		cfg.Rules = append(cfg.Rules, policy.Rule{
+			Name:          r.Name,
+			Reason:        r.Reason,
+			DenyPaths:     r.DenyPaths,
+			DenyCommands:  r.DenyCommands,
+			DenyTools:     r.DenyTools,
+			CommitMessage: r.CommitMessage,
+		})
+	}
+	return cfg
+}
+
+// GetCommitConventions extracts the commit message conventions
+func (ac *AppConfig) GetCommitConventions() agent.CommitConventions {
+	return agent.CommitConventions{
+		Types:               ac.Tools.GitCommit.Types,
+		RequireConventional: ac.Tools.GitCommit.RequireConventional,
+		RequireTicket:       ac.Tools.GitCommit.RequireTicket,
+		MaxHeaderLength:     ac.Tools.GitCommit.MaxHeaderLength,
+		TicketPattern:       ac.Tools.GitCommit.TicketPattern,
+		Template:            ac.Tools.GitCommit.Template,
+	}
+}
+
+// GetInjectionPolicy extracts the prompt injection policy for tool results
+func (ac *AppConfig) GetInjectionPolicy() security.InjectionPolicy {
+	return security.InjectionPolicy{
+		Enabled:        ac.Security.PromptInjection.Enabled,
+		UntrustedTools: ac.Security.PromptInjection.UntrustedTools,
+		VerbatimTools:  ac.Security.PromptInjection.VerbatimTools,
+		Classify:       ac.Security.PromptInjection.Classifier,
+	}
+}
+
+// validateIndexing rejects vector store settings NewBackend would not
+// understand, so a typo such as "hsnw" is not silently served by flat search
+func (ac *AppConfig) validateIndexing() error {
+	if err := vectorstore.ValidateBackend(ac.GetVectorBackendConfig()); err != nil {
+		return fmt.Errorf("backend: %w", err)
+	}
+	switch ac.Indexing.Quantization {
+	case "", "none", "float16":
+		return nil
+	default:
+		return fmt.Errorf("unknown quantization %q (use \"none\" or \"float16\")", ac.Indexing.Quantization)
+	}
+}
+
+// GetVectorBackendConfig extracts the vector store backend configuration for indexing
+func (ac *AppConfig) GetVectorBackendConfig() vectorstore.BackendConfig {
+	return vectorstore.BackendConfig{
+		Type: ac.Indexing.Backend,
+		HNSW: vectorstore.HNSWConfig{
+			M:              ac.Indexing.HNSWM,
+			EfConstruction: ac.Indexing.HNSWEfConstruction,
+			EfSearch:       ac.Indexing.HNSWEfSearch,
+			Float16:        ac.Indexing.Quantization == "float16",
+		},
+	}
+}
+
 // DeliberationConfig holds deliberation-specific configuration
 type DeliberationConfig struct {
 	Enabled             bool     `json:"enabled"`
@@ -260,7 +883,12 @@ const defaultInternalSystemPrompt = "You are a helpful AI assistant."
 // resolvePath tries to resolve a path. If configFilePath is provided and path is relative,
 // it attempts to resolve relative to the config file's directory.
 // Otherwise, it tries to make it absolute based on the current working directory.
+// A leading ~ is expanded to the home directory.
 func resolvePath(path string, configFilePath string) (string, error) {
+	path, err := security.ExpandHome(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand home directory: %w", err)
+	}
 	if filepath.IsAbs(path) {
 		return path, nil
 	}
@@ -293,34 +921,136 @@ func LoadConfig(cfgFile string) error {
 		viper.SetDefault("llm.request_timeout_seconds", "300s")
 		viper.SetDefault("llm.ollama_host_url", "http://localhost:11434")
 		viper.SetDefault("llm.ollama_keep_alive", "5m")
+		viper.SetDefault("llm.ollama_context_reuse", false)
 		viper.SetDefault("llm.gemini_temperature", 0.7)      // Default temperature for Gemini
 		viper.SetDefault("llm.max_tokens_per_request", 4096) // Default based on common models
 		viper.SetDefault("llm.requests_per_minute", 20)      // Default sensible RPM
+		viper.SetDefault("llm.max_concurrent_requests", 0)
+		viper.SetDefault("llm.routing.enabled", false)
+		viper.SetDefault("llm.routing.max_prompt_chars", 500)
+
+		viper.SetDefault("history.strategy", "full")
+		viper.SetDefault("history.window_turns", 8)
+		viper.SetDefault("history.retrieval_turns", 3)
+
+		viper.SetDefault("http.proxy", "")
+		viper.SetDefault("http.ca_cert_file", "")
+		viper.SetDefault("http.insecure_skip_verify", false)
+		viper.SetDefault("http.max_idle_conns", 100)
+		viper.SetDefault("http.max_idle_conns_per_host", 16)
+		viper.SetDefault("http.max_conns_per_host", 0)
+		viper.SetDefault("http.idle_conn_timeout", "90s")
+		viper.SetDefault("http.debug_log", false)
+		viper.SetDefault("http.debug_log_file", httpclient.DefaultDebugLogFile)
+		viper.SetDefault("http.debug_log_max_body", httpclient.DefaultDebugLogMaxBody)
 
 		viper.SetDefault("kgm.enabled", false)
 		viper.SetDefault("kgm.address", "http://localhost:7474") // Example Neo4j
 		viper.SetDefault("kgm.graphiti_api_url", "http://localhost:8000/api")
 
 		viper.SetDefault("project.workspace_root", ".")
+		viper.SetDefault("project.auto_detect", true)
+		viper.SetDefault("project.scope", "")
 		viper.SetDefault("project.default_ignore_dirs", []string{".git", ".idea", "node_modules", "vendor", "target", "dist", "build", "__pycache__", "*.pyc", "*.DS_Store"})
 		viper.SetDefault("project.default_source_extensions", []string{".go", ".py", ".js", ".ts", ".java", ".md", ".rs", ".cpp", ".c", ".h", ".hpp", ".json", ".toml", ".yaml", ".yml"})
 
 		viper.SetDefault("logging.level", "info")
 		viper.SetDefault("logging.log_file", "cge.log") // Default log file
+		viper.SetDefault("logging.max_size_mb", 10)
+		viper.SetDefault("logging.max_files", 5)
 
 		viper.SetDefault("budget.run_budget_usd", 0.0) // No budget by default
 
 		viper.SetDefault("commands.review.test_command", "")
 		viper.SetDefault("commands.review.lint_command", "")
 		viper.SetDefault("commands.review.max_cycles", 3)
+		viper.SetDefault("commands.review.max_fix_attempts", 2)
+		viper.SetDefault("commands.review.checkpoints", []string{"plan", "file_change", "command", "commit"})
+		viper.SetDefault("commands.review.approval", map[string]string{
+			"plan":        "approve",
+			"file_change": "approve",
+			"commit":      "approve",
+		})
 
 		// Tools configuration defaults
+		viper.SetDefault("tools.protected_paths", []string{})
 		viper.SetDefault("tools.list_directory.allow_outside_workspace", false)
 		viper.SetDefault("tools.list_directory.allowed_roots", []string{})
 		viper.SetDefault("tools.list_directory.max_depth_limit", 10)
 		viper.SetDefault("tools.list_directory.max_files_limit", 1000)
 		viper.SetDefault("tools.list_directory.auto_resolve_symlinks", false)
 		viper.SetDefault("tools.list_directory.smart_path_resolution", true)
+		commitDefaults := agent.DefaultCommitConventions()
+		viper.SetDefault("tools.git_commit.types", commitDefaults.Types)
+		viper.SetDefault("tools.git_commit.require_conventional", false)
+		viper.SetDefault("tools.git_commit.require_ticket", false)
+		viper.SetDefault("tools.git_commit.max_header_length", commitDefaults.MaxHeaderLength)
+		viper.SetDefault("tools.git_commit.ticket_pattern", agent.DefaultTicketPattern)
+		viper.SetDefault("tools.git_commit.template", "")
+		viper.SetDefault("tools.shell_commands.env_allowlist", agent.DefaultShellEnvAllowlist)
+		viper.SetDefault("tools.file_operations.max_overwrite_bytes", agent.DefaultMaxOverwriteBytes)
+		viper.SetDefault("tools.database.max_rows", agent.DefaultDatabaseMaxRows)
+		viper.SetDefault("tools.database.max_result_bytes", agent.DefaultDatabaseMaxResultBytes)
+		viper.SetDefault("tools.database.query_timeout_seconds", int(agent.DefaultDatabaseQueryTimeout/time.Second))
+		viper.SetDefault("tools.database.allow_writes", false)
+		viper.SetDefault("tools.database.masked_columns", agent.DefaultMaskedColumns)
+		defaultOutput := agent.DefaultOutputLimits()
+		viper.SetDefault("tools.output.max_chars", defaultOutput.MaxChars)
+		viper.SetDefault("tools.output.head_chars", defaultOutput.HeadChars)
+		viper.SetDefault("tools.output.tail_chars", defaultOutput.TailChars)
+		viper.SetDefault("tools.output.summarize", defaultOutput.Summarize)
+		viper.SetDefault("tools.docker.enabled", false)
+		viper.SetDefault("tools.docker.command", agent.DefaultDockerCommand)
+		viper.SetDefault("tools.docker.compose_files", []string{})
+		viper.SetDefault("tools.docker.timeout_seconds", int(agent.DefaultDockerTimeout/time.Second))
+		viper.SetDefault("tools.snippets.enabled", false)
+
+		// Indexing defaults
+		viper.SetDefault("indexing.embed_batch_size", 32)
+		viper.SetDefault("indexing.embed_concurrency", 4)
+		viper.SetDefault("indexing.backend", "flat")
+		viper.SetDefault("indexing.quantization", "none")
+		viper.SetDefault("indexing.hnsw_m", 16)
+		viper.SetDefault("indexing.hnsw_ef_construction", 200)
+		viper.SetDefault("indexing.hnsw_ef_search", 64)
+
+		viper.SetDefault("state.cache_max_age_days", 30)
+		viper.SetDefault("state.cache_max_size_mb", 512)
+		viper.SetDefault("state.reports_max_age_days", 30)
+		viper.SetDefault("state.backups_max_age_days", 14)
+		viper.SetDefault("shutdown.grace", "30s")
+		viper.SetDefault("retention.sessions_max_age_days", 0)
+		viper.SetDefault("retention.chat_history_max_age_days", 0)
+		viper.SetDefault("retention.scrub_after_days", 0)
+		viper.SetDefault("retention.export_redaction", redact.LevelCode)
+		viper.SetDefault("policy.rego_query", policy.DefaultRegoQuery)
+		viper.SetDefault("policy.opa", "opa")
+		viper.SetDefault("encryption.enabled", false)
+		viper.SetDefault("encryption.key_source", statecrypt.KeySourceAuto)
+		viper.SetDefault("encryption.passphrase_env", statecrypt.DefaultPassphraseEnv)
+		viper.SetDefault("sandbox.enabled", false)
+		viper.SetDefault("sandbox.branch_prefix", "cge/")
+		viper.SetDefault("sandbox.on_success", "prompt")
+
+		injection := security.DefaultInjectionPolicy()
+		viper.SetDefault("security.prompt_injection.enabled", injection.Enabled)
+		viper.SetDefault("security.prompt_injection.untrusted_tools", injection.UntrustedTools)
+		viper.SetDefault("security.prompt_injection.verbatim_tools", injection.VerbatimTools)
+		viper.SetDefault("security.prompt_injection.classifier", injection.Classify)
+
+		// Notification defaults
+		viper.SetDefault("notifications.enabled", true)
+		viper.SetDefault("notifications.min_duration", "60s")
+		viper.SetDefault("notifications.bell", true)
+		viper.SetDefault("notifications.desktop", false)
+		viper.SetDefault("notifications.slack.webhook_url", "")
+		viper.SetDefault("notifications.slack.only_failures", false)
+		viper.SetDefault("notifications.discord.webhook_url", "")
+		viper.SetDefault("notifications.discord.only_failures", false)
+		viper.SetDefault("ui.accessible", false)
+		viper.SetDefault("ui.chat.queue_mode", "after_run")
+		viper.SetDefault("ui.chat.idle_timeout", "15m")
+		viper.SetDefault("ui.chat.unload_on_idle", true)
 
 		// Defaults for old fields (to be reviewed)
 		viper.SetDefault("chat_system_prompt_file", "")
@@ -360,6 +1090,7 @@ func LoadConfig(cfgFile string) error {
 		_ = viper.BindEnv("llm.gemini_api_key", "GEMINI_API_KEY")
 
 		// Attempt to read the configuration file.
+		localFile := ""
 		if err := viper.ReadInConfig(); err != nil {
 			var v ViperConfigFileNotFoundError // Alias for type assertion
 			if errors.As(err, &v) {
@@ -379,7 +1110,12 @@ func LoadConfig(cfgFile string) error {
 				return
 			}
 		} else {
-			log.Printf("Using configuration file: %s", viper.ConfigFileUsed())
+			localFile = viper.ConfigFileUsed()
+			log.Printf("Using configuration file: %s", localFile)
+		}
+		if err := layerSharedConfig(localFile); err != nil {
+			loadErr = err
+			return
 		}
 
 		// Unmarshal the config into the Cfg struct.
@@ -387,6 +1123,30 @@ func LoadConfig(cfgFile string) error {
 			loadErr = fmt.Errorf("%w: %v", ErrConfigUnmarshal, err)
 			return
 		}
+		if err := hooks.Validate(Cfg.GetHooks()); err != nil {
+			loadErr = fmt.Errorf("invalid [[hooks]]: %w", err)
+			return
+		}
+		if err := policy.Validate(Cfg.GetPolicy()); err != nil {
+			loadErr = fmt.Errorf("invalid [policy]: %w", err)
+			return
+		}
+		if output := Cfg.Tools.Output; output.MaxChars > 0 && (output.HeadChars < 0 || output.TailChars < 0 || output.HeadChars+output.TailChars >= output.MaxChars) {
+			loadErr = fmt.Errorf("invalid [tools.output]: head_chars + tail_chars must be below max_chars (%d)", output.MaxChars)
+			return
+		}
+		if err := redact.Validate(Cfg.Retention.ExportRedaction); err != nil {
+			loadErr = fmt.Errorf("invalid [retention] export_redaction: %w", err)
+			return
+		}
+		if err := notify.ValidateChat(Cfg.GetNotifyOptions().Chat); err != nil {
+			loadErr = fmt.Errorf("invalid [notifications]: %w", err)
+			return
+		}
+		if err := Cfg.validateIndexing(); err != nil {
+			loadErr = fmt.Errorf("invalid [indexing]: %w", err)
+			return
+		}
 
 		// Load chat system prompt from file if specified
 		if Cfg.ChatSystemPromptFile != "" {
@@ -439,16 +1199,67 @@ func LoadConfig(cfgFile string) error {
 			log.Printf("Warning: invalid log_level '%s', setting to default (info)", Cfg.Logging.Level)
 			Cfg.Logging.Level = "info"
 		}
+		for subsystem, level := range Cfg.Logging.Components {
+			if !isValidLogLevel(level) {
+				log.Printf("Warning: invalid level '%s' for logging.components.%s, using logging.level", level, subsystem)
+				delete(Cfg.Logging.Components, subsystem)
+			}
+		}
 
 		// Validate LLM request timeout
 		if Cfg.LLM.RequestTimeoutSeconds <= 0 {
 			log.Printf("Warning: llm.request_timeout_seconds must be positive, setting to default (300s)")
 			Cfg.LLM.RequestTimeoutSeconds = 300 * time.Second
 		}
+
+		if Cfg.Project.AutoDetect {
+			Cfg.applyDetectedDefaults()
+		}
 	})
 	return loadErr
 }
 
+// layerSharedConfig reads the team configuration installed by 'CGE sync' in
+// the workspace and merges the local configuration file, if one was read,
+// over it, so local settings override shared ones
+func layerSharedConfig(localFile string) error {
+	workspaceRoot := viper.GetString("project.workspace_root")
+	if workspaceRoot == "" {
+		workspaceRoot = "."
+	}
+	shared := teamsync.ConfigPath(workspaceRoot)
+	if _, err := os.Stat(shared); err != nil {
+		return nil
+	}
+	viper.SetConfigFile(shared)
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading shared config file '%s': %w", shared, err)
+	}
+	log.Printf("Using shared configuration file: %s", shared)
+	if localFile == "" {
+		return nil
+	}
+	viper.SetConfigFile(localFile)
+	if err := viper.MergeInConfig(); err != nil {
+		return fmt.Errorf("error reading config file '%s': %w", localFile, err)
+	}
+	return nil
+}
+
+// applyDetectedDefaults fills the review commands left unset with the ones
+// detected in the workspace
+func (ac *AppConfig) applyDetectedDefaults() {
+	project := detect.Cached(ac.Project.WorkspaceRoot)
+	if ac.Commands.Review.TestCommand == "" && project.TestCommand != "" {
+		ac.Commands.Review.TestCommand = project.TestCommand
+		log.Printf("Using the detected test command: %s", project.TestCommand)
+	}
+	if ac.Commands.Review.LintCommand == "" && project.LintCommand != "" {
+		ac.Commands.Review.LintCommand = project.LintCommand
+		log.Printf("Using the detected lint command: %s", project.LintCommand)
+	}
+}
+
 // isValidLogLevel checks if the provided log level is valid
 func isValidLogLevel(level string) bool {
 	validLevels := map[string]bool{
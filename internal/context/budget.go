@@ -0,0 +1,178 @@
+package context
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/textutils"
+)
+
+// defaultContextWindow is assumed for models the capability registry does not
+// know
+const defaultContextWindow = 8192
+
+// contextBudgetFraction is the share of the model's window given to retrieved context,
+// leaving room for the system prompt, conversation history and the response
+const contextBudgetFraction = 0.25
+
+// minPieceTokens is the smallest truncated piece worth including; anything
+// smaller is dropped rather than shown as a meaningless fragment
+const minPieceTokens = 32
+
+// ModelContextWindow returns the context size in tokens for a model name from
+// the capability registry, falling back to a conservative default for
+// unknown models
+func ModelContextWindow(model string) int {
+	if caps, ok := llm.LookupCapabilities("", model, nil); ok && caps.ContextWindow > 0 {
+		return caps.ContextWindow
+	}
+	return defaultContextWindow
+}
+
+// DefaultContextBudget returns the token budget for retrieved context for a model
+func DefaultContextBudget(model string) int {
+	return int(float64(ModelContextWindow(model)) * contextBudgetFraction)
+}
+
+// BudgetReport describes how the context budget was spent during assembly
+type BudgetReport struct {
+	BudgetTokens    int `json:"budget_tokens"`
+	UsedTokens      int `json:"used_tokens"`
+	PiecesIncluded  int `json:"pieces_included"`
+	PiecesTruncated int `json:"pieces_truncated"`
+	PiecesDropped   int `json:"pieces_dropped"`
+}
+
+// assembleWithBudget ranks pieces by relevance and fits them into budget tokens.
+// Budget is shared in proportion to relevance; pieces that fit whole within
+// their share are kept intact and their unused share is redistributed to the
+// rest. Pieces that still don't fit are cut back to whole blocks or lines.
+// The returned pieces are in rank order with content already fitted.
+func assembleWithBudget(pieces []ContextPiece, budget int) ([]ContextPiece, *BudgetReport) {
+	report := &BudgetReport{BudgetTokens: budget}
+
+	ranked := make([]ContextPiece, len(pieces))
+	copy(ranked, pieces)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Relevance > ranked[j].Relevance
+	})
+
+	headerCost := make([]int, len(ranked))
+	cost := make([]int, len(ranked))
+	weight := make([]float64, len(ranked))
+	for i, piece := range ranked {
+		headerCost[i] = textutils.EstimateTokenCount(formatPieceHeader(i, piece))
+		cost[i] = headerCost[i] + textutils.EstimateTokenCount(piece.Content)
+		weight[i] = piece.Relevance
+		if weight[i] < 0.01 {
+			weight[i] = 0.01 // Every piece gets some share
+		}
+	}
+
+	// Water-fill: repeatedly admit whole pieces whose cost fits their
+	// proportional share until no more do
+	allot := make([]int, len(ranked))
+	whole := make([]bool, len(ranked))
+	remaining := budget
+	for {
+		totalWeight := 0.0
+		for i := range ranked {
+			if !whole[i] {
+				totalWeight += weight[i]
+			}
+		}
+		if totalWeight == 0 {
+			break
+		}
+
+		admitted := false
+		spent := 0
+		for i := range ranked {
+			if whole[i] {
+				continue
+			}
+			share := float64(remaining) * weight[i] / totalWeight
+			if float64(cost[i]) <= share {
+				whole[i] = true
+				allot[i] = cost[i]
+				spent += cost[i]
+				admitted = true
+			}
+		}
+		remaining -= spent
+		if !admitted {
+			// Whatever is left is split proportionally among pieces that must be truncated
+			for i := range ranked {
+				if !whole[i] {
+					allot[i] = int(float64(remaining) * weight[i] / totalWeight)
+				}
+			}
+			break
+		}
+	}
+
+	var fitted []ContextPiece
+	for i, piece := range ranked {
+		if whole[i] {
+			fitted = append(fitted, piece)
+			report.UsedTokens += cost[i]
+			report.PiecesIncluded++
+			continue
+		}
+
+		contentBudget := allot[i] - headerCost[i]
+		if contentBudget < minPieceTokens {
+			report.PiecesDropped++
+			continue
+		}
+
+		content := truncateToTokens(piece.Content, contentBudget)
+		if textutils.EstimateTokenCount(content) < minPieceTokens {
+			report.PiecesDropped++
+			continue
+		}
+
+		piece.Content = content + "\n... [Content truncated to fit context budget]"
+		fitted = append(fitted, piece)
+		report.UsedTokens += headerCost[i] + textutils.EstimateTokenCount(piece.Content)
+		report.PiecesIncluded++
+		report.PiecesTruncated++
+	}
+
+	return fitted, report
+}
+
+// truncateToTokens cuts content to at most maxTokens, preferring to end at a
+// blank line so functions and paragraphs stay whole, then at a line boundary
+func truncateToTokens(content string, maxTokens int) string {
+	if textutils.EstimateTokenCount(content) <= maxTokens {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	lastBlock, lastLine := 0, 0
+	size := 0
+	for i, line := range lines {
+		size += len([]rune(line)) + 1
+		if size/4 > maxTokens {
+			break
+		}
+		lastLine = i + 1
+		if strings.TrimSpace(line) == "" {
+			lastBlock = i
+		}
+	}
+
+	if lastLine == 0 {
+		// A single oversized line (e.g. minified code) can only be cut mid-line
+		return string([]rune(content)[:maxTokens*4])
+	}
+
+	// Only fall back to a mid-block cut when ending at a block would waste most of the budget
+	end := lastLine
+	if lastBlock > 0 && lastBlock*2 >= lastLine {
+		end = lastBlock
+	}
+	return strings.TrimRight(strings.Join(lines[:end], "\n"), "\n")
+}
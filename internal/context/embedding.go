@@ -0,0 +1,97 @@
+package context
+
+import (
+	"context"
+	"sync"
+
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// ProgressFunc is called as embedding work completes
+type ProgressFunc func(done, total int)
+
+// embedJob is a contiguous slice of texts embedded together
+type embedJob struct {
+	start int
+	texts []string
+}
+
+// embedTexts embeds texts using a pool of concurrency workers. Clients that
+// implement llm.BatchEmbedder receive up to batchSize texts per request; others are
+// called once per text. The result has one entry per text, nil where
+// embedding failed.
+func embedTexts(ctx context.Context, client LLMClient, texts []string, batchSize, concurrency int, progress ProgressFunc) [][]float32 {
+	results := make([][]float32, len(texts))
+	if len(texts) == 0 {
+		return results
+	}
+
+	batcher, canBatch := client.(llm.BatchEmbedder)
+	if !canBatch || batchSize < 1 {
+		batchSize = 1
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan embedJob)
+	go func() {
+		defer close(jobs)
+		for start := 0; start < len(texts); start += batchSize {
+			end := start + batchSize
+			if end > len(texts) {
+				end = len(texts)
+			}
+			select {
+			case jobs <- embedJob{start: start, texts: texts[start:end]}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		wg         sync.WaitGroup
+		progressMu sync.Mutex
+		done       int
+	)
+	report := func(n int) {
+		if progress == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		done += n
+		progress(done, len(texts))
+	}
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if canBatch && batchSize > 1 {
+					embeddings, err := batcher.EmbedBatch(ctx, job.texts)
+					if err == nil && len(embeddings) == len(job.texts) {
+						copy(results[job.start:], embeddings)
+						report(len(job.texts))
+						continue
+					}
+					// Fall back to single requests so one bad chunk does not fail the batch
+				}
+				for i, text := range job.texts {
+					if ctx.Err() != nil {
+						break
+					}
+					if embedding, err := client.Embed(ctx, text); err == nil {
+						results[job.start+i] = embedding
+					}
+				}
+				report(len(job.texts))
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
@@ -3,13 +3,20 @@ package context
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
 	"github.com/castrovroberto/CGE/internal/security"
+	"github.com/castrovroberto/CGE/internal/statedir"
 	"github.com/castrovroberto/CGE/internal/textutils"
 	"github.com/castrovroberto/CGE/internal/vectorstore"
 )
@@ -26,6 +33,7 @@ type ContextManager struct {
 	workspaceRoot string
 	gatherer      *Gatherer
 	vectorStore   *vectorstore.VectorStore
+	backendErr    error // Set when options name an unknown backend; indexing and retrieval report it
 	llmClient     LLMClient
 	modelName     string
 	chunker       *textutils.Chunker
@@ -38,9 +46,16 @@ type ContextManager struct {
 	cacheTimeout time.Duration
 
 	// Indexing state
-	indexed       bool
-	indexMutex    sync.RWMutex
-	lastIndexTime time.Time
+	indexed        bool
+	indexMutex     sync.RWMutex
+	lastIndexTime  time.Time
+	lastIndexStats *IndexStats
+	indexPath      string // Where the vector index is persisted; empty keeps it in memory
+	embeddingModel string // Model the embeddings come from; a saved index of another is discarded
+
+	embedBatchSize   int
+	embedConcurrency int
+	contextBudget    int // Tokens available for retrieved context
 }
 
 // CachedContext represents cached context information
@@ -59,6 +74,28 @@ type ContextOptions struct {
 	ChunkOverlap     int           `json:"chunk_overlap"`
 	SummaryMaxLength int           `json:"summary_max_length"`
 	VectorDimension  int           `json:"vector_dimension"`
+	IndexPath        string        `json:"index_path,omitempty"`
+	EmbeddingModel   string        `json:"embedding_model,omitempty"` // Defaults to the one the client names
+	EmbedBatchSize   int           `json:"embed_batch_size"`          // Texts per request for providers with batch embeddings
+	EmbedConcurrency int           `json:"embed_concurrency"`         // Parallel embedding requests while indexing
+	// VectorBackend selects exact (flat) or approximate (HNSW) search
+	VectorBackend vectorstore.BackendConfig `json:"vector_backend"`
+	// ContextBudgetTokens caps retrieved context; 0 derives it from the model's context window
+	ContextBudgetTokens int `json:"context_budget_tokens,omitempty"`
+}
+
+// IndexFilePath returns where `CGE index` persists the vector index for a workspace
+func IndexFilePath(workspaceRoot string) string {
+	return vectorstore.IndexFilePath(workspaceRoot)
+}
+
+// ScopedIndexFilePath returns where `CGE index --scope` persists the vector
+// index of scope, a subdirectory of the workspace, apart from the index of
+// the whole workspace. The scope is path-escaped into one directory name, so
+// "a/b" and "a_b" keep indexes of their own.
+func ScopedIndexFilePath(workspaceRoot, scope string) string {
+	name := url.PathEscape(filepath.ToSlash(filepath.Clean(scope)))
+	return statedir.Path(workspaceRoot, statedir.Index, "scopes", name, "vectors.json")
 }
 
 // DefaultContextOptions returns sensible defaults for context management
@@ -70,13 +107,19 @@ func DefaultContextOptions() ContextOptions {
 		ChunkOverlap:     15,
 		SummaryMaxLength: 500,
 		VectorDimension:  0, // Will be set dynamically
+		EmbedBatchSize:   32,
+		EmbedConcurrency: 4,
 	}
 }
 
 // NewContextManager creates a new context manager
 func NewContextManager(workspaceRoot string, llmClient LLMClient, modelName string, options ContextOptions) *ContextManager {
 	gatherer := NewGatherer(workspaceRoot)
-	vectorStore := vectorstore.NewVectorStore(options.VectorDimension)
+	backend, backendErr := vectorstore.NewBackend(options.VectorBackend)
+	if backendErr != nil {
+		backend = vectorstore.NewFlatBackend() // Never used; backendErr is returned instead
+	}
+	vectorStore := vectorstore.NewVectorStoreWithBackend(options.VectorDimension, backend)
 
 	// Configure chunker
 	chunkOptions := textutils.ChunkOptions{
@@ -91,10 +134,21 @@ func NewContextManager(workspaceRoot string, llmClient LLMClient, modelName stri
 	summaryOptions.MaxLength = options.SummaryMaxLength
 	summarizer := textutils.NewSummarizer(llmClient, modelName, summaryOptions)
 
+	embeddingModel := options.EmbeddingModel
+	if modeler, ok := llmClient.(llm.EmbeddingModeler); ok && embeddingModel == "" {
+		embeddingModel = modeler.EmbeddingModel()
+	}
+
+	contextBudget := options.ContextBudgetTokens
+	if contextBudget <= 0 {
+		contextBudget = DefaultContextBudget(modelName)
+	}
+
 	return &ContextManager{
 		workspaceRoot: workspaceRoot,
 		gatherer:      gatherer,
 		vectorStore:   vectorStore,
+		backendErr:    backendErr,
 		llmClient:     llmClient,
 		modelName:     modelName,
 		chunker:       chunker,
@@ -102,6 +156,13 @@ func NewContextManager(workspaceRoot string, llmClient LLMClient, modelName stri
 		cache:         make(map[string]*CachedContext),
 		maxCacheSize:  options.MaxCacheSize,
 		cacheTimeout:  options.CacheTimeout,
+		indexPath:     options.IndexPath,
+
+		embeddingModel: embeddingModel,
+
+		embedBatchSize:   options.EmbedBatchSize,
+		embedConcurrency: options.EmbedConcurrency,
+		contextBudget:    contextBudget,
 	}
 }
 
@@ -137,6 +198,7 @@ func (cm *ContextManager) RetrieveContext(ctx context.Context, query string, max
 		contextPieces, err = cm.vectorSearch(ctx, query, maxResults)
 		if err != nil {
 			// Fall back to LLM-assisted search
+			contextkeys.LoggerFor(ctx, logger.Context).Debug("Vector search failed, falling back to LLM-assisted search", "error", err)
 			contextPieces, err = cm.llmAssistedSearch(ctx, query, maxResults)
 		}
 	} else {
@@ -149,8 +211,9 @@ func (cm *ContextManager) RetrieveContext(ctx context.Context, query string, max
 	}
 
 	// Format and cache the results
-	content := cm.formatContextPieces(contextPieces)
+	content, budget := cm.formatContextPieces(contextPieces)
 	cm.cacheContext(query, content, len(contextPieces))
+	contextkeys.LoggerFor(ctx, logger.Context).Debug("Retrieved context", "query", query, "pieces", len(contextPieces), "chars", len(content))
 
 	return &ContextResponse{
 		Query:     query,
@@ -158,6 +221,7 @@ func (cm *ContextManager) RetrieveContext(ctx context.Context, query string, max
 		Pieces:    contextPieces,
 		Cached:    false,
 		Timestamp: time.Now(),
+		Budget:    budget,
 	}, nil
 }
 
@@ -168,6 +232,7 @@ type ContextResponse struct {
 	Pieces    []ContextPiece `json:"pieces,omitempty"`
 	Cached    bool           `json:"cached"`
 	Timestamp time.Time      `json:"timestamp"`
+	Budget    *BudgetReport  `json:"budget,omitempty"` // How much of the context budget the content uses
 }
 
 // ContextPiece represents a piece of retrieved context
@@ -189,6 +254,9 @@ func (cm *ContextManager) vectorSearch(ctx context.Context, query string, maxRes
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
+	if dimension := cm.vectorStore.Dimension(); dimension > 0 && len(queryEmbedding) != dimension {
+		return nil, fmt.Errorf("index has %d-dimensional embeddings but the query has %d; rebuild it with 'CGE index --force'", dimension, len(queryEmbedding))
+	}
 
 	// Search vector store
 	searchResults, err := cm.vectorStore.Search(queryEmbedding, maxResults*2)
@@ -214,10 +282,10 @@ func (cm *ContextManager) vectorSearch(ctx context.Context, query string, maxRes
 		if filePath, ok := result.Document.Metadata["file_path"].(string); ok {
 			piece.FilePath = filePath
 		}
-		if startLine, ok := result.Document.Metadata["start_line"].(int); ok {
+		if startLine, ok := vectorstore.MetadataInt(result.Document, "start_line"); ok {
 			piece.StartLine = startLine
 		}
-		if endLine, ok := result.Document.Metadata["end_line"].(int); ok {
+		if endLine, ok := vectorstore.MetadataInt(result.Document, "end_line"); ok {
 			piece.EndLine = endLine
 		}
 
@@ -360,122 +428,325 @@ func (cm *ContextManager) calculateRelevance(content, explanation string) float6
 	return float64(matches) / float64(len(words))
 }
 
-// formatContextPieces formats context pieces into a readable string
-func (cm *ContextManager) formatContextPieces(pieces []ContextPiece) string {
+// formatContextPieces fits pieces into the context budget and formats them into a readable string
+func (cm *ContextManager) formatContextPieces(pieces []ContextPiece) (string, *BudgetReport) {
+	fitted, report := assembleWithBudget(pieces, cm.contextBudget)
+
 	var formatted strings.Builder
 
-	formatted.WriteString(fmt.Sprintf("Retrieved %d relevant context pieces:\n\n", len(pieces)))
+	formatted.WriteString(fmt.Sprintf("Retrieved %d relevant context pieces (%d/%d tokens", len(fitted), report.UsedTokens, report.BudgetTokens))
+	if report.PiecesDropped > 0 {
+		formatted.WriteString(fmt.Sprintf(", %d omitted", report.PiecesDropped))
+	}
+	formatted.WriteString("):\n\n")
 
-	for i, piece := range pieces {
-		formatted.WriteString(fmt.Sprintf("## Context %d (Relevance: %.2f)\n", i+1, piece.Relevance))
-		formatted.WriteString(fmt.Sprintf("**File:** %s\n", piece.FilePath))
+	for i, piece := range fitted {
+		formatted.WriteString(formatPieceHeader(i, piece))
+		formatted.WriteString("\n```\n")
+		formatted.WriteString(piece.Content)
+		formatted.WriteString("\n```\n\n")
 
-		if piece.StartLine > 0 {
-			formatted.WriteString(fmt.Sprintf("**Lines:** %d-%d\n", piece.StartLine, piece.EndLine))
+		if i < len(fitted)-1 {
+			formatted.WriteString("---\n\n")
 		}
+	}
 
-		formatted.WriteString(fmt.Sprintf("**Type:** %s\n", piece.Type))
+	return formatted.String(), report
+}
 
-		if piece.Summary != "" {
-			formatted.WriteString(fmt.Sprintf("**Summary:** %s\n", piece.Summary))
-		}
+// formatPieceHeader formats the heading block shown above a context piece
+func formatPieceHeader(i int, piece ContextPiece) string {
+	var header strings.Builder
 
-		formatted.WriteString("\n```\n")
+	header.WriteString(fmt.Sprintf("## Context %d (Relevance: %.2f)\n", i+1, piece.Relevance))
+	header.WriteString(fmt.Sprintf("**File:** %s\n", piece.FilePath))
 
-		// Truncate very long content
-		content := piece.Content
-		if len(content) > 1500 {
-			content = content[:1500] + "\n... [Content truncated]"
-		}
+	if piece.StartLine > 0 {
+		header.WriteString(fmt.Sprintf("**Lines:** %d-%d\n", piece.StartLine, piece.EndLine))
+	}
 
-		formatted.WriteString(content)
-		formatted.WriteString("\n```\n\n")
+	header.WriteString(fmt.Sprintf("**Type:** %s\n", piece.Type))
 
-		if i < len(pieces)-1 {
-			formatted.WriteString("---\n\n")
-		}
+	if piece.Summary != "" {
+		header.WriteString(fmt.Sprintf("**Summary:** %s\n", piece.Summary))
 	}
 
-	return formatted.String()
+	return header.String()
+}
+
+// chunkCountKey records how many chunks a file produced when it was indexed
+const chunkCountKey = "chunk_count"
+
+// IndexOptions controls a workspace indexing run
+type IndexOptions struct {
+	// Force re-embeds every chunk, ignoring stored content hashes
+	Force bool
+	// Progress, if set, is called as chunks are embedded
+	Progress ProgressFunc
+}
+
+// IndexStats reports what an indexing run did
+type IndexStats struct {
+	FilesScanned   int           `json:"files_scanned"`
+	FilesUnchanged int           `json:"files_unchanged"`
+	FilesRemoved   int           `json:"files_removed"`
+	FilesSkipped   int           `json:"files_skipped"` // Binary or too large to index
+	ChunksEmbedded int           `json:"chunks_embedded"`
+	ChunksSkipped  int           `json:"chunks_skipped"`
+	ChunksFailed   int           `json:"chunks_failed"`
+	Duration       time.Duration `json:"duration"`
+	// Rebuilt reports that the saved index came from another embedding model,
+	// or had another dimension, and was embedded again from scratch
+	Rebuilt bool `json:"rebuilt,omitempty"`
 }
 
 // IndexWorkspace indexes the workspace for vector search
 func (cm *ContextManager) IndexWorkspace(ctx context.Context) error {
+	var opts IndexOptions
+	if report := agent.ProgressCallbackFromContext(ctx); report != nil {
+		// Indexing on demand from a tool call shows up as tool progress
+		opts.Progress = func(done, total int) {
+			if total > 0 {
+				report(float64(done)/float64(total), fmt.Sprintf("Embedded %d/%d chunks", done, total), done, total)
+			}
+		}
+	}
+	_, err := cm.IndexWorkspaceWithOptions(ctx, opts)
+	return err
+}
+
+// IndexWorkspaceWithOptions indexes the workspace, only embedding chunks whose
+// content hash is not already in the vector store unless opts.Force is set
+func (cm *ContextManager) IndexWorkspaceWithOptions(ctx context.Context, opts IndexOptions) (*IndexStats, error) {
 	cm.indexMutex.Lock()
 	defer cm.indexMutex.Unlock()
 
 	if !cm.llmClient.SupportsEmbeddings() {
-		return fmt.Errorf("LLM client does not support embeddings")
+		return nil, fmt.Errorf("LLM client does not support embeddings")
+	}
+	if cm.backendErr != nil {
+		return nil, cm.backendErr
 	}
+	return cm.indexWorkspace(ctx, opts)
+}
+
+// indexWorkspace indexes the workspace with indexMutex held
+func (cm *ContextManager) indexWorkspace(ctx context.Context, opts IndexOptions) (*IndexStats, error) {
+	start := time.Now()
+	stats := &IndexStats{}
 
-	// Clear existing index
-	cm.vectorStore.Clear()
+	// Load the persisted index so unchanged chunks can be reused across runs
+	if !opts.Force {
+		stale, err := cm.loadIndex(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stats.Rebuilt = stale
+	}
+
+	if opts.Force {
+		cm.vectorStore.Clear()
+		cm.vectorStore.SetEmbeddingModel(cm.embeddingModel)
+	}
 
 	// Get all source files
 	files, err := cm.getSourceFiles()
 	if err != nil {
-		return fmt.Errorf("failed to get source files: %w", err)
+		return nil, fmt.Errorf("failed to get source files: %w", err)
 	}
 
-	// Index each file
+	current := make(map[string]bool, len(files))
+
+	// Chunk each file, reusing stored embeddings and collecting chunks that need one
+	var pending []textutils.TextChunk
 	for _, filePath := range files {
-		if err := cm.indexFile(ctx, filePath); err != nil {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		relPath, _ := filepath.Rel(cm.workspaceRoot, filePath)
+		current[relPath] = true
+		stats.FilesScanned++
+
+		chunks, err := cm.indexFile(filePath, relPath, stats)
+		if err != nil {
 			// Log error but continue with other files
 			continue
 		}
+		pending = append(pending, chunks...)
+	}
+
+	// Embed new and changed chunks in parallel batches
+	texts := make([]string, len(pending))
+	for i, chunk := range pending {
+		texts[i] = chunk.Content
+	}
+	embeddings := embedTexts(ctx, cm.llmClient, texts, cm.embedBatchSize, cm.embedConcurrency, opts.Progress)
+	if cm.dimensionChanged(embeddings) {
+		// Reused embeddings cannot be compared with the new ones
+		contextkeys.LoggerFor(ctx, logger.Context).Info("Embedding dimension changed, rebuilding the index", "dimension", cm.vectorStore.Dimension())
+		opts.Force = true
+		rebuilt, err := cm.indexWorkspace(ctx, opts)
+		if rebuilt != nil {
+			rebuilt.Rebuilt = true
+		}
+		return rebuilt, err
+	}
+	for i, chunk := range pending {
+		if embeddings[i] == nil {
+			stats.ChunksFailed++ // Skip chunks that can't be embedded
+			continue
+		}
+		if err := cm.vectorStore.AddChunk(chunk, embeddings[i]); err != nil {
+			stats.ChunksFailed++ // Skip chunks that can't be stored
+			continue
+		}
+		stats.ChunksEmbedded++
+	}
+	if err := ctx.Err(); err != nil {
+		return stats, err
+	}
+
+	// Drop chunks for files that no longer exist
+	for path := range cm.vectorStore.IndexedFiles() {
+		if !current[path] {
+			cm.vectorStore.DeleteFile(path)
+			stats.FilesRemoved++
+		}
+	}
+
+	if cm.indexPath != "" {
+		if err := cm.vectorStore.SaveToFile(cm.indexPath); err != nil {
+			return stats, fmt.Errorf("failed to save index: %w", err)
+		}
 	}
 
+	stats.Duration = time.Since(start)
 	cm.indexed = true
 	cm.lastIndexTime = time.Now()
+	cm.lastIndexStats = stats
 
-	return nil
+	return stats, nil
+}
+
+// loadIndex loads the persisted index into an empty vector store, discarding
+// it when its embeddings come from another model. It reports whether the
+// saved index was discarded.
+func (cm *ContextManager) loadIndex(ctx context.Context) (bool, error) {
+	if cm.indexPath == "" || cm.vectorStore.Count() > 0 {
+		return false, nil
+	}
+	stale, err := cm.vectorStore.LoadIndex(cm.indexPath, cm.embeddingModel, 0)
+	if err != nil {
+		return false, fmt.Errorf("failed to load index: %w", err)
+	}
+	if stale {
+		contextkeys.LoggerFor(ctx, logger.Context).Info("Discarding index of another embedding model", "path", cm.indexPath, "embedding_model", cm.embeddingModel)
+	}
+	return stale, nil
 }
 
-// indexFile indexes a single file
-func (cm *ContextManager) indexFile(ctx context.Context, filePath string) error {
+// dimensionChanged reports whether new embeddings differ in dimension from
+// those already in the store
+func (cm *ContextManager) dimensionChanged(embeddings [][]float32) bool {
+	dimension := cm.vectorStore.Dimension()
+	if dimension == 0 {
+		return false
+	}
+	for _, embedding := range embeddings {
+		if embedding != nil {
+			return len(embedding) != dimension
+		}
+	}
+	return false
+}
+
+// indexFile chunks a single file, storing chunks whose content is unchanged with
+// their existing embedding and returning the chunks that still need embedding
+func (cm *ContextManager) indexFile(filePath, relPath string, stats *IndexStats) ([]textutils.TextChunk, error) {
 	content, err := readFileContent(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Skip very large files
-	if len(content) > 100000 { // 100KB limit
-		return nil
+	// Skip very large and binary files, dropping anything indexed for them before
+	if len(content) > 100000 || textutils.IsBinary([]byte(content)) { // 100KB limit
+		cm.vectorStore.DeleteFile(relPath)
+		stats.FilesSkipped++
+		return nil, nil
+	}
+
+	fileHash := vectorstore.ContentHash(content)
+	existing := cm.vectorStore.DocumentsForFile(relPath)
+
+	// Fast path: the whole file is unchanged since it was last indexed and none
+	// of its chunks failed to embed last time
+	if len(existing) > 0 && existing[0].Metadata[vectorstore.MetadataFileHash] == fileHash &&
+		existing[0].Metadata[chunkCountKey] == strconv.Itoa(len(existing)) {
+		stats.FilesUnchanged++
+		stats.ChunksSkipped += len(existing)
+		return nil, nil
 	}
 
 	// Chunk the file
 	chunks, err := cm.chunker.ChunkText(content)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Embeddings already computed for this file, keyed by chunk content hash
+	known := make(map[string][]float32, len(existing))
+	for _, doc := range existing {
+		if hash, ok := doc.Metadata[vectorstore.MetadataContentHash].(string); ok {
+			known[hash] = doc.Embedding
+		}
 	}
+	cm.vectorStore.DeleteFile(relPath)
 
 	// Add file metadata to chunks
-	relPath, _ := filepath.Rel(cm.workspaceRoot, filePath)
 	for i := range chunks {
 		if chunks[i].Metadata == nil {
 			chunks[i].Metadata = make(map[string]string)
 		}
-		chunks[i].Metadata["file_path"] = relPath
+		chunks[i].Metadata[vectorstore.MetadataFilePath] = relPath
+		chunks[i].Metadata[vectorstore.MetadataFileHash] = fileHash
+		chunks[i].Metadata[vectorstore.MetadataContentHash] = vectorstore.ContentHash(chunks[i].Content)
+		chunks[i].Metadata[chunkCountKey] = strconv.Itoa(len(chunks))
 	}
 
-	// Generate embeddings and store chunks
+	// Store unchanged chunks with their previous embedding
+	var pending []textutils.TextChunk
 	for _, chunk := range chunks {
-		embedding, err := cm.llmClient.Embed(ctx, chunk.Content)
-		if err != nil {
-			continue // Skip chunks that can't be embedded
+		embedding, ok := known[chunk.Metadata[vectorstore.MetadataContentHash]]
+		if !ok {
+			pending = append(pending, chunk)
+			continue
 		}
-
-		err = cm.vectorStore.AddChunk(chunk, embedding)
-		if err != nil {
-			continue // Skip chunks that can't be stored
+		if err := cm.vectorStore.AddChunk(chunk, embedding); err == nil {
+			stats.ChunksSkipped++
 		}
 	}
 
-	return nil
+	return pending, nil
 }
 
-// ensureIndexed ensures the workspace is indexed
+// LastIndexStats returns statistics from the most recent indexing run, if any
+func (cm *ContextManager) LastIndexStats() *IndexStats {
+	cm.indexMutex.RLock()
+	defer cm.indexMutex.RUnlock()
+	return cm.lastIndexStats
+}
+
+// ensureIndexed ensures the workspace is indexed, using the index saved by
+// `CGE index` while it is fresh
 func (cm *ContextManager) ensureIndexed(ctx context.Context) error {
+	if cm.backendErr != nil {
+		return cm.backendErr
+	}
+	if err := cm.loadSavedIndex(ctx); err != nil {
+		return err
+	}
+
 	cm.indexMutex.RLock()
 	needsIndexing := !cm.indexed || time.Since(cm.lastIndexTime) > 24*time.Hour
 	cm.indexMutex.RUnlock()
@@ -487,6 +758,28 @@ func (cm *ContextManager) ensureIndexed(ctx context.Context) error {
 	return nil
 }
 
+// loadSavedIndex serves retrieval from the persisted index without indexing
+// the workspace again, dating it by when it was saved
+func (cm *ContextManager) loadSavedIndex(ctx context.Context) error {
+	cm.indexMutex.Lock()
+	defer cm.indexMutex.Unlock()
+	if cm.indexed || cm.indexPath == "" {
+		return nil
+	}
+	info, err := os.Stat(cm.indexPath)
+	if err != nil {
+		return nil // Not indexed yet
+	}
+	if _, err := cm.loadIndex(ctx); err != nil {
+		return err
+	}
+	if cm.vectorStore.Count() > 0 {
+		cm.indexed = true
+		cm.lastIndexTime = info.ModTime()
+	}
+	return nil
+}
+
 // Cache management methods
 
 func (cm *ContextManager) getCachedContext(query string) *CachedContext {
@@ -560,6 +853,7 @@ func (cm *ContextManager) GetStats() map[string]interface{} {
 		"indexed":           cm.indexed,
 		"last_index_time":   cm.lastIndexTime,
 		"vector_store_size": cm.vectorStore.Count(),
+		"last_index_stats":  cm.lastIndexStats,
 	}
 }
 
@@ -593,7 +887,7 @@ func (cm *ContextManager) getSourceFiles() ([]string, error) {
 }
 
 func shouldSkipDir(name string) bool {
-	skipDirs := []string{".git", "node_modules", "vendor", ".vscode", ".idea", "target", "build", "dist"}
+	skipDirs := []string{".git", ".cge", "node_modules", "vendor", ".vscode", ".idea", "target", "build", "dist"}
 	for _, skip := range skipDirs {
 		if name == skip {
 			return true
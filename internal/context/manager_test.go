@@ -0,0 +1,289 @@
+package context
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingEmbedClient is a minimal LLMClient that counts embedding calls
+type countingEmbedClient struct {
+	mu         sync.Mutex
+	embedCalls int
+}
+
+func (c *countingEmbedClient) Generate(ctx context.Context, modelName, prompt, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	return "", nil
+}
+
+func (c *countingEmbedClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	c.mu.Lock()
+	c.embedCalls++
+	c.mu.Unlock()
+	return []float32{float32(len(text)), 1, 0.5}, nil
+}
+
+func (c *countingEmbedClient) SupportsEmbeddings() bool {
+	return true
+}
+
+func TestIndexWorkspaceSkipsUnchangedChunks(t *testing.T) {
+	// readFileContent only allows paths under the working directory
+	workspace, err := os.MkdirTemp(".", "index-test-")
+	if err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	defer os.RemoveAll(workspace)
+	workspace, _ = filepath.Abs(workspace)
+
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(workspace, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("a.go", "package a\n\nfunc A() int {\n\treturn 1\n}\n")
+	write("b.go", "package a\n\nfunc B() int {\n\treturn 2\n}\n")
+
+	client := &countingEmbedClient{}
+	options := DefaultContextOptions()
+	options.IndexPath = filepath.Join(workspace, ".cge", "index", "vectors.json")
+
+	first, err := NewContextManager(workspace, client, "test", options).IndexWorkspaceWithOptions(context.Background(), IndexOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if first.ChunksEmbedded == 0 || first.ChunksSkipped != 0 {
+		t.Fatalf("Expected a fresh index to embed every chunk, got %+v", first)
+	}
+
+	// A new manager loads the persisted index and only re-embeds the changed file
+	write("b.go", "package a\n\nfunc B() int {\n\treturn 3\n}\n")
+	callsBefore := client.embedCalls
+	second, err := NewContextManager(workspace, client, "test", options).IndexWorkspaceWithOptions(context.Background(), IndexOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if second.FilesUnchanged != 1 || second.ChunksSkipped == 0 {
+		t.Errorf("Expected a.go to be skipped as unchanged, got %+v", second)
+	}
+	if second.ChunksEmbedded == 0 || client.embedCalls-callsBefore != second.ChunksEmbedded {
+		t.Errorf("Expected only changed chunks to be embedded, got %+v (%d calls)", second, client.embedCalls-callsBefore)
+	}
+	if second.FilesScanned != 2 {
+		t.Errorf("Expected the .cge index directory to be ignored, scanned %d files", second.FilesScanned)
+	}
+
+	// Force re-embeds everything
+	forced, err := NewContextManager(workspace, client, "test", options).IndexWorkspaceWithOptions(context.Background(), IndexOptions{Force: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if forced.ChunksSkipped != 0 || forced.ChunksEmbedded != first.ChunksEmbedded {
+		t.Errorf("Expected --force to re-embed all %d chunks, got %+v", first.ChunksEmbedded, forced)
+	}
+
+	// Removing a file prunes its chunks
+	os.Remove(filepath.Join(workspace, "b.go"))
+	pruned, err := NewContextManager(workspace, client, "test", options).IndexWorkspaceWithOptions(context.Background(), IndexOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if pruned.FilesRemoved != 1 {
+		t.Errorf("Expected b.go to be pruned, got %+v", pruned)
+	}
+}
+
+// modelEmbedClient reports which embedding model countingEmbedClient stands in for
+type modelEmbedClient struct {
+	countingEmbedClient
+	model string
+}
+
+func (c *modelEmbedClient) EmbeddingModel() string {
+	return c.model
+}
+
+func TestSavedIndexKeyedByEmbeddingModel(t *testing.T) {
+	workspace, err := os.MkdirTemp(".", "index-test-")
+	if err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	defer os.RemoveAll(workspace)
+	workspace, _ = filepath.Abs(workspace)
+	if err := os.WriteFile(filepath.Join(workspace, "a.go"), []byte("package a\n\nfunc A() int {\n\treturn 1\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write a.go: %v", err)
+	}
+
+	options := DefaultContextOptions()
+	options.IndexPath = filepath.Join(workspace, ".cge", "index", "vectors.json")
+
+	client := &modelEmbedClient{model: "model-a"}
+	first, err := NewContextManager(workspace, client, "test", options).IndexWorkspaceWithOptions(context.Background(), IndexOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Retrieval in a new manager serves from the saved index, embedding only the query
+	callsBefore := client.embedCalls
+	response, err := NewContextManager(workspace, client, "test", options).RetrieveContext(context.Background(), "func A", 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if client.embedCalls-callsBefore != 1 {
+		t.Errorf("Expected only the query to be embedded, got %d calls", client.embedCalls-callsBefore)
+	}
+	if len(response.Pieces) == 0 || response.Pieces[0].FilePath != "a.go" || response.Pieces[0].StartLine == 0 {
+		t.Errorf("Expected a.go to be retrieved with line numbers, got %+v", response.Pieces)
+	}
+
+	// Another embedding model cannot reuse the saved vectors
+	client.model = "model-b"
+	rebuilt, err := NewContextManager(workspace, client, "test", options).IndexWorkspaceWithOptions(context.Background(), IndexOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !rebuilt.Rebuilt || rebuilt.ChunksSkipped != 0 || rebuilt.ChunksEmbedded != first.ChunksEmbedded {
+		t.Errorf("Expected a model switch to rebuild all %d chunks, got %+v", first.ChunksEmbedded, rebuilt)
+	}
+}
+
+func TestUnknownVectorBackendIsReported(t *testing.T) {
+	options := DefaultContextOptions()
+	options.VectorBackend.Type = "hsnw"
+	manager := NewContextManager(t.TempDir(), &countingEmbedClient{}, "test", options)
+
+	if _, err := manager.IndexWorkspaceWithOptions(context.Background(), IndexOptions{}); err == nil || !strings.Contains(err.Error(), "hsnw") {
+		t.Errorf("Expected indexing to report the unknown backend, got: %v", err)
+	}
+	if _, err := manager.RetrieveContext(context.Background(), "query", 3); err == nil || !strings.Contains(err.Error(), "hsnw") {
+		t.Errorf("Expected retrieval to report the unknown backend, got: %v", err)
+	}
+}
+
+// batchEmbedClient adds batch embedding support to countingEmbedClient
+type batchEmbedClient struct {
+	countingEmbedClient
+	batchSizes []int
+}
+
+func (c *batchEmbedClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	c.mu.Lock()
+	c.batchSizes = append(c.batchSizes, len(texts))
+	c.mu.Unlock()
+
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = []float32{float32(len(text)), 1}
+	}
+	return embeddings, nil
+}
+
+func TestEmbedTextsBatchesAndReportsProgress(t *testing.T) {
+	texts := make([]string, 10)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+
+	client := &batchEmbedClient{}
+	var lastDone, lastTotal int
+	results := embedTexts(context.Background(), client, texts, 4, 3, func(done, total int) {
+		lastDone, lastTotal = done, total
+	})
+
+	for i, embedding := range results {
+		if embedding == nil || embedding[0] != float32(i+1) {
+			t.Fatalf("Expected embedding for text %d in input order, got %v", i, embedding)
+		}
+	}
+	if len(client.batchSizes) != 3 || client.embedCalls != 0 {
+		t.Errorf("Expected 3 batch requests and no single requests, got batches %v and %d single calls", client.batchSizes, client.embedCalls)
+	}
+	if lastDone != 10 || lastTotal != 10 {
+		t.Errorf("Expected final progress 10/10, got %d/%d", lastDone, lastTotal)
+	}
+}
+
+func TestAssembleWithBudget(t *testing.T) {
+	// Three functions separated by blank lines, roughly 150 tokens each
+	var block strings.Builder
+	for f := 0; f < 3; f++ {
+		block.WriteString("func example() {\n")
+		for i := 0; i < 10; i++ {
+			block.WriteString("\tvalue := compute(input, options, settings)\n")
+		}
+		block.WriteString("}\n\n")
+	}
+	large := block.String()
+	small := "const answer = 42"
+
+	pieces := []ContextPiece{
+		{FilePath: "low.go", Content: large, Relevance: 0.2, Type: "chunk"},
+		{FilePath: "small.go", Content: small, Relevance: 0.5, Type: "chunk"},
+		{FilePath: "high.go", Content: large, Relevance: 0.9, Type: "chunk"},
+	}
+
+	fitted, report := assembleWithBudget(pieces, 400)
+	if report.UsedTokens > report.BudgetTokens {
+		t.Fatalf("Used %d tokens, over budget of %d", report.UsedTokens, report.BudgetTokens)
+	}
+	if len(fitted) == 0 || fitted[0].FilePath != "high.go" {
+		t.Fatalf("Expected highest relevance piece first, got %+v", fitted)
+	}
+	if report.PiecesIncluded+report.PiecesDropped != len(pieces) {
+		t.Errorf("Report does not account for every piece: %+v", report)
+	}
+
+	for _, piece := range fitted {
+		if piece.FilePath == "small.go" && piece.Content != small {
+			t.Errorf("Expected small piece to be kept whole, got %q", piece.Content)
+		}
+		if piece.FilePath == "high.go" {
+			// Truncation should end on a whole function, not mid-body
+			body := strings.TrimSuffix(piece.Content, "\n... [Content truncated to fit context budget]")
+			if !strings.HasSuffix(body, "}") {
+				t.Errorf("Expected truncation at a block boundary, got tail %q", body[len(body)-20:])
+			}
+		}
+	}
+
+	// A generous budget keeps everything intact
+	_, report = assembleWithBudget(pieces, 10000)
+	if report.PiecesTruncated != 0 || report.PiecesDropped != 0 || report.PiecesIncluded != 3 {
+		t.Errorf("Expected all pieces whole with a large budget, got %+v", report)
+	}
+}
+
+func TestModelContextWindow(t *testing.T) {
+	cases := map[string]int{
+		"gpt-4o-mini":   128000,
+		"gpt-4":         8192,
+		"llama3.1:8b":   128000,
+		"llama3:latest": 8192,
+		"unknown-model": defaultContextWindow,
+	}
+	for model, want := range cases {
+		if got := ModelContextWindow(model); got != want {
+			t.Errorf("ModelContextWindow(%q) = %d, want %d", model, got, want)
+		}
+	}
+}
+
+func TestScopedIndexFilePath(t *testing.T) {
+	workspace := t.TempDir()
+	scoped := ScopedIndexFilePath(workspace, filepath.Join("services", "billing"))
+	if scoped == IndexFilePath(workspace) {
+		t.Fatal("Expected a scoped index apart from the workspace index")
+	}
+	if filepath.Base(filepath.Dir(scoped)) != "services%2Fbilling" {
+		t.Errorf("Expected the scope in the index directory name, got %s", scoped)
+	}
+	if other := ScopedIndexFilePath(workspace, "services_billing"); other == scoped {
+		t.Errorf("Expected scopes with similar names to keep indexes of their own, got %s for both", scoped)
+	}
+	if other := ScopedIndexFilePath(workspace, "services/billing/"); other != scoped {
+		t.Errorf("Expected one index per scope however it is written, got %s and %s", scoped, other)
+	}
+}
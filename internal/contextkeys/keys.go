@@ -80,6 +80,12 @@ func LoggerFromContext(ctx context.Context) *slog.Logger {
 	return log
 }
 
+// LoggerFor retrieves Logger from context for a subsystem, which logs at the
+// level set for the subsystem
+func LoggerFor(ctx context.Context, subsystem string) *slog.Logger {
+	return LoggerFromContext(ctx).With(logger.SubsystemKey, subsystem)
+}
+
 /*
 func ConfigFromContext(ctx context.Context) config.AppConfig {
 	val := ctx.Value(ConfigKey)
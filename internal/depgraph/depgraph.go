@@ -0,0 +1,235 @@
+// Package depgraph builds the dependency graph of the packages of a workspace
+// from their imports: Go packages from the import declarations of their
+// files, and JavaScript and TypeScript modules, grouped by directory, from
+// their import, export and require statements. 'CGE diagram' renders the
+// graph as a Mermaid or Graphviz architecture diagram.
+package depgraph
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/analyzer"
+)
+
+// Language is the language of the packages of a node
+type Language string
+
+const (
+	LanguageGo Language = "go"
+	LanguageJS Language = "js" // JavaScript and TypeScript
+)
+
+// Languages are the languages graphs are built for
+var Languages = []Language{LanguageGo, LanguageJS}
+
+// Node is a package of the workspace, or an external dependency
+type Node struct {
+	ID          string   `json:"id"`   // Language and path, e.g. go:internal/agent
+	Path        string   `json:"path"` // Slash-separated directory relative to the workspace root, "." for the root; the import path of an external dependency
+	Language    Language `json:"language"`
+	ImportPath  string   `json:"import_path,omitempty"` // Of a Go package of the workspace
+	External    bool     `json:"external,omitempty"`
+	Files       int      `json:"files,omitempty"`
+	Doc         string   `json:"doc,omitempty"`         // First sentence of the package comment
+	Description string   `json:"description,omitempty"` // Written by Describe
+}
+
+// Edge is a dependency: the packages of From import those of To
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the dependency graph of a workspace, its nodes and edges sorted
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Options select what a graph is built from
+type Options struct {
+	Languages []Language // Languages to include; all when empty
+	Tests     bool       // Include test files and their imports
+	External  bool       // Include third-party dependencies as nodes
+	Depth     int        // Merge the packages below this many path segments into their ancestor; no merging when 0
+}
+
+// includes reports whether the options include language
+func (o Options) includes(language Language) bool {
+	if len(o.Languages) == 0 {
+		return true
+	}
+	for _, l := range o.Languages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLanguage returns the language called name: go, or js, javascript,
+// ts or typescript
+func ParseLanguage(name string) (Language, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "go", "golang":
+		return LanguageGo, nil
+	case "js", "javascript", "ts", "typescript":
+		return LanguageJS, nil
+	}
+	return "", fmt.Errorf("unsupported language %q; use go or js", name)
+}
+
+// NodeID returns the ID of the node of path in language
+func NodeID(language Language, path string) string {
+	return string(language) + ":" + path
+}
+
+// Node returns the node with the given ID
+func (g *Graph) Node(id string) (Node, bool) {
+	i := sort.Search(len(g.Nodes), func(i int) bool { return g.Nodes[i].ID >= id })
+	if i < len(g.Nodes) && g.Nodes[i].ID == id {
+		return g.Nodes[i], true
+	}
+	return Node{}, false
+}
+
+// builder collects the nodes and edges of a graph
+type builder struct {
+	nodes map[string]*Node
+	edges map[Edge]bool
+}
+
+func newBuilder() *builder {
+	return &builder{nodes: make(map[string]*Node), edges: make(map[Edge]bool)}
+}
+
+// node returns the node of path in language, adding it when missing
+func (b *builder) node(language Language, path string, external bool) *Node {
+	id := NodeID(language, path)
+	n, ok := b.nodes[id]
+	if !ok {
+		n = &Node{ID: id, Path: path, Language: language, External: external}
+		b.nodes[id] = n
+	}
+	return n
+}
+
+// edge records that from imports to
+func (b *builder) edge(from, to string) {
+	if from != to {
+		b.edges[Edge{From: from, To: to}] = true
+	}
+}
+
+// graph returns the collected graph with its packages merged to depth
+func (b *builder) graph(depth int) *Graph {
+	merged := make(map[string]string, len(b.nodes)) // Node ID to the ID it is merged into
+	nodes := make(map[string]*Node)
+	for id, n := range b.nodes {
+		target := *n
+		if depth > 0 && !n.External {
+			target.Path = truncatePath(n.Path, depth)
+			target.ID = NodeID(n.Language, target.Path)
+		}
+		merged[id] = target.ID
+		existing, ok := nodes[target.ID]
+		if !ok {
+			if target.Path != n.Path {
+				target.Doc = "" // Describes a package inside the merged one
+				target.ImportPath = ""
+			}
+			nodes[target.ID] = &target
+			continue
+		}
+		existing.Files += n.Files
+		if n.Path == existing.Path {
+			existing.Doc = n.Doc
+		}
+	}
+
+	g := &Graph{Nodes: make([]Node, 0, len(nodes))}
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, *n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+
+	edges := make(map[Edge]bool, len(b.edges))
+	for e := range b.edges {
+		e = Edge{From: merged[e.From], To: merged[e.To]}
+		if e.From != e.To && !edges[e] {
+			edges[e] = true
+			g.Edges = append(g.Edges, e)
+		}
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+// truncatePath keeps the first depth segments of a slash-separated path
+func truncatePath(p string, depth int) string {
+	segments := strings.Split(p, "/")
+	if len(segments) <= depth {
+		return p
+	}
+	return strings.Join(segments[:depth], "/")
+}
+
+// skipDir reports whether the directory called name is left out of graphs:
+// dependencies, build output, CGE state, test data and hidden directories
+func skipDir(name string) bool {
+	return analyzer.IsSkippableDir(name) || name == ".cge" || name == "testdata" || strings.HasPrefix(name, ".")
+}
+
+// Build returns the dependency graph of the packages under root
+func Build(root string, opts Options) (*Graph, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	var goFiles, jsFiles []string
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if p != root && skipDir(name) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case name == "go.mod" || strings.HasSuffix(name, ".go"):
+			if opts.includes(LanguageGo) {
+				goFiles = append(goFiles, rel)
+			}
+		case isJSFile(name):
+			if opts.includes(LanguageJS) {
+				jsFiles = append(jsFiles, rel)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the files of %s: %w", root, err)
+	}
+
+	b := newBuilder()
+	buildGo(b, root, goFiles, opts)
+	buildJS(b, root, jsFiles, opts)
+	return b.graph(opts.Depth), nil
+}
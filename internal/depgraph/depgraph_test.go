@@ -0,0 +1,188 @@
+package depgraph
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates files, given by slash-separated path, under root
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+}
+
+// goWorkspace is a Go module with a command, two internal packages, a test
+// and a nested module
+var goWorkspace = map[string]string{
+	"go.mod":                         "module example.com/shop\n\ngo 1.22\n\nrequire (\n\tgithub.com/spf13/cobra v1.8.1\n\tgithub.com/lib/pq v1.10.9 // indirect\n)\n",
+	"main.go":                        "package main\n\nimport \"example.com/shop/cmd\"\n\nfunc main() { cmd.Execute() }\n",
+	"cmd/root.go":                    "package cmd\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/shop/internal/orders\"\n\t\"github.com/spf13/cobra\"\n)\n",
+	"internal/orders/orders.go":      "// Package orders places and tracks orders.\npackage orders\n\nimport \"example.com/shop/internal/store/postgres\"\n",
+	"internal/orders/orders_test.go": "package orders\n\nimport \"example.com/shop/internal/testutil\"\n",
+	"internal/store/postgres/db.go":  "// Package postgres stores orders in PostgreSQL.\npackage postgres\n\nimport _ \"github.com/lib/pq/driver\"\n",
+	"internal/testutil/testutil.go":  "package testutil\n",
+	"tools/go.mod":                   "module example.com/shop/tools\n",
+	"tools/gen/main.go":              "package main\n\nimport \"example.com/shop/internal/orders\"\n",
+	"testdata/broken.go":             "package broken\n\nimport \"example.com/shop/cmd\"\n",
+}
+
+func TestBuildGo(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, goWorkspace)
+
+	g, err := Build(root, Options{})
+	require.NoError(t, err)
+	var paths []string
+	for _, n := range g.Nodes {
+		paths = append(paths, n.Path)
+	}
+	assert.Equal(t, []string{".", "cmd", "internal/orders", "internal/store/postgres", "internal/testutil", "tools/gen"}, paths, "testdata is left out")
+	assert.Equal(t, []Edge{
+		{"go:.", "go:cmd"},
+		{"go:cmd", "go:internal/orders"},
+		{"go:internal/orders", "go:internal/store/postgres"},
+		{"go:tools/gen", "go:internal/orders"},
+	}, g.Edges, "the imports of test files are left out, and nested modules are resolved")
+	orders, ok := g.Node("go:internal/orders")
+	require.True(t, ok)
+	assert.Equal(t, "Places and tracks orders.", orders.Doc)
+	assert.Equal(t, 1, orders.Files)
+
+	g, err = Build(root, Options{Tests: true, External: true, Depth: 2})
+	require.NoError(t, err)
+	assert.Contains(t, g.Edges, Edge{"go:cmd", "go:github.com/spf13/cobra"})
+	assert.Contains(t, g.Edges, Edge{"go:internal/store", "go:github.com/lib/pq"}, "imports are attributed to their required module")
+	assert.Contains(t, g.Edges, Edge{"go:internal/orders", "go:internal/testutil"})
+	assert.NotContains(t, g.Edges, Edge{"go:cmd", "go:fmt"}, "the standard library is left out")
+	store, ok := g.Node("go:internal/store")
+	require.True(t, ok)
+	assert.Empty(t, store.Doc, "the comment of a merged package does not describe its ancestor")
+	cobra, ok := g.Node("go:github.com/spf13/cobra")
+	require.True(t, ok)
+	assert.True(t, cobra.External)
+}
+
+func TestBuildJS(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"package.json":                `{"name": "web"}`,
+		"src/index.ts":                "import { App } from './app/App.js'\nimport React from 'react'\nimport '@/styles'\n",
+		"src/app/App.tsx":             "import { api } from '../api'\nimport type { User } from \"../types/user\"\nimport { format } from 'date-fns/format'\nconst fs = require('fs')\n",
+		"src/api/index.ts":            "export * from './client'\nimport { z } from '@scope/schema/v2'\n",
+		"src/api/client.ts":           "const lazy = () => import('../types/user')\n",
+		"src/types/user.d.ts":         "export interface User {}\n",
+		"src/types/user.ts":           "export type User = { id: string }\n",
+		"src/app/App.test.tsx":        "import { render } from '../testing/render'\n",
+		"src/testing/render.ts":       "export const render = () => {}\n",
+		"node_modules/react/index.js": "module.exports = {}\n",
+	})
+
+	g, err := Build(root, Options{Languages: []Language{LanguageJS}})
+	require.NoError(t, err)
+	assert.Equal(t, []Edge{
+		{"js:src", "js:src/app"},
+		{"js:src/api", "js:src/types"},
+		{"js:src/app", "js:src/api"},
+		{"js:src/app", "js:src/types"},
+	}, g.Edges)
+	api, ok := g.Node("js:src/api")
+	require.True(t, ok)
+	assert.Equal(t, 2, api.Files)
+
+	g, err = Build(root, Options{Languages: []Language{LanguageJS}, External: true})
+	require.NoError(t, err)
+	assert.Contains(t, g.Edges, Edge{"js:src", "js:react"})
+	assert.Contains(t, g.Edges, Edge{"js:src/app", "js:date-fns"})
+	assert.Contains(t, g.Edges, Edge{"js:src/api", "js:@scope/schema"})
+	_, ok = g.Node("js:fs")
+	assert.False(t, ok, "Node.js built-ins are not dependencies")
+	assert.NotContains(t, g.Edges, Edge{"js:src/app", "js:src/testing"}, "test files are left out")
+}
+
+func TestRender(t *testing.T) {
+	g := &Graph{
+		Nodes: []Node{
+			{ID: "go:cmd", Path: "cmd", Language: LanguageGo, Doc: `Parses "flags"`},
+			{ID: "go:github.com/spf13/cobra", Path: "github.com/spf13/cobra", Language: LanguageGo, External: true},
+			{ID: "go:internal/orders", Path: "internal/orders", Language: LanguageGo, Doc: "Places orders.", Description: "Order placement and tracking"},
+			{ID: "js:web", Path: "web", Language: LanguageJS},
+		},
+		Edges: []Edge{{"go:cmd", "go:github.com/spf13/cobra"}, {"go:cmd", "go:internal/orders"}},
+	}
+
+	assert.Equal(t, `graph LR
+  subgraph go [Go]
+    n0["cmd<br/><small>Parses #quot;flags#quot;</small>"]
+    n1(["github.com/spf13/cobra"])
+    n2["internal/orders<br/><small>Order placement and tracking</small>"]
+  end
+  subgraph js [JavaScript/TypeScript]
+    n3["web"]
+  end
+  n0 --> n1
+  n0 --> n2
+`, Render(g, FormatMermaid, "lr"))
+
+	g.Nodes = g.Nodes[:3]
+	assert.Equal(t, `digraph dependencies {
+  rankdir=TB;
+  node [shape=box, fontname="Helvetica", fontsize=10];
+  "go:cmd" [label="cmd\nParses \"flags\""];
+  "go:github.com/spf13/cobra" [label="github.com/spf13/cobra", style="dashed,rounded"];
+  "go:internal/orders" [label="internal/orders\nOrder placement and tracking"];
+  "go:cmd" -> "go:github.com/spf13/cobra";
+  "go:cmd" -> "go:internal/orders";
+}
+`, Render(g, FormatDOT, "TB"))
+}
+
+func TestEmbed(t *testing.T) {
+	doc := Embed("# Architecture", "graph LR\n  n0\n", FormatMermaid)
+	assert.Equal(t, "# Architecture\n\n<!-- cge:diagram -->\n```mermaid\ngraph LR\n  n0\n```\n<!-- /cge:diagram -->\n", doc)
+
+	doc += "\nMore text.\n"
+	doc = Embed(doc, "digraph {}\n", FormatDOT)
+	assert.Equal(t, "# Architecture\n\n<!-- cge:diagram -->\n```dot\ndigraph {}\n```\n<!-- /cge:diagram -->\n\nMore text.\n", doc, "the embedded diagram is replaced in place")
+}
+
+// describingClient answers description requests with a canned response
+type describingClient struct {
+	response string
+	prompts  []string
+}
+
+func (c *describingClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	c.prompts = append(c.prompts, prompt)
+	return c.response, nil
+}
+
+func TestDescribe(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, goWorkspace)
+	g, err := Build(root, Options{External: true})
+	require.NoError(t, err)
+
+	client := &describingClient{response: "Here you go:\n```json\n{\"cmd\": \"Command-line interface of the shop\", \"internal/orders\": \"Order placement\"}\n```"}
+	require.NoError(t, Describe(context.Background(), g, client, "model", root))
+	require.Len(t, client.prompts, 1)
+	assert.Contains(t, client.prompts[0], "- internal/orders (Go)\n  files: orders.go, orders_test.go\n  comment: Places and tracks orders.\n  imports: internal/store/postgres")
+	assert.NotContains(t, client.prompts[0], "- github.com/spf13/cobra", "external dependencies are not described")
+
+	orders, _ := g.Node("go:internal/orders")
+	assert.Equal(t, "Order placement", orders.Description)
+	postgres, _ := g.Node("go:internal/store/postgres")
+	assert.Empty(t, postgres.Description)
+	assert.Contains(t, Render(g, FormatMermaid, "LR"), "internal/store/postgres<br/><small>Stores orders in PostgreSQL.</small>", "packages left out keep their comment")
+
+	client.response = "I cannot help with that."
+	assert.Error(t, Describe(context.Background(), g, client, "model", root))
+}
@@ -0,0 +1,118 @@
+package depgraph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// describeBatchSize is how many packages are described per request
+const describeBatchSize = 25
+
+// maxDescribedFiles caps the file names listed for a package
+const maxDescribedFiles = 12
+
+const describeSystemPrompt = `You document the architecture of software projects. Given the packages of a project with their files, package comments and dependencies, describe the role of each package in one short sentence of at most 12 words, without repeating its name. Answer only with a JSON object mapping each package path to its description.`
+
+// Generator generates text with a model; llm.Client is one. It is declared
+// here as the agent tools use this package, and the llm package depends on
+// them through the configuration.
+type Generator interface {
+	Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error)
+}
+
+// Describe has the model write a one-sentence description of the role of
+// each package of g, from its files, package comment and dependencies.
+// External dependencies are not described. Packages the model leaves out
+// keep their package comment.
+func Describe(ctx context.Context, g *Graph, client Generator, model, root string) error {
+	var packages []int
+	for i, n := range g.Nodes {
+		if !n.External {
+			packages = append(packages, i)
+		}
+	}
+	dependencies := make(map[string][]string)
+	for _, e := range g.Edges {
+		if to, ok := g.Node(e.To); ok {
+			dependencies[e.From] = append(dependencies[e.From], to.Path)
+		}
+	}
+
+	for start := 0; start < len(packages); start += describeBatchSize {
+		batch := packages[start:min(start+describeBatchSize, len(packages))]
+		var prompt strings.Builder
+		prompt.WriteString("Packages:\n")
+		for _, i := range batch {
+			n := g.Nodes[i]
+			fmt.Fprintf(&prompt, "\n- %s (%s)\n", n.Path, languageTitles[n.Language])
+			if files := packageFiles(root, n); len(files) > 0 {
+				fmt.Fprintf(&prompt, "  files: %s\n", strings.Join(files, ", "))
+			}
+			if n.Doc != "" {
+				fmt.Fprintf(&prompt, "  comment: %s\n", n.Doc)
+			}
+			if deps := dependencies[n.ID]; len(deps) > 0 {
+				fmt.Fprintf(&prompt, "  imports: %s\n", strings.Join(deps, ", "))
+			}
+		}
+		response, err := client.Generate(ctx, model, prompt.String(), describeSystemPrompt, nil)
+		if err != nil {
+			return fmt.Errorf("failed to describe the packages: %w", err)
+		}
+		descriptions, err := parseDescriptions(response)
+		if err != nil {
+			return err
+		}
+		for _, i := range batch {
+			if description := strings.TrimSpace(descriptions[g.Nodes[i].Path]); description != "" {
+				g.Nodes[i].Description = description
+			}
+		}
+	}
+	return nil
+}
+
+// parseDescriptions reads the JSON object of descriptions by package path
+// from a response, which models often wrap in prose or code fences
+func parseDescriptions(response string) (map[string]string, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the model did not answer with package descriptions")
+	}
+	var descriptions map[string]string
+	if err := json.Unmarshal([]byte(response[start:end+1]), &descriptions); err != nil {
+		return nil, fmt.Errorf("failed to parse the package descriptions: %w", err)
+	}
+	return descriptions, nil
+}
+
+// packageFiles returns the names of the source files directly in the
+// directory of n, sorted; the first maxDescribedFiles of them
+func packageFiles(root string, n Node) []string {
+	entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(n.Path)))
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if (n.Language == LanguageGo && path.Ext(name) == ".go") || (n.Language == LanguageJS && isJSFile(name)) {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+	if len(files) > maxDescribedFiles {
+		files = append(files[:maxDescribedFiles], fmt.Sprintf("and %d more", len(files)-maxDescribedFiles))
+	}
+	return files
+}
@@ -0,0 +1,192 @@
+package depgraph
+
+import (
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goModule is a Go module of the workspace
+type goModule struct {
+	dir      string   // Slash-separated, relative to the workspace root
+	path     string   // Module path
+	requires []string // Paths of the required modules
+}
+
+// goPackage is the part of a Go package the graph is built from
+type goPackage struct {
+	files   int
+	doc     string
+	imports map[string]bool
+}
+
+// buildGo adds the Go packages of files, relative to root, to b. Packages
+// are identified by directory, so a directory with an external test package
+// is one node.
+func buildGo(b *builder, root string, files []string, opts Options) {
+	var modules []goModule
+	packages := make(map[string]*goPackage)
+	fset := token.NewFileSet()
+	for _, rel := range files {
+		full := filepath.Join(root, filepath.FromSlash(rel))
+		if path.Base(rel) == "go.mod" {
+			if module, ok := readGoMod(full, path.Dir(rel)); ok {
+				modules = append(modules, module)
+			}
+			continue
+		}
+		if strings.HasSuffix(rel, "_test.go") && !opts.Tests {
+			continue
+		}
+		file, err := parser.ParseFile(fset, full, nil, parser.ImportsOnly|parser.ParseComments)
+		if err != nil {
+			continue // Unparsable files, such as templates, are not part of a package
+		}
+		dir := path.Dir(rel)
+		pkg, ok := packages[dir]
+		if !ok {
+			pkg = &goPackage{imports: make(map[string]bool)}
+			packages[dir] = pkg
+		}
+		pkg.files++
+		if file.Doc != nil && pkg.doc == "" {
+			pkg.doc = packageSynopsis(file.Name.Name, file.Doc.Text())
+		}
+		for _, spec := range file.Imports {
+			if importPath, err := strconv.Unquote(spec.Path.Value); err == nil {
+				pkg.imports[importPath] = true
+			}
+		}
+	}
+	if len(packages) == 0 {
+		return
+	}
+
+	// Deeper modules first, so a package belongs to its nearest module
+	sort.Slice(modules, func(i, j int) bool { return dirDepth(modules[i].dir) > dirDepth(modules[j].dir) })
+	dirs := make(map[string]string, len(packages)) // Import path to directory
+	for dir := range packages {
+		if module, ok := moduleOf(modules, dir); ok {
+			dirs[importPathOf(module, dir)] = dir
+		}
+	}
+
+	for dir, pkg := range packages {
+		node := b.node(LanguageGo, dir, false)
+		node.Files, node.Doc = pkg.files, pkg.doc
+		module, ok := moduleOf(modules, dir)
+		if ok {
+			node.ImportPath = importPathOf(module, dir)
+		}
+		for importPath := range pkg.imports {
+			if target, ok := dirs[importPath]; ok {
+				b.edge(node.ID, NodeID(LanguageGo, target))
+				continue
+			}
+			if !opts.External || isStandardLibrary(importPath) {
+				continue
+			}
+			dependency := b.node(LanguageGo, dependencyModule(importPath, module.requires), true)
+			b.edge(node.ID, dependency.ID)
+		}
+	}
+}
+
+// packageSynopsis returns the first sentence of the comment of the package
+// called name, without its "Package name" opening; comments not following
+// that convention are usually file headers and are ignored
+func packageSynopsis(name, comment string) string {
+	synopsis, ok := strings.CutPrefix(doc.Synopsis(comment), "Package "+name+" ")
+	if !ok || synopsis == "" {
+		return ""
+	}
+	return strings.ToUpper(synopsis[:1]) + synopsis[1:]
+}
+
+// readGoMod reads the module path and requirements of the go.mod file at
+// full, in the directory dir of the workspace
+func readGoMod(full, dir string) (goModule, bool) {
+	content, err := os.ReadFile(full) // #nosec G304 - found walking the workspace
+	if err != nil {
+		return goModule{}, false
+	}
+	module := goModule{dir: dir}
+	inRequire := false
+	for _, line := range strings.Split(string(content), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "module" && len(fields) > 1:
+			module.path = strings.Trim(fields[1], `"`)
+		case fields[0] == "require" && len(fields) > 1 && fields[1] == "(":
+			inRequire = true
+		case fields[0] == "require" && len(fields) > 2:
+			module.requires = append(module.requires, fields[1])
+		case fields[0] == ")":
+			inRequire = false
+		case inRequire && len(fields) > 1:
+			module.requires = append(module.requires, fields[0])
+		}
+	}
+	return module, module.path != ""
+}
+
+// dirDepth returns the number of segments of a relative directory
+func dirDepth(dir string) int {
+	if dir == "." {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
+
+// moduleOf returns the module the package in dir belongs to; modules are
+// sorted deepest first
+func moduleOf(modules []goModule, dir string) (goModule, bool) {
+	for _, module := range modules {
+		if module.dir == "." || dir == module.dir || strings.HasPrefix(dir, module.dir+"/") {
+			return module, true
+		}
+	}
+	return goModule{}, false
+}
+
+// importPathOf returns the import path of the package in dir of module
+func importPathOf(module goModule, dir string) string {
+	if dir == module.dir {
+		return module.path
+	}
+	rel := dir
+	if module.dir != "." {
+		rel = strings.TrimPrefix(dir, module.dir+"/")
+	}
+	return module.path + "/" + rel
+}
+
+// isStandardLibrary reports whether importPath is a package of the standard
+// library, whose first element has no dot
+func isStandardLibrary(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+// dependencyModule returns the required module importPath belongs to, or
+// importPath itself when none matches
+func dependencyModule(importPath string, requires []string) string {
+	best := ""
+	for _, module := range requires {
+		if (importPath == module || strings.HasPrefix(importPath, module+"/")) && len(module) > len(best) {
+			best = module
+		}
+	}
+	if best == "" {
+		return importPath
+	}
+	return best
+}
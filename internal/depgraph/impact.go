@@ -0,0 +1,647 @@
+package depgraph
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exposure is how far a declaration can be used from
+type Exposure string
+
+const (
+	ExposurePublic  Exposure = "public"  // Exported from a package other modules can import
+	ExposureModule  Exposure = "module"  // Exported, but only importable within the module: a Go internal package, or a JavaScript export
+	ExposurePackage Exposure = "package" // Only usable in its Go package, or its JavaScript file
+)
+
+// maxReferences caps the references an Impact lists
+const maxReferences = 100
+
+// ErrSymbolNotFound is returned when the symbol of an impact analysis is not
+// declared where it was looked for
+var ErrSymbolNotFound = errors.New("symbol not found")
+
+// AmbiguousSymbolError is returned when a symbol is declared in several
+// files and no file was given to choose one
+type AmbiguousSymbolError struct {
+	Symbol string
+	Files  []string
+}
+
+func (e *AmbiguousSymbolError) Error() string {
+	return fmt.Sprintf("%s is declared in %d files: %s", e.Symbol, len(e.Files), strings.Join(e.Files, ", "))
+}
+
+// Reference is a use of a symbol
+type Reference struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// AffectedTest is a test file that may break with a change
+type AffectedTest struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// Impact is what may break when a file or symbol of the workspace changes
+type Impact struct {
+	File     string   `json:"file"`
+	Symbol   string   `json:"symbol,omitempty"`
+	Kind     string   `json:"kind,omitempty"` // func, method, type, const or var; or the JavaScript declaration keyword
+	Line     int      `json:"line,omitempty"` // Of the declaration
+	Package  string   `json:"package"`        // Directory of the file
+	Language Language `json:"language"`
+	Exposure Exposure `json:"exposure"`
+	// ExportedSymbols are the exported declarations of a file, when no
+	// symbol was given
+	ExportedSymbols      []string       `json:"exported_symbols,omitempty"`
+	References           []Reference    `json:"references,omitempty"` // Outside the declaration, the first maxReferences
+	ReferenceCount       int            `json:"reference_count"`
+	DirectDependents     []string       `json:"direct_dependents"`     // Packages importing the package
+	TransitiveDependents []string       `json:"transitive_dependents"` // Packages importing those, and so on
+	AffectedTests        []AffectedTest `json:"affected_tests"`
+	TestPackages         []string       `json:"test_packages,omitempty"` // Go packages with tests to run, as ./dir
+	// ByName is set when references were matched by name only, as for
+	// methods, which cannot be told apart from methods of other types
+	// without type checking
+	ByName bool `json:"by_name,omitempty"`
+}
+
+// AnalyzeImpact returns what depends on file, a slash-separated path relative
+// to root, or on symbol. symbol is a top-level name or Type.Method; without
+// file, it is looked up in every package. With neither, an error is returned.
+func AnalyzeImpact(root, file, symbol string) (*Impact, error) {
+	if file == "" && symbol == "" {
+		return nil, errors.New("a file or a symbol is needed")
+	}
+	if file == "" {
+		var err error
+		if file, err = findDeclaringFile(root, symbol); err != nil {
+			return nil, err
+		}
+	}
+	file = path.Clean(filepath.ToSlash(file))
+	var language Language
+	switch {
+	case strings.HasSuffix(file, ".go"):
+		language = LanguageGo
+	case isJSFile(path.Base(file)):
+		language = LanguageJS
+	default:
+		return nil, fmt.Errorf("impact analysis supports Go, JavaScript and TypeScript files, not %s", file)
+	}
+
+	g, err := Build(root, Options{Languages: []Language{language}, Tests: true})
+	if err != nil {
+		return nil, err
+	}
+	impact := &Impact{File: file, Symbol: symbol, Package: path.Dir(file), Language: language, DirectDependents: []string{}, TransitiveDependents: []string{}, AffectedTests: []AffectedTest{}}
+	direct, transitive := dependents(g, NodeID(language, impact.Package))
+	for _, n := range direct {
+		impact.DirectDependents = append(impact.DirectDependents, n.Path)
+	}
+	for _, n := range transitive {
+		impact.TransitiveDependents = append(impact.TransitiveDependents, n.Path)
+	}
+
+	if language == LanguageGo {
+		err = analyzeGo(root, g, impact)
+	} else {
+		err = analyzeJS(root, impact)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(impact.AffectedTests, func(i, j int) bool { return impact.AffectedTests[i].File < impact.AffectedTests[j].File })
+	return impact, nil
+}
+
+// dependents returns the nodes importing the node with the given ID, and
+// those depending on it through them, each sorted by path
+func dependents(g *Graph, id string) (direct, transitive []Node) {
+	importers := make(map[string][]string)
+	for _, e := range g.Edges {
+		importers[e.To] = append(importers[e.To], e.From)
+	}
+	seen := map[string]bool{id: true}
+	frontier := []string{id}
+	for depth := 1; len(frontier) > 0; depth++ {
+		var next []string
+		for _, current := range frontier {
+			for _, importer := range importers[current] {
+				if seen[importer] {
+					continue
+				}
+				seen[importer] = true
+				next = append(next, importer)
+				if n, ok := g.Node(importer); ok {
+					if depth == 1 {
+						direct = append(direct, n)
+					} else {
+						transitive = append(transitive, n)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+	byPath := func(nodes []Node) {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Path < nodes[j].Path })
+	}
+	byPath(direct)
+	byPath(transitive)
+	return direct, transitive
+}
+
+// addReference records a use of the symbol at line of file
+func (impact *Impact) addReference(file string, line int, lines []string) {
+	impact.ReferenceCount++
+	if len(impact.References) < maxReferences && line > 0 && line <= len(lines) {
+		impact.References = append(impact.References, Reference{File: file, Line: line, Text: strings.TrimSpace(lines[line-1])})
+	}
+}
+
+// goSource is a parsed Go file of the workspace
+type goSource struct {
+	rel   string // Slash-separated, relative to the workspace root
+	file  *ast.File
+	fset  *token.FileSet
+	lines []string
+}
+
+// parseGoPackage parses the Go files in dir, a slash-separated directory
+// relative to root
+func parseGoPackage(root, dir string) []goSource {
+	entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil
+	}
+	var sources []goSource
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		rel := path.Join(dir, entry.Name())
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel))) // #nosec G304 - inside the workspace
+		if err != nil {
+			continue
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, rel, content, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		sources = append(sources, goSource{rel: rel, file: file, fset: fset, lines: strings.Split(string(content), "\n")})
+	}
+	return sources
+}
+
+// goDeclaration finds symbol, a name or Type.Method, among the top-level
+// declarations of file, returning its kind and name position
+func goDeclaration(file *ast.File, symbol string) (string, token.Pos, bool) {
+	receiver, name := "", symbol
+	if i := strings.LastIndex(symbol, "."); i >= 0 {
+		receiver, name = symbol[:i], symbol[i+1:]
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Name.Name == name && goReceiver(d) == receiver {
+				if receiver != "" {
+					return "method", d.Name.Pos(), true
+				}
+				return "func", d.Name.Pos(), true
+			}
+		case *ast.GenDecl:
+			if receiver != "" {
+				continue
+			}
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.Name == name {
+						return "type", s.Name.Pos(), true
+					}
+				case *ast.ValueSpec:
+					for _, ident := range s.Names {
+						if ident.Name == name {
+							return d.Tok.String(), ident.Pos(), true
+						}
+					}
+				}
+			}
+		}
+	}
+	return "", token.NoPos, false
+}
+
+// goReceiver returns the type name of the receiver of a method, without
+// pointer or type parameters; empty for functions
+func goReceiver(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	expr := fn.Recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.IndexExpr:
+		expr = t.X
+	case *ast.IndexListExpr:
+		expr = t.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// goExportedDeclarations returns the exported top-level names of file, with
+// methods as Type.Method
+func goExportedDeclarations(file *ast.File) []string {
+	var names []string
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if receiver := goReceiver(d); receiver == "" {
+				names = append(names, d.Name.Name)
+			} else if ast.IsExported(receiver) {
+				names = append(names, receiver+"."+d.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						names = append(names, s.Name.Name)
+					}
+				case *ast.ValueSpec:
+					for _, ident := range s.Names {
+						if ident.IsExported() {
+							names = append(names, ident.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// goExposure returns the exposure of an exported declaration of the package
+// in dir called name
+func goExposure(dir, name string, exported bool) Exposure {
+	if !exported || name == "main" {
+		return ExposurePackage
+	}
+	if dir == "internal" || strings.HasPrefix(dir, "internal/") || strings.Contains(dir, "/internal/") || strings.HasSuffix(dir, "/internal") {
+		return ExposureModule
+	}
+	return ExposurePublic
+}
+
+// analyzeGo fills in the declaration, references, exposure and affected
+// tests of a Go target
+func analyzeGo(root string, g *Graph, impact *Impact) error {
+	sources := parseGoPackage(root, impact.Package)
+	var target *goSource
+	for i := range sources {
+		if sources[i].rel == impact.File {
+			target = &sources[i]
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("%s is not a Go file that can be parsed", impact.File)
+	}
+	packageName := target.file.Name.Name
+
+	// What is exported from the file or symbol
+	name := impact.Symbol
+	var declared token.Pos
+	if impact.Symbol != "" {
+		kind, pos, ok := goDeclaration(target.file, impact.Symbol)
+		if !ok {
+			return fmt.Errorf("%w: %s is not declared in %s", ErrSymbolNotFound, impact.Symbol, impact.File)
+		}
+		impact.Kind, impact.Line, declared = kind, target.fset.Position(pos).Line, pos
+		exported := true
+		if receiver, method, isMethod := strings.Cut(impact.Symbol, "."); isMethod {
+			name = method
+			exported = ast.IsExported(receiver) && ast.IsExported(method)
+			impact.ByName = true
+		} else {
+			exported = ast.IsExported(name)
+		}
+		impact.Exposure = goExposure(impact.Package, packageName, exported)
+	} else {
+		impact.ExportedSymbols = goExportedDeclarations(target.file)
+		sort.Strings(impact.ExportedSymbols)
+		impact.Exposure = goExposure(impact.Package, packageName, len(impact.ExportedSymbols) > 0)
+	}
+
+	// The packages that can reference the target: its own, and the packages
+	// importing it. Methods are reachable through values passed on, so every
+	// dependent is searched for them.
+	importPath := ""
+	if n, ok := g.Node(NodeID(LanguageGo, impact.Package)); ok {
+		importPath = n.ImportPath
+	}
+	searched := map[string][]goSource{impact.Package: sources}
+	dependentDirs := append(append([]string{}, impact.DirectDependents...), impact.TransitiveDependents...)
+	for i, dir := range dependentDirs {
+		if i >= len(impact.DirectDependents) && !impact.ByName {
+			break
+		}
+		searched[dir] = parseGoPackage(root, dir)
+	}
+
+	referencing := make(map[string]bool) // Files referencing the symbol
+	for dir, files := range searched {
+		for _, source := range files {
+			if impact.Symbol == "" {
+				continue
+			}
+			for _, line := range goReferences(source, dir == impact.Package && source.file.Name.Name == packageName, importPath, name, impact.ByName) {
+				if source.rel == impact.File && line == impact.Line && declared.IsValid() {
+					continue // The declaration itself
+				}
+				impact.addReference(source.rel, line, source.lines)
+				referencing[source.rel] = true
+			}
+		}
+	}
+	sort.Slice(impact.References, func(i, j int) bool {
+		if impact.References[i].File != impact.References[j].File {
+			return impact.References[i].File < impact.References[j].File
+		}
+		return impact.References[i].Line < impact.References[j].Line
+	})
+
+	// Tests: those of the package, and those of its dependents that use the
+	// symbol, or import the package when a file changes. The tests of a
+	// package using it in its own code are run too.
+	testPackages := make(map[string]bool)
+	dependentsAffected := false
+	for dir, files := range searched {
+		for _, source := range files {
+			if !strings.HasSuffix(source.rel, "_test.go") {
+				if dir != impact.Package && (referencing[source.rel] || (impact.Symbol == "" && importsPath(source.file, importPath))) {
+					dependentsAffected = true
+					if hasGoTests(root, dir) {
+						testPackages[dir] = true
+					}
+				}
+				continue
+			}
+			switch {
+			case referencing[source.rel]:
+				impact.AffectedTests = append(impact.AffectedTests, AffectedTest{File: source.rel, Reason: "references " + impact.Symbol})
+			case dir == impact.Package:
+				impact.AffectedTests = append(impact.AffectedTests, AffectedTest{File: source.rel, Reason: "tests the changed package"})
+			case impact.Symbol == "" && importsPath(source.file, importPath):
+				impact.AffectedTests = append(impact.AffectedTests, AffectedTest{File: source.rel, Reason: "imports the changed package"})
+			default:
+				continue
+			}
+			testPackages[dir] = true
+		}
+	}
+	// Dependents further away are only affected through the packages in
+	// between, so only their packages are listed
+	for _, dir := range impact.TransitiveDependents {
+		if _, ok := searched[dir]; !ok && dependentsAffected && hasGoTests(root, dir) {
+			testPackages[dir] = true
+		}
+	}
+	for dir := range testPackages {
+		impact.TestPackages = append(impact.TestPackages, "./"+dir)
+	}
+	sort.Strings(impact.TestPackages)
+	return nil
+}
+
+// goReferences returns the lines of source using name: as an identifier in
+// the package declaring it (local), through the import of importPath
+// elsewhere, or as any selector when matching by name
+func goReferences(source goSource, local bool, importPath, name string, byName bool) []int {
+	qualifiers := make(map[string]bool)
+	if !local && !byName {
+		for _, spec := range source.file.Imports {
+			if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != importPath {
+				continue
+			}
+			if spec.Name != nil {
+				qualifiers[spec.Name.Name] = true
+			} else {
+				qualifiers[path.Base(importPath)] = true
+			}
+		}
+		if len(qualifiers) == 0 {
+			return nil
+		}
+	}
+	var lines []int
+	seen := make(map[int]bool)
+	add := func(pos token.Pos) {
+		if line := source.fset.Position(pos).Line; !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	ast.Inspect(source.file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.SelectorExpr:
+			if node.Sel.Name != name {
+				return true
+			}
+			if byName {
+				add(node.Sel.Pos())
+			} else if x, ok := node.X.(*ast.Ident); ok && qualifiers[x.Name] {
+				add(node.Sel.Pos())
+				return false
+			}
+		case *ast.Ident:
+			if local && !byName && node.Name == name {
+				add(node.Pos())
+			}
+		}
+		return true
+	})
+	sort.Ints(lines)
+	return lines
+}
+
+// importsPath reports whether file imports importPath
+func importsPath(file *ast.File, importPath string) bool {
+	for _, spec := range file.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == importPath {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGoTests reports whether dir has Go test files
+func hasGoTests(root, dir string) bool {
+	matches, _ := filepath.Glob(filepath.Join(root, filepath.FromSlash(dir), "*_test.go"))
+	return len(matches) > 0
+}
+
+// jsDeclaration matches the declaration of a name in a JavaScript or
+// TypeScript module; the name is substituted for NAME
+const jsDeclaration = `(?m)^\s*(export\s+)?(?:default\s+)?(?:declare\s+)?(?:async\s+)?(function\*?|class|const|let|var|interface|type|enum)\s+NAME\b`
+
+// analyzeJS fills in the declaration, references, exposure and affected
+// tests of a JavaScript or TypeScript target. Modules are matched to the
+// target by name, in the target's directory and the directories importing it.
+func analyzeJS(root string, impact *Impact) error {
+	content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(impact.File))) // #nosec G304 - inside the workspace
+	if err != nil {
+		return err
+	}
+	if impact.Symbol != "" {
+		match := regexp.MustCompile(strings.Replace(jsDeclaration, "NAME", regexp.QuoteMeta(impact.Symbol), 1)).FindSubmatchIndex(content)
+		if match == nil {
+			return fmt.Errorf("%w: %s is not declared in %s", ErrSymbolNotFound, impact.Symbol, impact.File)
+		}
+		impact.Kind = strings.TrimSuffix(string(content[match[4]:match[5]]), "*")
+		impact.Line = bytes.Count(content[:match[0]], []byte("\n")) + 1
+		if match[2] >= 0 {
+			impact.Line = bytes.Count(content[:match[2]], []byte("\n")) + 1
+			impact.Exposure = ExposureModule
+		} else {
+			impact.Exposure = ExposurePackage
+		}
+	} else {
+		for _, m := range regexp.MustCompile(`(?m)^\s*export\s+(?:default\s+)?(?:declare\s+)?(?:async\s+)?(?:function\*?|class|const|let|var|interface|type|enum)\s+([A-Za-z_$][\w$]*)`).FindAllSubmatch(content, -1) {
+			impact.ExportedSymbols = append(impact.ExportedSymbols, string(m[1]))
+		}
+		impact.Exposure = ExposurePackage
+		if len(impact.ExportedSymbols) > 0 || bytes.Contains(content, []byte("export ")) || bytes.Contains(content, []byte("module.exports")) {
+			impact.Exposure = ExposureModule
+		}
+	}
+
+	stem := strings.TrimSuffix(path.Base(impact.File), path.Ext(impact.File))
+	importsTarget := regexp.MustCompile(`['"][^'"\n]*/` + regexp.QuoteMeta(stem) + `(?:\.[a-z]+)?['"]`)
+	if stem == "index" {
+		importsTarget = regexp.MustCompile(`['"][^'"\n]*/` + regexp.QuoteMeta(path.Base(impact.Package)) + `(?:/index(?:\.[a-z]+)?)?['"]`)
+	}
+	var word *regexp.Regexp
+	if impact.Symbol != "" {
+		word = regexp.MustCompile(`(^|[^\w$])` + regexp.QuoteMeta(impact.Symbol) + `($|[^\w$])`)
+	}
+
+	for _, dir := range append([]string{impact.Package}, impact.DirectDependents...) {
+		entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(dir)))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !isJSFile(entry.Name()) {
+				continue
+			}
+			rel := path.Join(dir, entry.Name())
+			source, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel))) // #nosec G304 - inside the workspace
+			if err != nil {
+				continue
+			}
+			imports := rel == impact.File || importsTarget.Match(source)
+			if !imports {
+				continue
+			}
+			referenced := false
+			if word != nil {
+				lines := strings.Split(string(source), "\n")
+				scanner := bufio.NewScanner(bytes.NewReader(source))
+				scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+				for line := 1; scanner.Scan(); line++ {
+					if rel == impact.File && line == impact.Line {
+						continue
+					}
+					if word.MatchString(scanner.Text()) {
+						impact.addReference(rel, line, lines)
+						referenced = true
+					}
+				}
+			}
+			if !isJSTest(rel) || rel == impact.File {
+				continue
+			}
+			switch {
+			case referenced:
+				impact.AffectedTests = append(impact.AffectedTests, AffectedTest{File: rel, Reason: "references " + impact.Symbol})
+			case impact.Symbol == "":
+				impact.AffectedTests = append(impact.AffectedTests, AffectedTest{File: rel, Reason: "imports the changed module"})
+			}
+		}
+	}
+	return nil
+}
+
+// findDeclaringFile returns the file declaring symbol, searching the Go
+// packages and JavaScript modules of the workspace
+func findDeclaringFile(root, symbol string) (string, error) {
+	var files []string
+	jsPattern := regexp.MustCompile(strings.Replace(jsDeclaration, "NAME", regexp.QuoteMeta(symbol), 1))
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && skipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case strings.HasSuffix(rel, "_test.go"):
+		case strings.HasSuffix(rel, ".go"):
+			file, err := parser.ParseFile(token.NewFileSet(), p, nil, parser.SkipObjectResolution)
+			if err == nil {
+				if _, _, ok := goDeclaration(file, symbol); ok {
+					files = append(files, rel)
+				}
+			}
+		case isJSFile(d.Name()) && !isJSTest(rel) && !strings.Contains(symbol, "."):
+			content, err := os.ReadFile(p) // #nosec G304 - found walking the workspace
+			if err == nil && jsPattern.Match(content) {
+				files = append(files, rel)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	switch len(files) {
+	case 0:
+		return "", fmt.Errorf("%w: no declaration of %s in the workspace", ErrSymbolNotFound, symbol)
+	case 1:
+		return files[0], nil
+	}
+	return "", &AmbiguousSymbolError{Symbol: symbol, Files: files}
+}
@@ -0,0 +1,166 @@
+package depgraph
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// impactWorkspace is a Go module where a command uses an internal package,
+// which uses a public one
+var impactWorkspace = map[string]string{
+	"go.mod":  "module example.com/shop\n\ngo 1.22\n",
+	"main.go": "package main\n\nimport \"example.com/shop/cmd\"\n\nfunc main() { cmd.Execute() }\n",
+	"cmd/root.go": `package cmd
+
+import o "example.com/shop/internal/orders"
+
+func Execute() {
+	order := o.Place("book")
+	order.Cancel()
+}
+`,
+	"cmd/root_test.go": "package cmd\n\nimport \"testing\"\n\nfunc TestExecute(t *testing.T) { Execute() }\n",
+	"internal/orders/orders.go": `package orders
+
+import "example.com/shop/money"
+
+// Order is a placed order
+type Order struct{ Total money.Amount }
+
+// Place places an order for item
+func Place(item string) *Order { return &Order{Total: money.Parse(item)} }
+
+// Cancel cancels the order
+func (o *Order) Cancel() {}
+
+func audit() {}
+`,
+	"internal/orders/orders_test.go": "package orders\n\nimport \"testing\"\n\nfunc TestPlace(t *testing.T) { Place(\"pen\") }\n",
+	"internal/orders/export_test.go": "package orders_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/shop/internal/orders\"\n)\n\nfunc TestOrder(t *testing.T) { _ = orders.Order{} }\n",
+	"money/money.go":                 "package money\n\n// Amount is an amount of money\ntype Amount int\n\n// Parse parses an amount\nfunc Parse(s string) Amount { return 0 }\n",
+	"money/money_test.go":            "package money\n\nimport \"testing\"\n\nfunc TestParse(t *testing.T) { Parse(\"1\") }\n",
+	"reports/cancel.go":              "package reports\n\ntype Ticket struct{}\n\nfunc (Ticket) Cancel() {}\n\nfunc Close(t Ticket) { t.Cancel() }\n",
+}
+
+func TestAnalyzeImpactGoSymbol(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, impactWorkspace)
+
+	impact, err := AnalyzeImpact(root, "internal/orders/orders.go", "Place")
+	require.NoError(t, err)
+	assert.Equal(t, "func", impact.Kind)
+	assert.Equal(t, 9, impact.Line)
+	assert.Equal(t, ExposureModule, impact.Exposure, "internal packages are only importable within the module")
+	assert.Equal(t, []string{"cmd"}, impact.DirectDependents)
+	assert.Equal(t, []string{"."}, impact.TransitiveDependents)
+	assert.Equal(t, []Reference{
+		{File: "cmd/root.go", Line: 6, Text: `order := o.Place("book")`},
+		{File: "internal/orders/orders_test.go", Line: 5, Text: `func TestPlace(t *testing.T) { Place("pen") }`},
+	}, impact.References, "references go through the import name, and the declaration is left out")
+	assert.Equal(t, []AffectedTest{
+		{File: "internal/orders/export_test.go", Reason: "tests the changed package"},
+		{File: "internal/orders/orders_test.go", Reason: "references Place"},
+	}, impact.AffectedTests)
+	assert.Equal(t, []string{"./cmd", "./internal/orders"}, impact.TestPackages, "the tests of dependents using the symbol are run")
+
+	impact, err = AnalyzeImpact(root, "", "Order.Cancel")
+	require.NoError(t, err)
+	assert.Equal(t, "internal/orders/orders.go", impact.File, "the declaring file is found")
+	assert.Equal(t, "method", impact.Kind)
+	assert.True(t, impact.ByName)
+	require.Len(t, impact.References, 1, "only packages depending on the declaring one are searched")
+	assert.Equal(t, "cmd/root.go", impact.References[0].File)
+	assert.Equal(t, []string{"./cmd", "./internal/orders"}, impact.TestPackages)
+
+	impact, err = AnalyzeImpact(root, "money/money.go", "Amount")
+	require.NoError(t, err)
+	assert.Equal(t, ExposurePublic, impact.Exposure)
+	assert.Equal(t, []string{"internal/orders"}, impact.DirectDependents)
+	assert.Equal(t, []string{".", "cmd"}, impact.TransitiveDependents)
+	assert.Equal(t, 2, impact.ReferenceCount)
+	assert.Equal(t, []string{"./cmd", "./internal/orders", "./money"}, impact.TestPackages, "tests of transitive dependents are listed by package")
+
+	impact, err = AnalyzeImpact(root, "money/money.go", "Parse")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"./cmd", "./internal/orders", "./money"}, impact.TestPackages, "dependents reach the symbol through the packages in between")
+
+	impact, err = AnalyzeImpact(root, "reports/cancel.go", "Close")
+	require.NoError(t, err)
+	assert.Empty(t, impact.TestPackages, "without dependents or tests, nothing is run")
+
+	impact, err = AnalyzeImpact(root, "internal/orders/orders.go", "audit")
+	require.NoError(t, err)
+	assert.Equal(t, ExposurePackage, impact.Exposure)
+	assert.Zero(t, impact.ReferenceCount)
+}
+
+func TestAnalyzeImpactGoFile(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, impactWorkspace)
+
+	impact, err := AnalyzeImpact(root, "internal/orders/orders.go", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Order", "Order.Cancel", "Place"}, impact.ExportedSymbols)
+	assert.Equal(t, ExposureModule, impact.Exposure)
+	assert.Len(t, impact.AffectedTests, 2)
+	assert.Equal(t, []string{"./cmd", "./internal/orders"}, impact.TestPackages)
+
+	impact, err = AnalyzeImpact(root, "main.go", "")
+	require.NoError(t, err)
+	assert.Equal(t, ExposurePackage, impact.Exposure, "commands cannot be imported")
+	assert.Empty(t, impact.DirectDependents)
+}
+
+func TestAnalyzeImpactErrors(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, impactWorkspace)
+	writeFiles(t, root, map[string]string{"legacy/orders.go": "package legacy\n\nfunc Place() {}\n"})
+
+	_, err := AnalyzeImpact(root, "", "")
+	assert.Error(t, err)
+	_, err = AnalyzeImpact(root, "internal/orders/orders.go", "Missing")
+	assert.True(t, errors.Is(err, ErrSymbolNotFound))
+	_, err = AnalyzeImpact(root, "", "Missing")
+	assert.True(t, errors.Is(err, ErrSymbolNotFound))
+	_, err = AnalyzeImpact(root, "README.md", "")
+	assert.Error(t, err)
+
+	_, err = AnalyzeImpact(root, "", "Place")
+	var ambiguous *AmbiguousSymbolError
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Equal(t, []string{"internal/orders/orders.go", "legacy/orders.go"}, ambiguous.Files)
+}
+
+func TestAnalyzeImpactJS(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"package.json":           `{"name": "web"}`,
+		"src/api/client.ts":      "export async function fetchUser(id: string) {\n  return fetch(id)\n}\n\nconst retries = 3\n",
+		"src/api/client.test.ts": "import { fetchUser } from './client'\n\ntest('fetches', () => fetchUser('1'))\n",
+		"src/app/App.tsx":        "import { fetchUser } from '../api/client'\n\nexport function App() {\n  fetchUser('me')\n}\n",
+		"src/app/App.test.tsx":   "import { App } from './App'\n",
+		"src/other/fetch.ts":     "export function fetchUser() {}\n",
+	})
+
+	impact, err := AnalyzeImpact(root, "src/api/client.ts", "fetchUser")
+	require.NoError(t, err)
+	assert.Equal(t, LanguageJS, impact.Language)
+	assert.Equal(t, "function", impact.Kind)
+	assert.Equal(t, 1, impact.Line)
+	assert.Equal(t, ExposureModule, impact.Exposure)
+	assert.Equal(t, []string{"src/app"}, impact.DirectDependents)
+	assert.Equal(t, 4, impact.ReferenceCount, "the declaration is left out, and modules not importing the file are not searched")
+	assert.Equal(t, []AffectedTest{{File: "src/api/client.test.ts", Reason: "references fetchUser"}}, impact.AffectedTests)
+
+	impact, err = AnalyzeImpact(root, "src/api/client.ts", "retries")
+	require.NoError(t, err)
+	assert.Equal(t, ExposurePackage, impact.Exposure)
+
+	impact, err = AnalyzeImpact(root, "src/api/client.ts", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fetchUser"}, impact.ExportedSymbols)
+	assert.Equal(t, []AffectedTest{{File: "src/api/client.test.ts", Reason: "imports the changed module"}}, impact.AffectedTests)
+}
@@ -0,0 +1,131 @@
+package depgraph
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// jsExtensions are the extensions of JavaScript and TypeScript modules, in
+// the order extensionless imports are resolved
+var jsExtensions = []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs", ".mts", ".cts"}
+
+// jsImport matches the module specifiers of import and export declarations,
+// dynamic imports and require calls
+var jsImport = regexp.MustCompile(`(?:\bfrom\s*|\bimport\s*\(?\s*|\brequire\s*\(\s*)['"]([^'"\n]+)['"]`)
+
+// nodeBuiltins are the modules of Node.js imported without the node: prefix
+var nodeBuiltins = map[string]bool{
+	"assert": true, "buffer": true, "child_process": true, "cluster": true, "crypto": true,
+	"dns": true, "events": true, "fs": true, "http": true, "http2": true, "https": true,
+	"net": true, "os": true, "path": true, "process": true, "querystring": true,
+	"readline": true, "stream": true, "string_decoder": true, "timers": true, "tls": true,
+	"url": true, "util": true, "v8": true, "vm": true, "worker_threads": true, "zlib": true,
+}
+
+// isJSFile reports whether the file called name is a JavaScript or
+// TypeScript module; declaration files are not
+func isJSFile(name string) bool {
+	if strings.HasSuffix(name, ".d.ts") {
+		return false
+	}
+	ext := path.Ext(name)
+	for _, e := range jsExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// isJSTest reports whether the module at rel is a test
+func isJSTest(rel string) bool {
+	name := path.Base(rel)
+	return strings.Contains(name, ".test.") || strings.Contains(name, ".spec.") ||
+		strings.HasPrefix(rel, "__tests__/") || strings.Contains(rel, "/__tests__/")
+}
+
+// buildJS adds the JavaScript and TypeScript modules of files, relative to
+// root, to b, grouped by directory. Relative imports are resolved to files;
+// bare ones are third-party packages, aliases such as @/components are
+// skipped.
+func buildJS(b *builder, root string, files []string, opts Options) {
+	known := make(map[string]bool, len(files))
+	for _, rel := range files {
+		known[rel] = true
+	}
+	for _, rel := range files {
+		if !opts.Tests && isJSTest(rel) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel))) // #nosec G304 - found walking the workspace
+		if err != nil {
+			continue
+		}
+		node := b.node(LanguageJS, path.Dir(rel), false)
+		node.Files++
+		for _, match := range jsImport.FindAllStringSubmatch(string(content), -1) {
+			specifier := match[1]
+			if strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../") {
+				if target, ok := resolveJSImport(known, path.Dir(rel), specifier); ok {
+					b.edge(node.ID, b.node(LanguageJS, path.Dir(target), false).ID)
+				}
+				continue
+			}
+			if !opts.External {
+				continue
+			}
+			if pkg, ok := jsPackage(specifier); ok {
+				b.edge(node.ID, b.node(LanguageJS, pkg, true).ID)
+			}
+		}
+	}
+}
+
+// resolveJSImport returns the module a relative specifier imported from dir
+// refers to: the file itself, the file with an extension, or the index of
+// the directory. TypeScript imports of ./x.js refer to x.ts.
+func resolveJSImport(known map[string]bool, dir, specifier string) (string, bool) {
+	target := path.Join(dir, specifier)
+	if strings.HasPrefix(target, "../") || target == ".." {
+		return "", false // Outside the workspace
+	}
+	candidates := []string{target}
+	if ext := path.Ext(target); ext == ".js" || ext == ".jsx" || ext == ".mjs" || ext == ".cjs" {
+		stem := strings.TrimSuffix(target, ext)
+		candidates = append(candidates, stem+".ts", stem+".tsx", stem+".mts", stem+".cts")
+	}
+	for _, ext := range jsExtensions {
+		candidates = append(candidates, target+ext)
+	}
+	for _, ext := range jsExtensions {
+		candidates = append(candidates, path.Join(target, "index"+ext))
+	}
+	for _, candidate := range candidates {
+		if known[candidate] {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// jsPackage returns the package a bare specifier imports, with its scope;
+// Node.js built-ins and path aliases are not packages
+func jsPackage(specifier string) (string, bool) {
+	if strings.HasPrefix(specifier, "node:") || strings.HasPrefix(specifier, "@/") || strings.HasPrefix(specifier, "~") || strings.HasPrefix(specifier, "/") {
+		return "", false
+	}
+	segments := strings.Split(specifier, "/")
+	if strings.HasPrefix(specifier, "@") {
+		if len(segments) < 2 {
+			return "", false
+		}
+		return segments[0] + "/" + segments[1], true
+	}
+	if nodeBuiltins[segments[0]] {
+		return "", false
+	}
+	return segments[0], true
+}
@@ -0,0 +1,186 @@
+package depgraph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Format is an output format of a diagram
+type Format string
+
+const (
+	FormatMermaid Format = "mermaid"
+	FormatDOT     Format = "dot" // Graphviz
+)
+
+// ParseFormat returns the diagram format called name: mermaid, or dot or
+// graphviz
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "mermaid", "":
+		return FormatMermaid, nil
+	case "dot", "graphviz":
+		return FormatDOT, nil
+	}
+	return "", fmt.Errorf("unsupported diagram format %q; use mermaid or dot", name)
+}
+
+// maxLabelDescription caps the description shown in a node
+const maxLabelDescription = 80
+
+// languageTitles name the languages in diagrams
+var languageTitles = map[Language]string{LanguageGo: "Go", LanguageJS: "JavaScript/TypeScript"}
+
+// Render returns the diagram of g in format. direction is the direction of
+// the edges: LR (left to right, the default) or TB (top to bottom).
+func Render(g *Graph, format Format, direction string) string {
+	direction = strings.ToUpper(direction)
+	if direction != "TB" {
+		direction = "LR"
+	}
+	if format == FormatDOT {
+		return renderDOT(g, direction)
+	}
+	return renderMermaid(g, direction)
+}
+
+// label returns the description shown in a node: the one written by
+// Describe, or else the package comment
+func label(n Node) string {
+	description := n.Description
+	if description == "" {
+		description = n.Doc
+	}
+	if runes := []rune(description); len(runes) > maxLabelDescription {
+		description = strings.TrimSpace(string(runes[:maxLabelDescription])) + "…"
+	}
+	return description
+}
+
+// languages returns the languages of the nodes of g, in the order of Languages
+func (g *Graph) languages() []Language {
+	seen := make(map[Language]bool)
+	for _, n := range g.Nodes {
+		seen[n.Language] = true
+	}
+	var languages []Language
+	for _, language := range Languages {
+		if seen[language] {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
+
+// renderMermaid returns g as a Mermaid flowchart. Nodes get short IDs, as
+// paths are not valid Mermaid IDs; external dependencies are rounded, and
+// each language gets a subgraph when there are several.
+func renderMermaid(g *Graph, direction string) string {
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+	escape := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+	node := func(n Node) string {
+		text := escape.Replace(n.Path)
+		if description := label(n); description != "" {
+			text += "<br/><small>" + escape.Replace(description) + "</small>"
+		}
+		if n.External {
+			return fmt.Sprintf(`%s(["%s"])`, ids[n.ID], text)
+		}
+		return fmt.Sprintf(`%s["%s"]`, ids[n.ID], text)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "graph %s\n", direction)
+	languages := g.languages()
+	for _, language := range languages {
+		indent := "  "
+		if len(languages) > 1 {
+			fmt.Fprintf(&b, "  subgraph %s [%s]\n", language, languageTitles[language])
+			indent = "    "
+		}
+		for _, n := range g.Nodes {
+			if n.Language == language {
+				fmt.Fprintf(&b, "%s%s\n", indent, node(n))
+			}
+		}
+		if len(languages) > 1 {
+			b.WriteString("  end\n")
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", ids[e.From], ids[e.To])
+	}
+	return b.String()
+}
+
+// renderDOT returns g as a Graphviz digraph, with each language in a
+// cluster when there are several and external dependencies dashed
+func renderDOT(g *Graph, direction string) string {
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n")
+	fmt.Fprintf(&b, "  rankdir=%s;\n", direction)
+	b.WriteString("  node [shape=box, fontname=\"Helvetica\", fontsize=10];\n")
+	languages := g.languages()
+	for _, language := range languages {
+		indent := "  "
+		if len(languages) > 1 {
+			fmt.Fprintf(&b, "  subgraph cluster_%s {\n    label=%s;\n", language, strconv.Quote(languageTitles[language]))
+			indent = "    "
+		}
+		for _, n := range g.Nodes {
+			if n.Language != language {
+				continue
+			}
+			text := n.Path
+			if description := label(n); description != "" {
+				text += "\n" + description
+			}
+			style := ""
+			if n.External {
+				style = ", style=\"dashed,rounded\""
+			}
+			fmt.Fprintf(&b, "%s%s [label=%s%s];\n", indent, strconv.Quote(n.ID), strconv.Quote(text), style)
+		}
+		if len(languages) > 1 {
+			b.WriteString("  }\n")
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s;\n", strconv.Quote(e.From), strconv.Quote(e.To))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Markers delimit the diagram Embed maintains in a Markdown document
+const (
+	BeginMarker = "<!-- cge:diagram -->"
+	EndMarker   = "<!-- /cge:diagram -->"
+)
+
+// Embed returns the Markdown document doc with diagram, in format, as a
+// fenced code block between the markers. A diagram embedded before is
+// replaced; without markers, the block is appended.
+func Embed(doc, diagram string, format Format) string {
+	lang := "mermaid"
+	if format == FormatDOT {
+		lang = "dot"
+	}
+	block := BeginMarker + "\n```" + lang + "\n" + strings.TrimRight(diagram, "\n") + "\n```\n" + EndMarker
+	begin := strings.Index(doc, BeginMarker)
+	end := strings.Index(doc, EndMarker)
+	if begin >= 0 && end > begin {
+		return doc[:begin] + block + doc[end+len(EndMarker):]
+	}
+	if doc != "" && !strings.HasSuffix(doc, "\n") {
+		doc += "\n"
+	}
+	if doc != "" {
+		doc += "\n"
+	}
+	return doc + block + "\n"
+}
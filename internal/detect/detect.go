@@ -0,0 +1,500 @@
+// Package detect infers what a workspace is from its manifest files: the
+// languages and frameworks it uses, how it is built, tested and linted, and
+// where its programs start. The result seeds `CGE init`, the review command
+// defaults and the agent's system prompt, so none of them has to be told what
+// the project obviously is.
+package detect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Project is what detection found in a workspace. Fields are empty when
+// nothing was found; the first language is the primary one, whose tooling
+// provides the commands.
+type Project struct {
+	Root         string
+	Languages    []string
+	Frameworks   []string
+	Manifests    []string // Manifest files found, relative to Root
+	Extensions   []string // Source file extensions of the languages
+	BuildCommand string
+	TestCommand  string
+	LintCommand  string
+	EntryPoints  []string // Files programs start from, relative to Root
+}
+
+// Empty reports whether nothing was detected
+func (p Project) Empty() bool {
+	return len(p.Languages) == 0 && p.TestCommand == "" && p.LintCommand == ""
+}
+
+// Summary describes the project in a line
+func (p Project) Summary() string {
+	languages := "no known language"
+	if len(p.Languages) > 0 {
+		languages = strings.Join(p.Languages, ", ")
+	}
+	if len(p.Frameworks) > 0 {
+		languages += " with " + strings.Join(p.Frameworks, ", ")
+	}
+	var commands []string
+	if p.TestCommand != "" {
+		commands = append(commands, "test: "+p.TestCommand)
+	}
+	if p.LintCommand != "" {
+		commands = append(commands, "lint: "+p.LintCommand)
+	}
+	if len(commands) == 0 {
+		return languages
+	}
+	return languages + " (" + strings.Join(commands, ", ") + ")"
+}
+
+// Markdown lists the findings as a markdown bullet list, for prompts and
+// rules files; empty when nothing was detected
+func (p Project) Markdown() string {
+	if p.Empty() {
+		return ""
+	}
+	var b strings.Builder
+	if len(p.Languages) > 0 {
+		fmt.Fprintf(&b, "- Languages: %s\n", strings.Join(p.Languages, ", "))
+	}
+	if len(p.Frameworks) > 0 {
+		fmt.Fprintf(&b, "- Frameworks: %s\n", strings.Join(p.Frameworks, ", "))
+	}
+	if p.BuildCommand != "" {
+		fmt.Fprintf(&b, "- Build with `%s`\n", p.BuildCommand)
+	}
+	if p.TestCommand != "" {
+		fmt.Fprintf(&b, "- Run the tests with `%s`\n", p.TestCommand)
+	}
+	if p.LintCommand != "" {
+		fmt.Fprintf(&b, "- Run the linter with `%s`\n", p.LintCommand)
+	}
+	if len(p.EntryPoints) > 0 {
+		fmt.Fprintf(&b, "- Entry points: %s\n", strings.Join(p.EntryPoints, ", "))
+	}
+	return b.String()
+}
+
+// detector inspects one kind of manifest, adding what it finds to p
+type detector struct {
+	manifest string
+	detect   func(root string, p *Project)
+}
+
+// detectors run in order of precedence: the first language found is the
+// primary one
+var detectors = []detector{
+	{"go.mod", detectGo},
+	{"Cargo.toml", detectRust},
+	{"pyproject.toml", detectPython},
+	{"setup.py", detectPython},
+	{"requirements.txt", detectPython},
+	{"package.json", detectNode},
+}
+
+// lookPath finds executables; a variable so tests do not depend on the tools
+// installed
+var lookPath = exec.LookPath
+
+// Detect inspects the workspace in root. It only reads the manifests and a
+// few well-known paths, so it is cheap enough to run on every command.
+func Detect(root string) Project {
+	p := Project{Root: root}
+	for _, d := range detectors {
+		if !isFile(filepath.Join(root, d.manifest)) {
+			continue
+		}
+		p.Manifests = append(p.Manifests, d.manifest)
+		d.detect(root, &p)
+	}
+	if target := makefileTarget(root, "build"); target != "" {
+		p.BuildCommand = target
+	}
+	if target := makefileTarget(root, "test"); target != "" {
+		p.TestCommand = target
+	}
+	if target := makefileTarget(root, "lint"); target != "" {
+		p.LintCommand = target
+	}
+	return p
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]Project) // Absolute workspace root -> project
+)
+
+// Cached is Detect, run once per workspace for the life of the process
+func Cached(root string) Project {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		absRoot = root
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if p, ok := cache[absRoot]; ok {
+		return p
+	}
+	p := Detect(absRoot)
+	cache[absRoot] = p
+	return p
+}
+
+// addLanguage records a language once, with its source extensions, reporting
+// whether it is the primary language
+func (p *Project) addLanguage(language string, extensions ...string) bool {
+	for _, known := range p.Languages {
+		if known == language {
+			return false
+		}
+	}
+	p.Languages = append(p.Languages, language)
+	p.Extensions = append(p.Extensions, extensions...)
+	return len(p.Languages) == 1
+}
+
+// addFrameworks records the frameworks whose marker dependency is in deps
+func (p *Project) addFrameworks(deps map[string]bool, markers map[string]string) {
+	var found []string
+	for dep, framework := range markers {
+		if deps[dep] && !contains(p.Frameworks, framework) && !contains(found, framework) {
+			found = append(found, framework)
+		}
+	}
+	sort.Strings(found)
+	p.Frameworks = append(p.Frameworks, found...)
+}
+
+// addEntryPoints records the paths, relative to root, that exist
+func (p *Project) addEntryPoints(root string, paths ...string) {
+	for _, path := range paths {
+		if isFile(filepath.Join(root, path)) && !contains(p.EntryPoints, filepath.ToSlash(path)) {
+			p.EntryPoints = append(p.EntryPoints, filepath.ToSlash(path))
+		}
+	}
+}
+
+// goFrameworks map Go module paths to framework names
+var goFrameworks = map[string]string{
+	"github.com/gin-gonic/gin":           "Gin",
+	"github.com/labstack/echo/v4":        "Echo",
+	"github.com/go-chi/chi/v5":           "chi",
+	"github.com/gofiber/fiber/v2":        "Fiber",
+	"github.com/spf13/cobra":             "Cobra",
+	"github.com/charmbracelet/bubbletea": "Bubble Tea",
+	"google.golang.org/grpc":             "gRPC",
+}
+
+func detectGo(root string, p *Project) {
+	if p.addLanguage("Go", ".go") {
+		p.BuildCommand = "go build ./..."
+		p.TestCommand = "go test ./..."
+		p.LintCommand = "go vet ./..."
+		if _, err := lookPath("golangci-lint"); err == nil {
+			p.LintCommand = "golangci-lint run"
+		}
+	}
+
+	deps := make(map[string]bool)
+	if content, err := os.ReadFile(filepath.Join(root, "go.mod")); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "require "))
+			if len(fields) >= 2 {
+				deps[fields[0]] = true
+			}
+		}
+	}
+	p.addFrameworks(deps, goFrameworks)
+
+	p.addEntryPoints(root, "main.go")
+	mains, _ := filepath.Glob(filepath.Join(root, "cmd", "*", "main.go"))
+	sort.Strings(mains)
+	for _, main := range mains {
+		if rel, err := filepath.Rel(root, main); err == nil {
+			p.addEntryPoints(root, rel)
+		}
+	}
+}
+
+// cargoManifest is the part of Cargo.toml detection reads
+type cargoManifest struct {
+	Dependencies map[string]any `toml:"dependencies"`
+	Workspace    *struct {
+		Members []string `toml:"members"`
+	} `toml:"workspace"`
+}
+
+// rustFrameworks map crate names to framework names
+var rustFrameworks = map[string]string{
+	"tokio":     "Tokio",
+	"actix-web": "Actix Web",
+	"axum":      "Axum",
+	"rocket":    "Rocket",
+	"clap":      "clap",
+	"tauri":     "Tauri",
+}
+
+func detectRust(root string, p *Project) {
+	var manifest cargoManifest
+	_ = readTOML(filepath.Join(root, "Cargo.toml"), &manifest)
+	if p.addLanguage("Rust", ".rs") {
+		flags := ""
+		if manifest.Workspace != nil {
+			flags = " --workspace"
+		}
+		p.BuildCommand = "cargo build" + flags
+		p.TestCommand = "cargo test" + flags
+		p.LintCommand = "cargo clippy" + flags
+	}
+
+	deps := make(map[string]bool)
+	for dep := range manifest.Dependencies {
+		deps[dep] = true
+	}
+	p.addFrameworks(deps, rustFrameworks)
+
+	p.addEntryPoints(root, filepath.Join("src", "main.rs"), filepath.Join("src", "lib.rs"))
+	bins, _ := filepath.Glob(filepath.Join(root, "src", "bin", "*.rs"))
+	sort.Strings(bins)
+	for _, bin := range bins {
+		if rel, err := filepath.Rel(root, bin); err == nil {
+			p.addEntryPoints(root, rel)
+		}
+	}
+}
+
+// pyproject is the part of pyproject.toml detection reads
+type pyproject struct {
+	Project struct {
+		Dependencies         []string            `toml:"dependencies"`
+		OptionalDependencies map[string][]string `toml:"optional-dependencies"`
+		Scripts              map[string]string   `toml:"scripts"`
+	} `toml:"project"`
+	Tool map[string]any `toml:"tool"`
+}
+
+// pythonFrameworks map distribution names to framework names
+var pythonFrameworks = map[string]string{
+	"django":  "Django",
+	"flask":   "Flask",
+	"fastapi": "FastAPI",
+	"pytest":  "pytest",
+	"torch":   "PyTorch",
+	"pandas":  "pandas",
+}
+
+func detectPython(root string, p *Project) {
+	primary := p.addLanguage("Python", ".py")
+
+	deps := make(map[string]bool)
+	tools := make(map[string]any)
+	var manifest pyproject
+	if readTOML(filepath.Join(root, "pyproject.toml"), &manifest) == nil {
+		requirements := manifest.Project.Dependencies
+		for _, extra := range manifest.Project.OptionalDependencies {
+			requirements = append(requirements, extra...)
+		}
+		for _, requirement := range requirements {
+			deps[requirementName(requirement)] = true
+		}
+		tools = manifest.Tool
+	}
+	if content, err := os.ReadFile(filepath.Join(root, "requirements.txt")); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "-") {
+				deps[requirementName(line)] = true
+			}
+		}
+	}
+	p.addFrameworks(deps, pythonFrameworks)
+
+	if primary {
+		p.TestCommand = "python -m unittest"
+		if _, ok := tools["pytest"]; ok || deps["pytest"] || isDir(filepath.Join(root, "tests")) {
+			p.TestCommand = "pytest"
+		}
+		p.LintCommand = "ruff check ."
+		if _, ok := tools["ruff"]; !ok && !deps["ruff"] && deps["flake8"] {
+			p.LintCommand = "flake8"
+		}
+		if _, ok := tools["poetry"]; ok {
+			p.TestCommand = "poetry run " + p.TestCommand
+			p.LintCommand = "poetry run " + p.LintCommand
+		}
+	}
+
+	p.addEntryPoints(root, "manage.py", "main.py", "app.py", "__main__.py")
+	for _, script := range sortedValues(manifest.Project.Scripts) {
+		// "package.module:function" points at package/module.py
+		module, _, _ := strings.Cut(script, ":")
+		path := strings.ReplaceAll(module, ".", "/") + ".py"
+		p.addEntryPoints(root, path, filepath.Join("src", path))
+	}
+}
+
+// requirementName returns the lowercased distribution name of a requirement
+// such as "Django>=4.2; python_version>'3.8'"
+func requirementName(requirement string) string {
+	end := strings.IndexAny(requirement, "<>=!~[;@ ")
+	if end >= 0 {
+		requirement = requirement[:end]
+	}
+	return strings.ToLower(strings.TrimSpace(requirement))
+}
+
+// packageJSON is the part of package.json detection reads
+type packageJSON struct {
+	Main            string            `json:"main"`
+	Bin             json.RawMessage   `json:"bin"`
+	Scripts         map[string]string `json:"scripts"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+}
+
+// nodeFrameworks map npm package names to framework names
+var nodeFrameworks = map[string]string{
+	"react":         "React",
+	"next":          "Next.js",
+	"vue":           "Vue",
+	"@angular/core": "Angular",
+	"svelte":        "Svelte",
+	"express":       "Express",
+	"@nestjs/core":  "NestJS",
+	"jest":          "Jest",
+	"vitest":        "Vitest",
+	"electron":      "Electron",
+}
+
+func detectNode(root string, p *Project) {
+	language, extensions := "JavaScript", []string{".js", ".jsx", ".mjs"}
+	if isFile(filepath.Join(root, "tsconfig.json")) {
+		language, extensions = "TypeScript", []string{".ts", ".tsx", ".js"}
+	}
+	primary := p.addLanguage(language, extensions...)
+
+	var manifest packageJSON
+	if content, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil {
+		_ = json.Unmarshal(content, &manifest)
+	}
+	deps := make(map[string]bool)
+	for dep := range manifest.Dependencies {
+		deps[dep] = true
+	}
+	for dep := range manifest.DevDependencies {
+		deps[dep] = true
+	}
+	p.addFrameworks(deps, nodeFrameworks)
+
+	if primary {
+		runner := nodeRunner(root)
+		if _, ok := manifest.Scripts["build"]; ok {
+			p.BuildCommand = runner + " run build"
+		}
+		if _, ok := manifest.Scripts["test"]; ok {
+			p.TestCommand = runner + " test"
+		}
+		if _, ok := manifest.Scripts["lint"]; ok {
+			p.LintCommand = runner + " run lint"
+		}
+	}
+
+	if manifest.Main != "" {
+		p.addEntryPoints(root, filepath.FromSlash(manifest.Main))
+	}
+	var bin string
+	var bins map[string]string
+	if json.Unmarshal(manifest.Bin, &bin) == nil && bin != "" {
+		p.addEntryPoints(root, filepath.FromSlash(bin))
+	} else if json.Unmarshal(manifest.Bin, &bins) == nil {
+		for _, path := range sortedValues(bins) {
+			p.addEntryPoints(root, filepath.FromSlash(path))
+		}
+	}
+	for _, candidate := range []string{"index.js", "index.ts", "src/index.js", "src/index.ts", "src/main.ts", "src/main.tsx", "src/App.tsx"} {
+		p.addEntryPoints(root, filepath.FromSlash(candidate))
+	}
+}
+
+// nodeRunner returns the package manager the lockfile in root belongs to
+func nodeRunner(root string) string {
+	switch {
+	case isFile(filepath.Join(root, "pnpm-lock.yaml")):
+		return "pnpm"
+	case isFile(filepath.Join(root, "yarn.lock")):
+		return "yarn"
+	case isFile(filepath.Join(root, "bun.lockb")):
+		return "bun"
+	default:
+		return "npm"
+	}
+}
+
+// makefileTarget returns the make command for target when the Makefile in
+// root defines it
+func makefileTarget(root, target string) string {
+	content, err := os.ReadFile(filepath.Join(root, "Makefile"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, target+":") {
+			return "make " + target
+		}
+	}
+	return ""
+}
+
+// readTOML decodes the TOML file at path into v
+func readTOML(path string, v any) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return toml.Unmarshal(content, v)
+}
+
+// sortedValues returns the values of m ordered by key
+func sortedValues(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = m[key]
+	}
+	return values
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,135 @@
+package detect
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates files, given by slash-separated path, under root
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+}
+
+// withoutTools makes every executable look missing for the test
+func withoutTools(t *testing.T) {
+	original := lookPath
+	lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	t.Cleanup(func() { lookPath = original })
+}
+
+func TestDetectGo(t *testing.T) {
+	withoutTools(t)
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":             "module example.com/app\n\ngo 1.22\n\nrequire (\n\tgithub.com/spf13/cobra v1.8.1\n\tgithub.com/gin-gonic/gin v1.9.0 // indirect\n)\n",
+		"cmd/server/main.go": "package main",
+		"cmd/cli/main.go":    "package main",
+	})
+
+	p := Detect(root)
+	assert.Equal(t, []string{"Go"}, p.Languages)
+	assert.Equal(t, []string{"Cobra", "Gin"}, p.Frameworks)
+	assert.Equal(t, "go test ./...", p.TestCommand)
+	assert.Equal(t, "go vet ./...", p.LintCommand)
+	assert.Equal(t, []string{"cmd/cli/main.go", "cmd/server/main.go"}, p.EntryPoints)
+	assert.Equal(t, "Go with Cobra, Gin (test: go test ./..., lint: go vet ./...)", p.Summary())
+}
+
+func TestDetectNode(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"package.json":   `{"main": "dist/index.js", "bin": {"tool": "bin/tool.js"}, "scripts": {"test": "vitest", "lint": "eslint ."}, "dependencies": {"react": "^18"}, "devDependencies": {"vitest": "^1"}}`,
+		"tsconfig.json":  "{}",
+		"pnpm-lock.yaml": "",
+		"bin/tool.js":    "",
+		"src/index.ts":   "",
+	})
+
+	p := Detect(root)
+	assert.Equal(t, []string{"TypeScript"}, p.Languages)
+	assert.Equal(t, []string{"React", "Vitest"}, p.Frameworks)
+	assert.Equal(t, "pnpm test", p.TestCommand)
+	assert.Equal(t, "pnpm run lint", p.LintCommand)
+	assert.Empty(t, p.BuildCommand, "there is no build script")
+	assert.Equal(t, []string{"bin/tool.js", "src/index.ts"}, p.EntryPoints, "main is not built yet")
+}
+
+func TestDetectPython(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"pyproject.toml":    "[project]\ndependencies = [\"Django>=4.2\", \"requests\"]\n\n[project.scripts]\nserve = \"app.cli:main\"\n\n[tool.ruff]\nline-length = 100\n",
+		"requirements.txt":  "# pinned\nfastapi==0.110\n-r dev.txt\n",
+		"app/cli.py":        "",
+		"manage.py":         "",
+		"tests/test_app.py": "",
+	})
+
+	p := Detect(root)
+	assert.Equal(t, []string{"Python"}, p.Languages)
+	assert.Equal(t, []string{"pyproject.toml", "requirements.txt"}, p.Manifests)
+	assert.Equal(t, []string{"Django", "FastAPI"}, p.Frameworks)
+	assert.Equal(t, "pytest", p.TestCommand, "a tests directory means pytest")
+	assert.Equal(t, "ruff check .", p.LintCommand)
+	assert.Equal(t, []string{"manage.py", "app/cli.py"}, p.EntryPoints)
+}
+
+func TestDetectRustWorkspace(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"Cargo.toml":  "[workspace]\nmembers = [\"core\"]\n\n[dependencies]\naxum = \"0.7\"\ntokio = { version = \"1\", features = [\"full\"] }\n",
+		"src/main.rs": "",
+	})
+
+	p := Detect(root)
+	assert.Equal(t, []string{"Rust"}, p.Languages)
+	assert.Equal(t, []string{"Axum", "Tokio"}, p.Frameworks)
+	assert.Equal(t, "cargo test --workspace", p.TestCommand)
+	assert.Equal(t, []string{"src/main.rs"}, p.EntryPoints)
+}
+
+func TestDetectMixedProject(t *testing.T) {
+	withoutTools(t)
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":       "module example.com/app\n",
+		"package.json": `{"scripts": {"test": "jest"}}`,
+		"Makefile":     "build:\n\tgo build\n\ntest: build\n\tgo test ./...\n",
+	})
+
+	p := Detect(root)
+	assert.Equal(t, []string{"Go", "JavaScript"}, p.Languages, "go.mod makes Go the primary language")
+	assert.Equal(t, "make test", p.TestCommand, "Makefile targets win")
+	assert.Equal(t, "make build", p.BuildCommand)
+	assert.Equal(t, "go vet ./...", p.LintCommand, "only the primary language provides commands")
+}
+
+func TestDetectNothing(t *testing.T) {
+	p := Detect(t.TempDir())
+	assert.True(t, p.Empty())
+	assert.Empty(t, p.Markdown())
+	assert.Equal(t, "no known language", p.Summary())
+}
+
+func TestCached(t *testing.T) {
+	root := t.TempDir()
+	assert.True(t, Cached(root).Empty())
+	writeFiles(t, root, map[string]string{"Cargo.toml": ""})
+	assert.True(t, Cached(root).Empty(), "detection runs once per workspace")
+	assert.False(t, Detect(root).Empty())
+}
+
+func TestRequirementName(t *testing.T) {
+	assert.Equal(t, "django", requirementName("Django>=4.2; python_version>'3.8'"))
+	assert.Equal(t, "uvicorn", requirementName("uvicorn[standard]"))
+	assert.Equal(t, "flask", requirementName("flask"))
+}
@@ -2,6 +2,7 @@ package di
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"path/filepath"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/castrovroberto/CGE/internal/agent"
 	"github.com/castrovroberto/CGE/internal/config"
 	contextutil "github.com/castrovroberto/CGE/internal/context"
+	"github.com/castrovroberto/CGE/internal/httpclient"
 	"github.com/castrovroberto/CGE/internal/llm"
 	"github.com/castrovroberto/CGE/internal/orchestrator"
 	"github.com/castrovroberto/CGE/internal/tui/chat"
@@ -22,6 +24,8 @@ type Container struct {
 	httpClient       HTTPClient
 	sessionStore     SessionStore
 	absWorkspaceRoot string // Always absolute workspace root
+	toolRoot         string // Directory tools are restricted to: the scope of the run, or the workspace root
+	pullConfirm      llm.PullConfirmFunc
 
 	// Services (built lazily)
 	llmClient         llm.Client
@@ -30,8 +34,9 @@ type Container struct {
 	contextIntegrator *contextutil.ContextIntegrator
 }
 
-// NewContainer creates a new dependency injection container
-func NewContainer(cfg *config.AppConfig) *Container {
+// NewContainer creates a new dependency injection container. It fails when
+// the scope of the configuration is not a directory inside the workspace.
+func NewContainer(cfg *config.AppConfig) (*Container, error) {
 	// Ensure workspace root is absolute
 	workspaceRoot := cfg.Project.WorkspaceRoot
 	if workspaceRoot == "" {
@@ -40,19 +45,23 @@ func NewContainer(cfg *config.AppConfig) *Container {
 
 	absWorkspaceRoot, err := filepath.Abs(workspaceRoot)
 	if err != nil {
-		// Fallback to original if conversion fails
-		absWorkspaceRoot = workspaceRoot
+		return nil, fmt.Errorf("failed to resolve the workspace root: %w", err)
+	}
+	toolRoot, err := cfg.ScopeRoot()
+	if err != nil {
+		return nil, err
 	}
 
 	return &Container{
 		config:           cfg,
 		absWorkspaceRoot: absWorkspaceRoot,
+		toolRoot:         toolRoot,
 		// Use real implementations by default
 		fileSystem:   &RealFileSystemService{},
 		cmdExecutor:  &RealCommandExecutor{},
-		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		httpClient:   &http.Client{Transport: httpclient.Default().Transport, Timeout: 30 * time.Second},
 		sessionStore: NewRealSessionStore(),
-	}
+	}, nil
 }
 
 // WithFileSystem allows injection of custom FileSystemService (for testing)
@@ -79,6 +88,13 @@ func (c *Container) WithSessionStore(store SessionStore) *Container {
 	return c
 }
 
+// WithModelPullConfirm makes the LLM client pull models missing on the server
+// when confirm agrees, then retry the request
+func (c *Container) WithModelPullConfirm(confirm llm.PullConfirmFunc) *Container {
+	c.pullConfirm = confirm
+	return c
+}
+
 // GetContextIntegrator returns the configured context integrator
 func (c *Container) GetContextIntegrator() *contextutil.ContextIntegrator {
 	if c.contextIntegrator == nil {
@@ -92,6 +108,12 @@ func (c *Container) GetAbsoluteWorkspaceRoot() string {
 	return c.absWorkspaceRoot
 }
 
+// GetToolRoot returns the absolute directory tools and retrieval are
+// restricted to
+func (c *Container) GetToolRoot() string {
+	return c.toolRoot
+}
+
 // GetLLMClient returns the configured LLM client
 func (c *Container) GetLLMClient() llm.Client {
 	if c.llmClient == nil {
@@ -100,6 +122,22 @@ func (c *Container) GetLLMClient() llm.Client {
 	return c.llmClient
 }
 
+// GetModelLoader returns a client loading and unloading the models of the
+// configured provider, nil when the provider does not keep models loaded
+func (c *Container) GetModelLoader() llm.ModelLoader {
+	loader, _ := c.buildProviderClient().(llm.ModelLoader)
+	return loader
+}
+
+// FitLLMClient checks that modelName can serve a workflow needing req and
+// makes the LLM client fall back where it cannot, returning a warning for
+// each missing capability
+func (c *Container) FitLLMClient(modelName string, req llm.Requirements) []string {
+	client, warnings := llm.FitToWorkflow(c.GetLLMClient(), c.config.LLM.Provider, modelName, c.config.LLM.ModelCapabilities, req)
+	c.llmClient = client
+	return warnings
+}
+
 // GetToolRegistry returns the configured tool registry
 func (c *Container) GetToolRegistry() *agent.Registry {
 	if c.toolRegistry == nil {
@@ -158,8 +196,18 @@ func (c *Container) GetChatPresenterWithContext(ctx context.Context, modelName,
 	)
 }
 
-// buildLLMClient creates the appropriate LLM client based on configuration
+// buildLLMClient creates the appropriate LLM client based on configuration,
+// limited to the configured number of requests in flight
 func (c *Container) buildLLMClient() llm.Client {
+	client := c.buildProviderClient()
+	if puller, ok := client.(llm.ModelPuller); ok && c.pullConfirm != nil {
+		client = llm.NewModelRecoveryClient(client, puller, c.pullConfirm, nil)
+	}
+	return llm.WithConcurrencyLimits(client, c.config.GetConcurrencyConfig())
+}
+
+// buildProviderClient creates the client of the configured provider
+func (c *Container) buildProviderClient() llm.Client {
 	switch c.config.LLM.Provider {
 	case "ollama":
 		config := c.config.GetOllamaConfig()
@@ -179,12 +227,14 @@ func (c *Container) buildLLMClient() llm.Client {
 
 // buildToolRegistry creates a tool registry with dependency injection
 func (c *Container) buildToolRegistry() *agent.Registry {
-	// Create enhanced tool factory with dependency injection using absolute workspace root
+	// Create enhanced tool factory with dependency injection, rooted at the scope of the run
 	enhancedFactory := agent.NewEnhancedToolFactory(
-		c.absWorkspaceRoot,
+		c.toolRoot,
 		c.fileSystem,
 		c.cmdExecutor,
 	)
+	enhancedFactory.SetDatabaseConfig(c.config.GetDatabaseToolConfig())
+	enhancedFactory.SetDockerConfig(c.config.GetDockerToolConfig())
 
 	return enhancedFactory.CreateGenerationRegistry()
 }
@@ -203,7 +253,7 @@ func (c *Container) buildAgentRunner(systemPrompt, modelName string) *orchestrat
 
 // buildContextIntegrator creates a context integrator with the tool registry
 func (c *Container) buildContextIntegrator() *contextutil.ContextIntegrator {
-	return contextutil.NewContextIntegrator(c.absWorkspaceRoot, c.GetToolRegistry())
+	return contextutil.NewContextIntegrator(c.toolRoot, c.GetToolRegistry())
 }
 
 // Config returns the application configuration
@@ -2,6 +2,8 @@ package di
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/castrovroberto/CGE/internal/agent"
@@ -17,7 +19,8 @@ func TestContainer_DependencyInjection(t *testing.T) {
 		cfg.LLM.Model = "test-model"
 		cfg.Project.WorkspaceRoot = "/tmp/test"
 
-		container := NewContainer(cfg)
+		container, err := NewContainer(cfg)
+		require.NoError(t, err)
 
 		// Test that we can get an LLM client
 		llmClient := container.GetLLMClient()
@@ -39,7 +42,9 @@ func TestContainer_DependencyInjection(t *testing.T) {
 		cfg.Project.WorkspaceRoot = "/tmp/test"
 
 		// Create container with mock dependencies
-		container := NewContainer(cfg).
+		container, err := NewContainer(cfg)
+		require.NoError(t, err)
+		container.
 			WithFileSystem(NewMockFileSystemService()).
 			WithCommandExecutor(NewMockCommandExecutor()).
 			WithHTTPClient(NewMockHTTPClient()).
@@ -55,6 +60,31 @@ func TestContainer_DependencyInjection(t *testing.T) {
 	})
 }
 
+func TestContainer_Scope(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "services", "billing"), 0755))
+	cfg := &config.AppConfig{}
+	cfg.Project.WorkspaceRoot = workspace
+
+	container, err := NewContainer(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, workspace, container.GetToolRoot(), "unscoped runs use the workspace root")
+
+	cfg.Project.Scope = "services/billing"
+	container, err = NewContainer(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(workspace, "services", "billing"), container.GetToolRoot())
+	assert.Equal(t, workspace, container.GetAbsoluteWorkspaceRoot(), "state stays in the workspace")
+
+	cfg.Project.Scope = "../elsewhere"
+	_, err = NewContainer(cfg)
+	assert.ErrorContains(t, err, "outside the workspace", "an invalid scope is reported, not ignored")
+
+	cfg.Project.Scope = "services/missing"
+	_, err = cfg.ScopeRoot()
+	assert.Error(t, err)
+}
+
 func TestMockFileSystemService(t *testing.T) {
 	fs := NewMockFileSystemService()
 
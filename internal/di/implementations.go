@@ -47,6 +47,10 @@ func (r *RealFileSystemService) IsDir(path string) bool {
 	return err == nil && stat.IsDir()
 }
 
+func (r *RealFileSystemService) Chmod(path string, mode os.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
 // RealCommandExecutor implements CommandExecutor using exec package
 type RealCommandExecutor struct{}
 
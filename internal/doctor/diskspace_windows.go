@@ -0,0 +1,19 @@
+//go:build windows
+
+package doctor
+
+import "golang.org/x/sys/windows"
+
+// freeDiskSpace returns the bytes available to the current user on the volume
+// holding dir
+func freeDiskSpace(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &free, nil, nil); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
@@ -0,0 +1,285 @@
+// Package doctor checks that the environment CGE runs in is usable: the LLM
+// provider answers, embeddings work, and the tools and disk space the commands
+// rely on are available. Each check returns a Result with a remediation tip
+// when something is wrong.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	StatusOK   Status = iota
+	StatusWarn        // Usable, but a feature is degraded
+	StatusFail        // A command relying on this will fail
+)
+
+// String returns the status label used in reports
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusWarn:
+		return "warn"
+	default:
+		return "fail"
+	}
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name    string
+	Status  Status
+	Detail  string        // What was found
+	Remedy  string        // How to fix a warning or failure
+	Latency time.Duration // Round trip of successful network checks; 0 otherwise
+}
+
+// Check runs one health check
+type Check func(ctx context.Context) Result
+
+// Run runs checks in order and returns their results
+func Run(ctx context.Context, checks ...Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		results = append(results, check(ctx))
+	}
+	return results
+}
+
+// Failed reports whether any result failed
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// probeTimeout bounds each network probe, so an unreachable host does not
+// stall the report
+const probeTimeout = 15 * time.Second
+
+// ProviderCheck lists the provider's models, measuring the round trip, and
+// verifies model is among them
+func ProviderCheck(client llm.Client, provider, model string) Check {
+	return func(ctx context.Context) Result {
+		result := Result{Name: fmt.Sprintf("LLM provider (%s)", provider)}
+
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		start := time.Now()
+		models, err := client.ListAvailableModels(ctx)
+		if err != nil {
+			result.Status = StatusFail
+			result.Detail = fmt.Sprintf("cannot list models: %v", err)
+			result.Remedy = providerRemedy(provider)
+			return result
+		}
+		result.Latency = time.Since(start)
+
+		if !hasModel(models, model) {
+			result.Status = StatusWarn
+			result.Detail = fmt.Sprintf("reachable, but model %q is not available", model)
+			result.Remedy = missingModelRemedy(provider, model)
+			return result
+		}
+		result.Detail = fmt.Sprintf("reachable, model %q available", model)
+		return result
+	}
+}
+
+// hasModel reports whether model is in models. Ollama lists models with their
+// tag, so "llama3" matches "llama3:latest".
+func hasModel(models []string, model string) bool {
+	for _, m := range models {
+		if m == model || m == model+":latest" || strings.TrimPrefix(m, "models/") == model {
+			return true
+		}
+	}
+	return false
+}
+
+func providerRemedy(provider string) string {
+	switch provider {
+	case "ollama":
+		return "Start the server with `ollama serve` and check llm.ollama_host_url in codex.toml"
+	case "openai":
+		return "Check that OPENAI_API_KEY is set and valid, and that the API is reachable from this network"
+	case "gemini":
+		return "Check that GEMINI_API_KEY is set and valid, and that the API is reachable from this network"
+	default:
+		return "Check the [llm] section of codex.toml"
+	}
+}
+
+func missingModelRemedy(provider, model string) string {
+	if provider == "ollama" {
+		return fmt.Sprintf("Run `ollama pull %s`, or pass --pull to download it when first used", model)
+	}
+	return "Set llm.model in codex.toml to a model your account can use"
+}
+
+// EmbeddingCheck embeds a short text, measuring the round trip. Semantic
+// search and `CGE index` need embeddings.
+func EmbeddingCheck(client llm.Client) Check {
+	return func(ctx context.Context) Result {
+		result := Result{Name: "Embeddings"}
+		if !client.SupportsEmbeddings() {
+			result.Status = StatusWarn
+			result.Detail = "the provider does not support embeddings"
+			result.Remedy = "Semantic search and `CGE index` are unavailable; switch to ollama or openai to use them"
+			return result
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		start := time.Now()
+		embedding, err := client.Embed(ctx, "CGE doctor")
+		if err != nil {
+			result.Status = StatusFail
+			result.Detail = fmt.Sprintf("embedding request failed: %v", err)
+			result.Remedy = "Check that the embedding model is installed (for Ollama: `ollama pull nomic-embed-text`)"
+			return result
+		}
+		result.Latency = time.Since(start)
+		result.Detail = fmt.Sprintf("%d dimensions", len(embedding))
+		return result
+	}
+}
+
+// GitCheck verifies git is installed and workspaceRoot is a repository
+func GitCheck(workspaceRoot string) Check {
+	return func(ctx context.Context) Result {
+		result := Result{Name: "Git"}
+		path, err := exec.LookPath("git")
+		if err != nil {
+			result.Status = StatusFail
+			result.Detail = "git not found in PATH"
+			result.Remedy = "Install git; the git tools and review diffs need it"
+			return result
+		}
+
+		cmd := exec.CommandContext(ctx, path, "rev-parse", "--is-inside-work-tree") // #nosec G204 - fixed arguments
+		cmd.Dir = workspaceRoot
+		if err := cmd.Run(); err != nil {
+			result.Status = StatusWarn
+			result.Detail = fmt.Sprintf("%s is not a git repository", workspaceRoot)
+			result.Remedy = "Run `git init` so changes made by the agent can be reviewed and reverted"
+			return result
+		}
+		result.Detail = path
+		return result
+	}
+}
+
+// CommandCheck verifies the executable of a configured command, such as the
+// review test command, resolves in PATH or the workspace
+func CommandCheck(name, command, configKey, workspaceRoot string) Check {
+	return func(ctx context.Context) Result {
+		result := Result{Name: name}
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			result.Status = StatusWarn
+			result.Detail = "not configured"
+			result.Remedy = fmt.Sprintf("Set %s in codex.toml", configKey)
+			return result
+		}
+
+		executable := fields[0]
+		if strings.ContainsRune(executable, filepath.Separator) && !filepath.IsAbs(executable) {
+			executable = filepath.Join(workspaceRoot, executable)
+		}
+		if _, err := exec.LookPath(executable); err != nil {
+			result.Status = StatusFail
+			result.Detail = fmt.Sprintf("%q does not resolve: %v", fields[0], err)
+			result.Remedy = fmt.Sprintf("Install %s or change %s in codex.toml", fields[0], configKey)
+			return result
+		}
+		result.Detail = command
+		return result
+	}
+}
+
+// DiskSpaceCheck verifies the file system holding dir has at least minFree
+// bytes available. dir need not exist yet; its nearest existing parent is
+// checked.
+func DiskSpaceCheck(name, dir string, minFree uint64) Check {
+	return func(ctx context.Context) Result {
+		result := Result{Name: name}
+		existing, err := nearestExistingDir(dir)
+		if err != nil {
+			result.Status = StatusFail
+			result.Detail = err.Error()
+			result.Remedy = fmt.Sprintf("Check that %s can be created", dir)
+			return result
+		}
+
+		free, err := freeDiskSpace(existing)
+		if err != nil {
+			result.Status = StatusWarn
+			result.Detail = fmt.Sprintf("cannot determine free space: %v", err)
+			return result
+		}
+		if free < minFree {
+			result.Status = StatusFail
+			result.Detail = fmt.Sprintf("%s free at %s, %s needed", formatBytes(free), dir, formatBytes(minFree))
+			result.Remedy = "Free up disk space or remove old data under .cge"
+			return result
+		}
+		result.Detail = fmt.Sprintf("%s free at %s", formatBytes(free), dir)
+		return result
+	}
+}
+
+// nearestExistingDir returns dir or its closest ancestor that exists
+func nearestExistingDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return "", fmt.Errorf("%s is not a directory", dir)
+			}
+			return dir, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", err
+		}
+		dir = parent
+	}
+}
+
+// formatBytes formats n with a binary unit, e.g. "1.5 GiB"
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
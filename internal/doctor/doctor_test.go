@@ -0,0 +1,101 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClient implements the llm.Client methods the checks use
+type fakeClient struct {
+	llm.Client
+	models     []string
+	listErr    error
+	embeddings bool
+	embedErr   error
+}
+
+func (c *fakeClient) ListAvailableModels(ctx context.Context) ([]string, error) {
+	return c.models, c.listErr
+}
+
+func (c *fakeClient) SupportsEmbeddings() bool {
+	return c.embeddings
+}
+
+func (c *fakeClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	if c.embedErr != nil {
+		return nil, c.embedErr
+	}
+	return make([]float32, 768), nil
+}
+
+func TestProviderCheck(t *testing.T) {
+	ctx := context.Background()
+
+	result := ProviderCheck(&fakeClient{models: []string{"llama3:latest"}}, "ollama", "llama3")(ctx)
+	assert.Equal(t, StatusOK, result.Status)
+
+	result = ProviderCheck(&fakeClient{models: []string{"mistral:7b"}}, "ollama", "llama3")(ctx)
+	assert.Equal(t, StatusWarn, result.Status)
+	assert.Contains(t, result.Remedy, "ollama pull llama3")
+
+	result = ProviderCheck(&fakeClient{listErr: errors.New("connection refused")}, "ollama", "llama3")(ctx)
+	assert.Equal(t, StatusFail, result.Status)
+	assert.Contains(t, result.Remedy, "ollama serve")
+}
+
+func TestEmbeddingCheck(t *testing.T) {
+	ctx := context.Background()
+
+	result := EmbeddingCheck(&fakeClient{embeddings: true})(ctx)
+	assert.Equal(t, StatusOK, result.Status)
+	assert.Equal(t, "768 dimensions", result.Detail)
+
+	result = EmbeddingCheck(&fakeClient{})(ctx)
+	assert.Equal(t, StatusWarn, result.Status)
+
+	result = EmbeddingCheck(&fakeClient{embeddings: true, embedErr: errors.New("model not found")})(ctx)
+	assert.Equal(t, StatusFail, result.Status)
+}
+
+func TestCommandCheck(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	assert.Equal(t, StatusWarn, CommandCheck("Test command", "", "commands.review.test_command", root)(ctx).Status)
+	assert.Equal(t, StatusFail, CommandCheck("Test command", "no-such-tool-cge ./...", "commands.review.test_command", root)(ctx).Status)
+
+	script := filepath.Join(root, "scripts", "test.sh")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(script), 0755))
+	assert.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\n"), 0755))
+	assert.Equal(t, StatusOK, CommandCheck("Test command", "scripts/test.sh -v", "commands.review.test_command", root)(ctx).Status)
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), ".cge", "index") // Not created yet
+
+	assert.Equal(t, StatusOK, DiskSpaceCheck("Index directory", dir, 1)(ctx).Status)
+	assert.Equal(t, StatusFail, DiskSpaceCheck("Index directory", dir, 1<<62)(ctx).Status)
+}
+
+func TestWriteReport(t *testing.T) {
+	results := []Result{
+		{Name: "Git", Status: StatusOK, Detail: "/usr/bin/git"},
+		{Name: "Lint command", Status: StatusFail, Detail: "not found", Remedy: "Install golangci-lint"},
+	}
+
+	var out bytes.Buffer
+	WriteReport(&out, results)
+	assert.Contains(t, out.String(), "Git: /usr/bin/git")
+	assert.Contains(t, out.String(), "Install golangci-lint")
+	assert.Contains(t, out.String(), "1 check(s) failed")
+	assert.True(t, Failed(results))
+}
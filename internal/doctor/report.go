@@ -0,0 +1,54 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	okStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	warnStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	failStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	tipStyle  = lipgloss.NewStyle().Faint(true)
+)
+
+// WriteReport prints one line per result, colored green, yellow or red, with
+// the remediation tip below each warning or failure, followed by a summary
+func WriteReport(w io.Writer, results []Result) {
+	var warnings, failures int
+	for _, r := range results {
+		var mark string
+		switch r.Status {
+		case StatusOK:
+			mark = okStyle.Render("✓")
+		case StatusWarn:
+			mark = warnStyle.Render("!")
+			warnings++
+		default:
+			mark = failStyle.Render("✗")
+			failures++
+		}
+
+		line := fmt.Sprintf("%s %s: %s", mark, r.Name, r.Detail)
+		if r.Latency > 0 {
+			line += fmt.Sprintf(" (%s)", r.Latency.Round(time.Millisecond))
+		}
+		fmt.Fprintln(w, line)
+		if r.Status != StatusOK && r.Remedy != "" {
+			fmt.Fprintln(w, tipStyle.Render("    → "+r.Remedy))
+		}
+	}
+
+	fmt.Fprintln(w)
+	switch {
+	case failures > 0:
+		fmt.Fprintln(w, failStyle.Render(fmt.Sprintf("%d check(s) failed, %d warning(s)", failures, warnings)))
+	case warnings > 0:
+		fmt.Fprintln(w, warnStyle.Render(fmt.Sprintf("All checks passed with %d warning(s)", warnings)))
+	default:
+		fmt.Fprintln(w, okStyle.Render("All checks passed"))
+	}
+}
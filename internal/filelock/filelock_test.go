@@ -0,0 +1,36 @@
+package filelock
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTryLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.lock")
+
+	locked, err := IsLocked(path)
+	require.NoError(t, err)
+	assert.False(t, locked)
+	assert.NoFileExists(t, path, "checking does not create the lock file")
+
+	lock, err := TryLock(path)
+	require.NoError(t, err)
+
+	_, err = TryLock(path)
+	assert.ErrorIs(t, err, ErrLocked)
+	locked, err = IsLocked(path)
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	require.NoError(t, lock.Unlock())
+	locked, err = IsLocked(path)
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	relock, err := TryLock(path)
+	require.NoError(t, err)
+	require.NoError(t, relock.Unlock())
+}
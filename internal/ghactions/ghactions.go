@@ -0,0 +1,211 @@
+// Package ghactions writes GitHub Actions workflow commands. Printed on their
+// own line in the log of a step, "::error file=...,line=...::message" and its
+// warning and notice variants become annotations, which GitHub shows on the
+// run and inline on the files of a pull request.
+package ghactions
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Levels of annotations
+const (
+	Error   = "error"
+	Warning = "warning"
+	Notice  = "notice"
+)
+
+// Running reports whether the process runs in a GitHub Actions workflow
+func Running() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// Annotation is a message attached to the run, and to a line of a file when
+// File is set
+type Annotation struct {
+	Level   string // Error, Warning or Notice
+	File    string // Relative to the root of the repository
+	Line    int
+	Column  int
+	Title   string
+	Message string
+}
+
+// String returns the workflow command creating the annotation
+func (a Annotation) String() string {
+	var props []string
+	if a.File != "" {
+		props = append(props, "file="+escapeProperty(a.File))
+		if a.Line > 0 {
+			props = append(props, "line="+strconv.Itoa(a.Line))
+			if a.Column > 0 {
+				props = append(props, "col="+strconv.Itoa(a.Column))
+			}
+		}
+	}
+	if a.Title != "" {
+		props = append(props, "title="+escapeProperty(a.Title))
+	}
+	level := a.Level
+	if level == "" {
+		level = Error
+	}
+	command := "::" + level
+	if len(props) > 0 {
+		command += " " + strings.Join(props, ",")
+	}
+	return command + "::" + escapeData(a.Message)
+}
+
+// LintLevel returns the level of an annotation for a linter severity
+func LintLevel(severity string) string {
+	switch strings.ToLower(severity) {
+	case "warning":
+		return Warning
+	case "info", "note", "notice":
+		return Notice
+	}
+	return Error
+}
+
+// escapeData escapes the message of a workflow command, so it can span lines
+func escapeData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// escapeProperty escapes a property value, which ends at a comma or colon
+func escapeProperty(s string) string {
+	s = escapeData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
+
+// TestFailure is a failure found in the output of go test
+type TestFailure struct {
+	Package string // Import path of the package, when go test named it
+	Test    string // Failing test; empty for a build error
+	// File is the file as go printed it: relative to the package directory
+	// for test failures, to the directory go test ran in for build errors.
+	// Empty when the failure names no line.
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+var (
+	goTestRun        = regexp.MustCompile(`^=== (?:RUN|CONT|NAME)\s+(\S+)`)
+	goTestOutcome    = regexp.MustCompile(`^\s*--- (FAIL|PASS|SKIP):\s+(\S+)`)
+	goTestLog        = regexp.MustCompile(`^(\s+)([^\s:]+\.go):(\d+): (.*)$`)
+	goBuildHeader    = regexp.MustCompile(`^# (\S+)`)
+	goBuildError     = regexp.MustCompile(`^([^\s:]+\.go):(\d+):(?:(\d+):)? (.*)$`)
+	goPackageOutcome = regexp.MustCompile(`^(?:FAIL|ok)\s+(\S+)`)
+)
+
+// ParseGoTest returns the failures in the output of go test, verbose or not:
+// build errors, and the messages failing tests logged with the line that
+// logged them. A failing test that logged nothing, nor had a subtest fail,
+// is returned without a file.
+func ParseGoTest(output string) []TestFailure {
+	var failures []TestFailure
+	pending := map[string][]TestFailure{} // Logged by running tests, which may still pass
+	var failed []string                   // Tests of the package that failed
+	packageStart := 0                     // Index of the first failure of the package
+	var current, buildPackage string
+	currentFailed := false
+	var last *TestFailure // Failure continuation lines are added to
+	lastIndent := 0
+
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n") {
+		if match := goBuildHeader.FindStringSubmatch(line); match != nil {
+			buildPackage, current, last = match[1], "", nil
+			continue
+		}
+		if match := goBuildError.FindStringSubmatch(line); match != nil && buildPackage != "" {
+			lineNo, _ := strconv.Atoi(match[2])
+			column, _ := strconv.Atoi(match[3])
+			failures = append(failures, TestFailure{Package: buildPackage, File: match[1], Line: lineNo, Column: column, Message: match[4]})
+			last = nil
+			continue
+		}
+		if match := goTestRun.FindStringSubmatch(line); match != nil {
+			current, currentFailed, buildPackage, last = match[1], false, "", nil
+			continue
+		}
+		if match := goTestOutcome.FindStringSubmatch(line); match != nil {
+			// Without -v, what a test logged follows its outcome
+			current, currentFailed, last = match[2], match[1] == "FAIL", nil
+			if currentFailed {
+				failed = append(failed, current)
+				failures = append(failures, pending[current]...)
+			}
+			delete(pending, current)
+			continue
+		}
+		if match := goTestLog.FindStringSubmatch(line); match != nil && current != "" {
+			lineNo, _ := strconv.Atoi(match[3])
+			failure := TestFailure{Test: current, File: match[2], Line: lineNo, Message: match[4]}
+			if currentFailed {
+				failures = append(failures, failure)
+				last = &failures[len(failures)-1]
+			} else {
+				pending[current] = append(pending[current], failure)
+				last = &pending[current][len(pending[current])-1]
+			}
+			lastIndent = len(match[1])
+			continue
+		}
+		if match := goPackageOutcome.FindStringSubmatch(line); match != nil {
+			for _, test := range failed {
+				if !explained(failures[packageStart:], test) {
+					failures = append(failures, TestFailure{Test: test, Message: test + " failed"})
+				}
+			}
+			for i := packageStart; i < len(failures); i++ {
+				if failures[i].Package == "" {
+					failures[i].Package = match[1]
+				}
+			}
+			packageStart, failed = len(failures), nil
+			current, currentFailed, buildPackage, last = "", false, "", nil
+			pending = map[string][]TestFailure{}
+			continue
+		}
+		if last != nil && indent(line) > lastIndent {
+			last.Message += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		last = nil
+	}
+	return failures
+}
+
+// explained reports whether failures has one of test or of its subtests
+func explained(failures []TestFailure, test string) bool {
+	for _, failure := range failures {
+		if failure.Test == test || strings.HasPrefix(failure.Test, test+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// indent returns the width of the leading whitespace of line
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// Summary returns the last lines of output, for annotations of failures that
+// could not be parsed
+func Summary(output string, lines int) string {
+	all := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
@@ -0,0 +1,67 @@
+package ghactions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotationString(t *testing.T) {
+	assert.Equal(t, "::error file=internal/a.go,line=12,col=3,title=go vet::unreachable code",
+		Annotation{Level: Error, File: "internal/a.go", Line: 12, Column: 3, Title: "go vet", Message: "unreachable code"}.String())
+	assert.Equal(t, "::warning title=Tests%3A 50%25%2C flaky::first%0Asecond",
+		Annotation{Level: Warning, Title: "Tests: 50%, flaky", Message: "first\nsecond"}.String(), "properties and messages are escaped")
+	assert.Equal(t, "::error::no file", Annotation{Message: "no file"}.String())
+	assert.Equal(t, Notice, LintLevel("info"))
+	assert.Equal(t, Error, LintLevel(""))
+}
+
+func TestParseGoTest(t *testing.T) {
+	quiet := "--- FAIL: TestA (0.00s)\n" +
+		"    p_test.go:7: want 1,\n" +
+		"        got 2\n" +
+		"--- FAIL: TestB (0.00s)\n" +
+		"    --- FAIL: TestB/sub (0.00s)\n" +
+		"        p_test.go:11: boom\n" +
+		"--- FAIL: TestD (0.00s)\n" +
+		"FAIL\n" +
+		"FAIL\texample.com/gt/p\t0.003s\n" +
+		"ok  \texample.com/gt/q\t0.002s\n" +
+		"FAIL\n"
+	verbose := "=== RUN   TestA\n" +
+		"    p_test.go:7: want 1,\n" +
+		"        got 2\n" +
+		"--- FAIL: TestA (0.00s)\n" +
+		"=== RUN   TestB\n" +
+		"=== RUN   TestB/sub\n" +
+		"    p_test.go:11: boom\n" +
+		"--- FAIL: TestB (0.00s)\n" +
+		"    --- FAIL: TestB/sub (0.00s)\n" +
+		"=== RUN   TestC\n" +
+		"    p_test.go:14: logged by a passing test\n" +
+		"--- PASS: TestC (0.00s)\n" +
+		"=== RUN   TestD\n" +
+		"--- FAIL: TestD (0.00s)\n" +
+		"FAIL\n" +
+		"FAIL\texample.com/gt/p\t0.003s\n" +
+		"FAIL\n"
+	want := []TestFailure{
+		{Package: "example.com/gt/p", Test: "TestA", File: "p_test.go", Line: 7, Message: "want 1,\ngot 2"},
+		{Package: "example.com/gt/p", Test: "TestB/sub", File: "p_test.go", Line: 11, Message: "boom"},
+		{Package: "example.com/gt/p", Test: "TestD", Message: "TestD failed"},
+	}
+	assert.Equal(t, want, ParseGoTest(quiet))
+	assert.Equal(t, want, ParseGoTest(verbose))
+
+	build := "# example.com/gt/p [example.com/gt/p.test]\n" +
+		"p/p.go:3:23: undefined: y\n" +
+		"FAIL\texample.com/gt/p [build failed]\n" +
+		"FAIL\n"
+	assert.Equal(t, []TestFailure{{Package: "example.com/gt/p", File: "p/p.go", Line: 3, Column: 23, Message: "undefined: y"}}, ParseGoTest(build))
+	assert.Empty(t, ParseGoTest("ok  \texample.com/gt/p\t0.003s\n"))
+}
+
+func TestSummary(t *testing.T) {
+	assert.Equal(t, "c\nd", Summary("a\nb\nc\nd\n", 2))
+	assert.Equal(t, "a", Summary("a\n", 5))
+}
@@ -0,0 +1,287 @@
+// Package hooks runs user-configured shell commands and webhooks on the
+// lifecycle events of agent runs - a run starting or completing, tool calls,
+// files being written and tests failing - passing each the event as JSON.
+// Hooks marked blocking can refuse a run or a tool call, which makes them a
+// place to enforce local policies.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/httpclient"
+)
+
+// Events hooks can subscribe to
+const (
+	RunStarted   = "run_started"   // Before the first LLM call of a run
+	RunCompleted = "run_completed" // After a run ended, successfully or not
+	PreTool      = "pre_tool"      // Before a tool call runs, after it was approved
+	PostTool     = "post_tool"     // After a tool call ran
+	FileWritten  = "file_written"  // After a tool changed a file
+	TestsFailed  = "tests_failed"  // After a test tool reported failures
+)
+
+// Events lists every event
+var Events = []string{RunStarted, RunCompleted, PreTool, PostTool, FileWritten, TestsFailed}
+
+// blockable are the events a blocking hook can refuse
+var blockable = map[string]bool{RunStarted: true, PreTool: true}
+
+// DefaultTimeout bounds a hook without a timeout of its own
+const DefaultTimeout = 10 * time.Second
+
+// maxOutput limits the hook output kept for errors and rejections
+const maxOutput = 2048
+
+// Hook is a command or webhook run on events
+type Hook struct {
+	Name     string            // Shown in logs and rejections; the command or URL when empty
+	Events   []string          // Events the hook runs on
+	Command  string            // Run by the shell in the workspace root, with the event on stdin
+	URL      string            // Receives the event in a POST request
+	Headers  map[string]string // Sent with webhook requests; $VARIABLES are expanded
+	Tools    []string          // Limits tool events to these tools; empty runs on every tool
+	Blocking bool              // A failing run_started or pre_tool hook refuses the run or tool call
+	Timeout  time.Duration     // DefaultTimeout when 0
+}
+
+// label names h in messages
+func (h Hook) label() string {
+	switch {
+	case h.Name != "":
+		return h.Name
+	case h.Command != "":
+		return h.Command
+	}
+	return h.URL
+}
+
+// Event is what a hook receives. Fields that do not apply to an event are
+// left out of its JSON.
+type Event struct {
+	Event      string          `json:"event"`
+	Time       time.Time       `json:"time"`
+	Workspace  string          `json:"workspace,omitempty"`
+	Command    string          `json:"command,omitempty"` // CGE command of the run, e.g. generate
+	SessionID  string          `json:"session_id,omitempty"`
+	Tool       string          `json:"tool,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	File       string          `json:"file,omitempty"`   // The file of file_written
+	Status     string          `json:"status,omitempty"` // "succeeded" or "failed" for completed runs and tool calls
+	Error      string          `json:"error,omitempty"`
+	DurationMS int64           `json:"duration_ms,omitempty"`
+	Iterations int             `json:"iterations,omitempty"`
+	ToolCalls  int             `json:"tool_calls,omitempty"`
+	Result     interface{}     `json:"result,omitempty"` // The test results of tests_failed
+}
+
+// Status values of an event
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Validate checks that every hook has one of a command and a URL and runs on
+// known events
+func Validate(hooks []Hook) error {
+	for i, h := range hooks {
+		name := fmt.Sprintf("hook %d", i+1)
+		if h.Name != "" {
+			name = fmt.Sprintf("hook %q", h.Name)
+		}
+		if (h.Command == "") == (h.URL == "") {
+			return fmt.Errorf("%s needs either a command or a url", name)
+		}
+		if h.URL != "" && !strings.HasPrefix(h.URL, "https://") && !strings.HasPrefix(h.URL, "http://") {
+			return fmt.Errorf("%s: url must be http or https", name)
+		}
+		if len(h.Events) == 0 {
+			return fmt.Errorf("%s has no events", name)
+		}
+		for _, event := range h.Events {
+			if !isEvent(event) {
+				return fmt.Errorf("%s: unknown event %q (want one of %s)", name, event, strings.Join(Events, ", "))
+			}
+			if h.Blocking && !blockable[event] {
+				return fmt.Errorf("%s: only %s and %s hooks can be blocking", name, RunStarted, PreTool)
+			}
+		}
+	}
+	return nil
+}
+
+func isEvent(name string) bool {
+	for _, event := range Events {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+// RejectedError is returned by Fire when a blocking hook refused the event
+type RejectedError struct {
+	Hook   string
+	Reason string // What the hook printed or responded with
+}
+
+func (e *RejectedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("refused by hook %s", e.Hook)
+	}
+	return fmt.Sprintf("refused by hook %s: %s", e.Hook, e.Reason)
+}
+
+// Dispatcher runs the hooks of a workspace
+type Dispatcher struct {
+	workspaceRoot string
+	hooks         []Hook
+	client        *http.Client
+}
+
+// New creates a dispatcher running hooks in workspaceRoot. Webhooks are sent
+// with the shared HTTP client.
+func New(workspaceRoot string, hooks []Hook) *Dispatcher {
+	return &Dispatcher{workspaceRoot: workspaceRoot, hooks: hooks, client: httpclient.Default()}
+}
+
+// Fire runs the hooks of event in order and waits for them. A blocking hook
+// that fails stops the hooks after it and its *RejectedError is returned;
+// failures of other hooks are joined into the returned error without
+// stopping anything. A nil dispatcher runs nothing.
+func (d *Dispatcher) Fire(ctx context.Context, event Event) error {
+	if d == nil {
+		return nil
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	var payload []byte
+	var failures []error
+	for _, h := range d.hooks {
+		if !h.runsOn(event) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(event); err != nil {
+				return fmt.Errorf("failed to encode %s event: %w", event.Event, err)
+			}
+		}
+		output, err := d.run(ctx, h, event, payload)
+		if err == nil {
+			continue
+		}
+		if h.Blocking && blockable[event.Event] {
+			reason := output
+			if reason == "" {
+				reason = err.Error()
+			}
+			return errors.Join(append(failures, &RejectedError{Hook: h.label(), Reason: reason})...)
+		}
+		failures = append(failures, fmt.Errorf("%s hook %s failed: %w", event.Event, h.label(), err))
+	}
+	return errors.Join(failures...)
+}
+
+// timeout returns how long h may run
+func (h Hook) timeout() time.Duration {
+	if h.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return h.Timeout
+}
+
+// runsOn reports whether h subscribes to event, and to its tool
+func (h Hook) runsOn(event Event) bool {
+	subscribed := false
+	for _, name := range h.Events {
+		subscribed = subscribed || name == event.Event
+	}
+	if !subscribed || len(h.Tools) == 0 || event.Tool == "" {
+		return subscribed
+	}
+	for _, tool := range h.Tools {
+		if tool == event.Tool {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs one hook and returns what it printed or responded with
+func (d *Dispatcher) run(ctx context.Context, h Hook, event Event, payload []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout())
+	defer cancel()
+	if h.URL != "" {
+		return d.post(ctx, h, payload)
+	}
+	return d.command(ctx, h, event, payload)
+}
+
+// command runs the command of h with the event on stdin and in CGE_HOOK_*
+// variables
+func (d *Dispatcher) command(ctx context.Context, h Hook, event Event, payload []byte) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", h.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.Command)
+	}
+	cmd.Dir = d.workspaceRoot
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"CGE_HOOK_EVENT="+event.Event,
+		"CGE_HOOK_TOOL="+event.Tool,
+		"CGE_HOOK_FILE="+event.File,
+		"CGE_HOOK_SESSION="+event.SessionID,
+	)
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("timed out after %s", h.timeout())
+	}
+	return trimOutput(output), err
+}
+
+// post sends the event to the URL of h; responses other than 2xx are failures
+func (d *Dispatcher) post(ctx context.Context, h Hook, payload []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CGE-hooks")
+	for name, value := range h.Headers {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput*2))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return trimOutput(body), fmt.Errorf("%s responded %s", h.URL, resp.Status)
+	}
+	return trimOutput(body), nil
+}
+
+// trimOutput keeps the end of output, where errors usually are
+func trimOutput(output []byte) string {
+	text := strings.TrimSpace(string(output))
+	if len(text) > maxOutput {
+		text = "..." + text[len(text)-maxOutput:]
+	}
+	return text
+}
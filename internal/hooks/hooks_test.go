@@ -0,0 +1,119 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate([]Hook{
+		{Events: []string{RunCompleted}, URL: "https://hooks.example.com/cge"},
+		{Events: []string{PreTool}, Command: "./policy.sh", Blocking: true},
+	}))
+
+	for _, tc := range []struct {
+		hook Hook
+		want string
+	}{
+		{Hook{Events: []string{RunStarted}}, "hook 1 needs either a command or a url"},
+		{Hook{Events: []string{RunStarted}, Command: "true", URL: "https://example.com"}, "either a command or a url"},
+		{Hook{Name: "ci", Events: []string{RunStarted}, URL: "ftp://example.com"}, `hook "ci": url must be http or https`},
+		{Hook{Command: "true"}, "has no events"},
+		{Hook{Events: []string{"file_deleted"}, Command: "true"}, `unknown event "file_deleted"`},
+		{Hook{Events: []string{PostTool}, Command: "true", Blocking: true}, "only run_started and pre_tool hooks can be blocking"},
+	} {
+		assert.ErrorContains(t, Validate([]Hook{tc.hook}), tc.want)
+	}
+}
+
+func TestFireCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks use POSIX shell commands")
+	}
+	workspace := t.TempDir()
+	dispatcher := New(workspace, []Hook{
+		{Events: []string{FileWritten}, Command: `cat > event.json; echo "$CGE_HOOK_EVENT $CGE_HOOK_FILE" > env.txt`},
+		{Events: []string{FileWritten}, Command: "echo formatter crashed; exit 3"},
+		{Events: []string{RunCompleted}, Command: "touch completed"},
+	})
+
+	err := dispatcher.Fire(context.Background(), Event{Event: FileWritten, Tool: "write_file", File: "main.go", SessionID: "s1"})
+	require.Error(t, err, "failures of non-blocking hooks are reported")
+	assert.Contains(t, err.Error(), "file_written hook echo formatter crashed; exit 3 failed")
+
+	data, err := os.ReadFile(filepath.Join(workspace, "event.json"))
+	require.NoError(t, err)
+	var event Event
+	require.NoError(t, json.Unmarshal(data, &event))
+	assert.Equal(t, "main.go", event.File)
+	assert.Equal(t, "s1", event.SessionID)
+	assert.False(t, event.Time.IsZero())
+	env, _ := os.ReadFile(filepath.Join(workspace, "env.txt"))
+	assert.Equal(t, "file_written main.go\n", string(env))
+	assert.NoFileExists(t, filepath.Join(workspace, "completed"), "hooks only run on their events")
+}
+
+func TestFireBlocking(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks use POSIX shell commands")
+	}
+	workspace := t.TempDir()
+	dispatcher := New(workspace, []Hook{
+		{Name: "no-shell", Events: []string{PreTool}, Tools: []string{"run_shell_command"}, Command: "echo shell commands are not allowed; exit 1", Blocking: true},
+		{Events: []string{PreTool}, Command: "touch ran"},
+	})
+
+	err := dispatcher.Fire(context.Background(), Event{Event: PreTool, Tool: "read_file"})
+	assert.NoError(t, err, "the blocking hook only runs on its tools")
+	assert.FileExists(t, filepath.Join(workspace, "ran"))
+	require.NoError(t, os.Remove(filepath.Join(workspace, "ran")))
+
+	err = dispatcher.Fire(context.Background(), Event{Event: PreTool, Tool: "run_shell_command"})
+	var rejected *RejectedError
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, "no-shell", rejected.Hook)
+	assert.Equal(t, "shell commands are not allowed", rejected.Reason)
+	assert.NoFileExists(t, filepath.Join(workspace, "ran"), "hooks after a refusal do not run")
+
+	var nilDispatcher *Dispatcher
+	assert.NoError(t, nilDispatcher.Fire(context.Background(), Event{Event: PreTool}))
+}
+
+func TestFireWebhook(t *testing.T) {
+	var received Event
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &received)
+		if received.Status == StatusFailed {
+			http.Error(w, "deploy is frozen", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("CGE_TEST_HOOK_TOKEN", "s3cret")
+	dispatcher := New(t.TempDir(), []Hook{{
+		Events:  []string{RunCompleted},
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer $CGE_TEST_HOOK_TOKEN"},
+	}})
+
+	err := dispatcher.Fire(context.Background(), Event{Event: RunCompleted, Command: "generate", Status: StatusSucceeded, Iterations: 4})
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer s3cret", authorization)
+	assert.Equal(t, "generate", received.Command)
+	assert.Equal(t, 4, received.Iterations)
+
+	err = dispatcher.Fire(context.Background(), Event{Event: RunCompleted, Status: StatusFailed})
+	assert.ErrorContains(t, err, "403 Forbidden")
+}
@@ -0,0 +1,280 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default debug log settings, used until ConfigureDebugLog is called
+const (
+	DefaultDebugLogFile    = ".cge/logs/llm-http.log"
+	DefaultDebugLogMaxBody = 8192
+)
+
+// debugLog writes LLM HTTP exchanges to a dedicated file for troubleshooting
+// provider issues. It is off until enabled; secrets are redacted before
+// anything is written.
+type debugLog struct {
+	enabled atomic.Bool
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	path    string
+	maxBody int // Bytes of each body written; 0 writes headers only
+	file    *os.File
+}
+
+var httpDebugLog = &debugLog{path: DefaultDebugLogFile, maxBody: DefaultDebugLogMaxBody}
+
+// ConfigureDebugLog sets where the debug log is written and how much of each
+// body it keeps. It takes effect the next time logging is enabled.
+func ConfigureDebugLog(path string, maxBodyBytes int) {
+	httpDebugLog.mu.Lock()
+	defer httpDebugLog.mu.Unlock()
+	if path != "" {
+		httpDebugLog.path = path
+	}
+	if maxBodyBytes >= 0 {
+		httpDebugLog.maxBody = maxBodyBytes
+	}
+}
+
+// SetDebugLogging turns logging of LLM HTTP traffic on or off. Enabling opens
+// the log file for appending.
+func SetDebugLogging(enabled bool) error {
+	l := httpDebugLog
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !enabled {
+		l.enabled.Store(false)
+		if l.file != nil {
+			err := l.file.Close()
+			l.file = nil
+			return err
+		}
+		return nil
+	}
+
+	if l.file == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0750); err != nil {
+			return fmt.Errorf("failed to create debug log directory: %w", err)
+		}
+		file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open debug log: %w", err)
+		}
+		l.file = file
+	}
+	l.enabled.Store(true)
+	return nil
+}
+
+// DebugLogging reports whether LLM HTTP traffic is being logged, and where to
+func DebugLogging() (enabled bool, path string) {
+	httpDebugLog.mu.Lock()
+	defer httpDebugLog.mu.Unlock()
+	return httpDebugLog.enabled.Load(), httpDebugLog.path
+}
+
+// write appends one entry to the log file
+func (l *debugLog) write(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		_, _ = l.file.WriteString(entry)
+	}
+}
+
+func (l *debugLog) bodyLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.maxBody
+}
+
+// debugTransport logs each exchange to httpDebugLog while logging is enabled
+type debugTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !httpDebugLog.enabled.Load() {
+		return t.base.RoundTrip(req)
+	}
+
+	id := httpDebugLog.nextID.Add(1)
+	maxBody := httpDebugLog.bodyLimit()
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s request #%d\n%s %s\n", time.Now().Format(time.RFC3339), id, req.Method, redactURL(req.URL))
+	writeHeaders(&b, req.Header)
+	writeBody(&b, body, len(body), maxBody)
+	httpDebugLog.write(b.String())
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		httpDebugLog.write(fmt.Sprintf("--- %s response #%d failed after %s: %v\n\n", time.Now().Format(time.RFC3339), id, time.Since(start).Round(time.Millisecond), err))
+		return nil, err
+	}
+
+	// The body is logged once the caller has read it, so streamed responses
+	// are not held back
+	resp.Body = &loggedBody{
+		ReadCloser: resp.Body,
+		maxBody:    maxBody,
+		onClose: func(captured []byte, total int) {
+			var b strings.Builder
+			fmt.Fprintf(&b, "--- %s response #%d %s (%s)\n", time.Now().Format(time.RFC3339), id, resp.Status, time.Since(start).Round(time.Millisecond))
+			writeHeaders(&b, resp.Header)
+			writeBody(&b, captured, total, maxBody)
+			httpDebugLog.write(b.String())
+		},
+	}
+	return resp, nil
+}
+
+// loggedBody captures the first maxBody bytes read from a response body and
+// reports them when the body is closed
+type loggedBody struct {
+	io.ReadCloser
+	maxBody  int
+	captured []byte
+	total    int
+	once     sync.Once
+	onClose  func(captured []byte, total int)
+}
+
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.total += n
+	if room := b.maxBody - len(b.captured); room > 0 {
+		b.captured = append(b.captured, p[:min(n, room)]...)
+	}
+	return n, err
+}
+
+func (b *loggedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.onClose(b.captured, b.total) })
+	return err
+}
+
+// sensitiveHeaders carry credentials and are masked in the log
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Api-Key":             true,
+	"X-Api-Key":           true,
+	"X-Goog-Api-Key":      true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// sensitiveQueryParams carry credentials in URLs, as Gemini's key parameter does
+var sensitiveQueryParams = []string{"key", "api_key", "apikey", "access_token", "token"}
+
+// secretPatterns match API keys that may appear in bodies
+var secretPatterns = regexp.MustCompile(`sk-[A-Za-z0-9_\-]{16,}|AIza[0-9A-Za-z_\-]{30,}`)
+
+// writeHeaders writes headers in sorted order with credentials masked
+func writeHeaders(b *strings.Builder, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+				value = redactCredential(value)
+			}
+			fmt.Fprintf(b, "%s: %s\n", name, value)
+		}
+	}
+}
+
+// writeBody writes up to maxBody bytes of body, whose full size is total.
+// Secrets are redacted before truncating so a key cut in half is still masked.
+func writeBody(b *strings.Builder, body []byte, total, maxBody int) {
+	if total == 0 {
+		b.WriteString("\n")
+		return
+	}
+	if maxBody == 0 {
+		fmt.Fprintf(b, "\n(%d byte body not logged)\n\n", total)
+		return
+	}
+	redacted := secretPatterns.ReplaceAllStringFunc(string(body), maskSecret)
+	omitted := total - len(body)
+	if len(redacted) > maxBody {
+		omitted += len(redacted) - maxBody
+		redacted = redacted[:maxBody]
+	}
+	b.WriteString("\n")
+	b.WriteString(redacted)
+	if omitted > 0 {
+		fmt.Fprintf(b, "\n... (%d more bytes)", omitted)
+	}
+	b.WriteString("\n\n")
+}
+
+// redactCredential masks a header value, keeping an auth scheme such as
+// "Bearer" readable
+func redactCredential(value string) string {
+	if scheme, credential, ok := strings.Cut(value, " "); ok {
+		return scheme + " " + maskSecret(credential)
+	}
+	return maskSecret(value)
+}
+
+// maskSecret keeps just enough of a secret to tell keys apart
+func maskSecret(secret string) string {
+	if len(secret) <= 12 {
+		return "[REDACTED]"
+	}
+	return secret[:3] + "..." + secret[len(secret)-4:]
+}
+
+// redactURL returns u with credentials in its query masked
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	query := redacted.Query()
+	changed := false
+	for _, param := range sensitiveQueryParams {
+		if values, ok := query[param]; ok {
+			for i, value := range values {
+				values[i] = maskSecret(value)
+			}
+			changed = true
+		}
+	}
+	if changed {
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
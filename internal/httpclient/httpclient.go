@@ -0,0 +1,131 @@
+// Package httpclient provides the HTTP client shared by the LLM providers, so
+// requests reuse pooled connections and honor the configured proxy and TLS
+// settings. The client sets no overall timeout: callers bound each request
+// with its context, which also covers streamed responses.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Options configures the shared transport
+type Options struct {
+	Proxy               string        // Proxy URL; empty uses HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+	CACertFile          string        // PEM bundle trusted in addition to the system roots
+	InsecureSkipVerify  bool          // Disable TLS certificate verification
+	MaxIdleConns        int           // Idle connections kept across all hosts
+	MaxIdleConnsPerHost int           // Idle connections kept per host
+	MaxConnsPerHost     int           // Concurrent connections per host; 0 is unlimited
+	IdleConnTimeout     time.Duration // How long an idle connection is kept
+	DialTimeout         time.Duration // Timeout for establishing a connection
+	TLSHandshakeTimeout time.Duration
+}
+
+// DefaultOptions returns the options used when none are configured
+func DefaultOptions() Options {
+	return Options{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// NewTransport builds a transport from opts
+func NewTransport(opts Options) (*http.Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", opts.Proxy)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify, // #nosec G402 - explicit opt-in
+	}
+	if opts.CACertFile != "" {
+		pem, err := os.ReadFile(opts.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   opts.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ExpectContinueTimeout: time.Second,
+	}, nil
+}
+
+// New builds a client using a transport from opts. Its exchanges are written
+// to the debug log while SetDebugLogging is on.
+func New(opts Options) (*http.Client, error) {
+	transport, err := NewTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &debugTransport{base: transport}}, nil
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   *http.Client
+)
+
+// Default returns the shared client, built from DefaultOptions unless
+// Configure was called
+func Default() *http.Client {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if shared == nil {
+		client, err := New(DefaultOptions())
+		if err != nil {
+			panic(err) // The default options are always valid
+		}
+		shared = client
+	}
+	return shared
+}
+
+// Configure replaces the shared client with one built from opts. Clients
+// already handed out keep their transport.
+func Configure(opts Options) error {
+	client, err := New(opts)
+	if err != nil {
+		return err
+	}
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	shared = client
+	return nil
+}
@@ -0,0 +1,56 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransport_Proxy(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Proxy = "http://proxy.internal:3128"
+	transport, err := NewTransport(opts)
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "https://api.openai.com/v1/models", nil)
+	proxyURL, err := transport.Proxy(req)
+	require.NoError(t, err)
+	assert.Equal(t, &url.URL{Scheme: "http", Host: "proxy.internal:3128"}, proxyURL)
+
+	opts.Proxy = "not a url"
+	_, err = NewTransport(opts)
+	assert.Error(t, err)
+}
+
+func TestNewTransport_CACertFile(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CACertFile = filepath.Join(t.TempDir(), "missing.pem")
+	_, err := NewTransport(opts)
+	assert.Error(t, err)
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("no certificates"), 0600))
+	opts.CACertFile = empty
+	_, err = NewTransport(opts)
+	assert.ErrorContains(t, err, "no certificates found")
+}
+
+func TestConfigure(t *testing.T) {
+	before := Default()
+	assert.Same(t, before, Default(), "the client is shared")
+
+	opts := DefaultOptions()
+	opts.MaxConnsPerHost = 4
+	require.NoError(t, Configure(opts))
+	t.Cleanup(func() { _ = Configure(DefaultOptions()) })
+
+	after := Default()
+	assert.NotSame(t, before, after)
+	assert.Equal(t, 4, after.Transport.(*debugTransport).base.(*http.Transport).MaxConnsPerHost)
+	assert.Zero(t, after.Timeout, "requests are bounded by their context")
+}
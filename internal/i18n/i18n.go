@@ -0,0 +1,166 @@
+// Package i18n translates the user-facing strings of the TUI. Messages are
+// looked up by key in the catalog of the current locale, falling back to the
+// English catalog built into CGE, so a translation may cover only part of the
+// messages. Catalogs for other locales are JSON objects of key to message,
+// named after the locale (de.json, pt-BR.json) in the locales directory.
+package i18n
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is the locale of the built-in catalog
+const DefaultLocale = "en"
+
+var (
+	mu      sync.RWMutex
+	current = DefaultLocale
+	catalog map[string]string // Translations for the current locale; nil for English
+)
+
+// Configure selects locale, loading its catalog from dir. An empty locale is
+// detected from the environment with DetectLocale. Catalogs of more general
+// locales are loaded first, so pt-BR.json only needs the messages that
+// differ from pt.json. When no catalog is found, the locale is still selected
+// (prompt templates may exist for it) and messages stay in English; the
+// error says so.
+func Configure(locale, dir string) error {
+	if locale == "" {
+		locale = DetectLocale()
+	}
+	locale = normalizeLocale(locale)
+
+	var loaded map[string]string
+	var err error
+	if !isDefault(locale) {
+		loaded, err = loadCatalog(locale, dir)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	current = locale
+	catalog = loaded
+	return err
+}
+
+// loadCatalog reads and merges the catalogs of locale and its fallbacks
+func loadCatalog(locale, dir string) (map[string]string, error) {
+	merged := make(map[string]string)
+	found := false
+	fallbacks := Fallbacks(locale)
+	for i := len(fallbacks) - 1; i >= 0; i-- {
+		path := filepath.Join(dir, fallbacks[i]+".json")
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message catalog: %w", err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("invalid message catalog %s: %w", path, err)
+		}
+		for key, message := range messages {
+			merged[key] = message
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("no message catalog for locale %s in %s; messages stay in English", locale, dir)
+	}
+	return merged, nil
+}
+
+// DetectLocale returns the locale named by CGE_LOCALE, LC_ALL, LC_MESSAGES or
+// LANG, the first one set, or DefaultLocale
+func DetectLocale() string {
+	for _, name := range []string{"CGE_LOCALE", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return normalizeLocale(value)
+		}
+	}
+	return DefaultLocale
+}
+
+// normalizeLocale turns POSIX locale names such as de_DE.UTF-8 into tags such
+// as de-DE. The C and POSIX locales are English.
+func normalizeLocale(name string) string {
+	name, _, _ = strings.Cut(name, ".")
+	name, _, _ = strings.Cut(name, "@")
+	if name == "" || name == "C" || name == "POSIX" {
+		return DefaultLocale
+	}
+	lang, region, hasRegion := strings.Cut(strings.ReplaceAll(name, "_", "-"), "-")
+	if !hasRegion {
+		return strings.ToLower(lang)
+	}
+	return strings.ToLower(lang) + "-" + strings.ToUpper(region)
+}
+
+// Fallbacks returns the locales tried for locale, most specific first, e.g.
+// pt-BR, pt
+func Fallbacks(locale string) []string {
+	if lang, _, ok := strings.Cut(locale, "-"); ok {
+		return []string{locale, lang}
+	}
+	return []string{locale}
+}
+
+// isDefault reports whether locale is served by the built-in catalog
+func isDefault(locale string) bool {
+	return locale == DefaultLocale || strings.HasPrefix(locale, DefaultLocale+"-")
+}
+
+// Locale returns the current locale
+func Locale() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// T returns the message of key in the current locale, formatted with args as
+// by fmt.Sprintf. Keys missing from every catalog are returned as they are.
+func T(key string, args ...interface{}) string {
+	mu.RLock()
+	message, ok := catalog[key]
+	if !ok {
+		message, ok = english[key]
+	}
+	mu.RUnlock()
+	if !ok {
+		message = key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Register adds English messages to the built-in catalog, for packages that
+// keep their English text in tables next to the code using it
+func Register(messages map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for key, message := range messages {
+		english[key] = message
+	}
+}
+
+// English returns a copy of the built-in catalog, as a starting point for
+// translations
+func English() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	messages := make(map[string]string, len(english))
+	for key, message := range english {
+		messages[key] = message
+	}
+	return messages
+}
@@ -0,0 +1,67 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := map[string]string{
+		"de_DE.UTF-8":     "de-DE",
+		"pt_br":           "pt-BR",
+		"fr":              "fr",
+		"sr_RS@latin":     "sr-RS",
+		"C":               DefaultLocale,
+		"POSIX":           DefaultLocale,
+		"en_US.ISO8859-1": "en-US",
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, normalizeLocale(name), name)
+	}
+	assert.Equal(t, []string{"pt-BR", "pt"}, Fallbacks("pt-BR"))
+}
+
+func TestDetectLocale(t *testing.T) {
+	t.Setenv("CGE_LOCALE", "")
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "es_ES.UTF-8")
+	assert.Equal(t, "es-ES", DetectLocale())
+
+	t.Setenv("CGE_LOCALE", "de")
+	assert.Equal(t, "de", DetectLocale(), "CGE_LOCALE wins")
+}
+
+func TestConfigure(t *testing.T) {
+	defer Configure(DefaultLocale, "")
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pt.json"), []byte(`{"status.quit": "%s: sair", "chat.thinking": "Pensando..."}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pt-BR.json"), []byte(`{"chat.thinking": "Processando..."}`), 0600))
+
+	require.NoError(t, Configure("pt_BR.UTF-8", dir))
+	assert.Equal(t, "pt-BR", Locale())
+	assert.Equal(t, "Processando...", T("chat.thinking"), "the regional catalog overrides the language one")
+	assert.Equal(t, "Ctrl+C: sair", T("status.quit", "Ctrl+C"))
+	assert.Equal(t, english["status.edit_last"], T("status.edit_last"), "missing messages stay in English")
+	assert.Equal(t, "no.such.key", T("no.such.key"))
+
+	err := Configure("ja", dir)
+	assert.ErrorContains(t, err, "no message catalog for locale ja")
+	assert.Equal(t, "ja", Locale(), "the locale is kept for prompt templates")
+	assert.Equal(t, english["chat.thinking"], T("chat.thinking"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{`), 0600))
+	assert.ErrorContains(t, Configure("fr", dir), "invalid message catalog")
+
+	require.NoError(t, Configure("en-GB", dir), "English needs no catalog")
+}
+
+func TestRegister(t *testing.T) {
+	Register(map[string]string{"test.registered": "Registered %d"})
+	assert.Equal(t, "Registered 3", T("test.registered", 3))
+	assert.Contains(t, English(), "test.registered")
+}
@@ -0,0 +1,42 @@
+package i18n
+
+// english is the built-in catalog. Keys are grouped by the part of the TUI
+// showing them; messages with verbs take fmt arguments in the order given.
+var english = map[string]string{
+	// Chat
+	"chat.welcome":        "Welcome to CGE Chat! Type your message or use '/' for commands.",
+	"chat.thinking":       "Thinking...",
+	"chat.keymap_invalid": "⚠️ %v. Using the default keys; type /keys to see them.",
+
+	// Input area
+	"input.placeholder": "Type your message... (Ctrl+E to edit last, Tab for completion)",
+
+	// Status bar; %s arguments are key names such as Ctrl+C
+	"status.thinking":    "%s Thinking... (%s)",
+	"status.queued":      "%d queued",
+	"status.plan_mode":   "📋 plan mode",
+	"status.cancel":      "%s: cancel",
+	"status.error":       "Error: %v",
+	"status.quit":        "%s: quit",
+	"status.edit_last":   "Ctrl+E: edit last",
+	"status.suggestions": "%s: suggestions",
+	"status.active":      "Active: %d",
+	"status.session":     "Session: %.0fm",
+	"status.no_index":    "no index",
+	"status.index_stale": "index stale",
+	"status.index_age":   "index %s",
+	"status.idle":        "💤 idle",
+	"status.loading":     "⏳ loading %s...",
+	"status.loaded":      "✓ %s loaded (%.1fs)",
+
+	// Message list
+	"tool.call":       "🔧 Tool Call: %s",
+	"tool.parameters": "Parameters:\n%s",
+	"tool.result":     "%s Tool Result: %s",
+	"tool.error":      "%s Tool Error: %s — %s",
+
+	// /errors
+	"errors.none":    "🧯 No errors so far",
+	"errors.summary": "🧯 %d error(s) this session",
+	"errors.latest":  "Latest:",
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrBudgetExceeded is returned by a BudgetClient for the requests made once
+// the run has spent its budget
+var ErrBudgetExceeded = errors.New("run budget exceeded")
+
+// BudgetClient wraps a Client to refuse generation requests once the cost of
+// the requests recorded in a UsageMeter reaches a limit. The request that
+// crosses the limit completes; the ones after it fail with ErrBudgetExceeded.
+type BudgetClient struct {
+	Client
+	meter    *UsageMeter
+	pricing  ModelPricing
+	limitUSD float64
+	exceeded atomic.Bool
+}
+
+// WithBudget wraps client so its generation requests fail once meter has
+// recorded limitUSD worth of usage at pricing. The meter is the one client
+// records into, or one it shares with the other clients of the run.
+func WithBudget(client Client, meter *UsageMeter, pricing ModelPricing, limitUSD float64) *BudgetClient {
+	return &BudgetClient{Client: client, meter: meter, pricing: pricing, limitUSD: limitUSD}
+}
+
+// Exceeded reports whether a request was refused for the budget
+func (c *BudgetClient) Exceeded() bool {
+	return c.exceeded.Load()
+}
+
+// check returns ErrBudgetExceeded when the budget is spent
+func (c *BudgetClient) check() error {
+	if spent := c.meter.Cost(c.pricing); spent >= c.limitUSD {
+		c.exceeded.Store(true)
+		return fmt.Errorf("%w: $%.4f spent of $%.4f", ErrBudgetExceeded, spent, c.limitUSD)
+	}
+	return nil
+}
+
+// Generate implements Client
+func (c *BudgetClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	if err := c.check(); err != nil {
+		return "", err
+	}
+	return c.Client.Generate(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+// GenerateWithFunctions implements Client
+func (c *BudgetClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition) (*FunctionCallResponse, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	return c.Client.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+// Stream implements Client
+func (c *BudgetClient) Stream(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}, out chan<- string) error {
+	if err := c.check(); err != nil {
+		close(out)
+		return err
+	}
+	return c.Client.Stream(ctx, modelName, prompt, systemPrompt, tools, out)
+}
+
+// StreamWithFunctions implements FunctionCallStreamer, falling back to
+// GenerateWithFunctions when the wrapped client cannot stream
+func (c *BudgetClient) StreamWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition, onDelta func(FunctionCallDelta)) (*FunctionCallResponse, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	if streamer, ok := c.Client.(FunctionCallStreamer); ok {
+		return streamer.StreamWithFunctions(ctx, modelName, prompt, systemPrompt, tools, onDelta)
+	}
+	return c.Client.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+// EmbedBatch implements BatchEmbedder; embeddings are not limited
+func (c *BudgetClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if batcher, ok := c.Client.(BatchEmbedder); ok {
+		return batcher.EmbedBatch(ctx, texts)
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding, err := c.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+// EmbeddingModel implements EmbeddingModeler
+func (c *BudgetClient) EmbeddingModel() string {
+	return EmbeddingModelOf(c.Client)
+}
+
+// SetPullProgress implements PullProgressSetter
+func (c *BudgetClient) SetPullProgress(progress func(PullProgress)) {
+	SetPullProgressOf(c.Client, progress)
+}
@@ -0,0 +1,28 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetClient(t *testing.T) {
+	meter := &UsageMeter{}
+	pricing := ModelPricing{InputPerMillion: 1_000_000, OutputPerMillion: 1_000_000} // $1 a token
+	prompt := strings.Repeat("word ", 20)
+	budget := WithBudget(WithUsageMeter(&echoClient{}, meter), meter, pricing, 1)
+
+	_, err := budget.Generate(context.Background(), "gpt-4o", prompt, "", nil)
+	require.NoError(t, err, "the request crossing the budget completes")
+	assert.False(t, budget.Exceeded())
+
+	_, err = budget.Generate(context.Background(), "gpt-4o", prompt, "", nil)
+	assert.True(t, errors.Is(err, ErrBudgetExceeded))
+	assert.True(t, budget.Exceeded())
+	requests, _, _ := meter.Usage()
+	assert.Equal(t, 1, requests, "the refused request is not sent")
+}
@@ -0,0 +1,204 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/config"
+)
+
+// Capabilities are what a model can do
+type Capabilities struct {
+	ContextWindow   int  // Context size in tokens; 0 when unknown
+	FunctionCalling bool // Native tool calls
+	Vision          bool // Image input
+	Embeddings      bool // The provider can embed text alongside the model
+}
+
+// capabilityEntry gives the capabilities of the models matching pattern,
+// "provider/model" with shell wildcards in either part
+type capabilityEntry struct {
+	pattern string
+	caps    Capabilities
+}
+
+// knownCapabilities is the built-in registry. The longest matching pattern
+// wins, so "*/llama3.1*" beats "*/llama3*".
+var knownCapabilities = []capabilityEntry{
+	{"*/gpt-4o*", Capabilities{ContextWindow: 128000, FunctionCalling: true, Vision: true, Embeddings: true}},
+	{"*/gpt-4.1*", Capabilities{ContextWindow: 1000000, FunctionCalling: true, Vision: true, Embeddings: true}},
+	{"*/gpt-4-turbo*", Capabilities{ContextWindow: 128000, FunctionCalling: true, Vision: true, Embeddings: true}},
+	{"*/gpt-4*", Capabilities{ContextWindow: 8192, FunctionCalling: true, Embeddings: true}},
+	{"*/gpt-3.5-turbo*", Capabilities{ContextWindow: 16385, FunctionCalling: true, Embeddings: true}},
+	{"*/gpt-3.5-turbo-instruct*", Capabilities{ContextWindow: 4096, Embeddings: true}},
+	{"*/o1*", Capabilities{ContextWindow: 128000, FunctionCalling: true, Vision: true, Embeddings: true}},
+	{"*/o1-mini*", Capabilities{ContextWindow: 128000, Embeddings: true}},
+	{"*/o1-preview*", Capabilities{ContextWindow: 128000, Embeddings: true}},
+	{"*/o3*", Capabilities{ContextWindow: 200000, FunctionCalling: true, Embeddings: true}},
+	{"*/gemini-1.5-pro*", Capabilities{ContextWindow: 2000000, FunctionCalling: true, Vision: true, Embeddings: true}},
+	{"*/gemini-1.5-flash*", Capabilities{ContextWindow: 1000000, FunctionCalling: true, Vision: true, Embeddings: true}},
+	{"*/gemini-2.0-flash*", Capabilities{ContextWindow: 1000000, FunctionCalling: true, Vision: true, Embeddings: true}},
+	{"*/llama3.1*", Capabilities{ContextWindow: 128000, FunctionCalling: true, Embeddings: true}},
+	{"*/llama3.2*", Capabilities{ContextWindow: 128000, FunctionCalling: true, Embeddings: true}},
+	{"*/llama3.2-vision*", Capabilities{ContextWindow: 128000, Vision: true, Embeddings: true}},
+	{"*/llama3*", Capabilities{ContextWindow: 8192, Embeddings: true}},
+	{"*/llama2*", Capabilities{ContextWindow: 4096, Embeddings: true}},
+	{"*/llava*", Capabilities{ContextWindow: 4096, Vision: true, Embeddings: true}},
+	{"*/codellama*", Capabilities{ContextWindow: 16384, Embeddings: true}},
+	{"*/mistral*", Capabilities{ContextWindow: 32768, FunctionCalling: true, Embeddings: true}},
+	{"*/mixtral*", Capabilities{ContextWindow: 32768, FunctionCalling: true, Embeddings: true}},
+	{"*/qwen2.5*", Capabilities{ContextWindow: 32768, FunctionCalling: true, Embeddings: true}},
+	{"*/deepseek-coder*", Capabilities{ContextWindow: 16384, Embeddings: true}},
+	{"*/gemma2*", Capabilities{ContextWindow: 8192, Embeddings: true}},
+	{"*/phi3*", Capabilities{ContextWindow: 4096, Embeddings: true}},
+}
+
+// LookupCapabilities returns the capabilities of model on provider. Declared
+// capabilities, from the configuration, take precedence over the built-in
+// registry. An empty provider matches any. ok is false for unknown models.
+func LookupCapabilities(provider, model string, declared []config.ModelCapabilities) (caps Capabilities, ok bool) {
+	provider = strings.ToLower(provider)
+	name := strings.ToLower(model)
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:] // "models/gemini-1.5-pro" -> "gemini-1.5-pro"
+	}
+
+	entries := make([]capabilityEntry, 0, len(declared))
+	for _, d := range declared {
+		entries = append(entries, capabilityEntry{pattern: d.Pattern, caps: Capabilities{
+			ContextWindow:   d.ContextWindow,
+			FunctionCalling: d.FunctionCalling,
+			Vision:          d.Vision,
+			Embeddings:      d.Embeddings,
+		}})
+	}
+	for _, registry := range [][]capabilityEntry{entries, knownCapabilities} {
+		best := ""
+		for _, entry := range registry {
+			if matchesModelPattern(strings.ToLower(entry.pattern), provider, name) && len(entry.pattern) > len(best) {
+				best, caps = entry.pattern, entry.caps
+			}
+		}
+		if best != "" {
+			return caps, true
+		}
+	}
+	return Capabilities{}, false
+}
+
+// matchesModelPattern reports whether "provider/model" pattern matches a
+// model name. A pattern without a slash matches the model on any provider.
+func matchesModelPattern(pattern, provider, name string) bool {
+	providerPattern, modelPattern, found := strings.Cut(pattern, "/")
+	if !found {
+		providerPattern, modelPattern = "*", pattern
+	}
+	if provider != "" {
+		if ok, err := path.Match(providerPattern, provider); err != nil || !ok {
+			return false
+		}
+	}
+	ok, err := path.Match(modelPattern, name)
+	return err == nil && ok
+}
+
+// Requirements are the capabilities a workflow needs
+type Requirements struct {
+	FunctionCalling  bool
+	Vision           bool
+	Embeddings       bool
+	MinContextWindow int
+}
+
+// AgentRequirements are needed by the agent loops of chat, generate and review
+var AgentRequirements = Requirements{FunctionCalling: true, MinContextWindow: 8192}
+
+// IndexRequirements are needed to index the workspace
+var IndexRequirements = Requirements{Embeddings: true}
+
+// FitToWorkflow checks the capabilities of model against what a workflow
+// needs. It returns a warning for each one the model lacks and, where there
+// is a fallback, wraps client to use it: tools are described in the prompt
+// when function calling is missing, and retrieval searches with the LLM
+// instead of embeddings. Unknown models are trusted.
+func FitToWorkflow(client Client, provider, model string, declared []config.ModelCapabilities, req Requirements) (Client, []string) {
+	caps, ok := LookupCapabilities(provider, model, declared)
+	if !ok {
+		return client, nil
+	}
+
+	var warnings []string
+	fallback := &capabilityFallbackClient{Client: client}
+	if !caps.FunctionCalling && req.FunctionCalling {
+		warnings = append(warnings, fmt.Sprintf("%s does not support function calling; tools are described in the prompt instead, which is less reliable", model))
+		fallback.promptTools = client.SupportsNativeFunctionCalling()
+	}
+	if !caps.Embeddings && client.SupportsEmbeddings() {
+		warnings = append(warnings, fmt.Sprintf("%s cannot embed text; context is found by LLM-assisted search instead of the vector index", model))
+		fallback.noEmbeddings = true
+	}
+	if !caps.Vision && req.Vision {
+		warnings = append(warnings, fmt.Sprintf("%s cannot read images; only their file names are sent", model))
+	}
+	if caps.ContextWindow > 0 && caps.ContextWindow < req.MinContextWindow {
+		warnings = append(warnings, fmt.Sprintf("%s has a %d-token context window, below the %d this workflow needs; long conversations will be cut short", model, caps.ContextWindow, req.MinContextWindow))
+	}
+
+	if fallback.promptTools || fallback.noEmbeddings {
+		return fallback, warnings
+	}
+	return client, warnings
+}
+
+// capabilityFallbackClient works around capabilities the model lacks
+type capabilityFallbackClient struct {
+	Client
+	promptTools  bool // Describe tools in the prompt instead of calling them natively
+	noEmbeddings bool // Report no embedding support
+}
+
+// GenerateWithFunctions implements Client
+func (c *capabilityFallbackClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition) (*FunctionCallResponse, error) {
+	if !c.promptTools {
+		return c.Client.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+	}
+	return generateWithPromptTools(ctx, c.Client, modelName, prompt, systemPrompt, tools)
+}
+
+// StreamWithFunctions implements FunctionCallStreamer. Tools described in the
+// prompt are not streamed, and neither are calls to a client that cannot
+// stream: the response comes from GenerateWithFunctions.
+func (c *capabilityFallbackClient) StreamWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition, onDelta func(FunctionCallDelta)) (*FunctionCallResponse, error) {
+	if streamer, ok := c.Client.(FunctionCallStreamer); ok && !c.promptTools {
+		return streamer.StreamWithFunctions(ctx, modelName, prompt, systemPrompt, tools, onDelta)
+	}
+	return c.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+// SupportsNativeFunctionCalling implements Client
+func (c *capabilityFallbackClient) SupportsNativeFunctionCalling() bool {
+	return !c.promptTools && c.Client.SupportsNativeFunctionCalling()
+}
+
+// SupportsEmbeddings implements Client
+func (c *capabilityFallbackClient) SupportsEmbeddings() bool {
+	return !c.noEmbeddings && c.Client.SupportsEmbeddings()
+}
+
+// EmbedBatch implements BatchEmbedder, embedding the texts one by one when the
+// wrapped client cannot batch
+func (c *capabilityFallbackClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedBatchOf(ctx, c.Client, texts)
+}
+
+// EmbeddingModel implements EmbeddingModeler
+func (c *capabilityFallbackClient) EmbeddingModel() string {
+	return EmbeddingModelOf(c.Client)
+}
+
+// SetPullProgress implements PullProgressSetter
+func (c *capabilityFallbackClient) SetPullProgress(progress func(PullProgress)) {
+	SetPullProgressOf(c.Client, progress)
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nativeToolsClient has native function calling and embeddings, and records
+// the prompts it is given
+type nativeToolsClient struct {
+	Client
+	prompts     []string
+	nativeCalls int
+}
+
+func (c *nativeToolsClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	c.prompts = append(c.prompts, prompt)
+	return `{"name": "read_file", "arguments": {"file_path": "main.go"}}`, nil
+}
+
+func (c *nativeToolsClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition) (*FunctionCallResponse, error) {
+	c.nativeCalls++
+	return &FunctionCallResponse{IsTextResponse: true, TextContent: "native"}, nil
+}
+
+func (c *nativeToolsClient) SupportsNativeFunctionCalling() bool { return true }
+func (c *nativeToolsClient) SupportsEmbeddings() bool            { return true }
+
+func TestLookupCapabilities(t *testing.T) {
+	caps, ok := LookupCapabilities("openai", "gpt-4o-mini", nil)
+	require.True(t, ok)
+	assert.Equal(t, Capabilities{ContextWindow: 128000, FunctionCalling: true, Vision: true, Embeddings: true}, caps)
+
+	caps, ok = LookupCapabilities("ollama", "llama3.1:8b", nil)
+	require.True(t, ok)
+	assert.True(t, caps.FunctionCalling, "llama3.1 beats llama3")
+
+	caps, _ = LookupCapabilities("openai", "o1-mini-2024-09-12", nil)
+	assert.False(t, caps.FunctionCalling)
+
+	_, ok = LookupCapabilities("ollama", "my-coder:7b", nil)
+	assert.False(t, ok)
+
+	declared := []config.ModelCapabilities{
+		{Pattern: "ollama/my-coder*", ContextWindow: 32768, FunctionCalling: true},
+		{Pattern: "openai/gpt-4o*", ContextWindow: 64000},
+	}
+	caps, ok = LookupCapabilities("ollama", "library/my-coder:7b", declared)
+	require.True(t, ok)
+	assert.Equal(t, Capabilities{ContextWindow: 32768, FunctionCalling: true}, caps)
+	caps, _ = LookupCapabilities("openai", "gpt-4o", declared)
+	assert.Equal(t, 64000, caps.ContextWindow, "declared capabilities win")
+	_, ok = LookupCapabilities("gemini", "my-coder", declared)
+	assert.False(t, ok, "declared patterns are per provider")
+}
+
+func TestFitToWorkflowFallsBackToPromptTools(t *testing.T) {
+	inner := &nativeToolsClient{}
+	client, warnings := FitToWorkflow(inner, "openai", "gpt-3.5-turbo-instruct", nil, AgentRequirements)
+	require.Len(t, warnings, 2, "function calling and the context window")
+	assert.False(t, client.SupportsNativeFunctionCalling())
+	assert.True(t, client.SupportsEmbeddings())
+
+	tools := []ToolDefinition{CreateToolDefinition("read_file", "Reads a file", []byte(`{"type":"object"}`))}
+	response, err := client.GenerateWithFunctions(context.Background(), "gpt-3.5-turbo-instruct", "Open main.go", "", tools)
+	require.NoError(t, err)
+	require.NotNil(t, response.FunctionCall)
+	assert.Equal(t, "read_file", response.FunctionCall.Name)
+	assert.Zero(t, inner.nativeCalls)
+	require.Len(t, inner.prompts, 1)
+	assert.Contains(t, inner.prompts[0], "Available tools:")
+}
+
+func TestFitToWorkflowWithoutFallback(t *testing.T) {
+	inner := &nativeToolsClient{}
+	client, warnings := FitToWorkflow(inner, "openai", "gpt-4o", nil, AgentRequirements)
+	assert.Empty(t, warnings)
+	assert.Same(t, Client(inner), client)
+
+	client, warnings = FitToWorkflow(inner, "openai", "unknown-model", nil, AgentRequirements)
+	assert.Empty(t, warnings, "unknown models are trusted")
+	assert.Same(t, Client(inner), client)
+
+	declared := []config.ModelCapabilities{{Pattern: "openai/no-embed*", ContextWindow: 8192, FunctionCalling: true}}
+	client, warnings = FitToWorkflow(inner, "openai", "no-embed-1", declared, IndexRequirements)
+	assert.Len(t, warnings, 1)
+	assert.False(t, client.SupportsEmbeddings())
+	assert.True(t, client.SupportsNativeFunctionCalling())
+}
+
+// optionalClient implements every optional interface a Client may have, and
+// records the ones called
+type optionalClient struct {
+	nativeToolsClient
+	called []string
+}
+
+func (c *optionalClient) StreamWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition, onDelta func(FunctionCallDelta)) (*FunctionCallResponse, error) {
+	c.called = append(c.called, "StreamWithFunctions")
+	onDelta(FunctionCallDelta{Content: "streamed"})
+	return &FunctionCallResponse{IsTextResponse: true, TextContent: "streamed"}, nil
+}
+
+func (c *optionalClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	c.called = append(c.called, "EmbedBatch")
+	return make([][]float32, len(texts)), nil
+}
+
+func (c *optionalClient) EmbeddingModel() string {
+	c.called = append(c.called, "EmbeddingModel")
+	return "embedder"
+}
+
+func (c *optionalClient) SetPullProgress(progress func(PullProgress)) {
+	c.called = append(c.called, "SetPullProgress")
+}
+
+func TestOptionalInterfacesSurviveWrapping(t *testing.T) {
+	// The recovery client pulls models itself, so it keeps the progress
+	// callback rather than forwarding it
+	wrappers := []struct {
+		name   string
+		wrap   func(Client) Client
+		called []string
+	}{
+		{"capability fallback", func(client Client) Client {
+			return &capabilityFallbackClient{Client: client, noEmbeddings: true}
+		}, []string{"StreamWithFunctions", "EmbedBatch", "EmbeddingModel", "SetPullProgress"}},
+		{"model recovery", func(client Client) Client {
+			return NewModelRecoveryClient(client, nil, nil, nil)
+		}, []string{"StreamWithFunctions", "EmbedBatch", "EmbeddingModel"}},
+	}
+	for _, tc := range wrappers {
+		t.Run(tc.name, func(t *testing.T) {
+			inner := &optionalClient{}
+			client := tc.wrap(inner)
+
+			streamer, ok := client.(FunctionCallStreamer)
+			require.True(t, ok, "StreamWithFunctions is forwarded")
+			var deltas []string
+			response, err := streamer.StreamWithFunctions(context.Background(), "model", "prompt", "", nil, func(delta FunctionCallDelta) {
+				deltas = append(deltas, delta.Content)
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "streamed", response.TextContent)
+			assert.Equal(t, []string{"streamed"}, deltas)
+
+			batcher, ok := client.(BatchEmbedder)
+			require.True(t, ok, "EmbedBatch is forwarded")
+			embeddings, err := batcher.EmbedBatch(context.Background(), []string{"a", "b"})
+			require.NoError(t, err)
+			assert.Len(t, embeddings, 2)
+
+			assert.Equal(t, "embedder", EmbeddingModelOf(client))
+			SetPullProgressOf(client, func(PullProgress) {})
+
+			assert.Equal(t, tc.called, inner.called)
+		})
+	}
+}
+
+func TestCapabilityFallbackDoesNotStreamPromptTools(t *testing.T) {
+	inner := &optionalClient{}
+	client := &capabilityFallbackClient{Client: inner, promptTools: true}
+
+	response, err := client.StreamWithFunctions(context.Background(), "model", "prompt", "", []ToolDefinition{{Type: "function", Function: ToolFunctionDefinition{Name: "read_file"}}}, func(FunctionCallDelta) {})
+	require.NoError(t, err)
+	assert.Empty(t, inner.called, "native streaming is bypassed")
+	require.NotNil(t, response.FunctionCall)
+	assert.Equal(t, "read_file", response.FunctionCall.Name)
+}
@@ -66,3 +66,62 @@ type Client interface {
 
 	// TODO: Potentially add methods for token counting, specific model capabilities, etc.
 }
+
+// BatchEmbedder is implemented by clients whose provider can embed several
+// texts in one request. Callers should type-assert a Client and fall back to
+// concurrent Embed calls when it is not supported.
+type BatchEmbedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// embedBatchOf embeds texts in one request when client can batch, and one by
+// one otherwise
+func embedBatchOf(ctx context.Context, client Client, texts []string) ([][]float32, error) {
+	if batcher, ok := client.(BatchEmbedder); ok {
+		return batcher.EmbedBatch(ctx, texts)
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding, err := client.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+// EmbeddingModeler is implemented by clients that can name the model their
+// embeddings come from. Embeddings of different models, or of different
+// dimensions, cannot be compared, so a saved index is keyed by it.
+type EmbeddingModeler interface {
+	EmbeddingModel() string
+}
+
+// EmbeddingModelOf returns the model the embeddings of client come from, or
+// "" when client cannot name it
+func EmbeddingModelOf(client Client) string {
+	if modeler, ok := client.(EmbeddingModeler); ok {
+		return modeler.EmbeddingModel()
+	}
+	return ""
+}
+
+// FunctionCallDelta is an increment of a streamed function-calling response:
+// either text content or a fragment of a tool call
+type FunctionCallDelta struct {
+	Content        string // Text appended to the response
+	CallID         string // ID of the tool call the fragment belongs to
+	FunctionName   string // Name of the function being called
+	Arguments      string // Arguments JSON received so far; incomplete until the call ends
+	ArgumentsDelta string // Fragment appended to Arguments by this delta
+}
+
+// FunctionCallStreamer is implemented by clients that can stream a
+// function-calling response. onDelta is called as text and tool call
+// fragments arrive; the returned response is the same GenerateWithFunctions
+// would return. Callers should type-assert a Client and fall back to
+// GenerateWithFunctions when it is not supported.
+type FunctionCallStreamer interface {
+	StreamWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition, onDelta func(FunctionCallDelta)) (*FunctionCallResponse, error)
+}
@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/castrovroberto/CGE/internal/config"
+)
+
+// Semaphore bounds the number of requests in flight
+type Semaphore struct {
+	slots chan struct{}
+}
+
+// NewSemaphore returns a semaphore with max slots
+func NewSemaphore(max int) *Semaphore {
+	return &Semaphore{slots: make(chan struct{}, max)}
+}
+
+// Acquire waits for a free slot or for ctx to be done
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (s *Semaphore) Release() {
+	<-s.slots
+}
+
+// InFlight returns the number of slots taken
+func (s *Semaphore) InFlight() int {
+	return len(s.slots)
+}
+
+// providerSemaphores are shared by all clients of a provider in the process,
+// so parallel sessions and tools cannot overwhelm a local server between them
+var providerSemaphores = struct {
+	sync.Mutex
+	byProvider map[string]*Semaphore
+}{byProvider: make(map[string]*Semaphore)}
+
+// ProviderSemaphore returns the process-wide semaphore of provider with max
+// slots. A different max replaces the semaphore for clients created after.
+func ProviderSemaphore(provider string, max int) *Semaphore {
+	providerSemaphores.Lock()
+	defer providerSemaphores.Unlock()
+	if sem, ok := providerSemaphores.byProvider[provider]; ok && cap(sem.slots) == max {
+		return sem
+	}
+	sem := NewSemaphore(max)
+	providerSemaphores.byProvider[provider] = sem
+	return sem
+}
+
+// ConcurrencyLimitedClient wraps a Client so at most a configured number of
+// its requests, and of all requests to its provider, are in flight at once.
+// Requests over the limit wait for a slot, or fail when their context ends
+// first.
+type ConcurrencyLimitedClient struct {
+	Client
+	semaphores []*Semaphore
+}
+
+// WithConcurrencyLimits wraps client with the limits of cfg. Without limits
+// client is returned unchanged.
+func WithConcurrencyLimits(client Client, cfg config.ConcurrencyConfig) Client {
+	var semaphores []*Semaphore
+	if cfg.SessionMax > 0 {
+		semaphores = append(semaphores, NewSemaphore(cfg.SessionMax))
+	}
+	if cfg.ProviderMax > 0 {
+		semaphores = append(semaphores, ProviderSemaphore(cfg.Provider, cfg.ProviderMax))
+	}
+	if len(semaphores) == 0 {
+		return client
+	}
+	return &ConcurrencyLimitedClient{Client: client, semaphores: semaphores}
+}
+
+// acquire takes a slot of every semaphore, in order, returning the function
+// that frees them
+func (c *ConcurrencyLimitedClient) acquire(ctx context.Context) (func(), error) {
+	for i, sem := range c.semaphores {
+		if err := sem.Acquire(ctx); err != nil {
+			for _, taken := range c.semaphores[:i] {
+				taken.Release()
+			}
+			return nil, err
+		}
+	}
+	return func() {
+		for _, sem := range c.semaphores {
+			sem.Release()
+		}
+	}, nil
+}
+
+// Generate implements Client
+func (c *ConcurrencyLimitedClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return c.Client.Generate(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+// GenerateWithFunctions implements Client
+func (c *ConcurrencyLimitedClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition) (*FunctionCallResponse, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Client.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+// Stream implements Client. The slot is held until the stream ends.
+func (c *ConcurrencyLimitedClient) Stream(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}, out chan<- string) error {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		close(out)
+		return err
+	}
+	defer release()
+	return c.Client.Stream(ctx, modelName, prompt, systemPrompt, tools, out)
+}
+
+// StreamWithFunctions implements FunctionCallStreamer, falling back to
+// GenerateWithFunctions when the wrapped client cannot stream
+func (c *ConcurrencyLimitedClient) StreamWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition, onDelta func(FunctionCallDelta)) (*FunctionCallResponse, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if streamer, ok := c.Client.(FunctionCallStreamer); ok {
+		return streamer.StreamWithFunctions(ctx, modelName, prompt, systemPrompt, tools, onDelta)
+	}
+	return c.Client.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+// Embed implements Client
+func (c *ConcurrencyLimitedClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Client.Embed(ctx, text)
+}
+
+// EmbedBatch implements BatchEmbedder. A batch takes a single slot; when the
+// wrapped client cannot batch, the texts are embedded concurrently, each
+// taking a slot of its own.
+func (c *ConcurrencyLimitedClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	batcher, ok := c.Client.(BatchEmbedder)
+	if !ok {
+		return c.embedEach(ctx, texts)
+	}
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return batcher.EmbedBatch(ctx, texts)
+}
+
+// embedEach embeds texts with concurrent Embed calls, returning the first
+// error
+func (c *ConcurrencyLimitedClient) embedEach(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	errs := make([]error, len(texts))
+	var wg sync.WaitGroup
+	for i, text := range texts {
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			embeddings[i], errs[i] = c.Embed(ctx, text)
+		}(i, text)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return embeddings, nil
+}
+
+// GenerateThought implements Client
+func (c *ConcurrencyLimitedClient) GenerateThought(ctx context.Context, modelName, prompt, context string) (*ThoughtResponse, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Client.GenerateThought(ctx, modelName, prompt, context)
+}
+
+// AssessConfidence implements Client
+func (c *ConcurrencyLimitedClient) AssessConfidence(ctx context.Context, modelName, thought, proposedAction string) (*ConfidenceAssessment, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Client.AssessConfidence(ctx, modelName, thought, proposedAction)
+}
+
+// EmbeddingModel implements EmbeddingModeler
+func (c *ConcurrencyLimitedClient) EmbeddingModel() string {
+	return EmbeddingModelOf(c.Client)
+}
+
+// SetPullProgress implements PullProgressSetter
+func (c *ConcurrencyLimitedClient) SetPullProgress(progress func(PullProgress)) {
+	SetPullProgressOf(c.Client, progress)
+}
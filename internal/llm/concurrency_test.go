@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowClient answers Generate and Embed after a short wait, recording the
+// most requests it had in flight at once
+type slowClient struct {
+	Client
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *slowClient) request() {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+}
+
+func (c *slowClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	c.request()
+	return "ok", nil
+}
+
+func (c *slowClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	c.request()
+	return []float32{1}, nil
+}
+
+func TestConcurrencyLimitedClient(t *testing.T) {
+	inner := &slowClient{}
+	client := WithConcurrencyLimits(inner, config.ConcurrencyConfig{SessionMax: 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Generate(context.Background(), "model", "prompt", "", nil)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2, inner.maxInFlight)
+
+	embeddings, err := client.(BatchEmbedder).EmbedBatch(context.Background(), []string{"a", "b", "c", "d"})
+	require.NoError(t, err)
+	assert.Len(t, embeddings, 4)
+	assert.Equal(t, 2, inner.maxInFlight, "texts of a batch share the limit")
+}
+
+func TestConcurrencyLimitsShareProviderSlots(t *testing.T) {
+	inner := &slowClient{}
+	cfg := config.ConcurrencyConfig{Provider: "test-shared", ProviderMax: 1}
+	first := WithConcurrencyLimits(inner, cfg)
+	second := WithConcurrencyLimits(inner, cfg)
+
+	var wg sync.WaitGroup
+	for _, client := range []Client{first, second, first, second} {
+		wg.Add(1)
+		go func(client Client) {
+			defer wg.Done()
+			_, err := client.Embed(context.Background(), "text")
+			assert.NoError(t, err)
+		}(client)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, inner.maxInFlight)
+}
+
+func TestConcurrencyLimitedClientCancelledWhileWaiting(t *testing.T) {
+	sem := ProviderSemaphore("test-cancel", 1)
+	require.NoError(t, sem.Acquire(context.Background()))
+	defer sem.Release()
+
+	client := WithConcurrencyLimits(&slowClient{}, config.ConcurrencyConfig{Provider: "test-cancel", ProviderMax: 1, SessionMax: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.Generate(ctx, "model", "prompt", "", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, sem.InFlight(), "only the held slot is taken")
+	assert.Zero(t, client.(*ConcurrencyLimitedClient).semaphores[0].InFlight(), "the session slot is given back")
+
+	out := make(chan string)
+	assert.Error(t, client.Stream(ctx, "model", "prompt", "", nil, out))
+	_, open := <-out
+	assert.False(t, open, "the stream channel is closed")
+}
+
+func TestWithConcurrencyLimitsUnlimited(t *testing.T) {
+	inner := &slowClient{}
+	assert.Same(t, Client(inner), WithConcurrencyLimits(inner, config.ConcurrencyConfig{Provider: "ollama"}))
+}
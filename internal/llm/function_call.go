@@ -1,10 +1,14 @@
 package llm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
+
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/logger"
 )
 
 // FunctionCall represents a function call request from the LLM
@@ -148,6 +152,35 @@ func CreateToolDefinition(name, description string, parameters json.RawMessage)
 	}
 }
 
+// generateWithPromptTools emulates function calling for clients and models
+// without it: the tool definitions are embedded in the prompt and a function
+// call is parsed from the text response
+func generateWithPromptTools(ctx context.Context, client Client, modelName, prompt string, systemPrompt string, tools []ToolDefinition) (*FunctionCallResponse, error) {
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
+
+	enhancedPrompt := prompt
+	if len(tools) > 0 {
+		enhancedPrompt = prompt + FormatToolCallForPrompt(tools)
+	}
+
+	response, err := client.Generate(ctx, modelName, enhancedPrompt, systemPrompt, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the response to see if it's a function call or text
+	functionCallResponse, parseErr := ParseFunctionCall(response)
+	if parseErr != nil {
+		log.Warn("Failed to parse function call response, treating as text", "error", parseErr)
+		return &FunctionCallResponse{
+			IsTextResponse: true,
+			TextContent:    response,
+		}, nil
+	}
+
+	return functionCallResponse, nil
+}
+
 // FormatToolCallForPrompt formats tool definitions for inclusion in prompts (for providers without native function calling)
 func FormatToolCallForPrompt(tools []ToolDefinition) string {
 	if len(tools) == 0 {
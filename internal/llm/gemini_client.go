@@ -242,6 +242,14 @@ func (gc *GeminiClient) SupportsNativeFunctionCalling() bool {
 	return true
 }
 
+// GeminiEmbeddingModel is the model Gemini embeddings come from
+const GeminiEmbeddingModel = "text-embedding-004"
+
+// EmbeddingModel implements EmbeddingModeler
+func (gc *GeminiClient) EmbeddingModel() string {
+	return GeminiEmbeddingModel
+}
+
 // Embed generates embeddings for the given text using Gemini
 func (gc *GeminiClient) Embed(ctx context.Context, text string) ([]float32, error) {
 	if err := gc.initClient(ctx); err != nil {
@@ -249,7 +257,7 @@ func (gc *GeminiClient) Embed(ctx context.Context, text string) ([]float32, erro
 	}
 
 	// Use the embedding model
-	embeddingModel := gc.client.EmbeddingModel("text-embedding-004")
+	embeddingModel := gc.client.EmbeddingModel(gc.EmbeddingModel())
 
 	res, err := embeddingModel.EmbedContent(ctx, genai.Text(text))
 	if err != nil {
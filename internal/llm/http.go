@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/httpclient"
+)
+
+// httpClientOrDefault returns client, or the shared client when it is nil
+func httpClientOrDefault(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return httpclient.Default()
+}
+
+// doWithTimeout sends req with client. When timeout is positive it bounds the
+// request including the reading of the response body, like http.Client's
+// Timeout but without a client per request; closing the body releases it.
+func doWithTimeout(client *http.Client, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout <= 0 {
+		return client.Do(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose cancels a request's context when its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,11 @@
+package llm
+
+import "context"
+
+// ModelLoader is implemented by clients whose provider keeps models loaded in
+// memory between requests and can load or unload them on demand, e.g. to free
+// VRAM while the user is away and to warm the model before they are back
+type ModelLoader interface {
+	LoadModel(ctx context.Context, modelName string) error
+	UnloadModel(ctx context.Context, modelName string) error
+}
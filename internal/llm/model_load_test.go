@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOllamaClient_LoadAndUnloadModel(t *testing.T) {
+	var requests []OllamaRequest
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/generate", r.URL.Path)
+		var body OllamaRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, body)
+		if body.Model != "llama3" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"model 'missing' not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"model":"llama3","response":"","done":true}`))
+	}))
+	t.Cleanup(httpServer.Close)
+
+	var client ModelLoader = NewOllamaClient(config.OllamaConfig{
+		HostURL:        httpServer.URL,
+		RequestTimeout: 5 * time.Second,
+		KeepAlive:      "10m",
+	})
+
+	require.NoError(t, client.UnloadModel(context.Background(), "llama3"))
+	require.NoError(t, client.LoadModel(context.Background(), "llama3"))
+	require.Len(t, requests, 2)
+	assert.Equal(t, "0", requests[0].KeepAlive)
+	assert.Equal(t, "10m", requests[1].KeepAlive)
+	for _, request := range requests {
+		assert.Empty(t, request.Prompt, "a request without a prompt only loads or unloads")
+	}
+
+	err := client.LoadModel(context.Background(), "missing")
+	assert.True(t, errors.Is(err, ErrOllamaModelNotFound))
+}
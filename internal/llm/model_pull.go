@@ -0,0 +1,236 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ModelNotFoundError is returned when the server does not have the requested
+// model. It matches ErrOllamaModelNotFound with errors.Is.
+type ModelNotFoundError struct {
+	Model         string
+	ServerMessage string
+}
+
+func (e *ModelNotFoundError) Error() string {
+	return fmt.Sprintf("%s: %s (model: %s)", ErrOllamaModelNotFound, e.ServerMessage, e.Model)
+}
+
+// Unwrap returns ErrOllamaModelNotFound
+func (e *ModelNotFoundError) Unwrap() error {
+	return ErrOllamaModelNotFound
+}
+
+// PullProgress is a progress update from downloading a model
+type PullProgress struct {
+	Model     string
+	Status    string // e.g. "pulling manifest", "downloading sha256:...", "success"
+	Completed int64  // Bytes downloaded of the current layer
+	Total     int64  // Size of the current layer; 0 when unknown
+}
+
+// Fraction returns the progress of the current layer from 0.0 to 1.0, or -1
+// when its size is unknown
+func (p PullProgress) Fraction() float64 {
+	if p.Total <= 0 {
+		return -1
+	}
+	return float64(p.Completed) / float64(p.Total)
+}
+
+// ModelPuller is implemented by clients whose provider can download models on
+// demand
+type ModelPuller interface {
+	PullModel(ctx context.Context, modelName string, progress func(PullProgress)) error
+}
+
+// PullProgressSetter is implemented by clients that pull missing models on
+// their own, and by wrappers that forward to the client they wrap
+type PullProgressSetter interface {
+	SetPullProgress(progress func(PullProgress))
+}
+
+// SetPullProgressOf replaces the pull progress callback of client, or of the
+// ModelRecoveryClient it wraps; clients that never pull models ignore it
+func SetPullProgressOf(client Client, progress func(PullProgress)) {
+	if setter, ok := client.(PullProgressSetter); ok {
+		setter.SetPullProgress(progress)
+	}
+}
+
+// PullConfirmFunc asks whether a missing model should be pulled
+type PullConfirmFunc func(ctx context.Context, modelName string) (bool, error)
+
+// ModelRecoveryClient wraps a Client so a request that fails because the
+// model is not on the server pulls the model, after confirmation, and is then
+// retried once. Callers see the request succeed as if the model had been
+// there all along.
+type ModelRecoveryClient struct {
+	Client
+	puller   ModelPuller
+	confirm  PullConfirmFunc
+	progress func(PullProgress)
+
+	// mu serializes recovery, so concurrent requests for a missing model (such
+	// as embedding workers) trigger a single prompt and pull
+	mu       sync.Mutex
+	pulled   map[string]bool
+	declined map[string]bool
+}
+
+// NewModelRecoveryClient wraps client. confirm is asked before each pull;
+// progress, if not nil, receives pull progress.
+func NewModelRecoveryClient(client Client, puller ModelPuller, confirm PullConfirmFunc, progress func(PullProgress)) *ModelRecoveryClient {
+	return &ModelRecoveryClient{
+		Client:   client,
+		puller:   puller,
+		confirm:  confirm,
+		progress: progress,
+		pulled:   make(map[string]bool),
+		declined: make(map[string]bool),
+	}
+}
+
+// SetPullProgress replaces the pull progress callback
+func (c *ModelRecoveryClient) SetPullProgress(progress func(PullProgress)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.progress = progress
+}
+
+// recoverMissingModel pulls the missing model err refers to. It reports whether the
+// request should be retried; a pull failure is returned wrapped with err.
+func (c *ModelRecoveryClient) recoverMissingModel(ctx context.Context, err error) (bool, error) {
+	var notFound *ModelNotFoundError
+	if !errors.As(err, &notFound) {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	model := notFound.Model
+	if c.pulled[model] {
+		return true, nil // Pulled by a concurrent request
+	}
+	if c.declined[model] {
+		return false, err
+	}
+
+	ok, confirmErr := c.confirm(ctx, model)
+	if confirmErr != nil {
+		return false, fmt.Errorf("%w (could not confirm pulling the model: %v)", err, confirmErr)
+	}
+	if !ok {
+		c.declined[model] = true
+		return false, err
+	}
+
+	if pullErr := c.puller.PullModel(ctx, model, c.progress); pullErr != nil {
+		return false, fmt.Errorf("%w (pulling the model failed: %v)", err, pullErr)
+	}
+	c.pulled[model] = true
+	return true, nil
+}
+
+// Generate implements Client
+func (c *ModelRecoveryClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	response, err := c.Client.Generate(ctx, modelName, prompt, systemPrompt, tools)
+	if err == nil {
+		return response, nil
+	}
+	if retry, err := c.recoverMissingModel(ctx, err); !retry {
+		return "", err
+	}
+	return c.Client.Generate(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+// GenerateWithFunctions implements Client
+func (c *ModelRecoveryClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition) (*FunctionCallResponse, error) {
+	response, err := c.Client.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+	if err == nil {
+		return response, nil
+	}
+	if retry, err := c.recoverMissingModel(ctx, err); !retry {
+		return nil, err
+	}
+	return c.Client.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+// Stream implements Client. Each attempt streams into its own channel, since
+// the wrapped client closes the channel it is given.
+func (c *ModelRecoveryClient) Stream(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}, out chan<- string) error {
+	defer close(out)
+
+	attempt := func() error {
+		chunks := make(chan string)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- c.Client.Stream(ctx, modelName, prompt, systemPrompt, tools, chunks)
+		}()
+		for chunk := range chunks {
+			out <- chunk
+		}
+		return <-errCh
+	}
+
+	err := attempt()
+	if err == nil {
+		return nil
+	}
+	if retry, err := c.recoverMissingModel(ctx, err); !retry {
+		return err
+	}
+	return attempt()
+}
+
+// StreamWithFunctions implements FunctionCallStreamer, falling back to
+// GenerateWithFunctions when the wrapped client cannot stream. A missing model
+// fails the request before anything is streamed, so the retry streams the
+// whole response.
+func (c *ModelRecoveryClient) StreamWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition, onDelta func(FunctionCallDelta)) (*FunctionCallResponse, error) {
+	streamer, ok := c.Client.(FunctionCallStreamer)
+	if !ok {
+		return c.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+	}
+	response, err := streamer.StreamWithFunctions(ctx, modelName, prompt, systemPrompt, tools, onDelta)
+	if err == nil {
+		return response, nil
+	}
+	if retry, err := c.recoverMissingModel(ctx, err); !retry {
+		return nil, err
+	}
+	return streamer.StreamWithFunctions(ctx, modelName, prompt, systemPrompt, tools, onDelta)
+}
+
+// Embed implements Client
+func (c *ModelRecoveryClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	embedding, err := c.Client.Embed(ctx, text)
+	if err == nil {
+		return embedding, nil
+	}
+	if retry, err := c.recoverMissingModel(ctx, err); !retry {
+		return nil, err
+	}
+	return c.Client.Embed(ctx, text)
+}
+
+// EmbedBatch implements BatchEmbedder, embedding the texts one by one when the
+// wrapped client cannot batch
+func (c *ModelRecoveryClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings, err := embedBatchOf(ctx, c.Client, texts)
+	if err == nil {
+		return embeddings, nil
+	}
+	if retry, err := c.recoverMissingModel(ctx, err); !retry {
+		return nil, err
+	}
+	return embedBatchOf(ctx, c.Client, texts)
+}
+
+// EmbeddingModel implements EmbeddingModeler
+func (c *ModelRecoveryClient) EmbeddingModel() string {
+	return EmbeddingModelOf(c.Client)
+}
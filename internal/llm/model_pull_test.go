@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOllamaServer serves /api/generate for the models it has and adds a
+// model on /api/pull
+type fakeOllamaServer struct {
+	mu     sync.Mutex
+	models map[string]bool
+	pulls  int
+}
+
+func (s *fakeOllamaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model string `json:"model"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/api/pull":
+		s.pulls++
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		fmt.Fprintln(w, `{"status":"downloading sha256:abc","completed":50,"total":100}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+		s.models[body.Model] = true
+	case "/api/generate":
+		if !s.models[body.Model] {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"model '%s' not found"}`, body.Model)
+			return
+		}
+		fmt.Fprintf(w, `{"model":%q,"response":"hello","done":true}`, body.Model)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestOllamaClient(t *testing.T, server *fakeOllamaServer) *OllamaClient {
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return NewOllamaClient(config.OllamaConfig{
+		HostURL:           httpServer.URL,
+		RequestTimeout:    5 * time.Second,
+		RequestsPerMinute: 600,
+	})
+}
+
+func TestOllamaClient_PullModel(t *testing.T) {
+	server := &fakeOllamaServer{models: map[string]bool{}}
+	client := newTestOllamaClient(t, server)
+
+	var updates []PullProgress
+	err := client.PullModel(context.Background(), "llama3", func(p PullProgress) {
+		updates = append(updates, p)
+	})
+	require.NoError(t, err)
+
+	require.Len(t, updates, 3)
+	assert.Equal(t, "pulling manifest", updates[0].Status)
+	assert.Equal(t, -1.0, updates[0].Fraction())
+	assert.InDelta(t, 0.5, updates[1].Fraction(), 1e-9)
+	assert.Equal(t, "success", updates[2].Status)
+	assert.Equal(t, "llama3", updates[2].Model)
+}
+
+func TestModelRecoveryClient(t *testing.T) {
+	t.Run("pulls the missing model and retries", func(t *testing.T) {
+		server := &fakeOllamaServer{models: map[string]bool{}}
+		ollama := newTestOllamaClient(t, server)
+
+		var asked []string
+		client := NewModelRecoveryClient(ollama, ollama, func(ctx context.Context, model string) (bool, error) {
+			asked = append(asked, model)
+			return true, nil
+		}, nil)
+
+		response, err := client.Generate(context.Background(), "llama3", "hi", "", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", response)
+		assert.Equal(t, []string{"llama3"}, asked)
+		assert.Equal(t, 1, server.pulls)
+
+		// The model is now on the server, so nothing is asked again
+		_, err = client.Generate(context.Background(), "llama3", "hi", "", nil)
+		require.NoError(t, err)
+		assert.Len(t, asked, 1)
+	})
+
+	t.Run("progress reaches a wrapped recovery client", func(t *testing.T) {
+		server := &fakeOllamaServer{models: map[string]bool{}}
+		ollama := newTestOllamaClient(t, server)
+		recovery := NewModelRecoveryClient(ollama, ollama, func(ctx context.Context, model string) (bool, error) {
+			return true, nil
+		}, nil)
+		client := WithUsageMeter(WithConcurrencyLimits(recovery, config.ConcurrencyConfig{SessionMax: 2}), &UsageMeter{})
+
+		var statuses []string
+		SetPullProgressOf(client, func(progress PullProgress) {
+			statuses = append(statuses, progress.Status)
+		})
+		_, err := client.Generate(context.Background(), "llama3", "hi", "", nil)
+		require.NoError(t, err)
+		assert.Contains(t, statuses, "success", "the wrappers forward the progress callback")
+	})
+
+	t.Run("declined pull is not asked again", func(t *testing.T) {
+		server := &fakeOllamaServer{models: map[string]bool{}}
+		ollama := newTestOllamaClient(t, server)
+
+		asked := 0
+		client := NewModelRecoveryClient(ollama, ollama, func(ctx context.Context, model string) (bool, error) {
+			asked++
+			return false, nil
+		}, nil)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.Generate(context.Background(), "llama3", "hi", "", nil)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrOllamaModelNotFound))
+		}
+		assert.Equal(t, 1, asked)
+		assert.Equal(t, 0, server.pulls)
+	})
+}
@@ -15,18 +15,24 @@ import (
 
 	"github.com/castrovroberto/CGE/internal/config"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/logger"
 )
 
 // OllamaClient implements the Client interface for Ollama.
 type OllamaClient struct {
-	config config.OllamaConfig
+	config   config.OllamaConfig
+	contexts *ollamaContextCache // nil unless context reuse is enabled
 }
 
 // NewOllamaClient creates a new Ollama client with the provided configuration.
 func NewOllamaClient(cfg config.OllamaConfig) *OllamaClient {
-	return &OllamaClient{
+	client := &OllamaClient{
 		config: cfg,
 	}
+	if cfg.ContextReuse {
+		client.contexts = newOllamaContextCache()
+	}
+	return client
 }
 
 // OllamaRequest represents the request structure for Ollama's /api/generate and /api/chat endpoints.
@@ -37,7 +43,8 @@ type OllamaRequest struct {
 	System    string                   `json:"system,omitempty"` // For system prompt
 	Stream    bool                     `json:"stream"`
 	KeepAlive string                   `json:"keep_alive,omitempty"`
-	Tools     []map[string]interface{} `json:"tools,omitempty"` // Experimental: Ollama's tool support might require specific formatting or might not be standard via /api/generate.
+	Context   []int                    `json:"context,omitempty"` // Returned for an earlier prompt this one continues
+	Tools     []map[string]interface{} `json:"tools,omitempty"`   // Experimental: Ollama's tool support might require specific formatting or might not be standard via /api/generate.
 	// Messages  []OllamaMessage `json:"messages,omitempty"` // Used for /api/chat
 }
 
@@ -78,7 +85,7 @@ var (
 
 // Generate performs a non-streaming generation request to Ollama.
 func (oc *OllamaClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
 
 	apiURL := fmt.Sprintf("%s/api/generate", strings.TrimRight(oc.config.HostURL, "/"))
 
@@ -95,6 +102,7 @@ func (oc *OllamaClient) Generate(ctx context.Context, modelName, prompt string,
 		KeepAlive: oc.config.KeepAlive,
 		// Tools: tools, // How tools are passed to Ollama's generate endpoint needs clarification. Might be part of prompt.
 	}
+	reused := oc.reuseContext(ctx, &requestPayload)
 
 	requestBody, err := json.Marshal(requestPayload)
 	if err != nil {
@@ -121,8 +129,7 @@ func (oc *OllamaClient) Generate(ctx context.Context, modelName, prompt string,
 		req.Header.Set("Content-Type", "application/json")
 
 		log.Debug("Sending Ollama query", "url", apiURL, "model", modelName, "attempt", i+1)
-		httpClient := &http.Client{Timeout: oc.config.RequestTimeout}
-		resp, httpErr := httpClient.Do(req)
+		resp, httpErr := doWithTimeout(httpClientOrDefault(oc.config.HTTPClient), req, oc.config.RequestTimeout)
 		lastErr = httpErr
 
 		if httpErr != nil {
@@ -163,7 +170,10 @@ func (oc *OllamaClient) Generate(ctx context.Context, modelName, prompt string,
 				time.Sleep(time.Second * time.Duration(i+1))
 				continue
 			}
-			log.Debug("Ollama query successful", "model_returned", ollamaResp.Model)
+			log.Debug("Ollama query successful", "model_returned", ollamaResp.Model, "prompt_eval_count", ollamaResp.PromptEvalCount)
+			if oc.contexts != nil {
+				oc.contexts.remember(modelName, systemPrompt, prompt, ollamaResp.Response, ollamaResp.Context, ollamaResp.EvalCount)
+			}
 			return ollamaResp.Response, nil
 		}
 
@@ -173,12 +183,21 @@ func (oc *OllamaClient) Generate(ctx context.Context, modelName, prompt string,
 			errMsgLower := strings.ToLower(ollamaErrorResp.Error)
 			if strings.Contains(errMsgLower, "model") && (strings.Contains(errMsgLower, "not found") || strings.Contains(errMsgLower, "does not exist")) {
 				log.Error("Ollama model not found by server", "model_requested", modelName, "server_error", ollamaErrorResp.Error)
-				return "", fmt.Errorf("%w: %s (model: %s)", ErrOllamaModelNotFound, ollamaErrorResp.Error, modelName)
+				return "", &ModelNotFoundError{Model: modelName, ServerMessage: ollamaErrorResp.Error}
 			}
 			lastErr = fmt.Errorf("ollama: API error - \"%s\" (HTTP %d)", strings.TrimSpace(ollamaErrorResp.Error), resp.StatusCode)
 		} else {
 			lastErr = fmt.Errorf("ollama: API returned status %d with unparsed error", resp.StatusCode)
 		}
+		if reused {
+			// The context may not be valid anymore, e.g. after the model was
+			// reloaded; retry with the whole prompt
+			oc.contexts.forget(modelName, systemPrompt)
+			requestPayload.Prompt, requestPayload.Context, reused = prompt, nil, false
+			if body, err := json.Marshal(requestPayload); err == nil {
+				requestBody = body
+			}
+		}
 
 		if i == maxRetries {
 			log.Error("Ollama request failed after all retries with non-OK status", "final_error", lastErr)
@@ -194,9 +213,9 @@ func (oc *OllamaClient) Generate(ctx context.Context, modelName, prompt string,
 }
 
 // Stream performs a streaming generation request to Ollama.
-func (oc *OllamaClient) Stream(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}, out chan<- string) error {
+func (oc *OllamaClient) Stream(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}, out chan<- string) (err error) {
 	defer close(out) // Ensure channel is closed when function exits
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
 
 	apiURL := fmt.Sprintf("%s/api/generate", strings.TrimRight(oc.config.HostURL, "/"))
 
@@ -208,6 +227,13 @@ func (oc *OllamaClient) Stream(ctx context.Context, modelName, prompt string, sy
 		KeepAlive: oc.config.KeepAlive,
 		// Tools: tools, // As with Generate, tool handling needs review for Ollama stream
 	}
+	if oc.reuseContext(ctx, &requestPayload) {
+		defer func() {
+			if err != nil && !errors.Is(err, context.Canceled) {
+				oc.contexts.forget(modelName, systemPrompt)
+			}
+		}()
+	}
 
 	requestBody, err := json.Marshal(requestPayload)
 	if err != nil {
@@ -222,8 +248,7 @@ func (oc *OllamaClient) Stream(ctx context.Context, modelName, prompt string, sy
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	httpClient := &http.Client{Timeout: oc.config.RequestTimeout} // Timeout for the entire stream might need adjustment
-	resp, err := httpClient.Do(req)
+	resp, err := doWithTimeout(httpClientOrDefault(oc.config.HTTPClient), req, oc.config.RequestTimeout) // Timeout for the entire stream might need adjustment
 	if err != nil {
 		log.Error("Ollama streaming request failed", "error", err)
 		// Check for host unreachable specifically
@@ -241,7 +266,7 @@ func (oc *OllamaClient) Stream(ctx context.Context, modelName, prompt string, sy
 		var ollamaErrorResp OllamaErrorResponse
 		if json.Unmarshal(bodyBytes, &ollamaErrorResp) == nil && ollamaErrorResp.Error != "" {
 			if strings.Contains(strings.ToLower(ollamaErrorResp.Error), "model not found") {
-				return fmt.Errorf("%w: %s (model: %s)", ErrOllamaModelNotFound, ollamaErrorResp.Error, modelName)
+				return &ModelNotFoundError{Model: modelName, ServerMessage: ollamaErrorResp.Error}
 			}
 			return fmt.Errorf("ollama stream: API error - \"%s\" (HTTP %d)", ollamaErrorResp.Error, resp.StatusCode)
 		}
@@ -249,6 +274,7 @@ func (oc *OllamaClient) Stream(ctx context.Context, modelName, prompt string, sy
 	}
 
 	decoder := json.NewDecoder(resp.Body)
+	var response strings.Builder
 	for {
 		var ollamaResp OllamaResponse
 		if err := decoder.Decode(&ollamaResp); err != nil {
@@ -266,7 +292,12 @@ func (oc *OllamaClient) Stream(ctx context.Context, modelName, prompt string, sy
 			return ctx.Err()
 		}
 
+		response.WriteString(ollamaResp.Response)
+
 		if ollamaResp.Done {
+			if oc.contexts != nil {
+				oc.contexts.remember(modelName, systemPrompt, prompt, response.String(), ollamaResp.Context, ollamaResp.EvalCount)
+			}
 			break
 		}
 	}
@@ -289,7 +320,7 @@ type OllamaTagsResponse struct {
 
 // ListAvailableModels retrieves a list of available models from Ollama.
 func (oc *OllamaClient) ListAvailableModels(ctx context.Context) ([]string, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
 
 	apiURL := fmt.Sprintf("%s/api/tags", strings.TrimRight(oc.config.HostURL, "/"))
 
@@ -299,8 +330,7 @@ func (oc *OllamaClient) ListAvailableModels(ctx context.Context) ([]string, erro
 		return nil, fmt.Errorf("ollama listmodels: failed to create request: %w", err)
 	}
 
-	httpClient := &http.Client{Timeout: oc.config.RequestTimeout} // Use a reasonable timeout
-	resp, err := httpClient.Do(req)
+	resp, err := doWithTimeout(httpClientOrDefault(oc.config.HTTPClient), req, oc.config.RequestTimeout)
 	if err != nil {
 		log.Error("Ollama /api/tags request failed", "error", err)
 		var netErr net.Error
@@ -332,33 +362,165 @@ func (oc *OllamaClient) ListAvailableModels(ctx context.Context) ([]string, erro
 	return modelNames, nil
 }
 
-// GenerateWithFunctions performs a generation request with function calling support for Ollama
-func (oc *OllamaClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition) (*FunctionCallResponse, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+// ollamaPullRequest is the request body of Ollama's /api/pull
+type ollamaPullRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
 
-	// Ollama doesn't have native function calling, so we embed tool definitions in the prompt
-	enhancedPrompt := prompt
-	if len(tools) > 0 {
-		enhancedPrompt = prompt + FormatToolCallForPrompt(tools)
+// ollamaPullResponse is one line of the streamed /api/pull response
+type ollamaPullResponse struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PullModel implements ModelPuller by downloading a model through Ollama's
+// /api/pull. Progress is reported as the server streams it. The request has no
+// timeout of its own, since large models take a while; cancel ctx to stop it.
+func (oc *OllamaClient) PullModel(ctx context.Context, modelName string, progress func(PullProgress)) error {
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
+
+	apiURL := fmt.Sprintf("%s/api/pull", strings.TrimRight(oc.config.HostURL, "/"))
+	requestBody, err := json.Marshal(ollamaPullRequest{Model: modelName, Stream: true})
+	if err != nil {
+		return fmt.Errorf("ollama pull: failed to marshal request: %w", err)
 	}
 
-	// Use the existing Generate method
-	response, err := oc.Generate(ctx, modelName, enhancedPrompt, systemPrompt, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(requestBody))
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("ollama pull: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	log.Info("Pulling Ollama model", "model", modelName)
+	resp, err := httpClientOrDefault(oc.config.HTTPClient).Do(req) // Pulls can take minutes; no timeout
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && (netErr.Timeout() || !netErr.Temporary()) {
+			return fmt.Errorf("%w: %v", ErrOllamaHostUnreachable, err)
+		}
+		return fmt.Errorf("ollama pull: request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		var ollamaErrorResp OllamaErrorResponse
+		if json.Unmarshal(bodyBytes, &ollamaErrorResp) == nil && ollamaErrorResp.Error != "" {
+			return fmt.Errorf("ollama pull: API error - \"%s\" (HTTP %d)", ollamaErrorResp.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("ollama pull: API returned status %d", resp.StatusCode)
 	}
 
-	// Parse the response to see if it's a function call or text
-	functionCallResponse, parseErr := ParseFunctionCall(response)
-	if parseErr != nil {
-		log.Warn("Failed to parse function call response, treating as text", "error", parseErr)
-		return &FunctionCallResponse{
-			IsTextResponse: true,
-			TextContent:    response,
-		}, nil
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var line ollamaPullResponse
+		if err := decoder.Decode(&line); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("%w: pull ended without success status", ErrOllamaInvalidResponse)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%w: failed to parse pull progress: %v", ErrOllamaInvalidResponse, err)
+		}
+		if line.Error != "" {
+			return fmt.Errorf("ollama pull: %s", line.Error)
+		}
+		if progress != nil {
+			progress(PullProgress{Model: modelName, Status: line.Status, Completed: line.Completed, Total: line.Total})
+		}
+		if line.Status == "success" {
+			log.Info("Pulled Ollama model", "model", modelName)
+			return nil
+		}
 	}
+}
 
-	return functionCallResponse, nil
+// LoadModel implements ModelLoader by sending Ollama an empty prompt, which
+// loads the model and keeps it loaded for the configured keep_alive
+func (oc *OllamaClient) LoadModel(ctx context.Context, modelName string) error {
+	return oc.setModelKeepAlive(ctx, modelName, oc.config.KeepAlive)
+}
+
+// UnloadModel implements ModelLoader by sending Ollama an empty prompt with
+// keep_alive 0, which unloads the model right away
+func (oc *OllamaClient) UnloadModel(ctx context.Context, modelName string) error {
+	return oc.setModelKeepAlive(ctx, modelName, "0")
+}
+
+// setModelKeepAlive sends /api/generate a request without a prompt, which
+// only loads the model, or unloads it when keepAlive is 0
+func (oc *OllamaClient) setModelKeepAlive(ctx context.Context, modelName, keepAlive string) error {
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
+
+	apiURL := fmt.Sprintf("%s/api/generate", strings.TrimRight(oc.config.HostURL, "/"))
+	requestBody, err := json.Marshal(OllamaRequest{Model: modelName, KeepAlive: keepAlive})
+	if err != nil {
+		return fmt.Errorf("ollama keep_alive: failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(requestBody))
+	if err != nil {
+		return fmt.Errorf("ollama keep_alive: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := doWithTimeout(httpClientOrDefault(oc.config.HTTPClient), req, oc.config.RequestTimeout)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && (netErr.Timeout() || !netErr.Temporary()) {
+			return fmt.Errorf("%w: %v", ErrOllamaHostUnreachable, err)
+		}
+		return fmt.Errorf("ollama keep_alive: request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		var ollamaErrorResp OllamaErrorResponse
+		if json.Unmarshal(bodyBytes, &ollamaErrorResp) == nil && ollamaErrorResp.Error != "" {
+			if strings.Contains(strings.ToLower(ollamaErrorResp.Error), "not found") {
+				return &ModelNotFoundError{Model: modelName, ServerMessage: ollamaErrorResp.Error}
+			}
+			return fmt.Errorf("ollama keep_alive: API error - \"%s\" (HTTP %d)", ollamaErrorResp.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("ollama keep_alive: API returned status %d", resp.StatusCode)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	log.Debug("Set Ollama model keep_alive", "model", modelName, "keep_alive", keepAlive)
+	return nil
+}
+
+// GenerateWithFunctions performs a generation request with function calling support for Ollama
+func (oc *OllamaClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition) (*FunctionCallResponse, error) {
+	if oc.contexts != nil && len(tools) > 0 {
+		// Tools described after the prompt would keep the next prompt from
+		// continuing this one, so its context could not be reused
+		systemPrompt = strings.TrimSpace(systemPrompt + FormatToolCallForPrompt(tools))
+		tools = nil
+	}
+	// Ollama doesn't have native function calling, so we embed tool definitions in the prompt
+	return generateWithPromptTools(ctx, oc, modelName, prompt, systemPrompt, tools)
+}
+
+// reuseContext sends the context of the conversation a request continues
+// with it, in place of the prompt the model has already seen, when context
+// reuse is enabled. It reports whether a context was attached.
+func (oc *OllamaClient) reuseContext(ctx context.Context, request *OllamaRequest) bool {
+	if oc.contexts == nil {
+		return false
+	}
+	rest, tokens := oc.contexts.reuse(request.Model, request.System, request.Prompt)
+	if tokens == nil {
+		return false
+	}
+	contextkeys.LoggerFor(ctx, logger.LLM).Debug("Reusing Ollama context", "model", request.Model, "context_tokens", len(tokens), "skipped_prompt_chars", len(request.Prompt)-len(rest))
+	request.Prompt, request.Context = rest, tokens
+	return true
 }
 
 // SupportsNativeFunctionCalling returns false for Ollama as it doesn't have native function calling
@@ -366,15 +528,22 @@ func (oc *OllamaClient) SupportsNativeFunctionCalling() bool {
 	return false
 }
 
+// OllamaEmbeddingModel is the model Ollama embeddings come from
+const OllamaEmbeddingModel = "nomic-embed-text"
+
+// EmbeddingModel implements EmbeddingModeler
+func (oc *OllamaClient) EmbeddingModel() string {
+	return OllamaEmbeddingModel
+}
+
 // Embed generates embeddings for the given text using Ollama's embedding models
 func (oc *OllamaClient) Embed(ctx context.Context, text string) ([]float32, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
 
 	// Use Ollama's /api/embeddings endpoint
 	apiURL := fmt.Sprintf("%s/api/embeddings", strings.TrimRight(oc.config.HostURL, "/"))
 
-	// Default embedding model - could be made configurable
-	embeddingModel := "nomic-embed-text"
+	embeddingModel := oc.EmbeddingModel()
 
 	requestPayload := map[string]interface{}{
 		"model":  embeddingModel,
@@ -394,8 +563,7 @@ func (oc *OllamaClient) Embed(ctx context.Context, text string) ([]float32, erro
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	httpClient := &http.Client{Timeout: oc.config.RequestTimeout}
-	resp, err := httpClient.Do(req)
+	resp, err := doWithTimeout(httpClientOrDefault(oc.config.HTTPClient), req, oc.config.RequestTimeout)
 	if err != nil {
 		log.Error("Ollama embedding request failed", "error", err)
 		var netErr net.Error
@@ -417,7 +585,7 @@ func (oc *OllamaClient) Embed(ctx context.Context, text string) ([]float32, erro
 		var ollamaErrorResp OllamaErrorResponse
 		if json.Unmarshal(responseBodyBytes, &ollamaErrorResp) == nil && ollamaErrorResp.Error != "" {
 			if strings.Contains(strings.ToLower(ollamaErrorResp.Error), "model not found") {
-				return nil, fmt.Errorf("%w: %s (model: %s)", ErrOllamaModelNotFound, ollamaErrorResp.Error, embeddingModel)
+				return nil, &ModelNotFoundError{Model: embeddingModel, ServerMessage: ollamaErrorResp.Error}
 			}
 			return nil, fmt.Errorf("ollama embed: API error - \"%s\" (HTTP %d)", ollamaErrorResp.Error, resp.StatusCode)
 		}
@@ -451,7 +619,7 @@ func (oc *OllamaClient) SupportsEmbeddings() bool {
 
 // GenerateThought performs deliberation step for internal reasoning (fallback implementation)
 func (oc *OllamaClient) GenerateThought(ctx context.Context, modelName, prompt, context string) (*ThoughtResponse, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
 
 	// For Ollama, we'll simulate deliberation using structured prompts
 	thoughtPrompt := fmt.Sprintf(`
@@ -507,7 +675,7 @@ Please structure your response clearly and provide a confidence score.`, context
 
 // AssessConfidence evaluates confidence in a proposed action (fallback implementation)
 func (oc *OllamaClient) AssessConfidence(ctx context.Context, modelName, thought, proposedAction string) (*ConfidenceAssessment, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
 
 	confidencePrompt := fmt.Sprintf(`
 Assess the confidence in this proposed action based on the reasoning provided.
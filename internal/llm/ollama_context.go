@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// ollamaContextCacheSize caps the conversations whose context is kept
+const ollamaContextCacheSize = 8
+
+// ollamaContext is the context Ollama returned for a prompt: the tokens of
+// the prompt and of the response, which spare re-evaluating them when they
+// are sent back with a prompt continuing the conversation
+type ollamaContext struct {
+	prompt         string
+	response       string
+	tokens         []int
+	responseTokens int // At the end of tokens
+	used           time.Time
+}
+
+// ollamaContextCache keeps the last context of each model and system prompt.
+// A context is only reused for a prompt repeating the one it was returned
+// for word for word, so conversations sharing a key can only cost a miss.
+type ollamaContextCache struct {
+	mu       sync.Mutex
+	contexts map[string]*ollamaContext
+}
+
+func newOllamaContextCache() *ollamaContextCache {
+	return &ollamaContextCache{contexts: make(map[string]*ollamaContext)}
+}
+
+func ollamaContextKey(model, system string) string {
+	return model + "\x00" + system
+}
+
+// reuse returns the part of prompt the model has not seen yet and the
+// context to send with it. When prompt continues the last prompt and its
+// response, the whole context is reused; when it continues the prompt with
+// the response written differently, as agents record tool calls, the tokens
+// of the response are dropped. Otherwise prompt is returned without context.
+func (c *ollamaContextCache) reuse(model, system, prompt string) (string, []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last, ok := c.contexts[ollamaContextKey(model, system)]
+	if !ok {
+		return prompt, nil
+	}
+	var rest string
+	var tokens []int
+	switch seen := last.prompt + last.response; {
+	case strings.HasPrefix(prompt, seen):
+		rest, tokens = prompt[len(seen):], last.tokens
+	case strings.HasPrefix(prompt, last.prompt) && last.responseTokens > 0 && last.responseTokens < len(last.tokens):
+		rest, tokens = prompt[len(last.prompt):], last.tokens[:len(last.tokens)-last.responseTokens]
+	}
+	if rest = strings.TrimLeft(rest, "\n"); strings.TrimSpace(rest) == "" {
+		return prompt, nil
+	}
+	last.used = time.Now()
+	return rest, tokens
+}
+
+// remember keeps the context returned for prompt, the whole prompt the
+// response answers
+func (c *ollamaContextCache) remember(model, system, prompt, response string, tokens []int, responseTokens int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := ollamaContextKey(model, system)
+	if len(tokens) == 0 {
+		delete(c.contexts, key)
+		return
+	}
+	if _, ok := c.contexts[key]; !ok && len(c.contexts) >= ollamaContextCacheSize {
+		var oldest string
+		for k, context := range c.contexts {
+			if oldest == "" || context.used.Before(c.contexts[oldest].used) {
+				oldest = k
+			}
+		}
+		delete(c.contexts, oldest)
+	}
+	c.contexts[key] = &ollamaContext{prompt: prompt, response: response, tokens: tokens, responseTokens: responseTokens, used: time.Now()}
+}
+
+// forget drops the context of a model and system prompt, as after the
+// server rejected it
+func (c *ollamaContextCache) forget(model, system string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.contexts, ollamaContextKey(model, system))
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contextServer answers /api/generate with a context of one token per
+// character of the prompts and responses it has seen, and records the
+// requests; it rejects contexts when reject is set
+type contextServer struct {
+	mu       sync.Mutex
+	requests []OllamaRequest
+	response string
+	reject   bool
+}
+
+func (s *contextServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request OllamaRequest
+	_ = json.NewDecoder(r.Body).Decode(&request)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request)
+	if s.reject && request.Context != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid context"}`)
+		return
+	}
+	tokens := append([]int{}, request.Context...)
+	for range request.Prompt {
+		tokens = append(tokens, 1)
+	}
+	for range s.response {
+		tokens = append(tokens, 2)
+	}
+	answer, _ := json.Marshal(OllamaResponse{Response: s.response, Done: true, Context: tokens, PromptEvalCount: len(request.Prompt), EvalCount: len(s.response)})
+	if request.Stream {
+		fmt.Fprintf(w, "{\"response\":%q}\n", s.response)
+		answer, _ = json.Marshal(OllamaResponse{Done: true, Context: tokens, EvalCount: len(s.response)})
+	}
+	w.Write(answer)
+}
+
+func (s *contextServer) last() OllamaRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+func newContextClient(t *testing.T, server *contextServer, reuse bool) *OllamaClient {
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return NewOllamaClient(config.OllamaConfig{HostURL: httpServer.URL, RequestTimeout: 5 * time.Second, ContextReuse: reuse})
+}
+
+func TestOllamaClient_ContextReuse(t *testing.T) {
+	server := &contextServer{response: "Hi"}
+	client := newContextClient(t, server, true)
+	ctx := context.Background()
+
+	_, err := client.Generate(ctx, "llama3", "User: hello", "system", nil)
+	require.NoError(t, err)
+	assert.Nil(t, server.last().Context)
+
+	_, err = client.Generate(ctx, "llama3", "User: helloHi\n\nUser: and now?", "system", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "User: and now?", server.last().Prompt, "only the new part of the conversation is sent")
+	assert.Len(t, server.last().Context, len("User: hello")+len("Hi"))
+
+	_, err = client.Generate(ctx, "llama3", "User: helloHi\n\nUser: and now?\n\nAssistant: [Called tool: read_file]\n\nTool (read_file): ok", "system", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Assistant: [Called tool: read_file]\n\nTool (read_file): ok", server.last().Prompt)
+	assert.Len(t, server.last().Context, len("User: hello")+len("Hi")+len("User: and now?"), "the tokens of a response recorded differently are dropped")
+
+	_, err = client.Generate(ctx, "llama3", "User: something else", "system", nil)
+	require.NoError(t, err)
+	assert.Nil(t, server.last().Context, "a prompt not continuing the conversation is sent whole")
+
+	_, err = client.Generate(ctx, "llama3", "User: something elseHi more", "another system", nil)
+	require.NoError(t, err)
+	assert.Nil(t, server.last().Context, "contexts are kept per system prompt")
+
+	updates := make(chan string, 10)
+	require.NoError(t, client.Stream(ctx, "llama3", "User: something elseHi\n\nUser: stream", "system", nil, updates))
+	assert.Equal(t, "User: stream", server.last().Prompt)
+	_, err = client.Generate(ctx, "llama3", "User: something elseHi\n\nUser: streamHi!", "system", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "!", server.last().Prompt, "streamed responses are remembered")
+}
+
+func TestOllamaClient_ContextReuseRejected(t *testing.T) {
+	server := &contextServer{response: "Hi", reject: true}
+	client := newContextClient(t, server, true)
+	ctx := context.Background()
+
+	_, err := client.Generate(ctx, "llama3", "User: hello", "", nil)
+	require.NoError(t, err)
+	answer, err := client.Generate(ctx, "llama3", "User: helloHi more", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Hi", answer)
+	assert.Equal(t, "User: helloHi more", server.last().Prompt, "a rejected context is retried with the whole prompt")
+	assert.Nil(t, server.last().Context)
+}
+
+func TestOllamaClient_ContextReuseDisabled(t *testing.T) {
+	server := &contextServer{response: "Hi"}
+	client := newContextClient(t, server, false)
+	ctx := context.Background()
+
+	_, err := client.Generate(ctx, "llama3", "User: hello", "", nil)
+	require.NoError(t, err)
+	_, err = client.Generate(ctx, "llama3", "User: helloHi more", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "User: helloHi more", server.last().Prompt)
+	assert.Nil(t, server.last().Context)
+
+	tools := []ToolDefinition{{Type: "function", Function: ToolFunctionDefinition{Name: "read_file", Description: "Reads a file", Parameters: json.RawMessage(`{}`)}}}
+	_, err = client.GenerateWithFunctions(ctx, "llama3", "User: hi", "system", tools)
+	require.NoError(t, err)
+	assert.Contains(t, server.last().Prompt, "Available tools", "tools are described after the prompt")
+
+	client = newContextClient(t, server, true)
+	_, err = client.GenerateWithFunctions(ctx, "llama3", "User: hi", "system", tools)
+	require.NoError(t, err)
+	assert.Equal(t, "User: hi", server.last().Prompt)
+	assert.Contains(t, server.last().System, "Available tools", "with context reuse, tools are described in the system prompt")
+}
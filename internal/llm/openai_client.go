@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/castrovroberto/CGE/internal/config"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/logger"
 )
 
 // OpenAIClient implements the Client interface for OpenAI API
@@ -60,6 +62,8 @@ type OpenAIRequest struct {
 	Temperature float64         `json:"temperature,omitempty"`
 	MaxTokens   int             `json:"max_tokens,omitempty"`
 	Stream      bool            `json:"stream,omitempty"`
+	// StreamOptions is set on streamed requests so their usage is reported
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
 }
 
 type OpenAIResponse struct {
@@ -72,11 +76,28 @@ type OpenAIResponse struct {
 		Message      OpenAIMessage `json:"message"`
 		FinishReason string        `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage openAIUsage `json:"usage"`
+}
+
+// openAIUsage is the usage block of a chat completion
+type openAIUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"` // Of the prompt prefix OpenAI had cached
+	} `json:"prompt_tokens_details"`
+}
+
+// tokenUsage converts the usage block to a TokenUsage
+func (u openAIUsage) tokenUsage() TokenUsage {
+	return TokenUsage{InputTokens: u.PromptTokens, CachedInputTokens: u.PromptTokensDetails.CachedTokens, OutputTokens: u.CompletionTokens}
+}
+
+// openAIStreamOptions asks for the usage of a streamed completion in a last
+// chunk
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type OpenAIErrorResponse struct {
@@ -140,25 +161,11 @@ func (oc *OpenAIClient) GenerateWithFunctions(ctx context.Context, modelName, pr
 		Messages: messages,
 	}
 
-	if len(tools) > 0 {
-		openaiTools := make([]OpenAITool, len(tools))
-		for i, tool := range tools {
-			var functionDef map[string]interface{}
-			if err := json.Unmarshal(tool.Function.Parameters, &functionDef); err != nil {
-				return nil, fmt.Errorf("failed to parse tool parameters: %w", err)
-			}
-
-			openaiTools[i] = OpenAITool{
-				Type: "function",
-				Function: map[string]interface{}{
-					"name":        tool.Function.Name,
-					"description": tool.Function.Description,
-					"parameters":  functionDef,
-				},
-			}
-		}
-		request.Tools = openaiTools
+	openaiTools, err := toOpenAITools(tools)
+	if err != nil {
+		return nil, err
 	}
+	request.Tools = openaiTools
 
 	response, err := oc.makeRequest(ctx, request)
 	if err != nil {
@@ -192,6 +199,65 @@ func (oc *OpenAIClient) GenerateWithFunctions(ctx context.Context, modelName, pr
 	}, nil
 }
 
+// toOpenAITools converts tool definitions to OpenAI's format
+func toOpenAITools(tools []ToolDefinition) ([]OpenAITool, error) {
+	if len(tools) == 0 {
+		return nil, nil
+	}
+	openaiTools := make([]OpenAITool, len(tools))
+	for i, tool := range tools {
+		var functionDef map[string]interface{}
+		if err := json.Unmarshal(tool.Function.Parameters, &functionDef); err != nil {
+			return nil, fmt.Errorf("failed to parse tool parameters: %w", err)
+		}
+
+		openaiTools[i] = OpenAITool{
+			Type: "function",
+			Function: map[string]interface{}{
+				"name":        tool.Function.Name,
+				"description": tool.Function.Description,
+				"parameters":  functionDef,
+			},
+		}
+	}
+	return openaiTools, nil
+}
+
+// StreamWithFunctions implements FunctionCallStreamer. OpenAI streams a tool
+// call as fragments: the first carries the call's ID and name, the following
+// ones pieces of its arguments JSON.
+func (oc *OpenAIClient) StreamWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition, onDelta func(FunctionCallDelta)) (*FunctionCallResponse, error) {
+	messages := []OpenAIMessage{
+		{Role: "user", Content: prompt},
+	}
+
+	if systemPrompt != "" {
+		messages = append([]OpenAIMessage{{Role: "system", Content: systemPrompt}}, messages...)
+	}
+
+	request := OpenAIRequest{
+		Model:    modelName,
+		Messages: messages,
+		Stream:   true,
+	}
+
+	openaiTools, err := toOpenAITools(tools)
+	if err != nil {
+		return nil, err
+	}
+	request.Tools = openaiTools
+
+	stream := newFunctionCallStream(onDelta)
+	err = oc.streamChatCompletion(ctx, request, func(delta openAIStreamDelta) error {
+		stream.add(delta)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream.response(), nil
+}
+
 // Stream performs a streaming generation request to OpenAI
 func (oc *OpenAIClient) Stream(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}, out chan<- string) error {
 	defer close(out)
@@ -234,8 +300,7 @@ func (oc *OpenAIClient) ListAvailableModels(ctx context.Context) ([]string, erro
 	req.Header.Set("Authorization", "Bearer "+oc.config.APIKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: oc.config.RequestTimeout}
-	resp, err := client.Do(req)
+	resp, err := doWithTimeout(httpClientOrDefault(oc.config.HTTPClient), req, oc.config.RequestTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("openai: request failed: %w", err)
 	}
@@ -268,14 +333,46 @@ func (oc *OpenAIClient) SupportsNativeFunctionCalling() bool {
 	return true
 }
 
+// OpenAIEmbeddingModel is the model OpenAI embeddings come from
+const OpenAIEmbeddingModel = "text-embedding-ada-002"
+
+// EmbeddingModel implements EmbeddingModeler
+func (oc *OpenAIClient) EmbeddingModel() string {
+	return OpenAIEmbeddingModel
+}
+
 // Embed generates embeddings for the given text using OpenAI's embedding models
 func (oc *OpenAIClient) Embed(ctx context.Context, text string) ([]float32, error) {
-	// Default embedding model - could be made configurable
-	embeddingModel := "text-embedding-ada-002"
+	embeddings, err := oc.embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for several texts in a single request.
+// The returned slice is in the same order as texts.
+func (oc *OpenAIClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	embeddings, err := oc.embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("openai embed: expected %d embeddings, got %d", len(texts), len(embeddings))
+	}
+	return embeddings, nil
+}
+
+// embed calls the embeddings endpoint; input is either a string or a []string
+func (oc *OpenAIClient) embed(ctx context.Context, input interface{}) ([][]float32, error) {
+	embeddingModel := oc.EmbeddingModel()
 
 	requestPayload := map[string]interface{}{
 		"model": embeddingModel,
-		"input": text,
+		"input": input,
 	}
 
 	requestBody, err := json.Marshal(requestPayload)
@@ -291,8 +388,7 @@ func (oc *OpenAIClient) Embed(ctx context.Context, text string) ([]float32, erro
 	req.Header.Set("Authorization", "Bearer "+oc.config.APIKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: oc.config.RequestTimeout}
-	resp, err := client.Do(req)
+	resp, err := doWithTimeout(httpClientOrDefault(oc.config.HTTPClient), req, oc.config.RequestTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("openai embed: request failed: %w", err)
 	}
@@ -314,6 +410,7 @@ func (oc *OpenAIClient) Embed(ctx context.Context, text string) ([]float32, erro
 	// Parse the embedding response
 	var embeddingResponse struct {
 		Data []struct {
+			Index     int       `json:"index"`
 			Embedding []float64 `json:"embedding"`
 		} `json:"data"`
 	}
@@ -326,13 +423,20 @@ func (oc *OpenAIClient) Embed(ctx context.Context, text string) ([]float32, erro
 		return nil, fmt.Errorf("openai embed: no embedding data in response")
 	}
 
-	// Convert []float64 to []float32
-	embedding := make([]float32, len(embeddingResponse.Data[0].Embedding))
-	for i, v := range embeddingResponse.Data[0].Embedding {
-		embedding[i] = float32(v)
+	// Convert []float64 to []float32, placing each embedding at its input index
+	embeddings := make([][]float32, len(embeddingResponse.Data))
+	for _, data := range embeddingResponse.Data {
+		if data.Index < 0 || data.Index >= len(embeddings) {
+			return nil, fmt.Errorf("openai embed: embedding index %d out of range", data.Index)
+		}
+		embedding := make([]float32, len(data.Embedding))
+		for i, v := range data.Embedding {
+			embedding[i] = float32(v)
+		}
+		embeddings[data.Index] = embedding
 	}
 
-	return embedding, nil
+	return embeddings, nil
 }
 
 // SupportsEmbeddings returns true as OpenAI supports embedding models
@@ -342,7 +446,7 @@ func (oc *OpenAIClient) SupportsEmbeddings() bool {
 
 // GenerateThought performs deliberation step for internal reasoning using OpenAI
 func (oc *OpenAIClient) GenerateThought(ctx context.Context, modelName, prompt, context string) (*ThoughtResponse, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
 
 	// Create a structured prompt for thought generation
 	thoughtPrompt := fmt.Sprintf(`
@@ -434,7 +538,7 @@ Be thorough in your analysis and provide a confidence score between 0.0 and 1.0.
 
 // AssessConfidence evaluates confidence in a proposed action using OpenAI
 func (oc *OpenAIClient) AssessConfidence(ctx context.Context, modelName, thought, proposedAction string) (*ConfidenceAssessment, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
 
 	confidencePrompt := fmt.Sprintf(`
 You are an expert evaluator assessing the confidence and risks of proposed actions.
@@ -535,7 +639,7 @@ func (oc *OpenAIClient) SupportsDeliberation() bool {
 
 // makeRequest makes a non-streaming request to OpenAI API
 func (oc *OpenAIClient) makeRequest(ctx context.Context, request OpenAIRequest) (*OpenAIResponse, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
 
 	requestBody, err := json.Marshal(request)
 	if err != nil {
@@ -552,8 +656,7 @@ func (oc *OpenAIClient) makeRequest(ctx context.Context, request OpenAIRequest)
 
 	log.Debug("Sending OpenAI request", "model", request.Model)
 
-	client := &http.Client{Timeout: oc.config.RequestTimeout}
-	resp, err := client.Do(req)
+	resp, err := doWithTimeout(httpClientOrDefault(oc.config.HTTPClient), req, oc.config.RequestTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("openai: request failed: %w", err)
 	}
@@ -577,14 +680,52 @@ func (oc *OpenAIClient) makeRequest(ctx context.Context, request OpenAIRequest)
 		return nil, fmt.Errorf("openai: failed to parse response: %w", err)
 	}
 
-	log.Debug("OpenAI request successful", "model", openaiResp.Model)
+	log.Debug("OpenAI request successful", "model", openaiResp.Model, "prompt_tokens", openaiResp.Usage.PromptTokens, "cached_tokens", openaiResp.Usage.PromptTokensDetails.CachedTokens)
+	reportUsage(ctx, openaiResp.Usage.tokenUsage())
 	return &openaiResp, nil
 }
 
-// makeStreamRequest makes a streaming request to OpenAI API
+// makeStreamRequest makes a streaming request to OpenAI API, forwarding
+// content deltas to out
 func (oc *OpenAIClient) makeStreamRequest(ctx context.Context, request OpenAIRequest, out chan<- string) error {
-	log := contextkeys.LoggerFromContext(ctx)
+	return oc.streamChatCompletion(ctx, request, func(delta openAIStreamDelta) error {
+		if delta.Content == "" {
+			return nil
+		}
+		select {
+		case out <- delta.Content:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
 
+// openAIStreamDelta is the delta of the first choice in a streamed chat
+// completion chunk
+type openAIStreamDelta struct {
+	Content   string                `json:"content"`
+	ToolCalls []openAIToolCallDelta `json:"tool_calls"`
+}
+
+// openAIToolCallDelta is a fragment of a streamed tool call. Fragments of the
+// same call share its Index; only the first carries the ID and name.
+type openAIToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// streamChatCompletion sends a streaming request and calls onDelta with the
+// delta of each chunk. An error from onDelta stops the stream.
+func (oc *OpenAIClient) streamChatCompletion(ctx context.Context, request OpenAIRequest, onDelta func(openAIStreamDelta) error) error {
+	log := contextkeys.LoggerFor(ctx, logger.LLM)
+
+	request.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
 	requestBody, err := json.Marshal(request)
 	if err != nil {
 		return fmt.Errorf("openai: failed to marshal request: %w", err)
@@ -600,8 +741,7 @@ func (oc *OpenAIClient) makeStreamRequest(ctx context.Context, request OpenAIReq
 
 	log.Debug("Sending OpenAI streaming request", "model", request.Model)
 
-	client := &http.Client{Timeout: oc.config.RequestTimeout}
-	resp, err := client.Do(req)
+	resp, err := doWithTimeout(httpClientOrDefault(oc.config.HTTPClient), req, oc.config.RequestTimeout)
 	if err != nil {
 		return fmt.Errorf("openai: request failed: %w", err)
 	}
@@ -616,104 +756,114 @@ func (oc *OpenAIClient) makeStreamRequest(ctx context.Context, request OpenAIReq
 		return fmt.Errorf("openai: API returned status %d", resp.StatusCode)
 	}
 
-	// Parse Server-Sent Events
-	scanner := NewSSEScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
+	events := NewSSEReader(resp.Body)
+	for {
+		event, err := events.Next()
+		if errors.Is(err, io.EOF) {
+			break
 		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
+		if err != nil {
+			return fmt.Errorf("openai: stream error: %w", err)
+		}
+		if event.Data == "[DONE]" {
 			break
 		}
 
 		var chunk struct {
 			Choices []struct {
-				Delta struct {
-					Content string `json:"content"`
-				} `json:"delta"`
+				Delta openAIStreamDelta `json:"delta"`
 			} `json:"choices"`
+			Usage *openAIUsage `json:"usage"` // In the last chunk, without choices
 		}
 
-		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
 			continue // Skip malformed chunks
 		}
+		if chunk.Usage != nil {
+			log.Debug("OpenAI stream usage", "prompt_tokens", chunk.Usage.PromptTokens, "cached_tokens", chunk.Usage.PromptTokensDetails.CachedTokens)
+			reportUsage(ctx, chunk.Usage.tokenUsage())
+		}
 
-		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-			select {
-			case out <- chunk.Choices[0].Delta.Content:
-			case <-ctx.Done():
-				return ctx.Err()
+		if len(chunk.Choices) > 0 {
+			if err := onDelta(chunk.Choices[0].Delta); err != nil {
+				return err
 			}
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("openai: stream error: %w", err)
-	}
-
 	log.Debug("OpenAI stream completed successfully")
 	return nil
 }
 
-// SSEScanner is a simple scanner for Server-Sent Events
-type SSEScanner struct {
-	reader io.Reader
-	buffer []byte
-	pos    int
-	err    error
+// functionCallStream assembles a function-calling response from stream
+// deltas, reporting each one as it arrives
+type functionCallStream struct {
+	onDelta func(FunctionCallDelta)
+	text    strings.Builder
+	calls   []*OpenAIToolCall       // In the order they started
+	byIndex map[int]*OpenAIToolCall // Calls by stream index
 }
 
-func NewSSEScanner(r io.Reader) *SSEScanner {
-	return &SSEScanner{reader: r}
+func newFunctionCallStream(onDelta func(FunctionCallDelta)) *functionCallStream {
+	return &functionCallStream{onDelta: onDelta, byIndex: make(map[int]*OpenAIToolCall)}
 }
 
-func (s *SSEScanner) Scan() bool {
-	if s.err != nil {
-		return false
+// add applies the delta of one chunk
+func (s *functionCallStream) add(delta openAIStreamDelta) {
+	if delta.Content != "" {
+		s.text.WriteString(delta.Content)
+		s.report(FunctionCallDelta{Content: delta.Content})
 	}
 
-	// Read more data if needed
-	if s.pos >= len(s.buffer) {
-		buf := make([]byte, 4096)
-		n, err := s.reader.Read(buf)
-		if err != nil {
-			s.err = err
-			return false
+	for _, fragment := range delta.ToolCalls {
+		call, ok := s.byIndex[fragment.Index]
+		if !ok {
+			call = &OpenAIToolCall{Type: "function"}
+			s.byIndex[fragment.Index] = call
+			s.calls = append(s.calls, call)
 		}
-		s.buffer = buf[:n]
-		s.pos = 0
-	}
+		if fragment.ID != "" {
+			call.ID = fragment.ID
+		}
+		call.Function.Name += fragment.Function.Name
+		call.Function.Arguments += fragment.Function.Arguments
 
-	// Find next line
-	for s.pos < len(s.buffer) && s.buffer[s.pos] != '\n' {
-		s.pos++
+		s.report(FunctionCallDelta{
+			CallID:         call.ID,
+			FunctionName:   call.Function.Name,
+			Arguments:      call.Function.Arguments,
+			ArgumentsDelta: fragment.Function.Arguments,
+		})
 	}
+}
 
-	if s.pos < len(s.buffer) {
-		s.pos++ // Skip the newline
-		return true
+func (s *functionCallStream) report(delta FunctionCallDelta) {
+	if s.onDelta != nil {
+		s.onDelta(delta)
 	}
-
-	return false
 }
 
-func (s *SSEScanner) Text() string {
-	if s.pos > 0 {
-		end := s.pos - 1
-		if end > 0 && s.buffer[end-1] == '\r' {
-			end--
+// response returns the assembled response. Like GenerateWithFunctions, only
+// the first tool call is returned.
+func (s *functionCallStream) response() *FunctionCallResponse {
+	if len(s.calls) == 0 {
+		return &FunctionCallResponse{
+			IsTextResponse: true,
+			TextContent:    s.text.String(),
 		}
-		return string(s.buffer[:end])
 	}
-	return ""
-}
 
-func (s *SSEScanner) Err() error {
-	if s.err == io.EOF {
-		return nil
+	call := s.calls[0]
+	arguments := call.Function.Arguments
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	return &FunctionCallResponse{
+		IsTextResponse: false,
+		FunctionCall: &FunctionCall{
+			Name:      call.Function.Name,
+			Arguments: json.RawMessage(arguments),
+			ID:        call.ID,
+		},
 	}
-	return s.err
 }
@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamDeltas decodes the delta of each chunk in a recorded OpenAI stream
+func streamDeltas(t *testing.T, chunks ...string) []openAIStreamDelta {
+	t.Helper()
+	deltas := make([]openAIStreamDelta, len(chunks))
+	for i, chunk := range chunks {
+		var parsed struct {
+			Choices []struct {
+				Delta openAIStreamDelta `json:"delta"`
+			} `json:"choices"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(chunk), &parsed))
+		deltas[i] = parsed.Choices[0].Delta
+	}
+	return deltas
+}
+
+func TestFunctionCallStream_ToolCall(t *testing.T) {
+	deltas := streamDeltas(t,
+		`{"choices":[{"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":""}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"pa"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"th\":\"main.go\"}"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"list_dir","arguments":"{}"}}]}}]}`,
+	)
+
+	var reported []FunctionCallDelta
+	stream := newFunctionCallStream(func(d FunctionCallDelta) { reported = append(reported, d) })
+	for _, delta := range deltas {
+		stream.add(delta)
+	}
+
+	response := stream.response()
+	require.False(t, response.IsTextResponse)
+	assert.Equal(t, "read_file", response.FunctionCall.Name)
+	assert.Equal(t, "call_1", response.FunctionCall.ID)
+	assert.JSONEq(t, `{"path":"main.go"}`, string(response.FunctionCall.Arguments))
+
+	require.Len(t, reported, 4)
+	assert.Equal(t, `{"pa`, reported[1].Arguments)
+	assert.Equal(t, `th":"main.go"}`, reported[2].ArgumentsDelta)
+	assert.Equal(t, "call_1", reported[2].CallID)
+	assert.Equal(t, "list_dir", reported[3].FunctionName)
+}
+
+func TestFunctionCallStream_Text(t *testing.T) {
+	deltas := streamDeltas(t,
+		`{"choices":[{"delta":{"role":"assistant","content":"Hello"}}]}`,
+		`{"choices":[{"delta":{"content":", world"}}]}`,
+	)
+
+	var content string
+	stream := newFunctionCallStream(func(d FunctionCallDelta) { content += d.Content })
+	for _, delta := range deltas {
+		stream.add(delta)
+	}
+
+	response := stream.response()
+	assert.True(t, response.IsTextResponse)
+	assert.Equal(t, "Hello, world", response.TextContent)
+	assert.Equal(t, "Hello, world", content)
+}
+
+func TestFunctionCallStream_EmptyArguments(t *testing.T) {
+	stream := newFunctionCallStream(nil)
+	stream.add(streamDeltas(t, `{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"git_status"}}]}}]}`)[0])
+	assert.Equal(t, "{}", string(stream.response().FunctionCall.Arguments))
+}
+
+func TestOpenAIClient_StreamWithFunctions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, chunk := range []string{
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"main.go\"}"}}]}}]}`,
+			`[DONE]`,
+		} {
+			// Split each event across writes, as proxies may
+			event := fmt.Sprintf("data: %s\r\n\r\n", chunk)
+			half := len(event) / 2
+			fmt.Fprint(w, event[:half])
+			flusher.Flush()
+			fmt.Fprint(w, event[half:])
+			flusher.Flush()
+		}
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(config.OpenAIConfig{BaseURL: server.URL, RequestTimeout: 5 * time.Second})
+	var names []string
+	response, err := client.StreamWithFunctions(context.Background(), "gpt-4o", "read main.go", "", nil, func(d FunctionCallDelta) {
+		names = append(names, d.FunctionName)
+	})
+	require.NoError(t, err)
+	require.NotNil(t, response.FunctionCall)
+	assert.Equal(t, "read_file", response.FunctionCall.Name)
+	assert.JSONEq(t, `{"path":"main.go"}`, string(response.FunctionCall.Arguments))
+	assert.Equal(t, []string{"read_file", "read_file", "read_file"}, names)
+}
+
+func TestDoWithTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "partial")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done() // Never finish the body
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := doWithTimeout(httpClientOrDefault(nil), req, 50*time.Millisecond)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// The timeout also bounds reading the body
+	_, err = io.ReadAll(resp.Body)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestOpenAIClient_ReportsCachedTokens(t *testing.T) {
+	var streamOptions []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		streamOptions = append(streamOptions, request["stream_options"])
+		usage := `{"prompt_tokens":2000,"completion_tokens":50,"total_tokens":2050,"prompt_tokens_details":{"cached_tokens":1536}}`
+		if request["stream"] == true {
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Done\"}}]}\n\n")
+			fmt.Fprintf(w, "data: {\"choices\":[],\"usage\":%s}\n\n", usage)
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"Done"}}],"usage":%s}`, usage)
+	}))
+	defer server.Close()
+
+	meter := &UsageMeter{}
+	client := WithUsageMeter(NewOpenAIClient(config.OpenAIConfig{BaseURL: server.URL, RequestTimeout: 5 * time.Second}), meter)
+	_, err := client.Generate(context.Background(), "gpt-4o", "prompt", "system", nil)
+	require.NoError(t, err)
+	_, err = client.(FunctionCallStreamer).StreamWithFunctions(context.Background(), "gpt-4o", "prompt", "system", nil, func(FunctionCallDelta) {})
+	require.NoError(t, err)
+
+	assert.Equal(t, UsageSnapshot{Requests: 2, Reported: 2, InputTokens: 4000, CachedInputTokens: 3072, OutputTokens: 100}, meter.Snapshot())
+	assert.Equal(t, []interface{}{nil, map[string]interface{}{"include_usage": true}}, streamOptions, "streams ask for their usage")
+	pricing := modelPricing["gpt-4o"]
+	assert.InDelta(t, (928*2.50+3072*1.25+100*10.00)/1_000_000, meter.Cost(pricing), 1e-12, "cached tokens are billed at their discounted price")
+}
@@ -0,0 +1,64 @@
+package llm
+
+import "strings"
+
+// ModelPricing is the list price of a model in USD per million tokens
+type ModelPricing struct {
+	InputPerMillion       float64
+	CachedInputPerMillion float64 // Input read from the provider's prompt cache; zero when not discounted
+	OutputPerMillion      float64
+}
+
+// Cost returns the USD cost of a request with the given token counts
+func (p ModelPricing) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.InputPerMillion + float64(outputTokens)*p.OutputPerMillion) / 1_000_000
+}
+
+// CostWithCache returns the USD cost of a request of which cachedTokens of
+// the inputTokens were read from the prompt cache
+func (p ModelPricing) CostWithCache(inputTokens, cachedTokens, outputTokens int) float64 {
+	if p.CachedInputPerMillion == 0 || cachedTokens <= 0 {
+		return p.Cost(inputTokens, outputTokens)
+	}
+	return p.Cost(inputTokens-cachedTokens, outputTokens) + float64(cachedTokens)*p.CachedInputPerMillion/1_000_000
+}
+
+// modelPricing maps model name prefixes to their pricing. Longer prefixes are
+// matched first so "gpt-4o-mini" wins over "gpt-4o" and "gpt-4".
+var modelPricing = map[string]ModelPricing{
+	"gpt-4o-mini":      {InputPerMillion: 0.15, CachedInputPerMillion: 0.075, OutputPerMillion: 0.60},
+	"gpt-4o":           {InputPerMillion: 2.50, CachedInputPerMillion: 1.25, OutputPerMillion: 10.00},
+	"gpt-4.1-nano":     {InputPerMillion: 0.10, CachedInputPerMillion: 0.025, OutputPerMillion: 0.40},
+	"gpt-4.1-mini":     {InputPerMillion: 0.40, CachedInputPerMillion: 0.10, OutputPerMillion: 1.60},
+	"gpt-4.1":          {InputPerMillion: 2.00, CachedInputPerMillion: 0.50, OutputPerMillion: 8.00},
+	"gpt-4-turbo":      {InputPerMillion: 10.00, OutputPerMillion: 30.00},
+	"gpt-4":            {InputPerMillion: 30.00, OutputPerMillion: 60.00},
+	"gpt-3.5-turbo":    {InputPerMillion: 0.50, OutputPerMillion: 1.50},
+	"o1-mini":          {InputPerMillion: 1.10, CachedInputPerMillion: 0.55, OutputPerMillion: 4.40},
+	"o1":               {InputPerMillion: 15.00, CachedInputPerMillion: 7.50, OutputPerMillion: 60.00},
+	"o3-mini":          {InputPerMillion: 1.10, CachedInputPerMillion: 0.55, OutputPerMillion: 4.40},
+	"gemini-1.5-flash": {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gemini-1.5-pro":   {InputPerMillion: 1.25, OutputPerMillion: 5.00},
+	"gemini-2.0-flash": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+}
+
+// LookupPricing returns the pricing for a model. Local providers (Ollama) are
+// free; ok is false when the price of a hosted model is unknown.
+func LookupPricing(provider, model string) (pricing ModelPricing, ok bool) {
+	if strings.EqualFold(provider, "ollama") {
+		return ModelPricing{}, true
+	}
+
+	name := strings.ToLower(model)
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:] // "models/gemini-1.5-pro" -> "gemini-1.5-pro"
+	}
+
+	best := ""
+	for prefix, p := range modelPricing {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(best) {
+			best, pricing = prefix, p
+		}
+	}
+	return pricing, best != ""
+}
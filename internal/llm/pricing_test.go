@@ -0,0 +1,40 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupPricing(t *testing.T) {
+	tests := []struct {
+		provider string
+		model    string
+		want     ModelPricing
+		known    bool
+	}{
+		{"openai", "gpt-4o-mini-2024-07-18", modelPricing["gpt-4o-mini"], true},
+		{"openai", "gpt-4o", modelPricing["gpt-4o"], true},
+		{"openai", "GPT-4-0613", modelPricing["gpt-4"], true},
+		{"gemini", "models/gemini-1.5-pro-latest", modelPricing["gemini-1.5-pro"], true},
+		{"ollama", "llama3:8b", ModelPricing{}, true},
+		{"openai", "my-finetune", ModelPricing{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, known := LookupPricing(tt.provider, tt.model)
+			assert.Equal(t, tt.known, known)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestModelPricingCost(t *testing.T) {
+	pricing := ModelPricing{InputPerMillion: 2.50, OutputPerMillion: 10.00}
+	assert.InDelta(t, 0.0035, pricing.Cost(1000, 100), 1e-9)
+	assert.InDelta(t, 0.0035, pricing.CostWithCache(1000, 800, 100), 1e-9, "without a cached price, cached tokens cost as much")
+
+	pricing.CachedInputPerMillion = 1.25
+	assert.InDelta(t, 0.0025, pricing.CostWithCache(1000, 800, 100), 1e-9)
+}
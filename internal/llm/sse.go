@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// maxSSELineSize bounds a single line of an event stream; streamed chunks
+// carrying large tool arguments can exceed bufio's 64 KiB default
+const maxSSELineSize = 4 << 20
+
+// SSEEvent is one Server-Sent Event
+type SSEEvent struct {
+	Event string // Event type; empty for the default "message" type
+	Data  string // Data lines joined with "\n"
+	ID    string // Last event ID, if the stream set one
+}
+
+// SSEReader parses a Server-Sent Events stream incrementally, following the
+// WHATWG event stream format: lines may end in CRLF, LF or CR, lines starting
+// with ":" are comments, and an event is dispatched at each blank line.
+// Events and lines may be split across reads in any way.
+type SSEReader struct {
+	scanner *bufio.Scanner
+	lastID  string
+}
+
+// NewSSEReader creates a reader parsing events from r
+func NewSSEReader(r io.Reader) *SSEReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+	scanner.Split(scanSSELines)
+	return &SSEReader{scanner: scanner}
+}
+
+// Next returns the next event. It returns io.EOF at the end of the stream;
+// an event not terminated by a blank line is discarded, as the format
+// requires.
+func (r *SSEReader) Next() (SSEEvent, error) {
+	var eventType string
+	var data strings.Builder
+	hasData := false
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+
+		if line == "" {
+			if !hasData {
+				eventType = "" // Nothing to dispatch
+				continue
+			}
+			return SSEEvent{Event: eventType, Data: data.String(), ID: r.lastID}, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // Comment, e.g. a keep-alive
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastID = value
+			}
+		default:
+			// "retry" and unknown fields are ignored
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return SSEEvent{}, err
+	}
+	return SSEEvent{}, io.EOF
+}
+
+// scanSSELines is a bufio.SplitFunc for lines ending in CRLF, LF or CR
+func scanSSELines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// A CR may be the first half of a CRLF split across reads
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvents reads every event from r
+func readEvents(t *testing.T, r io.Reader) []SSEEvent {
+	t.Helper()
+	var events []SSEEvent
+	reader := NewSSEReader(r)
+	for {
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+}
+
+// chunkedReader returns the stream in reads of the given sizes, then the rest
+type chunkedReader struct {
+	data  string
+	sizes []int
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, io.EOF
+	}
+	n := len(r.data)
+	if len(r.sizes) > 0 {
+		n, r.sizes = min(r.sizes[0], n), r.sizes[1:]
+	}
+	n = copy(p, r.data[:n])
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestSSEReader(t *testing.T) {
+	stream := ": keep-alive\r\n" +
+		"data: {\"a\":1}\r\n\r\n" +
+		"event: update\n" +
+		"id: 7\n" +
+		"data: first line\n" +
+		"data: second line\n" +
+		"retry: 1000\n\n" +
+		"data:no space\r\r" +
+		"\n\n" + // Blank lines without data dispatch nothing
+		"data: [DONE]\n\n"
+
+	want := []SSEEvent{
+		{Data: `{"a":1}`},
+		{Event: "update", Data: "first line\nsecond line", ID: "7"},
+		{Data: "no space", ID: "7"},
+		{Data: "[DONE]", ID: "7"},
+	}
+
+	t.Run("whole stream", func(t *testing.T) {
+		assert.Equal(t, want, readEvents(t, strings.NewReader(stream)))
+	})
+
+	t.Run("one byte at a time", func(t *testing.T) {
+		assert.Equal(t, want, readEvents(t, iotest.OneByteReader(strings.NewReader(stream))))
+	})
+
+	t.Run("split at every offset", func(t *testing.T) {
+		for i := 1; i < len(stream); i++ {
+			got := readEvents(t, &chunkedReader{data: stream, sizes: []int{i}})
+			require.Equal(t, want, got, "split at %d", i)
+		}
+	})
+}
+
+func TestSSEReader_DiscardsUnterminatedEvent(t *testing.T) {
+	events := readEvents(t, strings.NewReader("data: complete\n\ndata: cut off"))
+	assert.Equal(t, []SSEEvent{{Data: "complete"}}, events)
+}
+
+func TestSSEReader_EmptyData(t *testing.T) {
+	events := readEvents(t, strings.NewReader("data\n\ndata:\ndata:\n\n"))
+	assert.Equal(t, []SSEEvent{{Data: ""}, {Data: "\n"}}, events)
+}
+
+func TestSSEReader_LongLine(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+	events := readEvents(t, strings.NewReader("data: "+long+"\n\n"))
+	require.Len(t, events, 1)
+	assert.Equal(t, long, events[0].Data)
+}
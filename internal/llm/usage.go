@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/castrovroberto/CGE/internal/textutils"
+)
+
+// TokenUsage is the usage of a request as its provider reported it
+type TokenUsage struct {
+	InputTokens       int // Including the cached ones
+	CachedInputTokens int // Of the prompt prefix the provider had cached
+	OutputTokens      int
+}
+
+// usageReportKey is the context key of the usage report of a request
+type usageReportKey struct{}
+
+// usageReport receives the usage a client reports for a request
+type usageReport struct {
+	usage    TokenUsage
+	reported bool
+}
+
+// withUsageReport returns a context in which clients can report the usage of
+// the request made with it
+func withUsageReport(ctx context.Context) (context.Context, *usageReport) {
+	report := &usageReport{}
+	return context.WithValue(ctx, usageReportKey{}, report), report
+}
+
+// reportUsage records the usage the provider reported for the request made
+// with ctx, when it is metered
+func reportUsage(ctx context.Context, usage TokenUsage) {
+	if report, ok := ctx.Value(usageReportKey{}).(*usageReport); ok {
+		report.usage, report.reported = usage, true
+	}
+}
+
+// UsageMeter adds up the tokens of the generation requests made through the
+// clients metering into it. Tokens are those the provider reported, or
+// estimated from the text sent and received, as providers do not all report
+// them.
+type UsageMeter struct {
+	mu      sync.Mutex
+	current UsageSnapshot
+}
+
+// UsageSnapshot is the usage a UsageMeter has recorded
+type UsageSnapshot struct {
+	Requests          int
+	Reported          int // Requests whose usage the provider reported
+	InputTokens       int
+	CachedInputTokens int // Part of InputTokens
+	OutputTokens      int
+}
+
+// Sub returns the usage recorded since earlier
+func (s UsageSnapshot) Sub(earlier UsageSnapshot) UsageSnapshot {
+	return UsageSnapshot{
+		Requests:          s.Requests - earlier.Requests,
+		Reported:          s.Reported - earlier.Reported,
+		InputTokens:       s.InputTokens - earlier.InputTokens,
+		CachedInputTokens: s.CachedInputTokens - earlier.CachedInputTokens,
+		OutputTokens:      s.OutputTokens - earlier.OutputTokens,
+	}
+}
+
+// Cost returns the USD cost of the usage at pricing
+func (s UsageSnapshot) Cost(pricing ModelPricing) float64 {
+	return pricing.CostWithCache(s.InputTokens, s.CachedInputTokens, s.OutputTokens)
+}
+
+// Usage returns the number of requests and their tokens so far
+func (m *UsageMeter) Usage() (requests, inputTokens, outputTokens int) {
+	s := m.Snapshot()
+	return s.Requests, s.InputTokens, s.OutputTokens
+}
+
+// Snapshot returns the usage recorded so far
+func (m *UsageMeter) Snapshot() UsageSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+// Cost returns the USD cost of the requests so far at pricing, with cached
+// input tokens at their discounted price
+func (m *UsageMeter) Cost(pricing ModelPricing) float64 {
+	return m.Snapshot().Cost(pricing)
+}
+
+// record adds a request with the usage its provider reported, or else
+// estimated from the given prompt parts and response
+func (m *UsageMeter) record(report *usageReport, response string, prompt ...string) {
+	usage := report.usage
+	if !report.reported {
+		usage = TokenUsage{OutputTokens: textutils.EstimateTokenCount(response)}
+		for _, part := range prompt {
+			usage.InputTokens += textutils.EstimateTokenCount(part)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.current.Requests++
+	if report.reported {
+		m.current.Reported++
+	}
+	m.current.InputTokens += usage.InputTokens
+	m.current.CachedInputTokens += usage.CachedInputTokens
+	m.current.OutputTokens += usage.OutputTokens
+}
+
+// MeteredClient wraps a Client to record the usage of its generation
+// requests in a UsageMeter. Plain text streams are not metered.
+type MeteredClient struct {
+	Client
+	meter *UsageMeter
+}
+
+// WithUsageMeter wraps client so its generation requests are recorded in meter
+func WithUsageMeter(client Client, meter *UsageMeter) Client {
+	return &MeteredClient{Client: client, meter: meter}
+}
+
+// Generate implements Client
+func (c *MeteredClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	ctx, report := withUsageReport(ctx)
+	response, err := c.Client.Generate(ctx, modelName, prompt, systemPrompt, tools)
+	if err == nil {
+		c.meter.record(report, response, prompt, systemPrompt, encodeTools(tools))
+	}
+	return response, err
+}
+
+// GenerateWithFunctions implements Client
+func (c *MeteredClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition) (*FunctionCallResponse, error) {
+	ctx, report := withUsageReport(ctx)
+	response, err := c.Client.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+	if err == nil {
+		c.meter.record(report, functionCallText(response), prompt, systemPrompt, encodeTools(tools))
+	}
+	return response, err
+}
+
+// StreamWithFunctions implements FunctionCallStreamer, falling back to
+// GenerateWithFunctions when the wrapped client cannot stream
+func (c *MeteredClient) StreamWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []ToolDefinition, onDelta func(FunctionCallDelta)) (*FunctionCallResponse, error) {
+	streamer, ok := c.Client.(FunctionCallStreamer)
+	if !ok {
+		return c.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+	}
+	ctx, report := withUsageReport(ctx)
+	response, err := streamer.StreamWithFunctions(ctx, modelName, prompt, systemPrompt, tools, onDelta)
+	if err == nil {
+		c.meter.record(report, functionCallText(response), prompt, systemPrompt, encodeTools(tools))
+	}
+	return response, err
+}
+
+// EmbedBatch implements BatchEmbedder, embedding one text at a time when the
+// wrapped client cannot batch
+func (c *MeteredClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if batcher, ok := c.Client.(BatchEmbedder); ok {
+		return batcher.EmbedBatch(ctx, texts)
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding, err := c.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+// encodeTools returns the tool definitions as they are sent, for counting
+func encodeTools(tools interface{}) string {
+	data, err := json.Marshal(tools)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+	return string(data)
+}
+
+// functionCallText returns what the model generated for a response
+func functionCallText(response *FunctionCallResponse) string {
+	if response == nil {
+		return ""
+	}
+	if response.FunctionCall != nil {
+		return response.FunctionCall.Name + string(response.FunctionCall.Arguments)
+	}
+	return response.TextContent
+}
+
+// EmbeddingModel implements EmbeddingModeler
+func (c *MeteredClient) EmbeddingModel() string {
+	return EmbeddingModelOf(c.Client)
+}
+
+// SetPullProgress implements PullProgressSetter
+func (c *MeteredClient) SetPullProgress(progress func(PullProgress)) {
+	SetPullProgressOf(c.Client, progress)
+}
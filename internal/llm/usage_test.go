@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoClient answers Generate with the prompt
+type echoClient struct {
+	Client
+}
+
+func (c *echoClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	return prompt, nil
+}
+
+func TestUsageMeter(t *testing.T) {
+	meter := &UsageMeter{}
+	client := WithUsageMeter(&echoClient{}, meter)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Generate(context.Background(), "gpt-4o", "Summarize the changes to the checkout flow", "", nil)
+		require.NoError(t, err)
+	}
+	requests, input, output := meter.Usage()
+	assert.Equal(t, 2, requests)
+	assert.Positive(t, input)
+	assert.Equal(t, input, output, "the echoed response is as long as the prompt")
+
+	pricing := ModelPricing{InputPerMillion: 2.50, OutputPerMillion: 10.00}
+	assert.Equal(t, pricing.Cost(input, output), meter.Cost(pricing))
+
+	before := meter.Snapshot()
+	_, err := client.Generate(context.Background(), "gpt-4o", "Again", "", nil)
+	require.NoError(t, err)
+	since := meter.Snapshot().Sub(before)
+	assert.Equal(t, 1, since.Requests)
+	assert.Zero(t, since.Reported, "the echo client does not report its usage, so it is estimated")
+}
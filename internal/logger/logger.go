@@ -2,88 +2,200 @@
 package logger
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 )
 
+// Subsystems whose log level can be set apart from the global one
+const (
+	LLM          = "llm"
+	Orchestrator = "orchestrator"
+	Tools        = "tools"
+	TUI          = "tui"
+	Context      = "context"
+)
+
+// Subsystems lists the subsystems in the order they are shown
+var Subsystems = []string{LLM, Orchestrator, Tools, TUI, Context}
+
+// SubsystemKey is the attribute naming the subsystem of a log record
+const SubsystemKey = "subsystem"
+
 var globalLogger *slog.Logger
 
+// levels holds the global log level and those of the subsystems that have
+// their own
+var levels = struct {
+	sync.RWMutex
+	global     slog.Level
+	subsystems map[string]slog.Level
+}{global: slog.LevelInfo, subsystems: map[string]slog.Level{}}
+
 func init() {
 	// Initialize with a default logger until InitLogger is called.
 	// This ensures that Get() always returns a valid logger.
-	globalLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	globalLogger = slog.New(newHandler(os.Stderr))
 }
 
 // InitLogger initializes the global logger with the specified log level.
 func InitLogger(levelStr string) {
-	var level slog.Level
-	switch strings.ToLower(levelStr) {
-	case "debug":
-		level = slog.LevelDebug
-	case "info":
-		level = slog.LevelInfo
-	case "warn", "warning":
-		level = slog.LevelWarn
-	case "error", "err":
-		level = slog.LevelError
-	default:
-		level = slog.LevelInfo // Default to info for unknown levels
-	}
-
-	globalLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	setGlobalLevel(levelStr)
+	globalLogger = slog.New(newHandler(os.Stderr))
 }
 
-// InitLoggerForTUI initializes the logger to write to a file instead of stderr to avoid TUI interference
-func InitLoggerForTUI(levelStr string, logFile string) error {
-	var level slog.Level
-	switch strings.ToLower(levelStr) {
-	case "debug":
-		level = slog.LevelDebug
-	case "info":
-		level = slog.LevelInfo
-	case "warn", "warning":
-		level = slog.LevelWarn
-	case "error", "err":
-		level = slog.LevelError
-	default:
-		level = slog.LevelInfo // Default to info for unknown levels
-	}
+// InitLoggerForTUI initializes the logger to write to a file instead of stderr to avoid TUI interference.
+// The file is rotated once it grows past the size of rotation.
+func InitLoggerForTUI(levelStr string, logFile string, rotation Rotation) error {
+	setGlobalLevel(levelStr)
 
 	// Create or open log file
-	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err := os.MkdirAll(filepath.Dir(logFile), 0750); err != nil {
+		return err
+	}
+	file, err := openRotatingFile(logFile, rotation)
 	if err != nil {
 		return err
 	}
 
-	// Create a multi-writer that writes to both file and discard (no stderr during TUI)
-	writer := io.MultiWriter(file, io.Discard)
-	globalLogger = slog.New(slog.NewTextHandler(writer, &slog.HandlerOptions{Level: level}))
-
+	globalLogger = slog.New(newHandler(file))
 	return nil
 }
 
 // InitLoggerWithWriter initializes the logger with a custom writer
 func InitLoggerWithWriter(levelStr string, writer io.Writer) {
-	var level slog.Level
-	switch strings.ToLower(levelStr) {
+	setGlobalLevel(levelStr)
+	globalLogger = slog.New(newHandler(writer))
+}
+
+// Get returns the initialized global logger.
+func Get() *slog.Logger {
+	return globalLogger
+}
+
+// For returns the global logger for a subsystem, which logs at the level of
+// the subsystem
+func For(subsystem string) *slog.Logger {
+	return globalLogger.With(SubsystemKey, subsystem)
+}
+
+// ParseLevel returns the level named by s: debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
 	case "debug":
-		level = slog.LevelDebug
+		return slog.LevelDebug, nil
 	case "info":
-		level = slog.LevelInfo
+		return slog.LevelInfo, nil
 	case "warn", "warning":
-		level = slog.LevelWarn
+		return slog.LevelWarn, nil
 	case "error", "err":
-		level = slog.LevelError
-	default:
-		level = slog.LevelInfo // Default to info for unknown levels
+		return slog.LevelError, nil
 	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q; use debug, info, warn or error", s)
+}
 
-	globalLogger = slog.New(slog.NewTextHandler(writer, &slog.HandlerOptions{Level: level}))
+// setGlobalLevel sets the level of the subsystems without their own, info
+// for unknown levels
+func setGlobalLevel(levelStr string) {
+	level, _ := ParseLevel(levelStr)
+	levels.Lock()
+	levels.global = level
+	levels.Unlock()
 }
 
-// Get returns the initialized global logger.
-func Get() *slog.Logger {
-	return globalLogger
+// SetLevel sets the level of a subsystem; "default" makes it follow the
+// global level again
+func SetLevel(subsystem, levelStr string) error {
+	if !isSubsystem(subsystem) {
+		return fmt.Errorf("unknown subsystem %q; use %s", subsystem, strings.Join(Subsystems, ", "))
+	}
+	levels.Lock()
+	defer levels.Unlock()
+	if strings.EqualFold(levelStr, "default") {
+		delete(levels.subsystems, subsystem)
+		return nil
+	}
+	level, err := ParseLevel(levelStr)
+	if err != nil {
+		return err
+	}
+	levels.subsystems[subsystem] = level
+	return nil
+}
+
+// SetLevels sets the level of each subsystem in subsystemLevels, stopping at
+// the first unknown subsystem or level
+func SetLevels(subsystemLevels map[string]string) error {
+	names := make([]string, 0, len(subsystemLevels))
+	for name := range subsystemLevels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := SetLevel(name, subsystemLevels[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Level returns the level a subsystem logs at, and whether it is its own
+// rather than the global one
+func Level(subsystem string) (slog.Level, bool) {
+	levels.RLock()
+	defer levels.RUnlock()
+	if level, ok := levels.subsystems[subsystem]; ok {
+		return level, true
+	}
+	return levels.global, false
+}
+
+func isSubsystem(name string) bool {
+	for _, subsystem := range Subsystems {
+		if subsystem == name {
+			return true
+		}
+	}
+	return false
+}
+
+// subsystemHandler filters records by the level of the subsystem of the
+// logger, named by its SubsystemKey attribute, or by the global level
+type subsystemHandler struct {
+	inner     slog.Handler
+	subsystem string
+}
+
+func newHandler(w io.Writer) slog.Handler {
+	// The inner handler takes every record; levels are checked by Enabled
+	return &subsystemHandler{inner: slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})}
+}
+
+func (h *subsystemHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel, _ := Level(h.subsystem)
+	return level >= minLevel
+}
+
+func (h *subsystemHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	subsystem := h.subsystem
+	for _, attr := range attrs {
+		if attr.Key == SubsystemKey {
+			subsystem = attr.Value.String()
+		}
+	}
+	return &subsystemHandler{inner: h.inner.WithAttrs(attrs), subsystem: subsystem}
+}
+
+func (h *subsystemHandler) WithGroup(name string) slog.Handler {
+	return &subsystemHandler{inner: h.inner.WithGroup(name), subsystem: h.subsystem}
 }
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubsystemLevels(t *testing.T) {
+	var buf bytes.Buffer
+	InitLoggerWithWriter("info", &buf)
+	t.Cleanup(func() {
+		levels.Lock()
+		levels.subsystems = map[string]slog.Level{}
+		levels.Unlock()
+	})
+
+	require.NoError(t, SetLevels(map[string]string{Orchestrator: "debug", LLM: "error"}))
+	For(Orchestrator).Debug("planning")
+	For(LLM).Warn("slow response")
+	For(Tools).Debug("tool ran")
+	For(Tools).Info("tool done")
+	Get().Debug("global debug")
+	output := buf.String()
+	assert.Contains(t, output, "msg=planning subsystem=orchestrator")
+	assert.NotContains(t, output, "slow response")
+	assert.NotContains(t, output, "tool ran")
+	assert.Contains(t, output, "msg=\"tool done\" subsystem=tools")
+	assert.NotContains(t, output, "global debug")
+
+	level, own := Level(Tools)
+	assert.False(t, own)
+	assert.Equal(t, "INFO", level.String())
+	require.NoError(t, SetLevel(Orchestrator, "default"))
+	_, own = Level(Orchestrator)
+	assert.False(t, own)
+
+	assert.ErrorContains(t, SetLevel("network", "debug"), "unknown subsystem")
+	assert.ErrorContains(t, SetLevel(LLM, "verbose"), "unknown log level")
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.log")
+	file, err := openRotatingFile(path, Rotation{MaxSizeMB: 1, MaxFiles: 2})
+	require.NoError(t, err)
+	line := []byte(strings.Repeat("x", 600<<10) + "\n")
+
+	for i := 0; i < 4; i++ {
+		_, err := file.Write(line)
+		require.NoError(t, err)
+	}
+	require.NoError(t, file.file.Close())
+
+	// Each write past the first starts a new file; two old ones are kept
+	for _, name := range []string{"chat.log", "chat.log.1", "chat.log.2"} {
+		info, err := os.Stat(filepath.Join(filepath.Dir(path), name))
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(len(line)), info.Size(), name)
+	}
+	assert.NoFileExists(t, path+".3")
+}
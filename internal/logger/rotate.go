@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Rotation limits the size of a log file. Once a write would take it past
+// MaxSizeMB, the file is renamed to <file>.1, older copies shift to .2 and
+// so on, and a new file is started; at most MaxFiles old copies are kept.
+// A zero MaxSizeMB never rotates.
+type Rotation struct {
+	MaxSizeMB int
+	MaxFiles  int
+}
+
+// rotatingFile is a log file that rotates itself
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	rotation Rotation
+	file     *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, rotation Rotation) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, rotation: rotation}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rf.file, rf.size = file, info.Size()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	maxSize := int64(rf.rotation.MaxSizeMB) << 20
+	if maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the copies of the log file up by one, dropping the oldest,
+// and starts a new file
+func (rf *rotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	keep := max(rf.rotation.MaxFiles, 0)
+	os.Remove(fmt.Sprintf("%s.%d", rf.path, keep))
+	for i := keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+	}
+	if keep > 0 {
+		if err := os.Rename(rf.path, rf.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(rf.path); err != nil {
+		return err
+	}
+	return rf.open()
+}
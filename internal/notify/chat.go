@@ -0,0 +1,247 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// Chat services run summaries can be posted to
+const (
+	ChatSlack   = "slack"
+	ChatDiscord = "discord"
+)
+
+// maxShownFiles limits the changed files listed in a summary
+const maxShownFiles = 20
+
+// maxDiscordContent is the longest message Discord accepts
+const maxDiscordContent = 2000
+
+// ChatWebhook posts run summaries to a Slack or Discord incoming webhook
+type ChatWebhook struct {
+	Kind         string // ChatSlack or ChatDiscord
+	URL          string // Incoming webhook URL; $VARIABLES are expanded
+	Template     string // text/template over Summary; the kind's default when empty
+	OnlyFailures bool   // Post only runs that failed
+}
+
+// Summary describes a finished run
+type Summary struct {
+	Command      string        // CGE command, e.g. generate
+	Task         string        // What the run worked on, e.g. the goal of the plan
+	Workspace    string        // Name of the workspace
+	Success      bool          // The run completed without error
+	Error        string        // Why it failed
+	Elapsed      time.Duration // How long it ran
+	FilesChanged []string      // Files the run changed, relative to the workspace
+	CostUSD      float64       // Estimated cost of the LLM requests of the run
+	CostKnown    bool          // Whether the pricing of the model is known
+
+	// Tokens of the LLM requests of the run; CachedInputTokens are the input
+	// tokens the provider served from its prompt cache
+	InputTokens       int
+	CachedInputTokens int
+	OutputTokens      int
+}
+
+// Outcome is "succeeded" or "failed"
+func (s Summary) Outcome() string {
+	if s.Success {
+		return "succeeded"
+	}
+	return "failed"
+}
+
+// Duration is Elapsed rounded to the second
+func (s Summary) Duration() time.Duration {
+	return s.Elapsed.Round(time.Second)
+}
+
+// Cost is the estimated cost as text, e.g. "$0.42", "free" or "unknown"
+func (s Summary) Cost() string {
+	switch {
+	case !s.CostKnown:
+		return "unknown"
+	case s.CostUSD == 0:
+		return "free"
+	case s.CostUSD < 0.01:
+		return "<$0.01"
+	}
+	return fmt.Sprintf("$%.2f", s.CostUSD)
+}
+
+// Tokens is the token usage as text, e.g. "120.5k in (96.0k cached), 3.2k
+// out"; empty when no tokens were recorded
+func (s Summary) Tokens() string {
+	if s.InputTokens == 0 && s.OutputTokens == 0 {
+		return ""
+	}
+	text := formatTokens(s.InputTokens) + " in"
+	if s.CachedInputTokens > 0 {
+		text += " (" + formatTokens(s.CachedInputTokens) + " cached)"
+	}
+	return text + ", " + formatTokens(s.OutputTokens) + " out"
+}
+
+// formatTokens abbreviates a token count, e.g. 950, 12.3k or 1.5M
+func formatTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// ShownFiles are the first changed files, as many as a message lists
+func (s Summary) ShownFiles() []string {
+	if len(s.FilesChanged) > maxShownFiles {
+		return s.FilesChanged[:maxShownFiles]
+	}
+	return s.FilesChanged
+}
+
+// MoreFiles is the number of changed files left out of ShownFiles
+func (s Summary) MoreFiles() int {
+	return len(s.FilesChanged) - len(s.ShownFiles())
+}
+
+// Default message templates of each chat service, in their markup
+const (
+	DefaultSlackTemplate = `{{if .Success}}:white_check_mark:{{else}}:x:{{end}} *CGE {{.Command}}* {{.Outcome}} after {{.Duration}}{{with .Workspace}} in _{{.}}_{{end}}
+{{with .Task}}*Task:* {{.}}
+{{end}}{{with .Error}}*Error:* {{.}}
+{{end}}*Files changed:* {{len .FilesChanged}}{{range .ShownFiles}}
+• ` + "`{{.}}`" + `{{end}}{{with .MoreFiles}}
+…and {{.}} more{{end}}
+*Cost:* {{.Cost}}{{with .Tokens}} ({{.}}){{end}}`
+
+	DefaultDiscordTemplate = `{{if .Success}}✅{{else}}❌{{end}} **CGE {{.Command}}** {{.Outcome}} after {{.Duration}}{{with .Workspace}} in *{{.}}*{{end}}
+{{with .Task}}**Task:** {{.}}
+{{end}}{{with .Error}}**Error:** {{.}}
+{{end}}**Files changed:** {{len .FilesChanged}}{{range .ShownFiles}}
+- ` + "`{{.}}`" + `{{end}}{{with .MoreFiles}}
+…and {{.}} more{{end}}
+**Cost:** {{.Cost}}{{with .Tokens}} ({{.}}){{end}}`
+)
+
+// ValidateChat checks the kinds and templates of webhooks
+func ValidateChat(webhooks []ChatWebhook) error {
+	for _, webhook := range webhooks {
+		if webhook.Kind != ChatSlack && webhook.Kind != ChatDiscord {
+			return fmt.Errorf("unknown chat service %q (want %s or %s)", webhook.Kind, ChatSlack, ChatDiscord)
+		}
+		if _, err := webhook.template(); err != nil {
+			return fmt.Errorf("invalid %s template: %w", webhook.Kind, err)
+		}
+	}
+	return nil
+}
+
+// template parses the message template of w
+func (w ChatWebhook) template() (*template.Template, error) {
+	text := w.Template
+	if text == "" {
+		text = DefaultSlackTemplate
+		if w.Kind == ChatDiscord {
+			text = DefaultDiscordTemplate
+		}
+	}
+	return template.New(w.Kind).Option("missingkey=error").Parse(text)
+}
+
+// Message renders the message w posts for summary
+func (w ChatWebhook) Message(summary Summary) (string, error) {
+	tmpl, err := w.template()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, summary); err != nil {
+		return "", err
+	}
+	message := strings.TrimSpace(buf.String())
+	if w.Kind == ChatDiscord && utf8.RuneCountInString(message) > maxDiscordContent {
+		runes := []rune(message)
+		message = string(runes[:maxDiscordContent-1]) + "…"
+	}
+	return message, nil
+}
+
+// payload returns the JSON body of a message for the service of w
+func (w ChatWebhook) payload(message string) ([]byte, error) {
+	if w.Kind == ChatDiscord {
+		return json.Marshal(map[string]string{"content": message})
+	}
+	return json.Marshal(map[string]string{"text": message})
+}
+
+// PostSummary posts summary to the chat webhooks of the notifier. Like
+// RunFinished, nothing happens when notifications are disabled or the run was
+// shorter than the configured minimum; webhooks that only want failures skip
+// successful runs. The returned error reports failed posts and is safe to
+// ignore.
+func (n *Notifier) PostSummary(ctx context.Context, summary Summary) error {
+	if !n.opts.Enabled || summary.Elapsed < n.opts.MinDuration {
+		return nil
+	}
+	var failures []string
+	for _, webhook := range n.opts.Chat {
+		if webhook.OnlyFailures && summary.Success {
+			continue
+		}
+		if err := n.post(ctx, webhook, summary); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", webhook.Kind, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to post run summary: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// post sends the message of summary to one webhook
+func (n *Notifier) post(ctx context.Context, webhook ChatWebhook, summary Summary) error {
+	message, err := webhook.Message(summary)
+	if err != nil {
+		return err
+	}
+	body, err := webhook.payload(message)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, os.ExpandEnv(webhook.URL), bytes.NewReader(body))
+	if err != nil {
+		return errors.New("invalid webhook URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The URL of a webhook is its secret, so it stays out of errors
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("request failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
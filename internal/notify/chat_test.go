@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateChat(t *testing.T) {
+	assert.NoError(t, ValidateChat([]ChatWebhook{{Kind: ChatSlack}, {Kind: ChatDiscord, Template: "{{.Command}} {{.Outcome}}"}}))
+	assert.ErrorContains(t, ValidateChat([]ChatWebhook{{Kind: "teams"}}), `unknown chat service "teams"`)
+	assert.ErrorContains(t, ValidateChat([]ChatWebhook{{Kind: ChatSlack, Template: "{{.Command"}}), "invalid slack template")
+}
+
+func TestChatMessage(t *testing.T) {
+	summary := Summary{
+		Command:      "generate",
+		Task:         "Add pagination",
+		Workspace:    "shop",
+		Success:      true,
+		Elapsed:      12*time.Minute + 300*time.Millisecond,
+		FilesChanged: []string{"api/list.go", "api/list_test.go"},
+		CostUSD:      0.4213,
+		CostKnown:    true,
+	}
+
+	message, err := ChatWebhook{Kind: ChatSlack}.Message(summary)
+	require.NoError(t, err)
+	assert.Equal(t, ":white_check_mark: *CGE generate* succeeded after 12m0s in _shop_\n"+
+		"*Task:* Add pagination\n"+
+		"*Files changed:* 2\n• `api/list.go`\n• `api/list_test.go`\n"+
+		"*Cost:* $0.42", message)
+
+	summary.Success, summary.Error, summary.CostKnown = false, "tests failed", false
+	message, err = ChatWebhook{Kind: ChatDiscord}.Message(summary)
+	require.NoError(t, err)
+	assert.Contains(t, message, "❌ **CGE generate** failed after 12m0s")
+	assert.Contains(t, message, "**Error:** tests failed")
+	assert.Contains(t, message, "**Cost:** unknown")
+
+	message, err = ChatWebhook{Kind: ChatSlack, Template: "{{.Command}} {{.Outcome}} ({{.Cost}})"}.Message(summary)
+	require.NoError(t, err)
+	assert.Equal(t, "generate failed (unknown)", message)
+
+	summary.InputTokens, summary.CachedInputTokens, summary.OutputTokens = 120_500, 96_000, 3_200
+	message, err = ChatWebhook{Kind: ChatSlack}.Message(summary)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(message, "*Cost:* unknown (120.5k in (96.0k cached), 3.2k out)"))
+	summary.InputTokens, summary.CachedInputTokens, summary.OutputTokens = 0, 0, 0
+
+	summary.FilesChanged = make([]string, 25)
+	for i := range summary.FilesChanged {
+		summary.FilesChanged[i] = "internal/store/migration.go"
+	}
+	message, err = ChatWebhook{Kind: ChatDiscord}.Message(summary)
+	require.NoError(t, err)
+	assert.Contains(t, message, "**Files changed:** 25")
+	assert.True(t, strings.HasSuffix(message, "…and 5 more\n**Cost:** unknown"))
+
+	summary.Error = strings.Repeat("x", 3000)
+	message, err = ChatWebhook{Kind: ChatDiscord}.Message(summary)
+	require.NoError(t, err)
+	assert.Len(t, []rune(message), maxDiscordContent, "discord messages are cut to fit")
+}
+
+func TestSummaryCost(t *testing.T) {
+	assert.Equal(t, "unknown", Summary{}.Cost())
+	assert.Equal(t, "free", Summary{CostKnown: true}.Cost())
+	assert.Equal(t, "<$0.01", Summary{CostKnown: true, CostUSD: 0.004}.Cost())
+	assert.Equal(t, "$1.50", Summary{CostKnown: true, CostUSD: 1.5}.Cost())
+}
+
+func TestPostSummary(t *testing.T) {
+	var payloads []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]string
+		_ = json.Unmarshal(body, &payload)
+		payloads = append(payloads, payload)
+		if r.URL.Path == "/broken" {
+			http.Error(w, "invalid_token", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("CGE_TEST_SLACK_WEBHOOK", server.URL+"/slack")
+	n := &Notifier{client: server.Client(), opts: Options{
+		Enabled:     true,
+		MinDuration: time.Minute,
+		Chat: []ChatWebhook{
+			{Kind: ChatSlack, URL: "$CGE_TEST_SLACK_WEBHOOK", Template: "{{.Command}} {{.Outcome}}"},
+			{Kind: ChatDiscord, URL: server.URL + "/discord", Template: "{{.Command}} {{.Outcome}}", OnlyFailures: true},
+		},
+	}}
+
+	require.NoError(t, n.PostSummary(context.Background(), Summary{Command: "plan", Success: true, Elapsed: 30 * time.Second}))
+	assert.Empty(t, payloads, "short runs are not posted")
+
+	require.NoError(t, n.PostSummary(context.Background(), Summary{Command: "generate", Success: true, Elapsed: time.Hour}))
+	assert.Equal(t, []map[string]string{{"text": "generate succeeded"}}, payloads, "successful runs skip failure-only webhooks")
+
+	payloads = nil
+	require.NoError(t, n.PostSummary(context.Background(), Summary{Command: "review", Elapsed: time.Hour}))
+	assert.Equal(t, []map[string]string{{"text": "review failed"}, {"content": "review failed"}}, payloads)
+
+	n.opts.Chat = []ChatWebhook{{Kind: ChatSlack, URL: server.URL + "/broken"}}
+	err := n.PostSummary(context.Background(), Summary{Command: "review", Elapsed: time.Hour})
+	assert.ErrorContains(t, err, "slack: webhook responded 403 Forbidden: invalid_token")
+	assert.NotContains(t, err.Error(), server.URL, "webhook URLs stay out of errors")
+}
@@ -0,0 +1,102 @@
+// Package notify alerts the user when a long-running command finishes, so they
+// can switch away from the terminal during multi-minute runs.
+package notify
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/httpclient"
+)
+
+// Options configures completion notifications
+type Options struct {
+	Enabled     bool
+	MinDuration time.Duration // Runs shorter than this finish silently
+	Bell        bool          // Ring the terminal bell
+	Desktop     bool          // Send an OS notification
+	Chat        []ChatWebhook // Slack and Discord webhooks run summaries are posted to
+}
+
+// Notifier emits completion notifications
+type Notifier struct {
+	opts   Options
+	out    io.Writer                         // Terminal the bell is written to
+	send   func(title, message string) error // Sends an OS notification
+	client *http.Client                      // Posts to chat webhooks
+}
+
+// New creates a notifier that rings the bell on stderr, uses the platform's
+// notification command for desktop notifications and posts to chat webhooks
+// with the shared HTTP client
+func New(opts Options) *Notifier {
+	return &Notifier{
+		opts:   opts,
+		out:    os.Stderr,
+		send:   sendDesktopNotification,
+		client: httpclient.Default(),
+	}
+}
+
+// RunFinished notifies that task completed (runErr == nil) or failed after
+// elapsed. Nothing happens when notifications are disabled or the run was
+// shorter than the configured minimum. The returned error reports a failed
+// desktop notification and is safe to ignore.
+func (n *Notifier) RunFinished(task string, elapsed time.Duration, runErr error) error {
+	if !n.opts.Enabled || elapsed < n.opts.MinDuration {
+		return nil
+	}
+
+	if n.opts.Bell {
+		fmt.Fprint(n.out, "\a")
+	}
+	if !n.opts.Desktop {
+		return nil
+	}
+
+	elapsed = elapsed.Round(time.Second)
+	title := fmt.Sprintf("CGE %s finished", task)
+	message := fmt.Sprintf("Completed in %s", elapsed)
+	if runErr != nil {
+		title = fmt.Sprintf("CGE %s failed", task)
+		message = fmt.Sprintf("Failed after %s: %v", elapsed, runErr)
+	}
+	return n.send(title, message)
+}
+
+// sendDesktopNotification shows an OS notification using osascript on macOS
+// and notify-send on Linux and the BSDs
+func sendDesktopNotification(title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return fmt.Errorf("notify-send not found: %w", err)
+		}
+		cmd = exec.Command("notify-send", "--app-name=CGE", title, message)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to send desktop notification: %w (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", " ")
+	return `"` + s + `"`
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sentNotification struct {
+	title   string
+	message string
+}
+
+func newTestNotifier(opts Options) (*Notifier, *bytes.Buffer, *[]sentNotification) {
+	var out bytes.Buffer
+	var sent []sentNotification
+	n := &Notifier{
+		opts: opts,
+		out:  &out,
+		send: func(title, message string) error {
+			sent = append(sent, sentNotification{title: title, message: message})
+			return nil
+		},
+	}
+	return n, &out, &sent
+}
+
+func TestRunFinished(t *testing.T) {
+	opts := Options{Enabled: true, MinDuration: time.Minute, Bell: true, Desktop: true}
+
+	t.Run("short runs are silent", func(t *testing.T) {
+		n, out, sent := newTestNotifier(opts)
+		assert.NoError(t, n.RunFinished("generate", 30*time.Second, nil))
+		assert.Empty(t, out.String())
+		assert.Empty(t, *sent)
+	})
+
+	t.Run("long successful run", func(t *testing.T) {
+		n, out, sent := newTestNotifier(opts)
+		assert.NoError(t, n.RunFinished("generate", 10*time.Minute+400*time.Millisecond, nil))
+		assert.Equal(t, "\a", out.String())
+		if assert.Len(t, *sent, 1) {
+			assert.Equal(t, "CGE generate finished", (*sent)[0].title)
+			assert.Equal(t, "Completed in 10m0s", (*sent)[0].message)
+		}
+	})
+
+	t.Run("long failed run", func(t *testing.T) {
+		n, _, sent := newTestNotifier(opts)
+		assert.NoError(t, n.RunFinished("review", 2*time.Minute, errors.New("tests failed")))
+		if assert.Len(t, *sent, 1) {
+			assert.Equal(t, "CGE review failed", (*sent)[0].title)
+			assert.Equal(t, "Failed after 2m0s: tests failed", (*sent)[0].message)
+		}
+	})
+
+	t.Run("bell only", func(t *testing.T) {
+		n, out, sent := newTestNotifier(Options{Enabled: true, MinDuration: time.Minute, Bell: true})
+		assert.NoError(t, n.RunFinished("plan", time.Hour, nil))
+		assert.Equal(t, "\a", out.String())
+		assert.Empty(t, *sent)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		n, out, sent := newTestNotifier(Options{MinDuration: 0, Bell: true, Desktop: true})
+		assert.NoError(t, n.RunFinished("plan", time.Hour, nil))
+		assert.Empty(t, out.String())
+		assert.Empty(t, *sent)
+	})
+}
+
+func TestAppleScriptString(t *testing.T) {
+	assert.Equal(t, `"say \"hi\" \\ bye"`, appleScriptString(`say "hi" \ bye`))
+	assert.Equal(t, `"two lines"`, appleScriptString("two\nlines"))
+}
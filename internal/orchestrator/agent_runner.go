@@ -3,13 +3,20 @@ package orchestrator
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/config"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/hooks"
 	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/policy"
+	"github.com/castrovroberto/CGE/internal/security"
 )
 
 // Message represents a message in the conversation history
@@ -29,6 +36,11 @@ type RunResult struct {
 	Iterations    int       `json:"iterations"`
 	Success       bool      `json:"success"`
 	Error         string    `json:"error,omitempty"`
+	Cancelled     bool      `json:"cancelled,omitempty"` // Stopped by the caller cancelling the context
+	Stalled       bool      `json:"stalled,omitempty"`   // Stopped by loop detection; FinalResponse is the stall report
+	// UnusedSteering holds steering messages that arrived after the final
+	// LLM call, which the caller should send as the next prompt
+	UnusedSteering []string `json:"unused_steering,omitempty"`
 
 	// Enhanced error tracking
 	ToolRetries  int      `json:"tool_retries,omitempty"`
@@ -47,6 +59,10 @@ type RunConfig struct {
 	RetryWithModification bool `json:"retry_with_modification"`  // Enable retry prompting
 	EnableErrorAnalysis   bool `json:"enable_error_analysis"`    // Enable enhanced error formatting
 	AbortOnRepeatedErrors bool `json:"abort_on_repeated_errors"` // Abort if same error repeats
+
+	// Iterations without progress before loop detection intervenes; 0
+	// disables loop detection
+	StallIterations int `json:"stall_iterations"`
 }
 
 // DefaultRunConfig returns default configuration
@@ -60,20 +76,22 @@ func DefaultRunConfig() *RunConfig {
 		RetryWithModification: true,
 		EnableErrorAnalysis:   true,
 		AbortOnRepeatedErrors: false,
+		StallIterations:       5,
 	}
 }
 
 // PlanRunConfig returns configuration optimized for planning
 func PlanRunConfig() *RunConfig {
 	return &RunConfig{
-		MaxIterations:         5,                                                           // Planning should be quick
-		AllowedTools:          []string{"read_file", "list_directory", "retrieve_context"}, // Limited tools for planning
+		MaxIterations:         5,                                                                            // Planning should be quick
+		AllowedTools:          []string{"read_file", "list_directory", "retrieve_context", "grep_codebase"}, // Limited tools for planning
 		RequireTextOutput:     true,
 		TimeoutSeconds:        180, // 3 minutes
 		MaxToolRetries:        1,   // Fewer retries for planning
 		RetryWithModification: true,
 		EnableErrorAnalysis:   true,
 		AbortOnRepeatedErrors: true, // Abort quickly for planning
+		StallIterations:       3,
 	}
 }
 
@@ -81,13 +99,14 @@ func PlanRunConfig() *RunConfig {
 func GenerateRunConfig() *RunConfig {
 	return &RunConfig{
 		MaxIterations:         15, // Generation might need more iterations
-		AllowedTools:          []string{"read_file", "write_file", "list_directory", "apply_patch_to_file", "run_shell_command"},
+		AllowedTools:          []string{"read_file", "write_file", "list_directory", "grep_codebase", "apply_patch_to_file", "search_replace", "run_shell_command", "run_tests_with_coverage", "delegate_task"},
 		RequireTextOutput:     false, // Generation might end with tool calls
 		TimeoutSeconds:        600,   // 10 minutes
 		MaxToolRetries:        3,     // More retries for generation
 		RetryWithModification: true,
 		EnableErrorAnalysis:   true,
 		AbortOnRepeatedErrors: false,
+		StallIterations:       6,
 	}
 }
 
@@ -95,13 +114,14 @@ func GenerateRunConfig() *RunConfig {
 func ReviewRunConfig() *RunConfig {
 	return &RunConfig{
 		MaxIterations:         20, // Review might need many iterations
-		AllowedTools:          []string{"read_file", "apply_patch_to_file", "run_tests", "run_linter", "parse_test_results"},
+		AllowedTools:          []string{"read_file", "grep_codebase", "apply_patch_to_file", "search_replace", "run_tests", "run_linter", "parse_test_results", "run_tests_with_coverage", "delegate_task"},
 		RequireTextOutput:     false,
 		TimeoutSeconds:        900, // 15 minutes
 		MaxToolRetries:        2,   // Standard retries for review
 		RetryWithModification: true,
 		EnableErrorAnalysis:   true,
 		AbortOnRepeatedErrors: true, // Abort on repeated errors in review
+		StallIterations:       6,
 	}
 }
 
@@ -130,6 +150,71 @@ type AgentRunner struct {
 	toolAttempts   []ToolCallAttempt `json:"tool_attempts,omitempty"`
 	errorHistory   map[string]int    `json:"error_history,omitempty"`   // Error code -> count
 	currentRetries map[string]int    `json:"current_retries,omitempty"` // Tool call signature -> retry count
+
+	// Steering messages sent by the user while a run is in progress
+	steerMu  sync.Mutex
+	steering []string
+
+	// Iterations of the current run, and those reserved for or used by its
+	// delegated subtasks, which share the run's budget
+	delegationMu        sync.Mutex
+	runIterations       int
+	delegatedIterations int
+
+	// sessionMu serializes checkpoints of currentSession by the run loop and
+	// the heartbeat
+	sessionMu sync.Mutex
+
+	// approvalGate holds back file changes and commits for approval; nil
+	// runs every tool call
+	approvalGate *ApprovalGate
+
+	// injectionPolicy overrides the prompt injection policy of the
+	// configuration in the run context
+	injectionPolicy *security.InjectionPolicy
+
+	// routing overrides the small-model routing of the configuration in the
+	// run context
+	routing *config.RoutingConfig
+
+	// history overrides the history strategy of the configuration in the
+	// run context
+	history *config.HistoryConfig
+
+	// scratchpad holds the notes the agent keeps outside the conversation
+	scratchpad *agent.Scratchpad
+
+	// tasks is the checklist the agent keeps of the steps of its run
+	tasks *agent.TaskList
+
+	// fileVersions is what the agent knows of the files it read, kept
+	// across the runs of the runner unless the run context carries its own
+	fileVersions *agent.FileVersions
+
+	// pathGuard, when pathGuardSet, overrides the protected paths of the
+	// configuration in the run context
+	pathGuard    *agent.PathGuard
+	pathGuardSet bool
+
+	// hooks, when hooksSet, override the hooks of the configuration in the
+	// run context; runCommand is the command of the current run, for them
+	hooks      *hooks.Dispatcher
+	hooksSet   bool
+	runCommand string
+
+	// policy, when policySet, overrides the policies of the configuration in
+	// the run context
+	policy    *policy.Engine
+	policySet bool
+
+	// outputLimits, when outputLimitsSet, override the size guard of tool
+	// results of the configuration in the run context
+	outputLimits    agent.OutputLimits
+	outputLimitsSet bool
+
+	// toolsMu guards the tools disabled by the user
+	toolsMu       sync.Mutex
+	disabledTools map[string]bool
 }
 
 // NewAgentRunner creates a new agent runner
@@ -141,6 +226,9 @@ func NewAgentRunner(llmClient llm.Client, toolRegistry *agent.Registry, systemPr
 		maxIterations:  10, // Default max iterations
 		model:          model,
 		config:         DefaultRunConfig(),
+		scratchpad:     agent.NewScratchpad(nil),
+		tasks:          agent.NewTaskList(nil),
+		fileVersions:   agent.NewFileVersions(),
 		toolAttempts:   make([]ToolCallAttempt, 0),
 		errorHistory:   make(map[string]int),
 		currentRetries: make(map[string]int),
@@ -157,6 +245,9 @@ func NewAgentRunnerWithSession(llmClient llm.Client, toolRegistry *agent.Registr
 		model:          model,
 		config:         DefaultRunConfig(),
 		sessionManager: sessionManager,
+		scratchpad:     agent.NewScratchpad(nil),
+		tasks:          agent.NewTaskList(nil),
+		fileVersions:   agent.NewFileVersions(),
 		toolAttempts:   make([]ToolCallAttempt, 0),
 		errorHistory:   make(map[string]int),
 		currentRetries: make(map[string]int),
@@ -169,14 +260,103 @@ func (ar *AgentRunner) SetConfig(config *RunConfig) {
 	ar.maxIterations = config.MaxIterations
 }
 
+// SetModel changes the model used from the next LLM call on
+func (ar *AgentRunner) SetModel(model string) {
+	ar.model = model
+}
+
+// SetSystemPrompt replaces the system prompt of the runner from the next run
+// on
+func (ar *AgentRunner) SetSystemPrompt(prompt string) {
+	ar.systemPrompt = prompt
+}
+
+// SetApprovalGate makes file-changing and committing tool calls wait at the
+// gate's checkpoints. Its decisions are recorded in the session.
+func (ar *AgentRunner) SetApprovalGate(gate *ApprovalGate) {
+	ar.approvalGate = gate
+}
+
+// SetInjectionPolicy sets how untrusted tool results are defended against
+// prompt injection. Without it, the policy of the configuration in the run
+// context is used, or security.DefaultInjectionPolicy.
+func (ar *AgentRunner) SetInjectionPolicy(policy security.InjectionPolicy) {
+	ar.injectionPolicy = &policy
+}
+
+// SetProtectedPaths makes tools refuse to change the files of workspaceRoot
+// matching globs; no globs protect nothing. Without it, the protected paths
+// of the configuration in the run context are used.
+func (ar *AgentRunner) SetProtectedPaths(workspaceRoot string, globs []string) {
+	ar.pathGuard = agent.NewPathGuard(workspaceRoot, globs)
+	ar.pathGuardSet = true
+}
+
 // Run executes the agent orchestration loop
 func (ar *AgentRunner) Run(ctx context.Context, initialPrompt string) (*RunResult, error) {
 	return ar.RunWithCommand(ctx, initialPrompt, "unknown")
 }
 
-// RunWithCommand executes the agent orchestration loop with command tracking
+// RunWithCommand executes the agent orchestration loop with command tracking.
+// With a session manager, the session is checkpointed after every step and
+// periodically while a step runs, so it can be recovered if the process dies.
 func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string, command string) (*RunResult, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	// Nothing steered at this run carries over into the next one
+	defer ar.dropSteering(ctx)
+
+	// Initialize or resume session
+	if ar.sessionManager != nil && ar.currentSession == nil {
+		ar.currentSession = ar.sessionManager.CreateSession(ar.systemPrompt, ar.model, command, ar.config)
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Info("Created new session", "session_id", ar.currentSession.SessionID)
+	}
+	// The session stays locked until its outcome is saved, so no other
+	// process writes it meanwhile
+	if ar.currentSession != nil {
+		sessionID := ar.currentSession.SessionID
+		if err := ar.sessionManager.LockSession(sessionID); err != nil {
+			return nil, err
+		}
+		defer ar.sessionManager.UnlockSession(sessionID)
+	}
+	// Edits check that files did not change since the agent read them,
+	// in an earlier run of the runner as well
+	if _, ok := agent.FileVersionsFromContext(ctx); !ok {
+		ctx = agent.WithFileVersions(ctx, ar.fileVersions)
+	}
+	// The scratchpad and task list tools keep the notes and tasks of this
+	// runner, not those of a parent run
+	ar.restoreScratchpad()
+	ctx = agent.WithScratchpad(ctx, ar.scratchpad)
+	ar.restoreTasks()
+	ctx = agent.WithTaskList(ctx, ar.tasks)
+
+	// Blocking run_started hooks may refuse the run
+	ar.runCommand = command
+	started := time.Now()
+	if err := ar.fireHook(ctx, hooks.Event{Event: hooks.RunStarted}); err != nil {
+		err = fmt.Errorf("run %w", err)
+		ar.finishSession(ctx, nil, err)
+		return nil, err
+	}
+
+	// A shutdown waits for the runs in progress to pause their sessions
+	if shutdown := ShutdownFromContext(ctx); shutdown != nil {
+		defer shutdown.begin()()
+	}
+	result, err := ar.runWithCommand(ctx, initialPrompt, command)
+	// Steering sent after the last LLM call goes back to the caller
+	if result != nil {
+		result.UnusedSteering = ar.takeSteering()
+	}
+	ar.finishSession(ctx, result, err)
+	ar.fireRunCompleted(ctx, result, err, time.Since(started))
+	return result, err
+}
+
+// runWithCommand runs the orchestration loop for RunWithCommand
+func (ar *AgentRunner) runWithCommand(ctx context.Context, initialPrompt string, command string) (*RunResult, error) {
+	log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
+	toolLog := contextkeys.LoggerFor(ctx, logger.Tools)
 
 	// Apply timeout from configuration
 	if ar.config.TimeoutSeconds > 0 {
@@ -185,15 +365,17 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 		defer cancel()
 	}
 
-	// Initialize or resume session
-	if ar.sessionManager != nil && ar.currentSession == nil {
-		ar.currentSession = ar.sessionManager.CreateSession(ar.systemPrompt, ar.model, command, ar.config)
-		log.Info("Created new session", "session_id", ar.currentSession.SessionID)
+	if ar.currentSession != nil {
+		ar.sessionMu.Lock()
+		ar.currentSession.CurrentState = "running"
+		ar.currentSession.EndTime = nil
+		ar.sessionMu.Unlock()
+		defer ar.startSessionHeartbeat(ctx)()
 	}
 
 	// Initialize message history
 	messages := []Message{
-		{Role: "system", Content: ar.systemPrompt},
+		{Role: "system", Content: ar.runSystemPrompt(ctx) + "\n\n" + completionInstructions},
 		{Role: "user", Content: initialPrompt},
 	}
 
@@ -206,10 +388,15 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 		}
 	}
 
+	history := ar.newHistoryWindow(ctx, len(messages)-1)
+	tasks := ar.newTaskTracker()
 	toolCalls := 0
 	totalRetries := 0
 	iterations := 0
 	errorDetails := make([]string, 0)
+	failures := &failureMemory{}
+	loops := newLoopDetector(ar.config.StallIterations)
+	reminded := false // Whether the model was reminded to finish since its last tool call
 
 	// Count existing tool calls if resuming
 	if ar.currentSession != nil {
@@ -222,6 +409,10 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 		}
 	}
 
+	ar.delegationMu.Lock()
+	ar.runIterations, ar.delegatedIterations = iterations, 0
+	ar.delegationMu.Unlock()
+
 	log.Info("Starting agent orchestration", "max_iterations", ar.maxIterations, "session_id", func() string {
 		if ar.currentSession != nil {
 			return ar.currentSession.SessionID
@@ -231,20 +422,84 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 
 	// Main orchestration loop
 	for iterations < ar.maxIterations {
+		// A shutdown signal stops the run between steps, once the tool call
+		// of the last one finished
+		if signal, ok := shutdownSignal(ctx); ok {
+			return ar.stopForShutdown(ctx, signal, &RunResult{
+				Messages:     messages,
+				ToolCalls:    toolCalls,
+				Iterations:   iterations,
+				ToolRetries:  totalRetries,
+				ErrorDetails: errorDetails,
+			}), nil
+		}
+
+		// Intervene when the run stopped making progress, rather than let
+		// it use up its iterations
+		if reason := loops.nextIteration(); reason != "" {
+			message, stop := loops.intervene(reason)
+			if stop {
+				log.Warn("Agent run stalled", "reason", reason, "iterations", iterations)
+				return &RunResult{
+					FinalResponse: loops.report(reason),
+					Messages:      messages,
+					ToolCalls:     toolCalls,
+					Iterations:    iterations,
+					Success:       false,
+					Error:         fmt.Sprintf("stalled: %s", reason),
+					Stalled:       true,
+					ToolRetries:   totalRetries,
+					ErrorDetails:  errorDetails,
+				}, nil
+			}
+			log.Info("Loop detected", "reason", reason, "iteration", iterations+1)
+			messages = append(messages, Message{Role: "user", Content: message})
+			errorDetails = append(errorDetails, fmt.Sprintf("Loop detected: %s", reason))
+		}
+
 		iterations++
+		ar.setRunIterations(iterations)
 		log.Debug("Agent iteration", "iteration", iterations)
 
+		// Incorporate steering messages sent since the last iteration
+		for _, steer := range ar.takeSteering() {
+			log.Info("Incorporating steering message", "iteration", iterations)
+			messages = append(messages, Message{Role: "user", Content: steeringPrefix + steer})
+		}
+
 		// Prepare tool definitions
-		tools := ar.prepareToolDefinitions()
+		tools := append(loops.filter(append(append(ar.prepareToolDefinitions(), ar.scratchpadToolDefinitions()...), ar.taskListToolDefinitions()...)), finishToolDefinition())
 
 		// Call LLM with function calling support
-		response, err := ar.llmClient.GenerateWithFunctions(
-			ctx,
-			ar.model,
-			ar.buildPromptFromMessages(messages),
-			"", // System prompt already in messages
-			tools,
-		)
+		// Recent failures, the task list and the scratchpad digest are for
+		// this step only
+		// A shutdown signal cancels the call rather than wait for its answer
+		generateCtx, cancelGenerate := withShutdownCancel(ctx)
+		response, err := ar.routedGenerate(generateCtx, withScratchpadNote(withTaskNote(withFailureNote(history.view(ctx, messages), failures), tasks, toolCalls), ar.scratchpad), tools)
+		cancelGenerate()
+		if signal, ok := shutdownSignal(ctx); ok && err != nil && ctx.Err() == nil {
+			return ar.stopForShutdown(ctx, signal, &RunResult{
+				Messages:     messages,
+				ToolCalls:    toolCalls,
+				Iterations:   iterations,
+				ToolRetries:  totalRetries,
+				ErrorDetails: errorDetails,
+			}), nil
+		}
+		if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+			log.Info("Agent run cancelled during LLM generation")
+			ar.pauseCancelledSession(ctx)
+			return &RunResult{
+				Messages:     messages,
+				ToolCalls:    toolCalls,
+				Iterations:   iterations,
+				Success:      false,
+				Error:        fmt.Sprintf("cancelled: %v", ctx.Err()),
+				Cancelled:    true,
+				ToolRetries:  totalRetries,
+				ErrorDetails: errorDetails,
+			}, nil
+		}
 		if err != nil {
 			log.Error("LLM generation failed", "error", err, "iteration", iterations)
 			return &RunResult{
@@ -268,17 +523,10 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 			}
 			messages = append(messages, newMessage)
 
-			// Update session if available
-			if ar.currentSession != nil {
-				ar.currentSession.Messages = messages
-
-				// TODO: Fix type conflicts between local ToolCallRecord and session_manager.ToolCallRecord
-				// For now, just save messages
-				ar.sessionManager.SaveSession(ar.currentSession)
-			}
+			ar.checkpointSession(ctx, messages)
 
 			// Check if this should be treated as final
-			if ar.isFinalAnswer(response.TextContent, iterations) {
+			if ar.isFinalAnswer(response.TextContent, iterations, tools) {
 				log.Info("Agent completed with text response", "iterations", iterations, "tool_calls", toolCalls)
 				return &RunResult{
 					FinalResponse: response.TextContent,
@@ -290,10 +538,17 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 					ErrorDetails:  errorDetails,
 				}, nil
 			}
+			// Rather than wait for a reply that looks final, remind the
+			// model how to finish
+			if !reminded {
+				reminded = true
+				messages = append(messages, Message{Role: "user", Content: finishReminder})
+			}
 		} else {
 			// Function call - handle with enhanced error retry logic
 			functionCall := response.FunctionCall
 			callSignature := ar.getToolCallSignature(functionCall)
+			reminded = false
 
 			// Add function call message
 			callMessage := Message{
@@ -303,6 +558,70 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 			}
 			messages = append(messages, callMessage)
 
+			// The model ends the run with its final answer
+			if functionCall.Name == finishToolName {
+				answer, success, err := parseFinish(functionCall)
+				if err != nil {
+					messages = append(messages, Message{Role: "tool", ToolCallID: functionCall.ID, Name: finishToolName, Content: fmt.Sprintf("Error: %v", err)})
+					ar.checkpointSession(ctx, messages)
+					continue
+				}
+				// A run that succeeds with open tasks is asked once to
+				// bring its task list up to date
+				if reason := tasks.checkFinish(success); reason != "" {
+					messages = append(messages, Message{Role: "tool", ToolCallID: functionCall.ID, Name: finishToolName, Content: reason})
+					ar.checkpointSession(ctx, messages)
+					continue
+				}
+				messages = append(messages, Message{Role: "tool", ToolCallID: functionCall.ID, Name: finishToolName, Content: "Run finished."})
+				ar.checkpointSession(ctx, messages)
+				log.Info("Agent finished", "iterations", iterations, "tool_calls", toolCalls, "success", success)
+				result := &RunResult{
+					FinalResponse: answer,
+					Messages:      messages,
+					ToolCalls:     toolCalls,
+					Iterations:    iterations,
+					Success:       success,
+					ToolRetries:   totalRetries,
+					ErrorDetails:  errorDetails,
+				}
+				if !success {
+					result.Error = "the agent could not complete the task"
+				}
+				return result, nil
+			}
+
+			// An identical call failed before, and nothing that could change
+			// its outcome has happened since
+			if failure := failures.repeated(callSignature); failure != nil {
+				failure.repeats++
+				messages = append(messages, Message{
+					Role:       "tool",
+					ToolCallID: functionCall.ID,
+					Name:       functionCall.Name,
+					Content:    fmt.Sprintf("Not run: this exact call already failed (%s), and nothing that could change the outcome has happened since. Change the arguments or take another approach.", failure.summary),
+				})
+				errorDetails = append(errorDetails, fmt.Sprintf("Skipped repeated call to %s", functionCall.Name))
+				toolLog.Debug("Skipped repeated failing tool call", "tool", functionCall.Name, "repeats", failure.repeats)
+				loops.observe(functionCall, callSignature, false)
+				ar.checkpointSession(ctx, messages)
+				continue
+			}
+
+			// Tools withdrawn by loop detection are no longer offered, but a
+			// model may still call them
+			if loops.withholds(functionCall.Name) {
+				messages = append(messages, Message{
+					Role:       "tool",
+					ToolCallID: functionCall.ID,
+					Name:       functionCall.Name,
+					Content:    fmt.Sprintf("Not run: %s was withdrawn from this run because it was used in a loop. Take a different approach or give your final answer.", functionCall.Name),
+				})
+				loops.observe(functionCall, callSignature, false)
+				ar.checkpointSession(ctx, messages)
+				continue
+			}
+
 			// Track the attempt
 			attempt := ToolCallAttempt{
 				ToolName:   functionCall.Name,
@@ -312,7 +631,9 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 			}
 
 			// Execute tool with enhanced error handling
+			started := time.Now()
 			toolResult, executionErr := ar.executeTool(ctx, functionCall)
+			ar.recordSessionToolCall(functionCall, iterations, started, toolResult, executionErr)
 			if executionErr != nil {
 				// Internal execution error (tool not found, etc.)
 				errorMsg := fmt.Sprintf("Tool execution error: %v", executionErr)
@@ -327,6 +648,7 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 				}
 				messages = append(messages, resultMessage)
 				errorDetails = append(errorDetails, errorMsg)
+				loops.observe(functionCall, callSignature, false)
 				continue
 			}
 
@@ -348,6 +670,8 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 					attempt.ErrorMessage = toolResult.Error
 				}
 				ar.toolAttempts = append(ar.toolAttempts, attempt)
+				failures.record(functionCall, callSignature, toolResult)
+				loops.observe(functionCall, callSignature, false)
 
 				// Check if we should retry
 				if ar.shouldRetryToolCall(toolResult, retryCount, callSignature) {
@@ -361,11 +685,11 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 						Role:       "tool",
 						ToolCallID: functionCall.ID,
 						Name:       functionCall.Name,
-						Content:    retryPrompt,
+						Content:    ar.guardToolOutput(ctx, functionCall.Name, retryPrompt),
 					}
 					messages = append(messages, resultMessage)
 
-					log.Debug("Retrying tool call", "tool", functionCall.Name, "attempt", retryCount+1, "error", toolResult.Error)
+					toolLog.Debug("Retrying tool call", "tool", functionCall.Name, "attempt", retryCount+1, "error", toolResult.Error)
 					errorDetails = append(errorDetails, fmt.Sprintf("Retry %d for %s: %s", retryCount+1, functionCall.Name, toolResult.Error))
 				} else {
 					// No more retries, format error for LLM
@@ -380,7 +704,7 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 						Role:       "tool",
 						ToolCallID: functionCall.ID,
 						Name:       functionCall.Name,
-						Content:    errorContent,
+						Content:    ar.guardToolOutput(ctx, functionCall.Name, errorContent),
 					}
 					messages = append(messages, resultMessage)
 					errorDetails = append(errorDetails, fmt.Sprintf("Final error for %s: %s", functionCall.Name, toolResult.Error))
@@ -393,30 +717,28 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 
 				// Reset retry count for this tool call signature
 				delete(ar.currentRetries, callSignature)
+				if mayChangeOutcomes(functionCall) {
+					failures.forget()
+				}
+				loops.observe(functionCall, callSignature, true)
 
 				resultMessage := Message{
 					Role:       "tool",
 					ToolCallID: functionCall.ID,
 					Name:       functionCall.Name,
-					Content:    ar.formatToolResult(toolResult),
+					Content:    ar.guardToolOutput(ctx, functionCall.Name, ar.toolResultContent(ctx, functionCall.Name, ar.guardToolData(ctx, functionCall.Name, toolResult))),
 				}
 				messages = append(messages, resultMessage)
 			}
 
-			// Update session if available
-			if ar.currentSession != nil {
-				ar.currentSession.Messages = messages
-
-				// TODO: Fix type conflicts between local ToolCallRecord and session_manager.ToolCallRecord
-				// For now, just save messages
-				ar.sessionManager.SaveSession(ar.currentSession)
-			}
+			ar.checkpointSession(ctx, messages)
 		}
 
 		// Check for context cancellation
 		select {
 		case <-ctx.Done():
 			log.Info("Agent run cancelled", "reason", ctx.Err())
+			ar.pauseCancelledSession(ctx)
 			return &RunResult{
 				FinalResponse: "",
 				Messages:      messages,
@@ -424,6 +746,7 @@ func (ar *AgentRunner) RunWithCommand(ctx context.Context, initialPrompt string,
 				Iterations:    iterations,
 				Success:       false,
 				Error:         fmt.Sprintf("cancelled: %v", ctx.Err()),
+				Cancelled:     errors.Is(ctx.Err(), context.Canceled),
 				ToolRetries:   totalRetries,
 				ErrorDetails:  errorDetails,
 			}, nil
@@ -478,18 +801,32 @@ func (ar *AgentRunner) prepareToolDefinitions() []llm.ToolDefinition {
 		}
 	}
 
-	definitions := make([]llm.ToolDefinition, len(filteredTools))
-	for i, tool := range filteredTools {
-		definitions[i] = llm.CreateToolDefinition(
+	definitions := make([]llm.ToolDefinition, 0, len(filteredTools))
+	for _, tool := range filteredTools {
+		if !ar.ToolEnabled(tool.Name()) {
+			continue
+		}
+		definitions = append(definitions, llm.CreateToolDefinition(
 			tool.Name(),
 			tool.Description(),
 			tool.Parameters(),
-		)
+		))
 	}
 
 	return definitions
 }
 
+// systemPromptOf returns the system prompt of a conversation, which
+// buildPromptFromMessages leaves out of the prompt
+func systemPromptOf(messages []Message) string {
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			return msg.Content
+		}
+	}
+	return ""
+}
+
 // buildPromptFromMessages builds a prompt string from message history
 func (ar *AgentRunner) buildPromptFromMessages(messages []Message) string {
 	var parts []string
@@ -516,12 +853,20 @@ func (ar *AgentRunner) buildPromptFromMessages(messages []Message) string {
 }
 
 // executeTool executes a function call using the tool registry
-func (ar *AgentRunner) executeTool(ctx context.Context, functionCall *llm.FunctionCall) (*agent.ToolResult, error) {
+func (ar *AgentRunner) executeTool(ctx context.Context, functionCall *llm.FunctionCall) (result *agent.ToolResult, err error) {
 	// Look up tool in registry
 	tool, exists := ar.toolRegistry.Get(functionCall.Name)
+	if !exists {
+		tool, exists = runTool(functionCall.Name)
+	}
 	if !exists {
 		return nil, fmt.Errorf("tool not found: %s", functionCall.Name)
 	}
+	if !ar.ToolEnabled(functionCall.Name) {
+		return agent.NewSimpleErrorResult(fmt.Sprintf("tool %s is disabled for this session; use another tool or answer without it", functionCall.Name)), nil
+	}
+	start := time.Now()
+	defer func() { ar.toolRegistry.RecordCall(functionCall.Name, time.Since(start), result, err) }()
 
 	// Validate parameters (basic JSON validation)
 	var params map[string]interface{}
@@ -529,15 +874,67 @@ func (ar *AgentRunner) executeTool(ctx context.Context, functionCall *llm.Functi
 		return nil, fmt.Errorf("invalid tool parameters: %v", err)
 	}
 
+	// Arguments must match the tool's schema; every offending field is
+	// reported, so a retry can fix them all
+	if invalid := agent.ValidateArguments(functionCall.Arguments, tool.Parameters()); invalid != nil {
+		return agent.NewErrorResult(invalid), nil
+	}
+
+	// Calls organizational policies forbid are denied before anyone is asked
+	// to approve them
+	if denied := ar.checkPolicy(ctx, functionCall); denied != nil {
+		return denied, nil
+	}
+
+	// Changes wait for approval when the run has checkpoints
+	if rejected, err := ar.approveToolCall(ctx, functionCall); err != nil || rejected != nil {
+		return rejected, err
+	}
+	// Blocking pre_tool hooks may refuse approved calls
+	if refused := ar.firePreTool(ctx, functionCall); refused != nil {
+		return refused, nil
+	}
+
 	// Execute tool with timeout
-	toolCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	timeout := 60 * time.Second
+	if longRunning, ok := tool.(agent.LongRunningTool); ok {
+		timeout = longRunning.ToolTimeout()
+	}
+	toolCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	result, err := tool.Execute(toolCtx, functionCall.Arguments)
+	// Scope the progress reporter, if any, to this call
+	callID := functionCall.ID
+	if callID == "" {
+		callID = fmt.Sprintf("%s-%d", functionCall.Name, time.Now().UnixNano())
+	}
+	toolCtx = agent.WithToolProgress(toolCtx, functionCall.Name, callID)
+	toolCtx = agent.WithPathGuard(toolCtx, ar.runPathGuard(ctx))
+	toolCtx = agent.WithPathPolicy(toolCtx, ar.runPathPolicy(ctx, functionCall))
+	if dir, ok := ar.toolOutputDir(ctx); ok {
+		toolCtx = agent.WithToolOutputDir(toolCtx, dir)
+	}
+	agent.ReportProgress(toolCtx, 0, "Starting...", 0, 0)
+
+	result, err = tool.Execute(toolCtx, functionCall.Arguments)
+	ar.fireToolHooks(ctx, functionCall, time.Since(start), result, err)
+	contextkeys.LoggerFor(ctx, logger.Tools).Debug("Executed tool", "tool", functionCall.Name, "duration", time.Since(start), "success", err == nil && result != nil && result.Success)
 	if err != nil {
-		return nil, fmt.Errorf("tool execution error: %v", err)
+		agent.FailProgress(toolCtx, agent.AsStandardizedError(err, agent.ErrorCodeInternalError))
+		return nil, fmt.Errorf("tool execution error: %w", err)
 	}
 
+	switch {
+	case result == nil:
+		agent.FailProgress(toolCtx, agent.NewStandardizedError(agent.ErrorCodeInternalError, "tool returned no result", ""))
+	case result.Success:
+		agent.FinishProgress(toolCtx, "Completed")
+	case result.StandardizedError != nil:
+		agent.FailProgress(toolCtx, result.StandardizedError)
+	default:
+		// Tools that predate error codes only set a message
+		agent.FailProgress(toolCtx, &agent.StandardizedToolError{Message: result.Error})
+	}
 	return result, nil
 }
 
@@ -547,10 +944,15 @@ func (ar *AgentRunner) formatToolResult(result *agent.ToolResult) string {
 		return fmt.Sprintf("Error: %s", result.Error)
 	}
 
-	// Try to format the data nicely
+	// Try to format the data nicely, leaving <, > and & as they are so the
+	// model and the injection patterns see the text as written
 	if result.Data != nil {
-		if dataBytes, err := json.MarshalIndent(result.Data, "", "  "); err == nil {
-			return string(dataBytes)
+		var sb strings.Builder
+		encoder := json.NewEncoder(&sb)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result.Data); err == nil {
+			return strings.TrimSuffix(sb.String(), "\n")
 		}
 		return fmt.Sprintf("%v", result.Data)
 	}
@@ -558,39 +960,110 @@ func (ar *AgentRunner) formatToolResult(result *agent.ToolResult) string {
 	return "Tool executed successfully"
 }
 
-// isFinalAnswer determines if a text response should be treated as final
-func (ar *AgentRunner) isFinalAnswer(content string, iteration int) bool {
-	content = strings.ToLower(strings.TrimSpace(content))
+// runInjectionPolicy returns the prompt injection policy of a run
+func (ar *AgentRunner) runInjectionPolicy(ctx context.Context) security.InjectionPolicy {
+	if ar.injectionPolicy != nil {
+		return *ar.injectionPolicy
+	}
+	if cfg := contextkeys.ConfigPtrFromContext(ctx); cfg != nil {
+		return cfg.GetInjectionPolicy()
+	}
+	return security.DefaultInjectionPolicy()
+}
 
-	// If we're at max iterations, treat any text as final
-	if iteration >= ar.maxIterations {
-		return true
+// runPathGuard returns the guard of the files the tools of a run must not
+// change, or nil when none are protected
+func (ar *AgentRunner) runPathGuard(ctx context.Context) *agent.PathGuard {
+	if ar.pathGuardSet {
+		return ar.pathGuard
+	}
+	workspaceRoot, ok := runWorkspaceRoot(ctx)
+	if !ok {
+		return nil
+	}
+	return agent.NewPathGuard(workspaceRoot, contextkeys.ConfigPtrFromContext(ctx).Tools.ProtectedPaths)
+}
+
+// runSystemPrompt returns the system prompt of a run, with what was detected
+// about the workspace, the scope of the run, the protected files, the project
+// rules and, when prompt injection defenses are on, the rule for untrusted
+// content
+func (ar *AgentRunner) runSystemPrompt(ctx context.Context) string {
+	prompt := ar.systemPrompt
+	if profile := projectProfile(ctx); profile != "" {
+		prompt += "\n\n## Project\n\nDetected from the manifests of the workspace:\n\n" + profile
+	}
+	if scope := runScope(ctx); scope != "" {
+		prompt += "\n\n## Scope\n\nThis run is restricted to the " + scope + "/ directory of the repository. Paths are relative to it, and files outside it cannot be read or changed."
+	}
+	if globs := ar.runPathGuard(ctx).Globs(); len(globs) > 0 {
+		prompt += "\n\n## Protected Files\n\nFiles matching these patterns, relative to the repository root, may only be changed by the user; tools refuse to change them: " + strings.Join(globs, ", ")
+	}
+	if rules := projectRules(ctx); rules != "" {
+		prompt += "\n\n## Project Rules\n\nFollow these rules, written by the maintainers of this project:\n\n" + rules
 	}
+	if !ar.runInjectionPolicy(ctx).Enabled {
+		return prompt
+	}
+	return prompt + "\n\n" + security.UntrustedContentRule
+}
 
-	// Look for indicators that this is a final answer
-	finalIndicators := []string{
-		"task completed",
-		"finished",
-		"done",
-		"complete",
-		"successfully",
-		"final result",
-		"conclusion",
-		"summary",
+// maxClassifiedChars caps how much of a tool result the injection classifier
+// is given
+const maxClassifiedChars = 8000
+
+// toolResultContent formats a successful tool result for the message
+// history. Results of untrusted tools are wrapped in untrusted content
+// markers and cleaned of injection patterns, unless the policy keeps them
+// verbatim. With the classifier on, results it flags are withheld, or only
+// flagged when kept verbatim.
+func (ar *AgentRunner) toolResultContent(ctx context.Context, toolName string, result *agent.ToolResult) string {
+	content := ar.formatToolResult(result)
+	policy := ar.runInjectionPolicy(ctx)
+	untrusted, strip := policy.Guards(toolName)
+	if !untrusted {
+		return content
 	}
 
-	for _, indicator := range finalIndicators {
-		if strings.Contains(content, indicator) {
-			return true
+	var findings []security.InjectionFinding
+	if strip {
+		content, findings = security.StripInjections(content)
+	} else {
+		findings = security.ScanInjections(content)
+	}
+	if policy.Classify && ar.classifiesAsInjection(ctx, content) {
+		findings = append(findings, security.InjectionFinding{Kind: "classifier"})
+		if strip {
+			content = "[withheld: the content was classified as a prompt injection attempt]"
 		}
 	}
+	if len(findings) > 0 {
+		log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
+		log.Warn("Suspected prompt injection in tool result", "tool", toolName, "findings", len(findings), "removed", strip)
+	}
+	return security.WrapUntrusted(toolName, content, findings, strip)
+}
 
-	// If the response is substantial (more than 100 chars) and doesn't seem to be asking for more tools
-	if len(content) > 100 && !strings.Contains(content, "need to") && !strings.Contains(content, "should") {
-		return true
+// classifiesAsInjection asks the model whether content is a prompt injection
+// attempt. A failed classification counts as safe.
+func (ar *AgentRunner) classifiesAsInjection(ctx context.Context, content string) bool {
+	if len(content) > maxClassifiedChars {
+		content = content[:maxClassifiedChars]
 	}
+	reply, err := ar.llmClient.Generate(ctx, ar.model, content, security.InjectionClassifierPrompt, nil)
+	if err != nil {
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Debug("Injection classifier failed", "error", err)
+		return false
+	}
+	return security.ParseClassifierVerdict(reply)
+}
 
-	return false
+// isFinalAnswer determines if a reply without a tool call ends the run. Runs
+// end with finish; for models that reply instead, a reply is final on the
+// last iteration, when there are no tools besides finish, or when it looks
+// final.
+func (ar *AgentRunner) isFinalAnswer(content string, iteration int, tools []llm.ToolDefinition) bool {
+	return iteration >= ar.maxIterations || len(tools) <= 1 || looksFinal(content)
 }
 
 // GetMessageHistory returns the current message history
@@ -607,6 +1080,10 @@ func (ar *AgentRunner) ResumeSession(sessionID string) error {
 		return fmt.Errorf("session manager not available")
 	}
 
+	if ar.sessionManager.IsSessionLocked(sessionID) {
+		return &SessionLockedError{SessionID: sessionID}
+	}
+
 	session, err := ar.sessionManager.LoadSession(sessionID)
 	if err != nil {
 		return fmt.Errorf("failed to load session: %w", err)
@@ -636,10 +1113,65 @@ func (ar *AgentRunner) PauseSession() error {
 		return fmt.Errorf("no active session to pause")
 	}
 
+	ar.sessionMu.Lock()
+	defer ar.sessionMu.Unlock()
 	ar.sessionManager.UpdateSessionState(ar.currentSession, "paused")
 	return ar.sessionManager.SaveSession(ar.currentSession)
 }
 
+// steeringPrefix marks user messages injected into a running conversation
+const steeringPrefix = "[Sent by the user while you were working] "
+
+// Steer queues a message from the user for the in-progress run; it is added
+// to the conversation before the next LLM call. Messages that arrive after the
+// final LLM call are returned in RunResult.UnusedSteering. Safe to call
+// concurrently with Run.
+func (ar *AgentRunner) Steer(message string) {
+	ar.steerMu.Lock()
+	defer ar.steerMu.Unlock()
+	ar.steering = append(ar.steering, message)
+}
+
+// takeSteering returns and clears the queued steering messages
+func (ar *AgentRunner) takeSteering() []string {
+	ar.steerMu.Lock()
+	defer ar.steerMu.Unlock()
+	steering := ar.steering
+	ar.steering = nil
+	return steering
+}
+
+// setRunIterations records how many iterations the current run has started
+func (ar *AgentRunner) setRunIterations(iterations int) {
+	ar.delegationMu.Lock()
+	ar.runIterations = iterations
+	ar.delegationMu.Unlock()
+}
+
+// dropSteering discards steering messages no run will incorporate
+func (ar *AgentRunner) dropSteering(ctx context.Context) {
+	if dropped := ar.takeSteering(); len(dropped) > 0 {
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Warn("Dropping steering messages sent to a run that failed", "count", len(dropped))
+	}
+}
+
+// pauseCancelledSession marks the session as paused when the run was cancelled
+// by the caller (rather than timing out), so it can be resumed with a
+// corrective instruction
+func (ar *AgentRunner) pauseCancelledSession(ctx context.Context) {
+	if ar.currentSession == nil || !errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	if err := ar.PauseSession(); err != nil {
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Warn("Failed to pause cancelled session", "error", err)
+		return
+	}
+	// Runs whose tool call outlasted the grace period of a shutdown
+	if _, ok := shutdownSignal(ctx); ok {
+		ShutdownFromContext(ctx).recordPaused(PausedSession{SessionID: ar.currentSession.SessionID, Command: ar.currentSession.Command})
+	}
+}
+
 // GetCurrentSessionID returns the current session ID
 func (ar *AgentRunner) GetCurrentSessionID() string {
 	if ar.currentSession != nil {
@@ -676,6 +1208,12 @@ func (ar *AgentRunner) shouldRetryToolCall(toolResult *agent.ToolResult, retryCo
 		return false
 	}
 
+	// Refusals such as protected files fail the same way however the call is
+	// retried
+	if toolResult.StandardizedError != nil && toolResult.StandardizedError.Permanent() {
+		return false
+	}
+
 	if ar.config.RetryWithModification && toolResult.StandardizedError != nil {
 		return true
 	}
@@ -10,6 +10,7 @@ import (
 	"github.com/castrovroberto/CGE/internal/agent"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
 	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
 )
 
 // EnhancedAgentRunner manages the orchestration between LLM and tools with enhanced error handling
@@ -73,7 +74,7 @@ func (ar *EnhancedAgentRunner) Run(ctx context.Context, initialPrompt string) (*
 
 // RunWithCommand executes the agent orchestration loop with command tracking and enhanced error handling
 func (ar *EnhancedAgentRunner) RunWithCommand(ctx context.Context, initialPrompt string, command string) (*RunResult, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
 
 	// Apply timeout from configuration
 	if ar.config.TimeoutSeconds > 0 {
@@ -90,7 +91,7 @@ func (ar *EnhancedAgentRunner) RunWithCommand(ctx context.Context, initialPrompt
 
 	// Initialize message history
 	messages := []Message{
-		{Role: "system", Content: ar.systemPrompt},
+		{Role: "system", Content: ar.systemPrompt + "\n\n" + completionInstructions},
 		{Role: "user", Content: initialPrompt},
 	}
 
@@ -107,6 +108,7 @@ func (ar *EnhancedAgentRunner) RunWithCommand(ctx context.Context, initialPrompt
 	totalRetries := 0
 	iterations := 0
 	errorDetails := make([]string, 0)
+	reminded := false // Whether the model was reminded to finish since its last tool call
 
 	log.Info("Starting enhanced agent orchestration", "max_iterations", ar.maxIterations, "session_id", func() string {
 		if ar.currentSession != nil {
@@ -121,14 +123,14 @@ func (ar *EnhancedAgentRunner) RunWithCommand(ctx context.Context, initialPrompt
 		log.Debug("Agent iteration", "iteration", iterations)
 
 		// Prepare tool definitions
-		tools := ar.prepareToolDefinitions()
+		tools := append(ar.prepareToolDefinitions(), finishToolDefinition())
 
 		// Call LLM with function calling support
 		response, err := ar.llmClient.GenerateWithFunctions(
 			ctx,
 			ar.model,
 			ar.buildPromptFromMessages(messages),
-			"", // System prompt already in messages
+			systemPromptOf(messages),
 			tools,
 		)
 		if err != nil {
@@ -161,7 +163,7 @@ func (ar *EnhancedAgentRunner) RunWithCommand(ctx context.Context, initialPrompt
 			}
 
 			// Check if this should be treated as final
-			if ar.isFinalAnswer(response.TextContent, iterations) {
+			if ar.isFinalAnswer(response.TextContent, iterations, tools) {
 				log.Info("Agent completed with text response", "iterations", iterations, "tool_calls", toolCalls)
 				return &RunResult{
 					FinalResponse: response.TextContent,
@@ -173,10 +175,17 @@ func (ar *EnhancedAgentRunner) RunWithCommand(ctx context.Context, initialPrompt
 					ErrorDetails:  errorDetails,
 				}, nil
 			}
+			// Rather than wait for a reply that looks final, remind the
+			// model how to finish
+			if !reminded {
+				reminded = true
+				messages = append(messages, Message{Role: "user", Content: finishReminder})
+			}
 		} else {
 			// Function call - handle with enhanced error retry logic
 			functionCall := response.FunctionCall
 			callSignature := ar.getToolCallSignature(functionCall)
+			reminded = false
 
 			// Add function call message
 			callMessage := Message{
@@ -186,6 +195,34 @@ func (ar *EnhancedAgentRunner) RunWithCommand(ctx context.Context, initialPrompt
 			}
 			messages = append(messages, callMessage)
 
+			// The model ends the run with its final answer
+			if functionCall.Name == finishToolName {
+				answer, success, err := parseFinish(functionCall)
+				if err != nil {
+					messages = append(messages, Message{Role: "tool", ToolCallID: functionCall.ID, Name: finishToolName, Content: fmt.Sprintf("Error: %v", err)})
+					continue
+				}
+				messages = append(messages, Message{Role: "tool", ToolCallID: functionCall.ID, Name: finishToolName, Content: "Run finished."})
+				if ar.currentSession != nil {
+					ar.currentSession.Messages = messages
+					ar.sessionManager.SaveSession(ar.currentSession)
+				}
+				log.Info("Agent finished", "iterations", iterations, "tool_calls", toolCalls, "success", success)
+				result := &RunResult{
+					FinalResponse: answer,
+					Messages:      messages,
+					ToolCalls:     toolCalls,
+					Iterations:    iterations,
+					Success:       success,
+					ToolRetries:   totalRetries,
+					ErrorDetails:  errorDetails,
+				}
+				if !success {
+					result.Error = "the agent could not complete the task"
+				}
+				return result, nil
+			}
+
 			// Track the attempt
 			attempt := ToolCallAttempt{
 				ToolName:   functionCall.Name,
@@ -195,7 +232,11 @@ func (ar *EnhancedAgentRunner) RunWithCommand(ctx context.Context, initialPrompt
 			}
 
 			// Execute tool with enhanced error handling
+			started := time.Now()
 			toolResult, executionErr := ar.executeTool(ctx, functionCall)
+			if ar.currentSession != nil {
+				ar.sessionManager.AddToolCall(ar.currentSession, newToolCallRecord(functionCall, iterations, started, toolResult, executionErr))
+			}
 			if executionErr != nil {
 				// Internal execution error (tool not found, etc.)
 				errorMsg := fmt.Sprintf("Tool execution error: %v", executionErr)
@@ -398,12 +439,14 @@ func (ar *EnhancedAgentRunner) buildPromptFromMessages(messages []Message) strin
 }
 
 // executeTool executes a function call using the tool registry
-func (ar *EnhancedAgentRunner) executeTool(ctx context.Context, functionCall *llm.FunctionCall) (*agent.ToolResult, error) {
+func (ar *EnhancedAgentRunner) executeTool(ctx context.Context, functionCall *llm.FunctionCall) (result *agent.ToolResult, err error) {
 	// Look up tool in registry
 	tool, exists := ar.toolRegistry.Get(functionCall.Name)
 	if !exists {
 		return nil, fmt.Errorf("tool not found: %s", functionCall.Name)
 	}
+	start := time.Now()
+	defer func() { ar.toolRegistry.RecordCall(functionCall.Name, time.Since(start), result, err) }()
 
 	// Validate parameters (basic JSON validation)
 	var params map[string]interface{}
@@ -411,13 +454,19 @@ func (ar *EnhancedAgentRunner) executeTool(ctx context.Context, functionCall *ll
 		return nil, fmt.Errorf("invalid tool parameters: %v", err)
 	}
 
+	// Arguments must match the tool's schema; every offending field is
+	// reported, so a retry can fix them all
+	if invalid := agent.ValidateArguments(functionCall.Arguments, tool.Parameters()); invalid != nil {
+		return agent.NewErrorResult(invalid), nil
+	}
+
 	// Execute tool with timeout
 	toolCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
-	result, err := tool.Execute(toolCtx, functionCall.Arguments)
+	result, err = tool.Execute(toolCtx, functionCall.Arguments)
 	if err != nil {
-		return nil, fmt.Errorf("tool execution error: %v", err)
+		return nil, fmt.Errorf("tool execution error: %w", err)
 	}
 
 	return result, nil
@@ -443,39 +492,12 @@ func (ar *EnhancedAgentRunner) formatToolResult(result *agent.ToolResult) string
 	return "Tool executed successfully"
 }
 
-// isFinalAnswer determines if a text response should be treated as final
-func (ar *EnhancedAgentRunner) isFinalAnswer(content string, iteration int) bool {
-	content = strings.ToLower(strings.TrimSpace(content))
-
-	// If we're at max iterations, treat any text as final
-	if iteration >= ar.maxIterations {
-		return true
-	}
-
-	// Look for indicators that this is a final answer
-	finalIndicators := []string{
-		"task completed",
-		"finished",
-		"done",
-		"complete",
-		"successfully",
-		"final result",
-		"conclusion",
-		"summary",
-	}
-
-	for _, indicator := range finalIndicators {
-		if strings.Contains(content, indicator) {
-			return true
-		}
-	}
-
-	// If the response is substantial (more than 100 chars) and doesn't seem to be asking for more tools
-	if len(content) > 100 && !strings.Contains(content, "need to") && !strings.Contains(content, "should") {
-		return true
-	}
-
-	return false
+// isFinalAnswer determines if a reply without a tool call ends the run. Runs
+// end with finish; for models that reply instead, a reply is final on the
+// last iteration, when there are no tools besides finish, or when it looks
+// final.
+func (ar *EnhancedAgentRunner) isFinalAnswer(content string, iteration int, tools []llm.ToolDefinition) bool {
+	return iteration >= ar.maxIterations || len(tools) <= 1 || looksFinal(content)
 }
 
 // Enhanced error handling methods
@@ -526,6 +548,9 @@ func (ar *EnhancedAgentRunner) shouldRetryToolCall(toolResult *agent.ToolResult,
 	// Don't retry certain types of errors
 	if toolResult.StandardizedError != nil {
 		errorCode := toolResult.StandardizedError.Code
+		if toolResult.StandardizedError.Permanent() {
+			return false
+		}
 
 		// Don't retry these error types as they're unlikely to be fixed by retry
 		nonRetriableErrors := []agent.ToolErrorCode{
@@ -3,8 +3,22 @@ package orchestrator
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
 	"sort"
+	"strings"
 	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// Bounds of the change frequency sections of a report
+const (
+	maxFileHotspots     = 20 // Files listed, most edited first
+	maxDirectoryChanges = 15 // Directories listed, most edited first
+	hotspotSessions     = 3  // Sessions changing a file that make it a hotspot
 )
 
 // SessionAnalytics provides analytics and insights for sessions
@@ -27,8 +41,31 @@ type AnalyticsReport struct {
 	SessionsByState   map[string]int   `json:"sessions_by_state"`
 	ToolUsageStats    []ToolUsageStat  `json:"tool_usage_stats"`
 	PerformanceStats  PerformanceStats `json:"performance_stats"`
+	RoutingStats      RoutingStats     `json:"routing_stats"`
 	RecentSessions    []SessionSummary `json:"recent_sessions"`
 	Insights          []string         `json:"insights"`
+
+	// FileHotspots are the files the agent changed most across sessions, and
+	// DirectoryChanges the directories, most edited first
+	FileHotspots     []FileHotspot         `json:"file_hotspots"`
+	DirectoryChanges []DirectoryChangeStat `json:"directory_changes"`
+}
+
+// FileHotspot counts the changes the agent made to a file across sessions
+type FileHotspot struct {
+	Path     string `json:"path"`     // Relative to the workspace, with forward slashes
+	Edits    int    `json:"edits"`    // Successful tool calls changing the file
+	Sessions int    `json:"sessions"` // Sessions that changed it
+	Untested bool   `json:"untested"` // No test file was found next to it
+}
+
+// DirectoryChangeStat counts the changes the agent made to the files of a
+// directory, not those of its subdirectories, across sessions
+type DirectoryChangeStat struct {
+	Path     string `json:"path"` // Relative to the workspace; "." for its root
+	Edits    int    `json:"edits"`
+	Files    int    `json:"files"`    // Distinct files changed
+	Sessions int    `json:"sessions"` // Sessions that changed them
 }
 
 // ToolUsageStat represents statistics for a specific tool
@@ -40,6 +77,26 @@ type ToolUsageStat struct {
 	SuccessRate     float64       `json:"success_rate"`
 	AverageDuration time.Duration `json:"average_duration"`
 	TotalDuration   time.Duration `json:"total_duration"`
+	MaxDuration     time.Duration `json:"max_duration"`
+
+	// RuntimeShare is the percentage of the time spent in tool calls that
+	// was spent in this tool
+	RuntimeShare float64 `json:"runtime_share"`
+
+	// ErrorCodes counts the failed calls by error code
+	ErrorCodes map[string]int `json:"error_codes,omitempty"`
+}
+
+// TopErrorCode returns the most frequent error code of the tool's failed
+// calls, or an empty string when none failed
+func (s ToolUsageStat) TopErrorCode() string {
+	top := ""
+	for code, count := range s.ErrorCodes {
+		if count > s.ErrorCodes[top] || (count == s.ErrorCodes[top] && code < top) {
+			top = code
+		}
+	}
+	return top
 }
 
 // PerformanceStats represents overall performance statistics
@@ -52,6 +109,14 @@ type PerformanceStats struct {
 	OverallSuccessRate         float64       `json:"overall_success_rate"`
 }
 
+// RoutingStats sums up the steps tried on a small model across sessions
+type RoutingStats struct {
+	Tried             int            `json:"tried"`              // Steps sent to the small model
+	AnsweredBySmall   int            `json:"answered_by_small"`  // Steps whose small model answer was used
+	Escalated         int            `json:"escalated"`          // Steps the configured model answered instead
+	EscalationReasons map[string]int `json:"escalation_reasons"` // Escalated steps by reason
+}
+
 // SessionSummary represents a summary of a session
 type SessionSummary struct {
 	SessionID string        `json:"session_id"`
@@ -76,9 +141,13 @@ func (sa *SessionAnalytics) GenerateReport() (*AnalyticsReport, error) {
 		SessionsByCommand: make(map[string]int),
 		SessionsByState:   make(map[string]int),
 		ToolUsageStats:    []ToolUsageStat{},
+		RoutingStats:      RoutingStats{EscalationReasons: make(map[string]int)},
 		RecentSessions:    []SessionSummary{},
 		Insights:          []string{},
+		FileHotspots:      []FileHotspot{},
+		DirectoryChanges:  []DirectoryChangeStat{},
 	}
+	changes := newChangeFrequency()
 
 	// Collect data from all sessions
 	var allSessions []*SessionState
@@ -86,6 +155,7 @@ func (sa *SessionAnalytics) GenerateReport() (*AnalyticsReport, error) {
 	var totalDuration time.Duration
 	var completedSessions int
 	var totalToolCalls int
+	var totalToolDuration time.Duration
 	var totalMessages int
 	var successfulSessions int
 
@@ -122,18 +192,43 @@ func (sa *SessionAnalytics) GenerateReport() (*AnalyticsReport, error) {
 		for _, toolCall := range session.ToolCalls {
 			if toolStats[toolCall.ToolName] == nil {
 				toolStats[toolCall.ToolName] = &ToolUsageStat{
-					ToolName: toolCall.ToolName,
+					ToolName:   toolCall.ToolName,
+					ErrorCodes: make(map[string]int),
 				}
 			}
 
 			stat := toolStats[toolCall.ToolName]
 			stat.TotalCalls++
 			stat.TotalDuration += toolCall.Duration
+			totalToolDuration += toolCall.Duration
+			if toolCall.Duration > stat.MaxDuration {
+				stat.MaxDuration = toolCall.Duration
+			}
 
 			if toolCall.Success {
 				stat.SuccessfulCalls++
 			} else {
 				stat.FailedCalls++
+				code := toolCall.ErrorCode
+				if code == "" {
+					// Recorded before error codes were kept
+					code = string(agent.ErrorCodeUnclassified)
+				}
+				stat.ErrorCodes[code]++
+			}
+		}
+
+		// Count the files each session changed
+		changes.addSession(session)
+
+		// Process routing decisions
+		for _, decision := range session.Routing {
+			report.RoutingStats.Tried++
+			if decision.Escalated {
+				report.RoutingStats.Escalated++
+				report.RoutingStats.EscalationReasons[decision.Reason]++
+			} else {
+				report.RoutingStats.AnsweredBySmall++
 			}
 		}
 
@@ -155,6 +250,9 @@ func (sa *SessionAnalytics) GenerateReport() (*AnalyticsReport, error) {
 			stat.SuccessRate = float64(stat.SuccessfulCalls) / float64(stat.TotalCalls) * 100
 			stat.AverageDuration = stat.TotalDuration / time.Duration(stat.TotalCalls)
 		}
+		if totalToolDuration > 0 {
+			stat.RuntimeShare = float64(stat.TotalDuration) / float64(totalToolDuration) * 100
+		}
 		report.ToolUsageStats = append(report.ToolUsageStats, *stat)
 	}
 
@@ -184,6 +282,9 @@ func (sa *SessionAnalytics) GenerateReport() (*AnalyticsReport, error) {
 		report.PerformanceStats.AverageSessionDuration = totalDuration / time.Duration(completedSessions)
 	}
 
+	report.FileHotspots = changes.fileHotspots(sa.sessionManager.workspaceRoot)
+	report.DirectoryChanges = changes.directoryChanges()
+
 	// Generate insights
 	report.Insights = sa.generateInsights(report)
 
@@ -210,10 +311,24 @@ func (sa *SessionAnalytics) generateInsights(report *AnalyticsReport) []string {
 		// Find tools with low success rates
 		for _, tool := range report.ToolUsageStats {
 			if tool.TotalCalls >= 5 && tool.SuccessRate < 60 {
-				insights = append(insights, fmt.Sprintf("⚠️  Tool '%s' has low success rate (%.1f%%) - may need attention",
-					tool.ToolName, tool.SuccessRate))
+				insights = append(insights, fmt.Sprintf("⚠️  Tool '%s' has low success rate (%.1f%%), failing most with %s - may need attention",
+					tool.ToolName, tool.SuccessRate, tool.TopErrorCode()))
 			}
 		}
+
+		// Find a tool dominating the time spent in tools
+		for _, tool := range report.ToolUsageStats {
+			if len(report.ToolUsageStats) > 1 && tool.RuntimeShare >= 50 {
+				insights = append(insights, fmt.Sprintf("⏱️  Tool '%s' takes %.1f%% of the time spent in tools (avg %.2fs, max %.2fs)",
+					tool.ToolName, tool.RuntimeShare, tool.AverageDuration.Seconds(), tool.MaxDuration.Seconds()))
+			}
+		}
+	}
+
+	// Routing insights
+	if routing := report.RoutingStats; routing.Tried > 0 {
+		insights = append(insights, fmt.Sprintf("🪶 Small model answered %d of %d routed steps (%.1f%%)",
+			routing.AnsweredBySmall, routing.Tried, float64(routing.AnsweredBySmall)/float64(routing.Tried)*100))
 	}
 
 	// Session duration insights
@@ -235,6 +350,34 @@ func (sa *SessionAnalytics) generateInsights(report *AnalyticsReport) []string {
 		insights = append(insights, fmt.Sprintf("📊 Most used command: %s (%d sessions)", mostUsedCommand, maxCount))
 	}
 
+	// Change frequency insights: files the agent keeps coming back to are
+	// worth a convention in the project rules, or tests when they have none
+	suggested := 0
+	for _, hotspot := range report.FileHotspots {
+		if hotspot.Sessions < hotspotSessions || suggested == 3 {
+			continue
+		}
+		suggested++
+		if hotspot.Untested {
+			insights = append(insights, fmt.Sprintf("🔥 %s was changed in %d sessions and has no tests. Consider adding tests for it.", hotspot.Path, hotspot.Sessions))
+		} else {
+			insights = append(insights, fmt.Sprintf("🔥 %s was changed in %d sessions. Consider documenting its conventions in .cge/rules.md.", hotspot.Path, hotspot.Sessions))
+		}
+	}
+	if len(report.DirectoryChanges) > 1 {
+		total := 0
+		for _, dir := range report.DirectoryChanges {
+			total += dir.Edits
+		}
+		if top := report.DirectoryChanges[0]; total >= 10 && top.Edits*2 >= total {
+			name := top.Path + "/"
+			if top.Path == "." {
+				name = "The workspace root"
+			}
+			insights = append(insights, fmt.Sprintf("📁 %s takes %.0f%% of the listed file changes (%d edits in %d sessions)", name, float64(top.Edits)/float64(total)*100, top.Edits, top.Sessions))
+		}
+	}
+
 	// State distribution insights
 	if paused, exists := report.SessionsByState["paused"]; exists && paused > 0 {
 		insights = append(insights, fmt.Sprintf("⏸️  %d paused sessions found. Consider resuming or cleaning up.", paused))
@@ -247,6 +390,159 @@ func (sa *SessionAnalytics) generateInsights(report *AnalyticsReport) []string {
 	return insights
 }
 
+// changeFrequency counts the file changes of sessions by file and directory
+type changeFrequency struct {
+	files map[string]*fileChanges
+}
+
+// fileChanges are the changes to a file
+type fileChanges struct {
+	edits    int
+	sessions map[string]bool
+}
+
+// newChangeFrequency returns an empty changeFrequency
+func newChangeFrequency() *changeFrequency {
+	return &changeFrequency{files: make(map[string]*fileChanges)}
+}
+
+// addSession counts the files changed by the tool calls of session
+func (c *changeFrequency) addSession(session *SessionState) {
+	for _, call := range session.ToolCalls {
+		files := call.Files
+		if files == nil && call.Success {
+			// Recorded before the changed files were kept
+			files = changedFiles(&llm.FunctionCall{Name: call.ToolName, Arguments: call.Parameters}, nil)
+		}
+		for _, file := range files {
+			rel, ok := workspaceRelative(session.WorkspaceRoot, file)
+			if !ok {
+				continue
+			}
+			changes := c.files[rel]
+			if changes == nil {
+				changes = &fileChanges{sessions: make(map[string]bool)}
+				c.files[rel] = changes
+			}
+			changes.edits++
+			changes.sessions[session.SessionID] = true
+		}
+	}
+}
+
+// fileHotspots returns the most changed files, telling which have no tests
+// in the workspace at workspaceRoot
+func (c *changeFrequency) fileHotspots(workspaceRoot string) []FileHotspot {
+	hotspots := []FileHotspot{}
+	for file, changes := range c.files {
+		hotspots = append(hotspots, FileHotspot{Path: file, Edits: changes.edits, Sessions: len(changes.sessions)})
+	}
+	sort.Slice(hotspots, func(i, j int) bool {
+		a, b := hotspots[i], hotspots[j]
+		if a.Sessions != b.Sessions {
+			return a.Sessions > b.Sessions
+		}
+		if a.Edits != b.Edits {
+			return a.Edits > b.Edits
+		}
+		return a.Path < b.Path
+	})
+	if len(hotspots) > maxFileHotspots {
+		hotspots = hotspots[:maxFileHotspots]
+	}
+	for i := range hotspots {
+		hotspots[i].Untested = untested(workspaceRoot, hotspots[i].Path)
+	}
+	return hotspots
+}
+
+// directoryChanges returns the directories whose files were changed most
+func (c *changeFrequency) directoryChanges() []DirectoryChangeStat {
+	byDir := make(map[string]*DirectoryChangeStat)
+	sessions := make(map[string]map[string]bool)
+	for file, changes := range c.files {
+		dir := path.Dir(file)
+		stat := byDir[dir]
+		if stat == nil {
+			stat = &DirectoryChangeStat{Path: dir}
+			byDir[dir] = stat
+			sessions[dir] = make(map[string]bool)
+		}
+		stat.Edits += changes.edits
+		stat.Files++
+		for id := range changes.sessions {
+			sessions[dir][id] = true
+		}
+	}
+	dirs := []DirectoryChangeStat{}
+	for dir, stat := range byDir {
+		stat.Sessions = len(sessions[dir])
+		dirs = append(dirs, *stat)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Edits != dirs[j].Edits {
+			return dirs[i].Edits > dirs[j].Edits
+		}
+		return dirs[i].Path < dirs[j].Path
+	})
+	if len(dirs) > maxDirectoryChanges {
+		dirs = dirs[:maxDirectoryChanges]
+	}
+	return dirs
+}
+
+// workspaceRelative returns file, as a tool named it, relative to the
+// workspace with forward slashes, or false when it is outside the workspace
+func workspaceRelative(workspaceRoot, file string) (string, bool) {
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(workspaceRoot, file)
+		if err != nil {
+			return "", false
+		}
+		file = rel
+	}
+	file = path.Clean(filepath.ToSlash(file))
+	if file == "." || file == ".." || strings.HasPrefix(file, "../") {
+		return "", false
+	}
+	return file, true
+}
+
+// untested reports whether file, relative to the workspace, is source code
+// in a language whose test file naming is known and has no test file next
+// to it. Test files themselves, and files in other languages, are not.
+func untested(workspaceRoot, file string) bool {
+	dir, base := path.Split(file)
+	ext := path.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	var candidates []string
+	switch ext {
+	case ".go":
+		if strings.HasSuffix(name, "_test") {
+			return false
+		}
+		candidates = []string{name + "_test.go"}
+	case ".js", ".jsx", ".ts", ".tsx":
+		if strings.HasSuffix(name, ".test") || strings.HasSuffix(name, ".spec") {
+			return false
+		}
+		candidates = []string{name + ".test" + ext, name + ".spec" + ext, "__tests__/" + name + ".test" + ext}
+	case ".py":
+		if strings.HasPrefix(name, "test_") || strings.HasSuffix(name, "_test") {
+			return false
+		}
+		candidates = []string{"test_" + name + ".py", name + "_test.py", "tests/test_" + name + ".py"}
+	default:
+		return false
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(filepath.Join(workspaceRoot, filepath.FromSlash(dir+candidate))); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
 // GetToolPerformanceReport generates a detailed report for a specific tool
 func (sa *SessionAnalytics) GetToolPerformanceReport(toolName string) (*ToolPerformanceReport, error) {
 	sessions, err := sa.sessionManager.ListSessions()
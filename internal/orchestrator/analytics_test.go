@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedFiles(t *testing.T) {
+	write := &llm.FunctionCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path":"internal/auth/handler.go"}`)}
+	assert.Equal(t, []string{"internal/auth/handler.go"}, newToolCallRecord(write, 1, time.Now(), agent.NewSuccessResult(nil), nil).Files)
+	assert.Nil(t, newToolCallRecord(write, 1, time.Now(), &agent.ToolResult{Success: false}, nil).Files, "failed calls change nothing")
+
+	replace := &llm.FunctionCall{Name: "search_replace", Arguments: json.RawMessage(`{"pattern":"old","replacement":"new"}`)}
+	result := agent.NewSuccessResult(map[string]interface{}{"changes": []agent.FileReplacement{{FilePath: "a.go"}, {FilePath: "b/c.go"}}})
+	assert.Equal(t, []string{"a.go", "b/c.go"}, newToolCallRecord(replace, 1, time.Now(), result, nil).Files)
+
+	read := &llm.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"file_path":"a.go"}`)}
+	assert.Nil(t, newToolCallRecord(read, 1, time.Now(), agent.NewSuccessResult(nil), nil).Files)
+}
+
+func TestChangeFrequency(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "internal", "auth"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "internal", "auth", "token_test.go"), nil, 0o644))
+	sm, err := NewSessionManager(workspace, nil)
+	require.NoError(t, err)
+
+	edit := func(files ...string) ToolCallRecord {
+		return ToolCallRecord{ToolName: "write_file", Success: true, Files: files}
+	}
+	for i := 0; i < 3; i++ {
+		session := sm.CreateSession("system", "model", "generate", DefaultRunConfig())
+		session.ToolCalls = []ToolCallRecord{
+			edit("internal/auth/handler.go"),
+			edit(filepath.Join(workspace, "internal", "auth", "token.go")),
+		}
+		if i == 0 {
+			session.ToolCalls = append(session.ToolCalls,
+				edit("internal/auth/handler.go"),
+				edit("/elsewhere/outside.go"),
+				// Recorded before changed files were kept
+				ToolCallRecord{ToolName: "modify_file", Success: true, Parameters: json.RawMessage(`{"file_path":"README.md"}`)},
+			)
+		}
+		require.NoError(t, sm.SaveSession(session))
+	}
+
+	report, err := NewSessionAnalytics(sm).GenerateReport()
+	require.NoError(t, err)
+	assert.Equal(t, []FileHotspot{
+		{Path: "internal/auth/handler.go", Edits: 4, Sessions: 3, Untested: true},
+		{Path: "internal/auth/token.go", Edits: 3, Sessions: 3},
+		{Path: "README.md", Edits: 1, Sessions: 1},
+	}, report.FileHotspots)
+	assert.Equal(t, []DirectoryChangeStat{
+		{Path: "internal/auth", Edits: 7, Files: 2, Sessions: 3},
+		{Path: ".", Edits: 1, Files: 1, Sessions: 1},
+	}, report.DirectoryChanges)
+	assert.Contains(t, report.Insights, "🔥 internal/auth/handler.go was changed in 3 sessions and has no tests. Consider adding tests for it.")
+	assert.Contains(t, report.Insights, "🔥 internal/auth/token.go was changed in 3 sessions. Consider documenting its conventions in .cge/rules.md.")
+}
@@ -0,0 +1,268 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// Checkpoint names a point in a workflow where changes wait for approval
+type Checkpoint string
+
+const (
+	CheckpointPlan       Checkpoint = "plan"        // After the fixes to make are planned
+	CheckpointFileChange Checkpoint = "file_change" // Before each file is changed
+	CheckpointCommand    Checkpoint = "command"     // Before a shell command or code snippet runs
+	CheckpointCommit     Checkpoint = "commit"      // Before changes are committed
+)
+
+// Checkpoints lists every checkpoint, in workflow order
+var Checkpoints = []Checkpoint{CheckpointPlan, CheckpointFileChange, CheckpointCommand, CheckpointCommit}
+
+// Decision is the outcome of a checkpoint
+type Decision string
+
+const (
+	DecisionApprove Decision = "approve"
+	DecisionReject  Decision = "reject"
+	DecisionEdit    Decision = "edit" // Approved with content changed by the user
+)
+
+// Who decided a checkpoint
+const (
+	DecidedByUser   = "user"
+	DecidedByPolicy = "policy"
+)
+
+// ApprovalRequest describes what waits at a checkpoint
+type ApprovalRequest struct {
+	Checkpoint Checkpoint
+	Subject    string // What is being approved, e.g. a file path or "fix plan"
+	Summary    string // Shown to the user before deciding, e.g. a diff
+	Content    string // What an edit changes: the plan, new file content or commit message
+}
+
+// ApprovalDecision records how a checkpoint was decided
+type ApprovalDecision struct {
+	Checkpoint Checkpoint `json:"checkpoint"`
+	Subject    string     `json:"subject"`
+	Decision   Decision   `json:"decision"`
+	DecidedBy  string     `json:"decided_by"` // DecidedByUser or DecidedByPolicy
+	Reason     string     `json:"reason,omitempty"`
+	Timestamp  time.Time  `json:"timestamp"`
+	Content    string     `json:"-"` // The edited content when Decision is DecisionEdit
+}
+
+// Approved reports whether the change may go ahead, possibly edited
+func (d *ApprovalDecision) Approved() bool {
+	return d.Decision == DecisionApprove || d.Decision == DecisionEdit
+}
+
+// Approver asks a user to decide a checkpoint. Content is the edited
+// content when the decision is DecisionEdit.
+type Approver interface {
+	Approve(ctx context.Context, req ApprovalRequest) (decision Decision, content string, reason string, err error)
+}
+
+// ApprovalGate decides the enabled checkpoints of a run, asking the approver
+// when there is one (interactive runs) and applying policies otherwise. Every
+// decision is kept, to be recorded in the session.
+type ApprovalGate struct {
+	enabled  map[Checkpoint]bool
+	policies map[Checkpoint]Decision
+	approver Approver
+
+	mu        sync.Mutex
+	decisions []ApprovalDecision
+}
+
+// NewApprovalGate creates a gate stopping at checkpoints. Without an
+// approver, each checkpoint is decided by its policy, which is approve or
+// reject; checkpoints without a policy are approved.
+func NewApprovalGate(checkpoints []Checkpoint, policies map[Checkpoint]Decision, approver Approver) *ApprovalGate {
+	enabled := make(map[Checkpoint]bool, len(checkpoints))
+	for _, cp := range checkpoints {
+		enabled[cp] = true
+	}
+	return &ApprovalGate{enabled: enabled, policies: policies, approver: approver}
+}
+
+// ParseCheckpoints parses checkpoint names; "none" alone disables them all
+func ParseCheckpoints(names []string) ([]Checkpoint, error) {
+	if len(names) == 1 && names[0] == "none" {
+		return nil, nil
+	}
+	var checkpoints []Checkpoint
+	for _, name := range names {
+		cp := Checkpoint(strings.TrimSpace(name))
+		if !isCheckpoint(cp) {
+			return nil, fmt.Errorf("unknown checkpoint %q (want plan, file_change, command, commit or none)", name)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, nil
+}
+
+// ParsePolicies parses the decisions of non-interactive runs by checkpoint
+func ParsePolicies(policies map[string]string) (map[Checkpoint]Decision, error) {
+	parsed := make(map[Checkpoint]Decision, len(policies))
+	for name, value := range policies {
+		cp := Checkpoint(name)
+		if !isCheckpoint(cp) {
+			return nil, fmt.Errorf("unknown checkpoint %q in approval policies", name)
+		}
+		switch decision := Decision(value); decision {
+		case DecisionApprove, DecisionReject:
+			parsed[cp] = decision
+		default:
+			return nil, fmt.Errorf("invalid approval policy %q for %s (want approve or reject)", value, name)
+		}
+	}
+	return parsed, nil
+}
+
+func isCheckpoint(cp Checkpoint) bool {
+	for _, known := range Checkpoints {
+		if cp == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled reports whether the gate stops at checkpoint
+func (g *ApprovalGate) Enabled(checkpoint Checkpoint) bool {
+	return g != nil && g.enabled[checkpoint]
+}
+
+// Check decides req. Checkpoints that are not enabled are approved without
+// a record; a nil gate approves everything.
+func (g *ApprovalGate) Check(ctx context.Context, req ApprovalRequest) (*ApprovalDecision, error) {
+	if !g.Enabled(req.Checkpoint) {
+		return &ApprovalDecision{Checkpoint: req.Checkpoint, Subject: req.Subject, Decision: DecisionApprove, Timestamp: time.Now()}, nil
+	}
+
+	decision := ApprovalDecision{Checkpoint: req.Checkpoint, Subject: req.Subject, Timestamp: time.Now()}
+	if g.approver != nil {
+		d, content, reason, err := g.approver.Approve(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("approval at %s checkpoint failed: %w", req.Checkpoint, err)
+		}
+		decision.Decision, decision.Content, decision.Reason, decision.DecidedBy = d, content, reason, DecidedByUser
+	} else {
+		decision.Decision, decision.DecidedBy = DecisionApprove, DecidedByPolicy
+		if policy, ok := g.policies[req.Checkpoint]; ok {
+			decision.Decision = policy
+		}
+		decision.Reason = fmt.Sprintf("%s policy for non-interactive runs", req.Checkpoint)
+	}
+
+	g.mu.Lock()
+	g.decisions = append(g.decisions, decision)
+	g.mu.Unlock()
+	return &decision, nil
+}
+
+// Decisions returns the decisions made so far, oldest first
+func (g *ApprovalGate) Decisions() []ApprovalDecision {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]ApprovalDecision(nil), g.decisions...)
+}
+
+// toolCheckpoint returns the checkpoint gating a tool call and what it
+// changes, or false for tools that change nothing. Dry runs are not gated.
+func toolCheckpoint(call *llm.FunctionCall) (Checkpoint, string, bool) {
+	var params struct {
+		FilePath string `json:"file_path"`
+		Path     string `json:"path"`
+		Command  string `json:"command"`
+		Language string `json:"language"`
+		Message  string `json:"commit_message"`
+		DryRun   bool   `json:"dry_run"`
+	}
+	_ = json.Unmarshal(call.Arguments, &params)
+	if params.DryRun {
+		return "", "", false
+	}
+	switch call.Name {
+	case "write_file", "modify_file", "apply_patch_to_file", "apply_patch_to_file_enhanced":
+		return CheckpointFileChange, params.FilePath, true
+	case "search_replace":
+		subject := params.Path
+		if subject == "" {
+			subject = "files matching the search"
+		}
+		return CheckpointFileChange, subject, true
+	case "run_shell_command":
+		return CheckpointCommand, params.Command, true
+	case "run_snippet":
+		return CheckpointCommand, params.Language + " snippet", true
+	case "git_commit", "git_commit_enhanced":
+		return CheckpointCommit, params.Message, true
+	}
+	return "", "", false
+}
+
+// approveToolCall passes a file-changing or committing tool call through the
+// approval gate. It returns the result to report instead of running the tool
+// when the call is rejected, and updates the call's arguments when the user
+// edited them.
+func (ar *AgentRunner) approveToolCall(ctx context.Context, call *llm.FunctionCall) (*agent.ToolResult, error) {
+	checkpoint, subject, ok := toolCheckpoint(call)
+	if !ok || !ar.approvalGate.Enabled(checkpoint) {
+		return nil, nil
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, call.Arguments, "", "  "); err != nil {
+		pretty.Reset()
+		pretty.Write(call.Arguments)
+	}
+	decision, err := ar.approvalGate.Check(ctx, ApprovalRequest{
+		Checkpoint: checkpoint,
+		Subject:    subject,
+		Summary:    fmt.Sprintf("%s wants to run with:\n%s", call.Name, pretty.String()),
+		Content:    pretty.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Record the decision right away, so it survives a crash
+	ar.checkpointSession(ctx, nil)
+
+	switch {
+	case decision.Decision == DecisionEdit:
+		if !json.Valid([]byte(decision.Content)) {
+			return &agent.ToolResult{
+				Success:           false,
+				Error:             "the edited parameters are not valid JSON",
+				StandardizedError: agent.NewStandardizedError(agent.ErrorCodeInvalidParameters, "the edited parameters are not valid JSON", "Call the tool again."),
+			}, nil
+		}
+		call.Arguments = json.RawMessage(decision.Content)
+		return nil, nil
+	case decision.Approved():
+		return nil, nil
+	}
+
+	message := fmt.Sprintf("%s was rejected at the %s checkpoint", call.Name, checkpoint)
+	if decision.Reason != "" {
+		message += ": " + decision.Reason
+	}
+	return &agent.ToolResult{
+		Success:           false,
+		Error:             message,
+		StandardizedError: agent.NewStandardizedError(agent.ErrorCodePermissionDenied, message, "Do not retry this change as it is. Propose a different fix, or leave this issue unresolved."),
+	}, nil
+}
@@ -0,0 +1,179 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedApprover answers checkpoints with fixed decisions
+type scriptedApprover struct {
+	decision Decision
+	content  string
+	reason   string
+	asked    []ApprovalRequest
+}
+
+func (a *scriptedApprover) Approve(ctx context.Context, req ApprovalRequest) (Decision, string, string, error) {
+	a.asked = append(a.asked, req)
+	return a.decision, a.content, a.reason, nil
+}
+
+// countingTool counts how often it runs
+type countingTool struct {
+	name  string
+	calls int
+}
+
+func (c *countingTool) Name() string        { return c.name }
+func (c *countingTool) Description() string { return "counts calls" }
+func (c *countingTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{"type": "object", "properties": {"file_path": {"type": "string"}, "content": {"type": "string"}}}`)
+}
+func (c *countingTool) Execute(ctx context.Context, params json.RawMessage) (*agent.ToolResult, error) {
+	c.calls++
+	return &agent.ToolResult{Success: true, Data: map[string]interface{}{"written": true}}, nil
+}
+
+func TestApprovalGatePolicies(t *testing.T) {
+	gate := NewApprovalGate([]Checkpoint{CheckpointFileChange, CheckpointCommit}, map[Checkpoint]Decision{
+		CheckpointCommit: DecisionReject,
+	}, nil)
+	ctx := context.Background()
+
+	decision, err := gate.Check(ctx, ApprovalRequest{Checkpoint: CheckpointPlan, Subject: "plan"})
+	require.NoError(t, err)
+	assert.True(t, decision.Approved(), "disabled checkpoints are approved")
+
+	decision, err = gate.Check(ctx, ApprovalRequest{Checkpoint: CheckpointFileChange, Subject: "main.go"})
+	require.NoError(t, err)
+	assert.True(t, decision.Approved(), "checkpoints without a policy are approved")
+	assert.Equal(t, DecidedByPolicy, decision.DecidedBy)
+
+	decision, err = gate.Check(ctx, ApprovalRequest{Checkpoint: CheckpointCommit, Subject: "fix"})
+	require.NoError(t, err)
+	assert.False(t, decision.Approved())
+
+	decisions := gate.Decisions()
+	require.Len(t, decisions, 2, "only enabled checkpoints are recorded")
+	assert.Equal(t, "main.go", decisions[0].Subject)
+	assert.Equal(t, DecisionReject, decisions[1].Decision)
+}
+
+func TestApprovalGateApprover(t *testing.T) {
+	approver := &scriptedApprover{decision: DecisionEdit, content: "edited"}
+	gate := NewApprovalGate(Checkpoints, map[Checkpoint]Decision{CheckpointPlan: DecisionReject}, approver)
+
+	decision, err := gate.Check(context.Background(), ApprovalRequest{Checkpoint: CheckpointPlan, Subject: "plan", Content: "original"})
+	require.NoError(t, err)
+	assert.True(t, decision.Approved(), "the user decides, not the policy")
+	assert.Equal(t, "edited", decision.Content)
+	assert.Equal(t, DecidedByUser, decision.DecidedBy)
+	require.Len(t, approver.asked, 1)
+	assert.Equal(t, "original", approver.asked[0].Content)
+}
+
+func TestApprovalGateNil(t *testing.T) {
+	var gate *ApprovalGate
+	decision, err := gate.Check(context.Background(), ApprovalRequest{Checkpoint: CheckpointCommit})
+	require.NoError(t, err)
+	assert.True(t, decision.Approved())
+	assert.Empty(t, gate.Decisions())
+}
+
+func TestParseCheckpoints(t *testing.T) {
+	checkpoints, err := ParseCheckpoints([]string{"plan", " commit"})
+	require.NoError(t, err)
+	assert.Equal(t, []Checkpoint{CheckpointPlan, CheckpointCommit}, checkpoints)
+
+	checkpoints, err = ParseCheckpoints([]string{"none"})
+	require.NoError(t, err)
+	assert.Empty(t, checkpoints)
+
+	_, err = ParseCheckpoints([]string{"deploy"})
+	assert.Error(t, err)
+}
+
+func TestParsePolicies(t *testing.T) {
+	policies, err := ParsePolicies(map[string]string{"plan": "approve", "commit": "reject"})
+	require.NoError(t, err)
+	assert.Equal(t, map[Checkpoint]Decision{CheckpointPlan: DecisionApprove, CheckpointCommit: DecisionReject}, policies)
+
+	_, err = ParsePolicies(map[string]string{"commit": "edit"})
+	assert.Error(t, err, "edit needs a user")
+	_, err = ParsePolicies(map[string]string{"deploy": "approve"})
+	assert.Error(t, err)
+}
+
+func TestToolCheckpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       string
+		checkpoint Checkpoint
+		subject    string
+		gated      bool
+	}{
+		{"write_file", `{"file_path": "main.go"}`, CheckpointFileChange, "main.go", true},
+		{"apply_patch_to_file", `{"file_path": "a.go", "dry_run": true}`, "", "", false},
+		{"search_replace", `{"path": "pkg"}`, CheckpointFileChange, "pkg", true},
+		{"git_commit", `{"commit_message": "Fix tests"}`, CheckpointCommit, "Fix tests", true},
+		{"run_shell_command", `{"command": "make test"}`, CheckpointCommand, "make test", true},
+		{"run_snippet", `{"language": "python", "code": "print(1)"}`, CheckpointCommand, "python snippet", true},
+		{"read_file", `{"file_path": "main.go"}`, "", "", false},
+	}
+	for _, tt := range tests {
+		checkpoint, subject, gated := toolCheckpoint(&llm.FunctionCall{Name: tt.name, Arguments: json.RawMessage(tt.args)})
+		assert.Equal(t, tt.gated, gated, tt.name)
+		assert.Equal(t, tt.checkpoint, checkpoint, tt.name)
+		assert.Equal(t, tt.subject, subject, tt.name)
+	}
+}
+
+func TestAgentRunnerRejectedToolCall(t *testing.T) {
+	tool := &countingTool{name: "write_file"}
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(tool))
+
+	client := &MockLLMClient{responses: []*llm.FunctionCallResponse{{
+		FunctionCall: &llm.FunctionCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path": "main.go", "content": "x"}`), ID: "call_1"},
+	}}}
+	runner := NewAgentRunner(client, registry, "You are a helpful assistant", "mock-model")
+	runner.SetApprovalGate(NewApprovalGate(Checkpoints, map[Checkpoint]Decision{CheckpointFileChange: DecisionReject}, nil))
+
+	result, err := runner.Run(context.Background(), "Fix main.go")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Zero(t, tool.calls, "rejected calls do not run")
+
+	decisions := runner.approvalGate.Decisions()
+	require.Len(t, decisions, 1)
+	assert.Equal(t, "main.go", decisions[0].Subject)
+	assert.Equal(t, DecisionReject, decisions[0].Decision)
+}
+
+func TestAgentRunnerInvalidArgumentsAreNotRun(t *testing.T) {
+	tool := &countingTool{name: "write_file"}
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(tool))
+
+	client := &MockLLMClient{responses: []*llm.FunctionCallResponse{{
+		FunctionCall: &llm.FunctionCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path": 7, "content": ["x"]}`), ID: "call_1"},
+	}}}
+	runner := NewAgentRunner(client, registry, "You are a helpful assistant", "mock-model")
+	runner.SetApprovalGate(NewApprovalGate(Checkpoints, nil, nil))
+
+	result, err := runner.Run(context.Background(), "Fix main.go")
+	require.NoError(t, err)
+	assert.Zero(t, tool.calls, "calls with invalid arguments do not run")
+	assert.Empty(t, runner.approvalGate.Decisions(), "nor are they put to approval")
+
+	toolMessage := result.Messages[len(result.Messages)-2].Content
+	assert.Contains(t, toolMessage, "2 invalid argument(s)")
+	assert.Contains(t, toolMessage, "content must be string, got array")
+	assert.Contains(t, toolMessage, "file_path must be string, got number 7")
+}
@@ -6,9 +6,12 @@ import (
 	"fmt"
 
 	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/apispec"
 	"github.com/castrovroberto/CGE/internal/config"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
 	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/teamsync"
 	"github.com/castrovroberto/CGE/internal/templates"
 )
 
@@ -19,11 +22,13 @@ type CommandIntegrator struct {
 	config             config.IntegratorConfig
 	templateEngine     *templates.Engine
 	deliberationConfig config.DeliberationConfig
+	approvalGate       *ApprovalGate   // Checkpoints of runs; nil runs without approval
+	sessionManager     *SessionManager // Records runs as sessions; nil keeps no session
 }
 
 // NewCommandIntegrator creates a new command integrator
 func NewCommandIntegrator(llmClient llm.Client, toolRegistry *agent.Registry, cfg config.IntegratorConfig) *CommandIntegrator {
-	templateEngine := templates.NewEngine(cfg.PromptsDir)
+	templateEngine := templates.NewEngine(cfg.PromptsDir).WithFallbackDir(teamsync.PromptsPath(cfg.WorkspaceRoot))
 
 	return &CommandIntegrator{
 		llmClient:      llmClient,
@@ -50,6 +55,17 @@ func (ci *CommandIntegrator) SetDeliberationConfig(config config.DeliberationCon
 	ci.deliberationConfig = config
 }
 
+// SetApprovalGate makes runs wait for approval at the gate's checkpoints
+func (ci *CommandIntegrator) SetApprovalGate(gate *ApprovalGate) {
+	ci.approvalGate = gate
+}
+
+// SetSessionManager records runs as sessions, including their approval
+// decisions
+func (ci *CommandIntegrator) SetSessionManager(sessionManager *SessionManager) {
+	ci.sessionManager = sessionManager
+}
+
 // RunnerInterface defines the interface for both regular and deliberation runners
 type RunnerInterface interface {
 	RunWithCommand(ctx context.Context, initialPrompt string, command string) (RunnerResult, error)
@@ -92,7 +108,7 @@ type PlanResponse struct {
 
 // ExecutePlan runs the planning orchestrator
 func (ci *CommandIntegrator) ExecutePlan(ctx context.Context, req *PlanRequest) (*PlanResponse, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
 
 	// Prepare system prompt for planning
 	systemPrompt := `You are an expert software architect and project planner. 
@@ -139,7 +155,7 @@ Codebase Context:
 %v`, req.UserGoal, req.CodebaseContext)
 
 	// Run the orchestrator
-	result, err := runner.RunWithCommand(ctx, initialPrompt, "")
+	result, err := runner.RunWithCommand(ctx, initialPrompt, "plan")
 	if err != nil {
 		log.Error("Plan orchestration failed", "error", err)
 		return nil, fmt.Errorf("plan orchestration failed: %w", err)
@@ -179,7 +195,7 @@ type GenerateResponse struct {
 
 // ExecuteGenerate runs the code generation orchestrator
 func (ci *CommandIntegrator) ExecuteGenerate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
 
 	// Prepare system prompt for generation
 	systemPrompt := `You are an expert software engineer specializing in code generation.
@@ -225,8 +241,20 @@ Please implement this task by making the necessary code changes. Use the availab
 			return ""
 		}())
 
+	// Add the API contracts the task works against, if it touches API code
+	var task struct {
+		Description   string   `json:"description"`
+		Rationale     string   `json:"rationale"`
+		FilesToModify []string `json:"files_to_modify"`
+		FilesToCreate []string `json:"files_to_create"`
+	}
+	json.Unmarshal(taskJSON, &task)
+	if apiContext := apispec.PromptContext(ci.config.WorkspaceRoot, task.Description+"\n"+task.Rationale, append(task.FilesToModify, task.FilesToCreate...)); apiContext != "" {
+		initialPrompt += "\n\n" + apiContext
+	}
+
 	// Run the orchestrator
-	result, err := runner.RunWithCommand(ctx, initialPrompt, "")
+	result, err := runner.RunWithCommand(ctx, initialPrompt, "generate")
 	if err != nil {
 		log.Error("Generate orchestration failed", "error", err)
 		return nil, fmt.Errorf("generate orchestration failed: %w", err)
@@ -235,7 +263,7 @@ Please implement this task by making the necessary code changes. Use the availab
 	// Extract changes from tool calls
 	var changes []interface{}
 	for _, msg := range result.GetMessages() {
-		if msg.Role == "tool" && (msg.Name == "write_file" || msg.Name == "apply_patch_to_file") {
+		if msg.Role == "tool" && (msg.Name == "write_file" || msg.Name == "modify_file" || msg.Name == "apply_patch_to_file") {
 			changes = append(changes, map[string]interface{}{
 				"tool":    msg.Name,
 				"content": msg.Content,
@@ -264,11 +292,13 @@ type ReviewResponse struct {
 	FixesApplied []string  `json:"fixes_applied"`
 	Messages     []Message `json:"messages"`
 	Success      bool      `json:"success"`
+	Rejected     bool      `json:"rejected,omitempty"`   // The plan was rejected, so nothing ran
+	SessionID    string    `json:"session_id,omitempty"` // Session recording the run, with a session manager
 }
 
 // ExecuteReview runs the code review orchestrator
 func (ci *CommandIntegrator) ExecuteReview(ctx context.Context, req *ReviewRequest) (*ReviewResponse, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
 
 	// Load the review template
 	reviewTemplateData := map[string]interface{}{
@@ -322,8 +352,39 @@ Target Directory: %s
 Please use the available tools to read the relevant files, understand the issues, and apply fixes. After making changes, run the tests and linter again to verify the fixes.`,
 		req.TestOutput, req.LintOutput, req.TargetDir)
 
+	// The instructions are the plan of a review; they may be edited or
+	// rejected before the agent starts
+	decision, err := ci.approvalGate.Check(ctx, ApprovalRequest{
+		Checkpoint: CheckpointPlan,
+		Subject:    "review instructions",
+		Summary:    initialPrompt,
+		Content:    initialPrompt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case decision.Decision == DecisionEdit:
+		initialPrompt = decision.Content
+	case !decision.Approved():
+		log.Info("Review plan rejected", "reason", decision.Reason)
+		response := &ReviewResponse{Rejected: true}
+		// Nothing ran, but the decision still belongs in a session
+		if ci.sessionManager != nil {
+			session := ci.sessionManager.CreateSession("", req.Model, "review", nil)
+			session.Approvals = ci.approvalGate.Decisions()
+			ci.sessionManager.UpdateSessionState(session, "rejected")
+			if err := ci.sessionManager.SaveSession(session); err != nil {
+				log.Warn("Failed to record the rejected review plan", "error", err)
+			} else {
+				response.SessionID = session.SessionID
+			}
+		}
+		return response, nil
+	}
+
 	// Run the orchestrator
-	result, err := runner.RunWithCommand(ctx, initialPrompt, "")
+	result, err := runner.RunWithCommand(ctx, initialPrompt, "review")
 	if err != nil {
 		log.Error("Review orchestration failed", "error", err)
 		return nil, fmt.Errorf("review orchestration failed: %w", err)
@@ -337,11 +398,15 @@ Please use the available tools to read the relevant files, understand the issues
 		}
 	}
 
-	return &ReviewResponse{
+	response := &ReviewResponse{
 		FixesApplied: fixes,
 		Messages:     result.GetMessages(),
 		Success:      result.GetSuccess(),
-	}, nil
+	}
+	if sessionRunner, ok := runner.(interface{ GetCurrentSessionID() string }); ok {
+		response.SessionID = sessionRunner.GetCurrentSessionID()
+	}
+	return response, nil
 }
 
 // createRunner creates either a regular or deliberation-enabled runner based on configuration
@@ -356,11 +421,13 @@ func (ci *CommandIntegrator) createRunner(systemPrompt, model string, runConfig
 			ci.deliberationConfig,
 		)
 		deliberationRunner.SetConfig(runConfig)
+		deliberationRunner.SetApprovalGate(ci.approvalGate)
 		return &DeliberationRunnerWrapper{deliberationRunner}, nil
 	} else {
 		// Create regular runner
-		regularRunner := NewAgentRunner(ci.llmClient, ci.toolRegistry, systemPrompt, model)
+		regularRunner := NewAgentRunnerWithSession(ci.llmClient, ci.toolRegistry, systemPrompt, model, ci.sessionManager)
 		regularRunner.SetConfig(runConfig)
+		regularRunner.SetApprovalGate(ci.approvalGate)
 		return &AgentRunnerWrapper{regularRunner}, nil
 	}
 }
@@ -0,0 +1,87 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// finishToolName is the tool the model calls to end a run with its final
+// answer. It is handled by the runners rather than registered as a tool.
+const finishToolName = "finish"
+
+// finishToolParameters is the schema of the arguments of finish
+var finishToolParameters = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"answer": {
+			"type": "string",
+			"description": "The final answer for the user: the result, or a summary of what was done and anything left undone"
+		},
+		"success": {
+			"type": "boolean",
+			"description": "False when the task could not be completed; defaults to true"
+		}
+	},
+	"required": ["answer"]
+}`)
+
+// completionInstructions tell the model how to end a run
+const completionInstructions = "## Finishing\n\nWhen the task is done, or cannot be done, call the finish tool with your final answer for the user. The run only ends when you call finish."
+
+// finishReminder is sent when the model replies without calling a tool
+const finishReminder = "[You replied without calling a tool] If you are done, call finish with your final answer; otherwise continue with your next tool call."
+
+// finishToolDefinition returns the definition of finish offered to the model
+func finishToolDefinition() llm.ToolDefinition {
+	return llm.CreateToolDefinition(
+		finishToolName,
+		"End the run with your final answer for the user. Call it once the task is done or cannot be done; the run continues until you do.",
+		finishToolParameters,
+	)
+}
+
+// parseFinish returns the final answer of a call to finish and whether the
+// task was completed
+func parseFinish(call *llm.FunctionCall) (answer string, success bool, err error) {
+	var args struct {
+		Answer  string `json:"answer"`
+		Success *bool  `json:"success"`
+	}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return "", false, fmt.Errorf("invalid finish arguments: %w", err)
+	}
+	if strings.TrimSpace(args.Answer) == "" {
+		return "", false, fmt.Errorf("finish needs an answer: give the final answer for the user")
+	}
+	return args.Answer, args.Success == nil || *args.Success, nil
+}
+
+// looksFinal guesses whether a reply without a tool call is a final answer.
+// It is only a fallback for models that do not call finish.
+func looksFinal(content string) bool {
+	content = strings.ToLower(strings.TrimSpace(content))
+
+	// Look for indicators that this is a final answer
+	finalIndicators := []string{
+		"task completed",
+		"finished",
+		"done",
+		"complete",
+		"successfully",
+		"final result",
+		"conclusion",
+		"summary",
+	}
+
+	for _, indicator := range finalIndicators {
+		if strings.Contains(content, indicator) {
+			return true
+		}
+	}
+
+	// If the response is substantial (more than 100 chars) and doesn't seem to be asking for more tools
+	return len(content) > 100 && !strings.Contains(content, "need to") && !strings.Contains(content, "should")
+}
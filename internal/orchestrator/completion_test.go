@@ -0,0 +1,115 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// systemPromptRecordingLLMClient records the system prompts and tools it is
+// given
+type systemPromptRecordingLLMClient struct {
+	MockLLMClient
+	systemPrompts []string
+	tools         [][]llm.ToolDefinition
+}
+
+func (m *systemPromptRecordingLLMClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []llm.ToolDefinition) (*llm.FunctionCallResponse, error) {
+	m.systemPrompts = append(m.systemPrompts, systemPrompt)
+	m.tools = append(m.tools, tools)
+	return m.MockLLMClient.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+func finishCall(args string) *llm.FunctionCallResponse {
+	return &llm.FunctionCallResponse{FunctionCall: &llm.FunctionCall{ID: "finish-1", Name: finishToolName, Arguments: json.RawMessage(args)}}
+}
+
+func TestParseFinish(t *testing.T) {
+	answer, success, err := parseFinish(&llm.FunctionCall{Arguments: json.RawMessage(`{"answer":"All tests pass"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, "All tests pass", answer)
+	assert.True(t, success, "success defaults to true")
+
+	_, success, err = parseFinish(&llm.FunctionCall{Arguments: json.RawMessage(`{"answer":"Blocked on credentials","success":false}`)})
+	require.NoError(t, err)
+	assert.False(t, success)
+
+	_, _, err = parseFinish(&llm.FunctionCall{Arguments: json.RawMessage(`{"answer":"  "}`)})
+	assert.Error(t, err)
+	_, _, err = parseFinish(&llm.FunctionCall{Arguments: json.RawMessage(`not json`)})
+	assert.Error(t, err)
+}
+
+func TestAgentRunner_Finish(t *testing.T) {
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(&MockTool{name: "read_file", parameters: json.RawMessage(`{"type":"object"}`), result: &agent.ToolResult{Success: true, Data: "package main"}}))
+	client := &systemPromptRecordingLLMClient{MockLLMClient: MockLLMClient{responses: []*llm.FunctionCallResponse{
+		// Neither final-sounding nor a tool call, so the model is reminded
+		{IsTextResponse: true, TextContent: "I need to read main.go first."},
+		{FunctionCall: &llm.FunctionCall{ID: "read-1", Name: "read_file", Arguments: json.RawMessage(`{"file_path":"main.go"}`)}},
+		finishCall(`{}`),
+		finishCall(`{"answer":"main.go is the entry point"}`),
+	}}}
+	runner := NewAgentRunner(client, registry, "system", "model")
+
+	result, err := runner.Run(context.Background(), "What is main.go?")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, "main.go is the entry point", result.FinalResponse)
+	assert.Equal(t, 4, result.Iterations)
+
+	var reminders, finishErrors int
+	for _, msg := range result.Messages {
+		if msg.Content == finishReminder {
+			reminders++
+		}
+		if msg.Name == finishToolName && msg.Content != "Run finished." {
+			finishErrors++
+		}
+	}
+	assert.Equal(t, 1, reminders)
+	assert.Equal(t, 1, finishErrors, "finish without an answer is refused")
+	assert.Equal(t, "Run finished.", result.Messages[len(result.Messages)-1].Content, "the finish call has a result")
+
+	require.NotEmpty(t, client.systemPrompts)
+	assert.Contains(t, client.systemPrompts[0], "system")
+	assert.Contains(t, client.systemPrompts[0], completionInstructions, "the system prompt reaches the model")
+	last := client.tools[0][len(client.tools[0])-1]
+	assert.Equal(t, finishToolName, last.Function.Name)
+}
+
+func TestAgentRunner_FinishUnsuccessfully(t *testing.T) {
+	client := &MockLLMClient{responses: []*llm.FunctionCallResponse{
+		finishCall(`{"answer":"The API key is missing","success":false}`),
+	}}
+	for name, run := range map[string]func() (*RunResult, error){
+		"agent runner": func() (*RunResult, error) {
+			return NewAgentRunner(client, agent.NewRegistry(), "system", "model").Run(context.Background(), "Deploy")
+		},
+		"enhanced runner": func() (*RunResult, error) {
+			return NewEnhancedAgentRunner(client, agent.NewRegistry(), "system", "model").Run(context.Background(), "Deploy")
+		},
+	} {
+		client.callIndex = 0
+		result, err := run()
+		require.NoError(t, err, name)
+		assert.False(t, result.Success, name)
+		assert.Equal(t, "The API key is missing", result.FinalResponse, name)
+		assert.Equal(t, "the agent could not complete the task", result.Error, name)
+	}
+}
+
+func TestIsFinalAnswerFallback(t *testing.T) {
+	runner := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "system", "model")
+	tools := []llm.ToolDefinition{llm.CreateToolDefinition("read_file", "", nil), finishToolDefinition()}
+
+	assert.False(t, runner.isFinalAnswer("I need to read main.go first.", 1, tools))
+	assert.True(t, runner.isFinalAnswer("Task completed.", 1, tools))
+	assert.True(t, runner.isFinalAnswer("I need to read main.go first.", runner.maxIterations, tools), "the last reply is final")
+	assert.True(t, runner.isFinalAnswer("I need to read main.go first.", 1, tools[1:]), "there is nothing else to do")
+}
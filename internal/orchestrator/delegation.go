@@ -0,0 +1,162 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/logger"
+)
+
+// subAgentSystemPrompt is the system prompt of child agents run for delegate_task
+const subAgentSystemPrompt = `You are a sub-agent working on one subtask for another agent. You cannot see its conversation; everything you know about the subtask is in the request.
+
+Use the available tools to complete the subtask, staying strictly within its scope. Your final response is all the other agent will see, so end with a concise summary of what you found or changed, including file paths and any problems left unresolved.`
+
+// subAgentSpawner runs delegated subtasks in child AgentRunners that share the
+// parent's LLM client and model. Children share what is left of the parent's
+// iteration budget, get a slice of its time budget and no delegate_task tool
+// of their own.
+type subAgentSpawner struct {
+	parent      *AgentRunner
+	toolFactory *agent.ToolFactory
+}
+
+// EnableDelegation registers the delegate_task tool, letting the agent hand
+// subtasks to child agents built from toolFactory
+func (ar *AgentRunner) EnableDelegation(toolFactory *agent.ToolFactory) error {
+	return ar.toolRegistry.Register(agent.NewDelegateTaskTool(&subAgentSpawner{parent: ar, toolFactory: toolFactory}))
+}
+
+// Spawn implements agent.SubAgentSpawner
+func (s *subAgentSpawner) Spawn(ctx context.Context, req agent.DelegateRequest) (*agent.DelegateResult, error) {
+	log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
+
+	registry, err := s.registryFor(req.ToolSet)
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultRunConfig()
+	config.MaxIterations, err = s.iterationBudget(req.MaxIterations)
+	if err != nil {
+		return nil, err
+	}
+	config.TimeoutSeconds = timeBudgetSeconds(ctx, config.TimeoutSeconds)
+
+	// Child tool progress is reported as progress of the delegate_task call
+	if reporter, ok := agent.ProgressReporterFromContext(ctx); ok {
+		ctx = agent.WithProgressReporter(ctx, agent.ProgressReporterFunc(func(update agent.ProgressUpdate) {
+			reporter.Report(agent.ProgressUpdate{Progress: -1, Status: fmt.Sprintf("%s: %s", update.ToolName, update.Status)})
+		}))
+	}
+
+	var child *AgentRunner
+	if s.parent.sessionManager != nil {
+		child = NewAgentRunnerWithSession(s.parent.llmClient, registry, subAgentSystemPrompt, s.parent.model, s.parent.sessionManager)
+	} else {
+		child = NewAgentRunner(s.parent.llmClient, registry, subAgentSystemPrompt, s.parent.model)
+	}
+	child.SetConfig(config)
+
+	log.Info("Delegating subtask", "tool_set", req.ToolSet, "max_iterations", config.MaxIterations, "timeout_seconds", config.TimeoutSeconds)
+	result, err := child.RunWithCommand(ctx, delegatePrompt(req), "delegate")
+	used := config.MaxIterations
+	if result != nil {
+		used = result.Iterations
+	}
+	s.releaseIterations(config.MaxIterations - used)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.parent.currentSession != nil {
+		if childID := child.GetCurrentSessionID(); childID != "" {
+			children, _ := s.parent.currentSession.Metadata["child_sessions"].([]string)
+			s.parent.currentSession.Metadata["child_sessions"] = append(children, childID)
+		}
+	}
+
+	return &agent.DelegateResult{
+		Summary:    result.FinalResponse,
+		Success:    result.Success,
+		Iterations: result.Iterations,
+		ToolCalls:  result.ToolCalls,
+		Error:      result.Error,
+	}, nil
+}
+
+// registryFor builds the child's tool registry. None of the sets include
+// delegate_task, so children cannot delegate further.
+func (s *subAgentSpawner) registryFor(toolSet string) (*agent.Registry, error) {
+	switch toolSet {
+	case agent.DelegateToolSetReadOnly, "":
+		return s.toolFactory.CreatePlanningRegistry(), nil
+	case agent.DelegateToolSetEdit:
+		return s.toolFactory.CreateGenerationRegistry(), nil
+	case agent.DelegateToolSetFull:
+		return s.toolFactory.CreateFullRegistry(), nil
+	default:
+		return nil, fmt.Errorf("unknown tool set: %s", toolSet)
+	}
+}
+
+// iterationBudget reserves iterations for a child: half of what the parent's
+// run has left after its own iterations and those of earlier children, so
+// children together can never consume the parent's whole budget
+func (s *subAgentSpawner) iterationBudget(requested int) (int, error) {
+	ar := s.parent
+	ar.delegationMu.Lock()
+	defer ar.delegationMu.Unlock()
+
+	remaining := ar.maxIterations - ar.runIterations - ar.delegatedIterations
+	if remaining < 1 {
+		return 0, fmt.Errorf("%w: children used %d of the run's %d iterations", agent.ErrDelegationBudgetExhausted, ar.delegatedIterations, ar.maxIterations)
+	}
+	budget := remaining / 2
+	if budget < 1 {
+		budget = 1
+	}
+	if requested > 0 && requested < budget {
+		budget = requested
+	}
+	ar.delegatedIterations += budget
+	return budget, nil
+}
+
+// releaseIterations returns iterations a child reserved but did not use
+func (s *subAgentSpawner) releaseIterations(unused int) {
+	if unused <= 0 {
+		return
+	}
+	s.parent.delegationMu.Lock()
+	s.parent.delegatedIterations -= unused
+	s.parent.delegationMu.Unlock()
+}
+
+// timeBudgetSeconds gives a child half of the time left before ctx's
+// deadline, leaving the parent time to use the result
+func timeBudgetSeconds(ctx context.Context, fallback int) int {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return fallback
+	}
+	seconds := int(time.Until(deadline).Seconds() / 2)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// delegatePrompt builds the child's initial prompt from the request
+func delegatePrompt(req agent.DelegateRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Subtask: %s\n", req.Task)
+	if req.Context != "" {
+		fmt.Fprintf(&b, "\nContext from the delegating agent:\n%s\n", req.Context)
+	}
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRunner_Delegation(t *testing.T) {
+	args, _ := json.Marshal(map[string]interface{}{
+		"task":     "list the callers of Parse",
+		"context":  "Parse lives in parser.go",
+		"tool_set": "read_only",
+	})
+	client := &recordingLLMClient{MockLLMClient: MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{ID: "call-1", Name: "delegate_task", Arguments: args}},
+		{IsTextResponse: true, TextContent: "Summary: Parse is called from main.go and cli.go"},
+		{IsTextResponse: true, TextContent: "Task completed: Parse has two callers"},
+	}}}
+
+	toolFactory := agent.NewToolFactory(t.TempDir())
+	runner := NewAgentRunner(client, agent.NewRegistry(), "system", "test-model")
+	require.NoError(t, runner.EnableDelegation(toolFactory))
+	require.Error(t, runner.EnableDelegation(toolFactory), "the tool is registered once")
+
+	result, err := runner.Run(context.Background(), "who calls Parse?")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 1, result.ToolCalls)
+
+	// The child sees only the delegated request, the parent only the summary
+	require.Len(t, client.prompts, 3)
+	assert.Contains(t, client.prompts[1], "Subtask: list the callers of Parse")
+	assert.Contains(t, client.prompts[1], "Parse lives in parser.go")
+	assert.NotContains(t, client.prompts[1], "who calls Parse?")
+	assert.Contains(t, client.prompts[2], "Parse is called from main.go and cli.go")
+
+	var toolMessage Message
+	for _, msg := range result.Messages {
+		if msg.Role == "tool" {
+			toolMessage = msg
+		}
+	}
+	assert.Equal(t, "delegate_task", toolMessage.Name)
+	assert.Contains(t, toolMessage.Content, `"summary": "Summary: Parse is called from main.go and cli.go"`)
+}
+
+func TestSubAgentBudget(t *testing.T) {
+	parent := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "system", "test-model")
+	parent.SetConfig(&RunConfig{MaxIterations: 10})
+	spawner := &subAgentSpawner{parent: parent}
+	budget := func(requested int) int {
+		t.Helper()
+		iterations, err := spawner.iterationBudget(requested)
+		require.NoError(t, err)
+		spawner.releaseIterations(iterations) // Each case starts from a fresh run
+		return iterations
+	}
+
+	assert.Equal(t, 5, budget(0))
+	assert.Equal(t, 3, budget(3))
+	assert.Equal(t, 5, budget(50), "requests are capped by the budget")
+
+	parent.SetConfig(&RunConfig{MaxIterations: 1})
+	assert.Equal(t, 1, budget(0))
+
+	assert.Equal(t, 300, timeBudgetSeconds(context.Background(), 300))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+	assert.InDelta(t, 50, timeBudgetSeconds(ctx, 300), 1)
+}
+
+func TestSubAgentBudgetIsShared(t *testing.T) {
+	parent := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "system", "test-model")
+	parent.SetConfig(&RunConfig{MaxIterations: 10})
+	parent.setRunIterations(2)
+	spawner := &subAgentSpawner{parent: parent}
+
+	// Children split what the parent has left rather than each getting half
+	first, err := spawner.iterationBudget(0)
+	require.NoError(t, err)
+	assert.Equal(t, 4, first)
+	second, err := spawner.iterationBudget(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, second)
+
+	// Iterations a child did not use go back to the budget
+	spawner.releaseIterations(1)
+	for i := 0; i < 3; i++ {
+		last, err := spawner.iterationBudget(0)
+		require.NoError(t, err)
+		assert.Equal(t, 1, last)
+	}
+
+	_, err = spawner.iterationBudget(0)
+	assert.ErrorIs(t, err, agent.ErrDelegationBudgetExhausted, "delegation is refused once the budget is used up")
+}
+
+func TestDelegationRefusedWithoutBudget(t *testing.T) {
+	args, _ := json.Marshal(map[string]interface{}{"task": "list the callers of Parse"})
+	client := &recordingLLMClient{MockLLMClient: MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{ID: "call-1", Name: "delegate_task", Arguments: args}},
+	}}}
+	runner := NewAgentRunner(client, agent.NewRegistry(), "system", "test-model")
+	runner.SetConfig(&RunConfig{MaxIterations: 1})
+	require.NoError(t, runner.EnableDelegation(agent.NewToolFactory(t.TempDir())))
+
+	result, err := runner.Run(context.Background(), "who calls Parse?")
+	require.NoError(t, err)
+	assert.Len(t, client.prompts, 1, "no child agent is run")
+	var toolMessage Message
+	for _, msg := range result.Messages {
+		if msg.Role == "tool" {
+			toolMessage = msg
+		}
+	}
+	assert.Contains(t, toolMessage.Content, "no iterations are left to delegate")
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/castrovroberto/CGE/internal/config"
 	"github.com/castrovroberto/CGE/internal/contextkeys"
 	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
 )
 
 // DeliberationPhase represents different phases of deliberation
@@ -76,7 +77,7 @@ func (dr *DeliberationRunner) RunWithDeliberation(ctx context.Context, initialPr
 
 // RunWithDeliberationAndCommand executes with deliberation and command tracking
 func (dr *DeliberationRunner) RunWithDeliberationAndCommand(ctx context.Context, initialPrompt string, command string) (*DeliberationResult, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
 
 	if !dr.config.Enabled {
 		// Fallback to regular execution
@@ -112,7 +113,7 @@ func (dr *DeliberationRunner) RunWithDeliberationAndCommand(ctx context.Context,
 
 	// Initialize message history
 	messages := []Message{
-		{Role: "system", Content: dr.systemPrompt},
+		{Role: "system", Content: dr.runSystemPrompt(ctx) + "\n\n" + completionInstructions},
 		{Role: "user", Content: initialPrompt},
 	}
 
@@ -250,14 +251,14 @@ func (dr *DeliberationRunner) generateThought(ctx context.Context, messages []Me
 // executeActionPhase performs the regular agent action (tool call or response)
 func (dr *DeliberationRunner) executeActionPhase(ctx context.Context, messages []Message, iteration int) (*ActionResult, error) {
 	// Prepare tool definitions
-	tools := dr.prepareToolDefinitions()
+	tools := append(dr.prepareToolDefinitions(), finishToolDefinition())
 
 	// Call LLM with function calling support
 	response, err := dr.llmClient.GenerateWithFunctions(
 		ctx,
 		dr.model,
 		dr.buildPromptFromMessages(messages),
-		"",
+		systemPromptOf(messages),
 		tools,
 	)
 	if err != nil {
@@ -272,7 +273,7 @@ func (dr *DeliberationRunner) executeActionPhase(ctx context.Context, messages [
 			Content: response.TextContent,
 		}
 
-		isFinal := dr.isFinalAnswer(response.TextContent, iteration)
+		isFinal := dr.isFinalAnswer(response.TextContent, iteration, tools)
 		return &ActionResult{
 			Messages:   []Message{newMessage},
 			IsToolCall: false,
@@ -290,6 +291,22 @@ func (dr *DeliberationRunner) executeActionPhase(ctx context.Context, messages [
 			ToolCall: functionCall,
 		}
 
+		// The model ends the run with its final answer, kept as the last
+		// assistant message
+		if functionCall.Name == finishToolName {
+			resultMessage := Message{Role: "tool", ToolCallID: functionCall.ID, Name: finishToolName, Content: "Run finished."}
+			answer, _, err := parseFinish(functionCall)
+			if err != nil {
+				resultMessage.Content = fmt.Sprintf("Error: %v", err)
+				return &ActionResult{Messages: []Message{callMessage, resultMessage}, IsToolCall: true, ToolName: finishToolName, Content: resultMessage.Content}, nil
+			}
+			return &ActionResult{
+				Messages: []Message{callMessage, resultMessage, {Role: "assistant", Content: answer}},
+				IsFinal:  true,
+				Content:  answer,
+			}, nil
+		}
+
 		// Execute tool
 		toolResult, err := dr.executeTool(ctx, functionCall)
 		resultMessage := Message{
@@ -305,7 +322,7 @@ func (dr *DeliberationRunner) executeActionPhase(ctx context.Context, messages [
 			if dr.isClarificationRequest(toolResult) {
 				return dr.handleClarificationRequest(ctx, callMessage, functionCall, toolResult)
 			}
-			resultMessage.Content = dr.formatToolResult(toolResult)
+			resultMessage.Content = dr.toolResultContent(ctx, functionCall.Name, toolResult)
 		}
 
 		return &ActionResult{
@@ -449,7 +466,7 @@ func (dr *DeliberationRunner) isClarificationRequest(toolResult *agent.ToolResul
 
 // handleClarificationRequest handles a clarification request by pausing execution
 func (dr *DeliberationRunner) handleClarificationRequest(ctx context.Context, callMessage Message, functionCall *llm.FunctionCall, toolResult *agent.ToolResult) (*ActionResult, error) {
-	log := contextkeys.LoggerFromContext(ctx)
+	log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
 
 	data, _ := toolResult.Data.(map[string]interface{})
 	formattedMessage, _ := data["formatted_message"].(string)
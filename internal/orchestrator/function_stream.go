@@ -0,0 +1,58 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// callPreviewInterval limits how often a tool call being generated is
+// reported as progress
+const callPreviewInterval = 100 * time.Millisecond
+
+// maxCallPreviewArguments truncates the arguments shown while a tool call is
+// being generated
+const maxCallPreviewArguments = 80
+
+// generate asks the LLM for the next step. When the client can stream function
+// calls and ctx carries a progress reporter, a tool call is reported while the
+// model writes it, e.g. `calling read_file("path": "main.go")`, as progress of
+// the call that will run with the same ID.
+func (ar *AgentRunner) generate(ctx context.Context, systemPrompt, prompt string, tools []llm.ToolDefinition) (response *llm.FunctionCallResponse, err error) {
+	started := time.Now()
+	defer func() { ar.recordSessionLLMCall(ar.model, started, err) }()
+
+	streamer, canStream := ar.llmClient.(llm.FunctionCallStreamer)
+	reporter, hasReporter := agent.ProgressReporterFromContext(ctx)
+	if !canStream || !hasReporter {
+		return ar.llmClient.GenerateWithFunctions(ctx, ar.model, prompt, systemPrompt, tools)
+	}
+
+	var lastReport time.Time
+	return streamer.StreamWithFunctions(ctx, ar.model, prompt, systemPrompt, tools, func(delta llm.FunctionCallDelta) {
+		if delta.FunctionName == "" || time.Since(lastReport) < callPreviewInterval {
+			return
+		}
+		lastReport = time.Now()
+		reporter.Report(agent.ProgressUpdate{
+			ToolName: delta.FunctionName,
+			CallID:   delta.CallID,
+			Progress: -1,
+			Status:   callPreview(delta.FunctionName, delta.Arguments),
+		})
+	})
+}
+
+// callPreview formats a tool call whose arguments JSON may be incomplete
+func callPreview(name, arguments string) string {
+	arguments = strings.TrimSpace(arguments)
+	arguments = strings.TrimSuffix(strings.TrimPrefix(arguments, "{"), "}")
+	if runes := []rune(arguments); len(runes) > maxCallPreviewArguments {
+		arguments = string(runes[:maxCallPreviewArguments]) + "..."
+	}
+	return fmt.Sprintf("calling %s(%s)", name, arguments)
+}
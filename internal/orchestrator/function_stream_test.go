@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamingLLMClient streams the tool call of each scripted response as two
+// fragments before returning it
+type streamingLLMClient struct {
+	MockLLMClient
+}
+
+func (m *streamingLLMClient) StreamWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []llm.ToolDefinition, onDelta func(llm.FunctionCallDelta)) (*llm.FunctionCallResponse, error) {
+	response, err := m.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+	if err != nil || response.FunctionCall == nil {
+		return response, err
+	}
+	call := response.FunctionCall
+	half := string(call.Arguments[:len(call.Arguments)/2])
+	onDelta(llm.FunctionCallDelta{CallID: call.ID, FunctionName: call.Name, Arguments: half, ArgumentsDelta: half})
+	onDelta(llm.FunctionCallDelta{CallID: call.ID, FunctionName: call.Name, Arguments: string(call.Arguments)})
+	return response, nil
+}
+
+func TestAgentRunner_StreamsToolCallPreview(t *testing.T) {
+	args, _ := json.Marshal(map[string]string{"path": "main.go"})
+	client := &streamingLLMClient{MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{ID: "call-1", Name: "read_file", Arguments: args}},
+	}}}
+	runner := NewAgentRunner(client, agent.NewRegistry(), "system", "test-model")
+
+	var updates []agent.ProgressUpdate
+	ctx := agent.WithProgressReporter(context.Background(), agent.ProgressReporterFunc(func(update agent.ProgressUpdate) {
+		updates = append(updates, update)
+	}))
+
+	response, err := runner.generate(ctx, "system", "read main.go", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "read_file", response.FunctionCall.Name)
+
+	// Reports are throttled, so only the first fragment is shown here
+	require.Len(t, updates, 1)
+	assert.Equal(t, "read_file", updates[0].ToolName)
+	assert.Equal(t, "call-1", updates[0].CallID)
+	assert.True(t, strings.HasPrefix(updates[0].Status, `calling read_file("path`), updates[0].Status)
+
+	// Without a reporter the response is not streamed
+	client.callIndex = 0
+	response, err = runner.generate(context.Background(), "system", "read main.go", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "read_file", response.FunctionCall.Name)
+}
+
+func TestCallPreview(t *testing.T) {
+	assert.Equal(t, `calling read_file("path":"main.go")`, callPreview("read_file", `{"path":"main.go"}`))
+	assert.Equal(t, `calling read_file("pa)`, callPreview("read_file", `{"pa`))
+	assert.Equal(t, "calling list_dir()", callPreview("list_dir", ""))
+
+	long := callPreview("write_file", `{"content":"`+strings.Repeat("x", 200))
+	assert.True(t, strings.HasSuffix(long, "...)"))
+}
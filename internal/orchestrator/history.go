@@ -0,0 +1,425 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/textutils"
+)
+
+// History strategies of agent runs; see config.HistoryConfig
+const (
+	HistoryFull          = "full"
+	HistoryWindow        = "window"
+	HistorySummaryWindow = "summary_window"
+	HistoryRetrieval     = "retrieval"
+)
+
+// Turns kept by the windowed strategies when the configuration does not say
+const (
+	defaultHistoryWindowTurns    = 8
+	defaultHistoryRetrievalTurns = 3
+)
+
+// maxHistorySummaryChars bounds the summary of the turns left out of the
+// window
+const maxHistorySummaryChars = 2000
+
+// maxHistoryLineChars bounds each message in the digest of the turns left out
+const maxHistoryLineChars = 300
+
+// HistoryMetadataKey is the session metadata key of the HistoryStats of the
+// session's latest run
+const HistoryMetadataKey = "history"
+
+// HistoryStats records the history strategy of a run and what it sent the
+// model at its latest step
+type HistoryStats struct {
+	Strategy        string `json:"strategy"`
+	WindowTurns     int    `json:"window_turns,omitempty"`
+	RetrievalTurns  int    `json:"retrieval_turns,omitempty"`
+	Turns           int    `json:"turns"`                      // Turns of the conversation
+	SentTurns       int    `json:"sent_turns"`                 // Turns sent whole, the task included
+	SummarizedTurns int    `json:"summarized_turns,omitempty"` // Turns sent as a summary
+	RetrievedTurns  int    `json:"retrieved_turns,omitempty"`  // Earlier turns sent for their relevance to the task
+}
+
+// SessionHistory returns the history stats recorded in a session, whether it
+// was just run or loaded from disk
+func SessionHistory(session *SessionState) (HistoryStats, bool) {
+	var stats HistoryStats
+	value, ok := session.Metadata[HistoryMetadataKey]
+	if !ok {
+		return stats, false
+	}
+	if typed, ok := value.(HistoryStats); ok {
+		return typed, true
+	}
+	data, err := json.Marshal(value)
+	if err != nil || json.Unmarshal(data, &stats) != nil || stats.Strategy == "" {
+		return stats, false
+	}
+	return stats, true
+}
+
+// SetHistory sets the history strategy of the runner's runs. Without it, the
+// strategy the configuration in the run context gives the run's command is
+// used, or the full history.
+func (ar *AgentRunner) SetHistory(history config.HistoryConfig) {
+	ar.history = &history
+}
+
+// AssemblePrompt returns the system prompt and the prompt a step of a run
+// with messages sends the model under the runner's history strategy, taking
+// the first user message as the task. `CGE bench` times it on long histories.
+func (ar *AgentRunner) AssemblePrompt(ctx context.Context, messages []Message) (systemPrompt, prompt string) {
+	task := slices.IndexFunc(messages, func(msg Message) bool { return msg.Role == "user" })
+	view := ar.newHistoryWindow(ctx, max(task, 0)).view(ctx, messages)
+	return systemPromptOf(view), ar.buildPromptFromMessages(view)
+}
+
+// runHistory returns the history strategy of a run, with the defaults of
+// the settings it leaves unset
+func (ar *AgentRunner) runHistory(ctx context.Context) config.HistoryConfig {
+	var history config.HistoryConfig
+	if ar.history != nil {
+		history = *ar.history
+	} else if cfg := contextkeys.ConfigPtrFromContext(ctx); cfg != nil {
+		history = cfg.GetHistoryConfig(ar.runCommand)
+	}
+	switch history.Strategy {
+	case HistoryFull, HistoryWindow, HistorySummaryWindow, HistoryRetrieval:
+	case "":
+		history.Strategy = HistoryFull
+	default:
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Warn("Unknown history strategy, sending the full history", "strategy", history.Strategy, "command", ar.runCommand)
+		history.Strategy = HistoryFull
+	}
+	if history.WindowTurns <= 0 {
+		history.WindowTurns = defaultHistoryWindowTurns
+	}
+	if history.RetrievalTurns <= 0 {
+		history.RetrievalTurns = defaultHistoryRetrievalTurns
+	}
+	return history
+}
+
+// historyTurn is the span of messages of a turn: a user message, or a model
+// step with the tool results answering it
+type historyTurn struct {
+	start, end int
+}
+
+// splitTurns splits the messages after the system prompt into turns
+func splitTurns(messages []Message) []historyTurn {
+	var turns []historyTurn
+	for i, msg := range messages {
+		switch {
+		case msg.Role == "system":
+			continue
+		case msg.Role == "tool" && len(turns) > 0 && turns[len(turns)-1].end == i:
+			turns[len(turns)-1].end = i + 1
+		default:
+			turns = append(turns, historyTurn{start: i, end: i + 1})
+		}
+	}
+	return turns
+}
+
+// historySummary is the summary of the first turns left out of the window,
+// kept across the steps of a run so it is only extended as the window slides
+type historySummary struct {
+	turns int
+	text  string
+}
+
+// historyWindow applies the history strategy of a run to the messages of
+// each of its steps
+type historyWindow struct {
+	ar      *AgentRunner
+	config  config.HistoryConfig
+	task    int // Index of the message of the run's task, which is always sent
+	summary historySummary
+}
+
+// newHistoryWindow returns the history window of a run whose task is
+// messages[task]
+func (ar *AgentRunner) newHistoryWindow(ctx context.Context, task int) *historyWindow {
+	return &historyWindow{ar: ar, config: ar.runHistory(ctx), task: task}
+}
+
+// view returns the messages sent to the model at a step: the system prompt,
+// the task, and the turns the strategy keeps. The turns left out are
+// replaced by a note, or by their summary.
+//
+// The window slides by half its size at a time, so the start of the prompt,
+// summary included, stays the same for several steps and can be served from
+// the provider's prompt cache.
+func (w *historyWindow) view(ctx context.Context, messages []Message) []Message {
+	turns := splitTurns(messages)
+	stats := HistoryStats{Strategy: w.config.Strategy, Turns: len(turns), SentTurns: len(turns)}
+	if w.config.Strategy == HistoryFull {
+		w.ar.recordHistory(stats)
+		return messages
+	}
+	stats.WindowTurns = w.config.WindowTurns
+	if w.config.Strategy == HistoryRetrieval {
+		stats.RetrievalTurns = w.config.RetrievalTurns
+	}
+
+	// Turns other than the task, oldest first; the older ones beyond the
+	// window are left out
+	var candidates []int
+	for i, turn := range turns {
+		if turn.start != w.task {
+			candidates = append(candidates, i)
+		}
+	}
+	step := max(1, w.config.WindowTurns/2)
+	omitted := (len(candidates) - w.config.WindowTurns) / step * step
+	if omitted <= 0 {
+		w.ar.recordHistory(stats)
+		return messages
+	}
+	keep := make([]bool, len(turns))
+	for i := range turns {
+		keep[i] = true
+	}
+	for _, i := range candidates[:omitted] {
+		keep[i] = false
+	}
+
+	var note string
+	left := candidates[:omitted]
+	switch w.config.Strategy {
+	case HistoryWindow:
+		note = fmt.Sprintf("[%d earlier turns of this conversation are left out to keep the prompt short. Run a tool again if you need a result that is no longer shown.]", omitted)
+	case HistorySummaryWindow:
+		note = fmt.Sprintf("[Summary of %d earlier turns of this conversation]\n%s", omitted, w.summarize(ctx, messages, turns, left))
+		stats.SummarizedTurns = omitted
+	case HistoryRetrieval:
+		retrieved := relevantTurns(messages, turns, left, w.query(messages, turns), w.config.RetrievalTurns)
+		for _, i := range retrieved {
+			keep[i] = true
+		}
+		stats.RetrievedTurns = len(retrieved)
+		note = fmt.Sprintf("[%d earlier turns of this conversation are left out; the %d most relevant to the task are kept. Run a tool again if you need a result that is no longer shown.]", omitted-len(retrieved), len(retrieved))
+	}
+
+	view := make([]Message, 0, len(messages))
+	if len(messages) > 0 && messages[0].Role == "system" {
+		view = append(view, messages[0])
+	}
+	noted := false
+	sent := 0
+	for i, turn := range turns {
+		if !keep[i] {
+			if !noted {
+				view = append(view, Message{Role: "user", Content: note})
+				noted = true
+			}
+			continue
+		}
+		view = append(view, messages[turn.start:turn.end]...)
+		sent++
+	}
+	stats.SentTurns = sent
+	w.ar.recordHistory(stats)
+	contextkeys.LoggerFor(ctx, logger.Orchestrator).Debug("Applied history strategy", "strategy", stats.Strategy, "turns", stats.Turns, "sent", stats.SentTurns)
+	return view
+}
+
+// query is the text retrieval matches earlier turns against: the task and
+// the latest user message, such as a steering message
+func (w *historyWindow) query(messages []Message, turns []historyTurn) string {
+	query := messages[w.task].Content
+	for i := len(turns) - 1; i >= 0; i-- {
+		if msg := messages[turns[i].start]; msg.Role == "user" && turns[i].start != w.task {
+			return query + "\n" + msg.Content
+		}
+	}
+	return query
+}
+
+// summarize returns the summary of the turns left out of the window,
+// extending the summary of the previous steps with the turns that left the
+// window since. The model writes it; without a model, or when it fails, it
+// is a digest of the turns.
+func (w *historyWindow) summarize(ctx context.Context, messages []Message, turns []historyTurn, left []int) string {
+	if w.summary.turns == len(left) {
+		return w.summary.text
+	}
+	if w.summary.turns > len(left) {
+		w.summary = historySummary{}
+	}
+	text := historyDigest(messages, turns, left[w.summary.turns:])
+	if w.summary.turns > 0 {
+		text = w.summary.text + "\n\nThen:\n" + text
+	}
+
+	summary := ""
+	if w.ar.llmClient != nil {
+		options := textutils.DefaultSummaryOptions()
+		options.MaxLength = maxHistorySummaryChars
+		options.Style = "brief"
+		options.PreserveTags = []string{"files read and changed", "commands run and their outcome", "errors", "decisions"}
+		var err error
+		summary, err = textutils.NewSummarizer(w.ar.llmClient, w.ar.model, options).SummarizeText(ctx, text)
+		if err != nil {
+			contextkeys.LoggerFor(ctx, logger.Orchestrator).Debug("Failed to summarize earlier turns", "error", err)
+		}
+	}
+	if summary == "" {
+		summary = text
+	}
+	if len(summary) > maxHistorySummaryChars {
+		// The latest turns matter most to the next step
+		summary = "..." + summary[len(summary)-maxHistorySummaryChars:]
+	}
+	w.summary = historySummary{turns: len(left), text: summary}
+	return summary
+}
+
+// historyDigest describes turns in a line per message
+func historyDigest(messages []Message, turns []historyTurn, indexes []int) string {
+	var lines []string
+	for _, i := range indexes {
+		for _, msg := range messages[turns[i].start:turns[i].end] {
+			var line string
+			switch {
+			case msg.ToolCall != nil:
+				line = "Called " + describeToolCall(msg.ToolCall)
+			case msg.Role == "tool":
+				line = fmt.Sprintf("Result of %s: %s", msg.Name, msg.Content)
+			case msg.Role == "assistant":
+				line = "Assistant: " + msg.Content
+			default:
+				line = "User: " + msg.Content
+			}
+			line = strings.Join(strings.Fields(line), " ")
+			if runes := []rune(line); len(runes) > maxHistoryLineChars {
+				line = string(runes[:maxHistoryLineChars]) + "..."
+			}
+			lines = append(lines, "- "+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// historyTermPattern matches the words, identifiers and paths of a message
+var historyTermPattern = regexp.MustCompile(`[\p{L}\p{N}_][\p{L}\p{N}_./-]+`)
+
+// historyStopWords are words too common to tell turns apart
+var historyStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true,
+	"from": true, "are": true, "was": true, "not": true, "you": true, "your": true,
+	"have": true, "has": true, "but": true, "all": true, "can": true, "will": true,
+}
+
+// historyTermSeparators split identifiers and paths into words
+var historyTermSeparators = regexp.MustCompile(`[./_-]+`)
+
+// historyTerms returns the distinct terms of text, lowercased: its words,
+// identifiers and paths, and the words of these
+func historyTerms(text string) map[string]bool {
+	terms := make(map[string]bool)
+	add := func(term string) {
+		if len(term) >= 3 && !historyStopWords[term] {
+			terms[term] = true
+		}
+	}
+	for _, term := range historyTermPattern.FindAllString(strings.ToLower(text), -1) {
+		term = strings.Trim(term, "./-")
+		add(term)
+		for _, word := range historyTermSeparators.Split(term, -1) {
+			add(word)
+		}
+	}
+	return terms
+}
+
+// turnText is the text of a turn retrieval matches, tool arguments included
+func turnText(messages []Message, turn historyTurn) string {
+	var b strings.Builder
+	for _, msg := range messages[turn.start:turn.end] {
+		b.WriteString(msg.Content)
+		b.WriteString("\n")
+		if msg.ToolCall != nil {
+			b.WriteString(msg.ToolCall.Name + " " + string(msg.ToolCall.Arguments) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// relevantTurns returns up to limit of the candidate turns sharing terms
+// with query, in the order of the conversation. Terms found in fewer turns
+// weigh more.
+func relevantTurns(messages []Message, turns []historyTurn, candidates []int, query string, limit int) []int {
+	queryTerms := historyTerms(query)
+	if len(queryTerms) == 0 || limit <= 0 {
+		return nil
+	}
+	termsOf := make(map[int]map[string]bool, len(candidates))
+	frequency := make(map[string]int)
+	for _, i := range candidates {
+		termsOf[i] = historyTerms(turnText(messages, turns[i]))
+		for term := range termsOf[i] {
+			if queryTerms[term] {
+				frequency[term]++
+			}
+		}
+	}
+
+	type scored struct {
+		turn  int
+		score float64
+	}
+	var matches []scored
+	for _, i := range candidates {
+		score := 0.0
+		for term := range termsOf[i] {
+			if queryTerms[term] {
+				score += math.Log(1 + float64(len(candidates))/float64(frequency[term]))
+			}
+		}
+		if score > 0 {
+			matches = append(matches, scored{turn: i, score: score})
+		}
+	}
+	// The later of equally relevant turns is the more current
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].score != matches[b].score {
+			return matches[a].score > matches[b].score
+		}
+		return matches[a].turn > matches[b].turn
+	})
+	var relevant []int
+	for _, match := range matches[:min(limit, len(matches))] {
+		relevant = append(relevant, match.turn)
+	}
+	sort.Ints(relevant)
+	return relevant
+}
+
+// recordHistory records the history stats of the latest step in the session
+// metadata
+func (ar *AgentRunner) recordHistory(stats HistoryStats) {
+	if ar.currentSession == nil {
+		return
+	}
+	ar.sessionMu.Lock()
+	defer ar.sessionMu.Unlock()
+	if ar.currentSession.Metadata == nil {
+		ar.currentSession.Metadata = make(map[string]interface{})
+	}
+	ar.currentSession.Metadata[HistoryMetadataKey] = stats
+}
@@ -0,0 +1,157 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readSteps returns a conversation whose task is followed by a read_file
+// step per file
+func readSteps(task string, files ...string) []Message {
+	messages := []Message{{Role: "system", Content: "system"}, {Role: "user", Content: task}}
+	for i, file := range files {
+		call := &llm.FunctionCall{ID: fmt.Sprintf("call_%d", i), Name: "read_file", Arguments: json.RawMessage(fmt.Sprintf(`{"file_path": %q}`, file))}
+		messages = append(messages,
+			Message{Role: "assistant", ToolCall: call},
+			Message{Role: "tool", ToolCallID: call.ID, Name: "read_file", Content: "contents of " + file})
+	}
+	return messages
+}
+
+func files(n int) []string {
+	var names []string
+	for i := 0; i < n; i++ {
+		names = append(names, fmt.Sprintf("file%d.go", i))
+	}
+	return names
+}
+
+func TestSplitTurns(t *testing.T) {
+	messages := append(readSteps("Fix it", "a.go"), Message{Role: "user", Content: "steer"}, Message{Role: "assistant", Content: "Done"})
+	assert.Equal(t, []historyTurn{{1, 2}, {2, 4}, {4, 5}, {5, 6}}, splitTurns(messages))
+}
+
+func TestHistoryWindow(t *testing.T) {
+	runner := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "system", "model")
+	runner.SetHistory(config.HistoryConfig{Strategy: HistoryWindow, WindowTurns: 4})
+	window := runner.newHistoryWindow(context.Background(), 1)
+
+	messages := readSteps("Fix the parser", files(5)...)
+	assert.Equal(t, messages, window.view(context.Background(), messages), "the window slides by half its size at a time")
+
+	messages = readSteps("Fix the parser", files(12)...)
+	view := window.view(context.Background(), messages)
+	require.Len(t, view, 3+4*2)
+	assert.Equal(t, messages[:2], view[:2], "the system prompt and the task are always sent")
+	assert.Contains(t, view[2].Content, "8 earlier turns")
+	assert.Equal(t, messages[len(messages)-8:], view[3:])
+}
+
+func TestHistorySummaryWindow(t *testing.T) {
+	runner := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "system", "model")
+	runner.SetHistory(config.HistoryConfig{Strategy: HistorySummaryWindow, WindowTurns: 2})
+	window := runner.newHistoryWindow(context.Background(), 1)
+
+	view := window.view(context.Background(), readSteps("Fix the parser", files(4)...))
+	require.Len(t, view, 3+2*2)
+	assert.Contains(t, view[2].Content, "Summary of 2 earlier turns")
+	assert.Contains(t, view[2].Content, "Called read_file on file0.go")
+	assert.Contains(t, view[2].Content, "Result of read_file: contents of file1.go")
+
+	view = window.view(context.Background(), readSteps("Fix the parser", files(5)...))
+	assert.Contains(t, view[2].Content, "Summary of 3 earlier turns")
+	assert.Contains(t, view[2].Content, "Then:\n- Called read_file on file2.go", "the summary is extended with the turns that left the window")
+	assert.Equal(t, 3, window.summary.turns)
+}
+
+func TestHistoryRetrieval(t *testing.T) {
+	runner := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "system", "model")
+	runner.SetHistory(config.HistoryConfig{Strategy: HistoryRetrieval, WindowTurns: 2, RetrievalTurns: 1})
+	window := runner.newHistoryWindow(context.Background(), 1)
+
+	messages := readSteps("Fix the tokenizer in lexer.go", "main.go", "lexer.go", "util.go", "docs.md", "go.mod", "api.go")
+	view := window.view(context.Background(), messages)
+	prompt := runner.buildPromptFromMessages(view)
+	assert.Contains(t, prompt, "contents of lexer.go", "the earlier turn most relevant to the task is kept")
+	assert.NotContains(t, prompt, "contents of main.go")
+	assert.Contains(t, prompt, "contents of api.go")
+	assert.Contains(t, prompt, "the 1 most relevant to the task are kept")
+}
+
+func TestRelevantTurns(t *testing.T) {
+	messages := readSteps("task", "config.go", "parser.go", "parser_test.go", "config_test.go")
+	turns := splitTurns(messages)
+	candidates := []int{1, 2, 3, 4}
+	assert.Equal(t, []int{2, 3}, relevantTurns(messages, turns, candidates, "the parser", 2))
+	assert.Equal(t, []int{3}, relevantTurns(messages, turns, candidates, "the parser tests in parser_test.go", 1), "rarer terms weigh more")
+	assert.Empty(t, relevantTurns(messages, turns, candidates, "deploy", 2))
+}
+
+func TestHistoryStrategyPerCommand(t *testing.T) {
+	var responses []*llm.FunctionCallResponse
+	for i, file := range files(6) {
+		responses = append(responses, &llm.FunctionCallResponse{FunctionCall: &llm.FunctionCall{ID: fmt.Sprintf("call_%d", i), Name: "read_file", Arguments: json.RawMessage(fmt.Sprintf(`{"file_path": %q}`, file))}})
+	}
+	client := &promptRecorder{MockLLMClient: MockLLMClient{responses: responses}}
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(&countingTool{name: "read_file"}))
+	sessions, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+	runner := NewAgentRunnerWithSession(client, registry, "system", "model", sessions)
+	runConfig := DefaultRunConfig()
+	runConfig.MaxIterations = 10
+	runner.SetConfig(runConfig)
+
+	cfg := &config.AppConfig{}
+	cfg.History.Strategy = HistoryFull
+	cfg.History.Commands = map[string]config.HistoryConfig{"review": {Strategy: HistoryWindow, WindowTurns: 2}}
+	ctx := context.WithValue(context.Background(), contextkeys.ConfigKey, cfg)
+
+	result, err := runner.RunWithCommand(ctx, "Review the reader", "review")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	last := client.prompts[len(client.prompts)-1]
+	assert.True(t, strings.HasPrefix(last, "User: Review the reader"))
+	assert.Contains(t, last, "4 earlier turns of this conversation are left out")
+	assert.Equal(t, 2, strings.Count(last, "[Called tool: read_file]"))
+
+	stats, ok := SessionHistory(runner.currentSession)
+	require.True(t, ok)
+	assert.Equal(t, HistoryStats{Strategy: HistoryWindow, WindowTurns: 2, Turns: 7, SentTurns: 3}, stats)
+
+	loaded, err := sessions.LoadSession(runner.currentSession.SessionID)
+	require.NoError(t, err)
+	stats, ok = SessionHistory(loaded)
+	require.True(t, ok, "the history strategy is recorded in the saved session")
+	assert.Equal(t, HistoryWindow, stats.Strategy)
+}
+
+// promptRecorder records the prompts it is sent
+type promptRecorder struct {
+	MockLLMClient
+	prompts []string
+}
+
+func (p *promptRecorder) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []llm.ToolDefinition) (*llm.FunctionCallResponse, error) {
+	p.prompts = append(p.prompts, prompt)
+	return p.MockLLMClient.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+func TestRunHistoryDefaults(t *testing.T) {
+	runner := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "system", "model")
+	history := runner.runHistory(context.Background())
+	assert.Equal(t, config.HistoryConfig{Strategy: HistoryFull, WindowTurns: defaultHistoryWindowTurns, RetrievalTurns: defaultHistoryRetrievalTurns}, history)
+
+	runner.SetHistory(config.HistoryConfig{Strategy: "everything"})
+	assert.Equal(t, HistoryFull, runner.runHistory(context.Background()).Strategy, "unknown strategies send the full history")
+}
@@ -0,0 +1,230 @@
+package orchestrator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+const (
+	// loopRepeats is how many times in a row a block of tool calls must be
+	// made before the run is considered looping
+	loopRepeats = 3
+
+	// maxLoopPeriod is the longest block of tool calls checked for repeats
+	maxLoopPeriod = 3
+
+	// maxReportedCalls bounds the tool calls listed in a stall report
+	maxReportedCalls = 10
+)
+
+// loopDetector notices when a run stops making progress: the same tool calls
+// are made over and over, edits to a file go back and forth, or iterations go
+// by without changing files or learning anything new. Each time it does, the
+// intervention escalates, from a critique to withdrawing the tools involved
+// to stopping the run.
+type loopDetector struct {
+	stallIterations int
+
+	// Since the last intervention
+	calls      []string            // Signatures of the tool calls, in order
+	summaries  []string            // Descriptions of the tool calls, in order
+	edits      map[string][]string // Signatures of the edits made to each file
+	involved   map[string]bool     // Tools called without making progress
+	idle       int                 // Iterations without progress
+	progressed bool                // Whether the current iteration made progress
+	pending    string              // A stall noticed during the current iteration
+
+	started       bool            // Whether an iteration was observed
+	seen          map[string]bool // Signatures of the successful calls of the run
+	history       []string        // Descriptions of every tool call of the run
+	interventions int
+	withheld      map[string]bool // Tools withdrawn from the run
+}
+
+// newLoopDetector creates a detector intervening after stallIterations
+// iterations without progress, or nil when stallIterations is 0 and loops are
+// not detected
+func newLoopDetector(stallIterations int) *loopDetector {
+	if stallIterations <= 0 {
+		return nil
+	}
+	d := &loopDetector{
+		stallIterations: stallIterations,
+		seen:            make(map[string]bool),
+		withheld:        make(map[string]bool),
+	}
+	d.reset()
+	return d
+}
+
+// reset forgets what was observed since the last intervention, so the next
+// one needs fresh evidence
+func (d *loopDetector) reset() {
+	d.calls = nil
+	d.summaries = nil
+	d.edits = make(map[string][]string)
+	d.involved = make(map[string]bool)
+	d.idle = 0
+	d.progressed = false
+	d.pending = ""
+}
+
+// observe records a tool call of the current iteration. Calls make progress
+// when they succeed and either change files or were not made before.
+func (d *loopDetector) observe(call *llm.FunctionCall, signature string, succeeded bool) {
+	if d == nil {
+		return
+	}
+	summary := describeToolCall(call)
+	d.calls = append(d.calls, signature)
+	d.summaries = append(d.summaries, summary)
+	d.history = append(d.history, summary)
+
+	checkpoint, file, changesFiles := toolCheckpoint(call)
+	changesFiles = changesFiles && checkpoint == CheckpointFileChange
+	progress := succeeded && (!d.seen[signature] || changesFiles)
+
+	if succeeded && changesFiles {
+		edits := d.edits[file]
+		if len(edits) > 0 && edits[len(edits)-1] != signature && containsString(edits, signature) {
+			// An earlier version of the file is being restored
+			d.pending = fmt.Sprintf("edits to %s keep going back and forth between the same versions", file)
+			progress = false
+		}
+		d.edits[file] = append(edits, signature)
+	}
+	if succeeded {
+		d.seen[signature] = true
+	}
+	if progress {
+		d.progressed = true
+	} else {
+		d.involved[call.Name] = true
+	}
+}
+
+// nextIteration closes the previous iteration, if any, returning why the run
+// is stalled or an empty string when it is not
+func (d *loopDetector) nextIteration() string {
+	if d == nil {
+		return ""
+	}
+	if !d.started {
+		d.started = true
+		return ""
+	}
+	if d.progressed {
+		d.idle = 0
+	} else {
+		d.idle++
+	}
+	d.progressed = false
+
+	reason := d.pending
+	d.pending = ""
+	if reason == "" {
+		reason = d.repeatedBlock()
+	}
+	if reason == "" && d.idle >= d.stallIterations {
+		reason = fmt.Sprintf("%d iterations went by without changing files or learning anything new", d.idle)
+	}
+	return reason
+}
+
+// repeatedBlock describes the block of tool calls just made loopRepeats
+// times in a row, or returns an empty string when there is none
+func (d *loopDetector) repeatedBlock() string {
+	for period := 1; period <= maxLoopPeriod; period++ {
+		n := len(d.calls)
+		if n < period*loopRepeats {
+			break
+		}
+		repeated := true
+		for i := n - period*loopRepeats; i < n-period && repeated; i++ {
+			repeated = d.calls[i] == d.calls[i+period]
+		}
+		if repeated {
+			return fmt.Sprintf("the same tool calls were made %d times in a row: %s", loopRepeats, strings.Join(d.summaries[n-period:], ", "))
+		}
+	}
+	return ""
+}
+
+// intervene responds to a stall: the first time with a critique, the second
+// time also withdrawing the tools involved, and after that by stopping the
+// run. It returns the message to add to the conversation, or stop when the
+// run must end.
+func (d *loopDetector) intervene(reason string) (message string, stop bool) {
+	d.interventions++
+	involved := make([]string, 0, len(d.involved))
+	for name := range d.involved {
+		involved = append(involved, name)
+	}
+	sort.Strings(involved)
+	d.reset()
+
+	switch d.interventions {
+	case 1:
+		return fmt.Sprintf("[Loop detected] You are not making progress: %s. Stop and reconsider: summarize what you know and what has failed so far, then take a different approach or give your final answer.", reason), false
+	case 2:
+		for _, name := range involved {
+			d.withheld[name] = true
+		}
+		if len(involved) == 0 {
+			return fmt.Sprintf("[Loop detected again] You are still not making progress: %s. Take a different approach or give your final answer now.", reason), false
+		}
+		return fmt.Sprintf("[Loop detected again] You are still not making progress: %s. These tools are no longer available in this run: %s. Take a different approach or give your final answer now.", reason, strings.Join(involved, ", ")), false
+	default:
+		return "", true
+	}
+}
+
+// withholds reports whether a tool was withdrawn from the run
+func (d *loopDetector) withholds(name string) bool {
+	return d != nil && d.withheld[name]
+}
+
+// filter removes the tools withdrawn from the run from the tools offered to
+// the model
+func (d *loopDetector) filter(tools []llm.ToolDefinition) []llm.ToolDefinition {
+	if d == nil || len(d.withheld) == 0 {
+		return tools
+	}
+	filtered := make([]llm.ToolDefinition, 0, len(tools))
+	for _, tool := range tools {
+		if !d.withheld[tool.Function.Name] {
+			filtered = append(filtered, tool)
+		}
+	}
+	return filtered
+}
+
+// report explains why a stalled run was stopped and what it was doing
+func (d *loopDetector) report(reason string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The run was stopped because it stalled: %s.\n", reason)
+	fmt.Fprintf(&b, "It had already been told it was looping %d time(s).", d.interventions-1)
+	if len(d.history) > 0 {
+		recent := d.history
+		if len(recent) > maxReportedCalls {
+			recent = recent[len(recent)-maxReportedCalls:]
+		}
+		fmt.Fprintf(&b, "\n\nLast %d tool call(s):\n", len(recent))
+		for _, summary := range recent {
+			b.WriteString("- " + summary + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
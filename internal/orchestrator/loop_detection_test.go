@@ -0,0 +1,131 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loopCall(name, args string) (*llm.FunctionCall, string) {
+	call := &llm.FunctionCall{Name: name, Arguments: json.RawMessage(args)}
+	return call, name + ":" + args
+}
+
+func TestLoopDetectorRepeatedCalls(t *testing.T) {
+	d := newLoopDetector(10)
+	assert.Empty(t, d.nextIteration(), "nothing to check before the first iteration")
+
+	read, readSig := loopCall("read_file", `{"file_path":"a.go"}`)
+	list, listSig := loopCall("list_directory", `{"path":"."}`)
+	for i := 0; i < 2; i++ {
+		d.observe(read, readSig, true)
+		assert.Empty(t, d.nextIteration())
+		d.observe(list, listSig, true)
+		assert.Empty(t, d.nextIteration())
+	}
+	d.observe(read, readSig, true)
+	assert.Empty(t, d.nextIteration())
+	d.observe(list, listSig, true)
+	assert.Equal(t, "the same tool calls were made 3 times in a row: read_file on a.go, list_directory on .", d.nextIteration())
+}
+
+func TestLoopDetectorAlternatingEdits(t *testing.T) {
+	d := newLoopDetector(10)
+	d.nextIteration()
+	first, firstSig := loopCall("write_file", `{"file_path":"a.go","content":"one"}`)
+	second, secondSig := loopCall("write_file", `{"file_path":"a.go","content":"two"}`)
+	test, testSig := loopCall("run_tests", `{}`)
+
+	d.observe(first, firstSig, true)
+	assert.Empty(t, d.nextIteration())
+	d.observe(test, testSig, true)
+	assert.Empty(t, d.nextIteration())
+	d.observe(second, secondSig, true)
+	assert.Empty(t, d.nextIteration())
+	d.observe(test, testSig, true)
+	assert.Empty(t, d.nextIteration())
+	d.observe(first, firstSig, true)
+	assert.Equal(t, "edits to a.go keep going back and forth between the same versions", d.nextIteration())
+}
+
+func TestLoopDetectorStall(t *testing.T) {
+	d := newLoopDetector(3)
+	d.nextIteration()
+	for i := 0; i < 3; i++ {
+		call, sig := loopCall("read_file", fmt.Sprintf(`{"file_path":"missing%d.go"}`, i))
+		d.observe(call, sig, false)
+		if i < 2 {
+			assert.Empty(t, d.nextIteration())
+		}
+	}
+	assert.Equal(t, "3 iterations went by without changing files or learning anything new", d.nextIteration())
+
+	message, stop := d.intervene("stalled")
+	assert.False(t, stop)
+	assert.Contains(t, message, "[Loop detected] You are not making progress: stalled.")
+	assert.Empty(t, d.nextIteration(), "interventions need fresh evidence")
+
+	call, sig := loopCall("read_file", `{"file_path":"missing0.go"}`)
+	d.observe(call, sig, false)
+	message, stop = d.intervene("stalled again")
+	assert.False(t, stop)
+	assert.Contains(t, message, "no longer available in this run: read_file")
+	assert.True(t, d.withholds("read_file"))
+	tools := d.filter([]llm.ToolDefinition{
+		llm.CreateToolDefinition("read_file", "", nil),
+		llm.CreateToolDefinition("grep_codebase", "", nil),
+	})
+	require.Len(t, tools, 1)
+	assert.Equal(t, "grep_codebase", tools[0].Function.Name)
+
+	_, stop = d.intervene("still stalled")
+	assert.True(t, stop)
+	report := d.report("still stalled")
+	assert.Contains(t, report, "stalled: still stalled.")
+	assert.Contains(t, report, "looping 2 time(s)")
+	assert.Contains(t, report, "- read_file on missing0.go")
+
+	assert.Nil(t, newLoopDetector(0), "0 disables loop detection")
+	assert.Empty(t, (*loopDetector)(nil).nextIteration())
+}
+
+func TestAgentRunner_StopsStalledRun(t *testing.T) {
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(&MockTool{
+		name:       "list_directory",
+		parameters: json.RawMessage(`{"type":"object"}`),
+		result:     &agent.ToolResult{Success: true, Data: "a.go"},
+	}))
+	call := &llm.FunctionCallResponse{FunctionCall: &llm.FunctionCall{ID: "call", Name: "list_directory", Arguments: json.RawMessage(`{"path":"."}`)}}
+	responses := make([]*llm.FunctionCallResponse, 20)
+	for i := range responses {
+		responses[i] = call
+	}
+	runner := NewAgentRunner(&MockLLMClient{responses: responses}, registry, "system", "model")
+	config := DefaultRunConfig()
+	config.MaxIterations = 20
+	runner.SetConfig(config)
+
+	result, err := runner.Run(context.Background(), "List the files")
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.True(t, result.Stalled)
+	assert.Less(t, result.Iterations, 20, "the run stops before using up its iterations")
+	assert.Contains(t, result.Error, "stalled: the same tool calls were made 3 times in a row: list_directory on .")
+	assert.Contains(t, result.FinalResponse, "The run was stopped because it stalled")
+
+	var interventions []string
+	for _, msg := range result.Messages {
+		if msg.Role == "user" && len(msg.Content) > 0 && msg.Content[0] == '[' {
+			interventions = append(interventions, msg.Content)
+		}
+	}
+	require.Len(t, interventions, 2)
+	assert.Contains(t, interventions[1], "no longer available in this run: list_directory")
+}
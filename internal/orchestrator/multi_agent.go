@@ -0,0 +1,392 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
+)
+
+// AgentRole is the part an agent plays in a multi-agent pipeline
+type AgentRole string
+
+const (
+	RolePlanner  AgentRole = "planner"  // Breaks the goal into tasks
+	RoleExecutor AgentRole = "executor" // Implements the tasks
+	RoleReviewer AgentRole = "reviewer" // Critiques the implementation and gates it
+)
+
+// RoleSpec configures the agent playing one role
+type RoleSpec struct {
+	Role         AgentRole
+	SystemPrompt string
+	Registry     *agent.Registry
+	Config       *RunConfig
+	Model        string // Defaults to the pipeline model when empty
+}
+
+// PipelineTask is a unit of work produced by the planner
+type PipelineTask struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+// PipelineArtifact is the output of one pipeline stage, handed to the next
+type PipelineArtifact struct {
+	Role      AgentRole `json:"role"`
+	TaskID    string    `json:"task_id,omitempty"`
+	Cycle     int       `json:"cycle"` // Review cycle the artifact belongs to, starting at 1
+	Content   string    `json:"content"`
+	SessionID string    `json:"session_id,omitempty"` // Session of the agent that produced it
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ReviewVerdict is the reviewer's decision on an implementation
+type ReviewVerdict struct {
+	Approved bool   `json:"approved"`
+	Feedback string `json:"feedback"`
+}
+
+// PipelineResult represents the result of a multi-agent pipeline run
+type PipelineResult struct {
+	Goal         string             `json:"goal"`
+	Tasks        []PipelineTask     `json:"tasks"`
+	Artifacts    []PipelineArtifact `json:"artifacts"`
+	Verdict      *ReviewVerdict     `json:"verdict,omitempty"`
+	ReviewCycles int                `json:"review_cycles"`
+	SessionID    string             `json:"session_id,omitempty"` // Parent session recording the pipeline
+	Success      bool               `json:"success"`
+	Error        string             `json:"error,omitempty"`
+}
+
+// defaultMaxReviewCycles bounds how often the executor reworks rejected changes
+const defaultMaxReviewCycles = 3
+
+// MultiAgentOrchestrator coordinates a planner, an executor and a reviewer,
+// each running in its own AgentRunner with its own system prompt and tools.
+// The planner's tasks are passed to the executor, and the executor's work to
+// the reviewer, whose verdict either ends the pipeline or sends the work back.
+type MultiAgentOrchestrator struct {
+	llmClient       llm.Client
+	model           string
+	roles           map[AgentRole]RoleSpec
+	sessionManager  *SessionManager
+	maxReviewCycles int
+}
+
+// NewMultiAgentOrchestrator creates an orchestrator. A spec must be given for
+// each of the planner, executor and reviewer roles.
+func NewMultiAgentOrchestrator(llmClient llm.Client, model string, roles ...RoleSpec) (*MultiAgentOrchestrator, error) {
+	o := &MultiAgentOrchestrator{
+		llmClient:       llmClient,
+		model:           model,
+		roles:           make(map[AgentRole]RoleSpec),
+		maxReviewCycles: defaultMaxReviewCycles,
+	}
+	for _, spec := range roles {
+		o.roles[spec.Role] = spec
+	}
+	for _, role := range []AgentRole{RolePlanner, RoleExecutor, RoleReviewer} {
+		spec, ok := o.roles[role]
+		if !ok {
+			return nil, fmt.Errorf("no agent configured for the %s role", role)
+		}
+		if spec.Registry == nil {
+			return nil, fmt.Errorf("no tool registry configured for the %s role", role)
+		}
+	}
+	return o, nil
+}
+
+// DefaultRoleSpecs returns the standard planner, executor and reviewer agents,
+// using the planning, generation and review tool sets of toolFactory
+func DefaultRoleSpecs(toolFactory *agent.ToolFactory) []RoleSpec {
+	return []RoleSpec{
+		{Role: RolePlanner, SystemPrompt: plannerSystemPrompt, Registry: toolFactory.CreatePlanningRegistry(), Config: PlanRunConfig()},
+		{Role: RoleExecutor, SystemPrompt: executorSystemPrompt, Registry: toolFactory.CreateGenerationRegistry(), Config: GenerateRunConfig()},
+		{Role: RoleReviewer, SystemPrompt: reviewerSystemPrompt, Registry: toolFactory.CreateReviewRegistry(), Config: ReviewRunConfig()},
+	}
+}
+
+// SetSessionManager records pipeline runs in a parent session, and each
+// agent's run in a child session
+func (o *MultiAgentOrchestrator) SetSessionManager(sessionManager *SessionManager) {
+	o.sessionManager = sessionManager
+}
+
+// SetMaxReviewCycles sets how many times the reviewer may review the work
+// before the pipeline gives up
+func (o *MultiAgentOrchestrator) SetMaxReviewCycles(cycles int) {
+	if cycles > 0 {
+		o.maxReviewCycles = cycles
+	}
+}
+
+// Run executes the pipeline for goal
+func (o *MultiAgentOrchestrator) Run(ctx context.Context, goal string) (*PipelineResult, error) {
+	log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
+	result := &PipelineResult{Goal: goal}
+
+	var parent *SessionState
+	if o.sessionManager != nil {
+		parent = o.sessionManager.CreateSession("", o.model, "pipeline", nil)
+		parent.Metadata["goal"] = goal
+		parent.Messages = append(parent.Messages, Message{Role: "user", Content: goal})
+		result.SessionID = parent.SessionID
+		log.Info("Created pipeline session", "session_id", parent.SessionID)
+		// The parent stays locked for the whole pipeline, as a run's session
+		// does, so it is not taken for orphaned while saved as running
+		if err := o.sessionManager.LockSession(parent.SessionID); err != nil {
+			return nil, err
+		}
+		defer o.sessionManager.UnlockSession(parent.SessionID)
+	}
+
+	fail := func(err error) (*PipelineResult, error) {
+		result.Error = err.Error()
+		o.saveParent(ctx, parent, result, "failed")
+		return result, err
+	}
+
+	// 1. Plan
+	plan, err := o.runRole(ctx, RolePlanner, plannerPrompt(goal), "", 1)
+	result.Artifacts = append(result.Artifacts, plan)
+	o.recordArtifact(ctx, parent, result, plan)
+	if err != nil {
+		return fail(err)
+	}
+	result.Tasks = parseTasks(plan.Content, goal)
+	if parent != nil {
+		parent.Metadata["tasks"] = result.Tasks
+	}
+
+	// 2. Implement and review until the reviewer approves
+	var feedback string
+	for cycle := 1; cycle <= o.maxReviewCycles; cycle++ {
+		result.ReviewCycles = cycle
+
+		var implemented []PipelineArtifact
+		for _, task := range result.Tasks {
+			work, err := o.runRole(ctx, RoleExecutor, executorPrompt(goal, result.Tasks, task, implemented, feedback), task.ID, cycle)
+			result.Artifacts = append(result.Artifacts, work)
+			o.recordArtifact(ctx, parent, result, work)
+			if err != nil {
+				return fail(err)
+			}
+			implemented = append(implemented, work)
+		}
+
+		review, err := o.runRole(ctx, RoleReviewer, reviewerPrompt(goal, result.Tasks, implemented), "", cycle)
+		result.Artifacts = append(result.Artifacts, review)
+		o.recordArtifact(ctx, parent, result, review)
+		if err != nil {
+			return fail(err)
+		}
+
+		verdict := parseVerdict(review.Content)
+		result.Verdict = &verdict
+		if verdict.Approved {
+			log.Info("Pipeline approved by reviewer", "cycles", cycle)
+			result.Success = true
+			o.saveParent(ctx, parent, result, "completed")
+			return result, nil
+		}
+		log.Info("Reviewer requested changes", "cycle", cycle, "feedback", verdict.Feedback)
+		feedback = verdict.Feedback
+	}
+
+	result.Error = fmt.Sprintf("reviewer did not approve the changes after %d review cycles", o.maxReviewCycles)
+	o.saveParent(ctx, parent, result, "failed")
+	return result, nil
+}
+
+// runRole runs the agent for role on prompt and returns its output as an
+// artifact. An error is returned when the agent run fails, so the pipeline
+// stops instead of passing on incomplete work.
+func (o *MultiAgentOrchestrator) runRole(ctx context.Context, role AgentRole, prompt, taskID string, cycle int) (PipelineArtifact, error) {
+	spec := o.roles[role]
+	model := spec.Model
+	if model == "" {
+		model = o.model
+	}
+
+	var runner *AgentRunner
+	if o.sessionManager != nil {
+		runner = NewAgentRunnerWithSession(o.llmClient, spec.Registry, spec.SystemPrompt, model, o.sessionManager)
+	} else {
+		runner = NewAgentRunner(o.llmClient, spec.Registry, spec.SystemPrompt, model)
+	}
+	if spec.Config != nil {
+		runner.SetConfig(spec.Config)
+	}
+
+	artifact := PipelineArtifact{Role: role, TaskID: taskID, Cycle: cycle}
+	runResult, err := runner.RunWithCommand(ctx, prompt, string(role))
+	artifact.SessionID = runner.GetCurrentSessionID()
+	if err != nil {
+		artifact.Error = err.Error()
+		return artifact, fmt.Errorf("%s agent failed: %w", role, err)
+	}
+
+	artifact.Content = runResult.FinalResponse
+	artifact.Success = runResult.Success
+	if !runResult.Success {
+		artifact.Error = runResult.Error
+		return artifact, fmt.Errorf("%s agent did not complete: %s", role, runResult.Error)
+	}
+	return artifact, nil
+}
+
+// recordArtifact adds a stage's output to the parent session and saves it, so
+// an interrupted pipeline still leaves a record of the completed stages
+func (o *MultiAgentOrchestrator) recordArtifact(ctx context.Context, parent *SessionState, result *PipelineResult, artifact PipelineArtifact) {
+	if parent == nil {
+		return
+	}
+	parent.Messages = append(parent.Messages, Message{Role: "assistant", Name: string(artifact.Role), Content: artifact.Content})
+	if artifact.SessionID != "" {
+		children, _ := parent.Metadata["child_sessions"].([]string)
+		parent.Metadata["child_sessions"] = append(children, artifact.SessionID)
+	}
+	o.saveParent(ctx, parent, result, "running")
+}
+
+// saveParent stores the pipeline state in the parent session
+func (o *MultiAgentOrchestrator) saveParent(ctx context.Context, parent *SessionState, result *PipelineResult, state string) {
+	if parent == nil {
+		return
+	}
+	parent.Metadata["artifacts"] = result.Artifacts
+	parent.Metadata["review_cycles"] = result.ReviewCycles
+	if result.Verdict != nil {
+		parent.Metadata["verdict"] = result.Verdict
+	}
+	o.sessionManager.UpdateSessionState(parent, state)
+	if err := o.sessionManager.SaveSession(parent); err != nil {
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Warn("Failed to save pipeline session", "session_id", parent.SessionID, "error", err)
+	}
+}
+
+const plannerSystemPrompt = `You are the planner in a team of software agents. Explore the codebase with the available tools and break the user's goal into a short list of concrete, independently implementable tasks. Do not modify any files.
+
+Your final response must be a JSON object of the form:
+{"tasks": [{"id": "1", "description": "what to change, where and why"}]}`
+
+const executorSystemPrompt = `You are the executor in a team of software agents. Implement the task you are given using the available tools, following the plan and any reviewer feedback. Keep changes focused on the task.
+
+When done, reply with a concise summary of the files you changed and what you changed in them.`
+
+const reviewerSystemPrompt = `You are the reviewer in a team of software agents. Inspect the changes made for the plan with the available tools and check them for correctness, completeness and code quality. Do not modify any files.
+
+Your final response must be a JSON object of the form:
+{"approved": true or false, "feedback": "the problems to fix, or a short note when approving"}`
+
+// plannerPrompt asks the planner to break goal into tasks
+func plannerPrompt(goal string) string {
+	return fmt.Sprintf("Goal: %s\n\nBreak this goal into tasks.", goal)
+}
+
+// executorPrompt gives the executor the plan, the work done for earlier
+// tasks in this cycle and the reviewer's feedback on the previous cycle
+func executorPrompt(goal string, tasks []PipelineTask, task PipelineTask, done []PipelineArtifact, feedback string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Goal: %s\n\n", goal)
+	b.WriteString(formatTasks(tasks))
+	if len(done) > 0 {
+		b.WriteString("\nCompleted tasks:\n")
+		b.WriteString(formatArtifacts(done))
+	}
+	if feedback != "" {
+		fmt.Fprintf(&b, "\nThe reviewer requested changes to the previous attempt:\n%s\n", feedback)
+	}
+	fmt.Fprintf(&b, "\nImplement task %s: %s", task.ID, task.Description)
+	return b.String()
+}
+
+// reviewerPrompt gives the reviewer the plan and the executor's summaries
+func reviewerPrompt(goal string, tasks []PipelineTask, done []PipelineArtifact) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Goal: %s\n\n", goal)
+	b.WriteString(formatTasks(tasks))
+	b.WriteString("\nImplementation summaries:\n")
+	b.WriteString(formatArtifacts(done))
+	b.WriteString("\nReview the changes and decide whether to approve them.")
+	return b.String()
+}
+
+// formatTasks lists tasks for a prompt
+func formatTasks(tasks []PipelineTask) string {
+	var b strings.Builder
+	b.WriteString("Plan:\n")
+	for _, task := range tasks {
+		fmt.Fprintf(&b, "- [%s] %s\n", task.ID, task.Description)
+	}
+	return b.String()
+}
+
+// formatArtifacts lists executor summaries for a prompt
+func formatArtifacts(artifacts []PipelineArtifact) string {
+	var b strings.Builder
+	for _, artifact := range artifacts {
+		fmt.Fprintf(&b, "- Task %s: %s\n", artifact.TaskID, artifact.Content)
+	}
+	return b.String()
+}
+
+// extractJSONObject returns the outermost JSON object in text, which models
+// often wrap in prose or code fences
+func extractJSONObject(text string) (string, bool) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return "", false
+	}
+	return text[start : end+1], true
+}
+
+// parseTasks reads the planner's task list. When the planner did not return
+// valid tasks, the whole response becomes a single task so the pipeline can
+// still make progress.
+func parseTasks(response, goal string) []PipelineTask {
+	if raw, ok := extractJSONObject(response); ok {
+		var plan struct {
+			Tasks []PipelineTask `json:"tasks"`
+		}
+		if err := json.Unmarshal([]byte(raw), &plan); err == nil && len(plan.Tasks) > 0 {
+			for i := range plan.Tasks {
+				if plan.Tasks[i].ID == "" {
+					plan.Tasks[i].ID = fmt.Sprintf("%d", i+1)
+				}
+			}
+			return plan.Tasks
+		}
+	}
+
+	description := strings.TrimSpace(response)
+	if description == "" {
+		description = goal
+	}
+	return []PipelineTask{{ID: "1", Description: description}}
+}
+
+// parseVerdict reads the reviewer's verdict. A response without a valid
+// verdict is treated as a request for changes, so the gate never opens by
+// accident.
+func parseVerdict(response string) ReviewVerdict {
+	if raw, ok := extractJSONObject(response); ok {
+		var verdict struct {
+			Approved *bool  `json:"approved"`
+			Feedback string `json:"feedback"`
+		}
+		if err := json.Unmarshal([]byte(raw), &verdict); err == nil && verdict.Approved != nil {
+			return ReviewVerdict{Approved: *verdict.Approved, Feedback: verdict.Feedback}
+		}
+	}
+	return ReviewVerdict{Approved: false, Feedback: strings.TrimSpace(response)}
+}
@@ -0,0 +1,158 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLLMClient answers with canned responses, then canned text
+// responses, and records the prompts it receives
+type recordingLLMClient struct {
+	MockLLMClient
+	texts   []string
+	prompts []string
+}
+
+func (m *recordingLLMClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []llm.ToolDefinition) (*llm.FunctionCallResponse, error) {
+	m.prompts = append(m.prompts, prompt)
+	if m.callIndex < len(m.responses) {
+		m.callIndex++
+		return m.responses[m.callIndex-1], nil
+	}
+	text := "done"
+	if len(m.texts) > 0 {
+		text, m.texts = m.texts[0], m.texts[1:]
+	}
+	return &llm.FunctionCallResponse{IsTextResponse: true, TextContent: text}, nil
+}
+
+// hookedLLMClient calls before ahead of each LLM call, numbered from 1
+type hookedLLMClient struct {
+	*recordingLLMClient
+	calls  int
+	before func(call int)
+}
+
+func (m *hookedLLMClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []llm.ToolDefinition) (*llm.FunctionCallResponse, error) {
+	m.calls++
+	m.before(m.calls)
+	return m.recordingLLMClient.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+func testRoleSpecs() []RoleSpec {
+	return []RoleSpec{
+		{Role: RolePlanner, SystemPrompt: "plan", Registry: agent.NewRegistry()},
+		{Role: RoleExecutor, SystemPrompt: "execute", Registry: agent.NewRegistry()},
+		{Role: RoleReviewer, SystemPrompt: "review", Registry: agent.NewRegistry()},
+	}
+}
+
+func TestMultiAgentOrchestrator(t *testing.T) {
+	t.Run("missing role", func(t *testing.T) {
+		_, err := NewMultiAgentOrchestrator(&MockLLMClient{}, "test-model", testRoleSpecs()[:2]...)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "reviewer")
+	})
+
+	t.Run("review cycle passes artifacts and records the pipeline", func(t *testing.T) {
+		client := &recordingLLMClient{texts: []string{
+			`Here is the plan: {"tasks": [{"id": "a", "description": "add the flag"}, {"id": "b", "description": "document it"}]}`,
+			"done: added --verbose in cmd/root.go",
+			"done: documented --verbose in README.md",
+			`Review complete. {"approved": false, "feedback": "the flag has no test"}`,
+			"done: added --verbose with a test",
+			"done: documented --verbose in README.md",
+			`Review complete. {"approved": true, "feedback": "looks good"}`,
+		}}
+
+		sessionManager, err := NewSessionManager(t.TempDir(), nil)
+		require.NoError(t, err)
+		o, err := NewMultiAgentOrchestrator(client, "test-model", testRoleSpecs()...)
+		require.NoError(t, err)
+		o.SetSessionManager(sessionManager)
+
+		result, err := o.Run(context.Background(), "add a verbose flag")
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, 2, result.ReviewCycles)
+		assert.Equal(t, []PipelineTask{{ID: "a", Description: "add the flag"}, {ID: "b", Description: "document it"}}, result.Tasks)
+		assert.Equal(t, &ReviewVerdict{Approved: true, Feedback: "looks good"}, result.Verdict)
+		require.Len(t, result.Artifacts, 7)
+
+		// The executor sees the plan and earlier work, and the reviewer's feedback on retry
+		assert.Contains(t, client.prompts[2], "- Task a: done: added --verbose in cmd/root.go")
+		assert.Contains(t, client.prompts[4], "the flag has no test")
+		assert.Contains(t, client.prompts[6], "- Task b: done: documented --verbose in README.md")
+
+		parent, err := sessionManager.LoadSession(result.SessionID)
+		require.NoError(t, err)
+		assert.Equal(t, "pipeline", parent.Command)
+		assert.Equal(t, "completed", parent.CurrentState)
+		assert.Len(t, parent.Messages, 8)
+		assert.Len(t, parent.Metadata["child_sessions"], 7)
+		for _, artifact := range result.Artifacts {
+			child, err := sessionManager.LoadSession(artifact.SessionID)
+			require.NoError(t, err)
+			assert.Equal(t, string(artifact.Role), child.Command)
+		}
+	})
+
+	t.Run("a running pipeline is not orphaned", func(t *testing.T) {
+		workspace := t.TempDir()
+		sessionManager, err := NewSessionManager(workspace, nil)
+		require.NoError(t, err)
+		// Another process starting up looks for orphaned sessions mid-pipeline
+		other, err := NewSessionManager(workspace, nil)
+		require.NoError(t, err)
+		var flagged []*SessionInfo
+		client := &recordingLLMClient{texts: []string{
+			`Planning complete: {"tasks": [{"id": "a", "description": "add the flag"}]}`,
+			"done",
+			`Review complete. {"approved": true, "feedback": "looks good"}`,
+		}}
+		hooked := &hookedLLMClient{recordingLLMClient: client, before: func(call int) {
+			if call == 2 { // The executor's call, after the parent was saved as running
+				flagged, err = other.FlagOrphanedSessions()
+				require.NoError(t, err)
+			}
+		}}
+
+		o, err := NewMultiAgentOrchestrator(hooked, "test-model", testRoleSpecs()...)
+		require.NoError(t, err)
+		o.SetSessionManager(sessionManager)
+		result, err := o.Run(context.Background(), "add a verbose flag")
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+
+		for _, info := range flagged {
+			assert.NotEqual(t, result.SessionID, info.SessionID, "the parent session is locked while the pipeline runs")
+		}
+		parent, err := sessionManager.LoadSession(result.SessionID)
+		require.NoError(t, err)
+		assert.Equal(t, "completed", parent.CurrentState)
+		assert.False(t, other.IsSessionLocked(result.SessionID), "the lock is released when the pipeline ends")
+	})
+
+	t.Run("gives up after max review cycles", func(t *testing.T) {
+		client := &recordingLLMClient{texts: []string{
+			"summary: just do it",
+			"done",
+			"review complete, not convinced",
+		}}
+		o, err := NewMultiAgentOrchestrator(client, "test-model", testRoleSpecs()...)
+		require.NoError(t, err)
+		o.SetMaxReviewCycles(1)
+
+		result, err := o.Run(context.Background(), "refactor")
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Equal(t, []PipelineTask{{ID: "1", Description: "summary: just do it"}}, result.Tasks)
+		assert.Equal(t, "review complete, not convinced", result.Verdict.Feedback, "a response without a verdict is not an approval")
+		assert.Contains(t, result.Error, "1 review cycles")
+	})
+}
@@ -0,0 +1,88 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/detect"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/statedir"
+	"github.com/castrovroberto/CGE/internal/teamsync"
+)
+
+// maxProjectRulesBytes bounds the project rules added to the system prompt
+const maxProjectRulesBytes = 16 * 1024
+
+// runWorkspaceRoot returns the workspace root configured in the run context,
+// reporting false when there is no configuration
+func runWorkspaceRoot(ctx context.Context) (string, bool) {
+	cfg := contextkeys.ConfigPtrFromContext(ctx)
+	if cfg == nil {
+		return "", false
+	}
+	if cfg.Project.WorkspaceRoot == "" {
+		return ".", true
+	}
+	return cfg.Project.WorkspaceRoot, true
+}
+
+// runScope returns the subdirectory the run configured in the run context is
+// restricted to, relative to the workspace root; empty when it is not scoped
+func runScope(ctx context.Context) string {
+	cfg := contextkeys.ConfigPtrFromContext(ctx)
+	if cfg == nil || cfg.Project.Scope == "" {
+		return ""
+	}
+	workspaceRoot, _ := runWorkspaceRoot(ctx)
+	absRoot, err := filepath.Abs(workspaceRoot)
+	if err != nil {
+		return ""
+	}
+	scopeRoot, err := cfg.ScopeRoot()
+	if err != nil {
+		return ""
+	}
+	scope, err := filepath.Rel(absRoot, scopeRoot)
+	if err != nil || scope == "." {
+		return ""
+	}
+	return filepath.ToSlash(scope)
+}
+
+// projectProfile describes the workspace configured in the run context, or
+// the scope of the run, as detected from its manifests; empty when there is
+// no configuration, detection is off or nothing was detected
+func projectProfile(ctx context.Context) string {
+	cfg := contextkeys.ConfigPtrFromContext(ctx)
+	workspaceRoot, ok := runWorkspaceRoot(ctx)
+	if !ok || !cfg.Project.AutoDetect {
+		return ""
+	}
+	if scopeRoot, err := cfg.ScopeRoot(); err == nil {
+		workspaceRoot = scopeRoot
+	}
+	return strings.TrimSpace(detect.Cached(workspaceRoot).Markdown())
+}
+
+// projectRules returns the project rules of the workspace configured in the
+// run context: the team's shared rules from 'CGE sync', followed by the rules
+// file in its state directory; empty when there is no configuration or no
+// rules
+func projectRules(ctx context.Context) string {
+	workspaceRoot, ok := runWorkspaceRoot(ctx)
+	if !ok {
+		return ""
+	}
+	rules := teamsync.Rules(workspaceRoot)
+	if content, err := os.ReadFile(filepath.Join(statedir.Root(workspaceRoot), statedir.RulesFile)); err == nil {
+		rules = strings.TrimSpace(rules + "\n\n" + strings.TrimSpace(string(content)))
+	}
+	if len(rules) > maxProjectRulesBytes {
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Warn("Project rules are too long; only the start is used", "bytes", len(rules), "max", maxProjectRulesBytes)
+		rules = rules[:maxProjectRulesBytes]
+	}
+	return rules
+}
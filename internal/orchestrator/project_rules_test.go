@@ -0,0 +1,99 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/security"
+	"github.com/castrovroberto/CGE/internal/statedir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectRules(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.AppConfig{}
+	cfg.Project.WorkspaceRoot = workspace
+	ctx := context.WithValue(context.Background(), contextkeys.ConfigKey, cfg)
+
+	assert.Empty(t, projectRules(context.Background()), "no configuration means no rules")
+	assert.Empty(t, projectRules(ctx), "no rules file means no rules")
+
+	rulesPath := filepath.Join(statedir.Root(workspace), statedir.RulesFile)
+	require.NoError(t, os.MkdirAll(filepath.Dir(rulesPath), 0755))
+	require.NoError(t, os.WriteFile(rulesPath, []byte("\n- Use tabs\n\n"), 0644))
+	assert.Equal(t, "- Use tabs", projectRules(ctx))
+
+	require.NoError(t, os.WriteFile(rulesPath, []byte(strings.Repeat("x", maxProjectRulesBytes+10)), 0644))
+	assert.Len(t, projectRules(ctx), maxProjectRulesBytes)
+}
+
+func TestProjectProfile(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "Cargo.toml"), []byte("[dependencies]\naxum = \"0.7\"\n"), 0644))
+	cfg := &config.AppConfig{}
+	cfg.Project.WorkspaceRoot = workspace
+	ctx := context.WithValue(context.Background(), contextkeys.ConfigKey, cfg)
+
+	assert.Empty(t, projectProfile(ctx), "detection is off")
+	cfg.Project.AutoDetect = true
+	profile := projectProfile(ctx)
+	assert.Contains(t, profile, "- Languages: Rust")
+	assert.Contains(t, profile, "`cargo test`")
+
+	runner := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "system", "model")
+	runner.SetInjectionPolicy(security.InjectionPolicy{})
+	assert.Contains(t, runner.runSystemPrompt(ctx), "## Project\n\nDetected from the manifests of the workspace:\n\n- Languages: Rust")
+}
+
+func TestRunScope(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "services", "billing"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "services", "billing", "go.mod"), []byte("module billing\n"), 0644))
+	cfg := &config.AppConfig{}
+	cfg.Project.WorkspaceRoot = workspace
+	cfg.Project.AutoDetect = true
+	ctx := context.WithValue(context.Background(), contextkeys.ConfigKey, cfg)
+
+	assert.Empty(t, runScope(ctx))
+	assert.Empty(t, projectProfile(ctx), "the workspace root has no manifest")
+
+	cfg.Project.Scope = "services/billing/"
+	assert.Equal(t, "services/billing", runScope(ctx))
+	assert.Contains(t, projectProfile(ctx), "- Languages: Go", "the scope is detected on its own")
+
+	runner := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "system", "model")
+	runner.SetInjectionPolicy(security.InjectionPolicy{})
+	assert.Contains(t, runner.runSystemPrompt(ctx), "restricted to the services/billing/ directory")
+}
+
+func TestRunPathGuard(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.AppConfig{}
+	cfg.Project.WorkspaceRoot = workspace
+	ctx := context.WithValue(context.Background(), contextkeys.ConfigKey, cfg)
+	runner := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "system", "model")
+	runner.SetInjectionPolicy(security.InjectionPolicy{})
+
+	assert.Nil(t, runner.runPathGuard(context.Background()), "no configuration protects nothing")
+	assert.Nil(t, runner.runPathGuard(ctx), "no globs protect nothing")
+	assert.NotContains(t, runner.runSystemPrompt(ctx), "## Protected Files")
+
+	cfg.Tools.ProtectedPaths = []string{"vendor/**", "*.lock"}
+	glob, ok := runner.runPathGuard(ctx).Match(filepath.Join(workspace, "Cargo.lock"))
+	assert.True(t, ok)
+	assert.Equal(t, "*.lock", glob)
+	assert.Contains(t, runner.runSystemPrompt(ctx), "tools refuse to change them: vendor/**, *.lock")
+
+	runner.SetProtectedPaths(workspace, nil)
+	assert.Nil(t, runner.runPathGuard(ctx), "the setter overrides the configuration")
+
+	protected := &agent.ToolResult{StandardizedError: agent.NewProtectedPathError("Cargo.lock", "*.lock")}
+	assert.False(t, runner.shouldRetryToolCall(protected, 0, "sig"), "protected paths are never retried")
+}
@@ -0,0 +1,155 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
+)
+
+// Reasons a step tried on the small model is escalated to the configured one
+const (
+	EscalateError         = "error"
+	EscalateInvalid       = "invalid_response"
+	EscalateToolCall      = "tool_call"
+	EscalateLowConfidence = "low_confidence"
+)
+
+// defaultRoutingMaxPromptChars bounds the user messages considered simple
+// when the configuration does not
+const defaultRoutingMaxPromptChars = 500
+
+// RoutingDecision records a step that was tried on the small model
+type RoutingDecision struct {
+	Time        time.Time     `json:"time"`
+	SmallModel  string        `json:"small_model"`
+	StrongModel string        `json:"strong_model"`
+	Escalated   bool          `json:"escalated"`        // The strong model answered instead
+	Reason      string        `json:"reason,omitempty"` // Why it was escalated
+	PromptChars int           `json:"prompt_chars"`     // Length of the prompt sent
+	Duration    time.Duration `json:"duration"`         // Time spent on the small model
+}
+
+// lowConfidencePhrases reveal an answer the small model is unsure of
+var lowConfidencePhrases = []string{
+	"i'm not sure",
+	"i am not sure",
+	"i'm unsure",
+	"i don't know",
+	"i do not know",
+	"not certain",
+	"i cannot determine",
+	"i can't determine",
+	"unable to determine",
+	"i can't tell",
+	"without more context",
+	"without more information",
+}
+
+// SetRouting sets how simple steps are routed to a small model. Without it,
+// the routing of the configuration in the run context is used.
+func (ar *AgentRunner) SetRouting(routing config.RoutingConfig) {
+	ar.routing = &routing
+}
+
+// runRouting returns the small-model routing of a run; ok is false when
+// routing is off
+func (ar *AgentRunner) runRouting(ctx context.Context) (routing config.RoutingConfig, ok bool) {
+	if ar.routing != nil {
+		routing = *ar.routing
+	} else if cfg := contextkeys.ConfigPtrFromContext(ctx); cfg != nil {
+		routing = cfg.LLM.Routing
+	}
+	if !routing.Enabled || routing.SmallModel == "" || routing.SmallModel == ar.model {
+		return routing, false
+	}
+	if routing.MaxPromptChars <= 0 {
+		routing.MaxPromptChars = defaultRoutingMaxPromptChars
+	}
+	return routing, true
+}
+
+// routedGenerate asks the LLM for the next step. With routing on, a simple
+// step is first tried on the small model, and escalated to the configured
+// model when that fails, answers with a tool call or sounds unsure.
+func (ar *AgentRunner) routedGenerate(ctx context.Context, messages []Message, tools []llm.ToolDefinition) (*llm.FunctionCallResponse, error) {
+	systemPrompt, prompt := systemPromptOf(messages), ar.buildPromptFromMessages(messages)
+	routing, ok := ar.runRouting(ctx)
+	if !ok || !isSimpleStep(messages, routing.MaxPromptChars) {
+		return ar.generate(ctx, systemPrompt, prompt, tools)
+	}
+
+	decision := RoutingDecision{
+		Time:        time.Now(),
+		SmallModel:  routing.SmallModel,
+		StrongModel: ar.model,
+		PromptChars: len(prompt),
+	}
+	response, err := ar.llmClient.GenerateWithFunctions(ctx, routing.SmallModel, prompt, systemPrompt, tools)
+	decision.Duration = time.Since(decision.Time)
+	decision.Reason = escalationReason(response, err)
+	decision.Escalated = decision.Reason != ""
+	ar.recordRouting(ctx, decision)
+
+	if !decision.Escalated {
+		return response, nil
+	}
+	return ar.generate(ctx, systemPrompt, prompt, tools)
+}
+
+// isSimpleStep reports whether a step may go to the small model: no tool has
+// run yet and the latest user message is short
+func isSimpleStep(messages []Message, maxPromptChars int) bool {
+	var lastUser string
+	for _, msg := range messages {
+		if msg.Role == "tool" || msg.ToolCall != nil {
+			return false
+		}
+		if msg.Role == "user" {
+			lastUser = msg.Content
+		}
+	}
+	return lastUser != "" && len([]rune(lastUser)) <= maxPromptChars
+}
+
+// escalationReason returns why a small model response cannot be used, or ""
+// when it can
+func escalationReason(response *llm.FunctionCallResponse, err error) string {
+	switch {
+	case err != nil:
+		return EscalateError
+	case response == nil:
+		return EscalateInvalid
+	case !response.IsTextResponse:
+		return EscalateToolCall
+	case strings.TrimSpace(response.TextContent) == "":
+		return EscalateInvalid
+	}
+	text := strings.ToLower(response.TextContent)
+	for _, phrase := range lowConfidencePhrases {
+		if strings.Contains(text, phrase) {
+			return EscalateLowConfidence
+		}
+	}
+	return ""
+}
+
+// recordRouting logs a routing decision and adds it to the current session
+func (ar *AgentRunner) recordRouting(ctx context.Context, decision RoutingDecision) {
+	log := contextkeys.LoggerFor(ctx, logger.Orchestrator)
+	if decision.Escalated {
+		log.Info("Escalated step to the configured model", "small_model", decision.SmallModel, "model", decision.StrongModel, "reason", decision.Reason)
+	} else {
+		log.Debug("Step answered by the small model", "small_model", decision.SmallModel)
+	}
+	if ar.currentSession == nil {
+		return
+	}
+	ar.sessionMu.Lock()
+	defer ar.sessionMu.Unlock()
+	ar.currentSession.Routing = append(ar.currentSession.Routing, decision)
+}
@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// modelClient answers each model with its own scripted responses and records
+// which models were asked
+type modelClient struct {
+	MockLLMClient
+	responses map[string][]*llm.FunctionCallResponse
+	asked     []string
+}
+
+func (m *modelClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []llm.ToolDefinition) (*llm.FunctionCallResponse, error) {
+	m.asked = append(m.asked, modelName)
+	queue := m.responses[modelName]
+	if len(queue) == 0 {
+		return &llm.FunctionCallResponse{IsTextResponse: true, TextContent: "Task completed by " + modelName}, nil
+	}
+	m.responses[modelName] = queue[1:]
+	return queue[0], nil
+}
+
+func TestRoutingAnswersSimpleStepsWithSmallModel(t *testing.T) {
+	client := &modelClient{responses: map[string][]*llm.FunctionCallResponse{}}
+	runner := NewAgentRunner(client, agent.NewRegistry(), "You are a helpful assistant", "strong")
+	runner.SetRouting(config.RoutingConfig{Enabled: true, SmallModel: "small", MaxPromptChars: 100})
+
+	result, err := runner.Run(context.Background(), "What does main.go do?")
+	require.NoError(t, err)
+	assert.Equal(t, "Task completed by small", result.FinalResponse)
+	assert.Equal(t, []string{"small"}, client.asked)
+}
+
+func TestRoutingEscalates(t *testing.T) {
+	tool := &countingTool{name: "read_file"}
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(tool))
+	toolCall := &llm.FunctionCallResponse{FunctionCall: &llm.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"file_path": "main.go"}`), ID: "call_1"}}
+
+	client := &modelClient{responses: map[string][]*llm.FunctionCallResponse{
+		"small":  {toolCall},
+		"strong": {toolCall},
+	}}
+	sessions, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+	runner := NewAgentRunnerWithSession(client, registry, "You are a helpful assistant", "strong", sessions)
+	runner.SetRouting(config.RoutingConfig{Enabled: true, SmallModel: "small", MaxPromptChars: 100})
+
+	result, err := runner.RunWithCommand(context.Background(), "Fix main.go", "generate")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, []string{"small", "strong", "strong"}, client.asked, "steps after a tool call skip the small model")
+	assert.Equal(t, 1, tool.calls)
+
+	require.Len(t, runner.currentSession.Routing, 1)
+	decision := runner.currentSession.Routing[0]
+	assert.True(t, decision.Escalated)
+	assert.Equal(t, EscalateToolCall, decision.Reason)
+	assert.Equal(t, "strong", decision.StrongModel)
+}
+
+func TestEscalationReason(t *testing.T) {
+	assert.Equal(t, "", escalationReason(&llm.FunctionCallResponse{IsTextResponse: true, TextContent: "It prints hello."}, nil))
+	assert.Equal(t, EscalateInvalid, escalationReason(&llm.FunctionCallResponse{IsTextResponse: true, TextContent: "  "}, nil))
+	assert.Equal(t, EscalateLowConfidence, escalationReason(&llm.FunctionCallResponse{IsTextResponse: true, TextContent: "I'm not sure what it does."}, nil))
+	assert.Equal(t, EscalateError, escalationReason(nil, assert.AnError))
+}
+
+func TestIsSimpleStep(t *testing.T) {
+	messages := []Message{{Role: "system", Content: "long system prompt"}, {Role: "user", Content: "Hi"}}
+	assert.True(t, isSimpleStep(messages, 10))
+	assert.False(t, isSimpleStep(messages, 1), "long user message")
+	messages = append(messages, Message{Role: "tool", Content: "{}"})
+	assert.False(t, isSimpleStep(messages, 10), "a tool has run")
+}
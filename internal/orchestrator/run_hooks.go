@@ -0,0 +1,130 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/hooks"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
+)
+
+// testTools are the tools whose failures fire tests_failed
+var testTools = map[string]bool{"run_tests": true, "run_tests_with_coverage": true}
+
+// SetHooks sets the hooks run on the lifecycle events of the runner's runs;
+// nil runs none. Without it, the [[hooks]] of the configuration in the run
+// context are used.
+func (ar *AgentRunner) SetHooks(dispatcher *hooks.Dispatcher) {
+	ar.hooks = dispatcher
+	ar.hooksSet = true
+}
+
+// runHooks returns the hooks of a run, or nil when there are none
+func (ar *AgentRunner) runHooks(ctx context.Context) *hooks.Dispatcher {
+	if ar.hooksSet {
+		return ar.hooks
+	}
+	cfg := contextkeys.ConfigPtrFromContext(ctx)
+	if cfg == nil || len(cfg.Hooks) == 0 {
+		return nil
+	}
+	workspaceRoot, _ := runWorkspaceRoot(ctx)
+	return hooks.New(workspaceRoot, cfg.GetHooks())
+}
+
+// fireHook runs the hooks of event, filling in what every event carries.
+// Failing hooks are logged; the *hooks.RejectedError of a blocking hook that
+// refused the event is returned.
+func (ar *AgentRunner) fireHook(ctx context.Context, event hooks.Event) error {
+	dispatcher := ar.runHooks(ctx)
+	if dispatcher == nil {
+		return nil
+	}
+	event.Command = ar.runCommand
+	event.SessionID = ar.GetCurrentSessionID()
+	if workspaceRoot, ok := runWorkspaceRoot(ctx); ok {
+		if abs, err := filepath.Abs(workspaceRoot); err == nil {
+			workspaceRoot = abs
+		}
+		event.Workspace = workspaceRoot
+	}
+
+	err := dispatcher.Fire(ctx, event)
+	var rejected *hooks.RejectedError
+	if errors.As(err, &rejected) {
+		return rejected
+	}
+	if err != nil {
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Warn("Hook failed", "event", event.Event, "error", err)
+	}
+	return nil
+}
+
+// fireRunCompleted runs the run_completed hooks of a run that took elapsed.
+// They run even when the run was cancelled.
+func (ar *AgentRunner) fireRunCompleted(ctx context.Context, result *RunResult, runErr error, elapsed time.Duration) {
+	event := hooks.Event{Event: hooks.RunCompleted, Status: hooks.StatusFailed, DurationMS: elapsed.Milliseconds()}
+	switch {
+	case runErr != nil:
+		event.Error = runErr.Error()
+	case result != nil:
+		event.Error = result.Error
+		event.Iterations = result.Iterations
+		event.ToolCalls = result.ToolCalls
+		if result.Success {
+			event.Status = hooks.StatusSucceeded
+		}
+	}
+	_ = ar.fireHook(context.WithoutCancel(ctx), event)
+}
+
+// firePreTool runs the pre_tool hooks of a call, returning the result of a
+// call refused by a blocking hook, or nil to run it
+func (ar *AgentRunner) firePreTool(ctx context.Context, call *llm.FunctionCall) *agent.ToolResult {
+	err := ar.fireHook(ctx, hooks.Event{Event: hooks.PreTool, Tool: call.Name, Arguments: call.Arguments})
+	if err == nil {
+		return nil
+	}
+	message := fmt.Sprintf("%s was %s", call.Name, err)
+	return &agent.ToolResult{
+		Success:           false,
+		Error:             message,
+		StandardizedError: agent.NewStandardizedError(agent.ErrorCodePermissionDenied, message, "Do not retry this call as it is: a project policy refused it. Follow the reason given, take another approach, or tell the user what is needed."),
+	}
+}
+
+// fireToolHooks runs the post_tool hooks of a call that ran for elapsed, and
+// the file_written or tests_failed hooks its outcome calls for
+func (ar *AgentRunner) fireToolHooks(ctx context.Context, call *llm.FunctionCall, elapsed time.Duration, result *agent.ToolResult, err error) {
+	if ar.runHooks(ctx) == nil {
+		return
+	}
+	event := hooks.Event{Event: hooks.PostTool, Tool: call.Name, Arguments: call.Arguments, Status: hooks.StatusFailed, DurationMS: elapsed.Milliseconds()}
+	switch {
+	case err != nil:
+		event.Error = err.Error()
+	case result == nil:
+		event.Error = "tool returned no result"
+	case result.Success:
+		event.Status = hooks.StatusSucceeded
+	default:
+		event.Error = result.Error
+	}
+	_ = ar.fireHook(ctx, event)
+
+	if event.Status == hooks.StatusSucceeded {
+		if checkpoint, file, ok := toolCheckpoint(call); ok && checkpoint == CheckpointFileChange {
+			_ = ar.fireHook(ctx, hooks.Event{Event: hooks.FileWritten, Tool: call.Name, File: file})
+		}
+		return
+	}
+	if testTools[call.Name] && result != nil {
+		_ = ar.fireHook(ctx, hooks.Event{Event: hooks.TestsFailed, Tool: call.Name, Arguments: call.Arguments, Error: result.Error, Result: result.Data})
+	}
+}
@@ -0,0 +1,84 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/hooks"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRunnerHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks use POSIX shell commands")
+	}
+	workspace := t.TempDir()
+	tool := &countingTool{name: "write_file"}
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(tool))
+
+	client := &MockLLMClient{responses: []*llm.FunctionCallResponse{{
+		FunctionCall: &llm.FunctionCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path": "main.go", "content": "x"}`), ID: "call_1"},
+	}}}
+	runner := NewAgentRunner(client, registry, "You are a helpful assistant", "mock-model")
+	runner.SetHooks(hooks.New(workspace, []hooks.Hook{{
+		Events:  hooks.Events,
+		Command: `echo "$CGE_HOOK_EVENT $CGE_HOOK_TOOL $CGE_HOOK_FILE" >> events.log`,
+	}}))
+
+	result, err := runner.RunWithCommand(context.Background(), "Fix main.go", "generate")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+
+	log, err := os.ReadFile(filepath.Join(workspace, "events.log"))
+	require.NoError(t, err)
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(string(log)), "\n") {
+		events = append(events, strings.Join(strings.Fields(line), " "))
+	}
+	assert.Equal(t, []string{
+		"run_started",
+		"pre_tool write_file",
+		"post_tool write_file",
+		"file_written write_file main.go",
+		"run_completed",
+	}, events)
+	assert.Equal(t, 1, tool.calls)
+}
+
+func TestAgentRunnerBlockingHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks use POSIX shell commands")
+	}
+	tool := &countingTool{name: "write_file"}
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(tool))
+	call := &llm.FunctionCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path": "go.sum", "content": "x"}`), ID: "call_1"}
+
+	runner := NewAgentRunner(&MockLLMClient{responses: []*llm.FunctionCallResponse{{FunctionCall: call}}}, registry, "You are a helpful assistant", "mock-model")
+	runner.SetHooks(hooks.New(t.TempDir(), []hooks.Hook{{
+		Name:     "lockfiles",
+		Events:   []string{hooks.PreTool},
+		Command:  `grep -q go.sum && echo "edit go.mod instead" && exit 1; exit 0`,
+		Blocking: true,
+	}}))
+	result, err := runner.Run(context.Background(), "Fix go.sum")
+	require.NoError(t, err)
+	assert.Zero(t, tool.calls, "refused calls do not run")
+	toolMessage := result.Messages[len(result.Messages)-2].Content
+	assert.Contains(t, toolMessage, "PERMISSION_DENIED")
+	assert.Contains(t, toolMessage, "refused by hook lockfiles: edit go.mod instead")
+
+	runner = NewAgentRunner(&MockLLMClient{}, registry, "You are a helpful assistant", "mock-model")
+	runner.SetHooks(hooks.New(t.TempDir(), []hooks.Hook{{Events: []string{hooks.RunStarted}, Command: "echo outside working hours; exit 1", Blocking: true}}))
+	_, err = runner.Run(context.Background(), "Fix go.sum")
+	assert.ErrorContains(t, err, "run refused by hook echo outside working hours; exit 1: outside working hours")
+}
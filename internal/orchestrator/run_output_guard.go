@@ -0,0 +1,176 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/textutils"
+)
+
+// maxSummarizedChars caps how much of the omitted middle of a tool result
+// the summarizer is given
+const maxSummarizedChars = 16000
+
+// maxNotableLines is how many lines mentioning errors are quoted from the
+// omitted middle when it is not summarized
+const maxNotableLines = 20
+
+// notableLine matches the lines of a long output worth keeping: errors,
+// failures and warnings
+var notableLine = regexp.MustCompile(`(?i)\b(error|fail(ed|ure)?|panic|fatal|exception|warn(ing)?)\b`)
+
+// SetOutputLimits sets the size guard of the tool results the runner adds
+// to the conversation. Without it, the [tools.output] limits of the
+// configuration in the run context are used.
+func (ar *AgentRunner) SetOutputLimits(limits agent.OutputLimits) {
+	ar.outputLimits = limits
+	ar.outputLimitsSet = true
+}
+
+// runOutputLimits returns the size guard of the tool results of a run
+func (ar *AgentRunner) runOutputLimits(ctx context.Context) agent.OutputLimits {
+	if ar.outputLimitsSet {
+		return ar.outputLimits
+	}
+	if cfg := contextkeys.ConfigPtrFromContext(ctx); cfg != nil {
+		return cfg.GetOutputLimits()
+	}
+	return agent.DefaultOutputLimits()
+}
+
+// guardToolData truncates the text of a tool result that is too large for
+// the conversation, before the result is formatted: its data when it is a
+// string, or the strings of its data, such as the output of a command. Their
+// line breaks are still intact, so the head and tail are cut at lines and the
+// saved output can be searched line by line.
+func (ar *AgentRunner) guardToolData(ctx context.Context, toolName string, result *agent.ToolResult) *agent.ToolResult {
+	if result == nil || toolName == agent.ReadToolOutputName {
+		return result
+	}
+	switch data := result.Data.(type) {
+	case string:
+		guarded := *result
+		guarded.Data = ar.guardToolOutput(ctx, toolName, data)
+		return &guarded
+	case map[string]interface{}:
+		var fields map[string]interface{}
+		for key, value := range data {
+			text, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if truncated := ar.guardToolOutput(ctx, toolName, text); truncated != text {
+				if fields == nil {
+					fields = make(map[string]interface{}, len(data))
+					for k, v := range data {
+						fields[k] = v
+					}
+				}
+				fields[key] = truncated
+			}
+		}
+		if fields != nil {
+			guarded := *result
+			guarded.Data = fields
+			return &guarded
+		}
+	}
+	return result
+}
+
+// guardToolOutput truncates a tool result too large for the conversation to
+// its head and tail. The omitted middle is summarized, and the full result is
+// saved so the model can read more of it with read_tool_output.
+func (ar *AgentRunner) guardToolOutput(ctx context.Context, toolName, content string) string {
+	if toolName == agent.ReadToolOutputName {
+		return content // Bounded by the tool, and reading it again would loop
+	}
+	limits := ar.runOutputLimits(ctx)
+	head, middle, tail := agent.SplitOutput(content, limits)
+	if middle == "" {
+		return content
+	}
+	log := contextkeys.LoggerFor(ctx, logger.Tools)
+
+	var b strings.Builder
+	b.WriteString(head)
+	if !strings.HasSuffix(head, "\n") {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "[... %d characters (%d lines) omitted ...]\n", len(middle), strings.Count(middle, "\n")+1)
+	if summary := ar.summarizeOmitted(ctx, middle, limits); summary != "" {
+		b.WriteString(summary)
+		b.WriteString("\n")
+	}
+	if dir, ok := ar.toolOutputDir(ctx); ok {
+		id, err := agent.SaveToolOutput(dir, content)
+		if err == nil {
+			fmt.Fprintf(&b, "[The full output (%d characters) is saved as %s; call %s with {\"output_id\": %q} and an offset, or a pattern, to read the omitted part.]\n",
+				len(content), id, agent.ReadToolOutputName, id)
+		} else {
+			log.Warn("Failed to save truncated tool output", "tool", toolName, "error", err)
+		}
+	}
+	b.WriteString(tail)
+
+	log.Info("Truncated large tool output", "tool", toolName, "chars", len(content), "kept", len(head)+len(tail))
+	return b.String()
+}
+
+// summarizeOmitted describes the middle omitted from a tool result: a
+// summary by the model when enabled, else the lines mentioning errors
+func (ar *AgentRunner) summarizeOmitted(ctx context.Context, middle string, limits agent.OutputLimits) string {
+	var notable []string
+	size := 0
+	for _, line := range strings.Split(middle, "\n") {
+		if notableLine.MatchString(line) && size+len(line) <= maxSummarizedChars {
+			notable = append(notable, line)
+			size += len(line) + 1
+		}
+	}
+
+	// Errors are what the model needs from a long log; outputs without any
+	// are summarized from their start. Excerpts short enough to quote are
+	// quoted instead.
+	options := textutils.DefaultSummaryOptions()
+	options.MaxLength = 1000
+	options.Style = "brief"
+	excerpt := strings.Join(notable, "\n")
+	if excerpt == "" {
+		excerpt = middle[:min(len(middle), maxSummarizedChars)]
+	}
+	if limits.Summarize && ar.llmClient != nil && len(excerpt) > options.MaxLength {
+		summary, err := textutils.NewSummarizer(ar.llmClient, ar.model, options).SummarizeText(ctx, excerpt)
+		if err == nil && summary != "" {
+			return "Summary of the omitted part: " + summary
+		}
+		contextkeys.LoggerFor(ctx, logger.Tools).Debug("Failed to summarize truncated tool output", "error", err)
+	}
+
+	if len(notable) == 0 {
+		return ""
+	}
+	quoted := notable[:min(len(notable), maxNotableLines)]
+	text := "Lines of the omitted part mentioning errors:\n" + strings.Join(quoted, "\n")
+	if len(notable) > len(quoted) {
+		text += fmt.Sprintf("\n(%d more)", len(notable)-len(quoted))
+	}
+	return text
+}
+
+// toolOutputDir returns the directory the full outputs of truncated tool
+// results of a run are saved in, where read_tool_output finds them
+func (ar *AgentRunner) toolOutputDir(ctx context.Context) (string, bool) {
+	if workspaceRoot, ok := runWorkspaceRoot(ctx); ok {
+		return agent.ToolOutputDir(workspaceRoot), true
+	}
+	if ar.sessionManager != nil {
+		return agent.ToolOutputDir(ar.sessionManager.workspaceRoot), true
+	}
+	return "", false
+}
@@ -0,0 +1,125 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLog returns a long test log with a single failure in its middle
+func testLog() string {
+	var b strings.Builder
+	for i := 1; i <= 3000; i++ {
+		if i == 1500 {
+			b.WriteString("--- FAIL: TestCheckout (0.02s)\n")
+			continue
+		}
+		fmt.Fprintf(&b, "=== RUN   TestCase%04d\n--- PASS: TestCase%04d (0.00s)\n", i, i)
+	}
+	return b.String()
+}
+
+func TestGuardToolOutput(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.AppConfig{}
+	cfg.Project.WorkspaceRoot = workspace
+	ctx := context.WithValue(context.Background(), contextkeys.ConfigKey, cfg)
+
+	logTool := agent.NewTypedTool("run_tests", "runs the tests", func(ctx context.Context, params struct{}) (interface{}, error) {
+		return testLog(), nil
+	})
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(logTool))
+	client := &MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{Name: "run_tests", Arguments: json.RawMessage(`{}`), ID: "call_1"}},
+	}}
+	runner := NewAgentRunner(client, registry, "You are a helpful assistant", "mock-model")
+	runner.SetOutputLimits(agent.OutputLimits{MaxChars: 4000, HeadChars: 1000, TailChars: 1000})
+
+	result, err := runner.Run(ctx, "Run the tests")
+	require.NoError(t, err)
+	var content string
+	for _, message := range result.Messages {
+		if message.Role == "tool" {
+			content = message.Content
+		}
+	}
+	assert.Less(t, len(content), 4000)
+	assert.True(t, strings.HasPrefix(content, `"=== RUN   TestCase0001`), "the head is kept")
+	assert.Contains(t, content, "TestCase3000 (0.00s)", "the tail is kept")
+	assert.Contains(t, content, "characters (")
+	assert.Contains(t, content, `Lines of the omitted part mentioning errors:\n--- FAIL: TestCheckout (0.02s)`)
+
+	id := regexp.MustCompile(`out_[0-9a-f]+`).FindString(content)
+	require.NotEmpty(t, id, "the full output is saved")
+	read, err := agent.NewReadToolOutputTool(workspace).Execute(ctx, json.RawMessage(`{"output_id": "`+id+`", "pattern": "FAIL"}`))
+	require.NoError(t, err)
+	require.True(t, read.Success)
+	assert.Equal(t, []string{"2999: --- FAIL: TestCheckout (0.02s)"}, read.Data.(map[string]interface{})["matches"], "the saved output keeps its lines")
+}
+
+func TestGuardToolOutputScoped(t *testing.T) {
+	workspace := t.TempDir()
+	scopeRoot := filepath.Join(workspace, "services", "api")
+	require.NoError(t, os.MkdirAll(scopeRoot, 0755))
+	cfg := &config.AppConfig{}
+	cfg.Project.WorkspaceRoot = workspace
+	cfg.Project.Scope = "services/api"
+	ctx := context.WithValue(context.Background(), contextkeys.ConfigKey, cfg)
+
+	logTool := agent.NewTypedTool("run_tests", "runs the tests", func(ctx context.Context, params struct{}) (interface{}, error) {
+		return testLog(), nil
+	})
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(logTool))
+	require.NoError(t, registry.Register(agent.NewReadToolOutputTool(scopeRoot)))
+
+	client := &MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{Name: "run_tests", Arguments: json.RawMessage(`{}`), ID: "call_1"}},
+	}}
+	runner := NewAgentRunner(client, registry, "You are a helpful assistant", "mock-model")
+	runner.SetOutputLimits(agent.OutputLimits{MaxChars: 4000, HeadChars: 1000, TailChars: 1000})
+	result, err := runner.Run(ctx, "Run the tests")
+	require.NoError(t, err)
+	id := regexp.MustCompile(`out_[0-9a-f]+`).FindString(result.Messages[len(result.Messages)-2].Content)
+	require.NotEmpty(t, id, "the full output is saved")
+
+	client.responses = []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{Name: agent.ReadToolOutputName, Arguments: json.RawMessage(`{"output_id": "` + id + `", "pattern": "FAIL"}`), ID: "call_2"}},
+	}
+	client.callIndex = 0
+	result, err = runner.Run(ctx, "Find the failures")
+	require.NoError(t, err)
+	assert.Contains(t, result.Messages[len(result.Messages)-2].Content, "--- FAIL: TestCheckout (0.02s)", "read_tool_output of a scoped run finds the outputs the runner saved")
+}
+
+func TestGuardToolOutputSummarizes(t *testing.T) {
+	runner := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "You are a helpful assistant", "mock-model")
+	runner.SetOutputLimits(agent.OutputLimits{MaxChars: 4000, HeadChars: 1000, TailChars: 1000, Summarize: true})
+	ctx := context.Background()
+
+	content := runner.guardToolOutput(ctx, "run_tests", testLog())
+	assert.Contains(t, content, "Lines of the omitted part mentioning errors:\n--- FAIL: TestCheckout (0.02s)\n", "a single failure is quoted rather than summarized")
+	assert.NotContains(t, content, "out_", "without a workspace the output is not saved")
+
+	failures := strings.Repeat("--- FAIL: TestFlaky (0.01s)\n    flaky_test.go:12: connection refused\n", 200)
+	content = runner.guardToolOutput(ctx, "run_tests", failures)
+	assert.Contains(t, content, "Summary of the omitted part: Mock response")
+
+	short := "ok  \tgithub.com/acme/shop\t0.2s"
+	assert.Equal(t, short, runner.guardToolOutput(ctx, "run_tests", short))
+	long := testLog()
+	assert.Equal(t, long, runner.guardToolOutput(ctx, agent.ReadToolOutputName, long), "read_tool_output is bounded by itself")
+}
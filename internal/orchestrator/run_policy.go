@@ -0,0 +1,144 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/policy"
+)
+
+// SetPolicy sets the guardrails the runner's tool calls are checked against;
+// nil checks none. Without it, the [policy] of the configuration in the run
+// context is used.
+func (ar *AgentRunner) SetPolicy(engine *policy.Engine) {
+	ar.policy = engine
+	ar.policySet = true
+}
+
+// runPolicy returns the policy engine of a run, or nil when there are no
+// policies. A configuration whose policies cannot be loaded denies every call.
+func (ar *AgentRunner) runPolicy(ctx context.Context) (*policy.Engine, error) {
+	engine := ar.policy
+	if !ar.policySet {
+		cfg := contextkeys.ConfigPtrFromContext(ctx)
+		if cfg == nil {
+			return nil, nil
+		}
+		workspaceRoot, _ := runWorkspaceRoot(ctx)
+		var err error
+		if engine, err = policy.New(workspaceRoot, cfg.GetPolicy()); err != nil {
+			return nil, err
+		}
+	}
+	if engine != nil && ar.sessionManager != nil && ar.sessionManager.auditLogger != nil {
+		engine.SetAuditLogger(ar.sessionManager.auditLogger)
+	}
+	return engine, nil
+}
+
+// checkPolicy evaluates a tool call, and the commit of a committing one,
+// against the policies of the run. It returns the result of a denied call,
+// or nil to run it.
+func (ar *AgentRunner) checkPolicy(ctx context.Context, call *llm.FunctionCall) *agent.ToolResult {
+	engine, err := ar.runPolicy(ctx)
+	if err != nil {
+		return policyDenied(call, &policy.Denial{Rule: "configuration", Action: policy.ActionToolCall, Tool: call.Name, Reason: "the policies could not be loaded: " + err.Error()})
+	}
+	if engine == nil {
+		return nil
+	}
+
+	var params struct {
+		FilePath     string   `json:"file_path"`
+		Path         string   `json:"path"`
+		Command      string   `json:"command"`
+		Code         string   `json:"code"`
+		Message      string   `json:"commit_message"`
+		FilesToStage []string `json:"files_to_stage"`
+	}
+	_ = json.Unmarshal(call.Arguments, &params)
+	in := policy.Input{
+		Action:    policy.ActionToolCall,
+		Tool:      call.Name,
+		Arguments: call.Arguments,
+		SessionID: ar.GetCurrentSessionID(),
+	}
+	checkpoint, _, ok := toolCheckpoint(call)
+	if ok && checkpoint == CheckpointFileChange {
+		for _, file := range []string{params.FilePath, params.Path} {
+			if file != "" {
+				in.Files = append(in.Files, file)
+			}
+		}
+	}
+	switch call.Name {
+	case "run_shell_command":
+		in.Command = params.Command
+	case "run_snippet":
+		// deny_commands also apply to what a snippet runs
+		in.Command = params.Code
+	}
+	if denial := engine.Check(ctx, in); denial != nil {
+		return policyDenied(call, denial)
+	}
+
+	if ok && checkpoint == CheckpointCommit {
+		in.Action, in.Files, in.CommitMessage = policy.ActionCommit, params.FilesToStage, params.Message
+		if denial := engine.Check(ctx, in); denial != nil {
+			return policyDenied(call, denial)
+		}
+	}
+	return nil
+}
+
+// policyDenied returns the result reported for a call a policy denied
+func policyDenied(call *llm.FunctionCall, denial *policy.Denial) *agent.ToolResult {
+	err := policyDenialError(call, denial)
+	return &agent.ToolResult{
+		Success:           false,
+		Error:             err.Message,
+		Data:              map[string]interface{}{"policy_denial": denial},
+		StandardizedError: err,
+	}
+}
+
+// policyDenialError returns the error of a call a policy denied
+func policyDenialError(call *llm.FunctionCall, denial *policy.Denial) *agent.StandardizedToolError {
+	return agent.NewStandardizedError(agent.ErrorCodePolicyDenied, call.Name+" was "+denial.Error(), "Do not retry this call as it is: an organizational policy forbids it. Take an approach the policy allows, or tell the user what is needed.")
+}
+
+// callPathPolicy checks the files a tool call changes against the deny_paths
+// of the policies, as the tool reaches them
+type callPathPolicy struct {
+	engine *policy.Engine
+	call   *llm.FunctionCall
+	in     policy.Input
+}
+
+// runPathPolicy returns the path policy of a tool call, or nil when the run
+// has no policies
+func (ar *AgentRunner) runPathPolicy(ctx context.Context, call *llm.FunctionCall) agent.PathPolicy {
+	engine, err := ar.runPolicy(ctx)
+	if err != nil || engine == nil {
+		// Calls are denied before they run when the policies cannot be loaded
+		return nil
+	}
+	return &callPathPolicy{engine: engine, call: call, in: policy.Input{
+		Action:    policy.ActionToolCall,
+		Tool:      call.Name,
+		Arguments: call.Arguments,
+		SessionID: ar.GetCurrentSessionID(),
+	}}
+}
+
+// CheckChange implements agent.PathPolicy
+func (p *callPathPolicy) CheckChange(ctx context.Context, absPath, displayPath string) *agent.StandardizedToolError {
+	denial := p.engine.CheckFile(ctx, p.in, absPath)
+	if denial == nil {
+		return nil
+	}
+	return policyDenialError(p.call, denial).WithDetail("file_path", displayPath).WithDetail("policy_denial", denial)
+}
@@ -0,0 +1,110 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRunnerPolicy(t *testing.T) {
+	writeTool := &countingTool{name: "write_file"}
+	commitTool := &countingTool{name: "git_commit"}
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(writeTool))
+	require.NoError(t, registry.Register(commitTool))
+
+	engine, err := policy.New(t.TempDir(), policy.Config{Rules: []policy.Rule{
+		{Name: "prod-configs", DenyPaths: []string{"config/prod/**"}},
+		{Name: "ticket", CommitMessage: `[A-Z]+-[0-9]+`},
+	}})
+	require.NoError(t, err)
+
+	client := &MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path": "config/prod/app.yaml", "content": "x"}`), ID: "call_1"}},
+		{FunctionCall: &llm.FunctionCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path": "config/dev/app.yaml", "content": "x"}`), ID: "call_2"}},
+		{FunctionCall: &llm.FunctionCall{Name: "git_commit", Arguments: json.RawMessage(`{"commit_message": "Tune the pool size"}`), ID: "call_3"}},
+	}}
+	runner := NewAgentRunner(client, registry, "You are a helpful assistant", "mock-model")
+	runner.SetPolicy(engine)
+
+	result, err := runner.Run(context.Background(), "Tune the pool size")
+	require.NoError(t, err)
+	assert.Equal(t, 1, writeTool.calls, "only the allowed write runs")
+	assert.Zero(t, commitTool.calls, "commits without a ticket are denied")
+
+	var toolMessages []string
+	for _, message := range result.Messages {
+		if message.Role == "tool" {
+			toolMessages = append(toolMessages, message.Content)
+		}
+		assert.NotContains(t, message.Content, "Retry 1 for", "denied calls are not retried")
+	}
+	require.Len(t, toolMessages, 3)
+	assert.Contains(t, toolMessages[0], "write_file was denied by policy prod-configs: changing config/prod/app.yaml matches config/prod/**")
+	assert.NotContains(t, toolMessages[1], "denied")
+	assert.Contains(t, toolMessages[2], "git_commit was denied by policy ticket: the commit message must match [A-Z]+-[0-9]+")
+}
+
+func TestPolicyDenialsAreNotRetried(t *testing.T) {
+	runner := NewAgentRunner(&MockLLMClient{}, agent.NewRegistry(), "You are a helpful assistant", "mock-model")
+	runner.config.RetryWithModification = true
+
+	call := &llm.FunctionCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path": "config/prod/app.yaml"}`)}
+	denied := policyDenied(call, &policy.Denial{Rule: "prod-configs", Reason: "changing config/prod/app.yaml matches config/prod/**"})
+	assert.False(t, runner.shouldRetryToolCall(denied, 0, "sig"), "a retry would be denied again")
+}
+
+func TestPolicyDenyPathsAreEnforcedByTools(t *testing.T) {
+	workspace := t.TempDir()
+	prodConfig := filepath.Join(workspace, "config", "prod", "app.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(prodConfig), 0755))
+	require.NoError(t, os.WriteFile(prodConfig, []byte("pool: 10\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "app.yaml"), []byte("pool: 10\n"), 0644))
+
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(agent.NewSearchReplaceTool(workspace)))
+	require.NoError(t, registry.Register(agent.NewShellRunTool(workspace)))
+	require.NoError(t, registry.Register(agent.NewPatchApplyTool(workspace)))
+	engine, err := policy.New(workspace, policy.Config{Rules: []policy.Rule{
+		{Name: "prod-configs", DenyPaths: []string{"config/prod/**", "go.sum"}},
+	}})
+	require.NoError(t, err)
+
+	patch := "--- a/config/prod/app.yaml\n+++ b/config/prod/app.yaml\n@@ -1 +1 @@\n-pool: 10\n+pool: 20\n"
+	patchArgs, _ := json.Marshal(map[string]string{"file_path": "app.yaml", "patch_content": patch})
+	client := &MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{Name: "search_replace", Arguments: json.RawMessage(`{"pattern": "pool: 10", "replacement": "pool: 20", "path": "."}`), ID: "call_1"}},
+		{FunctionCall: &llm.FunctionCall{Name: "run_shell_command", Arguments: json.RawMessage(`{"command": "go mod tidy"}`), ID: "call_2"}},
+		{FunctionCall: &llm.FunctionCall{Name: "apply_patch_to_file", Arguments: patchArgs, ID: "call_3"}},
+	}}
+	runner := NewAgentRunner(client, registry, "You are a helpful assistant", "mock-model")
+	runner.SetPolicy(engine)
+
+	result, err := runner.Run(context.Background(), "Raise the pool size")
+	require.NoError(t, err)
+
+	var toolMessages []string
+	for _, message := range result.Messages {
+		if message.Role == "tool" {
+			toolMessages = append(toolMessages, message.Content)
+		}
+	}
+	require.Len(t, toolMessages, 3)
+	assert.Contains(t, toolMessages[0], "search_replace was denied by policy prod-configs: changing config/prod/app.yaml matches config/prod/**")
+	assert.Contains(t, toolMessages[1], "run_shell_command was denied by policy prod-configs: changing go.sum matches go.sum")
+	assert.Contains(t, toolMessages[2], "apply_patch_to_file was denied by policy prod-configs")
+
+	for _, file := range []string{prodConfig, filepath.Join(workspace, "app.yaml")} {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, "pool: 10\n", string(content), "a denied call changes nothing")
+	}
+}
@@ -0,0 +1,63 @@
+package orchestrator
+
+import (
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// maxScratchpadDigestChars bounds the digest of the scratchpad sent with
+// each step; longer notes are read with scratchpad_read
+const maxScratchpadDigestChars = 3000
+
+// scratchpadTools are offered in every run, whatever its registry and
+// allowed tools, as they only touch the runner's scratchpad
+var scratchpadTools = agent.ScratchpadTools()
+
+// Scratchpad returns the notes the runner's agent keeps outside the
+// conversation. They persist across the runs of the runner and are saved
+// with its session.
+func (ar *AgentRunner) Scratchpad() *agent.Scratchpad {
+	return ar.scratchpad
+}
+
+// restoreScratchpad takes the notes saved with the current session when the
+// runner has none, as when a session is resumed
+func (ar *AgentRunner) restoreScratchpad() {
+	if ar.currentSession == nil || len(ar.currentSession.Scratchpad) == 0 || ar.scratchpad.Notes() != nil {
+		return
+	}
+	ar.scratchpad = agent.NewScratchpad(ar.currentSession.Scratchpad)
+}
+
+// scratchpadTool returns the scratchpad tool called name
+func scratchpadTool(name string) (agent.Tool, bool) {
+	for _, tool := range scratchpadTools {
+		if tool.Name() == name {
+			return tool, true
+		}
+	}
+	return nil, false
+}
+
+// scratchpadToolDefinitions returns the definitions of the enabled
+// scratchpad tools
+func (ar *AgentRunner) scratchpadToolDefinitions() []llm.ToolDefinition {
+	var definitions []llm.ToolDefinition
+	for _, tool := range scratchpadTools {
+		if ar.ToolEnabled(tool.Name()) {
+			definitions = append(definitions, llm.CreateToolDefinition(tool.Name(), tool.Description(), tool.Parameters()))
+		}
+	}
+	return definitions
+}
+
+// withScratchpadNote returns messages followed by a digest of the notes of
+// pad, for one step: the notes are shown with each step without being added
+// to the conversation
+func withScratchpadNote(messages []Message, pad *agent.Scratchpad) []Message {
+	digest := pad.Digest(maxScratchpadDigestChars)
+	if digest == "" {
+		return messages
+	}
+	return append(messages[:len(messages):len(messages)], Message{Role: "user", Content: "[Your scratchpad notes, kept with " + agent.ScratchpadWriteToolName + "]\n" + digest})
+}
@@ -0,0 +1,61 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolRecorder records the prompts it is sent and the tools offered with them
+type toolRecorder struct {
+	promptRecorder
+	tools [][]string
+}
+
+func (r *toolRecorder) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []llm.ToolDefinition) (*llm.FunctionCallResponse, error) {
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Function.Name)
+	}
+	r.tools = append(r.tools, names)
+	return r.promptRecorder.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+func TestScratchpadKeptOutsideConversation(t *testing.T) {
+	client := &toolRecorder{promptRecorder: promptRecorder{MockLLMClient: MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{ID: "call_1", Name: agent.ScratchpadWriteToolName, Arguments: json.RawMessage(`{"name": "remaining_files", "content": "cmd/root.go\nmain.go"}`)}},
+		{FunctionCall: &llm.FunctionCall{ID: "call_2", Name: agent.ScratchpadWriteToolName, Arguments: json.RawMessage(`{"name": "remaining_files", "content": "main.go", "mode": "remove_lines"}`)}},
+	}}}}
+	sessions, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+	runner := NewAgentRunnerWithSession(client, agent.NewRegistry(), "system", "model", sessions)
+	runner.SetConfig(PlanRunConfig())
+
+	result, err := runner.RunWithCommand(context.Background(), "Rename the handler", "plan")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Contains(t, client.tools[0], agent.ScratchpadWriteToolName, "the scratchpad tools are offered whatever the allowed tools")
+	assert.NotContains(t, client.prompts[0], "scratchpad notes")
+	assert.Contains(t, client.prompts[1], "[Your scratchpad notes, kept with scratchpad_write]\n### remaining_files\ncmd/root.go\nmain.go")
+	assert.Contains(t, client.prompts[2], "### remaining_files\ncmd/root.go")
+	assert.NotContains(t, client.prompts[2], "main.go", "only the current notes are shown")
+	for _, msg := range result.Messages {
+		assert.NotContains(t, msg.Content, "scratchpad notes", "the digest is not added to the conversation")
+	}
+
+	loaded, err := sessions.LoadSession(runner.currentSession.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, "cmd/root.go", loaded.Scratchpad["remaining_files"].Content, "the notes are saved with the session")
+
+	resumed := NewAgentRunnerWithSession(client, agent.NewRegistry(), "system", "model", sessions)
+	resumed.currentSession = loaded
+	resumed.restoreScratchpad()
+	note, ok := resumed.Scratchpad().Note("remaining_files")
+	require.True(t, ok, "a resumed session has its notes back")
+	assert.Equal(t, "cmd/root.go", note.Content)
+}
@@ -2,13 +2,20 @@ package orchestrator
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/castrovroberto/CGE/internal/agent"
 	"github.com/castrovroberto/CGE/internal/audit"
+	"github.com/castrovroberto/CGE/internal/filelock"
 	"github.com/castrovroberto/CGE/internal/security"
+	"github.com/castrovroberto/CGE/internal/statecrypt"
+	"github.com/castrovroberto/CGE/internal/statedir"
 	"github.com/google/uuid"
 )
 
@@ -17,15 +24,24 @@ type SessionState struct {
 	SessionID     string                 `json:"session_id"`
 	StartTime     time.Time              `json:"start_time"`
 	EndTime       *time.Time             `json:"end_time,omitempty"`
+	UpdatedAt     time.Time              `json:"updated_at,omitempty"` // Time of the last checkpoint
 	SystemPrompt  string                 `json:"system_prompt"`
 	Model         string                 `json:"model"`
 	Config        *RunConfig             `json:"config"`
 	Messages      []Message              `json:"messages"`
 	ToolCalls     []ToolCallRecord       `json:"tool_calls"`
-	CurrentState  string                 `json:"current_state"` // "running", "completed", "failed", "paused"
+	LLMCalls      []LLMCallRecord        `json:"llm_calls,omitempty"` // Generation requests of the configured model, oldest first
+	CurrentState  string                 `json:"current_state"`       // "running", "completed", "failed", "paused", "interrupted"
 	Metadata      map[string]interface{} `json:"metadata,omitempty"`
 	WorkspaceRoot string                 `json:"workspace_root"`
-	Command       string                 `json:"command"` // "plan", "generate", "review", "chat"
+	Command       string                 `json:"command"`             // "plan", "generate", "review", "chat"
+	Approvals     []ApprovalDecision     `json:"approvals,omitempty"` // Checkpoint decisions, oldest first
+	Routing       []RoutingDecision      `json:"routing,omitempty"`   // Steps tried on the small model, oldest first
+
+	// Scratchpad holds the notes the agent kept outside the conversation
+	Scratchpad map[string]agent.ScratchpadNote `json:"scratchpad,omitempty"`
+	// Tasks is the task list the agent kept of the steps of the run
+	Tasks []agent.TaskItem `json:"tasks,omitempty"`
 }
 
 // ToolCallRecord represents a detailed record of a tool call
@@ -38,8 +54,21 @@ type ToolCallRecord struct {
 	Duration   time.Duration          `json:"duration"`
 	Success    bool                   `json:"success"`
 	Error      string                 `json:"error,omitempty"`
+	ErrorCode  string                 `json:"error_code,omitempty"`
 	Iteration  int                    `json:"iteration"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+
+	// Files are the files a successful call changed, as the tool named them
+	Files []string `json:"files,omitempty"`
+}
+
+// LLMCallRecord is a generation request a run made to its model. Requests
+// to the small model of routing are in the routing decisions instead.
+type LLMCallRecord struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Duration  time.Duration `json:"duration"`
+	Model     string        `json:"model"`
+	Error     string        `json:"error,omitempty"`
 }
 
 // ToolCallResult represents the result of a tool call
@@ -49,17 +78,44 @@ type ToolCallResult struct {
 	Error   string      `json:"error,omitempty"`
 }
 
-// SessionManager manages session state persistence
+// SessionStateInterrupted marks a session whose process stopped while it was
+// running, e.g. after a crash or SIGKILL. It can be continued with RecoverSession.
+const SessionStateInterrupted = "interrupted"
+
+// sessionHeartbeatInterval is how often a running session is checkpointed
+// while a step runs
+const sessionHeartbeatInterval = 30 * time.Second
+
+// runningMarkerExt names the marker file kept next to a running session, so
+// running sessions can be found without reading every session file
+const runningMarkerExt = ".running"
+
+// SessionLockedError is returned when a session is in use by another process
+type SessionLockedError struct {
+	SessionID string
+}
+
+func (e *SessionLockedError) Error() string {
+	return fmt.Sprintf("session %s is in use by another CGE process; wait for it to finish, or inspect it read-only with 'CGE session info %s'", e.SessionID, e.SessionID)
+}
+
+// SessionManager manages session state persistence. Each session has a lock
+// file held while a process runs it; sessions locked by another process are
+// never written, so concurrent CGE processes cannot clobber each other's state.
 type SessionManager struct {
 	workspaceRoot string
 	sessionDir    string
 	auditLogger   *audit.AuditLogger
 	safeOps       *security.SafeFileOps
+	cipher        *statecrypt.Cipher // Encrypts session files; nil writes plaintext
+
+	locksMu sync.Mutex
+	locks   map[string]*filelock.Lock // Session ID -> lock held by this manager
 }
 
 // NewSessionManager creates a new session manager
 func NewSessionManager(workspaceRoot string, auditLogger *audit.AuditLogger) (*SessionManager, error) {
-	sessionDir := filepath.Join(workspaceRoot, ".cge", "sessions")
+	sessionDir := statedir.Path(workspaceRoot, statedir.Sessions)
 	if err := os.MkdirAll(sessionDir, 0750); err != nil {
 		return nil, fmt.Errorf("failed to create session directory: %w", err)
 	}
@@ -67,14 +123,88 @@ func NewSessionManager(workspaceRoot string, auditLogger *audit.AuditLogger) (*S
 	// Create safe file operations with workspace root and session directory as allowed roots
 	safeOps := security.NewSafeFileOps(workspaceRoot, sessionDir)
 
+	// Sessions are encrypted at rest when [encryption] is enabled
+	cipher, err := statecrypt.ForDir(statedir.Root(workspaceRoot))
+	if err != nil {
+		return nil, err
+	}
+
 	return &SessionManager{
 		workspaceRoot: workspaceRoot,
 		sessionDir:    sessionDir,
 		auditLogger:   auditLogger,
 		safeOps:       safeOps,
+		cipher:        cipher,
+		locks:         make(map[string]*filelock.Lock),
 	}, nil
 }
 
+// LockSession takes the lock of a session for this manager, until
+// UnlockSession. It fails with a SessionLockedError when another process, or
+// another manager, holds it.
+func (sm *SessionManager) LockSession(sessionID string) error {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+	if _, held := sm.locks[sessionID]; held {
+		return nil
+	}
+	lock, err := filelock.TryLock(sm.lockPath(sessionID))
+	if errors.Is(err, filelock.ErrLocked) {
+		return &SessionLockedError{SessionID: sessionID}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock session: %w", err)
+	}
+	sm.locks[sessionID] = lock
+	return nil
+}
+
+// UnlockSession releases a lock taken with LockSession
+func (sm *SessionManager) UnlockSession(sessionID string) error {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+	lock, held := sm.locks[sessionID]
+	if !held {
+		return nil
+	}
+	delete(sm.locks, sessionID)
+	return lock.Unlock()
+}
+
+// IsSessionLocked reports whether a session is locked by another process or
+// manager
+func (sm *SessionManager) IsSessionLocked(sessionID string) bool {
+	sm.locksMu.Lock()
+	_, held := sm.locks[sessionID]
+	sm.locksMu.Unlock()
+	if held {
+		return false
+	}
+	locked, err := filelock.IsLocked(sm.lockPath(sessionID))
+	return err == nil && locked
+}
+
+// withSessionLock runs fn holding the lock of a session, taking it for the
+// duration of fn unless this manager already holds it
+func (sm *SessionManager) withSessionLock(sessionID string, fn func() error) error {
+	sm.locksMu.Lock()
+	_, held := sm.locks[sessionID]
+	sm.locksMu.Unlock()
+	if held {
+		return fn()
+	}
+	if err := sm.LockSession(sessionID); err != nil {
+		return err
+	}
+	defer sm.UnlockSession(sessionID)
+	return fn()
+}
+
+// lockPath returns the path of the lock file of a session
+func (sm *SessionManager) lockPath(sessionID string) string {
+	return filepath.Join(sm.sessionDir, fmt.Sprintf("session_%s.lock", sessionID))
+}
+
 // CreateSession creates a new session state
 func (sm *SessionManager) CreateSession(systemPrompt, model, command string, config *RunConfig) *SessionState {
 	sessionID := uuid.New().String()
@@ -94,18 +224,13 @@ func (sm *SessionManager) CreateSession(systemPrompt, model, command string, con
 	}
 }
 
-// SaveSession saves a session state to disk
+// SaveSession checkpoints a session state to disk. The file is replaced
+// atomically, so a crash while saving leaves the previous checkpoint intact.
+// It fails with a SessionLockedError when another process holds the session.
 func (sm *SessionManager) SaveSession(session *SessionState) error {
-	filename := fmt.Sprintf("session_%s.json", session.SessionID)
-	filepath := filepath.Join(sm.sessionDir, filename)
-
-	data, err := json.MarshalIndent(session, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal session state: %w", err)
-	}
-
-	if err := os.WriteFile(filepath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write session file: %w", err)
+	session.UpdatedAt = time.Now()
+	if err := sm.withSessionLock(session.SessionID, func() error { return sm.writeSession(session) }); err != nil {
+		return err
 	}
 
 	// Log session save event
@@ -122,6 +247,121 @@ func (sm *SessionManager) SaveSession(session *SessionState) error {
 	return nil
 }
 
+// writeSession writes session to its file and keeps its running marker in step
+// with its state. The caller holds the session lock.
+func (sm *SessionManager) writeSession(session *SessionState) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session state: %w", err)
+	}
+	if data, err = sm.cipher.Seal(data); err != nil {
+		return fmt.Errorf("failed to encrypt session state: %w", err)
+	}
+
+	path := filepath.Join(sm.sessionDir, fmt.Sprintf("session_%s.json", session.SessionID))
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+
+	marker := sm.runningMarkerPath(session.SessionID)
+	if session.CurrentState != "running" {
+		if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove running marker: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(marker, nil, 0600); err != nil {
+		return fmt.Errorf("failed to write running marker: %w", err)
+	}
+	return nil
+}
+
+// runningMarkerPath returns the path of the running marker of a session
+func (sm *SessionManager) runningMarkerPath(sessionID string) string {
+	return filepath.Join(sm.sessionDir, fmt.Sprintf("session_%s%s", sessionID, runningMarkerExt))
+}
+
+// writeFileAtomic replaces path with data by writing and syncing a temporary
+// file first, then renaming it over path
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm) // #nosec G304 - path is inside the session directory
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// FlagOrphanedSessions marks running sessions that no process holds as
+// interrupted, and returns them. Their process stopped without finishing them,
+// since the lock of a session is released when its process exits.
+func (sm *SessionManager) FlagOrphanedSessions() ([]*SessionInfo, error) {
+	markers, err := filepath.Glob(filepath.Join(sm.sessionDir, "session_*"+runningMarkerExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find running sessions: %w", err)
+	}
+
+	var flagged []*SessionInfo
+	for _, marker := range markers {
+		sessionID := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(marker), "session_"), runningMarkerExt)
+		sm.locksMu.Lock()
+		_, ours := sm.locks[sessionID]
+		sm.locksMu.Unlock()
+		if ours {
+			continue
+		}
+
+		var session *SessionState
+		err := sm.withSessionLock(sessionID, func() error {
+			var loadErr error
+			session, loadErr = sm.LoadSession(sessionID)
+			if loadErr != nil {
+				os.Remove(marker) // The session itself is gone
+				return nil
+			}
+			if session.CurrentState == "running" {
+				session.CurrentState = SessionStateInterrupted
+			}
+			// The last checkpoint time is kept, so it shows when the run stopped
+			return sm.writeSession(session)
+		})
+		var lockedErr *SessionLockedError
+		if errors.As(err, &lockedErr) {
+			continue // Still running in another process
+		}
+		if err != nil {
+			return flagged, err
+		}
+		if session == nil || session.CurrentState != SessionStateInterrupted {
+			continue
+		}
+
+		if sm.auditLogger != nil {
+			sm.auditLogger.LogToolExecution("session_manager", true, 0, nil, map[string]interface{}{
+				"operation":       "flag_orphaned_session",
+				"session_id":      sessionID,
+				"last_checkpoint": session.UpdatedAt,
+			})
+		}
+		flagged = append(flagged, sessionInfo(session))
+	}
+	return flagged, nil
+}
+
 // LoadSession loads a session state from disk
 func (sm *SessionManager) LoadSession(sessionID string) (*SessionState, error) {
 	filename := fmt.Sprintf("session_%s.json", sessionID)
@@ -131,6 +371,9 @@ func (sm *SessionManager) LoadSession(sessionID string) (*SessionState, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read session file: %w", err)
 	}
+	if data, err = sm.cipher.Open(data); err != nil {
+		return nil, fmt.Errorf("failed to decrypt session %s: %w", sessionID, err)
+	}
 
 	var session SessionState
 	if err := json.Unmarshal(data, &session); err != nil {
@@ -180,16 +423,22 @@ func (sm *SessionManager) GetSessionInfo(sessionID string) (*SessionInfo, error)
 		return nil, err
 	}
 
+	return sessionInfo(session), nil
+}
+
+// sessionInfo summarizes session
+func sessionInfo(session *SessionState) *SessionInfo {
 	return &SessionInfo{
 		SessionID:    session.SessionID,
 		StartTime:    session.StartTime,
 		EndTime:      session.EndTime,
+		UpdatedAt:    session.UpdatedAt,
 		Model:        session.Model,
 		Command:      session.Command,
 		CurrentState: session.CurrentState,
 		ToolCalls:    len(session.ToolCalls),
 		Messages:     len(session.Messages),
-	}, nil
+	}
 }
 
 // SessionInfo represents basic session information
@@ -197,6 +446,7 @@ type SessionInfo struct {
 	SessionID    string     `json:"session_id"`
 	StartTime    time.Time  `json:"start_time"`
 	EndTime      *time.Time `json:"end_time,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at,omitempty"`
 	Model        string     `json:"model"`
 	Command      string     `json:"command"`
 	CurrentState string     `json:"current_state"`
@@ -204,13 +454,25 @@ type SessionInfo struct {
 	Messages     int        `json:"messages"`
 }
 
-// DeleteSession removes a session from disk
+// DeleteSession removes a session from disk. A session in use by another
+// process is not deleted.
 func (sm *SessionManager) DeleteSession(sessionID string) error {
 	filename := fmt.Sprintf("session_%s.json", sessionID)
 	filepath := filepath.Join(sm.sessionDir, filename)
 
-	if err := os.Remove(filepath); err != nil {
-		return fmt.Errorf("failed to delete session file: %w", err)
+	// The session is deleted holding its lock, so no process resumes or saves
+	// it meanwhile. The lock file is left in place: removing it would let
+	// another process lock a new file at the same path while this one still
+	// holds the old one.
+	err := sm.withSessionLock(sessionID, func() error {
+		if err := os.Remove(filepath); err != nil {
+			return fmt.Errorf("failed to delete session file: %w", err)
+		}
+		os.Remove(sm.runningMarkerPath(sessionID))
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// Log session deletion
@@ -283,8 +545,9 @@ func (sm *SessionManager) GetToolCallHistory(sessionID string) ([]ToolCallRecord
 	return session.ToolCalls, nil
 }
 
-// ExportSessionToJSONL exports a session's tool calls to JSONL format for analysis
-func (sm *SessionManager) ExportSessionToJSONL(sessionID string, outputPath string) error {
+// ExportSessionToJSONL exports a session's tool calls to JSONL format for
+// analysis, stripped at a redaction level
+func (sm *SessionManager) ExportSessionToJSONL(sessionID, outputPath, level string) error {
 	session, err := sm.LoadSession(sessionID)
 	if err != nil {
 		return err
@@ -298,7 +561,7 @@ func (sm *SessionManager) ExportSessionToJSONL(sessionID string, outputPath stri
 
 	encoder := json.NewEncoder(file)
 	for _, toolCall := range session.ToolCalls {
-		if err := encoder.Encode(toolCall); err != nil {
+		if err := encoder.Encode(redactToolCall(toolCall, level)); err != nil {
 			return fmt.Errorf("failed to encode tool call: %w", err)
 		}
 	}
@@ -0,0 +1,180 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/statecrypt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveSessionKeepsRunningMarker(t *testing.T) {
+	sm, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+	session := sm.CreateSession("system", "model", "generate", DefaultRunConfig())
+	marker := sm.runningMarkerPath(session.SessionID)
+
+	require.NoError(t, sm.SaveSession(session))
+	assert.FileExists(t, marker)
+	assert.NoFileExists(t, filepath.Join(sm.sessionDir, "session_"+session.SessionID+".json.tmp"))
+	assert.False(t, session.UpdatedAt.IsZero())
+
+	sessions, err := sm.ListSessions()
+	require.NoError(t, err)
+	assert.Equal(t, []string{session.SessionID}, sessions, "the marker is not listed as a session")
+
+	sm.UpdateSessionState(session, "completed")
+	require.NoError(t, sm.SaveSession(session))
+	assert.NoFileExists(t, marker)
+}
+
+func TestSessionEncryption(t *testing.T) {
+	workspace := t.TempDir()
+	sm, err := NewSessionManager(workspace, nil)
+	require.NoError(t, err)
+	plain := sm.CreateSession("system", "model", "chat", DefaultRunConfig())
+	require.NoError(t, sm.SaveSession(plain))
+
+	t.Setenv(statecrypt.DefaultPassphraseEnv, "passphrase")
+	require.NoError(t, statecrypt.Configure(statecrypt.Options{Enabled: true}))
+	t.Cleanup(func() { _ = statecrypt.Configure(statecrypt.Options{}) })
+
+	sm, err = NewSessionManager(workspace, nil)
+	require.NoError(t, err)
+	session := sm.CreateSession("system", "model", "generate", DefaultRunConfig())
+	session.Messages = []Message{{Role: "user", Content: "the API key is sk-test-123"}}
+	require.NoError(t, sm.SaveSession(session))
+
+	data, err := os.ReadFile(filepath.Join(sm.sessionDir, "session_"+session.SessionID+".json"))
+	require.NoError(t, err)
+	assert.True(t, statecrypt.IsSealed(data))
+	assert.NotContains(t, string(data), "sk-test-123")
+
+	loaded, err := sm.LoadSession(session.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, session.Messages, loaded.Messages)
+	_, err = sm.LoadSession(plain.SessionID)
+	assert.NoError(t, err, "sessions saved before encryption stay readable")
+
+	require.NoError(t, statecrypt.Configure(statecrypt.Options{}))
+	sm, err = NewSessionManager(workspace, nil)
+	require.NoError(t, err)
+	_, err = sm.LoadSession(session.SessionID)
+	assert.ErrorIs(t, err, statecrypt.ErrEncrypted)
+}
+
+func TestFlagOrphanedSessions(t *testing.T) {
+	sm, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+
+	orphan := sm.CreateSession("system", "model", "generate", DefaultRunConfig())
+	require.NoError(t, sm.SaveSession(orphan))
+
+	// A session running in another process holds its lock
+	other, err := NewSessionManager(sm.workspaceRoot, nil)
+	require.NoError(t, err)
+	live := other.CreateSession("system", "model", "generate", DefaultRunConfig())
+	require.NoError(t, other.LockSession(live.SessionID))
+	defer other.UnlockSession(live.SessionID)
+	require.NoError(t, other.SaveSession(live))
+
+	flagged, err := sm.FlagOrphanedSessions()
+	require.NoError(t, err)
+	require.Len(t, flagged, 1)
+	assert.Equal(t, orphan.SessionID, flagged[0].SessionID)
+	assert.Equal(t, SessionStateInterrupted, flagged[0].CurrentState)
+	assert.WithinDuration(t, orphan.UpdatedAt, flagged[0].UpdatedAt, 0, "the last checkpoint time is kept")
+	assert.NoFileExists(t, sm.runningMarkerPath(orphan.SessionID))
+
+	loaded, err := sm.LoadSession(live.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, "running", loaded.CurrentState)
+
+	flagged, err = sm.FlagOrphanedSessions()
+	require.NoError(t, err)
+	assert.Empty(t, flagged, "a session is flagged once")
+}
+
+func TestSessionLocking(t *testing.T) {
+	workspace := t.TempDir()
+	first, err := NewSessionManager(workspace, nil)
+	require.NoError(t, err)
+	second, err := NewSessionManager(workspace, nil)
+	require.NoError(t, err)
+
+	session := first.CreateSession("system", "model", "generate", DefaultRunConfig())
+	require.NoError(t, first.LockSession(session.SessionID))
+	require.NoError(t, first.LockSession(session.SessionID), "locking a held session again is a no-op")
+	require.NoError(t, first.SaveSession(session))
+	assert.False(t, first.IsSessionLocked(session.SessionID), "a session is not locked for its holder")
+	assert.True(t, second.IsSessionLocked(session.SessionID))
+
+	var lockedErr *SessionLockedError
+	assert.ErrorAs(t, second.SaveSession(session), &lockedErr)
+	assert.ErrorAs(t, second.LockSession(session.SessionID), &lockedErr)
+	assert.ErrorAs(t, second.DeleteSession(session.SessionID), &lockedErr)
+	assert.Contains(t, lockedErr.Error(), "CGE session info "+session.SessionID)
+
+	runner := NewAgentRunnerWithSession(&MockLLMClient{}, agent.NewRegistry(), "system", "model", second)
+	assert.ErrorAs(t, runner.ResumeSession(session.SessionID), &lockedErr)
+	assert.ErrorAs(t, runner.RecoverSession(session.SessionID), &lockedErr)
+
+	// The running session is read-only for others, and not orphaned
+	loaded, err := second.LoadSession(session.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, "running", loaded.CurrentState)
+	flagged, err := second.FlagOrphanedSessions()
+	require.NoError(t, err)
+	assert.Empty(t, flagged)
+
+	require.NoError(t, first.UnlockSession(session.SessionID))
+	flagged, err = second.FlagOrphanedSessions()
+	require.NoError(t, err)
+	assert.Len(t, flagged, 1, "a session left running without a holder is orphaned")
+	require.NoError(t, second.DeleteSession(session.SessionID))
+	_, err = second.LoadSession(session.SessionID)
+	assert.Error(t, err, "the session is deleted")
+	assert.FileExists(t, second.lockPath(session.SessionID), "the lock file stays, so no process locks another file in its place")
+	assert.False(t, first.IsSessionLocked(session.SessionID), "the lock is released after the delete")
+}
+
+func TestRecoverSession(t *testing.T) {
+	sm, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+
+	session := sm.CreateSession("system", "mock-model", "generate", DefaultRunConfig())
+	session.Messages = []Message{
+		{Role: "system", Content: "system"},
+		{Role: "user", Content: "add a test"},
+		{Role: "assistant", ToolCall: &llm.FunctionCall{ID: "call_1", Name: "write_file", Arguments: json.RawMessage(`{}`)}},
+	}
+	session.CurrentState = SessionStateInterrupted
+	require.NoError(t, sm.SaveSession(session))
+
+	finished := sm.CreateSession("system", "mock-model", "generate", DefaultRunConfig())
+	sm.UpdateSessionState(finished, "completed")
+	require.NoError(t, sm.SaveSession(finished))
+
+	runner := NewAgentRunnerWithSession(&MockLLMClient{}, agent.NewRegistry(), "system", "mock-model", sm)
+	assert.ErrorContains(t, runner.RecoverSession(finished.SessionID), "is completed, not interrupted")
+
+	require.NoError(t, runner.RecoverSession(session.SessionID))
+	assert.Len(t, runner.GetMessageHistory(), 2, "the tool call without a result is dropped")
+
+	result, err := runner.RunWithCommand(context.Background(), RecoveryPrompt, "generate")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+
+	loaded, err := sm.LoadSession(session.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", loaded.CurrentState)
+	assert.NotNil(t, loaded.EndTime)
+	assert.Equal(t, RecoveryPrompt, loaded.Messages[2].Content)
+	assert.NoFileExists(t, sm.runningMarkerPath(session.SessionID))
+}
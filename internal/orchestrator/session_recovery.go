@@ -0,0 +1,121 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/logger"
+)
+
+// RecoveryPrompt continues a run recovered with RecoverSession
+const RecoveryPrompt = "The previous run stopped unexpectedly after the last step above, because the process running it exited. " +
+	"A tool call in progress at that moment may have been partially applied, so check the current state of any files you were changing, then continue the task."
+
+// checkpointSession saves the conversation so far to the current session, if
+// any. With nil messages, the session is saved as it is.
+func (ar *AgentRunner) checkpointSession(ctx context.Context, messages []Message) {
+	if ar.currentSession == nil {
+		return
+	}
+	ar.sessionMu.Lock()
+	defer ar.sessionMu.Unlock()
+	if messages != nil {
+		ar.currentSession.Messages = messages
+	}
+	ar.recordApprovals()
+	ar.currentSession.Scratchpad = ar.scratchpad.Notes()
+	ar.currentSession.Tasks = ar.tasks.Tasks()
+	if err := ar.sessionManager.SaveSession(ar.currentSession); err != nil {
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Warn("Failed to checkpoint session", "session_id", ar.currentSession.SessionID, "error", err)
+	}
+}
+
+// recordApprovals copies the decisions of the approval gate, if any, to the
+// current session; callers hold sessionMu
+func (ar *AgentRunner) recordApprovals() {
+	if ar.approvalGate != nil {
+		ar.currentSession.Approvals = ar.approvalGate.Decisions()
+	}
+}
+
+// startSessionHeartbeat checkpoints the current session every
+// sessionHeartbeatInterval, so a crash during a long LLM call or tool run
+// loses little. It returns a function that stops the heartbeat.
+func (ar *AgentRunner) startSessionHeartbeat(ctx context.Context) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(sessionHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ar.checkpointSession(ctx, nil)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// finishSession records the outcome of a run in the current session. A run
+// cancelled by the caller stays paused, so it can be resumed.
+func (ar *AgentRunner) finishSession(ctx context.Context, result *RunResult, err error) {
+	if ar.currentSession == nil {
+		return
+	}
+	ar.sessionMu.Lock()
+	defer ar.sessionMu.Unlock()
+	if ar.currentSession.CurrentState == "paused" {
+		return
+	}
+	state := "failed"
+	if err == nil && result != nil && result.Success {
+		state = "completed"
+	}
+	ar.sessionManager.UpdateSessionState(ar.currentSession, state)
+	ar.recordApprovals()
+	ar.currentSession.Scratchpad = ar.scratchpad.Notes()
+	ar.currentSession.Tasks = ar.tasks.Tasks()
+	if err := ar.sessionManager.SaveSession(ar.currentSession); err != nil {
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Warn("Failed to save finished session", "session_id", ar.currentSession.SessionID, "error", err)
+	}
+}
+
+// RecoverSession loads a session whose process died while running it, so it
+// can be continued from its last checkpoint with RecoveryPrompt. The session
+// must have been flagged as interrupted, or be running with no process holding
+// it. A tool call whose result was never recorded is dropped.
+func (ar *AgentRunner) RecoverSession(sessionID string) error {
+	if ar.sessionManager == nil {
+		return fmt.Errorf("session manager not available")
+	}
+
+	if ar.sessionManager.IsSessionLocked(sessionID) {
+		return &SessionLockedError{SessionID: sessionID}
+	}
+
+	session, err := ar.sessionManager.LoadSession(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+
+	orphaned := session.CurrentState == "running"
+	if session.CurrentState != SessionStateInterrupted && !orphaned {
+		return fmt.Errorf("session %s is %s, not interrupted; use resume to continue it", sessionID, session.CurrentState)
+	}
+
+	if n := len(session.Messages); n > 0 && session.Messages[n-1].Role == "assistant" && session.Messages[n-1].ToolCall != nil {
+		session.Messages = session.Messages[:n-1]
+	}
+
+	ar.currentSession = session
+	return nil
+}
@@ -0,0 +1,184 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/redact"
+	"github.com/castrovroberto/CGE/internal/statedir"
+)
+
+// RedactionMetadataKey records, in the metadata of a session, the redaction
+// level its content was stripped at
+const RedactionMetadataKey = "redaction"
+
+// RetentionResult is what ApplyRetention did, or would do in a dry run
+type RetentionResult struct {
+	Deleted  int // Sessions deleted
+	Scrubbed int // Sessions reduced to their metadata
+	Skipped  int // Sessions in use by another process
+}
+
+// ApplyRetention deletes the sessions inactive for longer than
+// retention.SessionsMaxAge and strips the content of those inactive for
+// longer than retention.ScrubAfter, keeping their metadata. Sessions in use
+// are skipped. With dryRun, nothing is changed.
+func (sm *SessionManager) ApplyRetention(retention statedir.Retention, dryRun bool) (RetentionResult, error) {
+	var result RetentionResult
+	if retention.SessionsMaxAge <= 0 && retention.ScrubAfter <= 0 {
+		return result, nil
+	}
+	sessions, err := sm.ListSessions()
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	for _, sessionID := range sessions {
+		session, err := sm.LoadSession(sessionID)
+		if err != nil {
+			continue // Skip sessions we can't read
+		}
+		age := now.Sub(lastActive(session))
+		switch {
+		case retention.SessionsMaxAge > 0 && age > retention.SessionsMaxAge:
+			if !dryRun {
+				err = sm.DeleteSession(sessionID)
+			} else if sm.IsSessionLocked(sessionID) {
+				err = &SessionLockedError{SessionID: sessionID}
+			}
+			if err == nil {
+				result.Deleted++
+			}
+		case retention.ScrubAfter > 0 && age > retention.ScrubAfter && session.Metadata[RedactionMetadataKey] != redact.LevelMetadata:
+			if !dryRun {
+				// Written directly rather than saved, so scrubbing does not
+				// count as activity
+				scrubbed := RedactSession(session, redact.LevelMetadata)
+				err = sm.withSessionLock(sessionID, func() error { return sm.writeSession(scrubbed) })
+			} else if sm.IsSessionLocked(sessionID) {
+				err = &SessionLockedError{SessionID: sessionID}
+			}
+			if err == nil {
+				result.Scrubbed++
+			}
+		}
+		var locked *SessionLockedError
+		if errors.As(err, &locked) {
+			result.Skipped++
+		} else if err != nil {
+			return result, fmt.Errorf("failed to apply retention to session %s: %w", sessionID, err)
+		}
+	}
+
+	if sm.auditLogger != nil && !dryRun {
+		sm.auditLogger.LogToolExecution("session_manager", true, 0, nil, map[string]interface{}{
+			"operation":      "apply_retention",
+			"deleted_count":  result.Deleted,
+			"scrubbed_count": result.Scrubbed,
+			"max_age_hours":  retention.SessionsMaxAge.Hours(),
+			"scrub_hours":    retention.ScrubAfter.Hours(),
+		})
+	}
+	return result, nil
+}
+
+// lastActive returns when session was last checkpointed
+func lastActive(session *SessionState) time.Time {
+	switch {
+	case !session.UpdatedAt.IsZero():
+		return session.UpdatedAt
+	case session.EndTime != nil:
+		return *session.EndTime
+	}
+	return session.StartTime
+}
+
+// RedactSession returns a copy of session with its code and file contents
+// stripped at a redaction level, keeping the structure of the conversation:
+// roles, tool names, file paths, timings and outcomes
+func RedactSession(session *SessionState, level string) *SessionState {
+	if level == redact.LevelNone {
+		return session
+	}
+	redacted := *session
+	redacted.SystemPrompt = redact.Text(session.SystemPrompt, level)
+
+	redacted.Messages = make([]Message, len(session.Messages))
+	for i, message := range session.Messages {
+		message.Content = redact.Content(message.Content, level)
+		if message.ToolCall != nil {
+			message.ToolCall = &llm.FunctionCall{
+				Name:      message.ToolCall.Name,
+				Arguments: redact.JSON(message.ToolCall.Arguments, level),
+				ID:        message.ToolCall.ID,
+			}
+		}
+		redacted.Messages[i] = message
+	}
+
+	redacted.ToolCalls = make([]ToolCallRecord, len(session.ToolCalls))
+	for i, toolCall := range session.ToolCalls {
+		redacted.ToolCalls[i] = redactToolCall(toolCall, level)
+	}
+
+	redacted.Approvals = make([]ApprovalDecision, len(session.Approvals))
+	for i, approval := range session.Approvals {
+		approval.Reason = redact.Text(approval.Reason, level)
+		redacted.Approvals[i] = approval
+	}
+
+	redacted.Metadata = make(map[string]interface{}, len(session.Metadata)+1)
+	for key, value := range session.Metadata {
+		redacted.Metadata[key] = redact.Value(value, level)
+	}
+	redacted.Metadata[RedactionMetadataKey] = level
+	return &redacted
+}
+
+// redactToolCall returns a copy of toolCall stripped at a redaction level
+func redactToolCall(toolCall ToolCallRecord, level string) ToolCallRecord {
+	toolCall.Parameters = redact.JSON(toolCall.Parameters, level)
+	toolCall.Error = redact.Text(toolCall.Error, level)
+	if toolCall.Result != nil {
+		toolCall.Result = &ToolCallResult{
+			Success: toolCall.Result.Success,
+			Data:    redact.Value(toolCall.Result.Data, level),
+			Error:   redact.Text(toolCall.Result.Error, level),
+		}
+	}
+	if toolCall.Metadata != nil {
+		metadata := make(map[string]interface{}, len(toolCall.Metadata))
+		for key, value := range toolCall.Metadata {
+			metadata[key] = redact.Value(value, level)
+		}
+		toolCall.Metadata = metadata
+	}
+	return toolCall
+}
+
+// ExportSessionToJSON exports a whole session, stripped at a redaction
+// level, as an indented JSON document, e.g. to attach to a bug report
+func (sm *SessionManager) ExportSessionToJSON(sessionID, outputPath, level string) error {
+	session, err := sm.LoadSession(sessionID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(RedactSession(session, level), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	file, err := sm.safeOps.SafeCreate(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/redact"
+	"github.com/castrovroberto/CGE/internal/statedir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionWithCode returns a completed session whose messages and tool calls
+// hold code
+func sessionWithCode(sm *SessionManager) *SessionState {
+	session := sm.CreateSession("You are a careful engineer", "model", "generate", DefaultRunConfig())
+	session.Messages = []Message{
+		{Role: "user", Content: "Fix the pool:\n```go\nconst size = 4\n```"},
+		{Role: "assistant", ToolCall: &llm.FunctionCall{Name: "write_file", ID: "call_1", Arguments: json.RawMessage(`{"file_path": "db/pool.go", "content": "package db\nconst size = 8\n"}`)}},
+		{Role: "tool", Name: "write_file", ToolCallID: "call_1", Content: `{"success": true, "path": "db/pool.go"}`},
+	}
+	session.ToolCalls = []ToolCallRecord{{
+		ID:         "call_1",
+		ToolName:   "write_file",
+		Parameters: json.RawMessage(`{"file_path": "db/pool.go", "content": "package db\nconst size = 8\n"}`),
+		Result:     &ToolCallResult{Success: true, Data: map[string]interface{}{"path": "db/pool.go", "diff": "-4\n+8"}},
+		Success:    true,
+	}}
+	sm.UpdateSessionState(session, "completed")
+	return session
+}
+
+// saveInactive saves session as last active age ago
+func saveInactive(t *testing.T, sm *SessionManager, session *SessionState, age time.Duration) {
+	t.Helper()
+	session.UpdatedAt = time.Now().Add(-age)
+	require.NoError(t, sm.withSessionLock(session.SessionID, func() error { return sm.writeSession(session) }))
+}
+
+func TestRedactSession(t *testing.T) {
+	sm, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+	session := sessionWithCode(sm)
+
+	redacted := RedactSession(session, redact.LevelCode)
+	assert.Equal(t, "Fix the pool:\n```go\n[redacted: 15 bytes]\n```", redacted.Messages[0].Content)
+	assert.Equal(t, "write_file", redacted.Messages[1].ToolCall.Name)
+	assert.JSONEq(t, `{"file_path": "db/pool.go", "content": "[redacted: 2 lines]"}`, string(redacted.Messages[1].ToolCall.Arguments))
+	assert.JSONEq(t, `{"file_path": "db/pool.go", "content": "[redacted: 2 lines]"}`, string(redacted.ToolCalls[0].Parameters))
+	assert.Equal(t, map[string]interface{}{"path": "db/pool.go", "diff": "[redacted: 2 lines]"}, redacted.ToolCalls[0].Result.Data)
+	assert.Equal(t, redact.LevelCode, redacted.Metadata[RedactionMetadataKey])
+
+	assert.Contains(t, session.Messages[0].Content, "const size = 4", "the session itself is left alone")
+	assert.Contains(t, string(session.Messages[1].ToolCall.Arguments), "const size = 8")
+	assert.NotContains(t, session.Metadata, RedactionMetadataKey)
+
+	metadata := RedactSession(session, redact.LevelMetadata)
+	assert.Equal(t, "[redacted: 4 lines]", metadata.Messages[0].Content)
+	assert.Equal(t, "[redacted: 26 bytes]", metadata.SystemPrompt)
+	assert.Equal(t, "tool", metadata.Messages[2].Role)
+	assert.JSONEq(t, `{"success": true, "path": "db/pool.go"}`, metadata.Messages[2].Content)
+	assert.Same(t, session, RedactSession(session, redact.LevelNone))
+}
+
+func TestApplyRetention(t *testing.T) {
+	sm, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+	recent, stale, expired, inUse := sessionWithCode(sm), sessionWithCode(sm), sessionWithCode(sm), sessionWithCode(sm)
+	saveInactive(t, sm, recent, time.Hour)
+	saveInactive(t, sm, stale, 10*24*time.Hour)
+	saveInactive(t, sm, expired, 100*24*time.Hour)
+	saveInactive(t, sm, inUse, 100*24*time.Hour)
+
+	other, err := NewSessionManager(sm.workspaceRoot, nil)
+	require.NoError(t, err)
+	require.NoError(t, other.LockSession(inUse.SessionID))
+	defer other.UnlockSession(inUse.SessionID)
+
+	retention := statedir.Retention{SessionsMaxAge: 90 * 24 * time.Hour, ScrubAfter: 7 * 24 * time.Hour}
+	result, err := sm.ApplyRetention(retention, true)
+	require.NoError(t, err)
+	assert.Equal(t, RetentionResult{Deleted: 1, Scrubbed: 1, Skipped: 1}, result)
+	sessions, _ := sm.ListSessions()
+	assert.Len(t, sessions, 4, "a dry run changes nothing")
+
+	result, err = sm.ApplyRetention(retention, false)
+	require.NoError(t, err)
+	assert.Equal(t, RetentionResult{Deleted: 1, Scrubbed: 1, Skipped: 1}, result)
+	sessions, _ = sm.ListSessions()
+	assert.ElementsMatch(t, []string{recent.SessionID, stale.SessionID, inUse.SessionID}, sessions)
+
+	scrubbed, err := sm.LoadSession(stale.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, redact.LevelMetadata, scrubbed.Metadata[RedactionMetadataKey])
+	assert.Equal(t, "[redacted: 4 lines]", scrubbed.Messages[0].Content)
+	assert.Equal(t, "write_file", scrubbed.ToolCalls[0].ToolName)
+	assert.WithinDuration(t, stale.UpdatedAt, scrubbed.UpdatedAt, time.Second, "scrubbing is not activity")
+
+	result, err = sm.ApplyRetention(retention, false)
+	require.NoError(t, err)
+	assert.Zero(t, result.Scrubbed, "scrubbed sessions are not scrubbed again")
+
+	kept, err := sm.LoadSession(recent.SessionID)
+	require.NoError(t, err)
+	assert.Contains(t, kept.Messages[0].Content, "const size = 4")
+}
+
+func TestExportSessionRedacted(t *testing.T) {
+	workspace := t.TempDir()
+	sm, err := NewSessionManager(workspace, nil)
+	require.NoError(t, err)
+	session := sessionWithCode(sm)
+	require.NoError(t, sm.SaveSession(session))
+
+	jsonlPath := filepath.Join(workspace, "export.jsonl")
+	require.NoError(t, sm.ExportSessionToJSONL(session.SessionID, jsonlPath, redact.LevelCode))
+	data, err := os.ReadFile(jsonlPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"tool_name":"write_file"`)
+	assert.NotContains(t, string(data), "const size")
+
+	jsonPath := filepath.Join(workspace, "export.json")
+	require.NoError(t, sm.ExportSessionToJSON(session.SessionID, jsonPath, redact.LevelMetadata))
+	data, err = os.ReadFile(jsonPath)
+	require.NoError(t, err)
+	var exported SessionState
+	require.NoError(t, json.Unmarshal(data, &exported))
+	assert.Equal(t, session.SessionID, exported.SessionID)
+	assert.Len(t, exported.Messages, 3)
+	assert.NotContains(t, string(data), "const size")
+	assert.NotContains(t, string(data), "careful engineer")
+}
@@ -0,0 +1,193 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/contextkeys"
+	"github.com/castrovroberto/CGE/internal/logger"
+)
+
+// ShutdownMetadataKey is the session metadata holding the ShutdownInfo of a
+// run paused by a shutdown signal
+const ShutdownMetadataKey = "shutdown"
+
+// ShutdownInfo records how a shutdown signal stopped a run
+type ShutdownInfo struct {
+	Signal     string    `json:"signal"`
+	Time       time.Time `json:"time"`
+	Iteration  int       `json:"iteration"`
+	ResumeHint string    `json:"resume_hint"`
+}
+
+// SessionShutdown returns how a shutdown signal stopped the last run of a
+// session, if one did
+func SessionShutdown(session *SessionState) (ShutdownInfo, bool) {
+	var info ShutdownInfo
+	value, ok := session.Metadata[ShutdownMetadataKey]
+	if !ok {
+		return info, false
+	}
+	if typed, ok := value.(ShutdownInfo); ok {
+		return typed, true
+	}
+	data, err := json.Marshal(value)
+	if err != nil || json.Unmarshal(data, &info) != nil || info.Signal == "" {
+		return info, false
+	}
+	return info, true
+}
+
+// Shutdown asks the agent runs of a process to stop gracefully, e.g. on
+// SIGINT. Once requested, a run cancels its LLM call in flight but lets its
+// tool call in flight finish, then checkpoints its session and pauses it
+// instead of stopping halfway through a step. Bounding how long that takes
+// is up to the caller, which cancels the runs' context when it runs out.
+type Shutdown struct {
+	mu        sync.Mutex
+	signal    string
+	requested chan struct{}
+	runs      int           // Runs in progress
+	stopped   chan struct{} // Closed once no run is in progress after the request
+	closed    bool
+	paused    []PausedSession
+}
+
+// PausedSession is a session a shutdown paused
+type PausedSession struct {
+	SessionID string
+	Command   string
+}
+
+// NewShutdown returns a shutdown that has not been requested
+func NewShutdown() *Shutdown {
+	return &Shutdown{requested: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+// Request asks the runs to stop, naming the signal that asked. It returns a
+// channel closed once the runs in progress have stopped, nil when none is.
+func (s *Shutdown) Request(signal string) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.signal == "" {
+		s.signal = signal
+		close(s.requested)
+	}
+	if s.runs == 0 {
+		return nil
+	}
+	return s.stopped
+}
+
+// Requested reports whether the shutdown was requested, and by which signal
+func (s *Shutdown) Requested() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signal, s.signal != ""
+}
+
+// Paused returns the sessions the shutdown paused, in the order it did
+func (s *Shutdown) Paused() []PausedSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PausedSession(nil), s.paused...)
+}
+
+func (s *Shutdown) recordPaused(session PausedSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = append(s.paused, session)
+}
+
+// begin counts a run in progress until the returned function is called
+func (s *Shutdown) begin() (end func()) {
+	s.mu.Lock()
+	s.runs++
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.runs--
+		if s.runs == 0 && s.signal != "" && !s.closed {
+			s.closed = true
+			close(s.stopped)
+		}
+	}
+}
+
+type shutdownKey struct{}
+
+// WithShutdown returns a context whose agent runs stop gracefully when the
+// shutdown is requested
+func WithShutdown(ctx context.Context, shutdown *Shutdown) context.Context {
+	return context.WithValue(ctx, shutdownKey{}, shutdown)
+}
+
+// ShutdownFromContext returns the shutdown of the runs of ctx, nil if none
+func ShutdownFromContext(ctx context.Context) *Shutdown {
+	shutdown, _ := ctx.Value(shutdownKey{}).(*Shutdown)
+	return shutdown
+}
+
+// shutdownSignal returns the signal that requested the shutdown of the runs
+// of ctx, if it was requested
+func shutdownSignal(ctx context.Context) (string, bool) {
+	if shutdown := ShutdownFromContext(ctx); shutdown != nil {
+		return shutdown.Requested()
+	}
+	return "", false
+}
+
+// withShutdownCancel returns a context cancelled as well when the shutdown of
+// the runs of ctx is requested, for the steps not worth finishing
+func withShutdownCancel(ctx context.Context) (context.Context, context.CancelFunc) {
+	shutdown := ShutdownFromContext(ctx)
+	if shutdown == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-shutdown.requested:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// stopForShutdown checkpoints the session of a run stopped by a shutdown
+// signal and pauses it with a hint on resuming it, and returns the result of
+// the run
+func (ar *AgentRunner) stopForShutdown(ctx context.Context, signal string, result *RunResult) *RunResult {
+	contextkeys.LoggerFor(ctx, logger.Orchestrator).Info("Agent run stopped by shutdown signal", "signal", signal, "iteration", result.Iterations)
+	result.Success = false
+	result.Error = fmt.Sprintf("stopped by %s", signal)
+	result.Cancelled = true
+	if ar.currentSession == nil || ar.sessionManager == nil {
+		return result
+	}
+
+	ar.checkpointSession(ctx, result.Messages)
+	ar.sessionMu.Lock()
+	defer ar.sessionMu.Unlock()
+	if ar.currentSession.Metadata == nil {
+		ar.currentSession.Metadata = make(map[string]interface{})
+	}
+	ar.currentSession.Metadata[ShutdownMetadataKey] = ShutdownInfo{
+		Signal:     signal,
+		Time:       time.Now(),
+		Iteration:  result.Iterations,
+		ResumeHint: fmt.Sprintf("CGE session resume %s", ar.currentSession.SessionID),
+	}
+	ar.sessionManager.UpdateSessionState(ar.currentSession, "paused")
+	if err := ar.sessionManager.SaveSession(ar.currentSession); err != nil {
+		contextkeys.LoggerFor(ctx, logger.Orchestrator).Warn("Failed to pause session on shutdown", "session_id", ar.currentSession.SessionID, "error", err)
+		return result
+	}
+	ShutdownFromContext(ctx).recordPaused(PausedSession{SessionID: ar.currentSession.SessionID, Command: ar.currentSession.Command})
+	return result
+}
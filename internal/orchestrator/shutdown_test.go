@@ -0,0 +1,70 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shutdownTool requests the shutdown of the run while it executes, and
+// records whether its context was cancelled meanwhile
+type shutdownTool struct {
+	MockTool
+	shutdown *Shutdown
+	stopped  <-chan struct{}
+	ctxErr   error
+}
+
+func (s *shutdownTool) Execute(ctx context.Context, params json.RawMessage) (*agent.ToolResult, error) {
+	s.stopped = s.shutdown.Request("SIGTERM")
+	s.ctxErr = ctx.Err()
+	return &agent.ToolResult{Success: true, Data: "tests pass"}, nil
+}
+
+func TestShutdownPausesRunAfterToolCall(t *testing.T) {
+	client := &MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{ID: "call_1", Name: "run_tests", Arguments: json.RawMessage(`{}`)}},
+		{FunctionCall: &llm.FunctionCall{ID: "call_2", Name: finishToolName, Arguments: json.RawMessage(`{"answer": "Done"}`)}},
+	}}
+	shutdown := NewShutdown()
+	registry := agent.NewRegistry()
+	tool := &shutdownTool{MockTool: MockTool{name: "run_tests", parameters: json.RawMessage(`{"type":"object"}`)}, shutdown: shutdown}
+	require.NoError(t, registry.Register(tool))
+	sessions, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+	runner := NewAgentRunnerWithSession(client, registry, "system", "model", sessions)
+
+	result, err := runner.RunWithCommand(WithShutdown(context.Background(), shutdown), "Fix the tests", "generate")
+	require.NoError(t, err)
+	assert.NoError(t, tool.ctxErr, "the tool call in flight finishes")
+	assert.True(t, result.Cancelled)
+	assert.Equal(t, "stopped by SIGTERM", result.Error)
+	assert.Equal(t, 1, result.ToolCalls)
+	assert.Equal(t, 1, client.callIndex, "no step starts after the signal")
+
+	require.NotNil(t, tool.stopped)
+	select {
+	case <-tool.stopped:
+	default:
+		t.Fatal("Expected the shutdown to report the run stopped")
+	}
+	sessionID := runner.GetCurrentSessionID()
+	assert.Equal(t, []PausedSession{{SessionID: sessionID, Command: "generate"}}, shutdown.Paused())
+
+	session, err := sessions.LoadSession(sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, "paused", session.CurrentState)
+	assert.Equal(t, "tool", session.Messages[len(session.Messages)-1].Role, "the tool result is checkpointed")
+	info, ok := SessionShutdown(session)
+	require.True(t, ok)
+	assert.Equal(t, "SIGTERM", info.Signal)
+	assert.Equal(t, "CGE session resume "+sessionID, info.ResumeHint)
+
+	// Once no run is in progress, a shutdown has nothing to wait for
+	assert.Nil(t, NewShutdown().Request("SIGINT"))
+}
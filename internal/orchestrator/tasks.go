@@ -0,0 +1,130 @@
+package orchestrator
+
+import (
+	"fmt"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// Steps after which the model is nudged to keep its task list: to start one
+// once a run takes taskListNudgeCalls tool calls, and to update one whose
+// open tasks have not changed for staleTaskSteps steps
+const (
+	taskListNudgeCalls = 4
+	staleTaskSteps     = 6
+)
+
+// taskListTools are offered in every run, like the scratchpad tools, as they
+// only touch the runner's task list
+var taskListTools = agent.TaskListTools()
+
+// Tasks returns the task list the runner's agent keeps of the steps of its
+// run. It is saved with the session, and carried over to the next run of the
+// runner until every task is done.
+func (ar *AgentRunner) Tasks() *agent.TaskList {
+	return ar.tasks
+}
+
+// restoreTasks takes the tasks saved with the current session when the
+// runner has none, as when a session is resumed, and clears a list whose
+// tasks are all done, which belongs to a run that finished
+func (ar *AgentRunner) restoreTasks() {
+	if ar.tasks.Tasks() == nil && ar.currentSession != nil && len(ar.currentSession.Tasks) > 0 {
+		ar.tasks.Replace(ar.currentSession.Tasks)
+	}
+	if done, total := ar.tasks.Progress(); total > 0 && done == total {
+		ar.tasks.Replace(nil)
+	}
+}
+
+// runTool returns the tool called name among those every run offers besides
+// the registry's
+func runTool(name string) (agent.Tool, bool) {
+	if tool, ok := scratchpadTool(name); ok {
+		return tool, true
+	}
+	for _, tool := range taskListTools {
+		if tool.Name() == name {
+			return tool, true
+		}
+	}
+	return nil, false
+}
+
+// taskListToolDefinitions returns the definitions of the enabled task list
+// tools
+func (ar *AgentRunner) taskListToolDefinitions() []llm.ToolDefinition {
+	var definitions []llm.ToolDefinition
+	for _, tool := range taskListTools {
+		if ar.ToolEnabled(tool.Name()) {
+			definitions = append(definitions, llm.CreateToolDefinition(tool.Name(), tool.Description(), tool.Parameters()))
+		}
+	}
+	return definitions
+}
+
+// taskTracker follows the task list through a run, to show it with each step
+// and nudge the model when it neglects it
+type taskTracker struct {
+	list     *agent.TaskList
+	enabled  bool // Whether the model may call update_tasks
+	revision int  // Revision of the list at the last step
+	stale    int  // Steps since the list last changed
+	checked  bool // Whether a finish with open tasks was already turned down
+}
+
+// newTaskTracker follows the task list of ar for a run
+func (ar *AgentRunner) newTaskTracker() *taskTracker {
+	return &taskTracker{
+		list:     ar.tasks,
+		enabled:  ar.ToolEnabled(agent.UpdateTasksToolName),
+		revision: ar.tasks.Revision(),
+	}
+}
+
+// note describes the task list for a step after toolCalls tool calls, with
+// a nudge when the model should start or update it; it is empty when there
+// is nothing to show
+func (t *taskTracker) note(toolCalls int) string {
+	if revision := t.list.Revision(); revision != t.revision {
+		t.revision = revision
+		t.stale = 0
+	} else {
+		t.stale++
+	}
+	done, total := t.list.Progress()
+	if total == 0 {
+		if !t.enabled || toolCalls < taskListNudgeCalls {
+			return ""
+		}
+		return fmt.Sprintf("[No task list yet] If the task takes several more steps, list them with %s and tick them off as you go, so the user can follow your progress.", agent.UpdateTasksToolName)
+	}
+	note := fmt.Sprintf("[Your task list, kept with %s: %d of %d done]\n%s", agent.UpdateTasksToolName, done, total, t.list.Checklist())
+	if t.enabled && done < total && t.stale >= staleTaskSteps {
+		note += fmt.Sprintf("\nIt has not changed in %d steps: mark the tasks you finished done and the one you are on in_progress.", t.stale)
+	}
+	return note
+}
+
+// checkFinish returns why a finish should be turned down: the first time the
+// model reports success with tasks still open, it is asked to update the
+// list. It is empty when the run may end.
+func (t *taskTracker) checkFinish(success bool) string {
+	open := t.list.Open()
+	if !success || t.checked || !t.enabled || len(open) == 0 {
+		return ""
+	}
+	t.checked = true
+	return fmt.Sprintf("Not finished: %d tasks of your task list are still open:\n%s\nMark those you completed done with %s and remove those no longer needed, or call finish again to end the run with them open.", len(open), agent.FormatChecklist(open), agent.UpdateTasksToolName)
+}
+
+// withTaskNote returns messages followed by the note of tracker for this
+// step, which is not added to the conversation
+func withTaskNote(messages []Message, tracker *taskTracker, toolCalls int) []Message {
+	note := tracker.note(toolCalls)
+	if note == "" {
+		return messages
+	}
+	return append(messages[:len(messages):len(messages)], Message{Role: "user", Content: note})
+}
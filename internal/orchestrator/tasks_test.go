@@ -0,0 +1,82 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskListKeptThroughRun(t *testing.T) {
+	client := &toolRecorder{promptRecorder: promptRecorder{MockLLMClient: MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{ID: "call_1", Name: agent.UpdateTasksToolName, Arguments: json.RawMessage(`{"tasks": [{"id": "1", "title": "Rename the handler", "status": "in_progress"}, {"id": "2", "title": "Fix the callers"}]}`)}},
+		{FunctionCall: &llm.FunctionCall{ID: "call_2", Name: agent.UpdateTasksToolName, Arguments: json.RawMessage(`{"tasks": [{"id": "1", "status": "done"}]}`)}},
+		{FunctionCall: &llm.FunctionCall{ID: "call_3", Name: finishToolName, Arguments: json.RawMessage(`{"answer": "Renamed the handler"}`)}},
+		{FunctionCall: &llm.FunctionCall{ID: "call_4", Name: finishToolName, Arguments: json.RawMessage(`{"answer": "Renamed the handler; the callers are left"}`)}},
+	}}}}
+	sessions, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+	runner := NewAgentRunnerWithSession(client, agent.NewRegistry(), "system", "model", sessions)
+	runner.SetConfig(PlanRunConfig())
+	var reported [][]agent.TaskItem
+	runner.Tasks().OnChange(func(tasks []agent.TaskItem) { reported = append(reported, tasks) })
+
+	result, err := runner.RunWithCommand(context.Background(), "Rename the handler", "plan")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, "Renamed the handler; the callers are left", result.FinalResponse, "a second finish ends the run with tasks open")
+	assert.Contains(t, client.tools[0], agent.UpdateTasksToolName, "the task list tools are offered whatever the allowed tools")
+	assert.NotContains(t, client.prompts[0], "task list")
+	assert.Contains(t, client.prompts[1], "[Your task list, kept with update_tasks: 0 of 2 done]\n[~] 1: Rename the handler (in progress)\n[ ] 2: Fix the callers")
+	assert.Contains(t, client.prompts[2], "1 of 2 done]\n[x] 1: Rename the handler")
+	assert.Contains(t, client.prompts[3], "Not finished: 1 tasks of your task list are still open:\n[ ] 2: Fix the callers", "the first finish with open tasks is turned down")
+	assert.Len(t, reported, 2, "each change is reported")
+	for _, msg := range result.Messages {
+		assert.NotContains(t, msg.Content, "[Your task list", "the task list is not added to the conversation")
+	}
+
+	loaded, err := sessions.LoadSession(runner.currentSession.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, runner.Tasks().Tasks(), loaded.Tasks, "the tasks are saved with the session")
+
+	resumed := NewAgentRunnerWithSession(client, agent.NewRegistry(), "system", "model", sessions)
+	resumed.currentSession = loaded
+	resumed.restoreTasks()
+	assert.Equal(t, loaded.Tasks, resumed.Tasks().Tasks(), "a resumed session has its open tasks back")
+
+	require.NoError(t, resumed.Tasks().Update([]agent.TaskItem{{ID: "2", Status: agent.TaskDone}}, nil))
+	resumed.restoreTasks()
+	assert.Nil(t, resumed.Tasks().Tasks(), "a list whose tasks are all done is cleared for the next run")
+}
+
+func TestTaskListNudges(t *testing.T) {
+	var responses []*llm.FunctionCallResponse
+	for i, file := range files(taskListNudgeCalls) {
+		responses = append(responses, &llm.FunctionCallResponse{FunctionCall: &llm.FunctionCall{ID: fmt.Sprintf("call_%d", i), Name: "read_file", Arguments: json.RawMessage(fmt.Sprintf(`{"file_path": %q}`, file))}})
+	}
+	client := &promptRecorder{MockLLMClient: MockLLMClient{responses: responses}}
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(&countingTool{name: "read_file"}))
+	runner := NewAgentRunner(client, registry, "system", "model")
+
+	_, err := runner.RunWithCommand(context.Background(), "Review the reader", "review")
+	require.NoError(t, err)
+	assert.NotContains(t, client.prompts[taskListNudgeCalls-1], "[No task list yet]")
+	assert.Contains(t, client.prompts[taskListNudgeCalls], "[No task list yet]", "a long run without a task list is asked to keep one")
+
+	require.NoError(t, runner.Tasks().Update([]agent.TaskItem{{ID: "1", Title: "Review the reader"}}, nil))
+	tracker := runner.newTaskTracker()
+	for i := 1; i < staleTaskSteps; i++ {
+		assert.NotContains(t, tracker.note(0), "It has not changed")
+	}
+	assert.Contains(t, tracker.note(0), "It has not changed in 6 steps", "a list left untouched with tasks open is to be updated")
+
+	require.NoError(t, runner.SetToolEnabled(agent.UpdateTasksToolName, false))
+	runner.Tasks().Replace(nil)
+	assert.Empty(t, runner.newTaskTracker().note(taskListNudgeCalls), "no nudge when the tool is disabled")
+}
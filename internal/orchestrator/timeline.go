@@ -0,0 +1,328 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of the events of a run timeline
+const (
+	TimelineLLM  = "llm"
+	TimelineTool = "tool"
+	TimelineIdle = "idle"
+)
+
+// DefaultTimelineGap is the shortest stretch with nothing running that a
+// timeline shows as idle
+const DefaultTimelineGap = 2 * time.Second
+
+// maxTimelineSlowTools bounds the tools a timeline lists by time spent
+const maxTimelineSlowTools = 5
+
+// Timeline lays out what a run spent its time on: LLM calls, tool calls and
+// the idle gaps between them, oldest first
+type Timeline struct {
+	SessionID string          `json:"session_id"`
+	Command   string          `json:"command"`
+	Start     time.Time       `json:"start"`
+	End       time.Time       `json:"end"`
+	Events    []TimelineEvent `json:"events"`
+
+	LLMTime   time.Duration      `json:"llm_time"`
+	ToolTime  time.Duration      `json:"tool_time"`
+	IdleTime  time.Duration      `json:"idle_time"`
+	Retries   int                `json:"retries"`
+	SlowTools []TimelineToolTime `json:"slow_tools"` // Tools by time spent, slowest first
+}
+
+// TimelineEvent is a stretch of a run
+type TimelineEvent struct {
+	Kind     string        `json:"kind"`
+	Label    string        `json:"label"` // Model or tool name
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Failed   bool          `json:"failed,omitempty"`
+	Retry    bool          `json:"retry,omitempty"` // Repeats a call that failed
+	Detail   string        `json:"detail,omitempty"`
+}
+
+// End returns when the event ended
+func (e TimelineEvent) End() time.Time {
+	return e.Start.Add(e.Duration)
+}
+
+// TimelineToolTime is the time a run spent in a tool
+type TimelineToolTime struct {
+	ToolName string        `json:"tool_name"`
+	Calls    int           `json:"calls"`
+	Total    time.Duration `json:"total"`
+	Max      time.Duration `json:"max"`
+}
+
+// BuildTimeline lays out the LLM calls, routing attempts and tool calls of a
+// session; stretches of at least minGap with none of them running are idle.
+// Sessions recorded before LLM calls were have tool calls and idle gaps only.
+func BuildTimeline(session *SessionState, minGap time.Duration) *Timeline {
+	timeline := &Timeline{SessionID: session.SessionID, Command: session.Command, Start: session.StartTime}
+
+	var busy []TimelineEvent
+	for _, call := range session.LLMCalls {
+		busy = append(busy, TimelineEvent{Kind: TimelineLLM, Label: call.Model, Start: call.Timestamp, Duration: call.Duration, Failed: call.Error != "", Detail: call.Error})
+	}
+	for _, decision := range session.Routing {
+		event := TimelineEvent{Kind: TimelineLLM, Label: decision.SmallModel, Start: decision.Time, Duration: decision.Duration, Detail: "small model"}
+		if decision.Escalated {
+			event.Failed, event.Detail = true, "small model, escalated: "+decision.Reason
+		}
+		busy = append(busy, event)
+	}
+	failed := map[string]bool{}
+	tools := map[string]*TimelineToolTime{}
+	for _, call := range session.ToolCalls {
+		signature := call.ToolName + "\x00" + compactJSON(call.Parameters)
+		event := TimelineEvent{Kind: TimelineTool, Label: call.ToolName, Start: call.Timestamp, Duration: call.Duration, Failed: !call.Success, Retry: failed[signature], Detail: call.Error}
+		failed[signature] = !call.Success
+		if event.Retry {
+			timeline.Retries++
+		}
+		busy = append(busy, event)
+
+		stat := tools[call.ToolName]
+		if stat == nil {
+			stat = &TimelineToolTime{ToolName: call.ToolName}
+			tools[call.ToolName] = stat
+		}
+		stat.Calls++
+		stat.Total += call.Duration
+		if call.Duration > stat.Max {
+			stat.Max = call.Duration
+		}
+	}
+	sort.SliceStable(busy, func(i, j int) bool { return busy[i].Start.Before(busy[j].Start) })
+
+	// The run ends with its last event, or later when it was recorded
+	timeline.End = timeline.Start
+	switch {
+	case session.EndTime != nil:
+		timeline.End = *session.EndTime
+	case !session.UpdatedAt.IsZero():
+		timeline.End = session.UpdatedAt
+	}
+	for _, event := range busy {
+		if event.End().After(timeline.End) {
+			timeline.End = event.End()
+		}
+	}
+
+	// Idle gaps are where no event runs; events of delegated tasks may overlap
+	cursor := timeline.Start
+	for _, event := range busy {
+		if gap := event.Start.Sub(cursor); gap >= minGap && gap > 0 {
+			timeline.Events = append(timeline.Events, TimelineEvent{Kind: TimelineIdle, Label: "idle", Start: cursor, Duration: gap})
+			timeline.IdleTime += gap
+		}
+		timeline.Events = append(timeline.Events, event)
+		if event.End().After(cursor) {
+			cursor = event.End()
+		}
+		switch event.Kind {
+		case TimelineLLM:
+			timeline.LLMTime += event.Duration
+		case TimelineTool:
+			timeline.ToolTime += event.Duration
+		}
+	}
+	if gap := timeline.End.Sub(cursor); gap >= minGap && gap > 0 {
+		timeline.Events = append(timeline.Events, TimelineEvent{Kind: TimelineIdle, Label: "idle", Start: cursor, Duration: gap})
+		timeline.IdleTime += gap
+	}
+
+	for _, stat := range tools {
+		timeline.SlowTools = append(timeline.SlowTools, *stat)
+	}
+	sort.Slice(timeline.SlowTools, func(i, j int) bool {
+		if timeline.SlowTools[i].Total != timeline.SlowTools[j].Total {
+			return timeline.SlowTools[i].Total > timeline.SlowTools[j].Total
+		}
+		return timeline.SlowTools[i].ToolName < timeline.SlowTools[j].ToolName
+	})
+	if len(timeline.SlowTools) > maxTimelineSlowTools {
+		timeline.SlowTools = timeline.SlowTools[:maxTimelineSlowTools]
+	}
+	return timeline
+}
+
+// compactJSON returns raw without insignificant whitespace, so equal
+// arguments compare equal
+func compactJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// Duration returns the wall time of the run
+func (t *Timeline) Duration() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// share returns d as a percentage of the run
+func (t *Timeline) share(d time.Duration) float64 {
+	if t.Duration() <= 0 {
+		return 0
+	}
+	return float64(d) / float64(t.Duration()) * 100
+}
+
+// WriteText renders the timeline as a Gantt chart with bars width columns
+// wide, followed by where the time went
+func (t *Timeline) WriteText(w io.Writer, width int) {
+	width = max(width, 10)
+	labelWidth := 4
+	for _, event := range t.Events {
+		labelWidth = max(labelWidth, len(event.Label))
+	}
+	labelWidth = min(labelWidth, 24)
+
+	fmt.Fprintf(w, "Timeline of %s (%s), %s\n", t.SessionID, t.Command, formatTimelineDuration(t.Duration()))
+	fmt.Fprintf(w, "Started %s; each column is %s\n\n", t.Start.Format("2006-01-02 15:04:05"), formatTimelineDuration(t.Duration()/time.Duration(width)))
+	for _, event := range t.Events {
+		from, to := t.column(event.Start, width), t.column(event.End(), width)
+		to = max(to, from+1)
+		bar := strings.Repeat(" ", from) + strings.Repeat(timelineGlyph(event), to-from) + strings.Repeat(" ", max(width-to, 0))
+		label := event.Label
+		if len(label) > labelWidth {
+			label = label[:labelWidth-1] + "…"
+		}
+		note := ""
+		switch {
+		case event.Retry && event.Failed:
+			note = "  retry, failed"
+		case event.Retry:
+			note = "  retry"
+		case event.Failed:
+			note = "  failed"
+		}
+		fmt.Fprintf(w, "%8s  %-4s %-*s |%s| %s%s\n", "+"+formatTimelineDuration(event.Start.Sub(t.Start)), event.Kind, labelWidth, label, bar, formatTimelineDuration(event.Duration), note)
+	}
+	fmt.Fprintf(w, "\n█ llm  ▒ tool  · idle\n\n")
+
+	fmt.Fprintf(w, "Time spent:\n")
+	fmt.Fprintf(w, "  LLM calls:  %s (%.0f%%)\n", formatTimelineDuration(t.LLMTime), t.share(t.LLMTime))
+	fmt.Fprintf(w, "  Tool calls: %s (%.0f%%)\n", formatTimelineDuration(t.ToolTime), t.share(t.ToolTime))
+	fmt.Fprintf(w, "  Idle:       %s (%.0f%%)\n", formatTimelineDuration(t.IdleTime), t.share(t.IdleTime))
+	if t.Retries > 0 {
+		fmt.Fprintf(w, "  Retried tool calls: %d\n", t.Retries)
+	}
+	if len(t.SlowTools) > 0 {
+		fmt.Fprintf(w, "\nSlowest tools:\n")
+		for _, tool := range t.SlowTools {
+			fmt.Fprintf(w, "  %s: %s in %d call(s), longest %s\n", tool.ToolName, formatTimelineDuration(tool.Total), tool.Calls, formatTimelineDuration(tool.Max))
+		}
+	}
+}
+
+// column returns the column of the chart at, among width
+func (t *Timeline) column(at time.Time, width int) int {
+	if t.Duration() <= 0 {
+		return 0
+	}
+	return min(int(float64(at.Sub(t.Start))/float64(t.Duration())*float64(width)), width)
+}
+
+func timelineGlyph(event TimelineEvent) string {
+	switch event.Kind {
+	case TimelineLLM:
+		return "█"
+	case TimelineTool:
+		return "▒"
+	}
+	return "·"
+}
+
+// formatTimelineDuration formats d to a tenth of a second, or milliseconds
+// below a second
+func formatTimelineDuration(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// timelineHTML is the page WriteHTML renders: a row per event, with its bar
+// placed and sized as a percentage of the run
+var timelineHTML = template.Must(template.New("timeline").Funcs(template.FuncMap{
+	"duration": formatTimelineDuration,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Timeline of {{.SessionID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: 2px 6px; font-size: 13px; white-space: nowrap; }
+td.track { width: 70%; position: relative; }
+.bar { position: absolute; top: 3px; bottom: 3px; min-width: 2px; border-radius: 2px; }
+.llm { background: #4a7bd0; } .tool { background: #4caf7a; } .idle { background: #ddd; }
+.failed { background: #d0564a; } .retry { outline: 2px dashed #e0a030; }
+tr:hover { background: #f4f4f4; }
+</style>
+</head>
+<body>
+<h1>Timeline of {{.SessionID}}</h1>
+<p>{{.Command}}, started {{.Start.Format "2006-01-02 15:04:05"}}, {{duration .Duration}}</p>
+<p>LLM calls {{duration .LLMTime}} ({{printf "%.0f" .LLMShare}}%), tool calls {{duration .ToolTime}} ({{printf "%.0f" .ToolShare}}%), idle {{duration .IdleTime}} ({{printf "%.0f" .IdleShare}}%){{if .Retries}}, {{.Retries}} retried tool call(s){{end}}</p>
+<table>
+{{range .Rows}}<tr title="{{.Detail}}">
+<td>+{{duration .Offset}}</td><td>{{.Kind}}</td><td>{{.Label}}</td>
+<td class="track"><div class="bar {{.Class}}" style="left: {{printf "%.3f" .Left}}%; width: {{printf "%.3f" .Width}}%"></div></td>
+<td>{{duration .Duration}}{{if .Retry}} retry{{end}}{{if .Failed}} failed{{end}}</td>
+</tr>
+{{end}}</table>
+{{if .SlowTools}}<h2>Slowest tools</h2>
+<ul>{{range .SlowTools}}<li>{{.ToolName}}: {{duration .Total}} in {{.Calls}} call(s), longest {{duration .Max}}</li>{{end}}</ul>{{end}}
+</body>
+</html>
+`))
+
+// timelineRow is an event as the HTML page places it
+type timelineRow struct {
+	TimelineEvent
+	Offset      time.Duration
+	Left, Width float64 // Percentages of the run
+	Class       string
+}
+
+// WriteHTML renders the timeline as a standalone HTML page
+func (t *Timeline) WriteHTML(w io.Writer) error {
+	rows := make([]timelineRow, len(t.Events))
+	for i, event := range t.Events {
+		class := event.Kind
+		if event.Failed {
+			class += " failed"
+		}
+		if event.Retry {
+			class += " retry"
+		}
+		rows[i] = timelineRow{
+			TimelineEvent: event,
+			Offset:        event.Start.Sub(t.Start),
+			Left:          t.share(event.Start.Sub(t.Start)),
+			Width:         t.share(event.Duration),
+			Class:         class,
+		}
+	}
+	return timelineHTML.Execute(w, struct {
+		*Timeline
+		Rows                           []timelineRow
+		LLMShare, ToolShare, IdleShare float64
+	}{t, rows, t.share(t.LLMTime), t.share(t.ToolTime), t.share(t.IdleTime)})
+}
@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	at := func(seconds float64) time.Time { return start.Add(time.Duration(seconds * float64(time.Second))) }
+	end := at(40)
+	session := &SessionState{
+		SessionID: "1f2e3d4c",
+		Command:   "generate",
+		StartTime: start,
+		EndTime:   &end,
+		LLMCalls: []LLMCallRecord{
+			{Timestamp: at(0), Duration: 4 * time.Second, Model: "qwen2.5-coder"},
+			{Timestamp: at(10), Duration: 3 * time.Second, Model: "qwen2.5-coder"},
+			{Timestamp: at(30), Duration: 2 * time.Second, Model: "qwen2.5-coder", Error: "context deadline exceeded"},
+		},
+		Routing: []RoutingDecision{{Time: at(32), Duration: time.Second, SmallModel: "llama3.2", Escalated: true, Reason: "low confidence"}},
+		ToolCalls: []ToolCallRecord{
+			{Timestamp: at(4), Duration: 5 * time.Second, ToolName: "run_tests", Parameters: json.RawMessage(`{"path": "./..."}`), Error: "2 tests failed"},
+			{Timestamp: at(13), Duration: 500 * time.Millisecond, ToolName: "read_file", Parameters: json.RawMessage(`{"file_path":"a.go"}`), Success: true},
+			{Timestamp: at(14), Duration: 6 * time.Second, ToolName: "run_tests", Parameters: json.RawMessage(`{"path":"./..."}`), Success: true},
+		},
+	}
+
+	timeline := BuildTimeline(session, DefaultTimelineGap)
+	assert.Equal(t, 40*time.Second, timeline.Duration())
+	assert.Equal(t, 10*time.Second, timeline.LLMTime)
+	assert.Equal(t, 11500*time.Millisecond, timeline.ToolTime)
+	// Idle from 20s to 30s and from 33s to the end
+	assert.Equal(t, 17*time.Second, timeline.IdleTime)
+	assert.Equal(t, 1, timeline.Retries, "the second run_tests repeats the failed one")
+
+	var kinds []string
+	for _, event := range timeline.Events {
+		kinds = append(kinds, event.Kind+":"+event.Label)
+	}
+	assert.Equal(t, []string{
+		"llm:qwen2.5-coder", "tool:run_tests", "llm:qwen2.5-coder", "tool:read_file", "tool:run_tests",
+		"idle:idle", "llm:qwen2.5-coder", "llm:llama3.2", "idle:idle",
+	}, kinds)
+	assert.True(t, timeline.Events[4].Retry)
+	assert.True(t, timeline.Events[6].Failed)
+	assert.True(t, timeline.Events[7].Failed, "escalated routing attempts count as failed")
+
+	require.Len(t, timeline.SlowTools, 2)
+	assert.Equal(t, TimelineToolTime{ToolName: "run_tests", Calls: 2, Total: 11 * time.Second, Max: 6 * time.Second}, timeline.SlowTools[0])
+
+	var text strings.Builder
+	timeline.WriteText(&text, 40)
+	assert.Contains(t, text.String(), "Timeline of 1f2e3d4c (generate), 40s")
+	assert.Contains(t, text.String(), "   +14s  tool run_tests     |              ▒▒▒▒▒▒                    | 6s  retry")
+	assert.Contains(t, text.String(), "Idle:       17s (42%)")
+	assert.Contains(t, text.String(), "run_tests: 11s in 2 call(s), longest 6s")
+
+	var html strings.Builder
+	require.NoError(t, timeline.WriteHTML(&html))
+	assert.Contains(t, html.String(), `<div class="bar tool retry" style="left: 35.000%; width: 15.000%">`)
+	assert.Contains(t, html.String(), `title="context deadline exceeded"`)
+}
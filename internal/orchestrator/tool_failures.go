@@ -0,0 +1,156 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// maxRecentFailures bounds the failures summarized to the model
+const maxRecentFailures = 5
+
+// maxFailureMessageChars bounds the error message of a summarized failure
+const maxFailureMessageChars = 120
+
+// failureNotePrefix marks the summary of recent failures added to the
+// conversation sent to the model
+const failureNotePrefix = "[Recent tool failures in this run; do not repeat these calls unchanged]\n"
+
+// transientErrorCodes are failures an identical call may not repeat, so they
+// do not block it
+var transientErrorCodes = map[agent.ToolErrorCode]bool{
+	agent.ErrorCodeTimeout:        true,
+	agent.ErrorCodeCommandTimeout: true,
+	agent.ErrorCodeResourceLimit:  true,
+}
+
+// toolFailure is a failed tool call remembered for the rest of a run
+type toolFailure struct {
+	signature string
+	summary   string
+	transient bool
+	repeats   int
+}
+
+// failureMemory remembers the tool calls that failed in a run, so the model
+// is reminded of them and identical calls are not run again while nothing
+// that could change their outcome has happened
+type failureMemory struct {
+	failures []*toolFailure // Oldest first
+}
+
+// record remembers a failed call
+func (m *failureMemory) record(call *llm.FunctionCall, signature string, result *agent.ToolResult) {
+	if failure := m.find(signature); failure != nil {
+		failure.repeats++
+		return
+	}
+	message := result.Error
+	transient := false
+	if result.StandardizedError != nil {
+		message = result.StandardizedError.Message
+		transient = transientErrorCodes[result.StandardizedError.Code]
+	}
+	m.failures = append(m.failures, &toolFailure{
+		signature: signature,
+		summary:   fmt.Sprintf("%s failed: %s", describeToolCall(call), truncateFailureMessage(message)),
+		transient: transient,
+		repeats:   1,
+	})
+}
+
+// repeated returns the failure of an identical earlier call whose outcome
+// would not change, or nil when the call may run
+func (m *failureMemory) repeated(signature string) *toolFailure {
+	if failure := m.find(signature); failure != nil && !failure.transient {
+		return failure
+	}
+	return nil
+}
+
+// forget drops every remembered failure, once a call that could change their
+// outcome succeeded
+func (m *failureMemory) forget() {
+	m.failures = nil
+}
+
+func (m *failureMemory) find(signature string) *toolFailure {
+	for _, failure := range m.failures {
+		if failure.signature == signature {
+			return failure
+		}
+	}
+	return nil
+}
+
+// note returns the summary of the most recent failures to show the model, or
+// an empty string when there are none
+func (m *failureMemory) note() string {
+	if len(m.failures) == 0 {
+		return ""
+	}
+	recent := m.failures
+	if len(recent) > maxRecentFailures {
+		recent = recent[len(recent)-maxRecentFailures:]
+	}
+	var b strings.Builder
+	b.WriteString(failureNotePrefix)
+	for _, failure := range recent {
+		b.WriteString("- " + failure.summary)
+		if failure.repeats > 1 {
+			fmt.Fprintf(&b, " (%d times)", failure.repeats)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// withFailureNote returns the messages to send the model: the conversation,
+// followed by the summary of recent failures when there are any. The summary
+// is not kept in the conversation, so it never piles up.
+func withFailureNote(messages []Message, failures *failureMemory) []Message {
+	note := failures.note()
+	if note == "" {
+		return messages
+	}
+	return append(messages[:len(messages):len(messages)], Message{Role: "user", Content: note})
+}
+
+// mayChangeOutcomes reports whether a successful call could change the outcome
+// of failed calls: it changed files, committed, ran a command or delegated
+func mayChangeOutcomes(call *llm.FunctionCall) bool {
+	if _, _, ok := toolCheckpoint(call); ok {
+		return true
+	}
+	switch call.Name {
+	case "run_shell_command", "delegate_task", "request_human_clarification":
+		return true
+	}
+	return false
+}
+
+// describeToolCall names a call by its tool and the subject of its arguments,
+// e.g. "read_file on main.go"
+func describeToolCall(call *llm.FunctionCall) string {
+	var args map[string]interface{}
+	_ = json.Unmarshal(call.Arguments, &args)
+	for _, key := range []string{"file_path", "path", "command", "pattern", "query", "target"} {
+		if subject, ok := args[key].(string); ok && subject != "" {
+			return fmt.Sprintf("%s on %s", call.Name, subject)
+		}
+	}
+	return call.Name
+}
+
+// truncateFailureMessage keeps the first line of an error message, bounded
+// to maxFailureMessageChars
+func truncateFailureMessage(message string) string {
+	message, _, _ = strings.Cut(strings.TrimSpace(message), "\n")
+	if len(message) > maxFailureMessageChars {
+		message = message[:maxFailureMessageChars] + "…"
+	}
+	return message
+}
@@ -0,0 +1,82 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// promptRecordingLLMClient records the prompts it is given
+type promptRecordingLLMClient struct {
+	MockLLMClient
+	prompts []string
+}
+
+func (m *promptRecordingLLMClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []llm.ToolDefinition) (*llm.FunctionCallResponse, error) {
+	m.prompts = append(m.prompts, prompt)
+	return m.MockLLMClient.GenerateWithFunctions(ctx, modelName, prompt, systemPrompt, tools)
+}
+
+func TestFailureMemory(t *testing.T) {
+	memory := &failureMemory{}
+	assert.Empty(t, memory.note())
+
+	read := &llm.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"file_path":"missing.go"}`)}
+	memory.record(read, "sig", agent.NewErrorResult(agent.NewFileNotFoundError("missing.go")))
+	memory.record(read, "sig", agent.NewErrorResult(agent.NewFileNotFoundError("missing.go")))
+	assert.NotNil(t, memory.repeated("sig"))
+	assert.Nil(t, memory.repeated("other"))
+	assert.Contains(t, memory.note(), "- read_file on missing.go failed: ")
+	assert.Contains(t, memory.note(), "(2 times)")
+
+	timeout := agent.NewErrorResult(agent.NewStandardizedError(agent.ErrorCodeTimeout, "timed out\nafter 60s", ""))
+	memory.record(&llm.FunctionCall{Name: "run_tests", Arguments: json.RawMessage(`{}`)}, "tests", timeout)
+	assert.Nil(t, memory.repeated("tests"), "transient failures may be retried unchanged")
+	assert.True(t, strings.HasSuffix(memory.note(), "- run_tests failed: timed out"), "only the first line of messages is kept")
+
+	for i := 0; i < maxRecentFailures; i++ {
+		memory.record(read, fmt.Sprintf("sig-%d", i), &agent.ToolResult{Error: "boom"})
+	}
+	assert.Equal(t, maxRecentFailures, strings.Count(memory.note(), "\n- "), "only the most recent failures are summarized")
+
+	messages := []Message{{Role: "user", Content: "task"}}
+	withNote := withFailureNote(messages, memory)
+	require.Len(t, withNote, 2)
+	assert.True(t, strings.HasPrefix(withNote[1].Content, failureNotePrefix))
+	assert.Len(t, messages, 1, "the conversation is left unchanged")
+
+	memory.forget()
+	assert.Nil(t, memory.repeated("sig"))
+	assert.Equal(t, messages, withFailureNote(messages, memory))
+
+	assert.True(t, mayChangeOutcomes(&llm.FunctionCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path":"a.go"}`)}))
+	assert.False(t, mayChangeOutcomes(read))
+}
+
+func TestAgentRunner_SkipsRepeatedFailingCalls(t *testing.T) {
+	tool := NewMockFailingTool("read_file", 10, agent.ErrorCodeFileNotFound, "File not found: missing.go")
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(tool))
+
+	call := &llm.FunctionCallResponse{FunctionCall: &llm.FunctionCall{ID: "call", Name: "read_file", Arguments: json.RawMessage(`{"input":"missing.go"}`)}}
+	client := &promptRecordingLLMClient{MockLLMClient: MockLLMClient{responses: []*llm.FunctionCallResponse{call, call}}}
+	runner := NewAgentRunner(client, registry, "system", "model")
+
+	result, err := runner.Run(context.Background(), "Read missing.go")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 1, tool.currentAttempt, "the identical call is not run again")
+	assert.Contains(t, result.Messages[len(result.Messages)-2].Content, "Not run: this exact call already failed")
+
+	require.Len(t, client.prompts, 3)
+	assert.NotContains(t, client.prompts[0], failureNotePrefix)
+	assert.Contains(t, client.prompts[1], failureNotePrefix+"- read_file failed: File not found: missing.go")
+	assert.Contains(t, client.prompts[2], "(2 times)")
+}
@@ -0,0 +1,126 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+)
+
+// ToolStats counts the calls of a tool made through the runner's registry
+type ToolStats = agent.ToolStats
+
+// SetToolEnabled disables a registered tool, or one every run offers such
+// as update_tasks, for the remaining runs of the runner, or enables it again.
+// A disabled tool is not offered to the model, and calls to it fail.
+func (ar *AgentRunner) SetToolEnabled(name string, enabled bool) error {
+	if _, ok := ar.toolRegistry.Get(name); !ok {
+		if _, ok := runTool(name); !ok {
+			return fmt.Errorf("tool not found: %s", name)
+		}
+	}
+	ar.toolsMu.Lock()
+	defer ar.toolsMu.Unlock()
+	if ar.disabledTools == nil {
+		ar.disabledTools = make(map[string]bool)
+	}
+	if enabled {
+		delete(ar.disabledTools, name)
+	} else {
+		ar.disabledTools[name] = true
+	}
+	return nil
+}
+
+// ToolEnabled reports whether the tool called name may be used
+func (ar *AgentRunner) ToolEnabled(name string) bool {
+	ar.toolsMu.Lock()
+	defer ar.toolsMu.Unlock()
+	return !ar.disabledTools[name]
+}
+
+// ToolStats returns the call statistics of each tool called so far, by name
+func (ar *AgentRunner) ToolStats() map[string]ToolStats {
+	return ar.toolRegistry.Stats()
+}
+
+// ToolRegistry returns the tools the runner can call
+func (ar *AgentRunner) ToolRegistry() *agent.Registry {
+	return ar.toolRegistry
+}
+
+// recordSessionToolCall adds a tool call of the given iteration, started at
+// started, to the current session, if any
+func (ar *AgentRunner) recordSessionToolCall(call *llm.FunctionCall, iteration int, started time.Time, result *agent.ToolResult, err error) {
+	if ar.currentSession == nil {
+		return
+	}
+	ar.sessionMu.Lock()
+	defer ar.sessionMu.Unlock()
+	ar.sessionManager.AddToolCall(ar.currentSession, newToolCallRecord(call, iteration, started, result, err))
+}
+
+// recordSessionLLMCall adds a finished generation request to the session of
+// the run, if any
+func (ar *AgentRunner) recordSessionLLMCall(model string, started time.Time, err error) {
+	if ar.currentSession == nil {
+		return
+	}
+	record := LLMCallRecord{Timestamp: started, Duration: time.Since(started), Model: model}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	ar.sessionMu.Lock()
+	defer ar.sessionMu.Unlock()
+	ar.currentSession.LLMCalls = append(ar.currentSession.LLMCalls, record)
+}
+
+// newToolCallRecord describes a finished tool call for its session. Results
+// are already in the messages of the session, so only their outcome is kept.
+func newToolCallRecord(call *llm.FunctionCall, iteration int, started time.Time, result *agent.ToolResult, err error) ToolCallRecord {
+	record := ToolCallRecord{
+		ID:         call.ID,
+		Timestamp:  started,
+		ToolName:   call.Name,
+		Parameters: call.Arguments,
+		Duration:   time.Since(started),
+		ErrorCode:  string(agent.ErrorCodeOf(result, err)),
+		Iteration:  iteration,
+	}
+	switch {
+	case err != nil:
+		record.Error = err.Error()
+	case result != nil:
+		record.Success = result.Success
+		record.Error = result.Error
+	}
+	if record.Success {
+		record.Files = changedFiles(call, result)
+	}
+	return record
+}
+
+// changedFiles returns the files a successful file-changing call changed
+func changedFiles(call *llm.FunctionCall, result *agent.ToolResult) []string {
+	checkpoint, subject, ok := toolCheckpoint(call)
+	if !ok || checkpoint != CheckpointFileChange {
+		return nil
+	}
+	if call.Name != "search_replace" {
+		if subject == "" {
+			return nil
+		}
+		return []string{subject}
+	}
+	if result == nil {
+		return nil
+	}
+	data, _ := result.Data.(map[string]interface{})
+	changes, _ := data["changes"].([]agent.FileReplacement)
+	var files []string
+	for _, change := range changes {
+		files = append(files, change.FilePath)
+	}
+	return files
+}
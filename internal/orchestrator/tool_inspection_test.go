@@ -0,0 +1,95 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisabledToolsAreNotOffered(t *testing.T) {
+	writeTool := &countingTool{name: "write_file"}
+	readTool := &countingTool{name: "read_file"}
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(writeTool))
+	require.NoError(t, registry.Register(readTool))
+	runner := NewAgentRunner(&MockLLMClient{}, registry, "system", "model")
+
+	assert.Error(t, runner.SetToolEnabled("delete_everything", false))
+	require.NoError(t, runner.SetToolEnabled("write_file", false))
+	assert.False(t, runner.ToolEnabled("write_file"))
+	definitions := runner.prepareToolDefinitions()
+	require.Len(t, definitions, 1)
+	assert.Equal(t, "read_file", definitions[0].Function.Name)
+
+	result, err := runner.executeTool(context.Background(), &llm.FunctionCall{Name: "write_file", Arguments: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "disabled")
+	assert.Zero(t, writeTool.calls)
+
+	require.NoError(t, runner.SetToolEnabled("write_file", true))
+	assert.Len(t, runner.prepareToolDefinitions(), 2)
+}
+
+func TestToolStats(t *testing.T) {
+	tool := &countingTool{name: "read_file"}
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(tool))
+	runner := NewAgentRunner(&MockLLMClient{}, registry, "system", "model")
+
+	for _, args := range []string{`{"file_path": "a.go"}`, `{"file_path": "b.go"}`, `not json`} {
+		_, _ = runner.executeTool(context.Background(), &llm.FunctionCall{Name: "read_file", Arguments: json.RawMessage(args)})
+	}
+
+	stats := runner.ToolStats()
+	require.Contains(t, stats, "read_file")
+	assert.Equal(t, 3, stats["read_file"].Calls)
+	assert.Equal(t, 1, stats["read_file"].Errors, "invalid arguments count as an error")
+	assert.InDelta(t, 1.0/3, stats["read_file"].ErrorRate(), 0.001)
+	assert.Equal(t, map[agent.ToolErrorCode]int{agent.ErrorCodeInternalError: 1}, stats["read_file"].ErrorCodes)
+	assert.Equal(t, registry.Stats(), stats, "the statistics are kept by the registry")
+	assert.Zero(t, ToolStats{}.ErrorRate())
+}
+
+func TestToolCallsAreRecordedInSessions(t *testing.T) {
+	registry := agent.NewRegistry()
+	require.NoError(t, registry.Register(&countingTool{name: "write_file"}))
+	require.NoError(t, registry.Register(NewMockFailingTool("read_file", 10, agent.ErrorCodeFileNotFound, "File not found: missing.go")))
+
+	client := &MockLLMClient{responses: []*llm.FunctionCallResponse{
+		{FunctionCall: &llm.FunctionCall{ID: "call_1", Name: "read_file", Arguments: json.RawMessage(`{"input": "missing.go"}`)}},
+		{FunctionCall: &llm.FunctionCall{ID: "call_2", Name: "write_file", Arguments: json.RawMessage(`{"file_path": "main.go", "content": "x"}`)}},
+	}}
+	sessions, err := NewSessionManager(t.TempDir(), nil)
+	require.NoError(t, err)
+	runner := NewAgentRunnerWithSession(client, registry, "system", "model", sessions)
+
+	_, err = runner.RunWithCommand(context.Background(), "Fix main.go", "generate")
+	require.NoError(t, err)
+
+	calls := runner.currentSession.ToolCalls
+	require.Len(t, calls, 2)
+	assert.Equal(t, "read_file", calls[0].ToolName)
+	assert.False(t, calls[0].Success)
+	assert.Equal(t, string(agent.ErrorCodeFileNotFound), calls[0].ErrorCode)
+	assert.Equal(t, 1, calls[0].Iteration)
+	assert.True(t, calls[1].Success)
+	assert.Empty(t, calls[1].ErrorCode)
+
+	report, err := NewSessionAnalytics(sessions).GenerateReport()
+	require.NoError(t, err)
+	require.Len(t, report.ToolUsageStats, 2)
+	for _, stat := range report.ToolUsageStats {
+		if stat.ToolName == "read_file" {
+			assert.Equal(t, map[string]int{string(agent.ErrorCodeFileNotFound): 1}, stat.ErrorCodes)
+			assert.Equal(t, string(agent.ErrorCodeFileNotFound), stat.TopErrorCode())
+		} else {
+			assert.Empty(t, stat.TopErrorCode())
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package patchutils
+
+import "strings"
+
+// maxMergeCells bounds the line comparison table of a merge; larger changes
+// are treated as replacing the whole region
+const maxMergeCells = 4_000_000
+
+// Conflict markers written around regions both sides changed differently
+const (
+	MarkerOurs   = "<<<<<<< ours"
+	MarkerBase   = "======="
+	MarkerTheirs = ">>>>>>> theirs"
+)
+
+// MergeResult is the outcome of a three-way merge
+type MergeResult struct {
+	Content   string // Merged text, with conflict markers around conflicts
+	Conflicts int    // Number of regions both sides changed differently
+}
+
+// Merge3 merges the changes made from base to ours and from base to theirs,
+// line by line, like git merge-file. Regions both sides changed in different
+// ways are conflicts, written with ours between MarkerOurs and MarkerBase and
+// theirs between MarkerBase and MarkerTheirs.
+func Merge3(base, ours, theirs string) MergeResult {
+	baseLines, ourLines, theirLines := splitLines(base), splitLines(ours), splitLines(theirs)
+	toOurs, toTheirs := matchLines(baseLines, ourLines), matchLines(baseLines, theirLines)
+
+	var result MergeResult
+	var out strings.Builder
+	b, o, t := 0, 0, 0
+	for {
+		// The next base line both sides kept ends the current region
+		k := b
+		for k < len(baseLines) && (toOurs[k] < 0 || toTheirs[k] < 0) {
+			k++
+		}
+		if k == len(baseLines) {
+			result.Conflicts += mergeRegion(&out, baseLines[b:], ourLines[o:], theirLines[t:])
+			break
+		}
+		result.Conflicts += mergeRegion(&out, baseLines[b:k], ourLines[o:toOurs[k]], theirLines[t:toTheirs[k]])
+		out.WriteString(baseLines[k])
+		b, o, t = k+1, toOurs[k]+1, toTheirs[k]+1
+	}
+	result.Content = out.String()
+	return result
+}
+
+// mergeRegion writes the merge of one region between kept lines, returning 1
+// when it is a conflict
+func mergeRegion(out *strings.Builder, base, ours, theirs []string) int {
+	switch {
+	case equalLines(ours, theirs), equalLines(base, theirs):
+		writeLines(out, ours)
+	case equalLines(base, ours):
+		writeLines(out, theirs)
+	default:
+		out.WriteString(MarkerOurs + "\n")
+		writeLines(out, ours)
+		terminateLine(out)
+		out.WriteString(MarkerBase + "\n")
+		writeLines(out, theirs)
+		terminateLine(out)
+		out.WriteString(MarkerTheirs + "\n")
+		return 1
+	}
+	return 0
+}
+
+// matchLines returns, for every line of a, the index of the line of b it is
+// matched with in a longest common subsequence, or -1
+func matchLines(a, b []string) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+
+	// Common prefix and suffix need no table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		match[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		match[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(midA), len(midB)
+	if n == 0 || m == 0 || n*m > maxMergeCells {
+		return match
+	}
+
+	// lcs[i*(m+1)+j] is the LCS length of midA[i:] and midB[j:]
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else if down, right := lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1]; down >= right {
+				lcs[i*(m+1)+j] = down
+			} else {
+				lcs[i*(m+1)+j] = right
+			}
+		}
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case midA[i] == midB[j]:
+			match[prefix+i] = prefix + j
+			i++
+			j++
+		case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return match
+}
+
+// splitLines splits text into lines that keep their line endings
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// terminateLine ends the output with a newline so a marker starts its own line
+func terminateLine(out *strings.Builder) {
+	if s := out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		out.WriteString("\n")
+	}
+}
@@ -0,0 +1,41 @@
+package patchutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge3(t *testing.T) {
+	base := "package main\n\nfunc a() {}\n\nfunc b() {}\n\nfunc c() {}\n"
+
+	t.Run("separate changes merge cleanly", func(t *testing.T) {
+		ours := "package main\n\nfunc a() { println(1) }\n\nfunc b() {}\n\nfunc c() {}\n"
+		theirs := "package main\n\nfunc a() {}\n\nfunc b() {}\n\nfunc c() { println(3) }\n\nfunc d() {}\n"
+		result := Merge3(base, ours, theirs)
+		assert.Zero(t, result.Conflicts)
+		assert.Equal(t, "package main\n\nfunc a() { println(1) }\n\nfunc b() {}\n\nfunc c() { println(3) }\n\nfunc d() {}\n", result.Content)
+	})
+
+	t.Run("same change on both sides", func(t *testing.T) {
+		changed := "package main\n\nfunc a() {}\n\nfunc b() { return }\n\nfunc c() {}\n"
+		result := Merge3(base, changed, changed)
+		assert.Zero(t, result.Conflicts)
+		assert.Equal(t, changed, result.Content)
+	})
+
+	t.Run("overlapping changes conflict", func(t *testing.T) {
+		ours := "package main\n\nfunc a() {}\n\nfunc b() { ours() }\n\nfunc c() {}\n"
+		theirs := "package main\n\nfunc a() {}\n\nfunc b() { theirs() }\n\nfunc c() {}\n"
+		result := Merge3(base, ours, theirs)
+		assert.Equal(t, 1, result.Conflicts)
+		assert.Equal(t, "package main\n\nfunc a() {}\n\n"+
+			MarkerOurs+"\nfunc b() { ours() }\n"+MarkerBase+"\nfunc b() { theirs() }\n"+MarkerTheirs+"\n\nfunc c() {}\n", result.Content)
+	})
+
+	t.Run("missing final newline", func(t *testing.T) {
+		result := Merge3("a\nb\nc", "a\nb\nc\nd", "x\nb\nc")
+		assert.Zero(t, result.Conflicts)
+		assert.Equal(t, "x\nb\nc\nd", result.Content)
+	})
+}
@@ -0,0 +1,390 @@
+// Package policy enforces organizational guardrails on what agent runs may
+// do. Before a tool call runs and before changes are committed, the call or
+// commit is checked against simple rules from the configuration - denied
+// paths, denied shell commands, denied tools, a pattern commit messages must
+// match - and, optionally, against Rego policies evaluated by the OPA command
+// line tool. A refused call or commit gets a structured Denial, which is
+// recorded in the audit log of the workspace.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/audit"
+)
+
+// Actions policies are evaluated on
+const (
+	ActionToolCall = "tool_call" // Before a tool call runs
+	ActionCommit   = "commit"    // Before changes are committed
+)
+
+// DefaultRegoQuery is the Rego query evaluated when none is configured. It
+// should yield the reasons for denying the input, as a set of strings.
+const DefaultRegoQuery = "data.cge.deny"
+
+// regoTimeout bounds an OPA evaluation
+const regoTimeout = 10 * time.Second
+
+// Rule is a simple guardrail. A rule can combine several checks; the first
+// one that fails denies the call or commit.
+type Rule struct {
+	Name          string   // Shown in denials and the audit log
+	Reason        string   // Why the rule exists, added to its denials
+	DenyPaths     []string // Globs of workspace files tool calls must not change nor commits include
+	DenyCommands  []string // Regular expressions of shell commands tool calls must not run
+	DenyTools     []string // Tools that must not be called
+	CommitMessage string   // Regular expression commit messages must match
+}
+
+// Config configures an Engine
+type Config struct {
+	Rules     []Rule
+	Rego      []string // Rego files or directories, evaluated with opa eval when set
+	RegoQuery string   // DefaultRegoQuery when empty
+	OPA       string   // OPA executable; "opa" when empty
+}
+
+// Input describes a tool call or commit to evaluate. It is also the input
+// document of Rego policies.
+type Input struct {
+	Action        string          `json:"action"`
+	Tool          string          `json:"tool,omitempty"`
+	Arguments     json.RawMessage `json:"arguments,omitempty"`
+	Files         []string        `json:"files,omitempty"`   // Files changed or committed, relative to the workspace
+	Command       string          `json:"command,omitempty"` // Shell command the tool call runs
+	CommitMessage string          `json:"commit_message,omitempty"`
+	SessionID     string          `json:"session_id,omitempty"`
+}
+
+// Denial is a policy refusing a tool call or commit
+type Denial struct {
+	Rule    string `json:"rule"`
+	Action  string `json:"action"`
+	Tool    string `json:"tool,omitempty"`
+	File    string `json:"file,omitempty"`
+	Command string `json:"command,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// Error implements error
+func (d *Denial) Error() string {
+	return fmt.Sprintf("denied by policy %s: %s", d.Rule, d.Reason)
+}
+
+// rule is a Rule with its patterns compiled
+type rule struct {
+	Rule
+	paths    *agent.PathGuard
+	commands []*regexp.Regexp
+	commit   *regexp.Regexp
+}
+
+// Engine evaluates the policies of a workspace
+type Engine struct {
+	workspaceRoot string
+	rules         []rule
+	rego          []string
+	query         string
+	opa           string
+	audit         *audit.AuditLogger
+}
+
+// New returns the engine of cfg for the workspace at workspaceRoot, or nil
+// when cfg has no rules nor Rego policies. A nil engine allows everything.
+func New(workspaceRoot string, cfg Config) (*Engine, error) {
+	if len(cfg.Rules) == 0 && len(cfg.Rego) == 0 {
+		return nil, nil
+	}
+	if abs, err := filepath.Abs(workspaceRoot); err == nil {
+		workspaceRoot = abs
+	}
+	e := &Engine{workspaceRoot: workspaceRoot, query: cfg.RegoQuery, opa: cfg.OPA}
+	if e.query == "" {
+		e.query = DefaultRegoQuery
+	}
+	if e.opa == "" {
+		e.opa = "opa"
+	}
+	for _, path := range cfg.Rego {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workspaceRoot, path)
+		}
+		e.rego = append(e.rego, path)
+	}
+
+	for i, r := range cfg.Rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+			r.Name = name
+		}
+		if len(r.DenyPaths) == 0 && len(r.DenyCommands) == 0 && len(r.DenyTools) == 0 && r.CommitMessage == "" {
+			return nil, fmt.Errorf("%s has nothing to check (set deny_paths, deny_commands, deny_tools or commit_message)", name)
+		}
+		compiled := rule{Rule: r, paths: agent.NewPathGuard(workspaceRoot, r.DenyPaths)}
+		for _, pattern := range r.DenyCommands {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid deny_commands pattern %q: %w", name, pattern, err)
+			}
+			compiled.commands = append(compiled.commands, re)
+		}
+		if r.CommitMessage != "" {
+			re, err := regexp.Compile(r.CommitMessage)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid commit_message pattern %q: %w", name, r.CommitMessage, err)
+			}
+			compiled.commit = re
+		}
+		e.rules = append(e.rules, compiled)
+	}
+	return e, nil
+}
+
+// denials counts the denials of every engine of the process
+var denials atomic.Int64
+
+// Denials returns the number of calls and commits denied in this process so
+// far, e.g. to fail a CI run in which the agent tried something forbidden
+func Denials() int {
+	return int(denials.Load())
+}
+
+// Validate checks the rules of cfg
+func Validate(cfg Config) error {
+	_, err := New(".", cfg)
+	return err
+}
+
+// SetAuditLogger records denials with logger. Without it, each denial is
+// appended to the audit log of the workspace on its own.
+func (e *Engine) SetAuditLogger(logger *audit.AuditLogger) {
+	e.audit = logger
+}
+
+// Check evaluates in, returning the denial of the first policy refusing it
+// or nil when it is allowed. Denials are recorded in the audit log. Rego
+// policies that cannot be evaluated deny everything, so a broken policy
+// never lets calls through.
+func (e *Engine) Check(ctx context.Context, in Input) *Denial {
+	if e == nil {
+		return nil
+	}
+	in.Files = e.relative(in.Files)
+	denial := e.checkRules(in)
+	if denial == nil && len(e.rego) > 0 {
+		denial = e.checkRego(ctx, in)
+	}
+	if denial != nil {
+		denial.Action, denial.Tool = in.Action, in.Tool
+		denials.Add(1)
+		e.record(denial, in.SessionID)
+	}
+	return denial
+}
+
+// CheckFile evaluates the deny_paths rules for a change the tool call in
+// makes to file, which is absolute or relative to the workspace. Tools check
+// each file they are about to change this way, since a call's arguments do
+// not always name them. Denials are recorded like those of Check.
+func (e *Engine) CheckFile(ctx context.Context, in Input, file string) *Denial {
+	if e == nil {
+		return nil
+	}
+	in.Files = e.relative([]string{file})
+	for _, r := range e.rules {
+		if denial := r.checkPaths(e.workspaceRoot, in); denial != nil {
+			denial.Action, denial.Tool = in.Action, in.Tool
+			denials.Add(1)
+			e.record(denial, in.SessionID)
+			return denial
+		}
+	}
+	return nil
+}
+
+// deny returns the denial of the rule, with its reason added to detail
+func (r *rule) deny(detail string) *Denial {
+	if r.Reason != "" {
+		detail += ": " + r.Reason
+	}
+	return &Denial{Rule: r.Name, Reason: detail}
+}
+
+// checkPaths evaluates the deny_paths of the rule against the files of in
+func (r *rule) checkPaths(workspaceRoot string, in Input) *Denial {
+	for _, file := range in.Files {
+		if glob, ok := r.paths.Match(filepath.Join(workspaceRoot, filepath.FromSlash(file))); ok {
+			denial := r.deny(fmt.Sprintf("%s matches %s", file, glob))
+			if in.Action == ActionCommit {
+				denial.Reason = "committing " + denial.Reason
+			} else {
+				denial.Reason = "changing " + denial.Reason
+			}
+			denial.File = file
+			return denial
+		}
+	}
+	return nil
+}
+
+// checkRules evaluates the simple rules
+func (e *Engine) checkRules(in Input) *Denial {
+	for i := range e.rules {
+		r := &e.rules[i]
+		deny := r.deny
+		if denial := r.checkPaths(e.workspaceRoot, in); denial != nil {
+			return denial
+		}
+
+		switch in.Action {
+		case ActionToolCall:
+			for _, tool := range r.DenyTools {
+				if tool == in.Tool {
+					return deny(fmt.Sprintf("the %s tool is not allowed", tool))
+				}
+			}
+			if in.Command == "" {
+				continue
+			}
+			for _, re := range r.commands {
+				if re.MatchString(in.Command) {
+					denial := deny(fmt.Sprintf("the command matches %s", re))
+					denial.Command = in.Command
+					return denial
+				}
+			}
+		case ActionCommit:
+			if r.commit != nil && !r.commit.MatchString(in.CommitMessage) {
+				return deny(fmt.Sprintf("the commit message must match %s", r.commit))
+			}
+		}
+	}
+	return nil
+}
+
+// checkRego evaluates the Rego policies with opa eval
+func (e *Engine) checkRego(ctx context.Context, in Input) *Denial {
+	input, err := json.Marshal(in)
+	if err != nil {
+		return &Denial{Rule: e.query, Reason: fmt.Sprintf("policy input could not be encoded: %v", err)}
+	}
+	ctx, cancel := context.WithTimeout(ctx, regoTimeout)
+	defer cancel()
+
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, path := range e.rego {
+		args = append(args, "--data", path)
+	}
+	cmd := exec.CommandContext(ctx, e.opa, append(args, e.query)...)
+	cmd.Dir = e.workspaceRoot
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if detail == "" {
+			detail = err.Error()
+		}
+		return &Denial{Rule: e.query, Reason: "policy evaluation failed: " + detail}
+	}
+
+	var result struct {
+		Result []struct {
+			Expressions []struct {
+				Value interface{} `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return &Denial{Rule: e.query, Reason: fmt.Sprintf("policy evaluation failed: unexpected opa output: %v", err)}
+	}
+	var reasons []string
+	for _, r := range result.Result {
+		for _, expression := range r.Expressions {
+			reasons = append(reasons, denyReasons(expression.Value)...)
+		}
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	return &Denial{Rule: e.query, Reason: strings.Join(reasons, "; ")}
+}
+
+// denyReasons interprets the value of the Rego query: true, a reason, or a
+// set of reasons or of objects with a msg
+func denyReasons(value interface{}) []string {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return []string{"denied by the Rego policy"}
+		}
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		var reasons []string
+		for _, item := range v {
+			reasons = append(reasons, denyReasons(item)...)
+		}
+		return reasons
+	case map[string]interface{}:
+		for _, key := range []string{"msg", "reason", "message"} {
+			if msg, ok := v[key].(string); ok && msg != "" {
+				return []string{msg}
+			}
+		}
+		if len(v) > 0 {
+			data, _ := json.Marshal(v)
+			return []string{string(data)}
+		}
+	}
+	return nil
+}
+
+// relative returns files relative to the workspace, with forward slashes
+func (e *Engine) relative(files []string) []string {
+	if len(files) == 0 {
+		return files
+	}
+	relative := make([]string, len(files))
+	for i, file := range files {
+		if filepath.IsAbs(file) {
+			if rel, err := filepath.Rel(e.workspaceRoot, file); err == nil {
+				file = rel
+			}
+		}
+		relative[i] = filepath.ToSlash(filepath.Clean(file))
+	}
+	return relative
+}
+
+// record writes a denial to the audit log
+func (e *Engine) record(denial *Denial, sessionID string) {
+	logger := e.audit
+	if logger == nil {
+		var err error
+		if logger, err = audit.NewAuditLogger(e.workspaceRoot, sessionID); err != nil {
+			return
+		}
+		defer logger.Close()
+	}
+	var metadata map[string]interface{}
+	if denial.Command != "" {
+		metadata = map[string]interface{}{"command": denial.Command}
+	}
+	logger.LogPolicyDenial(denial.Rule, denial.Action, denial.Tool, denial.File, denial.Reason, metadata)
+}
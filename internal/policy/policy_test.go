@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	engine, err := New(t.TempDir(), Config{})
+	require.NoError(t, err)
+	assert.Nil(t, engine, "no policies, no engine")
+	assert.Nil(t, engine.Check(context.Background(), Input{Action: ActionToolCall, Tool: "run_shell_command", Command: "rm -rf /"}))
+
+	assert.ErrorContains(t, Validate(Config{Rules: []Rule{{Name: "empty"}}}), "empty has nothing to check")
+	assert.ErrorContains(t, Validate(Config{Rules: []Rule{{DenyCommands: []string{"rm ("}}}}), `rule 1: invalid deny_commands pattern "rm ("`)
+	assert.ErrorContains(t, Validate(Config{Rules: []Rule{{CommitMessage: "[A-Z+"}}}), "invalid commit_message pattern")
+}
+
+func TestCheckRules(t *testing.T) {
+	workspace := t.TempDir()
+	engine, err := New(workspace, Config{Rules: []Rule{
+		{Name: "prod-configs", Reason: "production configuration changes go through the release process", DenyPaths: []string{"config/prod/**", "**/*.prod.yaml"}},
+		{Name: "no-rm-rf", DenyCommands: []string{`\brm\s+-[a-zA-Z]*(rf|fr)`}, DenyTools: []string{"git_push"}},
+		{Name: "ticket", CommitMessage: `\b[A-Z]+-[0-9]+\b`},
+	}})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	denial := engine.Check(ctx, Input{Action: ActionToolCall, Tool: "write_file", Files: []string{"config/prod/db.yaml"}})
+	require.NotNil(t, denial)
+	assert.Equal(t, &Denial{
+		Rule:   "prod-configs",
+		Action: ActionToolCall,
+		Tool:   "write_file",
+		File:   "config/prod/db.yaml",
+		Reason: "changing config/prod/db.yaml matches config/prod/**: production configuration changes go through the release process",
+	}, denial)
+	assert.NotNil(t, engine.Check(ctx, Input{Action: ActionToolCall, Tool: "write_file", Files: []string{filepath.Join(workspace, "deploy", "app.prod.yaml")}}), "absolute paths are matched too")
+	assert.Nil(t, engine.Check(ctx, Input{Action: ActionToolCall, Tool: "write_file", Files: []string{"config/dev/db.yaml"}}))
+
+	denial = engine.Check(ctx, Input{Action: ActionToolCall, Tool: "run_shell_command", Command: "cd build && rm -rf ."})
+	require.NotNil(t, denial)
+	assert.Equal(t, "no-rm-rf", denial.Rule)
+	assert.Equal(t, "cd build && rm -rf .", denial.Command)
+	assert.Nil(t, engine.Check(ctx, Input{Action: ActionToolCall, Tool: "run_shell_command", Command: "rm build/out.txt"}))
+	assert.EqualError(t, engine.Check(ctx, Input{Action: ActionToolCall, Tool: "git_push"}), "denied by policy no-rm-rf: the git_push tool is not allowed")
+
+	denial = engine.Check(ctx, Input{Action: ActionCommit, CommitMessage: "Fix the flaky checkout test"})
+	require.NotNil(t, denial)
+	assert.Equal(t, `the commit message must match \b[A-Z]+-[0-9]+\b`, denial.Reason)
+	assert.Nil(t, engine.Check(ctx, Input{Action: ActionCommit, CommitMessage: "SHOP-142: Fix the flaky checkout test"}))
+	denial = engine.Check(ctx, Input{Action: ActionCommit, CommitMessage: "SHOP-142: Tune pools", Files: []string{"config/prod/db.yaml"}})
+	require.NotNil(t, denial)
+	assert.Contains(t, denial.Reason, "committing config/prod/db.yaml")
+}
+
+func TestCheckRecordsDenials(t *testing.T) {
+	workspace := t.TempDir()
+	engine, err := New(workspace, Config{Rules: []Rule{{Name: "no-shell", DenyTools: []string{"run_shell_command"}}}})
+	require.NoError(t, err)
+	before := Denials()
+	require.NotNil(t, engine.Check(context.Background(), Input{Action: ActionToolCall, Tool: "run_shell_command", Command: "make deploy", SessionID: "5f0c9a2e-session"}))
+	assert.Equal(t, before+1, Denials())
+
+	logger, err := audit.NewAuditLogger(workspace, "reader")
+	require.NoError(t, err)
+	defer logger.Close()
+	events, err := logger.QueryEvents(func(event audit.AuditEvent) bool { return event.EventType == audit.EventPolicyDenial })
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "5f0c9a2e-session", events[0].SessionID)
+	assert.Equal(t, "run_shell_command", events[0].ToolName)
+	assert.Equal(t, "no-shell", events[0].Metadata["rule"])
+	assert.Equal(t, ActionToolCall, events[0].Metadata["action"])
+	assert.Equal(t, "the run_shell_command tool is not allowed", events[0].Error)
+}
+
+func TestCheckRego(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake opa is a POSIX shell script")
+	}
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "cge.rego"), []byte("package cge\n"), 0644))
+	// The fake opa denies writes to migrations and records its arguments
+	opa := filepath.Join(workspace, "opa")
+	require.NoError(t, os.WriteFile(opa, []byte(`#!/bin/sh
+echo "$@" > args.txt
+if grep -q migrations; then
+  echo '{"result":[{"expressions":[{"value":["migrations are reviewed by the DBA team"]}]}]}'
+else
+  echo '{"result":[{"expressions":[{"value":[]}]}]}'
+fi
+`), 0755))
+
+	engine, err := New(workspace, Config{Rego: []string{"cge.rego"}, OPA: opa})
+	require.NoError(t, err)
+	ctx := context.Background()
+	assert.Nil(t, engine.Check(ctx, Input{Action: ActionToolCall, Tool: "write_file", Files: []string{"main.go"}}))
+	args, err := os.ReadFile(filepath.Join(workspace, "args.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "eval --format json --stdin-input --data "+filepath.Join(workspace, "cge.rego")+" "+DefaultRegoQuery+"\n", string(args))
+
+	denial := engine.Check(ctx, Input{Action: ActionToolCall, Tool: "write_file", Files: []string{"db/migrations/004.sql"}})
+	require.NotNil(t, denial)
+	assert.Equal(t, DefaultRegoQuery, denial.Rule)
+	assert.Equal(t, "migrations are reviewed by the DBA team", denial.Reason)
+
+	engine, err = New(workspace, Config{Rego: []string{"cge.rego"}, OPA: filepath.Join(workspace, "missing-opa")})
+	require.NoError(t, err)
+	denial = engine.Check(ctx, Input{Action: ActionToolCall, Tool: "read_file"})
+	require.NotNil(t, denial, "policies that cannot be evaluated deny")
+	assert.Contains(t, denial.Reason, "policy evaluation failed")
+}
+
+func TestDenyReasons(t *testing.T) {
+	assert.Equal(t, []string{"denied by the Rego policy"}, denyReasons(true))
+	assert.Nil(t, denyReasons(false))
+	assert.Equal(t, []string{"no deploys", "no pushes"}, denyReasons([]interface{}{"no deploys", map[string]interface{}{"msg": "no pushes"}}))
+	assert.Nil(t, denyReasons(nil))
+}
@@ -0,0 +1,220 @@
+// Package redact strips code and file contents from sessions and chat
+// histories so they can be shared, for instance attached to a bug report,
+// while keeping their structure. What is kept depends on the level:
+//
+//   - code: prose is kept and secrets in it are masked; fenced code blocks,
+//     file contents and multi-line values are replaced by placeholders
+//     giving their size
+//   - metadata: only the structure is kept (roles, tool names, file paths,
+//     numbers and flags); all text is replaced by placeholders
+//   - none: nothing is removed
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Redaction levels
+const (
+	LevelNone     = "none"     // Nothing is removed
+	LevelCode     = "code"     // Code and file contents are removed, prose is kept
+	LevelMetadata = "metadata" // Only the structure is kept
+)
+
+// maxInlineLen is the longest single-line value kept at LevelCode; longer
+// values are more likely data than a name or a short message
+const maxInlineLen = 200
+
+// contentKeys name the arguments and results holding code or file contents;
+// their values are removed at every level but LevelNone
+var contentKeys = map[string]bool{
+	"content": true, "contents": true, "new_content": true, "old_content": true, "file_content": true,
+	"code": true, "snippet": true, "source": true, "body": true, "text": true, "lines": true,
+	"patch": true, "diff": true, "search": true, "replace": true, "old_string": true, "new_string": true,
+	"original": true, "modified": true, "output": true, "stdout": true, "stderr": true,
+}
+
+// pathKeys name the arguments and results holding file paths, which are kept
+// at every level: they are what a maintainer needs to follow a session
+var pathKeys = map[string]bool{
+	"path": true, "paths": true, "file": true, "files": true, "file_path": true, "filepath": true,
+	"target_file": true, "target_path": true, "source_path": true, "destination_path": true,
+	"directory": true, "dir": true, "files_to_stage": true,
+}
+
+// secretPatterns match credentials; the first group of a pattern, when it
+// has one, is kept
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{16,}|gh[pousr]_[A-Za-z0-9]{20,}|xox[abprs]-[A-Za-z0-9-]{10,}|AKIA[0-9A-Z]{16})\b`),
+	regexp.MustCompile(`(?i)\b((?:api[_-]?key|access[_-]?token|auth[_-]?token|token|secret|password|passwd)["']?\s*[:=]\s*["']?)[^\s"',]+`),
+}
+
+// Validate checks that level is a redaction level
+func Validate(level string) error {
+	switch level {
+	case LevelNone, LevelCode, LevelMetadata:
+		return nil
+	}
+	return fmt.Errorf("unknown redaction level %q (want %s, %s or %s)", level, LevelNone, LevelCode, LevelMetadata)
+}
+
+// Text redacts prose, such as a message of a conversation
+func Text(s, level string) string {
+	switch {
+	case level == LevelNone || s == "":
+		return s
+	case level == LevelMetadata:
+		return placeholder(s)
+	}
+	return Secrets(stripFences(s))
+}
+
+// Content redacts the content of a message, which tools fill with JSON
+func Content(s, level string) string {
+	trimmed := strings.TrimSpace(s)
+	if level != LevelNone && (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return string(JSON(json.RawMessage(trimmed), level))
+	}
+	return Text(s, level)
+}
+
+// JSON redacts a JSON document, such as the arguments of a tool call,
+// keeping its keys
+func JSON(raw json.RawMessage, level string) json.RawMessage {
+	if level == LevelNone || len(raw) == 0 {
+		return raw
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		data, _ := json.Marshal(placeholder(string(raw)))
+		return data
+	}
+	data, err := json.Marshal(value("", v, level))
+	if err != nil {
+		data, _ = json.Marshal(placeholder(string(raw)))
+	}
+	return data
+}
+
+// Value redacts a value decoded from JSON, or any value that encodes to
+// JSON, such as the data of a tool result
+func Value(v interface{}, level string) interface{} {
+	if level == LevelNone || v == nil {
+		return v
+	}
+	switch v.(type) {
+	case map[string]interface{}, []interface{}, string:
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return placeholder(fmt.Sprint(v))
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return placeholder(string(data))
+		}
+		v = generic
+	}
+	return value("", v, level)
+}
+
+// value redacts v, found under key
+func value(key string, v interface{}, level string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			redacted[k] = value(k, item, level)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = value(key, item, level) // Items share the key of their list
+		}
+		return redacted
+	case string:
+		return field(strings.ToLower(key), v, level)
+	}
+	return v
+}
+
+// field redacts the string value of key
+func field(key, s, level string) string {
+	multiline := strings.Contains(s, "\n")
+	switch {
+	case s == "":
+		return s
+	case pathKeys[key] && !multiline:
+		return s
+	case level == LevelMetadata, contentKeys[key], multiline, len(s) > maxInlineLen:
+		return placeholder(s)
+	}
+	return Secrets(s)
+}
+
+// Secrets masks API keys, tokens and passwords in s
+func Secrets(s string) string {
+	for _, pattern := range secretPatterns {
+		if pattern.NumSubexp() > 0 {
+			s = pattern.ReplaceAllString(s, "${1}[secret]")
+		} else {
+			s = pattern.ReplaceAllString(s, "[secret]")
+		}
+	}
+	return s
+}
+
+// stripFences replaces the body of the fenced code blocks of s by
+// placeholders, keeping the fences and their language
+func stripFences(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	var b strings.Builder
+	var fence string // The marker of the open block, if any
+	var body strings.Builder
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			fence = trimmed[:3]
+			b.WriteString(line)
+		case fence != "" && strings.HasPrefix(trimmed, fence):
+			writeBody(&b, body.String())
+			body.Reset()
+			fence = ""
+			b.WriteString(line)
+		case fence != "":
+			body.WriteString(line)
+		default:
+			b.WriteString(line)
+		}
+	}
+	if fence != "" {
+		// An unterminated block runs to the end of the text
+		writeBody(&b, body.String())
+	}
+	return b.String()
+}
+
+// writeBody writes the placeholder of the body of a code block
+func writeBody(b *strings.Builder, body string) {
+	if body == "" {
+		return
+	}
+	b.WriteString(placeholder(body))
+	b.WriteString("\n")
+}
+
+// placeholder stands for the removed value s
+func placeholder(s string) string {
+	if lines := strings.Count(strings.TrimSuffix(s, "\n"), "\n") + 1; lines > 1 {
+		return fmt.Sprintf("[redacted: %d lines]", lines)
+	}
+	return fmt.Sprintf("[redacted: %d bytes]", len(s))
+}
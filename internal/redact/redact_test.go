@@ -0,0 +1,61 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	for _, level := range []string{LevelNone, LevelCode, LevelMetadata} {
+		assert.NoError(t, Validate(level))
+	}
+	assert.ErrorContains(t, Validate("all"), `unknown redaction level "all"`)
+}
+
+func TestText(t *testing.T) {
+	message := "The parser fails on empty input:\n\n```go\nfunc parse(s string) {\n\treturn s[0]\n}\n```\n\nMy key is sk-abcdefghijklmnop1234 and password = hunter2"
+
+	assert.Equal(t, message, Text(message, LevelNone))
+	assert.Equal(t, "The parser fails on empty input:\n\n```go\n[redacted: 3 lines]\n```\n\nMy key is [secret] and password = [secret]", Text(message, LevelCode))
+	assert.Equal(t, "[redacted: 9 lines]", Text(message, LevelMetadata))
+	assert.Equal(t, "Unterminated:\n~~~\n[redacted: 2 lines]\n", Text("Unterminated:\n~~~\nline 1\nline 2", LevelCode))
+	assert.Empty(t, Text("", LevelMetadata))
+}
+
+func TestJSON(t *testing.T) {
+	args := json.RawMessage(`{"file_path": "internal/db/pool.go", "content": "package db\n\nconst size = 4\n", "create_dirs": true, "mode": 420, "comment": "token: abc123", "files": ["a.go", "b.go"]}`)
+
+	var code map[string]interface{}
+	require.NoError(t, json.Unmarshal(JSON(args, LevelCode), &code))
+	assert.Equal(t, map[string]interface{}{
+		"file_path":   "internal/db/pool.go",
+		"content":     "[redacted: 3 lines]",
+		"create_dirs": true,
+		"mode":        float64(420),
+		"comment":     "token: [secret]",
+		"files":       []interface{}{"a.go", "b.go"},
+	}, code)
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(JSON(args, LevelMetadata), &metadata))
+	assert.Equal(t, "internal/db/pool.go", metadata["file_path"], "paths are kept at every level")
+	assert.Equal(t, "[redacted: 13 bytes]", metadata["comment"])
+
+	assert.Equal(t, `"[redacted: 9 bytes]"`, string(JSON(json.RawMessage(`{invalid}`), LevelCode)))
+	assert.Equal(t, string(args), string(JSON(args, LevelNone)))
+}
+
+func TestContentAndValue(t *testing.T) {
+	assert.Equal(t, `{"content":"[redacted: 2 lines]","success":true}`, Content(`{"success": true, "content": "a\nb"}`, LevelCode))
+	assert.Equal(t, "Done, see [secret]", Content("Done, see ghp_abcdefghijklmnopqrstuvwx", LevelCode))
+
+	type result struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	assert.Equal(t, map[string]interface{}{"path": "main.go", "content": "[redacted: 12 bytes]"}, Value(result{Path: "main.go", Content: "package main"}, LevelCode))
+	assert.Equal(t, 42, Value(42, LevelNone))
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 // SafeFileOps provides secure file operations that prevent path traversal attacks
@@ -43,14 +42,7 @@ func (sfo *SafeFileOps) ValidatePath(path string) error {
 
 	// Check if the path is within any of the allowed roots
 	for _, root := range sfo.allowedRoots {
-		// Check if the absolute path is within this root
-		relPath, err := filepath.Rel(root, absPath)
-		if err != nil {
-			continue // Try next root
-		}
-
-		// If the relative path doesn't start with "..", it's within the root
-		if !strings.HasPrefix(relPath, "..") && !strings.HasPrefix(relPath, "/") {
+		if IsWithin(root, absPath) {
 			return nil // Path is safe
 		}
 	}
@@ -0,0 +1,46 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsWithin reports whether path is root or a path inside it. Both are
+// cleaned first. On Windows the comparison ignores case, and paths on another
+// drive are never within root.
+func IsWithin(root, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// IsDriveRelative reports whether path names a drive without being absolute,
+// such as C:file on Windows. Such paths depend on the current directory of
+// that drive, so they cannot be resolved against a workspace.
+func IsDriveRelative(path string) bool {
+	return filepath.VolumeName(path) != "" && !filepath.IsAbs(path)
+}
+
+// ExpandHome replaces a leading ~ with the home directory, followed by either
+// separator on Windows. Other paths, including ~user forms, are returned as
+// is.
+func ExpandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") || (len(path) > 1 && !os.IsPathSeparator(path[1])) {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// NormalizePath converts a path written by a user or a model to the local
+// form: forward slashes become the OS separator, so Windows paths may be
+// written either way, and the result is cleaned.
+func NormalizePath(path string) string {
+	return filepath.Clean(filepath.FromSlash(strings.TrimSpace(path)))
+}
@@ -0,0 +1,93 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestIsWithin(t *testing.T) {
+	root := filepath.Join(os.TempDir(), "workspace")
+
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"root itself", root, true},
+		{"file inside", filepath.Join(root, "cmd", "main.go"), true},
+		{"unclean path inside", root + string(filepath.Separator) + "a" + string(filepath.Separator) + "..", true},
+		{"parent", filepath.Dir(root), false},
+		{"sibling sharing a prefix", root + "-other", false},
+		{"escape with dots", filepath.Join(root, "..", "etc"), false},
+		{"dotted name inside", filepath.Join(root, "..cache"), true},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests,
+			struct {
+				name string
+				path string
+				want bool
+			}{"different case", filepath.Join(filepath.Dir(root), "WORKSPACE", "a.go"), true},
+			struct {
+				name string
+				path string
+				want bool
+			}{"other drive", `Z:\workspace\a.go`, false},
+		)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsWithin(root, tt.path); got != tt.want {
+				t.Errorf("IsWithin(%q, %q) = %v, want %v", root, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~", home},
+		{"~/src", filepath.Join(home, "src")},
+		{"~user/src", "~user/src"},
+		{"src/~", "src/~"},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, struct {
+			path string
+			want string
+		}{`~\src`, filepath.Join(home, "src")})
+	}
+	for _, tt := range tests {
+		got, err := ExpandHome(tt.path)
+		if err != nil {
+			t.Fatalf("ExpandHome(%q) failed: %v", tt.path, err)
+		}
+		if got != tt.want {
+			t.Errorf("ExpandHome(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	want := filepath.Join("internal", "agent", "tools.go")
+	if got := NormalizePath(" internal/agent/./tools.go "); got != want {
+		t.Errorf("NormalizePath() = %q, want %q", got, want)
+	}
+	if runtime.GOOS == "windows" && !IsDriveRelative("C:tools.go") {
+		t.Error("C:tools.go should be drive-relative")
+	}
+	if IsDriveRelative("tools.go") {
+		t.Error("tools.go should not be drive-relative")
+	}
+}
@@ -0,0 +1,467 @@
+// Package snapshot captures the files of a workspace, tracked and untracked,
+// and restores them exactly, so evaluation and generate runs can be repeated
+// from the same starting point. A snapshot is a manifest of the path, hash
+// and mode of every file; contents are kept once per hash in an object store
+// in the state directory, shared by all snapshots of the workspace.
+package snapshot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/analyzer"
+	"github.com/castrovroberto/CGE/internal/statedir"
+)
+
+// objectsDir is the directory of the snapshot directory holding file contents
+const objectsDir = "objects"
+
+// errNotFile is returned for paths listed in a workspace that are not files,
+// such as submodules
+var errNotFile = errors.New("not a file")
+
+// ErrHeadMoved is returned by Restore when another commit is checked out than
+// when the snapshot was taken
+var ErrHeadMoved = errors.New("the checked out commit changed since the snapshot was taken")
+
+// File is a file of a snapshot
+type File struct {
+	Path string      `json:"path"` // Relative to the workspace root, with slashes
+	Hash string      `json:"hash"` // SHA-256 of the content, or of the target of a symlink
+	Mode fs.FileMode `json:"mode"`
+	Size int64       `json:"size"`
+	Link string      `json:"link,omitempty"` // Target of a symlink
+}
+
+// Snapshot is the state of the files of a workspace at one time
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Head      string    `json:"head,omitempty"` // Git commit checked out, when the workspace is a repository
+	Files     []File    `json:"files"`
+}
+
+// Size returns the total size of the files of the snapshot
+func (s *Snapshot) Size() int64 {
+	var size int64
+	for _, file := range s.Files {
+		size += file.Size
+	}
+	return size
+}
+
+// Change is a difference between a snapshot and the workspace
+type Change struct {
+	Path string
+	Kind string // ChangeModified, ChangeAdded or ChangeDeleted, from the snapshot to the workspace
+}
+
+// Kinds of Change
+const (
+	ChangeModified = "modified"
+	ChangeAdded    = "added"
+	ChangeDeleted  = "deleted"
+)
+
+// Dir returns the directory the snapshots of a workspace are kept in
+func Dir(workspaceRoot string) string {
+	return statedir.Path(workspaceRoot, statedir.Snapshots)
+}
+
+// Capture takes a snapshot of the files of a workspace, saving their contents
+// and the manifest. In a git repository these are the tracked files and the
+// untracked ones that are not ignored; otherwise every file outside the
+// directories analysis skips, such as node_modules. The state directory is
+// never part of a snapshot.
+func Capture(ctx context.Context, workspaceRoot, name string) (*Snapshot, error) {
+	if name != "" {
+		if existing, err := Load(workspaceRoot, name); err == nil {
+			return nil, fmt.Errorf("a snapshot named %q already exists (%s)", name, existing.ID)
+		}
+	}
+	paths, err := workspaceFiles(ctx, workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := Dir(workspaceRoot)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	snapshot := &Snapshot{ID: newID(), Name: name, CreatedAt: time.Now(), Head: gitHead(ctx, workspaceRoot)}
+	for _, path := range paths {
+		file, content, err := readFile(workspaceRoot, path)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, errNotFile) {
+			continue // Deleted but still tracked, or a submodule
+		}
+		if err != nil {
+			return nil, err
+		}
+		if file.Link == "" {
+			if err := saveObject(dir, file.Hash, content); err != nil {
+				return nil, err
+			}
+		}
+		snapshot.Files = append(snapshot.Files, file)
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, snapshot.ID+".json"), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// Load returns the snapshot of a workspace with an ID or name
+func Load(workspaceRoot, ref string) (*Snapshot, error) {
+	snapshots, err := List(workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ID == ref || snapshot.Name == ref {
+			return snapshot, nil
+		}
+	}
+	return nil, fmt.Errorf("no snapshot %q; see 'CGE snapshot list'", ref)
+}
+
+// List returns the snapshots of a workspace, newest first
+func List(workspaceRoot string) ([]*Snapshot, error) {
+	dir := Dir(workspaceRoot)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var snapshots []*Snapshot
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name())) // #nosec G304 - a file of the snapshot directory
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			continue // Skip manifests we can't parse
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Delete removes a snapshot of a workspace, and the contents no other
+// snapshot needs
+func Delete(workspaceRoot, ref string) error {
+	snapshot, err := Load(workspaceRoot, ref)
+	if err != nil {
+		return err
+	}
+	dir := Dir(workspaceRoot)
+	if err := os.Remove(filepath.Join(dir, snapshot.ID+".json")); err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+
+	remaining, err := List(workspaceRoot)
+	if err != nil {
+		return err
+	}
+	used := make(map[string]bool)
+	for _, other := range remaining {
+		for _, file := range other.Files {
+			used[file.Hash] = true
+		}
+	}
+	for _, file := range snapshot.Files {
+		if !used[file.Hash] && file.Link == "" {
+			_ = os.Remove(objectPath(dir, file.Hash))
+			used[file.Hash] = true // Shared by files of the snapshot
+		}
+	}
+	return nil
+}
+
+// Diff returns how the files of a workspace differ from the snapshot, in
+// path order
+func (s *Snapshot) Diff(ctx context.Context, workspaceRoot string) ([]Change, error) {
+	paths, err := workspaceFiles(ctx, workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		current[path] = true
+	}
+
+	var changes []Change
+	for _, file := range s.Files {
+		delete(current, file.Path)
+		now, _, err := readFile(workspaceRoot, file.Path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist), errors.Is(err, errNotFile):
+			changes = append(changes, Change{Path: file.Path, Kind: ChangeDeleted})
+		case err != nil:
+			return nil, err
+		case now.Hash != file.Hash || now.Link != file.Link || now.Mode != file.Mode:
+			changes = append(changes, Change{Path: file.Path, Kind: ChangeModified})
+		}
+	}
+	for path := range current {
+		if info, err := os.Lstat(filepath.Join(workspaceRoot, filepath.FromSlash(path))); err == nil && !info.IsDir() {
+			changes = append(changes, Change{Path: path, Kind: ChangeAdded})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// HeadMoved reports whether another commit is checked out in the workspace
+// than when the snapshot was taken, and returns the current one
+func (s *Snapshot) HeadMoved(ctx context.Context, workspaceRoot string) (string, bool) {
+	if s.Head == "" {
+		return "", false
+	}
+	head := gitHead(ctx, workspaceRoot)
+	return head, head != s.Head
+}
+
+// Restore returns the files of a workspace to the snapshot: modified and
+// deleted files get their content and mode back, and files added since are
+// removed. Ignored files are left alone. It returns the changes it undid.
+// Restoring files over another commit than the snapshot was taken at would
+// mix the two, so it fails with ErrHeadMoved unless force is set.
+func (s *Snapshot) Restore(ctx context.Context, workspaceRoot string, force bool) ([]Change, error) {
+	if head, moved := s.HeadMoved(ctx, workspaceRoot); moved && !force {
+		return nil, fmt.Errorf("%w: %s is checked out, the snapshot was taken at %s", ErrHeadMoved, ShortCommit(head), ShortCommit(s.Head))
+	}
+	changes, err := s.Diff(ctx, workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]File, len(s.Files))
+	for _, file := range s.Files {
+		files[file.Path] = file
+	}
+
+	dir := Dir(workspaceRoot)
+	for _, change := range changes {
+		path := filepath.Join(workspaceRoot, filepath.FromSlash(change.Path))
+		if change.Kind == ChangeAdded {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("failed to remove %s: %w", change.Path, err)
+			}
+			removeEmptyParents(workspaceRoot, filepath.Dir(path))
+			continue
+		}
+		if err := restoreFile(dir, path, files[change.Path]); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", change.Path, err)
+		}
+	}
+	return changes, nil
+}
+
+// restoreFile writes a file of a snapshot back to path
+func restoreFile(dir, path string, file File) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(path); err == nil && (file.Link != "" || info.Mode()&fs.ModeSymlink != 0) {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	if file.Link != "" {
+		return os.Symlink(file.Link, path)
+	}
+
+	content, err := os.ReadFile(objectPath(dir, file.Hash))
+	if err != nil {
+		return fmt.Errorf("content missing from the snapshot store: %w", err)
+	}
+	if err := os.WriteFile(path, content, file.Mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chmod(path, file.Mode.Perm()) // WriteFile keeps the mode of an existing file
+}
+
+// readFile returns the snapshot entry of a file of the workspace and its
+// content
+func readFile(workspaceRoot, path string) (File, []byte, error) {
+	abs := filepath.Join(workspaceRoot, filepath.FromSlash(path))
+	info, err := os.Lstat(abs)
+	if err != nil {
+		return File{}, nil, err
+	}
+	if info.IsDir() {
+		return File{}, nil, errNotFile
+	}
+	file := File{Path: path, Mode: info.Mode()}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if file.Link, err = os.Readlink(abs); err != nil {
+			return File{}, nil, err
+		}
+		file.Hash = hash([]byte(file.Link))
+		return file, nil, nil
+	}
+	content, err := os.ReadFile(abs) // #nosec G304 - a file listed in the workspace
+	if err != nil {
+		return File{}, nil, err
+	}
+	file.Hash = hash(content)
+	file.Size = int64(len(content))
+	return file, content, nil
+}
+
+// workspaceFiles returns the paths of the files of a workspace a snapshot
+// covers, relative to its root with slashes, in order
+func workspaceFiles(ctx context.Context, workspaceRoot string) ([]string, error) {
+	var paths []string
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = workspaceRoot
+	if output, err := cmd.Output(); err == nil {
+		for _, path := range strings.Split(string(output), "\x00") {
+			if path != "" {
+				paths = append(paths, path)
+			}
+		}
+	} else {
+		err := filepath.WalkDir(workspaceRoot, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != workspaceRoot && analyzer.IsSkippableDir(d.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(workspaceRoot, path)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list workspace files: %w", err)
+		}
+	}
+
+	kept := paths[:0]
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if seen[path] || path == statedir.DirName || strings.HasPrefix(path, statedir.DirName+"/") {
+			continue // Listed twice when tracked and in conflict
+		}
+		seen[path] = true
+		kept = append(kept, path)
+	}
+	sort.Strings(kept)
+	return kept, nil
+}
+
+// gitHead returns the commit checked out in the workspace, or "" outside a
+// repository
+func gitHead(ctx context.Context, workspaceRoot string) string {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "HEAD")
+	cmd.Dir = workspaceRoot
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// ShortCommit abbreviates a commit hash for messages
+func ShortCommit(head string) string {
+	if head == "" {
+		return "no commit"
+	}
+	if len(head) > 12 {
+		return head[:12]
+	}
+	return head
+}
+
+// saveObject stores content under its hash unless it is already stored
+func saveObject(dir, sum string, content []byte) error {
+	path := objectPath(dir, sum)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create snapshot store: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return fmt.Errorf("failed to store file content: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// objectPath returns where the content with a hash is stored
+func objectPath(dir, sum string) string {
+	return filepath.Join(dir, objectsDir, sum[:2], sum)
+}
+
+// removeEmptyParents removes dir and its parents below the workspace root
+// while they are empty
+func removeEmptyParents(workspaceRoot, dir string) {
+	root, err := filepath.Abs(workspaceRoot)
+	if err != nil {
+		return
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return
+	}
+	for {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			return
+		}
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// newID returns the ID of a new snapshot: its time and a random suffix
+func newID() string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return "snap-" + time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
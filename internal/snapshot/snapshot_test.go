@@ -0,0 +1,196 @@
+package snapshot
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes files, relative paths to contents, in dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+// initRepo creates a repository with committed files, an ignored file and an
+// untracked one
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".gitignore":     "*.log\n",
+		"main.go":        "package main\n",
+		"pkg/util.go":    "package pkg\n",
+		"pkg/util_2.go":  "package pkg\n", // Same content as util.go
+		"build.log":      "ignored\n",
+		"notes/todo.txt": "untracked\n",
+	})
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", ".gitignore", "main.go", "pkg"},
+		{"commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return dir
+}
+
+func TestCaptureAndRestore(t *testing.T) {
+	ctx := context.Background()
+	workspace := initRepo(t)
+
+	snapshot, err := Capture(ctx, workspace, "baseline")
+	require.NoError(t, err)
+	var paths []string
+	for _, file := range snapshot.Files {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{".gitignore", "main.go", "notes/todo.txt", "pkg/util.go", "pkg/util_2.go"}, paths, "ignored files and the state directory are left out")
+	assert.Len(t, snapshot.Head, 40)
+
+	// A run changes, adds and deletes files
+	writeFiles(t, workspace, map[string]string{
+		"main.go":          "package main\n\nfunc main() {}\n",
+		"pkg/new/extra.go": "package new\n",
+		"build.log":        "rebuilt\n",
+	})
+	require.NoError(t, os.Remove(filepath.Join(workspace, "notes", "todo.txt")))
+	require.NoError(t, os.Chmod(filepath.Join(workspace, "pkg", "util.go"), 0755))
+
+	changes, err := snapshot.Diff(ctx, workspace)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Path: "main.go", Kind: ChangeModified},
+		{Path: "notes/todo.txt", Kind: ChangeDeleted},
+		{Path: "pkg/new/extra.go", Kind: ChangeAdded},
+		{Path: "pkg/util.go", Kind: ChangeModified},
+	}, changes)
+
+	restored, err := snapshot.Restore(ctx, workspace, false)
+	require.NoError(t, err)
+	assert.Equal(t, changes, restored)
+	changes, err = snapshot.Diff(ctx, workspace)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	data, err := os.ReadFile(filepath.Join(workspace, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(data))
+	info, err := os.Stat(filepath.Join(workspace, "pkg", "util.go"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	assert.NoDirExists(t, filepath.Join(workspace, "pkg", "new"), "directories emptied by the restore are removed")
+	data, err = os.ReadFile(filepath.Join(workspace, "build.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "rebuilt\n", string(data), "ignored files are left alone")
+}
+
+func TestSnapshotStore(t *testing.T) {
+	ctx := context.Background()
+	workspace := t.TempDir()
+	writeFiles(t, workspace, map[string]string{
+		"a.txt":               "same\n",
+		"b.txt":               "same\n",
+		"node_modules/x/x.js": "skipped\n",
+	})
+
+	first, err := Capture(ctx, workspace, "first")
+	require.NoError(t, err)
+	assert.Len(t, first.Files, 2, "without git, skippable directories are left out")
+	assert.Empty(t, first.Head)
+	_, err = Capture(ctx, workspace, "first")
+	assert.ErrorContains(t, err, "already exists")
+
+	writeFiles(t, workspace, map[string]string{"c.txt": "other\n"})
+	second, err := Capture(ctx, workspace, "")
+	require.NoError(t, err)
+
+	snapshots, err := List(workspace)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	loaded, err := Load(workspace, "first")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, loaded.ID)
+	_, err = Load(workspace, second.ID)
+	require.NoError(t, err)
+
+	objects := func() int {
+		entries, _ := filepath.Glob(filepath.Join(Dir(workspace), objectsDir, "*", "*"))
+		return len(entries)
+	}
+	assert.Equal(t, 2, objects(), "identical contents are stored once")
+	require.NoError(t, Delete(workspace, second.ID))
+	assert.Equal(t, 1, objects(), "contents no snapshot needs are removed")
+
+	require.NoError(t, os.Remove(filepath.Join(workspace, "a.txt")))
+	_, err = first.Restore(ctx, workspace, false)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(workspace, "a.txt"))
+	assert.NoFileExists(t, filepath.Join(workspace, "c.txt"))
+}
+
+func TestRestoreRefusesAnotherCommit(t *testing.T) {
+	ctx := context.Background()
+	workspace := initRepo(t)
+	snapshot, err := Capture(ctx, workspace, "")
+	require.NoError(t, err)
+
+	writeFiles(t, workspace, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	cmd := exec.Command("git", "commit", "-q", "-am", "second")
+	cmd.Dir = workspace
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	head, moved := snapshot.HeadMoved(ctx, workspace)
+	assert.True(t, moved)
+	assert.NotEqual(t, snapshot.Head, head)
+	_, err = snapshot.Restore(ctx, workspace, false)
+	assert.ErrorIs(t, err, ErrHeadMoved)
+	content, _ := os.ReadFile(filepath.Join(workspace, "main.go"))
+	assert.Equal(t, "package main\n\nfunc main() {}\n", string(content), "nothing is restored over another commit")
+
+	_, err = snapshot.Restore(ctx, workspace, true)
+	require.NoError(t, err)
+	content, _ = os.ReadFile(filepath.Join(workspace, "main.go"))
+	assert.Equal(t, "package main\n", string(content), "force restores anyway")
+}
+
+func TestRemoveEmptyParents(t *testing.T) {
+	dir := t.TempDir()
+	workspace := filepath.Join(dir, "ws")
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "a", "b", "c"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "ws2", "d"), 0755))
+
+	// A relative workspace root, as "." in the configuration
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(workspace))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	removeEmptyParents(".", filepath.Join("a", "b", "c"))
+	assert.NoDirExists(t, filepath.Join(workspace, "a"))
+	assert.DirExists(t, workspace, "the workspace root is kept")
+
+	// A sibling sharing the workspace's name as a prefix is outside it
+	removeEmptyParents(workspace, filepath.Join(dir, "ws2", "d"))
+	assert.DirExists(t, filepath.Join(dir, "ws2", "d"))
+}
@@ -0,0 +1,85 @@
+package statecrypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringService names the entries of CGE in the OS keyring
+const keyringService = "CGE"
+
+// keyringGet and keyringSet read and store a secret of the OS keyring, through
+// the security tool on macOS and secret-tool (libsecret) on Linux and the
+// BSDs; variables so tests can use an in-memory keyring
+var (
+	keyringGet = systemKeyringGet
+	keyringSet = systemKeyringSet
+)
+
+func systemKeyringGet(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	case "windows":
+		return "", errors.New("the OS keyring is not supported on Windows")
+	default:
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return "", fmt.Errorf("secret-tool not found: %w", err)
+		}
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", account)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	secret := strings.TrimSpace(string(output))
+	if secret == "" {
+		return "", fmt.Errorf("no entry %s in the keyring", account)
+	}
+	return secret, nil
+}
+
+func systemKeyringSet(account, secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// Interactive mode reads the command from standard input, keeping the
+		// secret out of the process list
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(securityAddCommand(account, secret))
+	case "windows":
+		return errors.New("the OS keyring is not supported on Windows")
+	default:
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return fmt.Errorf("secret-tool not found: %w", err)
+		}
+		cmd = exec.Command("secret-tool", "store", "--label=CGE state encryption key", "service", keyringService, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	// security -i reports a failed command without failing itself
+	if runtime.GOOS == "darwin" {
+		if stored, err := systemKeyringGet(account); err != nil || stored != secret {
+			return fmt.Errorf("the keychain did not store the secret: %s", strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// securityAddCommand returns the command of security -i storing secret
+func securityAddCommand(account, secret string) string {
+	quote := func(arg string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+	}
+	return fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", quote(keyringService), quote(account), quote(secret))
+}
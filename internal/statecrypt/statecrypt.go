@@ -0,0 +1,354 @@
+// Package statecrypt encrypts state files at rest. Sessions and chat
+// histories hold code and, potentially, secrets; with encryption enabled they
+// are sealed with AES-256-GCM before they are written and opened as they are
+// read, so the stores using them keep their API. The key of a state directory
+// is a random key kept in the OS keyring, or is derived from a passphrase with
+// scrypt. The directory's encryption.json records which, with the salt and a
+// check value that tells a wrong key apart from a damaged file.
+//
+// Files written before encryption was enabled are read as they are, and
+// 'CGE state encrypt' seals them.
+package statecrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Where the key of a state directory comes from
+const (
+	KeySourceAuto       = "auto"       // The passphrase when its variable is set, the keyring otherwise
+	KeySourceKeyring    = "keyring"    // A random key kept in the OS keyring
+	KeySourcePassphrase = "passphrase" // A key derived from a passphrase
+)
+
+// DefaultPassphraseEnv is the variable holding the passphrase by default
+const DefaultPassphraseEnv = "CGE_STATE_PASSPHRASE"
+
+// MetadataFile records how the files of a state directory are encrypted
+const MetadataFile = "encryption.json"
+
+// magic starts every sealed file
+var magic = []byte("CGEENC1\n")
+
+// checkText is sealed into the metadata to verify keys
+var checkText = []byte("CGE state key check")
+
+// ErrEncrypted is returned when reading a sealed file without encryption
+// enabled
+var ErrEncrypted = errors.New("the file is encrypted; enable [encryption] in codex.toml to read it")
+
+// ErrWrongKey is returned when the key does not open the files of a state
+// directory
+var ErrWrongKey = errors.New("the key does not match the one the state was encrypted with (wrong passphrase or keyring entry?)")
+
+// Options configure encryption for the process
+type Options struct {
+	Enabled       bool
+	KeySource     string // KeySourceAuto when empty
+	PassphraseEnv string // DefaultPassphraseEnv when empty
+}
+
+// metadata is the content of MetadataFile
+type metadata struct {
+	Version   int    `json:"version"`
+	KeySource string `json:"key_source"`
+	KeyID     string `json:"key_id,omitempty"` // Account of the key in the keyring
+	Salt      string `json:"salt,omitempty"`   // scrypt salt of passphrase keys
+	Check     string `json:"check"`            // checkText sealed with the key
+}
+
+// Cipher seals and opens the files of a state directory. A nil Cipher, used
+// when encryption is disabled, writes plaintext and refuses sealed files.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+var (
+	mu      sync.Mutex
+	options Options
+	ciphers = make(map[string]*Cipher) // Absolute directory -> its cipher
+)
+
+// Configure sets the encryption options of the process
+func Configure(opts Options) error {
+	switch opts.KeySource {
+	case "":
+		opts.KeySource = KeySourceAuto
+	case KeySourceAuto, KeySourceKeyring, KeySourcePassphrase:
+	default:
+		return fmt.Errorf("unknown key_source %q (want %s, %s or %s)", opts.KeySource, KeySourceAuto, KeySourceKeyring, KeySourcePassphrase)
+	}
+	if opts.PassphraseEnv == "" {
+		opts.PassphraseEnv = DefaultPassphraseEnv
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	options = opts
+	ciphers = make(map[string]*Cipher)
+	return nil
+}
+
+// Enabled reports whether files are encrypted
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return options.Enabled
+}
+
+// ForDir returns the cipher of the files of dir, a state directory, or nil
+// when encryption is disabled. The key is set up the first time dir is
+// encrypted.
+func ForDir(dir string) (*Cipher, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !options.Enabled {
+		return nil, nil
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if c, ok := ciphers[dir]; ok {
+		return c, nil
+	}
+	c, err := loadCipher(dir, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the state encryption key of %s: %w", dir, err)
+	}
+	ciphers[dir] = c
+	return c, nil
+}
+
+// loadCipher reads the metadata of dir, creating it and the key when missing
+func loadCipher(dir string, opts Options) (*Cipher, error) {
+	path := filepath.Join(dir, MetadataFile)
+	data, err := os.ReadFile(path) // #nosec G304 - path is in the state directory
+	if errors.Is(err, os.ErrNotExist) {
+		return createKey(dir, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta metadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", MetadataFile, err)
+	}
+	if opts.KeySource != KeySourceAuto && opts.KeySource != meta.KeySource {
+		return nil, fmt.Errorf("the state is encrypted with a %s key, but key_source is %s", meta.KeySource, opts.KeySource)
+	}
+
+	var key []byte
+	switch meta.KeySource {
+	case KeySourcePassphrase:
+		salt, err := base64.StdEncoding.DecodeString(meta.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid salt in %s: %w", MetadataFile, err)
+		}
+		if key, err = passphraseKey(opts.PassphraseEnv, salt); err != nil {
+			return nil, err
+		}
+	case KeySourceKeyring:
+		secret, err := keyringGet(meta.KeyID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the key from the OS keyring: %w", err)
+		}
+		if key, err = base64.StdEncoding.DecodeString(secret); err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("the key in the OS keyring is not a valid key")
+		}
+	default:
+		return nil, fmt.Errorf("unknown key_source %q in %s", meta.KeySource, MetadataFile)
+	}
+
+	c, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	check, err := base64.StdEncoding.DecodeString(meta.Check)
+	if err != nil {
+		return nil, fmt.Errorf("invalid check in %s: %w", MetadataFile, err)
+	}
+	if opened, err := c.Open(check); err != nil || !bytes.Equal(opened, checkText) {
+		return nil, ErrWrongKey
+	}
+	return c, nil
+}
+
+// createKey sets up the key of dir and writes its metadata
+func createKey(dir string, opts Options) (*Cipher, error) {
+	source := opts.KeySource
+	if source == KeySourceAuto {
+		source = KeySourceKeyring
+		if os.Getenv(opts.PassphraseEnv) != "" {
+			source = KeySourcePassphrase
+		}
+	}
+
+	meta := metadata{Version: 1, KeySource: source}
+	var key []byte
+	switch source {
+	case KeySourcePassphrase:
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		var err error
+		if key, err = passphraseKey(opts.PassphraseEnv, salt); err != nil {
+			return nil, err
+		}
+		meta.Salt = base64.StdEncoding.EncodeToString(salt)
+	case KeySourceKeyring:
+		key = make([]byte, 32)
+		id := make([]byte, 8)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		meta.KeyID = fmt.Sprintf("state-%x", id)
+		if err := keyringSet(meta.KeyID, base64.StdEncoding.EncodeToString(key)); err != nil {
+			return nil, fmt.Errorf("failed to store a key in the OS keyring (set %s to use a passphrase instead): %w", opts.PassphraseEnv, err)
+		}
+	}
+
+	c, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	check, err := c.Seal(checkText)
+	if err != nil {
+		return nil, err
+	}
+	meta.Check = base64.StdEncoding.EncodeToString(check)
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	// O_EXCL, so two processes setting up a key at once cannot overwrite
+	// each other's metadata
+	file, err := os.OpenFile(filepath.Join(dir, MetadataFile), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return loadCipher(dir, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return c, file.Close()
+}
+
+// passphraseKey derives a key from the passphrase in env
+func passphraseKey(env string, salt []byte) ([]byte, error) {
+	passphrase := os.Getenv(env)
+	if passphrase == "" {
+		return nil, fmt.Errorf("the state is encrypted with a passphrase; set it in %s", env)
+	}
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+// newCipher returns the cipher of a 32-byte key
+func newCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// IsSealed reports whether data is a sealed file
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts the content of a file; a nil Cipher returns it as it is
+func (c *Cipher) Seal(plaintext []byte) ([]byte, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(append([]byte{}, magic...), nonce...)
+	return c.aead.Seal(sealed, nonce, plaintext, nil), nil
+}
+
+// Open decrypts a file sealed by Seal. Files that are not sealed are returned
+// as they are, so state written before encryption was enabled stays readable.
+func (c *Cipher) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, ErrEncrypted
+	}
+	data = data[len(magic):]
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("the encrypted file is truncated")
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("the encrypted file is damaged or was encrypted with another key")
+	}
+	return plaintext, nil
+}
+
+// ConvertFile seals the file at path with c or, with decrypt, opens it back
+// to plaintext, reporting whether it changed. Files already in the wanted
+// form are left alone. The file is replaced atomically.
+func ConvertFile(path string, c *Cipher, decrypt bool) (bool, error) {
+	if c == nil {
+		return false, errors.New("encryption is not enabled")
+	}
+	data, err := os.ReadFile(path) // #nosec G304 - path is a state file chosen by the caller
+	if err != nil {
+		return false, err
+	}
+	if IsSealed(data) != decrypt {
+		return false, nil
+	}
+	if decrypt {
+		data, err = c.Open(data)
+	} else {
+		data, err = c.Seal(data)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return false, err
+	}
+	return true, nil
+}
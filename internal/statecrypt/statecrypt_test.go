@@ -0,0 +1,177 @@
+package statecrypt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configure enables encryption for a test, disabling it again afterwards
+func configure(t *testing.T, opts Options) {
+	t.Helper()
+	opts.Enabled = true
+	require.NoError(t, Configure(opts))
+	t.Cleanup(func() { _ = Configure(Options{}) })
+}
+
+// memoryKeyring replaces the OS keyring for a test
+func memoryKeyring(t *testing.T) map[string]string {
+	secrets := make(map[string]string)
+	get, set := keyringGet, keyringSet
+	keyringGet = func(account string) (string, error) {
+		secret, ok := secrets[account]
+		if !ok {
+			return "", errors.New("no such entry")
+		}
+		return secret, nil
+	}
+	keyringSet = func(account, secret string) error {
+		secrets[account] = secret
+		return nil
+	}
+	t.Cleanup(func() { keyringGet, keyringSet = get, set })
+	return secrets
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DefaultPassphraseEnv, "correct horse battery staple")
+	configure(t, Options{})
+
+	c, err := ForDir(dir)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.FileExists(t, filepath.Join(dir, MetadataFile))
+
+	sealed, err := c.Seal([]byte(`{"messages": ["token = sk-secret"]}`))
+	require.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, string(sealed), "sk-secret")
+	opened, err := c.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"messages": ["token = sk-secret"]}`, string(opened))
+
+	opened, err = c.Open([]byte(`{"plain": true}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"plain": true}`, string(opened), "files written before encryption are read as they are")
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = c.Open(sealed)
+	assert.ErrorContains(t, err, "damaged")
+
+	var disabled *Cipher
+	_, err = disabled.Open(sealed)
+	assert.ErrorIs(t, err, ErrEncrypted)
+	plain, err := disabled.Seal([]byte("x"))
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(plain))
+}
+
+func TestPassphraseKey(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DefaultPassphraseEnv, "first passphrase")
+	configure(t, Options{KeySource: KeySourcePassphrase})
+	c, err := ForDir(dir)
+	require.NoError(t, err)
+	sealed, err := c.Seal([]byte("session"))
+	require.NoError(t, err)
+
+	// A new process with the same passphrase opens the files
+	configure(t, Options{})
+	c, err = ForDir(dir)
+	require.NoError(t, err)
+	opened, err := c.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "session", string(opened))
+
+	t.Setenv(DefaultPassphraseEnv, "second passphrase")
+	configure(t, Options{})
+	_, err = ForDir(dir)
+	assert.ErrorIs(t, err, ErrWrongKey)
+
+	t.Setenv(DefaultPassphraseEnv, "")
+	configure(t, Options{})
+	_, err = ForDir(dir)
+	assert.ErrorContains(t, err, "set it in "+DefaultPassphraseEnv)
+
+	configure(t, Options{KeySource: KeySourceKeyring})
+	_, err = ForDir(dir)
+	assert.ErrorContains(t, err, "encrypted with a passphrase key, but key_source is keyring")
+}
+
+func TestKeyringKey(t *testing.T) {
+	secrets := memoryKeyring(t)
+	dir := t.TempDir()
+	t.Setenv(DefaultPassphraseEnv, "")
+	configure(t, Options{})
+
+	c, err := ForDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, secrets, 1, "the key is stored in the keyring")
+	sealed, err := c.Seal([]byte("history"))
+	require.NoError(t, err)
+
+	configure(t, Options{})
+	c, err = ForDir(dir)
+	require.NoError(t, err)
+	opened, err := c.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "history", string(opened))
+
+	for account := range secrets {
+		delete(secrets, account)
+	}
+	configure(t, Options{})
+	_, err = ForDir(dir)
+	assert.ErrorContains(t, err, "failed to read the key from the OS keyring")
+}
+
+func TestSecurityAddCommand(t *testing.T) {
+	assert.Equal(t, "add-generic-password -U -s \"CGE\" -a \"key-1\" -w \"a+b/c=\"\n", securityAddCommand("key-1", "a+b/c="))
+	assert.Equal(t, `add-generic-password -U -s "CGE" -a "a\"b" -w "c\\d"`+"\n", securityAddCommand(`a"b`, `c\d`), "quotes and backslashes are escaped")
+}
+
+func TestConfigure(t *testing.T) {
+	assert.ErrorContains(t, Configure(Options{Enabled: true, KeySource: "tpm"}), `unknown key_source "tpm"`)
+	require.NoError(t, Configure(Options{}))
+	assert.False(t, Enabled())
+	c, err := ForDir(t.TempDir())
+	require.NoError(t, err)
+	assert.Nil(t, c, "disabled encryption has no cipher")
+}
+
+func TestConvertFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DefaultPassphraseEnv, "passphrase")
+	configure(t, Options{})
+	c, err := ForDir(dir)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "session_1.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"session_id": "1"}`), 0600))
+
+	changed, err := ConvertFile(path, c, false)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	data, _ := os.ReadFile(path)
+	assert.True(t, IsSealed(data))
+	info, _ := os.Stat(path)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	changed, err = ConvertFile(path, c, false)
+	require.NoError(t, err)
+	assert.False(t, changed, "sealed files are left alone")
+
+	changed, err = ConvertFile(path, c, true)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	data, _ = os.ReadFile(path)
+	assert.Equal(t, `{"session_id": "1"}`, string(data))
+
+	_, err = ConvertFile(path, nil, false)
+	assert.ErrorContains(t, err, "not enabled")
+}
@@ -0,0 +1,153 @@
+package statedir
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Policy bounds what a subdirectory of the state directory keeps
+type Policy struct {
+	MaxAge  time.Duration // Files not modified for longer are removed; 0 keeps files of any age
+	MaxSize int64         // Oldest files are removed while the subdirectory is larger; 0 is unlimited
+}
+
+// Retention bounds how long sessions and chat histories are kept; 0 disables
+// a limit
+type Retention struct {
+	SessionsMaxAge    time.Duration // Sessions inactive for longer are deleted
+	ChatHistoryMaxAge time.Duration // Chat histories not saved for longer are deleted
+	ScrubAfter        time.Duration // Sessions inactive for longer keep only their metadata
+}
+
+// Enabled reports whether r sets any limit
+func (r Retention) Enabled() bool {
+	return r.SessionsMaxAge > 0 || r.ChatHistoryMaxAge > 0 || r.ScrubAfter > 0
+}
+
+// GCResult is what GC did to one subdirectory
+type GCResult struct {
+	Subdir  string
+	Removed int   // Files removed, or that would be in a dry run
+	Freed   int64 // Bytes removed
+	Kept    int   // Files left
+	Size    int64 // Bytes left
+}
+
+// Usage is the number and total size of the files in a directory
+type Usage struct {
+	Files int
+	Bytes int64
+}
+
+// stateFile is a file considered for removal
+type stateFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// GC prunes the subdirectories of a state directory that have a policy,
+// removing files older than its MaxAge, then the oldest files until the
+// subdirectory fits in its MaxSize. With dryRun, nothing is removed.
+// Subdirectories are processed in name order.
+func GC(root string, policies map[string]Policy, dryRun bool) ([]GCResult, error) {
+	subdirs := make([]string, 0, len(policies))
+	for subdir := range policies {
+		subdirs = append(subdirs, subdir)
+	}
+	sort.Strings(subdirs)
+
+	now := time.Now()
+	var results []GCResult
+	for _, subdir := range subdirs {
+		policy := policies[subdir]
+		dir := filepath.Join(root, subdir)
+		files, err := listFiles(dir)
+		if err != nil {
+			return results, err
+		}
+		// Oldest first, so size pruning removes the least recently used data
+		sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+		result := GCResult{Subdir: subdir}
+		for _, f := range files {
+			result.Size += f.size
+		}
+		for _, f := range files {
+			expired := policy.MaxAge > 0 && now.Sub(f.modTime) > policy.MaxAge
+			oversized := policy.MaxSize > 0 && result.Size > policy.MaxSize
+			if !expired && !oversized {
+				result.Kept++
+				continue
+			}
+			if !dryRun {
+				if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return results, err
+				}
+			}
+			result.Removed++
+			result.Freed += f.size
+			result.Size -= f.size
+		}
+		if !dryRun {
+			removeEmptyDirs(dir)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// DirUsage returns the number and total size of the files under dir. A
+// missing directory is empty.
+func DirUsage(dir string) (Usage, error) {
+	files, err := listFiles(dir)
+	if err != nil {
+		return Usage{}, err
+	}
+	usage := Usage{Files: len(files)}
+	for _, f := range files {
+		usage.Bytes += f.size
+	}
+	return usage, nil
+}
+
+// listFiles returns the regular files under dir
+func listFiles(dir string) ([]stateFile, error) {
+	var files []stateFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed meanwhile
+		}
+		files = append(files, stateFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// removeEmptyDirs removes the empty directories below dir, deepest first
+func removeEmptyDirs(dir string) {
+	var dirs []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && path != dir {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i]) // Fails, harmlessly, unless empty
+	}
+}
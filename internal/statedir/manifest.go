@@ -0,0 +1,175 @@
+package statedir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/filelock"
+)
+
+// LayoutVersion is the layout of the state directory this CGE writes. Version
+// 1 is the unversioned layout from before the manifest.
+const LayoutVersion = 2
+
+// ManifestFile is the name of the manifest in the state directory
+const ManifestFile = "manifest.json"
+
+// Manifest describes a state directory
+type Manifest struct {
+	Version   int       `json:"version"`
+	Workspace string    `json:"workspace"` // Absolute path of the workspace, for fallback directories
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReadManifest reads the manifest of a state directory. A directory without
+// one has layout version 1.
+func ReadManifest(root string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(root, ManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Manifest{Version: 1}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid state manifest %s: %w", filepath.Join(root, ManifestFile), err)
+	}
+	return &manifest, nil
+}
+
+// writeManifest replaces the manifest of a state directory atomically
+func writeManifest(root string, manifest *Manifest) error {
+	manifest.UpdatedAt = time.Now()
+	if manifest.CreatedAt.IsZero() {
+		manifest.CreatedAt = manifest.UpdatedAt
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(root, ManifestFile)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write state manifest: %w", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// migration upgrades a state directory by one layout version
+type migration struct {
+	version     int // Layout version the migration produces
+	description string
+	apply       func(root, absWorkspaceRoot string) error
+}
+
+// migrations in the order they apply
+var migrations = []migration{
+	{2, "moved logs to logs/ and saved LLM responses to reports/", migrateToV2},
+}
+
+// Migrate upgrades the state directory of a workspace to LayoutVersion and
+// returns what each applied migration did. A workspace without a state
+// directory is left alone; it is created with the current layout when first
+// written. Migrate does nothing while another process is migrating.
+func Migrate(workspaceRoot string) ([]string, error) {
+	root := Root(workspaceRoot)
+	if _, err := os.Stat(root); err != nil {
+		return nil, nil
+	}
+	absRoot, err := filepath.Abs(workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := filelock.TryLock(filepath.Join(root, "migrate.lock"))
+	if errors.Is(err, filelock.ErrLocked) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock state directory: %w", err)
+	}
+	defer lock.Unlock()
+
+	manifest, err := ReadManifest(root)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Version > LayoutVersion {
+		return nil, fmt.Errorf("state directory %s has layout version %d, but this CGE only knows up to version %d; upgrade CGE", root, manifest.Version, LayoutVersion)
+	}
+	if manifest.Version == LayoutVersion && manifest.Workspace != "" {
+		return nil, nil
+	}
+
+	var applied []string
+	for _, m := range migrations {
+		if m.version <= manifest.Version {
+			continue
+		}
+		if err := m.apply(root, absRoot); err != nil {
+			return applied, fmt.Errorf("failed to migrate state directory to version %d: %w", m.version, err)
+		}
+		// Recorded after each step, so an interrupted migration resumes
+		manifest.Version = m.version
+		manifest.Workspace = absRoot
+		if err := writeManifest(root, manifest); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m.description)
+	}
+	if manifest.Workspace == "" {
+		manifest.Workspace = absRoot
+		return applied, writeManifest(root, manifest)
+	}
+	return applied, nil
+}
+
+// migrateToV2 moves the logs kept at the top of the state directory into
+// logs/, and the raw LLM responses that commands used to leave in the
+// workspace into reports/
+func migrateToV2(root, absWorkspaceRoot string) error {
+	for _, name := range []string{"chat.log", "llm-http.log"} {
+		if err := moveFile(filepath.Join(root, name), filepath.Join(root, Logs, name)); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range []string{"failed_*_raw_output.txt", "failed_generate_task_*_raw.txt", "failed_review_fixes_raw.txt"} {
+		matches, err := filepath.Glob(filepath.Join(absWorkspaceRoot, pattern))
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			if err := moveFile(match, filepath.Join(root, Reports, filepath.Base(match))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// moveFile moves a file, copying it when the destination is on another
+// device. A missing source is not an error.
+func moveFile(src, dst string) error {
+	if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, data, 0600); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,103 @@
+// Package statedir defines where CGE keeps the state of a workspace. Sessions,
+// the index, audit logs, caches, reports, backups and logs live in
+// subdirectories of a single versioned directory, .cge in the workspace, or a
+// per-workspace directory under ~/.cge/workspaces when the workspace is not
+// writable. A manifest records the layout version, so older layouts can be
+// migrated as CGE changes.
+package statedir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DirName is the name of the state directory in a workspace
+const DirName = ".cge"
+
+// Subdirectories of the state directory
+const (
+	Sessions  = "sessions"  // Agent sessions and their locks
+	Index     = "index"     // Semantic search index
+	Audit     = "audit"     // Audit logs of tool executions
+	Cache     = "cache"     // Derived data that can be rebuilt at any time
+	Reports   = "reports"   // Run reports and raw LLM responses kept for debugging
+	Backups   = "backups"   // Copies of files taken before they were changed
+	Logs      = "logs"      // Chat and HTTP debug logs
+	Worktrees = "worktrees" // Git worktrees of sandboxed runs
+	Shared    = "shared"    // Team configuration, rules and prompts pulled by 'CGE sync'
+	Snapshots = "snapshots" // Workspace snapshots taken to repeat runs from the same files
+	Profiles  = "profiles"  // CPU and heap profiles written by --profile
+)
+
+// Subdirs lists every subdirectory of the state directory
+var Subdirs = []string{Sessions, Index, Audit, Cache, Reports, Backups, Logs, Worktrees, Shared, Snapshots, Profiles}
+
+// RulesFile holds the project rules added to the agent's system prompt, in
+// the state directory
+const RulesFile = "rules.md"
+
+var (
+	rootsMu sync.Mutex
+	roots   = make(map[string]string) // Absolute workspace root -> state directory
+)
+
+// writable reports whether files can be created in dir; a variable so tests
+// can simulate read-only workspaces
+var writable = func(dir string) bool {
+	probe, err := os.CreateTemp(dir, ".cge-probe-*")
+	if err != nil {
+		return false
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return true
+}
+
+// Root returns the state directory of a workspace. It is DirName in the
+// workspace, unless that cannot be written, in which case it is the global
+// fallback from GlobalRoot. The directory itself may not exist yet.
+func Root(workspaceRoot string) string {
+	absRoot, err := filepath.Abs(workspaceRoot)
+	if err != nil {
+		absRoot = workspaceRoot
+	}
+
+	rootsMu.Lock()
+	defer rootsMu.Unlock()
+	if root, ok := roots[absRoot]; ok {
+		return root
+	}
+
+	root := filepath.Join(absRoot, DirName)
+	probeDir := root
+	if _, err := os.Stat(root); err != nil {
+		probeDir = absRoot
+	}
+	if !writable(probeDir) {
+		if fallback, err := GlobalRoot(absRoot); err == nil {
+			root = fallback
+		}
+	}
+	roots[absRoot] = root
+	return root
+}
+
+// Path joins elem to a subdirectory of the state directory of a workspace
+func Path(workspaceRoot, subdir string, elem ...string) string {
+	return filepath.Join(append([]string{Root(workspaceRoot), subdir}, elem...)...)
+}
+
+// GlobalRoot returns the state directory used for a workspace that cannot be
+// written: ~/.cge/workspaces/<name>-<hash of its path>
+func GlobalRoot(absWorkspaceRoot string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(absWorkspaceRoot))
+	name := filepath.Base(absWorkspaceRoot) + "-" + hex.EncodeToString(sum[:])[:12]
+	return filepath.Join(home, DirName, "workspaces", name), nil
+}
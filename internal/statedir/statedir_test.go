@@ -0,0 +1,106 @@
+package statedir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoot(t *testing.T) {
+	workspace := t.TempDir()
+	assert.Equal(t, filepath.Join(workspace, DirName), Root(workspace))
+	assert.Equal(t, filepath.Join(workspace, DirName, Sessions, "a.json"), Path(workspace, Sessions, "a.json"))
+
+	t.Setenv("HOME", t.TempDir())
+	readOnly := t.TempDir()
+	defer func(w func(string) bool) { writable = w }(writable)
+	writable = func(dir string) bool { return dir != readOnly }
+
+	fallback, err := GlobalRoot(readOnly)
+	require.NoError(t, err)
+	assert.Equal(t, fallback, Root(readOnly))
+	assert.Contains(t, fallback, filepath.Join(DirName, "workspaces", filepath.Base(readOnly)+"-"))
+}
+
+func TestMigrate(t *testing.T) {
+	workspace := t.TempDir()
+	root := Root(workspace)
+
+	applied, err := Migrate(workspace)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "a workspace without state is left alone")
+	assert.NoDirExists(t, root)
+
+	// The layout before the manifest
+	require.NoError(t, os.MkdirAll(filepath.Join(root, Sessions), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "chat.log"), []byte("chat"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "failed_plan_raw_output.txt"), []byte("{"), 0600))
+
+	applied, err = Migrate(workspace)
+	require.NoError(t, err)
+	assert.Len(t, applied, 1)
+	assert.FileExists(t, filepath.Join(root, Logs, "chat.log"))
+	assert.NoFileExists(t, filepath.Join(root, "chat.log"))
+	assert.FileExists(t, filepath.Join(root, Reports, "failed_plan_raw_output.txt"))
+	assert.NoFileExists(t, filepath.Join(workspace, "failed_plan_raw_output.txt"))
+
+	manifest, err := ReadManifest(root)
+	require.NoError(t, err)
+	assert.Equal(t, LayoutVersion, manifest.Version)
+	assert.Equal(t, workspace, manifest.Workspace)
+
+	applied, err = Migrate(workspace)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "migrations run once")
+
+	manifest.Version = LayoutVersion + 1
+	require.NoError(t, writeManifest(root, manifest))
+	_, err = Migrate(workspace)
+	assert.ErrorContains(t, err, "upgrade CGE")
+}
+
+func TestGC(t *testing.T) {
+	root := t.TempDir()
+	write := func(path string, size int, age time.Duration) {
+		full := filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0750))
+		require.NoError(t, os.WriteFile(full, make([]byte, size), 0600))
+		modTime := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(full, modTime, modTime))
+	}
+	write("cache/old", 10, 48*time.Hour)
+	write("cache/older", 10, 72*time.Hour)
+	write("cache/new", 10, time.Minute)
+	write("backups/run-1/a.go", 100, 30*24*time.Hour)
+	write("backups/run-2/a.go", 100, time.Hour)
+	write("sessions/session_1.json", 100, 365*24*time.Hour)
+
+	policies := map[string]Policy{
+		Cache:   {MaxSize: 15},
+		Backups: {MaxAge: 7 * 24 * time.Hour},
+	}
+
+	results, err := GC(root, policies, true)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, GCResult{Subdir: Backups, Removed: 1, Freed: 100, Kept: 1, Size: 100}, results[0])
+	assert.Equal(t, GCResult{Subdir: Cache, Removed: 2, Freed: 20, Kept: 1, Size: 10}, results[1])
+	assert.FileExists(t, filepath.Join(root, "cache/older"), "a dry run removes nothing")
+
+	_, err = GC(root, policies, false)
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(root, "cache/older"))
+	assert.NoFileExists(t, filepath.Join(root, "cache/old"))
+	assert.FileExists(t, filepath.Join(root, "cache/new"))
+	assert.NoDirExists(t, filepath.Join(root, "backups/run-1"))
+	assert.FileExists(t, filepath.Join(root, "backups/run-2/a.go"))
+	assert.FileExists(t, filepath.Join(root, "sessions/session_1.json"), "subdirectories without a policy are untouched")
+
+	usage, err := DirUsage(filepath.Join(root, Cache))
+	require.NoError(t, err)
+	assert.Equal(t, Usage{Files: 1, Bytes: 10}, usage)
+}
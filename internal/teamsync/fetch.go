@@ -0,0 +1,195 @@
+package teamsync
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/httpclient"
+)
+
+// commitPattern matches abbreviated and full commit hashes
+var commitPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// fetch puts the bundle of opts.From under tmp and returns its directory
+func fetch(ctx context.Context, opts Options, tmp string, source *Source) (string, error) {
+	switch {
+	case isArchiveURL(opts.From):
+		if opts.VerifySignature {
+			return "", errors.New("signatures can only be verified for git sources; pin archives with --checksum")
+		}
+		return fetchArchive(ctx, opts.From, tmp)
+	case isGitSource(opts.From):
+		return fetchGit(ctx, opts, tmp, source)
+	}
+	info, err := os.Stat(opts.From)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %w", opts.From, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is neither a git repository, an archive URL nor a directory", opts.From)
+	}
+	if opts.VerifySignature {
+		return "", errors.New("signatures can only be verified for git sources; pin directories with --checksum")
+	}
+	return opts.From, nil
+}
+
+// isArchiveURL reports whether from is the URL of a .tar.gz archive
+func isArchiveURL(from string) bool {
+	if !strings.HasPrefix(from, "https://") && !strings.HasPrefix(from, "http://") {
+		return false
+	}
+	p := strings.ToLower(strings.SplitN(from, "?", 2)[0])
+	return strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz")
+}
+
+// isGitSource reports whether from names a git repository: a URL git
+// understands, or a local repository
+func isGitSource(from string) bool {
+	for _, prefix := range []string{"git@", "ssh://", "git://", "https://", "http://", "file://"} {
+		if strings.HasPrefix(from, prefix) {
+			return true
+		}
+	}
+	if strings.HasSuffix(from, ".git") {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(from, ".git"))
+	return err == nil
+}
+
+// fetchGit clones the repository at the requested ref and checks the
+// signature of its commit when asked to
+func fetchGit(ctx context.Context, opts Options, tmp string, source *Source) (string, error) {
+	dir := filepath.Join(tmp, "repo")
+	args := []string{"clone", "--quiet"}
+	if opts.Ref != "" && !commitPattern.MatchString(opts.Ref) {
+		args = append(args, "--depth", "1", "--branch", opts.Ref)
+	} else if opts.Ref == "" {
+		args = append(args, "--depth", "1")
+	}
+	if _, err := git(ctx, "", append(args, "--", opts.From, dir)...); err != nil {
+		return "", err
+	}
+	if commitPattern.MatchString(opts.Ref) {
+		if _, err := git(ctx, dir, "checkout", "--quiet", opts.Ref); err != nil {
+			return "", err
+		}
+	}
+	revision, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	source.Revision = revision
+	if opts.VerifySignature {
+		if _, err := git(ctx, dir, "verify-commit", "HEAD"); err != nil {
+			return "", fmt.Errorf("commit %s is not signed by a trusted key: %w", revision, err)
+		}
+	}
+	return dir, nil
+}
+
+// git runs a git command without prompting for credentials and returns its
+// trimmed output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		message := strings.TrimSpace(string(output))
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// fetchArchive downloads and unpacks a .tar.gz archive. Archives with a single
+// top-level directory, as code hosts produce, are unpacked from inside it.
+func fetchArchive(ctx context.Context, url, tmp string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpclient.Default().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	dir := filepath.Join(tmp, "archive")
+	if err := unpack(io.LimitReader(resp.Body, maxArchiveSize), dir); err != nil {
+		return "", fmt.Errorf("failed to unpack %s: %w", url, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name()), nil
+	}
+	return dir, nil
+}
+
+// unpack extracts the regular files of a .tar.gz stream into dir. Entries
+// that would land outside dir are refused; links and devices are skipped.
+func unpack(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	files := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("entry %s is outside the archive", header.Name)
+		}
+		if files++; files > maxBundleFiles*4 {
+			return fmt.Errorf("the archive has too many files")
+		}
+		if header.Size > maxBundleFileSize {
+			continue // Too large to be part of the bundle
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, io.LimitReader(tr, maxBundleFileSize))
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Package teamsync pulls a team's shared CGE setup - configuration, project
+// rules and prompt templates - from a git repository, an archive URL or a
+// directory, verifies it and installs it in the shared directory of the
+// workspace state. Shared files sit beneath local ones: the local codex.toml
+// is merged over the shared one, local templates replace shared templates of
+// the same name, and local rules follow the shared rules.
+package teamsync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/statedir"
+)
+
+// Files of a shared bundle
+const (
+	ConfigFile    = "codex.toml" // Configuration, including tool policies
+	RulesFile     = "rules.md"   // Project rules added to the agent's system prompt
+	PromptsDir    = "prompts"    // Prompt templates
+	ChecksumsFile = "SHA256SUMS" // Optional sha256sum-style checksums of the other files
+)
+
+// sourceFile records where the installed bundle came from
+const sourceFile = "source.json"
+
+// Limits on what a bundle may contain
+const (
+	maxBundleFiles    = 500
+	maxBundleFileSize = 1 << 20
+	maxArchiveSize    = 16 << 20
+)
+
+// ErrUnverified is returned when a bundle is neither pinned by a checksum nor
+// signed, and unverified bundles are not allowed
+var ErrUnverified = errors.New("the shared bundle is not verified")
+
+// Options says what to sync and how to verify it
+type Options struct {
+	From            string // Git URL or path, https URL of a .tar.gz archive, or directory
+	Ref             string // Branch, tag or commit of a git source; its default branch when empty
+	Checksum        string // Expected digest of the bundle, as reported by Sync
+	VerifySignature bool   // Require a valid signature on the commit of a git source
+	AllowUnverified bool   // Install bundles that are neither pinned nor signed
+}
+
+// Source describes the installed bundle
+type Source struct {
+	From     string    `json:"from"`
+	Ref      string    `json:"ref,omitempty"`
+	Revision string    `json:"revision,omitempty"` // Commit of a git source
+	Digest   string    `json:"digest"`             // sha256 over the checksums of the files
+	Verified []string  `json:"verified,omitempty"` // "checksum", "signature" and "checksums file"
+	Files    []string  `json:"files"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// Dir returns the directory the shared bundle of a workspace is installed in
+func Dir(workspaceRoot string) string {
+	return statedir.Path(workspaceRoot, statedir.Shared)
+}
+
+// ConfigPath returns the path of the shared configuration of a workspace,
+// which may not exist
+func ConfigPath(workspaceRoot string) string {
+	return filepath.Join(Dir(workspaceRoot), ConfigFile)
+}
+
+// PromptsPath returns the directory of the shared prompt templates of a
+// workspace, which may not exist
+func PromptsPath(workspaceRoot string) string {
+	return filepath.Join(Dir(workspaceRoot), PromptsDir)
+}
+
+// Rules returns the shared project rules of a workspace; empty when there are
+// none
+func Rules(workspaceRoot string) string {
+	content, err := os.ReadFile(filepath.Join(Dir(workspaceRoot), RulesFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// Current returns the source of the installed bundle; the error wraps
+// fs.ErrNotExist when nothing was synced
+func Current(workspaceRoot string) (*Source, error) {
+	data, err := os.ReadFile(filepath.Join(Dir(workspaceRoot), sourceFile))
+	if err != nil {
+		return nil, err
+	}
+	var source Source
+	if err := json.Unmarshal(data, &source); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", sourceFile, err)
+	}
+	return &source, nil
+}
+
+// Sync fetches the bundle of opts.From, verifies it and replaces the shared
+// bundle of the workspace with it. Nothing is installed when verification
+// fails.
+func Sync(ctx context.Context, workspaceRoot string, opts Options) (*Source, error) {
+	if opts.From == "" {
+		return nil, errors.New("no source to sync from: pass --from or set [sync] from")
+	}
+	tmp, err := os.MkdirTemp("", "cge-sync-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	source := &Source{From: opts.From, Ref: opts.Ref}
+	bundleDir, err := fetch(ctx, opts, tmp, source)
+	if err != nil {
+		return nil, err
+	}
+	files, err := bundleFiles(bundleDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s has no %s, %s or %s/", opts.From, ConfigFile, RulesFile, PromptsDir)
+	}
+	sums, err := checksums(bundleDir, files)
+	if err != nil {
+		return nil, err
+	}
+	source.Files = files
+	source.Digest = Digest(sums)
+
+	if listed, err := verifyChecksumsFile(bundleDir, sums); err != nil {
+		return nil, err
+	} else if listed {
+		source.Verified = append(source.Verified, "checksums file")
+	}
+	if opts.Checksum != "" {
+		want := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(opts.Checksum), "sha256:"))
+		if want != source.Digest {
+			return nil, fmt.Errorf("checksum mismatch: the bundle's digest is %s, expected %s", source.Digest, want)
+		}
+		source.Verified = append(source.Verified, "checksum")
+	}
+	if opts.VerifySignature {
+		source.Verified = append(source.Verified, "signature") // Checked by fetch
+	}
+	if opts.Checksum == "" && !opts.VerifySignature && !opts.AllowUnverified {
+		return nil, fmt.Errorf("%w: pin it with --checksum %s, or pass --verify-signature for a signed git source", ErrUnverified, source.Digest)
+	}
+
+	source.SyncedAt = time.Now().UTC()
+	if err := install(bundleDir, files, Dir(workspaceRoot), source); err != nil {
+		return nil, fmt.Errorf("failed to install the shared bundle: %w", err)
+	}
+	return source, nil
+}
+
+// bundleFiles lists the files of a bundle that are installed, as sorted
+// slash-separated paths. Symbolic links are refused, so a bundle cannot pull
+// in files from outside it.
+func bundleFiles(dir string) ([]string, error) {
+	var files []string
+	for _, name := range []string{ConfigFile, RulesFile} {
+		info, err := os.Lstat(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s in the bundle is not a regular file", name)
+		}
+		files = append(files, name)
+	}
+
+	prompts := filepath.Join(dir, PromptsDir)
+	err := filepath.WalkDir(prompts, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == prompts {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if strings.HasPrefix(entry.Name(), ".") && path != prompts {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if !entry.Type().IsRegular() {
+			return fmt.Errorf("%s in the bundle is not a regular file", filepath.ToSlash(rel))
+		}
+		files = append(files, filepath.ToSlash(rel))
+		if len(files) > maxBundleFiles {
+			return fmt.Errorf("the bundle has more than %d files", maxBundleFiles)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// checksums returns the sha256 of each file, by path
+func checksums(dir string, files []string) (map[string]string, error) {
+	sums := make(map[string]string, len(files))
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.New()
+		n, err := io.Copy(hash, io.LimitReader(f, maxBundleFileSize+1))
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if n > maxBundleFileSize {
+			return nil, fmt.Errorf("%s in the bundle is larger than %d bytes", file, maxBundleFileSize)
+		}
+		sums[file] = hex.EncodeToString(hash.Sum(nil))
+	}
+	return sums, nil
+}
+
+// Digest is the digest of a bundle: the sha256 of its checksums in
+// sha256sum format, one "<sha256>  <path>" line per file in path order
+func Digest(sums map[string]string) string {
+	paths := make([]string, 0, len(sums))
+	for path := range sums {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	hash := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(hash, "%s  %s\n", sums[path], path)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// verifyChecksumsFile checks the files of a bundle against its checksums file,
+// reporting whether it has one. Every installed file must be listed.
+func verifyChecksumsFile(dir string, sums map[string]string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ChecksumsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	listed := map[string]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, path, ok := strings.Cut(line, " ")
+		if !ok {
+			return false, fmt.Errorf("%s line %d: expected \"<sha256>  <path>\"", ChecksumsFile, i+1)
+		}
+		path = strings.TrimPrefix(strings.TrimSpace(path), "*") // Binary mode marker
+		listed[strings.TrimPrefix(path, "./")] = strings.ToLower(sum)
+	}
+	for path, sum := range sums {
+		want, ok := listed[path]
+		if !ok {
+			return false, fmt.Errorf("%s is not listed in %s", path, ChecksumsFile)
+		}
+		if want != sum {
+			return false, fmt.Errorf("%s does not match its checksum in %s", path, ChecksumsFile)
+		}
+	}
+	return true, nil
+}
+
+// install copies the files of a bundle to a new directory, then swaps it in
+// for dir, so a failed sync leaves the previous bundle in place
+func install(bundleDir string, files []string, dir string, source *Source) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(bundleDir, filepath.FromSlash(file)))
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(staging, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(source, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(staging, sourceFile), data, 0644); err != nil {
+		return err
+	}
+
+	old := staging + ".old"
+	if err := os.Rename(dir, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		os.Rename(old, dir)
+		return err
+	}
+	return os.RemoveAll(old)
+}
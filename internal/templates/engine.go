@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"text/template"
 
+	"github.com/castrovroberto/CGE/internal/i18n"
 	"github.com/castrovroberto/CGE/internal/security"
 )
 
@@ -14,6 +16,7 @@ import (
 type Engine struct {
 	templatesDir string
 	safeOps      *security.SafeFileOps
+	fallbacks    []*Engine // Consulted in order for templates templatesDir lacks
 }
 
 // NewEngine creates a new template engine
@@ -27,12 +30,31 @@ func NewEngine(templatesDir string) *Engine {
 	}
 }
 
-// Render renders a template with the given data
+// WithFallbackDir adds a directory of templates used for the templates the
+// engine's own directory lacks, such as the team's shared prompts. It returns
+// the engine.
+func (e *Engine) WithFallbackDir(dir string) *Engine {
+	e.fallbacks = append(e.fallbacks, NewEngine(dir))
+	return e
+}
+
+// Render renders a template with the given data. A template in the
+// subdirectory of the current locale (prompts/de/plan.tmpl) overrides the
+// default one, so prompts can be localized one template at a time.
 func (e *Engine) Render(templateName string, data interface{}) (string, error) {
-	templatePath := filepath.Join(e.templatesDir, templateName)
+	layer := e
+	templatePath, ok := e.templatePath(templateName)
+	for _, fallback := range e.fallbacks {
+		if ok {
+			break
+		}
+		if path, found := fallback.templatePath(templateName); found {
+			layer, templatePath, ok = fallback, path, true
+		}
+	}
 
 	// Read template file using secure file operations
-	content, err := e.safeOps.SafeReadFile(templatePath)
+	content, err := layer.safeOps.SafeReadFile(templatePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read template %s: %w", templatePath, err)
 	}
@@ -52,6 +74,20 @@ func (e *Engine) Render(templateName string, data interface{}) (string, error) {
 	return buf.String(), nil
 }
 
+// templatePath returns the path of the most specific template for the current
+// locale, reporting whether the template exists
+func (e *Engine) templatePath(templateName string) (string, bool) {
+	for _, locale := range i18n.Fallbacks(i18n.Locale()) {
+		localized := filepath.Join(e.templatesDir, locale, templateName)
+		if _, err := os.Stat(localized); err == nil {
+			return localized, true
+		}
+	}
+	path := filepath.Join(e.templatesDir, templateName)
+	_, err := os.Stat(path)
+	return path, err == nil
+}
+
 // RenderWithTools renders a template with tool definitions included
 func (e *Engine) RenderWithTools(templateName string, data interface{}, tools []ToolDefinition) (string, error) {
 	// Create enhanced data structure that includes tools
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/castrovroberto/CGE/internal/i18n"
 )
 
 func TestPlanTemplateWithFunctionCalling(t *testing.T) {
@@ -307,3 +309,39 @@ func TestValidateFileOperationEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderLocalizedTemplate(t *testing.T) {
+	defer i18n.Configure(i18n.DefaultLocale, "")
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "plan.tmpl"), []byte("Goal: {{.UserGoal}}"), 0600); err != nil {
+		t.Fatalf("Failed to write test template: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tempDir, "de"), 0750); err != nil {
+		t.Fatalf("Failed to create locale directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "de", "plan.tmpl"), []byte("Ziel: {{.UserGoal}}"), 0600); err != nil {
+		t.Fatalf("Failed to write localized template: %v", err)
+	}
+	engine := NewEngine(tempDir)
+	data := PlanTemplateData{UserGoal: "Tests"}
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"en", "Goal: Tests"},
+		{"de", "Ziel: Tests"},
+		{"de-AT", "Ziel: Tests"}, // Falls back to the language
+		{"fr", "Goal: Tests"},    // No localized template
+	}
+	for _, tt := range tests {
+		_ = i18n.Configure(tt.locale, tempDir)
+		result, err := engine.Render("plan.tmpl", data)
+		if err != nil {
+			t.Fatalf("Failed to render template for %s: %v", tt.locale, err)
+		}
+		if result != tt.want {
+			t.Errorf("Render() for %s = %q, want %q", tt.locale, result, tt.want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package textutils
+
+import "bytes"
+
+// binarySniffLength is how much of a file IsBinary looks at, as in git
+const binarySniffLength = 8000
+
+// IsBinary reports whether content looks binary: like git, it checks for a
+// NUL byte near the start
+func IsBinary(content []byte) bool {
+	sniff := content
+	if len(sniff) > binarySniffLength {
+		sniff = sniff[:binarySniffLength]
+	}
+	return bytes.IndexByte(sniff, 0) >= 0
+}
@@ -99,6 +99,9 @@ func (c *Chunker) chunkByLines(text string) ([]TextChunk, error) {
 			ChunkIndex: chunkIndex,
 		}
 		chunks = append(chunks, chunk)
+		if end == len(lines) {
+			break
+		}
 
 		// Move to next chunk with overlap
 		i = end - c.options.OverlapSize
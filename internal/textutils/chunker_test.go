@@ -0,0 +1,32 @@
+package textutils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkByLinesOverlap(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 25; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	chunker := NewChunker(ChunkOptions{Strategy: ChunkByLines, MaxSize: 10, OverlapSize: 2})
+
+	chunks, err := chunker.ChunkText(strings.Join(lines, "\n"))
+	require.NoError(t, err)
+	var spans []string
+	for _, chunk := range chunks {
+		spans = append(spans, fmt.Sprintf("%d-%d", chunk.StartLine, chunk.EndLine))
+	}
+	assert.Equal(t, []string{"1-10", "9-18", "17-25"}, spans)
+
+	// A text shorter than a chunk is a single chunk despite the overlap
+	chunks, err = chunker.ChunkText("package main\n\nfunc main() {}")
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, 3, chunks[0].EndLine)
+}
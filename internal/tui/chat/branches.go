@@ -0,0 +1,401 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// mainBranch is the branch a conversation starts on
+const mainBranch = "main"
+
+// maxMergedOutcomeChars bounds the outcome of a branch quoted when it is merged
+const maxMergedOutcomeChars = 2000
+
+// branchNamePattern matches the names /branch accepts
+var branchNamePattern = regexp.MustCompile(`^[\w.-]+$`)
+
+// chatBranch is a line of the conversation. A branch starts as a copy of the
+// messages of its parent up to the point it forked at, and goes its own way
+// from there. Branches are of the conversation only: the files the agent
+// changes in one branch stay changed in the others.
+type chatBranch struct {
+	name     string
+	parent   string // "" for the main branch
+	forkAt   int    // Messages of the parent the branch started from
+	created  time.Time
+	mergedAt *time.Time
+	// messages holds the conversation of the branch while another is shown;
+	// the messages of the branch shown are in the message list
+	messages []chatMessage
+}
+
+// branchExplorer shows the branches as a tree in place of the message list
+type branchExplorer struct {
+	selected int
+}
+
+// branchCommand opens the branch explorer, starts a branch from the end of
+// the conversation or after a turn, or switches to or merges a branch:
+// "/branch <name> [turn]", "/branch switch <name>", "/branch merge [name]"
+func (m *Model) branchCommand(args string) tea.Cmd {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		if len(m.branches) == 0 {
+			m.addSystemNotice("🌿 No branches yet; /branch <name> [turn] starts one from the end of the conversation, or after turn n")
+			return nil
+		}
+		m.branchExplorer = &branchExplorer{selected: slices.IndexFunc(m.branchTree(), func(node branchNode) bool {
+			return node.branch.name == m.branch
+		})}
+		return nil
+	}
+	if m.loading {
+		m.addSystemNotice("🌿 Wait for the run to finish, or cancel it, before changing branches")
+		return nil
+	}
+
+	usage := "Usage: /branch [<name> [turn] | switch <name> | merge [name]]"
+	if (fields[0] == "switch" || fields[0] == "merge") && len(m.branches) == 0 {
+		m.addSystemNotice("🌿 No branches yet; /branch <name> [turn] starts one")
+		return nil
+	}
+	switch fields[0] {
+	case "switch":
+		if len(fields) != 2 {
+			m.addSystemNotice(usage)
+			return nil
+		}
+		m.switchBranch(fields[1])
+	case "merge":
+		if len(fields) > 2 {
+			m.addSystemNotice(usage)
+			return nil
+		}
+		name := m.branch
+		if len(fields) == 2 {
+			name = fields[1]
+		}
+		m.mergeBranch(name)
+	default:
+		if len(fields) > 2 {
+			m.addSystemNotice(usage)
+			return nil
+		}
+		turn := 0
+		if len(fields) == 2 {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				m.addSystemNotice(usage)
+				return nil
+			}
+			turn = n
+		}
+		m.createBranch(fields[0], turn)
+	}
+	return nil
+}
+
+// createBranch starts a branch from the current one after turn, or from its
+// end when turn is 0, and switches to it
+func (m *Model) createBranch(name string, turn int) {
+	if !branchNamePattern.MatchString(name) || name == "switch" || name == "merge" {
+		m.addSystemNotice(fmt.Sprintf("🌿 %q cannot name a branch; use letters, digits, '.', '-' and '_'", name))
+		return
+	}
+	if name == mainBranch || m.findBranch(name) != nil {
+		m.addSystemNotice(fmt.Sprintf("🌿 Branch %s exists; /branch switch %s goes to it", name, name))
+		return
+	}
+	messages := m.messageList.GetMessages()
+	forkAt := len(messages)
+	if turn != 0 {
+		var ok bool
+		if forkAt, ok = turnEnd(messages, turn); !ok {
+			m.addSystemNotice(fmt.Sprintf("🌿 There is no turn %d; the conversation has %d turn(s)", turn, countTurns(messages)))
+			return
+		}
+	}
+
+	if len(m.branches) == 0 {
+		m.branches = []*chatBranch{{name: mainBranch, created: m.chatStartTime}}
+		m.branch = mainBranch
+	}
+	from := m.branch
+	branch := &chatBranch{name: name, parent: from, forkAt: forkAt, created: time.Now(), messages: slices.Clone(messages[:forkAt])}
+	turns := countTurns(branch.messages)
+	m.branches = append(m.branches, branch)
+	m.showBranch(branch)
+	m.addSystemNotice(fmt.Sprintf("🌿 Started branch %s from %s after turn %d; /branch switch %s goes back", name, from, turns, from))
+}
+
+// switchBranch shows the conversation of another branch
+func (m *Model) switchBranch(name string) {
+	branch := m.findBranch(name)
+	if branch == nil {
+		m.addSystemNotice(fmt.Sprintf("🌿 There is no branch %s; /branch lists them", name))
+		return
+	}
+	if name == m.branch {
+		m.addSystemNotice(fmt.Sprintf("🌿 Already on branch %s", name))
+		return
+	}
+	m.showBranch(branch)
+	m.addSystemNotice(fmt.Sprintf("🌿 Switched to branch %s", name))
+}
+
+// mergeBranch brings the outcome of a branch back to its parent as a summary
+// message, and switches to the parent
+func (m *Model) mergeBranch(name string) {
+	branch := m.findBranch(name)
+	switch {
+	case branch == nil:
+		m.addSystemNotice(fmt.Sprintf("🌿 There is no branch %s; /branch lists them", name))
+		return
+	case branch.parent == "":
+		m.addSystemNotice(fmt.Sprintf("🌿 %s has no parent to merge into; switch to a branch to merge it", name))
+		return
+	case branch.mergedAt != nil:
+		m.addSystemNotice(fmt.Sprintf("🌿 Branch %s was merged already", name))
+		return
+	}
+	parent := m.findBranch(branch.parent)
+	summary, ok := branchSummary(branch, m.branchMessages(branch))
+	if !ok {
+		m.addSystemNotice(fmt.Sprintf("🌿 Branch %s has no turns of its own to merge", name))
+		return
+	}
+
+	if m.branch != parent.name {
+		m.showBranch(parent)
+	}
+	now := time.Now()
+	branch.mergedAt = &now
+	m.addSystemNotice(summary)
+}
+
+// branchSummary sums up what a branch did since it forked: its last request
+// and the answer to it. It reports false when the branch has no turns.
+func branchSummary(branch *chatBranch, messages []chatMessage) (string, bool) {
+	own := messages[min(branch.forkAt, len(messages)):]
+	turns := countTurns(own)
+	if turns == 0 {
+		return "", false
+	}
+	var request, outcome string
+	for _, msg := range own {
+		switch {
+		case msg.sender == "You":
+			request, outcome = msg.text, ""
+		case msg.isAssistantReply():
+			outcome = msg.sourceText()
+		}
+	}
+	if outcome == "" {
+		outcome = "(no answer)"
+	}
+	if len(outcome) > maxMergedOutcomeChars {
+		outcome = truncateUTF8(outcome, maxMergedOutcomeChars) + "..."
+	}
+	first, _, _ := strings.Cut(request, "\n")
+	return fmt.Sprintf("🔀 Merged branch %s: %d turn(s) since it forked\n\nLast request: %s\n\nOutcome:\n%s", branch.name, turns, first, outcome), true
+}
+
+// showBranch replaces the conversation shown with that of branch, keeping the
+// one shown in its branch
+func (m *Model) showBranch(branch *chatBranch) {
+	if current := m.findBranch(m.branch); current != nil {
+		current.messages = slices.Clone(m.messageList.GetMessages())
+	}
+	m.messageList.LoadHistory(branch.messages)
+	branch.messages = nil
+	m.branch = branch.name
+	// The turns of other branches cannot be undone from this one
+	m.turnStarts = nil
+	m.codeBlockSelection = -1
+	m.messageList.GotoBottom()
+}
+
+// findBranch returns the branch called name, nil when there is none
+func (m *Model) findBranch(name string) *chatBranch {
+	for _, branch := range m.branches {
+		if branch.name == name {
+			return branch
+		}
+	}
+	return nil
+}
+
+// branchMessages returns the conversation of a branch, shown or not
+func (m *Model) branchMessages(branch *chatBranch) []chatMessage {
+	if branch.name == m.branch {
+		return m.messageList.GetMessages()
+	}
+	return branch.messages
+}
+
+// countTurns returns the number of prompts of the user in messages
+func countTurns(messages []chatMessage) int {
+	turns := 0
+	for _, msg := range messages {
+		if msg.sender == "You" {
+			turns++
+		}
+	}
+	return turns
+}
+
+// turnEnd returns the index of the message following turn n, 1-based:
+// the next prompt of the user, or the end of the conversation
+func turnEnd(messages []chatMessage, n int) (int, bool) {
+	if n < 1 {
+		return 0, false
+	}
+	turns := 0
+	for i, msg := range messages {
+		if msg.sender != "You" {
+			continue
+		}
+		if turns == n {
+			return i, true
+		}
+		turns++
+	}
+	return len(messages), turns == n
+}
+
+// branchNode is a branch in the tree the explorer shows
+type branchNode struct {
+	branch *chatBranch
+	depth  int
+}
+
+// branchTree returns the branches depth first, each under its parent, in the
+// order they were created
+func (m *Model) branchTree() []branchNode {
+	var nodes []branchNode
+	var visit func(parent string, depth int)
+	visit = func(parent string, depth int) {
+		for _, branch := range m.branches {
+			if branch.parent == parent {
+				nodes = append(nodes, branchNode{branch: branch, depth: depth})
+				visit(branch.name, depth+1)
+			}
+		}
+	}
+	visit("", 0)
+	return nodes
+}
+
+// branchExplorerKey handles a key while the branch explorer is open
+func (m *Model) branchExplorerKey(msg tea.KeyMsg) tea.Cmd {
+	nodes := m.branchTree()
+	explorer := m.branchExplorer
+	selected := nodes[explorer.selected].branch
+	switch msg.String() {
+	case "esc", "q":
+		m.branchExplorer = nil
+	case "up", "k":
+		explorer.selected = max(0, explorer.selected-1)
+	case "down", "j":
+		explorer.selected = min(len(nodes)-1, explorer.selected+1)
+	case "enter":
+		if m.loading {
+			m.addSystemNotice("🌿 Wait for the run to finish, or cancel it, before changing branches")
+			return nil
+		}
+		m.branchExplorer = nil
+		if selected.name != m.branch {
+			m.switchBranch(selected.name)
+		}
+	case "m":
+		if m.loading {
+			m.addSystemNotice("🌿 Wait for the run to finish, or cancel it, before changing branches")
+			return nil
+		}
+		m.branchExplorer = nil
+		m.mergeBranch(selected.name)
+	}
+	return nil
+}
+
+// branchExplorerView renders the branches as a tree: the branch shown is
+// starred, and each tells where it forked and whether it was merged
+func (m Model) branchExplorerView() string {
+	width, height := m.messageList.OverlaySize()
+	out := []string{m.theme.Sender.Render("Branches"), ""}
+	for i, node := range m.branchTree() {
+		branch := node.branch
+		line := branch.name
+		if node.depth > 0 {
+			line = strings.Repeat("   ", node.depth-1) + "└─ " + line
+		}
+		if branch.name == m.branch {
+			line = "* " + line
+		} else {
+			line = "  " + line
+		}
+		messages := m.branchMessages(branch)
+		line += fmt.Sprintf("  %d turn(s)", countTurns(messages))
+		if branch.parent != "" {
+			line += fmt.Sprintf(", forked from %s after turn %d", branch.parent, countTurns(messages[:min(branch.forkAt, len(messages))]))
+		}
+		if branch.mergedAt != nil {
+			line += ", merged " + branch.mergedAt.Format("15:04")
+		}
+		if i == m.branchExplorer.selected {
+			out = append(out, m.theme.Sender.Render(truncateLine("> "+line, width)))
+		} else {
+			out = append(out, truncateLine("  "+line, width))
+		}
+	}
+	for len(out) < height-1 {
+		out = append(out, "")
+	}
+	out = append(out, m.theme.Time.Render(truncateLine("up/down select, enter switch, m merge into its parent, esc close", width)))
+	return strings.Join(out, "\n")
+}
+
+// savedBranches returns the branches as written to the chat history
+func (m *Model) savedBranches() []SavedBranch {
+	saved := make([]SavedBranch, len(m.branches))
+	for i, branch := range m.branches {
+		saved[i] = SavedBranch{
+			Name:     branch.name,
+			Parent:   branch.parent,
+			ForkAt:   branch.forkAt,
+			Created:  branch.created,
+			MergedAt: branch.mergedAt,
+			Messages: m.branchMessages(branch),
+		}
+	}
+	return saved
+}
+
+// loadBranches restores the branches of a chat history; the messages of the
+// branch shown are loaded with the history
+func (m *Model) loadBranches(history *ChatHistory) {
+	m.branches, m.branch = nil, ""
+	if len(history.Branches) == 0 {
+		return
+	}
+	for _, saved := range history.Branches {
+		branch := &chatBranch{
+			name:     saved.Name,
+			parent:   saved.Parent,
+			forkAt:   saved.ForkAt,
+			created:  saved.Created,
+			mergedAt: saved.MergedAt,
+		}
+		if saved.Name != history.Branch {
+			branch.messages = saved.Messages
+		}
+		m.branches = append(m.branches, branch)
+	}
+	m.branch = history.Branch
+}
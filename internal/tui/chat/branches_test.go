@@ -0,0 +1,136 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchCommand(t *testing.T) {
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	update := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	turn := func(prompt, answer string) {
+		model.messageList.AddMessage(chatMessage{text: prompt, sender: "You"})
+		model.messageList.AddMessage(chatMessage{text: answer, rawText: answer, isMarkdown: true, sender: "Assistant"})
+	}
+	texts := func() []string {
+		var texts []string
+		for _, msg := range model.messageList.GetMessages() {
+			texts = append(texts, msg.sourceText())
+		}
+		return texts
+	}
+	turn("Fix the parser", "Use a recursive descent parser")
+	turn("Add tests", "Added parser_test.go")
+
+	model.branchCommand("")
+	assert.Contains(t, lastMessageText(model), "No branches yet")
+	model.branchCommand("merge")
+	assert.Contains(t, lastMessageText(model), "No branches yet")
+	model.branchCommand("bad/name")
+	assert.Contains(t, lastMessageText(model), "cannot name a branch")
+	model.branchCommand("try 5")
+	assert.Contains(t, lastMessageText(model), "There is no turn 5; the conversation has 2 turn(s)")
+
+	// A branch after turn 1 leaves out the second turn
+	model.branchCommand("try 1")
+	assert.Contains(t, lastMessageText(model), "Started branch try from main after turn 1")
+	assert.NotContains(t, texts(), "Add tests")
+	assert.Contains(t, texts(), "Use a recursive descent parser")
+	model.branchCommand("try")
+	assert.Contains(t, lastMessageText(model), "Branch try exists")
+	turn("Use a parser generator instead", "Switched to goyacc")
+
+	model.branchCommand("switch main")
+	assert.Contains(t, texts(), "Add tests")
+	assert.NotContains(t, texts(), "Switched to goyacc")
+	model.branchCommand("switch main")
+	assert.Contains(t, lastMessageText(model), "Already on branch main")
+	model.branchCommand("merge main")
+	assert.Contains(t, lastMessageText(model), "no parent to merge into")
+
+	// Merging the branch quotes its outcome in its parent
+	model.branchCommand("merge try")
+	assert.Equal(t, mainBranch, model.branch)
+	assert.Contains(t, lastMessageText(model), "Merged branch try: 1 turn(s) since it forked")
+	assert.Contains(t, lastMessageText(model), "Last request: Use a parser generator instead")
+	assert.Contains(t, lastMessageText(model), "Switched to goyacc")
+	model.branchCommand("merge try")
+	assert.Contains(t, lastMessageText(model), "was merged already")
+
+	// A branch without turns of its own has nothing to merge
+	model.branchCommand("empty")
+	model.branchCommand("merge")
+	assert.Contains(t, lastMessageText(model), "Branch empty has no turns of its own to merge")
+	assert.Equal(t, "empty", model.branch)
+}
+
+func TestBranchExplorer(t *testing.T) {
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	update := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	model.messageList.AddMessage(chatMessage{text: "Fix the parser", sender: "You"})
+	model.branchCommand("a")
+	model.branchCommand("b")
+	model.branchCommand("switch main")
+	model.branchCommand("c")
+
+	model.branchCommand("")
+	require.NotNil(t, model.branchExplorer)
+	view := model.View()
+	assert.Contains(t, view, "main  1 turn(s)")
+	assert.Contains(t, view, "└─ a  1 turn(s), forked from main after turn 1")
+	assert.Contains(t, view, "   └─ b")
+	assert.Less(t, strings.Index(view, "└─ b"), strings.Index(view, "└─ c"), "b is listed under a")
+	assert.Contains(t, view, "* └─ c")
+
+	// The tree lists main, a, b, c: c is selected, b is above it
+	update(tea.KeyMsg{Type: tea.KeyUp})
+	update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Nil(t, model.branchExplorer)
+	assert.Equal(t, "b", model.branch)
+}
+
+func TestBranchHistoryRoundTrip(t *testing.T) {
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	model.messageList.AddMessage(chatMessage{text: "Fix the parser", sender: "You"})
+	model.messageList.AddMessage(chatMessage{text: "**Done**", rawText: "**Done**", isMarkdown: true, sender: "Assistant"})
+	model.branchCommand("try")
+	model.messageList.AddMessage(chatMessage{text: "Try goyacc", sender: "You"})
+
+	history := ChatHistory{Messages: model.messageList.GetMessages(), Branch: model.branch, Branches: model.savedBranches()}
+	data, err := json.Marshal(history)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"text":"**Done**"`)
+	var loaded ChatHistory
+	require.NoError(t, json.Unmarshal(data, &loaded))
+
+	restored := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	restored.LoadHistory(&loaded)
+	assert.Equal(t, "try", restored.branch)
+	require.Len(t, restored.branches, 2)
+	assert.Equal(t, "Try goyacc", lastMessageText(restored))
+	restored.branchCommand("switch main")
+	messages := restored.messageList.GetMessages()
+	assert.Equal(t, "Fix the parser", messages[1].text)
+	assert.True(t, messages[2].isMarkdown)
+	assert.Equal(t, "**Done**", messages[2].sourceText())
+}
@@ -4,12 +4,19 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/castrovroberto/CGE/internal/agent"
 	"github.com/castrovroberto/CGE/internal/llm"
 	"github.com/castrovroberto/CGE/internal/orchestrator"
+	"github.com/castrovroberto/CGE/internal/textutils"
 )
 
 // ChatPresenter implements MessageProvider and acts as the presenter layer
@@ -23,6 +30,48 @@ type ChatPresenter struct {
 	cancelCtx    context.CancelFunc
 	systemPrompt string
 	modelName    string
+
+	// Pricing used to estimate the cost of each run
+	pricing      llm.ModelPricing
+	pricingKnown bool
+	// usage meters the requests of the agent, for the usage providers report
+	usage *llm.UsageMeter
+
+	// runMu serializes agent runs; a prompt sent right after cancelling waits
+	// for the cancelled run to unwind
+	runMu sync.Mutex
+	// interrupted holds the messages of a cancelled run, which the next prompt
+	// continues from
+	interrupted []orchestrator.Message
+	// adopted holds the exchange of a comparison the user picked an answer
+	// of, which the next prompt continues from
+	adopted []orchestrator.Message
+	// checkpoints holds the file changes of recent turns, newest last, for Undo
+	checkpoints []*agent.Checkpoint
+	// fileVersions is what the agent knows of the files it read, kept across
+	// turns so edits made by the user in between are detected
+	fileVersions *agent.FileVersions
+
+	// Plan mode, see plan_mode.go. The fields are changed under runMu.
+	planMode     atomic.Bool
+	planDraft    *agent.PlanDraft
+	planRequests []string        // Messages of the user in plan mode, oldest first
+	planDisabled map[string]bool // Tools disabled on entering plan mode, to enable again on leaving it
+}
+
+// maxUndoTurns is how many turns Undo can step back through
+const maxUndoTurns = 20
+
+// TokenUsage is the token usage and cost of one agent run, as the provider
+// reported it or else estimated. It is attached to the final assistant
+// message under the "usage" metadata key.
+type TokenUsage struct {
+	InputTokens       int     // Prompt tokens summed over every LLM call in the run
+	CachedInputTokens int     // Part of InputTokens served from the provider's prompt cache
+	OutputTokens      int     // Tokens generated by the model
+	ContextTokens     int     // Size of the conversation and tool definitions at the end of the run
+	CostUSD           float64 // Estimated cost; zero when CostKnown is false
+	CostKnown         bool    // Whether the model's pricing is known
 }
 
 // NewChatPresenter creates a new ChatPresenter
@@ -36,14 +85,339 @@ func NewChatPresenter(ctx context.Context, llmClient llm.Client, toolRegistry *a
 		cancelCtx:    pCancel,
 		systemPrompt: systemPrompt,
 		modelName:    modelName,
+		fileVersions: agent.NewFileVersions(),
+		planDraft:    &agent.PlanDraft{},
+		usage:        &llm.UsageMeter{},
 	}
 
+	// Show model pulls triggered by a missing model like tool progress
+	llm.SetPullProgressOf(llmClient, presenter.reportPullProgress)
+
 	// Initialize AgentRunner
-	presenter.agentRunner = orchestrator.NewAgentRunner(llmClient, toolRegistry, systemPrompt, modelName)
+	presenter.agentRunner = orchestrator.NewAgentRunner(llm.WithUsageMeter(llmClient, presenter.usage), toolRegistry, systemPrompt, modelName)
+	presenter.agentRunner.Tasks().OnChange(presenter.reportTasks)
+	presenter.registerPlanTool()
 
 	return presenter
 }
 
+// EnableDelegation lets the agent hand subtasks to child agents built from
+// toolFactory with the delegate_task tool
+func (p *ChatPresenter) EnableDelegation(toolFactory *agent.ToolFactory) error {
+	return p.agentRunner.EnableDelegation(toolFactory)
+}
+
+// SetPricing sets the model pricing used for cost estimates; known is false when
+// the price of the model is unknown
+func (p *ChatPresenter) SetPricing(pricing llm.ModelPricing, known bool) {
+	p.pricing = pricing
+	p.pricingKnown = known
+}
+
+// SetModel implements ModelSwitcher. It fails while a run is in progress. The
+// pricing is reset to unknown; call SetPricing for the new model.
+func (p *ChatPresenter) SetModel(name string) error {
+	if !p.runMu.TryLock() {
+		return errors.New("a run is in progress; wait for it to finish or cancel it")
+	}
+	defer p.runMu.Unlock()
+	p.setModel(name)
+	return nil
+}
+
+// setModel switches the model of the next runs. The caller holds runMu.
+func (p *ChatPresenter) setModel(name string) {
+	p.modelName = name
+	p.agentRunner.SetModel(name)
+	p.pricing, p.pricingKnown = llm.ModelPricing{}, false
+}
+
+// Tools implements ToolInspector, listing the tools sorted by name
+func (p *ChatPresenter) Tools() []ToolInfo {
+	stats := p.agentRunner.ToolStats()
+	tools := p.agentRunner.ToolRegistry().List()
+	infos := make([]ToolInfo, 0, len(tools))
+	for _, tool := range tools {
+		s := stats[tool.Name()]
+		codes := make(map[string]int, len(s.ErrorCodes))
+		for code, count := range s.ErrorCodes {
+			codes[string(code)] = count
+		}
+		infos = append(infos, ToolInfo{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			Parameters:  tool.Parameters(),
+			Enabled:     p.agentRunner.ToolEnabled(tool.Name()),
+			Calls:       s.Calls,
+			Errors:      s.Errors,
+			Duration:    s.Duration,
+			MaxDuration: s.MaxDuration,
+			ErrorCodes:  codes,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// SetToolEnabled implements ToolInspector. The change applies from the next
+// step of the agent.
+func (p *ChatPresenter) SetToolEnabled(name string, enabled bool) error {
+	return p.agentRunner.SetToolEnabled(name, enabled)
+}
+
+// EstimateContextTokens implements ContextEstimator: the system prompt, tool
+// definitions and the prompt itself make up the first request of a run
+func (p *ChatPresenter) EstimateContextTokens(prompt string) int {
+	return textutils.EstimateTokenCount(p.systemPrompt) + p.toolDefinitionTokens() + textutils.EstimateTokenCount(prompt)
+}
+
+// toolDefinitionTokens estimates the tokens used by the tool definitions sent with each request
+func (p *ChatPresenter) toolDefinitionTokens() int {
+	if p.toolRegistry == nil {
+		return 0
+	}
+	tokens := 0
+	for _, tool := range p.toolRegistry.List() {
+		if !p.agentRunner.ToolEnabled(tool.Name()) {
+			continue // Not sent to the model
+		}
+		tokens += textutils.EstimateTokenCount(tool.Name() + tool.Description() + string(tool.Parameters()))
+	}
+	return tokens
+}
+
+// estimateUsage estimates the tokens consumed by a run from its message
+// history. Every assistant message is one LLM call whose prompt was the
+// conversation up to that point plus the tool definitions.
+func (p *ChatPresenter) estimateUsage(messages []orchestrator.Message) TokenUsage {
+	var usage TokenUsage
+	toolTokens := p.toolDefinitionTokens()
+	conversation := 0
+	for _, msg := range messages {
+		tokens := textutils.EstimateTokenCount(msg.Content)
+		if msg.ToolCall != nil {
+			tokens += textutils.EstimateTokenCount(msg.ToolCall.Name + string(msg.ToolCall.Arguments))
+		}
+		if msg.Role == "assistant" {
+			usage.InputTokens += conversation + toolTokens
+			usage.OutputTokens += tokens
+		}
+		conversation += tokens
+	}
+	usage.ContextTokens = conversation + toolTokens
+	if p.pricingKnown {
+		usage.CostUSD = p.pricing.Cost(usage.InputTokens, usage.OutputTokens)
+		usage.CostKnown = true
+	}
+	return usage
+}
+
+// runUsage returns the usage of a run: the one its provider reported when it
+// reported every request, as with prompt caching only it knows how much of
+// the input was cached, or else the one estimated from messages
+func (p *ChatPresenter) runUsage(messages []orchestrator.Message, reported llm.UsageSnapshot) TokenUsage {
+	usage := p.estimateUsage(messages)
+	if reported.Requests == 0 || reported.Reported < reported.Requests {
+		return usage
+	}
+	usage.InputTokens, usage.CachedInputTokens, usage.OutputTokens = reported.InputTokens, reported.CachedInputTokens, reported.OutputTokens
+	if p.pricingKnown {
+		usage.CostUSD = reported.Cost(p.pricing)
+	}
+	return usage
+}
+
+// Steer implements Steerer: the message is added to the running conversation
+// before the agent's next LLM call
+func (p *ChatPresenter) Steer(message string) error {
+	p.agentRunner.Steer(message)
+	return nil
+}
+
+// returnUnusedSteering hands messages that reached the agent after its last
+// step back to the TUI, ahead of the run's outcome, to send as the next prompt
+func (p *ChatPresenter) returnUnusedSteering(messages []string, turnID string) {
+	p.sendMessage(ChatMessage{
+		ID:        p.generateID(),
+		Type:      SystemMessage,
+		Sender:    "System",
+		Text:      fmt.Sprintf("↩ %d message(s) arrived after the agent's last step and were not seen by it.", len(messages)),
+		Timestamp: time.Now(),
+		Metadata: map[string]interface{}{
+			"turn_id":         turnID,
+			"unused_steering": messages,
+		},
+	})
+}
+
+// handleCancelledRun keeps the steps of a run the user cancelled so the next
+// prompt can correct course, and tells the TUI the run has stopped
+func (p *ChatPresenter) handleCancelledRun(result *orchestrator.RunResult, turnID string) {
+	toolCalls := 0
+	if result != nil {
+		p.interrupted = result.Messages
+		toolCalls = result.ToolCalls
+	}
+
+	p.sendMessage(ChatMessage{
+		ID:        p.generateID(),
+		Type:      SystemMessage,
+		Sender:    "System",
+		Text:      fmt.Sprintf("⏹ Run cancelled after %d tool call(s). Send a corrective instruction to continue from where it stopped.", toolCalls),
+		Timestamp: time.Now(),
+		Metadata: map[string]interface{}{
+			"turn_id":    turnID,
+			"cancelled":  true,
+			"tool_calls": toolCalls,
+		},
+	})
+}
+
+// maxCorrectionArgLength truncates tool arguments summarized in a correction prompt
+const maxCorrectionArgLength = 200
+
+// correctionPrompt continues a cancelled run: the original request and the
+// tool calls made before cancelling are summarized ahead of the user's new
+// instruction
+func correctionPrompt(interrupted []orchestrator.Message, instruction string) string {
+	var request string
+	var steps []string
+	for _, msg := range interrupted {
+		switch {
+		case msg.Role == "user" && request == "":
+			request = msg.Content
+		case msg.Role == "assistant" && msg.ToolCall != nil:
+			args := string(msg.ToolCall.Arguments)
+			if len(args) > maxCorrectionArgLength {
+				args = truncateUTF8(args, maxCorrectionArgLength) + "..."
+			}
+			steps = append(steps, fmt.Sprintf("- %s(%s)", msg.ToolCall.Name, args))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("The user cancelled your previous run before it finished.\n")
+	if request != "" {
+		sb.WriteString("\nOriginal request:\n" + request + "\n")
+	}
+	if len(steps) > 0 {
+		sb.WriteString("\nTool calls made before cancelling (their effects remain in the workspace):\n")
+		sb.WriteString(strings.Join(steps, "\n") + "\n")
+	}
+	sb.WriteString("\nCorrective instruction from the user:\n" + instruction)
+	return sb.String()
+}
+
+// pushCheckpoint keeps the checkpoint of a finished turn, dropping the oldest
+// beyond maxUndoTurns. The caller holds runMu.
+func (p *ChatPresenter) pushCheckpoint(checkpoint *agent.Checkpoint) {
+	p.checkpoints = append(p.checkpoints, checkpoint)
+	if len(p.checkpoints) > maxUndoTurns {
+		p.checkpoints = p.checkpoints[len(p.checkpoints)-maxUndoTurns:]
+	}
+}
+
+// Undo implements Undoer: the files changed in the most recent turn are
+// restored, and a cancelled run is no longer continued by the next prompt
+func (p *ChatPresenter) Undo() (UndoResult, error) {
+	if !p.runMu.TryLock() {
+		return UndoResult{}, errors.New("a run is in progress")
+	}
+	defer p.runMu.Unlock()
+
+	if len(p.checkpoints) == 0 {
+		return UndoResult{}, errors.New("nothing to undo")
+	}
+	checkpoint := p.checkpoints[len(p.checkpoints)-1]
+	files, err := checkpoint.Restore()
+	// The agent knows the restored files as they were before the turn
+	for _, file := range files {
+		if content, readErr := os.ReadFile(file); readErr == nil { // #nosec G304 - restored by the checkpoint
+			p.fileVersions.Record(file, content)
+		} else {
+			p.fileVersions.Forget(file)
+		}
+	}
+	if err != nil {
+		// The checkpoint keeps the files that failed, so undo can be retried
+		return UndoResult{TurnID: checkpoint.ID, Files: files}, fmt.Errorf("failed to restore some files: %w", err)
+	}
+	p.checkpoints = p.checkpoints[:len(p.checkpoints)-1]
+	p.interrupted = nil
+	return UndoResult{TurnID: checkpoint.ID, Files: files}, nil
+}
+
+// reportProgress forwards a tool progress update to the TUI. A failed tool
+// call is also shown as a tool result, so its error stays in the conversation.
+func (p *ChatPresenter) reportProgress(update agent.ProgressUpdate) {
+	p.sendMessage(ChatMessage{
+		ID:        p.generateID(),
+		Type:      ToolProgressMessage,
+		Sender:    update.ToolName,
+		Text:      update.Status,
+		Timestamp: time.Now(),
+		Metadata: map[string]interface{}{
+			"progress": update,
+		},
+	})
+	if update.Done && update.Error != nil {
+		p.sendMessage(ChatMessage{
+			ID:        p.generateID(),
+			Type:      ToolResultMessage,
+			Sender:    update.ToolName,
+			Text:      update.Error.Message,
+			Timestamp: time.Now(),
+			Metadata: map[string]interface{}{
+				"tool_name":    update.ToolName,
+				"tool_call_id": update.CallID,
+				"success":      false,
+				"error_code":   update.Error.Code,
+			},
+		})
+	}
+}
+
+// reportTasks forwards the task list of the agent to the TUI whenever it
+// changes
+func (p *ChatPresenter) reportTasks(tasks []agent.TaskItem) {
+	p.sendMessage(ChatMessage{
+		ID:        p.generateID(),
+		Type:      TaskListMessage,
+		Sender:    "Tasks",
+		Text:      agent.FormatChecklist(tasks),
+		Timestamp: time.Now(),
+		Metadata: map[string]interface{}{
+			"tasks": tasks,
+		},
+	})
+}
+
+// reportFileChange tells the TUI a tool is about to change path
+func (p *ChatPresenter) reportFileChange(path string) {
+	p.sendMessage(ChatMessage{
+		ID:        p.generateID(),
+		Type:      FileChangeMessage,
+		Sender:    "System",
+		Text:      path,
+		Timestamp: time.Now(),
+		Metadata: map[string]interface{}{
+			"path": path,
+		},
+	})
+}
+
+// reportPullProgress forwards the progress of pulling a missing model to the
+// TUI as the progress of a pseudo tool call
+func (p *ChatPresenter) reportPullProgress(progress llm.PullProgress) {
+	p.reportProgress(agent.ProgressUpdate{
+		ToolName: "pull_model",
+		CallID:   "pull_model:" + progress.Model,
+		Progress: progress.Fraction(),
+		Status:   fmt.Sprintf("%s: %s", progress.Model, progress.Status),
+		Done:     progress.Status == "success",
+	})
+}
+
 // Send implements MessageProvider.Send
 func (p *ChatPresenter) Send(ctx context.Context, prompt string) error {
 	// Start processing asynchronously
@@ -77,8 +451,44 @@ func (p *ChatPresenter) processPromptAsync(ctx context.Context, prompt string) {
 		Timestamp: time.Now(),
 	})
 
-	// Run the agent
-	result, err := p.agentRunner.Run(ctx, prompt)
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+
+	runPrompt := prompt
+	if p.interrupted != nil {
+		runPrompt = correctionPrompt(p.interrupted, prompt)
+		p.interrupted = nil
+	}
+	if p.adopted != nil {
+		runPrompt = continuationPrompt(p.adopted, runPrompt)
+		p.adopted = nil
+	}
+	planning := p.planMode.Load()
+	_, planRevision := p.planDraft.Plan()
+	if planning {
+		runPrompt = p.planningPrompt(runPrompt)
+		ctx = agent.WithPlanDraft(ctx, p.planDraft)
+	}
+
+	// Record the files changed in this turn so it can be undone, whatever the
+	// outcome of the run
+	checkpoint := agent.NewCheckpoint(turnID)
+	checkpoint.OnSnapshot(p.reportFileChange)
+	defer p.pushCheckpoint(checkpoint)
+
+	// Run the agent, streaming tool progress to the TUI
+	ctx = agent.WithCheckpoint(ctx, checkpoint)
+	ctx = agent.WithFileVersions(ctx, p.fileVersions)
+	ctx = agent.WithProgressReporter(ctx, agent.ProgressReporterFunc(p.reportProgress))
+	usageBefore := p.usage.Snapshot()
+	result, err := p.agentRunner.RunWithCommand(ctx, runPrompt, "chat")
+	if result != nil && len(result.UnusedSteering) > 0 {
+		p.returnUnusedSteering(result.UnusedSteering, turnID)
+	}
+	if errors.Is(ctx.Err(), context.Canceled) || (result != nil && result.Cancelled) {
+		p.handleCancelledRun(result, turnID)
+		return
+	}
 	if err != nil {
 		p.sendMessage(ChatMessage{
 			ID:        p.generateID(),
@@ -87,19 +497,24 @@ func (p *ChatPresenter) processPromptAsync(ctx context.Context, prompt string) {
 			Text:      fmt.Sprintf("Error: %v", err),
 			Timestamp: time.Now(),
 			Metadata: map[string]interface{}{
-				"turn_id": turnID,
-				"error":   err.Error(),
+				"turn_id":    turnID,
+				"error":      err.Error(),
+				"error_code": classifyRunError(err),
 			},
 		})
 		return
 	}
 
 	// Convert orchestrator result to chat messages
-	p.convertRunResultToMessages(result, turnID)
+	p.convertRunResultToMessages(result, turnID, p.usage.Snapshot().Sub(usageBefore))
+	if planning {
+		p.reportPlan(planRevision, turnID)
+	}
 }
 
 // convertRunResultToMessages converts an orchestrator.RunResult to ChatMessage(s)
-func (p *ChatPresenter) convertRunResultToMessages(result *orchestrator.RunResult, turnID string) {
+// with the usage the provider reported for the run
+func (p *ChatPresenter) convertRunResultToMessages(result *orchestrator.RunResult, turnID string, reported llm.UsageSnapshot) {
 	if !result.Success && result.Error != "" {
 		p.sendMessage(ChatMessage{
 			ID:        p.generateID(),
@@ -109,6 +524,7 @@ func (p *ChatPresenter) convertRunResultToMessages(result *orchestrator.RunResul
 			Timestamp: time.Now(),
 			Metadata: map[string]interface{}{
 				"turn_id":    turnID,
+				"error_code": classifyErrorText(result.Error),
 				"iterations": result.Iterations,
 				"tool_calls": result.ToolCalls,
 			},
@@ -147,6 +563,7 @@ func (p *ChatPresenter) convertRunResultToMessages(result *orchestrator.RunResul
 				"turn_id":    turnID,
 				"iterations": result.Iterations,
 				"tool_calls": result.ToolCalls,
+				"usage":      p.runUsage(result.Messages, reported),
 			},
 		})
 	}
@@ -0,0 +1,32 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/orchestrator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunUsage(t *testing.T) {
+	presenter := NewChatPresenter(context.Background(), &scriptedClient{}, agent.NewRegistry(), "system", "model")
+	pricing := llm.ModelPricing{InputPerMillion: 2.00, CachedInputPerMillion: 0.50, OutputPerMillion: 8.00}
+	presenter.SetPricing(pricing, true)
+	messages := []orchestrator.Message{
+		{Role: "user", Content: "Rename the handler"},
+		{Role: "assistant", Content: "Renamed it."},
+	}
+	estimated := presenter.estimateUsage(messages)
+
+	usage := presenter.runUsage(messages, llm.UsageSnapshot{Requests: 2, Reported: 2, InputTokens: 10000, CachedInputTokens: 8000, OutputTokens: 300})
+	assert.Equal(t, 10000, usage.InputTokens)
+	assert.Equal(t, 8000, usage.CachedInputTokens)
+	assert.Equal(t, 300, usage.OutputTokens)
+	assert.Equal(t, estimated.ContextTokens, usage.ContextTokens)
+	assert.InDelta(t, (2000*2.00+8000*0.50+300*8.00)/1_000_000, usage.CostUSD, 1e-12)
+
+	assert.Equal(t, estimated, presenter.runUsage(messages, llm.UsageSnapshot{Requests: 2, Reported: 1, InputTokens: 500}), "usage is estimated when a request was not reported")
+	assert.Equal(t, estimated, presenter.runUsage(messages, llm.UsageSnapshot{}))
+}
@@ -0,0 +1,250 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+)
+
+// maxPasteBytes caps how much clipboard content /paste attaches to a prompt
+const maxPasteBytes = 64 * 1024
+
+// ClipboardService abstracts system clipboard access (injectable for testing)
+type ClipboardService interface {
+	// Copy places text on the clipboard
+	Copy(text string) error
+	// Paste returns the current clipboard text
+	Paste() (string, error)
+}
+
+// SystemClipboard uses the platform clipboard and falls back to the OSC52
+// escape sequence, which lets the local terminal set its clipboard when the
+// TUI runs over SSH or no clipboard utility is installed.
+type SystemClipboard struct {
+	out         io.Writer // Terminal to write OSC52 sequences to
+	preferOSC52 bool
+}
+
+// NewSystemClipboard creates a clipboard that prefers OSC52 in SSH sessions
+func NewSystemClipboard() *SystemClipboard {
+	return &SystemClipboard{
+		out:         os.Stderr,
+		preferOSC52: isSSHSession(),
+	}
+}
+
+// Copy implements ClipboardService
+func (c *SystemClipboard) Copy(text string) error {
+	if !c.preferOSC52 && !clipboard.Unsupported {
+		if err := clipboard.WriteAll(text); err == nil {
+			return nil
+		}
+	}
+	if _, err := osc52Sequence(text).WriteTo(c.out); err != nil {
+		return fmt.Errorf("failed to write OSC52 sequence: %w", err)
+	}
+	return nil
+}
+
+// Paste implements ClipboardService. OSC52 reads are rarely permitted by
+// terminals, so pasting requires a local clipboard utility.
+func (c *SystemClipboard) Paste() (string, error) {
+	if clipboard.Unsupported {
+		return "", fmt.Errorf("no clipboard utility found (install xclip, xsel or wl-clipboard, or paste with your terminal)")
+	}
+	text, err := clipboard.ReadAll()
+	if err != nil {
+		if c.preferOSC52 {
+			return "", fmt.Errorf("clipboard is not readable over SSH; paste with your terminal instead: %w", err)
+		}
+		return "", fmt.Errorf("failed to read clipboard: %w", err)
+	}
+	return text, nil
+}
+
+// isSSHSession reports whether the process appears to run in an SSH session
+func isSSHSession() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_CLIENT") != ""
+}
+
+// osc52Sequence builds an OSC52 copy sequence, wrapped for tmux or screen when needed
+func osc52Sequence(text string) osc52.Sequence {
+	seq := osc52.New(text)
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		seq = seq.Screen()
+	}
+	return seq
+}
+
+// codeBlock is a fenced code block found in a message
+type codeBlock struct {
+	language string
+	code     string
+}
+
+// extractCodeBlocks returns the fenced code blocks in markdown text in order
+func extractCodeBlocks(text string) []codeBlock {
+	var blocks []codeBlock
+	var current *codeBlock
+	var body strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "```") {
+			if current != nil {
+				body.WriteString(line)
+				body.WriteString("\n")
+			}
+			continue
+		}
+		if current == nil {
+			current = &codeBlock{language: strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))}
+			body.Reset()
+			continue
+		}
+		current.code = body.String()
+		blocks = append(blocks, *current)
+		current = nil
+	}
+	return blocks
+}
+
+// sourceText returns the message text as received, before code highlighting
+func (cm chatMessage) sourceText() string {
+	if cm.rawText != "" {
+		return cm.rawText
+	}
+	return cm.text
+}
+
+// isAssistantReply reports whether the message is a completed assistant response
+func (cm chatMessage) isAssistantReply() bool {
+	return cm.sender == "Assistant" && !cm.placeholder && !cm.isToolCall && !cm.isToolResult
+}
+
+// lastAssistantMessage returns the most recent completed assistant response
+func lastAssistantMessage(messages []chatMessage) (string, bool) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].isAssistantReply() {
+			return messages[i].sourceText(), true
+		}
+	}
+	return "", false
+}
+
+// assistantCodeBlocks returns the code blocks of all assistant responses, oldest first
+func assistantCodeBlocks(messages []chatMessage) []codeBlock {
+	var blocks []codeBlock
+	for _, msg := range messages {
+		if msg.isAssistantReply() {
+			blocks = append(blocks, extractCodeBlocks(msg.sourceText())...)
+		}
+	}
+	return blocks
+}
+
+// lastDiff returns the most recent diff shown in the conversation: a diff code
+// block in an assistant response or a diff embedded in a tool result
+func lastDiff(messages []chatMessage) (string, bool) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		switch {
+		case msg.isAssistantReply():
+			blocks := extractCodeBlocks(msg.sourceText())
+			for j := len(blocks) - 1; j >= 0; j-- {
+				if blocks[j].language == "diff" || blocks[j].language == "patch" || looksLikeDiff(blocks[j].code) {
+					return blocks[j].code, true
+				}
+			}
+		case msg.isToolResult:
+			if diff, ok := diffInToolResult(msg.text); ok {
+				return diff, true
+			}
+		}
+	}
+	return "", false
+}
+
+// diffInToolResult finds a diff in a tool result, which is usually JSON with the
+// diff in one of its string fields
+func diffInToolResult(text string) (string, bool) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(text), &data); err != nil {
+		if looksLikeDiff(text) {
+			return text, true
+		}
+		return "", false
+	}
+
+	var diffs []string
+	collectDiffStrings(data, &diffs)
+	if len(diffs) == 0 {
+		return "", false
+	}
+	return strings.Join(diffs, "\n"), true
+}
+
+// collectDiffStrings appends the diff-like string values found in data
+func collectDiffStrings(data interface{}, diffs *[]string) {
+	switch v := data.(type) {
+	case string:
+		if looksLikeDiff(v) {
+			*diffs = append(*diffs, strings.TrimRight(v, "\n"))
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectDiffStrings(item, diffs)
+		}
+	case map[string]interface{}:
+		// Map iteration order is random; prefer well-known keys for a stable result
+		for _, key := range []string{"diff", "patch", "hunks"} {
+			if s, ok := v[key].(string); ok && looksLikeDiff(s) {
+				*diffs = append(*diffs, strings.TrimRight(s, "\n"))
+				return
+			}
+		}
+		for _, item := range v {
+			collectDiffStrings(item, diffs)
+		}
+	}
+}
+
+// looksLikeDiff reports whether text contains unified diff markers
+func looksLikeDiff(text string) bool {
+	hasOld, hasNew := false, false
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "), strings.HasPrefix(line, "@@ -"):
+			return true
+		case strings.HasPrefix(line, "--- "):
+			hasOld = true
+		case strings.HasPrefix(line, "+++ "):
+			hasNew = true
+		}
+	}
+	return hasOld && hasNew
+}
+
+// withPastedContext appends clipboard content to a prompt as a fenced block
+func withPastedContext(prompt, pasted string) string {
+	if pasted == "" {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n\nContext pasted from the clipboard:\n```\n%s\n```", prompt, strings.TrimRight(pasted, "\n"))
+}
+
+// lineCount returns the number of lines in text
+func lineCount(text string) int {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return 0
+	}
+	return strings.Count(text, "\n") + 1
+}
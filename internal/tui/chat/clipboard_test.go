@@ -0,0 +1,172 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClipboard records copies and returns a fixed paste value
+type fakeClipboard struct {
+	copied   []string
+	pasteVal string
+	pasteErr error
+}
+
+func (f *fakeClipboard) Copy(text string) error {
+	f.copied = append(f.copied, text)
+	return nil
+}
+
+func (f *fakeClipboard) Paste() (string, error) {
+	return f.pasteVal, f.pasteErr
+}
+
+func (f *fakeClipboard) last() string {
+	if len(f.copied) == 0 {
+		return ""
+	}
+	return f.copied[len(f.copied)-1]
+}
+
+func newClipboardTestModel(t *testing.T, cb ClipboardService) (Model, *MockMessageProvider) {
+	t.Helper()
+	provider := NewMockMessageProvider()
+	t.Cleanup(func() { provider.Close() })
+	model := NewChatModel(
+		WithParentContext(context.Background()),
+		WithMessageProvider(provider),
+		WithClipboard(cb),
+	)
+	return model, provider
+}
+
+const sampleReply = "Here you go:\n```go\nfunc a() {}\n```\nand a patch:\n```diff\n--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-old\n+new\n```\n"
+
+func TestExtractCodeBlocks(t *testing.T) {
+	blocks := extractCodeBlocks(sampleReply)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "go", blocks[0].language)
+	assert.Equal(t, "func a() {}\n", blocks[0].code)
+	assert.Equal(t, "diff", blocks[1].language)
+
+	assert.Empty(t, extractCodeBlocks("no code here"))
+	assert.Empty(t, extractCodeBlocks("```go\nunterminated"))
+}
+
+func TestLastDiff(t *testing.T) {
+	t.Run("from assistant code block", func(t *testing.T) {
+		diff, ok := lastDiff([]chatMessage{{sender: "Assistant", text: sampleReply, isMarkdown: true}})
+		require.True(t, ok)
+		assert.True(t, strings.HasPrefix(diff, "--- a/x.go"))
+	})
+
+	t.Run("from tool result JSON", func(t *testing.T) {
+		result := `{"changes":[{"file_path":"a.go","hunks":"@@ -3,1 +3,1 @@\n-foo\n+bar\n"}],"dry_run":true}`
+		diff, ok := lastDiff([]chatMessage{
+			{sender: "Assistant", text: sampleReply},
+			{sender: "System", text: result, isToolResult: true},
+		})
+		require.True(t, ok)
+		assert.Equal(t, "@@ -3,1 +3,1 @@\n-foo\n+bar", diff)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		_, ok := lastDiff([]chatMessage{{sender: "Assistant", text: "```go\nx := 1\n```"}})
+		assert.False(t, ok)
+	})
+}
+
+func TestLooksLikeDiff(t *testing.T) {
+	assert.True(t, looksLikeDiff("diff --git a/x b/x\n"))
+	assert.True(t, looksLikeDiff("--- a\n+++ b\n"))
+	assert.True(t, looksLikeDiff("@@ -1,2 +1,2 @@\n"))
+	assert.False(t, looksLikeDiff("--- just a separator\n"))
+	assert.False(t, looksLikeDiff("plain text"))
+}
+
+func TestClipboardKeybindings(t *testing.T) {
+	cb := &fakeClipboard{}
+	model, _ := newClipboardTestModel(t, cb)
+	model.messageList.AddMessage(chatMessage{sender: "Assistant", text: sampleReply, isMarkdown: true, timestamp: time.Now()})
+
+	press := func(key string) {
+		updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		model = updated.(Model)
+	}
+	pressType := func(keyType tea.KeyType) {
+		updated, _ := model.Update(tea.KeyMsg{Type: keyType})
+		model = updated.(Model)
+	}
+
+	pressType(tea.KeyCtrlY)
+	assert.Equal(t, sampleReply, cb.last(), "the raw markdown should be copied, not the highlighted text")
+
+	pressType(tea.KeyCtrlG)
+	assert.True(t, strings.HasPrefix(cb.last(), "--- a/x.go"), "first press copies the newest code block")
+	pressType(tea.KeyCtrlG)
+	assert.Equal(t, "func a() {}\n", cb.last(), "second press steps back to the previous block")
+
+	pressType(tea.KeyCtrlX)
+	assert.Contains(t, cb.last(), "+new")
+
+	press("q")
+	assert.Len(t, cb.copied, 4, "ordinary keys must not copy")
+}
+
+func TestPasteCommand(t *testing.T) {
+	t.Run("attaches to next prompt", func(t *testing.T) {
+		cb := &fakeClipboard{pasteVal: "line one\nline two\n"}
+		model, provider := newClipboardTestModel(t, cb)
+
+		model.inputArea.SetValue("/paste")
+		updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		model = updated.(Model)
+		assert.Equal(t, "line one\nline two\n", model.pastedContext)
+		assert.Empty(t, provider.GetSentMessages(), "/paste alone must not send anything")
+
+		model.inputArea.SetValue("explain this")
+		updated, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		model = updated.(Model)
+		assert.Empty(t, model.pastedContext)
+
+		sent := provider.GetSentMessages()
+		require.Len(t, sent, 1)
+		assert.Equal(t, "explain this\n\nContext pasted from the clipboard:\n```\nline one\nline two\n```", sent[0])
+	})
+
+	t.Run("with prompt sends immediately", func(t *testing.T) {
+		cb := &fakeClipboard{pasteVal: "panic: nil map"}
+		model, provider := newClipboardTestModel(t, cb)
+
+		model.inputArea.SetValue("/paste why does this fail?")
+		model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+		sent := provider.GetSentMessages()
+		require.Len(t, sent, 1)
+		assert.True(t, strings.HasPrefix(sent[0], "why does this fail?"))
+		assert.Contains(t, sent[0], "panic: nil map")
+	})
+
+	t.Run("clipboard error", func(t *testing.T) {
+		cb := &fakeClipboard{pasteErr: errors.New("no clipboard")}
+		model, provider := newClipboardTestModel(t, cb)
+
+		model.inputArea.SetValue("/paste")
+		updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		model = updated.(Model)
+		assert.Empty(t, model.pastedContext)
+		assert.Empty(t, provider.GetSentMessages())
+	})
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "abc", truncateUTF8("abc", 10))
+	assert.Equal(t, "a", truncateUTF8("aé", 2), "must not split a multi-byte rune")
+}
@@ -0,0 +1,357 @@
+package chat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/patchutils"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sourcegraph/go-diff/diff"
+)
+
+// maxBlockPreviewLines bounds the lines of the selected block shown in the picker
+const maxBlockPreviewLines = 12
+
+// pathCommentPattern matches a first line naming the file of a code block, as
+// in "// cmd/root.go", "# file: setup.py" or "<!-- index.html -->"
+var pathCommentPattern = regexp.MustCompile(`^\s*(?://|#|--|/\*|<!--)\s*(?:(?:file|filename|path):\s*)?([\w./-]+\.\w+)\s*(?:\*/|-->)?\s*$`)
+
+// pickedBlock is a code block of the assistant's responses offered by the
+// block picker
+type pickedBlock struct {
+	codeBlock
+	number int    // 1-based, oldest first, as numbered by the copy key
+	path   string // Suggested file to save it to, "" when none
+	isDiff bool
+}
+
+// blockPicker lists the code blocks of the assistant's responses, newest
+// first, in place of the message list, with actions on the selected one
+type blockPicker struct {
+	blocks   []pickedBlock
+	selected int
+	confirm  string // Path the next save overwrites, after a first save was refused
+}
+
+// pickableBlocks returns the code blocks of the assistant's responses with
+// the file each suggests, newest first
+func pickableBlocks(messages []chatMessage) []pickedBlock {
+	blocks := assistantCodeBlocks(messages)
+	picked := make([]pickedBlock, 0, len(blocks))
+	for i := len(blocks) - 1; i >= 0; i-- {
+		block := blocks[i]
+		language := blockLanguage(block.language)
+		pick := pickedBlock{
+			codeBlock: block,
+			number:    i + 1,
+			isDiff:    language == "diff" || language == "patch" || looksLikeDiff(block.code),
+		}
+		if !pick.isDiff {
+			pick.path = suggestedBlockPath(block)
+		}
+		picked = append(picked, pick)
+	}
+	return picked
+}
+
+// blockLanguage returns the language of a fence info string such as
+// "go title=main.go" or "go:cmd/main.go"
+func blockLanguage(info string) string {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return ""
+	}
+	language, _, _ := strings.Cut(fields[0], ":")
+	return strings.ToLower(language)
+}
+
+// suggestedBlockPath returns the file a code block names, in its fence info
+// string ("go:cmd/main.go", "go title=cmd/main.go", "cmd/main.go") or in a
+// comment on its first line
+func suggestedBlockPath(block codeBlock) string {
+	fields := strings.Fields(block.language)
+	if len(fields) > 0 {
+		if _, path, ok := strings.Cut(fields[0], ":"); ok && path != "" {
+			return path
+		}
+		if strings.Contains(fields[0], "/") || strings.Contains(fields[0], ".") {
+			return fields[0]
+		}
+	}
+	for _, field := range fields[min(1, len(fields)):] {
+		for _, key := range []string{"title=", "file=", "filename=", "path="} {
+			if value, ok := strings.CutPrefix(field, key); ok {
+				return strings.Trim(value, `"'`)
+			}
+		}
+	}
+	first, _, _ := strings.Cut(block.code, "\n")
+	if match := pathCommentPattern.FindStringSubmatch(first); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// blocksCommand opens the block picker, or acts on a block by its number:
+// "/blocks copy 2", "/blocks apply 3", "/blocks save 2 <path> [--force]"
+func (m *Model) blocksCommand(args string) tea.Cmd {
+	blocks := pickableBlocks(m.messageList.GetMessages())
+	if len(blocks) == 0 {
+		m.addSystemNotice("📋 No code blocks in the assistant's responses yet")
+		return nil
+	}
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		m.blockPicker = &blockPicker{blocks: blocks}
+		return nil
+	}
+
+	usage := "Usage: /blocks [copy <n> | apply <n> | save <n> [path] [--force]]"
+	if len(fields) < 2 {
+		m.addSystemNotice(usage)
+		return nil
+	}
+	number, err := strconv.Atoi(fields[1])
+	if err != nil || number < 1 || number > len(blocks) {
+		m.addSystemNotice(fmt.Sprintf("There is no code block %s; the blocks are numbered 1 to %d", fields[1], len(blocks)))
+		return nil
+	}
+	block := blocks[len(blocks)-number]
+	switch fields[0] {
+	case "copy":
+		m.copyPickedBlock(block)
+		return nil
+	case "apply":
+		return m.applyPickedBlock(block)
+	case "save":
+		path, force := block.path, false
+		for _, field := range fields[2:] {
+			if field == "--force" {
+				force = true
+			} else {
+				path = field
+			}
+		}
+		_, cmd := m.savePickedBlock(block, path, force)
+		return cmd
+	}
+	m.addSystemNotice(usage)
+	return nil
+}
+
+// blockPickerKey handles a key while the block picker is open
+func (m *Model) blockPickerKey(msg tea.KeyMsg) tea.Cmd {
+	picker := m.blockPicker
+	block := picker.blocks[picker.selected]
+	key := msg.String()
+	if key != "s" {
+		picker.confirm = ""
+	}
+	switch key {
+	case "esc", "q":
+		m.blockPicker = nil
+	case "up", "k":
+		picker.selected = max(0, picker.selected-1)
+	case "down", "j":
+		picker.selected = min(len(picker.blocks)-1, picker.selected+1)
+	case "c", "y", "enter":
+		m.copyPickedBlock(block)
+		m.blockPicker = nil
+	case "a":
+		if !block.isDiff {
+			m.addSystemNotice(fmt.Sprintf("Code block %d is not a diff; save it to a file instead", block.number))
+			return nil
+		}
+		m.blockPicker = nil
+		return m.applyPickedBlock(block)
+	case "s":
+		if block.path == "" {
+			// Ask for the file in the input area
+			m.blockPicker = nil
+			m.inputArea.SetValue(fmt.Sprintf("/blocks save %d ", block.number))
+			m.inputArea.CursorEnd()
+			return nil
+		}
+		refused, cmd := m.savePickedBlock(block, block.path, picker.confirm == block.path)
+		if refused {
+			picker.confirm = block.path
+			return nil
+		}
+		m.blockPicker = nil
+		return cmd
+	}
+	return nil
+}
+
+// copyPickedBlock copies a code block to the clipboard
+func (m *Model) copyPickedBlock(block pickedBlock) {
+	what := fmt.Sprintf("code block %d", block.number)
+	if language := blockLanguage(block.language); language != "" {
+		what += " [" + language + "]"
+	}
+	m.copyToClipboard(block.code, what)
+}
+
+// savePickedBlock writes a code block to a file of the workspace. An existing
+// file with other content is only overwritten when force is set; otherwise
+// refused reports that the save is to be confirmed.
+func (m *Model) savePickedBlock(block pickedBlock, path string, force bool) (refused bool, cmd tea.Cmd) {
+	if path == "" {
+		m.addSystemNotice(fmt.Sprintf("Code block %d names no file; use /blocks save %d <path>", block.number, block.number))
+		return false, nil
+	}
+	abs, err := m.workspacePath(path)
+	if err != nil {
+		m.addSystemNotice(fmt.Sprintf("💾 Cannot save code block %d: %v", block.number, err))
+		return false, nil
+	}
+	if existing, err := os.ReadFile(abs); err == nil && string(existing) != block.code && !force {
+		m.addSystemNotice(fmt.Sprintf("💾 %s exists; press s again, or add --force to /blocks save, to overwrite it", m.displayPath(abs)))
+		return true, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		m.addSystemNotice(fmt.Sprintf("💾 Cannot save code block %d: %v", block.number, err))
+		return false, nil
+	}
+	if err := os.WriteFile(abs, []byte(block.code), 0o644); err != nil { // #nosec G306 - a source file of the workspace
+		m.addSystemNotice(fmt.Sprintf("💾 Cannot save code block %d: %v", block.number, err))
+		return false, nil
+	}
+	m.addTouchedFile(abs)
+	m.addSystemNotice(fmt.Sprintf("💾 Saved code block %d to %s (%d line(s))", block.number, m.displayPath(abs), lineCount(block.code)))
+	return false, m.refreshWorkspaceStatus()
+}
+
+// applyPickedBlock applies a diff code block to the files it names. Every
+// file is checked first, so a diff that does not apply changes nothing.
+func (m *Model) applyPickedBlock(block pickedBlock) tea.Cmd {
+	files, err := m.applyDiff(block.code)
+	if err != nil {
+		m.addSystemNotice(fmt.Sprintf("🩹 Cannot apply code block %d: %v", block.number, err))
+		return nil
+	}
+	m.addSystemNotice(fmt.Sprintf("🩹 Applied code block %d to %s", block.number, strings.Join(files, ", ")))
+	return m.refreshWorkspaceStatus()
+}
+
+// applyDiff applies a unified diff to the workspace and returns the files it
+// changed, relative to the workspace
+func (m *Model) applyDiff(text string) ([]string, error) {
+	root, err := m.workspacePath(".")
+	if err != nil {
+		return nil, err
+	}
+	fileDiffs, err := diff.ParseMultiFileDiff([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("not a valid diff: %w", err)
+	}
+	var files []string
+	for _, fileDiff := range fileDiffs {
+		if fileDiff.OrigName == "/dev/null" || fileDiff.NewName == "/dev/null" {
+			return nil, fmt.Errorf("it creates or deletes files, which only changes to existing files can")
+		}
+		name := fileDiff.NewName
+		if strings.HasPrefix(name, "b/") && strings.HasPrefix(fileDiff.OrigName, "a/") {
+			name = strings.TrimPrefix(name, "b/")
+		}
+		if _, err := m.workspacePath(name); err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("it names no files")
+	}
+
+	check := patchutils.NewPatchApplier(root, patchutils.ApplyOptions{DryRun: true})
+	for _, file := range files {
+		if _, err := check.ApplyPatch(file, text); err != nil {
+			return nil, err
+		}
+	}
+	applier := patchutils.NewPatchApplier(root, patchutils.ApplyOptions{})
+	for _, file := range files {
+		if _, err := applier.ApplyPatch(file, text); err != nil {
+			return nil, err
+		}
+		m.addTouchedFile(filepath.Join(root, file))
+	}
+	return files, nil
+}
+
+// workspacePath returns the absolute path of path in the workspace, which
+// need not exist. Relative paths are taken from the workspace root; paths
+// outside it are refused.
+func (m Model) workspacePath(path string) (string, error) {
+	root := m.workspaceRoot
+	if root == "" {
+		root = "."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+	if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the workspace", path)
+	}
+	return path, nil
+}
+
+// blockPickerView renders the picker: a line per block, newest first, the
+// start of the selected block and the keys it takes
+func (m Model) blockPickerView() string {
+	picker := m.blockPicker
+	width, height := m.messageList.OverlaySize()
+	out := []string{m.theme.Sender.Render("Code blocks, newest first")}
+
+	listHeight := max(1, min(len(picker.blocks), height/3))
+	start := max(0, min(picker.selected-listHeight/2, len(picker.blocks)-listHeight))
+	for i := start; i < min(len(picker.blocks), start+listHeight); i++ {
+		block := picker.blocks[i]
+		line := fmt.Sprintf("#%d", block.number)
+		if language := blockLanguage(block.language); language != "" {
+			line += " " + language
+		}
+		switch {
+		case block.isDiff:
+			line += "  diff"
+		case block.path != "":
+			line += "  -> " + block.path
+		}
+		line += fmt.Sprintf("  (%d line(s))", lineCount(block.code))
+		if i == picker.selected {
+			out = append(out, m.theme.Sender.Render(truncateLine("> "+line, width)))
+		} else {
+			out = append(out, truncateLine("  "+line, width))
+		}
+	}
+
+	out = append(out, "")
+	preview := strings.Split(strings.TrimRight(picker.blocks[picker.selected].code, "\n"), "\n")
+	room := max(1, min(maxBlockPreviewLines, height-len(out)-2))
+	if len(preview) > room {
+		preview = append(preview[:room-1], fmt.Sprintf("... %d more line(s)", len(preview)-room+1))
+	}
+	for _, line := range preview {
+		out = append(out, m.theme.Time.Render(truncateLine("  "+strings.ReplaceAll(line, "\t", "    "), width)))
+	}
+
+	help := "up/down select, c copy, s save to file"
+	if picker.blocks[picker.selected].isDiff {
+		help += ", a apply diff"
+	}
+	help += ", esc close"
+	for len(out) < height-1 {
+		out = append(out, "")
+	}
+	out = append(out, m.theme.Time.Render(truncateLine(help, width)))
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,115 @@
+package chat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestedBlockPath(t *testing.T) {
+	for _, tt := range []struct {
+		info, code, path string
+	}{
+		{info: "go:cmd/main.go", code: "package main\n", path: "cmd/main.go"},
+		{info: `go title="cmd/main.go"`, code: "package main\n", path: "cmd/main.go"},
+		{info: "cmd/main.go", code: "package main\n", path: "cmd/main.go"},
+		{info: "go", code: "// internal/util/strings.go\npackage util\n", path: "internal/util/strings.go"},
+		{info: "python", code: "# file: setup.py\nimport setuptools\n", path: "setup.py"},
+		{info: "go", code: "// Package util has helpers\npackage util\n", path: ""},
+		{info: "", code: "echo hi\n", path: ""},
+	} {
+		assert.Equal(t, tt.path, suggestedBlockPath(codeBlock{language: tt.info, code: tt.code}), "%q %q", tt.info, tt.code)
+	}
+}
+
+func TestBlockPicker(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "x.go"), []byte("package x\n\nvar v = old\n"), 0o644))
+	cb := &fakeClipboard{}
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider), WithClipboard(cb), WithWorkspaceRoot(root))
+	update := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	press := func(keys ...string) {
+		for _, key := range keys {
+			switch key {
+			case "ctrl+l":
+				update(tea.KeyMsg{Type: tea.KeyCtrlL})
+			case "down":
+				update(tea.KeyMsg{Type: tea.KeyDown})
+			case "esc":
+				update(tea.KeyMsg{Type: tea.KeyEsc})
+			default:
+				update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+			}
+		}
+	}
+	lastNotice := func() string {
+		messages := model.messageList.GetMessages()
+		return messages[len(messages)-1].text
+	}
+	update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	model.messageList.AddMessage(chatMessage{sender: "Assistant", text: "Add a helper:\n```go:util/util.go\npackage util\n```\n" +
+		"and change x.go:\n```diff\n--- a/x.go\n+++ b/x.go\n@@ -1,3 +1,3 @@\n package x\n \n-var v = old\n+var v = new\n```\n" +
+		"then run:\n```sh\ngo test ./...\n```\n"})
+
+	press("ctrl+l")
+	require.NotNil(t, model.blockPicker)
+	view := model.View()
+	assert.Contains(t, view, "> #3 sh", "the newest block is selected")
+	assert.Contains(t, view, "#2 diff  diff")
+	assert.Contains(t, view, "#1 go  -> util/util.go")
+
+	press("c")
+	assert.Nil(t, model.blockPicker)
+	assert.Equal(t, "go test ./...\n", cb.last())
+
+	press("ctrl+l", "a")
+	assert.Contains(t, lastNotice(), "not a diff")
+	press("down", "a")
+	assert.Nil(t, model.blockPicker)
+	assert.Contains(t, lastNotice(), "Applied code block 2 to x.go")
+	content, err := os.ReadFile(filepath.Join(root, "x.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package x\n\nvar v = new\n", string(content))
+	assert.Contains(t, model.touchedFiles, filepath.Join(root, "x.go"))
+
+	model.blocksCommand("apply 2")
+	assert.Contains(t, lastNotice(), "Cannot apply code block 2", "a diff that no longer applies changes nothing")
+	content, _ = os.ReadFile(filepath.Join(root, "x.go"))
+	assert.Equal(t, "package x\n\nvar v = new\n", string(content))
+
+	press("ctrl+l", "down", "down", "s")
+	assert.Nil(t, model.blockPicker)
+	assert.Contains(t, lastNotice(), "Saved code block 1 to util/util.go")
+	content, err = os.ReadFile(filepath.Join(root, "util", "util.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package util\n", string(content))
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "util", "util.go"), []byte("package util // edited\n"), 0o644))
+	press("ctrl+l", "down", "down", "s")
+	require.NotNil(t, model.blockPicker, "an edited file is not overwritten at once")
+	assert.Contains(t, lastNotice(), "util/util.go exists")
+	press("s")
+	assert.Nil(t, model.blockPicker)
+	content, _ = os.ReadFile(filepath.Join(root, "util", "util.go"))
+	assert.Equal(t, "package util\n", string(content), "a second press overwrites it")
+
+	press("ctrl+l", "s")
+	assert.Nil(t, model.blockPicker)
+	assert.Equal(t, "/blocks save 3 ", model.inputArea.GetValue(), "a block naming no file asks for one")
+	model.blocksCommand("save 3 scripts/test.sh")
+	assert.FileExists(t, filepath.Join(root, "scripts", "test.sh"))
+	model.blocksCommand("save 3 ../outside.sh")
+	assert.Contains(t, lastNotice(), "outside the workspace")
+	model.blocksCommand("copy 9")
+	assert.Contains(t, lastNotice(), "numbered 1 to 3")
+}
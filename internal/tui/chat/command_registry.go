@@ -0,0 +1,206 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/logger"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SlashCommand is a command typed in the chat input as /name. Commands are
+// handled in the TUI and never sent to the LLM.
+type SlashCommand struct {
+	Name  string // With the slash, e.g. "/model"
+	Usage string // Arguments shown by /help, e.g. "[name]"; empty when none
+	Help  string // One line description shown by /help
+
+	// Complete returns candidate values for the arguments typed so far,
+	// offered as suggestions; nil when the command completes nothing
+	Complete func(args string) []string
+
+	// Run handles the command; args is the text after the name, trimmed
+	Run func(c *CommandContext, args string) tea.Cmd
+}
+
+// CommandContext is what a slash command can do with the chat it runs in
+type CommandContext struct {
+	m *Model
+}
+
+// Context returns the context of the chat session
+func (c *CommandContext) Context() context.Context {
+	return c.m.parentCtx
+}
+
+// Config returns the app configuration; nil when the chat has none
+func (c *CommandContext) Config() *config.AppConfig {
+	return c.m.cfg
+}
+
+// Provider returns the message provider, whose optional interfaces give
+// access to the agent behind the chat
+func (c *CommandContext) Provider() MessageProvider {
+	return c.m.messageProvider
+}
+
+// Busy reports whether an agent run is in progress
+func (c *CommandContext) Busy() bool {
+	return c.m.loading
+}
+
+// Notice shows a system message in the conversation
+func (c *CommandContext) Notice(text string) {
+	c.m.addSystemNotice(text)
+}
+
+// Send sends prompt to the agent, or queues it while a run is in progress
+func (c *CommandContext) Send(prompt string) tea.Cmd {
+	return c.m.sendOrQueue(prompt)
+}
+
+// SetInput replaces the text of the input area
+func (c *CommandContext) SetInput(text string) {
+	c.m.inputArea.SetValue(text)
+	c.m.inputArea.CursorEnd()
+}
+
+// ModelName returns the active model, or "unknown"
+func (c *CommandContext) ModelName() string {
+	if c.m.modelName == "" {
+		return "unknown"
+	}
+	return c.m.modelName
+}
+
+// SetModelName changes the model shown in the header and status bar
+func (c *CommandContext) SetModelName(name string) {
+	c.m.setModelName(name)
+}
+
+// SetPlanMode shows in the status bar whether the agent is in plan mode
+func (c *CommandContext) SetPlanMode(enabled bool) {
+	c.m.statusBar.SetPlanMode(enabled)
+}
+
+// CommandRegistry holds the slash commands of a chat. Packages outside the TUI
+// add their own with Register and pass the registry with WithCommands.
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	commands map[string]SlashCommand
+}
+
+// NewCommandRegistry returns a registry holding the built-in commands
+func NewCommandRegistry() *CommandRegistry {
+	r := &CommandRegistry{commands: make(map[string]SlashCommand)}
+	for _, cmd := range builtinCommands() {
+		r.commands[cmd.Name] = cmd
+	}
+	return r
+}
+
+// Register adds cmd, replacing a command of the same name
+func (r *CommandRegistry) Register(cmd SlashCommand) error {
+	if !strings.HasPrefix(cmd.Name, "/") || len(cmd.Name) < 2 || strings.ContainsAny(cmd.Name, " \t\n") {
+		return fmt.Errorf("invalid slash command name %q: want \"/name\" without spaces", cmd.Name)
+	}
+	if cmd.Run == nil {
+		return fmt.Errorf("slash command %s has no handler", cmd.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[cmd.Name] = cmd
+	return nil
+}
+
+// Lookup returns the command called name
+func (r *CommandRegistry) Lookup(name string) (SlashCommand, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.commands[name]
+	return cmd, ok
+}
+
+// Commands returns the registered commands sorted by name
+func (r *CommandRegistry) Commands() []SlashCommand {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	commands := make([]SlashCommand, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		commands = append(commands, cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// suggestions returns the completions of a partly typed command: matching
+// command names while the name is typed, then the values its completer
+// offers for the arguments
+func (r *CommandRegistry) suggestions(input string) []string {
+	name, args, typingArgs := strings.Cut(input, " ")
+	if !typingArgs {
+		var names []string
+		for _, cmd := range r.Commands() {
+			if strings.HasPrefix(cmd.Name, name) {
+				if cmd.Usage != "" {
+					names = append(names, cmd.Name+" ")
+				} else {
+					names = append(names, cmd.Name)
+				}
+			}
+		}
+		return names
+	}
+
+	cmd, ok := r.Lookup(name)
+	if !ok || cmd.Complete == nil {
+		return nil
+	}
+	var values []string
+	for _, value := range cmd.Complete(args) {
+		if strings.HasPrefix(value, args) && value != args {
+			values = append(values, name+" "+value)
+		}
+	}
+	return values
+}
+
+// builtin adapts a Model method to a SlashCommand handler
+func builtin(handler func(m *Model, args string) tea.Cmd) func(c *CommandContext, args string) tea.Cmd {
+	return func(c *CommandContext, args string) tea.Cmd {
+		return handler(c.m, args)
+	}
+}
+
+// completeFrom returns a completer offering fixed values
+func completeFrom(values ...string) func(args string) []string {
+	return func(args string) []string {
+		return values
+	}
+}
+
+// builtinCommands are the commands every chat has
+func builtinCommands() []SlashCommand {
+	return []SlashCommand{
+		{Name: "/help", Help: "List the slash commands", Run: builtin((*Model).helpCommand)},
+		{Name: "/clear", Help: "Clear the conversation from the screen", Run: builtin((*Model).clearCommand)},
+		{Name: "/status", Help: "Show the model, run state and session statistics", Run: builtin((*Model).statusCommand)},
+		{Name: "/paste", Usage: "[prompt]", Help: "Attach the clipboard to the next message, or send it with prompt", Run: builtin((*Model).pasteCommand)},
+		{Name: "/open", Usage: "[file[:line]]", Help: "View a workspace file, by default the one last referenced, and attach lines of it", Run: builtin((*Model).openCommand)},
+		{Name: "/blocks", Usage: "[copy|apply|save <n> [path]]", Help: "Pick a code block of the responses to copy, save to a file or apply as a diff", Complete: completeFrom("copy", "apply", "save"), Run: builtin((*Model).blocksCommand)},
+		{Name: "/compare", Usage: "<model1> <model2> <prompt>", Help: "Answer a prompt with two models side by side and continue with the one you pick", Run: builtin((*Model).compareCommand)},
+		{Name: "/branch", Usage: "[<name> [turn] | switch <name> | merge [name]]", Help: "Branch the conversation, switch between branches or merge one back into its parent", Complete: completeFrom("switch", "merge"), Run: builtin((*Model).branchCommand)},
+		{Name: "/keys", Help: "Show the active key bindings", Run: builtin((*Model).keysCommand)},
+		{Name: "/steer", Usage: "<message>", Help: "Pass a message to the running agent", Run: builtin((*Model).steerCommand)},
+		{Name: "/undo", Help: "Revert the file changes of the last turn", Run: builtin((*Model).undoCommand)},
+		{Name: "/debug", Usage: "llm [on|off]", Help: "Log LLM HTTP traffic to a file", Complete: completeFrom("llm", "llm on", "llm off"), Run: builtin((*Model).debugCommand)},
+		{Name: "/log", Usage: "[<subsystem> <level>]", Help: "Show or change the log level of llm, orchestrator, tools, tui or context", Complete: completeFrom(logger.Subsystems...), Run: builtin((*Model).logCommand)},
+		{Name: "/errors", Help: "Summarize the errors of this session", Run: builtin((*Model).errorsCommand)},
+		{Name: "/snippets", Help: "List the #name prompt snippets", Run: builtin((*Model).snippetsCommand)},
+		{Name: "/quit", Help: "Save the history and leave the chat", Run: builtin((*Model).quitCommand)},
+	}
+}
@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandRegistryRegister(t *testing.T) {
+	registry := NewCommandRegistry()
+	run := func(c *CommandContext, args string) tea.Cmd { return nil }
+
+	assert.Error(t, registry.Register(SlashCommand{Name: "theme", Run: run}), "the slash is required")
+	assert.Error(t, registry.Register(SlashCommand{Name: "/", Run: run}))
+	assert.Error(t, registry.Register(SlashCommand{Name: "/two words", Run: run}))
+	assert.Error(t, registry.Register(SlashCommand{Name: "/theme"}), "a handler is required")
+	require.NoError(t, registry.Register(SlashCommand{Name: "/theme", Run: run}))
+
+	_, ok := registry.Lookup("/theme")
+	assert.True(t, ok)
+	_, ok = registry.Lookup("/help")
+	assert.True(t, ok, "built-in commands are registered")
+	commands := registry.Commands()
+	for i := 1; i < len(commands); i++ {
+		assert.Less(t, commands[i-1].Name, commands[i].Name)
+	}
+}
+
+func TestCommandRegistrySuggestions(t *testing.T) {
+	registry := NewCommandRegistry()
+	require.NoError(t, registry.Register(SlashCommand{
+		Name:     "/theme",
+		Usage:    "<name>",
+		Complete: completeFrom("dark", "default", "light"),
+		Run:      func(c *CommandContext, args string) tea.Cmd { return nil },
+	}))
+
+	assert.Equal(t, []string{"/snippets", "/status", "/steer "}, registry.suggestions("/s"))
+	assert.Equal(t, []string{"/theme "}, registry.suggestions("/th"), "commands taking arguments end with a space")
+	assert.Equal(t, []string{"/theme dark", "/theme default"}, registry.suggestions("/theme d"))
+	assert.Empty(t, registry.suggestions("/theme dark"), "a complete value is not suggested again")
+	assert.Empty(t, registry.suggestions("/clear x"), "commands without a completer suggest nothing")
+	assert.Empty(t, registry.suggestions("/nope x"))
+}
+
+func TestRegisteredCommandRuns(t *testing.T) {
+	registry := NewCommandRegistry()
+	var got string
+	require.NoError(t, registry.Register(SlashCommand{
+		Name:  "/echo",
+		Usage: "<text>",
+		Help:  "Repeat text",
+		Run: func(c *CommandContext, args string) tea.Cmd {
+			got = args
+			c.Notice("echo: " + args)
+			return nil
+		},
+	}))
+
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider), WithCommands(registry), WithModelName("llama3"))
+	send := func(text string) string {
+		model.inputArea.SetValue(text)
+		updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		model = updated.(Model)
+		return lastMessageText(model)
+	}
+
+	assert.Equal(t, "echo: hello there", send("/echo  hello there "))
+	assert.Equal(t, "hello there", got)
+	assert.Empty(t, model.inputArea.GetValue())
+
+	help := send("/help")
+	assert.Contains(t, help, "/echo <text>")
+	assert.Contains(t, help, "Repeat text")
+	assert.Contains(t, help, "/quit")
+
+	assert.Contains(t, send("/status"), "Model:    llama3")
+
+	send("/clear")
+	assert.Len(t, model.messageList.GetMessages(), 1, "only the notice is left")
+}
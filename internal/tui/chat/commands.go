@@ -0,0 +1,385 @@
+package chat
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/httpclient"
+	"github.com/castrovroberto/CGE/internal/i18n"
+	"github.com/castrovroberto/CGE/internal/logger"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// handleSlashCommand runs input as a registered slash command. It reports
+// false when input is not one, so the caller sends it to the LLM as usual.
+func (m *Model) handleSlashCommand(input string) (tea.Cmd, bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "/") {
+		return nil, false
+	}
+	name, args, _ := strings.Cut(input, " ")
+	cmd, ok := m.commands.Lookup(name)
+	if !ok {
+		return nil, false
+	}
+	m.inputArea.Reset()
+	return cmd.Run(&CommandContext{m: m}, strings.TrimSpace(args)), true
+}
+
+// helpCommand lists the registered slash commands
+func (m *Model) helpCommand(args string) tea.Cmd {
+	var b strings.Builder
+	b.WriteString("Slash commands:")
+	for _, cmd := range m.commands.Commands() {
+		usage := cmd.Name
+		if cmd.Usage != "" {
+			usage += " " + cmd.Usage
+		}
+		fmt.Fprintf(&b, "\n  %-22s %s", usage, cmd.Help)
+	}
+	b.WriteString("\nType #name to use a prompt snippet; /snippets lists them.")
+	m.addSystemNotice(b.String())
+	return nil
+}
+
+// clearCommand removes the conversation from the screen. Runs do not share
+// history, so the agent is unaffected.
+func (m *Model) clearCommand(args string) tea.Cmd {
+	m.messageList.Truncate(0)
+	m.turnStarts = nil
+	m.codeBlockSelection = -1
+	m.addSystemNotice("🧹 Cleared the conversation")
+	return nil
+}
+
+// statusCommand shows the model, the run state and statistics of the session
+func (m *Model) statusCommand(args string) tea.Cmd {
+	state := "idle"
+	if m.loading {
+		state = fmt.Sprintf("running for %s", time.Since(m.thinkingStartTime).Round(time.Second))
+	}
+	var b strings.Builder
+	b.WriteString("📊 Status")
+	fmt.Fprintf(&b, "\n  Model:    %s", (&CommandContext{m: m}).ModelName())
+	fmt.Fprintf(&b, "\n  Agent:    %s", state)
+	fmt.Fprintf(&b, "\n  Session:  %s, %d turn(s), %d message(s)", time.Since(m.chatStartTime).Round(time.Second), len(m.turnStarts), len(m.messageList.GetMessages()))
+	if len(m.branches) > 0 {
+		fmt.Fprintf(&b, "\n  Branch:   %s of %d; /branch shows them", m.branch, len(m.branches))
+	}
+	if len(m.queuedMessages) > 0 {
+		fmt.Fprintf(&b, "\n  Queued:   %d message(s)", len(m.queuedMessages))
+	}
+	if len(m.errorHistory) > 0 {
+		fmt.Fprintf(&b, "\n  Errors:   %d; /errors lists them", len(m.errorHistory))
+	}
+	m.addSystemNotice(b.String())
+	return nil
+}
+
+// quitCommand saves the history and leaves the chat
+func (m *Model) quitCommand(args string) tea.Cmd {
+	return m.saveAndQuit("/quit")
+}
+
+// addSystemNotice shows a short system message in the conversation
+func (m *Model) addSystemNotice(text string) {
+	m.messageList.AddMessage(chatMessage{
+		text:      text,
+		sender:    "System",
+		timestamp: time.Now(),
+	})
+	m.messageList.GotoBottom()
+}
+
+// pasteCommand attaches the clipboard content to the next prompt, or sends it
+// straight away with args as the prompt
+func (m *Model) pasteCommand(args string) tea.Cmd {
+	text, err := m.clipboard.Paste()
+	if err != nil {
+		m.statusBar.SetError(err)
+		return nil
+	}
+	if strings.TrimSpace(text) == "" {
+		m.addSystemNotice("📋 Clipboard is empty")
+		return nil
+	}
+
+	truncated := len(text) > maxPasteBytes
+	if truncated {
+		text = truncateUTF8(text, maxPasteBytes)
+	}
+	m.pastedContext = text
+
+	notice := fmt.Sprintf("📋 Attached %d line(s) from the clipboard", lineCount(text))
+	if truncated {
+		notice += fmt.Sprintf(" (truncated to %d KB)", maxPasteBytes/1024)
+	}
+	if args == "" {
+		m.addSystemNotice(notice + "; they will be sent with your next message")
+		return nil
+	}
+	m.addSystemNotice(notice)
+	return m.sendOrQueue(args)
+}
+
+// steerCommand passes a message to the run in progress, whatever the
+// configured queue mode; when idle the message is simply sent
+func (m *Model) steerCommand(args string) tea.Cmd {
+	if args == "" {
+		m.addSystemNotice("Usage: /steer <message> — pass a message to the agent while it is working")
+		return nil
+	}
+	if !m.loading {
+		return m.submitPrompt(args)
+	}
+	if !m.steer(args) {
+		m.queueMessage(args)
+	}
+	return nil
+}
+
+// undoCommand reverts the file changes made in the last turn and removes the
+// turn from the conversation
+func (m *Model) undoCommand(args string) tea.Cmd {
+	undoer, ok := m.messageProvider.(Undoer)
+	if !ok {
+		m.addSystemNotice("↩ Undo is not supported by this chat backend")
+		return nil
+	}
+	if m.loading {
+		m.addSystemNotice("↩ Wait for the run to finish, or cancel it, before undoing")
+		return nil
+	}
+	if len(m.turnStarts) == 0 {
+		m.addSystemNotice("↩ Nothing to undo")
+		return nil
+	}
+
+	result, err := undoer.Undo()
+	if err != nil {
+		m.addSystemNotice(fmt.Sprintf("↩ Undo failed: %v", err))
+		return m.refreshWorkspaceStatus()
+	}
+
+	m.messageList.Truncate(m.turnStarts[len(m.turnStarts)-1])
+	m.turnStarts = m.turnStarts[:len(m.turnStarts)-1]
+	if len(result.Files) == 0 {
+		m.addSystemNotice("↩ Removed the last turn; it changed no files")
+		return nil
+	}
+	files := make([]string, len(result.Files))
+	for i, file := range result.Files {
+		files[i] = file
+		if rel, err := filepath.Rel(m.workspaceRoot, file); err == nil && m.workspaceRoot != "" && !strings.HasPrefix(rel, "..") {
+			files[i] = rel
+		}
+	}
+	m.addSystemNotice(fmt.Sprintf("↩ Removed the last turn and restored %d file(s): %s", len(files), strings.Join(files, ", ")))
+	return m.refreshWorkspaceStatus()
+}
+
+// debugCommand turns debug logging on or off: "/debug llm on" logs LLM HTTP
+// traffic, with secrets redacted, to a file
+func (m *Model) debugCommand(args string) tea.Cmd {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || fields[0] != "llm" || len(fields) > 2 {
+		m.addSystemNotice("🐞 Usage: /debug llm [on|off]")
+		return nil
+	}
+
+	if len(fields) == 1 {
+		enabled, path := httpclient.DebugLogging()
+		if enabled {
+			m.addSystemNotice(fmt.Sprintf("🐞 LLM HTTP logging is on, writing to %s", path))
+		} else {
+			m.addSystemNotice("🐞 LLM HTTP logging is off; /debug llm on enables it")
+		}
+		return nil
+	}
+
+	switch fields[1] {
+	case "on":
+		if err := httpclient.SetDebugLogging(true); err != nil {
+			m.addSystemNotice(fmt.Sprintf("🐞 Could not enable LLM HTTP logging: %v", err))
+			return nil
+		}
+		_, path := httpclient.DebugLogging()
+		m.addSystemNotice(fmt.Sprintf("🐞 Logging LLM HTTP requests and responses to %s (API keys redacted)", path))
+	case "off":
+		if err := httpclient.SetDebugLogging(false); err != nil {
+			m.addSystemNotice(fmt.Sprintf("🐞 LLM HTTP logging stopped, but closing the log failed: %v", err))
+			return nil
+		}
+		m.addSystemNotice("🐞 LLM HTTP logging is off")
+	default:
+		m.addSystemNotice("🐞 Usage: /debug llm [on|off]")
+	}
+	return nil
+}
+
+// logCommand shows the log level of each subsystem, or sets one for the rest
+// of the session: "/log orchestrator debug", "/log tools default"
+func (m *Model) logCommand(args string) tea.Cmd {
+	fields := strings.Fields(args)
+	switch len(fields) {
+	case 0:
+		var b strings.Builder
+		b.WriteString("📜 Log levels:")
+		for _, subsystem := range logger.Subsystems {
+			level, own := logger.Level(subsystem)
+			fmt.Fprintf(&b, "\n  %-13s %s", subsystem, strings.ToLower(level.String()))
+			if !own {
+				b.WriteString(" (default)")
+			}
+		}
+		b.WriteString("\n/log <subsystem> <debug|info|warn|error|default> changes one")
+		m.addSystemNotice(b.String())
+	case 2:
+		if err := logger.SetLevel(fields[0], fields[1]); err != nil {
+			m.addSystemNotice(fmt.Sprintf("📜 %v", err))
+			return nil
+		}
+		level, _ := logger.Level(fields[0])
+		m.addSystemNotice(fmt.Sprintf("📜 %s now logs at %s", fields[0], strings.ToLower(level.String())))
+	default:
+		m.addSystemNotice("📜 Usage: /log [<subsystem> <debug|info|warn|error|default>]")
+	}
+	return nil
+}
+
+// maxRecentErrors is how many of the latest errors /errors lists in full
+const maxRecentErrors = 5
+
+// errorsCommand summarizes the errors of this session by code, most severe
+// first, followed by the latest few
+func (m *Model) errorsCommand(args string) tea.Cmd {
+	if len(m.errorHistory) == 0 {
+		m.addSystemNotice(i18n.T("errors.none"))
+		return nil
+	}
+
+	type codeCount struct {
+		code  agent.ToolErrorCode
+		count int
+	}
+	counts := map[agent.ToolErrorCode]int{}
+	for _, record := range m.errorHistory {
+		counts[record.code]++
+	}
+	byCode := make([]codeCount, 0, len(counts))
+	for code, count := range counts {
+		byCode = append(byCode, codeCount{code, count})
+	}
+	sort.Slice(byCode, func(i, j int) bool {
+		si, sj := guideFor(byCode[i].code).severity, guideFor(byCode[j].code).severity
+		if si != sj {
+			return si > sj
+		}
+		if byCode[i].count != byCode[j].count {
+			return byCode[i].count > byCode[j].count
+		}
+		return byCode[i].code < byCode[j].code
+	})
+
+	var b strings.Builder
+	b.WriteString(i18n.T("errors.summary", len(m.errorHistory)) + "\n")
+	for _, entry := range byCode {
+		guide := guideFor(entry.code)
+		code := entry.code
+		if code == "" {
+			code = "UNKNOWN"
+		}
+		fmt.Fprintf(&b, "\n%s %s ×%d: %s\n    → %s", m.severityIcon(guide.severity), code, entry.count, guide.summary, guide.action)
+	}
+
+	recent := m.errorHistory[max(0, len(m.errorHistory)-maxRecentErrors):]
+	b.WriteString("\n\n" + i18n.T("errors.latest"))
+	for i := len(recent) - 1; i >= 0; i-- {
+		record := recent[i]
+		fmt.Fprintf(&b, "\n  %s %s: %s", record.at.Format("15:04:05"), record.source, firstLine(record.message))
+	}
+	m.addSystemNotice(b.String())
+	return nil
+}
+
+// copyToClipboard copies text and reports the outcome in the conversation
+func (m *Model) copyToClipboard(text, what string) {
+	if err := m.clipboard.Copy(text); err != nil {
+		m.statusBar.SetError(fmt.Errorf("failed to copy %s: %w", what, err))
+		return
+	}
+	m.statusBar.ClearError()
+	m.addSystemNotice(fmt.Sprintf("📋 Copied %s (%d line(s))", what, lineCount(text)))
+}
+
+// copyLastAssistantMessage copies the latest assistant response as markdown
+func (m *Model) copyLastAssistantMessage() {
+	text, ok := lastAssistantMessage(m.messageList.GetMessages())
+	if !ok {
+		m.addSystemNotice("📋 No assistant message to copy yet")
+		return
+	}
+	m.copyToClipboard(text, "last assistant message")
+}
+
+// copyCodeBlock copies a code block from the assistant's responses. The first
+// press selects the newest block; repeated presses step back to older ones.
+func (m *Model) copyCodeBlock() {
+	blocks := assistantCodeBlocks(m.messageList.GetMessages())
+	if len(blocks) == 0 {
+		m.addSystemNotice("📋 No code blocks to copy yet")
+		return
+	}
+
+	if m.codeBlockSelection < 0 || m.codeBlockSelection >= len(blocks) {
+		m.codeBlockSelection = len(blocks) - 1
+	} else {
+		m.codeBlockSelection = (m.codeBlockSelection - 1 + len(blocks)) % len(blocks)
+	}
+	block := blocks[m.codeBlockSelection]
+
+	what := fmt.Sprintf("code block %d/%d", m.codeBlockSelection+1, len(blocks))
+	if block.language != "" {
+		what += " [" + block.language + "]"
+	}
+	m.copyToClipboard(block.code, what)
+}
+
+// copyLastDiff copies the most recent diff from a response or tool result
+func (m *Model) copyLastDiff() {
+	diff, ok := lastDiff(m.messageList.GetMessages())
+	if !ok {
+		m.addSystemNotice("📋 No diff to copy yet")
+		return
+	}
+	m.copyToClipboard(diff, "diff")
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// keysCommand opens the overlay listing the active key bindings
+func (m *Model) keysCommand(args string) tea.Cmd {
+	m.showKeys = true
+	return nil
+}
+
+// keysOverlay renders the content of the /keys overlay
+func (m *Model) keysOverlay() string {
+	title := m.theme.Sender.Render("Key bindings")
+	hint := m.theme.Time.Render("Remap actions in the [keybindings] section of codex.toml. Press any key to close.")
+	return title + "\n\n" + m.keyMap.Help() + "\n" + hint
+}
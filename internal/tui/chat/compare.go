@@ -0,0 +1,87 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/orchestrator"
+)
+
+// Compare implements Comparer. Each model answers prompt at once with a plain
+// generation, without tools, so the models cannot race to change the
+// workspace. The cost of an answer is known for the active model only.
+func (p *ChatPresenter) Compare(ctx context.Context, prompt string, models []string) []ModelAnswer {
+	answers := make([]ModelAnswer, len(models))
+	var wg sync.WaitGroup
+	for i, model := range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			answers[i] = p.answerWith(ctx, model, prompt)
+		}()
+	}
+	wg.Wait()
+	return answers
+}
+
+// answerWith has model answer prompt, measuring its latency and usage
+func (p *ChatPresenter) answerWith(ctx context.Context, model, prompt string) ModelAnswer {
+	meter := &llm.UsageMeter{}
+	start := time.Now()
+	text, err := llm.WithUsageMeter(p.llmClient, meter).Generate(ctx, model, prompt, p.systemPrompt, nil)
+	answer := ModelAnswer{Model: model, Text: strings.TrimSpace(text), Err: err, Latency: time.Since(start)}
+	if err != nil {
+		return answer
+	}
+	usage := meter.Snapshot()
+	answer.Usage = TokenUsage{
+		InputTokens:       usage.InputTokens,
+		CachedInputTokens: usage.CachedInputTokens,
+		OutputTokens:      usage.OutputTokens,
+	}
+	if model == p.modelName && p.pricingKnown {
+		answer.Usage.CostUSD = usage.Cost(p.pricing)
+		answer.Usage.CostKnown = true
+	}
+	return answer
+}
+
+// AdoptAnswer implements Comparer. It fails while a run is in progress.
+func (p *ChatPresenter) AdoptAnswer(prompt string, answer ModelAnswer) error {
+	if answer.Err != nil {
+		return errors.New("the model failed to answer")
+	}
+	if !p.runMu.TryLock() {
+		return errors.New("a run is in progress; wait for it to finish or cancel it")
+	}
+	defer p.runMu.Unlock()
+	if answer.Model != p.modelName {
+		p.setModel(answer.Model)
+	}
+	p.adopted = []orchestrator.Message{
+		{Role: "user", Content: prompt},
+		{Role: "assistant", Content: answer.Text},
+	}
+	return nil
+}
+
+// continuationPrompt carries the exchange of an adopted comparison, which
+// the agent took no part in, into the next prompt
+func continuationPrompt(adopted []orchestrator.Message, prompt string) string {
+	var sb strings.Builder
+	sb.WriteString("Earlier in this conversation:\n")
+	for _, msg := range adopted {
+		switch msg.Role {
+		case "user":
+			sb.WriteString("\nThe user asked:\n" + msg.Content + "\n")
+		case "assistant":
+			sb.WriteString("\nYou answered:\n" + msg.Content + "\n")
+		}
+	}
+	sb.WriteString("\nThe user's new message:\n" + prompt)
+	return sb.String()
+}
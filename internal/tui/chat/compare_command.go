@@ -0,0 +1,239 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// minCompareColumnWidth is the narrowest column answers are shown side by
+// side in; narrower windows stack them
+const minCompareColumnWidth = 36
+
+// comparison is a prompt answered by several models, shown in place of the
+// message list until the user picks an answer or discards them
+type comparison struct {
+	prompt   string
+	models   []string
+	answers  []ModelAnswer // Nil while the models are answering
+	selected int
+	scroll   int  // First line of the answers shown
+	stacked  bool // Answers one above the other even when there is room for columns
+	cancel   context.CancelFunc
+}
+
+// comparisonMsg carries the answers of a comparison
+type comparisonMsg struct {
+	comparison *comparison
+	answers    []ModelAnswer
+}
+
+// compareCommand sends a prompt to several models at once:
+// "/compare <model1> <model2> <prompt>"
+func (m *Model) compareCommand(args string) tea.Cmd {
+	comparer, ok := m.messageProvider.(Comparer)
+	if !ok {
+		m.addSystemNotice("⚖️ This chat backend cannot compare models")
+		return nil
+	}
+	fields := strings.Fields(args)
+	if len(fields) < 3 {
+		m.addSystemNotice("Usage: /compare <model1> <model2> <prompt>")
+		return nil
+	}
+	if m.loading {
+		m.addSystemNotice("⚖️ Wait for the run to finish, or cancel it, before comparing models")
+		return nil
+	}
+	if m.comparison != nil {
+		m.addSystemNotice("⚖️ A comparison is already open")
+		return nil
+	}
+
+	models := fields[:2]
+	rest := strings.TrimSpace(args)
+	for _, model := range models {
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, model))
+	}
+
+	prompt := m.expandPastes(rest)
+	m.forgetPastes(rest)
+	ctx, cancel := context.WithCancel(m.parentCtx)
+	c := &comparison{prompt: prompt, models: models, cancel: cancel}
+	m.comparison = c
+	return func() tea.Msg {
+		return comparisonMsg{comparison: c, answers: comparer.Compare(ctx, prompt, c.models)}
+	}
+}
+
+// comparisonAnswered shows the answers of a comparison, unless it was
+// discarded while the models were answering
+func (m *Model) comparisonAnswered(msg comparisonMsg) {
+	if m.comparison != msg.comparison {
+		return
+	}
+	m.comparison.answers = msg.answers
+	for _, answer := range msg.answers {
+		if answer.Err == nil {
+			m.statusBar.AddUsage(answer.Usage)
+		}
+	}
+}
+
+// comparisonKey handles a key while a comparison is open
+func (m *Model) comparisonKey(msg tea.KeyMsg) tea.Cmd {
+	c := m.comparison
+	key := msg.String()
+	if key == "esc" || key == "q" {
+		c.cancel()
+		m.comparison = nil
+		m.addSystemNotice("⚖️ Comparison discarded")
+		return nil
+	}
+	if c.answers == nil {
+		return nil
+	}
+	switch key {
+	case "left", "h", "shift+tab":
+		c.selected = max(0, c.selected-1)
+	case "right", "l", "tab":
+		c.selected = min(len(c.answers)-1, c.selected+1)
+	case "up", "k":
+		c.scroll = max(0, c.scroll-1)
+	case "down", "j":
+		c.scroll++
+	case "v":
+		c.stacked = !c.stacked
+	case "enter":
+		m.pickAnswer(c.selected)
+	default:
+		if n, err := strconv.Atoi(key); err == nil && n >= 1 && n <= len(c.answers) {
+			m.pickAnswer(n - 1)
+		}
+	}
+	return nil
+}
+
+// pickAnswer continues the conversation from an answer of the comparison
+// with its model
+func (m *Model) pickAnswer(i int) {
+	c := m.comparison
+	answer := c.answers[i]
+	if answer.Err != nil {
+		m.addSystemNotice(fmt.Sprintf("⚖️ %s failed to answer; pick another answer", answer.Model))
+		return
+	}
+	if err := m.messageProvider.(Comparer).AdoptAnswer(c.prompt, answer); err != nil {
+		m.addSystemNotice(fmt.Sprintf("⚖️ Could not continue with %s: %v", answer.Model, err))
+		return
+	}
+	c.cancel()
+	m.comparison = nil
+
+	m.messageList.AddMessage(chatMessage{text: c.prompt, sender: "You", timestamp: time.Now()})
+	m.messageList.AddMessage(chatMessage{text: answer.Text, sender: "Assistant", timestamp: time.Now(), isMarkdown: true, ThinkingTime: answer.Latency})
+	var others []string
+	for j, other := range c.answers {
+		if j != i {
+			others = append(others, fmt.Sprintf("%s (%s)", other.Model, answerStats(other)))
+		}
+	}
+	m.setModelName(answer.Model)
+	m.addSystemNotice(fmt.Sprintf("⚖️ Picked %s (%s) over %s; the next messages go to %s", answer.Model, answerStats(answer), strings.Join(others, ", "), answer.Model))
+}
+
+// answerStats summarizes the latency and usage of an answer
+func answerStats(answer ModelAnswer) string {
+	if answer.Err != nil {
+		return "failed after " + answer.Latency.Round(100*time.Millisecond).String()
+	}
+	stats := fmt.Sprintf("%s, %s in / %s out tokens", answer.Latency.Round(100*time.Millisecond),
+		formatTokenCount(answer.Usage.InputTokens), formatTokenCount(answer.Usage.OutputTokens))
+	if answer.Usage.CostKnown {
+		stats += fmt.Sprintf(", $%.4f", answer.Usage.CostUSD)
+	}
+	return stats
+}
+
+// comparisonView renders the answers side by side, or stacked when the
+// window is too narrow for columns, with the keys the comparison takes
+func (m Model) comparisonView() string {
+	c := m.comparison
+	width, height := m.messageList.OverlaySize()
+	out := []string{m.theme.Sender.Render(truncateLine("Comparing "+strings.Join(c.models, " vs "), width))}
+	first, _, _ := strings.Cut(c.prompt, "\n")
+	out = append(out, m.theme.Time.Render(truncateLine("Prompt: "+first, width)), "")
+
+	if c.answers == nil {
+		out = append(out, fmt.Sprintf("Waiting for the answers of %s...", strings.Join(c.models, " and ")))
+		for len(out) < height-1 {
+			out = append(out, "")
+		}
+		out = append(out, m.theme.Time.Render(truncateLine("esc cancel", width)))
+		return strings.Join(out, "\n")
+	}
+
+	room := max(2, height-len(out)-1)
+	columnWidth := (width - 2*(len(c.answers)-1)) / len(c.answers)
+	if !c.stacked && columnWidth >= minCompareColumnWidth {
+		var columns []string
+		for i := range c.answers {
+			if i > 0 {
+				columns = append(columns, "  ")
+			}
+			columns = append(columns, lipgloss.NewStyle().Width(columnWidth).Render(strings.Join(m.answerPane(i, columnWidth, room), "\n")))
+		}
+		out = append(out, strings.Split(lipgloss.JoinHorizontal(lipgloss.Top, columns...), "\n")...)
+	} else {
+		paneHeight := max(2, room/len(c.answers))
+		for i := range c.answers {
+			out = append(out, m.answerPane(i, width, paneHeight)...)
+		}
+	}
+
+	if len(out) > height-1 {
+		out = out[:height-1]
+	}
+	for len(out) < height-1 {
+		out = append(out, "")
+	}
+	help := fmt.Sprintf("1-%d or enter pick, left/right select, up/down scroll, v stack/columns, esc discard", len(c.answers))
+	out = append(out, m.theme.Time.Render(truncateLine(help, width)))
+	return strings.Join(out, "\n")
+}
+
+// answerPane renders answer i in at most height lines of width: its model
+// and stats, then its text from the scroll position
+func (m Model) answerPane(i, width, height int) []string {
+	c := m.comparison
+	answer := c.answers[i]
+	title := fmt.Sprintf("[%d] %s  %s", i+1, answer.Model, answerStats(answer))
+	if i == c.selected {
+		title = m.theme.Sender.Render(truncateLine("> "+title, width))
+	} else {
+		title = truncateLine("  "+title, width)
+	}
+
+	text := answer.Text
+	if answer.Err != nil {
+		text = "Error: " + answer.Err.Error()
+	}
+	wrapped := strings.Split(lipgloss.NewStyle().Width(width).Render(strings.ReplaceAll(text, "\t", "    ")), "\n")
+	start := min(c.scroll, max(0, len(wrapped)-1))
+	body := wrapped[start:]
+	if len(body) > height-1 {
+		body = append(body[:height-2], m.theme.Time.Render(fmt.Sprintf("... %d more line(s)", len(body)-height+2)))
+	}
+	lines := append([]string{title}, body...)
+	if answer.Err != nil {
+		for j := 1; j < len(lines); j++ {
+			lines[j] = m.theme.Error.Render(lines[j])
+		}
+	}
+	return lines
+}
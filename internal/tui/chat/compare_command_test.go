@@ -0,0 +1,100 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// comparingProvider is a message provider that compares models with canned
+// answers
+type comparingProvider struct {
+	*MockMessageProvider
+	prompt  string
+	adopted ModelAnswer
+}
+
+func (p *comparingProvider) Compare(ctx context.Context, prompt string, models []string) []ModelAnswer {
+	p.prompt = prompt
+	answers := make([]ModelAnswer, len(models))
+	for i, model := range models {
+		answers[i] = ModelAnswer{Model: model, Text: "Answer of " + model, Latency: time.Duration(i+1) * time.Second, Usage: TokenUsage{InputTokens: 100, OutputTokens: 20 * (i + 1)}}
+		if model == "broken" {
+			answers[i] = ModelAnswer{Model: model, Err: errors.New("model not found")}
+		}
+	}
+	return answers
+}
+
+func (p *comparingProvider) AdoptAnswer(prompt string, answer ModelAnswer) error {
+	p.adopted = answer
+	return nil
+}
+
+func TestCompareCommand(t *testing.T) {
+	provider := &comparingProvider{MockMessageProvider: NewMockMessageProvider()}
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider), WithModelName("model-a"))
+	update := func(msg tea.Msg) tea.Cmd {
+		updated, cmd := model.Update(msg)
+		model = updated.(Model)
+		return cmd
+	}
+	compare := func(args string) {
+		model.inputArea.SetValue("/compare " + args)
+		cmd := update(tea.KeyMsg{Type: tea.KeyEnter})
+		require.NotNil(t, cmd)
+		require.NotNil(t, model.comparison)
+		assert.Contains(t, model.View(), "Waiting for the answers of")
+		update(cmd())
+	}
+	update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	model.compareCommand("model-a model-b")
+	assert.Contains(t, lastMessageText(model), "Usage: /compare")
+	assert.Nil(t, model.comparison)
+
+	compare("model-a  model-b   Explain  the cache")
+	assert.Equal(t, "Explain  the cache", provider.prompt)
+	view := model.View()
+	assert.Contains(t, view, "> [1] model-a  1s, 100 in / 20 out tokens")
+	assert.Contains(t, view, "[2] model-b  2s, 100 in / 40 out tokens")
+	sideBySide := false
+	for _, line := range strings.Split(view, "\n") {
+		if strings.Contains(line, "Answer of model-a") && strings.Contains(line, "Answer of model-b") {
+			sideBySide = true
+		}
+	}
+	assert.True(t, sideBySide, "wide windows show the answers in columns")
+	update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	for _, line := range strings.Split(model.View(), "\n") {
+		assert.False(t, strings.Contains(line, "Answer of model-a") && strings.Contains(line, "Answer of model-b"), "v stacks the answers")
+	}
+
+	update(tea.KeyMsg{Type: tea.KeyRight})
+	update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Nil(t, model.comparison)
+	assert.Equal(t, "model-b", provider.adopted.Model)
+	assert.Equal(t, "model-b", model.modelName)
+	messages := model.messageList.GetMessages()
+	require.GreaterOrEqual(t, len(messages), 3)
+	assert.Equal(t, "Explain  the cache", messages[len(messages)-3].text)
+	assert.Equal(t, "Answer of model-b", messages[len(messages)-2].rawText, "the picked answer continues the conversation")
+	assert.Contains(t, lastMessageText(model), "Picked model-b (2s, 100 in / 40 out tokens) over model-a (1s, 100 in / 20 out tokens)")
+	assert.Contains(t, model.statusBar.View(), "model-b")
+
+	compare("broken model-a Hi")
+	update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("1")})
+	require.NotNil(t, model.comparison, "a failed answer cannot be picked")
+	assert.Contains(t, lastMessageText(model), "broken failed to answer")
+	update(tea.KeyMsg{Type: tea.KeyEsc})
+	assert.Nil(t, model.comparison)
+	assert.Contains(t, lastMessageText(model), "Comparison discarded")
+	assert.Equal(t, "model-b", model.modelName)
+}
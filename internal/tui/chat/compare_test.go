@@ -0,0 +1,54 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// answeringClient answers plain generations with the name of the model, and
+// fails for the model "broken"
+type answeringClient struct {
+	scriptedClient
+}
+
+func (c *answeringClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	if modelName == "broken" {
+		return "", errors.New("model not found")
+	}
+	return "  answer of " + modelName + "\n", nil
+}
+
+func TestCompare(t *testing.T) {
+	client := &answeringClient{}
+	presenter := NewChatPresenter(context.Background(), client, agent.NewRegistry(), "system", "model-a")
+	defer presenter.Close()
+
+	answers := presenter.Compare(context.Background(), "Explain the cache", []string{"model-a", "model-b", "broken"})
+	require.Len(t, answers, 3)
+	assert.Equal(t, "model-a", answers[0].Model)
+	assert.Equal(t, "answer of model-a", answers[0].Text)
+	assert.Equal(t, "answer of model-b", answers[1].Text)
+	assert.Positive(t, answers[1].Usage.InputTokens, "usage is estimated when the provider reports none")
+	assert.Positive(t, answers[1].Usage.OutputTokens)
+	assert.False(t, answers[1].Usage.CostKnown, "the pricing of another model is unknown")
+	assert.Error(t, answers[2].Err)
+	assert.Error(t, presenter.AdoptAnswer("Explain the cache", answers[2]), "a failed answer cannot be adopted")
+
+	require.NoError(t, presenter.AdoptAnswer("Explain the cache", answers[1]))
+	assert.Equal(t, "model-b", presenter.modelName)
+
+	presenter.processPromptAsync(context.Background(), "Now make it faster")
+	prompt, _, _ := client.lastRequest()
+	assert.Contains(t, prompt, "The user asked:\nExplain the cache")
+	assert.Contains(t, prompt, "You answered:\nanswer of model-b")
+	assert.Contains(t, prompt, "The user's new message:\nNow make it faster")
+
+	presenter.processPromptAsync(context.Background(), "Thanks")
+	prompt, _, _ = client.lastRequest()
+	assert.NotContains(t, prompt, "answer of model-b", "the exchange is carried into one prompt only")
+}
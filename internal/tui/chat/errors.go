@@ -0,0 +1,215 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/i18n"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// errorSeverity ranks how much attention an error needs from the user
+type errorSeverity int
+
+const (
+	severityWarning  errorSeverity = iota // The agent can usually recover by itself
+	severityError                         // A step failed; the agent may work around it
+	severityCritical                      // The environment or provider needs fixing
+)
+
+// Codes for run errors that do not come from a tool
+const (
+	errorCodeModelNotFound       agent.ToolErrorCode = "MODEL_NOT_FOUND"
+	errorCodeProviderUnreachable agent.ToolErrorCode = "PROVIDER_UNREACHABLE"
+	errorCodeAuthentication      agent.ToolErrorCode = "AUTHENTICATION_FAILED"
+	errorCodeRateLimited         agent.ToolErrorCode = "RATE_LIMITED"
+)
+
+// errorGuide is the user-facing description of an error code
+type errorGuide struct {
+	severity errorSeverity
+	summary  string // What went wrong, in plain words
+	action   string // Suggested next step for the user
+}
+
+// errorGuides describes the codes of agent.StandardizedToolError and of run
+// errors. Codes not listed get unknownErrorGuide.
+var errorGuides = map[agent.ToolErrorCode]errorGuide{
+	agent.ErrorCodeInvalidParameters:    {severityWarning, "The model sent invalid tool arguments", "Usually fixed on retry; if it repeats, try a model with better tool support"},
+	agent.ErrorCodeMissingParameter:     {severityWarning, "The model left out a required tool argument", "Usually fixed on retry; if it repeats, try a model with better tool support"},
+	agent.ErrorCodeInvalidPathFormat:    {severityWarning, "A file path was malformed", "Mention the exact path in your message"},
+	agent.ErrorCodePathOutsideWorkspace: {severityWarning, "A path pointed outside the workspace", "Tools only work inside the workspace; start CGE from the project root if the file belongs there"},
+	agent.ErrorCodePolicyDenied:         {severityError, "An organizational policy refused a tool call", "Check the [policy] rules of the configuration, or ask for a change they allow"},
+	agent.ErrorCodeFileNotFound:         {severityWarning, "A file does not exist", "Check the file name, or tell the agent where the file is"},
+	agent.ErrorCodeDirectoryNotFound:    {severityWarning, "A directory does not exist", "Check the directory name, or ask the agent to create it"},
+	agent.ErrorCodeFileAlreadyExists:    {severityWarning, "A file already exists", "Say whether the agent should overwrite it"},
+	agent.ErrorCodePermissionDenied:     {severityCritical, "Permission denied", "Check the file permissions and the user CGE runs as"},
+	agent.ErrorCodeInsufficientSpace:    {severityCritical, "The disk is full", "Free up disk space, then /undo or retry the last message"},
+	agent.ErrorCodeFileConflict:         {severityWarning, "A file changed since the agent read it", "Usually fixed once the agent rereads the file; your edits are kept"},
+	agent.ErrorCodeInvalidFileFormat:    {severityWarning, "A file is not in the expected format", "Check the file is text in the format the tool expects"},
+	agent.ErrorCodeContentTooLarge:      {severityWarning, "Content was too large for a tool", "Ask for the change in smaller steps"},
+	agent.ErrorCodeInvalidLineRange:     {severityWarning, "A line range was out of bounds", "Usually fixed on retry once the agent rereads the file"},
+	agent.ErrorCodeInvalidEncoding:      {severityWarning, "A file is not valid text", "Binary files cannot be read or edited by the agent"},
+	agent.ErrorCodeGitNotRepository:     {severityError, "The workspace is not a git repository", "Run git init, or start CGE inside the repository"},
+	agent.ErrorCodeGitNothingToCommit:   {severityWarning, "There was nothing to commit", "No action needed"},
+	agent.ErrorCodeGitConflict:          {severityCritical, "Git reported a conflict", "Resolve the conflict in your editor, then ask the agent to continue"},
+	agent.ErrorCodeInvalidCommitMessage: {severityWarning, "The commit message was rejected", "Usually fixed on retry"},
+	agent.ErrorCodeTestFailure:          {severityError, "Tests failed", "Review the failures; ask the agent to fix them or run them yourself"},
+	agent.ErrorCodeLintErrors:           {severityError, "The linter reported problems", "Ask the agent to fix the reported issues"},
+	agent.ErrorCodeCompilationFailure:   {severityError, "The code does not compile", "Ask the agent to fix the build errors"},
+	agent.ErrorCodeInvalidTestPattern:   {severityWarning, "A test pattern was invalid", "Usually fixed on retry"},
+	agent.ErrorCodeCommandNotFound:      {severityCritical, "A command is not installed", "Install it or add it to PATH; CGE doctor checks the configured tools"},
+	agent.ErrorCodeCommandTimeout:       {severityError, "A command timed out", "Run it yourself to see why it is slow, or ask for a narrower command"},
+	agent.ErrorCodeCommandFailed:        {severityError, "A command failed", "Check its output below"},
+	agent.ErrorCodeInvalidCommandArgs:   {severityWarning, "A command was given invalid arguments", "Usually fixed on retry"},
+	agent.ErrorCodeInternalError:        {severityCritical, "An internal error occurred", "Retry the last message; if it persists, report it with the log"},
+	agent.ErrorCodeTimeout:              {severityError, "An operation timed out", "Retry, or split the request into smaller steps"},
+	agent.ErrorCodeResourceLimit:        {severityError, "A resource limit was reached", "Split the request into smaller steps"},
+	agent.ErrorCodeUnsupportedOperation: {severityWarning, "The operation is not supported", "Ask for a different approach"},
+
+	errorCodeModelNotFound:       {severityCritical, "The model is not available", "Switch model with /model, or restart with --pull to download it"},
+	errorCodeProviderUnreachable: {severityCritical, "The LLM provider cannot be reached", "Check the provider is running and the URL in codex.toml; CGE doctor can help"},
+	errorCodeAuthentication:      {severityCritical, "The provider rejected the API key", "Check the API key in your environment or codex.toml"},
+	errorCodeRateLimited:         {severityError, "The provider is rate limiting requests", "Wait a moment, then retry the last message"},
+}
+
+// unknownErrorGuide describes errors without a known code
+var unknownErrorGuide = errorGuide{severityError, "Something went wrong", "Check the details; /errors lists the errors of this session"}
+
+// Error guides are translated under error.<CODE>.summary and .action keys
+func init() {
+	messages := map[string]string{
+		errorGuideKey("", "summary"): unknownErrorGuide.summary,
+		errorGuideKey("", "action"):  unknownErrorGuide.action,
+	}
+	for code, guide := range errorGuides {
+		messages[errorGuideKey(code, "summary")] = guide.summary
+		messages[errorGuideKey(code, "action")] = guide.action
+	}
+	i18n.Register(messages)
+}
+
+// errorGuideKey returns the message key of a field of the guide of code
+func errorGuideKey(code agent.ToolErrorCode, field string) string {
+	if code == "" {
+		code = "UNKNOWN"
+	}
+	return "error." + string(code) + "." + field
+}
+
+// guideFor returns the description of code in the current locale
+func guideFor(code agent.ToolErrorCode) errorGuide {
+	guide, ok := errorGuides[code]
+	if !ok {
+		guide, code = unknownErrorGuide, ""
+	}
+	guide.summary = i18n.T(errorGuideKey(code, "summary"))
+	guide.action = i18n.T(errorGuideKey(code, "action"))
+	return guide
+}
+
+// httpStatusPattern finds the HTTP status in provider error messages, which
+// end in "(HTTP 401)" or "status 401"
+var httpStatusPattern = regexp.MustCompile(`(?:HTTP|status) (\d{3})`)
+
+// codedErrorPattern finds the code of a formatted StandardizedToolError
+var codedErrorPattern = regexp.MustCompile(`\[([A-Z][A-Z_]+)\] `)
+
+// classifyRunError returns the code of an error that ended an agent run
+func classifyRunError(err error) agent.ToolErrorCode {
+	var standardized *agent.StandardizedToolError
+	var notFound *llm.ModelNotFoundError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &standardized):
+		return standardized.Code
+	case errors.As(err, &notFound):
+		return errorCodeModelNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return agent.ErrorCodeTimeout
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return agent.ErrorCodeTimeout
+		}
+		return errorCodeProviderUnreachable
+	}
+	return classifyErrorText(err.Error())
+}
+
+// classifyErrorText infers a code from an error message, for errors that
+// reach the TUI only as text
+func classifyErrorText(text string) agent.ToolErrorCode {
+	// StandardizedToolError.Error() formats as "[CODE] message"
+	if match := codedErrorPattern.FindStringSubmatch(text); match != nil {
+		return agent.ToolErrorCode(match[1])
+	}
+	if match := httpStatusPattern.FindStringSubmatch(text); match != nil {
+		switch match[1] {
+		case "401", "403":
+			return errorCodeAuthentication
+		case "404":
+			if strings.Contains(text, "model") {
+				return errorCodeModelNotFound
+			}
+		case "429":
+			return errorCodeRateLimited
+		}
+	}
+	lower := strings.ToLower(text)
+	switch {
+	case strings.Contains(lower, "connection refused"), strings.Contains(lower, "no such host"):
+		return errorCodeProviderUnreachable
+	case strings.Contains(lower, "deadline exceeded"), strings.Contains(lower, "timed out"):
+		return agent.ErrorCodeTimeout
+	}
+	return ""
+}
+
+// errorRecord is an error shown during the session, kept for /errors
+type errorRecord struct {
+	at      time.Time
+	source  string // Tool name, or "agent" for run errors
+	code    agent.ToolErrorCode
+	message string
+}
+
+// recordError adds an error to the history summarized by /errors
+func (m *Model) recordError(source string, code agent.ToolErrorCode, message string) {
+	m.errorHistory = append(m.errorHistory, errorRecord{at: time.Now(), source: source, code: code, message: message})
+}
+
+// severityIcon renders the icon of a severity in its color
+func (m *Model) severityIcon(severity errorSeverity) string {
+	return severityStyle(m.theme, severity).Render(severityIcons[severity])
+}
+
+// severityIcons mark each severity in the message list and /errors
+var severityIcons = map[errorSeverity]string{
+	severityWarning:  "⚠",
+	severityError:    "✗",
+	severityCritical: "⛔",
+}
+
+// severityStyle returns the foreground color style of a severity
+func severityStyle(theme *Theme, severity errorSeverity) lipgloss.Style {
+	switch severity {
+	case severityWarning:
+		return lipgloss.NewStyle().Foreground(theme.Colors.Warning)
+	case severityCritical:
+		return lipgloss.NewStyle().Foreground(theme.Colors.Error).Bold(true)
+	default:
+		return lipgloss.NewStyle().Foreground(theme.Colors.Error)
+	}
+}
+
+// firstLine returns the first line of text
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
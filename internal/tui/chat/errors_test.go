@@ -0,0 +1,98 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/i18n"
+	"github.com/castrovroberto/CGE/internal/llm"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyRunError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want agent.ToolErrorCode
+	}{
+		{"standardized", fmt.Errorf("run: %w", agent.NewFileNotFoundError("a.go")), agent.ErrorCodeFileNotFound},
+		{"model not found", fmt.Errorf("generate: %w", &llm.ModelNotFoundError{Model: "llama3"}), errorCodeModelNotFound},
+		{"deadline", fmt.Errorf("generate: %w", context.DeadlineExceeded), agent.ErrorCodeTimeout},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, errorCodeProviderUnreachable},
+		{"unauthorized", errors.New("openai: API returned status 401"), errorCodeAuthentication},
+		{"rate limited", errors.New(`ollama: API error - "slow down" (HTTP 429)`), errorCodeRateLimited},
+		{"coded text", errors.New("Tool execution error: [GIT_CONFLICT] merge conflict in a.go"), agent.ErrorCodeGitConflict},
+		{"unknown", errors.New("something odd"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyRunError(tt.err))
+		})
+	}
+}
+
+func TestGuideFor(t *testing.T) {
+	assert.Equal(t, severityCritical, guideFor(agent.ErrorCodePermissionDenied).severity)
+	assert.Equal(t, severityWarning, guideFor(agent.ErrorCodeFileNotFound).severity)
+	assert.Equal(t, unknownErrorGuide, guideFor("NOT_A_CODE"))
+
+	defer i18n.Configure(i18n.DefaultLocale, "")
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "es.json"), []byte(`{"error.FILE_NOT_FOUND.summary": "Un archivo no existe"}`), 0600))
+	require.NoError(t, i18n.Configure("es", dir))
+	guide := guideFor(agent.ErrorCodeFileNotFound)
+	assert.Equal(t, "Un archivo no existe", guide.summary)
+	assert.Equal(t, errorGuides[agent.ErrorCodeFileNotFound].action, guide.action, "untranslated fields stay in English")
+}
+
+func TestErrorsCommand(t *testing.T) {
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	update := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	send := func(text string) string {
+		model.inputArea.SetValue(text)
+		update(tea.KeyMsg{Type: tea.KeyEnter})
+		return lastMessageText(model)
+	}
+
+	assert.Contains(t, send("/errors"), "No errors so far")
+
+	toolFailure := ChatMessage{
+		Type: ToolResultMessage,
+		Text: "File not found: a.go",
+		Metadata: map[string]interface{}{
+			"tool_name":  "read_file",
+			"success":    false,
+			"error_code": agent.ErrorCodeFileNotFound,
+		},
+	}
+	update(chatMsgWrapper{toolFailure})
+	update(chatMsgWrapper{toolFailure})
+	messages := model.messageList.GetMessages()
+	assert.Equal(t, agent.ErrorCodeFileNotFound, messages[len(messages)-1].errorCode)
+
+	update(chatMsgWrapper{ChatMessage{Type: ErrorMessage, Text: "Error: openai: API returned status 401"}})
+	messages = model.messageList.GetMessages()
+	assert.True(t, messages[len(messages)-1].isError)
+	assert.Equal(t, errorCodeAuthentication, messages[len(messages)-1].errorCode)
+
+	summary := send("/errors")
+	assert.Contains(t, summary, "3 error(s) this session")
+	assert.Contains(t, summary, "FILE_NOT_FOUND ×2")
+	assert.Contains(t, summary, "→ Check the API key")
+	assert.Less(t, strings.Index(summary, "AUTHENTICATION_FAILED"), strings.Index(summary, "FILE_NOT_FOUND"), "most severe first")
+	assert.Contains(t, summary, "agent: Error: openai: API returned status 401")
+}
@@ -0,0 +1,358 @@
+package chat
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxViewerBytes bounds the size of a file opened in the viewer
+const maxViewerBytes = 2 << 20
+
+// fileRefPattern matches a file reference in messages and tool output, such
+// as "internal/chat/model.go" or "model.go:42" or "model.go:42:7"
+var fileRefPattern = regexp.MustCompile(`([\w./-]*\w\.[A-Za-z0-9]+)(?::(\d+))?`)
+
+// fileRef is a file referenced in the conversation, with its line when given
+type fileRef struct {
+	path  string
+	line  int // 1-based, 0 when none
+	start int // Column span of the reference in its text, in runes
+	end   int
+}
+
+// fileViewer is the pane that shows a workspace file, highlighted and with
+// line numbers, in place of the message list
+type fileViewer struct {
+	path        string   // Absolute
+	name        string   // Relative to the workspace
+	lines       []string // Raw, for attaching to a prompt
+	highlighted []string
+	cursor      int // 0-based line of the cursor
+	top         int // First line shown
+	anchor      int // Other end of the selection, -1 when nothing is selected
+}
+
+// findFileRefs returns the file references in text, in order
+func findFileRefs(text string) []fileRef {
+	var refs []fileRef
+	for _, match := range fileRefPattern.FindAllStringSubmatchIndex(text, -1) {
+		ref := fileRef{
+			path:  text[match[2]:match[3]],
+			start: len([]rune(text[:match[0]])),
+			end:   len([]rune(text[:match[1]])),
+		}
+		if match[4] >= 0 {
+			ref.line, _ = strconv.Atoi(text[match[4]:match[5]])
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// parseFileArg splits "path:line" into the path and the line, 0 when none
+func parseFileArg(arg string) (string, int) {
+	if i := strings.LastIndex(arg, ":"); i > 0 {
+		if line, err := strconv.Atoi(arg[i+1:]); err == nil && line > 0 {
+			return arg[:i], line
+		}
+	}
+	return arg, 0
+}
+
+// resolveWorkspaceFile returns the absolute path of a file in the workspace.
+// Relative paths are taken from the workspace root; files outside it are
+// refused.
+func (m Model) resolveWorkspaceFile(path string) (string, error) {
+	path, err := m.workspacePath(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", m.displayPath(path))
+	}
+	if info.Size() > maxViewerBytes {
+		return "", fmt.Errorf("%s is larger than %d MB", m.displayPath(path), maxViewerBytes>>20)
+	}
+	return path, nil
+}
+
+// lastFileRef returns the most recent reference in the conversation to a file
+// of the workspace, preferring those with a line
+func (m Model) lastFileRef() (fileRef, bool) {
+	messages := m.messageList.GetMessages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		refs := findFileRefs(stripANSI(messages[i].text))
+		var found *fileRef
+		for j := len(refs) - 1; j >= 0; j-- {
+			if _, err := m.resolveWorkspaceFile(refs[j].path); err != nil {
+				continue
+			}
+			if refs[j].line > 0 {
+				return refs[j], true
+			}
+			if found == nil {
+				found = &refs[j]
+			}
+		}
+		if found != nil {
+			return *found, true
+		}
+	}
+	return fileRef{}, false
+}
+
+// openCommand opens a workspace file in the viewer: "/open path[:line]", or
+// with no argument the file last referenced in the conversation
+func (m *Model) openCommand(args string) tea.Cmd {
+	path, line := parseFileArg(strings.TrimSpace(args))
+	if path == "" {
+		ref, ok := m.lastFileRef()
+		if !ok {
+			m.addSystemNotice("📄 No file of the workspace is referenced in the conversation; use /open <file>[:line]")
+			return nil
+		}
+		path, line = ref.path, ref.line
+	}
+	if err := m.openFile(path, line); err != nil {
+		m.addSystemNotice(fmt.Sprintf("📄 Cannot open %s: %v", path, err))
+	}
+	return nil
+}
+
+// openFile shows a workspace file in the viewer with the cursor on line,
+// 1-based, or on the first line when line is 0
+func (m *Model) openFile(path string, line int) error {
+	abs, err := m.resolveWorkspaceFile(path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(abs)
+	if err != nil {
+		return err
+	}
+	if bytes.IndexByte(content, 0) >= 0 {
+		return fmt.Errorf("%s is a binary file", m.displayPath(abs))
+	}
+
+	text := strings.ReplaceAll(strings.TrimSuffix(string(content), "\n"), "\t", "    ")
+	lines := strings.Split(text, "\n")
+	viewer := &fileViewer{
+		path:        abs,
+		name:        m.displayPath(abs),
+		lines:       lines,
+		highlighted: lines,
+		anchor:      -1,
+	}
+	if !m.theme.Accessible {
+		viewer.highlighted = highlightLines(abs, text, len(lines))
+	}
+	viewer.cursor = max(0, min(line-1, len(lines)-1))
+	_, height := m.viewerSize()
+	viewer.top = max(0, viewer.cursor-height/2)
+	m.viewer = viewer
+	return nil
+}
+
+// highlightLines highlights text by the language of its file name, falling
+// back to its content, one string per line. The lines are left as they are
+// when highlighting fails.
+func highlightLines(path, text string, count int) []string {
+	lexer := lexers.Match(filepath.Base(path))
+	if lexer == nil {
+		lexer = lexers.Analyse(text)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	style := styles.Get("monokai")
+	if style == nil {
+		style = styles.Fallback
+	}
+
+	plain := strings.Split(text, "\n")
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, text)
+	if err != nil {
+		return plain
+	}
+	tokenLines := chroma.SplitTokensIntoLines(iterator.Tokens())
+	if len(tokenLines) < count {
+		return plain
+	}
+	highlighted := make([]string, count)
+	for i := range highlighted {
+		tokens := make([]chroma.Token, len(tokenLines[i]))
+		for j, token := range tokenLines[i] {
+			token.Value = strings.TrimSuffix(token.Value, "\n")
+			tokens[j] = token
+		}
+		var buf strings.Builder
+		if err := formatters.TTY256.Format(&buf, style, chroma.Literator(tokens...)); err != nil {
+			return plain
+		}
+		highlighted[i] = buf.String()
+	}
+	return highlighted
+}
+
+// viewerSize returns the width and the number of file lines the viewer
+// shows, less its title and help lines
+func (m Model) viewerSize() (int, int) {
+	width, height := m.messageList.OverlaySize()
+	return width, max(1, height-2)
+}
+
+// selection returns the first and last line of the selection, 0-based; the
+// cursor line when nothing is selected
+func (v *fileViewer) selection() (int, int) {
+	if v.anchor < 0 {
+		return v.cursor, v.cursor
+	}
+	return min(v.anchor, v.cursor), max(v.anchor, v.cursor)
+}
+
+// moveCursor moves the cursor by delta lines and scrolls it into view
+func (v *fileViewer) moveCursor(delta, height int) {
+	v.cursor = max(0, min(v.cursor+delta, len(v.lines)-1))
+	if v.cursor < v.top {
+		v.top = v.cursor
+	}
+	if v.cursor >= v.top+height {
+		v.top = v.cursor - height + 1
+	}
+}
+
+// viewerKey handles a key while the viewer is open: moving, selecting lines,
+// attaching them to the next prompt and closing
+func (m *Model) viewerKey(msg tea.KeyMsg) tea.Cmd {
+	viewer := m.viewer
+	_, height := m.viewerSize()
+	switch msg.String() {
+	case "esc", "q":
+		m.viewer = nil
+	case "up", "k":
+		viewer.moveCursor(-1, height)
+	case "down", "j":
+		viewer.moveCursor(1, height)
+	case "pgup", "ctrl+u":
+		viewer.moveCursor(-height, height)
+	case "pgdown", "ctrl+d":
+		viewer.moveCursor(height, height)
+	case "home", "g":
+		viewer.moveCursor(-len(viewer.lines), height)
+	case "end", "G":
+		viewer.moveCursor(len(viewer.lines), height)
+	case "v", " ":
+		if viewer.anchor < 0 {
+			viewer.anchor = viewer.cursor
+		} else {
+			viewer.anchor = -1
+		}
+	case "enter":
+		m.attachSelection()
+		m.viewer = nil
+	}
+	return nil
+}
+
+// attachSelection attaches the selected lines of the viewer to the next prompt
+func (m *Model) attachSelection() {
+	first, last := m.viewer.selection()
+	block := formatFileRange(m.viewer.name, first+1, last+1, m.viewer.lines[first:last+1])
+	m.fileRanges = append(m.fileRanges, block)
+	m.addSystemNotice(fmt.Sprintf("📄 Attached %s; it will be sent with your next message", fileRangeLabel(m.viewer.name, first+1, last+1)))
+}
+
+// fileRangeLabel names lines first to last of a file, 1-based
+func fileRangeLabel(name string, first, last int) string {
+	if first == last {
+		return fmt.Sprintf("%s line %d", name, first)
+	}
+	return fmt.Sprintf("%s lines %d-%d", name, first, last)
+}
+
+// formatFileRange renders lines of a file, the first being line first, as a
+// fenced block labelled with their place in the file
+func formatFileRange(name string, first, last int, lines []string) string {
+	return fmt.Sprintf("%s:\n```\n%s\n```", fileRangeLabel(name, first, last), strings.Join(lines, "\n"))
+}
+
+// withFileRanges appends the file ranges attached from the viewer to a prompt
+func withFileRanges(prompt string, ranges []string) string {
+	if len(ranges) == 0 {
+		return prompt
+	}
+	return prompt + "\n\nContext attached from the workspace:\n" + strings.Join(ranges, "\n\n")
+}
+
+// openClickedRef opens the file referenced where the message list was
+// clicked, if any. x and y are the column and row of the click in the window.
+func (m *Model) openClickedRef(x, y int) bool {
+	row := y - lipgloss.Height(m.header.View())
+	lines := strings.Split(m.messageList.View(), "\n")
+	if row < 0 || row >= len(lines) {
+		return false
+	}
+	for _, ref := range findFileRefs(stripANSI(lines[row])) {
+		if x < ref.start || x >= ref.end {
+			continue
+		}
+		if _, err := m.resolveWorkspaceFile(ref.path); err != nil {
+			return false
+		}
+		if err := m.openFile(ref.path, ref.line); err != nil {
+			m.addSystemNotice(fmt.Sprintf("📄 Cannot open %s: %v", ref.path, err))
+		}
+		return true
+	}
+	return false
+}
+
+// fileViewerView renders the viewer: a title, the lines of the file around
+// the cursor with their numbers, and the keys it takes
+func (m Model) fileViewerView() string {
+	viewer := m.viewer
+	width, height := m.viewerSize()
+	first, last := viewer.selection()
+
+	title := viewer.name
+	if viewer.anchor >= 0 {
+		title += fmt.Sprintf("  (%d line(s) selected)", last-first+1)
+	}
+	out := []string{m.theme.Sender.Render(truncateLine(title, width))}
+
+	gutter := len(strconv.Itoa(len(viewer.lines)))
+	lineStyle := lipgloss.NewStyle().MaxWidth(width)
+	end := min(len(viewer.lines), viewer.top+height)
+	for i := viewer.top; i < end; i++ {
+		marker := " "
+		switch {
+		case i == viewer.cursor:
+			marker = ">"
+		case viewer.anchor >= 0 && i >= first && i <= last:
+			marker = "|"
+		}
+		number := m.theme.Time.Render(fmt.Sprintf("%*d", gutter, i+1))
+		out = append(out, lineStyle.Render(fmt.Sprintf("%s%s %s", marker, number, viewer.highlighted[i])))
+	}
+	for i := end - viewer.top; i < height; i++ {
+		out = append(out, "")
+	}
+	out = append(out, m.theme.Time.Render(truncateLine("up/down move, v select, enter attach, esc close", width)))
+	return strings.Join(out, "\n")
+}
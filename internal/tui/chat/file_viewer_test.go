@@ -0,0 +1,127 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindFileRefs(t *testing.T) {
+	refs := findFileRefs("internal/chat/model.go:42:7: undefined: foo, see README.md")
+	require.Len(t, refs, 2)
+	assert.Equal(t, fileRef{path: "internal/chat/model.go", line: 42, start: 0, end: 25}, refs[0])
+	assert.Equal(t, "README.md", refs[1].path)
+	assert.Zero(t, refs[1].line)
+
+	path, line := parseFileArg("cmd/root.go:12")
+	assert.Equal(t, "cmd/root.go", path)
+	assert.Equal(t, 12, line)
+	path, line = parseFileArg("cmd/root.go")
+	assert.Equal(t, "cmd/root.go", path)
+	assert.Zero(t, line)
+}
+
+func TestFileViewer(t *testing.T) {
+	root := t.TempDir()
+	var source []string
+	for i := 1; i <= 100; i++ {
+		source = append(source, fmt.Sprintf("var v%d = %d", i, i))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "pkg"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pkg", "vars.go"), []byte("package pkg\n\n"+strings.Join(source, "\n")+"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "blob.bin"), []byte{'a', 0, 'b'}, 0o644))
+
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider), WithWorkspaceRoot(root))
+	update := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	press := func(keys ...string) {
+		for _, key := range keys {
+			switch key {
+			case "enter":
+				update(tea.KeyMsg{Type: tea.KeyEnter})
+			case "esc":
+				update(tea.KeyMsg{Type: tea.KeyEsc})
+			default:
+				update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+			}
+		}
+	}
+	lastNotice := func() string {
+		messages := model.messageList.GetMessages()
+		return messages[len(messages)-1].text
+	}
+	update(tea.WindowSizeMsg{Width: 100, Height: 40})
+
+	model.openCommand("pkg/vars.go:50")
+	require.NotNil(t, model.viewer)
+	assert.Equal(t, 49, model.viewer.cursor, "the cursor is on the line asked for")
+	view := model.View()
+	assert.Contains(t, stripANSI(view), "pkg/vars.go")
+	assert.Contains(t, stripANSI(view), "> 50 var v48 = 48", "lines are numbered")
+	for _, line := range strings.Split(view, "\n") {
+		assert.LessOrEqual(t, lipgloss.Width(line), 100)
+	}
+
+	press("v", "j", "j", "enter")
+	assert.Nil(t, model.viewer, "attaching closes the viewer")
+	assert.Contains(t, lastNotice(), "Attached pkg/vars.go lines 50-52")
+	require.Len(t, model.fileRanges, 1)
+	assert.Equal(t, "pkg/vars.go lines 50-52:\n```\nvar v48 = 48\nvar v49 = 49\nvar v50 = 50\n```", model.fileRanges[0])
+	prompt := withFileRanges("Explain these", model.fileRanges)
+	assert.True(t, strings.HasPrefix(prompt, "Explain these\n\nContext attached from the workspace:\npkg/vars.go lines 50-52:"))
+
+	model.submitPrompt("Explain these")
+	assert.Empty(t, model.fileRanges, "the ranges are sent once")
+
+	model.messageList.AddMessage(chatMessage{text: "pkg/vars.go:7:2: v5 declared and not used", sender: "System", isToolResult: true})
+	model.openCommand("")
+	require.NotNil(t, model.viewer, "with no file, the last one referenced opens")
+	assert.Equal(t, 6, model.viewer.cursor)
+	press("G")
+	assert.Equal(t, 101, model.viewer.cursor)
+	press("esc")
+	assert.Nil(t, model.viewer)
+
+	for _, bad := range []string{"../outside.go", "missing.go", "pkg", "blob.bin"} {
+		model.openCommand(bad)
+		assert.Nil(t, model.viewer, bad)
+		assert.Contains(t, lastNotice(), "Cannot open "+bad)
+	}
+}
+
+func TestFileViewerClickToOpen(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider), WithWorkspaceRoot(root))
+	updated, _ := model.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	model = updated.(Model)
+	model.messageList.AddMessage(chatMessage{text: "main.go:3: missing return", sender: "System", isToolResult: true})
+
+	top := lipgloss.Height(model.header.View())
+	for row, line := range strings.Split(model.messageList.View(), "\n") {
+		col := strings.Index(stripANSI(line), "main.go:3")
+		if col < 0 {
+			continue
+		}
+		updated, _ = model.Update(tea.MouseMsg{X: col + 1, Y: top + row, Action: tea.MouseActionPress, Button: tea.MouseButtonLeft})
+		model = updated.(Model)
+		break
+	}
+	require.NotNil(t, model.viewer, "clicking a reference opens the file")
+	assert.Equal(t, 2, model.viewer.cursor)
+	assert.Contains(t, stripANSI(model.View()), "main.go")
+}
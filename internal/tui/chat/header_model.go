@@ -55,7 +55,7 @@ func NewHeaderModel(theme *Theme, provider, modelName, sessionID, status string)
 		gitRepo:     gitRepo,
 		sessionTime: time.Now(),
 		width:       50, // Default width
-		multiLine:   true,
+		multiLine:   !theme.Accessible,
 		version:     "v1.0.0", // Could be made configurable
 	}
 }
@@ -93,7 +93,7 @@ func (h *HeaderModel) Update(msg tea.Msg) (*HeaderModel, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		h.width = msg.Width
 		// Enable bordered display for wider terminals with better threshold
-		h.multiLine = msg.Width >= 80 // Lower threshold since we have nice borders
+		h.multiLine = msg.Width >= 80 && !h.theme.Accessible // Lower threshold since we have nice borders
 
 		// Refresh git info on resize in case working directory changed
 		if h.gitRepo {
@@ -7,7 +7,9 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/castrovroberto/CGE/internal/agent"
 	"github.com/castrovroberto/CGE/internal/security"
+	"github.com/castrovroberto/CGE/internal/statecrypt"
 )
 
 // ChatHistory represents the persistent chat history
@@ -21,6 +23,98 @@ type ChatHistory struct {
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 	Command      string                 `json:"command,omitempty"`
 	SystemPrompt string                 `json:"system_prompt,omitempty"`
+
+	// Branch is the branch Messages belong to, and Branches every branch of
+	// the conversation with its messages, once /branch was used
+	Branch   string        `json:"branch,omitempty"`
+	Branches []SavedBranch `json:"branches,omitempty"`
+}
+
+// SavedBranch is a branch of the conversation in the chat history
+type SavedBranch struct {
+	Name     string        `json:"name"`
+	Parent   string        `json:"parent,omitempty"`
+	ForkAt   int           `json:"fork_at"` // Messages of the parent the branch started from
+	Created  time.Time     `json:"created"`
+	MergedAt *time.Time    `json:"merged_at,omitempty"`
+	Messages []chatMessage `json:"messages"`
+}
+
+// savedMessage is a chatMessage as written to the chat history
+type savedMessage struct {
+	Text         string                 `json:"text"`
+	Sender       string                 `json:"sender"`
+	Timestamp    time.Time              `json:"timestamp"`
+	Markdown     bool                   `json:"markdown,omitempty"`
+	Language     string                 `json:"language,omitempty"` // Of a code message
+	ThinkingTime time.Duration          `json:"thinking_time,omitempty"`
+	ToolCall     bool                   `json:"tool_call,omitempty"`
+	ToolResult   bool                   `json:"tool_result,omitempty"`
+	ToolName     string                 `json:"tool_name,omitempty"`
+	ToolCallID   string                 `json:"tool_call_id,omitempty"`
+	ToolSuccess  bool                   `json:"tool_success,omitempty"`
+	ToolDuration time.Duration          `json:"tool_duration,omitempty"`
+	ToolParams   map[string]interface{} `json:"tool_params,omitempty"`
+	Error        bool                   `json:"error,omitempty"`
+	ErrorCode    agent.ToolErrorCode    `json:"error_code,omitempty"`
+}
+
+// MarshalJSON writes a message with its markdown as received
+func (cm chatMessage) MarshalJSON() ([]byte, error) {
+	text := cm.text
+	if cm.rawText != "" {
+		text = cm.rawText
+	}
+	saved := savedMessage{
+		Text:         text,
+		Sender:       cm.sender,
+		Timestamp:    cm.timestamp,
+		Markdown:     cm.isMarkdown,
+		ThinkingTime: cm.ThinkingTime,
+		ToolCall:     cm.isToolCall,
+		ToolResult:   cm.isToolResult,
+		ToolName:     cm.toolName,
+		ToolCallID:   cm.toolCallID,
+		ToolSuccess:  cm.toolSuccess,
+		ToolDuration: cm.toolDuration,
+		ToolParams:   cm.toolParams,
+		Error:        cm.isError,
+		ErrorCode:    cm.errorCode,
+	}
+	if cm.isCode {
+		saved.Language = cm.language
+	}
+	return json.Marshal(saved)
+}
+
+// UnmarshalJSON reads a message written by MarshalJSON
+func (cm *chatMessage) UnmarshalJSON(data []byte) error {
+	var saved savedMessage
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	*cm = chatMessage{
+		text:         saved.Text,
+		isMarkdown:   saved.Markdown,
+		isCode:       saved.Language != "",
+		language:     saved.Language,
+		timestamp:    saved.Timestamp,
+		sender:       saved.Sender,
+		ThinkingTime: saved.ThinkingTime,
+		isToolCall:   saved.ToolCall,
+		isToolResult: saved.ToolResult,
+		toolName:     saved.ToolName,
+		toolCallID:   saved.ToolCallID,
+		toolSuccess:  saved.ToolSuccess,
+		toolDuration: saved.ToolDuration,
+		toolParams:   saved.ToolParams,
+		isError:      saved.Error,
+		errorCode:    saved.ErrorCode,
+	}
+	if saved.Markdown {
+		cm.rawText = saved.Text
+	}
+	return nil
 }
 
 // ToolCallRecord represents a tool call in chat history
@@ -56,12 +150,20 @@ func (m *Model) SaveHistory() error {
 		Metadata:  make(map[string]interface{}),
 		Command:   "chat",
 	}
+	if len(m.branches) > 0 {
+		history.Branch = m.branch
+		history.Branches = m.savedBranches()
+	}
 
 	// Create history directory if it doesn't exist
 	historyDir := filepath.Join(os.Getenv("HOME"), ".cge", "chat_history")
 	if err := os.MkdirAll(historyDir, 0750); err != nil {
 		return fmt.Errorf("failed to create history directory: %w", err)
 	}
+	cipher, err := statecrypt.ForDir(filepath.Dir(historyDir))
+	if err != nil {
+		return err
+	}
 
 	// Create history file with timestamp
 	filename := fmt.Sprintf("chat_%s.json", m.header.GetSessionID())
@@ -73,6 +175,11 @@ func (m *Model) SaveHistory() error {
 		return fmt.Errorf("failed to marshal chat history: %w", err)
 	}
 
+	// Encrypt it when [encryption] is enabled
+	if data, err = cipher.Seal(data); err != nil {
+		return fmt.Errorf("failed to encrypt chat history: %w", err)
+	}
+
 	// Write to file
 	if err := os.WriteFile(filepath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write chat history: %w", err)
@@ -84,6 +191,10 @@ func (m *Model) SaveHistory() error {
 // LoadHistory loads chat history from a file
 func LoadHistory(sessionID string) (*ChatHistory, error) {
 	historyDir := filepath.Join(os.Getenv("HOME"), ".cge", "chat_history")
+	cipher, err := statecrypt.ForDir(filepath.Dir(historyDir))
+	if err != nil {
+		return nil, err
+	}
 	filepath := filepath.Join(historyDir, fmt.Sprintf("chat_%s.json", sessionID))
 
 	// Create safe file operations with history directory as allowed root
@@ -93,6 +204,9 @@ func LoadHistory(sessionID string) (*ChatHistory, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read chat history: %w", err)
 	}
+	if data, err = cipher.Open(data); err != nil {
+		return nil, fmt.Errorf("failed to decrypt chat history: %w", err)
+	}
 
 	var history ChatHistory
 	if err := json.Unmarshal(data, &history); err != nil {
@@ -163,3 +277,29 @@ func LoadLatestHistory() (*ChatHistory, error) {
 
 	return LoadHistory(latestSession)
 }
+
+// PruneHistory deletes the chat histories not saved for longer than maxAge
+// and returns how many it deleted, or would delete with dryRun
+func PruneHistory(maxAge time.Duration, dryRun bool) (int, error) {
+	historyDir := filepath.Join(os.Getenv("HOME"), ".cge", "chat_history")
+	paths, err := filepath.Glob(filepath.Join(historyDir, "chat_*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	pruned := 0
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return pruned, fmt.Errorf("failed to delete chat history: %w", err)
+			}
+		}
+		pruned++
+	}
+	return pruned, nil
+}
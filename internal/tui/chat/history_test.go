@@ -0,0 +1,36 @@
+package chat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	historyDir := filepath.Join(home, ".cge", "chat_history")
+	require.NoError(t, os.MkdirAll(historyDir, 0750))
+
+	old := filepath.Join(historyDir, "chat_old.json")
+	recent := filepath.Join(historyDir, "chat_recent.json")
+	require.NoError(t, os.WriteFile(old, []byte(`{}`), 0600))
+	require.NoError(t, os.WriteFile(recent, []byte(`{}`), 0600))
+	longAgo := time.Now().Add(-60 * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(old, longAgo, longAgo))
+
+	pruned, err := PruneHistory(30*24*time.Hour, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+	assert.FileExists(t, old, "a dry run deletes nothing")
+
+	pruned, err = PruneHistory(30*24*time.Hour, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, recent)
+}
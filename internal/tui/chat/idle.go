@@ -0,0 +1,166 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/i18n"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// modelLoadingNotice is how long the loading notice is shown at most; the
+	// loaded notice replaces it sooner
+	modelLoadingNotice = 2 * time.Minute
+	// modelLoadedNotice is how long the loaded notice is shown
+	modelLoadedNotice = 5 * time.Second
+)
+
+// idleCheckMsg asks whether the chat has gone without input for its idle
+// timeout
+type idleCheckMsg struct{}
+
+// modelUnloadedMsg reports the unloading of the model of an idle chat
+type modelUnloadedMsg struct {
+	model string
+	err   error
+}
+
+// modelLoadedMsg reports the loading of the model once the chat is back
+type modelLoadedMsg struct {
+	model    string
+	duration time.Duration
+	err      error
+}
+
+// isUserInput reports whether msg is the user typing or clicking, which
+// keeps the chat from going idle
+func isUserInput(msg tea.Msg) bool {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		return true
+	case tea.MouseMsg:
+		return msg.Action == tea.MouseActionPress
+	}
+	return false
+}
+
+// scheduleIdleCheck checks whether the chat is idle after d; nil when idle
+// handling is disabled
+func (m *Model) scheduleIdleCheck(d time.Duration) tea.Cmd {
+	if m.idleTimeout <= 0 {
+		return nil
+	}
+	return tea.Tick(d, func(time.Time) tea.Msg {
+		return idleCheckMsg{}
+	})
+}
+
+// checkIdle puts the chat to idle once it has gone without input for its
+// idle timeout, and otherwise checks again when it might have. A run in
+// progress counts as activity.
+func (m *Model) checkIdle() tea.Cmd {
+	if m.idle || m.idleTimeout <= 0 {
+		return nil
+	}
+	if m.loading {
+		m.lastActivity = time.Now()
+	}
+	if remaining := m.idleTimeout - time.Since(m.lastActivity); remaining > 0 {
+		return m.scheduleIdleCheck(remaining)
+	}
+	return m.goIdle()
+}
+
+// goIdle saves the chat history, dims the status bar and unloads the model
+// to free its memory until the user is back
+func (m *Model) goIdle() tea.Cmd {
+	log := logger.For(logger.TUI)
+	m.idle = true
+	m.statusBeforeIdle = m.header.GetStatus()
+	m.header.SetStatus("Idle")
+	m.statusBar.SetIdle(true)
+	if err := m.SaveHistory(); err != nil {
+		log.Warn("Failed to save chat history on idle", "error", err)
+	}
+	if m.modelLoader == nil || m.modelName == "" {
+		log.Info("Chat idle", "idle_timeout", m.idleTimeout)
+		return nil
+	}
+	log.Info("Chat idle, unloading model", "model", m.modelName, "idle_timeout", m.idleTimeout)
+	return unloadModel(m.parentCtx, m.modelLoader, m.modelName)
+}
+
+// markActive records input from the user. When the chat was idle it brightens
+// the status bar again and returns the command loading the model, if it was
+// unloaded, and watching for the next idle period.
+func (m *Model) markActive() tea.Cmd {
+	m.lastActivity = time.Now()
+	if !m.idle {
+		return nil
+	}
+	m.idle = false
+	m.statusBar.SetIdle(false)
+	m.header.SetStatus(m.statusBeforeIdle)
+	check := m.scheduleIdleCheck(m.idleTimeout)
+	if !m.modelUnloaded {
+		return check
+	}
+	return tea.Batch(check, m.warmModel())
+}
+
+// warmModel loads the model again after the chat was idle, so the next
+// prompt does not wait for it
+func (m *Model) warmModel() tea.Cmd {
+	m.modelUnloaded = false
+	logger.For(logger.TUI).Info("Loading model after idle", "model", m.modelName)
+	m.statusBar.SetNotice(i18n.T("status.loading", m.modelName), modelLoadingNotice)
+	return loadModel(m.parentCtx, m.modelLoader, m.modelName)
+}
+
+// handleModelUnloaded records that the model was unloaded, loading it again
+// right away when the user was back before it was
+func (m *Model) handleModelUnloaded(msg modelUnloadedMsg) tea.Cmd {
+	if msg.err != nil {
+		logger.For(logger.TUI).Warn("Failed to unload model on idle", "model", msg.model, "error", msg.err)
+		m.addSystemNotice(fmt.Sprintf("⚠️ Failed to unload %s while idle: %v", msg.model, msg.err))
+		return nil
+	}
+	logger.For(logger.TUI).Info("Unloaded model on idle", "model", msg.model)
+	m.modelUnloaded = true
+	if !m.idle {
+		return m.warmModel()
+	}
+	return nil
+}
+
+// handleModelLoaded shows that the model is ready again
+func (m *Model) handleModelLoaded(msg modelLoadedMsg) {
+	if msg.err != nil {
+		logger.For(logger.TUI).Warn("Failed to load model after idle", "model", msg.model, "error", msg.err)
+		m.statusBar.ClearNotice()
+		m.addSystemNotice(fmt.Sprintf("⚠️ Failed to load %s: %v. It will load with the next prompt.", msg.model, msg.err))
+		return
+	}
+	logger.For(logger.TUI).Info("Loaded model after idle", "model", msg.model, "duration", msg.duration)
+	m.statusBar.SetNotice(i18n.T("status.loaded", msg.model, msg.duration.Seconds()), modelLoadedNotice)
+}
+
+// unloadModel unloads model from the memory of its provider
+func unloadModel(ctx context.Context, loader llm.ModelLoader, model string) tea.Cmd {
+	return func() tea.Msg {
+		return modelUnloadedMsg{model: model, err: loader.UnloadModel(ctx, model)}
+	}
+}
+
+// loadModel loads model into the memory of its provider
+func loadModel(ctx context.Context, loader llm.ModelLoader, model string) tea.Cmd {
+	return func() tea.Msg {
+		start := time.Now()
+		err := loader.LoadModel(ctx, model)
+		return modelLoadedMsg{model: model, duration: time.Since(start), err: err}
+	}
+}
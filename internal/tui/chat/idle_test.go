@@ -0,0 +1,130 @@
+package chat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeModelLoader records the models loaded and unloaded
+type fakeModelLoader struct {
+	loaded   []string
+	unloaded []string
+}
+
+func (l *fakeModelLoader) LoadModel(ctx context.Context, modelName string) error {
+	l.loaded = append(l.loaded, modelName)
+	return nil
+}
+
+func (l *fakeModelLoader) UnloadModel(ctx context.Context, modelName string) error {
+	l.unloaded = append(l.unloaded, modelName)
+	return nil
+}
+
+func newIdleTestModel(t *testing.T, loader *fakeModelLoader, unload bool) Model {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	cfg := &config.AppConfig{}
+	cfg.UI.Chat.IdleTimeout = time.Minute
+	cfg.UI.Chat.UnloadOnIdle = unload
+	provider := NewMockMessageProvider()
+	t.Cleanup(func() { provider.Close() })
+	return NewChatModel(
+		WithParentContext(context.Background()),
+		WithInitialConfig(cfg),
+		WithMessageProvider(provider),
+		WithModelName("llama3"),
+		WithModelLoader(loader),
+	)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	t.Run("not idle before the timeout", func(t *testing.T) {
+		loader := &fakeModelLoader{}
+		model := newIdleTestModel(t, loader, true)
+
+		updated, cmd := model.Update(idleCheckMsg{})
+		m := updated.(Model)
+		assert.False(t, m.idle)
+		assert.NotNil(t, cmd, "checks again once the timeout may have passed")
+	})
+
+	t.Run("a run in progress is not idle", func(t *testing.T) {
+		loader := &fakeModelLoader{}
+		model := newIdleTestModel(t, loader, true)
+		model.lastActivity = time.Now().Add(-2 * time.Minute)
+		model.setLoading(true)
+
+		updated, _ := model.Update(idleCheckMsg{})
+		assert.False(t, updated.(Model).idle)
+	})
+
+	t.Run("idle saves history, unloads the model and loads it on input", func(t *testing.T) {
+		loader := &fakeModelLoader{}
+		model := newIdleTestModel(t, loader, true)
+		model.lastActivity = time.Now().Add(-2 * time.Minute)
+
+		updated, cmd := model.Update(idleCheckMsg{})
+		m := updated.(Model)
+		require.True(t, m.idle)
+		assert.True(t, m.statusBar.idle, "the status bar is dimmed")
+		assert.Equal(t, "Idle", m.header.GetStatus())
+		entries, err := os.ReadDir(filepath.Join(os.Getenv("HOME"), ".cge", "chat_history"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, entries, "the history is saved")
+
+		require.NotNil(t, cmd)
+		unloaded := cmd()
+		assert.Equal(t, []string{"llama3"}, loader.unloaded)
+		updated, _ = m.Update(unloaded)
+		m = updated.(Model)
+		assert.True(t, m.modelUnloaded)
+
+		updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h")})
+		m = updated.(Model)
+		assert.False(t, m.idle)
+		assert.False(t, m.statusBar.idle)
+		assert.Equal(t, "h", m.inputArea.GetValue(), "the key is typed as usual")
+		assert.NotNil(t, cmd)
+		assert.Contains(t, m.statusBar.notice, "loading llama3")
+
+		loaded := loadModel(m.parentCtx, loader, "llama3")()
+		assert.Equal(t, []string{"llama3"}, loader.loaded)
+		updated, _ = m.Update(loaded)
+		m = updated.(Model)
+		assert.Contains(t, m.statusBar.notice, "llama3 loaded")
+	})
+
+	t.Run("model kept loaded when unloading is off", func(t *testing.T) {
+		loader := &fakeModelLoader{}
+		model := newIdleTestModel(t, loader, false)
+		model.lastActivity = time.Now().Add(-2 * time.Minute)
+
+		updated, cmd := model.Update(idleCheckMsg{})
+		assert.True(t, updated.(Model).idle)
+		assert.Nil(t, cmd)
+	})
+
+	t.Run("input before the model is unloaded loads it again", func(t *testing.T) {
+		loader := &fakeModelLoader{}
+		model := newIdleTestModel(t, loader, true)
+		model.lastActivity = time.Now().Add(-2 * time.Minute)
+
+		updated, _ := model.Update(idleCheckMsg{})
+		updated, _ = updated.(Model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h")})
+		updated, cmd := updated.(Model).Update(modelUnloadedMsg{model: "llama3"})
+		m := updated.(Model)
+		assert.False(t, m.modelUnloaded)
+		require.NotNil(t, cmd)
+		assert.IsType(t, modelLoadedMsg{}, cmd())
+		assert.Equal(t, []string{"llama3"}, loader.loaded)
+	})
+}
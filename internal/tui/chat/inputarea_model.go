@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/castrovroberto/CGE/internal/i18n"
 	"github.com/castrovroberto/CGE/internal/logger"
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
@@ -17,6 +18,7 @@ type InputAreaModel struct {
 	suggestions       []string
 	selected          int
 	availableCommands []string
+	completer         func(input string) []string // Suggests slash commands and their arguments
 	isEditing         bool
 	editingIndex      int
 	width             int
@@ -27,7 +29,7 @@ type InputAreaModel struct {
 func NewInputAreaModel(theme *Theme, availableCommands []string) *InputAreaModel {
 	// Initialize textarea with better styling
 	ta := textarea.New()
-	ta.Placeholder = "Type your message... (Ctrl+E to edit last, Tab for completion)"
+	ta.Placeholder = i18n.T("input.placeholder")
 	ta.Focus()
 	ta.Prompt = "┃ "
 	ta.CharLimit = 2000
@@ -88,7 +90,7 @@ func (i *InputAreaModel) Update(msg tea.Msg) (*InputAreaModel, tea.Cmd) {
 	// Sanitize the input to remove any control sequences that might have leaked through
 	sanitizedValue := sanitizeInput(currentValue)
 	if sanitizedValue != currentValue {
-		logger.Get().Debug("Sanitized control sequences from input",
+		logger.For(logger.TUI).Debug("Sanitized control sequences from input",
 			"original_length", len(currentValue),
 			"sanitized_length", len(sanitizedValue))
 		i.textarea.SetValue(sanitizedValue)
@@ -131,12 +133,16 @@ func (i *InputAreaModel) View() string {
 func (i *InputAreaModel) updateSuggestions(input string) {
 	previousSuggestionCount := len(i.suggestions)
 
-	if strings.HasPrefix(input, "/") {
+	if strings.HasPrefix(input, "/") || strings.HasPrefix(input, snippetPrefix) {
 		i.suggestions = nil
 		i.selected = 0
-		for _, cmd := range i.availableCommands {
-			if strings.HasPrefix(cmd, input) {
-				i.suggestions = append(i.suggestions, cmd)
+		if i.completer != nil && strings.HasPrefix(input, "/") {
+			i.suggestions = i.completer(input)
+		} else {
+			for _, cmd := range i.availableCommands {
+				if strings.HasPrefix(cmd, input) {
+					i.suggestions = append(i.suggestions, cmd)
+				}
 			}
 		}
 		// Ensure selected index is valid
@@ -148,7 +154,7 @@ func (i *InputAreaModel) updateSuggestions(input string) {
 
 		// Log suggestion updates for debugging
 		if len(i.suggestions) > 0 {
-			logger.Get().Debug("Updated suggestions", "input", input, "count", len(i.suggestions), "selected", i.selected)
+			logger.For(logger.TUI).Debug("Updated suggestions", "input", input, "count", len(i.suggestions), "selected", i.selected)
 		}
 	} else {
 		i.suggestions = nil
@@ -157,7 +163,7 @@ func (i *InputAreaModel) updateSuggestions(input string) {
 
 	// Log if suggestion count changed
 	if len(i.suggestions) != previousSuggestionCount {
-		logger.Get().Debug("Suggestion count changed",
+		logger.For(logger.TUI).Debug("Suggestion count changed",
 			"previousCount", previousSuggestionCount,
 			"newCount", len(i.suggestions))
 	}
@@ -193,6 +199,12 @@ func (i *InputAreaModel) ApplySelectedSuggestion() bool {
 	return false
 }
 
+// SetCompleter sets the function suggesting completions of slash commands,
+// in place of the available commands
+func (i *InputAreaModel) SetCompleter(completer func(input string) []string) {
+	i.completer = completer
+}
+
 // ClearSuggestions clears all suggestions
 func (i *InputAreaModel) ClearSuggestions() {
 	i.suggestions = nil
@@ -210,6 +222,13 @@ func (i *InputAreaModel) SetValue(value string) {
 	i.lastInputValue = value // Update tracked value
 }
 
+// InsertString inserts text at the cursor
+func (i *InputAreaModel) InsertString(text string) {
+	i.textarea.InsertString(text)
+	i.lastInputValue = i.textarea.Value()
+	i.updateSuggestions(i.lastInputValue)
+}
+
 // Reset resets the textarea
 func (i *InputAreaModel) Reset() {
 	i.textarea.Reset()
@@ -268,7 +287,7 @@ func (i *InputAreaModel) StopEditing() {
 	i.textarea.Blur()
 	i.textarea.Reset()
 	i.lastInputValue = "" // Reset tracked value
-	i.textarea.Placeholder = "Type your message... (Ctrl+E to edit last, Tab for completion)"
+	i.textarea.Placeholder = i18n.T("input.placeholder")
 }
 
 // IsEditing returns true if in editing mode
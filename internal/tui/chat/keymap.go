@@ -0,0 +1,217 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keyAction names a remappable chat action; the names are the keys of the
+// [keybindings] config section
+type keyAction string
+
+const (
+	actionQuit               keyAction = "quit"
+	actionSend               keyAction = "send"
+	actionAcceptSuggestion   keyAction = "accept_suggestion"
+	actionDismissSuggestions keyAction = "dismiss_suggestions"
+	actionSuggestionUp       keyAction = "suggestion_up"
+	actionSuggestionDown     keyAction = "suggestion_down"
+	actionCopyMessage        keyAction = "copy_message"
+	actionCopyCodeBlock      keyAction = "copy_code_block"
+	actionCopyDiff           keyAction = "copy_diff"
+	actionToggleSidebar      keyAction = "toggle_sidebar"
+	actionCodeBlocks         keyAction = "code_blocks"
+)
+
+// keyBinding associates an action with the keys that trigger it
+type keyBinding struct {
+	action      keyAction
+	keys        []string
+	description string
+}
+
+// defaultKeyBindings lists every action in the order shown by /keys
+var defaultKeyBindings = []keyBinding{
+	{actionSend, []string{"enter"}, "Send the message (or apply the selected suggestion)"},
+	{actionQuit, []string{"ctrl+c"}, "Save history and quit"},
+	{actionAcceptSuggestion, []string{"tab"}, "Apply the selected command suggestion"},
+	{actionDismissSuggestions, []string{"esc"}, "Dismiss command suggestions; during a run, cancel it (press twice)"},
+	{actionSuggestionUp, []string{"up"}, "Select the previous suggestion"},
+	{actionSuggestionDown, []string{"down"}, "Select the next suggestion"},
+	{actionCopyMessage, []string{"ctrl+y"}, "Copy the last assistant message"},
+	{actionCopyCodeBlock, []string{"ctrl+g"}, "Copy a code block (repeat for older blocks)"},
+	{actionCopyDiff, []string{"ctrl+x"}, "Copy the most recent diff"},
+	{actionToggleSidebar, []string{"ctrl+b"}, "Show or hide the sidebar of tasks, changed files and running tools"},
+	{actionCodeBlocks, []string{"ctrl+l"}, "Pick a code block to copy, save to a file or apply as a diff"},
+}
+
+// KeyMap resolves key presses to chat actions
+type KeyMap struct {
+	bindings []keyBinding
+	byKey    map[string]keyAction
+}
+
+// DefaultKeyMap returns the built-in key bindings
+func DefaultKeyMap() *KeyMap {
+	km, _ := NewKeyMap(nil) // The defaults never conflict
+	return km
+}
+
+// NewKeyMap applies overrides (action name -> keys, as in the [keybindings]
+// config section) on top of the defaults. It returns an error for unknown
+// actions, empty key lists and keys bound to more than one action.
+func NewKeyMap(overrides map[string][]string) (*KeyMap, error) {
+	bindings := make([]keyBinding, len(defaultKeyBindings))
+	index := make(map[keyAction]int, len(defaultKeyBindings))
+	for i, b := range defaultKeyBindings {
+		bindings[i] = keyBinding{action: b.action, keys: append([]string(nil), b.keys...), description: b.description}
+		index[b.action] = i
+	}
+
+	var problems []string
+	actions := make([]string, 0, len(overrides))
+	for action := range overrides {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions) // Stable error messages
+
+	for _, action := range actions {
+		i, ok := index[keyAction(strings.ToLower(action))]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown action %q", action))
+			continue
+		}
+		var keys []string
+		for _, key := range overrides[action] {
+			key = normalizeKey(key)
+			if key == "" {
+				continue
+			}
+			if strings.ContainsAny(key, " \t") {
+				problems = append(problems, fmt.Sprintf("invalid key %q for %s", key, action))
+				continue
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) == 0 {
+			problems = append(problems, fmt.Sprintf("no keys given for %s", action))
+			continue
+		}
+		bindings[i].keys = keys
+	}
+
+	byKey := make(map[string]keyAction)
+	for _, b := range bindings {
+		for _, key := range b.keys {
+			if other, exists := byKey[key]; exists && other != b.action {
+				problems = append(problems, fmt.Sprintf("key %q is bound to both %s and %s", key, other, b.action))
+				continue
+			}
+			byKey[key] = b.action
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid keybindings: %s", strings.Join(problems, "; "))
+	}
+	return &KeyMap{bindings: bindings, byKey: byKey}, nil
+}
+
+// normalizeKey lowercases a key and maps common aliases to Bubble Tea key names
+func normalizeKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	switch key {
+	case "escape":
+		return "esc"
+	case "return":
+		return "enter"
+	}
+	return key
+}
+
+// Lookup returns the action bound to a key press
+func (km *KeyMap) Lookup(msg tea.KeyMsg) (keyAction, bool) {
+	action, ok := km.byKey[msg.String()]
+	return action, ok
+}
+
+// KeysFor returns the keys bound to action
+func (km *KeyMap) KeysFor(action keyAction) []string {
+	for _, b := range km.bindings {
+		if b.action == action {
+			return b.keys
+		}
+	}
+	return nil
+}
+
+// Hint returns the first key bound to action formatted for display, e.g. "Ctrl+C"
+func (km *KeyMap) Hint(action keyAction) string {
+	keys := km.KeysFor(action)
+	if len(keys) == 0 {
+		return ""
+	}
+	parts := strings.Split(keys[0], "+")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "+")
+}
+
+// Help renders the active bindings as an aligned table
+func (km *KeyMap) Help() string {
+	width := 0
+	for _, b := range km.bindings {
+		if l := len(strings.Join(b.keys, ", ")); l > width {
+			width = l
+		}
+	}
+
+	var sb strings.Builder
+	for _, b := range km.bindings {
+		fmt.Fprintf(&sb, "%-*s  %-20s %s\n", width, strings.Join(b.keys, ", "), b.action, b.description)
+	}
+	return sb.String()
+}
+
+// keyHandler runs an action. handled reports whether the key was consumed;
+// unhandled keys are passed on to the input area.
+type keyHandler func(m *Model, msg tea.KeyMsg) (cmd tea.Cmd, handled bool)
+
+// keyHandlers is the key-dispatch table for the chat model
+var keyHandlers = map[keyAction]keyHandler{
+	actionQuit:               (*Model).quitKey,
+	actionSend:               (*Model).sendKey,
+	actionAcceptSuggestion:   (*Model).acceptSuggestionKey,
+	actionDismissSuggestions: (*Model).dismissSuggestionsKey,
+	actionSuggestionUp: func(m *Model, msg tea.KeyMsg) (tea.Cmd, bool) {
+		return nil, m.inputArea.HandleSuggestionNavigation("up")
+	},
+	actionSuggestionDown: func(m *Model, msg tea.KeyMsg) (tea.Cmd, bool) {
+		return nil, m.inputArea.HandleSuggestionNavigation("down")
+	},
+	actionCopyMessage: func(m *Model, msg tea.KeyMsg) (tea.Cmd, bool) {
+		m.copyLastAssistantMessage()
+		return nil, true
+	},
+	actionCopyCodeBlock: func(m *Model, msg tea.KeyMsg) (tea.Cmd, bool) {
+		m.copyCodeBlock()
+		return nil, true
+	},
+	actionCopyDiff: func(m *Model, msg tea.KeyMsg) (tea.Cmd, bool) {
+		m.copyLastDiff()
+		return nil, true
+	},
+	actionToggleSidebar: func(m *Model, msg tea.KeyMsg) (tea.Cmd, bool) {
+		m.toggleSidebar()
+		return nil, true
+	},
+	actionCodeBlocks: func(m *Model, msg tea.KeyMsg) (tea.Cmd, bool) {
+		return m.blocksCommand(""), true
+	},
+}
@@ -0,0 +1,135 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyMap(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		km := DefaultKeyMap()
+		action, ok := km.Lookup(tea.KeyMsg{Type: tea.KeyCtrlC})
+		assert.True(t, ok)
+		assert.Equal(t, actionQuit, action)
+
+		action, ok = km.Lookup(tea.KeyMsg{Type: tea.KeyEsc})
+		assert.True(t, ok)
+		assert.Equal(t, actionDismissSuggestions, action)
+
+		for _, b := range defaultKeyBindings {
+			assert.Contains(t, keyHandlers, b.action, "every action needs a handler")
+		}
+	})
+
+	t.Run("override replaces default keys", func(t *testing.T) {
+		km, err := NewKeyMap(map[string][]string{"quit": {"Ctrl+Q", "ctrl+d"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ctrl+q", "ctrl+d"}, km.KeysFor(actionQuit))
+		assert.Equal(t, "Ctrl+Q", km.Hint(actionQuit))
+
+		_, ok := km.Lookup(tea.KeyMsg{Type: tea.KeyCtrlC})
+		assert.False(t, ok, "ctrl+c is no longer bound")
+		action, ok := km.Lookup(tea.KeyMsg{Type: tea.KeyCtrlQ})
+		assert.True(t, ok)
+		assert.Equal(t, actionQuit, action)
+	})
+
+	t.Run("aliases are normalized", func(t *testing.T) {
+		km, err := NewKeyMap(map[string][]string{"dismiss_suggestions": {"escape"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"esc"}, km.KeysFor(actionDismissSuggestions))
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		_, err := NewKeyMap(map[string][]string{"copy_diff": {"ctrl+y"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `key "ctrl+y" is bound to both`)
+	})
+
+	t.Run("swapping keys is not a conflict", func(t *testing.T) {
+		_, err := NewKeyMap(map[string][]string{"copy_diff": {"ctrl+y"}, "copy_message": {"ctrl+x"}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("unknown action and empty keys", func(t *testing.T) {
+		_, err := NewKeyMap(map[string][]string{"launch_rockets": {"ctrl+r"}, "send": {" "}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown action "launch_rockets"`)
+		assert.Contains(t, err.Error(), "no keys given for send")
+	})
+}
+
+func TestKeyDispatch(t *testing.T) {
+	t.Run("remapped send key", func(t *testing.T) {
+		km, err := NewKeyMap(map[string][]string{"send": {"ctrl+s"}})
+		require.NoError(t, err)
+
+		provider := NewMockMessageProvider()
+		defer provider.Close()
+		model := NewChatModel(
+			WithParentContext(context.Background()),
+			WithMessageProvider(provider),
+			WithClipboard(&fakeClipboard{}),
+			WithKeyMap(km),
+		)
+
+		model.inputArea.SetValue("hello")
+		updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		model = updated.(Model)
+		assert.Empty(t, provider.GetSentMessages(), "enter is unbound and must not send")
+
+		updated, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		model = updated.(Model)
+		require.Len(t, provider.GetSentMessages(), 1)
+		assert.True(t, strings.HasPrefix(provider.GetSentMessages()[0], "hello"))
+	})
+
+	t.Run("invalid config falls back to defaults", func(t *testing.T) {
+		cfg := &config.AppConfig{}
+		cfg.Keybindings = map[string][]string{"copy_diff": {"ctrl+c"}}
+
+		provider := NewMockMessageProvider()
+		defer provider.Close()
+		model := NewChatModel(
+			WithParentContext(context.Background()),
+			WithInitialConfig(cfg),
+			WithMessageProvider(provider),
+		)
+
+		action, ok := model.keyMap.Lookup(tea.KeyMsg{Type: tea.KeyCtrlC})
+		assert.True(t, ok)
+		assert.Equal(t, actionQuit, action)
+
+		messages := model.messageList.GetMessages()
+		assert.Contains(t, messages[len(messages)-1].text, "invalid keybindings")
+	})
+
+	t.Run("keys overlay", func(t *testing.T) {
+		provider := NewMockMessageProvider()
+		defer provider.Close()
+		model := NewChatModel(
+			WithParentContext(context.Background()),
+			WithMessageProvider(provider),
+		)
+
+		updated, _ := model.Update(tea.WindowSizeMsg{Width: 140, Height: 40})
+		model = updated.(Model)
+		model.inputArea.SetValue("/keys")
+		updated, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		model = updated.(Model)
+		assert.True(t, model.showKeys)
+		assert.Empty(t, provider.GetSentMessages(), "/keys is handled locally")
+		assert.Contains(t, model.View(), "copy_code_block")
+
+		updated, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+		model = updated.(Model)
+		assert.False(t, model.showKeys)
+		assert.Empty(t, model.inputArea.GetValue(), "the closing key is consumed")
+	})
+}
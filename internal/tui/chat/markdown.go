@@ -0,0 +1,147 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	glamourstyles "github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Footnote references and definitions, as in "see the docs[^1]" and
+// "[^1]: https://example.com"
+var (
+	footnoteRefPattern = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+	footnoteDefPattern = regexp.MustCompile(`^\[\^([^\]\s]+)\]:\s*(.*)$`)
+)
+
+// newMarkdownRenderer creates the renderer of assistant markdown for theme,
+// wrapping at width
+func newMarkdownRenderer(theme *Theme, width int) (*glamour.TermRenderer, error) {
+	return glamour.NewTermRenderer(
+		glamour.WithStyles(markdownStyleConfig(theme)),
+		glamour.WithWordWrap(width),
+	)
+}
+
+// markdownStyleConfig derives the markdown style from theme: headings, links
+// and tables in its colors, block quotes muted, and code blocks highlighted
+// like the code blocks of the message list. The accessible theme
+// renders markdown as plain ASCII.
+func markdownStyleConfig(theme *Theme) ansi.StyleConfig {
+	if theme.Accessible {
+		return glamourstyles.ASCIIStyleConfig
+	}
+	style := glamourstyles.LightStyleConfig
+	if lipgloss.HasDarkBackground() {
+		style = glamourstyles.DarkStyleConfig
+	}
+	color := func(c lipgloss.Color) *string {
+		s := string(c)
+		return &s
+	}
+	yes := true
+
+	style.Heading.Color = color(theme.Colors.Primary)
+	style.H1.Color = color(theme.Colors.Secondary)
+	style.H1.BackgroundColor = color(theme.Colors.Primary)
+	style.Link.Color = color(theme.Colors.Primary)
+	style.LinkText.Bold = &yes
+
+	quoteIndent, quoteToken := uint(1), "│ "
+	style.BlockQuote.Indent = &quoteIndent
+	style.BlockQuote.IndentToken = &quoteToken
+	style.BlockQuote.Color = color(theme.Colors.Muted)
+	style.BlockQuote.Italic = &yes
+
+	style.List.LevelIndent = 2
+	style.Task.Ticked = "[✓] "
+	style.Task.Unticked = "[ ] "
+
+	style.Table.Color = color(theme.Colors.Border)
+	centerSeparator, columnSeparator, rowSeparator := "┼", "│", "─"
+	style.Table.CenterSeparator = &centerSeparator
+	style.Table.ColumnSeparator = &columnSeparator
+	style.Table.RowSeparator = &rowSeparator
+
+	style.Code.BackgroundColor = color(theme.Colors.Background)
+	style.CodeBlock.Theme = "monokai"
+	style.CodeBlock.Chroma = nil
+	return style
+}
+
+// withFootnotes rewrites the footnotes of markdown, which the renderer does
+// not support: references become [1], [2], ... in the order they first
+// appear, and the definitions are listed under the text by those numbers.
+// Code blocks are left alone.
+func withFootnotes(markdown string) string {
+	if !strings.Contains(markdown, "[^") {
+		return markdown
+	}
+
+	var (
+		body    []string
+		order   []string
+		notes   = map[string]string{}
+		numbers = map[string]int{}
+		fenced  bool
+		last    string // Definition continued by indented lines
+	)
+	number := func(id string) int {
+		if n, ok := numbers[id]; ok {
+			return n
+		}
+		order = append(order, id)
+		numbers[id] = len(order)
+		return numbers[id]
+	}
+
+	for _, line := range strings.Split(markdown, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+		}
+		if fenced || strings.HasPrefix(strings.TrimSpace(line), "```") {
+			last = ""
+			body = append(body, line)
+			continue
+		}
+		if match := footnoteDefPattern.FindStringSubmatch(line); match != nil {
+			last = match[1]
+			notes[last] = match[2]
+			continue
+		}
+		if last != "" && (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")) {
+			notes[last] += " " + strings.TrimSpace(line)
+			continue
+		}
+		last = ""
+		body = append(body, footnoteRefPattern.ReplaceAllStringFunc(line, func(ref string) string {
+			id := footnoteRefPattern.FindStringSubmatch(ref)[1]
+			return fmt.Sprintf(`\[%d\]`, number(id))
+		}))
+	}
+	if len(notes) == 0 {
+		return markdown
+	}
+
+	// Definitions never referenced are listed after the others, in the
+	// order they were written
+	for _, line := range strings.Split(markdown, "\n") {
+		if match := footnoteDefPattern.FindStringSubmatch(line); match != nil && notes[match[1]] != "" {
+			number(match[1])
+		}
+	}
+	text := strings.TrimRight(strings.Join(body, "\n"), "\n")
+	var list []string
+	for i, id := range order {
+		note, ok := notes[id]
+		if !ok {
+			continue // Referenced but never defined
+		}
+		list = append(list, fmt.Sprintf(`\[%d\] %s`, i+1, note))
+	}
+	return text + "\n\n---\n\n" + strings.Join(list, "\n\n")
+}
@@ -0,0 +1,58 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithFootnotes(t *testing.T) {
+	markdown := "Use the cache[^cache] and retry[^2].\n\n```\nx[^cache]\n```\n\n[^2]: Up to three times.\n[^cache]: See internal/cache,\n    which holds the entries.\n[^extra]: Never referenced."
+	assert.Equal(t,
+		"Use the cache\\[1\\] and retry\\[2\\].\n\n```\nx[^cache]\n```\n\n---\n\n"+
+			"\\[1\\] See internal/cache, which holds the entries.\n\n\\[2\\] Up to three times.\n\n\\[3\\] Never referenced.",
+		withFootnotes(markdown))
+	assert.Equal(t, "A link [^1] without a definition", withFootnotes("A link [^1] without a definition"))
+}
+
+func TestMarkdownRendering(t *testing.T) {
+	theme := NewDefaultTheme()
+	style := markdownStyleConfig(theme)
+	require.NotNil(t, style.Heading.Color)
+	assert.Equal(t, string(theme.Colors.Primary), *style.Heading.Color, "the style follows the theme")
+
+	ml := NewMessageListModel(theme, 80, 60)
+	ml.AddMessage(chatMessage{sender: "Assistant", isMarkdown: true, text: strings.Join([]string{
+		"| Package | Coverage |",
+		"|---------|----------|",
+		"| agent   | 81%      |",
+		"",
+		"- [x] Rename the handler",
+		"- [ ] Fix the callers",
+		"  - cmd/root.go",
+		"",
+		"> Quoted from the docs",
+		"",
+		"Cached[^1].",
+		"",
+		"[^1]: Until the file changes.",
+	}, "\n")})
+
+	view := stripANSI(ml.View())
+	assert.Regexp(t, `Package\s+│\s+Coverage`, view, "tables have columns")
+	assert.Contains(t, view, "┼")
+	assert.Contains(t, view, "[✓] Rename the handler")
+	assert.Contains(t, view, "[ ] Fix the callers")
+	assert.Regexp(t, `│\s{3,}• cmd/root.go`, view, "nested lists are indented")
+	assert.Contains(t, view, "│ Quoted from the docs")
+	assert.Contains(t, view, "Cached[1].")
+	assert.Contains(t, view, "[1] Until the file changes.")
+
+	ml.SetWidth(40)
+	for _, line := range strings.Split(ml.View(), "\n") {
+		assert.LessOrEqual(t, lipgloss.Width(line), 40, "markdown is rewrapped to the new width")
+	}
+}
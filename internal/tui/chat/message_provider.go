@@ -2,7 +2,10 @@ package chat
 
 import (
 	"context"
+	"encoding/json"
 	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
 )
 
 // MessageType represents the type of a chat message
@@ -15,6 +18,9 @@ const (
 	ToolResultMessage // For displaying tool results
 	ErrorMessage
 	SystemMessage
+	ToolProgressMessage // Progress reported by a running tool; Metadata["progress"] holds an agent.ProgressUpdate
+	TaskListMessage     // The agent's task list changed; Metadata["tasks"] holds its []agent.TaskItem
+	FileChangeMessage   // A tool is about to change a file; Metadata["path"] holds its absolute path
 	// Add other types as needed
 )
 
@@ -41,3 +47,82 @@ type MessageProvider interface {
 	// Close allows for cleanup of the message provider resources.
 	Close() error
 }
+
+// Steerer is implemented by message providers that can pass a message to a run
+// in progress, which the agent incorporates on its next step
+type Steerer interface {
+	Steer(message string) error
+}
+
+// Undoer is implemented by message providers that can revert the file changes
+// made while answering the most recent prompt
+type Undoer interface {
+	Undo() (UndoResult, error)
+}
+
+// UndoResult describes a reverted turn
+type UndoResult struct {
+	TurnID string
+	Files  []string // Absolute paths of the files restored
+}
+
+// ModelSwitcher is implemented by message providers that can change the model
+// answering the next prompts
+type ModelSwitcher interface {
+	SetModel(name string) error
+}
+
+// ToolInspector is implemented by message providers that can list the tools of
+// the agent with their session statistics, and turn tools off and on
+type ToolInspector interface {
+	Tools() []ToolInfo
+	SetToolEnabled(name string, enabled bool) error
+}
+
+// ToolInfo describes a tool of the agent and its use this session
+type ToolInfo struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // JSON schema of the arguments
+	Enabled     bool
+	Calls       int
+	Errors      int
+	Duration    time.Duration  // Total time spent in calls
+	MaxDuration time.Duration  // Longest call
+	ErrorCodes  map[string]int // Failed calls by error code
+}
+
+// PlanModer is implemented by message providers with a plan mode, in which
+// the agent may only read the workspace and works out a structured plan with
+// the user before it is executed
+type PlanModer interface {
+	PlanMode() bool
+	SetPlanMode(enabled bool) error
+	CurrentPlan() *agent.Plan
+	ExecutePlan() (prompt string, err error) // Leaves plan mode and returns the prompt that runs the plan
+}
+
+// Comparer is implemented by message providers that can answer a prompt with
+// several models at once, and continue the conversation from the answer the
+// user picks
+type Comparer interface {
+	Compare(ctx context.Context, prompt string, models []string) []ModelAnswer
+	// AdoptAnswer switches to the model of answer; the next prompt continues
+	// from prompt and answer
+	AdoptAnswer(prompt string, answer ModelAnswer) error
+}
+
+// ModelAnswer is the answer of one model to a compared prompt
+type ModelAnswer struct {
+	Model   string
+	Text    string
+	Err     error
+	Latency time.Duration
+	Usage   TokenUsage
+}
+
+// ContextEstimator is implemented by message providers that can estimate how
+// many context tokens a prompt will use before it is sent
+type ContextEstimator interface {
+	EstimateContextTokens(prompt string) int
+}
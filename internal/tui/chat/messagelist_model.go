@@ -10,10 +10,12 @@ import (
 	"github.com/alecthomas/chroma/formatters"
 	"github.com/alecthomas/chroma/lexers"
 	"github.com/alecthomas/chroma/styles"
+	"github.com/castrovroberto/CGE/internal/i18n"
 	"github.com/castrovroberto/CGE/internal/logger"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
 )
 
 // MessageListModel manages the message list and viewport
@@ -37,12 +39,9 @@ func NewMessageListModel(theme *Theme, width, height int) *MessageListModel {
 	vp.Style = theme.ViewportBorder
 
 	// Initialize glamour renderer for markdown
-	renderer, err := glamour.NewTermRenderer(
-		glamour.WithAutoStyle(),
-		glamour.WithWordWrap(vp.Width),
-	)
+	renderer, err := newMarkdownRenderer(theme, vp.Width)
 	if err != nil {
-		logger.Get().Error("Failed to initialize glamour markdown renderer", "error", err)
+		logger.For(logger.TUI).Error("Failed to initialize glamour markdown renderer", "error", err)
 		renderer = nil
 	}
 
@@ -56,7 +55,7 @@ func NewMessageListModel(theme *Theme, width, height int) *MessageListModel {
 		placeholderIndex: -1,
 		renderer:         renderer,
 		formatter:        formatter,
-		progressRenderer: NewProgressRenderer(width),
+		progressRenderer: &ProgressRenderer{width: width, plain: theme.Accessible},
 		activeToolCalls:  make(map[string]*toolProgressState),
 		width:            width,
 		height:           height,
@@ -69,32 +68,9 @@ func (ml *MessageListModel) Update(msg tea.Msg) (*MessageListModel, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		ml.width = msg.Width
 		ml.height = msg.Height
-
-		// Update viewport dimensions
-		wFrame := ml.viewport.Style.GetHorizontalFrameSize()
-		ml.viewport.Width = msg.Width - wFrame
 		ml.viewport.Height = msg.Height
-
-		// Update progress renderer width
-		if ml.progressRenderer != nil {
-			ml.progressRenderer.width = msg.Width
-		}
-
-		// Update glamour renderer for new width
-		if ml.renderer != nil {
-			newRenderer, err := glamour.NewTermRenderer(
-				glamour.WithAutoStyle(),
-				glamour.WithWordWrap(ml.viewport.Width),
-			)
-			if err != nil {
-				logger.Get().Error("Failed to re-initialize glamour renderer on resize", "error", err)
-			} else {
-				ml.renderer = newRenderer
-				ml.rebuildViewport() // Rebuild with new width
-			}
-		}
+		ml.SetWidth(msg.Width)
 
 	case tea.MouseMsg:
 		ml.viewport, cmd = ml.viewport.Update(msg)
@@ -116,13 +92,16 @@ func (ml *MessageListModel) AddMessage(msg chatMessage) {
 
 	// Process code blocks in markdown
 	if msg.isMarkdown {
+		if msg.rawText == "" {
+			msg.rawText = msg.text
+		}
 		msg.text = ml.processCodeBlocks(msg.text)
 	}
 
 	// Validate placeholder state before adding
 	if msg.placeholder {
 		if ml.placeholderIndex >= 0 && ml.placeholderIndex < len(ml.messages) {
-			logger.Get().Warn("Adding placeholder when one already exists",
+			logger.For(logger.TUI).Warn("Adding placeholder when one already exists",
 				"existingIndex", ml.placeholderIndex,
 				"messageCount", len(ml.messages))
 			// Replace existing placeholder instead of adding new one
@@ -137,7 +116,7 @@ func (ml *MessageListModel) AddMessage(msg chatMessage) {
 	// Update placeholder index if this is a placeholder
 	if msg.placeholder {
 		ml.placeholderIndex = len(ml.messages) - 1
-		logger.Get().Debug("Added placeholder message", "index", ml.placeholderIndex)
+		logger.For(logger.TUI).Debug("Added placeholder message", "index", ml.placeholderIndex)
 	}
 
 	ml.rebuildViewport()
@@ -149,22 +128,22 @@ func (ml *MessageListModel) ReplacePlaceholder(msg chatMessage) {
 	if ml.placeholderIndex >= 0 && ml.placeholderIndex < len(ml.messages) {
 		// Verify the message at placeholder index is actually a placeholder
 		if !ml.messages[ml.placeholderIndex].placeholder {
-			logger.Get().Warn("Placeholder index points to non-placeholder message",
+			logger.For(logger.TUI).Warn("Placeholder index points to non-placeholder message",
 				"index", ml.placeholderIndex,
 				"messageType", "non-placeholder")
 		}
 
-		logger.Get().Debug("Replacing placeholder", "index", ml.placeholderIndex, "sender", msg.sender)
+		logger.For(logger.TUI).Debug("Replacing placeholder", "index", ml.placeholderIndex, "sender", msg.sender)
 		ml.messages[ml.placeholderIndex] = msg
 		ml.placeholderIndex = -1 // Reset placeholder index
 	} else {
 		// No valid placeholder to replace
 		if ml.placeholderIndex >= 0 {
-			logger.Get().Warn("Invalid placeholder index, appending message instead",
+			logger.For(logger.TUI).Warn("Invalid placeholder index, appending message instead",
 				"placeholderIndex", ml.placeholderIndex,
 				"messagesLength", len(ml.messages))
 		} else {
-			logger.Get().Debug("No placeholder to replace, appending message",
+			logger.For(logger.TUI).Debug("No placeholder to replace, appending message",
 				"placeholderIndex", ml.placeholderIndex,
 				"messagesLength", len(ml.messages))
 		}
@@ -180,7 +159,7 @@ func (ml *MessageListModel) ReplacePlaceholder(msg chatMessage) {
 func (ml *MessageListModel) rebuildViewport() {
 	defer func() {
 		if r := recover(); r != nil {
-			logger.Get().Error("Panic in rebuildViewport", "panic", r)
+			logger.For(logger.TUI).Error("Panic in rebuildViewport", "panic", r)
 			// Set fallback content
 			ml.viewport.SetContent("Error rendering messages. Please restart the chat.")
 		}
@@ -193,7 +172,7 @@ func (ml *MessageListModel) rebuildViewport() {
 	for i, cm := range ml.messages {
 		// Add index validation (defensive programming)
 		if i < 0 || i >= len(ml.messages) {
-			logger.Get().Warn("Invalid message index in rebuildViewport", "index", i, "length", len(ml.messages))
+			logger.For(logger.TUI).Warn("Invalid message index in rebuildViewport", "index", i, "length", len(ml.messages))
 			continue
 		}
 
@@ -202,11 +181,13 @@ func (ml *MessageListModel) rebuildViewport() {
 			b.WriteString(ml.formatToolCall(cm))
 		} else if cm.isToolResult {
 			b.WriteString(ml.formatToolResult(cm))
+		} else if cm.isError {
+			b.WriteString(ml.formatError(cm))
 		} else if cm.isMarkdown && ml.renderer != nil {
 			// Handle all markdown consistently
-			rendered, err := ml.renderer.Render(cm.text)
+			rendered, err := ml.renderer.Render(withFootnotes(cm.text))
 			if err != nil {
-				logger.Get().Warn("Markdown rendering failed in rebuildViewport", "error", err)
+				logger.For(logger.TUI).Warn("Markdown rendering failed in rebuildViewport", "error", err)
 				// Fall back to regular message formatting
 				b.WriteString(ml.formatRegularMessage(cm))
 			} else {
@@ -230,7 +211,9 @@ func (ml *MessageListModel) rebuildViewport() {
 
 	// Add active progress bars at the bottom
 	if len(ml.activeToolCalls) > 0 {
-		b.WriteString("\n" + strings.Repeat("─", ml.progressRenderer.width) + "\n")
+		if !ml.theme.Accessible {
+			b.WriteString("\n" + strings.Repeat("─", ml.progressRenderer.width) + "\n")
+		}
 		b.WriteString("🔄 Active Operations:\n\n")
 
 		for _, state := range ml.activeToolCalls {
@@ -264,7 +247,7 @@ func (ml *MessageListModel) formatToolCall(msg chatMessage) string {
 	var parts []string
 
 	// Tool call header with icon
-	header := fmt.Sprintf("🔧 Tool Call: %s", msg.toolName)
+	header := i18n.T("tool.call", msg.toolName)
 	if msg.toolCallID != "" {
 		// Safely truncate ID to first 8 chars (or full ID if shorter)
 		idDisplay := msg.toolCallID
@@ -278,7 +261,7 @@ func (ml *MessageListModel) formatToolCall(msg chatMessage) string {
 	// Tool parameters (if any)
 	if len(msg.toolParams) > 0 {
 		paramsJSON, _ := json.MarshalIndent(msg.toolParams, "", "  ")
-		paramText := fmt.Sprintf("Parameters:\n%s", string(paramsJSON))
+		paramText := i18n.T("tool.parameters", string(paramsJSON))
 		parts = append(parts, ml.theme.ToolParams.Render(paramText))
 	}
 
@@ -293,14 +276,16 @@ func (ml *MessageListModel) formatToolResult(msg chatMessage) string {
 	var icon, header string
 	var style = ml.theme.ToolResult
 
+	var guide errorGuide
 	if msg.toolSuccess {
 		icon = "✅"
-		header = fmt.Sprintf("%s Tool Result: %s", icon, msg.toolName)
+		header = i18n.T("tool.result", icon, msg.toolName)
 		style = ml.theme.ToolSuccess
 	} else {
-		icon = "❌"
-		header = fmt.Sprintf("%s Tool Error: %s", icon, msg.toolName)
-		style = ml.theme.ToolError
+		guide = guideFor(msg.errorCode)
+		icon = severityIcons[guide.severity]
+		header = i18n.T("tool.error", icon, msg.toolName, guide.summary)
+		style = ml.severityHeaderStyle(guide.severity)
 	}
 
 	if msg.toolDuration > 0 {
@@ -314,10 +299,35 @@ func (ml *MessageListModel) formatToolResult(msg chatMessage) string {
 		resultContent := ml.theme.ToolResult.Render(msg.text)
 		parts = append(parts, resultContent)
 	}
+	if !msg.toolSuccess {
+		parts = append(parts, ml.theme.ErrorAction.Render("→ "+guide.action))
+	}
 
 	return strings.Join(parts, "\n")
 }
 
+// formatError formats a run error with the description of its code
+func (ml *MessageListModel) formatError(msg chatMessage) string {
+	guide := guideFor(msg.errorCode)
+	senderPrefix := ml.theme.Sender.Render(msg.sender + ": ")
+	timestamp := ml.theme.Time.Render(msg.timestamp.Format("15:04:05"))
+	header := ml.severityHeaderStyle(guide.severity).Render(fmt.Sprintf("%s %s", severityIcons[guide.severity], guide.summary))
+	action := ml.theme.ErrorAction.Render("→ " + guide.action)
+	return fmt.Sprintf("%s %s\n%s\n%s\n%s", senderPrefix, timestamp, header, msg.text, action)
+}
+
+// severityHeaderStyle returns the header style of errors of severity
+func (ml *MessageListModel) severityHeaderStyle(severity errorSeverity) lipgloss.Style {
+	switch severity {
+	case severityWarning:
+		return ml.theme.ToolWarning
+	case severityCritical:
+		return ml.theme.ToolCritical
+	default:
+		return ml.theme.ToolError
+	}
+}
+
 // processCodeBlocks handles syntax highlighting of code blocks in markdown
 func (ml *MessageListModel) processCodeBlocks(text string) string {
 	// Split the text into lines
@@ -388,6 +398,51 @@ func (ml *MessageListModel) highlightCode(code, language string) string {
 	return ml.theme.Code.Render(buf.String())
 }
 
+// RenderOverlay renders content in place of the messages, using the viewport's frame and size
+func (ml *MessageListModel) RenderOverlay(content string) string {
+	style := ml.viewport.Style
+	width, height := ml.OverlaySize()
+	body := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		MaxWidth(width).
+		MaxHeight(height).
+		Render(content)
+	return style.Render(body)
+}
+
+// OverlaySize returns the width and height of the content RenderOverlay shows
+func (ml *MessageListModel) OverlaySize() (int, int) {
+	style := ml.viewport.Style
+	return max(1, ml.viewport.Width-style.GetHorizontalFrameSize()), max(1, ml.viewport.Height-style.GetVerticalFrameSize())
+}
+
+// SetWidth sets the width of the message list, including its frame, and
+// rewraps the messages to it
+func (ml *MessageListModel) SetWidth(width int) {
+	ml.width = width
+
+	// Update viewport dimensions
+	wFrame := ml.viewport.Style.GetHorizontalFrameSize()
+	ml.viewport.Width = width - wFrame
+
+	// Update progress renderer width
+	if ml.progressRenderer != nil {
+		ml.progressRenderer.width = width
+	}
+
+	// Update glamour renderer for new width
+	if ml.renderer != nil {
+		newRenderer, err := newMarkdownRenderer(ml.theme, ml.viewport.Width)
+		if err != nil {
+			logger.For(logger.TUI).Error("Failed to re-initialize glamour renderer on resize", "error", err)
+		} else {
+			ml.renderer = newRenderer
+			ml.rebuildViewport() // Rebuild with new width
+		}
+	}
+}
+
 // SetHeight sets the viewport height
 func (ml *MessageListModel) SetHeight(height int) {
 	ml.height = height
@@ -410,6 +465,18 @@ func (ml *MessageListModel) LoadHistory(messages []chatMessage) {
 	ml.rebuildViewport()
 }
 
+// Truncate removes the messages from index n on
+func (ml *MessageListModel) Truncate(n int) {
+	if n < 0 || n >= len(ml.messages) {
+		return
+	}
+	ml.messages = ml.messages[:n]
+	if ml.placeholderIndex >= n {
+		ml.placeholderIndex = -1
+	}
+	ml.rebuildViewport()
+}
+
 // GetMessages returns the current messages
 func (ml *MessageListModel) GetMessages() []chatMessage {
 	return ml.messages
@@ -438,7 +505,7 @@ func (ml *MessageListModel) validatePlaceholderIndex() bool {
 // resetInvalidPlaceholder resets placeholder index if it's invalid
 func (ml *MessageListModel) resetInvalidPlaceholder() {
 	if !ml.validatePlaceholderIndex() {
-		logger.Get().Warn("Resetting invalid placeholder index",
+		logger.For(logger.TUI).Warn("Resetting invalid placeholder index",
 			"oldIndex", ml.placeholderIndex,
 			"messageCount", len(ml.messages))
 		ml.placeholderIndex = -1
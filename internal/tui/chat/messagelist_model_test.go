@@ -277,3 +277,16 @@ func TestMessageListCodeBlockProcessing(t *testing.T) {
 	view := model.View()
 	assert.NotEmpty(t, view, "View should render without error")
 }
+
+func TestMessageListAccessibleProgress(t *testing.T) {
+	ml := NewMessageListModel(NewAccessibleTheme(), 80, 20)
+	ml.SetActiveToolCalls(map[string]*toolProgressState{
+		"call-1": {toolName: "codebase_search", startTime: time.Now(), progress: 0.4, status: "embedding", step: 2, totalSteps: 5},
+	})
+
+	view := ml.View()
+	assert.Contains(t, view, "Running: codebase_search (2/5) 40% - embedding")
+	assert.NotContains(t, view, "█")
+	assert.NotContains(t, view, "─")
+	assert.NotContains(t, view, "╭")
+}
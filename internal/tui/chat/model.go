@@ -11,9 +11,12 @@ import (
 	// Bubbles components for TUI
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
+	"github.com/castrovroberto/CGE/internal/a11y"
 	"github.com/castrovroberto/CGE/internal/agent"
 	"github.com/castrovroberto/CGE/internal/config" // Ensure this path and package are correct
+	"github.com/castrovroberto/CGE/internal/i18n"
 	"github.com/castrovroberto/CGE/internal/llm"
 
 	// Import the new llm package
@@ -74,6 +77,7 @@ type toolProgressState struct {
 // ProgressRenderer handles rendering of progress bars and status
 type ProgressRenderer struct {
 	width int
+	plain bool // A status line without bar or ticking time, for the accessible theme
 }
 
 // NewProgressRenderer creates a new progress renderer
@@ -86,6 +90,9 @@ func (pr *ProgressRenderer) RenderProgress(state *toolProgressState) string {
 	if state == nil {
 		return ""
 	}
+	if pr.plain {
+		return pr.renderPlain(state)
+	}
 
 	// Progress bar
 	barWidth := pr.width - 20 // Leave space for text
@@ -93,13 +100,20 @@ func (pr *ProgressRenderer) RenderProgress(state *toolProgressState) string {
 		barWidth = 10
 	}
 
-	filled := int(state.progress * float64(barWidth))
-	if filled > barWidth {
-		filled = barWidth
+	var bar string
+	if state.progress < 0 {
+		// Unknown amount of work: a block sweeps across the bar
+		const pulseWidth = 3
+		pos := int(time.Since(state.startTime)/(100*time.Millisecond)) % (barWidth - pulseWidth + 1)
+		bar = strings.Repeat("░", pos) + strings.Repeat("█", pulseWidth) + strings.Repeat("░", barWidth-pulseWidth-pos)
+	} else {
+		filled := int(state.progress * float64(barWidth))
+		if filled > barWidth {
+			filled = barWidth
+		}
+		bar = strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
 	}
 
-	bar := strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
-
 	// Status text
 	elapsed := time.Since(state.startTime)
 	statusText := fmt.Sprintf("🔄 %s", state.toolName)
@@ -108,15 +122,35 @@ func (pr *ProgressRenderer) RenderProgress(state *toolProgressState) string {
 		statusText += fmt.Sprintf(" (%d/%d)", state.step, state.totalSteps)
 	}
 
-	statusText += fmt.Sprintf(" %.1f%% - %s", state.progress*100, state.status)
+	if state.progress >= 0 {
+		statusText += fmt.Sprintf(" %.1f%%", state.progress*100)
+	}
+	statusText += fmt.Sprintf(" - %s", state.status)
 	statusText += fmt.Sprintf(" (%.1fs)", elapsed.Seconds())
 
 	return fmt.Sprintf("%s\n[%s]", statusText, bar)
 }
 
+// renderPlain renders progress as one line that only changes when the
+// progress does, e.g. "Running: index (2/5) 40% - embedding"
+func (pr *ProgressRenderer) renderPlain(state *toolProgressState) string {
+	text := "Running: " + state.toolName
+	if state.totalSteps > 0 {
+		text += fmt.Sprintf(" (%d/%d)", state.step, state.totalSteps)
+	}
+	if state.progress >= 0 {
+		text += fmt.Sprintf(" %.0f%%", state.progress*100)
+	}
+	if state.status != "" {
+		text += " - " + state.status
+	}
+	return text
+}
+
 // chatMessage holds a single chat entry for re-rendering
 type chatMessage struct {
 	text         string
+	rawText      string // Markdown as received, before code highlighting
 	isMarkdown   bool
 	isCode       bool   // New: specifically for code blocks
 	language     string // New: for syntax highlighting
@@ -133,6 +167,10 @@ type chatMessage struct {
 	toolSuccess  bool                   // New: whether tool execution was successful
 	toolDuration time.Duration          // New: how long the tool took to execute
 	toolParams   map[string]interface{} // New: tool parameters for display
+
+	// Errors are shown with the description of their code
+	isError   bool                // A run error, rather than a failed tool call
+	errorCode agent.ToolErrorCode // Code of a run error or failed tool call; empty when unknown
 }
 
 // Add near the top after other type definitions
@@ -151,44 +189,107 @@ type Model struct {
 	statusBar   *StatusBarModel
 
 	// Context and config
-	cfg       *config.AppConfig
-	parentCtx context.Context
+	cfg           *config.AppConfig
+	parentCtx     context.Context
+	modelName     string // Active model shown in the header and status bar
+	workspaceRoot string // Workspace whose git and index state the status bar shows
 
 	// Business logic interfaces (injectable for testing)
 	messageProvider MessageProvider
 	delayProvider   DelayProvider
 	historyService  HistoryService
+	clipboard       ClipboardService
+	keyMap          *KeyMap
 
 	// State management
 	loading           bool
 	thinkingStartTime time.Time
 	chatStartTime     time.Time
 
-	// Available slash commands for suggestions
-	availableCommands []string
+	// Run cancellation
+	cancelRun          context.CancelFunc // Cancels the in-flight agent run, nil when idle
+	cancelConfirmUntil time.Time          // A second cancel key press before this time confirms
+
+	// Idle handling: after idleTimeout without input the chat saves its
+	// history, dims the status bar and unloads the model through modelLoader
+	// when it has one, loading it again on the next key press
+	idleTimeout      time.Duration
+	modelLoader      llm.ModelLoader
+	lastActivity     time.Time
+	idle             bool
+	statusBeforeIdle string // Header status restored on the next input
+	modelUnloaded    bool   // The model was unloaded when the chat went idle
+
+	// Messages sent while a run is in progress
+	queueMode      string   // queueAfterRun or queueSteer
+	queuedMessages []string // Sent in order once the current run finishes
+
+	// turnStarts holds the message list index of each prompt sent, for /undo
+	turnStarts []int
+
+	// commands are the slash commands handled in the TUI
+	commands *CommandRegistry
+
+	// snippets are the prompt templates expanded from #name, by name
+	snippets map[string]string
 
 	// Progress tracking
 	activeToolCalls map[string]*toolProgressState
-}
 
-var defaultSlashCommands = []string{
-	"/help",
-	"/model ", // Suggest space for model name
-	"/clear",
-	"/session ", // Suggest space for session id or action
-	"/status",   // Show current status and statistics
-	"/tools",    // List available tools
-	"/quit",
+	// tasks is the task list of the agent, shown as a checklist above the
+	// input area; viewportHeight is the height of the message list without it
+	tasks          []agent.TaskItem
+	viewportHeight int
+
+	// Sidebar of tasks, files changed this run and running tools, right of
+	// the message list; windowWidth is shared between the two
+	showSidebar  bool
+	windowWidth  int
+	touchedFiles []string // Absolute paths, in the order first changed
+
+	// errorHistory holds the errors shown this session, for /errors
+	errorHistory []errorRecord
+
+	// showKeys displays the /keys overlay in place of the message list
+	showKeys bool
+
+	// viewer shows a workspace file in place of the message list, nil when
+	// closed; fileRanges are the lines attached from it to the next prompt
+	viewer     *fileViewer
+	fileRanges []string
+
+	// blockPicker lists the code blocks of the assistant's responses in place
+	// of the message list, nil when closed
+	blockPicker *blockPicker
+
+	// comparison shows the answers of several models to a prompt in place of
+	// the message list, nil when none is open
+	comparison *comparison
+
+	// branches are the lines of the conversation once /branch was used, nil
+	// before; branch is the one shown. branchExplorer shows them as a tree in
+	// place of the message list, nil when closed.
+	branches       []*chatBranch
+	branch         string
+	branchExplorer *branchExplorer
+
+	// Clipboard state
+	pastedContext      string       // Clipboard content attached to the next prompt by /paste
+	pastes             []pastedText // Large pastes whose chips are in the input or queued
+	pasteCount         int          // Numbers the chips of large pastes
+	codeBlockSelection int          // Index of the code block last copied, -1 when none
 }
 
 // NewChatModel creates a new ChatModel using functional options
 func NewChatModel(opts ...ChatModelOption) Model {
 	// Initialize with default values
 	m := Model{
-		theme:             NewDefaultTheme(),
-		availableCommands: defaultSlashCommands,
-		activeToolCalls:   make(map[string]*toolProgressState),
-		chatStartTime:     time.Now(),
+		queueMode:          queueAfterRun,
+		theme:              NewDefaultTheme(),
+		activeToolCalls:    make(map[string]*toolProgressState),
+		chatStartTime:      time.Now(),
+		lastActivity:       time.Now(),
+		codeBlockSelection: -1,
 	}
 
 	// Apply all provided options
@@ -211,8 +312,31 @@ func NewChatModel(opts ...ChatModelOption) Model {
 	if m.statusBar == nil {
 		m.statusBar = NewStatusBarModel(m.theme, m.chatStartTime)
 	}
+	if m.snippets == nil {
+		var configured map[string]string
+		if m.cfg != nil {
+			configured = m.cfg.UI.Chat.Snippets
+		}
+		m.snippets = loadSnippets(configured)
+	}
+	if m.commands == nil {
+		m.commands = NewCommandRegistry()
+	}
+	if inspector, ok := m.messageProvider.(ToolInspector); ok {
+		if _, registered := m.commands.Lookup("/tools"); !registered {
+			_ = m.commands.Register(toolsCommand(inspector))
+		}
+	}
+	if planner, ok := m.messageProvider.(PlanModer); ok {
+		for _, command := range planCommands(planner) {
+			if _, registered := m.commands.Lookup(command.Name); !registered {
+				_ = m.commands.Register(command)
+			}
+		}
+	}
 	if m.inputArea == nil {
-		m.inputArea = NewInputAreaModel(m.theme, m.availableCommands)
+		m.inputArea = NewInputAreaModel(m.theme, snippetSuggestions(m.snippets))
+		m.inputArea.SetCompleter(m.commands.suggestions)
 	}
 	if m.messageList == nil {
 		m.messageList = NewMessageListModel(m.theme, 50, 10)
@@ -225,15 +349,61 @@ func NewChatModel(opts ...ChatModelOption) Model {
 	if m.delayProvider == nil {
 		m.delayProvider = &RealDelayProvider{}
 	}
+	if m.clipboard == nil {
+		m.clipboard = NewSystemClipboard()
+	}
+
+	var keyMapErr error
+	if m.keyMap == nil {
+		m.keyMap = DefaultKeyMap()
+		if m.cfg != nil && len(m.cfg.Keybindings) > 0 {
+			if km, err := NewKeyMap(m.cfg.Keybindings); err != nil {
+				keyMapErr = err
+				logger.For(logger.TUI).Warn("Ignoring custom keybindings", "error", err)
+			} else {
+				m.keyMap = km
+			}
+		}
+	}
+	if m.cfg != nil && m.cfg.UI.Chat.Sidebar {
+		m.showSidebar = true
+	}
+	if m.cfg != nil {
+		m.idleTimeout = m.cfg.UI.Chat.IdleTimeout
+		if !m.cfg.UI.Chat.UnloadOnIdle {
+			m.modelLoader = nil
+		}
+	}
+	var queueModeErr error
+	if m.cfg != nil && m.cfg.UI.Chat.QueueMode != "" {
+		switch mode := m.cfg.UI.Chat.QueueMode; mode {
+		case queueAfterRun, queueSteer:
+			m.queueMode = mode
+		default:
+			queueModeErr = fmt.Errorf("unknown ui.chat.queue_mode %q (want %q or %q)", mode, queueAfterRun, queueSteer)
+		}
+	}
+
+	m.statusBar.SetKeyHints(m.keyMap.Hint(actionQuit), m.keyMap.Hint(actionAcceptSuggestion), m.keyMap.Hint(actionDismissSuggestions))
+	if m.modelName != "" {
+		m.header.SetModelName(m.modelName)
+		m.statusBar.SetModelName(m.modelName)
+	}
 
 	// Add welcome message
 	welcomeMsg := chatMessage{
-		text:       "Welcome to CGE Chat! Type your message or use '/' for commands.",
+		text:       i18n.T("chat.welcome"),
 		sender:     "System",
 		timestamp:  time.Now(),
 		isMarkdown: false,
 	}
 	m.messageList.AddMessage(welcomeMsg)
+	if keyMapErr != nil {
+		m.addSystemNotice(i18n.T("chat.keymap_invalid", keyMapErr))
+	}
+	if queueModeErr != nil {
+		m.addSystemNotice(fmt.Sprintf("⚠️ %v. Queuing messages until each run finishes.", queueModeErr))
+	}
 
 	return m
 }
@@ -257,6 +427,7 @@ func InitialModel(ctx context.Context, cfg *config.AppConfig, modelName string)
 		ollamaConfig := cfg.GetOllamaConfig()
 		llmClient = llm.NewOllamaClient(ollamaConfig)
 	}
+	llmClient = llm.WithConcurrencyLimits(llmClient, cfg.GetConcurrencyConfig())
 
 	// Create tool registry with chat tools
 	workspaceRoot := cfg.Project.WorkspaceRoot
@@ -271,6 +442,8 @@ func InitialModel(ctx context.Context, cfg *config.AppConfig, modelName string)
 	}
 
 	toolFactory := agent.NewToolFactory(absWorkspaceRoot)
+	toolFactory.SetDatabaseConfig(cfg.GetDatabaseToolConfig())
+	toolFactory.SetDockerConfig(cfg.GetDockerToolConfig())
 	toolRegistry := toolFactory.CreateGenerationRegistry()
 
 	// Create chat presenter with enhanced system prompt that includes context instructions
@@ -280,6 +453,10 @@ func InitialModel(ctx context.Context, cfg *config.AppConfig, modelName string)
 	enhancedSystemPrompt := systemPrompt + "\n\n" + buildContextAwarenessInstructions(absWorkspaceRoot)
 
 	presenter := NewChatPresenter(ctx, llmClient, toolRegistry, enhancedSystemPrompt, modelName)
+	presenter.SetPricing(llm.LookupPricing(cfg.LLM.Provider, modelName))
+	if err := presenter.EnableDelegation(toolFactory); err != nil {
+		logger.For(logger.TUI).Warn("Failed to enable task delegation", "error", err)
+	}
 
 	// Create model with options
 	return NewChatModel(
@@ -287,6 +464,8 @@ func InitialModel(ctx context.Context, cfg *config.AppConfig, modelName string)
 		WithInitialConfig(cfg),
 		WithMessageProvider(presenter),
 		WithDelayProvider(&RealDelayProvider{}),
+		WithModelName(modelName),
+		WithWorkspaceRoot(absWorkspaceRoot),
 	)
 }
 
@@ -294,19 +473,270 @@ func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		m.statusBar.GetSpinnerTickCmd(),
 		m.listenForMessages(), // Start listening for messages from the provider
+		m.refreshWorkspaceStatus(),
+		m.scheduleIdleCheck(m.idleTimeout),
 	)
 }
 
-func (m Model) sendMessage(prompt string) tea.Cmd {
-	// Send the message through the message provider
-	if err := m.messageProvider.Send(m.parentCtx, prompt); err != nil {
+// refreshWorkspaceStatus reloads the git and index state shown in the status
+// bar; it does nothing when no workspace is configured
+func (m Model) refreshWorkspaceStatus() tea.Cmd {
+	if m.workspaceRoot == "" {
+		return nil
+	}
+	return refreshWorkspaceStatus(m.workspaceRoot)
+}
+
+func (m *Model) sendMessage(prompt string) tea.Cmd {
+	// Send the message through the message provider, with a context the user
+	// can cancel from the TUI
+	runCtx, cancel := context.WithCancel(m.parentCtx)
+	if err := m.messageProvider.Send(runCtx, prompt); err != nil {
+		cancel()
+		m.turnStarts = m.turnStarts[:len(m.turnStarts)-1] // Nothing to undo
 		return func() tea.Msg {
 			return errMsg(err)
 		}
 	}
+	m.cancelRun = cancel
 	return nil
 }
 
+// cancelConfirmWindow is how long the cancel key must be pressed again within
+// to confirm cancelling a run
+const cancelConfirmWindow = 3 * time.Second
+
+// requestCancel asks for confirmation on the first press of the cancel key and
+// cancels the in-flight run on the second
+func (m *Model) requestCancel() {
+	if time.Now().Before(m.cancelConfirmUntil) {
+		m.cancelActiveRun()
+		return
+	}
+	m.cancelConfirmUntil = time.Now().Add(cancelConfirmWindow)
+	m.statusBar.SetNotice(fmt.Sprintf("Press %s again to cancel the run", m.keyMap.Hint(actionDismissSuggestions)), cancelConfirmWindow)
+}
+
+// cancelActiveRun cancels the run context, which stops the current tool
+// subprocess, and pauses the session until the user sends a corrective instruction
+func (m *Model) cancelActiveRun() {
+	m.finishRun()
+	m.cancelConfirmUntil = time.Time{}
+	m.statusBar.ClearNotice()
+	m.setLoading(false)
+	m.clearToolProgress()
+	m.header.SetStatus("Paused")
+	m.messageList.ReplacePlaceholder(chatMessage{
+		text:      "⏹ Cancelling the run...",
+		sender:    "System",
+		timestamp: time.Now(),
+	})
+	m.restoreQueuedMessages()
+}
+
+// finishRun releases the context of the in-flight run
+func (m *Model) finishRun() {
+	if m.cancelRun != nil {
+		m.cancelRun()
+		m.cancelRun = nil
+	}
+}
+
+// submitPrompt shows the user's prompt with an assistant placeholder and sends
+// it, together with the large pastes it has chips of, any clipboard content
+// attached by /paste and file lines attached from the viewer
+func (m *Model) submitPrompt(userPrompt string) tea.Cmd {
+	// Start loading state with proper coordination
+	m.setLoading(true)
+	m.header.SetStatus("Running")
+
+	m.turnStarts = append(m.turnStarts, len(m.messageList.GetMessages()))
+	m.touchedFiles = nil
+	displayText := userPrompt
+	if m.pastedContext != "" {
+		displayText += fmt.Sprintf("\n📋 (+%d line(s) of pasted context)", lineCount(m.pastedContext))
+	}
+	if len(m.fileRanges) > 0 {
+		displayText += fmt.Sprintf("\n📄 (+%d range(s) of workspace files)", len(m.fileRanges))
+	}
+	m.messageList.AddMessage(chatMessage{
+		text:      displayText,
+		sender:    "You",
+		timestamp: time.Now(),
+	})
+
+	// Add a placeholder for the assistant response
+	m.messageList.AddMessage(chatMessage{
+		text:        i18n.T("chat.thinking"),
+		sender:      "Assistant",
+		timestamp:   time.Now(),
+		placeholder: true,
+	})
+
+	prompt := withFileRanges(withPastedContext(m.expandPastes(userPrompt), m.pastedContext), m.fileRanges)
+	m.forgetPastes(userPrompt)
+	m.pastedContext = ""
+	m.fileRanges = nil
+	if estimator, ok := m.messageProvider.(ContextEstimator); ok {
+		m.statusBar.SetContextTokens(estimator.EstimateContextTokens(prompt))
+	}
+	m.codeBlockSelection = -1
+	return tea.Batch(m.sendMessage(prompt), m.statusBar.GetSpinnerTickCmd())
+}
+
+// quitKey saves the chat history and quits
+func (m *Model) quitKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	return m.saveAndQuit(msg.String()), true
+}
+
+// saveAndQuit saves the chat history and quits the TUI; trigger is the key or
+// command that asked for it
+func (m *Model) saveAndQuit(trigger string) tea.Cmd {
+	logger.For(logger.TUI).Info("Quit requested, attempting to save chat history and quit TUI.", "trigger", trigger)
+	if err := m.SaveHistory(); err != nil {
+		m.statusBar.SetError(fmt.Errorf("error saving history on quit: %w", err))
+		logger.For(logger.TUI).Error("Failed to save chat history on quit", "error", err)
+	} else {
+		logger.For(logger.TUI).Info("Chat history saved successfully on quit.")
+	}
+	return tea.Quit
+}
+
+// sendKey applies the selected suggestion, runs a local slash command or sends
+// the prompt. It always consumes the key so it never reaches the textarea.
+func (m *Model) sendKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	// If suggestions are active and one is selected, apply it first
+	if m.inputArea.ApplySelectedSuggestion() {
+		// Suggestion was applied, don't send message yet
+		return nil, true
+	}
+
+	if m.inputArea.GetValue() == "" {
+		return nil, true
+	}
+	if cmd, handled := m.handleSlashCommand(m.inputArea.GetValue()); handled {
+		return cmd, true
+	}
+	if cmd, handled := m.handleSnippet(m.inputArea.GetValue()); handled {
+		return cmd, true
+	}
+
+	userPrompt := m.inputArea.GetValue()
+	m.inputArea.Reset()
+	return m.sendOrQueue(userPrompt), true
+}
+
+// Values of the ui.chat.queue_mode setting
+const (
+	queueAfterRun = "after_run" // Send messages typed during a run once it finishes
+	queueSteer    = "steer"     // Inject messages typed during a run into it
+)
+
+// sendOrQueue sends prompt, or handles it according to the queue mode when a
+// run is already in progress
+func (m *Model) sendOrQueue(prompt string) tea.Cmd {
+	if !m.loading {
+		return m.submitPrompt(prompt)
+	}
+	if m.queueMode == queueSteer && m.steer(prompt) {
+		return nil
+	}
+	m.queueMessage(prompt)
+	return nil
+}
+
+// steer passes prompt to the run in progress; it reports false when the
+// message provider cannot steer runs
+func (m *Model) steer(prompt string) bool {
+	steerer, ok := m.messageProvider.(Steerer)
+	if !ok {
+		return false
+	}
+	if err := steerer.Steer(m.expandPastes(prompt)); err != nil {
+		logger.For(logger.TUI).Warn("Failed to steer run, queuing instead", "error", err)
+		return false
+	}
+	m.forgetPastes(prompt)
+	m.messageList.AddMessage(chatMessage{
+		text:      prompt + "\n↪ (passed to the running agent)",
+		sender:    "You",
+		timestamp: time.Now(),
+	})
+	m.messageList.GotoBottom()
+	return true
+}
+
+// queueMessage holds prompt until the current run finishes
+func (m *Model) queueMessage(prompt string) {
+	m.queuedMessages = append(m.queuedMessages, prompt)
+	m.statusBar.SetQueuedCount(len(m.queuedMessages))
+
+	preview, _, multiline := strings.Cut(strings.TrimSpace(prompt), "\n")
+	if len(preview) > 60 {
+		preview = truncateUTF8(preview, 60) + "..."
+	} else if multiline {
+		preview += " ..."
+	}
+	m.addSystemNotice(fmt.Sprintf("⏳ Queued %q; it will be sent when the current run finishes", preview))
+}
+
+// sendNextQueued sends the oldest queued message once no run is in progress
+func (m *Model) sendNextQueued() tea.Cmd {
+	if m.loading || len(m.queuedMessages) == 0 {
+		return nil
+	}
+	next := m.queuedMessages[0]
+	m.queuedMessages = m.queuedMessages[1:]
+	m.statusBar.SetQueuedCount(len(m.queuedMessages))
+	return m.submitPrompt(next)
+}
+
+// requeueSteering queues messages steered at a run that finished before
+// seeing them, so they are sent next; after a cancel they go back into the
+// input like the other queued messages
+func (m *Model) requeueSteering(prompts []string) {
+	m.queuedMessages = append(append([]string{}, prompts...), m.queuedMessages...)
+	m.statusBar.SetQueuedCount(len(m.queuedMessages))
+	if !m.loading {
+		m.restoreQueuedMessages()
+	}
+}
+
+// restoreQueuedMessages moves queued messages back into the input area, so
+// after cancelling a run they can be edited into a correction
+func (m *Model) restoreQueuedMessages() {
+	if len(m.queuedMessages) == 0 {
+		return
+	}
+	restored := strings.Join(m.queuedMessages, "\n\n")
+	if current := m.inputArea.GetValue(); current != "" {
+		restored += "\n\n" + current
+	}
+	m.inputArea.SetValue(restored)
+	m.queuedMessages = nil
+	m.statusBar.SetQueuedCount(0)
+	m.addSystemNotice("Queued messages were moved back to the input so you can edit them into a correction.")
+}
+
+// acceptSuggestionKey applies the selected suggestion, if any
+func (m *Model) acceptSuggestionKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	return nil, m.inputArea.ApplySelectedSuggestion()
+}
+
+// dismissSuggestionsKey clears visible suggestions, if any; otherwise it
+// cancels the in-flight run after confirmation
+func (m *Model) dismissSuggestionsKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	if m.inputArea.HasSuggestions() {
+		m.inputArea.ClearSuggestions()
+		return nil, true
+	}
+	if m.loading && m.cancelRun != nil {
+		m.requestCancel()
+		return nil, true
+	}
+	return nil, false
+}
+
 // listenForMessages creates a command that listens to the message provider's channel
 func (m Model) listenForMessages() tea.Cmd {
 	return func() tea.Msg {
@@ -359,14 +789,33 @@ func convertToTuiMessage(msg ChatMessage) chatMessage {
 		if duration, ok := msg.Metadata["duration"].(time.Duration); ok {
 			tuiMsg.toolDuration = duration
 		}
+		if code, ok := msg.Metadata["error_code"].(agent.ToolErrorCode); ok {
+			tuiMsg.errorCode = code
+		} else if !tuiMsg.toolSuccess {
+			tuiMsg.errorCode = classifyErrorText(msg.Text)
+		}
 	case ErrorMessage:
-		// Error messages are displayed as regular text, but could be styled differently
+		tuiMsg.isError = true
+		if code, ok := msg.Metadata["error_code"].(agent.ToolErrorCode); ok {
+			tuiMsg.errorCode = code
+		} else {
+			tuiMsg.errorCode = classifyErrorText(msg.Text)
+		}
 	}
 
 	return tuiMsg
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Input brings the chat back from idle, loading the model again, before
+	// it is handled as usual
+	if isUserInput(msg) {
+		if wake := m.markActive(); wake != nil {
+			updated, cmd := m.Update(msg)
+			return updated, tea.Batch(wake, cmd)
+		}
+	}
+
 	var cmds []tea.Cmd
 
 	// Update all components
@@ -381,7 +830,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, cmd)
 	}
 
-	m.messageList, cmd = m.messageList.Update(msg)
+	// The message list shares the width of the window with the sidebar
+	listMsg := msg
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		m.windowWidth = size.Width
+		size.Width, _ = m.paneWidths()
+		listMsg = size
+	}
+	m.messageList, cmd = m.messageList.Update(listMsg)
 	if cmd != nil {
 		cmds = append(cmds, cmd)
 	}
@@ -411,9 +867,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.header,
 		)
 		if err != nil {
-			logger.Get().Warn("Layout validation failed", "error", err)
+			logger.For(logger.TUI).Warn("Layout validation failed", "error", err)
 			// In case of layout validation failure, try to use a safe fallback
-			logger.Get().Debug("Layout validation details",
+			logger.For(logger.TUI).Debug("Layout validation details",
 				"windowHeight", msg.Height,
 				"headerHeight", m.header.GetHeight(),
 				"textareaHeight", textareaHeight,
@@ -435,13 +891,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Ensure viewport height is reasonable
 		if viewportHeight < m.layout.GetMinViewportHeight() {
-			logger.Get().Warn("Calculated viewport height is too small, using minimum",
+			logger.For(logger.TUI).Warn("Calculated viewport height is too small, using minimum",
 				"calculated", viewportHeight,
 				"minimum", m.layout.GetMinViewportHeight())
 			viewportHeight = m.layout.GetMinViewportHeight()
 		}
 
-		m.messageList.SetHeight(viewportHeight)
+		m.viewportHeight = viewportHeight
+		m.resizeMessageList()
 
 		// Update input area after header calculations are complete
 		m.inputArea, inputCmd = m.inputArea.Update(msg)
@@ -450,98 +907,58 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case tea.KeyMsg:
-		// Handle key messages
-		switch msg.String() {
-		case "ctrl+c":
-			logger.Get().Info("Ctrl+C pressed, attempting to save chat history and quit TUI.")
-			if err := m.SaveHistory(); err != nil {
-				m.statusBar.SetError(fmt.Errorf("error saving history on Ctrl+C: %w", err))
-				logger.Get().Error("Failed to save chat history on Ctrl+C", "error", err)
-			} else {
-				logger.Get().Info("Chat history saved successfully on Ctrl+C.")
-			}
-			return m, tea.Quit
-
-		case "tab":
-			if m.inputArea.ApplySelectedSuggestion() {
-				// Suggestion was applied, don't pass to input area
-				return m, nil
-			}
-			// No suggestion to apply, let input area handle it
-			m.inputArea, cmd = m.inputArea.Update(msg)
-			if cmd != nil {
-				cmds = append(cmds, cmd)
-			}
-
-		case "escape":
-			if m.inputArea.HasSuggestions() {
-				m.inputArea.ClearSuggestions()
-				return m, nil
-			}
-			// No suggestions to clear, handle as normal escape
-			m.inputArea, cmd = m.inputArea.Update(msg)
-			if cmd != nil {
-				cmds = append(cmds, cmd)
-			}
-
-		case "up":
-			if m.inputArea.HandleSuggestionNavigation("up") {
-				// Navigation handled by input area
-				return m, nil
-			}
-			// No suggestions, let input area handle normally
-			m.inputArea, cmd = m.inputArea.Update(msg)
-			if cmd != nil {
-				cmds = append(cmds, cmd)
-			}
+		// The key overlay is dismissed by any key
+		if m.showKeys {
+			m.showKeys = false
+			return m, nil
+		}
 
-		case "down":
-			if m.inputArea.HandleSuggestionNavigation("down") {
-				// Navigation handled by input area
-				return m, nil
-			}
-			// No suggestions, let input area handle normally
-			m.inputArea, cmd = m.inputArea.Update(msg)
-			if cmd != nil {
-				cmds = append(cmds, cmd)
-			}
+		// Pasted text goes to the input, large pastes as a chip
+		if msg.Paste {
+			return m, m.pasteKey(msg)
+		}
 
-		case "enter":
-			// If suggestions are active and one is selected, apply it first
-			if m.inputArea.ApplySelectedSuggestion() {
-				// Suggestion was applied, don't send message yet
-				return m, nil
+		// The file viewer, block picker, comparison and branch explorer take
+		// the keys but for quitting and the sidebar
+		action, bound := m.keyMap.Lookup(msg)
+		overlay := m.viewer != nil || m.blockPicker != nil || m.comparison != nil || m.branchExplorer != nil
+		if overlay && (!bound || (action != actionQuit && action != actionToggleSidebar)) {
+			switch {
+			case m.viewer != nil:
+				return m, m.viewerKey(msg)
+			case m.blockPicker != nil:
+				return m, m.blockPickerKey(msg)
+			case m.comparison != nil:
+				return m, m.comparisonKey(msg)
 			}
+			return m, m.branchExplorerKey(msg)
+		}
 
-			if m.inputArea.GetValue() != "" && !m.loading {
-				// Start loading state with proper coordination
-				m.setLoading(true)
-
-				userPrompt := m.inputArea.GetValue()
-				m.messageList.AddMessage(chatMessage{
-					text:      userPrompt,
-					sender:    "You",
-					timestamp: time.Now(),
-				})
-
-				// Add a placeholder for the assistant response
-				m.messageList.AddMessage(chatMessage{
-					text:        "Thinking...",
-					sender:      "Assistant",
-					timestamp:   time.Now(),
-					placeholder: true,
-				})
-
-				m.inputArea.Reset()
-				return m, tea.Batch(m.sendMessage(userPrompt), m.statusBar.GetSpinnerTickCmd())
+		// Dispatch bound keys to their action; anything else goes to the input area
+		if bound {
+			if handler, ok := keyHandlers[action]; ok {
+				if cmd, handled := handler(&m, msg); handled {
+					return m, cmd
+				}
 			}
+		}
+		m.inputArea, cmd = m.inputArea.Update(msg)
+		if cmd != nil {
+			cmds = append(cmds, cmd)
+		}
 
-		default:
-			// Pass other keys to input area
-			m.inputArea, cmd = m.inputArea.Update(msg)
-			if cmd != nil {
-				cmds = append(cmds, cmd)
+	case tea.MouseMsg:
+		// The wheel scrolls the file viewer; a click on a file reference opens it
+		if m.viewer != nil {
+			_, height := m.viewerSize()
+			switch msg.Button {
+			case tea.MouseButtonWheelUp:
+				m.viewer.moveCursor(-3, height)
+			case tea.MouseButtonWheelDown:
+				m.viewer.moveCursor(3, height)
 			}
+		} else if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
+			m.openClickedRef(msg.X, msg.Y)
 		}
 
 	case ollamaSuccessResponseMsg:
@@ -586,7 +1003,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// Tool call message handlers
 	case toolStartMsg:
-		logger.Get().Info("Tool call started", "toolCallID", msg.toolCallID, "toolName", msg.toolName)
+		logger.For(logger.TUI).Info("Tool call started", "toolCallID", msg.toolCallID, "toolName", msg.toolName)
 
 		// Create new tool progress state
 		m.activeToolCalls[msg.toolCallID] = &toolProgressState{
@@ -615,7 +1032,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.updateToolCallState()
 
 	case toolProgressMsg:
-		logger.Get().Debug("Tool call progress update", "toolCallID", msg.toolCallID, "progress", msg.progress, "status", msg.status)
+		logger.For(logger.TUI).Debug("Tool call progress update", "toolCallID", msg.toolCallID, "progress", msg.progress, "status", msg.status)
 
 		// Update existing tool progress state
 		if state, exists := m.activeToolCalls[msg.toolCallID]; exists {
@@ -627,21 +1044,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Update components with latest progress using centralized method
 			m.updateToolCallState()
 		} else {
-			logger.Get().Warn("Received progress for unknown tool call", "toolCallID", msg.toolCallID)
+			logger.For(logger.TUI).Warn("Received progress for unknown tool call", "toolCallID", msg.toolCallID)
 		}
 
 	case toolCompleteMsg:
-		logger.Get().Info("Tool call completed", "toolCallID", msg.toolCallID, "success", msg.success, "duration", msg.duration)
+		logger.For(logger.TUI).Info("Tool call completed", "toolCallID", msg.toolCallID, "success", msg.success, "duration", msg.duration)
 
 		// Remove from active tool calls
 		delete(m.activeToolCalls, msg.toolCallID)
 
 		// Add tool result message to chat
-		var resultText string
-		if msg.success {
-			resultText = msg.result
-		} else {
-			resultText = fmt.Sprintf("Tool execution failed: %s", msg.error)
+		resultText := msg.result
+		var errorCode agent.ToolErrorCode
+		if !msg.success {
+			resultText = msg.error
+			errorCode = classifyErrorText(msg.error)
+			m.recordError(msg.toolName, errorCode, msg.error)
 		}
 
 		toolResultMsg := chatMessage{
@@ -653,15 +1071,34 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			toolCallID:   msg.toolCallID,
 			toolSuccess:  msg.success,
 			toolDuration: msg.duration,
+			errorCode:    errorCode,
 		}
 		m.messageList.AddMessage(toolResultMsg)
 
 		// Update components with updated active tool calls using centralized method
 		m.updateToolCallState()
+		cmds = append(cmds, m.refreshWorkspaceStatus())
+
+	case workspaceStatusMsg:
+		m.statusBar.SetWorkspaceStatus(msg.status)
+
+	case idleCheckMsg:
+		cmds = append(cmds, m.checkIdle())
+
+	case modelUnloadedMsg:
+		cmds = append(cmds, m.handleModelUnloaded(msg))
+
+	case modelLoadedMsg:
+		m.handleModelLoaded(msg)
+
+	case comparisonMsg:
+		m.comparisonAnswered(msg)
 
 	case chatMsgWrapper:
 		// Handle new messages from the MessageProvider
 		chatMessage := msg.ChatMessage
+		m.lastActivity = time.Now() // A working agent keeps the chat from going idle
+		var refresh, next tea.Cmd
 		switch chatMessage.Type {
 		case UserMessage:
 			// User messages are typically added when sending, but could be echoed back
@@ -669,9 +1106,24 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case AssistantMessage:
 			m.setLoading(false) // Stop loading when we receive assistant response
 			m.messageList.ReplacePlaceholder(convertToTuiMessage(chatMessage))
+			m.clearToolProgress()
+			m.finishRun()
+			m.header.SetStatus("Ready")
+			if usage, ok := chatMessage.Metadata["usage"].(TokenUsage); ok {
+				m.statusBar.AddUsage(usage)
+			}
+			refresh = m.refreshWorkspaceStatus()
+			next = m.sendNextQueued()
 		case ErrorMessage:
+			tuiMsg := convertToTuiMessage(chatMessage)
+			m.recordError("agent", tuiMsg.errorCode, chatMessage.Text)
 			m.setError(fmt.Errorf("%s", chatMessage.Text))
-			m.messageList.ReplacePlaceholder(convertToTuiMessage(chatMessage))
+			m.messageList.ReplacePlaceholder(tuiMsg)
+			m.clearToolProgress()
+			m.finishRun()
+			m.header.SetStatus("Ready")
+			refresh = m.refreshWorkspaceStatus()
+			next = m.sendNextQueued()
 		case ToolCallMessage:
 			// Display tool call attempt
 			m.messageList.AddMessage(convertToTuiMessage(chatMessage))
@@ -680,15 +1132,36 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case ToolResultMessage:
 			// Display tool result
-			m.messageList.AddMessage(convertToTuiMessage(chatMessage))
+			tuiMsg := convertToTuiMessage(chatMessage)
+			if !tuiMsg.toolSuccess {
+				m.recordError(tuiMsg.toolName, tuiMsg.errorCode, chatMessage.Text)
+			}
+			m.messageList.AddMessage(tuiMsg)
 			m.updateToolCallState()
+			refresh = m.refreshWorkspaceStatus() // Tools may have edited files
 		case SystemMessage:
 			// Display system messages
 			m.messageList.AddMessage(convertToTuiMessage(chatMessage))
+			if unused, ok := chatMessage.Metadata["unused_steering"].([]string); ok {
+				m.requeueSteering(unused)
+			}
+		case ToolProgressMessage:
+			if update, ok := chatMessage.Metadata["progress"].(agent.ProgressUpdate); ok {
+				m.applyToolProgress(update)
+			}
+			return m, m.listenForMessages() // Progress does not move the message list
+		case TaskListMessage:
+			tasks, _ := chatMessage.Metadata["tasks"].([]agent.TaskItem)
+			m.setTasks(tasks)
+		case FileChangeMessage:
+			if path, ok := chatMessage.Metadata["path"].(string); ok {
+				m.addTouchedFile(path)
+			}
+			return m, m.listenForMessages() // Nothing changes in the message list
 		}
 		m.messageList.GotoBottom()
 		// Return a new command to continue listening
-		return m, m.listenForMessages()
+		return m, tea.Batch(m.listenForMessages(), refresh, next)
 
 	default:
 		// Update input area for other messages
@@ -708,10 +1181,33 @@ func (m Model) View() string {
 	view.WriteString(m.header.View())
 	view.WriteString("\n")
 
-	// Message List (viewport)
-	view.WriteString(m.messageList.View())
+	// Message List (viewport), or the key binding overlay, file viewer, block
+	// picker, comparison or branch explorer, and the sidebar
+	panes := m.messageList.View()
+	switch {
+	case m.showKeys:
+		panes = m.messageList.RenderOverlay(m.keysOverlay())
+	case m.viewer != nil:
+		panes = m.messageList.RenderOverlay(m.fileViewerView())
+	case m.blockPicker != nil:
+		panes = m.messageList.RenderOverlay(m.blockPickerView())
+	case m.comparison != nil:
+		panes = m.messageList.RenderOverlay(m.comparisonView())
+	case m.branchExplorer != nil:
+		panes = m.messageList.RenderOverlay(m.branchExplorerView())
+	}
+	if m.sidebarWidth() > 0 {
+		panes = lipgloss.JoinHorizontal(lipgloss.Top, panes, m.sidebar(lipgloss.Height(panes)))
+	}
+	view.WriteString(panes)
 	view.WriteString("\n")
 
+	// Task list of the agent, when it keeps one
+	if panel := m.taskPanel(); panel != "" {
+		view.WriteString(panel)
+		view.WriteString("\n")
+	}
+
 	// Input Area (textarea + suggestions)
 	view.WriteString(m.inputArea.View())
 	view.WriteString("\n")
@@ -719,6 +1215,10 @@ func (m Model) View() string {
 	// Status Bar
 	view.WriteString(m.statusBar.View())
 
+	if m.theme.Accessible {
+		// Emoji in messages, notices and tool output become words or nothing
+		return a11y.Plain(view.String())
+	}
 	return view.String()
 }
 
@@ -727,6 +1227,7 @@ func (m *Model) LoadHistory(history *ChatHistory) {
 	m.header.SetSessionID(history.SessionID)
 	m.header.SetModelName(history.ModelName)
 	m.messageList.LoadHistory(history.Messages)
+	m.loadBranches(history)
 }
 
 func formatToolDescriptions(tools []map[string]interface{}) string {
@@ -749,6 +1250,13 @@ func (m *Model) setLoading(loading bool) {
 	m.statusBar.SetLoading(loading)
 }
 
+// setModelName shows name as the active model
+func (m *Model) setModelName(name string) {
+	m.modelName = name
+	m.header.SetModelName(name)
+	m.statusBar.SetModelName(name)
+}
+
 // setError sets error state and clears loading
 func (m *Model) setError(err error) {
 	m.loading = false
@@ -757,6 +1265,41 @@ func (m *Model) setError(err error) {
 }
 
 // updateToolCallState updates tool call state consistently across components
+// applyToolProgress updates the progress display of a running tool call and
+// removes it once the call is done
+func (m *Model) applyToolProgress(update agent.ProgressUpdate) {
+	if update.Done {
+		delete(m.activeToolCalls, update.CallID)
+		m.updateToolCallState()
+		return
+	}
+
+	state, exists := m.activeToolCalls[update.CallID]
+	if !exists {
+		state = &toolProgressState{
+			toolName:     update.ToolName,
+			startTime:    time.Now(),
+			messageIndex: -1,
+		}
+		m.activeToolCalls[update.CallID] = state
+	}
+	state.progress = update.Progress
+	state.status = update.Status
+	state.step = update.Step
+	state.totalSteps = update.TotalSteps
+	m.updateToolCallState()
+}
+
+// clearToolProgress drops the progress of every tool call when a run ends, in
+// case a final update was lost
+func (m *Model) clearToolProgress() {
+	if len(m.activeToolCalls) == 0 {
+		return
+	}
+	m.activeToolCalls = make(map[string]*toolProgressState)
+	m.updateToolCallState()
+}
+
 func (m *Model) updateToolCallState() {
 	m.statusBar.SetActiveToolCalls(len(m.activeToolCalls))
 	m.messageList.SetActiveToolCalls(m.activeToolCalls)
@@ -781,7 +1324,7 @@ func (m *Model) updateStatusBarState() {
 func (m *Model) validateState() bool {
 	// Check tool call state consistency
 	if len(m.activeToolCalls) < 0 {
-		logger.Get().Warn("Negative active tool call count")
+		logger.For(logger.TUI).Warn("Negative active tool call count")
 		return false
 	}
 
@@ -790,7 +1333,7 @@ func (m *Model) validateState() bool {
 
 // debugLayoutInfo logs detailed layout information for troubleshooting
 func (m *Model) debugLayoutInfo(windowWidth, windowHeight, textareaHeight, suggestionAreaHeight int) {
-	logger.Get().Debug("Layout debug info",
+	logger.For(logger.TUI).Debug("Layout debug info",
 		"windowWidth", windowWidth,
 		"windowHeight", windowHeight,
 		"headerHeight", m.layout.GetHeaderHeight(),
@@ -805,7 +1348,7 @@ func (m *Model) debugLayoutInfo(windowWidth, windowHeight, textareaHeight, sugge
 
 // debugLayoutInfoWithHeader logs detailed layout information for troubleshooting with dynamic header height
 func (m *Model) debugLayoutInfoWithHeader(windowWidth, windowHeight, textareaHeight, suggestionAreaHeight int) {
-	logger.Get().Debug("Layout debug info with dynamic header height",
+	logger.For(logger.TUI).Debug("Layout debug info with dynamic header height",
 		"windowWidth", windowWidth,
 		"windowHeight", windowHeight,
 		"headerHeight", m.header.GetHeight(),
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/llm"
 )
 
 // ChatModelOption is a functional option for configuring ChatModel
@@ -37,6 +38,20 @@ func WithHistoryService(service HistoryService) ChatModelOption {
 	}
 }
 
+// WithClipboard sets the clipboard service for the chat model
+func WithClipboard(service ClipboardService) ChatModelOption {
+	return func(m *Model) {
+		m.clipboard = service
+	}
+}
+
+// WithKeyMap sets the key bindings for the chat model
+func WithKeyMap(keyMap *KeyMap) ChatModelOption {
+	return func(m *Model) {
+		m.keyMap = keyMap
+	}
+}
+
 // WithParentContext sets the parent context for the chat model
 func WithParentContext(ctx context.Context) ChatModelOption {
 	return func(m *Model) {
@@ -51,9 +66,33 @@ func WithInitialConfig(cfg *config.AppConfig) ChatModelOption {
 	}
 }
 
-// WithAvailableCommands sets the available slash commands for the chat model
-func WithAvailableCommands(commands []string) ChatModelOption {
+// WithModelName sets the active model shown in the header and status bar
+func WithModelName(name string) ChatModelOption {
+	return func(m *Model) {
+		m.modelName = name
+	}
+}
+
+// WithWorkspaceRoot sets the workspace whose git branch and index freshness
+// are shown in the status bar
+func WithWorkspaceRoot(root string) ChatModelOption {
+	return func(m *Model) {
+		m.workspaceRoot = root
+	}
+}
+
+// WithCommands sets the slash commands of the chat model; a registry from
+// NewCommandRegistry holds the built-in ones
+func WithCommands(commands *CommandRegistry) ChatModelOption {
+	return func(m *Model) {
+		m.commands = commands
+	}
+}
+
+// WithModelLoader sets the client unloading the model when the chat goes idle
+// and loading it again on the next input
+func WithModelLoader(loader llm.ModelLoader) ChatModelOption {
 	return func(m *Model) {
-		m.availableCommands = commands
+		m.modelLoader = loader
 	}
 }
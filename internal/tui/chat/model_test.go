@@ -2,12 +2,22 @@ package chat
 
 import (
 	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/castrovroberto/CGE/internal/agent"
 	"github.com/castrovroberto/CGE/internal/config"
+	"github.com/castrovroberto/CGE/internal/httpclient"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/castrovroberto/CGE/internal/logger"
+	"github.com/castrovroberto/CGE/internal/orchestrator"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLayoutDimensionHelpers(t *testing.T) {
@@ -273,6 +283,29 @@ func TestToolCallMessageHandling(t *testing.T) {
 		assert.Equal(t, 4, state.totalSteps, "Total steps should be updated")
 	})
 
+	t.Run("tool_progress_from_provider", func(t *testing.T) {
+		provider := NewMockMessageProvider()
+		defer provider.Close()
+		model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+
+		progress := func(update agent.ProgressUpdate) ChatMessage {
+			return ChatMessage{Type: ToolProgressMessage, Metadata: map[string]interface{}{"progress": update}}
+		}
+
+		updatedModel, _ := model.Update(chatMsgWrapper{progress(agent.ProgressUpdate{
+			ToolName: "run_shell_command", CallID: "call-1", Progress: -1, Status: "ok  ./pkg", Step: 3,
+		})})
+		m := updatedModel.(Model)
+		require.Contains(t, m.activeToolCalls, "call-1", "progress for an unseen call starts tracking it")
+		assert.Equal(t, "ok  ./pkg", m.activeToolCalls["call-1"].status)
+		assert.Contains(t, m.messageList.progressRenderer.RenderProgress(m.activeToolCalls["call-1"]), "ok  ./pkg")
+		assert.NotContains(t, m.messageList.progressRenderer.RenderProgress(m.activeToolCalls["call-1"]), "%", "indeterminate progress has no percentage")
+
+		updatedModel, _ = m.Update(chatMsgWrapper{progress(agent.ProgressUpdate{CallID: "call-1", Done: true})})
+		m = updatedModel.(Model)
+		assert.Empty(t, m.activeToolCalls)
+	})
+
 	t.Run("tool_complete_message_success", func(t *testing.T) {
 		// Create a fresh test model for this test
 		cfg := &config.AppConfig{}
@@ -397,3 +430,270 @@ func TestToolCallMessageHandling(t *testing.T) {
 		assert.Contains(t, m.activeToolCalls, "tool-2", "Second tool should still be active")
 	})
 }
+
+// ctxRecordingProvider records the context each prompt was sent with
+type ctxRecordingProvider struct {
+	*MockMessageProvider
+	ctxs []context.Context
+}
+
+func (p *ctxRecordingProvider) Send(ctx context.Context, prompt string) error {
+	p.ctxs = append(p.ctxs, ctx)
+	return p.MockMessageProvider.Send(ctx, prompt)
+}
+
+func TestCancelRun(t *testing.T) {
+	provider := &ctxRecordingProvider{MockMessageProvider: NewMockMessageProvider()}
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+
+	model.inputArea.SetValue("refactor everything")
+	updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model = updated.(Model)
+	require.Len(t, provider.ctxs, 1)
+	runCtx := provider.ctxs[0]
+	require.True(t, model.loading)
+
+	// The first press asks for confirmation
+	updated, _ = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	model = updated.(Model)
+	assert.NoError(t, runCtx.Err())
+	assert.True(t, model.loading)
+	assert.Contains(t, model.statusBar.View(), "Press Esc again to cancel")
+
+	// The second press cancels the run
+	updated, _ = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	model = updated.(Model)
+	assert.ErrorIs(t, runCtx.Err(), context.Canceled)
+	assert.False(t, model.loading)
+	assert.Equal(t, "Paused", model.header.GetStatus())
+	messages := model.messageList.GetMessages()
+	assert.Contains(t, messages[len(messages)-1].text, "Cancelling")
+
+	// Esc does nothing once idle
+	updated, _ = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	model = updated.(Model)
+	assert.False(t, model.loading)
+}
+
+func TestCorrectionPrompt(t *testing.T) {
+	interrupted := []orchestrator.Message{
+		{Role: "system", Content: "You are helpful"},
+		{Role: "user", Content: "rename Foo to Bar everywhere"},
+		{Role: "assistant", ToolCall: &llm.FunctionCall{Name: "search_replace", Arguments: json.RawMessage(`{"search":"Foo"}`)}},
+		{Role: "tool", Content: "replaced 40 occurrences"},
+	}
+
+	prompt := correctionPrompt(interrupted, "only in the api package")
+	assert.Contains(t, prompt, "cancelled your previous run")
+	assert.Contains(t, prompt, "rename Foo to Bar everywhere")
+	assert.Contains(t, prompt, `- search_replace({"search":"Foo"})`)
+	assert.True(t, strings.HasSuffix(prompt, "only in the api package"))
+}
+
+// steeringProvider records steering messages
+type steeringProvider struct {
+	*MockMessageProvider
+	steered []string
+}
+
+func (p *steeringProvider) Steer(message string) error {
+	p.steered = append(p.steered, message)
+	return nil
+}
+
+func TestQueuedMessages(t *testing.T) {
+	send := func(model Model, text string) Model {
+		model.inputArea.SetValue(text)
+		updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		return updated.(Model)
+	}
+
+	t.Run("after run", func(t *testing.T) {
+		provider := &steeringProvider{MockMessageProvider: NewMockMessageProvider()}
+		defer provider.Close()
+		model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+
+		model = send(model, "first")
+		model = send(model, "second")
+		model = send(model, "third")
+		assert.Len(t, provider.GetSentMessages(), 1, "messages typed during a run are queued")
+		assert.Equal(t, []string{"second", "third"}, model.queuedMessages)
+		assert.Empty(t, provider.steered)
+		assert.Contains(t, model.statusBar.View(), "2 queued")
+
+		updated, _ := model.Update(chatMsgWrapper{ChatMessage{Type: AssistantMessage, Text: "done"}})
+		model = updated.(Model)
+		require.Len(t, provider.GetSentMessages(), 2)
+		assert.Equal(t, "second", provider.GetSentMessages()[1])
+		assert.True(t, model.loading, "the queued message starts the next run")
+		assert.Equal(t, []string{"third"}, model.queuedMessages)
+	})
+
+	t.Run("steer mode", func(t *testing.T) {
+		cfg := &config.AppConfig{}
+		cfg.UI.Chat.QueueMode = "steer"
+		provider := &steeringProvider{MockMessageProvider: NewMockMessageProvider()}
+		defer provider.Close()
+		model := NewChatModel(WithParentContext(context.Background()), WithInitialConfig(cfg), WithMessageProvider(provider))
+
+		model = send(model, "first")
+		model = send(model, "use the v2 API instead")
+		assert.Equal(t, []string{"use the v2 API instead"}, provider.steered)
+		assert.Empty(t, model.queuedMessages)
+	})
+
+	t.Run("steering the run did not see is sent next", func(t *testing.T) {
+		cfg := &config.AppConfig{}
+		cfg.UI.Chat.QueueMode = "steer"
+		provider := &steeringProvider{MockMessageProvider: NewMockMessageProvider()}
+		defer provider.Close()
+		model := NewChatModel(WithParentContext(context.Background()), WithInitialConfig(cfg), WithMessageProvider(provider))
+
+		model = send(model, "first")
+		model = send(model, "also update the changelog")
+		updated, _ := model.Update(chatMsgWrapper{ChatMessage{Type: SystemMessage, Metadata: map[string]interface{}{
+			"unused_steering": []string{"also update the changelog"},
+		}}})
+		model = updated.(Model)
+		assert.Equal(t, []string{"also update the changelog"}, model.queuedMessages)
+
+		updated, _ = model.Update(chatMsgWrapper{ChatMessage{Type: AssistantMessage, Text: "done"}})
+		model = updated.(Model)
+		require.Len(t, provider.GetSentMessages(), 2)
+		assert.Equal(t, "also update the changelog", provider.GetSentMessages()[1])
+	})
+
+	t.Run("steer command without steering support", func(t *testing.T) {
+		provider := NewMockMessageProvider()
+		defer provider.Close()
+		model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+
+		model = send(model, "first")
+		model = send(model, "/steer stop editing tests")
+		assert.Equal(t, []string{"stop editing tests"}, model.queuedMessages, "falls back to queuing")
+	})
+
+	t.Run("cancel restores queued messages", func(t *testing.T) {
+		provider := NewMockMessageProvider()
+		defer provider.Close()
+		model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+
+		model = send(model, "first")
+		model = send(model, "second")
+		model.cancelActiveRun()
+		assert.Empty(t, model.queuedMessages)
+		assert.Equal(t, "second", model.inputArea.GetValue())
+	})
+}
+
+// undoingProvider records undo requests and returns a canned result
+type undoingProvider struct {
+	*MockMessageProvider
+	undos  int
+	result UndoResult
+}
+
+func (p *undoingProvider) Undo() (UndoResult, error) {
+	p.undos++
+	return p.result, nil
+}
+
+func TestUndoCommand(t *testing.T) {
+	send := func(model Model, text string) Model {
+		model.inputArea.SetValue(text)
+		updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		return updated.(Model)
+	}
+	reply := func(model Model, text string) Model {
+		updated, _ := model.Update(chatMsgWrapper{ChatMessage{Type: AssistantMessage, Text: text}})
+		return updated.(Model)
+	}
+
+	provider := &undoingProvider{
+		MockMessageProvider: NewMockMessageProvider(),
+		result:              UndoResult{Files: []string{"/work/main.go"}},
+	}
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider), WithWorkspaceRoot("/work"))
+
+	model = send(model, "/undo")
+	assert.Zero(t, provider.undos)
+	assert.Contains(t, lastMessageText(model), "Nothing to undo")
+
+	model = reply(send(model, "add a main function"), "added it")
+	before := len(model.messageList.GetMessages())
+	model = send(model, "now add tests")
+	model = send(model, "/undo")
+	assert.Zero(t, provider.undos, "a run is in progress")
+	assert.Contains(t, lastMessageText(model), "Wait for the run to finish")
+
+	model = reply(model, "added tests")
+	model = send(model, "/undo")
+	assert.Equal(t, 1, provider.undos)
+	messages := model.messageList.GetMessages()
+	assert.Len(t, messages, before+1, "the turn is replaced by the undo notice")
+	assert.Equal(t, "↩ Removed the last turn and restored 1 file(s): main.go", messages[len(messages)-1].text)
+	assert.Equal(t, "added it", messages[len(messages)-2].text)
+}
+
+func TestDebugCommand(t *testing.T) {
+	httpclient.ConfigureDebugLog(filepath.Join(t.TempDir(), "llm-http.log"), 0)
+	t.Cleanup(func() {
+		_ = httpclient.SetDebugLogging(false)
+		httpclient.ConfigureDebugLog(httpclient.DefaultDebugLogFile, httpclient.DefaultDebugLogMaxBody)
+	})
+
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	send := func(text string) string {
+		model.inputArea.SetValue(text)
+		updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		model = updated.(Model)
+		return lastMessageText(model)
+	}
+
+	assert.Contains(t, send("/debug"), "Usage: /debug llm [on|off]")
+	assert.Contains(t, send("/debug llm"), "logging is off")
+	assert.Contains(t, send("/debug llm on"), "llm-http.log")
+	enabled, _ := httpclient.DebugLogging()
+	assert.True(t, enabled)
+	assert.Contains(t, send("/debug llm off"), "logging is off")
+	enabled, _ = httpclient.DebugLogging()
+	assert.False(t, enabled)
+}
+
+func TestLogCommand(t *testing.T) {
+	t.Cleanup(func() { _ = logger.SetLevel(logger.Orchestrator, "default") })
+
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	send := func(text string) string {
+		model.inputArea.SetValue(text)
+		updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		model = updated.(Model)
+		return lastMessageText(model)
+	}
+
+	assert.Contains(t, send("/log orchestrator debug"), "orchestrator now logs at debug")
+	level, own := logger.Level(logger.Orchestrator)
+	assert.True(t, own)
+	assert.Equal(t, slog.LevelDebug, level)
+	levels := send("/log")
+	assert.Contains(t, levels, "orchestrator  debug\n")
+	assert.Contains(t, levels, "tools")
+	assert.Contains(t, send("/log network debug"), "unknown subsystem")
+	assert.Contains(t, send("/log llm loud"), "unknown log level")
+	assert.Contains(t, send("/log llm"), "Usage: /log")
+	assert.Contains(t, send("/log orchestrator default"), "orchestrator now logs at")
+	_, own = logger.Level(logger.Orchestrator)
+	assert.False(t, own)
+}
+
+// lastMessageText returns the text of the newest message in the conversation
+func lastMessageText(model Model) string {
+	messages := model.messageList.GetMessages()
+	return messages[len(messages)-1].text
+}
@@ -0,0 +1,98 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Pastes longer than these are attached to the prompt as a whole, with a chip
+// standing for them in the input, rather than typed into the input
+const (
+	largePasteLines = 15
+	largePasteChars = 1000
+)
+
+// pastedText is a large paste attached to the prompt whose chip is in it
+type pastedText struct {
+	chip string // Placeholder in the input, e.g. "[Pasted text #1, 500 lines]"
+	text string
+}
+
+// pasteKey handles a bracketed paste. Short pastes go into the input as they
+// are; large ones are kept aside and a chip standing for them is put in the
+// input, so the textarea neither clips them nor slows down.
+func (m *Model) pasteKey(msg tea.KeyMsg) tea.Cmd {
+	text := normalizePaste(string(msg.Runes))
+	if text == "" {
+		return nil
+	}
+	lines := lineCount(text)
+	if lines <= largePasteLines && len([]rune(text)) <= largePasteChars {
+		m.inputArea.InsertString(text)
+		return nil
+	}
+
+	truncated := len(text) > maxPasteBytes
+	if truncated {
+		text = truncateUTF8(text, maxPasteBytes)
+		m.addSystemNotice(fmt.Sprintf("📋 The paste was truncated to %d KB", maxPasteBytes/1024))
+	}
+	m.pasteCount++
+	paste := pastedText{
+		chip: fmt.Sprintf("[Pasted text #%d, %d lines]", m.pasteCount, lineCount(text)),
+		text: text,
+	}
+	m.pastes = append(m.pastes, paste)
+	m.inputArea.InsertString(paste.chip)
+	return nil
+}
+
+// normalizePaste turns the line endings of pasted text into "\n" and drops
+// the trailing newline; indentation, tabs included, is kept as it is
+func normalizePaste(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.TrimRight(text, "\n")
+}
+
+// expandPastes appends the text of each large paste whose chip is in prompt
+// as a fenced block; the chips stay, naming the blocks
+func (m *Model) expandPastes(prompt string) string {
+	for _, paste := range m.pastes {
+		if !strings.Contains(prompt, paste.chip) {
+			continue
+		}
+		label := strings.TrimSuffix(strings.TrimPrefix(paste.chip, "["), "]")
+		fence := codeFence(paste.text)
+		prompt += fmt.Sprintf("\n\n%s:\n%s\n%s\n%s", label, fence, paste.text, fence)
+	}
+	return prompt
+}
+
+// forgetPastes drops the large pastes whose chip is in prompt, once sent
+func (m *Model) forgetPastes(prompt string) {
+	kept := m.pastes[:0]
+	for _, paste := range m.pastes {
+		if !strings.Contains(prompt, paste.chip) {
+			kept = append(kept, paste)
+		}
+	}
+	m.pastes = kept
+}
+
+// codeFence returns a fence longer than any run of backticks in text, so the
+// text cannot close its block early
+func codeFence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBracketedPaste(t *testing.T) {
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	update := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	paste := func(text string) {
+		update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text), Paste: true})
+	}
+
+	paste("func a() {\r\n    return\r\n}\r\n")
+	assert.Equal(t, "func a() {\n    return\n}", model.inputArea.GetValue(), "short pastes keep their lines and indentation")
+	assert.Empty(t, provider.GetSentMessages(), "the newlines of a paste do not send it")
+
+	model.inputArea.Reset()
+	var trace []string
+	for i := 0; i < 500; i++ {
+		trace = append(trace, fmt.Sprintf("\tat frame%d (main.go:%d)", i, i))
+	}
+	update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("Why does this fail? ")})
+	paste(strings.Join(trace, "\n") + "\n")
+	assert.Equal(t, "Why does this fail? [Pasted text #1, 500 lines]", model.inputArea.GetValue(), "large pastes become a chip")
+
+	update(tea.KeyMsg{Type: tea.KeyEnter})
+	sent := provider.GetSentMessages()
+	require.Len(t, sent, 1)
+	assert.True(t, strings.HasPrefix(sent[0], "Why does this fail? [Pasted text #1, 500 lines]\n\nPasted text #1, 500 lines:\n```\n\tat frame0 (main.go:0)\n"), "the paste is sent with the prompt, tabs and all")
+	assert.True(t, strings.HasSuffix(sent[0], "\tat frame499 (main.go:499)\n```"))
+	assert.Empty(t, model.pastes, "a paste is sent once")
+	messages := model.messageList.GetMessages()
+	assert.NotContains(t, messages[len(messages)-2].text, "frame0", "the conversation shows the chip only")
+}
+
+func TestLargePasteSteered(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.UI.Chat.QueueMode = "steer"
+	provider := &steeringProvider{MockMessageProvider: NewMockMessageProvider()}
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithInitialConfig(cfg), WithMessageProvider(provider))
+	model.setLoading(true)
+
+	model.pasteKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(strings.Repeat("```go\nx := 1\n```\n", 10)), Paste: true})
+	model.sendOrQueue(model.inputArea.GetValue())
+	require.Len(t, provider.steered, 1)
+	assert.Contains(t, provider.steered[0], "Pasted text #1, 30 lines:\n````\n```go\n", "the fence outlasts the backticks of the paste")
+	assert.Empty(t, model.pastes)
+}
+
+func TestCodeFence(t *testing.T) {
+	assert.Equal(t, "```", codeFence("no backticks"))
+	assert.Equal(t, "````", codeFence("```go\n```"))
+	assert.Equal(t, "`````", codeFence("````"))
+}
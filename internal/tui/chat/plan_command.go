@@ -0,0 +1,99 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// planCommands returns the /plan and /execute slash commands of planner.
+// /plan switches plan mode, in which the agent only reads the workspace and
+// works out a plan with the user, and shows or saves the plan; /execute
+// leaves plan mode and has the agent implement the plan.
+func planCommands(planner PlanModer) []SlashCommand {
+	return []SlashCommand{
+		{
+			Name:     "/plan",
+			Usage:    "[on | off | show | save <file>]",
+			Help:     "Plan with a read-only agent before changing anything, or show or save the plan",
+			Complete: completeFrom("on", "off", "show", "save "),
+			Run: func(c *CommandContext, args string) tea.Cmd {
+				action, file, _ := strings.Cut(strings.TrimSpace(args), " ")
+				switch action {
+				case "":
+					setPlanMode(c, planner, !planner.PlanMode())
+				case "on", "off":
+					setPlanMode(c, planner, action == "on")
+				case "show":
+					if plan := planner.CurrentPlan(); plan != nil {
+						c.Notice("📋 " + plan.Markdown())
+					} else {
+						c.Notice("📋 No plan yet; /plan enters plan mode, where the agent drafts one")
+					}
+				case "save":
+					savePlan(c, planner, strings.TrimSpace(file))
+				default:
+					c.Notice("📋 Usage: /plan [on | off | show | save <file>]")
+				}
+				return nil
+			},
+		},
+		{
+			Name: "/execute",
+			Help: "Leave plan mode and have the agent implement the plan",
+			Run: func(c *CommandContext, args string) tea.Cmd {
+				if c.Busy() {
+					c.Notice("📋 Wait for the run to finish, or cancel it, before executing the plan")
+					return nil
+				}
+				prompt, err := planner.ExecutePlan()
+				if err != nil {
+					c.Notice(fmt.Sprintf("📋 %v", err))
+					return nil
+				}
+				c.SetPlanMode(false)
+				c.Notice("📋 Plan mode off; all tools are available again. Executing the plan...")
+				return c.Send(prompt)
+			},
+		},
+	}
+}
+
+// setPlanMode enters or leaves plan mode
+func setPlanMode(c *CommandContext, planner PlanModer, enabled bool) {
+	if err := planner.SetPlanMode(enabled); err != nil {
+		c.Notice(fmt.Sprintf("📋 %v", err))
+		return
+	}
+	c.SetPlanMode(enabled)
+	if enabled {
+		c.Notice("📋 Plan mode on: the agent can only read the workspace, and records its plan for you to review. Discuss it, then /execute runs it; /plan off leaves plan mode.")
+	} else {
+		c.Notice("📋 Plan mode off; all tools are available again. /plan show shows the last plan.")
+	}
+}
+
+// savePlan writes the plan as JSON, in the format 'CGE generate --plan' reads
+func savePlan(c *CommandContext, planner PlanModer, file string) {
+	if file == "" {
+		c.Notice("📋 Usage: /plan save <file>, e.g. /plan save plan.json")
+		return
+	}
+	plan := planner.CurrentPlan()
+	if plan == nil {
+		c.Notice("📋 No plan to save yet")
+		return
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err == nil {
+		err = os.WriteFile(file, append(data, '\n'), 0600)
+	}
+	if err != nil {
+		c.Notice(fmt.Sprintf("📋 Failed to save the plan: %v", err))
+		return
+	}
+	c.Notice(fmt.Sprintf("📋 Saved the plan to %s; 'CGE generate --plan %s' runs it", file, file))
+}
@@ -0,0 +1,103 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// planningProvider is a message provider with a plan mode
+type planningProvider struct {
+	*MockMessageProvider
+	planMode bool
+	plan     *agent.Plan
+}
+
+func (p *planningProvider) PlanMode() bool {
+	return p.planMode
+}
+
+func (p *planningProvider) SetPlanMode(enabled bool) error {
+	p.planMode = enabled
+	return nil
+}
+
+func (p *planningProvider) CurrentPlan() *agent.Plan {
+	return p.plan
+}
+
+func (p *planningProvider) ExecutePlan() (string, error) {
+	if p.plan == nil {
+		return "", errors.New("no plan yet")
+	}
+	p.planMode = false
+	return "Implement the plan: " + p.plan.OverallGoal, nil
+}
+
+func TestPlanCommands(t *testing.T) {
+	provider := &planningProvider{MockMessageProvider: NewMockMessageProvider()}
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	model.statusBar.Update(tea.WindowSizeMsg{Width: 200})
+	send := func(text string) (string, tea.Cmd) {
+		model.inputArea.SetValue(text)
+		updated, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		model = updated.(Model)
+		return lastMessageText(model), cmd
+	}
+
+	text, _ := send("/plan")
+	assert.Contains(t, text, "Plan mode on")
+	assert.True(t, provider.planMode)
+	assert.Contains(t, model.statusBar.View(), "plan mode")
+	text, _ = send("/plan show")
+	assert.Contains(t, text, "No plan yet")
+	text, _ = send("/execute")
+	assert.Contains(t, text, "no plan yet")
+
+	provider.plan = &agent.Plan{OverallGoal: "Add rate limiting", Tasks: []agent.PlanTask{{ID: "task-1", Description: "Add a limiter middleware"}}}
+	text, _ = send("/plan show")
+	assert.Contains(t, text, "## Plan: Add rate limiting")
+
+	file := filepath.Join(t.TempDir(), "plan.json")
+	text, _ = send("/plan save " + file)
+	assert.Contains(t, text, "CGE generate --plan "+file)
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var saved agent.Plan
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.Equal(t, *provider.plan, saved)
+
+	_, cmd := send("/execute")
+	require.NotNil(t, cmd, "the plan is sent to the agent")
+	assert.False(t, provider.planMode)
+	assert.NotContains(t, model.statusBar.View(), "plan mode")
+	var texts []string
+	for _, message := range model.messageList.GetMessages() {
+		texts = append(texts, message.text)
+	}
+	assert.Contains(t, texts, "Implement the plan: Add rate limiting")
+
+	text, _ = send("/plan off")
+	assert.Contains(t, text, "Plan mode off")
+	text, _ = send("/plan nope")
+	assert.Contains(t, text, "Usage: /plan")
+}
+
+func TestPlanCommandsNeedPlanModer(t *testing.T) {
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	_, ok := model.commands.Lookup("/plan")
+	assert.False(t, ok)
+	_, ok = model.commands.Lookup("/execute")
+	assert.False(t, ok)
+}
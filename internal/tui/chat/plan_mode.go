@@ -0,0 +1,171 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+)
+
+// planModeInstructions are added to the system prompt in plan mode
+const planModeInstructions = `## Plan Mode
+
+You are in plan mode: work out a development plan with the user before anything is changed. Only read-only tools are available; do not try to change files or run commands that do.
+
+1. Investigate the code the request touches with the read-only tools.
+2. Record a complete, structured plan with the submit_plan tool.
+3. Answer with a short summary of the plan and the questions the user should decide.
+
+When the user asks for changes, submit the revised plan again in full. The user runs the plan with /execute, which hands it to an agent that can change files.`
+
+// registerPlanTool adds submit_plan to the tools of the agent, disabled until
+// plan mode is entered
+func (p *ChatPresenter) registerPlanTool() {
+	if p.toolRegistry == nil {
+		return
+	}
+	if _, ok := p.toolRegistry.Get(agent.SubmitPlanToolName); !ok {
+		if err := p.toolRegistry.Register(agent.NewSubmitPlanTool()); err != nil {
+			return
+		}
+	}
+	_ = p.agentRunner.SetToolEnabled(agent.SubmitPlanToolName, false)
+}
+
+// PlanMode implements PlanModer
+func (p *ChatPresenter) PlanMode() bool {
+	return p.planMode.Load()
+}
+
+// SetPlanMode implements PlanModer. Entering plan mode leaves the agent only
+// its read-only tools and submit_plan, and tells it to plan; leaving it
+// restores the tools and system prompt. It fails while a run is in progress.
+func (p *ChatPresenter) SetPlanMode(enabled bool) error {
+	if !p.runMu.TryLock() {
+		return errors.New("a run is in progress; wait for it to finish or cancel it")
+	}
+	defer p.runMu.Unlock()
+	if enabled {
+		return p.enterPlanMode()
+	}
+	p.leavePlanMode()
+	return nil
+}
+
+// enterPlanMode switches the agent to planning. The caller holds runMu.
+func (p *ChatPresenter) enterPlanMode() error {
+	if p.planMode.Load() {
+		return nil
+	}
+	if p.toolRegistry == nil {
+		return errors.New("the agent has no tools to plan with")
+	}
+	p.planDisabled = make(map[string]bool)
+	for _, tool := range p.toolRegistry.List() {
+		name := tool.Name()
+		if agent.ReadOnlyTool(name) || !p.agentRunner.ToolEnabled(name) {
+			continue
+		}
+		if err := p.agentRunner.SetToolEnabled(name, false); err == nil {
+			p.planDisabled[name] = true
+		}
+	}
+	_ = p.agentRunner.SetToolEnabled(agent.SubmitPlanToolName, true)
+	p.agentRunner.SetSystemPrompt(p.systemPrompt + "\n\n" + planModeInstructions)
+	p.planDraft.Clear()
+	p.planRequests = nil
+	p.interrupted = nil // A cancelled run was not a planning run
+	p.planMode.Store(true)
+	return nil
+}
+
+// leavePlanMode gives the agent back the tools and system prompt it had
+// before plan mode. The plan is kept for /plan show and /plan save. The
+// caller holds runMu.
+func (p *ChatPresenter) leavePlanMode() {
+	if !p.planMode.Load() {
+		return
+	}
+	for name := range p.planDisabled {
+		_ = p.agentRunner.SetToolEnabled(name, true)
+	}
+	p.planDisabled = nil
+	_ = p.agentRunner.SetToolEnabled(agent.SubmitPlanToolName, false)
+	p.agentRunner.SetSystemPrompt(p.systemPrompt)
+	p.planRequests = nil
+	p.interrupted = nil
+	p.planMode.Store(false)
+}
+
+// CurrentPlan implements PlanModer
+func (p *ChatPresenter) CurrentPlan() *agent.Plan {
+	plan, _ := p.planDraft.Plan()
+	return plan
+}
+
+// ExecutePlan implements PlanModer: plan mode is left, and the prompt that
+// has the agent implement the plan is returned
+func (p *ChatPresenter) ExecutePlan() (string, error) {
+	if !p.runMu.TryLock() {
+		return "", errors.New("a run is in progress; wait for it to finish or cancel it")
+	}
+	defer p.runMu.Unlock()
+	plan, _ := p.planDraft.Plan()
+	if plan == nil {
+		return "", errors.New("no plan yet; ask the agent for one in plan mode")
+	}
+	p.leavePlanMode()
+	return executionPrompt(plan), nil
+}
+
+// planningPrompt gives a planning run what earlier runs of plan mode did,
+// since runs do not share their conversation: the requests of the user and
+// the current plan
+func (p *ChatPresenter) planningPrompt(prompt string) string {
+	var b strings.Builder
+	if len(p.planRequests) > 0 {
+		b.WriteString("Earlier messages of the user in this planning session:\n")
+		for _, request := range p.planRequests {
+			fmt.Fprintf(&b, "- %s\n", request)
+		}
+		b.WriteString("\n")
+	}
+	if plan, revision := p.planDraft.Plan(); plan != nil {
+		fmt.Fprintf(&b, "Current plan (revision %d), which the user is reviewing:\n\n%s\n", revision, plan.Markdown())
+	}
+	p.planRequests = append(p.planRequests, prompt)
+	if b.Len() == 0 {
+		return prompt
+	}
+	b.WriteString("Message of the user:\n" + prompt)
+	return b.String()
+}
+
+// reportPlan shows the plan when the run revised it since revision
+func (p *ChatPresenter) reportPlan(revision int, turnID string) {
+	plan, current := p.planDraft.Plan()
+	if plan == nil || current == revision {
+		return
+	}
+	p.sendMessage(ChatMessage{
+		ID:        p.generateID(),
+		Type:      SystemMessage,
+		Sender:    "System",
+		Text:      fmt.Sprintf("📋 Plan revision %d\n\n%s\n/execute runs it, /plan save <file> saves it for 'CGE generate --plan'.", current, plan.Markdown()),
+		Timestamp: time.Now(),
+		Metadata: map[string]interface{}{
+			"turn_id":       turnID,
+			"plan_revision": current,
+		},
+	})
+}
+
+// executionPrompt asks the agent to implement plan
+func executionPrompt(plan *agent.Plan) string {
+	var b strings.Builder
+	b.WriteString("Implement the plan below, which the user approved. Work through the tasks in order, respecting their dependencies, and verify the changes as you go. Report what you changed when done, and any step you had to deviate from.\n\n")
+	b.WriteString(plan.Markdown())
+	return b.String()
+}
@@ -0,0 +1,182 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedClient answers with its responses in turn, then with a final text,
+// and records the requests it is given
+type scriptedClient struct {
+	mu            sync.Mutex
+	responses     []*llm.FunctionCallResponse
+	prompts       []string
+	systemPrompts []string
+	tools         [][]string
+}
+
+func (c *scriptedClient) Generate(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}) (string, error) {
+	return "", nil
+}
+
+func (c *scriptedClient) GenerateWithFunctions(ctx context.Context, modelName, prompt string, systemPrompt string, tools []llm.ToolDefinition) (*llm.FunctionCallResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prompts = append(c.prompts, prompt)
+	c.systemPrompts = append(c.systemPrompts, systemPrompt)
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Function.Name)
+	}
+	c.tools = append(c.tools, names)
+	if len(c.responses) == 0 {
+		return &llm.FunctionCallResponse{IsTextResponse: true, TextContent: "Task completed successfully"}, nil
+	}
+	response := c.responses[0]
+	c.responses = c.responses[1:]
+	return response, nil
+}
+
+func (c *scriptedClient) Stream(ctx context.Context, modelName, prompt string, systemPrompt string, tools []map[string]interface{}, out chan<- string) error {
+	close(out)
+	return nil
+}
+
+func (c *scriptedClient) ListAvailableModels(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (c *scriptedClient) SupportsNativeFunctionCalling() bool {
+	return true
+}
+
+func (c *scriptedClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	return nil, nil
+}
+
+func (c *scriptedClient) SupportsEmbeddings() bool {
+	return false
+}
+
+func (c *scriptedClient) GenerateThought(ctx context.Context, modelName, prompt, context string) (*llm.ThoughtResponse, error) {
+	return &llm.ThoughtResponse{}, nil
+}
+
+func (c *scriptedClient) AssessConfidence(ctx context.Context, modelName, thought, proposedAction string) (*llm.ConfidenceAssessment, error) {
+	return &llm.ConfidenceAssessment{}, nil
+}
+
+func (c *scriptedClient) SupportsDeliberation() bool {
+	return false
+}
+
+// lastRequest returns the prompt, system prompt and tools of the last request
+func (c *scriptedClient) lastRequest() (string, string, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last := len(c.prompts) - 1
+	return c.prompts[last], c.systemPrompts[last], c.tools[last]
+}
+
+// waitForAnswer returns the messages of a turn up to the assistant's answer
+func waitForAnswer(t *testing.T, presenter *ChatPresenter) []ChatMessage {
+	t.Helper()
+	var messages []ChatMessage
+	for {
+		select {
+		case message := <-presenter.Messages():
+			messages = append(messages, message)
+			if message.Type == AssistantMessage || message.Type == ErrorMessage {
+				// The plan follows the answer
+				select {
+				case message := <-presenter.Messages():
+					messages = append(messages, message)
+				case <-time.After(100 * time.Millisecond):
+				}
+				return messages
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no answer")
+		}
+	}
+}
+
+func TestChatPresenterPlanMode(t *testing.T) {
+	registry := agent.NewRegistry()
+	for _, name := range []string{"read_file", "write_file", "run_shell_command"} {
+		require.NoError(t, registry.Register(agent.NewTypedTool(name, name, func(ctx context.Context, params struct{}) (interface{}, error) {
+			return "ok", nil
+		})))
+	}
+	submit := func(plan string) *llm.FunctionCallResponse {
+		return &llm.FunctionCallResponse{FunctionCall: &llm.FunctionCall{ID: "plan-1", Name: agent.SubmitPlanToolName, Arguments: json.RawMessage(plan)}}
+	}
+	client := &scriptedClient{responses: []*llm.FunctionCallResponse{
+		submit(`{"overall_goal": "Add rate limiting", "tasks": [{"id": "task-1", "description": "Add a limiter middleware"}]}`),
+	}}
+	presenter := NewChatPresenter(context.Background(), client, registry, "You are a coding agent", "model")
+	defer presenter.Close()
+
+	require.NoError(t, presenter.SetToolEnabled("run_shell_command", false))
+	assert.False(t, presenter.agentRunner.ToolEnabled(agent.SubmitPlanToolName), "submit_plan is only offered in plan mode")
+
+	require.NoError(t, presenter.SetPlanMode(true))
+	assert.True(t, presenter.PlanMode())
+	require.NoError(t, presenter.Send(context.Background(), "Rate limit the API"))
+	messages := waitForAnswer(t, presenter)
+	_, systemPrompt, tools := client.lastRequest()
+	assert.Contains(t, systemPrompt, "## Plan Mode")
+	assert.ElementsMatch(t, []string{"read_file", agent.SubmitPlanToolName, agent.ScratchpadWriteToolName, agent.ScratchpadReadToolName, agent.UpdateTasksToolName, agent.ListTasksToolName, "finish"}, tools, "only read-only tools are offered")
+	require.NotNil(t, presenter.CurrentPlan())
+	assert.Equal(t, "Add rate limiting", presenter.CurrentPlan().OverallGoal)
+	last := messages[len(messages)-1]
+	assert.Equal(t, SystemMessage, last.Type)
+	assert.Contains(t, last.Text, "Plan revision 1")
+	assert.Contains(t, last.Text, "task-1")
+
+	client.responses = []*llm.FunctionCallResponse{
+		submit(`{"overall_goal": "Add rate limiting", "tasks": [{"id": "task-1", "description": "Add a limiter middleware"}, {"id": "task-2", "description": "Test the limiter", "dependencies": ["task-1"]}]}`),
+	}
+	require.NoError(t, presenter.Send(context.Background(), "Add a test step"))
+	messages = waitForAnswer(t, presenter)
+	prompt, _, _ := client.lastRequest()
+	assert.Contains(t, prompt, "- Rate limit the API", "earlier requests are carried over")
+	assert.Contains(t, prompt, "Current plan (revision 1)")
+	assert.Contains(t, prompt, "Message of the user:\nAdd a test step")
+	assert.Contains(t, messages[len(messages)-1].Text, "Plan revision 2")
+	assert.Len(t, presenter.CurrentPlan().Tasks, 2)
+
+	execute, err := presenter.ExecutePlan()
+	require.NoError(t, err)
+	assert.False(t, presenter.PlanMode())
+	assert.Contains(t, execute, "Implement the plan")
+	assert.Contains(t, execute, "**task-2** Test the limiter")
+	require.NoError(t, presenter.Send(context.Background(), execute))
+	messages = waitForAnswer(t, presenter)
+	prompt, systemPrompt, tools = client.lastRequest()
+	assert.Contains(t, prompt, execute)
+	assert.NotContains(t, systemPrompt, "Plan Mode")
+	assert.ElementsMatch(t, []string{"read_file", "write_file", agent.ScratchpadWriteToolName, agent.ScratchpadReadToolName, agent.UpdateTasksToolName, agent.ListTasksToolName, "finish"}, tools, "the tools disabled by the user stay disabled")
+	assert.Equal(t, AssistantMessage, messages[len(messages)-1].Type, "the plan is only shown in plan mode")
+	assert.NotNil(t, presenter.CurrentPlan(), "the plan is kept for /plan show and /plan save")
+}
+
+func TestChatPresenterExecutePlanNeedsPlan(t *testing.T) {
+	presenter := NewChatPresenter(context.Background(), &scriptedClient{}, agent.NewRegistry(), "system", "model")
+	defer presenter.Close()
+
+	require.NoError(t, presenter.SetPlanMode(true))
+	_, err := presenter.ExecutePlan()
+	assert.Error(t, err)
+	assert.True(t, presenter.PlanMode(), "plan mode is kept without a plan")
+	require.NoError(t, presenter.SetPlanMode(false))
+	assert.False(t, presenter.agentRunner.ToolEnabled(agent.SubmitPlanToolName))
+}
@@ -0,0 +1,124 @@
+package chat
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Bounds of the sections of the sidebar
+const (
+	maxSidebarTasks = 12
+	maxSidebarFiles = 10
+)
+
+// paneWidths returns the widths of the main pane and the sidebar, 0 when the
+// sidebar is hidden or collapsed
+func (m Model) paneWidths() (mainWidth, sidebarWidth int) {
+	return m.layout.CalculatePaneWidths(m.windowWidth, m.showSidebar)
+}
+
+// sidebarWidth returns the width of the sidebar, 0 when it is not shown
+func (m Model) sidebarWidth() int {
+	_, width := m.paneWidths()
+	return width
+}
+
+// toggleSidebar shows or hides the sidebar. On a window too narrow for it,
+// it shows once the window is widened.
+func (m *Model) toggleSidebar() {
+	m.showSidebar = !m.showSidebar
+	if m.showSidebar && m.windowWidth > 0 && m.sidebarWidth() == 0 {
+		m.addSystemNotice(fmt.Sprintf("The sidebar needs a window at least %d columns wide; it shows once the window is wide enough.", m.layout.GetMinSidebarWindowWidth()))
+	}
+	m.resizePanes()
+}
+
+// resizePanes gives the message list the width the sidebar leaves, and the
+// height the task panel leaves
+func (m *Model) resizePanes() {
+	if m.windowWidth == 0 {
+		return // Not laid out yet
+	}
+	mainWidth, _ := m.paneWidths()
+	m.messageList.SetWidth(mainWidth)
+	m.resizeMessageList()
+}
+
+// addTouchedFile records a file changed this run, for the sidebar
+func (m *Model) addTouchedFile(path string) {
+	for _, touched := range m.touchedFiles {
+		if touched == path {
+			return
+		}
+	}
+	m.touchedFiles = append(m.touchedFiles, path)
+}
+
+// sidebar renders the sidebar at its width and the given height: the task
+// list of the agent, the files changed this run and the tool calls running
+func (m Model) sidebar(height int) string {
+	style := m.theme.ViewportBorder
+	width := m.sidebarWidth() - style.GetHorizontalFrameSize()
+	innerHeight := max(1, height-style.GetVerticalFrameSize())
+
+	var sections []string
+	if len(m.tasks) > 0 {
+		sections = append(sections, strings.Join(m.taskLines(maxSidebarTasks, width), "\n"))
+	}
+	if len(m.touchedFiles) > 0 {
+		lines := []string{m.theme.Time.Render(fmt.Sprintf("Files changed (%d)", len(m.touchedFiles)))}
+		shown := m.touchedFiles
+		if len(shown) > maxSidebarFiles {
+			shown = shown[len(shown)-maxSidebarFiles:]
+		}
+		for _, path := range shown {
+			lines = append(lines, truncateLine("  "+m.displayPath(path), width))
+		}
+		if hidden := len(m.touchedFiles) - len(shown); hidden > 0 {
+			lines = append(lines, m.theme.Time.Render(fmt.Sprintf("  ... %d earlier", hidden)))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	if len(m.activeToolCalls) > 0 {
+		ids := make([]string, 0, len(m.activeToolCalls))
+		for id := range m.activeToolCalls {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return m.activeToolCalls[ids[i]].startTime.Before(m.activeToolCalls[ids[j]].startTime)
+		})
+		lines := []string{m.theme.Time.Render("Tools running")}
+		plain := &ProgressRenderer{plain: true}
+		for _, id := range ids {
+			status := strings.TrimPrefix(plain.RenderProgress(m.activeToolCalls[id]), "Running: ")
+			lines = append(lines, truncateLine("  "+status, width))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	content := strings.Join(sections, "\n\n")
+	if content == "" {
+		content = m.theme.Time.Render("The tasks, changed files and running tools of the agent show here.")
+	}
+
+	body := lipgloss.NewStyle().
+		Width(width).
+		Height(innerHeight).
+		MaxWidth(width).
+		MaxHeight(innerHeight).
+		Render(content)
+	return style.Render(body)
+}
+
+// displayPath shows path relative to the workspace when it is inside it
+func (m Model) displayPath(path string) string {
+	if m.workspaceRoot != "" {
+		if rel, err := filepath.Rel(m.workspaceRoot, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
@@ -0,0 +1,98 @@
+package chat
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	"github.com/castrovroberto/CGE/internal/config"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculatePaneWidths(t *testing.T) {
+	layout := NewLayoutDimensions(NewDefaultTheme())
+	for _, tt := range []struct {
+		window, main, sidebar int
+		show                  bool
+	}{
+		{window: 120, show: false, main: 120, sidebar: 0},
+		{window: 150, show: true, main: 110, sidebar: 40},
+		{window: 90, show: true, main: 60, sidebar: 30},
+		{window: 84, show: true, main: 60, sidebar: 24},
+		{window: 80, show: true, main: 80, sidebar: 0},
+	} {
+		main, sidebar := layout.CalculatePaneWidths(tt.window, tt.show)
+		assert.Equal(t, tt.main, main, "main pane of a %d column window", tt.window)
+		assert.Equal(t, tt.sidebar, sidebar, "sidebar of a %d column window", tt.window)
+	}
+	assert.Equal(t, 84, layout.GetMinSidebarWindowWidth())
+}
+
+func TestSidebar(t *testing.T) {
+	root := t.TempDir()
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider), WithWorkspaceRoot(root))
+	update := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	toggle := func() { update(tea.KeyMsg{Type: tea.KeyCtrlB}) }
+
+	update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	update(chatMsgWrapper{ChatMessage{Type: TaskListMessage, Metadata: map[string]interface{}{"tasks": []agent.TaskItem{
+		{ID: "1", Title: "Rename the handler", Status: agent.TaskInProgress},
+	}}}})
+	update(chatMsgWrapper{ChatMessage{Type: FileChangeMessage, Metadata: map[string]interface{}{"path": filepath.Join(root, "cmd", "root.go")}}})
+	update(chatMsgWrapper{ChatMessage{Type: FileChangeMessage, Metadata: map[string]interface{}{"path": filepath.Join(root, "cmd", "root.go")}}})
+	model.activeToolCalls["call_1"] = &toolProgressState{toolName: "run_tests", startTime: time.Now(), progress: 0.5, status: "internal/chat"}
+	assert.Equal(t, 120, model.messageList.width)
+	assert.NotEmpty(t, model.taskPanel(), "without the sidebar the tasks show above the input")
+
+	toggle()
+	assert.Equal(t, 80, model.messageList.width, "the message list makes room for the sidebar")
+	assert.Empty(t, model.taskPanel(), "the sidebar shows the tasks instead")
+	view := model.View()
+	assert.Contains(t, view, "[~] 1: Rename the handler")
+	assert.Contains(t, view, "Files changed (1)")
+	assert.Contains(t, view, filepath.Join("cmd", "root.go"))
+	assert.NotContains(t, view, root, "files are shown relative to the workspace")
+	assert.Contains(t, view, "run_tests 50% - internal/chat")
+	for _, line := range strings.Split(view, "\n") {
+		assert.LessOrEqual(t, lipgloss.Width(line), 120)
+	}
+
+	update(tea.WindowSizeMsg{Width: 70, Height: 40})
+	assert.Equal(t, 70, model.messageList.width, "the sidebar collapses on narrow windows")
+	assert.NotEmpty(t, model.taskPanel())
+
+	toggle()
+	toggle()
+	messages := model.messageList.GetMessages()
+	assert.Contains(t, messages[len(messages)-1].text, "at least 84 columns wide")
+
+	update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	assert.Equal(t, 80, model.messageList.width, "the sidebar shows again once the window is wide enough")
+	toggle()
+	assert.Equal(t, 120, model.messageList.width)
+
+	model.submitPrompt("next")
+	assert.Empty(t, model.touchedFiles, "the files are those of the current run")
+}
+
+func TestSidebarFromConfig(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.UI.Chat.Sidebar = true
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider), WithInitialConfig(cfg))
+	updated, _ := model.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	model = updated.(Model)
+	assert.Equal(t, 80, model.messageList.width)
+	assert.Contains(t, model.View(), "The tasks, changed files and running", "an empty sidebar says what it shows")
+}
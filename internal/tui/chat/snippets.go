@@ -0,0 +1,174 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// snippetPrefix starts a snippet reference in the input, e.g. "#tests main.go"
+const snippetPrefix = "#"
+
+// placeholderPattern matches the {{name}} placeholders of a snippet
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// snippetNamePattern restricts snippet names, so "# Heading" and "#123" are
+// sent as typed
+var snippetNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// builtinSnippets are available without configuration; snippets of the same
+// name in ui.chat.snippets replace them
+var builtinSnippets = map[string]string{
+	"explain": `Explain {{target}}.
+
+Start with a two or three sentence summary of what it does and why it exists. Then walk through how it works: the main data flow, the key functions or types and how they interact, and any non-obvious decisions, invariants or edge cases. Point out anything that looks fragile or surprising. Read the relevant files before answering and cite them by path and line.`,
+
+	"refactor": `Refactor {{target}}.
+
+Goals: make the code easier to read and change without altering its behavior. Keep the public API unless a change is clearly worth it, and say so when it is. Follow the conventions already used in this codebase. Before editing, briefly list the problems you see and the changes you plan. After editing, run the existing tests and explain anything that could affect callers.`,
+
+	"tests": `Write tests for {{target}}.
+
+Read the code and the existing tests first, and follow their layout, helpers and assertion style. Cover the main behavior, edge cases (empty input, boundaries, errors) and any bug-prone paths. Prefer small table-driven tests with descriptive names. Do not change the code under test unless a test reveals a bug; report such bugs separately. Run the tests and make sure they pass.`,
+}
+
+// loadSnippets returns the built-in snippets merged with the configured ones,
+// skipping configured names that could not be typed
+func loadSnippets(configured map[string]string) map[string]string {
+	snippets := make(map[string]string, len(builtinSnippets)+len(configured))
+	for name, template := range builtinSnippets {
+		snippets[name] = template
+	}
+	for name, template := range configured {
+		name = strings.TrimPrefix(strings.ToLower(name), snippetPrefix)
+		if !snippetNamePattern.MatchString(name) || strings.TrimSpace(template) == "" {
+			continue
+		}
+		snippets[name] = template
+	}
+	return snippets
+}
+
+// snippetSuggestions returns the snippet references offered while typing,
+// sorted by name
+func snippetSuggestions(snippets map[string]string) []string {
+	suggestions := make([]string, 0, len(snippets))
+	for name := range snippets {
+		suggestions = append(suggestions, snippetPrefix+name+" ")
+	}
+	sort.Strings(suggestions)
+	return suggestions
+}
+
+// snippetPlaceholders returns the distinct placeholder names of template, in
+// order of first appearance
+func snippetPlaceholders(template string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// expandSnippet expands input when it starts with a known snippet reference.
+// Arguments after the name fill the placeholders: "key=value" fills one by
+// name, and the others fill the remaining placeholders in order, the last
+// taking the rest of the text. missing lists the placeholders left unfilled,
+// which stay in the expansion. ok is false when input is not a snippet.
+func expandSnippet(input string, snippets map[string]string) (expanded string, missing []string, ok bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, snippetPrefix) {
+		return "", nil, false
+	}
+	name, rest, _ := strings.Cut(strings.TrimPrefix(input, snippetPrefix), " ")
+	template, ok := snippets[strings.ToLower(name)]
+	if !ok {
+		return "", nil, false
+	}
+
+	placeholders := snippetPlaceholders(template)
+	isPlaceholder := make(map[string]bool, len(placeholders))
+	for _, placeholder := range placeholders {
+		isPlaceholder[placeholder] = true
+	}
+	values := make(map[string]string)
+	var positional []string
+	for _, field := range strings.Fields(rest) {
+		if key, value, found := strings.Cut(field, "="); found && isPlaceholder[key] {
+			values[key] = value
+			continue
+		}
+		positional = append(positional, field)
+	}
+
+	var unnamed []string
+	for _, placeholder := range placeholders {
+		if _, named := values[placeholder]; !named {
+			unnamed = append(unnamed, placeholder)
+		}
+	}
+	for i, placeholder := range unnamed {
+		switch {
+		case i >= len(positional):
+			missing = append(missing, placeholder)
+		case i == len(unnamed)-1:
+			values[placeholder] = strings.Join(positional[i:], " ")
+		default:
+			values[placeholder] = positional[i]
+		}
+	}
+
+	expanded = placeholderPattern.ReplaceAllStringFunc(template, func(match string) string {
+		if value, ok := values[placeholderPattern.FindStringSubmatch(match)[1]]; ok {
+			return value
+		}
+		return match
+	})
+	return expanded, missing, true
+}
+
+// handleSnippet expands a snippet reference in input. A complete expansion is
+// sent; one with unfilled placeholders is put back in the input to be edited.
+// It reports false when input is not a snippet.
+func (m *Model) handleSnippet(input string) (tea.Cmd, bool) {
+	expanded, missing, ok := expandSnippet(input, m.snippets)
+	if !ok {
+		return nil, false
+	}
+	if len(missing) > 0 {
+		m.inputArea.SetValue(expanded)
+		m.inputArea.CursorEnd()
+		m.addSystemNotice(fmt.Sprintf("✂ Fill in {{%s}}, then send", strings.Join(missing, "}}, {{")))
+		return nil, true
+	}
+	m.inputArea.Reset()
+	return m.sendOrQueue(expanded), true
+}
+
+// snippetsCommand lists the snippets that can be used with #name
+func (m *Model) snippetsCommand(args string) tea.Cmd {
+	names := make([]string, 0, len(m.snippets))
+	for name := range m.snippets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("✂ Snippets (type #name, then arguments for the {{placeholders}}):")
+	for _, name := range names {
+		b.WriteString("\n  " + snippetPrefix + name)
+		if placeholders := snippetPlaceholders(m.snippets[name]); len(placeholders) > 0 {
+			b.WriteString(" {{" + strings.Join(placeholders, "}} {{") + "}}")
+		}
+	}
+	b.WriteString("\nAdd your own under [ui.chat.snippets] in the configuration.")
+	m.addSystemNotice(b.String())
+	return nil
+}
@@ -0,0 +1,80 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/config"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandSnippet(t *testing.T) {
+	snippets := loadSnippets(map[string]string{
+		"review": "Review {{file}} for {{focus}}.",
+		"#Diff":  "Show the diff of {{ file }} and {{file}}",
+		"# bad":  "ignored",
+	})
+	assert.Contains(t, snippets, "diff", "the prefix is dropped and the name lowered")
+	assert.NotContains(t, snippets, "# bad")
+
+	tests := []struct {
+		name     string
+		input    string
+		expanded string
+		missing  []string
+		ok       bool
+	}{
+		{"positional", "#review main.go error handling", "Review main.go for error handling.", nil, true},
+		{"named", "#review focus=naming main.go", "Review main.go for naming.", nil, true},
+		{"missing", "#review main.go", "Review main.go for {{focus}}.", []string{"focus"}, true},
+		{"repeated placeholder", "#diff a.go", "Show the diff of a.go and a.go", nil, true},
+		{"case insensitive", "#REVIEW a b", "Review a for b.", nil, true},
+		{"built in", "#tests the parser", "Write tests for the parser.", nil, true},
+		{"unknown", "#nope x", "", nil, false},
+		{"heading", "# Title", "", nil, false},
+		{"plain text", "review main.go", "", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expanded, missing, ok := expandSnippet(tt.input, snippets)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.missing, missing)
+			if tt.ok {
+				assert.Contains(t, expanded, tt.expanded)
+			}
+		})
+	}
+}
+
+func TestSnippetInput(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.UI.Chat.Snippets = map[string]string{"review": "Review {{file}}"}
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider), WithInitialConfig(cfg))
+
+	model.inputArea.SetValue("#re")
+	model.inputArea.updateSuggestions("#re")
+	assert.Equal(t, []string{"#refactor ", "#review "}, model.inputArea.suggestions)
+	model.inputArea.ClearSuggestions()
+
+	model.inputArea.SetValue("#review")
+	updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model = updated.(Model)
+	assert.Equal(t, "Review {{file}}", model.inputArea.GetValue(), "an incomplete expansion is left to edit")
+	assert.Contains(t, lastMessageText(model), "Fill in {{file}}")
+
+	model.inputArea.SetValue("#review main.go")
+	updated, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model = updated.(Model)
+	assert.Empty(t, model.inputArea.GetValue())
+	messages := model.messageList.GetMessages()
+	require.NotEmpty(t, messages)
+	found := false
+	for _, msg := range messages {
+		found = found || msg.text == "Review main.go"
+	}
+	assert.True(t, found, "the expansion is sent")
+}
@@ -5,9 +5,12 @@ import (
 	"strings"
 	"time"
 
+	cgecontext "github.com/castrovroberto/CGE/internal/context"
+	"github.com/castrovroberto/CGE/internal/i18n"
 	"github.com/castrovroberto/CGE/internal/logger"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 // StatusBarState represents the complete state of the status bar for atomic updates
@@ -30,6 +33,31 @@ type StatusBarModel struct {
 	activeToolCalls   int
 	width             int
 	lastState         *StatusBarState // Track last known good state
+	quitKey           string          // Key hint shown for quitting
+	suggestionKey     string          // Key hint shown for applying suggestions
+	cancelKey         string          // Key hint shown for cancelling a run
+	notice            string          // Transient notice shown while loading
+	queuedCount       int             // Messages waiting for the current run to finish
+	planMode          bool            // Whether the agent is planning rather than changing files
+	idle              bool            // Whether the chat is idle, which dims the bar
+	noticeUntil       time.Time
+
+	// Model and usage information
+	modelName     string
+	contextWindow int     // Context size of the model in tokens
+	contextTokens int     // Estimated context tokens of the pending or last request
+	sessionTokens int     // Input plus output tokens used this session
+	sessionCost   float64 // Estimated USD cost this session
+	costKnown     bool    // Whether every run so far had known pricing
+	runs          int     // Number of completed runs with usage information
+	workspace     *WorkspaceStatus
+}
+
+// statusPart is one segment of the status bar; when space runs out the
+// lowest-priority segments are dropped first
+type statusPart struct {
+	text     string
+	priority int
 }
 
 // NewStatusBarModel creates a new status bar model
@@ -45,9 +73,75 @@ func NewStatusBarModel(theme *Theme, chatStartTime time.Time) *StatusBarModel {
 		loading:       false,
 		chatStartTime: chatStartTime,
 		width:         50, // Default width
+		quitKey:       "Ctrl+C",
+		suggestionKey: "Tab",
+		cancelKey:     "Esc",
+		costKnown:     true,
+	}
+}
+
+// SetModelName sets the active model shown in the status bar
+func (s *StatusBarModel) SetModelName(name string) {
+	s.modelName = name
+	s.contextWindow = cgecontext.ModelContextWindow(name)
+}
+
+// SetContextTokens sets the estimated context size of the pending request
+func (s *StatusBarModel) SetContextTokens(tokens int) {
+	s.contextTokens = tokens
+}
+
+// AddUsage accumulates the token usage and cost of a completed run
+func (s *StatusBarModel) AddUsage(usage TokenUsage) {
+	s.runs++
+	s.sessionTokens += usage.InputTokens + usage.OutputTokens
+	s.sessionCost += usage.CostUSD
+	s.costKnown = s.costKnown && usage.CostKnown
+	if usage.ContextTokens > 0 {
+		s.contextTokens = usage.ContextTokens
 	}
 }
 
+// SetWorkspaceStatus sets the git and index state shown in the status bar
+func (s *StatusBarModel) SetWorkspaceStatus(status WorkspaceStatus) {
+	s.workspace = &status
+}
+
+// SetKeyHints sets the keys shown in the status bar for quitting, applying
+// suggestions and cancelling a run
+func (s *StatusBarModel) SetKeyHints(quitKey, suggestionKey, cancelKey string) {
+	s.quitKey = quitKey
+	s.suggestionKey = suggestionKey
+	s.cancelKey = cancelKey
+}
+
+// SetNotice shows a transient notice, e.g. a confirmation prompt
+func (s *StatusBarModel) SetNotice(notice string, duration time.Duration) {
+	s.notice = notice
+	s.noticeUntil = time.Now().Add(duration)
+}
+
+// SetQueuedCount sets the number of messages waiting for the current run
+func (s *StatusBarModel) SetQueuedCount(count int) {
+	s.queuedCount = count
+}
+
+// SetPlanMode sets whether plan mode is shown
+func (s *StatusBarModel) SetPlanMode(enabled bool) {
+	s.planMode = enabled
+}
+
+// SetIdle sets whether the chat is idle
+func (s *StatusBarModel) SetIdle(idle bool) {
+	s.idle = idle
+}
+
+// ClearNotice removes the transient notice
+func (s *StatusBarModel) ClearNotice() {
+	s.notice = ""
+	s.noticeUntil = time.Time{}
+}
+
 // Update handles status bar updates
 func (s *StatusBarModel) Update(msg tea.Msg) (*StatusBarModel, tea.Cmd) {
 	var cmd tea.Cmd
@@ -68,7 +162,7 @@ func (s *StatusBarModel) Update(msg tea.Msg) (*StatusBarModel, tea.Cmd) {
 func (s *StatusBarModel) View() string {
 	// Validate state before rendering to prevent inconsistencies
 	if !s.ValidateState() {
-		logger.Get().Warn("Status bar state validation failed, using safe defaults")
+		logger.For(logger.TUI).Warn("Status bar state validation failed, using safe defaults")
 		// Use safe defaults
 		s.activeToolCalls = 0
 		if s.chatStartTime.IsZero() {
@@ -81,53 +175,183 @@ func (s *StatusBarModel) View() string {
 	if s.loading {
 		elapsed := time.Since(s.thinkingStartTime)
 		elapsedStr := fmt.Sprintf("%.1fs", elapsed.Seconds())
-		statusBar = s.theme.StatusBar.Render(fmt.Sprintf("%s Thinking... (%s)", s.spinner.View(), elapsedStr))
+		thinking := i18n.T("status.thinking", s.spinner.View(), elapsedStr)
+		if s.theme.Accessible {
+			// A line changing every tick would be read out again and again
+			thinking = i18n.T("chat.thinking")
+		}
+		if ctx := s.contextSummary(); ctx != "" {
+			thinking += " | " + ctx
+		}
+		if s.queuedCount > 0 {
+			thinking += " | " + i18n.T("status.queued", s.queuedCount)
+		}
+		if s.planMode {
+			thinking += " | " + i18n.T("status.plan_mode")
+		}
+		if s.notice != "" && time.Now().Before(s.noticeUntil) {
+			thinking += " | " + s.notice
+		} else {
+			thinking += " | " + i18n.T("status.cancel", s.cancelKey)
+		}
+		statusBar = s.theme.StatusBar.Render(thinking)
 	} else if s.err != nil {
-		statusBar = s.theme.Error.Render("Error: " + s.err.Error())
+		statusBar = s.theme.Error.Render(i18n.T("status.error", s.err))
 	} else {
-		// Enhanced status bar with more information
-		var statusParts []string
-
-		// Basic controls
-		statusParts = append(statusParts, "Ctrl+C: quit")
-		statusParts = append(statusParts, "Ctrl+E: edit last")
-		statusParts = append(statusParts, "Tab: suggestions")
+		sessionDuration := time.Since(s.chatStartTime)
+		parts := []statusPart{{i18n.T("status.quit", s.quitKey), 100}}
+		if s.modelName != "" {
+			parts = append(parts, statusPart{s.modelName, 80})
+		}
+		if s.planMode {
+			parts = append(parts, statusPart{i18n.T("status.plan_mode"), 90})
+		}
+		if s.idle {
+			parts = append(parts, statusPart{i18n.T("status.idle"), 90})
+		}
+		if s.notice != "" && time.Now().Before(s.noticeUntil) {
+			parts = append(parts, statusPart{s.notice, 90})
+		}
+		if git := s.gitSummary(); git != "" {
+			parts = append(parts, statusPart{git, 70})
+		}
+		if ctx := s.contextSummary(); ctx != "" {
+			parts = append(parts, statusPart{ctx, 60})
+		}
+		if usage := s.usageSummary(); usage != "" {
+			parts = append(parts, statusPart{usage, 50})
+		}
+		if index := s.indexSummary(); index != "" {
+			parts = append(parts, statusPart{index, 40})
+		}
+		parts = append(parts,
+			statusPart{i18n.T("status.edit_last"), 10},
+			statusPart{i18n.T("status.suggestions", s.suggestionKey), 10},
+		)
 
 		// Active operations count - always include if > 0
 		if s.activeToolCalls > 0 {
-			statusParts = append(statusParts, fmt.Sprintf("Active: %d", s.activeToolCalls))
+			parts = append(parts, statusPart{i18n.T("status.active", s.activeToolCalls), 100})
 		}
 
 		// Session info - use consistent time source
-		sessionDuration := time.Since(s.chatStartTime)
-		statusParts = append(statusParts, fmt.Sprintf("Session: %.0fm", sessionDuration.Minutes()))
+		parts = append(parts, statusPart{i18n.T("status.session", sessionDuration.Minutes()), 100})
 
-		// Create full status bar content
-		fullStatusContent := strings.Join(statusParts, " | ")
+		style := s.theme.StatusBar
+		if s.idle {
+			style = style.Foreground(s.theme.Colors.Muted).Faint(true)
+		}
+		statusBar = style.Render(fitStatusParts(parts, s.width))
+	}
 
-		// Apply theme styling
-		statusBar = s.theme.StatusBar.Render(fullStatusContent)
+	return statusBar
+}
 
-		// Handle truncation only if absolutely necessary and we have a width constraint
-		if s.width > 0 && len(fullStatusContent) > s.width {
-			// Create minimal version that preserves active tool calls
-			var minimalParts []string
-			minimalParts = append(minimalParts, "Ctrl+C: quit")
+// fitStatusParts joins parts with separators, dropping the lowest-priority
+// parts until the result fits in width (no limit when width is 0)
+func fitStatusParts(parts []statusPart, width int) string {
+	join := func(parts []statusPart) string {
+		texts := make([]string, len(parts))
+		for i, p := range parts {
+			texts[i] = p.text
+		}
+		return strings.Join(texts, " | ")
+	}
 
-			// Always preserve active tool calls if present
-			if s.activeToolCalls > 0 {
-				minimalParts = append(minimalParts, fmt.Sprintf("Active: %d", s.activeToolCalls))
+	content := join(parts)
+	for width > 0 && lipgloss.Width(content) > width && len(parts) > 1 {
+		// Drop the last part among those with the lowest priority
+		drop := len(parts) - 1
+		for i := len(parts) - 1; i >= 0; i-- {
+			if parts[i].priority < parts[drop].priority {
+				drop = i
 			}
+		}
+		if parts[drop].priority >= 100 {
+			break // Essential parts are never dropped
+		}
+		parts = append(parts[:drop:drop], parts[drop+1:]...)
+		content = join(parts)
+	}
+	return content
+}
 
-			// Add session time
-			minimalParts = append(minimalParts, fmt.Sprintf("Session: %.0fm", sessionDuration.Minutes()))
+// gitSummary renders the branch with a "*" when the working tree is dirty
+func (s *StatusBarModel) gitSummary() string {
+	if s.workspace == nil || !s.workspace.IsRepo {
+		return ""
+	}
+	summary := "⎇ " + s.workspace.Branch
+	if s.workspace.Dirty {
+		summary += "*"
+	}
+	return summary
+}
 
-			minimalContent := strings.Join(minimalParts, " | ")
-			statusBar = s.theme.StatusBar.Render(minimalContent)
-		}
+// contextSummary renders the estimated context size against the model's window
+func (s *StatusBarModel) contextSummary() string {
+	if s.contextTokens <= 0 {
+		return ""
 	}
+	if s.contextWindow > 0 {
+		return fmt.Sprintf("ctx ~%s/%s", formatTokenCount(s.contextTokens), formatTokenCount(s.contextWindow))
+	}
+	return fmt.Sprintf("ctx ~%s", formatTokenCount(s.contextTokens))
+}
 
-	return statusBar
+// usageSummary renders the session cost, or the token total when pricing is unknown
+func (s *StatusBarModel) usageSummary() string {
+	if s.runs == 0 {
+		return ""
+	}
+	if !s.costKnown {
+		return fmt.Sprintf("~%s tok", formatTokenCount(s.sessionTokens))
+	}
+	if s.sessionCost > 0 && s.sessionCost < 0.01 {
+		return fmt.Sprintf("$%.4f", s.sessionCost)
+	}
+	return fmt.Sprintf("$%.2f", s.sessionCost)
+}
+
+// indexSummary renders the age of the semantic index
+func (s *StatusBarModel) indexSummary() string {
+	if s.workspace == nil {
+		return ""
+	}
+	switch {
+	case s.workspace.IndexBuilt.IsZero():
+		return i18n.T("status.no_index")
+	case s.workspace.IndexStale:
+		return i18n.T("status.index_stale")
+	default:
+		return i18n.T("status.index_age", formatAge(time.Since(s.workspace.IndexBuilt)))
+	}
+}
+
+// formatTokenCount abbreviates token counts: 950, 1.2k, 1.5M
+func formatTokenCount(tokens int) string {
+	switch {
+	case tokens >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(tokens)/1_000_000)
+	case tokens >= 1000:
+		return fmt.Sprintf("%.1fk", float64(tokens)/1000)
+	default:
+		return fmt.Sprintf("%d", tokens)
+	}
+}
+
+// formatAge renders a duration as a short age: 5m, 3h, 2d
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
 }
 
 // SetLoading sets the loading state
@@ -158,9 +382,10 @@ func (s *StatusBarModel) GetHeight() int {
 	return s.theme.StatusBarHeight
 }
 
-// GetSpinnerTickCmd returns the spinner tick command if loading
+// GetSpinnerTickCmd returns the spinner tick command if loading; the
+// accessible theme has no spinner
 func (s *StatusBarModel) GetSpinnerTickCmd() tea.Cmd {
-	if s.loading {
+	if s.loading && !s.theme.Accessible {
 		return s.spinner.Tick
 	}
 	return nil
@@ -199,3 +199,94 @@ func TestStatusBarModelSessionStartTime(t *testing.T) {
 	// Should show elapsed time
 	assert.Contains(t, view, "Session:", "Should show session info")
 }
+
+func TestStatusBarModelSessionInfo(t *testing.T) {
+	newStatusBar := func() *StatusBarModel {
+		model := NewStatusBarModel(NewDefaultTheme(), time.Now())
+		model.Update(tea.WindowSizeMsg{Width: 200, Height: 40})
+		return model
+	}
+
+	t.Run("model, context and cost", func(t *testing.T) {
+		model := newStatusBar()
+		model.SetModelName("gpt-4o")
+		model.SetContextTokens(1500)
+		model.AddUsage(TokenUsage{InputTokens: 10000, OutputTokens: 500, ContextTokens: 2500, CostUSD: 0.03, CostKnown: true})
+		model.AddUsage(TokenUsage{InputTokens: 2000, OutputTokens: 100, CostUSD: 0.006, CostKnown: true})
+
+		view := model.View()
+		assert.Contains(t, view, "gpt-4o")
+		assert.Contains(t, view, "ctx ~2.5k/128.0k", "usage replaces the pending estimate")
+		assert.Contains(t, view, "$0.04")
+	})
+
+	t.Run("unknown pricing shows tokens", func(t *testing.T) {
+		model := newStatusBar()
+		model.AddUsage(TokenUsage{InputTokens: 1000, OutputTokens: 200, CostUSD: 0.01, CostKnown: true})
+		model.AddUsage(TokenUsage{InputTokens: 11000, OutputTokens: 300})
+		assert.Contains(t, model.View(), "~12.5k tok")
+	})
+
+	t.Run("git and index", func(t *testing.T) {
+		model := newStatusBar()
+		model.SetWorkspaceStatus(WorkspaceStatus{IsRepo: true, Branch: "main", Dirty: true, IndexBuilt: time.Now().Add(-3 * time.Hour)})
+		view := model.View()
+		assert.Contains(t, view, "⎇ main*")
+		assert.Contains(t, view, "index 3h")
+
+		model.SetWorkspaceStatus(WorkspaceStatus{IsRepo: true, Branch: "main", IndexBuilt: time.Now(), IndexStale: true})
+		view = model.View()
+		assert.Contains(t, view, "index stale")
+		assert.NotContains(t, view, "main*")
+
+		model.SetWorkspaceStatus(WorkspaceStatus{})
+		view = model.View()
+		assert.Contains(t, view, "no index")
+		assert.NotContains(t, view, "⎇")
+	})
+
+	t.Run("narrow width keeps essentials", func(t *testing.T) {
+		model := newStatusBar()
+		model.SetModelName("gpt-4o")
+		model.SetWorkspaceStatus(WorkspaceStatus{IsRepo: true, Branch: "feature/status-bar"})
+		model.SetActiveToolCalls(1)
+		model.Update(tea.WindowSizeMsg{Width: 45, Height: 40})
+
+		view := model.View()
+		assert.Contains(t, view, "quit")
+		assert.Contains(t, view, "Active: 1")
+		assert.Contains(t, view, "Session:")
+		assert.NotContains(t, view, "feature/status-bar")
+	})
+}
+
+func TestFormatTokenCount(t *testing.T) {
+	assert.Equal(t, "950", formatTokenCount(950))
+	assert.Equal(t, "1.2k", formatTokenCount(1234))
+	assert.Equal(t, "1.5M", formatTokenCount(1_500_000))
+}
+
+func TestStatusBarAccessible(t *testing.T) {
+	model := NewStatusBarModel(NewAccessibleTheme(), time.Now())
+	model.SetLoading(true)
+
+	assert.Nil(t, model.GetSpinnerTickCmd(), "the accessible theme has no spinner")
+	view := model.View()
+	assert.Contains(t, view, "Thinking...")
+	assert.NotContains(t, view, "s)", "elapsed time would change on every render")
+}
+
+func TestStatusBarModel_Idle(t *testing.T) {
+	model := NewStatusBarModel(NewDefaultTheme(), time.Now())
+	model.Update(tea.WindowSizeMsg{Width: 200})
+	assert.NotContains(t, model.View(), "idle")
+
+	model.SetIdle(true)
+	model.SetNotice("⏳ loading llama3...", time.Minute)
+	view := model.View()
+	assert.Contains(t, view, "idle")
+	assert.Contains(t, view, "loading llama3", "notices show when not loading too")
+
+	model.SetIdle(false)
+	assert.NotContains(t, model.View(), "idle")
+}
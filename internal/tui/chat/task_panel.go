@@ -0,0 +1,85 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+)
+
+// maxTaskPanelTasks bounds the tasks listed in the task panel, so a long
+// list does not crowd out the conversation
+const maxTaskPanelTasks = 6
+
+// setTasks shows tasks in the task panel, or hides it when there are none,
+// and gives the message list the height the panel leaves
+func (m *Model) setTasks(tasks []agent.TaskItem) {
+	m.tasks = tasks
+	m.resizeMessageList()
+}
+
+// resizeMessageList sets the height of the message list to that of the
+// viewport less the task panel
+func (m *Model) resizeMessageList() {
+	if m.viewportHeight == 0 {
+		return // Not laid out yet
+	}
+	height := m.viewportHeight
+	if panel := m.taskPanel(); panel != "" {
+		height -= strings.Count(panel, "\n") + 1
+	}
+	m.messageList.SetHeight(max(height, m.layout.GetMinViewportHeight()))
+}
+
+// taskPanel renders the task list of the agent above the input area. It is
+// empty when there are no tasks, or when the sidebar shows them.
+func (m Model) taskPanel() string {
+	if len(m.tasks) == 0 || m.sidebarWidth() > 0 {
+		return ""
+	}
+	return strings.Join(m.taskLines(maxTaskPanelTasks, m.messageList.width), "\n")
+}
+
+// taskLines renders the task list as a checklist: a title with the progress,
+// then up to limit tasks from just before the first open one, each cut to
+// width
+func (m Model) taskLines(limit, width int) []string {
+	done, first := 0, len(m.tasks)
+	for i, task := range m.tasks {
+		if task.Status == agent.TaskDone {
+			done++
+		} else if i < first {
+			first = i
+		}
+	}
+	start := max(0, min(first-1, len(m.tasks)-limit))
+	end := min(len(m.tasks), start+limit)
+
+	lines := []string{m.theme.Time.Render(fmt.Sprintf("Tasks %d/%d done", done, len(m.tasks)))}
+	if start > 0 {
+		lines = append(lines, m.theme.Time.Render(fmt.Sprintf("  ... %d done", start)))
+	}
+	for _, task := range m.tasks[start:end] {
+		line := truncateLine("  "+agent.FormatChecklist([]agent.TaskItem{task}), width)
+		switch task.Status {
+		case agent.TaskDone:
+			line = m.theme.Time.Render(line)
+		case agent.TaskInProgress:
+			line = m.theme.Sender.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	if end < len(m.tasks) {
+		lines = append(lines, m.theme.Time.Render(fmt.Sprintf("  ... %d more", len(m.tasks)-end)))
+	}
+	return lines
+}
+
+// truncateLine shortens line to width characters, when width is known
+func truncateLine(line string, width int) string {
+	runes := []rune(line)
+	if width <= 3 || len(runes) <= width {
+		return line
+	}
+	return string(runes[:width-3]) + "..."
+}
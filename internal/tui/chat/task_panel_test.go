@@ -0,0 +1,60 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/agent"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskPanel(t *testing.T) {
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	update := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	showTasks := func(tasks []agent.TaskItem) {
+		update(chatMsgWrapper{ChatMessage{Type: TaskListMessage, Metadata: map[string]interface{}{"tasks": tasks}}})
+	}
+
+	update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	fullHeight := model.messageList.GetHeight()
+	assert.Empty(t, model.taskPanel(), "no panel without tasks")
+
+	showTasks([]agent.TaskItem{
+		{ID: "1", Title: "Rename the handler", Status: agent.TaskDone},
+		{ID: "2", Title: "Fix the callers", Status: agent.TaskInProgress},
+		{ID: "3", Title: "Update the docs", Status: agent.TaskPending},
+	})
+	view := model.View()
+	assert.Contains(t, view, "Tasks 1/3 done")
+	assert.Contains(t, view, "[x] 1: Rename the handler")
+	assert.Contains(t, view, "[~] 2: Fix the callers (in progress)")
+	assert.Contains(t, view, "[ ] 3: Update the docs")
+	assert.Equal(t, fullHeight-4, model.messageList.GetHeight(), "the message list makes room for the panel")
+
+	var long []agent.TaskItem
+	for i := 1; i <= 12; i++ {
+		status := agent.TaskPending
+		if i <= 5 {
+			status = agent.TaskDone
+		}
+		long = append(long, agent.TaskItem{ID: fmt.Sprint(i), Title: fmt.Sprintf("Step %d", i), Status: status})
+	}
+	showTasks(long)
+	panel := model.taskPanel()
+	assert.Contains(t, panel, "... 4 done")
+	assert.Contains(t, panel, "[x] 5: Step 5", "the last done task stays in view")
+	assert.Contains(t, panel, "[ ] 6: Step 6")
+	assert.Contains(t, panel, "... 2 more")
+	assert.NotContains(t, panel, "Step 11")
+
+	showTasks(nil)
+	assert.Empty(t, model.taskPanel())
+	assert.Equal(t, fullHeight, model.messageList.GetHeight(), "the message list takes the room back")
+}
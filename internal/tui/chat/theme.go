@@ -8,11 +8,21 @@ import (
 
 // Theme contains all styling and dimension constants for the TUI
 type Theme struct {
+	// Accessible renders linear plain text for screen readers: no spinner,
+	// progress bars, emoji or visible box drawing
+	Accessible bool
+
 	// Layout dimensions
 	HeaderHeight      int
 	StatusBarHeight   int
 	MinViewportHeight int
 
+	// Pane widths: the sidebar takes a third of the window within its
+	// bounds, leaving the main pane at least MinMainPaneWidth
+	MinSidebarWidth  int
+	MaxSidebarWidth  int
+	MinMainPaneWidth int
+
 	// Color palette
 	Colors struct {
 		Primary    lipgloss.Color
@@ -43,6 +53,11 @@ type Theme struct {
 	ToolError   lipgloss.Style
 	ToolParams  lipgloss.Style
 
+	// Error severity styles; ToolError is used for the middle severity
+	ToolWarning  lipgloss.Style
+	ToolCritical lipgloss.Style
+	ErrorAction  lipgloss.Style // Suggested next action below an error
+
 	// Viewport styling
 	ViewportBorder lipgloss.Style
 }
@@ -54,6 +69,9 @@ func NewDefaultTheme() *Theme {
 		HeaderHeight:      2, // Base height, will be overridden by dynamic calculation
 		StatusBarHeight:   1,
 		MinViewportHeight: 3,
+		MinSidebarWidth:   24,
+		MaxSidebarWidth:   40,
+		MinMainPaneWidth:  60,
 	}
 
 	// Color palette
@@ -130,6 +148,25 @@ func NewDefaultTheme() *Theme {
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(theme.Colors.Error)
 
+	theme.ToolWarning = lipgloss.NewStyle().
+		Background(lipgloss.Color("58")).
+		Foreground(lipgloss.Color("255")).
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(theme.Colors.Warning)
+
+	theme.ToolCritical = lipgloss.NewStyle().
+		Background(lipgloss.Color("88")).
+		Foreground(lipgloss.Color("255")).
+		Bold(true).
+		Padding(0, 1).
+		Border(lipgloss.ThickBorder()).
+		BorderForeground(theme.Colors.Error)
+
+	theme.ErrorAction = lipgloss.NewStyle().
+		Foreground(theme.Colors.Warning).
+		Italic(true)
+
 	theme.ToolParams = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("244")).
 		Italic(true)
@@ -142,6 +179,20 @@ func NewDefaultTheme() *Theme {
 	return theme
 }
 
+// NewAccessibleTheme creates the theme of the accessible mode. Borders are
+// hidden rather than removed, so the layout keeps its dimensions.
+func NewAccessibleTheme() *Theme {
+	theme := NewDefaultTheme()
+	theme.Accessible = true
+	for _, style := range []*lipgloss.Style{
+		&theme.ToolCall, &theme.ToolSuccess, &theme.ToolError,
+		&theme.ToolWarning, &theme.ToolCritical, &theme.ViewportBorder,
+	} {
+		*style = style.Border(lipgloss.HiddenBorder())
+	}
+	return theme
+}
+
 // LayoutDimensions provides centralized dimension calculations
 type LayoutDimensions struct {
 	theme *Theme
@@ -213,6 +264,27 @@ func (ld *LayoutDimensions) CalculateViewportHeightWithHeader(windowHeight, text
 	return availableHeight
 }
 
+// CalculatePaneWidths splits the window width between the main pane and the
+// right-hand sidebar, when it is shown. The sidebar gets a third of the
+// window within the bounds of the theme, less what the main pane needs to
+// keep its minimum width; it collapses to 0 when that leaves it narrower
+// than its own minimum, as in narrow terminals.
+func (ld *LayoutDimensions) CalculatePaneWidths(windowWidth int, sidebar bool) (mainWidth, sidebarWidth int) {
+	if !sidebar {
+		return windowWidth, 0
+	}
+	sidebarWidth = min(max(windowWidth/3, ld.theme.MinSidebarWidth), ld.theme.MaxSidebarWidth, windowWidth-ld.theme.MinMainPaneWidth)
+	if sidebarWidth < ld.theme.MinSidebarWidth {
+		return windowWidth, 0
+	}
+	return windowWidth - sidebarWidth, sidebarWidth
+}
+
+// GetMinSidebarWindowWidth returns the narrowest window that fits the sidebar
+func (ld *LayoutDimensions) GetMinSidebarWindowWidth() int {
+	return ld.theme.MinMainPaneWidth + ld.theme.MinSidebarWidth
+}
+
 // ValidateLayout validates that all component heights sum to the window height
 func (ld *LayoutDimensions) ValidateLayout(windowHeight, textareaHeight, suggestionAreaHeight, viewportFrameHeight int) error {
 	totalHeight := ld.GetHeaderHeight() + ld.GetStatusBarHeight() +
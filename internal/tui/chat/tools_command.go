@@ -0,0 +1,143 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// toolsCommand returns the /tools slash command, which lists the tools of
+// inspector with their statistics for the session, shows the parameters of
+// one, and disables or enables tools until the chat ends
+func toolsCommand(inspector ToolInspector) SlashCommand {
+	return SlashCommand{
+		Name:  "/tools",
+		Usage: "[name | disable <name> | enable <name>]",
+		Help:  "List the tools and their use, show one, or turn one off for this session",
+		Complete: func(args string) []string {
+			values := []string{"disable ", "enable "}
+			action, _, _ := strings.Cut(args, " ")
+			for _, tool := range inspector.Tools() {
+				switch {
+				case action == "disable" && tool.Enabled, action == "enable" && !tool.Enabled:
+					values = append(values, action+" "+tool.Name)
+				case action != "disable" && action != "enable":
+					values = append(values, tool.Name)
+				}
+			}
+			return values
+		},
+		Run: func(c *CommandContext, args string) tea.Cmd {
+			fields := strings.Fields(args)
+			switch {
+			case len(fields) == 0:
+				c.Notice(formatToolList(inspector.Tools()))
+			case len(fields) == 2 && (fields[0] == "disable" || fields[0] == "enable"):
+				enabled := fields[0] == "enable"
+				if err := inspector.SetToolEnabled(fields[1], enabled); err != nil {
+					c.Notice(fmt.Sprintf("🔧 %v", err))
+				} else if enabled {
+					c.Notice(fmt.Sprintf("🔧 %s is enabled again", fields[1]))
+				} else {
+					c.Notice(fmt.Sprintf("🔧 %s is disabled for the rest of this session; /tools enable %s turns it back on", fields[1], fields[1]))
+				}
+			case len(fields) == 1:
+				for _, tool := range inspector.Tools() {
+					if tool.Name == fields[0] {
+						c.Notice(formatToolDetails(tool))
+						return nil
+					}
+				}
+				c.Notice(fmt.Sprintf("🔧 No tool called %s; /tools lists them", fields[0]))
+			default:
+				c.Notice("🔧 Usage: /tools [name | disable <name> | enable <name>]")
+			}
+			return nil
+		},
+	}
+}
+
+// formatToolList renders one line per tool with its use this session
+func formatToolList(tools []ToolInfo) string {
+	if len(tools) == 0 {
+		return "🔧 The agent has no tools"
+	}
+	var total time.Duration
+	for _, tool := range tools {
+		total += tool.Duration
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔧 %d tool(s); /tools <name> shows the parameters of one", len(tools))
+	for _, tool := range tools {
+		name := tool.Name
+		if !tool.Enabled {
+			name += " (disabled)"
+		}
+		fmt.Fprintf(&b, "\n  %-28s %s", name, toolUsage(tool))
+		if total > 0 && tool.Calls > 0 {
+			fmt.Fprintf(&b, ", %.0f%% of tool time", 100*float64(tool.Duration)/float64(total))
+		}
+		fmt.Fprintf(&b, "\n      %s", firstLine(tool.Description))
+	}
+	return b.String()
+}
+
+// formatToolDetails renders the description, parameter schema and use of a
+// tool
+func formatToolDetails(tool ToolInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔧 %s", tool.Name)
+	if !tool.Enabled {
+		b.WriteString(" (disabled for this session)")
+	}
+	fmt.Fprintf(&b, "\n%s\n\nThis session: %s", strings.TrimSpace(tool.Description), toolUsage(tool))
+	if tool.Calls > 0 {
+		fmt.Fprintf(&b, ", %s on average", (tool.Duration / time.Duration(tool.Calls)).Round(time.Millisecond))
+		if tool.MaxDuration > 0 {
+			fmt.Fprintf(&b, ", %s at most", tool.MaxDuration.Round(time.Millisecond))
+		}
+	}
+	if len(tool.ErrorCodes) > 0 {
+		fmt.Fprintf(&b, "\nErrors: %s", formatErrorCodes(tool.ErrorCodes))
+	}
+
+	var schema bytes.Buffer
+	if err := json.Indent(&schema, tool.Parameters, "", "  "); err != nil || len(tool.Parameters) == 0 {
+		b.WriteString("\n\nNo parameter schema")
+	} else {
+		b.WriteString("\n\nParameters:\n" + schema.String())
+	}
+	return b.String()
+}
+
+// formatErrorCodes lists error codes with their counts, most frequent first
+func formatErrorCodes(codes map[string]int) string {
+	names := make([]string, 0, len(codes))
+	for code := range codes {
+		names = append(names, code)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if codes[names[i]] != codes[names[j]] {
+			return codes[names[i]] > codes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, code := range names {
+		parts[i] = fmt.Sprintf("%s ×%d", code, codes[code])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// toolUsage summarizes the calls and error rate of a tool
+func toolUsage(tool ToolInfo) string {
+	if tool.Calls == 0 {
+		return "not called yet"
+	}
+	return fmt.Sprintf("%d call(s), %d error(s) (%.0f%%)", tool.Calls, tool.Errors, 100*float64(tool.Errors)/float64(tool.Calls))
+}
@@ -0,0 +1,84 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inspectingProvider is a message provider with tools to inspect
+type inspectingProvider struct {
+	*MockMessageProvider
+	tools []ToolInfo
+}
+
+func (p *inspectingProvider) Tools() []ToolInfo {
+	return p.tools
+}
+
+func (p *inspectingProvider) SetToolEnabled(name string, enabled bool) error {
+	for i := range p.tools {
+		if p.tools[i].Name == name {
+			p.tools[i].Enabled = enabled
+			return nil
+		}
+	}
+	return fmt.Errorf("tool not found: %s", name)
+}
+
+func TestToolsCommand(t *testing.T) {
+	provider := &inspectingProvider{MockMessageProvider: NewMockMessageProvider(), tools: []ToolInfo{
+		{Name: "read_file", Description: "Read a file\nwith details", Parameters: json.RawMessage(`{"type":"object","properties":{"file_path":{"type":"string"}}}`), Enabled: true, Calls: 4, Errors: 1, Duration: 2 * time.Second, MaxDuration: 1200 * time.Millisecond, ErrorCodes: map[string]int{"FILE_NOT_FOUND": 1}},
+		{Name: "write_file", Description: "Write a file", Enabled: true},
+		{Name: "run_tests", Description: "Run the tests", Enabled: true, Calls: 1, Duration: 2 * time.Second},
+	}}
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	send := func(text string) string {
+		model.inputArea.SetValue(text)
+		updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		model = updated.(Model)
+		return lastMessageText(model)
+	}
+
+	list := send("/tools")
+	assert.Contains(t, list, "3 tool(s)")
+	assert.Contains(t, list, "4 call(s), 1 error(s) (25%), 50% of tool time")
+	assert.Contains(t, list, "1 call(s), 0 error(s) (0%), 50% of tool time")
+	assert.Contains(t, list, "not called yet")
+	assert.NotContains(t, list, "with details", "only the first line of descriptions is listed")
+
+	details := send("/tools read_file")
+	assert.Contains(t, details, "with details")
+	assert.Contains(t, details, `"file_path": {`)
+	assert.Contains(t, details, "500ms on average, 1.2s at most")
+	assert.Contains(t, details, "Errors: FILE_NOT_FOUND ×1")
+	assert.Contains(t, send("/tools nope"), "No tool called nope")
+
+	assert.Contains(t, send("/tools disable write_file"), "disabled for the rest of this session")
+	assert.False(t, provider.tools[1].Enabled)
+	assert.Contains(t, send("/tools"), "write_file (disabled)")
+	assert.Contains(t, send("/tools disable nope"), "tool not found")
+	assert.Contains(t, send("/tools enable write_file"), "enabled again")
+	assert.True(t, provider.tools[1].Enabled)
+
+	cmd, ok := model.commands.Lookup("/tools")
+	require.True(t, ok)
+	assert.Contains(t, cmd.Complete("disable "), "disable read_file")
+	assert.NotContains(t, cmd.Complete("enable "), "enable read_file", "only disabled tools are offered")
+	assert.Contains(t, cmd.Complete("r"), "read_file")
+}
+
+func TestToolsCommandNeedsInspector(t *testing.T) {
+	provider := NewMockMessageProvider()
+	defer provider.Close()
+	model := NewChatModel(WithParentContext(context.Background()), WithMessageProvider(provider))
+	_, ok := model.commands.Lookup("/tools")
+	assert.False(t, ok)
+}
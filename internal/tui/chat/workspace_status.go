@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	cgecontext "github.com/castrovroberto/CGE/internal/context"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WorkspaceStatus summarizes the git and index state shown in the status bar
+type WorkspaceStatus struct {
+	IsRepo     bool
+	Branch     string
+	Dirty      bool      // Uncommitted or untracked changes
+	IndexBuilt time.Time // When the semantic index was last written; zero when there is none
+	IndexStale bool      // A commit was made after the index was built
+}
+
+// workspaceStatusMsg carries a freshly loaded WorkspaceStatus
+type workspaceStatusMsg struct {
+	status WorkspaceStatus
+}
+
+// refreshWorkspaceStatus loads the workspace status in the background, since
+// git can be slow in large repositories
+func refreshWorkspaceStatus(workspaceRoot string) tea.Cmd {
+	return func() tea.Msg {
+		return workspaceStatusMsg{status: loadWorkspaceStatus(workspaceRoot)}
+	}
+}
+
+// loadWorkspaceStatus reads the branch, dirty state and index freshness of a workspace
+func loadWorkspaceStatus(workspaceRoot string) WorkspaceStatus {
+	var status WorkspaceStatus
+	status.Branch, status.IsRepo = getGitInfo(workspaceRoot)
+
+	if status.IsRepo {
+		cmd := exec.Command("git", "status", "--porcelain")
+		cmd.Dir = workspaceRoot
+		if output, err := cmd.Output(); err == nil {
+			status.Dirty = strings.TrimSpace(string(output)) != ""
+		}
+	}
+
+	if info, err := os.Stat(cgecontext.IndexFilePath(workspaceRoot)); err == nil {
+		status.IndexBuilt = info.ModTime()
+		if status.IsRepo {
+			if commitTime, ok := lastCommitTime(workspaceRoot); ok {
+				status.IndexStale = commitTime.After(status.IndexBuilt)
+			}
+		}
+	}
+
+	return status
+}
+
+// lastCommitTime returns the commit time of HEAD
+func lastCommitTime(workspaceRoot string) (time.Time, bool) {
+	cmd := exec.Command("git", "log", "-1", "--format=%ct")
+	cmd.Dir = workspaceRoot
+	output, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
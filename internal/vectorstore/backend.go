@@ -0,0 +1,119 @@
+package vectorstore
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Backend types understood by NewBackend
+const (
+	BackendFlat = "flat"
+	BackendHNSW = "hnsw"
+)
+
+// Neighbor is a single nearest-neighbor match returned by a backend
+type Neighbor struct {
+	ID         string
+	Similarity float64
+}
+
+// VectorStoreBackend owns the embedding vectors of a VectorStore and answers
+// nearest-neighbor queries over them. Vectors passed in are already normalized,
+// so similarity is the dot product. Backends are not safe for concurrent
+// mutation; VectorStore serializes access.
+type VectorStoreBackend interface {
+	// Add inserts or replaces the vector for id
+	Add(id string, vector []float32)
+	// Remove deletes the vector for id, if present
+	Remove(id string)
+	// Vector returns a copy of the stored vector for id
+	Vector(id string) ([]float32, bool)
+	// Search returns up to k neighbors most similar to query, best first.
+	// accept, when non-nil, restricts results to IDs for which it returns true.
+	Search(query []float32, k int, accept func(id string) bool) []Neighbor
+	// Len returns the number of stored vectors
+	Len() int
+	// Clear removes all vectors
+	Clear()
+}
+
+// BackendConfig selects and tunes a vector store backend
+type BackendConfig struct {
+	Type string     // "flat" (default) or "hnsw"
+	HNSW HNSWConfig // Used when Type is "hnsw"
+}
+
+// ValidateBackend reports whether cfg names a backend NewBackend can create
+func ValidateBackend(cfg BackendConfig) error {
+	switch cfg.Type {
+	case "", BackendFlat, BackendHNSW:
+		return nil
+	default:
+		return fmt.Errorf("unknown vector store backend %q (use %q or %q)", cfg.Type, BackendFlat, BackendHNSW)
+	}
+}
+
+// NewBackend creates the backend described by cfg
+func NewBackend(cfg BackendConfig) (VectorStoreBackend, error) {
+	if err := ValidateBackend(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Type == BackendHNSW {
+		return NewHNSWBackend(cfg.HNSW), nil
+	}
+	return NewFlatBackend(), nil
+}
+
+// FlatBackend performs exact brute-force search. It is the best choice for
+// small and medium repositories where a linear scan is already fast.
+type FlatBackend struct {
+	vectors map[string][]float32
+}
+
+// NewFlatBackend creates an exact, brute-force backend
+func NewFlatBackend() *FlatBackend {
+	return &FlatBackend{vectors: make(map[string][]float32)}
+}
+
+func (b *FlatBackend) Add(id string, vector []float32) {
+	b.vectors[id] = vector
+}
+
+func (b *FlatBackend) Remove(id string) {
+	delete(b.vectors, id)
+}
+
+func (b *FlatBackend) Vector(id string) ([]float32, bool) {
+	vector, ok := b.vectors[id]
+	if !ok {
+		return nil, false
+	}
+	return append([]float32(nil), vector...), true
+}
+
+func (b *FlatBackend) Search(query []float32, k int, accept func(id string) bool) []Neighbor {
+	neighbors := make([]Neighbor, 0, len(b.vectors))
+	for id, vector := range b.vectors {
+		if accept != nil && !accept(id) {
+			continue
+		}
+		neighbors = append(neighbors, Neighbor{ID: id, Similarity: cosineSimilarity(query, vector)})
+	}
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		return neighbors[i].Similarity > neighbors[j].Similarity
+	})
+
+	if k > 0 && len(neighbors) > k {
+		neighbors = neighbors[:k]
+	}
+	return neighbors
+}
+
+func (b *FlatBackend) Len() int {
+	return len(b.vectors)
+}
+
+func (b *FlatBackend) Clear() {
+	b.vectors = make(map[string][]float32)
+}
@@ -0,0 +1,188 @@
+package vectorstore
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func randomVectors(n, dim int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = float32(rng.NormFloat64())
+		}
+		vectors[i] = normalizeVector(v)
+	}
+	return vectors
+}
+
+func buildBackend(b VectorStoreBackend, vectors [][]float32) VectorStoreBackend {
+	for i, v := range vectors {
+		b.Add(fmt.Sprintf("doc_%d", i), v)
+	}
+	return b
+}
+
+// recallAt measures the fraction of exact top-k neighbors found by the backend
+func recallAt(t *testing.T, backend VectorStoreBackend, vectors, queries [][]float32, k int) float64 {
+	t.Helper()
+	exact := buildBackend(NewFlatBackend(), vectors)
+
+	found, total := 0, 0
+	for _, q := range queries {
+		want := make(map[string]bool, k)
+		for _, n := range exact.Search(q, k, nil) {
+			want[n.ID] = true
+		}
+		for _, n := range backend.Search(q, k, nil) {
+			if want[n.ID] {
+				found++
+			}
+		}
+		total += k
+	}
+	return float64(found) / float64(total)
+}
+
+func TestHNSWRecall(t *testing.T) {
+	vectors := randomVectors(2000, 32, 1)
+	queries := randomVectors(50, 32, 2)
+
+	for _, float16 := range []bool{false, true} {
+		backend := buildBackend(NewHNSWBackend(HNSWConfig{Float16: float16}), vectors)
+		if backend.Len() != len(vectors) {
+			t.Fatalf("Expected %d vectors, got %d", len(vectors), backend.Len())
+		}
+		if recall := recallAt(t, backend, vectors, queries, 10); recall < 0.9 {
+			t.Errorf("Expected recall@10 >= 0.9 (float16=%v), got %.3f", float16, recall)
+		}
+	}
+}
+
+func TestHNSWRemoveAndFilter(t *testing.T) {
+	vectors := randomVectors(300, 16, 3)
+	backend := buildBackend(NewHNSWBackend(HNSWConfig{}), vectors)
+
+	// Removing more than half the vectors triggers a rebuild; results must only contain live IDs
+	for i := 0; i < 200; i++ {
+		backend.Remove(fmt.Sprintf("doc_%d", i))
+	}
+	if backend.Len() != 100 {
+		t.Fatalf("Expected 100 vectors after removal, got %d", backend.Len())
+	}
+	for _, n := range backend.Search(vectors[0], 20, nil) {
+		var idx int
+		fmt.Sscanf(n.ID, "doc_%d", &idx)
+		if idx < 200 {
+			t.Errorf("Search returned removed vector %s", n.ID)
+		}
+	}
+
+	// A restrictive filter still returns exact matches
+	only := "doc_250"
+	results := backend.Search(vectors[0], 5, func(id string) bool { return id == only })
+	if len(results) != 1 || results[0].ID != only {
+		t.Errorf("Expected only %s, got %+v", only, results)
+	}
+}
+
+func TestFloat16RoundTrip(t *testing.T) {
+	for _, v := range []float32{0, 1, -1, 0.5, 0.333, -0.0001, 6e-5, 65504} {
+		got := halfToFloat32(float32ToHalf(v))
+		if diff := math.Abs(float64(got - v)); diff > math.Abs(float64(v))*1e-3+1e-7 {
+			t.Errorf("float16 round trip of %v gave %v", v, got)
+		}
+	}
+	if !math.IsInf(float64(halfToFloat32(float32ToHalf(1e6))), 1) {
+		t.Errorf("Expected overflow to +Inf")
+	}
+}
+
+func TestVectorStoreWithHNSWBackend(t *testing.T) {
+	vs := NewVectorStoreWithBackend(0, NewHNSWBackend(HNSWConfig{Float16: true}))
+	vectors := randomVectors(50, 8, 4)
+	for i, v := range vectors {
+		lang := "go"
+		if i%2 == 1 {
+			lang = "py"
+		}
+		if err := vs.Add(fmt.Sprintf("doc_%d", i), "content", v, map[string]interface{}{"lang": lang}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	results, err := vs.SearchWithFilter(vectors[1], 3, map[string]interface{}{"lang": "py"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 3 || results[0].Document.ID != "doc_1" {
+		t.Fatalf("Expected doc_1 as best filtered match, got %+v", results)
+	}
+	for _, r := range results {
+		if r.Document.Metadata["lang"] != "py" || len(r.Document.Embedding) != 8 {
+			t.Errorf("Unexpected result document %+v", r.Document)
+		}
+	}
+
+	// Export/Import round-trips embeddings through the backend
+	data, err := vs.Export()
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	restored := NewVectorStore(0)
+	if err := restored.Import(data); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if doc, ok := restored.Get("doc_7"); !ok || len(doc.Embedding) != 8 {
+		t.Errorf("Expected doc_7 with embedding after import, got %+v", doc)
+	}
+}
+
+func benchmarkSearch(b *testing.B, backend VectorStoreBackend) {
+	vectors := randomVectors(10000, 256, 5)
+	buildBackend(backend, vectors)
+	queries := randomVectors(100, 256, 6)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		backend.Search(queries[i%len(queries)], 10, nil)
+	}
+}
+
+func BenchmarkFlatSearch(b *testing.B) {
+	benchmarkSearch(b, NewFlatBackend())
+}
+
+func BenchmarkHNSWSearch(b *testing.B) {
+	benchmarkSearch(b, NewHNSWBackend(HNSWConfig{}))
+}
+
+func BenchmarkHNSWFloat16Search(b *testing.B) {
+	benchmarkSearch(b, NewHNSWBackend(HNSWConfig{Float16: true}))
+}
+
+func BenchmarkHNSWInsert(b *testing.B) {
+	vectors := randomVectors(b.N, 256, 7)
+	backend := NewHNSWBackend(HNSWConfig{})
+
+	b.ResetTimer()
+	for i, v := range vectors {
+		backend.Add(fmt.Sprintf("doc_%d", i), v)
+	}
+}
+
+func TestNewBackendRejectsUnknownTypes(t *testing.T) {
+	for _, backendType := range []string{"", BackendFlat, BackendHNSW} {
+		if _, err := NewBackend(BackendConfig{Type: backendType}); err != nil {
+			t.Errorf("Expected backend %q to be accepted, got: %v", backendType, err)
+		}
+	}
+	if _, err := NewBackend(BackendConfig{Type: "hsnw"}); err == nil || !strings.Contains(err.Error(), `"hsnw"`) {
+		t.Errorf("Expected a typo to be rejected, got: %v", err)
+	}
+}
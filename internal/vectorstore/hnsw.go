@@ -0,0 +1,469 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// HNSWConfig tunes the recall/speed/memory tradeoffs of HNSWBackend
+type HNSWConfig struct {
+	// M is the number of neighbors kept per node on upper layers (2*M on layer 0).
+	// Higher values improve recall at the cost of memory and insert time. Default 16.
+	M int
+	// EfConstruction is the candidate list size while inserting. Default 200.
+	EfConstruction int
+	// EfSearch is the candidate list size while querying; raise it for better
+	// recall, lower it for faster queries. Default 64.
+	EfSearch int
+	// Float16 stores vectors as IEEE half-precision floats, halving memory use
+	// with a negligible effect on ranking for normalized embeddings
+	Float16 bool
+	// Seed makes level assignment deterministic; 0 uses a fixed default
+	Seed int64
+}
+
+func (c HNSWConfig) withDefaults() HNSWConfig {
+	if c.M <= 0 {
+		c.M = 16
+	}
+	if c.EfConstruction <= 0 {
+		c.EfConstruction = 200
+	}
+	if c.EfSearch <= 0 {
+		c.EfSearch = 64
+	}
+	if c.Seed == 0 {
+		c.Seed = 42
+	}
+	return c
+}
+
+type hnswNode struct {
+	id      string
+	vector  []float32 // nil when quantized
+	half    []uint16  // float16 vector when quantized
+	friends [][]int32 // neighbor node indexes per layer
+	deleted bool
+}
+
+// HNSWBackend is an approximate nearest neighbor index based on Hierarchical
+// Navigable Small World graphs (Malkov & Yashunin, 2016). Deleted vectors are
+// tombstoned and the graph is rebuilt once tombstones outnumber live vectors.
+type HNSWBackend struct {
+	config     HNSWConfig
+	nodes      []*hnswNode
+	byID       map[string]int32
+	entry      int32
+	maxLevel   int
+	levelMult  float64
+	rng        *rand.Rand
+	tombstones int
+}
+
+// NewHNSWBackend creates an empty HNSW index
+func NewHNSWBackend(config HNSWConfig) *HNSWBackend {
+	config = config.withDefaults()
+	return &HNSWBackend{
+		config:    config,
+		byID:      make(map[string]int32),
+		entry:     -1,
+		levelMult: 1 / math.Log(float64(config.M)),
+		rng:       rand.New(rand.NewSource(config.Seed)), // #nosec G404 - level assignment is not security sensitive
+	}
+}
+
+func (h *HNSWBackend) Add(id string, vector []float32) {
+	if _, exists := h.byID[id]; exists {
+		h.Remove(id)
+	}
+
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	node := &hnswNode{id: id, friends: make([][]int32, level+1)}
+	if h.config.Float16 {
+		node.half = make([]uint16, len(vector))
+		for i, v := range vector {
+			node.half[i] = float32ToHalf(v)
+		}
+	} else {
+		node.vector = append([]float32(nil), vector...)
+	}
+
+	idx := int32(len(h.nodes))
+	h.nodes = append(h.nodes, node)
+	h.byID[id] = idx
+
+	if h.entry < 0 {
+		h.entry = idx
+		h.maxLevel = level
+		return
+	}
+
+	// Greedy descent through layers above the node's level
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.searchLayer(vector, []int32{ep}, 1, l)[0].node
+	}
+
+	entryPoints := []int32{ep}
+	for l := minInt(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vector, entryPoints, h.config.EfConstruction, l)
+
+		neighbors := candidates
+		if len(neighbors) > h.config.M {
+			neighbors = neighbors[:h.config.M]
+		}
+		node.friends[l] = make([]int32, 0, len(neighbors))
+		for _, c := range neighbors {
+			node.friends[l] = append(node.friends[l], c.node)
+			h.link(c.node, idx, l)
+		}
+
+		entryPoints = entryPoints[:0]
+		for _, c := range candidates {
+			entryPoints = append(entryPoints, c.node)
+		}
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entry = idx
+	}
+}
+
+// link adds target to the neighbor list of node on a layer, pruning to the
+// closest neighbors when the list exceeds its capacity
+func (h *HNSWBackend) link(node, target int32, layer int) {
+	n := h.nodes[node]
+	n.friends[layer] = append(n.friends[layer], target)
+
+	maxConn := h.config.M
+	if layer == 0 {
+		maxConn = 2 * h.config.M
+	}
+	if len(n.friends[layer]) <= maxConn {
+		return
+	}
+
+	base := h.vectorOf(n)
+	candidates := make([]hnswCandidate, len(n.friends[layer]))
+	for i, f := range n.friends[layer] {
+		candidates[i] = hnswCandidate{node: f, dist: h.distance(base, f)}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+
+	n.friends[layer] = n.friends[layer][:0]
+	for _, c := range candidates[:maxConn] {
+		n.friends[layer] = append(n.friends[layer], c.node)
+	}
+}
+
+func (h *HNSWBackend) Remove(id string) {
+	idx, exists := h.byID[id]
+	if !exists {
+		return
+	}
+	delete(h.byID, id)
+	h.nodes[idx].deleted = true
+	h.tombstones++
+
+	// Tombstones still route searches; rebuild once they dominate the graph
+	if h.tombstones > 64 && h.tombstones > len(h.byID) {
+		h.rebuild()
+	}
+}
+
+func (h *HNSWBackend) rebuild() {
+	live := make([]*hnswNode, 0, len(h.byID))
+	for _, node := range h.nodes {
+		if !node.deleted {
+			live = append(live, node)
+		}
+	}
+
+	h.nodes = nil
+	h.byID = make(map[string]int32, len(live))
+	h.entry = -1
+	h.maxLevel = 0
+	h.tombstones = 0
+	for _, node := range live {
+		h.Add(node.id, h.vectorOf(node))
+	}
+}
+
+func (h *HNSWBackend) Vector(id string) ([]float32, bool) {
+	idx, exists := h.byID[id]
+	if !exists {
+		return nil, false
+	}
+	return append([]float32(nil), h.vectorOf(h.nodes[idx])...), true
+}
+
+func (h *HNSWBackend) Search(query []float32, k int, accept func(id string) bool) []Neighbor {
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.searchLayer(query, []int32{ep}, 1, l)[0].node
+	}
+
+	ef := h.config.EfSearch
+	if ef < k {
+		ef = k
+	}
+	if accept != nil {
+		ef *= 4 // Filters discard candidates; widen the beam to compensate
+	}
+
+	var neighbors []Neighbor
+	for _, c := range h.searchLayer(query, []int32{ep}, ef, 0) {
+		node := h.nodes[c.node]
+		if node.deleted || (accept != nil && !accept(node.id)) {
+			continue
+		}
+		neighbors = append(neighbors, Neighbor{ID: node.id, Similarity: float64(1 - c.dist)})
+		if len(neighbors) == k {
+			return neighbors
+		}
+	}
+
+	// A restrictive filter can leave too few graph results; fall back to an exact scan
+	if accept != nil && len(neighbors) < k && len(neighbors) < len(h.byID) {
+		return h.exactSearch(query, k, accept)
+	}
+	return neighbors
+}
+
+func (h *HNSWBackend) exactSearch(query []float32, k int, accept func(id string) bool) []Neighbor {
+	var candidates []hnswCandidate
+	for id, idx := range h.byID {
+		if accept != nil && !accept(id) {
+			continue
+		}
+		candidates = append(candidates, hnswCandidate{node: idx, dist: h.distance(query, idx)})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	neighbors := make([]Neighbor, len(candidates))
+	for i, c := range candidates {
+		neighbors[i] = Neighbor{ID: h.nodes[c.node].id, Similarity: float64(1 - c.dist)}
+	}
+	return neighbors
+}
+
+func (h *HNSWBackend) Len() int {
+	return len(h.byID)
+}
+
+func (h *HNSWBackend) Clear() {
+	h.nodes = nil
+	h.byID = make(map[string]int32)
+	h.entry = -1
+	h.maxLevel = 0
+	h.tombstones = 0
+}
+
+// searchLayer returns up to ef nodes closest to query on a layer, nearest first
+func (h *HNSWBackend) searchLayer(query []float32, entryPoints []int32, ef, layer int) []hnswCandidate {
+	visited := make(map[int32]struct{}, ef*4)
+	candidates := &hnswMinHeap{}
+	results := &hnswMaxHeap{}
+
+	for _, ep := range entryPoints {
+		if _, seen := visited[ep]; seen {
+			continue
+		}
+		visited[ep] = struct{}{}
+		c := hnswCandidate{node: ep, dist: h.distance(query, ep)}
+		heap.Push(candidates, c)
+		heap.Push(results, c)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && c.dist > (*results)[0].dist {
+			break
+		}
+
+		friends := h.nodes[c.node].friends
+		if layer >= len(friends) {
+			continue
+		}
+		for _, f := range friends[layer] {
+			if _, seen := visited[f]; seen {
+				continue
+			}
+			visited[f] = struct{}{}
+
+			d := h.distance(query, f)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(candidates, hnswCandidate{node: f, dist: d})
+				heap.Push(results, hnswCandidate{node: f, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	out := make([]hnswCandidate, results.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return out
+}
+
+// distance is 1 - cosine similarity between normalized vectors
+func (h *HNSWBackend) distance(query []float32, idx int32) float32 {
+	node := h.nodes[idx]
+	var dot float32
+	if node.half != nil {
+		table := halfTable()
+		for i := range query {
+			if i < len(node.half) {
+				dot += query[i] * table[node.half[i]]
+			}
+		}
+	} else {
+		for i := range query {
+			if i < len(node.vector) {
+				dot += query[i] * node.vector[i]
+			}
+		}
+	}
+	return 1 - dot
+}
+
+func (h *HNSWBackend) vectorOf(node *hnswNode) []float32 {
+	if node.half == nil {
+		return node.vector
+	}
+	vector := make([]float32, len(node.half))
+	for i, v := range node.half {
+		vector[i] = halfToFloat32(v)
+	}
+	return vector
+}
+
+type hnswCandidate struct {
+	node int32
+	dist float32
+}
+
+type hnswMinHeap []hnswCandidate
+
+func (h hnswMinHeap) Len() int            { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool  { return h[i].dist < h[j].dist }
+func (h hnswMinHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMinHeap) Push(x interface{}) { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMinHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+type hnswMaxHeap []hnswCandidate
+
+func (h hnswMaxHeap) Len() int            { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h hnswMaxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMaxHeap) Push(x interface{}) { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMaxHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// float32ToHalf converts to IEEE 754 half precision, rounding to nearest
+func float32ToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int((bits>>23)&0xff) - 127 + 15
+	mant := bits & 0x7fffff
+
+	switch {
+	case (bits>>23)&0xff == 0xff: // Inf or NaN
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp >= 0x1f: // Overflow
+		return sign | 0x7c00
+	case exp <= 0: // Subnormal or zero
+		if exp < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint(14 - exp)
+		half := uint16(mant >> shift)
+		if (mant>>(shift-1))&1 != 0 {
+			half++
+		}
+		return sign | half
+	}
+
+	half := sign | uint16(exp)<<10 | uint16(mant>>13)
+	if mant&0x1000 != 0 {
+		half++ // Carry into the exponent is the correct rounding result
+	}
+	return half
+}
+
+// halfToFloat32 converts an IEEE 754 half precision value to float32
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Normalize the subnormal value
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		return math.Float32frombits(sign | e<<23 | (mant&0x3ff)<<13)
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+var (
+	halfTableOnce  sync.Once
+	halfTableValue []float32
+)
+
+// halfTable returns a lookup table decoding every float16 value
+func halfTable() []float32 {
+	halfTableOnce.Do(func() {
+		halfTableValue = make([]float32, 1<<16)
+		for i := range halfTableValue {
+			halfTableValue[i] = halfToFloat32(uint16(i))
+		}
+	})
+	return halfTableValue
+}
@@ -0,0 +1,139 @@
+package vectorstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/castrovroberto/CGE/internal/statedir"
+)
+
+// Metadata keys used for incremental indexing
+const (
+	MetadataFilePath    = "file_path"
+	MetadataContentHash = "content_hash"
+	MetadataFileHash    = "file_hash"
+)
+
+// IndexFilePath returns where `CGE index` persists the vector index of a
+// workspace, for retrieval to load
+func IndexFilePath(workspaceRoot string) string {
+	return statedir.Path(workspaceRoot, statedir.Index, "vectors.json")
+}
+
+// ContentHash returns a stable hash of chunk or file content
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// MetadataInt returns the integer metadata key of doc, such as start_line,
+// whether it was stored in memory or read back from a saved index as a float
+func MetadataInt(doc *Document, key string) (int, bool) {
+	switch v := doc.Metadata[key].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// DocumentsForFile returns all documents whose file_path metadata matches path
+func (vs *VectorStore) DocumentsForFile(path string) []*Document {
+	vs.mutex.RLock()
+	defer vs.mutex.RUnlock()
+
+	var docs []*Document
+	for _, doc := range vs.documents {
+		if doc.Metadata[MetadataFilePath] == path {
+			docs = append(docs, vs.withEmbedding(doc))
+		}
+	}
+	return docs
+}
+
+// IndexedFiles returns the set of file paths that have at least one document
+func (vs *VectorStore) IndexedFiles() map[string]bool {
+	vs.mutex.RLock()
+	defer vs.mutex.RUnlock()
+
+	files := make(map[string]bool)
+	for _, doc := range vs.documents {
+		if path, ok := doc.Metadata[MetadataFilePath].(string); ok {
+			files[path] = true
+		}
+	}
+	return files
+}
+
+// DeleteFile removes every document belonging to path and returns how many were removed
+func (vs *VectorStore) DeleteFile(path string) int {
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+
+	removed := 0
+	for id, doc := range vs.documents {
+		if doc.Metadata[MetadataFilePath] == path {
+			delete(vs.documents, id)
+			vs.backend.Remove(id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// SaveToFile writes the store to path, creating parent directories as needed
+func (vs *VectorStore) SaveToFile(path string) error {
+	data, err := vs.Export()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	// Write atomically so an interrupted save never leaves a truncated index
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// LoadFromFile replaces the store contents with the index saved at path.
+// A missing file is not an error; the store is left empty.
+func (vs *VectorStore) LoadFromFile(path string) error {
+	data, err := os.ReadFile(path) // #nosec G304 - index path is controlled by the application
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	return vs.Import(data)
+}
+
+// LoadIndex loads the index saved at path when its embeddings come from
+// embeddingModel and, when dimension is positive, have that dimension.
+// Embeddings of another model cannot be compared with new ones, so a saved
+// index that does not match is discarded: the store is left empty and stale
+// reports it. A missing file leaves the store empty without being stale.
+func (vs *VectorStore) LoadIndex(path, embeddingModel string, dimension int) (stale bool, err error) {
+	if err := vs.LoadFromFile(path); err != nil {
+		return false, err
+	}
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+	if len(vs.documents) > 0 && (vs.embeddingModel != embeddingModel || (dimension > 0 && vs.dimension != dimension)) {
+		stale = true
+		vs.documents = make(map[string]*Document)
+		vs.backend.Clear()
+		vs.dimension = vs.configuredDimension
+	}
+	vs.embeddingModel = embeddingModel
+	return stale, nil
+}
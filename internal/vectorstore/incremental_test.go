@@ -0,0 +1,73 @@
+package vectorstore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/castrovroberto/CGE/internal/textutils"
+)
+
+func TestLoadIndexKeyedByEmbeddingModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	saved := NewVectorStore(0)
+	saved.SetEmbeddingModel("nomic-embed-text")
+	chunk := textutils.TextChunk{Content: "func A() {}", StartLine: 3, EndLine: 5}
+	if err := saved.AddChunk(chunk, []float32{1, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saved.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		model     string
+		dimension int
+		wantStale bool
+	}{
+		{"same model", "nomic-embed-text", 0, false},
+		{"same model and dimension", "nomic-embed-text", 3, false},
+		{"other model", "text-embedding-ada-002", 0, true},
+		{"other dimension", "nomic-embed-text", 768, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewVectorStore(0)
+			stale, err := store.LoadIndex(path, tt.model, tt.dimension)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stale != tt.wantStale {
+				t.Errorf("stale = %v, want %v", stale, tt.wantStale)
+			}
+			wantCount, wantDimension := 1, 3
+			if tt.wantStale {
+				wantCount, wantDimension = 0, 0
+			}
+			if store.Count() != wantCount || store.Dimension() != wantDimension {
+				t.Errorf("got %d document(s) of dimension %d, want %d of %d", store.Count(), store.Dimension(), wantCount, wantDimension)
+			}
+			if store.EmbeddingModel() != tt.model {
+				t.Errorf("embedding model = %q, want %q", store.EmbeddingModel(), tt.model)
+			}
+		})
+	}
+
+	t.Run("line numbers survive saving", func(t *testing.T) {
+		store := NewVectorStore(0)
+		if _, err := store.LoadIndex(path, "nomic-embed-text", 0); err != nil {
+			t.Fatal(err)
+		}
+		doc, _ := store.Get(ChunkID(chunk))
+		if start, ok := MetadataInt(doc, "start_line"); !ok || start != 3 {
+			t.Errorf("start_line = %d, %v; want 3", start, ok)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		stale, err := NewVectorStore(0).LoadIndex(filepath.Join(t.TempDir(), "none.json"), "nomic-embed-text", 0)
+		if err != nil || stale {
+			t.Errorf("LoadIndex = %v, %v; want not stale and no error", stale, err)
+		}
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
-	"sort"
 	"sync"
 	"time"
 
@@ -27,18 +26,31 @@ type SearchResult struct {
 	Score      float64   `json:"score"` // Alias for similarity for backward compatibility
 }
 
-// VectorStore provides in-memory vector storage and similarity search
+// VectorStore provides in-memory vector storage and similarity search.
+// Document metadata lives in the store; embeddings are owned by the backend.
 type VectorStore struct {
 	documents map[string]*Document
+	backend   VectorStoreBackend
 	mutex     sync.RWMutex
 	dimension int // Expected embedding dimension
+
+	configuredDimension int    // Dimension given at creation; 0 learns it from the first document
+	embeddingModel      string // Model the embeddings come from, saved with the store
 }
 
-// NewVectorStore creates a new in-memory vector store
+// NewVectorStore creates a new in-memory vector store with exact (flat) search
 func NewVectorStore(dimension int) *VectorStore {
+	return NewVectorStoreWithBackend(dimension, NewFlatBackend())
+}
+
+// NewVectorStoreWithBackend creates a vector store that delegates nearest
+// neighbor search to backend
+func NewVectorStoreWithBackend(dimension int, backend VectorStoreBackend) *VectorStore {
 	return &VectorStore{
-		documents: make(map[string]*Document),
-		dimension: dimension,
+		documents:           make(map[string]*Document),
+		backend:             backend,
+		dimension:           dimension,
+		configuredDimension: dimension,
 	}
 }
 
@@ -62,12 +74,12 @@ func (vs *VectorStore) Add(id, content string, embedding []float32, metadata map
 	doc := &Document{
 		ID:        id,
 		Content:   content,
-		Embedding: normalizedEmbedding,
 		Metadata:  metadata,
 		Timestamp: time.Now(),
 	}
 
 	vs.documents[id] = doc
+	vs.backend.Add(id, normalizedEmbedding)
 	return nil
 }
 
@@ -83,9 +95,22 @@ func (vs *VectorStore) AddChunk(chunk textutils.TextChunk, embedding []float32)
 	for k, v := range chunk.Metadata {
 		metadata[k] = v
 	}
+	if _, ok := metadata[MetadataContentHash]; !ok {
+		metadata[MetadataContentHash] = ContentHash(chunk.Content)
+	}
+
+	return vs.Add(ChunkID(chunk), chunk.Content, embedding, metadata)
+}
+
+// ChunkID returns the document ID used for a chunk
+func ChunkID(chunk textutils.TextChunk) string {
+	return fmt.Sprintf("chunk_%d_%s", chunk.ChunkIndex, chunk.Metadata["file_path"])
+}
 
-	id := fmt.Sprintf("chunk_%d_%s", chunk.ChunkIndex, chunk.Metadata["file_path"])
-	return vs.Add(id, chunk.Content, embedding, metadata)
+// HasChunk reports whether the chunk is already stored with identical content
+func (vs *VectorStore) HasChunk(chunk textutils.TextChunk) bool {
+	doc, exists := vs.Get(ChunkID(chunk))
+	return exists && doc.Metadata[MetadataContentHash] == ContentHash(chunk.Content)
 }
 
 // Search finds the most similar documents to the query embedding
@@ -97,31 +122,37 @@ func (vs *VectorStore) Search(queryEmbedding []float32, limit int) ([]*SearchRes
 		return nil, fmt.Errorf("query embedding dimension mismatch: expected %d, got %d", vs.dimension, len(queryEmbedding))
 	}
 
-	normalizedQuery := normalizeVector(queryEmbedding)
-	var results []*SearchResult
+	return vs.searchBackend(queryEmbedding, limit, nil), nil
+}
 
-	// Calculate similarity for all documents
-	for _, doc := range vs.documents {
-		similarity := cosineSimilarity(normalizedQuery, doc.Embedding)
-		result := &SearchResult{
-			Document:   doc,
-			Similarity: similarity,
-			Score:      similarity,
-		}
-		results = append(results, result)
+// searchBackend runs a nearest-neighbor query; callers must hold the read lock
+func (vs *VectorStore) searchBackend(queryEmbedding []float32, limit int, accept func(id string) bool) []*SearchResult {
+	k := limit
+	if k <= 0 {
+		k = len(vs.documents)
 	}
 
-	// Sort by similarity (descending)
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Similarity > results[j].Similarity
-	})
-
-	// Limit results
-	if limit > 0 && len(results) > limit {
-		results = results[:limit]
+	neighbors := vs.backend.Search(normalizeVector(queryEmbedding), k, accept)
+	results := make([]*SearchResult, 0, len(neighbors))
+	for _, n := range neighbors {
+		doc, exists := vs.documents[n.ID]
+		if !exists {
+			continue
+		}
+		results = append(results, &SearchResult{
+			Document:   vs.withEmbedding(doc),
+			Similarity: n.Similarity,
+			Score:      n.Similarity,
+		})
 	}
+	return results
+}
 
-	return results, nil
+// withEmbedding returns a copy of doc with its embedding loaded from the backend
+func (vs *VectorStore) withEmbedding(doc *Document) *Document {
+	materialized := *doc
+	materialized.Embedding, _ = vs.backend.Vector(doc.ID)
+	return &materialized
 }
 
 // SearchByText searches for documents similar to the given text
@@ -141,7 +172,10 @@ func (vs *VectorStore) Get(id string) (*Document, bool) {
 	defer vs.mutex.RUnlock()
 
 	doc, exists := vs.documents[id]
-	return doc, exists
+	if !exists {
+		return nil, false
+	}
+	return vs.withEmbedding(doc), true
 }
 
 // Delete removes a document from the store
@@ -152,6 +186,7 @@ func (vs *VectorStore) Delete(id string) bool {
 	_, exists := vs.documents[id]
 	if exists {
 		delete(vs.documents, id)
+		vs.backend.Remove(id)
 	}
 	return exists
 }
@@ -182,6 +217,31 @@ func (vs *VectorStore) Clear() {
 	defer vs.mutex.Unlock()
 
 	vs.documents = make(map[string]*Document)
+	vs.backend.Clear()
+	vs.dimension = vs.configuredDimension
+}
+
+// Dimension returns the dimension of the embeddings in the store, 0 when it
+// is not known yet
+func (vs *VectorStore) Dimension() int {
+	vs.mutex.RLock()
+	defer vs.mutex.RUnlock()
+	return vs.dimension
+}
+
+// EmbeddingModel returns the model the embeddings of the store come from,
+// empty when unknown
+func (vs *VectorStore) EmbeddingModel() string {
+	vs.mutex.RLock()
+	defer vs.mutex.RUnlock()
+	return vs.embeddingModel
+}
+
+// SetEmbeddingModel records the model the embeddings of the store come from
+func (vs *VectorStore) SetEmbeddingModel(model string) {
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+	vs.embeddingModel = model
 }
 
 // FilterByMetadata searches documents that match the given metadata criteria
@@ -193,7 +253,7 @@ func (vs *VectorStore) FilterByMetadata(criteria map[string]interface{}) []*Docu
 
 	for _, doc := range vs.documents {
 		if matchesMetadata(doc.Metadata, criteria) {
-			matches = append(matches, doc)
+			matches = append(matches, vs.withEmbedding(doc))
 		}
 	}
 
@@ -209,35 +269,15 @@ func (vs *VectorStore) SearchWithFilter(queryEmbedding []float32, limit int, met
 		return nil, fmt.Errorf("query embedding dimension mismatch: expected %d, got %d", vs.dimension, len(queryEmbedding))
 	}
 
-	normalizedQuery := normalizeVector(queryEmbedding)
-	var results []*SearchResult
-
-	// Calculate similarity for documents that match the filter
-	for _, doc := range vs.documents {
-		if metadataFilter != nil && !matchesMetadata(doc.Metadata, metadataFilter) {
-			continue
+	var accept func(id string) bool
+	if metadataFilter != nil {
+		accept = func(id string) bool {
+			doc, exists := vs.documents[id]
+			return exists && matchesMetadata(doc.Metadata, metadataFilter)
 		}
-
-		similarity := cosineSimilarity(normalizedQuery, doc.Embedding)
-		result := &SearchResult{
-			Document:   doc,
-			Similarity: similarity,
-			Score:      similarity,
-		}
-		results = append(results, result)
-	}
-
-	// Sort by similarity (descending)
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Similarity > results[j].Similarity
-	})
-
-	// Limit results
-	if limit > 0 && len(results) > limit {
-		results = results[:limit]
 	}
 
-	return results, nil
+	return vs.searchBackend(queryEmbedding, limit, accept), nil
 }
 
 // Export serializes the vector store to JSON
@@ -245,12 +285,19 @@ func (vs *VectorStore) Export() ([]byte, error) {
 	vs.mutex.RLock()
 	defer vs.mutex.RUnlock()
 
+	documents := make(map[string]*Document, len(vs.documents))
+	for id, doc := range vs.documents {
+		documents[id] = vs.withEmbedding(doc)
+	}
+
 	data := struct {
-		Documents map[string]*Document `json:"documents"`
-		Dimension int                  `json:"dimension"`
+		Documents      map[string]*Document `json:"documents"`
+		Dimension      int                  `json:"dimension"`
+		EmbeddingModel string               `json:"embedding_model,omitempty"`
 	}{
-		Documents: vs.documents,
-		Dimension: vs.dimension,
+		Documents:      documents,
+		Dimension:      vs.dimension,
+		EmbeddingModel: vs.embeddingModel,
 	}
 
 	return json.Marshal(data)
@@ -262,16 +309,24 @@ func (vs *VectorStore) Import(data []byte) error {
 	defer vs.mutex.Unlock()
 
 	var importData struct {
-		Documents map[string]*Document `json:"documents"`
-		Dimension int                  `json:"dimension"`
+		Documents      map[string]*Document `json:"documents"`
+		Dimension      int                  `json:"dimension"`
+		EmbeddingModel string               `json:"embedding_model"`
 	}
 
 	if err := json.Unmarshal(data, &importData); err != nil {
 		return fmt.Errorf("failed to unmarshal vector store data: %w", err)
 	}
 
-	vs.documents = importData.Documents
+	vs.documents = make(map[string]*Document, len(importData.Documents))
 	vs.dimension = importData.Dimension
+	vs.embeddingModel = importData.EmbeddingModel
+	vs.backend.Clear()
+	for id, doc := range importData.Documents {
+		vs.backend.Add(id, doc.Embedding)
+		doc.Embedding = nil
+		vs.documents[id] = doc
+	}
 
 	return nil
 }
@@ -0,0 +1,171 @@
+// Package worktree sandboxes runs that change code in a git worktree of their
+// own, on a new branch, so the user's working tree is only touched when they
+// merge the result.
+package worktree
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/castrovroberto/CGE/internal/statedir"
+)
+
+// Sandbox is a git worktree created for one run
+type Sandbox struct {
+	RepoRoot   string // Top of the user's repository
+	Path       string // Top of the worktree
+	Branch     string // Branch checked out in the worktree
+	BaseBranch string // Branch checked out in the repository, "" when detached
+	BaseCommit string // Commit the sandbox branch starts from
+}
+
+var unsafeBranchChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Create adds a worktree for the repository of dir on a new branch named
+// prefix, name and the time, starting from the current commit. The worktree
+// lives in the state directory of the repository.
+func Create(ctx context.Context, dir, prefix, name string) (*Sandbox, error) {
+	root, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("sandboxing needs a git repository: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	base, err := git(ctx, root, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("sandboxing needs at least one commit: %w", err)
+	}
+	baseBranch, _ := git(ctx, root, "symbolic-ref", "--short", "-q", "HEAD")
+
+	name = strings.Trim(unsafeBranchChars.ReplaceAllString(name, "-"), "-")
+	branch := fmt.Sprintf("%s%s-%s", prefix, name, time.Now().Format("20060102-150405"))
+	path := statedir.Path(root, statedir.Worktrees, strings.ReplaceAll(branch, "/", "-"))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	if _, err := git(ctx, root, "worktree", "add", "-q", "-b", branch, path, base); err != nil {
+		return nil, fmt.Errorf("failed to create worktree: %w", err)
+	}
+	return &Sandbox{RepoRoot: root, Path: path, Branch: branch, BaseBranch: baseBranch, BaseCommit: base}, nil
+}
+
+// Dir maps a path in the user's repository to the same path in the worktree.
+// Paths outside the repository are returned unchanged.
+func (s *Sandbox) Dir(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	rel, err := filepath.Rel(s.RepoRoot, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.Join(s.Path, rel)
+}
+
+// Dirty reports whether the user's working tree has uncommitted changes,
+// which the sandbox does not see
+func (s *Sandbox) Dirty(ctx context.Context) bool {
+	status, err := git(ctx, s.RepoRoot, "status", "--porcelain", "--untracked-files=no")
+	return err == nil && status != ""
+}
+
+// Commit commits everything changed in the worktree, except CGE state
+// directories, reporting whether there was anything to commit
+func (s *Sandbox) Commit(ctx context.Context, message string) (bool, error) {
+	if _, err := git(ctx, s.Path, "add", "-A", "--", ".", ":(exclude,glob)**/"+statedir.DirName+"/**"); err != nil {
+		return false, err
+	}
+	if _, err := git(ctx, s.Path, "diff", "--cached", "--quiet"); err == nil {
+		return false, nil
+	}
+	if _, err := git(ctx, s.Path, "commit", "-q", "-m", message); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ChangedFiles returns the files the sandbox branch changed, relative to the
+// top of the repository
+func (s *Sandbox) ChangedFiles(ctx context.Context) ([]string, error) {
+	output, err := git(ctx, s.Path, "diff", "--name-only", s.BaseCommit, "HEAD")
+	if err != nil || output == "" {
+		return nil, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// Merge brings the sandbox branch into the branch the run started from, as a
+// merge commit or, with squash, as a single commit with message. That branch
+// must still be checked out. A merge that fails is aborted, leaving the
+// sandbox branch for the user to merge by hand.
+func (s *Sandbox) Merge(ctx context.Context, squash bool, message string) error {
+	if s.BaseBranch == "" {
+		return fmt.Errorf("the run started on a detached HEAD; merge %s by hand", s.Branch)
+	}
+	current, _ := git(ctx, s.RepoRoot, "symbolic-ref", "--short", "-q", "HEAD")
+	if current != s.BaseBranch {
+		return fmt.Errorf("%s is checked out instead of %s; check it out and merge %s by hand", current, s.BaseBranch, s.Branch)
+	}
+
+	if squash {
+		if _, err := git(ctx, s.RepoRoot, "merge", "--squash", s.Branch); err != nil {
+			_, _ = git(ctx, s.RepoRoot, "reset", "--merge")
+			return fmt.Errorf("squash merge of %s failed: %w", s.Branch, err)
+		}
+		if _, err := git(ctx, s.RepoRoot, "commit", "-q", "-m", message); err != nil {
+			return fmt.Errorf("failed to commit the squashed changes: %w", err)
+		}
+		return nil
+	}
+	if _, err := git(ctx, s.RepoRoot, "merge", "--no-ff", "-q", "-m", message, s.Branch); err != nil {
+		_, _ = git(ctx, s.RepoRoot, "merge", "--abort")
+		return fmt.Errorf("merge of %s failed: %w", s.Branch, err)
+	}
+	return nil
+}
+
+// Remove deletes the worktree and, unless keepBranch is set, its branch
+func (s *Sandbox) Remove(ctx context.Context, keepBranch bool) error {
+	if _, err := git(ctx, s.RepoRoot, "worktree", "remove", "--force", s.Path); err != nil {
+		// A worktree whose directory is gone is pruned instead
+		_ = os.RemoveAll(s.Path)
+		if _, pruneErr := git(ctx, s.RepoRoot, "worktree", "prune"); pruneErr != nil {
+			return err
+		}
+	}
+	if keepBranch {
+		return nil
+	}
+	if _, err := git(ctx, s.RepoRoot, "branch", "-D", s.Branch); err != nil {
+		return err
+	}
+	return nil
+}
+
+// git runs git in dir, returning its trimmed output; errors carry git's
+// message
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
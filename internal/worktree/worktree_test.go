@@ -0,0 +1,106 @@
+package worktree
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// initRepo creates a repository on main with one committed file
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "src"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main\n"), 0644))
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "-A"},
+		{"commit", "-q", "-m", "initial"},
+	} {
+		_, err := git(context.Background(), dir, args...)
+		require.NoError(t, err)
+	}
+	return dir
+}
+
+func TestSandboxMerge(t *testing.T) {
+	ctx := context.Background()
+	repo := initRepo(t)
+
+	sandbox, err := Create(ctx, filepath.Join(repo, "src"), "cge/", "fix tests")
+	require.NoError(t, err)
+	assert.Equal(t, "main", sandbox.BaseBranch)
+	assert.Regexp(t, `^cge/fix-tests-\d{8}-\d{6}$`, sandbox.Branch)
+
+	// Changes in the sandbox leave the working tree alone
+	srcDir := sandbox.Dir(filepath.Join(repo, "src"))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, ".cge", "reports"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, ".cge", "reports", "raw.txt"), []byte("state"), 0644))
+	content, err := os.ReadFile(filepath.Join(repo, "src", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(content))
+
+	committed, err := sandbox.Commit(ctx, "Add main")
+	require.NoError(t, err)
+	assert.True(t, committed)
+	files, err := sandbox.ChangedFiles(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"src/main.go"}, files)
+
+	require.NoError(t, sandbox.Merge(ctx, true, "Add main"))
+	require.NoError(t, sandbox.Remove(ctx, false))
+
+	content, err = os.ReadFile(filepath.Join(repo, "src", "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "func main()")
+	subject, err := git(ctx, repo, "log", "-1", "--format=%s")
+	require.NoError(t, err)
+	assert.Equal(t, "Add main", subject)
+	_, err = git(ctx, repo, "rev-parse", "--verify", "--quiet", sandbox.Branch)
+	assert.Error(t, err, "the branch is deleted")
+	assert.NoDirExists(t, sandbox.Path)
+}
+
+func TestSandboxDiscard(t *testing.T) {
+	ctx := context.Background()
+	repo := initRepo(t)
+
+	sandbox, err := Create(ctx, repo, "cge/", "generate")
+	require.NoError(t, err)
+	committed, err := sandbox.Commit(ctx, "nothing")
+	require.NoError(t, err)
+	assert.False(t, committed)
+
+	require.NoError(t, sandbox.Remove(ctx, true))
+	_, err = git(ctx, repo, "rev-parse", "--verify", "--quiet", sandbox.Branch)
+	assert.NoError(t, err, "the branch is kept")
+	assert.NoDirExists(t, sandbox.Path)
+}
+
+func TestSandboxDir(t *testing.T) {
+	sandbox := &Sandbox{RepoRoot: "/repo", Path: "/repo/.cge/worktrees/b"}
+	assert.Equal(t, filepath.FromSlash("/repo/.cge/worktrees/b/src"), sandbox.Dir("/repo/src"))
+	assert.Equal(t, "/elsewhere", sandbox.Dir("/elsewhere"))
+}
+
+func TestCreateOutsideRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	_, err := Create(context.Background(), t.TempDir(), "cge/", "run")
+	assert.Error(t, err)
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/castrovroberto/CGE/cmd"
 )
@@ -31,11 +32,24 @@ func main() {
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error during command execution: %v\n", err)
 		}
+		cancel()
+		os.Exit(cmd.ExitCode(err))
 	case sig := <-osSignalChan:
 		fmt.Printf("\nReceived signal: %s. Initiating shutdown...\n", sig)
+		// Agent runs finish their tool call in flight and pause their
+		// sessions, within the grace period; a second signal stops at once
+		if runsStopped, grace := cmd.RequestShutdown(sig); runsStopped != nil {
+			fmt.Printf("Pausing the agent run once its current step finishes (up to %s); send the signal again to stop at once.\n", grace)
+			select {
+			case <-runsStopped:
+			case <-time.After(grace):
+			case <-osSignalChan:
+			}
+		}
 		cancel()
 
-		<-done
+		err := <-done
 		fmt.Println("Shutdown complete.")
+		os.Exit(cmd.ExitCode(err))
 	}
 }